@@ -3,16 +3,21 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/mhrivnak/ssvirt/pkg/api"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/commands"
 	"github.com/mhrivnak/ssvirt/pkg/config"
 	"github.com/mhrivnak/ssvirt/pkg/database"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
 	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
@@ -23,6 +28,17 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Configure password hashing before any passwords are hashed or verified
+	if err := auth.ConfigurePasswordHashing(cfg); err != nil {
+		log.Fatalf("Invalid password hashing configuration: %v", err)
+	}
+	if err := services.ValidateMetadataPolicy(models.MetadataPolicy{
+		Labels:      cfg.Instantiation.Labels,
+		Annotations: cfg.Instantiation.Annotations,
+	}); err != nil {
+		log.Fatalf("Invalid instantiation metadata configuration: %v", err)
+	}
+
 	// Initialize database connection with retry logic
 	ctx := context.Background()
 	retryConfig := database.RetryConfigFromConfig(cfg)
@@ -40,6 +56,15 @@ func main() {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// Give VDCs with invalid namespace names a valid one
+	migratedVDCs, err := db.MigrateVDCNamespaces(context.Background())
+	if err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Printf("Failed to close database connection: %v", closeErr)
+		}
+		log.Fatalf("Failed to migrate VDC namespaces: %v", err)
+	}
+
 	// Bootstrap default data (roles and organizations)
 	if err := db.BootstrapDefaultData(); err != nil {
 		if closeErr := db.Close(); closeErr != nil {
@@ -71,8 +96,14 @@ func main() {
 	vappRepo := repositories.NewVAppRepository(db.DB)
 	vmRepo := repositories.NewVMRepository(db.DB)
 
+	// Enable access through the organization hierarchy if configured
+	orgRepo.SetHierarchicalAccess(cfg.Organizations.HierarchicalAccess)
+	vdcRepo.SetHierarchicalAccess(cfg.Organizations.HierarchicalAccess)
+	catalogRepo.SetHierarchicalAccess(cfg.Organizations.HierarchicalAccess)
+
 	// Initialize authentication services
 	jwtManager := auth.NewJWTManager(cfg.Auth.JWTSecret, cfg.Auth.TokenExpiry)
+	jwtManager.SetClaimsValidation(cfg.Auth.Issuer, cfg.Auth.Audience, cfg.Auth.ClockSkew)
 	authSvc := auth.NewService(userRepo, jwtManager)
 
 	// Initialize template service
@@ -86,10 +117,45 @@ func main() {
 	if templateNamespace == "" {
 		templateNamespace = "openshift"
 	}
-	k8sService, err := services.NewKubernetesService(templateNamespace, log.Default())
+	k8sService, err := services.NewKubernetesService(templateNamespace, log.Default(), services.KubernetesServiceOptions{
+		CacheResync:       cfg.Kubernetes.Cache.ResyncPeriod,
+		CacheSyncTimeout:  cfg.Kubernetes.Cache.SyncTimeout,
+		NamespaceSelector: cfg.Kubernetes.Cache.NamespaceSelector,
+		Writes: services.NamespaceWriteLimits{
+			QPS:             cfg.Kubernetes.Writes.QPS,
+			Burst:           cfg.Kubernetes.Writes.Burst,
+			QueueDepth:      cfg.Kubernetes.Writes.QueueDepth,
+			BreakerCooldown: cfg.Kubernetes.Writes.BreakerCooldown,
+		},
+	})
 	if err != nil {
 		log.Printf("Warning: Failed to initialize Kubernetes service: %v", err)
 		log.Println("Continuing without Kubernetes integration...")
+	} else {
+		if err := services.RegisterKubernetesWriteMetrics(prometheus.DefaultRegisterer); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		objects := cfg.Quota.Objects
+		k8sService.SetDefaultObjectQuota(models.ObjectQuota{
+			Pods:                   &objects.Pods,
+			PersistentVolumeClaims: &objects.PersistentVolumeClaims,
+			Services:               &objects.Services,
+			Secrets:                &objects.Secrets,
+			ConfigMaps:             &objects.ConfigMaps,
+		})
+	}
+
+	// Create the namespaces of VDCs renamed by the namespace migration
+	if k8sService != nil {
+		for i := range migratedVDCs {
+			vdc := &migratedVDCs[i]
+			if vdc.Organization == nil {
+				continue
+			}
+			if err := k8sService.EnsureNamespaceForVDC(context.Background(), vdc, vdc.Organization); err != nil {
+				log.Printf("Warning: Failed to create namespace %s for VDC %s: %v", vdc.Namespace, vdc.ID, err)
+			}
+		}
 	}
 
 	// Create cancelable context for services
@@ -103,6 +169,10 @@ func main() {
 		}
 	}()
 
+	// Keep persisted catalog items in sync with OpenShift Templates
+	catalogItemSyncer := services.NewCatalogItemSyncer(templateService, repositories.NewCatalogItemRepository(db.DB, catalogRepo), services.DefaultCatalogItemResyncInterval, slog.Default())
+	go catalogItemSyncer.Start(serviceCtx)
+
 	// Start Kubernetes service if available
 	if k8sService != nil {
 		go func() {
@@ -116,6 +186,78 @@ func main() {
 	var templateServiceInterface services.TemplateServiceInterface = templateService
 	server := api.NewServer(cfg, db, authSvc, jwtManager, userRepo, roleRepo, orgRepo, vdcRepo, catalogRepo, templateRepo, vappRepo, vmRepo, templateServiceInterface, k8sService)
 
+	// Check that the template namespaces exist and templates can be
+	// instantiated, reporting problems through /readyz
+	if k8sService != nil {
+		templateAccess, err := services.NewTemplateAccessChecker([]string{templateNamespace, services.CatalogTemplateNamespace}, services.DefaultTemplateAccessCheckInterval, slog.Default())
+		if err != nil {
+			log.Printf("Warning: Failed to create template access checker: %v", err)
+		} else {
+			go templateAccess.Start(serviceCtx)
+			server.SetTemplateAccessChecker(templateAccess)
+		}
+	}
+
+	taskTracker := events.NewVMTaskTracker(repositories.NewTaskRepository(db.DB), server.EventBus(), slog.Default())
+
+	// Publish VM status transitions written by the VM controller to the event bus
+	if cfg.Notifications.PollInterval > 0 {
+		vmPoller := events.NewVMStatusPoller(vmRepo, server.EventBus(), cfg.Notifications.PollInterval, slog.Default())
+		go vmPoller.Start(serviceCtx)
+
+		// Complete VM power tasks as the polled status transitions arrive
+		go taskTracker.Start(serviceCtx)
+	}
+
+	// Send power operations to the vm-controller and apply the results it reports
+	if cfg.InternalAPI.ControllerURL != "" {
+		tlsFiles := commands.TLSFiles{
+			CertFile: cfg.InternalAPI.TLSCert,
+			KeyFile:  cfg.InternalAPI.TLSKey,
+			CAFile:   cfg.InternalAPI.CACert,
+		}
+		clientTLS, err := commands.ClientTLSConfig(tlsFiles)
+		if err != nil {
+			log.Fatalf("Failed to load internal API client certificates: %v", err)
+		}
+		serverTLS, err := commands.ServerTLSConfig(tlsFiles)
+		if err != nil {
+			log.Fatalf("Failed to load internal API server certificates: %v", err)
+		}
+
+		client := commands.NewClient(clientTLS, commands.RetryPolicy{
+			Attempts:  cfg.InternalAPI.RetryAttempts,
+			BaseDelay: cfg.InternalAPI.RetryBaseDelay,
+		})
+		server.SetCommandDispatcher(commands.NewDispatcher(client, cfg.InternalAPI.ControllerURL, cfg.InternalAPI.CallbackURL))
+
+		go func() {
+			if err := commands.Serve(serviceCtx, cfg.InternalAPI.ListenAddress, serverTLS, commands.NewResultHandler(taskTracker, slog.Default())); err != nil {
+				log.Printf("Internal API server error: %v", err)
+			}
+		}()
+		log.Printf("Dispatching VM commands to %s", cfg.InternalAPI.ControllerURL)
+	}
+
+	// Serve Prometheus metrics on their own listener
+	if cfg.API.MetricsAddress != "" {
+		go func() {
+			if err := api.ServeMetrics(serviceCtx, cfg.API.MetricsAddress); err != nil {
+				log.Printf("Metrics server error: %v", err)
+			}
+		}()
+	}
+
+	// Write per-user API usage counts to the database
+	if recorder := server.APIUsageRecorder(); recorder != nil {
+		go recorder.Start(serviceCtx)
+	}
+
+	// Move VM records that stayed DELETED to the archive
+	if archiver := server.VMArchiver(); archiver != nil {
+		go archiver.Start(serviceCtx)
+	}
+
 	// Start server in a goroutine
 	go func() {
 		if err := server.Start(); err != nil {
@@ -132,13 +274,20 @@ func main() {
 	// Cancel service contexts first
 	serviceCancel()
 
-	// Give the server 30 seconds to finish current requests
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Give the server time to finish current requests and background operations
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.API.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Stop(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Keep the usage of the requests completed during shutdown
+	if recorder := server.APIUsageRecorder(); recorder != nil {
+		if err := recorder.Flush(context.Background()); err != nil {
+			log.Printf("Warning: Failed to flush API usage: %v", err)
+		}
+	}
+
 	log.Println("Server exited")
 }
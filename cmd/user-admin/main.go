@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/mhrivnak/ssvirt/pkg/auth"
 	"github.com/mhrivnak/ssvirt/pkg/config"
@@ -18,6 +22,9 @@ func main() {
 		fmt.Println("Commands:")
 		fmt.Println("  create-user <username> <email> <password> [full_name] [description]")
 		fmt.Println("  list-users")
+		fmt.Println("  seed --profile <" + strings.Join(database.SeedProfileNames(), "|") + "> --password <password>")
+		fmt.Println("  apply -f <tenants.yaml> [--dry-run] [--prune] [--initial-password <password>]")
+		fmt.Println("  export [-o <tenants.yaml>]")
 		os.Exit(1)
 	}
 
@@ -26,6 +33,10 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	if err := auth.ConfigurePasswordHashing(cfg); err != nil {
+		log.Fatalf("Invalid password hashing configuration: %v", err)
+	}
+
 	db, err := database.NewConnection(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -88,6 +99,100 @@ func main() {
 			fmt.Printf("- %s (%s) - %s\n", user.Username, user.Email, user.FullName)
 		}
 
+	case "seed":
+		flags := flag.NewFlagSet("seed", flag.ExitOnError)
+		profile := flags.String("profile", "", "Seed profile to load ("+strings.Join(database.SeedProfileNames(), ", ")+")")
+		password := flags.String("password", os.Getenv("SSVIRT_SEED_PASSWORD"), "Password for every seeded user (default $SSVIRT_SEED_PASSWORD)")
+		_ = flags.Parse(os.Args[2:])
+		if *profile == "" || *password == "" {
+			fmt.Println("Usage: user-admin seed --profile <" + strings.Join(database.SeedProfileNames(), "|") + "> --password <password>")
+			os.Exit(1)
+		}
+
+		result, err := db.Seed(context.Background(), *profile, *password)
+		if err != nil {
+			log.Fatalf("Failed to seed database: %v", err)
+		}
+
+		fmt.Printf("Seeded %s profile!\n", *profile)
+		fmt.Printf("Organizations: %d\n", result.Organizations)
+		fmt.Printf("Users: %d\n", result.Users)
+		fmt.Printf("VDCs: %d\n", result.VDCs)
+		fmt.Printf("Catalogs: %d\n", result.Catalogs)
+		fmt.Printf("vApps: %d\n", result.VApps)
+		fmt.Printf("VMs: %d\n", result.VMs)
+
+	case "apply":
+		flags := flag.NewFlagSet("apply", flag.ExitOnError)
+		file := flags.String("f", "", "Tenant spec to apply, or - for stdin")
+		dryRun := flags.Bool("dry-run", false, "Print the changes without making them")
+		prune := flags.Bool("prune", false, "Delete organizations, users, VDCs and catalogs the spec does not declare")
+		password := flags.String("initial-password", os.Getenv("SSVIRT_INITIAL_PASSWORD"), "Password for new users without one in the spec (default $SSVIRT_INITIAL_PASSWORD)")
+		_ = flags.Parse(os.Args[2:])
+		if *file == "" {
+			fmt.Println("Usage: user-admin apply -f <tenants.yaml> [--dry-run] [--prune] [--initial-password <password>]")
+			os.Exit(1)
+		}
+
+		var data []byte
+		if *file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(*file)
+		}
+		if err != nil {
+			log.Fatalf("Failed to read tenant spec: %v", err)
+		}
+		spec, err := database.ParseTenantSpec(data)
+		if err != nil {
+			log.Fatalf("Failed to parse tenant spec: %v", err)
+		}
+
+		result, err := db.ApplyTenants(context.Background(), spec, database.TenantApplyOptions{
+			DryRun:          *dryRun,
+			Prune:           *prune,
+			InitialPassword: *password,
+		})
+		if err != nil {
+			log.Fatalf("Failed to apply tenant spec: %v", err)
+		}
+
+		for _, change := range result.Changes {
+			line := fmt.Sprintf("%s %s %s", change.Action, change.Kind, change.Name)
+			if len(change.Fields) > 0 {
+				line += " (" + strings.Join(change.Fields, ", ") + ")"
+			}
+			fmt.Println(line)
+		}
+		if result.DryRun {
+			fmt.Printf("%d changes would be made (dry run)\n", len(result.Changes))
+		} else {
+			fmt.Printf("%d changes made\n", len(result.Changes))
+		}
+		// Without a cluster connection only the database is changed
+		if !result.DryRun && len(result.CreatedVDCs)+len(result.DeletedVDCs) > 0 {
+			fmt.Println("VDC namespaces were not created or deleted; apply through the API to manage them")
+		}
+
+	case "export":
+		flags := flag.NewFlagSet("export", flag.ExitOnError)
+		output := flags.String("o", "", "File to write the tenant spec to (default stdout)")
+		_ = flags.Parse(os.Args[2:])
+
+		spec, err := db.ExportTenants(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to export tenants: %v", err)
+		}
+		data, err := database.MarshalTenantSpec(spec)
+		if err != nil {
+			log.Fatalf("Failed to encode tenant spec: %v", err)
+		}
+		if *output == "" {
+			_, _ = os.Stdout.Write(data)
+		} else if err := os.WriteFile(*output, data, 0o600); err != nil {
+			log.Fatalf("Failed to write tenant spec: %v", err)
+		}
+
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		os.Exit(1)
@@ -2,23 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	templatev1 "github.com/openshift/api/template/v1"
+	userv1 "github.com/openshift/api/user/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	kubevirtv1 "kubevirt.io/api/core/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer-api/pkg/apis/core/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
+	"github.com/mhrivnak/ssvirt/pkg/commands"
 	"github.com/mhrivnak/ssvirt/pkg/config"
 	"github.com/mhrivnak/ssvirt/pkg/controllers"
 	"github.com/mhrivnak/ssvirt/pkg/database"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+	"github.com/mhrivnak/ssvirt/pkg/version"
 )
 
 var (
@@ -26,10 +39,56 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// Controller names accepted by --controllers
+const (
+	controllerVMStatus           = "vmstatus"
+	controllerVAppStatus         = "vappstatus"
+	controllerTemplateValidation = "templatevalidation"
+	controllerStorageUsage       = "storageusage"
+	controllerAutoSuspend        = "autosuspend"
+	controllerCatalogSync        = "catalogsync"
+	controllerCommands           = "commands"
+	controllerPowerState         = "powerstate"
+	controllerJanitor            = "janitor"
+	controllerGroupSync          = "groupsync"
+	controllerExternalDNS        = "externaldns"
+	controllerVDCConditions      = "vdcconditions"
+	controllerPrewarm            = "prewarm"
+)
+
+// heartbeatComponent identifies this process in heartbeats
+const heartbeatComponent = "vm-controller"
+
+// allControllers lists every controller in the order they are registered
+var allControllers = []string{controllerVMStatus, controllerVAppStatus, controllerPowerState, controllerTemplateValidation, controllerStorageUsage, controllerAutoSuspend, controllerCatalogSync, controllerCommands, controllerJanitor, controllerGroupSync, controllerExternalDNS, controllerVDCConditions, controllerPrewarm}
+
+// defaultControllers lists the controllers run when --controllers is not set.
+// Template validation is optional because it writes to catalog Templates;
+// storage usage is optional because it watches every PersistentVolumeClaim;
+// auto-suspend is optional because it needs metrics-server; catalog sync is
+// optional because it writes catalog Templates from remote sources; commands is
+// optional because it needs the internal API certificates; janitor is optional
+// because it deletes Secrets and TemplateInstances; group sync is optional
+// because it needs group mappings and overwrites users' roles; ExternalDNS is
+// optional because it needs ExternalDNS and its DNSEndpoint resource; VDC
+// conditions is optional because it watches every Namespace and ResourceQuota;
+// prewarm is optional because it needs CDI and stores images in VDC namespaces.
+var defaultControllers = []string{controllerVMStatus, controllerVAppStatus, controllerPowerState}
+
+// legacyControllers are the controllers that ran under the original lease,
+// before power state reconciliation was added to the defaults
+var legacyControllers = []string{controllerVMStatus, controllerVAppStatus}
+
+// legacyLeaderElectionID is the lease used when all controllers run in one
+// deployment, matching the lease name from before controllers could be split
+const legacyLeaderElectionID = "ssvirt-vm-controller"
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(kubevirtv1.AddToScheme(scheme))
 	utilruntime.Must(templatev1.AddToScheme(scheme))
+	utilruntime.Must(userv1.AddToScheme(scheme))
+	utilruntime.Must(cdiv1.AddToScheme(scheme))
 }
 
 func main() {
@@ -38,12 +97,22 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var enablePprof bool
+	var controllerList string
+	var leaderElectionID string
+	var stallTimeout time.Duration
+	var templateNamespace string
+	var heartbeatInterval time.Duration
 
 	flag.StringVar(&configPath, "config", "/etc/ssvirt/config.yaml", "Path to configuration file")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", true, "Enable leader election for controller manager.")
 	flag.BoolVar(&enablePprof, "enable-pprof", false, "Enable pprof endpoint for debugging.")
+	flag.StringVar(&controllerList, "controllers", strings.Join(defaultControllers, ","), "Comma-separated controllers to run: "+strings.Join(allControllers, ", ")+".")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "", "Leader election lease name. Defaults to a name derived from --controllers so split deployments use independent leases.")
+	flag.DurationVar(&stallTimeout, "reconcile-stall-timeout", controllers.DefaultReconcileStallTimeout, "Report not ready when a reconcile runs longer than this while leader.")
+	flag.StringVar(&templateNamespace, "template-namespace", defaultTemplateNamespace(), "Namespace of the catalog Templates checked by the templatevalidation controller, imported by the catalogsync controller and prewarmed by the prewarm controller.")
+	flag.DurationVar(&heartbeatInterval, "heartbeat-interval", controllers.DefaultHeartbeatInterval, "How often to record this replica's heartbeat in the database, shown at /api/admin/system/components. 0 disables heartbeats.")
 
 	opts := zap.Options{
 		Development: false,
@@ -53,11 +122,23 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	setupLog.Info("Starting VM Status Controller",
+	enabled, err := parseControllers(controllerList)
+	if err != nil {
+		setupLog.Error(err, "Invalid --controllers value")
+		os.Exit(1)
+	}
+	if leaderElectionID == "" {
+		leaderElectionID = defaultLeaderElectionID(enabled)
+	}
+
+	setupLog.Info("Starting SSVirt controllers",
+		"version", version.Get(),
 		"config", configPath,
+		"controllers", enabled,
 		"metrics-addr", metricsAddr,
 		"probe-addr", probeAddr,
 		"leader-election", enableLeaderElection,
+		"leader-election-id", leaderElectionID,
 	)
 
 	// Load configuration
@@ -93,7 +174,7 @@ func main() {
 		Metrics:                server.Options{BindAddress: metricsAddr},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "ssvirt-vm-controller",
+		LeaderElectionID:       leaderElectionID,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -106,24 +187,214 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Setup VM Status Controller
-	if err = controllers.SetupVMStatusController(mgr, vmRepo, vappRepo, vdcRepo); err != nil {
-		setupLog.Error(err, "Unable to create controller", "controller", "VMStatus")
+	// Expose connection pool and slow query metrics alongside the controller metrics
+	if err := db.RegisterMetrics(metrics.Registry); err != nil {
+		setupLog.Error(err, "Unable to register database metrics")
 		os.Exit(1)
 	}
 
-	// Setup VApp Status Controller
-	if err = controllers.SetupVAppStatusController(mgr, vappRepo, vmRepo, vdcRepo); err != nil {
-		setupLog.Error(err, "Unable to create controller", "controller", "VAppStatus")
-		os.Exit(1)
+	// Email system events when an SMTP server is configured
+	var mailer services.Notifier
+	if cfg.Notifications.Email.Enabled {
+		mailer, err = services.NewSMTPNotifier(cfg.Notifications.Email)
+		if err != nil {
+			setupLog.Error(err, "Invalid email notification configuration")
+			os.Exit(1)
+		}
 	}
 
-	// Add health checks
+	// Setup the selected controllers, tracking reconciles for readiness
+	var trackers []*controllers.ReconcileHealth
+	for _, name := range enabled {
+		switch name {
+		case controllerVMStatus:
+			health := controllers.NewReconcileHealth(controllers.VMStatusControllerName, stallTimeout)
+			trackers = append(trackers, health)
+
+			// Hold status updates in memory while the database is unavailable
+			var statusBuffer *controllers.StatusBuffer
+			if bufferCfg := cfg.Controllers.VMStatus.StatusBuffer; bufferCfg.Enabled {
+				policy, policyErr := controllers.ParseOverflowPolicy(bufferCfg.OverflowPolicy)
+				if policyErr != nil {
+					setupLog.Error(policyErr, "Invalid VM status buffer configuration")
+					os.Exit(1)
+				}
+				statusBuffer = controllers.NewStatusBuffer(vmRepo, controllers.StatusBufferOptions{
+					Capacity:       bufferCfg.Capacity,
+					Policy:         policy,
+					ReplayInterval: bufferCfg.ReplayInterval,
+				})
+			}
+
+			err = controllers.SetupVMStatusController(mgr, vmRepo, vappRepo, vdcRepo, controllers.ControllerOptions{
+				MaxConcurrentReconciles: cfg.Controllers.VMStatus.MaxConcurrentReconciles,
+				RateLimiter: controllers.NewReconcileRateLimiter(controllers.VMStatusControllerName, controllers.RateLimiterOptions{
+					BaseDelay:      cfg.Controllers.VMStatus.RetryBaseDelay,
+					MaxDelay:       cfg.Controllers.VMStatus.RetryMaxDelay,
+					NamespaceQPS:   cfg.Controllers.VMStatus.NamespaceRetryQPS,
+					NamespaceBurst: cfg.Controllers.VMStatus.NamespaceRetryBurst,
+				}),
+				Health:             health,
+				StatusBuffer:       statusBuffer,
+				LabelSweepInterval: cfg.Controllers.VMStatus.LabelSweepInterval,
+			})
+		case controllerVAppStatus:
+			health := controllers.NewReconcileHealth(controllers.VAppStatusControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			alerts := controllers.ProvisioningAlerts{Notifier: mailer, After: cfg.Controllers.VAppStatus.StuckAlertAfter}
+			err = controllers.SetupVAppStatusController(mgr, vappRepo, vmRepo, vdcRepo, alerts, controllers.ControllerOptions{
+				MaxConcurrentReconciles: cfg.Controllers.VAppStatus.MaxConcurrentReconciles,
+				Health:                  health,
+			})
+		case controllerPowerState:
+			health := controllers.NewReconcileHealth(controllers.PowerStateControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			err = controllers.SetupPowerStateController(mgr, vmRepo,
+				cfg.Controllers.PowerState.PollInterval,
+				cfg.Controllers.PowerState.RetryMaxDelay,
+				controllers.ControllerOptions{Health: health})
+		case controllerTemplateValidation:
+			health := controllers.NewReconcileHealth(controllers.TemplateValidationControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			err = controllers.SetupTemplateValidationController(mgr, templateNamespace, controllers.ControllerOptions{
+				Health: health,
+			})
+		case controllerStorageUsage:
+			health := controllers.NewReconcileHealth(controllers.StorageUsageControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			var notifiers controllers.StorageAlertNotifiers
+			if storageCfg := cfg.Controllers.StorageUsage; storageCfg.AlertWebhookURL != "" {
+				notifiers = append(notifiers, controllers.NewStorageAlertWebhook(storageCfg.AlertWebhookURL, storageCfg.AlertWebhookTimeout))
+			}
+			if mailer != nil {
+				notifiers = append(notifiers, &controllers.StorageAlertMailer{Notifier: mailer})
+			}
+			var notifier controllers.StorageAlertNotifier
+			if len(notifiers) > 0 {
+				notifier = notifiers
+			}
+			err = controllers.SetupStorageUsageController(mgr, vdcRepo, notifier, controllers.ControllerOptions{
+				Health: health,
+			})
+		case controllerAutoSuspend:
+			health := controllers.NewReconcileHealth(controllers.AutoSuspendControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			// Pausing VMs needs a KubeVirt subresource the manager's client cannot call
+			clientset, clientErr := kubernetes.NewForConfig(mgr.GetConfig())
+			if clientErr != nil {
+				setupLog.Error(clientErr, "Unable to create Kubernetes clientset")
+				os.Exit(1)
+			}
+			err = controllers.SetupAutoSuspendController(mgr, vdcRepo, vmRepo,
+				services.NewMetricsService(mgr.GetAPIReader()),
+				services.NewVMIPauser(clientset.CoreV1().RESTClient()),
+				mailer,
+				controllers.AutoSuspendOptions{
+					Interval:     cfg.Controllers.AutoSuspend.Interval,
+					NoticePeriod: cfg.Controllers.AutoSuspend.NoticePeriod,
+				},
+				controllers.ControllerOptions{Health: health})
+		case controllerCatalogSync:
+			health := controllers.NewReconcileHealth(controllers.CatalogSyncControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			err = controllers.SetupCatalogSyncController(mgr,
+				repositories.NewCatalogSourceRepository(db.DB),
+				services.NewCatalogSourceFetcher(cfg.Controllers.CatalogSync.FetchTimeout),
+				templateNamespace,
+				cfg.Controllers.CatalogSync.PollInterval,
+				controllers.ControllerOptions{Health: health})
+		case controllerCommands:
+			err = setupCommandServer(mgr, cfg)
+		case controllerJanitor:
+			health := controllers.NewReconcileHealth(controllers.JanitorControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			err = controllers.SetupJanitorController(mgr, controllers.JanitorOptions{
+				Interval:                cfg.Controllers.Janitor.Interval,
+				SecretGracePeriod:       cfg.Controllers.Janitor.SecretGracePeriod,
+				FailedInstanceRetention: cfg.Controllers.Janitor.FailedInstanceRetention,
+			}, controllers.ControllerOptions{Health: health})
+		case controllerGroupSync:
+			if len(cfg.GroupSync.Mappings) == 0 {
+				// Without mappings every synced user would be removed from their organization
+				err = errors.New("group_sync.mappings must not be empty")
+				break
+			}
+			health := controllers.NewReconcileHealth(controllers.GroupSyncControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			err = controllers.SetupGroupSyncController(mgr,
+				services.NewGroupSyncService(mgr.GetAPIReader(),
+					repositories.NewUserRepository(db.DB),
+					repositories.NewOrganizationRepository(db.DB),
+					repositories.NewRoleRepository(db.DB),
+					cfg.GroupSync.Mappings),
+				cfg.GroupSync.Interval,
+				controllers.ControllerOptions{Health: health})
+		case controllerExternalDNS:
+			health := controllers.NewReconcileHealth(controllers.ExternalDNSControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			err = controllers.SetupExternalDNSController(mgr, vmRepo, vdcRepo, controllers.ControllerOptions{
+				Health: health,
+			})
+		case controllerVDCConditions:
+			health := controllers.NewReconcileHealth(controllers.VDCConditionsControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			err = controllers.SetupVDCConditionsController(mgr, vdcRepo, controllers.ControllerOptions{
+				Health: health,
+			})
+		case controllerPrewarm:
+			health := controllers.NewReconcileHealth(controllers.PrewarmControllerName, stallTimeout)
+			trackers = append(trackers, health)
+			err = controllers.SetupPrewarmController(mgr,
+				repositories.NewCatalogItemPinRepository(db.DB),
+				templateNamespace,
+				cfg.Controllers.Prewarm.PollInterval,
+				controllers.ControllerOptions{Health: health})
+		}
+		if err != nil {
+			setupLog.Error(err, "Unable to create controller", "controller", name)
+			os.Exit(1)
+		}
+	}
+
+	// Record a heartbeat so operators can see this replica and its build
+	if heartbeatInterval > 0 {
+		instanceID, hostErr := os.Hostname()
+		if hostErr != nil {
+			setupLog.Error(hostErr, "Unable to determine instance ID for heartbeats")
+			os.Exit(1)
+		}
+		heartbeat := controllers.NewHeartbeat(repositories.NewComponentHeartbeatRepository(db.DB), controllers.HeartbeatOptions{
+			InstanceID: instanceID,
+			Component:  heartbeatComponent,
+			Version:    version.Get(),
+			Interval:   heartbeatInterval,
+			Elected:    mgr.Elected(),
+			Trackers:   trackers,
+		})
+		if err := mgr.Add(heartbeat); err != nil {
+			setupLog.Error(err, "Unable to set up heartbeat")
+			os.Exit(1)
+		}
+	}
+
+	// Add health checks. Readiness requires synced informer caches and, on the
+	// leader, controllers whose reconciles are not stalled.
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "Unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	if err := mgr.AddReadyzCheck("informers", func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), time.Second)
+		defer cancel()
+		if !mgr.GetCache().WaitForCacheSync(ctx) {
+			return errors.New("informer caches not synced")
+		}
+		return nil
+	}); err != nil {
+		setupLog.Error(err, "Unable to set up ready check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("reconcilers", controllers.LeaderAwareReadyzCheck(mgr.Elected(), trackers...)); err != nil {
 		setupLog.Error(err, "Unable to set up ready check")
 		os.Exit(1)
 	}
@@ -133,10 +404,109 @@ func main() {
 		setupLog.Info("pprof enabled - endpoints available at /debug/pprof/")
 	}
 
-	setupLog.Info("Starting manager with singleton leader election")
+	setupLog.Info("Starting manager", "leader-election-id", leaderElectionID)
 	ctx := ctrl.SetupSignalHandler()
 	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "Problem running manager")
 		os.Exit(1)
 	}
 }
+
+// parseControllers validates a comma-separated list of controller names and
+// returns them in registration order without duplicates
+func parseControllers(value string) ([]string, error) {
+	requested := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, c := range allControllers {
+			if c == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown controller %q, must be one of: %s", name, strings.Join(allControllers, ", "))
+		}
+		requested[name] = true
+	}
+	if len(requested) == 0 {
+		return nil, errors.New("at least one controller must be enabled")
+	}
+
+	var enabled []string
+	for _, c := range allControllers {
+		if requested[c] {
+			enabled = append(enabled, c)
+		}
+	}
+	return enabled, nil
+}
+
+// defaultLeaderElectionID derives the lease name for a set of controllers. Running
+// every legacy controller keeps the original lease so upgrades do not create a
+// second leader.
+func defaultLeaderElectionID(enabled []string) string {
+	running := make(map[string]bool, len(enabled))
+	for _, name := range enabled {
+		running[name] = true
+	}
+	legacy := true
+	for _, name := range legacyControllers {
+		legacy = legacy && running[name]
+	}
+	if legacy {
+		return legacyLeaderElectionID
+	}
+	names := append([]string(nil), enabled...)
+	sort.Strings(names)
+	return "ssvirt-" + strings.Join(names, "-") + "-controller"
+}
+
+// defaultTemplateNamespace returns the catalog Template namespace, matching the
+// API server's TEMPLATE_NAMESPACE setting
+func defaultTemplateNamespace() string {
+	if namespace := os.Getenv("TEMPLATE_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return "openshift"
+}
+
+// setupCommandServer serves the commands the API server sends over the
+// internal API and reports their results back to it
+func setupCommandServer(mgr ctrl.Manager, cfg *config.Config) error {
+	tlsFiles := commands.TLSFiles{
+		CertFile: cfg.InternalAPI.TLSCert,
+		KeyFile:  cfg.InternalAPI.TLSKey,
+		CAFile:   cfg.InternalAPI.CACert,
+	}
+	serverTLS, err := commands.ServerTLSConfig(tlsFiles)
+	if err != nil {
+		return fmt.Errorf("failed to load internal API server certificates: %w", err)
+	}
+	clientTLS, err := commands.ClientTLSConfig(tlsFiles)
+	if err != nil {
+		return fmt.Errorf("failed to load internal API client certificates: %w", err)
+	}
+
+	// Rebooting and resetting VMs needs KubeVirt subresources the manager's
+	// client cannot call
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+
+	results := commands.NewClient(clientTLS, commands.RetryPolicy{
+		Attempts:  cfg.InternalAPI.RetryAttempts,
+		BaseDelay: cfg.InternalAPI.RetryBaseDelay,
+	})
+	server := commands.NewCommandServer(cfg.InternalAPI.ListenAddress, serverTLS,
+		controllers.NewVMCommandExecutor(mgr.GetClient(),
+			services.NewVMRestarter(clientset.CoreV1().RESTClient()),
+			cfg.InternalAPI.CommandTimeout),
+		results, cfg.InternalAPI.CallbackURL, nil)
+	return mgr.Add(server)
+}
@@ -247,3 +247,33 @@ func TestMetricsRegistration(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, metricFamilies)
 }
+
+func TestParseControllers(t *testing.T) {
+	enabled, err := parseControllers("vappstatus, VMStatus,vmstatus")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{controllerVMStatus, controllerVAppStatus}, enabled)
+
+	enabled, err = parseControllers("vappstatus")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{controllerVAppStatus}, enabled)
+
+	enabled, err = parseControllers("templatevalidation")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{controllerTemplateValidation}, enabled)
+
+	_, err = parseControllers("vdc")
+	assert.Error(t, err)
+
+	_, err = parseControllers(" , ")
+	assert.Error(t, err)
+}
+
+func TestDefaultLeaderElectionID(t *testing.T) {
+	assert.Equal(t, legacyLeaderElectionID, defaultLeaderElectionID(allControllers))
+	assert.Equal(t, "ssvirt-vmstatus-controller", defaultLeaderElectionID([]string{controllerVMStatus}))
+	assert.Equal(t, "ssvirt-vappstatus-controller", defaultLeaderElectionID([]string{controllerVAppStatus}))
+	assert.Equal(t, legacyLeaderElectionID, defaultLeaderElectionID(defaultControllers))
+	assert.Equal(t, legacyLeaderElectionID, defaultLeaderElectionID([]string{controllerVMStatus, controllerVAppStatus}))
+	assert.Equal(t, "ssvirt-powerstate-controller", defaultLeaderElectionID([]string{controllerPowerState}))
+	assert.Equal(t, "ssvirt-templatevalidation-controller", defaultLeaderElectionID([]string{controllerTemplateValidation}))
+}
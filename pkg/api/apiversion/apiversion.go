@@ -0,0 +1,220 @@
+// Package apiversion negotiates the VCD API version requested by clients.
+//
+// VCD clients select an API version with a media type parameter on the Accept
+// header, for example "application/json;version=39.0". The negotiated version is
+// stored in the request context so handlers can shape responses for it: response
+// fields tagged with `since:"<version>"` are omitted for clients that requested an
+// older version.
+package apiversion
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the Gin context key for storing the negotiated API version
+const ContextKey = "api_version"
+
+// Version is a VCD API version such as 39.0
+type Version struct {
+	Major int
+	Minor int
+}
+
+// Supported lists the API versions this server accepts, oldest first
+var Supported = []Version{
+	{Major: 37, Minor: 0},
+	{Major: 38, Minor: 0},
+	{Major: 39, Minor: 0},
+}
+
+// Latest is the newest supported version, used when a client does not request one
+var Latest = Supported[len(Supported)-1]
+
+// Parse parses a version string such as "39.0" or "39"
+func Parse(s string) (Version, error) {
+	majorStr, minorStr, hasMinor := strings.Cut(strings.TrimSpace(s), ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil || major < 0 {
+		return Version{}, fmt.Errorf("invalid API version %q", s)
+	}
+
+	minor := 0
+	if hasMinor {
+		minor, err = strconv.Atoi(minorStr)
+		if err != nil || minor < 0 {
+			return Version{}, fmt.Errorf("invalid API version %q", s)
+		}
+	}
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// MustParse parses a version string and panics if it is invalid
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String formats the version the way VCD does, e.g. "39.0"
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// AtLeast reports whether v is the same as or newer than other
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	return v.Minor >= other.Minor
+}
+
+// IsSupported reports whether the server accepts the version
+func IsSupported(v Version) bool {
+	for _, supported := range Supported {
+		if supported == v {
+			return true
+		}
+	}
+	return false
+}
+
+// FromAccept extracts the version parameter from an Accept header. It returns
+// false if no media range in the header carries a version.
+func FromAccept(header string) (Version, bool, error) {
+	for _, mediaRange := range strings.Split(header, ",") {
+		if strings.TrimSpace(mediaRange) == "" {
+			continue
+		}
+		_, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		if value, ok := params["version"]; ok {
+			v, err := Parse(value)
+			return v, true, err
+		}
+	}
+	return Version{}, false, nil
+}
+
+// FromContext returns the version negotiated for the request, or Latest if the
+// request did not pass through the negotiation middleware
+func FromContext(c *gin.Context) Version {
+	if value, exists := c.Get(ContextKey); exists {
+		if v, ok := value.(Version); ok {
+			return v
+		}
+	}
+	return Latest
+}
+
+// Middleware negotiates the API version from the Accept header. Requests
+// without a version use Latest; requests for an unsupported version are
+// rejected with 406 Not Acceptable. JSON responses to versioned requests carry
+// the negotiated version in their Content-Type, as VCD does.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accept := c.GetHeader("Accept")
+		v, found, err := FromAccept(accept)
+		if err != nil || (found && !IsSupported(v)) {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+				"code":    http.StatusNotAcceptable,
+				"error":   "Not Acceptable",
+				"message": "Unsupported API version",
+				"details": fmt.Sprintf("Supported versions: %s", supportedList()),
+			})
+			return
+		}
+
+		if !found {
+			v = Latest
+		} else if strings.Contains(accept, "json") {
+			c.Header("Content-Type", "application/json;version="+v.String())
+		}
+		c.Set(ContextKey, v)
+		c.Next()
+	}
+}
+
+// JSON writes obj as the response body, shaped for the negotiated version
+func JSON(c *gin.Context, code int, obj interface{}) {
+	c.JSON(code, Shape(FromContext(c), obj))
+}
+
+// Shape returns obj with every field tagged `since:"<version>"` newer than v
+// cleared. Combine the tag with omitempty so older clients do not see the field
+// at all. Nested structs, pointers, slices and maps are shaped in place, so obj
+// should be a response built for the current request.
+func Shape(v Version, obj interface{}) interface{} {
+	if obj == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(obj)
+	shaped := reflect.New(rv.Type()).Elem()
+	shaped.Set(rv)
+	shapeValue(v, shaped)
+	return shaped.Interface()
+}
+
+func shapeValue(v Version, rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return
+		}
+		elem := rv.Elem()
+		if rv.Kind() == reflect.Ptr {
+			shapeValue(v, elem)
+			return
+		}
+		// Values held in interfaces are not addressable, so shape a copy
+		shaped := reflect.New(elem.Type()).Elem()
+		shaped.Set(elem)
+		shapeValue(v, shaped)
+		if rv.CanSet() {
+			rv.Set(shaped)
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := rv.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+			if since, ok := t.Field(i).Tag.Lookup("since"); ok && !v.AtLeast(MustParse(since)) {
+				field.Set(reflect.Zero(field.Type()))
+				continue
+			}
+			shapeValue(v, field)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			shapeValue(v, rv.Index(i))
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			shaped := reflect.New(iter.Value().Type()).Elem()
+			shaped.Set(iter.Value())
+			shapeValue(v, shaped)
+			rv.SetMapIndex(iter.Key(), shaped)
+		}
+	}
+}
+
+func supportedList() string {
+	versions := make([]string, len(Supported))
+	for i, v := range Supported {
+		versions[i] = v.String()
+	}
+	return strings.Join(versions, ", ")
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+// APIUsageHandlers report API call counts and data transfer per user and day
+type APIUsageHandlers struct {
+	usageRepo *repositories.APIUsageRepository
+	roleCache *auth.RoleCache
+}
+
+// NewAPIUsageHandlers creates a new APIUsageHandlers instance
+func NewAPIUsageHandlers(usageRepo *repositories.APIUsageRepository, roleCache *auth.RoleCache) *APIUsageHandlers {
+	return &APIUsageHandlers{
+		usageRepo: usageRepo,
+		roleCache: roleCache,
+	}
+}
+
+// ListAPIUsage handles GET /api/admin/usage/api. The orgId, userId, from and
+// to (YYYY-MM-DD, inclusive) query parameters narrow the results.
+func (h *APIUsageHandlers) ListAPIUsage(c *gin.Context) {
+	filter, ok := parseAPIUsageFilter(c)
+	if !ok {
+		return
+	}
+	filter.OrganizationID = c.Query("orgId")
+	h.listUsage(c, filter)
+}
+
+// GetOrgAPIUsage handles GET /cloudapi/1.0.0/orgs/{id}/usage/api, the API usage
+// of one organization, for its Organization Administrators
+func (h *APIUsageHandlers) GetOrgAPIUsage(c *gin.Context) {
+	orgID := c.Param("id")
+
+	user, err := auth.UserWithRoles(c, h.roleCache)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to verify user permissions",
+		))
+		return
+	}
+	roles := make(map[string]bool, len(user.Roles))
+	for _, role := range user.Roles {
+		roles[role.Name] = true
+	}
+	ownOrg := user.OrganizationID != nil && *user.OrganizationID == orgID
+	if !roles[models.RoleSystemAdmin] && !(roles[models.RoleOrgAdmin] && ownOrg) {
+		c.JSON(http.StatusForbidden, NewAPIError(
+			http.StatusForbidden,
+			"Forbidden",
+			"Insufficient rights",
+		))
+		return
+	}
+
+	filter, ok := parseAPIUsageFilter(c)
+	if !ok {
+		return
+	}
+	filter.OrganizationID = orgID
+	h.listUsage(c, filter)
+}
+
+func (h *APIUsageHandlers) listUsage(c *gin.Context, filter repositories.APIUsageFilter) {
+	page, pageSize := parsePaginationParams(c)
+	ctx := c.Request.Context()
+
+	total, err := h.usageRepo.Count(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve API usage",
+		))
+		return
+	}
+	usage, err := h.usageRepo.List(ctx, filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve API usage",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewPage(usage, page, pageSize, total))
+}
+
+// parseAPIUsageFilter reads the userId, from and to query parameters
+func parseAPIUsageFilter(c *gin.Context) (repositories.APIUsageFilter, bool) {
+	filter := repositories.APIUsageFilter{
+		UserID: c.Query("userId"),
+		From:   c.Query("from"),
+		To:     c.Query("to"),
+	}
+	for _, day := range []string{filter.From, filter.To} {
+		if day == "" {
+			continue
+		}
+		if _, err := time.Parse(models.APIUsageDayFormat, day); err != nil {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid date, expected YYYY-MM-DD",
+				day,
+			))
+			return filter, false
+		}
+	}
+	return filter, true
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// ApprovalTaskStore records operations held for approval
+type ApprovalTaskStore interface {
+	Create(ctx context.Context, task *models.Task) error
+	GetByID(ctx context.Context, id string) (*models.Task, error)
+	FindApprovalTask(ctx context.Context, name, ownerID, operation, userID string, statuses []string) (*models.Task, error)
+	ResolveApproval(ctx context.Context, id, status, details string) error
+	UpdateStatus(ctx context.Context, id, status string, progress int, details string) error
+}
+
+// Approvals gates privileged operations through the approval webhook.
+// Operations the approval system holds are recorded as tasks pending approval.
+// Once it approves one, the task is queued until the user repeats the
+// operation, which then runs without another callout and completes the task.
+type Approvals struct {
+	gate     *services.ApprovalGate
+	tasks    ApprovalTaskStore
+	eventBus *events.Bus
+	logger   *slog.Logger
+}
+
+// NewApprovals creates an approval gate for handlers
+func NewApprovals(gate *services.ApprovalGate, tasks ApprovalTaskStore, eventBus *events.Bus) *Approvals {
+	return &Approvals{
+		gate:     gate,
+		tasks:    tasks,
+		eventBus: eventBus,
+		logger:   slog.Default(),
+	}
+}
+
+// Authorize decides whether a gated operation may run, writing the response
+// and returning false when it may not. Operations are always authorized when
+// the approvals are nil or the operation is not gated. The request's user and
+// task ID are filled in from the request context.
+func (a *Approvals) Authorize(c *gin.Context, request services.ApprovalRequest) bool {
+	if a == nil || !a.gate.Requires(request.Operation) {
+		return true
+	}
+	if userClaims, ok := c.Get(auth.ClaimsContextKey); ok {
+		if claims, ok := userClaims.(*auth.Claims); ok {
+			request.UserID = claims.UserID
+			request.Username = claims.Username
+		}
+	}
+	ctx := c.Request.Context()
+
+	// An approved request is used up by repeating the operation
+	approved, err := a.tasks.FindApprovalTask(ctx, request.Operation, request.TargetID, request.Description, request.UserID,
+		[]string{models.TaskStatusQueued})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		a.respondError(c, "Failed to look up approvals")
+		return false
+	}
+	if approved != nil {
+		err := a.tasks.UpdateStatus(ctx, approved.ID, models.TaskStatusSuccess, 100, "")
+		if err == nil {
+			publishTaskUpdate(a.eventBus, approved, models.TaskStatusSuccess)
+			a.logger.Info("audit: approved operation", "operation", request.Operation, "task_id", approved.ID,
+				"user_id", request.UserID, "entity_id", request.TargetID, "entity", request.TargetName)
+			return true
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			a.respondError(c, "Failed to use approval")
+			return false
+		}
+		// Used concurrently by another request; ask again
+	}
+
+	// Repeating a request that is still held returns its task
+	pending, err := a.tasks.FindApprovalTask(ctx, request.Operation, request.TargetID, request.Description, request.UserID,
+		[]string{models.TaskStatusPendingApproval})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		a.respondError(c, "Failed to look up approvals")
+		return false
+	}
+	if pending != nil {
+		respondPendingApproval(c, pending)
+		return false
+	}
+
+	request.TaskID = models.GenerateTaskURN()
+	decision, err := a.gate.Request(ctx, request)
+	if err != nil {
+		a.logger.Warn("Approval webhook failed", "operation", request.Operation, "entity_id", request.TargetID, "error", err)
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"The operation requires approval and the approval service is unavailable",
+		))
+		return false
+	}
+
+	switch decision.Decision {
+	case services.ApprovalAllow:
+		return true
+	case services.ApprovalDeny:
+		c.JSON(http.StatusForbidden, NewAPIError(
+			http.StatusForbidden,
+			"Forbidden",
+			"The operation was denied by the approval service",
+			decision.Reason,
+		))
+		return false
+	}
+
+	task := &models.Task{
+		ID:             request.TaskID,
+		Name:           request.Operation,
+		Operation:      request.Description,
+		Status:         models.TaskStatusPendingApproval,
+		OwnerID:        request.TargetID,
+		OwnerName:      request.TargetName,
+		OrganizationID: request.OrgID,
+		UserID:         request.UserID,
+		Details:        decision.Reason,
+	}
+	if err := a.tasks.Create(ctx, task); err != nil {
+		a.respondError(c, "Failed to create approval task")
+		return false
+	}
+	publishTaskUpdate(a.eventBus, task, task.Status)
+	respondPendingApproval(c, task)
+	return false
+}
+
+// RecordDecision handles POST /cloudapi/1.0.0/approvals/{task_id}. The approval
+// system posts its decision on a held operation, signed with the approval
+// secret instead of authenticating with a token.
+func (a *Approvals) RecordDecision(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Failed to read request body",
+		))
+		return
+	}
+	if err := a.gate.Verify(c.GetHeader(services.ApprovalTimestampHeader), c.GetHeader(services.ApprovalSignatureHeader), body); err != nil {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid approval signature",
+		))
+		return
+	}
+
+	var decision services.ApprovalDecision
+	if err := json.Unmarshal(body, &decision); err != nil ||
+		(decision.Decision != services.ApprovalAllow && decision.Decision != services.ApprovalDeny) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid approval decision",
+			fmt.Sprintf("decision must be %q or %q", services.ApprovalAllow, services.ApprovalDeny),
+		))
+		return
+	}
+	taskID := c.Param("task_id")
+	if decision.TaskID != taskID {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid approval decision",
+			"taskId must name the task the decision is posted for",
+		))
+		return
+	}
+
+	status, details := models.TaskStatusQueued, decision.Reason
+	if decision.Decision == services.ApprovalDeny {
+		status, details = models.TaskStatusError, "Denied by the approval service"
+		if decision.Reason != "" {
+			details += ": " + decision.Reason
+		}
+	}
+	if err := a.tasks.ResolveApproval(c.Request.Context(), taskID, status, details); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusConflict, NewAPIError(
+				http.StatusConflict,
+				"Conflict",
+				"Task is not pending approval",
+			))
+			return
+		}
+		a.respondError(c, "Failed to record approval decision")
+		return
+	}
+
+	task, err := a.tasks.GetByID(c.Request.Context(), taskID)
+	if err != nil {
+		a.respondError(c, "Failed to retrieve task")
+		return
+	}
+	publishTaskUpdate(a.eventBus, task, task.Status)
+	a.logger.Info("audit: approval decision", "operation", task.Name, "task_id", task.ID,
+		"decision", decision.Decision, "reason", decision.Reason, "user_id", task.UserID, "entity_id", task.OwnerID)
+	c.JSON(http.StatusOK, toTaskResponse(task))
+}
+
+// respondPendingApproval tells the client the operation is held under task
+func respondPendingApproval(c *gin.Context, task *models.Task) {
+	response := toTaskResponse(task)
+	c.Header("Location", response.Href)
+	c.JSON(http.StatusAccepted, response)
+}
+
+// respondError writes an internal server error response
+func (a *Approvals) respondError(c *gin.Context, message string) {
+	c.JSON(http.StatusInternalServerError, NewAPIError(
+		http.StatusInternalServerError,
+		"Internal Server Error",
+		message,
+	))
+}
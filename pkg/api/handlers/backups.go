@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// VAppBackupPolicy is the backup policy of a vApp's VMs
+type VAppBackupPolicy struct {
+	// Policy is the vApp's policy, or its VDC's when Inherited; nil when
+	// neither sets one and backups are left to the operator
+	Policy *models.BackupPolicy `json:"policy"`
+	// Inherited reports that the vApp follows its VDC's policy. Setting it in
+	// an update removes the vApp's own policy.
+	Inherited bool `json:"inherited"`
+}
+
+// policy returns the policy the vApp overrides its VDC's with, or nil to inherit it
+func (p VAppBackupPolicy) policy() *models.BackupPolicy {
+	if p.Inherited {
+		return nil
+	}
+	return p.Policy
+}
+
+// VMBackupStatusResponse is the response for GET /cloudapi/1.0.0/vms/{vm_id}/backupStatus
+type VMBackupStatusResponse struct {
+	VMID string `json:"vmId"`
+	// Policy is the backup policy the VM's vApp and VDC configure
+	Policy      *models.BackupPolicy `json:"policy"`
+	CollectedAt string               `json:"collectedAt"`
+	*services.VMBackupStatus
+}
+
+// SetBackups enables applying vApp backup policies to running VMs
+func (h *VAppHandlers) SetBackups(backups services.BackupService) {
+	h.backups = backups
+}
+
+// SetBackups enables VM backup status, read from Velero
+func (h *VMHandlers) SetBackups(backups services.BackupService) {
+	h.backups = backups
+}
+
+// GetBackupPolicy handles GET /cloudapi/1.0.0/vapps/{vapp_id}/backupPolicy
+func (h *VAppHandlers) GetBackupPolicy(c *gin.Context) {
+	vapp, ok := h.authorizeVApp(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, VAppBackupPolicy{
+		Policy:    vapp.BackupPolicy(vapp.VDC),
+		Inherited: vapp.BackupEnabled == nil,
+	})
+}
+
+// UpdateBackupPolicy handles PUT /cloudapi/1.0.0/vapps/{vapp_id}/backupPolicy.
+// The labels of the vApp's VMs are updated so Velero Schedules pick up the
+// change at their next run.
+func (h *VAppHandlers) UpdateBackupPolicy(c *gin.Context) {
+	vapp, ok := h.authorizeVApp(c)
+	if !ok {
+		return
+	}
+
+	var req VAppBackupPolicy
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	switch {
+	case req.Inherited:
+	case req.Policy == nil:
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid backup policy",
+			"policy is required unless inherited is set",
+		))
+		return
+	default:
+		if !validateBackupPolicy(c, *req.Policy) {
+			return
+		}
+	}
+
+	if err := h.vappRepo.UpdateBackupPolicy(c.Request.Context(), vapp.ID, req.policy()); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to update backup policy",
+		))
+		return
+	}
+
+	vapp.SetBackupPolicy(req.policy())
+	policy := vapp.BackupPolicy(vapp.VDC)
+	if h.backups != nil {
+		vms, err := h.vmRepo.GetByVAppID(vapp.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to retrieve VMs",
+			))
+			return
+		}
+		for _, vm := range vms {
+			if vm.VMName == "" || vm.Namespace == "" {
+				continue
+			}
+			if err := h.backups.ApplyVMBackupPolicy(c.Request.Context(), vm.Namespace, vm.VMName, policy); err != nil {
+				// The policy is saved; repeating the update relabels the remaining VMs
+				h.logger.Error("Failed to apply backup policy",
+					"vappID", vapp.ID, "vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+				c.JSON(http.StatusInternalServerError, NewAPIError(
+					http.StatusInternalServerError,
+					"Internal Server Error",
+					"Failed to apply backup policy",
+				))
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, VAppBackupPolicy{Policy: policy, Inherited: vapp.BackupEnabled == nil})
+}
+
+// GetVMBackupStatus handles GET /cloudapi/1.0.0/vms/{vm_id}/backupStatus. It
+// reports the VM's backup policy and its most recent Velero Backups, so
+// tenants can check their VMs are protected.
+func (h *VMHandlers) GetVMBackupStatus(c *gin.Context) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	if h.backups == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"VM backup status is not available",
+		))
+		return
+	}
+
+	response := VMBackupStatusResponse{
+		VMID:           vm.ID,
+		Policy:         vm.VApp.BackupPolicy(vm.VApp.VDC),
+		CollectedAt:    time.Now().UTC().Format(time.RFC3339),
+		VMBackupStatus: &services.VMBackupStatus{},
+	}
+	if vm.VMName == "" || vm.Namespace == "" {
+		// The VirtualMachine was never created, so it cannot have been backed up
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	status, err := h.backups.VMBackupStatus(c.Request.Context(), vm.Namespace, vm.VMName)
+	switch {
+	case errors.Is(err, services.ErrBackupsUnavailable):
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"Velero is not installed",
+		))
+		return
+	case err != nil:
+		h.logger.Error("Failed to collect VM backup status",
+			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to collect VM backup status",
+		))
+		return
+	}
+	response.VMBackupStatus = status
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+// requireUserID returns the authenticated user's ID, writing a 401 response
+// and returning false if the request has no valid claims
+func requireUserID(c *gin.Context) (string, bool) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return "", false
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return "", false
+	}
+	return userClaims.UserID, true
+}
+
+// requireCatalogAccess checks that the authenticated user holds at least the
+// required access level on a catalog. Catalogs the user cannot see are reported
+// as not found; visible catalogs with too low an access level as forbidden.
+func requireCatalogAccess(c *gin.Context, catalogRepo *repositories.CatalogRepository, catalogID, required string) (*models.Catalog, string, bool) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return nil, "", false
+	}
+
+	catalog, level, err := catalogRepo.GetCatalogAccess(c.Request.Context(), userID, catalogID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"Catalog not found",
+				fmt.Sprintf("Catalog with ID '%s' does not exist", catalogID),
+			))
+			return nil, "", false
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to validate catalog access",
+		))
+		return nil, "", false
+	}
+
+	if models.CatalogAccessRank(level) < models.CatalogAccessRank(required) {
+		c.JSON(http.StatusForbidden, NewAPIError(
+			http.StatusForbidden,
+			"Forbidden",
+			"Catalog access denied",
+			fmt.Sprintf("%s access to the catalog is required", required),
+		))
+		return nil, "", false
+	}
+	return catalog, level, true
+}
+
+// GetControlAccess handles GET /cloudapi/1.0.0/catalogs/{catalogUrn}/controlAccess
+func (h *CatalogHandlers) GetControlAccess(c *gin.Context) {
+	catalogURN := c.Param("catalogUrn")
+	if !strings.HasPrefix(catalogURN, models.URNPrefixCatalog) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid catalog URN format",
+			"Catalog ID must be a valid URN with prefix 'urn:vcloud:catalog:'",
+		))
+		return
+	}
+
+	catalog, _, ok := requireCatalogAccess(c, h.catalogRepo, catalogURN, models.CatalogAccessReadOnly)
+	if !ok {
+		return
+	}
+
+	response, err := h.controlAccessResponse(c, catalog)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve catalog access settings",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SetControlAccess handles POST /cloudapi/1.0.0/catalogs/{catalogUrn}/action/controlAccess.
+// The request replaces the catalog's sharing settings entirely.
+func (h *CatalogHandlers) SetControlAccess(c *gin.Context) {
+	catalogURN := c.Param("catalogUrn")
+	if !strings.HasPrefix(catalogURN, models.URNPrefixCatalog) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid catalog URN format",
+			"Catalog ID must be a valid URN with prefix 'urn:vcloud:catalog:'",
+		))
+		return
+	}
+
+	catalog, _, ok := requireCatalogAccess(c, h.catalogRepo, catalogURN, models.CatalogAccessChange)
+	if !ok {
+		return
+	}
+
+	var req ControlAccessParams
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	everyoneLevel := models.CatalogAccessReadOnly
+	if req.IsSharedToEveryone {
+		level, valid := models.NormalizeCatalogAccessLevel(req.EveryoneAccessLevel)
+		if !valid {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid everyone access level",
+				"Access level must be one of ReadOnly, Use, Change or FullControl",
+			))
+			return
+		}
+		everyoneLevel = level
+	}
+
+	grants := make([]models.CatalogAccessControl, 0, len(req.AccessSettings))
+	seen := make(map[string]bool, len(req.AccessSettings))
+	for _, setting := range req.AccessSettings {
+		level, valid := models.NormalizeCatalogAccessLevel(setting.AccessLevel)
+		if !valid {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid access level",
+				fmt.Sprintf("Access level for '%s' must be one of ReadOnly, Use, Change or FullControl", setting.Subject.ID),
+			))
+			return
+		}
+		if seen[setting.Subject.ID] {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Duplicate access setting",
+				fmt.Sprintf("Subject '%s' appears more than once", setting.Subject.ID),
+			))
+			return
+		}
+		seen[setting.Subject.ID] = true
+
+		subjectType, err := h.resolveSubject(setting.Subject.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusBadRequest, NewAPIError(
+					http.StatusBadRequest,
+					"Bad Request",
+					"Access setting subject not found",
+					fmt.Sprintf("No user or role with ID '%s' exists", setting.Subject.ID),
+				))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to resolve access setting subject",
+			))
+			return
+		}
+
+		grants = append(grants, models.CatalogAccessControl{
+			SubjectID:   setting.Subject.ID,
+			SubjectType: subjectType,
+			AccessLevel: level,
+		})
+	}
+
+	err := h.catalogRepo.SetControlAccess(c.Request.Context(), catalog.ID, req.IsSharedToEveryone, everyoneLevel, grants)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to update catalog access settings",
+		))
+		return
+	}
+
+	catalog.SharedToEveryone = req.IsSharedToEveryone
+	catalog.EveryoneAccessLevel = everyoneLevel
+	response, err := h.controlAccessResponse(c, catalog)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve catalog access settings",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// resolveSubject returns the subject type of a user or role URN, or
+// gorm.ErrRecordNotFound if no such user or role exists
+func (h *CatalogHandlers) resolveSubject(subjectID string) (string, error) {
+	switch {
+	case strings.HasPrefix(subjectID, models.URNPrefixUser):
+		if _, err := h.userRepo.GetByID(subjectID); err != nil {
+			return "", err
+		}
+		return models.AccessSubjectUser, nil
+	case strings.HasPrefix(subjectID, models.URNPrefixRole):
+		if _, err := h.roleRepo.GetByID(subjectID); err != nil {
+			return "", err
+		}
+		return models.AccessSubjectRole, nil
+	default:
+		return "", gorm.ErrRecordNotFound
+	}
+}
+
+// controlAccessResponse builds the sharing settings of a catalog, naming each subject
+func (h *CatalogHandlers) controlAccessResponse(c *gin.Context, catalog *models.Catalog) (ControlAccessParams, error) {
+	grants, err := h.catalogRepo.GetControlAccess(c.Request.Context(), catalog.ID)
+	if err != nil {
+		return ControlAccessParams{}, err
+	}
+
+	response := ControlAccessParams{
+		IsSharedToEveryone: catalog.SharedToEveryone,
+		AccessSettings:     make([]AccessSetting, len(grants)),
+	}
+	if catalog.SharedToEveryone {
+		response.EveryoneAccessLevel = catalog.EveryoneAccessLevel
+	}
+
+	for i, grant := range grants {
+		subject := models.EntityRef{ID: grant.SubjectID}
+		switch grant.SubjectType {
+		case models.AccessSubjectUser:
+			if user, err := h.userRepo.GetByID(grant.SubjectID); err == nil {
+				subject.Name = user.Username
+			}
+		case models.AccessSubjectRole:
+			if role, err := h.roleRepo.GetByID(grant.SubjectID); err == nil {
+				subject.Name = role.Name
+			}
+		}
+		response.AccessSettings[i] = AccessSetting{Subject: subject, AccessLevel: grant.AccessLevel}
+	}
+	return response, nil
+}
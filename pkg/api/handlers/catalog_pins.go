@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	domainerrors "github.com/mhrivnak/ssvirt/pkg/domain/errors"
+)
+
+// CatalogPinHandlers pin catalog items for VDCs. The prewarm controller imports
+// the images of pinned items into the VDC namespace, so instantiating them in
+// the VDC clones the images instead of downloading them.
+type CatalogPinHandlers struct {
+	pins            *repositories.CatalogItemPinRepository
+	catalogRepo     *repositories.CatalogRepository
+	catalogItemRepo *repositories.CatalogItemRepository
+	access          *auth.AccessControl
+}
+
+// NewCatalogPinHandlers creates a new CatalogPinHandlers instance
+func NewCatalogPinHandlers(pins *repositories.CatalogItemPinRepository, catalogRepo *repositories.CatalogRepository, catalogItemRepo *repositories.CatalogItemRepository, access *auth.AccessControl) *CatalogPinHandlers {
+	return &CatalogPinHandlers{
+		pins:            pins,
+		catalogRepo:     catalogRepo,
+		catalogItemRepo: catalogItemRepo,
+		access:          access,
+	}
+}
+
+// CatalogItemPinResponse represents a catalog item pinned for a VDC and the
+// import status of its images
+type CatalogItemPinResponse struct {
+	CatalogItemID string `json:"catalogItemId"`
+	TemplateName  string `json:"templateName"`
+	Status        string `json:"status"`
+	Message       string `json:"message,omitempty"`
+	// ReadyImages counts the images instantiations already clone
+	ReadyImages int        `json:"readyImages"`
+	ReadyAt     *time.Time `json:"readyAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// ListPinnedCatalogItems handles GET /cloudapi/1.0.0/vdcs/{vdc_id}/pinnedCatalogItems
+func (h *CatalogPinHandlers) ListPinnedCatalogItems(c *gin.Context) {
+	vdc, ok := h.accessibleVDC(c)
+	if !ok {
+		return
+	}
+
+	pins, err := h.pins.ListByVDC(c.Request.Context(), vdc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve pinned catalog items",
+		))
+		return
+	}
+
+	responses := make([]CatalogItemPinResponse, len(pins))
+	for i := range pins {
+		responses[i] = toCatalogItemPinResponse(&pins[i])
+	}
+	c.JSON(http.StatusOK, types.NewPage(responses, 1, max(len(responses), 1), int64(len(responses))))
+}
+
+// PinCatalogItem handles PUT /cloudapi/1.0.0/vdcs/{vdc_id}/pinnedCatalogItems/{itemId}.
+// The caller must be able to instantiate the item. Pinning an item again keeps
+// its import status.
+func (h *CatalogPinHandlers) PinCatalogItem(c *gin.Context) {
+	vdc, ok := h.accessibleVDC(c)
+	if !ok {
+		return
+	}
+	itemID := c.Param("itemId")
+	catalogID, _, ok := parsePinnedCatalogItemURN(c, itemID)
+	if !ok {
+		return
+	}
+	if _, _, ok := requireCatalogAccess(c, h.catalogRepo, catalogID, models.CatalogAccessUse); !ok {
+		return
+	}
+
+	item, err := h.catalogItemRepo.GetByID(c.Request.Context(), catalogID, itemID)
+	if err != nil {
+		if errors.Is(err, domainerrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"Catalog item not found",
+				fmt.Sprintf("Catalog item '%s' does not exist", itemID),
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve catalog item",
+		))
+		return
+	}
+
+	pin := &models.CatalogItemPin{
+		VDCID:         vdc.ID,
+		TemplateName:  item.Name,
+		CatalogItemID: item.ID,
+	}
+	if err := h.pins.Save(c.Request.Context(), pin); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to pin catalog item",
+			err.Error(),
+		))
+		return
+	}
+
+	saved, err := h.pins.Get(c.Request.Context(), vdc.ID, item.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve pinned catalog items",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, toCatalogItemPinResponse(saved))
+}
+
+// UnpinCatalogItem handles DELETE /cloudapi/1.0.0/vdcs/{vdc_id}/pinnedCatalogItems/{itemId}.
+// The prewarm controller deletes the item's images at its next poll. Items
+// whose Template no longer exists can still be unpinned.
+func (h *CatalogPinHandlers) UnpinCatalogItem(c *gin.Context) {
+	vdc, ok := h.accessibleVDC(c)
+	if !ok {
+		return
+	}
+	_, templateName, ok := parsePinnedCatalogItemURN(c, c.Param("itemId"))
+	if !ok {
+		return
+	}
+
+	if err := h.pins.Delete(c.Request.Context(), vdc.ID, templateName); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"Catalog item is not pinned",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to unpin catalog item",
+		))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// accessibleVDC loads the VDC named in the path, writing an error response if
+// the caller cannot access it
+func (h *CatalogPinHandlers) accessibleVDC(c *gin.Context) (*models.VDC, bool) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return nil, false
+	}
+	vdc, err := h.access.CanAccessVDC(c.Request.Context(), userID, c.Param("vdc_id"))
+	if err != nil {
+		respondAccessError(c, err, "VDC")
+		return nil, false
+	}
+	return vdc, true
+}
+
+// parsePinnedCatalogItemURN returns the catalog and Template name of a catalog
+// item URN, urn:vcloud:catalogitem:<catalog-uuid>:<name>. Legacy URNs without a
+// catalog are not accepted.
+func parsePinnedCatalogItemURN(c *gin.Context, itemID string) (string, string, bool) {
+	suffix, isURN := strings.CutPrefix(itemID, models.URNPrefixCatalogItem)
+	colonIndex := strings.LastIndex(suffix, ":")
+	if !isURN || colonIndex <= 0 || colonIndex == len(suffix)-1 {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid catalog item URN format",
+			"Catalog item ID must have the format urn:vcloud:catalogitem:<catalog-uuid>:<name>",
+		))
+		return "", "", false
+	}
+	name, err := url.QueryUnescape(suffix[colonIndex+1:])
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid catalog item name encoding",
+			err.Error(),
+		))
+		return "", "", false
+	}
+	return models.URNPrefixCatalog + suffix[:colonIndex], name, true
+}
+
+func toCatalogItemPinResponse(pin *models.CatalogItemPin) CatalogItemPinResponse {
+	return CatalogItemPinResponse{
+		CatalogItemID: pin.CatalogItemID,
+		TemplateName:  pin.TemplateName,
+		Status:        pin.Status,
+		Message:       pin.Message,
+		ReadyImages:   len(pin.ReadyImageNames()),
+		ReadyAt:       pin.ReadyAt,
+		CreatedAt:     pin.CreatedAt,
+	}
+}
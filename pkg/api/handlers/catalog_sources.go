@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// CatalogSourceRequest represents the request body for setting the source of a catalog
+type CatalogSourceRequest struct {
+	Type                string `json:"type" binding:"required"`
+	URL                 string `json:"url" binding:"required"`
+	Ref                 string `json:"ref"`
+	Path                string `json:"path"`
+	SyncIntervalMinutes *int   `json:"syncIntervalMinutes"`
+}
+
+// CatalogSourceResponse represents the source of a catalog and its sync status
+type CatalogSourceResponse struct {
+	Catalog             models.EntityRef `json:"catalog"`
+	Type                string           `json:"type"`
+	URL                 string           `json:"url"`
+	Ref                 string           `json:"ref,omitempty"`
+	Path                string           `json:"path,omitempty"`
+	SyncIntervalMinutes int              `json:"syncIntervalMinutes"`
+	SyncStatus          string           `json:"syncStatus"`
+	SyncMessage         string           `json:"syncMessage,omitempty"`
+	LastSyncAt          *time.Time       `json:"lastSyncAt,omitempty"`
+	SyncRequestedAt     *time.Time       `json:"syncRequestedAt,omitempty"`
+	Revision            string           `json:"revision,omitempty"`
+	TemplateCount       int              `json:"templateCount"`
+}
+
+// SetCatalogSources enables catalog sources, which the catalog sync controller
+// imports Templates from
+func (h *CatalogHandlers) SetCatalogSources(sources *repositories.CatalogSourceRepository) {
+	h.catalogSources = sources
+}
+
+// GetCatalogSource handles GET /cloudapi/1.0.0/catalogs/{catalogUrn}/source
+func (h *CatalogHandlers) GetCatalogSource(c *gin.Context) {
+	catalog, ok := h.catalogForSource(c, models.CatalogAccessReadOnly)
+	if !ok {
+		return
+	}
+
+	source, ok := h.getCatalogSource(c, catalog)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toCatalogSourceResponse(catalog, source))
+}
+
+// SetCatalogSource handles PUT /cloudapi/1.0.0/catalogs/{catalogUrn}/source. It
+// creates or replaces the source of a catalog and requests an immediate sync.
+func (h *CatalogHandlers) SetCatalogSource(c *gin.Context) {
+	catalog, ok := h.catalogForSource(c, models.CatalogAccessChange)
+	if !ok {
+		return
+	}
+
+	var req CatalogSourceRequest
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	source := &models.CatalogSource{
+		CatalogID:           catalog.ID,
+		Type:                req.Type,
+		URL:                 strings.TrimSpace(req.URL),
+		Ref:                 strings.TrimSpace(req.Ref),
+		Path:                strings.Trim(strings.TrimSpace(req.Path), "/"),
+		SyncIntervalMinutes: models.DefaultCatalogSyncIntervalMinutes,
+	}
+	if req.SyncIntervalMinutes != nil {
+		source.SyncIntervalMinutes = *req.SyncIntervalMinutes
+	}
+	if err := services.ValidateCatalogSource(*source); err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid catalog source",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.catalogSources.Save(c.Request.Context(), source); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to save catalog source",
+			err.Error(),
+		))
+		return
+	}
+
+	saved, ok := h.getCatalogSource(c, catalog)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toCatalogSourceResponse(catalog, saved))
+}
+
+// DeleteCatalogSource handles DELETE /cloudapi/1.0.0/catalogs/{catalogUrn}/source.
+// Templates already imported from the source are kept.
+func (h *CatalogHandlers) DeleteCatalogSource(c *gin.Context) {
+	catalog, ok := h.catalogForSource(c, models.CatalogAccessChange)
+	if !ok {
+		return
+	}
+
+	if err := h.catalogSources.Delete(c.Request.Context(), catalog.ID); err != nil {
+		h.catalogSourceError(c, catalog, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SyncCatalogSource handles POST /cloudapi/1.0.0/catalogs/{catalogUrn}/source/actions/sync.
+// The catalog sync controller picks up the request at its next poll.
+func (h *CatalogHandlers) SyncCatalogSource(c *gin.Context) {
+	catalog, ok := h.catalogForSource(c, models.CatalogAccessChange)
+	if !ok {
+		return
+	}
+
+	if err := h.catalogSources.RequestSync(c.Request.Context(), catalog.ID); err != nil {
+		h.catalogSourceError(c, catalog, err)
+		return
+	}
+
+	source, ok := h.getCatalogSource(c, catalog)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusAccepted, toCatalogSourceResponse(catalog, source))
+}
+
+// catalogForSource validates the catalog URN and the caller's access to the catalog
+func (h *CatalogHandlers) catalogForSource(c *gin.Context, required string) (*models.Catalog, bool) {
+	catalogURN := c.Param("catalogUrn")
+	if !strings.HasPrefix(catalogURN, models.URNPrefixCatalog) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid catalog URN format",
+			"Catalog ID must be a valid URN with prefix 'urn:vcloud:catalog:'",
+		))
+		return nil, false
+	}
+
+	catalog, _, ok := requireCatalogAccess(c, h.catalogRepo, catalogURN, required)
+	return catalog, ok
+}
+
+// getCatalogSource loads the source of a catalog, writing an error response if it fails
+func (h *CatalogHandlers) getCatalogSource(c *gin.Context, catalog *models.Catalog) (*models.CatalogSource, bool) {
+	source, err := h.catalogSources.Get(c.Request.Context(), catalog.ID)
+	if err != nil {
+		h.catalogSourceError(c, catalog, err)
+		return nil, false
+	}
+	return source, true
+}
+
+// catalogSourceError writes the response for a failed catalog source lookup or update
+func (h *CatalogHandlers) catalogSourceError(c *gin.Context, catalog *models.Catalog, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, NewAPIError(
+			http.StatusNotFound,
+			"Not Found",
+			"Catalog source not found",
+			fmt.Sprintf("Catalog '%s' has no source", catalog.ID),
+		))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, NewAPIError(
+		http.StatusInternalServerError,
+		"Internal Server Error",
+		"Failed to retrieve catalog source",
+		err.Error(),
+	))
+}
+
+func toCatalogSourceResponse(catalog *models.Catalog, source *models.CatalogSource) CatalogSourceResponse {
+	return CatalogSourceResponse{
+		Catalog:             models.EntityRef{Name: catalog.Name, ID: catalog.ID},
+		Type:                source.Type,
+		URL:                 source.URL,
+		Ref:                 source.Ref,
+		Path:                source.Path,
+		SyncIntervalMinutes: source.SyncIntervalMinutes,
+		SyncStatus:          source.SyncStatus,
+		SyncMessage:         source.SyncMessage,
+		LastSyncAt:          source.LastSyncAt,
+		SyncRequestedAt:     source.SyncRequestedAt,
+		Revision:            source.Revision,
+		TemplateCount:       source.TemplateCount,
+	}
+}
@@ -18,12 +18,14 @@ import (
 // CatalogItemHandler handles catalog item API endpoints
 type CatalogItemHandler struct {
 	catalogItemRepo *repositories.CatalogItemRepository
+	catalogRepo     *repositories.CatalogRepository
 }
 
 // NewCatalogItemHandler creates a new CatalogItemHandler
-func NewCatalogItemHandler(catalogItemRepo *repositories.CatalogItemRepository) *CatalogItemHandler {
+func NewCatalogItemHandler(catalogItemRepo *repositories.CatalogItemRepository, catalogRepo *repositories.CatalogRepository) *CatalogItemHandler {
 	return &CatalogItemHandler{
 		catalogItemRepo: catalogItemRepo,
+		catalogRepo:     catalogRepo,
 	}
 }
 
@@ -41,14 +43,19 @@ func (h *CatalogItemHandler) ListCatalogItems(c *gin.Context) {
 		return
 	}
 
+	if _, _, ok := requireCatalogAccess(c, h.catalogRepo, catalogID, models.CatalogAccessReadOnly); !ok {
+		return
+	}
+
 	// Parse pagination parameters
 	page, pageSize := parsePaginationParams(c)
 
 	// Calculate offset
 	offset := (page - 1) * pageSize
+	filter := c.Query("filter")
 
 	// Get catalog items
-	catalogItems, err := h.catalogItemRepo.ListByCatalogID(c.Request.Context(), catalogID, pageSize, offset)
+	catalogItems, err := h.catalogItemRepo.ListByCatalogID(c.Request.Context(), catalogID, filter, pageSize, offset)
 	if err != nil {
 		if errors.Is(err, domainerrors.ErrNotFound) {
 			c.JSON(http.StatusNotFound, NewAPIError(
@@ -68,7 +75,7 @@ func (h *CatalogItemHandler) ListCatalogItems(c *gin.Context) {
 	}
 
 	// Get total count
-	totalCount, err := h.catalogItemRepo.CountByCatalogID(c.Request.Context(), catalogID)
+	totalCount, err := h.catalogItemRepo.CountByCatalogID(c.Request.Context(), catalogID, filter)
 	if err != nil {
 		if errors.Is(err, domainerrors.ErrNotFound) {
 			c.JSON(http.StatusNotFound, NewAPIError(
@@ -127,6 +134,10 @@ func (h *CatalogItemHandler) GetCatalogItem(c *gin.Context) {
 		return
 	}
 
+	if _, _, ok := requireCatalogAccess(c, h.catalogRepo, catalogID, models.CatalogAccessReadOnly); !ok {
+		return
+	}
+
 	// Get catalog item
 	catalogItem, err := h.catalogItemRepo.GetByID(c.Request.Context(), catalogID, itemID)
 	if err != nil {
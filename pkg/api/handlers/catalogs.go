@@ -20,14 +20,19 @@ type CatalogHandlers struct {
 	catalogRepo     *repositories.CatalogRepository
 	catalogItemRepo *repositories.CatalogItemRepository
 	orgRepo         *repositories.OrganizationRepository
+	userRepo        *repositories.UserRepository
+	roleRepo        *repositories.RoleRepository
 	k8sService      services.KubernetesService
+	catalogSources  *repositories.CatalogSourceRepository
 }
 
-func NewCatalogHandlers(catalogRepo *repositories.CatalogRepository, catalogItemRepo *repositories.CatalogItemRepository, orgRepo *repositories.OrganizationRepository, k8sService services.KubernetesService) *CatalogHandlers {
+func NewCatalogHandlers(catalogRepo *repositories.CatalogRepository, catalogItemRepo *repositories.CatalogItemRepository, orgRepo *repositories.OrganizationRepository, userRepo *repositories.UserRepository, roleRepo *repositories.RoleRepository, k8sService services.KubernetesService) *CatalogHandlers {
 	return &CatalogHandlers{
 		catalogRepo:     catalogRepo,
 		catalogItemRepo: catalogItemRepo,
 		orgRepo:         orgRepo,
+		userRepo:        userRepo,
+		roleRepo:        roleRepo,
 		k8sService:      k8sService,
 	}
 }
@@ -60,8 +65,28 @@ type CatalogResponse struct {
 	Version                  int                       `json:"version"`
 }
 
+// ControlAccessParams describes who a catalog is shared with, following VCD's
+// controlAccess format
+type ControlAccessParams struct {
+	IsSharedToEveryone  bool            `json:"isSharedToEveryone"`
+	EveryoneAccessLevel string          `json:"everyoneAccessLevel,omitempty"`
+	AccessSettings      []AccessSetting `json:"accessSettings"`
+}
+
+// AccessSetting grants a user or role an access level. The subject is
+// identified by a user or role URN.
+type AccessSetting struct {
+	Subject     models.EntityRef `json:"subject"`
+	AccessLevel string           `json:"accessLevel"`
+}
+
 // ListCatalogs handles GET /cloudapi/1.0.0/catalogs
 func (h *CatalogHandlers) ListCatalogs(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
 	// Parse pagination parameters
 	page := 1
 	pageSize := 25
@@ -80,8 +105,8 @@ func (h *CatalogHandlers) ListCatalogs(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 
-	// Get catalogs with pagination
-	catalogs, err := h.catalogRepo.ListWithPagination(pageSize, offset)
+	// Get the catalogs visible to the user with pagination
+	catalogs, err := h.catalogRepo.ListAccessibleWithPagination(c.Request.Context(), userID, pageSize, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, NewAPIError(
 			http.StatusInternalServerError,
@@ -93,7 +118,7 @@ func (h *CatalogHandlers) ListCatalogs(c *gin.Context) {
 	}
 
 	// Get total count
-	totalCount, err := h.catalogRepo.CountAll()
+	totalCount, err := h.catalogRepo.CountAccessible(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, NewAPIError(
 			http.StatusInternalServerError,
@@ -109,9 +134,8 @@ func (h *CatalogHandlers) ListCatalogs(c *gin.Context) {
 	for i, catalog := range catalogs {
 		catalogResponse := h.toCatalogResponse(catalog)
 
-		// Enrich with OpenShift template count if template service is available
-		// Use the existing template service which has proper filtering
-		templates, err := h.catalogItemRepo.CountByCatalogID(c.Request.Context(), catalog.ID)
+		// Enrich with the number of OpenShift templates offered as catalog items
+		templates, err := h.catalogItemRepo.CountByCatalogID(c.Request.Context(), catalog.ID, "")
 		if err == nil {
 			catalogResponse.NumberOfVAppTemplates = int(templates)
 		}
@@ -140,6 +164,10 @@ func (h *CatalogHandlers) GetCatalog(c *gin.Context) {
 		return
 	}
 
+	if _, _, ok := requireCatalogAccess(c, h.catalogRepo, catalogURN, models.CatalogAccessReadOnly); !ok {
+		return
+	}
+
 	// Get catalog
 	catalog, err := h.catalogRepo.GetByURN(catalogURN)
 	if err != nil {
@@ -166,14 +194,14 @@ func (h *CatalogHandlers) GetCatalog(c *gin.Context) {
 
 // CreateCatalog handles POST /cloudapi/1.0.0/catalogs
 func (h *CatalogHandlers) CreateCatalog(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
 	var req CatalogCreateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, NewAPIError(
-			http.StatusBadRequest,
-			"Bad Request",
-			"Invalid request body",
-			err.Error(),
-		))
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
@@ -218,7 +246,7 @@ func (h *CatalogHandlers) CreateCatalog(c *gin.Context) {
 		IsSubscribed:   false, // Default
 		IsLocal:        true,  // Default
 		Version:        1,     // Default
-		OwnerID:        "",    // Default empty for now
+		OwnerID:        userID,
 	}
 
 	// Create catalog
@@ -250,24 +278,8 @@ func (h *CatalogHandlers) DeleteCatalog(c *gin.Context) {
 		return
 	}
 
-	// Verify catalog exists
-	_, err := h.catalogRepo.GetByURN(catalogURN)
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, NewAPIError(
-				http.StatusNotFound,
-				"Not Found",
-				"Catalog not found",
-				fmt.Sprintf("Catalog with ID '%s' does not exist", catalogURN),
-			))
-			return
-		}
-		c.JSON(http.StatusInternalServerError, NewAPIError(
-			http.StatusInternalServerError,
-			"Internal Server Error",
-			"Failed to retrieve catalog",
-			err.Error(),
-		))
+	// Deleting a catalog requires Change access
+	if _, _, ok := requireCatalogAccess(c, h.catalogRepo, catalogURN, models.CatalogAccessChange); !ok {
 		return
 	}
 
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+const (
+	defaultChangesPageSize = 100
+	maxChangesPageSize     = 1000
+)
+
+// ChangeHandlers report entity changes, so external inventories such as a
+// CMDB can synchronize incrementally
+type ChangeHandlers struct {
+	changes *repositories.EntityChangeRepository
+}
+
+// NewChangeHandlers creates a new ChangeHandlers instance
+func NewChangeHandlers(changes *repositories.EntityChangeRepository) *ChangeHandlers {
+	return &ChangeHandlers{changes: changes}
+}
+
+// ChangesResponse is a page of entity changes
+type ChangesResponse struct {
+	Values []models.EntityChange `json:"values"`
+	// NextCursor is passed as since to continue after the last change; it is
+	// returned even when there are no changes, so clients can store it
+	NextCursor string `json:"nextCursor"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+// changeCursorToken is the encoded form of a repositories.ChangeCursor
+type changeCursorToken struct {
+	ChangedAt time.Time `json:"t"`
+	Type      string    `json:"k"`
+	URN       string    `json:"u"`
+}
+
+// ListChanges handles GET /api/admin/changes. The since query parameter is an
+// RFC 3339 timestamp or the nextCursor of a previous response; without it all
+// entities are listed.
+func (h *ChangeHandlers) ListChanges(c *gin.Context) {
+	after, err := parseChangesSince(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid since, expected an RFC 3339 timestamp or a cursor",
+			err.Error(),
+		))
+		return
+	}
+	pageSize := defaultChangesPageSize
+	if pageSizeStr := c.Query("pageSize"); pageSizeStr != "" {
+		if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 {
+			pageSize = min(ps, maxChangesPageSize)
+		}
+	}
+
+	changes, err := h.changes.ListChanges(c.Request.Context(), after, pageSize+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve changes",
+		))
+		return
+	}
+
+	response := ChangesResponse{Values: changes}
+	if len(changes) > pageSize {
+		response.Values = changes[:pageSize]
+		response.HasMore = true
+	}
+	if response.Values == nil {
+		response.Values = []models.EntityChange{}
+	}
+	if n := len(response.Values); n > 0 {
+		last := response.Values[n-1]
+		after = repositories.ChangeCursor{ChangedAt: last.ChangedAt, Type: last.Type, URN: last.URN}
+	}
+	response.NextCursor = encodeChangeCursor(after)
+	c.JSON(http.StatusOK, response)
+}
+
+// parseChangesSince reads since as a timestamp, which includes changes at that
+// time, or as a cursor
+func parseChangesSince(since string) (repositories.ChangeCursor, error) {
+	if since == "" {
+		return repositories.ChangeCursor{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, since); err == nil {
+		return repositories.ChangeCursor{ChangedAt: t}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(since)
+	if err != nil {
+		return repositories.ChangeCursor{}, errors.New("since is neither a timestamp nor a cursor")
+	}
+	var token changeCursorToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return repositories.ChangeCursor{}, errors.New("since is neither a timestamp nor a cursor")
+	}
+	return repositories.ChangeCursor{ChangedAt: token.ChangedAt, Type: token.Type, URN: token.URN}, nil
+}
+
+// encodeChangeCursor returns the opaque form of cursor
+func encodeChangeCursor(cursor repositories.ChangeCursor) string {
+	data, _ := json.Marshal(changeCursorToken{ChangedAt: cursor.ChangedAt, Type: cursor.Type, URN: cursor.URN})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+	"github.com/mhrivnak/ssvirt/pkg/version"
+)
+
+// ComponentHandlers report the controller processes and the builds they run
+type ComponentHandlers struct {
+	heartbeatRepo  *repositories.ComponentHeartbeatRepository
+	templateAccess *services.TemplateAccessChecker
+	now            func() time.Time
+}
+
+// NewComponentHandlers creates a new ComponentHandlers instance
+func NewComponentHandlers(heartbeatRepo *repositories.ComponentHeartbeatRepository) *ComponentHandlers {
+	return &ComponentHandlers{
+		heartbeatRepo: heartbeatRepo,
+		now:           time.Now,
+	}
+}
+
+// SetTemplateAccess reports the API server's template namespace and permission
+// checks with its component
+func (h *ComponentHandlers) SetTemplateAccess(checker *services.TemplateAccessChecker) {
+	h.templateAccess = checker
+}
+
+// ComponentStatus is the latest heartbeat of a controller process
+type ComponentStatus struct {
+	models.ComponentHeartbeat
+	// Alive is false once the process has missed three heartbeats
+	Alive bool `json:"alive"`
+}
+
+// ComponentsResponse lists the API server and the controller processes
+type ComponentsResponse struct {
+	APIServer  APIServerComponent `json:"apiServer"`
+	Components []ComponentStatus  `json:"components"`
+}
+
+// APIServerComponent describes the API server answering the request
+type APIServerComponent struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	// TemplateAccess is the latest template namespace and permission check,
+	// omitted before the first check or without Kubernetes
+	TemplateAccess *services.TemplateAccessStatus `json:"templateAccess,omitempty"`
+}
+
+// ListComponents handles GET /api/admin/system/components. Each controller
+// replica, leader or standby, records a heartbeat periodically; replicas that
+// stopped reporting are listed as not alive until their heartbeat expires.
+func (h *ComponentHandlers) ListComponents(c *gin.Context) {
+	heartbeats, err := h.heartbeatRepo.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve components",
+		))
+		return
+	}
+
+	now := h.now()
+	components := make([]ComponentStatus, 0, len(heartbeats))
+	for _, heartbeat := range heartbeats {
+		components = append(components, ComponentStatus{
+			ComponentHeartbeat: heartbeat,
+			Alive:              !heartbeat.Stale(now),
+		})
+	}
+	apiServer := APIServerComponent{
+		Version:   version.Get(),
+		GoVersion: runtime.Version(),
+	}
+	if h.templateAccess != nil {
+		if status, checked := h.templateAccess.Status(); checked {
+			apiServer.TemplateAccess = &status
+		}
+	}
+	c.JSON(http.StatusOK, ComponentsResponse{
+		APIServer:  apiServer,
+		Components: components,
+	})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// DeletionResponse is returned with 202 Accepted by deletions that finish in
+// the background. The task, when task tracking is enabled, reports progress
+// and the outcome.
+type DeletionResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Href     string `json:"href"`
+	TaskID   string `json:"taskId,omitempty"`
+	TaskHref string `json:"taskHref,omitempty"`
+}
+
+// acceptDeletion writes the 202 Accepted response of a background deletion,
+// pointing the Location header at its task like VMware Cloud Director does
+func acceptDeletion(c *gin.Context, response DeletionResponse, task *models.Task) {
+	if task != nil {
+		response.TaskID = task.ID
+		response.TaskHref = fmt.Sprintf("/cloudapi/1.0.0/tasks/%s", task.ID)
+		c.Header("Location", response.TaskHref)
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// runInBackground runs fn after the request returns. Under work, the API
+// server waits for fn on shutdown and cancels its context at the deadline;
+// without it fn runs in a plain goroutine that is never cancelled.
+func runInBackground(c *gin.Context, work *services.BackgroundWork, fn func(ctx context.Context)) {
+	if work != nil {
+		work.Go(fn)
+		return
+	}
+	go fn(context.WithoutCancel(c.Request.Context()))
+}
+
+// publishTaskUpdate notifies task waiters and notification subscribers. bus
+// may be nil.
+func publishTaskUpdate(bus *events.Bus, task *models.Task, status string) {
+	if bus == nil {
+		return
+	}
+	bus.Publish(events.Event{
+		Type:       events.TypeTaskUpdated,
+		EntityType: events.EntityTask,
+		EntityID:   task.ID,
+		OrgID:      task.OrganizationID,
+		Data: map[string]interface{}{
+			"name":    task.Name,
+			"status":  status,
+			"ownerId": task.OwnerID,
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// forceOverrideAdmin returns the System Administrator making the request, or
+// nil when the caller is not one. Force overrides skip state validation and
+// proceed when the tenant's namespace is broken, so only System Administrators
+// may use them; without a role cache nobody can.
+func forceOverrideAdmin(c *gin.Context, roleCache *auth.RoleCache) (*models.User, error) {
+	if roleCache == nil {
+		return nil, nil
+	}
+	user, err := auth.UserWithRoles(c, roleCache)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range user.Roles {
+		if role.IsSystemAdmin() {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+// auditForceOverride records a force override in the audit log. Impersonated
+// requests carry the tenant user's roles and cannot override, so admin is the
+// administrator who asked for it.
+func auditForceOverride(c *gin.Context, admin *models.User, action, entityID, entityName string, attrs ...any) {
+	slog.Info("audit: force override", append([]any{
+		"action", action,
+		"admin_id", admin.ID,
+		"admin", admin.Username,
+		"entity_id", entityID,
+		"entity", entityName,
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+	}, attrs...)...)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// GroupSyncHandlers report what the OpenShift Group sync would change
+type GroupSyncHandlers struct {
+	sync   services.GroupSyncService
+	logger *slog.Logger
+}
+
+// NewGroupSyncHandlers creates a new GroupSyncHandlers instance. sync is nil
+// when Kubernetes is not configured.
+func NewGroupSyncHandlers(sync services.GroupSyncService) *GroupSyncHandlers {
+	return &GroupSyncHandlers{
+		sync:   sync,
+		logger: slog.Default(),
+	}
+}
+
+// GetGroupSyncReport handles GET /api/admin/groupSync/report. It is a dry run:
+// it compares the members of the mapped OpenShift Groups with the users'
+// organizations and roles and reports the changes the groupsync controller
+// would make, without making them.
+func (h *GroupSyncHandlers) GetGroupSyncReport(c *gin.Context) {
+	if h.sync == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"Group sync is not available",
+		))
+		return
+	}
+
+	report, err := h.sync.Plan(c.Request.Context())
+	switch {
+	case errors.Is(err, services.ErrGroupsUnavailable):
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"OpenShift Groups are not available",
+		))
+		return
+	case err != nil:
+		h.logger.Error("Failed to plan group sync", "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to plan group sync",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+)
+
+// notificationHeartbeatInterval is how often a comment line is sent to keep idle
+// streams open through proxies
+const notificationHeartbeatInterval = 30 * time.Second
+
+// NotificationHandlers streams entity change events to clients
+type NotificationHandlers struct {
+	bus     *events.Bus
+	orgRepo *repositories.OrganizationRepository
+}
+
+// NewNotificationHandlers creates a new NotificationHandlers instance
+func NewNotificationHandlers(bus *events.Bus, orgRepo *repositories.OrganizationRepository) *NotificationHandlers {
+	return &NotificationHandlers{
+		bus:     bus,
+		orgRepo: orgRepo,
+	}
+}
+
+// StreamNotifications handles GET /cloudapi/1.0.0/notifications
+//
+// Events are delivered as Server-Sent Events, filtered to organizations the
+// authenticated user can access. The optional "types" query parameter restricts
+// the stream to a comma-separated list of event types.
+func (h *NotificationHandlers) StreamNotifications(c *gin.Context) {
+	// Extract user ID from JWT claims
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	orgIDs, allOrgs, err := h.orgRepo.AccessibleOrgIDs(c.Request.Context(), userClaims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to resolve accessible organizations",
+		))
+		return
+	}
+
+	allowedOrgs := make(map[string]bool, len(orgIDs))
+	for _, id := range orgIDs {
+		allowedOrgs[id] = true
+	}
+
+	var allowedTypes map[string]bool
+	if typesParam := c.Query("types"); typesParam != "" {
+		allowedTypes = make(map[string]bool)
+		for _, t := range strings.Split(typesParam, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				allowedTypes[t] = true
+			}
+		}
+	}
+
+	sub := h.bus.Subscribe(0)
+	defer sub.Close()
+
+	// Streams outlive the server's write timeout, so clear the deadline for this response
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(notificationHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if allowedTypes != nil && !allowedTypes[event.Type] {
+				continue
+			}
+			if !allOrgs && !allowedOrgs[event.OrgID] {
+				continue
+			}
+			c.Render(-1, sse.Event{
+				Id:    event.ID,
+				Event: event.Type,
+				Data:  event,
+			})
+			c.Writer.Flush()
+		}
+	}
+}
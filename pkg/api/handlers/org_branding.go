@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// OrgBrandingRequest represents the request body for updating organization branding.
+// All fields are replaced; omitted fields are cleared.
+type OrgBrandingRequest struct {
+	LogoURL        string `json:"logoUrl"`
+	PrimaryColor   string `json:"primaryColor"`
+	SecondaryColor string `json:"secondaryColor"`
+	SupportEmail   string `json:"supportEmail"`
+	SupportURL     string `json:"supportUrl"`
+	SupportPhone   string `json:"supportPhone"`
+}
+
+// GetOrgBranding handles GET /cloudapi/1.0.0/orgs/{id}/branding
+func (h *OrgHandlers) GetOrgBranding(c *gin.Context) {
+	// Extract user ID from JWT claims
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token"})
+		return
+	}
+
+	id := c.Param("id")
+
+	// Validate URN type is "org"
+	urnType, err := models.GetURNType(id)
+	if err != nil || urnType != "org" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID format"})
+		return
+	}
+
+	// Tenants can read branding for any organization they can access
+	if _, err := h.orgRepo.GetAccessibleOrg(c.Request.Context(), userClaims.UserID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organization"})
+		return
+	}
+
+	branding, err := h.orgRepo.GetBranding(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organization branding"})
+		return
+	}
+
+	c.JSON(http.StatusOK, branding)
+}
+
+// UpdateOrgBranding handles PUT /cloudapi/1.0.0/orgs/{id}/branding
+func (h *OrgHandlers) UpdateOrgBranding(c *gin.Context) {
+	id := c.Param("id")
+
+	// Validate URN type is "org"
+	urnType, err := models.GetURNType(id)
+	if err != nil || urnType != "org" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID format"})
+		return
+	}
+
+	if _, err := h.orgRepo.GetByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organization"})
+		return
+	}
+
+	var req OrgBrandingRequest
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if msg := validateOrgBranding(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	branding := &models.OrgBranding{
+		OrganizationID: id,
+		LogoURL:        req.LogoURL,
+		PrimaryColor:   req.PrimaryColor,
+		SecondaryColor: req.SecondaryColor,
+		SupportEmail:   req.SupportEmail,
+		SupportURL:     req.SupportURL,
+		SupportPhone:   req.SupportPhone,
+	}
+
+	if err := h.orgRepo.SaveBranding(c.Request.Context(), branding); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization branding"})
+		return
+	}
+
+	updated, err := h.orgRepo.GetBranding(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated organization branding"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// validateOrgBranding returns an error message for the first invalid field, or "" if valid
+func validateOrgBranding(req OrgBrandingRequest) string {
+	if req.LogoURL != "" && !isHTTPURL(req.LogoURL) {
+		return "logoUrl must be an absolute http or https URL"
+	}
+	if req.SupportURL != "" && !isHTTPURL(req.SupportURL) {
+		return "supportUrl must be an absolute http or https URL"
+	}
+	if req.PrimaryColor != "" && !hexColorRegex.MatchString(req.PrimaryColor) {
+		return "primaryColor must be a hex color such as #1A2B3C"
+	}
+	if req.SecondaryColor != "" && !hexColorRegex.MatchString(req.SecondaryColor) {
+		return "secondaryColor must be a hex color such as #1A2B3C"
+	}
+	if req.SupportEmail != "" {
+		if _, err := mail.ParseAddress(req.SupportEmail); err != nil {
+			return "supportEmail must be a valid email address"
+		}
+	}
+	if len(req.SupportPhone) > 64 {
+		return "supportPhone must be at most 64 characters"
+	}
+	return ""
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
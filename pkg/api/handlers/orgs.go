@@ -11,13 +11,16 @@ import (
 
 	"github.com/mhrivnak/ssvirt/pkg/api/types"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/config"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
 )
 
 // OrgHandlers contains handlers for organization-related CloudAPI endpoints
 type OrgHandlers struct {
-	orgRepo *repositories.OrganizationRepository
+	orgRepo        *repositories.OrganizationRepository
+	defaultCatalog config.DefaultCatalogConfig
+	roleCache      *auth.RoleCache
 }
 
 // CreateOrgRequest represents the request body for creating an organization
@@ -29,6 +32,16 @@ type CreateOrgRequest struct {
 	CanManageOrgs           *bool  `json:"canManageOrgs"`
 	CanPublish              *bool  `json:"canPublish"`
 	MaskedEventTaskUsername string `json:"maskedEventTaskUsername"`
+	// ManagedBy optionally references the parent organization
+	ManagedBy *models.EntityRef `json:"managedBy"`
+	// ExternalID makes creation idempotent: repeating a request with the same
+	// external ID and name returns the organization created by the first one
+	ExternalID string `json:"externalId"`
+	// SecurityPolicy lists the privileged VM settings the organization allows
+	SecurityPolicy *models.OrgSecurityPolicy `json:"securityPolicy"`
+	// Quota caps resources across all of the organization's VDCs; only System
+	// Administrators may set it
+	Quota *models.OrgQuota `json:"quota"`
 }
 
 // UpdateOrgRequest represents the request body for updating an organization
@@ -40,6 +53,13 @@ type UpdateOrgRequest struct {
 	CanManageOrgs           *bool  `json:"canManageOrgs"`
 	CanPublish              *bool  `json:"canPublish"`
 	MaskedEventTaskUsername string `json:"maskedEventTaskUsername"`
+	// ManagedBy sets the parent organization; an empty ID detaches the organization
+	ManagedBy *models.EntityRef `json:"managedBy"`
+	// SecurityPolicy replaces the organization's VM security policy
+	SecurityPolicy *models.OrgSecurityPolicy `json:"securityPolicy"`
+	// Quota replaces the organization's quota; a quota without any ceiling
+	// removes it. Only System Administrators may change it.
+	Quota *models.OrgQuota `json:"quota"`
 }
 
 // NewOrgHandlers creates a new OrgHandlers instance
@@ -49,6 +69,43 @@ func NewOrgHandlers(orgRepo *repositories.OrganizationRepository) *OrgHandlers {
 	}
 }
 
+// SetDefaultCatalog configures the catalog created with each new organization
+func (h *OrgHandlers) SetDefaultCatalog(cfg config.DefaultCatalogConfig) {
+	h.defaultCatalog = cfg
+}
+
+// SetRoleCache lets System Administrators set organization quotas. When unset,
+// requests that set a quota are refused.
+func (h *OrgHandlers) SetRoleCache(roleCache *auth.RoleCache) {
+	h.roleCache = roleCache
+}
+
+// authorizeQuota checks a requested organization quota, writing an error
+// response and returning false if it is invalid or the user may not set it
+func (h *OrgHandlers) authorizeQuota(c *gin.Context, quota *models.OrgQuota) bool {
+	if quota == nil {
+		return true
+	}
+	if quota.CPUCores < 0 || quota.MemoryMB < 0 || quota.StorageMB < 0 || quota.VMCount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization quota ceilings cannot be negative"})
+		return false
+	}
+	if h.roleCache != nil {
+		user, err := auth.UserWithRoles(c, h.roleCache)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user permissions"})
+			return false
+		}
+		for _, role := range user.Roles {
+			if role.IsSystemAdmin() {
+				return true
+			}
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Only System Administrators can set organization quotas"})
+	return false
+}
+
 // ListOrgs handles GET /cloudapi/1.0.0/orgs
 func (h *OrgHandlers) ListOrgs(c *gin.Context) {
 	// Extract user ID from JWT claims
@@ -83,15 +140,21 @@ func (h *OrgHandlers) ListOrgs(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
+	// Exact-match lookups let infrastructure-as-code tools import existing organizations
+	filter := repositories.OrgListFilter{
+		Name:       c.Query("name"),
+		ExternalID: c.Query("externalId"),
+	}
+
 	// Get total count of accessible organizations
-	totalCount, err := h.orgRepo.CountAccessibleOrgs(c.Request.Context(), userClaims.UserID)
+	totalCount, err := h.orgRepo.CountAccessibleOrgs(c.Request.Context(), userClaims.UserID, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count organizations"})
 		return
 	}
 
 	// Get organizations accessible to the user
-	orgs, err := h.orgRepo.ListAccessibleOrgs(c.Request.Context(), userClaims.UserID, limit, offset)
+	orgs, err := h.orgRepo.ListAccessibleOrgs(c.Request.Context(), userClaims.UserID, filter, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizations"})
 		return
@@ -154,10 +217,35 @@ func (h *OrgHandlers) GetOrg(c *gin.Context) {
 // CreateOrg handles POST /cloudapi/1.0.0/orgs
 func (h *OrgHandlers) CreateOrg(c *gin.Context) {
 	var req CreateOrgRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
+	if !h.authorizeQuota(c, req.Quota) {
+		return
+	}
+
+	if req.ExternalID != "" {
+		existingOrg, err := h.orgRepo.GetByExternalID(req.ExternalID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing organization external ID"})
+			return
+		}
+		if existingOrg != nil {
+			if existingOrg.Name != req.Name {
+				c.JSON(http.StatusConflict, gin.H{"error": "Organization external ID is already used by another organization"})
+				return
+			}
+			// A retried create returns the organization it already created
+			org, err := h.orgRepo.GetWithEntityRefs(existingOrg.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organization"})
+				return
+			}
+			c.JSON(http.StatusOK, org)
+			return
+		}
+	}
 
 	// Check if organization name already exists
 	existingOrg, err := h.orgRepo.GetByName(req.Name)
@@ -177,6 +265,11 @@ func (h *OrgHandlers) CreateOrg(c *gin.Context) {
 		Description:             req.Description,
 		MaskedEventTaskUsername: req.MaskedEventTaskUsername,
 	}
+	if req.ExternalID != "" {
+		org.ExternalID = &req.ExternalID
+	}
+	org.SetSecurityPolicy(req.SecurityPolicy)
+	org.SetQuota(req.Quota)
 
 	// Set default display name if not provided
 	if org.DisplayName == "" {
@@ -204,8 +297,34 @@ func (h *OrgHandlers) CreateOrg(c *gin.Context) {
 		org.CanPublish = false
 	}
 
-	// Create organization in database
-	if err := h.orgRepo.Create(org); err != nil {
+	// Set parent organization if provided
+	if req.ManagedBy != nil && req.ManagedBy.ID != "" {
+		parentID, ok := h.resolveParentOrg(c, "", req.ManagedBy.ID)
+		if !ok {
+			return
+		}
+		org.ParentOrgID = parentID
+	}
+
+	// Create organization in database, with its default catalog if configured
+	var defaultCatalog *models.Catalog
+	if h.defaultCatalog.Enabled {
+		defaultCatalog = &models.Catalog{
+			Name:        h.defaultCatalog.Name,
+			Description: h.defaultCatalog.Description,
+			IsLocal:     true,
+		}
+		defaultCatalog.SetItemNames(h.defaultCatalog.Templates)
+		if claims, exists := c.Get(auth.ClaimsContextKey); exists {
+			if userClaims, ok := claims.(*auth.Claims); ok {
+				defaultCatalog.OwnerID = userClaims.UserID
+			}
+		}
+		err = h.orgRepo.CreateWithCatalog(org, defaultCatalog)
+	} else {
+		err = h.orgRepo.Create(org)
+	}
+	if err != nil {
 		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
 			c.JSON(http.StatusConflict, gin.H{"error": "Organization with name already exists"})
 			return
@@ -220,6 +339,9 @@ func (h *OrgHandlers) CreateOrg(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve created organization"})
 		return
 	}
+	if defaultCatalog != nil {
+		createdOrg.DefaultCatalog = &models.EntityRef{Name: defaultCatalog.Name, ID: defaultCatalog.ID}
+	}
 
 	c.JSON(http.StatusCreated, createdOrg)
 }
@@ -257,8 +379,11 @@ func (h *OrgHandlers) UpdateOrg(c *gin.Context) {
 	}
 
 	var req UpdateOrgRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if !h.authorizeQuota(c, req.Quota) {
 		return
 	}
 
@@ -300,6 +425,28 @@ func (h *OrgHandlers) UpdateOrg(c *gin.Context) {
 	if req.CanPublish != nil {
 		org.CanPublish = *req.CanPublish
 	}
+	if req.SecurityPolicy != nil {
+		org.SetSecurityPolicy(req.SecurityPolicy)
+	}
+	if req.Quota != nil {
+		org.SetQuota(req.Quota)
+	}
+
+	if req.ManagedBy != nil {
+		if req.ManagedBy.ID == "" {
+			org.ParentOrgID = nil
+		} else {
+			if org.IsProvider() {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "The Provider organization cannot have a parent organization"})
+				return
+			}
+			parentID, ok := h.resolveParentOrg(c, org.ID, req.ManagedBy.ID)
+			if !ok {
+				return
+			}
+			org.ParentOrgID = parentID
+		}
+	}
 
 	// Update organization in database
 	if err := h.orgRepo.Update(org); err != nil {
@@ -359,11 +506,91 @@ func (h *OrgHandlers) DeleteOrg(c *gin.Context) {
 		return
 	}
 
-	// Delete organization
+	// Delete organization, refusing to orphan child organizations
 	if err := h.orgRepo.Delete(id); err != nil {
+		if errors.Is(err, repositories.ErrOrgHasChildren) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete organization with child organizations"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
 		return
 	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
+
+// GetOrgRollup handles GET /cloudapi/1.0.0/orgs/{id}/rollup
+func (h *OrgHandlers) GetOrgRollup(c *gin.Context) {
+	// Extract user ID from JWT claims
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token"})
+		return
+	}
+
+	id := c.Param("id")
+
+	// Validate URN type is "org"
+	urnType, err := models.GetURNType(id)
+	if err != nil || urnType != "org" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID format"})
+		return
+	}
+
+	// Ensure the user can access the organization
+	if _, err := h.orgRepo.GetAccessibleOrg(c.Request.Context(), userClaims.UserID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organization"})
+		return
+	}
+
+	rollup, err := h.orgRepo.GetRollup(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute organization rollup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rollup)
+}
+
+// resolveParentOrg validates a parent organization reference for orgID (empty for new
+// organizations). It writes an error response and returns false if validation fails.
+func (h *OrgHandlers) resolveParentOrg(c *gin.Context, orgID, parentID string) (*string, bool) {
+	urnType, err := models.GetURNType(parentID)
+	if err != nil || urnType != "org" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid managedBy organization ID format"})
+		return nil, false
+	}
+
+	if _, err := h.orgRepo.GetByID(parentID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent organization not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve parent organization"})
+		return nil, false
+	}
+
+	if err := h.orgRepo.ValidateParent(c.Request.Context(), orgID, parentID); err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrOrgHierarchyCycle):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent organization would create a cycle"})
+		case errors.Is(err, repositories.ErrOrgHierarchyTooDeep):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Organization hierarchy too deep"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate parent organization"})
+		}
+		return nil, false
+	}
+
+	return &parentID, true
+}
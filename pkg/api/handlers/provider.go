@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+// ProviderHandlers serves the provider discovery endpoints that VCD UIs and SDKs
+// call at startup. Values come from the provider and session site configuration.
+type ProviderHandlers struct {
+	config *config.Config
+}
+
+// NewProviderHandlers creates a new ProviderHandlers instance
+func NewProviderHandlers(config *config.Config) *ProviderHandlers {
+	return &ProviderHandlers{config: config}
+}
+
+// SiteResponse describes this site
+type SiteResponse struct {
+	ID                      string `json:"id"`
+	Name                    string `json:"name"`
+	RestEndpoint            string `json:"restEndpoint"`
+	RestEndpointCertificate string `json:"restEndpointCertificate"`
+}
+
+// SiteAssociationResponse describes another site associated with this one
+type SiteAssociationResponse struct {
+	SiteID                  string `json:"siteId"`
+	SiteName                string `json:"siteName"`
+	RestEndpoint            string `json:"restEndpoint"`
+	RestEndpointCertificate string `json:"restEndpointCertificate"`
+	Status                  string `json:"status"`
+}
+
+// FeatureFlagResponse describes an API feature flag
+type FeatureFlagResponse struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// GeneralSettings contains the general system settings reported to admin clients
+type GeneralSettings struct {
+	Href                          string `json:"href"`
+	InstallationID                int    `json:"installationId"`
+	SessionTimeoutMinutes         int    `json:"sessionTimeoutMinutes"`
+	AbsoluteSessionTimeoutMinutes int    `json:"absoluteSessionTimeoutMinutes"`
+}
+
+// SystemSettingsResponse is the system settings document at /api/admin/extension/settings
+type SystemSettingsResponse struct {
+	Href            string          `json:"href"`
+	GeneralSettings GeneralSettings `json:"generalSettings"`
+}
+
+// GetSite handles GET /cloudapi/1.0.0/site
+func (h *ProviderHandlers) GetSite(c *gin.Context) {
+	c.JSON(http.StatusOK, SiteResponse{
+		ID:           h.config.Session.Site.ID,
+		Name:         h.config.Session.Site.Name,
+		RestEndpoint: h.restEndpoint(c),
+	})
+}
+
+// ListSiteAssociations handles GET /cloudapi/1.0.0/siteAssociations. A
+// standalone installation has no associations.
+func (h *ProviderHandlers) ListSiteAssociations(c *gin.Context) {
+	associations := make([]SiteAssociationResponse, 0, len(h.config.Provider.SiteAssociations))
+	for _, association := range h.config.Provider.SiteAssociations {
+		associations = append(associations, SiteAssociationResponse{
+			SiteID:       association.SiteID,
+			SiteName:     association.SiteName,
+			RestEndpoint: association.RestEndpoint,
+			Status:       "ACTIVE",
+		})
+	}
+
+	c.JSON(http.StatusOK, types.NewPage(associations, 1, max(len(associations), 1), int64(len(associations))))
+}
+
+// ListFeatureFlags handles GET /cloudapi/1.0.0/featureFlags
+func (h *ProviderHandlers) ListFeatureFlags(c *gin.Context) {
+	names := make([]string, 0, len(h.config.Provider.FeatureFlags))
+	for name := range h.config.Provider.FeatureFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	flags := make([]FeatureFlagResponse, len(names))
+	for i, name := range names {
+		flags[i] = FeatureFlagResponse{
+			ID:      "urn:vcloud:featureFlag:" + name,
+			Name:    name,
+			Enabled: h.config.Provider.FeatureFlags[name],
+		}
+	}
+
+	c.JSON(http.StatusOK, types.NewPage(flags, 1, max(len(flags), 1), int64(len(flags))))
+}
+
+// GetSystemSettings handles GET /api/admin/extension/settings
+func (h *ProviderHandlers) GetSystemSettings(c *gin.Context) {
+	endpoint := h.restEndpoint(c)
+	c.JSON(http.StatusOK, SystemSettingsResponse{
+		Href:            endpoint + "/api/admin/extension/settings",
+		GeneralSettings: h.generalSettings(endpoint),
+	})
+}
+
+// GetGeneralSettings handles GET /api/admin/extension/settings/general
+func (h *ProviderHandlers) GetGeneralSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, h.generalSettings(h.restEndpoint(c)))
+}
+
+func (h *ProviderHandlers) generalSettings(endpoint string) GeneralSettings {
+	return GeneralSettings{
+		Href:                          endpoint + "/api/admin/extension/settings/general",
+		InstallationID:                h.config.Provider.InstallationID,
+		SessionTimeoutMinutes:         h.config.Session.IdleTimeoutMinutes,
+		AbsoluteSessionTimeoutMinutes: int(h.config.Auth.TokenExpiry.Minutes()),
+	}
+}
+
+// restEndpoint returns the configured public URL, or the URL the client used
+// to reach this request when none is configured
+func (h *ProviderHandlers) restEndpoint(c *gin.Context) string {
+	if h.config.Provider.RestEndpoint != "" {
+		return strings.TrimSuffix(h.config.Provider.RestEndpoint, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := c.GetHeader("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
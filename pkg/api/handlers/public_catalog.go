@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	domainerrors "github.com/mhrivnak/ssvirt/pkg/domain/errors"
+)
+
+// PublicCatalogHandlers serve the published catalogs without authentication,
+// for self-service portals that show the offering before login. Only names,
+// descriptions and icons are returned, and catalogs that are not published to
+// every organization are reported as not found.
+type PublicCatalogHandlers struct {
+	catalogRepo     *repositories.CatalogRepository
+	catalogItemRepo *repositories.CatalogItemRepository
+}
+
+// NewPublicCatalogHandlers creates a new PublicCatalogHandlers instance
+func NewPublicCatalogHandlers(catalogRepo *repositories.CatalogRepository, catalogItemRepo *repositories.CatalogItemRepository) *PublicCatalogHandlers {
+	return &PublicCatalogHandlers{
+		catalogRepo:     catalogRepo,
+		catalogItemRepo: catalogItemRepo,
+	}
+}
+
+// PublicCatalog is a published catalog as shown to anonymous users
+type PublicCatalog struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// PublicCatalogItem is an item of a published catalog as shown to anonymous users
+type PublicCatalogItem struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IconClass   string `json:"iconClass,omitempty"`
+}
+
+// ListPublicCatalogs handles GET /cloudapi/1.0.0/public/catalogs
+func (h *PublicCatalogHandlers) ListPublicCatalogs(c *gin.Context) {
+	page, pageSize := parsePaginationParams(c)
+	ctx := c.Request.Context()
+
+	total, err := h.catalogRepo.CountPublished(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve catalogs",
+		))
+		return
+	}
+	catalogs, err := h.catalogRepo.ListPublished(ctx, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve catalogs",
+		))
+		return
+	}
+
+	values := make([]PublicCatalog, 0, len(catalogs))
+	for _, catalog := range catalogs {
+		values = append(values, PublicCatalog{ID: catalog.ID, Name: catalog.Name, Description: catalog.Description})
+	}
+	c.JSON(http.StatusOK, types.NewPage(values, page, pageSize, total))
+}
+
+// ListPublicCatalogItems handles GET /cloudapi/1.0.0/public/catalogs/{catalogUrn}/catalogItems.
+// Items that fail validation cannot be instantiated and are left out.
+func (h *PublicCatalogHandlers) ListPublicCatalogItems(c *gin.Context) {
+	catalogID := c.Param("catalogUrn")
+	catalog, err := h.catalogRepo.GetByID(catalogID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve catalog",
+		))
+		return
+	}
+	if err != nil || !catalog.IsPublished {
+		c.JSON(http.StatusNotFound, NewAPIError(
+			http.StatusNotFound,
+			"Not Found",
+			"Catalog not found",
+		))
+		return
+	}
+
+	page, pageSize := parsePaginationParams(c)
+	ctx := c.Request.Context()
+	const filter = "validationStatus==VALID"
+
+	total, err := h.catalogItemRepo.CountByCatalogID(ctx, catalog.ID, filter)
+	if err != nil {
+		h.sendItemsError(c, err)
+		return
+	}
+	catalogItems, err := h.catalogItemRepo.ListByCatalogID(ctx, catalog.ID, filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		h.sendItemsError(c, err)
+		return
+	}
+
+	items := make([]PublicCatalogItem, 0, len(catalogItems))
+	for _, item := range catalogItems {
+		items = append(items, PublicCatalogItem{
+			ID:          item.ID,
+			Name:        item.Name,
+			Description: item.Description,
+			IconClass:   item.IconClass,
+		})
+	}
+	c.JSON(http.StatusOK, types.NewPage(items, page, pageSize, total))
+}
+
+// sendItemsError reports a failure to read the items of a catalog, which may
+// have been deleted meanwhile
+func (h *PublicCatalogHandlers) sendItemsError(c *gin.Context, err error) {
+	if errors.Is(err, domainerrors.ErrNotFound) {
+		c.JSON(http.StatusNotFound, NewAPIError(
+			http.StatusNotFound,
+			"Not Found",
+			"Catalog not found",
+		))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, NewAPIError(
+		http.StatusInternalServerError,
+		"Internal Server Error",
+		"Failed to retrieve catalog items",
+	))
+}
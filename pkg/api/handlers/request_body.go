@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// StrictJSONContextKey is set on requests of route groups whose JSON bodies may
+// not contain unknown fields
+const StrictJSONContextKey = "strict_json"
+
+// FieldError describes why one field of a request body is invalid. Field is
+// the JSON path of the field, such as "disks[0].size", and is empty when the
+// body as a whole is invalid.
+type FieldError struct {
+	Field      string `json:"field"`
+	Constraint string `json:"constraint"`
+	Message    string `json:"message"`
+}
+
+func init() {
+	// Report validation failures by JSON field name rather than Go field name
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// bindJSON decodes the JSON request body into obj and validates it like
+// ShouldBindJSON, also rejecting unknown fields when the request's route group
+// decodes JSON strictly
+func bindJSON(c *gin.Context, obj interface{}) error {
+	if !c.GetBool(StrictJSONContextKey) {
+		return c.ShouldBindJSON(obj)
+	}
+	if c.Request == nil || c.Request.Body == nil {
+		return errors.New("invalid request")
+	}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// respondBindError writes a 400 response for an error returned by bindJSON,
+// listing the invalid fields so clients can highlight them
+func respondBindError(c *gin.Context, err error) {
+	fieldErrors := bindingFieldErrors(err)
+	messages := make([]string, len(fieldErrors))
+	for i, fieldError := range fieldErrors {
+		messages[i] = fieldError.Message
+	}
+	apiErr := NewAPIError(
+		http.StatusBadRequest,
+		"Bad Request",
+		"Invalid request body",
+		strings.Join(messages, "; "),
+	)
+	apiErr.ValidationErrors = fieldErrors
+	c.JSON(http.StatusBadRequest, apiErr)
+}
+
+// bindingFieldErrors translates a decoding or validation error into field errors
+func bindingFieldErrors(err error) []FieldError {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fieldErrors := make([]FieldError, len(validationErrors))
+		for i, fe := range validationErrors {
+			field := fe.Namespace()
+			// Drop the name of the request type
+			if _, path, found := strings.Cut(field, "."); found {
+				field = path
+			}
+			fieldErrors[i] = FieldError{
+				Field:      field,
+				Constraint: fe.Tag(),
+				Message:    field + " " + constraintMessage(fe),
+			}
+		}
+		return fieldErrors
+	}
+
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &typeError):
+		field := jsonArrayPath(typeError.Field)
+		if field == "" {
+			return []FieldError{{Constraint: "type", Message: "Request body must be a JSON " + jsonTypeName(typeError.Type)}}
+		}
+		return []FieldError{{
+			Field:      field,
+			Constraint: "type",
+			Message:    fmt.Sprintf("%s must be a %s", field, jsonTypeName(typeError.Type)),
+		}}
+	case errors.As(err, &syntaxError), errors.Is(err, io.ErrUnexpectedEOF):
+		return []FieldError{{Constraint: "json", Message: "Request body is not valid JSON"}}
+	case errors.Is(err, io.EOF):
+		return []FieldError{{Constraint: "required", Message: "Request body is required"}}
+	case errors.As(err, &tooLarge):
+		return []FieldError{{Constraint: "maxBytes", Message: fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit)}}
+	}
+	// encoding/json reports unknown fields only by message
+	if field, found := strings.CutPrefix(err.Error(), "json: unknown field "); found {
+		field = strings.Trim(field, `"`)
+		return []FieldError{{Field: field, Constraint: "unknown", Message: field + " is not a known field"}}
+	}
+	return []FieldError{{Constraint: "invalid", Message: err.Error()}}
+}
+
+// jsonArrayPath writes the array indexes of an encoding/json field path, such
+// as "disks.0.size", the way validation errors do: "disks[0].size"
+func jsonArrayPath(field string) string {
+	segments := strings.Split(field, ".")
+	var path strings.Builder
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil && i > 0 {
+			path.WriteString("[" + segment + "]")
+			continue
+		}
+		if i > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(segment)
+	}
+	return path.String()
+}
+
+// constraintMessage describes the constraint a field failed, to follow its name
+func constraintMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min", "max", "len":
+		bound := map[string]string{"min": "at least", "max": "at most", "len": "exactly"}[fe.Tag()]
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, fe.Param())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("must contain %s %s items", bound, fe.Param())
+		}
+		return fmt.Sprintf("must be %s %s", bound, fe.Param())
+	}
+	if fe.Param() != "" {
+		return fmt.Sprintf("does not satisfy the %s=%s constraint", fe.Tag(), fe.Param())
+	}
+	return fmt.Sprintf("does not satisfy the %s constraint", fe.Tag())
+}
+
+// jsonFieldName returns the name a struct field has in JSON
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// jsonTypeName returns the JSON type a Go type is decoded from
+func jsonTypeName(t reflect.Type) string {
+	if t == nil {
+		return "value"
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return "value"
+}
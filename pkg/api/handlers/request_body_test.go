@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	type request struct {
+		Name string `json:"name" binding:"required"`
+	}
+	bind := func(strict bool, body string) error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		if strict {
+			c.Set(StrictJSONContextKey, true)
+		}
+		var req request
+		return bindJSON(c, &req)
+	}
+
+	assert.NoError(t, bind(false, `{"name":"web","extra":1}`))
+	assert.NoError(t, bind(true, `{"name":"web"}`))
+	assert.ErrorContains(t, bind(true, `{"name":"web","extra":1}`), "unknown field")
+	// Struct validation applies to strict decoding too
+	assert.Error(t, bind(true, `{}`))
+}
+
+func TestBindingFieldErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	type disk struct {
+		Size int `json:"size" binding:"min=1"`
+	}
+	type request struct {
+		Name     string `json:"name" binding:"required"`
+		Email    string `json:"email" binding:"omitempty,email"`
+		Password string `json:"password" binding:"omitempty,min=8"`
+		Disks    []disk `json:"disks" binding:"dive"`
+	}
+	fieldErrors := func(strict bool, body string) []FieldError {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		if strict {
+			c.Set(StrictJSONContextKey, true)
+		}
+		var req request
+		err := bindJSON(c, &req)
+		if !assert.Error(t, err) {
+			return nil
+		}
+		return bindingFieldErrors(err)
+	}
+
+	assert.Equal(t, []FieldError{
+		{Field: "name", Constraint: "required", Message: "name is required"},
+		{Field: "email", Constraint: "email", Message: "email must be a valid email address"},
+		{Field: "password", Constraint: "min", Message: "password must be at least 8 characters long"},
+		{Field: "disks[1].size", Constraint: "min", Message: "disks[1].size must be at least 1"},
+	}, fieldErrors(false, `{"email":"nope","password":"short","disks":[{"size":1},{"size":0}]}`))
+	assert.Equal(t, []FieldError{
+		{Field: "disks[0].size", Constraint: "type", Message: "disks[0].size must be a number"},
+	}, fieldErrors(false, `{"name":"web","disks":[{"size":"big"}]}`))
+	assert.Equal(t, []FieldError{
+		{Field: "extra", Constraint: "unknown", Message: "extra is not a known field"},
+	}, fieldErrors(true, `{"name":"web","extra":1}`))
+	assert.Equal(t, []FieldError{
+		{Constraint: "json", Message: "Request body is not valid JSON"},
+	}, fieldErrors(false, `{"name":`))
+	assert.Equal(t, []FieldError{
+		{Constraint: "required", Message: "Request body is required"},
+	}, fieldErrors(true, ``))
+}
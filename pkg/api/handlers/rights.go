@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// RequireRight middleware ensures the authenticated user holds a role granting the named right
+func RequireRight(roleCache *auth.RoleCache, right string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := c.Get(auth.ClaimsContextKey)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, NewAPIError(
+				http.StatusUnauthorized,
+				"Unauthorized",
+				"Authentication required",
+			))
+			c.Abort()
+			return
+		}
+
+		if _, ok := claims.(*auth.Claims); !ok {
+			c.JSON(http.StatusUnauthorized, NewAPIError(
+				http.StatusUnauthorized,
+				"Unauthorized",
+				"Invalid authentication token",
+			))
+			c.Abort()
+			return
+		}
+
+		// Get user with roles, shared with the rest of the request
+		user, err := auth.UserWithRoles(c, roleCache)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to verify user permissions",
+			))
+			c.Abort()
+			return
+		}
+
+		for _, role := range user.Roles {
+			if models.RoleHasRight(role.Name, right) {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, NewAPIError(
+			http.StatusForbidden,
+			"Forbidden",
+			"Insufficient rights",
+			"Operation requires the right: "+right,
+		))
+		c.Abort()
+	}
+}
+
+// respondAccessError writes the response for a failed AccessControl check on the
+// named entity: 404 if it does not exist, 403 if the user may not access it and
+// 500 for any other failure
+func respondAccessError(c *gin.Context, err error, entity string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, NewAPIError(
+			http.StatusNotFound,
+			"Not Found",
+			entity+" not found",
+		))
+	case errors.Is(err, auth.ErrAccessDenied):
+		c.JSON(http.StatusForbidden, NewAPIError(
+			http.StatusForbidden,
+			"Forbidden",
+			entity+" access denied",
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to validate "+entity+" access",
+		))
+	}
+}
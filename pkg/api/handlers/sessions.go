@@ -3,12 +3,15 @@ package handlers
 import (
 	"encoding/base64"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
 	"gorm.io/gorm"
 
+	"github.com/mhrivnak/ssvirt/pkg/api/messages"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
 	"github.com/mhrivnak/ssvirt/pkg/config"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
@@ -20,7 +23,12 @@ type APIError struct {
 	Code    int    `json:"code"`
 	Type    string `json:"error"`
 	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+	// MinorErrorCode identifies the message in the message catalog; clients
+	// should match on it rather than on the message, which may be localized
+	MinorErrorCode string `json:"minorErrorCode,omitempty"`
+	Details        string `json:"details,omitempty"`
+	// ValidationErrors lists the invalid fields of a request body
+	ValidationErrors []FieldError `json:"validationErrors,omitempty"`
 }
 
 // Error implements the error interface
@@ -31,13 +39,19 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Type, e.Message)
 }
 
-// NewAPIError creates a new API error response
+// NewAPIError creates a new API error response. Messages in the message
+// catalog get their code, and the catalog's wording, so they can be localized.
 func NewAPIError(code int, errorType string, message string, details ...string) *APIError {
 	apiErr := &APIError{
 		Code:    code,
 		Type:    errorType,
 		Message: message,
 	}
+	catalog := messages.Default()
+	if minorCode := catalog.Code(message); minorCode != "" {
+		apiErr.MinorErrorCode = minorCode
+		apiErr.Message, _ = catalog.Message(minorCode, language.English)
+	}
 	if len(details) > 0 {
 		apiErr.Details = details[0]
 	}
@@ -49,14 +63,16 @@ type SessionHandlers struct {
 	authSvc    *auth.Service
 	jwtManager *auth.JWTManager
 	config     *config.Config
+	roleCache  *auth.RoleCache
 }
 
-func NewSessionHandlers(userRepo *repositories.UserRepository, authSvc *auth.Service, jwtManager *auth.JWTManager, config *config.Config) *SessionHandlers {
+func NewSessionHandlers(userRepo *repositories.UserRepository, authSvc *auth.Service, jwtManager *auth.JWTManager, config *config.Config, roleCache *auth.RoleCache) *SessionHandlers {
 	return &SessionHandlers{
 		userRepo:   userRepo,
 		authSvc:    authSvc,
 		jwtManager: jwtManager,
 		config:     config,
+		roleCache:  roleCache,
 	}
 }
 
@@ -92,6 +108,11 @@ func (h *SessionHandlers) CreateSession(c *gin.Context) {
 		return
 	}
 
+	// Upgrade the stored hash now that the plaintext password is known to be correct
+	if h.authSvc != nil {
+		h.authSvc.UpgradePasswordHash(user, password)
+	}
+
 	// Get user with roles and organization
 	userWithRoles, err := h.userRepo.GetWithRoles(user.ID)
 	if err != nil {
@@ -118,6 +139,95 @@ func (h *SessionHandlers) CreateSession(c *gin.Context) {
 	c.JSON(http.StatusOK, session)
 }
 
+// ImpersonateSessionRequest selects the tenant user to impersonate by ID or username
+type ImpersonateSessionRequest struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	// Reason is recorded in the audit log, e.g. a support ticket reference
+	Reason string `json:"reason"`
+}
+
+// ImpersonateSession handles POST /cloudapi/1.0.0/sessions/actions/impersonate.
+// It issues a System Administrator a short-lived session acting as a tenant user.
+func (h *SessionHandlers) ImpersonateSession(c *gin.Context) {
+	var req ImpersonateSessionRequest
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if (req.UserID == "") == (req.Username == "") {
+		c.JSON(http.StatusBadRequest, NewAPIError(400, "Bad Request", "Exactly one of userId or username is required"))
+		return
+	}
+
+	admin, err := auth.UserWithRoles(c, h.roleCache)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(500, "Internal Server Error", "Failed to load user data"))
+		return
+	}
+
+	var target *models.User
+	if req.UserID != "" {
+		target, err = h.userRepo.GetByID(req.UserID)
+	} else {
+		target, err = h.userRepo.GetByUsername(req.Username)
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, NewAPIError(404, "Not Found", "User not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(500, "Internal Server Error", "Failed to retrieve user"))
+		return
+	}
+
+	if target.ID == admin.ID {
+		c.JSON(http.StatusBadRequest, NewAPIError(400, "Bad Request", "Cannot impersonate yourself"))
+		return
+	}
+	if !target.Enabled {
+		c.JSON(http.StatusForbidden, NewAPIError(403, "Forbidden", "User account is inactive"))
+		return
+	}
+
+	targetWithRoles, err := h.userRepo.GetWithRoles(target.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(500, "Internal Server Error", "Failed to load user data"))
+		return
+	}
+	for _, role := range targetWithRoles.Roles {
+		if role.Name == models.RoleSystemAdmin {
+			c.JSON(http.StatusForbidden, NewAPIError(403, "Forbidden", "Cannot impersonate a System Administrator"))
+			return
+		}
+	}
+
+	session, err := h.buildSessionResponse(targetWithRoles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(500, "Internal Server Error", "Failed to create session"))
+		return
+	}
+	session.ImpersonatedBy = &models.EntityRef{Name: admin.Username, ID: admin.ID}
+
+	token, err := h.jwtManager.GenerateImpersonation(targetWithRoles.ID, targetWithRoles.Username, session.ID, admin.ID, admin.Username, h.config.Auth.ImpersonationTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(500, "Internal Server Error", "Failed to generate session token"))
+		return
+	}
+
+	slog.Info("audit: impersonation session created",
+		"impersonator_id", admin.ID,
+		"impersonator", admin.Username,
+		"user_id", targetWithRoles.ID,
+		"user", targetWithRoles.Username,
+		"session_id", session.ID,
+		"reason", req.Reason,
+	)
+
+	c.Header("Authorization", "Bearer "+token)
+	c.JSON(http.StatusOK, session)
+}
+
 // GetCurrentSession handles GET /cloudapi/1.0.0/sessions/{sessionId}
 func (h *SessionHandlers) GetCurrentSession(c *gin.Context) {
 	sessionId := c.Param("sessionId")
@@ -135,7 +245,7 @@ func (h *SessionHandlers) GetCurrentSession(c *gin.Context) {
 	}
 
 	// Get user with roles
-	user, err := h.userRepo.GetWithRoles(userID)
+	user, err := auth.UserWithRoles(c, h.roleCache)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, NewAPIError(500, "Internal Server Error", "Failed to load user data"))
 		return
@@ -150,6 +260,12 @@ func (h *SessionHandlers) GetCurrentSession(c *gin.Context) {
 
 	// Use the session ID from the URL
 	session.ID = sessionId
+	if claims, ok := auth.GetClaims(c); ok && claims.IsImpersonation() {
+		session.ImpersonatedBy = &models.EntityRef{
+			Name: derefString(claims.ImpersonatorUsername),
+			ID:   *claims.ImpersonatorID,
+		}
+	}
 	c.JSON(http.StatusOK, session)
 }
 
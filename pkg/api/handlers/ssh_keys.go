@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+// SSHKeyHandlers manages the SSH public keys users register for injection into
+// the VMs they create. Users manage their own keys; System Administrators may
+// manage any user's keys.
+type SSHKeyHandlers struct {
+	sshKeyRepo *repositories.SSHKeyRepository
+	userRepo   *repositories.UserRepository
+	roleCache  *auth.RoleCache
+}
+
+// NewSSHKeyHandlers creates a new SSHKeyHandlers instance
+func NewSSHKeyHandlers(sshKeyRepo *repositories.SSHKeyRepository, userRepo *repositories.UserRepository, roleCache *auth.RoleCache) *SSHKeyHandlers {
+	return &SSHKeyHandlers{
+		sshKeyRepo: sshKeyRepo,
+		userRepo:   userRepo,
+		roleCache:  roleCache,
+	}
+}
+
+// SSHKeyRequest is the request body for registering or updating an SSH key
+type SSHKeyRequest struct {
+	Name      string `json:"name" binding:"required"`
+	PublicKey string `json:"publicKey" binding:"required"`
+}
+
+// ListSSHKeys handles GET /cloudapi/1.0.0/users/{id}/sshKeys
+func (h *SSHKeyHandlers) ListSSHKeys(c *gin.Context) {
+	userID, ok := h.authorizeUser(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := parsePaginationParams(c)
+	keys, err := h.sshKeyRepo.ListByUserIDWithPagination(c.Request.Context(), userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve SSH keys",
+		))
+		return
+	}
+
+	total, err := h.sshKeyRepo.CountByUserID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to count SSH keys",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewPage(keys, page, pageSize, total))
+}
+
+// GetSSHKey handles GET /cloudapi/1.0.0/users/{id}/sshKeys/{keyId}
+func (h *SSHKeyHandlers) GetSSHKey(c *gin.Context) {
+	userID, ok := h.authorizeUser(c)
+	if !ok {
+		return
+	}
+
+	key, ok := h.getKey(c, userID)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// CreateSSHKey handles POST /cloudapi/1.0.0/users/{id}/sshKeys
+func (h *SSHKeyHandlers) CreateSSHKey(c *gin.Context) {
+	userID, ok := h.authorizeUser(c)
+	if !ok {
+		return
+	}
+
+	key := &models.SSHKey{UserID: userID}
+	if !h.bindKey(c, key) {
+		return
+	}
+
+	if err := h.sshKeyRepo.Create(c.Request.Context(), key); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to create SSH key",
+		))
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+// UpdateSSHKey handles PUT /cloudapi/1.0.0/users/{id}/sshKeys/{keyId}
+func (h *SSHKeyHandlers) UpdateSSHKey(c *gin.Context) {
+	userID, ok := h.authorizeUser(c)
+	if !ok {
+		return
+	}
+
+	key, ok := h.getKey(c, userID)
+	if !ok {
+		return
+	}
+	if !h.bindKey(c, key) {
+		return
+	}
+
+	if err := h.sshKeyRepo.Update(c.Request.Context(), key); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to update SSH key",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// DeleteSSHKey handles DELETE /cloudapi/1.0.0/users/{id}/sshKeys/{keyId}
+func (h *SSHKeyHandlers) DeleteSSHKey(c *gin.Context) {
+	userID, ok := h.authorizeUser(c)
+	if !ok {
+		return
+	}
+
+	keyID := c.Param("keyId")
+	if err := h.sshKeyRepo.Delete(c.Request.Context(), userID, keyID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"SSH key not found",
+				fmt.Sprintf("SSH key with ID '%s' does not exist", keyID),
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to delete SSH key",
+		))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// authorizeUser returns the user whose keys are addressed by the request,
+// allowing the user themselves and System Administrators
+func (h *SSHKeyHandlers) authorizeUser(c *gin.Context) (string, bool) {
+	callerID, ok := requireUserID(c)
+	if !ok {
+		return "", false
+	}
+
+	userID := c.Param("id")
+	if !strings.HasPrefix(userID, models.URNPrefixUser) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid user URN format",
+			"User ID must be a valid URN with prefix 'urn:vcloud:user:'",
+		))
+		return "", false
+	}
+
+	if userID != callerID {
+		caller, err := auth.UserWithRoles(c, h.roleCache)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to verify user permissions",
+			))
+			return "", false
+		}
+		isSystemAdmin := false
+		for _, role := range caller.Roles {
+			if role.IsSystemAdmin() {
+				isSystemAdmin = true
+				break
+			}
+		}
+		if !isSystemAdmin {
+			c.JSON(http.StatusForbidden, NewAPIError(
+				http.StatusForbidden,
+				"Forbidden",
+				"SSH keys can only be managed by their owner",
+			))
+			return "", false
+		}
+
+		if _, err := h.userRepo.GetByID(userID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, NewAPIError(
+					http.StatusNotFound,
+					"Not Found",
+					"User not found",
+					fmt.Sprintf("User with ID '%s' does not exist", userID),
+				))
+				return "", false
+			}
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to retrieve user",
+			))
+			return "", false
+		}
+	}
+	return userID, true
+}
+
+// getKey loads the SSH key named in the request, writing a 404 if it does not
+// belong to the user
+func (h *SSHKeyHandlers) getKey(c *gin.Context, userID string) (*models.SSHKey, bool) {
+	keyID := c.Param("keyId")
+	key, err := h.sshKeyRepo.GetByID(c.Request.Context(), userID, keyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"SSH key not found",
+				fmt.Sprintf("SSH key with ID '%s' does not exist", keyID),
+			))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve SSH key",
+		))
+		return nil, false
+	}
+	return key, true
+}
+
+// bindKey validates the request body and applies it to the key, writing a 400
+// for an unparseable key and a 409 when the name or key is already registered
+func (h *SSHKeyHandlers) bindKey(c *gin.Context, key *models.SSHKey) bool {
+	var req SSHKeyRequest
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 255 {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid SSH key name",
+			"Name must be between 1 and 255 characters",
+		))
+		return false
+	}
+
+	publicKey, _, _, rest, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil || strings.TrimSpace(string(rest)) != "" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid SSH public key",
+			"Public key must be a single key in OpenSSH authorized_keys format",
+		))
+		return false
+	}
+	fingerprint := ssh.FingerprintSHA256(publicKey)
+
+	exists, err := h.sshKeyRepo.ExistsForUser(c.Request.Context(), key.UserID, key.ID, name, fingerprint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to validate SSH key",
+		))
+		return false
+	}
+	if exists {
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"SSH key already registered",
+			"The user already has an SSH key with this name or fingerprint",
+		))
+		return false
+	}
+
+	key.Name = name
+	key.PublicKey = strings.TrimSpace(req.PublicKey)
+	key.Fingerprint = fingerprint
+	return true
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// maxStatusMessageLength bounds the message of a status override
+const maxStatusMessageLength = 1024
+
+// SystemStatusHandlers serve the coarse platform status shown to tenants and
+// the administrator's override of it
+type SystemStatusHandlers struct {
+	reporter  *services.SystemStatusReporter
+	overrides *repositories.SystemStatusRepository
+}
+
+// NewSystemStatusHandlers creates a new SystemStatusHandlers instance
+func NewSystemStatusHandlers(reporter *services.SystemStatusReporter, overrides *repositories.SystemStatusRepository) *SystemStatusHandlers {
+	return &SystemStatusHandlers{
+		reporter:  reporter,
+		overrides: overrides,
+	}
+}
+
+// SystemStatusOverrideRequest is the body of PUT /api/admin/system/statusOverride
+type SystemStatusOverrideRequest struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// Subsystems the override applies to; empty applies it to all
+	Subsystems []string   `json:"subsystems"`
+	StartsAt   *time.Time `json:"startsAt"`
+	EndsAt     *time.Time `json:"endsAt"`
+}
+
+// GetSystemStatus handles GET /cloudapi/1.0.0/systemStatus. Any signed-in user
+// may read it; it only reports each subsystem as operational, under
+// maintenance, degraded or out, so tenant portals can embed it.
+func (h *SystemStatusHandlers) GetSystemStatus(c *gin.Context) {
+	report, err := h.reporter.Report(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to determine system status",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetStatusOverride handles GET /api/admin/system/statusOverride
+func (h *SystemStatusHandlers) GetStatusOverride(c *gin.Context) {
+	override, err := h.overrides.GetOverride(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve status override",
+		))
+		return
+	}
+	if override == nil {
+		c.JSON(http.StatusNotFound, NewAPIError(
+			http.StatusNotFound,
+			"Not Found",
+			"No status override is set",
+		))
+		return
+	}
+	c.JSON(http.StatusOK, override)
+}
+
+// SetStatusOverride handles PUT /api/admin/system/statusOverride, replacing
+// any previous override
+func (h *SystemStatusHandlers) SetStatusOverride(c *gin.Context) {
+	var req SystemStatusOverrideRequest
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if msg := validateStatusOverride(req); msg != "" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid status override",
+			msg,
+		))
+		return
+	}
+
+	override := &models.SystemStatusOverride{
+		Status:   req.Status,
+		Message:  req.Message,
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+	}
+	override.SetSubsystems(req.Subsystems)
+	if claims, ok := c.Get(auth.ClaimsContextKey); ok {
+		if userClaims, ok := claims.(*auth.Claims); ok {
+			override.UpdatedBy = userClaims.UserID
+		}
+	}
+	if err := h.overrides.SaveOverride(c.Request.Context(), override); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to save status override",
+		))
+		return
+	}
+	h.reporter.Invalidate()
+	c.JSON(http.StatusOK, override)
+}
+
+// ClearStatusOverride handles DELETE /api/admin/system/statusOverride, so the
+// measured status is reported again
+func (h *SystemStatusHandlers) ClearStatusOverride(c *gin.Context) {
+	if err := h.overrides.ClearOverride(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to clear status override",
+		))
+		return
+	}
+	h.reporter.Invalidate()
+	c.Status(http.StatusNoContent)
+}
+
+// validateStatusOverride returns an error message for the first invalid field, or "" if valid
+func validateStatusOverride(req SystemStatusOverrideRequest) string {
+	if !models.IsValidSystemStatus(req.Status) {
+		return fmt.Sprintf("status must be one of %s, %s, %s or %s", models.SystemStatusOperational,
+			models.SystemStatusMaintenance, models.SystemStatusDegraded, models.SystemStatusOutage)
+	}
+	if len(req.Message) > maxStatusMessageLength {
+		return fmt.Sprintf("message must be at most %d characters", maxStatusMessageLength)
+	}
+	for _, subsystem := range req.Subsystems {
+		if !slices.Contains(models.Subsystems, subsystem) {
+			return fmt.Sprintf("unknown subsystem %q", subsystem)
+		}
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return "endsAt must be after startsAt"
+	}
+	return ""
+}
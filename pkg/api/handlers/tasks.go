@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+)
+
+const (
+	// defaultTaskWaitTimeout applies when waitFor is given without a timeout
+	defaultTaskWaitTimeout = 60 * time.Second
+	// maxTaskWaitTimeout bounds how long a single request may block
+	maxTaskWaitTimeout = 120 * time.Second
+	// taskWaitPollInterval is how often the task is re-read while waiting, so that
+	// updates made by other API server replicas are observed
+	taskWaitPollInterval = time.Second
+)
+
+// taskStatuses lists the valid values for the waitFor query parameter
+var taskStatuses = []string{
+	models.TaskStatusQueued,
+	models.TaskStatusRunning,
+	models.TaskStatusSuccess,
+	models.TaskStatusError,
+	models.TaskStatusAborted,
+	models.TaskStatusPendingApproval,
+}
+
+// TaskHandlers handles task API requests
+type TaskHandlers struct {
+	taskRepo *repositories.TaskRepository
+	orgRepo  *repositories.OrganizationRepository
+	bus      *events.Bus
+}
+
+// NewTaskHandlers creates a new TaskHandlers instance
+func NewTaskHandlers(taskRepo *repositories.TaskRepository, orgRepo *repositories.OrganizationRepository, bus *events.Bus) *TaskHandlers {
+	return &TaskHandlers{
+		taskRepo: taskRepo,
+		orgRepo:  orgRepo,
+		bus:      bus,
+	}
+}
+
+// TaskResponse represents a task in API responses
+type TaskResponse struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Operation    string            `json:"operation"`
+	Status       string            `json:"status"`
+	Progress     int               `json:"progress"`
+	Owner        models.EntityRef  `json:"owner"`
+	Org          *models.EntityRef `json:"org,omitempty"`
+	User         *models.EntityRef `json:"user,omitempty"`
+	StartTime    time.Time         `json:"startTime"`
+	EndTime      *time.Time        `json:"endTime,omitempty"`
+	ErrorMessage string            `json:"errorMessage,omitempty"`
+	// Result is the outcome of operations that report one, such as user imports
+	Result json.RawMessage `json:"result,omitempty"`
+	Href   string          `json:"href"`
+}
+
+// GetTask handles GET /cloudapi/1.0.0/tasks/{task_id}
+//
+// With the waitFor query parameter (a comma-separated list of task statuses) the
+// request blocks until the task reaches one of those statuses or a terminal
+// status, or until timeout elapses. The current task is returned in all cases,
+// so a timed-out wait is not an error.
+func (h *TaskHandlers) GetTask(c *gin.Context) {
+	// Extract user ID from JWT claims
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	taskID := c.Param("task_id")
+	if !strings.HasPrefix(taskID, models.URNPrefixTask) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid task URN format",
+		))
+		return
+	}
+
+	waitFor, err := parseWaitFor(c.Query("waitFor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid waitFor parameter",
+			err.Error(),
+		))
+		return
+	}
+
+	timeout, err := parseTaskWaitTimeout(c.Query("timeout"), len(waitFor) > 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid timeout parameter",
+			err.Error(),
+		))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Subscribe before the first read so an update between the read and the wait is not missed
+	var sub *events.Subscription
+	if timeout > 0 && h.bus != nil {
+		sub = h.bus.Subscribe(0)
+		defer sub.Close()
+	}
+
+	task, err := h.taskRepo.GetByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"Task not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve task",
+		))
+		return
+	}
+
+	allowed, err := h.canAccessTask(c, userClaims.UserID, task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to resolve accessible organizations",
+		))
+		return
+	}
+	if !allowed {
+		// Return 404 rather than 403 to avoid disclosing tasks in other organizations
+		c.JSON(http.StatusNotFound, NewAPIError(
+			http.StatusNotFound,
+			"Not Found",
+			"Task not found",
+		))
+		return
+	}
+
+	if timeout > 0 && !taskWaitSatisfied(task, waitFor) {
+		// The wait may outlast the server's write timeout, so extend the deadline for this response
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout + 10*time.Second))
+
+		task, err = h.waitForTask(c, task, waitFor, timeout, sub)
+		if err != nil {
+			if ctx.Err() != nil {
+				// Client went away
+				return
+			}
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to retrieve task",
+			))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, toTaskResponse(task))
+}
+
+// waitForTask blocks until the task satisfies waitFor, the timeout elapses, or the
+// request is cancelled, and returns the latest state of the task
+func (h *TaskHandlers) waitForTask(c *gin.Context, task *models.Task, waitFor map[string]bool, timeout time.Duration, sub *events.Subscription) (*models.Task, error) {
+	ctx := c.Request.Context()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(taskWaitPollInterval)
+	defer ticker.Stop()
+
+	var updates <-chan events.Event
+	if sub != nil {
+		updates = sub.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return task, nil
+		case event, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			if event.Type != events.TypeTaskUpdated || event.EntityID != task.ID {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		latest, err := h.taskRepo.GetByID(ctx, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		task = latest
+		if taskWaitSatisfied(task, waitFor) {
+			return task, nil
+		}
+	}
+}
+
+// canAccessTask reports whether a user may view a task. Users can always see tasks
+// they started, and otherwise tasks in organizations they can access.
+func (h *TaskHandlers) canAccessTask(c *gin.Context, userID string, task *models.Task) (bool, error) {
+	if task.UserID != "" && task.UserID == userID {
+		return true, nil
+	}
+
+	orgIDs, allOrgs, err := h.orgRepo.AccessibleOrgIDs(c.Request.Context(), userID)
+	if err != nil {
+		return false, err
+	}
+	if allOrgs {
+		return true, nil
+	}
+	for _, id := range orgIDs {
+		if id == task.OrganizationID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// taskWaitSatisfied reports whether a wait on the task can end
+func taskWaitSatisfied(task *models.Task, waitFor map[string]bool) bool {
+	return task.IsTerminal() || waitFor[task.Status]
+}
+
+// parseWaitFor parses a comma-separated, case-insensitive list of task statuses
+func parseWaitFor(value string) (map[string]bool, error) {
+	waitFor := make(map[string]bool)
+	if value == "" {
+		return waitFor, nil
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		matched := false
+		for _, status := range taskStatuses {
+			if strings.EqualFold(part, status) {
+				waitFor[status] = true
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unknown task status %q, must be one of: %s", part, strings.Join(taskStatuses, ", "))
+		}
+	}
+	return waitFor, nil
+}
+
+// parseTaskWaitTimeout parses the timeout query parameter as a Go duration (e.g.
+// "60s") or a number of seconds. Without a timeout, waiting is only enabled when
+// waitFor is set.
+func parseTaskWaitTimeout(value string, waiting bool) (time.Duration, error) {
+	if value == "" {
+		if waiting {
+			return defaultTaskWaitTimeout, nil
+		}
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("timeout must be a duration such as 60s or a number of seconds")
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("timeout must not be negative")
+	}
+	if timeout > maxTaskWaitTimeout {
+		return 0, fmt.Errorf("timeout must not exceed %s", maxTaskWaitTimeout)
+	}
+	return timeout, nil
+}
+
+// toTaskResponse converts a task model to its API representation
+func toTaskResponse(task *models.Task) TaskResponse {
+	response := TaskResponse{
+		ID:        task.ID,
+		Name:      task.Name,
+		Operation: task.Operation,
+		Status:    task.Status,
+		Progress:  task.Progress,
+		Owner:     models.EntityRef{Name: task.OwnerName, ID: task.OwnerID},
+		StartTime: task.StartTime,
+		EndTime:   task.EndTime,
+		Href:      fmt.Sprintf("/cloudapi/1.0.0/tasks/%s", task.ID),
+	}
+	if task.OrganizationID != "" {
+		response.Org = &models.EntityRef{ID: task.OrganizationID}
+	}
+	if task.UserID != "" {
+		response.User = &models.EntityRef{ID: task.UserID}
+	}
+	if task.Status == models.TaskStatusError {
+		response.ErrorMessage = task.Details
+	}
+	if task.ResultData != "" {
+		response.Result = json.RawMessage(task.ResultData)
+	}
+	return response
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// tenantSpecMaxBytes caps the size of a tenant spec
+const tenantSpecMaxBytes = 5 << 20
+
+// TenantHandlers apply and export declarative tenant specs, so organizations
+// and their users, VDCs and catalogs can be managed from Git
+type TenantHandlers struct {
+	db         *database.DB
+	k8sService services.KubernetesService
+	roleCache  *auth.RoleCache
+	logger     *slog.Logger
+}
+
+// NewTenantHandlers creates a new TenantHandlers instance. k8sService may be
+// nil, in which case no VDC namespaces are created or deleted.
+func NewTenantHandlers(db *database.DB, k8sService services.KubernetesService, roleCache *auth.RoleCache) *TenantHandlers {
+	return &TenantHandlers{
+		db:         db,
+		k8sService: k8sService,
+		roleCache:  roleCache,
+		logger:     slog.Default(),
+	}
+}
+
+// ApplyTenants handles POST /api/admin/tenants/apply. The body is a tenant
+// spec in YAML or JSON. With dryRun=true the changes are only reported, and
+// with prune=true undeclared records are deleted.
+func (h *TenantHandlers) ApplyTenants(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, tenantSpecMaxBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid tenant spec",
+			err.Error(),
+		))
+		return
+	}
+	spec, err := database.ParseTenantSpec(body)
+	if err != nil {
+		h.respondApplyError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	opts := database.TenantApplyOptions{
+		DryRun: c.Query("dryRun") == "true",
+		Prune:  c.Query("prune") == "true",
+	}
+	if h.k8sService != nil {
+		opts.NamespaceInUse = func(name string) (bool, error) {
+			return h.k8sService.NamespaceExists(ctx, name)
+		}
+	}
+	result, err := h.db.ApplyTenants(ctx, spec, opts)
+	if err != nil {
+		h.respondApplyError(c, err)
+		return
+	}
+
+	if !result.DryRun {
+		if h.k8sService != nil {
+			for i := range result.CreatedVDCs {
+				vdc := &result.CreatedVDCs[i]
+				if err := h.k8sService.EnsureNamespaceForVDC(ctx, vdc, vdc.Organization); err != nil {
+					h.logger.Warn("Failed to create VDC namespace", "vdc", vdc.ID, "namespace", vdc.Namespace, "error", err)
+					result.NamespaceErrors = append(result.NamespaceErrors, tenantNamespaceError(database.TenantActionCreate, vdc, err))
+				}
+			}
+			for i := range result.DeletedVDCs {
+				vdc := &result.DeletedVDCs[i]
+				if err := h.k8sService.DeleteNamespaceForVDC(ctx, vdc); err != nil {
+					h.logger.Warn("Failed to delete VDC namespace", "vdc", vdc.ID, "namespace", vdc.Namespace, "error", err)
+					result.NamespaceErrors = append(result.NamespaceErrors, tenantNamespaceError(database.TenantActionDelete, vdc, err))
+				}
+			}
+		}
+		// Roles and organizations of users may have changed
+		if h.roleCache != nil && len(result.Changes) > 0 {
+			h.roleCache.InvalidateAll()
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportTenants handles GET /api/admin/tenants/export, describing the current
+// organizations as a YAML tenant spec that apply accepts
+func (h *TenantHandlers) ExportTenants(c *gin.Context) {
+	spec, err := h.db.ExportTenants(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to export tenants",
+		))
+		return
+	}
+	data, err := database.MarshalTenantSpec(spec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to export tenants",
+		))
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml", data)
+}
+
+// tenantNamespaceError describes a VDC namespace that could not be created or deleted
+func tenantNamespaceError(action string, vdc *models.VDC, err error) database.TenantNamespaceError {
+	name := vdc.Name
+	if vdc.Organization != nil {
+		name = vdc.Organization.Name + "/" + vdc.Name
+	}
+	return database.TenantNamespaceError{Action: action, VDC: name, Namespace: vdc.Namespace, Error: err.Error()}
+}
+
+func (h *TenantHandlers) respondApplyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrInvalidTenantSpec):
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid tenant spec",
+			err.Error(),
+		))
+	case errors.Is(err, database.ErrTenantConflict), errors.Is(err, models.ErrNamespaceUnavailable):
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"Tenant spec cannot be applied",
+			err.Error(),
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to apply tenant spec",
+			err.Error(),
+		))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -12,15 +13,24 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
 // UserHandlers contains handlers for user-related CloudAPI endpoints
 type UserHandlers struct {
-	userRepo *repositories.UserRepository
-	orgRepo  *repositories.OrganizationRepository
-	roleRepo *repositories.RoleRepository
+	userRepo  *repositories.UserRepository
+	orgRepo   *repositories.OrganizationRepository
+	roleRepo  *repositories.RoleRepository
+	roleCache *auth.RoleCache
+
+	tasks      UserImportTaskStore
+	eventBus   *events.Bus
+	background *services.BackgroundWork
+	logger     *slog.Logger
 }
 
 // CreateUserRequest represents the request body for creating a user
@@ -54,11 +64,15 @@ type UpdateUserRequest struct {
 }
 
 // NewUserHandlers creates a new UserHandlers instance
-func NewUserHandlers(userRepo *repositories.UserRepository, orgRepo *repositories.OrganizationRepository, roleRepo *repositories.RoleRepository) *UserHandlers {
+// NewUserHandlers creates a new UserHandlers instance. Changes to a user are
+// invalidated in roleCache so authorization checks see them immediately.
+func NewUserHandlers(userRepo *repositories.UserRepository, orgRepo *repositories.OrganizationRepository, roleRepo *repositories.RoleRepository, roleCache *auth.RoleCache) *UserHandlers {
 	return &UserHandlers{
-		userRepo: userRepo,
-		orgRepo:  orgRepo,
-		roleRepo: roleRepo,
+		userRepo:  userRepo,
+		orgRepo:   orgRepo,
+		roleRepo:  roleRepo,
+		roleCache: roleCache,
+		logger:    slog.Default(),
 	}
 }
 
@@ -149,8 +163,8 @@ func (h *UserHandlers) GetUser(c *gin.Context) {
 // CreateUser handles POST /cloudapi/1.0.0/users
 func (h *UserHandlers) CreateUser(c *gin.Context) {
 	var req CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
@@ -285,8 +299,8 @@ func (h *UserHandlers) UpdateUser(c *gin.Context) {
 	}
 
 	var req UpdateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
@@ -395,21 +409,27 @@ func (h *UserHandlers) UpdateUser(c *gin.Context) {
 	}
 
 	// Update role assignments if provided
+	var roleErr error
 	if shouldUpdateRoles {
 		if len(roleIDs) > 0 {
-			if err := h.userRepo.AssignRoles(user.ID, roleIDs); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign roles to user"})
-				return
-			}
+			roleErr = h.userRepo.AssignRoles(user.ID, roleIDs)
 		} else {
 			// Clear all roles if empty array was provided
-			if err := h.userRepo.ClearRoles(user.ID); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear user roles"})
-				return
-			}
+			roleErr = h.userRepo.ClearRoles(user.ID)
 		}
 	}
 
+	// Authorization checks must see the updated user and roles
+	h.roleCache.Invalidate(user.ID)
+	if roleErr != nil {
+		if len(roleIDs) > 0 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign roles to user"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear user roles"})
+		}
+		return
+	}
+
 	// Get updated user with entity references
 	updatedUser, err := h.userRepo.GetWithEntityRefs(user.ID)
 	if err != nil {
@@ -464,6 +484,7 @@ func (h *UserHandlers) DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	h.roleCache.Invalidate(id)
 
 	c.JSON(http.StatusNoContent, nil)
 }
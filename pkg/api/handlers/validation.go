@@ -8,7 +8,10 @@
 // API compliance and security.
 package handlers
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+)
 
 // Input validation patterns for non-URN fields used across handlers.
 // URN validation is now centralized in models.ParseURN and models.GetURNType.
@@ -25,4 +28,34 @@ var (
 	// Allows alphanumeric characters, hyphens, underscores, and colons.
 	// Supports both legacy 4-part format (item-name) and 5-part format (catalog-id:item-name)
 	catalogItemURNRegex = regexp.MustCompile(`^[a-zA-Z0-9\-_:]+$`)
+
+	// hexColorRegex validates CSS hex colors in #RGB or #RRGGBB form.
+	hexColorRegex = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
 )
+
+// ReservedNamePrefixes lists name prefixes reserved for system namespaces and
+// objects, such as "kube" or "openshift"
+type ReservedNamePrefixes []string
+
+// NewReservedNamePrefixes normalizes configured prefixes to lowercase,
+// dropping empty ones
+func NewReservedNamePrefixes(prefixes []string) ReservedNamePrefixes {
+	var reserved ReservedNamePrefixes
+	for _, prefix := range prefixes {
+		if prefix = strings.ToLower(strings.TrimSpace(prefix)); prefix != "" {
+			reserved = append(reserved, prefix)
+		}
+	}
+	return reserved
+}
+
+// Match returns the reserved prefix name starts with, ignoring case
+func (r ReservedNamePrefixes) Match(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for _, prefix := range r {
+		if strings.HasPrefix(name, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	templatev1 "github.com/openshift/api/template/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// errTemplateInstanceRemovalTimeout is returned when the failed TemplateInstance
+// is still terminating after the deletion timeout
+var errTemplateInstanceRemovalTimeout = errors.New("timed out waiting for the TemplateInstance to be removed")
+
+// RetryResponse is returned with 202 Accepted by vApp instantiation retries
+type RetryResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Href     string `json:"href"`
+	TaskID   string `json:"taskId,omitempty"`
+	TaskHref string `json:"taskHref,omitempty"`
+}
+
+// RetryVAppInstantiation handles POST /cloudapi/1.0.0/vapps/{vapp_id}/actions/retry.
+// The failed TemplateInstance and the VirtualMachines it created are deleted and
+// the TemplateInstance is created again from the request recorded when the vApp
+// was instantiated. The vApp keeps its ID, name, description and policies. The
+// work runs in the background and is tracked by a task.
+func (h *VAppHandlers) RetryVAppInstantiation(c *gin.Context) {
+	vapp, ok := h.authorizeVApp(c)
+	if !ok {
+		return
+	}
+
+	if h.k8sService == nil || h.k8sService.GetClient() == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"Kubernetes client not initialized",
+		))
+		return
+	}
+
+	if vapp.Status != models.VAppStatusFailed {
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"Only failed vApps can be retried",
+			fmt.Sprintf("vApp %s is %s", vapp.Name, vapp.Status),
+		))
+		return
+	}
+
+	var req services.TemplateInstanceRequest
+	if vapp.InstantiationData == "" || json.Unmarshal([]byte(vapp.InstantiationData), &req) != nil {
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"vApp instantiation cannot be retried",
+			"the vApp does not record how it was instantiated; delete and recreate it",
+		))
+		return
+	}
+
+	vms, err := h.vmRepo.GetByVAppID(vapp.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VMs",
+		))
+		return
+	}
+
+	if err := h.vappRepo.UpdateStatus(c.Request.Context(), vapp.ID, models.VAppStatusInstantiating); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to update vApp status",
+		))
+		return
+	}
+
+	task := h.startVAppTask(c, vapp, models.TaskOperationVAppRetry, fmt.Sprintf("Retrying instantiation of vApp %s", vapp.Name))
+	response := RetryResponse{
+		ID:     vapp.ID,
+		Name:   vapp.Name,
+		Status: models.VAppStatusInstantiating,
+		Href:   fmt.Sprintf("/cloudapi/1.0.0/vapps/%s", vapp.ID),
+	}
+	if task != nil {
+		response.TaskID = task.ID
+		response.TaskHref = fmt.Sprintf("/cloudapi/1.0.0/tasks/%s", task.ID)
+		c.Header("Location", response.TaskHref)
+	}
+
+	// Removing the VirtualMachines can take minutes, so it outlives the request
+	runInBackground(c, h.background, func(ctx context.Context) {
+		h.runInstantiationRetry(ctx, vapp, vms, &req, task)
+	})
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// runInstantiationRetry replaces the vApp's TemplateInstance, recording the
+// outcome on task. The vApp returns to FAILED when the TemplateInstance cannot
+// be replaced; otherwise the vApp status controller tracks the new one.
+func (h *VAppHandlers) runInstantiationRetry(ctx context.Context, vapp *models.VApp, vms []models.VM, req *services.TemplateInstanceRequest, task *models.Task) {
+	// State is still recorded after ctx is cancelled
+	dbCtx := context.WithoutCancel(ctx)
+
+	fail := func(status, details string) {
+		if err := h.vappRepo.UpdateStatus(dbCtx, vapp.ID, models.VAppStatusFailed); err != nil {
+			h.logger.Warn("Failed to mark vApp as failed after retry", "vappID", vapp.ID, "error", err)
+		}
+		h.finishVAppTask(dbCtx, task, status, details)
+	}
+
+	err := h.deleteVAppResources(ctx, vapp, req.Namespace, vms, task)
+	if err == nil {
+		h.updateVAppTaskProgress(dbCtx, task, 60, "Waiting for the failed TemplateInstance to be removed")
+		err = h.waitForTemplateInstanceRemoval(ctx, req.Namespace, req.Name)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			h.logger.Warn("vApp instantiation retry interrupted by API server shutdown", "vappID", vapp.ID)
+			fail(models.TaskStatusAborted, "API server shut down before the failed resources were removed; retry the vApp again")
+			return
+		}
+		h.logger.Error("Failed to remove failed vApp resources", "vappID", vapp.ID, "namespace", req.Namespace, "error", err)
+		details := err.Error()
+		if errors.Is(err, ErrVMDeletionTimeout) || errors.Is(err, errTemplateInstanceRemovalTimeout) {
+			details = "The failed resources are still being removed; retry the vApp again to finish"
+		}
+		fail(models.TaskStatusError, details)
+		return
+	}
+
+	h.updateVAppTaskProgress(dbCtx, task, 80, "Creating TemplateInstance")
+	if _, err := h.k8sService.CreateTemplateInstance(dbCtx, req); err != nil {
+		h.logger.Error("Failed to recreate TemplateInstance", "vappID", vapp.ID, "namespace", req.Namespace, "error", err)
+		fail(models.TaskStatusError, fmt.Sprintf("Failed to create template instance: %v", err))
+		return
+	}
+
+	h.finishVAppTask(dbCtx, task, models.TaskStatusSuccess, "")
+}
+
+// waitForTemplateInstanceRemoval waits until the TemplateInstance is gone, then
+// removes its SSH key secret, which is otherwise garbage collected after it,
+// so that both can be created again under the same names
+func (h *VAppHandlers) waitForTemplateInstanceRemoval(ctx context.Context, namespace, name string) error {
+	k8sClient := h.k8sService.GetClient()
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	err := wait.PollUntilContextTimeout(ctx, vmDeletionPollInterval, h.deletionTimeout, true, func(ctx context.Context) (bool, error) {
+		err := k8sClient.Get(ctx, key, &templatev1.TemplateInstance{})
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if wait.Interrupted(err) {
+		return errTemplateInstanceRemovalTimeout
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get TemplateInstance %s: %w", key, err)
+	}
+
+	secret := &corev1.Secret{}
+	secret.Name = name + "-ssh-keys"
+	secret.Namespace = namespace
+	if err := k8sClient.Delete(ctx, secret); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete SSH key secret %s/%s: %w", namespace, secret.Name, err)
+	}
+	return nil
+}
+
+// recordInstantiation keeps the TemplateInstance request on the vApp so a
+// failed instantiation can be retried with the same parameters
+func recordInstantiation(vapp *models.VApp, req *services.TemplateInstanceRequest) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	vapp.InstantiationData = string(data)
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// maxVMStartDelay is the longest a startup section may wait after starting a VM, in seconds
+const maxVMStartDelay = 3600
+
+// StartupSection is the start order of the VMs in a vApp, mirroring the
+// StartupSection of VMware Cloud Director
+type StartupSection struct {
+	Items []StartupSectionItem `json:"items"`
+}
+
+// StartupSectionItem is the start order and delay of one VM. VMs with a lower
+// order start first and VMs with the same order start together. StartDelay is
+// how many seconds to wait after starting the VM before starting the next order.
+type StartupSectionItem struct {
+	VMID       string `json:"vmId"`
+	Name       string `json:"name,omitempty"`
+	Order      int    `json:"order"`
+	StartDelay int    `json:"startDelay"`
+}
+
+// GetStartupSection handles GET /cloudapi/1.0.0/vapps/{vapp_id}/startupSection
+func (h *VAppHandlers) GetStartupSection(c *gin.Context) {
+	vapp, ok := h.authorizeVApp(c)
+	if !ok {
+		return
+	}
+
+	vms, err := h.vmRepo.GetByVAppID(vapp.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VMs",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, toStartupSection(vms))
+}
+
+// UpdateStartupSection handles PUT /cloudapi/1.0.0/vapps/{vapp_id}/startupSection.
+// Only the listed VMs are changed; VMs left out keep their start order and delay.
+func (h *VAppHandlers) UpdateStartupSection(c *gin.Context) {
+	vapp, ok := h.authorizeVApp(c)
+	if !ok {
+		return
+	}
+
+	var req StartupSection
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	startup := make([]models.VMStartup, 0, len(req.Items))
+	seen := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		if err := validateStartupSectionItem(item); err != nil {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid startup section",
+				err.Error(),
+			))
+			return
+		}
+		if seen[item.VMID] {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid startup section",
+				fmt.Sprintf("VM %s is listed more than once", item.VMID),
+			))
+			return
+		}
+		seen[item.VMID] = true
+		startup = append(startup, models.VMStartup{VMID: item.VMID, Order: item.Order, Delay: item.StartDelay})
+	}
+
+	if err := h.vmRepo.UpdateStartupSection(c.Request.Context(), vapp.ID, startup); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid startup section",
+				"Every VM must belong to the vApp",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to update startup section",
+		))
+		return
+	}
+
+	h.GetStartupSection(c)
+}
+
+// PowerOnVApp handles POST /cloudapi/1.0.0/vapps/{vapp_id}/actions/powerOn. The
+// vApp's VMs are started in the order of its startup section, waiting for each
+// order's start delay before starting the next. The sequence runs in the
+// background and is tracked by a task.
+func (h *VAppHandlers) PowerOnVApp(c *gin.Context) {
+	vapp, ok := h.authorizeVApp(c)
+	if !ok {
+		return
+	}
+
+	var k8sClient client.Client
+	if h.k8sService != nil {
+		k8sClient = h.k8sService.GetClient()
+	}
+	if k8sClient == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"Kubernetes client not initialized",
+		))
+		return
+	}
+
+	switch vapp.Status {
+	case models.VAppStatusPoweringOn:
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"vApp is already powering on",
+		))
+		return
+	case models.VAppStatusInstantiating, models.VAppStatusDeleting, models.VAppStatusDeleted:
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"vApp is in a conflicting state",
+		))
+		return
+	}
+
+	vms, err := h.vmRepo.GetByVAppID(vapp.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VMs",
+		))
+		return
+	}
+	groups := startupGroups(vms)
+	if len(groups) == 0 {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"vApp has no VMs to power on",
+		))
+		return
+	}
+
+	if err := h.vappRepo.UpdateStatus(c.Request.Context(), vapp.ID, models.VAppStatusPoweringOn); err != nil {
+		h.logger.Warn("Failed to mark vApp as powering on", "vappID", vapp.ID, "error", err)
+	}
+
+	task := h.startVAppTask(c, vapp, models.TaskOperationVAppPowerOn, fmt.Sprintf("Powering on vApp %s", vapp.Name))
+	response := PowerOperationResponse{
+		ID:         vapp.ID,
+		Name:       vapp.Name,
+		Status:     models.VAppStatusPoweringOn,
+		PowerState: models.VAppStatusPoweringOn,
+		Href:       fmt.Sprintf("/cloudapi/1.0.0/vapps/%s", vapp.ID),
+	}
+	if task != nil {
+		response.TaskID = task.ID
+		response.TaskHref = fmt.Sprintf("/cloudapi/1.0.0/tasks/%s", task.ID)
+	}
+
+	// The sequence outlives the request
+	runInBackground(c, h.background, func(ctx context.Context) {
+		h.runStartupSequence(ctx, k8sClient, vapp, task, groups)
+	})
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// runStartupSequence starts each group of VMs in turn. A VM that fails to start
+// stops the sequence, since later orders usually depend on earlier ones. When
+// ctx is cancelled because the API server is shutting down, the task is
+// aborted so that it is not left running without anyone working on it.
+func (h *VAppHandlers) runStartupSequence(ctx context.Context, k8sClient client.Client, vapp *models.VApp, task *models.Task, groups [][]models.VM) {
+	// State is still recorded after ctx is cancelled
+	dbCtx := context.WithoutCancel(ctx)
+
+	// The VM status controller tracks the VMs themselves; the vApp returns to
+	// the status it had before the power on
+	defer func() {
+		if err := h.vappRepo.UpdateStatus(dbCtx, vapp.ID, vapp.Status); err != nil {
+			h.logger.Warn("Failed to reset vApp status after power on", "vappID", vapp.ID, "error", err)
+		}
+	}()
+
+	for i, group := range groups {
+		delay := 0
+		for _, vm := range group {
+			if ctx.Err() != nil {
+				h.abortStartupSequence(dbCtx, vapp, task, group[0].StartOrder)
+				return
+			}
+			if err := h.powerOnVirtualMachine(ctx, k8sClient, vm); err != nil {
+				if ctx.Err() != nil {
+					h.abortStartupSequence(dbCtx, vapp, task, group[0].StartOrder)
+					return
+				}
+				h.logger.Error("Failed to power on VM in vApp startup sequence",
+					"vappID", vapp.ID, "vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+				h.finishVAppTask(dbCtx, task, models.TaskStatusError, fmt.Sprintf("Failed to power on VM %s: %v", vm.Name, err))
+				return
+			}
+			delay = max(delay, vm.StartDelay)
+		}
+		h.logger.Info("vApp startup order started", "vappID", vapp.ID, "order", group[0].StartOrder, "vms", len(group))
+
+		if i == len(groups)-1 {
+			break
+		}
+		h.updateVAppTaskProgress(dbCtx, task, (i+1)*100/len(groups), fmt.Sprintf("Started order %d, waiting %ds", group[0].StartOrder, delay))
+		select {
+		case <-time.After(time.Duration(delay) * time.Second):
+		case <-ctx.Done():
+			h.abortStartupSequence(dbCtx, vapp, task, groups[i+1][0].StartOrder)
+			return
+		}
+	}
+
+	h.finishVAppTask(dbCtx, task, models.TaskStatusSuccess, "")
+}
+
+// abortStartupSequence records that the API server stopped a startup sequence
+// before the given start order. VMs that were started keep running; powering
+// on the vApp again starts the rest.
+func (h *VAppHandlers) abortStartupSequence(ctx context.Context, vapp *models.VApp, task *models.Task, order int) {
+	h.logger.Warn("vApp startup sequence interrupted by API server shutdown", "vappID", vapp.ID, "order", order)
+	h.finishVAppTask(ctx, task, models.TaskStatusAborted,
+		fmt.Sprintf("API server shut down before start order %d; power on the vApp again to start the remaining VMs", order))
+}
+
+// updateVAppTaskProgress records the progress of a running task
+func (h *VAppHandlers) updateVAppTaskProgress(ctx context.Context, task *models.Task, progress int, details string) {
+	if task == nil {
+		return
+	}
+	if err := h.tasks.UpdateStatus(ctx, task.ID, models.TaskStatusRunning, progress, details); err != nil {
+		h.logger.Warn("Failed to update vApp task progress", "taskID", task.ID, "error", err)
+	}
+}
+
+// authorizeVApp validates the vApp URN and the user's access to it, writing an
+// error response and returning false if either fails
+func (h *VAppHandlers) authorizeVApp(c *gin.Context) (*models.VApp, bool) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return nil, false
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return nil, false
+	}
+
+	vappID := c.Param("vapp_id")
+	if urnType, err := models.GetURNType(vappID); err != nil || urnType != "vapp" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid vApp URN format",
+		))
+		return nil, false
+	}
+
+	vapp, err := h.access.CanAccessVApp(c.Request.Context(), userClaims.UserID, vappID)
+	if err != nil {
+		respondAccessError(c, err, "vApp")
+		return nil, false
+	}
+	return vapp, true
+}
+
+func validateStartupSectionItem(item StartupSectionItem) error {
+	if urnType, err := models.GetURNType(item.VMID); err != nil || urnType != "vm" {
+		return fmt.Errorf("vmId %q is not a VM URN", item.VMID)
+	}
+	if item.Order < 0 {
+		return fmt.Errorf("order of VM %s must not be negative", item.VMID)
+	}
+	if item.StartDelay < 0 || item.StartDelay > maxVMStartDelay {
+		return fmt.Errorf("startDelay of VM %s must be between 0 and %d seconds", item.VMID, maxVMStartDelay)
+	}
+	return nil
+}
+
+// toStartupSection lists VMs in start order
+func toStartupSection(vms []models.VM) StartupSection {
+	sortByStartOrder(vms)
+	section := StartupSection{Items: make([]StartupSectionItem, 0, len(vms))}
+	for _, vm := range vms {
+		section.Items = append(section.Items, StartupSectionItem{
+			VMID:       vm.ID,
+			Name:       vm.Name,
+			Order:      vm.StartOrder,
+			StartDelay: vm.StartDelay,
+		})
+	}
+	return section
+}
+
+// startupGroups groups the VMs that need starting by start order, lowest first.
+// VMs that are already running, have no VirtualMachine or are Halted are skipped.
+func startupGroups(vms []models.VM) [][]models.VM {
+	sortByStartOrder(vms)
+	var groups [][]models.VM
+	for _, vm := range vms {
+		if vm.Status == "POWERED_ON" || vm.Status == "POWERING_ON" || vm.VMName == "" || vm.Namespace == "" || vm.RunStrategy == models.VMRunStrategyHalted {
+			continue
+		}
+		if n := len(groups); n > 0 && groups[n-1][0].StartOrder == vm.StartOrder {
+			groups[n-1] = append(groups[n-1], vm)
+			continue
+		}
+		groups = append(groups, []models.VM{vm})
+	}
+	return groups
+}
+
+func sortByStartOrder(vms []models.VM) {
+	sort.SliceStable(vms, func(i, j int) bool {
+		if vms[i].StartOrder != vms[j].StartOrder {
+			return vms[i].StartOrder < vms[j].StartOrder
+		}
+		return vms[i].Name < vms[j].Name
+	})
+}
+
+// powerOnVirtualMachine records that a VM should run and sets the run strategy
+// of its VirtualMachine to Always right away, rather than when the vm-controller
+// next reconciles the desired power state, so start delays are kept. When the
+// patch fails the previous desired power state is restored.
+func (h *VAppHandlers) powerOnVirtualMachine(ctx context.Context, k8sClient client.Client, vm models.VM) error {
+	if err := h.vmRepo.SetDesiredPowerState(ctx, vm.ID, models.VMPowerStateOn); err != nil {
+		return fmt.Errorf("failed to set desired power state: %w", err)
+	}
+	patchBytes, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"runStrategy": kubevirtv1.RunStrategyAlways,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	vmResource := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: vm.VMName, Namespace: vm.Namespace},
+	}
+	if err := k8sClient.Patch(ctx, vmResource, client.RawPatch(types.MergePatchType, patchBytes)); err != nil {
+		if restoreErr := h.vmRepo.SetDesiredPowerState(ctx, vm.ID, vm.DesiredPowerState); restoreErr != nil {
+			h.logger.Error("Failed to restore desired power state", "vmID", vm.ID, "error", restoreErr)
+		}
+		return err
+	}
+	return nil
+}
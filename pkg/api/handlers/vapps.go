@@ -5,9 +5,15 @@
 // VDCs. The implementation follows VMware Cloud Director API specifications.
 //
 // Key Features:
-//   - List vApps with pagination and filtering at /cloudapi/1.0.0/vdcs/{vdc_id}/vapps
+//   - List vApps with pagination, status and name filtering at /cloudapi/1.0.0/vdcs/{vdc_id}/vapps
 //   - Retrieve detailed vApp information at /cloudapi/1.0.0/vapps/{vapp_id}
-//   - Delete vApps with dependency validation at /cloudapi/1.0.0/vapps/{vapp_id}
+//   - List the VMs in a vApp with pagination and status filtering at /cloudapi/1.0.0/vapps/{vapp_id}/vms
+//   - Cursor pagination on both lists, which stays stable while vApps and VMs are added or removed
+//   - Delete vApps at /cloudapi/1.0.0/vapps/{vapp_id}, removing the TemplateInstance and
+//     VirtualMachines from the cluster before the database records, tracked by a task
+//   - Start order and delays per VM at /cloudapi/1.0.0/vapps/{vapp_id}/startupSection, honored
+//     when powering on the vApp at /cloudapi/1.0.0/vapps/{vapp_id}/actions/powerOn
+//   - Backup policies overriding the VDC's at /cloudapi/1.0.0/vapps/{vapp_id}/backupPolicy
 //   - Organization-based access control through VDC membership
 //   - VM reference management within vApps
 //   - Force deletion support for powered-on VMs
@@ -22,44 +28,95 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/api/apiversion"
+	apitypes "github.com/mhrivnak/ssvirt/pkg/api/types"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/pagination"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
 	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
+// vappLabel is the label the VM status controller sets on VirtualMachines created
+// from a vApp's TemplateInstance; its value is the TemplateInstance name
+const vappLabel = "vapp.ssvirt"
+
+// VAppTaskStore creates and completes tasks that track vApp operations
+type VAppTaskStore interface {
+	CreateVAppTask(ctx context.Context, vappID, name, operation, userID string) (*models.Task, error)
+	UpdateStatus(ctx context.Context, id, status string, progress int, details string) error
+}
+
 // VAppHandlers handles vApp API endpoints
 type VAppHandlers struct {
 	vappRepo   *repositories.VAppRepository
 	vdcRepo    *repositories.VDCRepository
 	vmRepo     *repositories.VMRepository
+	access     *auth.AccessControl
 	k8sService services.KubernetesService
+	tasks      VAppTaskStore
+	eventBus   *events.Bus
+	logger     *slog.Logger
+	background *services.BackgroundWork
+	backups    services.BackupService
+	roleCache  *auth.RoleCache
+
+	deletionTimeout time.Duration
 }
 
 // NewVAppHandlers creates a new VAppHandlers instance
-func NewVAppHandlers(vappRepo *repositories.VAppRepository, vdcRepo *repositories.VDCRepository, vmRepo *repositories.VMRepository, k8sService services.KubernetesService) *VAppHandlers {
+func NewVAppHandlers(vappRepo *repositories.VAppRepository, vdcRepo *repositories.VDCRepository, vmRepo *repositories.VMRepository, access *auth.AccessControl, k8sService services.KubernetesService) *VAppHandlers {
 	return &VAppHandlers{
 		vappRepo:   vappRepo,
 		vdcRepo:    vdcRepo,
 		vmRepo:     vmRepo,
+		access:     access,
 		k8sService: k8sService,
+		logger:     slog.Default(),
+
+		deletionTimeout: defaultVMDeletionTimeout,
 	}
 }
 
+// SetTaskStore enables task tracking for vApp deletion. bus may be nil, in which
+// case task updates are not published.
+func (h *VAppHandlers) SetTaskStore(tasks VAppTaskStore, bus *events.Bus) {
+	h.tasks = tasks
+	h.eventBus = bus
+}
+
+// SetBackgroundWork runs operations that outlive their request, such as vApp
+// startup sequences, under work the API server waits for on shutdown
+func (h *VAppHandlers) SetBackgroundWork(work *services.BackgroundWork) {
+	h.background = work
+}
+
+// SetRoleCache lets System Administrators override failures of the cluster
+// cleanup when deleting a vApp with force=true
+func (h *VAppHandlers) SetRoleCache(roleCache *auth.RoleCache) {
+	h.roleCache = roleCache
+}
+
 // VAppDetailedResponse represents the detailed response for vApp with VMs
 type VAppDetailedResponse struct {
 	ID          string        `json:"id"`
 	Name        string        `json:"name"`
 	Description string        `json:"description"`
 	Status      string        `json:"status"`
+	HealthState string        `json:"healthState,omitempty" since:"39.0"`
 	VDCID       string        `json:"vdcId"`
 	TemplateID  string        `json:"templateId,omitempty"`
 	CreatedAt   string        `json:"createdAt"`
@@ -67,14 +124,17 @@ type VAppDetailedResponse struct {
 	NumberOfVMs int           `json:"numberOfVMs"`
 	VMs         []VMReference `json:"vms"`
 	Href        string        `json:"href"`
+	// Conditions report the readiness of the vApp
+	Conditions []models.Condition `json:"conditions"`
 }
 
 // VMReference represents a VM reference in vApp response
 type VMReference struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Status string `json:"status"`
-	Href   string `json:"href"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	HealthState string `json:"healthState,omitempty" since:"39.0"`
+	Href        string `json:"href"`
 }
 
 // ListVApps handles GET /cloudapi/1.0.0/vdcs/{vdc_id}/vapps
@@ -113,30 +173,34 @@ func (h *VAppHandlers) ListVApps(c *gin.Context) {
 	}
 
 	// Validate VDC access
-	err := h.validateVDCAccess(c.Request.Context(), userClaims.UserID, vdcID)
+	_, err := h.access.CanAccessVDC(c.Request.Context(), userClaims.UserID, vdcID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, NewAPIError(
-				http.StatusNotFound,
-				"Not Found",
-				"VDC not found",
-			))
-		} else {
-			c.JSON(http.StatusForbidden, NewAPIError(
-				http.StatusForbidden,
-				"Forbidden",
-				"VDC access denied",
-			))
-		}
+		respondAccessError(c, err, "VDC")
 		return
 	}
 
 	// Parse pagination and sorting parameters
 	page, pageSize, offset, sortOrder := h.parseVAppPaginationParams(c)
-	filter := c.Query("filter")
+	filter := parseVAppListFilter(c)
+	cursor, useCursor, ok := parseCursorParam(c)
+	if !ok {
+		return
+	}
 
 	// Get vApps in VDC
-	vapps, err := h.vappRepo.ListByVDCWithPagination(c.Request.Context(), vdcID, pageSize, offset, filter, sortOrder)
+	var vapps []repositories.VAppSummary
+	var nextCursor string
+	if useCursor {
+		page = 0
+		vapps, err = h.vappRepo.ListByVDCAfterCursor(c.Request.Context(), vdcID, cursor, pageSize, filter)
+		if err == nil && len(vapps) > pageSize {
+			vapps = vapps[:pageSize]
+			last := vapps[len(vapps)-1]
+			nextCursor = pagination.EncodeCursor(last.Name, last.ID)
+		}
+	} else {
+		vapps, err = h.vappRepo.ListByVDCWithPagination(c.Request.Context(), vdcID, pageSize, offset, filter, sortOrder)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, NewAPIError(
 			http.StatusInternalServerError,
@@ -160,22 +224,23 @@ func (h *VAppHandlers) ListVApps(c *gin.Context) {
 	// Convert to response format
 	vappResponses := make([]VAppResponse, len(vapps))
 	for i, vapp := range vapps {
-		vappResponses[i] = h.toVAppResponse(vapp)
+		vappResponses[i] = h.toVAppResponse(vapp.VApp, vapp.NumberOfVMs)
 	}
 
 	// Calculate pagination info
 	pageCount := int(math.Ceil(float64(totalCount) / float64(pageSize)))
 
 	// Create paginated response
-	response := types.Page[VAppResponse]{
+	response := apitypes.Page[VAppResponse]{
 		ResultTotal: totalCount,
 		PageCount:   pageCount,
 		Page:        page,
 		PageSize:    pageSize,
+		NextCursor:  nextCursor,
 		Values:      vappResponses,
 	}
 
-	c.JSON(http.StatusOK, response)
+	apiversion.JSON(c, http.StatusOK, response)
 }
 
 // GetVApp handles GET /cloudapi/1.0.0/vapps/{vapp_id}
@@ -214,27 +279,14 @@ func (h *VAppHandlers) GetVApp(c *gin.Context) {
 	}
 
 	// Validate vApp access
-	_, err := h.validateVAppAccess(c.Request.Context(), userClaims.UserID, vappID)
+	vapp, err := h.access.CanAccessVApp(c.Request.Context(), userClaims.UserID, vappID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, NewAPIError(
-				http.StatusNotFound,
-				"Not Found",
-				"vApp not found",
-			))
-		} else {
-			c.JSON(http.StatusForbidden, NewAPIError(
-				http.StatusForbidden,
-				"Forbidden",
-				"vApp access denied",
-			))
-		}
+		respondAccessError(c, err, "vApp")
 		return
 	}
 
-	// Get vApp with VMs
-	vappWithVMs, err := h.vappRepo.GetWithVMsString(c.Request.Context(), vappID)
-	if err != nil {
+	// Load the VMs of the vApp the access check returned
+	if err := h.vappRepo.LoadVMs(c.Request.Context(), vapp); err != nil {
 		c.JSON(http.StatusInternalServerError, NewAPIError(
 			http.StatusInternalServerError,
 			"Internal Server Error",
@@ -244,12 +296,12 @@ func (h *VAppHandlers) GetVApp(c *gin.Context) {
 	}
 
 	// Convert to detailed response format
-	response := h.toVAppDetailedResponse(*vappWithVMs)
-	c.JSON(http.StatusOK, response)
+	response := h.toVAppDetailedResponse(*vapp)
+	apiversion.JSON(c, http.StatusOK, response)
 }
 
-// DeleteVApp handles DELETE /cloudapi/1.0.0/vapps/{vapp_id}
-func (h *VAppHandlers) DeleteVApp(c *gin.Context) {
+// ListVAppVMs handles GET /cloudapi/1.0.0/vapps/{vapp_id}/vms
+func (h *VAppHandlers) ListVAppVMs(c *gin.Context) {
 	// Extract user ID from JWT claims
 	claims, exists := c.Get(auth.ClaimsContextKey)
 	if !exists {
@@ -283,130 +335,357 @@ func (h *VAppHandlers) DeleteVApp(c *gin.Context) {
 		return
 	}
 
-	// Parse force parameter
-	force := c.Query("force") == "true"
-
-	// Validate vApp access and get vApp details
-	vapp, err := h.validateVAppAccess(c.Request.Context(), userClaims.UserID, vappID)
+	// Validate vApp access
+	vapp, err := h.access.CanAccessVApp(c.Request.Context(), userClaims.UserID, vappID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, NewAPIError(
-				http.StatusNotFound,
-				"Not Found",
-				"vApp not found",
-			))
-		} else {
-			c.JSON(http.StatusForbidden, NewAPIError(
-				http.StatusForbidden,
-				"Forbidden",
-				"vApp access denied",
-			))
+		respondAccessError(c, err, "vApp")
+		return
+	}
+
+	// Parse pagination, sorting and status filter parameters
+	page, pageSize, offset, sortOrder := h.parseVAppPaginationParams(c)
+	if c.Query("sortAsc") == "" && c.Query("sortDesc") == "" {
+		sortOrder = "name ASC, id ASC"
+	}
+	statuses := parseStatusParam(c.Query("status"))
+	cursor, useCursor, ok := parseCursorParam(c)
+	if !ok {
+		return
+	}
+
+	var vms []models.VM
+	var nextCursor string
+	if useCursor {
+		page = 0
+		vms, err = h.vmRepo.ListByVAppAfterCursor(c.Request.Context(), vappID, cursor, pageSize, statuses)
+		if err == nil && len(vms) > pageSize {
+			vms = vms[:pageSize]
+			last := vms[len(vms)-1]
+			nextCursor = pagination.EncodeCursor(last.Name, last.ID)
 		}
+	} else {
+		vms, err = h.vmRepo.ListByVAppWithPagination(c.Request.Context(), vappID, pageSize, offset, statuses, sortOrder)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VMs",
+		))
 		return
 	}
 
-	// Get VDC information to find the namespace for TemplateInstance cleanup
-	vdc, err := h.vdcRepo.GetByIDString(c.Request.Context(), vapp.VDCID)
+	totalCount, err := h.vmRepo.CountByVApp(c.Request.Context(), vappID, statuses)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, NewAPIError(
 			http.StatusInternalServerError,
 			"Internal Server Error",
-			"Failed to get VDC information",
+			"Failed to count VMs",
 		))
 		return
 	}
 
-	// Delete associated TemplateInstance if k8s service is available
-	if h.k8sService != nil && vdc.Namespace != "" {
-		// The TemplateInstance name should match the vApp name
-		err = h.k8sService.DeleteTemplateInstance(c.Request.Context(), vdc.Namespace, vapp.Name)
-		if err != nil {
-			// Log the error but don't fail the API call - continue with database cleanup
-			// This follows the pattern used in VDC deletion
-			// TODO: Add proper logging
-			_ = err
-		}
+	// Every VM belongs to the vApp loaded by the access check, so it is
+	// attached here rather than preloaded again
+	vmResponses := make([]VMResponse, len(vms))
+	for i, vm := range vms {
+		vm.VApp = vapp
+		vmResponses[i] = toVMResponse(vm)
 	}
 
-	// Delete vApp with validation
-	err = h.vappRepo.DeleteWithValidation(c.Request.Context(), vappID, force)
-	if err != nil {
-		if errors.Is(err, repositories.ErrVAppHasRunningVMs) {
-			c.JSON(http.StatusBadRequest, NewAPIError(
-				http.StatusBadRequest,
-				"Bad Request",
-				"vApp contains running VMs",
-			))
-		} else {
+	response := apitypes.Page[VMResponse]{
+		ResultTotal: totalCount,
+		PageCount:   int(math.Ceil(float64(totalCount) / float64(pageSize))),
+		Page:        page,
+		PageSize:    pageSize,
+		NextCursor:  nextCursor,
+		Values:      vmResponses,
+	}
+
+	apiversion.JSON(c, http.StatusOK, response)
+}
+
+// DeleteVApp handles DELETE /cloudapi/1.0.0/vapps/{vapp_id}. The request is
+// validated and the vApp marked DELETING, then its resources are removed in the
+// background and the response is 202 Accepted with the tracking task.
+func (h *VAppHandlers) DeleteVApp(c *gin.Context) {
+	// Extract user ID from JWT claims
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vappID := c.Param("vapp_id")
+
+	// Validate vApp URN format using centralized validation
+	if urnType, err := models.GetURNType(vappID); err != nil || urnType != "vapp" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid vApp URN format",
+		))
+		return
+	}
+
+	// Parse force parameter. For tenants it allows deleting running VMs; for
+	// System Administrators it also removes the records when the cluster
+	// resources cannot be cleaned up, e.g. because the namespace is broken.
+	force := c.Query("force") == "true"
+	var admin *models.User
+	if force {
+		var err error
+		if admin, err = forceOverrideAdmin(c, h.roleCache); err != nil {
 			c.JSON(http.StatusInternalServerError, NewAPIError(
 				http.StatusInternalServerError,
 				"Internal Server Error",
-				"Failed to delete vApp",
+				"Failed to verify user permissions",
 			))
+			return
 		}
+	}
+
+	// Validate vApp access and get vApp details
+	vapp, err := h.access.CanAccessVApp(c.Request.Context(), userClaims.UserID, vappID)
+	if err != nil {
+		respondAccessError(c, err, "vApp")
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	// Refuse before touching the cluster if running VMs would be destroyed
+	if err := h.vappRepo.LoadVMs(c.Request.Context(), vapp); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve vApp details",
+		))
+		return
+	}
+	if !force && hasRunningVMs(vapp.VMs) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"vApp contains running VMs",
+		))
+		return
+	}
+
+	// Get VDC information to find the namespace for TemplateInstance cleanup
+	namespace := ""
+	vdc, err := h.vdcRepo.GetByIDString(c.Request.Context(), vapp.VDCID)
+	switch {
+	case err == nil:
+		namespace = vdc.Namespace
+	case admin == nil:
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to get VDC information",
+		))
+		return
+	default:
+		h.logger.Warn("Forced vApp deletion without VDC information", "vappID", vappID, "error", err)
+	}
+
+	if admin != nil {
+		auditForceOverride(c, admin, "vappDelete", vapp.ID, vapp.Name, "status", vapp.Status, "namespace", namespace)
+	}
+
+	task := h.startVAppTask(c, vapp, models.TaskOperationVAppDelete, fmt.Sprintf("Deleting vApp %s", vapp.Name))
+
+	if err := h.vappRepo.UpdateStatus(c.Request.Context(), vappID, models.VAppStatusDeleting); err != nil {
+		h.logger.Warn("Failed to mark vApp as deleting", "vappID", vappID, "error", err)
+	}
+
+	// Removing the VirtualMachines can take minutes, so it outlives the request
+	runInBackground(c, h.background, func(ctx context.Context) {
+		h.runVAppDeletion(ctx, vapp, namespace, task, admin)
+	})
+
+	acceptDeletion(c, DeletionResponse{
+		ID:     vapp.ID,
+		Name:   vapp.Name,
+		Status: models.VAppStatusDeleting,
+		Href:   fmt.Sprintf("/cloudapi/1.0.0/vapps/%s", vapp.ID),
+	}, task)
 }
 
-// validateVDCAccess validates that a user has access to a VDC
-func (h *VAppHandlers) validateVDCAccess(ctx context.Context, userID, vdcID string) error {
-	_, err := h.vdcRepo.GetAccessibleVDC(ctx, userID, vdcID)
-	return err
+// runVAppDeletion removes the vApp's cluster resources and then its records,
+// recording the outcome on task. The cluster resources go first so a failure
+// leaves the records in place, with status DELETING, for a retry instead of
+// orphaning running VirtualMachines. When a System Administrator forced the
+// deletion, admin is set and the records are removed despite the failure.
+func (h *VAppHandlers) runVAppDeletion(ctx context.Context, vapp *models.VApp, namespace string, task *models.Task, admin *models.User) {
+	// State is still recorded after ctx is cancelled
+	dbCtx := context.WithoutCancel(ctx)
+
+	details := ""
+	if err := h.deleteVAppResources(ctx, vapp, namespace, vapp.VMs, task); err != nil {
+		if ctx.Err() != nil {
+			h.logger.Warn("vApp deletion interrupted by API server shutdown", "vappID", vapp.ID)
+			h.finishVAppTask(dbCtx, task, models.TaskStatusAborted,
+				"API server shut down before the VirtualMachines were removed; delete the vApp again to finish")
+			return
+		}
+		h.logger.Error("Failed to delete vApp resources", "vappID", vapp.ID, "namespace", namespace, "error", err)
+		if admin == nil {
+			details := err.Error()
+			if errors.Is(err, ErrVMDeletionTimeout) {
+				details = "VirtualMachines are still being removed; delete the vApp again to finish"
+			}
+			h.finishVAppTask(dbCtx, task, models.TaskStatusError, details)
+			return
+		}
+		slog.Info("audit: force override ignored cluster cleanup failure",
+			"action", "vappDelete", "admin_id", admin.ID, "admin", admin.Username,
+			"entity_id", vapp.ID, "entity", vapp.Name, "namespace", namespace, "error", err.Error())
+		details = "Cluster resources may remain: " + err.Error()
+	}
+
+	h.updateVAppTaskProgress(dbCtx, task, 90, "Deleting vApp records")
+	if err := h.vappRepo.DeleteWithValidation(dbCtx, vapp.ID, true); err != nil {
+		h.logger.Error("Failed to delete vApp records", "vappID", vapp.ID, "error", err)
+		h.finishVAppTask(dbCtx, task, models.TaskStatusError, "Failed to delete vApp records")
+		return
+	}
+
+	h.finishVAppTask(dbCtx, task, models.TaskStatusSuccess, details)
 }
 
-// validateVAppAccess validates that a user has access to a vApp through VDC organization membership
-func (h *VAppHandlers) validateVAppAccess(ctx context.Context, userID, vappID string) (*models.VApp, error) {
-	vapp, err := h.vappRepo.GetWithVDC(ctx, vappID)
+// deleteVAppResources deletes the vApp's TemplateInstance and every VirtualMachine
+// belonging to it, waiting for the VirtualMachines to be removed. VirtualMachines
+// are found both from the VM records and from the vapp.ssvirt label, so VMs the
+// controller has not recorded yet are not left behind. Progress is recorded on
+// task, which may be nil.
+func (h *VAppHandlers) deleteVAppResources(ctx context.Context, vapp *models.VApp, namespace string, vms []models.VM, task *models.Task) error {
+	if h.k8sService == nil || namespace == "" {
+		return nil
+	}
+
+	templateInstanceName := vapp.GetTemplateInstanceName()
+	if err := h.k8sService.DeleteTemplateInstance(ctx, namespace, templateInstanceName); err != nil {
+		return err
+	}
+
+	k8sClient := h.k8sService.GetClient()
+	if k8sClient == nil {
+		return nil
+	}
+
+	seen := make(map[types.NamespacedName]bool)
+	var keys []types.NamespacedName
+	addKey := func(key types.NamespacedName) {
+		if key.Name != "" && key.Namespace != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	for _, vm := range vms {
+		addKey(types.NamespacedName{Name: vm.VMName, Namespace: vm.Namespace})
+	}
+
+	labelled := &kubevirtv1.VirtualMachineList{}
+	err := k8sClient.List(ctx, labelled, client.InNamespace(namespace), client.MatchingLabels{vappLabel: templateInstanceName})
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to list VirtualMachines for vApp: %w", err)
+	}
+	for _, vm := range labelled.Items {
+		addKey(types.NamespacedName{Name: vm.Name, Namespace: vm.Namespace})
+	}
+
+	h.updateVAppTaskProgress(context.WithoutCancel(ctx), task, 20, fmt.Sprintf("Waiting for %d VirtualMachines to be removed", len(keys)))
+	return deleteVirtualMachines(ctx, k8sClient, keys, h.deletionTimeout)
+}
+
+// startVAppTask records a running task for a vApp operation. It returns nil when
+// task tracking is disabled or the task could not be created; the operation
+// proceeds either way.
+func (h *VAppHandlers) startVAppTask(c *gin.Context, vapp *models.VApp, name, operation string) *models.Task {
+	if h.tasks == nil {
+		return nil
+	}
+
+	var userID string
+	if claims, exists := c.Get(auth.ClaimsContextKey); exists {
+		if userClaims, ok := claims.(*auth.Claims); ok {
+			userID = userClaims.UserID
+		}
 	}
 
-	// Check if user has access to the VDC containing this vApp
-	err = h.validateVDCAccess(ctx, userID, vapp.VDCID)
+	task, err := h.tasks.CreateVAppTask(c.Request.Context(), vapp.ID, name, operation, userID)
 	if err != nil {
-		return nil, fmt.Errorf("VDC access denied: %w", err)
+		h.logger.Warn("Failed to create task for vApp operation", "vappID", vapp.ID, "operation", name, "error", err)
+		return nil
 	}
+	publishTaskUpdate(h.eventBus, task, task.Status)
+	return task
+}
 
-	return vapp, nil
+// finishVAppTask moves a task to its final status
+func (h *VAppHandlers) finishVAppTask(ctx context.Context, task *models.Task, status, details string) {
+	if task == nil {
+		return
+	}
+	if err := h.tasks.UpdateStatus(ctx, task.ID, status, 100, details); err != nil {
+		h.logger.Warn("Failed to update vApp task", "taskID", task.ID, "status", status, "error", err)
+		return
+	}
+	publishTaskUpdate(h.eventBus, task, status)
 }
 
-// toVAppResponse converts a VApp model to VCD-compliant response format
-func (h *VAppHandlers) toVAppResponse(vapp models.VApp) VAppResponse {
-	templateID := ""
-	if vapp.TemplateID != nil {
-		templateID = *vapp.TemplateID
+// hasRunningVMs reports whether any of the VMs is powered on
+func hasRunningVMs(vms []models.VM) bool {
+	for _, vm := range vms {
+		if vm.Status == "POWERED_ON" {
+			return true
+		}
 	}
+	return false
+}
 
+// toVAppResponse converts a VApp model and its VM count to VCD-compliant response format
+func (h *VAppHandlers) toVAppResponse(vapp models.VApp, numberOfVMs int) VAppResponse {
 	return VAppResponse{
 		ID:          vapp.ID,
 		Name:        vapp.Name,
 		Description: vapp.Description,
 		Status:      vapp.Status,
+		HealthState: vapp.GetHealthState(),
 		VDCID:       vapp.VDCID,
-		TemplateID:  templateID,
+		TemplateID:  vappTemplateID(vapp),
 		CreatedAt:   vapp.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		NumberOfVMs: len(vapp.VMs), // Count actual VMs
+		NumberOfVMs: numberOfVMs,
 		Href:        fmt.Sprintf("/cloudapi/1.0.0/vapps/%s", vapp.ID),
+		Conditions:  vapp.Conditions(),
 	}
 }
 
 // toVAppDetailedResponse converts a VApp model to detailed VCD-compliant response format
 func (h *VAppHandlers) toVAppDetailedResponse(vapp models.VApp) VAppDetailedResponse {
-	templateID := ""
-	if vapp.TemplateID != nil {
-		templateID = *vapp.TemplateID
-	}
-
 	// Convert VMs to references
 	vmRefs := make([]VMReference, len(vapp.VMs))
 	for i, vm := range vapp.VMs {
 		vmRefs[i] = VMReference{
-			ID:     vm.ID,
-			Name:   vm.Name,
-			Status: vm.Status,
-			Href:   fmt.Sprintf("/cloudapi/1.0.0/vms/%s", vm.ID),
+			ID:          vm.ID,
+			Name:        vm.Name,
+			Status:      vm.Status,
+			HealthState: vm.GetHealthState(),
+			Href:        fmt.Sprintf("/cloudapi/1.0.0/vms/%s", vm.ID),
 		}
 	}
 
@@ -415,14 +694,82 @@ func (h *VAppHandlers) toVAppDetailedResponse(vapp models.VApp) VAppDetailedResp
 		Name:        vapp.Name,
 		Description: vapp.Description,
 		Status:      vapp.Status,
+		HealthState: vapp.GetHealthState(),
 		VDCID:       vapp.VDCID,
-		TemplateID:  templateID,
+		TemplateID:  vappTemplateID(vapp),
 		CreatedAt:   vapp.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:   vapp.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 		NumberOfVMs: len(vapp.VMs),
 		VMs:         vmRefs,
 		Href:        fmt.Sprintf("/cloudapi/1.0.0/vapps/%s", vapp.ID),
+		Conditions:  vapp.Conditions(),
+	}
+}
+
+// vappTemplateID returns the template a vApp was instantiated from: the vApp
+// template record when there is one, otherwise the catalog item it came from
+func vappTemplateID(vapp models.VApp) string {
+	if vapp.TemplateID != nil {
+		return *vapp.TemplateID
+	}
+	return vapp.CatalogItemID
+}
+
+// parseVAppListFilter extracts the filter, status and name query parameters.
+// status accepts a comma-separated list of vApp statuses.
+func parseVAppListFilter(c *gin.Context) repositories.VAppListFilter {
+	filter := repositories.VAppListFilter{
+		Filter:     c.Query("filter"),
+		NameSearch: strings.TrimSpace(c.Query("name")),
+	}
+	filter.Statuses = parseStatusParam(c.Query("status"))
+	return filter
+}
+
+// parseStatusParam splits a comma-separated status query parameter into
+// upper-case status values
+func parseStatusParam(statusParam string) []string {
+	var statuses []string
+	for _, status := range strings.Split(statusParam, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses = append(statuses, strings.ToUpper(status))
+		}
+	}
+	return statuses
+}
+
+// parseCursorParam extracts the cursor query parameter, writing an error
+// response if it is invalid. Its presence selects cursor pagination, which
+// orders by name and ID; an empty cursor starts at the first page. Cursors
+// cannot be combined with page, offset or sort parameters.
+func parseCursorParam(c *gin.Context) (cursor *pagination.Cursor, useCursor, ok bool) {
+	value, useCursor := c.GetQuery("cursor")
+	if !useCursor {
+		return nil, false, true
+	}
+	for _, param := range []string{"page", "offset", "sortAsc", "sortDesc"} {
+		if c.Query(param) != "" {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Pagination cursor cannot be combined with page, offset or sort parameters",
+			))
+			return nil, true, false
+		}
+	}
+	if value == "" {
+		return nil, true, true
+	}
+	decoded, err := pagination.DecodeCursor(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid pagination cursor",
+		))
+		return nil, true, false
 	}
+	return &decoded, true, true
 }
 
 // parseVAppPaginationParams extracts and validates pagination and sorting parameters from the request
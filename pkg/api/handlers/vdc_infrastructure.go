@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// VDCInfrastructureResponse is the response for
+// GET /api/admin/org/{orgId}/vdcs/{vdcId}/infrastructure
+type VDCInfrastructureResponse struct {
+	VDCID       string `json:"vdcId"`
+	CollectedAt string `json:"collectedAt"`
+	*services.VDCInfrastructure
+}
+
+// GetVDCInfrastructure handles GET /api/admin/org/{orgId}/vdcs/{vdcId}/infrastructure.
+// It is read-only: it reports the namespace, ResourceQuotas with their usage,
+// LimitRanges and NetworkPolicies as they exist in the cluster, so
+// administrators can verify what SSVirt created without kubectl.
+func (h *VDCHandlers) GetVDCInfrastructure(c *gin.Context) {
+	orgURN := c.Param("orgId")
+	vdcURN := c.Param("vdcId")
+
+	// Validate URN formats
+	if !strings.HasPrefix(orgURN, models.URNPrefixOrg) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid organization URN format",
+			"Organization ID must be a valid URN with prefix 'urn:vcloud:org:'",
+		))
+		return
+	}
+
+	if !strings.HasPrefix(vdcURN, models.URNPrefixVDC) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VDC URN format",
+			"VDC ID must be a valid URN with prefix 'urn:vcloud:vdc:'",
+		))
+		return
+	}
+
+	vdc, err := h.vdcRepo.GetByOrgAndVDCURN(orgURN, vdcURN)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"VDC not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VDC",
+			err.Error(),
+		))
+		return
+	}
+
+	if h.k8sService == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"VDC infrastructure is not available",
+		))
+		return
+	}
+
+	response := VDCInfrastructureResponse{
+		VDCID:       vdc.ID,
+		CollectedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if vdc.Namespace == "" {
+		// The namespace was never assigned, so there is nothing to inspect
+		response.VDCInfrastructure = &services.VDCInfrastructure{
+			ResourceQuotas:  []services.VDCResourceQuota{},
+			LimitRanges:     []services.VDCLimitRange{},
+			NetworkPolicies: []services.VDCNetworkPolicy{},
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	infrastructure, err := h.k8sService.GetVDCInfrastructure(c.Request.Context(), vdc.Namespace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to read VDC infrastructure",
+			err.Error(),
+		))
+		return
+	}
+	response.VDCInfrastructure = infrastructure
+	c.JSON(http.StatusOK, response)
+}
@@ -11,13 +11,15 @@ import (
 
 	"github.com/mhrivnak/ssvirt/pkg/api/types"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/config"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
 )
 
 // VDCPublicHandlers handles public (non-admin) VDC API endpoints
 type VDCPublicHandlers struct {
-	vdcRepo *repositories.VDCRepository
+	vdcRepo       *repositories.VDCRepository
+	defaultVMSize config.VMSizeConfig
 }
 
 // NewVDCPublicHandlers creates a new VDCPublicHandlers instance
@@ -27,6 +29,12 @@ func NewVDCPublicHandlers(vdcRepo *repositories.VDCRepository) *VDCPublicHandler
 	}
 }
 
+// SetDefaultVMSize configures the VM size the remaining capacity of VDCs is
+// estimated in; without it the estimate is omitted
+func (h *VDCPublicHandlers) SetDefaultVMSize(size config.VMSizeConfig) {
+	h.defaultVMSize = size
+}
+
 // isValidVDCURN validates that a VDC URN matches the expected format
 func isValidVDCURN(urn string) bool {
 	urnType, err := models.GetURNType(urn)
@@ -62,8 +70,14 @@ func (h *VDCPublicHandlers) ListVDCs(c *gin.Context) {
 	// Calculate offset
 	offset := (page - 1) * pageSize
 
-	// Get VDCs accessible to the user
-	vdcs, err := h.vdcRepo.ListAccessibleVDCs(c.Request.Context(), userClaims.UserID, pageSize, offset)
+	// Exact-match lookups let infrastructure-as-code tools import existing VDCs
+	filter := repositories.VDCListFilter{
+		Name:       c.Query("name"),
+		ExternalID: c.Query("externalId"),
+	}
+
+	// Get a page of the VDCs accessible to the user and their total count
+	vdcs, totalCount, err := h.vdcRepo.ListAccessibleVDCsWithCount(c.Request.Context(), userClaims.UserID, filter, pageSize, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, NewAPIError(
 			http.StatusInternalServerError,
@@ -73,13 +87,17 @@ func (h *VDCPublicHandlers) ListVDCs(c *gin.Context) {
 		return
 	}
 
-	// Get total count of accessible VDCs
-	totalCount, err := h.vdcRepo.CountAccessibleVDCs(c.Request.Context(), userClaims.UserID)
+	// Aggregate the compute usage of the whole page in one query
+	vdcIDs := make([]string, len(vdcs))
+	for i, vdc := range vdcs {
+		vdcIDs[i] = vdc.ID
+	}
+	usage, err := h.vdcRepo.ComputeUsageByVDC(c.Request.Context(), vdcIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, NewAPIError(
 			http.StatusInternalServerError,
 			"Internal Server Error",
-			"Failed to count VDCs",
+			"Failed to retrieve VDC usage",
 		))
 		return
 	}
@@ -87,7 +105,7 @@ func (h *VDCPublicHandlers) ListVDCs(c *gin.Context) {
 	// Convert to response format
 	vdcResponses := make([]VDCResponse, len(vdcs))
 	for i, vdc := range vdcs {
-		vdcResponses[i] = toVDCResponse(vdc)
+		vdcResponses[i] = h.withCapacity(toVDCResponse(vdc), vdc, usage[vdc.ID])
 	}
 
 	// Calculate pagination info
@@ -158,8 +176,76 @@ func (h *VDCPublicHandlers) GetVDC(c *gin.Context) {
 		))
 		return
 	}
+	if err := h.vdcRepo.LoadStorageProfiles(c.Request.Context(), vdc); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VDC storage profiles",
+		))
+		return
+	}
+
+	usage, err := h.vdcRepo.ComputeUsage(c.Request.Context(), vdc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VDC usage",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, h.withCapacity(toVDCResponse(*vdc), *vdc, usage))
+}
+
+// withCapacity adds a VDC's VM count and the headroom left under its compute
+// limits to its response, so tenants can pick a VDC with room before
+// instantiating. Percentages are omitted for unlimited resources and the
+// remaining capacity when no resource is limited. As in quota enforcement,
+// CPU limits only count in cores or millicores.
+func (h *VDCPublicHandlers) withCapacity(response VDCResponse, vdc models.VDC, usage models.ComputeUsage) VDCResponse {
+	vmCount := usage.VMs
+	response.VMCount = &vmCount
+
+	var remaining *int64
+	// fit lowers the estimate to the default-size VMs that fit in free
+	fit := func(free, perVM int64) {
+		if perVM <= 0 {
+			return
+		}
+		vms := max(free/perVM, 0)
+		if remaining == nil || vms < *remaining {
+			remaining = &vms
+		}
+	}
+
+	if vdc.MemoryLimit > 0 {
+		limit := int64(vdc.MemoryLimit)
+		response.MemoryUsedPct = usedPercent(usage.MemoryMB, limit)
+		fit(limit-usage.MemoryMB, int64(h.defaultVMSize.MemoryMB))
+	}
 
-	c.JSON(http.StatusOK, toVDCResponse(*vdc))
+	scale := int64(0)
+	switch vdc.CPUUnits {
+	case "cores":
+		scale = 1
+	case "millicores":
+		scale = 1000
+	}
+	if scale > 0 && vdc.CPULimit > 0 {
+		limit := int64(vdc.CPULimit)
+		response.CPUUsedPct = usedPercent(usage.CPUs*scale, limit)
+		fit(limit-usage.CPUs*scale, int64(h.defaultVMSize.CPUs)*scale)
+	}
+
+	response.RemainingCapacityEstimate = remaining
+	return response
+}
+
+// usedPercent returns used as a whole percentage of limit
+func usedPercent(used, limit int64) *int64 {
+	pct := used * 100 / limit
+	return &pct
 }
 
 // toVDCResponse converts a VDC model to VCD-compliant response format
@@ -167,6 +253,7 @@ func (h *VDCPublicHandlers) GetVDC(c *gin.Context) {
 func toVDCResponse(vdc models.VDC) VDCResponse {
 	return VDCResponse{
 		ID:                 vdc.ID,
+		ExternalID:         derefString(vdc.ExternalID),
 		Name:               vdc.Name,
 		Description:        vdc.Description,
 		AllocationModel:    vdc.AllocationModel,
@@ -177,6 +264,14 @@ func toVDCResponse(vdc models.VDC) VDCResponse {
 		VdcStorageProfiles: vdc.VdcStorageProfiles(),
 		IsThinProvision:    vdc.IsThinProvision,
 		IsEnabled:          vdc.IsEnabled,
+
+		AllowedInterfaceTypes:  vdc.InterfaceTypes(),
+		StorageAlertThresholds: vdc.StorageAlertThresholds(),
+		ComputeQuotaPolicy:     vdc.ComputeQuotaPolicy(),
+		AutoSuspendPolicy:      vdc.AutoSuspendPolicy(),
+		MetadataPolicy:         vdc.MetadataPolicy(),
+		BackupPolicy:           vdc.BackupPolicy(),
+		Conditions:             vdc.Conditions(),
 	}
 }
 
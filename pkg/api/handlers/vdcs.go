@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/mhrivnak/ssvirt/pkg/api/types"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
@@ -21,6 +23,10 @@ type VDCHandlers struct {
 	orgRepo    *repositories.OrganizationRepository
 	userRepo   *repositories.UserRepository
 	k8sService services.KubernetesService
+	// reservedNames are name prefixes new VDCs may not use
+	reservedNames ReservedNamePrefixes
+	// approvals may hold VDC deletion for external approval
+	approvals *Approvals
 }
 
 func NewVDCHandlers(vdcRepo *repositories.VDCRepository, orgRepo *repositories.OrganizationRepository, userRepo *repositories.UserRepository, k8sService services.KubernetesService) *VDCHandlers {
@@ -43,6 +49,39 @@ type VDCCreateRequest struct {
 	NetworkQuota    int                    `json:"networkQuota"`
 	IsThinProvision bool                   `json:"isThinProvision"`
 	IsEnabled       bool                   `json:"isEnabled"`
+	// AllowedInterfaceTypes defaults to bridge and masquerade
+	AllowedInterfaceTypes []models.InterfaceType `json:"allowedInterfaceTypes,omitempty"`
+	// StorageProfiles limits the storage the VDC may use per storage class
+	StorageProfiles        []VDCStorageProfileParams      `json:"storageProfiles,omitempty"`
+	StorageAlertThresholds *models.StorageAlertThresholds `json:"storageAlertThresholds,omitempty"`
+	ComputeQuotaPolicy     *models.ComputeQuotaPolicy     `json:"computeQuotaPolicy,omitempty"`
+	AutoSuspendPolicy      *models.AutoSuspendPolicy      `json:"autoSuspendPolicy,omitempty"`
+	MetadataPolicy         *models.MetadataPolicy         `json:"metadataPolicy,omitempty"`
+	BackupPolicy           *models.BackupPolicy           `json:"backupPolicy,omitempty"`
+	// ObjectQuota overrides the configured pod, PVC, service, secret and
+	// ConfigMap counts of the VDC's ResourceQuota
+	ObjectQuota *models.ObjectQuota `json:"objectQuota,omitempty"`
+	// DNSZone publishes the VDC's VMs in DNS as <vm>.<zone> through ExternalDNS
+	DNSZone string `json:"dnsZone,omitempty"`
+	// ExternalID makes creation idempotent: repeating a request with the same
+	// external ID, organization and name returns the VDC created by the first one
+	ExternalID string `json:"externalId,omitempty"`
+}
+
+// VDCStorageProfileParams sets the storage limit of a VDC storage profile
+type VDCStorageProfileParams struct {
+	// Name is the Kubernetes StorageClass the profile maps to
+	Name string `json:"name"`
+	// Limit is the storage allowed in Units; 0 means unlimited
+	Limit int64 `json:"limit"`
+	// Units is MB (default) or GB
+	Units string `json:"units,omitempty"`
+	// Default marks the profile persistent disks use unless the template or
+	// instantiation names another; at most one profile may be the default
+	Default bool `json:"default,omitempty"`
+	// DiskTier is the tier template disks default to in the VDC, settable on
+	// the default profile only
+	DiskTier models.DiskTier `json:"diskTier,omitempty"`
 }
 
 // VDCUpdateRequest represents the request body for updating a VDC
@@ -56,11 +95,26 @@ type VDCUpdateRequest struct {
 	NetworkQuota    *int                    `json:"networkQuota,omitempty"`
 	IsThinProvision *bool                   `json:"isThinProvision,omitempty"`
 	IsEnabled       *bool                   `json:"isEnabled,omitempty"`
+	// AllowedInterfaceTypes replaces the allowed types; an empty list restores the defaults
+	AllowedInterfaceTypes *[]models.InterfaceType `json:"allowedInterfaceTypes,omitempty"`
+	// StorageProfiles replaces the storage profile limits when set
+	StorageProfiles        *[]VDCStorageProfileParams     `json:"storageProfiles,omitempty"`
+	StorageAlertThresholds *models.StorageAlertThresholds `json:"storageAlertThresholds,omitempty"`
+	ComputeQuotaPolicy     *models.ComputeQuotaPolicy     `json:"computeQuotaPolicy,omitempty"`
+	AutoSuspendPolicy      *models.AutoSuspendPolicy      `json:"autoSuspendPolicy,omitempty"`
+	MetadataPolicy         *models.MetadataPolicy         `json:"metadataPolicy,omitempty"`
+	BackupPolicy           *models.BackupPolicy           `json:"backupPolicy,omitempty"`
+	// ObjectQuota replaces the VDC's object count overrides when set; an empty
+	// object restores the configured defaults
+	ObjectQuota *models.ObjectQuota `json:"objectQuota,omitempty"`
+	// DNSZone replaces the VDC's DNS zone when set; an empty zone stops publishing VMs
+	DNSZone *string `json:"dnsZone,omitempty"`
 }
 
 // VDCResponse represents the VCD-compliant VDC response
 type VDCResponse struct {
 	ID                 string                    `json:"id"`
+	ExternalID         string                    `json:"externalId,omitempty"`
 	Name               string                    `json:"name"`
 	Description        string                    `json:"description"`
 	AllocationModel    models.AllocationModel    `json:"allocationModel"`
@@ -71,6 +125,27 @@ type VDCResponse struct {
 	VdcStorageProfiles models.VdcStorageProfiles `json:"vdcStorageProfiles"`
 	IsThinProvision    bool                      `json:"isThinProvision"`
 	IsEnabled          bool                      `json:"isEnabled"`
+	// AllowedInterfaceTypes lists the interface types VM NICs in the VDC may request
+	AllowedInterfaceTypes  []models.InterfaceType        `json:"allowedInterfaceTypes"`
+	StorageAlertThresholds models.StorageAlertThresholds `json:"storageAlertThresholds"`
+	ComputeQuotaPolicy     models.ComputeQuotaPolicy     `json:"computeQuotaPolicy"`
+	AutoSuspendPolicy      models.AutoSuspendPolicy      `json:"autoSuspendPolicy"`
+	MetadataPolicy         models.MetadataPolicy         `json:"metadataPolicy"`
+	// BackupPolicy is omitted when the VDC leaves backups to the operator
+	BackupPolicy *models.BackupPolicy `json:"backupPolicy,omitempty"`
+	// ObjectQuota is omitted when the VDC uses the configured object counts
+	ObjectQuota *models.ObjectQuota `json:"objectQuota,omitempty"`
+	DNSZone     string              `json:"dnsZone,omitempty"`
+	// Conditions report the readiness of the VDC's namespace, quota and network
+	Conditions []models.Condition `json:"conditions"`
+	// VMCount and the capacity fields are only reported by the public VDC API.
+	// The used percentages are omitted for unlimited resources, and the
+	// remaining capacity, in VMs of the configured default size, when no
+	// resource is limited.
+	VMCount                   *int64 `json:"vmCount,omitempty"`
+	CPUUsedPct                *int64 `json:"cpuUsedPct,omitempty"`
+	MemoryUsedPct             *int64 `json:"memoryUsedPct,omitempty"`
+	RemainingCapacityEstimate *int64 `json:"remainingCapacityEstimate,omitempty"`
 }
 
 // ListVDCs handles GET /api/admin/org/{orgId}/vdcs
@@ -209,6 +284,16 @@ func (h *VDCHandlers) GetVDC(c *gin.Context) {
 		return
 	}
 
+	if err := h.vdcRepo.LoadStorageProfiles(c.Request.Context(), vdc); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VDC storage profiles",
+			err.Error(),
+		))
+		return
+	}
+
 	c.JSON(http.StatusOK, h.toVDCResponse(*vdc))
 }
 
@@ -248,16 +333,21 @@ func (h *VDCHandlers) CreateVDC(c *gin.Context) {
 	}
 
 	var req VDCCreateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, NewAPIError(
-			http.StatusBadRequest,
-			"Bad Request",
-			"Invalid request body",
-			err.Error(),
-		))
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
+	h.createVDC(c, org, req)
+}
+
+// SetReservedNamePrefixes sets the name prefixes new VDCs may not use
+func (h *VDCHandlers) SetReservedNamePrefixes(prefixes ReservedNamePrefixes) {
+	h.reservedNames = prefixes
+}
+
+// createVDC creates a VDC in an organization that has already been resolved
+func (h *VDCHandlers) createVDC(c *gin.Context, org *models.Organization, req VDCCreateRequest) {
 	// Validate allocation model
 	if !req.AllocationModel.Valid() {
 		c.JSON(http.StatusBadRequest, NewAPIError(
@@ -268,6 +358,70 @@ func (h *VDCHandlers) CreateVDC(c *gin.Context) {
 		))
 		return
 	}
+	if prefix, reserved := h.reservedNames.Match(req.Name); reserved {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Name uses a reserved prefix",
+			fmt.Sprintf("names starting with %q are reserved for system objects", prefix),
+		))
+		return
+	}
+
+	if !validateInterfaceTypes(c, req.AllowedInterfaceTypes) {
+		return
+	}
+	storageProfiles, ok := parseStorageProfiles(c, req.StorageProfiles)
+	if !ok {
+		return
+	}
+	if req.StorageAlertThresholds != nil && !validateStorageAlertThresholds(c, *req.StorageAlertThresholds) {
+		return
+	}
+	if req.ComputeQuotaPolicy != nil && !validateComputeQuotaPolicy(c, *req.ComputeQuotaPolicy) {
+		return
+	}
+	if req.AutoSuspendPolicy != nil && !validateAutoSuspendPolicy(c, *req.AutoSuspendPolicy) {
+		return
+	}
+	if req.MetadataPolicy != nil && !validateMetadataPolicy(c, *req.MetadataPolicy) {
+		return
+	}
+	if req.BackupPolicy != nil && !validateBackupPolicy(c, *req.BackupPolicy) {
+		return
+	}
+	if req.ObjectQuota != nil && !validateObjectQuota(c, *req.ObjectQuota) {
+		return
+	}
+	if !validateDNSZone(c, req.DNSZone) {
+		return
+	}
+
+	if req.ExternalID != "" {
+		existing, err := h.vdcRepo.GetByExternalID(req.ExternalID)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to check existing VDC external ID",
+				err.Error(),
+			))
+			return
+		}
+		if existing != nil {
+			if existing.OrganizationID != org.ID || existing.Name != req.Name {
+				c.JSON(http.StatusConflict, NewAPIError(
+					http.StatusConflict,
+					"Conflict",
+					"VDC external ID is already used by another VDC",
+				))
+				return
+			}
+			// A retried create returns the VDC it already created
+			c.JSON(http.StatusOK, h.toVDCResponse(*existing))
+			return
+		}
+	}
 
 	// Set defaults for optional fields
 	if req.NicQuota == 0 {
@@ -281,22 +435,84 @@ func (h *VDCHandlers) CreateVDC(c *gin.Context) {
 	vdc := &models.VDC{
 		Name:            req.Name,
 		Description:     req.Description,
-		OrganizationID:  orgURN,
+		OrganizationID:  org.ID,
 		AllocationModel: req.AllocationModel,
 		NicQuota:        req.NicQuota,
 		NetworkQuota:    req.NetworkQuota,
 		IsThinProvision: req.IsThinProvision,
 		IsEnabled:       req.IsEnabled,
+		DNSZone:         strings.ToLower(req.DNSZone),
+	}
+	if req.ExternalID != "" {
+		vdc.ExternalID = &req.ExternalID
 	}
 
 	// Set compute capacity
 	vdc.SetComputeCapacity(req.ComputeCapacity)
+	vdc.SetInterfaceTypes(req.AllowedInterfaceTypes)
+	if req.StorageAlertThresholds != nil {
+		vdc.SetStorageAlertThresholds(*req.StorageAlertThresholds)
+	}
+	if req.ComputeQuotaPolicy != nil {
+		vdc.SetComputeQuotaPolicy(*req.ComputeQuotaPolicy)
+	}
+	if req.AutoSuspendPolicy != nil {
+		vdc.SetAutoSuspendPolicy(*req.AutoSuspendPolicy)
+	}
+	if req.MetadataPolicy != nil {
+		vdc.SetMetadataPolicy(*req.MetadataPolicy)
+	}
+	vdc.SetBackupPolicy(req.BackupPolicy)
+	if req.ObjectQuota != nil {
+		vdc.SetObjectQuota(*req.ObjectQuota)
+	}
+	vdc.StorageProfiles = storageProfiles
 
 	// Set provider VDC reference
 	vdc.SetProviderVdc(req.ProviderVdc)
 
+	// Pick a namespace that no other VDC uses and that does not already exist
+	// in the cluster, so a namespace left behind by a deleted VDC or created
+	// outside SSVirt is never adopted
+	if h.k8sService != nil {
+		ctx := c.Request.Context()
+		namespace, err := h.vdcRepo.GenerateNamespace(ctx, org.Name, vdc.Name, func(name string) (bool, error) {
+			return h.k8sService.NamespaceExists(ctx, name)
+		})
+		if err != nil {
+			if errors.Is(err, models.ErrNamespaceUnavailable) {
+				c.JSON(http.StatusConflict, NewAPIError(
+					http.StatusConflict,
+					"Conflict",
+					"VDC namespace is unavailable",
+					err.Error(),
+				))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to generate VDC namespace",
+				err.Error(),
+			))
+			return
+		}
+		vdc.Namespace = namespace
+	}
+
 	// Create VDC in database
 	if err := h.vdcRepo.Create(vdc); err != nil {
+		// A concurrent create can claim the same namespace first
+		if strings.Contains(err.Error(), "idx_vdc_namespace_active") ||
+			strings.Contains(err.Error(), "vdcs.namespace") {
+			c.JSON(http.StatusConflict, NewAPIError(
+				http.StatusConflict,
+				"Conflict",
+				"VDC namespace is unavailable",
+				fmt.Sprintf("Namespace '%s' was claimed by another VDC, retry the request", vdc.Namespace),
+			))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, NewAPIError(
 			http.StatusInternalServerError,
 			"Internal Server Error",
@@ -314,6 +530,9 @@ func (h *VDCHandlers) CreateVDC(c *gin.Context) {
 				// Log cleanup error but don't fail the request
 				_ = cleanupErr
 			}
+			if respondNamespaceWritesSaturated(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, NewAPIError(
 				http.StatusInternalServerError,
 				"Internal Server Error",
@@ -373,14 +592,14 @@ func (h *VDCHandlers) UpdateVDC(c *gin.Context) {
 		return
 	}
 
+	h.updateVDC(c, vdc)
+}
+
+// updateVDC applies the update request body to a VDC that has already been resolved
+func (h *VDCHandlers) updateVDC(c *gin.Context, vdc *models.VDC) {
 	var req VDCUpdateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, NewAPIError(
-			http.StatusBadRequest,
-			"Bad Request",
-			"Invalid request body",
-			err.Error(),
-		))
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
@@ -420,6 +639,61 @@ func (h *VDCHandlers) UpdateVDC(c *gin.Context) {
 	if req.IsEnabled != nil {
 		vdc.IsEnabled = *req.IsEnabled
 	}
+	if req.AllowedInterfaceTypes != nil {
+		if !validateInterfaceTypes(c, *req.AllowedInterfaceTypes) {
+			return
+		}
+		vdc.SetInterfaceTypes(*req.AllowedInterfaceTypes)
+	}
+	if req.StorageAlertThresholds != nil {
+		if !validateStorageAlertThresholds(c, *req.StorageAlertThresholds) {
+			return
+		}
+		vdc.SetStorageAlertThresholds(*req.StorageAlertThresholds)
+	}
+	if req.ComputeQuotaPolicy != nil {
+		if !validateComputeQuotaPolicy(c, *req.ComputeQuotaPolicy) {
+			return
+		}
+		vdc.SetComputeQuotaPolicy(*req.ComputeQuotaPolicy)
+	}
+	if req.AutoSuspendPolicy != nil {
+		if !validateAutoSuspendPolicy(c, *req.AutoSuspendPolicy) {
+			return
+		}
+		vdc.SetAutoSuspendPolicy(*req.AutoSuspendPolicy)
+	}
+	if req.MetadataPolicy != nil {
+		if !validateMetadataPolicy(c, *req.MetadataPolicy) {
+			return
+		}
+		vdc.SetMetadataPolicy(*req.MetadataPolicy)
+	}
+	if req.BackupPolicy != nil {
+		if !validateBackupPolicy(c, *req.BackupPolicy) {
+			return
+		}
+		vdc.SetBackupPolicy(req.BackupPolicy)
+	}
+	if req.ObjectQuota != nil {
+		if !validateObjectQuota(c, *req.ObjectQuota) {
+			return
+		}
+		vdc.SetObjectQuota(*req.ObjectQuota)
+	}
+	if req.DNSZone != nil {
+		if !validateDNSZone(c, *req.DNSZone) {
+			return
+		}
+		vdc.DNSZone = strings.ToLower(*req.DNSZone)
+	}
+	var storageProfiles []models.VDCStorageProfile
+	if req.StorageProfiles != nil {
+		var ok bool
+		if storageProfiles, ok = parseStorageProfiles(c, *req.StorageProfiles); !ok {
+			return
+		}
+	}
 
 	// Update VDC
 	if err := h.vdcRepo.Update(vdc); err != nil {
@@ -431,6 +705,38 @@ func (h *VDCHandlers) UpdateVDC(c *gin.Context) {
 		))
 		return
 	}
+	if storageProfiles != nil {
+		if err := h.vdcRepo.SetStorageProfiles(c.Request.Context(), vdc.ID, storageProfiles); err != nil {
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to update VDC storage profiles",
+				err.Error(),
+			))
+			return
+		}
+	}
+	// Apply changed object counts to the namespace's ResourceQuota
+	if req.ObjectQuota != nil && h.k8sService != nil && vdc.Namespace != "" {
+		if err := h.k8sService.EnsureNamespaceResources(c.Request.Context(), vdc.Namespace, vdc); err != nil {
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to update VDC resource quota",
+				err.Error(),
+			))
+			return
+		}
+	}
+	if err := h.vdcRepo.LoadStorageProfiles(c.Request.Context(), vdc); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VDC storage profiles",
+			err.Error(),
+		))
+		return
+	}
 
 	c.JSON(http.StatusOK, h.toVDCResponse(*vdc))
 }
@@ -481,6 +787,26 @@ func (h *VDCHandlers) DeleteVDC(c *gin.Context) {
 		return
 	}
 
+	h.deleteVDC(c, vdc)
+}
+
+// SetApprovals enables holding VDC deletion for external approval
+func (h *VDCHandlers) SetApprovals(approvals *Approvals) {
+	h.approvals = approvals
+}
+
+// deleteVDC deletes a VDC that has already been resolved, along with its namespace
+func (h *VDCHandlers) deleteVDC(c *gin.Context, vdc *models.VDC) {
+	if !h.approvals.Authorize(c, services.ApprovalRequest{
+		Operation:   models.TaskOperationVDCDelete,
+		Description: fmt.Sprintf("Deleting VDC %s", vdc.Name),
+		OrgID:       vdc.OrganizationID,
+		TargetID:    vdc.ID,
+		TargetName:  vdc.Name,
+	}) {
+		return
+	}
+
 	// Delete VDC with validation (checks for dependent vApps)
 	if err := h.vdcRepo.DeleteWithValidation(vdc.ID); err != nil {
 		if strings.Contains(err.Error(), "dependent vApps") {
@@ -515,8 +841,9 @@ func (h *VDCHandlers) DeleteVDC(c *gin.Context) {
 
 // toVDCResponse converts a VDC model to VCD-compliant response format
 func (h *VDCHandlers) toVDCResponse(vdc models.VDC) VDCResponse {
-	return VDCResponse{
+	response := VDCResponse{
 		ID:                 vdc.ID,
+		ExternalID:         derefString(vdc.ExternalID),
 		Name:               vdc.Name,
 		Description:        vdc.Description,
 		AllocationModel:    vdc.AllocationModel,
@@ -527,11 +854,226 @@ func (h *VDCHandlers) toVDCResponse(vdc models.VDC) VDCResponse {
 		VdcStorageProfiles: vdc.VdcStorageProfiles(),
 		IsThinProvision:    vdc.IsThinProvision,
 		IsEnabled:          vdc.IsEnabled,
+
+		AllowedInterfaceTypes:  vdc.InterfaceTypes(),
+		StorageAlertThresholds: vdc.StorageAlertThresholds(),
+		ComputeQuotaPolicy:     vdc.ComputeQuotaPolicy(),
+		AutoSuspendPolicy:      vdc.AutoSuspendPolicy(),
+		MetadataPolicy:         vdc.MetadataPolicy(),
+		BackupPolicy:           vdc.BackupPolicy(),
+		DNSZone:                vdc.DNSZone,
+		Conditions:             vdc.Conditions(),
+	}
+	if objectQuota := vdc.ObjectQuota(); !objectQuota.IsEmpty() {
+		response.ObjectQuota = &objectQuota
+	}
+	return response
+}
+
+// derefString returns the value of an optional string, or "" when it is unset
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// parseStorageProfiles validates storage profile limits, converting them to MB,
+// and the default profile, writing a 400 for an invalid request
+func parseStorageProfiles(c *gin.Context, profiles []VDCStorageProfileParams) ([]models.VDCStorageProfile, bool) {
+	result := make([]models.VDCStorageProfile, 0, len(profiles))
+	names := make(map[string]bool, len(profiles))
+	defaultProfile := ""
+	for _, profile := range profiles {
+		var message string
+		switch {
+		case profile.Name == "" || len(profile.Name) > 253:
+			message = "Storage profile name must be between 1 and 253 characters"
+		case names[profile.Name]:
+			message = fmt.Sprintf("Storage profile '%s' is listed more than once", profile.Name)
+		case profile.Limit < 0:
+			message = fmt.Sprintf("Storage profile '%s' limit must not be negative", profile.Name)
+		case profile.Units != "" && profile.Units != "MB" && profile.Units != "GB":
+			message = fmt.Sprintf("Storage profile '%s' units must be MB or GB", profile.Name)
+		case profile.Default && defaultProfile != "":
+			message = fmt.Sprintf("Storage profiles '%s' and '%s' cannot both be the default", defaultProfile, profile.Name)
+		case profile.DiskTier != "" && !profile.DiskTier.Valid():
+			message = fmt.Sprintf("Storage profile '%s' disk tier must be ephemeral or persistent", profile.Name)
+		case profile.DiskTier != "" && !profile.Default:
+			message = fmt.Sprintf("Storage profile '%s' sets a disk tier but is not the default profile", profile.Name)
+		}
+		if message != "" {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid storage profile",
+				message,
+			))
+			return nil, false
+		}
+		names[profile.Name] = true
+		if profile.Default {
+			defaultProfile = profile.Name
+		}
+
+		limit := profile.Limit
+		if profile.Units == "GB" {
+			limit *= 1024
+		}
+		result = append(result, models.VDCStorageProfile{
+			Name:      profile.Name,
+			LimitMB:   limit,
+			IsDefault: profile.Default,
+			DiskTier:  profile.DiskTier,
+		})
+	}
+	return result, true
+}
+
+// validateStorageAlertThresholds writes a 400 unless 0 < warning < critical <= 100
+func validateStorageAlertThresholds(c *gin.Context, thresholds models.StorageAlertThresholds) bool {
+	if thresholds.Warning <= 0 || thresholds.Warning >= thresholds.Critical || thresholds.Critical > 100 {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid storage alert thresholds",
+			"Thresholds must satisfy 0 < warning < critical <= 100",
+		))
+		return false
+	}
+	return true
+}
+
+// validateObjectQuota writes a 400 unless every object count is at least 0
+func validateObjectQuota(c *gin.Context, quota models.ObjectQuota) bool {
+	for _, count := range []*int{quota.Pods, quota.PersistentVolumeClaims, quota.Services, quota.Secrets, quota.ConfigMaps} {
+		if count != nil && *count < 0 {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid object quota",
+				"Object counts must not be negative; 0 means unlimited",
+			))
+			return false
+		}
+	}
+	return true
+}
+
+// validateComputeQuotaPolicy writes a 400 unless 0 <= softLimitPercent <= 100
+// and 0 <= gracePercent <= 100
+func validateComputeQuotaPolicy(c *gin.Context, policy models.ComputeQuotaPolicy) bool {
+	if policy.SoftLimitPercent < 0 || policy.SoftLimitPercent > 100 || policy.GracePercent < 0 || policy.GracePercent > 100 {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid compute quota policy",
+			"softLimitPercent and gracePercent must be between 0 and 100",
+		))
+		return false
+	}
+	return true
+}
+
+// maxAutoSuspendIdleHours caps the idle period of an auto-suspend policy at 30 days
+const maxAutoSuspendIdleHours = 720
+
+// validateAutoSuspendPolicy writes a 400 unless an enabled policy has an idle
+// period of 1 to 720 hours, the CPU threshold is between 0 and 100 and the
+// action is suspend or powerOff
+func validateAutoSuspendPolicy(c *gin.Context, policy models.AutoSuspendPolicy) bool {
+	var message string
+	switch {
+	case policy.Enabled && (policy.IdleHours < 1 || policy.IdleHours > maxAutoSuspendIdleHours):
+		message = fmt.Sprintf("idleHours must be between 1 and %d", maxAutoSuspendIdleHours)
+	case policy.IdleHours < 0 || policy.IdleHours > maxAutoSuspendIdleHours:
+		message = fmt.Sprintf("idleHours must be between 0 and %d", maxAutoSuspendIdleHours)
+	case policy.CPUThresholdPercent < 0 || policy.CPUThresholdPercent > 100:
+		message = "cpuThresholdPercent must be between 0 and 100"
+	case policy.Action != "" && policy.Action != models.AutoSuspendActionSuspend && policy.Action != models.AutoSuspendActionPowerOff:
+		message = "action must be one of: suspend, powerOff"
+	}
+	if message != "" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid auto-suspend policy",
+			message,
+		))
+		return false
+	}
+	return true
+}
+
+// validateMetadataPolicy writes a 400 unless the policy's labels and
+// annotations are valid and outside the reserved key prefixes
+func validateMetadataPolicy(c *gin.Context, policy models.MetadataPolicy) bool {
+	if err := services.ValidateMetadataPolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid metadata policy",
+			err.Error(),
+		))
+		return false
+	}
+	return true
+}
+
+// validateBackupPolicy writes a 400 unless the policy names a valid Schedule
+func validateBackupPolicy(c *gin.Context, policy models.BackupPolicy) bool {
+	if err := services.ValidateBackupPolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid backup policy",
+			err.Error(),
+		))
+		return false
+	}
+	return true
+}
+
+// validateDNSZone writes a 400 unless zone is empty or a DNS domain under
+// which VM names form valid host names
+func validateDNSZone(c *gin.Context, zone string) bool {
+	if zone == "" {
+		return true
+	}
+	if errs := validation.IsDNS1123Subdomain(strings.ToLower(zone)); len(errs) > 0 || !strings.Contains(zone, ".") {
+		details := "DNS zone must be a domain such as vms.example.com"
+		if len(errs) > 0 {
+			details = strings.Join(errs, "; ")
+		}
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid DNS zone",
+			details,
+		))
+		return false
+	}
+	return true
+}
+
+// validateInterfaceTypes writes a 400 if any requested interface type is unknown
+func validateInterfaceTypes(c *gin.Context, types []models.InterfaceType) bool {
+	for _, t := range types {
+		if !t.Valid() {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid interface type",
+				fmt.Sprintf("Interface type '%s' must be one of: bridge, masquerade, sriov", t),
+			))
+			return false
+		}
 	}
+	return true
 }
 
 // RequireSystemAdmin middleware ensures only System Administrators can access VDC endpoints
-func RequireSystemAdmin(userRepo *repositories.UserRepository) gin.HandlerFunc {
+func RequireSystemAdmin(roleCache *auth.RoleCache) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims, exists := c.Get(auth.ClaimsContextKey)
 		if !exists {
@@ -544,8 +1086,7 @@ func RequireSystemAdmin(userRepo *repositories.UserRepository) gin.HandlerFunc {
 			return
 		}
 
-		userClaims, ok := claims.(*auth.Claims)
-		if !ok {
+		if _, ok := claims.(*auth.Claims); !ok {
 			c.JSON(http.StatusUnauthorized, NewAPIError(
 				http.StatusUnauthorized,
 				"Unauthorized",
@@ -555,8 +1096,8 @@ func RequireSystemAdmin(userRepo *repositories.UserRepository) gin.HandlerFunc {
 			return
 		}
 
-		// Get user with roles from database
-		user, err := userRepo.GetWithRoles(userClaims.UserID)
+		// Get user with roles, shared with the rest of the request
+		user, err := auth.UserWithRoles(c, roleCache)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, NewAPIError(
 				http.StatusInternalServerError,
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// CloudAPIVDCCreateRequest represents the request body for creating a VDC through
+// CloudAPI, where the owning organization is given in the body rather than the path
+type CloudAPIVDCCreateRequest struct {
+	VDCCreateRequest
+	Org *models.EntityRef `json:"org" binding:"required"`
+}
+
+// CloudAPICreateVDC handles POST /cloudapi/1.0.0/vdcs
+func (h *VDCHandlers) CloudAPICreateVDC(c *gin.Context) {
+	var req CloudAPIVDCCreateRequest
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if !strings.HasPrefix(req.Org.ID, models.URNPrefixOrg) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid organization URN format",
+			"org.id must be a valid URN with prefix 'urn:vcloud:org:'",
+		))
+		return
+	}
+
+	org, err := h.orgRepo.GetByID(req.Org.ID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// The organization is part of the body, so an unknown one is a client error
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Organization not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to query organization",
+			err.Error(),
+		))
+		return
+	}
+
+	h.createVDC(c, org, req.VDCCreateRequest)
+}
+
+// CloudAPIUpdateVDC handles PUT /cloudapi/1.0.0/vdcs/{vdc_id}
+func (h *VDCHandlers) CloudAPIUpdateVDC(c *gin.Context) {
+	vdc, ok := h.lookupVDC(c)
+	if !ok {
+		return
+	}
+	h.updateVDC(c, vdc)
+}
+
+// CloudAPIDeleteVDC handles DELETE /cloudapi/1.0.0/vdcs/{vdc_id}
+func (h *VDCHandlers) CloudAPIDeleteVDC(c *gin.Context) {
+	vdc, ok := h.lookupVDC(c)
+	if !ok {
+		return
+	}
+	h.deleteVDC(c, vdc)
+}
+
+// lookupVDC resolves the vdc_id path parameter, writing an error response and
+// returning false if the VDC cannot be found
+func (h *VDCHandlers) lookupVDC(c *gin.Context) (*models.VDC, bool) {
+	vdcURN := c.Param("vdc_id")
+	if !strings.HasPrefix(vdcURN, models.URNPrefixVDC) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VDC URN format",
+			"VDC ID must be a valid URN with prefix 'urn:vcloud:vdc:'",
+		))
+		return nil, false
+	}
+
+	vdc, err := h.vdcRepo.GetByURN(vdcURN)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"VDC not found",
+			))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VDC",
+			err.Error(),
+		))
+		return nil, false
+	}
+	return vdc, true
+}
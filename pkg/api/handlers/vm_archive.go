@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+// VMArchiveHandlers let System Administrators look up VMs that were archived
+// after their deletion and restore them for viewing
+type VMArchiveHandlers struct {
+	archiveRepo *repositories.VMArchiveRepository
+}
+
+// NewVMArchiveHandlers creates a new VMArchiveHandlers instance
+func NewVMArchiveHandlers(archiveRepo *repositories.VMArchiveRepository) *VMArchiveHandlers {
+	return &VMArchiveHandlers{archiveRepo: archiveRepo}
+}
+
+// ArchivedVMResponse is an archived VM with its record and tasks
+type ArchivedVMResponse struct {
+	models.ArchivedVM
+	VM    VMResponse     `json:"vm"`
+	Tasks []TaskResponse `json:"tasks"`
+}
+
+// ListArchivedVMs handles GET /api/admin/archive/vms. The vappId and namespace
+// query parameters narrow the results.
+func (h *VMArchiveHandlers) ListArchivedVMs(c *gin.Context) {
+	page, pageSize := parsePaginationParams(c)
+	ctx := c.Request.Context()
+	filter := repositories.ArchivedVMFilter{
+		VAppID:    c.Query("vappId"),
+		Namespace: c.Query("namespace"),
+	}
+
+	total, err := h.archiveRepo.Count(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve archived VMs",
+		))
+		return
+	}
+	vms, err := h.archiveRepo.List(ctx, filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve archived VMs",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewPage(vms, page, pageSize, total))
+}
+
+// GetArchivedVM handles GET /api/admin/archive/vms/{id}, the archived VM
+// record and the tasks that ran on it
+func (h *VMArchiveHandlers) GetArchivedVM(c *gin.Context) {
+	archived, err := h.archiveRepo.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"Archived VM not found",
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve archived VMs",
+		))
+		return
+	}
+
+	vm, err := archived.VM()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to read archived VM",
+		))
+		return
+	}
+	tasks, err := archived.TaskList()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to read archived VM",
+		))
+		return
+	}
+
+	response := ArchivedVMResponse{
+		ArchivedVM: *archived,
+		VM:         toVMResponse(*vm),
+		Tasks:      make([]TaskResponse, 0, len(tasks)),
+	}
+	for i := range tasks {
+		response.Tasks = append(response.Tasks, toTaskResponse(&tasks[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RestoreArchivedVM handles POST /api/admin/archive/vms/{id}/actions/restore.
+// The VM and its tasks are moved back so the regular VM and task endpoints
+// show them again; the VM stays DELETED and is archived again later.
+func (h *VMArchiveHandlers) RestoreArchivedVM(c *gin.Context) {
+	vm, err := h.archiveRepo.Restore(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"Archived VM not found",
+			))
+		case errors.Is(err, repositories.ErrArchivedVMVAppNotFound):
+			c.JSON(http.StatusConflict, NewAPIError(
+				http.StatusConflict,
+				"Conflict",
+				"The vApp of the archived VM no longer exists",
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to restore archived VM",
+			))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, toVMResponse(*vm))
+}
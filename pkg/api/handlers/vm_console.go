@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// Serial console logs are returned up to a size limit, so a guest that floods
+// its console cannot tie up the API server
+const (
+	defaultConsoleLogBytes = 256 * 1024
+	maxConsoleLogBytes     = 4 * 1024 * 1024
+)
+
+// VMConsoleLogSource opens the guest serial console log of a VM
+type VMConsoleLogSource interface {
+	GetVMConsoleLog(ctx context.Context, namespace, vmName string, opts services.ConsoleLogOptions) (io.ReadCloser, error)
+}
+
+// SetConsoleLogs enables serial console log retrieval, normally backed by the
+// KubernetesService
+func (h *VMHandlers) SetConsoleLogs(consoleLogs VMConsoleLogSource) {
+	h.consoleLogs = consoleLogs
+}
+
+// GetVMConsoleLog handles GET /cloudapi/1.0.0/vms/{vm_id}/console/log. It
+// streams the guest serial console log from the VM's virt-launcher pod as
+// plain text, to debug boot hangs where the graphical console shows nothing.
+// The limitBytes query parameter caps the size of the log (default 256 KiB,
+// at most 4 MiB) and tailLines returns only the last lines.
+func (h *VMHandlers) GetVMConsoleLog(c *gin.Context) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	opts, err := parseConsoleLogOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid console log parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	if h.consoleLogs == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"Serial console logs are not available",
+		))
+		return
+	}
+
+	if vm.VMName == "" || vm.Namespace == "" {
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"VM is not running",
+		))
+		return
+	}
+
+	stream, err := h.consoleLogs.GetVMConsoleLog(c.Request.Context(), vm.Namespace, vm.VMName, opts)
+	switch {
+	case errors.Is(err, services.ErrVMNotRunning):
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"VM is not running",
+		))
+		return
+	case errors.Is(err, services.ErrSerialConsoleLogDisabled):
+		c.JSON(http.StatusNotFound, NewAPIError(
+			http.StatusNotFound,
+			"Not Found",
+			"Serial console logging is not enabled for the VM",
+			"Enable logSerialConsole in the KubeVirt configuration or on the VM",
+		))
+		return
+	case err != nil:
+		h.logger.Error("Failed to stream serial console log",
+			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to get serial console log",
+		))
+		return
+	}
+	defer func() { _ = stream.Close() }()
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	// Enforce the limit locally too, in case the log source ignores it
+	if _, err := io.Copy(c.Writer, io.LimitReader(stream, opts.LimitBytes)); err != nil {
+		h.logger.Warn("Serial console log stream ended early",
+			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+	}
+}
+
+// parseConsoleLogOptions reads the limitBytes and tailLines query parameters
+func parseConsoleLogOptions(c *gin.Context) (services.ConsoleLogOptions, error) {
+	opts := services.ConsoleLogOptions{LimitBytes: defaultConsoleLogBytes}
+
+	if value := c.Query("limitBytes"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			return opts, fmt.Errorf("limitBytes must be a positive integer")
+		}
+		if limit > maxConsoleLogBytes {
+			return opts, fmt.Errorf("limitBytes must be at most %d", maxConsoleLogBytes)
+		}
+		opts.LimitBytes = limit
+	}
+
+	if value := c.Query("tailLines"); value != "" {
+		lines, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || lines <= 0 {
+			return opts, fmt.Errorf("tailLines must be a positive integer")
+		}
+		opts.TailLines = &lines
+	}
+
+	return opts, nil
+}
@@ -16,52 +16,172 @@
 //  2. Validate VDC access through organization membership
 //  3. Validate catalog item access (organization or published catalogs)
 //  4. Check for name conflicts within the VDC
-//  5. Create vApp with template reference
-//  6. Return vApp details with proper VCD-compliant response format
+//  5. Create vApp with template reference, waiting while the VDC or organization
+//     has as many vApps instantiating as it may
+//  6. Hold vApps with GPUs for external approval when the approval webhook gates them
+//  7. Return vApp details with proper VCD-compliant response format
 package handlers
 
 import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"github.com/mhrivnak/ssvirt/pkg/api/apiversion"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	domainerrors "github.com/mhrivnak/ssvirt/pkg/domain/errors"
 	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
+// instantiationRetryAfter is the Retry-After sent when an instantiation is
+// refused because too many vApps are instantiating
+const instantiationRetryAfter = 30 * time.Second
+
+// namespaceWritesRetryAfter is the Retry-After sent when a request is refused
+// because the VDC namespace has too many Kubernetes writes pending
+const namespaceWritesRetryAfter = 10 * time.Second
+
+// respondNamespaceWritesSaturated writes a 429 Too Many Requests response and
+// returns true when err reports that the namespace written to is saturated
+func respondNamespaceWritesSaturated(c *gin.Context, err error) bool {
+	if !errors.Is(err, services.ErrNamespaceWritesSaturated) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(namespaceWritesRetryAfter.Seconds())))
+	c.JSON(http.StatusTooManyRequests, NewAPIError(
+		http.StatusTooManyRequests,
+		"Too Many Requests",
+		"Too many pending writes to the VDC namespace",
+		err.Error(),
+	))
+	return true
+}
+
 // VMCreationHandlers handles VM creation via template instantiation
 type VMCreationHandlers struct {
 	vdcRepo         *repositories.VDCRepository
 	vappRepo        *repositories.VAppRepository
 	catalogItemRepo *repositories.CatalogItemRepository
 	catalogRepo     *repositories.CatalogRepository
+	access          *auth.AccessControl
 	k8sService      services.KubernetesService
+	sshKeys         SSHKeyLister
+	pricing         services.Pricing
+	quota           *services.QuotaService
+	limiter         *services.InstantiationLimiter
+	metadata        models.MetadataPolicy
+	reservedNames   ReservedNamePrefixes
+	approvals       *Approvals
+	pins            CatalogPinLookup
+}
+
+// SSHKeyLister lists the SSH public keys a user has registered
+type SSHKeyLister interface {
+	ListByUserID(ctx context.Context, userID string) ([]models.SSHKey, error)
+}
+
+// CatalogPinLookup returns the pin of a Template in a VDC
+type CatalogPinLookup interface {
+	Get(ctx context.Context, vdcID, templateName string) (*models.CatalogItemPin, error)
 }
 
 // NewVMCreationHandlers creates a new VMCreationHandlers instance
-func NewVMCreationHandlers(vdcRepo *repositories.VDCRepository, vappRepo *repositories.VAppRepository, catalogItemRepo *repositories.CatalogItemRepository, catalogRepo *repositories.CatalogRepository, k8sService services.KubernetesService) *VMCreationHandlers {
+func NewVMCreationHandlers(vdcRepo *repositories.VDCRepository, vappRepo *repositories.VAppRepository, catalogItemRepo *repositories.CatalogItemRepository, catalogRepo *repositories.CatalogRepository, access *auth.AccessControl, k8sService services.KubernetesService) *VMCreationHandlers {
 	return &VMCreationHandlers{
 		vdcRepo:         vdcRepo,
 		vappRepo:        vappRepo,
 		catalogItemRepo: catalogItemRepo,
 		catalogRepo:     catalogRepo,
+		access:          access,
 		k8sService:      k8sService,
 	}
 }
 
+// SetSSHKeyStore enables injecting users' registered SSH keys into the VMs they instantiate
+func (h *VMCreationHandlers) SetSSHKeyStore(sshKeys SSHKeyLister) {
+	h.sshKeys = sshKeys
+}
+
+// SetPricing enables monthly cost estimates in instantiation responses
+func (h *VMCreationHandlers) SetPricing(pricing services.Pricing) {
+	h.pricing = pricing
+}
+
+// SetQuotaService enables checking instantiations against VDC compute limits
+func (h *VMCreationHandlers) SetQuotaService(quota *services.QuotaService) {
+	h.quota = quota
+}
+
+// SetInstantiationLimiter enables capping concurrent instantiations per VDC and organization
+func (h *VMCreationHandlers) SetInstantiationLimiter(limiter *services.InstantiationLimiter) {
+	h.limiter = limiter
+}
+
+// SetMetadataPolicy sets the labels and annotations applied to the resources of
+// every instantiated vApp. Each VDC's own metadata policy overrides them.
+func (h *VMCreationHandlers) SetMetadataPolicy(policy models.MetadataPolicy) {
+	h.metadata = policy
+}
+
+// SetReservedNamePrefixes sets the name prefixes vApps may not use
+func (h *VMCreationHandlers) SetReservedNamePrefixes(prefixes ReservedNamePrefixes) {
+	h.reservedNames = prefixes
+}
+
+// SetCatalogPins enables cloning the prewarmed images of catalog items pinned
+// for the VDC. When unset, every instantiation imports its images.
+func (h *VMCreationHandlers) SetCatalogPins(pins CatalogPinLookup) {
+	h.pins = pins
+}
+
+// SetApprovals enables holding the instantiation of vApps with GPUs for
+// external approval
+func (h *VMCreationHandlers) SetApprovals(approvals *Approvals) {
+	h.approvals = approvals
+}
+
 // InstantiateTemplateRequest represents the request body for template instantiation
 type InstantiateTemplateRequest struct {
 	Name        string      `json:"name" binding:"required"`
 	Description string      `json:"description"`
 	CatalogItem CatalogItem `json:"catalogItem" binding:"required"`
+	// InjectSSHKeys injects the caller's registered SSH public keys into the VMs via cloud-init
+	InjectSSHKeys bool `json:"injectSshKeys,omitempty"`
+	// NetworkInterfaces customize the NICs declared by the template's VMs
+	NetworkInterfaces []NetworkInterfaceRequest `json:"networkInterfaces,omitempty"`
+	// Disks select the storage tier and profile of disks declared by the template
+	Disks []DiskRequest `json:"disks,omitempty"`
+	// BackupPolicy overrides the VDC's backup policy for the vApp's VMs
+	BackupPolicy *models.BackupPolicy `json:"backupPolicy,omitempty"`
+}
+
+// NetworkInterfaceRequest pins the MAC address or selects the interface type of
+// a NIC declared by the template
+type NetworkInterfaceRequest struct {
+	Name          string               `json:"name" binding:"required"`
+	MACAddress    string               `json:"macAddress,omitempty"`
+	InterfaceType models.InterfaceType `json:"interfaceType,omitempty"`
+}
+
+// DiskRequest selects the storage tier or storage profile of a disk declared by
+// the template. Disks not listed take the tier and storage profile of the VDC's
+// default storage profile.
+type DiskRequest struct {
+	Name string          `json:"name" binding:"required"`
+	Tier models.DiskTier `json:"tier,omitempty"`
+	// StorageProfile places a persistent disk in one of the VDC's storage profiles
+	StorageProfile string `json:"storageProfile,omitempty"`
 }
 
 // CatalogItem represents a catalog item reference in the request
@@ -76,11 +196,23 @@ type VAppResponse struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Status      string `json:"status"`
+	HealthState string `json:"healthState,omitempty" since:"39.0"`
 	VDCID       string `json:"vdcId"`
 	TemplateID  string `json:"templateId,omitempty"`
 	CreatedAt   string `json:"createdAt"`
 	NumberOfVMs int    `json:"numberOfVMs"`
 	Href        string `json:"href"`
+	// Conditions report the readiness of the vApp, such as whether its
+	// TemplateInstance created its resources
+	Conditions []models.Condition `json:"conditions"`
+	// EstimatedCost is the monthly cost of the catalog item's resources, present
+	// when pricing is configured and the catalog item is known
+	EstimatedCost *services.CostEstimate `json:"estimatedCost,omitempty"`
+	// Warnings report VDC soft quotas or compute limits the instantiation crosses
+	Warnings []string `json:"warnings,omitempty"`
+	// QuotaGraceExpiresAt is when the VDC's grace allowance for exceeding its
+	// compute limits ends, present when the instantiation uses it
+	QuotaGraceExpiresAt *time.Time `json:"quotaGraceExpiresAt,omitempty"`
 }
 
 // InstantiateTemplate handles POST /cloudapi/1.0.0/vdcs/{vdc_id}/actions/instantiateTemplate
@@ -120,12 +252,8 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 
 	// Parse request body
 	var req InstantiateTemplateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, NewAPIError(
-			http.StatusBadRequest,
-			"Bad Request",
-			"Invalid request format",
-		))
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
 		return
 	}
 
@@ -138,6 +266,15 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 		))
 		return
 	}
+	if prefix, reserved := h.reservedNames.Match(req.Name); reserved {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Name uses a reserved prefix",
+			fmt.Sprintf("names starting with %q are reserved for system objects", prefix),
+		))
+		return
+	}
 
 	// Validate catalog item URN format - catalog items have special format rules
 	if !strings.HasPrefix(req.CatalogItem.ID, models.URNPrefixCatalogItem) {
@@ -172,21 +309,29 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 	}
 
 	// Validate VDC access
-	err := h.validateVDCAccess(c.Request.Context(), userClaims.UserID, vdcID)
+	accessibleVDC, err := h.access.CanAccessVDC(c.Request.Context(), userClaims.UserID, vdcID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, NewAPIError(
-				http.StatusNotFound,
-				"Not Found",
-				"VDC not found",
-			))
-		} else {
-			c.JSON(http.StatusForbidden, NewAPIError(
-				http.StatusForbidden,
-				"Forbidden",
-				"VDC access denied",
-			))
-		}
+		respondAccessError(c, err, "VDC")
+		return
+	}
+
+	networkInterfaces, ok := validateNetworkInterfaces(c, accessibleVDC, req.NetworkInterfaces)
+	if !ok {
+		return
+	}
+	if err := h.vdcRepo.LoadStorageProfiles(c.Request.Context(), accessibleVDC); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve VDC storage profiles",
+		))
+		return
+	}
+	disks, diskDefaults, ok := validateDisks(c, accessibleVDC, req.Disks)
+	if !ok {
+		return
+	}
+	if req.BackupPolicy != nil && !validateBackupPolicy(c, *req.BackupPolicy) {
 		return
 	}
 
@@ -209,6 +354,11 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 		return
 	}
 
+	sshPublicKeys, ok := h.loadSSHPublicKeys(c, userClaims.UserID, req.InjectSSHKeys)
+	if !ok {
+		return
+	}
+
 	// Check for name conflicts within VDC
 	exists, err = h.vappRepo.ExistsByNameInVDC(c.Request.Context(), vdcID, req.Name)
 	if err != nil {
@@ -231,16 +381,37 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 	// Create vApp
 	// Note: TemplateID is not set because catalog items are virtual entities
 	// that represent OpenShift templates, not database VAppTemplate records.
-	// The catalog item reference is tracked internally but not added to the description.
+	// The catalog item, template and TemplateInstance are recorded in their own columns.
 	vapp := &models.VApp{
-		Name:        req.Name,
-		Description: req.Description,
-		VDCID:       vdcID,
-		TemplateID:  nil,
-		Status:      models.VAppStatusInstantiating,
+		Name:                 req.Name,
+		Description:          req.Description,
+		VDCID:                vdcID,
+		TemplateID:           nil,
+		TemplateInstanceName: req.Name, // The TemplateInstance is named after the vApp
+		CatalogItemID:        req.CatalogItem.ID,
+		TemplateName:         req.CatalogItem.Name,
+		Status:               models.VAppStatusInstantiating,
 	}
+	vapp.SetBackupPolicy(req.BackupPolicy)
 
-	err = h.vappRepo.CreateWithContext(c.Request.Context(), vapp)
+	createVApp := func() error {
+		return h.vappRepo.CreateWithContext(c.Request.Context(), vapp)
+	}
+	if h.limiter != nil {
+		err = h.limiter.Admit(c.Request.Context(), accessibleVDC, createVApp)
+	} else {
+		err = createVApp()
+	}
+	if errors.Is(err, services.ErrInstantiationLimitReached) {
+		c.Header("Retry-After", strconv.Itoa(int(instantiationRetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, NewAPIError(
+			http.StatusTooManyRequests,
+			"Too Many Requests",
+			"Too many vApps are instantiating",
+			err.Error(),
+		))
+		return
+	}
 	if err != nil {
 		// Check if this is a unique constraint violation on the composite index
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") ||
@@ -261,6 +432,11 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 		return
 	}
 
+	// The catalog item is resolved when the URN names its catalog
+	var catalogItem *models.CatalogItem
+	// Set when the instantiation was checked against the VDC's compute limits
+	var quotaDecision *services.QuotaDecision
+
 	// Create TemplateInstance in OpenShift if k8s service is available
 	if h.k8sService != nil {
 		// Parse catalog item URN to extract catalog ID and item name
@@ -321,7 +497,6 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 		}
 
 		// Only validate catalog item for 5-part URNs (when we have a catalog ID)
-		var catalogItem *models.CatalogItem
 		if catalogID != "" {
 			var err error
 			catalogItem, err = h.catalogItemRepo.GetByID(c.Request.Context(), catalogID, itemName)
@@ -392,6 +567,60 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 			return
 		}
 
+		// vApps with GPUs may need external approval
+		if catalogItem != nil && catalogItem.Entity.NumberOfGpus > 0 && !h.approvals.Authorize(c, services.ApprovalRequest{
+			Operation:   models.TaskOperationGPUVMCreate,
+			Description: fmt.Sprintf("Instantiating vApp %s from %s", req.Name, req.CatalogItem.ID),
+			OrgID:       vdc.OrganizationID,
+			TargetID:    vdc.ID,
+			TargetName:  vdc.Name,
+			Details: map[string]interface{}{
+				"vappName":      req.Name,
+				"catalogItemId": req.CatalogItem.ID,
+				"gpus":          catalogItem.Entity.NumberOfGpus,
+			},
+		}) {
+			if cleanupErr := h.vappRepo.DeleteWithValidation(c.Request.Context(), vapp.ID, true); cleanupErr != nil {
+				// Log cleanup error but don't fail the request
+				_ = cleanupErr
+			}
+			return
+		}
+
+		// Check the catalog item's resources against the VDC's compute limits
+		if h.quota != nil && catalogItem != nil {
+			quotaDecision, err = h.quota.Check(c.Request.Context(), vdc, catalogItemUsage(catalogItem))
+			if err != nil {
+				if cleanupErr := h.vappRepo.DeleteWithValidation(c.Request.Context(), vapp.ID, true); cleanupErr != nil {
+					// Log cleanup error but don't fail the request
+					_ = cleanupErr
+				}
+				switch {
+				case errors.Is(err, services.ErrQuotaExceeded):
+					c.JSON(http.StatusForbidden, NewAPIError(
+						http.StatusForbidden,
+						"Forbidden",
+						"VDC compute quota exceeded",
+						err.Error(),
+					))
+				case errors.Is(err, services.ErrOrgQuotaExceeded):
+					c.JSON(http.StatusForbidden, NewAPIError(
+						http.StatusForbidden,
+						"Forbidden",
+						"Organization quota exceeded",
+						err.Error(),
+					))
+				default:
+					c.JSON(http.StatusInternalServerError, NewAPIError(
+						http.StatusInternalServerError,
+						"Internal Server Error",
+						"Failed to check VDC compute quota",
+					))
+				}
+				return
+			}
+		}
+
 		// Create template instance request
 		// For 4-part URNs, catalogItem will be nil, so use the name from the request
 		// For 5-part URNs, use the catalogItem.Name (which should match the request name)
@@ -399,14 +628,26 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 		if catalogItem != nil {
 			templateName = catalogItem.Name
 		}
+		vapp.TemplateName = templateName
 
+		metadata := services.MergeMetadataPolicies(h.metadata, vdc.MetadataPolicy(),
+			models.MetadataPolicy{Labels: services.BackupLabels(vapp.BackupPolicy(vdc))})
 		templateInstanceReq := &services.TemplateInstanceRequest{
-			Name:         req.Name,
-			Namespace:    vdc.Namespace, // Use the VDC's actual Kubernetes namespace
-			TemplateName: templateName,
-			Parameters:   []services.TemplateInstanceParam{}, // Empty parameters for now
+			Name:              req.Name,
+			Namespace:         vdc.Namespace, // Use the VDC's actual Kubernetes namespace
+			TemplateName:      templateName,
+			Parameters:        []services.TemplateInstanceParam{}, // Empty parameters for now
+			Labels:            metadata.Labels,
+			Annotations:       metadata.Annotations,
+			SSHPublicKeys:     sshPublicKeys,
+			NetworkInterfaces: networkInterfaces,
+			Disks:             disks,
+			DiskDefaults:      diskDefaults,
+			GoldenImages:      h.goldenImages(c.Request.Context(), vdc.ID, templateName),
 		}
 
+		recordInstantiation(vapp, templateInstanceReq)
+
 		// Create the template instance
 		_, err = h.k8sService.CreateTemplateInstance(c.Request.Context(), templateInstanceReq)
 		if err != nil {
@@ -415,6 +656,9 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 				// Log cleanup error but don't fail the request
 				_ = cleanupErr
 			}
+			if respondNamespaceWritesSaturated(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, NewAPIError(
 				http.StatusInternalServerError,
 				"Internal Server Error",
@@ -440,16 +684,196 @@ func (h *VMCreationHandlers) InstantiateTemplate(c *gin.Context) {
 
 	// Return vApp response
 	response := h.toVAppResponse(*vapp)
-	c.JSON(http.StatusCreated, response)
+	if catalogItem != nil {
+		response.EstimatedCost = h.pricing.Estimate(catalogItemUsage(catalogItem))
+	}
+	if quotaDecision != nil {
+		response.Warnings = quotaDecision.Warnings
+		response.QuotaGraceExpiresAt = quotaDecision.GraceExpiresAt
+	}
+	apiversion.JSON(c, http.StatusCreated, response)
 }
 
-// validateVDCAccess validates that a user has access to a VDC
-func (h *VMCreationHandlers) validateVDCAccess(ctx context.Context, userID, vdcID string) error {
-	_, err := h.vdcRepo.GetAccessibleVDC(ctx, userID, vdcID)
-	return err
+// goldenImages returns the prewarmed images of a Template pinned for the VDC.
+// Instantiations import the images themselves when none are ready.
+func (h *VMCreationHandlers) goldenImages(ctx context.Context, vdcID, templateName string) []string {
+	if h.pins == nil {
+		return nil
+	}
+	pin, err := h.pins.Get(ctx, vdcID, templateName)
+	if err != nil {
+		return nil
+	}
+	return pin.ReadyImageNames()
+}
+
+// catalogItemUsage returns the resources a catalog item's VMs reserve
+func catalogItemUsage(item *models.CatalogItem) services.ResourceUsage {
+	return services.ResourceUsage{
+		CPUs:         item.Entity.NumberOfCpus,
+		MemoryBytes:  item.Entity.MemoryAllocation,
+		StorageBytes: item.Entity.StorageAllocation,
+		GPUs:         item.Entity.NumberOfGpus,
+		VMs:          item.Entity.NumberOfVMs,
+	}
 }
 
 // validateCatalogItemAccess validates that a user has access to a catalog item
+// validateNetworkInterfaces checks the requested NIC settings against the VDC's
+// allowed interface types and converts them for the template instance, writing
+// a 400 for an invalid request
+func validateNetworkInterfaces(c *gin.Context, vdc *models.VDC, nics []NetworkInterfaceRequest) ([]services.NetworkInterface, bool) {
+	badRequest := func(message, details string) ([]services.NetworkInterface, bool) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			message,
+			details,
+		))
+		return nil, false
+	}
+
+	result := make([]services.NetworkInterface, 0, len(nics))
+	names := make(map[string]bool, len(nics))
+	macs := make(map[string]bool, len(nics))
+	for _, nic := range nics {
+		if nic.Name == "" {
+			return badRequest("Invalid network interface", "Network interface name is required")
+		}
+		if names[nic.Name] {
+			return badRequest("Duplicate network interface", fmt.Sprintf("Network interface '%s' is listed more than once", nic.Name))
+		}
+		names[nic.Name] = true
+
+		mac := ""
+		if nic.MACAddress != "" {
+			hw, err := net.ParseMAC(nic.MACAddress)
+			if err != nil || len(hw) != 6 || hw[0]&1 != 0 {
+				return badRequest("Invalid MAC address", fmt.Sprintf("MAC address '%s' must be a 48-bit unicast address", nic.MACAddress))
+			}
+			mac = hw.String()
+			if macs[mac] {
+				return badRequest("Duplicate MAC address", fmt.Sprintf("MAC address '%s' is assigned to more than one interface", mac))
+			}
+			macs[mac] = true
+		}
+
+		if nic.InterfaceType != "" {
+			if !nic.InterfaceType.Valid() {
+				return badRequest("Invalid interface type", fmt.Sprintf("Interface type '%s' must be one of: bridge, masquerade, sriov", nic.InterfaceType))
+			}
+			if !vdc.AllowsInterfaceType(nic.InterfaceType) {
+				return badRequest("Interface type not allowed", fmt.Sprintf("VDC does not allow interface type '%s'", nic.InterfaceType))
+			}
+		}
+
+		result = append(result, services.NetworkInterface{
+			Name:          nic.Name,
+			MACAddress:    mac,
+			InterfaceType: string(nic.InterfaceType),
+		})
+	}
+	return result, true
+}
+
+// validateDisks checks the requested disk tiers and storage profiles against the
+// VDC's storage profiles and converts them for the template instance, along
+// with the defaults of the VDC's default storage profile, writing a 400 for an
+// invalid request
+func validateDisks(c *gin.Context, vdc *models.VDC, disks []DiskRequest) ([]services.Disk, *services.DiskDefaults, bool) {
+	badRequest := func(message, details string) ([]services.Disk, *services.DiskDefaults, bool) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			message,
+			details,
+		))
+		return nil, nil, false
+	}
+
+	profiles := make(map[string]bool, len(vdc.StorageProfiles))
+	for _, profile := range vdc.StorageProfiles {
+		profiles[profile.Name] = true
+	}
+
+	result := make([]services.Disk, 0, len(disks))
+	names := make(map[string]bool, len(disks))
+	for _, disk := range disks {
+		if disk.Name == "" {
+			return badRequest("Invalid disk", "Disk name is required")
+		}
+		if names[disk.Name] {
+			return badRequest("Duplicate disk", fmt.Sprintf("Disk '%s' is listed more than once", disk.Name))
+		}
+		names[disk.Name] = true
+
+		if disk.Tier != "" && !disk.Tier.Valid() {
+			return badRequest("Invalid disk tier", fmt.Sprintf("Disk tier '%s' must be one of: ephemeral, persistent", disk.Tier))
+		}
+		if disk.StorageProfile != "" {
+			if disk.Tier == models.DiskTierEphemeral {
+				return badRequest("Invalid disk", fmt.Sprintf("Disk '%s' is ephemeral and cannot use a storage profile", disk.Name))
+			}
+			if !profiles[disk.StorageProfile] {
+				return badRequest("Storage profile not available", fmt.Sprintf("VDC has no storage profile '%s'", disk.StorageProfile))
+			}
+		}
+
+		result = append(result, services.Disk{
+			Name:         disk.Name,
+			Tier:         string(disk.Tier),
+			StorageClass: disk.StorageProfile,
+		})
+	}
+
+	var defaults *services.DiskDefaults
+	if profile := vdc.DefaultStorageProfile(); profile != nil {
+		defaults = &services.DiskDefaults{Tier: string(profile.DiskTier), StorageClass: profile.Name}
+	}
+	return result, defaults, true
+}
+
+// loadSSHPublicKeys returns the caller's registered SSH public keys when key
+// injection is requested, writing a 400 if the caller has none to inject
+func (h *VMCreationHandlers) loadSSHPublicKeys(c *gin.Context, userID string, inject bool) ([]string, bool) {
+	if !inject {
+		return nil, true
+	}
+	if h.sshKeys == nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"SSH key injection is not available",
+		))
+		return nil, false
+	}
+
+	keys, err := h.sshKeys.ListByUserID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve SSH keys",
+		))
+		return nil, false
+	}
+	if len(keys) == 0 {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"No SSH keys registered",
+			"Register an SSH key at /cloudapi/1.0.0/users/{id}/sshKeys before requesting injection",
+		))
+		return nil, false
+	}
+
+	publicKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		publicKeys = append(publicKeys, key.PublicKey)
+	}
+	return publicKeys, true
+}
+
 func (h *VMCreationHandlers) validateCatalogItemAccess(ctx context.Context, userID, catalogItemID string) error {
 	// Validate that the user has access to catalogs for template instantiation
 	// This checks if the user has access to any catalogs in their organization
@@ -462,33 +886,47 @@ func (h *VMCreationHandlers) validateCatalogItemAccess(ctx context.Context, user
 		return fmt.Errorf("failed to validate catalog access: %w", err)
 	}
 
-	// Additional validation: attempt to resolve the catalog item
-	// to ensure it exists and is accessible
-	// This is a placeholder for future catalog item resolution logic
-	// In a full implementation, this would:
-	// 1. Parse the catalog item ID to extract catalog reference
-	// 2. Verify the catalog is accessible to the user
-	// 3. Verify the template exists in that catalog
-	// 4. Use h.catalogItemRepo.GetByID() to validate existence
+	// Items in the 5-part URN format name their catalog: the catalog must be
+	// visible to the user and the item must exist. Legacy 4-part URNs carry no
+	// catalog reference and are resolved against the template namespace later.
+	catalogItemSuffix := strings.TrimPrefix(catalogItemID, models.URNPrefixCatalogItem)
+	colonIndex := strings.LastIndex(catalogItemSuffix, ":")
+	if colonIndex == -1 {
+		return nil
+	}
+	catalogID := models.URNPrefixCatalog + catalogItemSuffix[:colonIndex]
+
+	_, level, err := h.catalogRepo.GetCatalogAccess(ctx, userID, catalogID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return gorm.ErrRecordNotFound
+		}
+		return fmt.Errorf("failed to validate catalog access: %w", err)
+	}
+	if models.CatalogAccessRank(level) < models.CatalogAccessRank(models.CatalogAccessUse) {
+		return fmt.Errorf("catalog access level %s does not allow instantiation", level)
+	}
+
+	if _, err := h.catalogItemRepo.GetByID(ctx, catalogID, catalogItemID); err != nil {
+		if errors.Is(err, domainerrors.ErrNotFound) {
+			return gorm.ErrRecordNotFound
+		}
+		return fmt.Errorf("failed to resolve catalog item: %w", err)
+	}
 
 	return nil
 }
 
 // toVAppResponse converts a VApp model to VCD-compliant response format
 func (h *VMCreationHandlers) toVAppResponse(vapp models.VApp) VAppResponse {
-	templateID := ""
-	if vapp.TemplateID != nil {
-		templateID = *vapp.TemplateID
-	}
-	// Note: Catalog item references are no longer stored in description
-
 	return VAppResponse{
 		ID:          vapp.ID,
 		Name:        vapp.Name,
 		Description: vapp.Description,
 		Status:      vapp.Status,
+		HealthState: vapp.GetHealthState(),
 		VDCID:       vapp.VDCID,
-		TemplateID:  templateID,
+		TemplateID:  vappTemplateID(vapp),
 		CreatedAt:   vapp.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		NumberOfVMs: 1, // For now, each vApp has one VM
 		Href:        fmt.Sprintf("/cloudapi/1.0.0/vapps/%s", vapp.ID),
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// VMDiagnosticsSource collects the Kubernetes state behind a VM
+type VMDiagnosticsSource interface {
+	GetVMDiagnostics(ctx context.Context, namespace, vmName string) (*services.VMDiagnostics, error)
+}
+
+// VMDiagnosticsResponse is the response for GET /cloudapi/1.0.0/vms/{vm_id}/diagnostics
+type VMDiagnosticsResponse struct {
+	VMID        string `json:"vmId"`
+	Status      string `json:"status"`
+	CollectedAt string `json:"collectedAt"`
+	*services.VMDiagnostics
+}
+
+// SetDiagnostics enables VM diagnostics, normally backed by the KubernetesService
+func (h *VMHandlers) SetDiagnostics(diagnostics VMDiagnosticsSource) {
+	h.diagnostics = diagnostics
+}
+
+// GetVMDiagnostics handles GET /cloudapi/1.0.0/vms/{vm_id}/diagnostics. It
+// reports the recent Kubernetes events, conditions, scheduling failures, and
+// image pull errors of the VM's VirtualMachineInstance and virt-launcher pod,
+// so users can find out why a VM is in ERROR without cluster access.
+func (h *VMHandlers) GetVMDiagnostics(c *gin.Context) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	if h.diagnostics == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"VM diagnostics are not available",
+		))
+		return
+	}
+
+	response := VMDiagnosticsResponse{
+		VMID:        vm.ID,
+		Status:      vm.Status,
+		CollectedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if vm.VMName == "" || vm.Namespace == "" {
+		// The VirtualMachine was never created, so there is nothing to inspect
+		response.VMDiagnostics = &services.VMDiagnostics{
+			Problems:   []services.DiagnosticProblem{},
+			Conditions: []services.DiagnosticCondition{},
+			Events:     []services.DiagnosticEvent{},
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	diagnostics, err := h.diagnostics.GetVMDiagnostics(c.Request.Context(), vm.Namespace, vm.VMName)
+	if err != nil {
+		h.logger.Error("Failed to collect VM diagnostics",
+			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to collect VM diagnostics",
+		))
+		return
+	}
+	response.VMDiagnostics = diagnostics
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// VMExportRequest is the optional body of POST /cloudapi/1.0.0/vms/{vm_id}/actions/export
+type VMExportRequest struct {
+	// Format of the downloaded disks, raw or gzip; defaults to gzip
+	Format string `json:"format"`
+}
+
+// VMTaskResultRecorder stores the JSON result of VM tasks; VMTaskStore
+// implementations may implement it
+type VMTaskResultRecorder interface {
+	UpdateResult(ctx context.Context, id, result string) error
+}
+
+// SetExports enables VM exports, with download links valid for ttl and each
+// export bounded by timeout
+func (h *VMHandlers) SetExports(exports services.VMExportService, ttl, timeout time.Duration) {
+	h.exports = exports
+	h.exportTTL = ttl
+	h.exportTimeout = timeout
+}
+
+// ExportVM handles POST /cloudapi/1.0.0/vms/{vm_id}/actions/export. The VM's
+// disks are snapshotted and exported in the background under a vmExport task,
+// whose result holds the download links once it succeeds. The links embed a
+// token and expire with the export.
+func (h *VMHandlers) ExportVM(c *gin.Context) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	req := VMExportRequest{Format: services.VMExportFormatGzip}
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &req); err != nil {
+			respondBindError(c, err)
+			return
+		}
+	}
+	if !services.IsValidVMExportFormat(req.Format) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid export format",
+			fmt.Sprintf("format must be %q or %q", services.VMExportFormatRaw, services.VMExportFormatGzip),
+		))
+		return
+	}
+
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	if h.exports == nil || h.tasks == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"VM exports are not available",
+		))
+		return
+	}
+
+	if vm.VMName == "" || vm.Namespace == "" {
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"VM has no VirtualMachine to export",
+		))
+		return
+	}
+
+	task, err := h.tasks.CreateVMTask(c.Request.Context(), vm.ID, models.TaskOperationVMExport, fmt.Sprintf("Exporting VM %s", vm.Name), userClaims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to create export task",
+		))
+		return
+	}
+	publishTaskUpdate(h.eventBus, task, task.Status)
+
+	opts := services.VMExportOptions{Format: req.Format, TTL: h.exportTTL, Timeout: h.exportTimeout}
+	runInBackground(c, h.background, func(ctx context.Context) {
+		h.runVMExport(ctx, vm, task, opts)
+	})
+
+	response := toTaskResponse(task)
+	c.Header("Location", response.Href)
+	c.JSON(http.StatusAccepted, response)
+}
+
+// runVMExport exports the VM under task, storing the download links as the
+// task's result
+func (h *VMHandlers) runVMExport(ctx context.Context, vm *models.VM, task *models.Task, opts services.VMExportOptions) {
+	export, err := h.exports.ExportVM(ctx, vm.Namespace, vm.VMName, opts, func(percent int, details string) {
+		h.updateVMTask(ctx, task, models.TaskStatusRunning, percent, details)
+	})
+
+	// Record the outcome even when the export was cut short by shutdown
+	finishCtx := context.WithoutCancel(ctx)
+	if err != nil {
+		h.logger.Error("Failed to export VM", "vmID", vm.ID, "namespace", vm.Namespace, "error", err)
+		details := fmt.Sprintf("Failed to export VM: %v", err)
+		if errors.Is(err, services.ErrExportsUnavailable) {
+			details = "VM exports are not available in the cluster"
+		}
+		h.updateVMTask(finishCtx, task, models.TaskStatusError, 100, details)
+		return
+	}
+
+	result, err := json.Marshal(export)
+	if err != nil {
+		h.logger.Error("Failed to encode VM export result", "taskID", task.ID, "error", err)
+		h.updateVMTask(finishCtx, task, models.TaskStatusError, 100, "Failed to record the download links")
+		return
+	}
+	recorder, ok := h.tasks.(VMTaskResultRecorder)
+	if !ok {
+		h.updateVMTask(finishCtx, task, models.TaskStatusError, 100, "Failed to record the download links")
+		return
+	}
+	if err := recorder.UpdateResult(finishCtx, task.ID, string(result)); err != nil {
+		h.logger.Warn("Failed to store VM export result", "taskID", task.ID, "error", err)
+		h.updateVMTask(finishCtx, task, models.TaskStatusError, 100, "Failed to record the download links")
+		return
+	}
+	h.updateVMTask(finishCtx, task, models.TaskStatusSuccess, 100,
+		fmt.Sprintf("Download links expire at %s", export.ExpiresAt.UTC().Format(time.RFC3339)))
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// Number of peers returned by the network flow summary
+const (
+	defaultNetworkFlowPeers = 10
+	maxNetworkFlowPeers     = 50
+)
+
+// Scopes of the peers in a network flow summary
+const (
+	// NetworkFlowScopeOrganization is a workload in one of the VM's organization's VDCs
+	NetworkFlowScopeOrganization = "organization"
+	// NetworkFlowScopeCluster is all the workloads of other tenants and the
+	// platform, which are not identified
+	NetworkFlowScopeCluster = "cluster"
+	// NetworkFlowScopeExternal is traffic to or from outside the cluster
+	NetworkFlowScopeExternal = "external"
+)
+
+// NetworkFlowPeerResponse is a workload a VM exchanged traffic with
+type NetworkFlowPeerResponse struct {
+	Scope string `json:"scope"`
+	// Name and Kind identify organization peers only
+	Name          string `json:"name,omitempty"`
+	Kind          string `json:"kind,omitempty"`
+	BytesSent     int64  `json:"bytesSent"`
+	BytesReceived int64  `json:"bytesReceived"`
+	TotalBytes    int64  `json:"totalBytes"`
+}
+
+// VMNetworkFlowsResponse is the response for GET /cloudapi/1.0.0/vms/{vm_id}/network/flows
+type VMNetworkFlowsResponse struct {
+	VMID          string                    `json:"vmId"`
+	WindowSeconds int64                     `json:"windowSeconds"`
+	BytesSent     int64                     `json:"bytesSent"`
+	BytesReceived int64                     `json:"bytesReceived"`
+	TopTalkers    []NetworkFlowPeerResponse `json:"topTalkers"`
+	CollectedAt   string                    `json:"collectedAt"`
+}
+
+// SetNetworkFlows enables the VM network flow summary
+func (h *VMHandlers) SetNetworkFlows(flows services.NetworkFlowService) {
+	h.networkFlows = flows
+}
+
+// GetVMNetworkFlows handles GET /cloudapi/1.0.0/vms/{vm_id}/network/flows. It
+// summarizes the VM's traffic over the last hour from the cluster's flow
+// metrics, with the peers it exchanged the most bytes with. Workloads outside
+// the VM's organization are aggregated so other tenants are not disclosed. The
+// top query parameter sets how many peers are returned (default 10, at most 50).
+func (h *VMHandlers) GetVMNetworkFlows(c *gin.Context) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	top, err := parseNetworkFlowTop(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid network flow parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	if h.networkFlows == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"VM network flows are not available",
+		))
+		return
+	}
+
+	window := services.DefaultNetworkFlowWindow
+	response := VMNetworkFlowsResponse{
+		VMID:          vm.ID,
+		WindowSeconds: int64(window / time.Second),
+		TopTalkers:    []NetworkFlowPeerResponse{},
+		CollectedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if vm.VMName == "" || vm.Namespace == "" {
+		// The VirtualMachine was never created, so it has no traffic
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	summary, err := h.networkFlows.VMNetworkFlows(c.Request.Context(), vm.Namespace, vm.VMName, window)
+	if err != nil {
+		h.logger.Error("Failed to collect VM network flows",
+			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		c.JSON(http.StatusBadGateway, NewAPIError(
+			http.StatusBadGateway,
+			"Bad Gateway",
+			"Failed to query network flow metrics",
+		))
+		return
+	}
+
+	orgNamespaces := map[string]bool{vm.Namespace: true}
+	if vm.VApp != nil && vm.VApp.VDC != nil {
+		vdcs, err := h.vdcRepo.GetByOrganizationID(vm.VApp.VDC.OrganizationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to retrieve VDCs",
+			))
+			return
+		}
+		for _, vdc := range vdcs {
+			orgNamespaces[vdc.Namespace] = true
+		}
+	}
+
+	response.BytesSent = summary.BytesSent
+	response.BytesReceived = summary.BytesReceived
+	response.TopTalkers = redactNetworkFlowPeers(summary.Peers, orgNamespaces)
+	if len(response.TopTalkers) > top {
+		response.TopTalkers = response.TopTalkers[:top]
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// redactNetworkFlowPeers keeps the peers in the organization's namespaces and
+// merges the rest into one cluster peer and one external peer, busiest first
+func redactNetworkFlowPeers(peers []services.NetworkFlowPeer, orgNamespaces map[string]bool) []NetworkFlowPeerResponse {
+	redacted := make([]NetworkFlowPeerResponse, 0, len(peers))
+	cluster := NetworkFlowPeerResponse{Scope: NetworkFlowScopeCluster}
+	external := NetworkFlowPeerResponse{Scope: NetworkFlowScopeExternal}
+	for _, peer := range peers {
+		switch {
+		case peer.Namespace == "":
+			external.BytesSent += peer.BytesSent
+			external.BytesReceived += peer.BytesReceived
+		case !orgNamespaces[peer.Namespace]:
+			cluster.BytesSent += peer.BytesSent
+			cluster.BytesReceived += peer.BytesReceived
+		default:
+			// Namespaces are internal; the workload name is what tenants know
+			redacted = append(redacted, NetworkFlowPeerResponse{
+				Scope:         NetworkFlowScopeOrganization,
+				Name:          peer.Name,
+				Kind:          peer.Kind,
+				BytesSent:     peer.BytesSent,
+				BytesReceived: peer.BytesReceived,
+				TotalBytes:    peer.TotalBytes(),
+			})
+		}
+	}
+	for _, merged := range []NetworkFlowPeerResponse{cluster, external} {
+		merged.TotalBytes = merged.BytesSent + merged.BytesReceived
+		if merged.TotalBytes > 0 {
+			redacted = append(redacted, merged)
+		}
+	}
+	sort.SliceStable(redacted, func(i, j int) bool {
+		return redacted[i].TotalBytes > redacted[j].TotalBytes
+	})
+	return redacted
+}
+
+// parseNetworkFlowTop reads the top query parameter
+func parseNetworkFlowTop(c *gin.Context) (int, error) {
+	value := c.Query("top")
+	if value == "" {
+		return defaultNetworkFlowPeers, nil
+	}
+	top, err := strconv.Atoi(value)
+	if err != nil || top <= 0 {
+		return 0, fmt.Errorf("top must be a positive integer")
+	}
+	if top > maxNetworkFlowPeers {
+		return 0, fmt.Errorf("top must be at most %d", maxNetworkFlowPeers)
+	}
+	return top, nil
+}
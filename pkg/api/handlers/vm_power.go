@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -16,18 +16,46 @@ import (
 	kubevirtv1 "kubevirt.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/commands"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 )
 
 // VMRepositoryInterface defines the interface for VM repository operations
 type VMRepositoryInterface interface {
 	GetByID(id string) (*models.VM, error)
+	SetDesiredPowerState(ctx context.Context, vmID string, state string) error
+}
+
+// VMTaskCreator creates tasks that track asynchronous VM operations
+type VMTaskCreator interface {
+	CreateVMTask(ctx context.Context, vmID, name, operation, userID string) (*models.Task, error)
+}
+
+// VMTaskUpdater transitions tasks; VMTaskCreator implementations may implement it
+type VMTaskUpdater interface {
+	UpdateStatus(ctx context.Context, id, status string, progress int, details string) error
+}
+
+// VMStatusUpdater records a VM's status; VMRepositoryInterface implementations
+// may implement it
+type VMStatusUpdater interface {
+	UpdateStatus(ctx context.Context, vmID string, status string) error
+}
+
+// VMCommandDispatcher sends commands to the vm-controller
+type VMCommandDispatcher interface {
+	Dispatch(ctx context.Context, cmd commands.Command) error
 }
 
 // PowerManagementHandler handles VM power operations
 type PowerManagementHandler struct {
 	vmRepo    VMRepositoryInterface
 	k8sClient client.Client
+	tasks     VMTaskCreator
+	access    *auth.AccessControl
+	commands  VMCommandDispatcher
+	roleCache *auth.RoleCache
 	logger    *slog.Logger
 }
 
@@ -40,6 +68,67 @@ func NewPowerManagementHandler(vmRepo VMRepositoryInterface, k8sClient client.Cl
 	}
 }
 
+// SetTaskCreator enables task tracking for power operations. When unset, power
+// operations do not return a task reference.
+func (h *PowerManagementHandler) SetTaskCreator(tasks VMTaskCreator) {
+	h.tasks = tasks
+}
+
+// SetAccessControl restricts power operations to users who may manage the VM.
+// When unset, no per-VM access check is performed.
+func (h *PowerManagementHandler) SetAccessControl(access *auth.AccessControl) {
+	h.access = access
+}
+
+// SetCommandDispatcher makes power operations commands carried out by the
+// vm-controller, which completes their tasks, instead of changes the API server
+// makes to the VirtualMachine itself
+func (h *PowerManagementHandler) SetCommandDispatcher(dispatcher VMCommandDispatcher) {
+	h.commands = dispatcher
+}
+
+// SetRoleCache lets System Administrators force a power off with force=true.
+// When unset, force is refused.
+func (h *PowerManagementHandler) SetRoleCache(roleCache *auth.RoleCache) {
+	h.roleCache = roleCache
+}
+
+// authorize checks that the requesting user may manage the VM, writing an error
+// response and returning false if not
+func (h *PowerManagementHandler) authorize(c *gin.Context, vmID string) bool {
+	if h.access == nil {
+		return true
+	}
+
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return false
+	}
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return false
+	}
+
+	if _, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, auth.ErrAccessDenied) {
+			h.logger.Error("Failed to validate VM access", "vmID", vmID, "error", err)
+		}
+		respondAccessError(c, err, "VM")
+		return false
+	}
+	return true
+}
+
 // PowerOperationResponse represents the response from power operations
 type PowerOperationResponse struct {
 	ID         string `json:"id"`
@@ -47,6 +136,54 @@ type PowerOperationResponse struct {
 	Status     string `json:"status"`
 	PowerState string `json:"powerState"`
 	Href       string `json:"href"`
+	TaskID     string `json:"taskId,omitempty"`
+	TaskHref   string `json:"taskHref,omitempty"`
+}
+
+// startTask records a running task for a power operation and attaches it to the
+// response. Failures are logged but do not fail the operation, which has already
+// been applied to the cluster.
+func (h *PowerManagementHandler) startTask(c *gin.Context, response *PowerOperationResponse, name, operation string) {
+	if h.tasks == nil {
+		return
+	}
+
+	var userID string
+	if claims, exists := c.Get(auth.ClaimsContextKey); exists {
+		if userClaims, ok := claims.(*auth.Claims); ok {
+			userID = userClaims.UserID
+		}
+	}
+
+	task, err := h.tasks.CreateVMTask(c.Request.Context(), response.ID, name, operation, userID)
+	if err != nil {
+		h.logger.Warn("Failed to create task for power operation", "vmID", response.ID, "operation", name, "error", err)
+		return
+	}
+	response.TaskID = task.ID
+	response.TaskHref = fmt.Sprintf("/cloudapi/1.0.0/tasks/%s", task.ID)
+}
+
+// failTask records that the cluster did not accept a power operation, when the
+// task store can transition tasks
+func (h *PowerManagementHandler) failTask(ctx context.Context, taskID, details string) {
+	updater, ok := h.tasks.(VMTaskUpdater)
+	if !ok || taskID == "" {
+		return
+	}
+	if err := updater.UpdateStatus(ctx, taskID, models.TaskStatusError, 100, details); err != nil {
+		h.logger.Warn("Failed to fail power task", "taskID", taskID, "error", err)
+	}
+}
+
+// restoreDesiredPowerState puts back the desired power state the VM had before
+// an operation the cluster did not accept, so the vm-controller does not carry
+// the operation out later after the request failed
+func (h *PowerManagementHandler) restoreDesiredPowerState(ctx context.Context, vm *models.VM) {
+	if err := h.vmRepo.SetDesiredPowerState(ctx, vm.ID, vm.DesiredPowerState); err != nil {
+		h.logger.Error("Failed to restore desired power state",
+			"vmID", vm.ID, "desiredPowerState", vm.DesiredPowerState, "error", err)
+	}
 }
 
 // PowerOn handles VM power on requests
@@ -71,8 +208,12 @@ func (h *PowerManagementHandler) PowerOn(c *gin.Context) {
 	// Use normalized ID for Kubernetes operations (UUID only)
 	vmID := normalizedID
 
+	if !h.authorize(c, dbLookupID) {
+		return
+	}
+
 	// Defensive check for Kubernetes client
-	if h.k8sClient == nil {
+	if h.k8sClient == nil && h.commands == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"code":    503,
 			"error":   "Service Unavailable",
@@ -121,6 +262,34 @@ func (h *PowerManagementHandler) PowerOn(c *gin.Context) {
 		return
 	}
 
+	// The VM is already starting and KubeVirt retries it once the quota allows;
+	// powering it on again would not help
+	if vm.Status == models.VMStatusQuotaExceeded {
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"VM cannot start because the VDC resource quota is exceeded",
+			"Power off or delete other VMs in the VDC, or ask an administrator to raise its quota; the VM starts once the quota allows it. "+vm.StatusDetails,
+		))
+		return
+	}
+
+	if vm.RunStrategy == models.VMRunStrategyHalted {
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"VM run strategy is Halted",
+			"Change the VM's run strategy to power it on",
+		))
+		return
+	}
+
+	if h.commands != nil {
+		h.dispatchPower(c, vm, dbLookupID, commands.ActionPowerOn, "POWERING_ON",
+			models.TaskOperationVMPowerOn, fmt.Sprintf("Powering on VM %s", vm.Name))
+		return
+	}
+
 	// Get the VirtualMachine resource from Kubernetes
 	vmResource := &kubevirtv1.VirtualMachine{}
 	vmKey := types.NamespacedName{
@@ -148,37 +317,38 @@ func (h *PowerManagementHandler) PowerOn(c *gin.Context) {
 		return
 	}
 
-	// Patch the VirtualMachine spec to power on using strategic merge patch
-	runStrategy := kubevirtv1.RunStrategyAlways
-
-	// Create patch to update only the runStrategy field
-	patchData := map[string]interface{}{
-		"spec": map[string]interface{}{
-			"runStrategy": runStrategy,
-		},
-	}
-
-	patchBytes, err := json.Marshal(patchData)
-	if err != nil {
-		h.logger.Error("Failed to marshal patch data", "error", err)
+	// Record the desired state; the vm-controller changes the VirtualMachine's
+	// run strategy and keeps it there
+	if err := h.vmRepo.SetDesiredPowerState(ctx, vm.ID, models.VMPowerStateOn); err != nil {
+		h.logger.Error("Failed to set desired power state",
+			"vmID", vmID, "vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"error":   "Internal Server Error",
-			"message": "Failed to prepare VM update",
+			"message": "Failed to power on VM",
 		})
 		return
 	}
 
-	err = h.k8sClient.Patch(ctx, vmResource, client.RawPatch(types.MergePatchType, patchBytes))
-	if err != nil {
-		h.logger.Error("Failed to patch VirtualMachine run strategy",
-			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"error":   "Internal Server Error",
-			"message": "Failed to power on VM",
-		})
-		return
+	// KubeVirt does not restart a VM its guest stopped under RerunOnFailure or
+	// Manual, so start it with Always; the vm-controller sets the VM's run
+	// strategy again once it runs
+	if vm.RunStrategy == models.VMRunStrategyRerunOnFailure || vm.RunStrategy == models.VMRunStrategyManual {
+		patch := client.MergeFrom(vmResource.DeepCopy())
+		always := kubevirtv1.RunStrategyAlways
+		vmResource.Spec.RunStrategy = &always
+		vmResource.Spec.Running = nil
+		if err := h.k8sClient.Patch(ctx, vmResource, patch); err != nil {
+			h.logger.Error("Failed to start VirtualMachine",
+				"vmID", vmID, "vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+			h.restoreDesiredPowerState(ctx, vm)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"error":   "Internal Server Error",
+				"message": "Failed to power on VM",
+			})
+			return
+		}
 	}
 
 	h.logger.Info("VM power on initiated",
@@ -192,11 +362,13 @@ func (h *PowerManagementHandler) PowerOn(c *gin.Context) {
 		PowerState: "POWERING_ON",
 		Href:       fmt.Sprintf("/cloudapi/1.0.0/vms/%s", dbLookupID),
 	}
+	h.startTask(c, &response, models.TaskOperationVMPowerOn, fmt.Sprintf("Powering on VM %s", vm.Name))
 
 	c.JSON(http.StatusAccepted, response)
 }
 
-// PowerOff handles VM power off requests
+// PowerOff handles VM power off requests. System Administrators may add
+// force=true to power off a VM in any state, see forcePowerOff.
 func (h *PowerManagementHandler) PowerOff(c *gin.Context) {
 	ctx := c.Request.Context()
 	vmIDParam := c.Param("vm_id")
@@ -212,14 +384,23 @@ func (h *PowerManagementHandler) PowerOff(c *gin.Context) {
 		return
 	}
 
+	if c.Query("force") == "true" {
+		h.forcePowerOff(c, vmIDParam)
+		return
+	}
+
 	// Use the original parameter for database lookup (preserves URN format)
 	dbLookupID := vmIDParam
 
 	// Use normalized ID for Kubernetes operations (UUID only)
 	vmID := normalizedID
 
+	if !h.authorize(c, dbLookupID) {
+		return
+	}
+
 	// Defensive check for Kubernetes client
-	if h.k8sClient == nil {
+	if h.k8sClient == nil && h.commands == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"code":    503,
 			"error":   "Service Unavailable",
@@ -268,6 +449,12 @@ func (h *PowerManagementHandler) PowerOff(c *gin.Context) {
 		return
 	}
 
+	if h.commands != nil {
+		h.dispatchPower(c, vm, dbLookupID, commands.ActionPowerOff, "POWERING_OFF",
+			models.TaskOperationVMPowerOff, fmt.Sprintf("Powering off VM %s", vm.Name))
+		return
+	}
+
 	// Get the VirtualMachine resource from Kubernetes
 	vmResource := &kubevirtv1.VirtualMachine{}
 	vmKey := types.NamespacedName{
@@ -295,31 +482,11 @@ func (h *PowerManagementHandler) PowerOff(c *gin.Context) {
 		return
 	}
 
-	// Patch the VirtualMachine spec to power off using strategic merge patch
-	runStrategy := kubevirtv1.RunStrategyHalted
-
-	// Create patch to update only the runStrategy field
-	patchData := map[string]interface{}{
-		"spec": map[string]interface{}{
-			"runStrategy": runStrategy,
-		},
-	}
-
-	patchBytes, err := json.Marshal(patchData)
-	if err != nil {
-		h.logger.Error("Failed to marshal patch data", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"error":   "Internal Server Error",
-			"message": "Failed to prepare VM update",
-		})
-		return
-	}
-
-	err = h.k8sClient.Patch(ctx, vmResource, client.RawPatch(types.MergePatchType, patchBytes))
-	if err != nil {
-		h.logger.Error("Failed to patch VirtualMachine run strategy",
-			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+	// Record the desired state; the vm-controller changes the VirtualMachine's
+	// run strategy and keeps it there
+	if err := h.vmRepo.SetDesiredPowerState(ctx, vm.ID, models.VMPowerStateOff); err != nil {
+		h.logger.Error("Failed to set desired power state",
+			"vmID", vmID, "vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"error":   "Internal Server Error",
@@ -339,10 +506,309 @@ func (h *PowerManagementHandler) PowerOff(c *gin.Context) {
 		PowerState: "POWERING_OFF",
 		Href:       fmt.Sprintf("/cloudapi/1.0.0/vms/%s", dbLookupID),
 	}
+	h.startTask(c, &response, models.TaskOperationVMPowerOff, fmt.Sprintf("Powering off VM %s", vm.Name))
 
 	c.JSON(http.StatusAccepted, response)
 }
 
+// forcePowerOff stops a VM for a System Administrator regardless of its status
+// or access through its vApp. The VirtualMachine is halted and its running
+// instance deleted without a grace period; when the VirtualMachine or its
+// namespace is gone the VM is recorded as powered off directly.
+func (h *PowerManagementHandler) forcePowerOff(c *gin.Context, vmID string) {
+	ctx := c.Request.Context()
+
+	admin, err := forceOverrideAdmin(c, h.roleCache)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to verify user permissions",
+		))
+		return
+	}
+	if admin == nil {
+		c.JSON(http.StatusForbidden, NewAPIError(
+			http.StatusForbidden,
+			"Forbidden",
+			"Only System Administrators can force a power off",
+		))
+		return
+	}
+
+	if h.k8sClient == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"Kubernetes client not initialized",
+		))
+		return
+	}
+
+	vm, err := h.vmRepo.GetByID(vmID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"VM not found",
+			))
+			return
+		}
+		h.logger.Error("Failed to find VM", "vmID", vmID, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Internal server error",
+		))
+		return
+	}
+
+	auditForceOverride(c, admin, "vmPowerOff", vm.ID, vm.Name, "status", vm.Status, "namespace", vm.Namespace)
+
+	if err := h.vmRepo.SetDesiredPowerState(ctx, vm.ID, models.VMPowerStateOff); err != nil {
+		h.logger.Error("Failed to set desired power state", "vmID", vmID, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to power off VM",
+		))
+		return
+	}
+
+	response := PowerOperationResponse{
+		ID:         vmID,
+		Name:       vm.Name,
+		Status:     "POWERING_OFF",
+		PowerState: "POWERING_OFF",
+		Href:       fmt.Sprintf("/cloudapi/1.0.0/vms/%s", vmID),
+	}
+
+	halted, err := h.haltVirtualMachine(ctx, vm)
+	if err != nil {
+		h.logger.Error("Failed to force power off VirtualMachine",
+			"vmID", vmID, "vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		h.restoreDesiredPowerState(ctx, vm)
+		h.startTask(c, &response, models.TaskOperationVMPowerOff, fmt.Sprintf("Force powering off VM %s", vm.Name))
+		h.failTask(ctx, response.TaskID, fmt.Sprintf("Failed to halt VirtualMachine: %v", err))
+		if respondNamespaceWritesSaturated(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to power off VM",
+		))
+		return
+	}
+	h.startTask(c, &response, models.TaskOperationVMPowerOff, fmt.Sprintf("Force powering off VM %s", vm.Name))
+
+	if !halted {
+		// Nothing runs in the cluster, so no status change will arrive
+		if updater, ok := h.vmRepo.(VMStatusUpdater); ok {
+			if err := updater.UpdateStatus(ctx, vm.ID, "POWERED_OFF"); err != nil {
+				h.logger.Error("Failed to record forced power off", "vmID", vmID, "error", err)
+				c.JSON(http.StatusInternalServerError, NewAPIError(
+					http.StatusInternalServerError,
+					"Internal Server Error",
+					"Failed to power off VM",
+				))
+				return
+			}
+		}
+		if updater, ok := h.tasks.(VMTaskUpdater); ok && response.TaskID != "" {
+			if err := updater.UpdateStatus(ctx, response.TaskID, models.TaskStatusSuccess, 100, "VirtualMachine not found in cluster"); err != nil {
+				h.logger.Warn("Failed to complete power task", "taskID", response.TaskID, "error", err)
+			}
+		}
+		response.Status = "POWERED_OFF"
+		response.PowerState = "POWERED_OFF"
+	}
+
+	h.logger.Info("VM forced power off initiated",
+		"vmID", vmID, "vmName", vm.VMName, "namespace", vm.Namespace, "halted", halted)
+	c.JSON(http.StatusAccepted, response)
+}
+
+// haltVirtualMachine sets the VM's VirtualMachine to Halted and deletes its
+// running instance at once. It returns false when the VirtualMachine does not
+// exist, including when its namespace is gone.
+func (h *PowerManagementHandler) haltVirtualMachine(ctx context.Context, vm *models.VM) (bool, error) {
+	if vm.VMName == "" || vm.Namespace == "" {
+		return false, nil
+	}
+
+	vmResource := &kubevirtv1.VirtualMachine{}
+	if err := h.k8sClient.Get(ctx, types.NamespacedName{Name: vm.VMName, Namespace: vm.Namespace}, vmResource); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	patch := client.MergeFrom(vmResource.DeepCopy())
+	halted := kubevirtv1.RunStrategyHalted
+	vmResource.Spec.RunStrategy = &halted
+	vmResource.Spec.Running = nil
+	if err := h.k8sClient.Patch(ctx, vmResource, patch); err != nil {
+		return false, err
+	}
+
+	vmi := &kubevirtv1.VirtualMachineInstance{}
+	vmi.Name = vm.VMName
+	vmi.Namespace = vm.Namespace
+	if err := h.k8sClient.Delete(ctx, vmi, client.GracePeriodSeconds(0)); err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reboot handles VM reboot requests. The guest is asked to restart, through
+// ACPI or its guest agent, and may ignore the request.
+func (h *PowerManagementHandler) Reboot(c *gin.Context) {
+	h.restart(c, commands.ActionReboot, models.TaskOperationVMReboot, "Rebooting VM %s")
+}
+
+// Reset handles VM reset requests. The VM is restarted at once without
+// waiting for the guest to shut down.
+func (h *PowerManagementHandler) Reset(c *gin.Context) {
+	h.restart(c, commands.ActionReset, models.TaskOperationVMReset, "Resetting VM %s")
+}
+
+// restart dispatches a reboot or reset of a powered on VM to the vm-controller,
+// which completes the task. Without the controller's internal API neither can
+// be carried out.
+func (h *PowerManagementHandler) restart(c *gin.Context, action, operation, taskNameFormat string) {
+	vmID := c.Param("vm_id")
+	if _, err := parseVMIDParam(vmID); err != nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM ID format",
+		))
+		return
+	}
+
+	if !h.authorize(c, vmID) {
+		return
+	}
+
+	if h.commands == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"VM controller is unavailable",
+		))
+		return
+	}
+
+	vm, err := h.vmRepo.GetByID(vmID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, NewAPIError(
+				http.StatusNotFound,
+				"Not Found",
+				"VM not found",
+			))
+			return
+		}
+		h.logger.Error("Failed to find VM", "vmID", vmID, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Internal server error",
+		))
+		return
+	}
+
+	switch vm.Status {
+	case "POWERED_ON":
+	case "DELETING", "DELETED":
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"VM is in a conflicting state",
+		))
+		return
+	default:
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"VM is not powered on",
+		))
+		return
+	}
+
+	// The VM stays powered on; its status follows the VirtualMachine while a
+	// reset restarts it
+	h.dispatch(c, vm, vmID, action, vm.Status, operation, fmt.Sprintf(taskNameFormat, vm.Name))
+}
+
+// dispatchPower records the desired power state and sends a power command to
+// the vm-controller. The task is created first so the controller can complete
+// it once the VM reaches the new state. The desired state is recorded before
+// the command so the vm-controller does not revert the command's run strategy,
+// and is restored when the command cannot be sent.
+func (h *PowerManagementHandler) dispatchPower(c *gin.Context, vm *models.VM, vmID, action, status, operation, taskName string) {
+	desired := models.VMPowerStateOn
+	if action == commands.ActionPowerOff {
+		desired = models.VMPowerStateOff
+	}
+	if err := h.vmRepo.SetDesiredPowerState(c.Request.Context(), vm.ID, desired); err != nil {
+		h.logger.Error("Failed to set desired power state", "vmID", vmID, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Internal server error",
+		))
+		return
+	}
+
+	if !h.dispatch(c, vm, vmID, action, status, operation, taskName) {
+		h.restoreDesiredPowerState(c.Request.Context(), vm)
+	}
+}
+
+// dispatch sends a power command to the vm-controller, responding with the
+// task tracking it. It reports whether the command was sent.
+func (h *PowerManagementHandler) dispatch(c *gin.Context, vm *models.VM, vmID, action, status, operation, taskName string) bool {
+	response := PowerOperationResponse{
+		ID:         vmID,
+		Name:       vm.Name,
+		Status:     status,
+		PowerState: status,
+		Href:       fmt.Sprintf("/cloudapi/1.0.0/vms/%s", vmID),
+	}
+	h.startTask(c, &response, operation, taskName)
+
+	cmd := commands.Command{
+		ID:          uuid.NewString(),
+		Type:        commands.TypeVMPower,
+		Action:      action,
+		Namespace:   vm.Namespace,
+		Name:        vm.VMName,
+		VMID:        vmID,
+		TaskID:      response.TaskID,
+		RunStrategy: vm.RunStrategy,
+	}
+	if err := h.commands.Dispatch(c.Request.Context(), cmd); err != nil {
+		h.logger.Error("Failed to dispatch VM power command",
+			"vmID", vmID, "action", action, "vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		h.failTask(c.Request.Context(), response.TaskID, "VM controller is unavailable")
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"VM controller is unavailable",
+		))
+		return false
+	}
+
+	h.logger.Info("VM power command dispatched",
+		"vmID", vmID, "action", action, "commandID", cmd.ID, "vmName", vm.VMName, "namespace", vm.Namespace)
+	c.JSON(http.StatusAccepted, response)
+	return true
+}
+
 // parseVMIDParam normalizes VM ID parameter from URN or hyphenless format to canonical UUID
 func parseVMIDParam(param string) (string, error) {
 	// Handle URN format: urn:vcloud:vm:{uuid}
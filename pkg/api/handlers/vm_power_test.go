@@ -16,12 +16,16 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	kubevirtv1 "kubevirt.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/commands"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 )
 
@@ -38,6 +42,16 @@ func (m *MockVMRepository) GetByID(id string) (*models.VM, error) {
 	return nil, args.Error(1)
 }
 
+func (m *MockVMRepository) SetDesiredPowerState(ctx context.Context, vmID string, state string) error {
+	args := m.Called(ctx, vmID, state)
+	return args.Error(0)
+}
+
+func (m *MockVMRepository) UpdateStatus(ctx context.Context, vmID string, status string) error {
+	args := m.Called(ctx, vmID, status)
+	return args.Error(0)
+}
+
 func setupTest() (*gin.Engine, *MockVMRepository, client.Client) {
 	gin.SetMode(gin.TestMode)
 
@@ -90,6 +104,7 @@ func TestPowerOnHandler_Success(t *testing.T) {
 
 	// Setup mock expectations - expect the URN format as stored in database
 	mockRepo.On("GetByID", vmURN).Return(vm, nil)
+	mockRepo.On("SetDesiredPowerState", mock.Anything, vmURN, models.VMPowerStateOn).Return(nil)
 
 	// Make request
 	req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/powerOn", vmURN), bytes.NewBuffer([]byte("{}")))
@@ -108,6 +123,37 @@ func TestPowerOnHandler_Success(t *testing.T) {
 	assert.Equal(t, "POWERING_ON", response.Status)
 	assert.Equal(t, "POWERING_ON", response.PowerState)
 
+	// The vm-controller changes the run strategy toward the desired state
+	assert.NoError(t, k8sClient.Get(context.Background(), client.ObjectKeyFromObject(vmResource), vmResource))
+	assert.Equal(t, kubevirtv1.RunStrategyHalted, *vmResource.Spec.RunStrategy)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPowerOnHandler_DesiredStateNotSaved(t *testing.T) {
+	router, mockRepo, k8sClient := setupTest()
+
+	vmURN := fmt.Sprintf("urn:vcloud:vm:%s", uuid.New().String())
+	vm := &models.VM{
+		ID:        vmURN,
+		Name:      "test-vm",
+		VMName:    "test-vm",
+		Namespace: "test-namespace",
+		Status:    "POWERED_OFF",
+	}
+	assert.NoError(t, k8sClient.Create(context.Background(), &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"},
+	}))
+
+	mockRepo.On("GetByID", vmURN).Return(vm, nil)
+	mockRepo.On("SetDesiredPowerState", mock.Anything, vmURN, models.VMPowerStateOn).Return(errors.New("database connection failed"))
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/powerOn", vmURN), bytes.NewBuffer([]byte("{}")))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	mockRepo.AssertExpectations(t)
 }
 
@@ -170,6 +216,84 @@ func TestPowerOnHandler_VMAlreadyPoweredOn(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestPowerOnHandler_QuotaExceeded(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	vmID := uuid.New().String()
+	mockRepo.On("GetByID", vmID).Return(&models.VM{
+		ID:            vmID,
+		Name:          "test-vm",
+		Status:        models.VMStatusQuotaExceeded,
+		StatusDetails: "exceeded quota: vdc-quota, requested: requests.memory=2Gi, used: requests.memory=7Gi, limited: requests.memory=8Gi",
+	}, nil)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/powerOn", vmID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var response APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "VM cannot start because the VDC resource quota is exceeded", response.Message)
+	assert.Contains(t, response.Details, "limited: requests.memory=8Gi")
+	mockRepo.AssertNotCalled(t, "SetDesiredPowerState", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPowerOnHandler_RunStrategy(t *testing.T) {
+	t.Run("Halted VMs cannot be powered on", func(t *testing.T) {
+		router, mockRepo, _ := setupTest()
+
+		vmID := uuid.New().String()
+		mockRepo.On("GetByID", vmID).Return(&models.VM{
+			ID:          vmID,
+			Name:        "test-vm",
+			Status:      "POWERED_OFF",
+			RunStrategy: models.VMRunStrategyHalted,
+		}, nil)
+
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/powerOn", vmID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		var response APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "VM run strategy is Halted", response.Message)
+		mockRepo.AssertNotCalled(t, "SetDesiredPowerState", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("VMs stopped by their guest are started with Always", func(t *testing.T) {
+		router, mockRepo, k8sClient := setupTest()
+
+		vmURN := fmt.Sprintf("urn:vcloud:vm:%s", uuid.New().String())
+		vmResource := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"},
+			Spec: kubevirtv1.VirtualMachineSpec{
+				RunStrategy: &[]kubevirtv1.VirtualMachineRunStrategy{kubevirtv1.RunStrategyRerunOnFailure}[0],
+			},
+		}
+		assert.NoError(t, k8sClient.Create(context.Background(), vmResource))
+		mockRepo.On("GetByID", vmURN).Return(&models.VM{
+			ID:          vmURN,
+			Name:        "test-vm",
+			VMName:      "test-vm",
+			Namespace:   "test-namespace",
+			Status:      "POWERED_OFF",
+			RunStrategy: models.VMRunStrategyRerunOnFailure,
+		}, nil)
+		mockRepo.On("SetDesiredPowerState", mock.Anything, vmURN, models.VMPowerStateOn).Return(nil)
+
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/powerOn", vmURN), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.NoError(t, k8sClient.Get(context.Background(), client.ObjectKeyFromObject(vmResource), vmResource))
+		assert.Equal(t, kubevirtv1.RunStrategyAlways, *vmResource.Spec.RunStrategy)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
 func TestPowerOnHandler_ConflictingState(t *testing.T) {
 	router, mockRepo, _ := setupTest()
 
@@ -284,6 +408,7 @@ func TestPowerOnHandler_ValidURN(t *testing.T) {
 
 	// Setup mock expectations - expect the URN format as stored in database
 	mockRepo.On("GetByID", vmURN).Return(vm, nil)
+	mockRepo.On("SetDesiredPowerState", mock.Anything, vmURN, models.VMPowerStateOn).Return(nil)
 
 	// Make request with VM URN format
 	req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/powerOn", vmURN), bytes.NewBuffer([]byte("{}")))
@@ -335,6 +460,7 @@ func TestPowerOffHandler_Success(t *testing.T) {
 
 	// Setup mock expectations - expect the URN format as stored in database
 	mockRepo.On("GetByID", vmURN).Return(vm, nil)
+	mockRepo.On("SetDesiredPowerState", mock.Anything, vmURN, models.VMPowerStateOff).Return(nil)
 
 	// Make request
 	req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/powerOff", vmURN), bytes.NewBuffer([]byte("{}")))
@@ -415,6 +541,126 @@ func TestPowerOffHandler_DatabaseError(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// rolesLoader returns users with fixed roles for the role cache
+type rolesLoader map[string]*models.User
+
+func (l rolesLoader) GetWithRoles(id string) (*models.User, error) {
+	if user, ok := l[id]; ok {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func TestPowerOffHandler_Force(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+
+	admin := &models.User{ID: "admin-id", Username: "admin", Roles: []models.Role{{Name: models.RoleSystemAdmin}}}
+	tenant := &models.User{ID: "tenant-id", Username: "tenant"}
+
+	setup := func(userID string, objs ...client.Object) (*gin.Engine, *MockVMRepository, client.Client) {
+		mockRepo := new(MockVMRepository)
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+		handler := NewPowerManagementHandler(mockRepo, k8sClient, slog.Default())
+		handler.SetRoleCache(auth.NewRoleCache(rolesLoader{admin.ID: admin, tenant.ID: tenant}, 0))
+
+		router := gin.New()
+		router.POST("/cloudapi/1.0.0/vms/:vm_id/actions/powerOff", func(c *gin.Context) {
+			c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: userID})
+			handler.PowerOff(c)
+		})
+		return router, mockRepo, k8sClient
+	}
+	powerOff := func(router *gin.Engine, vmID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/powerOff?force=true", vmID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	vmID := fmt.Sprintf("urn:vcloud:vm:%s", uuid.New().String())
+
+	t.Run("Tenants cannot force a power off", func(t *testing.T) {
+		router, mockRepo, _ := setup(tenant.ID)
+		w := powerOff(router, vmID)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("The VirtualMachine is halted whatever the VM status", func(t *testing.T) {
+		vmResource := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"},
+			Spec: kubevirtv1.VirtualMachineSpec{
+				RunStrategy: &[]kubevirtv1.VirtualMachineRunStrategy{kubevirtv1.RunStrategyAlways}[0],
+			},
+		}
+		vmi := &kubevirtv1.VirtualMachineInstance{ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"}}
+		router, mockRepo, k8sClient := setup(admin.ID, vmResource, vmi)
+		vm := &models.VM{ID: vmID, Name: "test-vm", VMName: "test-vm", Namespace: "test-namespace", Status: "DELETING"}
+		mockRepo.On("GetByID", vmID).Return(vm, nil)
+		mockRepo.On("SetDesiredPowerState", mock.Anything, vmID, models.VMPowerStateOff).Return(nil)
+
+		w := powerOff(router, vmID)
+		assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var response PowerOperationResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "POWERING_OFF", response.Status)
+
+		assert.NoError(t, k8sClient.Get(context.Background(), client.ObjectKeyFromObject(vmResource), vmResource))
+		assert.Equal(t, kubevirtv1.RunStrategyHalted, *vmResource.Spec.RunStrategy)
+		err := k8sClient.Get(context.Background(), client.ObjectKeyFromObject(vmi), &kubevirtv1.VirtualMachineInstance{})
+		assert.True(t, k8serrors.IsNotFound(err))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("A failed halt restores the desired power state and fails the task", func(t *testing.T) {
+		vmResource := &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"}}
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vmResource).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					return errors.New("admission webhook denied the request")
+				},
+			}).Build()
+		mockRepo := new(MockVMRepository)
+		handler := NewPowerManagementHandler(mockRepo, k8sClient, slog.Default())
+		handler.SetRoleCache(auth.NewRoleCache(rolesLoader{admin.ID: admin}, 0))
+		tasks := &recordingTasks{statuses: map[string]string{}}
+		handler.SetTaskCreator(tasks)
+		router := gin.New()
+		router.POST("/cloudapi/1.0.0/vms/:vm_id/actions/powerOff", func(c *gin.Context) {
+			c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: admin.ID})
+			handler.PowerOff(c)
+		})
+
+		vm := &models.VM{ID: vmID, Name: "test-vm", VMName: "test-vm", Namespace: "test-namespace",
+			Status: "POWERED_ON", DesiredPowerState: models.VMPowerStateOn}
+		mockRepo.On("GetByID", vmID).Return(vm, nil)
+		mockRepo.On("SetDesiredPowerState", mock.Anything, vmID, models.VMPowerStateOff).Return(nil).Once()
+		mockRepo.On("SetDesiredPowerState", mock.Anything, vmID, models.VMPowerStateOn).Return(nil).Once()
+
+		w := powerOff(router, vmID)
+		assert.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+		assert.Equal(t, models.TaskStatusError, tasks.statuses["task-1"])
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("A VM whose VirtualMachine is gone is recorded as powered off", func(t *testing.T) {
+		router, mockRepo, _ := setup(admin.ID)
+		vm := &models.VM{ID: vmID, Name: "test-vm", VMName: "test-vm", Namespace: "deleted-namespace", Status: "POWERED_ON"}
+		mockRepo.On("GetByID", vmID).Return(vm, nil)
+		mockRepo.On("SetDesiredPowerState", mock.Anything, vmID, models.VMPowerStateOff).Return(nil)
+		mockRepo.On("UpdateStatus", mock.Anything, vmID, "POWERED_OFF").Return(nil)
+
+		w := powerOff(router, vmID)
+		assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var response PowerOperationResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "POWERED_OFF", response.Status)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
 func TestIsValidUUID(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -521,3 +767,153 @@ func TestFormatVMURN(t *testing.T) {
 	result := formatVMURN(vmID)
 	assert.Equal(t, expected, result)
 }
+
+type recordingDispatcher struct {
+	commands []commands.Command
+	err      error
+}
+
+func (d *recordingDispatcher) Dispatch(ctx context.Context, cmd commands.Command) error {
+	d.commands = append(d.commands, cmd)
+	return d.err
+}
+
+type recordingTasks struct {
+	statuses map[string]string
+}
+
+func (r *recordingTasks) CreateVMTask(ctx context.Context, vmID, name, operation, userID string) (*models.Task, error) {
+	id := fmt.Sprintf("task-%d", len(r.statuses)+1)
+	r.statuses[id] = models.TaskStatusRunning
+	return &models.Task{ID: id, Name: name, OwnerID: vmID}, nil
+}
+
+func (r *recordingTasks) UpdateStatus(ctx context.Context, id, status string, progress int, details string) error {
+	r.statuses[id] = status
+	return nil
+}
+
+func TestPowerHandlers_CommandDispatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := new(MockVMRepository)
+	// Without a Kubernetes client the power change can only be dispatched
+	handler := NewPowerManagementHandler(mockRepo, nil, slog.Default())
+	tasks := &recordingTasks{statuses: map[string]string{}}
+	handler.SetTaskCreator(tasks)
+	dispatcher := &recordingDispatcher{}
+	handler.SetCommandDispatcher(dispatcher)
+
+	router := gin.New()
+	router.POST("/cloudapi/1.0.0/vms/:vm_id/actions/powerOn", handler.PowerOn)
+	router.POST("/cloudapi/1.0.0/vms/:vm_id/actions/powerOff", handler.PowerOff)
+
+	vmURN := fmt.Sprintf("urn:vcloud:vm:%s", uuid.New().String())
+	mockRepo.On("GetByID", vmURN).Return(&models.VM{
+		ID:                vmURN,
+		Name:              "test-vm",
+		VMName:            "test-vm",
+		Namespace:         "test-namespace",
+		Status:            "POWERED_OFF",
+		DesiredPowerState: models.VMPowerStateOff,
+		RunStrategy:       models.VMRunStrategyManual,
+	}, nil)
+	mockRepo.On("SetDesiredPowerState", mock.Anything, vmURN, models.VMPowerStateOn).Return(nil)
+
+	post := func(action string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/%s", vmURN, action), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("powerOn")
+	assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response PowerOperationResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "POWERING_ON", response.Status)
+	assert.Equal(t, "task-1", response.TaskID)
+
+	if assert.Len(t, dispatcher.commands, 1) {
+		cmd := dispatcher.commands[0]
+		assert.NotEmpty(t, cmd.ID)
+		assert.Equal(t, commands.TypeVMPower, cmd.Type)
+		assert.Equal(t, commands.ActionPowerOn, cmd.Action)
+		assert.Equal(t, "test-namespace", cmd.Namespace)
+		assert.Equal(t, "test-vm", cmd.Name)
+		assert.Equal(t, vmURN, cmd.VMID)
+		assert.Equal(t, "task-1", cmd.TaskID)
+		assert.Equal(t, models.VMRunStrategyManual, cmd.RunStrategy)
+	}
+	// The task stays running until the controller reports the result
+	assert.Equal(t, models.TaskStatusRunning, tasks.statuses["task-1"])
+
+	// An unreachable controller fails the request and its task, and the
+	// previous desired power state is restored
+	dispatcher.err = errors.New("connection refused")
+	mockRepo.On("SetDesiredPowerState", mock.Anything, vmURN, models.VMPowerStateOff).Return(nil).Once()
+	w = post("powerOn")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, models.TaskStatusError, tasks.statuses["task-2"])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestartHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockRepo := new(MockVMRepository)
+	handler := NewPowerManagementHandler(mockRepo, nil, slog.Default())
+	tasks := &recordingTasks{statuses: map[string]string{}}
+	handler.SetTaskCreator(tasks)
+
+	router := gin.New()
+	router.POST("/cloudapi/1.0.0/vms/:vm_id/actions/reboot", handler.Reboot)
+	router.POST("/cloudapi/1.0.0/vms/:vm_id/actions/reset", handler.Reset)
+
+	vmURN := fmt.Sprintf("urn:vcloud:vm:%s", uuid.New().String())
+	stoppedURN := fmt.Sprintf("urn:vcloud:vm:%s", uuid.New().String())
+	mockRepo.On("GetByID", vmURN).Return(&models.VM{
+		ID:        vmURN,
+		Name:      "test-vm",
+		VMName:    "test-vm",
+		Namespace: "test-namespace",
+		Status:    "POWERED_ON",
+	}, nil)
+	mockRepo.On("GetByID", stoppedURN).Return(&models.VM{ID: stoppedURN, Status: "POWERED_OFF"}, nil)
+
+	post := func(vmID, action string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/cloudapi/1.0.0/vms/%s/actions/%s", vmID, action), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Restarts are only carried out by the vm-controller
+	assert.Equal(t, http.StatusServiceUnavailable, post(vmURN, "reboot").Code)
+
+	dispatcher := &recordingDispatcher{}
+	handler.SetCommandDispatcher(dispatcher)
+
+	for i, action := range []string{commands.ActionReboot, commands.ActionReset} {
+		w := post(vmURN, action)
+		assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var response PowerOperationResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "POWERED_ON", response.PowerState)
+		taskID := fmt.Sprintf("task-%d", i+1)
+		assert.Equal(t, taskID, response.TaskID)
+		assert.Equal(t, models.TaskStatusRunning, tasks.statuses[taskID])
+
+		if assert.Len(t, dispatcher.commands, i+1) {
+			cmd := dispatcher.commands[i]
+			assert.Equal(t, commands.TypeVMPower, cmd.Type)
+			assert.Equal(t, action, cmd.Action)
+			assert.Equal(t, taskID, cmd.TaskID)
+			assert.NoError(t, cmd.Validate())
+		}
+	}
+
+	// The desired power state is unchanged
+	mockRepo.AssertNotCalled(t, "SetDesiredPowerState", mock.Anything, mock.Anything, mock.Anything)
+
+	assert.Equal(t, http.StatusBadRequest, post(stoppedURN, "reset").Code)
+	assert.Len(t, dispatcher.commands, 2)
+}
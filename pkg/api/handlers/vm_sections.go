@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// Resource types of virtual hardware items, from the DMTF CIM_ResourceAllocationSettingData
+// schema that VCD uses for the VirtualHardwareSection
+const (
+	ResourceTypeProcessor       = 3
+	ResourceTypeMemory          = 4
+	ResourceTypeEthernetAdapter = 10
+	ResourceTypeCDDrive         = 15
+	ResourceTypeDiskDrive       = 17
+)
+
+// Allocation units of the processor and memory items
+const (
+	allocationUnitsCPU    = "hertz * 10^6"
+	allocationUnitsMemory = "byte * 2^20"
+)
+
+// VirtualHardwareSection describes a VM's virtual hardware as VCD does: one
+// item per processor, memory, disk and network adapter
+type VirtualHardwareSection struct {
+	Info  string                `json:"info"`
+	Items []VirtualHardwareItem `json:"item"`
+	Href  string                `json:"href"`
+}
+
+// VirtualHardwareItem is one item of a VirtualHardwareSection
+type VirtualHardwareItem struct {
+	InstanceID      int    `json:"instanceID"`
+	ResourceType    int    `json:"resourceType"`
+	ResourceSubType string `json:"resourceSubType,omitempty"`
+	ElementName     string `json:"elementName"`
+	Description     string `json:"description,omitempty"`
+	AllocationUnits string `json:"allocationUnits,omitempty"`
+	VirtualQuantity int64  `json:"virtualQuantity,omitempty"`
+	// CoresPerSocket is set on the processor item
+	CoresPerSocket int `json:"coresPerSocket,omitempty"`
+	// Address is the MAC address of a network adapter
+	Address string `json:"address,omitempty"`
+	// AddressOnParent orders disks and network adapters
+	AddressOnParent int `json:"addressOnParent"`
+	// Connection names the network of a network adapter
+	Connection string `json:"connection,omitempty"`
+	// AutomaticAllocation reports whether a network adapter is connected
+	AutomaticAllocation *bool `json:"automaticAllocation,omitempty"`
+	// CapacityMB is the size of a disk
+	CapacityMB int64 `json:"capacityMB,omitempty"`
+}
+
+// GuestCustomizationSection describes how the guest is customized on first
+// boot. VMs are customized with cloud-init, so the Windows-specific fields of
+// VCD are always disabled.
+type GuestCustomizationSection struct {
+	Info                  string `json:"info"`
+	Enabled               bool   `json:"enabled"`
+	ChangeSid             bool   `json:"changeSid"`
+	VirtualMachineID      string `json:"virtualMachineId"`
+	JoinDomainEnabled     bool   `json:"joinDomainEnabled"`
+	UseOrgSettings        bool   `json:"useOrgSettings"`
+	AdminPasswordEnabled  bool   `json:"adminPasswordEnabled"`
+	AdminPasswordAuto     bool   `json:"adminPasswordAuto"`
+	AdminAutoLogonEnabled bool   `json:"adminAutoLogonEnabled"`
+	ResetPasswordRequired bool   `json:"resetPasswordRequired"`
+	ComputerName          string `json:"computerName"`
+	Href                  string `json:"href"`
+}
+
+// GetVirtualHardwareSection handles GET /cloudapi/1.0.0/vms/{vm_id}/virtualHardwareSection
+func (h *VMHandlers) GetVirtualHardwareSection(c *gin.Context) {
+	vm, ok := h.sectionVM(c)
+	if !ok {
+		return
+	}
+	response := toVMResponse(*vm)
+	h.addSections(c.Request.Context(), vm, &response)
+	c.JSON(http.StatusOK, response.VirtualHardwareSection)
+}
+
+// GetGuestCustomizationSection handles GET /cloudapi/1.0.0/vms/{vm_id}/guestCustomizationSection
+func (h *VMHandlers) GetGuestCustomizationSection(c *gin.Context) {
+	vm, ok := h.sectionVM(c)
+	if !ok {
+		return
+	}
+	response := toVMResponse(*vm)
+	h.addSections(c.Request.Context(), vm, &response)
+	c.JSON(http.StatusOK, response.GuestCustomizationSection)
+}
+
+// sectionVM returns the VM named by the request if the user may manage it,
+// writing an error response otherwise
+func (h *VMHandlers) sectionVM(c *gin.Context) (*models.VM, bool) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return nil, false
+	}
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return nil, false
+	}
+
+	vmID := c.Param("vm_id")
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return nil, false
+	}
+
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return nil, false
+	}
+	return vm, true
+}
+
+// addSections fills in the VM's hardware and guest customization sections from
+// the database and, when it can be read, the live VirtualMachine spec
+func (h *VMHandlers) addSections(ctx context.Context, vm *models.VM, response *VMResponse) {
+	var vmResource *kubevirtv1.VirtualMachine
+	if h.k8sClient != nil && vm.VMName != "" && vm.Namespace != "" {
+		vmResource = &kubevirtv1.VirtualMachine{}
+		key := types.NamespacedName{Name: vm.VMName, Namespace: vm.Namespace}
+		if err := h.k8sClient.Get(ctx, key, vmResource); err != nil {
+			h.logger.Debug("VirtualMachine unavailable for VM sections", "vmID", vm.ID, "error", err)
+			vmResource = nil
+		}
+	}
+
+	response.VirtualHardwareSection = buildVirtualHardwareSection(vm, response.Hardware, vmResource)
+	response.GuestCustomizationSection = buildGuestCustomizationSection(vm, vmResource)
+}
+
+// buildVirtualHardwareSection lists the processor and memory of the VM and,
+// from the VirtualMachine spec, its disks and network adapters. The spec's CPU
+// topology and memory take precedence over the values stored in the database.
+func buildVirtualHardwareSection(vm *models.VM, hardware HardwareInfo, vmResource *kubevirtv1.VirtualMachine) *VirtualHardwareSection {
+	numCPUs, coresPerSocket, memoryMB := hardware.NumCPUs, hardware.NumCoresPerSocket, int64(hardware.MemoryMB)
+
+	var spec *kubevirtv1.VirtualMachineInstanceSpec
+	if vmResource != nil && vmResource.Spec.Template != nil {
+		spec = &vmResource.Spec.Template.Spec
+		if cpu := spec.Domain.CPU; cpu != nil {
+			sockets, cores, threads := max(cpu.Sockets, 1), max(cpu.Cores, 1), max(cpu.Threads, 1)
+			numCPUs, coresPerSocket = int(sockets*cores*threads), int(cores)
+		}
+		if memory := guestMemory(spec); memory != nil {
+			memoryMB = memory.Value() / (1024 * 1024)
+		}
+	}
+
+	section := &VirtualHardwareSection{
+		Info: "Virtual hardware requirements",
+		Href: fmt.Sprintf("/cloudapi/1.0.0/vms/%s/virtualHardwareSection", vm.ID),
+	}
+	instanceID := 1
+	add := func(item VirtualHardwareItem) {
+		item.InstanceID = instanceID
+		instanceID++
+		section.Items = append(section.Items, item)
+	}
+
+	add(VirtualHardwareItem{
+		ResourceType:    ResourceTypeProcessor,
+		ElementName:     fmt.Sprintf("%d virtual CPU(s)", numCPUs),
+		Description:     "Number of Virtual CPUs",
+		AllocationUnits: allocationUnitsCPU,
+		VirtualQuantity: int64(numCPUs),
+		CoresPerSocket:  coresPerSocket,
+	})
+	add(VirtualHardwareItem{
+		ResourceType:    ResourceTypeMemory,
+		ElementName:     fmt.Sprintf("%d MB of memory", memoryMB),
+		Description:     "Memory Size",
+		AllocationUnits: allocationUnitsMemory,
+		VirtualQuantity: memoryMB,
+	})
+	if spec == nil {
+		return section
+	}
+
+	capacities := diskCapacities(vmResource)
+	for i, disk := range spec.Domain.Devices.Disks {
+		item := VirtualHardwareItem{
+			ResourceType:    ResourceTypeDiskDrive,
+			ElementName:     disk.Name,
+			Description:     "Hard disk",
+			AddressOnParent: i,
+			CapacityMB:      capacities[disk.Name],
+		}
+		switch {
+		case disk.CDRom != nil:
+			item.ResourceType = ResourceTypeCDDrive
+			item.Description = "CD/DVD drive"
+			item.ResourceSubType = string(disk.CDRom.Bus)
+		case disk.Disk != nil:
+			item.ResourceSubType = string(disk.Disk.Bus)
+		}
+		add(item)
+	}
+
+	networks := make(map[string]string, len(spec.Networks))
+	for _, network := range spec.Networks {
+		switch {
+		case network.Multus != nil:
+			networks[network.Name] = network.Multus.NetworkName
+		case network.Pod != nil:
+			networks[network.Name] = "pod"
+		}
+	}
+	for i, iface := range spec.Domain.Devices.Interfaces {
+		connected := iface.State != kubevirtv1.InterfaceStateLinkDown && iface.State != kubevirtv1.InterfaceStateAbsent
+		add(VirtualHardwareItem{
+			ResourceType:        ResourceTypeEthernetAdapter,
+			ResourceSubType:     iface.Model,
+			ElementName:         iface.Name,
+			Description:         "Network adapter",
+			Address:             iface.MacAddress,
+			AddressOnParent:     i,
+			Connection:          networks[iface.Name],
+			AutomaticAllocation: &connected,
+		})
+	}
+	return section
+}
+
+// guestMemory returns the memory the guest sees, or nil when the spec does not set it
+func guestMemory(spec *kubevirtv1.VirtualMachineInstanceSpec) *resource.Quantity {
+	if memory := spec.Domain.Memory; memory != nil && memory.Guest != nil {
+		return memory.Guest
+	}
+	if request, ok := spec.Domain.Resources.Requests[corev1.ResourceMemory]; ok {
+		return &request
+	}
+	return nil
+}
+
+// diskCapacities returns the size in MB of each disk backed by a DataVolume template
+func diskCapacities(vmResource *kubevirtv1.VirtualMachine) map[string]int64 {
+	templates := make(map[string]int64, len(vmResource.Spec.DataVolumeTemplates))
+	for _, dvt := range vmResource.Spec.DataVolumeTemplates {
+		var size resource.Quantity
+		var ok bool
+		switch {
+		case dvt.Spec.Storage != nil:
+			size, ok = dvt.Spec.Storage.Resources.Requests[corev1.ResourceStorage]
+		case dvt.Spec.PVC != nil:
+			size, ok = dvt.Spec.PVC.Resources.Requests[corev1.ResourceStorage]
+		}
+		if ok {
+			templates[dvt.Name] = size.Value() / (1024 * 1024)
+		}
+	}
+
+	capacities := make(map[string]int64)
+	for _, volume := range vmResource.Spec.Template.Spec.Volumes {
+		if volume.DataVolume != nil {
+			if size, ok := templates[volume.DataVolume.Name]; ok {
+				capacities[volume.Name] = size
+			}
+		}
+	}
+	return capacities
+}
+
+// buildGuestCustomizationSection reports whether the VM is customized with
+// cloud-init and its computer name. User data is not returned because it
+// commonly holds credentials.
+func buildGuestCustomizationSection(vm *models.VM, vmResource *kubevirtv1.VirtualMachine) *GuestCustomizationSection {
+	section := &GuestCustomizationSection{
+		Info:             "Specifies Guest OS Customization Settings",
+		VirtualMachineID: vm.ID,
+		ComputerName:     vm.VMName,
+		Href:             fmt.Sprintf("/cloudapi/1.0.0/vms/%s/guestCustomizationSection", vm.ID),
+	}
+	if vmResource == nil || vmResource.Spec.Template == nil {
+		return section
+	}
+
+	spec := vmResource.Spec.Template.Spec
+	if spec.Hostname != "" {
+		section.ComputerName = spec.Hostname
+	}
+	for _, volume := range spec.Volumes {
+		if volume.CloudInitNoCloud != nil || volume.CloudInitConfigDrive != nil {
+			section.Enabled = true
+			break
+		}
+	}
+	return section
+}
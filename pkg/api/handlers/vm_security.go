@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// VMSecurityProfileResponse is the response for GET /cloudapi/1.0.0/vms/{vm_id}/securityProfile
+type VMSecurityProfileResponse struct {
+	VMID        string `json:"vmId"`
+	CollectedAt string `json:"collectedAt"`
+	// Policy is the organization's security policy, or the one it inherits,
+	// the items were checked against; without one every item is allowed
+	Policy *models.OrgSecurityPolicy `json:"policy,omitempty"`
+	*services.VMSecurityProfile
+}
+
+// SetSecurityProfiles enables VM security profiles, read from the cluster.
+// orgRepo resolves the policies organizations inherit from their ancestors.
+func (h *VMHandlers) SetSecurityProfiles(security services.VMSecurityService, orgRepo *repositories.OrganizationRepository) {
+	h.security = security
+	h.orgRepo = orgRepo
+}
+
+// GetVMSecurityProfile handles GET /cloudapi/1.0.0/vms/{vm_id}/securityProfile.
+// It lists the privileged devices, host-passthrough CPU model, host network
+// and custom tolerations of the VM's VirtualMachine, flagging those the
+// organization's security policy disallows.
+func (h *VMHandlers) GetVMSecurityProfile(c *gin.Context) {
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	if h.security == nil {
+		c.JSON(http.StatusServiceUnavailable, NewAPIError(
+			http.StatusServiceUnavailable,
+			"Service Unavailable",
+			"VM security profiles are not available",
+		))
+		return
+	}
+
+	vdc, err := h.vdcRepo.GetWithOrganization(vm.VApp.VDCID)
+	if err != nil {
+		h.logger.Error("Failed to load organization of VM", "vmID", vm.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to collect VM security profile",
+		))
+		return
+	}
+
+	response := VMSecurityProfileResponse{
+		VMID:              vm.ID,
+		CollectedAt:       time.Now().UTC().Format(time.RFC3339),
+		VMSecurityProfile: &services.VMSecurityProfile{Items: []services.SecurityProfileItem{}},
+	}
+	if vdc.Organization != nil {
+		response.Policy, err = h.orgRepo.EffectiveSecurityPolicy(c.Request.Context(), vdc.Organization)
+		if err != nil {
+			h.logger.Error("Failed to resolve security policy of VM", "vmID", vm.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, NewAPIError(
+				http.StatusInternalServerError,
+				"Internal Server Error",
+				"Failed to collect VM security profile",
+			))
+			return
+		}
+	}
+	if vm.VMName == "" || vm.Namespace == "" {
+		// The VirtualMachine was never created, so it has no privileged settings
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	profile, err := h.security.VMSecurityProfile(c.Request.Context(), vm.Namespace, vm.VMName, response.Policy)
+	if err != nil {
+		h.logger.Error("Failed to collect VM security profile",
+			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to collect VM security profile",
+		))
+		return
+	}
+	response.VMSecurityProfile = profile
+	c.JSON(http.StatusOK, response)
+}
@@ -10,7 +10,16 @@
 //   - Hardware specifications (CPU, memory, storage)
 //   - Network connection details (IP addresses, MAC addresses)
 //   - VM tools status and version information
-//   - Template source information
+//   - Template source information and lineage: what the VM was created from and its boot image
+//   - VirtualHardwareSection and GuestCustomizationSection in the VCD shape, from the
+//     database and the live VirtualMachine spec
+//   - Display name and description updates at PATCH and PUT /cloudapi/1.0.0/vms/{vm_id}
+//   - VM deletion at DELETE /cloudapi/1.0.0/vms/{vm_id}, removing the KubeVirt VirtualMachine first
+//   - Boot diagnostics at GET /cloudapi/1.0.0/vms/{vm_id}/diagnostics
+//   - Serial console logs at GET /cloudapi/1.0.0/vms/{vm_id}/console/log
+//   - Velero backup status at GET /cloudapi/1.0.0/vms/{vm_id}/backupStatus
+//   - Network top talkers at GET /cloudapi/1.0.0/vms/{vm_id}/network/flows
+//   - Privileged settings at GET /cloudapi/1.0.0/vms/{vm_id}/securityProfile
 //   - Access control through vApp → VDC → Organization chain
 //
 // Access Control:
@@ -26,53 +35,179 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/mhrivnak/ssvirt/pkg/api/apiversion"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
 // ErrAccessDenied is returned when a user doesn't have access to a resource
-var ErrAccessDenied = errors.New("access denied")
+var ErrAccessDenied = auth.ErrAccessDenied
+
+// maxVMNameLength is the maximum length of a VM display name
+const maxVMNameLength = 128
+
+// Limits on VM tags
+const (
+	maxVMTags      = 20
+	maxVMTagLength = 64
+)
+
+// vmTagPattern matches a valid VM tag
+var vmTagPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+
+// VM deletion waits for KubeVirt to finish removing the VirtualMachine before the
+// database record is dropped
+const (
+	defaultVMDeletionTimeout = 2 * time.Minute
+	vmDeletionPollInterval   = time.Second
+)
+
+// ErrVMDeletionTimeout is returned when the VirtualMachine is still present after
+// the deletion timeout
+var ErrVMDeletionTimeout = errors.New("timed out waiting for VirtualMachine deletion")
 
 // VMHandlers handles VM API endpoints
 type VMHandlers struct {
-	vmRepo   *repositories.VMRepository
-	vappRepo *repositories.VAppRepository
-	vdcRepo  *repositories.VDCRepository
+	vmRepo    *repositories.VMRepository
+	vappRepo  *repositories.VAppRepository
+	vdcRepo   *repositories.VDCRepository
+	access    *auth.AccessControl
+	k8sClient client.Client
+	eventBus  *events.Bus
+	logger    *slog.Logger
+	pricing   services.Pricing
+
+	diagnostics     VMDiagnosticsSource
+	consoleLogs     VMConsoleLogSource
+	backups         services.BackupService
+	networkFlows    services.NetworkFlowService
+	security        services.VMSecurityService
+	orgRepo         *repositories.OrganizationRepository
+	exports         services.VMExportService
+	exportTTL       time.Duration
+	exportTimeout   time.Duration
+	tasks           VMTaskStore
+	background      *services.BackgroundWork
+	deletionTimeout time.Duration
+}
+
+// VMTaskStore creates and completes tasks that track VM operations
+type VMTaskStore interface {
+	VMTaskCreator
+	VMTaskUpdater
 }
 
-// NewVMHandlers creates a new VMHandlers instance
-func NewVMHandlers(vmRepo *repositories.VMRepository, vappRepo *repositories.VAppRepository, vdcRepo *repositories.VDCRepository) *VMHandlers {
+// NewVMHandlers creates a new VMHandlers instance. k8sClient may be nil, in which
+// case VM updates are only persisted to the database. eventBus may be nil, in
+// which case no change events are published.
+func NewVMHandlers(vmRepo *repositories.VMRepository, vappRepo *repositories.VAppRepository, vdcRepo *repositories.VDCRepository, access *auth.AccessControl, k8sClient client.Client, eventBus *events.Bus) *VMHandlers {
 	return &VMHandlers{
-		vmRepo:   vmRepo,
-		vappRepo: vappRepo,
-		vdcRepo:  vdcRepo,
+		vmRepo:    vmRepo,
+		vappRepo:  vappRepo,
+		vdcRepo:   vdcRepo,
+		access:    access,
+		k8sClient: k8sClient,
+		eventBus:  eventBus,
+		logger:    slog.Default(),
+
+		deletionTimeout: defaultVMDeletionTimeout,
 	}
 }
 
+// SetTaskStore enables task tracking for VM deletion
+func (h *VMHandlers) SetTaskStore(tasks VMTaskStore) {
+	h.tasks = tasks
+}
+
+// SetBackgroundWork runs VM deletions, which outlive their request, under work
+// the API server waits for on shutdown
+func (h *VMHandlers) SetBackgroundWork(work *services.BackgroundWork) {
+	h.background = work
+}
+
+// SetPricing enables monthly cost estimates in VM detail responses
+func (h *VMHandlers) SetPricing(pricing services.Pricing) {
+	h.pricing = pricing
+}
+
+// UpdateVMRequest represents the request body for updating a VM.
+// Omitted fields are left unchanged.
+type UpdateVMRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	// Tags replaces the VM's tags, such as no-auto-suspend
+	Tags *[]string `json:"tags"`
+	// RunStrategy is Always, RerunOnFailure, Manual or Halted. Halted also
+	// powers the VM off. PUT leaves it unchanged when omitted.
+	RunStrategy *string `json:"runStrategy"`
+}
+
 // VMResponse represents the detailed response for VM information
 type VMResponse struct {
-	ID                 string              `json:"id"`
-	Name               string              `json:"name"`
-	Description        string              `json:"description"`
-	Status             string              `json:"status"`
-	VAppID             string              `json:"vappId"`
-	TemplateID         string              `json:"templateId,omitempty"`
-	CreatedAt          string              `json:"createdAt"`
-	UpdatedAt          string              `json:"updatedAt"`
-	GuestOS            string              `json:"guestOs"`
+	ID          string        `json:"id"`
+	FriendlyID  string        `json:"friendlyId,omitempty"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Status      string        `json:"status"`
+	HealthState string        `json:"healthState,omitempty" since:"39.0"`
+	VAppID      string        `json:"vappId"`
+	TemplateID  string        `json:"templateId,omitempty"`
+	CreatedAt   string        `json:"createdAt"`
+	UpdatedAt   string        `json:"updatedAt"`
+	GuestOS     string        `json:"guestOs"`
+	Tags        []string      `json:"tags,omitempty"`
+	Source      *VMSourceInfo `json:"source,omitempty"`
+	// RunStrategy is the run strategy requested for the VM; omitted while the
+	// VM keeps the run strategy of its template
+	RunStrategy string `json:"runStrategy,omitempty"`
+	// StatusDetails explains the status, such as the quota keeping a
+	// QUOTA_EXCEEDED VM from starting
+	StatusDetails string `json:"statusDetails,omitempty"`
+	// FQDN is the DNS name published for the VM when its VDC has a DNS zone
+	FQDN               string              `json:"fqdn,omitempty"`
 	VMTools            VMToolsInfo         `json:"vmTools"`
 	Hardware           HardwareInfo        `json:"hardware"`
 	StorageProfile     StorageProfileInfo  `json:"storageProfile"`
 	NetworkConnections []NetworkConnection `json:"networkConnections"`
 	Href               string              `json:"href"`
+	// EstimatedCost is the monthly cost of the VM's vCPUs and memory, present
+	// on VM detail responses when pricing is configured
+	EstimatedCost *services.CostEstimate `json:"estimatedCost,omitempty"`
+	// Sections in the VCD shape, present on VM detail responses
+	VirtualHardwareSection    *VirtualHardwareSection    `json:"virtualHardwareSection,omitempty"`
+	GuestCustomizationSection *GuestCustomizationSection `json:"guestCustomizationSection,omitempty"`
+}
+
+// VMSourceInfo is the lineage of a VM: what it was created from and the image
+// its boot disk was populated from
+type VMSourceInfo struct {
+	// Type is template, import, clone or manual
+	Type string `json:"type"`
+	// Ref is the catalog item URN or template name, or the VM it was imported
+	// or cloned from
+	Ref   string `json:"ref,omitempty"`
+	Image string `json:"image,omitempty"`
 }
 
 // VMToolsInfo represents VM tools information
@@ -86,6 +221,10 @@ type HardwareInfo struct {
 	NumCPUs           int `json:"numCpus"`
 	NumCoresPerSocket int `json:"numCoresPerSocket"`
 	MemoryMB          int `json:"memoryMB"`
+	// BIOSUUID and SerialNumber are the SMBIOS identity the guest sees, stable
+	// across restarts and migrations
+	BIOSUUID     string `json:"biosUuid,omitempty"`
+	SerialNumber string `json:"serialNumber,omitempty"`
 }
 
 // StorageProfileInfo represents storage profile information
@@ -138,56 +277,537 @@ func (h *VMHandlers) GetVM(c *gin.Context) {
 	}
 
 	// Validate VM access
-	vm, err := h.validateVMAccess(c.Request.Context(), userClaims.UserID, vmID)
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	// Convert to response format
+	response := toVMResponse(*vm)
+	response.EstimatedCost = h.pricing.Estimate(services.ResourceUsage{
+		CPUs:        response.Hardware.NumCPUs,
+		MemoryBytes: int64(response.Hardware.MemoryMB) * 1024 * 1024,
+	})
+	h.addSections(c.Request.Context(), vm, &response)
+	apiversion.JSON(c, http.StatusOK, response)
+}
+
+// UpdateVM handles PATCH /cloudapi/1.0.0/vms/{vm_id}
+func (h *VMHandlers) UpdateVM(c *gin.Context) {
+	// Extract user ID from JWT claims
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+
+	// Validate VM URN format using centralized validation
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	var req UpdateVMRequest
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if req.Name == nil && req.Description == nil && req.Tags == nil && req.RunStrategy == nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"At least one of name or description must be provided",
+		))
+		return
+	}
+
+	h.applyVMUpdate(c, userClaims.UserID, vmID, req)
+}
+
+// ReplaceVM handles PUT /cloudapi/1.0.0/vms/{vm_id}. Unlike PATCH, the request
+// replaces the VM's editable fields: name is required and an omitted
+// description clears it.
+func (h *VMHandlers) ReplaceVM(c *gin.Context) {
+	// Extract user ID from JWT claims
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+
+	// Validate VM URN format using centralized validation
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	var req UpdateVMRequest
+	if err := bindJSON(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if req.Name == nil {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"VM name is required",
+		))
+		return
+	}
+	if req.Description == nil {
+		empty := ""
+		req.Description = &empty
+	}
+	if req.Tags == nil {
+		req.Tags = &[]string{}
+	}
+
+	h.applyVMUpdate(c, userClaims.UserID, vmID, req)
+}
+
+// applyVMUpdate validates and applies a name, description, tags and run
+// strategy update shared by PATCH and PUT, writing the response
+func (h *VMHandlers) applyVMUpdate(c *gin.Context, userID, vmID string, req UpdateVMRequest) {
+	if req.RunStrategy != nil && !models.IsValidVMRunStrategy(*req.RunStrategy) {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM run strategy",
+			fmt.Sprintf("runStrategy must be %s, %s, %s or %s", models.VMRunStrategyAlways,
+				models.VMRunStrategyRerunOnFailure, models.VMRunStrategyManual, models.VMRunStrategyHalted),
+		))
+		return
+	}
+	if req.Tags != nil {
+		tags, err := normalizeVMTags(*req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid VM tags",
+				err.Error(),
+			))
+			return
+		}
+		req.Tags = &tags
+	}
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"VM name cannot be empty",
+			))
+			return
+		}
+		if len(trimmed) > maxVMNameLength {
+			c.JSON(http.StatusBadRequest, NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				fmt.Sprintf("VM name must be at most %d characters", maxVMNameLength),
+			))
+			return
+		}
+		req.Name = &trimmed
+	}
+
+	// Validate VM access
+	vm, err := h.access.CanManageVM(c.Request.Context(), userID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	if vm.Status == "DELETING" || vm.Status == "DELETED" {
+		c.JSON(http.StatusConflict, NewAPIError(
+			http.StatusConflict,
+			"Conflict",
+			"VM is in a conflicting state",
+		))
+		return
+	}
+
+	// Mirror the new values onto the VirtualMachine before persisting them so
+	// the database never reports a name the cluster has not accepted
+	if err := h.syncVMAnnotations(c.Request.Context(), vm, req.Name, req.Description); err != nil {
+		h.logger.Error("Failed to update VirtualMachine annotations",
+			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		if respondNamespaceWritesSaturated(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to update VM resource",
+		))
+		return
+	}
+
+	err = h.vmRepo.UpdateNameAndDescription(c.Request.Context(), vm.ID, req.Name, req.Description)
+	if err == nil && req.Tags != nil {
+		err = h.vmRepo.UpdateTags(c.Request.Context(), vm.ID, *req.Tags)
+	}
+	if err == nil && req.RunStrategy != nil {
+		err = h.vmRepo.SetRunStrategy(c.Request.Context(), vm.ID, *req.RunStrategy)
+		// The vm-controller only manages VMs with a desired power state, so
+		// record that a running VM should keep running under its new strategy
+		if err == nil && vm.DesiredPowerState == "" && vm.Status == "POWERED_ON" && *req.RunStrategy != models.VMRunStrategyHalted {
+			err = h.vmRepo.SetDesiredPowerState(c.Request.Context(), vm.ID, models.VMPowerStateOn)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, NewAPIError(
 				http.StatusNotFound,
 				"Not Found",
 				"VM not found",
 			))
-		} else if err == ErrAccessDenied {
-			c.JSON(http.StatusForbidden, NewAPIError(
-				http.StatusForbidden,
-				"Forbidden",
-				"VM access denied",
-			))
-		} else {
-			c.JSON(http.StatusInternalServerError, NewAPIError(
-				http.StatusInternalServerError,
-				"Internal Server Error",
-				"Failed to validate VM access",
-			))
+			return
 		}
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to update VM",
+		))
 		return
 	}
 
-	// Convert to response format
-	response := h.toVMResponse(*vm)
-	c.JSON(http.StatusOK, response)
+	updatedVM, err := h.vmRepo.GetWithVAppContext(c.Request.Context(), vm.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to retrieve updated VM",
+		))
+		return
+	}
+
+	if h.eventBus != nil {
+		event := events.Event{
+			Type:       events.TypeVMUpdated,
+			EntityType: events.EntityVM,
+			EntityID:   updatedVM.ID,
+			Data: map[string]interface{}{
+				"name":        updatedVM.Name,
+				"description": updatedVM.Description,
+				"tags":        updatedVM.TagList(),
+				"runStrategy": updatedVM.RunStrategy,
+			},
+		}
+		if updatedVM.VApp != nil && updatedVM.VApp.VDC != nil {
+			event.OrgID = updatedVM.VApp.VDC.OrganizationID
+		}
+		h.eventBus.Publish(event)
+	}
+
+	apiversion.JSON(c, http.StatusOK, toVMResponse(*updatedVM))
+}
+
+// DeleteVM handles DELETE /cloudapi/1.0.0/vms/{vm_id}. The VM is marked DELETING
+// and the response is 202 Accepted with the tracking task; the backing
+// VirtualMachine is then deleted in the background, and the VM record only
+// disappears once KubeVirt has finished removing it. Powered-on VMs are
+// rejected unless force=true.
+func (h *VMHandlers) DeleteVM(c *gin.Context) {
+	// Extract user ID from JWT claims
+	claims, exists := c.Get(auth.ClaimsContextKey)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Authentication required",
+		))
+		return
+	}
+
+	userClaims, ok := claims.(*auth.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, NewAPIError(
+			http.StatusUnauthorized,
+			"Unauthorized",
+			"Invalid authentication token",
+		))
+		return
+	}
+
+	vmID := c.Param("vm_id")
+
+	// Validate VM URN format using centralized validation
+	if urnType, err := models.GetURNType(vmID); err != nil || urnType != "vm" {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"Invalid VM URN format",
+		))
+		return
+	}
+
+	force := c.Query("force") == "true"
+
+	// Validate VM access
+	vm, err := h.access.CanManageVM(c.Request.Context(), userClaims.UserID, vmID)
+	if err != nil {
+		respondAccessError(c, err, "VM")
+		return
+	}
+
+	if vm.Status == "POWERED_ON" && !force {
+		c.JSON(http.StatusBadRequest, NewAPIError(
+			http.StatusBadRequest,
+			"Bad Request",
+			"VM is powered on",
+			"Power off the VM or retry with force=true",
+		))
+		return
+	}
+
+	if err := h.vmRepo.UpdateStatus(c.Request.Context(), vm.ID, "DELETING"); err != nil {
+		c.JSON(http.StatusInternalServerError, NewAPIError(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"Failed to update VM status",
+		))
+		return
+	}
+
+	task := h.startVMDeletionTask(c, vm)
+
+	// Removing the VirtualMachine can take minutes, so it outlives the request
+	runInBackground(c, h.background, func(ctx context.Context) {
+		h.runVMDeletion(ctx, vm, task)
+	})
+
+	acceptDeletion(c, DeletionResponse{
+		ID:     vm.ID,
+		Name:   vm.Name,
+		Status: "DELETING",
+		Href:   fmt.Sprintf("/cloudapi/1.0.0/vms/%s", vm.ID),
+	}, task)
 }
 
-// validateVMAccess validates that a user has access to a VM through vApp's VDC organization membership
-func (h *VMHandlers) validateVMAccess(ctx context.Context, userID, vmID string) (*models.VM, error) {
-	vm, err := h.vmRepo.GetWithVAppContext(ctx, vmID)
+// runVMDeletion deletes the VM's VirtualMachine and then its record, recording
+// the outcome on task. A failure leaves the record in place, with status
+// DELETING, for a retry.
+func (h *VMHandlers) runVMDeletion(ctx context.Context, vm *models.VM, task *models.Task) {
+	// State is still recorded after ctx is cancelled
+	dbCtx := context.WithoutCancel(ctx)
+
+	h.updateVMTask(dbCtx, task, models.TaskStatusRunning, 20, "Waiting for the VirtualMachine to be removed")
+	if err := h.deleteVirtualMachine(ctx, vm); err != nil {
+		if ctx.Err() != nil {
+			h.logger.Warn("VM deletion interrupted by API server shutdown", "vmID", vm.ID)
+			h.updateVMTask(dbCtx, task, models.TaskStatusAborted, 100,
+				"API server shut down before the VirtualMachine was removed; delete the VM again to finish")
+			return
+		}
+		h.logger.Error("Failed to delete VirtualMachine",
+			"vmName", vm.VMName, "namespace", vm.Namespace, "error", err)
+		details := "Failed to delete VM resource"
+		if errors.Is(err, ErrVMDeletionTimeout) {
+			details = "The VirtualMachine is still being removed; delete the VM again to finish"
+		}
+		h.updateVMTask(dbCtx, task, models.TaskStatusError, 100, details)
+		return
+	}
+
+	h.updateVMTask(dbCtx, task, models.TaskStatusRunning, 90, "Deleting VM record")
+	if err := h.vmRepo.DeleteWithContext(dbCtx, vm.ID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		h.logger.Error("Failed to delete VM record", "vmID", vm.ID, "error", err)
+		h.updateVMTask(dbCtx, task, models.TaskStatusError, 100, "Failed to delete VM")
+		return
+	}
+
+	if h.eventBus != nil {
+		event := events.Event{
+			Type:       events.TypeVMDeleted,
+			EntityType: events.EntityVM,
+			EntityID:   vm.ID,
+			Data: map[string]interface{}{
+				"name": vm.Name,
+			},
+		}
+		if vm.VApp != nil && vm.VApp.VDC != nil {
+			event.OrgID = vm.VApp.VDC.OrganizationID
+		}
+		h.eventBus.Publish(event)
+	}
+
+	h.updateVMTask(dbCtx, task, models.TaskStatusSuccess, 100, "")
+}
+
+// startVMDeletionTask records a running vmDelete task. It returns nil when task
+// tracking is disabled or the task could not be created; the deletion proceeds
+// either way.
+func (h *VMHandlers) startVMDeletionTask(c *gin.Context, vm *models.VM) *models.Task {
+	if h.tasks == nil {
+		return nil
+	}
+
+	var userID string
+	if claims, exists := c.Get(auth.ClaimsContextKey); exists {
+		if userClaims, ok := claims.(*auth.Claims); ok {
+			userID = userClaims.UserID
+		}
+	}
+
+	task, err := h.tasks.CreateVMTask(c.Request.Context(), vm.ID, models.TaskOperationVMDelete, fmt.Sprintf("Deleting VM %s", vm.Name), userID)
 	if err != nil {
-		return nil, err
+		h.logger.Warn("Failed to create task for VM deletion", "vmID", vm.ID, "error", err)
+		return nil
+	}
+	publishTaskUpdate(h.eventBus, task, task.Status)
+	return task
+}
+
+// updateVMTask records the status and progress of a VM task
+func (h *VMHandlers) updateVMTask(ctx context.Context, task *models.Task, status string, progress int, details string) {
+	if task == nil {
+		return
+	}
+	if err := h.tasks.UpdateStatus(ctx, task.ID, status, progress, details); err != nil {
+		h.logger.Warn("Failed to update VM task", "taskID", task.ID, "status", status, "error", err)
+		return
+	}
+	if status != models.TaskStatusRunning {
+		publishTaskUpdate(h.eventBus, task, status)
+	}
+}
+
+// deleteVirtualMachine deletes the VirtualMachine backing a VM record and waits
+// until it is gone. It is a no-op when no Kubernetes client is configured or the
+// record has no backing resource.
+func (h *VMHandlers) deleteVirtualMachine(ctx context.Context, vm *models.VM) error {
+	if h.k8sClient == nil || vm.VMName == "" || vm.Namespace == "" {
+		return nil
+	}
+	key := types.NamespacedName{Name: vm.VMName, Namespace: vm.Namespace}
+	return deleteVirtualMachines(ctx, h.k8sClient, []types.NamespacedName{key}, h.deletionTimeout)
+}
+
+// deleteVirtualMachines deletes VirtualMachines and waits until all of them are
+// gone, returning ErrVMDeletionTimeout if any remain after timeout. Foreground
+// propagation removes each VirtualMachineInstance and other dependents before
+// the VirtualMachine itself disappears.
+func deleteVirtualMachines(ctx context.Context, k8sClient client.Client, keys []types.NamespacedName, timeout time.Duration) error {
+	for _, key := range keys {
+		vmResource := &kubevirtv1.VirtualMachine{}
+		vmResource.Name = key.Name
+		vmResource.Namespace = key.Namespace
+
+		err := k8sClient.Delete(ctx, vmResource, client.PropagationPolicy(metav1.DeletePropagationForeground))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete VirtualMachine %s: %w", key, err)
+		}
 	}
 
-	// Check if user has access to the VDC containing this VM's vApp
-	_, err = h.vdcRepo.GetAccessibleVDC(ctx, userID, vm.VApp.VDCID)
+	remaining := keys
+	err := wait.PollUntilContextTimeout(ctx, vmDeletionPollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		var pending []types.NamespacedName
+		for _, key := range remaining {
+			err := k8sClient.Get(ctx, key, &kubevirtv1.VirtualMachine{})
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			pending = append(pending, key)
+		}
+		remaining = pending
+		return len(remaining) == 0, nil
+	})
+	if wait.Interrupted(err) {
+		return ErrVMDeletionTimeout
+	}
+	return err
+}
+
+// syncVMAnnotations merge-patches the display name and description annotations on
+// the VirtualMachine backing a VM record. It is a no-op when no Kubernetes client is
+// configured or the VirtualMachine does not exist yet; the controller creates VM
+// records from existing resources, so a missing resource only happens during teardown.
+func (h *VMHandlers) syncVMAnnotations(ctx context.Context, vm *models.VM, name, description *string) error {
+	if h.k8sClient == nil || vm.VMName == "" || vm.Namespace == "" {
+		return nil
+	}
+
+	vmResource := &kubevirtv1.VirtualMachine{}
+	err := h.k8sClient.Get(ctx, types.NamespacedName{Name: vm.VMName, Namespace: vm.Namespace}, vmResource)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, ErrAccessDenied
+		if k8serrors.IsNotFound(err) {
+			h.logger.Warn("VirtualMachine resource not found, skipping annotation sync",
+				"vmName", vm.VMName, "namespace", vm.Namespace)
+			return nil
 		}
-		return nil, err // Return database errors as-is
+		return err
 	}
 
-	return vm, nil
+	annotations := map[string]interface{}{}
+	if name != nil {
+		annotations[services.VMDisplayNameAnnotation] = *name
+	}
+	if description != nil {
+		annotations[services.VMDescriptionAnnotation] = *description
+	}
+
+	patchBytes, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return h.k8sClient.Patch(ctx, vmResource, client.RawPatch(types.MergePatchType, patchBytes))
 }
 
 // toVMResponse converts a VM model to VCD-compliant response format
-func (h *VMHandlers) toVMResponse(vm models.VM) VMResponse {
+func toVMResponse(vm models.VM) VMResponse {
 	// Extract template ID if available
 	templateID := ""
 	if vm.VApp != nil && vm.VApp.TemplateID != nil {
@@ -200,6 +820,8 @@ func (h *VMHandlers) toVMResponse(vm models.VM) VMResponse {
 		NumCPUs:           2,
 		NumCoresPerSocket: 1,
 		MemoryMB:          4096,
+		BIOSUUID:          vm.BIOSUUID,
+		SerialNumber:      vm.SerialNumber,
 	}
 
 	if vm.CPUCount != nil {
@@ -219,21 +841,33 @@ func (h *VMHandlers) toVMResponse(vm models.VM) VMResponse {
 		description = fmt.Sprintf("Virtual machine %s", vm.Name)
 	}
 
+	ipAddress := vm.IPAddress
+	if ipAddress == "" {
+		ipAddress = "192.168.1.100" // Default until the VM controller reports one
+	}
+
 	return VMResponse{
 		ID:          vm.ID,
+		FriendlyID:  vm.FriendlyID,
 		Name:        vm.Name,
 		Description: description,
 		Status:      vm.Status,
+		HealthState: vm.GetHealthState(),
 		VAppID:      vm.VAppID,
 		TemplateID:  templateID,
 		CreatedAt:   vm.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:   vm.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 		GuestOS:     guestOS,
+		Tags:        vm.TagList(),
+		Source:      vmSourceInfo(vm),
+		RunStrategy: vm.RunStrategy,
+		FQDN:        vm.DNSName,
 		VMTools: VMToolsInfo{
 			Status:  "RUNNING",
 			Version: "12.1.5",
 		},
-		Hardware: hardware,
+		Hardware:      hardware,
+		StatusDetails: vm.StatusDetails,
 		StorageProfile: StorageProfileInfo{
 			Name: "default-storage-policy",
 			Href: "/cloudapi/1.0.0/storageProfiles/default-storage-policy",
@@ -241,7 +875,7 @@ func (h *VMHandlers) toVMResponse(vm models.VM) VMResponse {
 		NetworkConnections: []NetworkConnection{
 			{
 				NetworkName: "default-network",
-				IPAddress:   "192.168.1.100", // Would be retrieved from OpenShift
+				IPAddress:   ipAddress,
 				MACAddress:  "00:50:56:12:34:56",
 				Connected:   true,
 			},
@@ -249,3 +883,36 @@ func (h *VMHandlers) toVMResponse(vm models.VM) VMResponse {
 		Href: fmt.Sprintf("/cloudapi/1.0.0/vms/%s", vm.ID),
 	}
 }
+
+// vmSourceInfo returns a VM's lineage, or nil for VMs recorded before lineage was tracked
+func vmSourceInfo(vm models.VM) *VMSourceInfo {
+	if vm.SourceType == "" {
+		return nil
+	}
+	return &VMSourceInfo{
+		Type:  vm.SourceType,
+		Ref:   vm.SourceRef,
+		Image: vm.SourceImage,
+	}
+}
+
+// normalizeVMTags trims, lowercases and deduplicates VM tags, returning an
+// error if any tag is invalid
+func normalizeVMTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if len(tag) > maxVMTagLength || !vmTagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("tag %q must be 1 to %d lowercase letters, digits, '.', '_' or '-', starting and ending with a letter or digit", tag, maxVMTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxVMTags {
+		return nil, fmt.Errorf("a VM may have at most %d tags", maxVMTags)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestSyncVMAnnotations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+
+	vmResource := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-vm",
+			Namespace:   "test-namespace",
+			Annotations: map[string]string{"existing": "value"},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vmResource).Build()
+
+	h := &VMHandlers{k8sClient: fakeClient, logger: slog.Default()}
+	vm := &models.VM{VMName: "test-vm", Namespace: "test-namespace"}
+
+	name := "Renamed VM"
+	description := "A renamed VM"
+	require.NoError(t, h.syncVMAnnotations(context.Background(), vm, &name, &description))
+
+	updated := &kubevirtv1.VirtualMachine{}
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-vm", Namespace: "test-namespace"}, updated))
+	assert.Equal(t, "Renamed VM", updated.Annotations[services.VMDisplayNameAnnotation])
+	assert.Equal(t, "A renamed VM", updated.Annotations[services.VMDescriptionAnnotation])
+	assert.Equal(t, "value", updated.Annotations["existing"])
+
+	// Updating only the description leaves the display name annotation intact
+	newDescription := "Updated description"
+	require.NoError(t, h.syncVMAnnotations(context.Background(), vm, nil, &newDescription))
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-vm", Namespace: "test-namespace"}, updated))
+	assert.Equal(t, "Renamed VM", updated.Annotations[services.VMDisplayNameAnnotation])
+	assert.Equal(t, "Updated description", updated.Annotations[services.VMDescriptionAnnotation])
+}
+
+func TestSyncVMAnnotations_MissingResource(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	h := &VMHandlers{k8sClient: fakeClient, logger: slog.Default()}
+	vm := &models.VM{VMName: "missing-vm", Namespace: "test-namespace"}
+
+	name := "Renamed VM"
+	assert.NoError(t, h.syncVMAnnotations(context.Background(), vm, &name, nil))
+}
+
+func TestSyncVMAnnotations_NoClient(t *testing.T) {
+	h := &VMHandlers{logger: slog.Default()}
+	vm := &models.VM{VMName: "test-vm", Namespace: "test-namespace"}
+
+	name := "Renamed VM"
+	assert.NoError(t, h.syncVMAnnotations(context.Background(), vm, &name, nil))
+}
+
+func TestDeleteVirtualMachine(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+
+	vmResource := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vmResource).Build()
+
+	h := &VMHandlers{k8sClient: fakeClient, logger: slog.Default(), deletionTimeout: time.Second}
+	vm := &models.VM{VMName: "test-vm", Namespace: "test-namespace"}
+
+	require.NoError(t, h.deleteVirtualMachine(context.Background(), vm))
+
+	err := fakeClient.Get(context.Background(), types.NamespacedName{Name: "test-vm", Namespace: "test-namespace"}, &kubevirtv1.VirtualMachine{})
+	assert.True(t, k8serrors.IsNotFound(err))
+
+	// Deleting again is a no-op once the resource is gone
+	assert.NoError(t, h.deleteVirtualMachine(context.Background(), vm))
+}
+
+func TestDeleteVirtualMachine_WaitsForCleanup(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+
+	// A finalizer keeps the VirtualMachine around after deletion is requested
+	vmResource := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "stuck-vm",
+			Namespace:  "test-namespace",
+			Finalizers: []string{"kubevirt.io/virtualMachineControllerFinalize"},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vmResource).Build()
+
+	h := &VMHandlers{k8sClient: fakeClient, logger: slog.Default(), deletionTimeout: 50 * time.Millisecond}
+	vm := &models.VM{VMName: "stuck-vm", Namespace: "test-namespace"}
+
+	err := h.deleteVirtualMachine(context.Background(), vm)
+	assert.ErrorIs(t, err, ErrVMDeletionTimeout)
+}
+
+func TestDeleteVirtualMachine_NoClient(t *testing.T) {
+	h := &VMHandlers{logger: slog.Default()}
+	vm := &models.VM{VMName: "test-vm", Namespace: "test-namespace"}
+
+	assert.NoError(t, h.deleteVirtualMachine(context.Background(), vm))
+}
+
+func TestRunVMDeletion_RecordsOutcomeOnTask(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+
+	// A finalizer keeps the VirtualMachine around after deletion is requested
+	vmResource := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "stuck-vm",
+			Namespace:  "test-namespace",
+			Finalizers: []string{"kubevirt.io/virtualMachineControllerFinalize"},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vmResource).Build()
+
+	tasks := &recordingTasks{statuses: map[string]string{}}
+	h := &VMHandlers{k8sClient: fakeClient, logger: slog.Default(), deletionTimeout: 50 * time.Millisecond, tasks: tasks}
+	vm := &models.VM{ID: "urn:vcloud:vm:stuck", VMName: "stuck-vm", Namespace: "test-namespace"}
+
+	t.Run("timeout fails the task", func(t *testing.T) {
+		task, err := tasks.CreateVMTask(context.Background(), vm.ID, models.TaskOperationVMDelete, "Deleting VM", "")
+		require.NoError(t, err)
+		h.runVMDeletion(context.Background(), vm, task)
+		assert.Equal(t, models.TaskStatusError, tasks.statuses[task.ID])
+	})
+
+	t.Run("shutdown aborts the task", func(t *testing.T) {
+		task, err := tasks.CreateVMTask(context.Background(), vm.ID, models.TaskOperationVMDelete, "Deleting VM", "")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		h.runVMDeletion(ctx, vm, task)
+		assert.Equal(t, models.TaskStatusAborted, tasks.statuses[task.ID])
+	})
+}
+
+func TestNormalizeVMTags(t *testing.T) {
+	tags, err := normalizeVMTags([]string{" Dev ", "no-auto-suspend", "dev"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "no-auto-suspend"}, tags)
+
+	for _, invalid := range []string{"", "-dev", "a,b", "with space"} {
+		_, err := normalizeVMTags([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+
+	many := make([]string, maxVMTags+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("tag-%d", i)
+	}
+	_, err = normalizeVMTags(many)
+	assert.Error(t, err)
+}
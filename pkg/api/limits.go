@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+)
+
+// ownBodyLimitRoutes accept bodies larger than api.requests.max_body_bytes and
+// bound them themselves
+var ownBodyLimitRoutes = map[string]bool{
+	"/api/admin/users/import": true,
+}
+
+// requestLimitsMiddleware bounds request bodies to api.requests.max_body_bytes
+// and rejects JSON bodies nested deeper than max_json_depth or with arrays
+// longer than max_json_array_length before handlers decode them, since gin
+// reads bodies of any size and shape
+func (s *Server) requestLimitsMiddleware() gin.HandlerFunc {
+	limits := s.config.API.Requests
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody || ownBodyLimitRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		if limits.MaxBodyBytes > 0 {
+			if c.Request.ContentLength > limits.MaxBodyBytes {
+				abortBodyTooLarge(c, limits.MaxBodyBytes)
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxBodyBytes)
+		}
+		if !isJSONContentType(c.ContentType()) || (limits.MaxJSONDepth == 0 && limits.MaxJSONArrayLength == 0) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				abortBodyTooLarge(c, limits.MaxBodyBytes)
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid request body",
+				err.Error(),
+			))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if problem := checkJSONShape(body, limits.MaxJSONDepth, limits.MaxJSONArrayLength); problem != "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Request body is too complex",
+				problem,
+			))
+			return
+		}
+		c.Next()
+	}
+}
+
+// strictJSONMiddleware makes handlers of the route group reject JSON bodies
+// with unknown fields when the group is listed in api.requests.strict_json_groups
+func (s *Server) strictJSONMiddleware(group string) gin.HandlerFunc {
+	strict := slices.Contains(s.config.API.Requests.StrictJSONGroups, group)
+	return func(c *gin.Context) {
+		if strict {
+			c.Set(handlers.StrictJSONContextKey, true)
+		}
+		c.Next()
+	}
+}
+
+// abortBodyTooLarge rejects a request whose body exceeds limit bytes
+func abortBodyTooLarge(c *gin.Context, limit int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, handlers.NewAPIError(
+		http.StatusRequestEntityTooLarge,
+		"Request Entity Too Large",
+		"Request body too large",
+		fmt.Sprintf("request bodies are limited to %d bytes", limit),
+	))
+}
+
+// isJSONContentType reports whether contentType is JSON, including types
+// such as application/merge-patch+json
+func isJSONContentType(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// checkJSONShape returns a description of the first limit body exceeds, or ""
+// when it is within them. Limits of 0 are not checked. Malformed JSON is left
+// for handlers to report.
+func checkJSONShape(body []byte, maxDepth, maxArrayLength int) string {
+	type container struct {
+		array  bool
+		length int
+	}
+	var stack []container
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		delim, isDelim := token.(json.Delim)
+		if isDelim && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		// Every value inside an array is an element of it; object keys and
+		// values are not counted
+		if n := len(stack); n > 0 && stack[n-1].array {
+			stack[n-1].length++
+			if maxArrayLength > 0 && stack[n-1].length > maxArrayLength {
+				return fmt.Sprintf("arrays may have at most %d elements", maxArrayLength)
+			}
+		}
+		if isDelim {
+			stack = append(stack, container{array: delim == '['})
+			if maxDepth > 0 && len(stack) > maxDepth {
+				return fmt.Sprintf("JSON may be nested at most %d levels deep", maxDepth)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+func TestCheckJSONShape(t *testing.T) {
+	assert.Empty(t, checkJSONShape([]byte(`{"a":{"b":[1,2,3]}}`), 3, 3))
+	assert.Contains(t, checkJSONShape([]byte(`{"a":{"b":[1,2,3]}}`), 2, 3), "nested at most 2 levels")
+	assert.Contains(t, checkJSONShape([]byte(`{"a":[1,2,3,4]}`), 3, 3), "at most 3 elements")
+	// Nested arrays count as one element of their parent
+	assert.Empty(t, checkJSONShape([]byte(`[[1,2],[3,4],{"a":1,"b":2,"c":3,"d":4}]`), 3, 3))
+	// Limits of 0 are not checked, and malformed JSON is left to handlers
+	assert.Empty(t, checkJSONShape([]byte(`[[[[[1,2,3,4,5]]]]]`), 0, 0))
+	assert.Empty(t, checkJSONShape([]byte(`{"a":`), 1, 1))
+}
+
+func TestRequestLimitsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.API.Requests.MaxBodyBytes = 64
+	cfg.API.Requests.MaxJSONDepth = 3
+	cfg.API.Requests.MaxJSONArrayLength = 4
+	s := &Server{config: cfg}
+
+	router := gin.New()
+	router.Use(s.requestLimitsMiddleware())
+	handler := func(c *gin.Context) {
+		var body interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	router.POST("/items", handler)
+	router.POST("/api/admin/users/import", handler)
+
+	post := func(path, contentType, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, post("/items", "application/json", `{"name":"web","tags":[1,2,3,4]}`))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/items", "application/json", `{"name":"`+strings.Repeat("x", 64)+`"}`))
+	assert.Equal(t, http.StatusBadRequest, post("/items", "application/json", `{"a":{"b":{"c":{}}}}`))
+	assert.Equal(t, http.StatusBadRequest, post("/items", "application/merge-patch+json", `{"tags":[1,2,3,4,5]}`))
+	// Bodies that are not JSON are only bounded in size
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/items", "text/csv", strings.Repeat("x", 65)))
+	// Routes with their own limits are left alone
+	assert.Equal(t, http.StatusOK, post("/api/admin/users/import", "application/json", `{"name":"`+strings.Repeat("x", 64)+`"}`))
+}
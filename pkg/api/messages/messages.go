@@ -0,0 +1,149 @@
+// Package messages provides the catalog of API error messages.
+//
+// Every error message the API returns has a stable code, such as
+// VDC_NOT_FOUND, that clients can rely on instead of matching English text.
+// The built-in catalog holds the English messages; operators add translations
+// by placing <language>.json files (for example de.json or pt-BR.json) mapping
+// codes to messages in a catalog directory. Middleware negotiates the language
+// of each request from its Accept-Language header and localizes error
+// responses.
+package messages
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed catalog/en.json
+var builtin embed.FS
+
+// Catalog maps error codes to messages in each supported language
+type Catalog struct {
+	tags     []language.Tag
+	messages map[language.Tag]map[string]string
+	codes    map[string]string // lowercase English message to code
+	matcher  language.Matcher
+}
+
+var defaultCatalog = mustLoadBuiltin()
+
+// Default returns the catalog of built-in English messages
+func Default() *Catalog {
+	return defaultCatalog
+}
+
+func mustLoadBuiltin() *Catalog {
+	data, err := builtin.ReadFile("catalog/en.json")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read built-in message catalog: %v", err))
+	}
+	var english map[string]string
+	if err := json.Unmarshal(data, &english); err != nil {
+		panic(fmt.Sprintf("failed to parse built-in message catalog: %v", err))
+	}
+
+	c := &Catalog{
+		messages: make(map[language.Tag]map[string]string),
+		codes:    make(map[string]string, len(english)),
+	}
+	c.add(language.English, english)
+	for code, message := range english {
+		c.codes[strings.ToLower(message)] = code
+	}
+	return c
+}
+
+// Load returns the built-in catalog extended with the translations in dir.
+// Each <language>.json file in dir maps codes to messages in the language
+// named by the file; codes it leaves out fall back to English.
+func Load(dir string) (*Catalog, error) {
+	c := mustLoadBuiltin()
+	if dir == "" {
+		return c, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message catalogs in %s: %w", dir, err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		tag, err := language.Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("message catalog %s is not named after a language: %w", file, err)
+		}
+		data, err := os.ReadFile(file) // #nosec G304 - catalog directory is operator configured
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message catalog %s: %w", file, err)
+		}
+		var translated map[string]string
+		if err := json.Unmarshal(data, &translated); err != nil {
+			return nil, fmt.Errorf("failed to parse message catalog %s: %w", file, err)
+		}
+		for code := range translated {
+			if _, ok := c.messages[language.English][code]; !ok {
+				return nil, fmt.Errorf("message catalog %s has unknown code %q", file, code)
+			}
+		}
+		c.add(tag, translated)
+	}
+	return c, nil
+}
+
+// add registers the messages of a language, merging with any already loaded
+func (c *Catalog) add(tag language.Tag, messages map[string]string) {
+	existing, ok := c.messages[tag]
+	if !ok {
+		existing = make(map[string]string, len(messages))
+		c.messages[tag] = existing
+		c.tags = append(c.tags, tag)
+		c.matcher = language.NewMatcher(c.tags)
+	}
+	for code, message := range messages {
+		existing[code] = message
+	}
+}
+
+// Code returns the code of an English message, ignoring case, or "" when the
+// message is not in the catalog
+func (c *Catalog) Code(message string) string {
+	return c.codes[strings.ToLower(message)]
+}
+
+// Message returns the message for a code in the language, falling back to
+// English. The second result is false when the code is unknown.
+func (c *Catalog) Message(code string, tag language.Tag) (string, bool) {
+	if message, ok := c.messages[tag][code]; ok {
+		return message, true
+	}
+	message, ok := c.messages[language.English][code]
+	return message, ok
+}
+
+// Negotiate returns the catalog language that best matches an Accept-Language
+// header, defaulting to English
+func (c *Catalog) Negotiate(acceptLanguage string) language.Tag {
+	if acceptLanguage == "" {
+		return language.English
+	}
+	preferred, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(preferred) == 0 {
+		return language.English
+	}
+	_, index, confidence := c.matcher.Match(preferred...)
+	if confidence == language.No {
+		return language.English
+	}
+	return c.tags[index]
+}
+
+// Languages returns the languages the catalog has messages for
+func (c *Catalog) Languages() []language.Tag {
+	return append([]language.Tag(nil), c.tags...)
+}
@@ -0,0 +1,88 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// LanguageContextKey is the Gin context key for the negotiated language.Tag
+const LanguageContextKey = "language"
+
+// Middleware negotiates the language of each request and translates the
+// message of JSON error responses that carry a minorErrorCode. Responses in
+// English, and successful responses, are written through unchanged.
+func Middleware(catalog *Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag := catalog.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(LanguageContextKey, tag)
+		if tag == language.English {
+			c.Next()
+			return
+		}
+
+		writer := &localizingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.buffer.Len() == 0 {
+			return
+		}
+		body := writer.buffer.Bytes()
+		if localized, ok := localizeError(catalog, tag, body); ok {
+			body = localized
+			writer.Header().Set("Content-Language", tag.String())
+		}
+		_, _ = writer.ResponseWriter.Write(body)
+	}
+}
+
+// localizingWriter holds back JSON error bodies so their message can be translated
+type localizingWriter struct {
+	gin.ResponseWriter
+	buffer bytes.Buffer
+}
+
+func (w *localizingWriter) buffering() bool {
+	return w.Status() >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+}
+
+func (w *localizingWriter) Write(data []byte) (int, error) {
+	if w.buffering() {
+		return w.buffer.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *localizingWriter) WriteString(s string) (int, error) {
+	if w.buffering() {
+		return w.buffer.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// localizeError replaces the message of an API error body with its translation
+func localizeError(catalog *Catalog, tag language.Tag, body []byte) ([]byte, bool) {
+	var apiError map[string]interface{}
+	if err := json.Unmarshal(body, &apiError); err != nil {
+		return nil, false
+	}
+	code, _ := apiError["minorErrorCode"].(string)
+	if code == "" {
+		return nil, false
+	}
+	message, ok := catalog.messages[tag][code]
+	if !ok {
+		return nil, false
+	}
+	apiError["message"] = message
+	localized, err := json.Marshal(apiError)
+	if err != nil {
+		return nil, false
+	}
+	return localized, true
+}
@@ -2,8 +2,23 @@ package api
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/consistency"
+)
+
+const (
+	// drainRetryAfter is the Retry-After sent with requests rejected while
+	// draining; by then another replica is normally serving
+	drainRetryAfter = 5 * time.Second
+	// drainPollInterval is how often Stop checks for in-flight requests
+	drainPollInterval = 100 * time.Millisecond
 )
 
 // corsMiddleware handles Cross-Origin Resource Sharing (CORS)
@@ -24,6 +39,113 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// drainMiddleware counts in-flight requests that change state and, once Stop
+// has been called, rejects new ones with 503 Service Unavailable so that
+// clients retry them on another replica. Reads are still served.
+func (s *Server) drainMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isMutatingMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if s.draining.Load() {
+			c.Header("Retry-After", strconv.Itoa(int(drainRetryAfter.Seconds())))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, handlers.NewAPIError(
+				http.StatusServiceUnavailable,
+				"Service Unavailable",
+				"Server is shutting down",
+			))
+			return
+		}
+
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		c.Next()
+	}
+}
+
+// isMutatingMethod reports whether requests with the method may change state
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// consistencyMiddleware applies the consistency query parameter to the
+// request's reads. Automation that lists resources right after creating them
+// sets consistency=strong so the lists include them even when reads are
+// otherwise served from caches; such responses must not be cached either.
+func (s *Server) consistencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		level, err := consistency.Parse(c.Query(consistency.QueryParameter))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, handlers.NewAPIError(
+				http.StatusBadRequest,
+				"Bad Request",
+				"Invalid consistency level",
+				err.Error(),
+			))
+			return
+		}
+		if level == consistency.Strong {
+			c.Request = c.Request.WithContext(consistency.WithLevel(c.Request.Context(), level))
+			c.Header("Cache-Control", "no-store")
+		}
+		c.Next()
+	}
+}
+
+// apiUsageMiddleware counts the calls and data transfer of authenticated users.
+// Claims are set by the authentication middleware of the route groups, so they
+// are read once the request has been handled.
+func (s *Server) apiUsageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		claims, exists := c.Get(auth.ClaimsContextKey)
+		if !exists {
+			return
+		}
+		userClaims, ok := claims.(*auth.Claims)
+		if !ok {
+			return
+		}
+
+		orgID := ""
+		if user, err := auth.UserWithRoles(c, s.roleCache); err == nil && user.OrganizationID != nil {
+			orgID = *user.OrganizationID
+		} else if userClaims.OrganizationID != nil {
+			orgID = *userClaims.OrganizationID
+		}
+
+		bytesIn := c.Request.ContentLength
+		if bytesIn < 0 {
+			bytesIn = 0
+		}
+		bytesOut := int64(c.Writer.Size())
+		if bytesOut < 0 {
+			bytesOut = 0
+		}
+		s.apiUsage.Record(userClaims.UserID, orgID, bytesIn, bytesOut, c.Writer.Status() >= http.StatusBadRequest)
+	}
+}
+
+// serverErrorMiddleware counts API requests and their server errors for the
+// system status
+func (s *Server) serverErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		path := c.Request.URL.Path
+		if !strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/cloudapi/") {
+			return
+		}
+		s.serverErrors.Record(c.Writer.Status() >= http.StatusInternalServerError)
+	}
+}
+
 // errorHandlerMiddleware provides consistent error handling
 func (s *Server) errorHandlerMiddleware() gin.HandlerFunc {
 	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+)
+
+const (
+	// clientIdleTimeout is how long the rate limit state of a client that
+	// stopped sending requests is kept
+	clientIdleTimeout = 10 * time.Minute
+	// maxTrackedClients bounds the clients whose rate limit state is kept
+	maxTrackedClients = 10000
+)
+
+// clientRateLimiter limits the request rate of each client address on routes
+// served without authentication
+type clientRateLimiter struct {
+	limit      rate.Limit
+	burst      int
+	maxClients int
+	now        func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimit
+	lastPrune time.Time
+}
+
+type clientLimit struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newClientRateLimiter allows each client requestsPerMinute requests a minute,
+// up to burst at once
+func newClientRateLimiter(requestsPerMinute, burst int) *clientRateLimiter {
+	return &clientRateLimiter{
+		limit:      rate.Limit(float64(requestsPerMinute) / 60),
+		burst:      burst,
+		maxClients: maxTrackedClients,
+		now:        time.Now,
+		clients:    make(map[string]*clientLimit),
+	}
+}
+
+// allow reports whether the client may make a request now. New clients are
+// refused while maxClients are tracked, until idle ones are forgotten.
+func (l *clientRateLimiter) allow(client string) bool {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastPrune) > clientIdleTimeout {
+		for address, state := range l.clients {
+			if now.Sub(state.lastSeen) > clientIdleTimeout {
+				delete(l.clients, address)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	state, ok := l.clients[client]
+	if !ok {
+		if len(l.clients) >= l.maxClients {
+			return false
+		}
+		state = &clientLimit{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = state
+	}
+	state.lastSeen = now
+	return state.limiter.AllowN(now, 1)
+}
+
+// retryAfter is how long a limited client waits for its next request
+func (l *clientRateLimiter) retryAfter() time.Duration {
+	return time.Duration(math.Ceil(float64(time.Second) / float64(l.limit)))
+}
+
+// middleware rejects requests over the client's limit with 429 Too Many Requests
+func (l *clientRateLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.allow(c.ClientIP()) {
+			seconds := int(math.Ceil(l.retryAfter().Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, handlers.NewAPIError(
+				http.StatusTooManyRequests,
+				"Too Many Requests",
+				"Rate limit exceeded",
+			))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+func TestClientRateLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newClientRateLimiter(60, 2)
+	limiter.now = func() time.Time { return now }
+
+	assert.True(t, limiter.allow("10.0.0.1"))
+	assert.True(t, limiter.allow("10.0.0.1"))
+	assert.False(t, limiter.allow("10.0.0.1"))
+	// Clients are limited separately
+	assert.True(t, limiter.allow("10.0.0.2"))
+
+	now = now.Add(time.Second)
+	assert.True(t, limiter.allow("10.0.0.1"))
+	assert.False(t, limiter.allow("10.0.0.1"))
+
+	// Idle clients are forgotten
+	now = now.Add(clientIdleTimeout + time.Second)
+	assert.True(t, limiter.allow("10.0.0.3"))
+	assert.Len(t, limiter.clients, 1)
+
+	// New clients are refused while the limiter is full
+	limiter.maxClients = 2
+	assert.True(t, limiter.allow("10.0.0.4"))
+	assert.False(t, limiter.allow("10.0.0.5"))
+	assert.True(t, limiter.allow("10.0.0.3"))
+	now = now.Add(clientIdleTimeout + time.Second)
+	assert.True(t, limiter.allow("10.0.0.5"))
+}
+
+func TestClientRateLimiterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(newClientRateLimiter(30, 1).middleware())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request().Code)
+	w := request()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
+
+func TestClientRateLimiterIgnoresForwardedHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newRouter(&config.Config{})
+	router.Use(newClientRateLimiter(30, 1).middleware())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.Header.Set("X-Real-IP", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Forged addresses do not give the caller a new limit
+	assert.Equal(t, http.StatusOK, request("203.0.113.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("203.0.113.2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("203.0.113.3").Code)
+}
+
+func TestClientRateLimiterTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.API.TrustedProxies = []string{"192.0.2.0/24"}
+	router := newRouter(cfg)
+	router.Use(newClientRateLimiter(30, 1).middleware())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// httptest requests come from 192.0.2.1, so their forwarded address is used
+	request := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, request("203.0.113.1"))
+	assert.Equal(t, http.StatusOK, request("203.0.113.2"))
+	assert.Equal(t, http.StatusTooManyRequests, request("203.0.113.1"))
+}
@@ -9,16 +9,22 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/mhrivnak/ssvirt/pkg/api/apiversion"
 	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/messages"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
 	"github.com/mhrivnak/ssvirt/pkg/config"
 	"github.com/mhrivnak/ssvirt/pkg/database"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
 	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
@@ -37,23 +43,52 @@ type Server struct {
 	vappRepo        *repositories.VAppRepository
 	vmRepo          *repositories.VMRepository
 	catalogItemRepo *repositories.CatalogItemRepository
+	taskRepo        *repositories.TaskRepository
+	sshKeyRepo      *repositories.SSHKeyRepository
 	templateService services.TemplateServiceInterface
 	k8sService      services.KubernetesService
+	eventBus        *events.Bus
+	roleCache       *auth.RoleCache
+	messageCatalog  *messages.Catalog
+	apiUsage        *services.APIUsageRecorder
+	vmArchiver      *services.VMArchiver
+	background      *services.BackgroundWork
+	templateAccess  *services.TemplateAccessChecker
+	// serverErrors counts the server errors the system status judges the API by
+	serverErrors *services.ServerErrorWindow
+	// approvals is set when the approval webhook gates privileged operations
+	approvals *handlers.Approvals
+	// draining is set by Stop; inFlight counts mutating requests being handled
+	draining atomic.Bool
+	inFlight atomic.Int64
 	// CloudAPI handlers
-	userHandlers        *handlers.UserHandlers
-	roleHandlers        *handlers.RoleHandlers
-	orgHandlers         *handlers.OrgHandlers
-	vdcHandlers         *handlers.VDCHandlers
-	vdcPublicHandlers   *handlers.VDCPublicHandlers
-	catalogHandlers     *handlers.CatalogHandlers
-	catalogItemHandlers *handlers.CatalogItemHandler
-	sessionHandlers     *handlers.SessionHandlers
-	vmCreationHandlers  *handlers.VMCreationHandlers
-	vappHandlers        *handlers.VAppHandlers
-	vmHandlers          *handlers.VMHandlers
-	powerMgmtHandlers   *handlers.PowerManagementHandler
-	router              *gin.Engine
-	httpServer          *http.Server
+	userHandlers         *handlers.UserHandlers
+	roleHandlers         *handlers.RoleHandlers
+	orgHandlers          *handlers.OrgHandlers
+	vdcHandlers          *handlers.VDCHandlers
+	vdcPublicHandlers    *handlers.VDCPublicHandlers
+	catalogHandlers      *handlers.CatalogHandlers
+	catalogItemHandlers  *handlers.CatalogItemHandler
+	sessionHandlers      *handlers.SessionHandlers
+	vmCreationHandlers   *handlers.VMCreationHandlers
+	vappHandlers         *handlers.VAppHandlers
+	vmHandlers           *handlers.VMHandlers
+	powerMgmtHandlers    *handlers.PowerManagementHandler
+	notificationHandlers *handlers.NotificationHandlers
+	taskHandlers         *handlers.TaskHandlers
+	providerHandlers     *handlers.ProviderHandlers
+	sshKeyHandlers       *handlers.SSHKeyHandlers
+	apiUsageHandlers     *handlers.APIUsageHandlers
+	groupSyncHandlers    *handlers.GroupSyncHandlers
+	componentHandlers    *handlers.ComponentHandlers
+	vmArchiveHandlers    *handlers.VMArchiveHandlers
+	publicCatalogs       *handlers.PublicCatalogHandlers
+	systemStatusHandlers *handlers.SystemStatusHandlers
+	changeHandlers       *handlers.ChangeHandlers
+	catalogPinHandlers   *handlers.CatalogPinHandlers
+	tenantHandlers       *handlers.TenantHandlers
+	router               *gin.Engine
+	httpServer           *http.Server
 }
 
 // NewServer creates a new API server instance
@@ -67,7 +102,30 @@ func NewServer(cfg *config.Config, db *database.DB, authSvc *auth.Service, jwtMa
 	}
 
 	// Create catalog item repository
-	catalogItemRepo := repositories.NewCatalogItemRepository(templateService, catalogRepo)
+	catalogItemRepo := repositories.NewCatalogItemRepository(db.DB, catalogRepo)
+
+	// Create task repository for tracking asynchronous operations
+	taskRepo := repositories.NewTaskRepository(db.DB)
+	sshKeyRepo := repositories.NewSSHKeyRepository(db.DB)
+
+	// Create the internal event bus shared by event producers and the notifications stream
+	eventBus := events.NewBus()
+
+	// Cache users' roles so authorization checks do not query the database on every request
+	roleCache := auth.NewRoleCache(userRepo, cfg.Auth.RoleCacheTTL)
+
+	// Translations of API error messages; English is always available
+	messageCatalog, err := messages.Load(cfg.Localization.CatalogDir)
+	if err != nil {
+		log.Printf("Warning: failed to load message catalogs, serving English error messages: %v", err)
+		messageCatalog = messages.Default()
+	}
+
+	apiUsageRepo := repositories.NewAPIUsageRepository(db.DB)
+	vmArchiveRepo := repositories.NewVMArchiveRepository(db.DB)
+
+	// Shared access checks for VDCs and the vApps and VMs within them
+	accessControl := auth.NewAccessControl(vdcRepo, vappRepo, vmRepo)
 
 	server := &Server{
 		config:          cfg,
@@ -83,22 +141,104 @@ func NewServer(cfg *config.Config, db *database.DB, authSvc *auth.Service, jwtMa
 		vappRepo:        vappRepo,
 		vmRepo:          vmRepo,
 		catalogItemRepo: catalogItemRepo,
+		taskRepo:        taskRepo,
+		sshKeyRepo:      sshKeyRepo,
 		templateService: templateService,
 		k8sService:      k8sService,
+		eventBus:        eventBus,
+		roleCache:       roleCache,
+		messageCatalog:  messageCatalog,
 		// Initialize CloudAPI handlers
-		userHandlers:        handlers.NewUserHandlers(userRepo, orgRepo, roleRepo),
-		roleHandlers:        handlers.NewRoleHandlers(roleRepo),
-		orgHandlers:         handlers.NewOrgHandlers(orgRepo),
-		vdcHandlers:         handlers.NewVDCHandlers(vdcRepo, orgRepo, userRepo, k8sService),
-		vdcPublicHandlers:   handlers.NewVDCPublicHandlers(vdcRepo),
-		catalogHandlers:     handlers.NewCatalogHandlers(catalogRepo, catalogItemRepo, orgRepo, k8sService),
-		catalogItemHandlers: handlers.NewCatalogItemHandler(catalogItemRepo),
-		sessionHandlers:     handlers.NewSessionHandlers(userRepo, authSvc, jwtManager, cfg),
-		vmCreationHandlers:  handlers.NewVMCreationHandlers(vdcRepo, vappRepo, catalogItemRepo, catalogRepo, k8sService),
-		vappHandlers:        handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, k8sService),
-		vmHandlers:          handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo),
-		powerMgmtHandlers:   createPowerManagementHandler(vmRepo, k8sService),
+		userHandlers:         handlers.NewUserHandlers(userRepo, orgRepo, roleRepo, roleCache),
+		roleHandlers:         handlers.NewRoleHandlers(roleRepo),
+		orgHandlers:          handlers.NewOrgHandlers(orgRepo),
+		vdcHandlers:          handlers.NewVDCHandlers(vdcRepo, orgRepo, userRepo, k8sService),
+		vdcPublicHandlers:    handlers.NewVDCPublicHandlers(vdcRepo),
+		catalogHandlers:      handlers.NewCatalogHandlers(catalogRepo, catalogItemRepo, orgRepo, userRepo, roleRepo, k8sService),
+		catalogItemHandlers:  handlers.NewCatalogItemHandler(catalogItemRepo, catalogRepo),
+		sessionHandlers:      handlers.NewSessionHandlers(userRepo, authSvc, jwtManager, cfg, roleCache),
+		vmCreationHandlers:   handlers.NewVMCreationHandlers(vdcRepo, vappRepo, catalogItemRepo, catalogRepo, accessControl, k8sService),
+		vappHandlers:         handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, accessControl, k8sService),
+		vmHandlers:           handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, accessControl, getK8sClient(k8sService), eventBus),
+		powerMgmtHandlers:    createPowerManagementHandler(vmRepo, k8sService),
+		notificationHandlers: handlers.NewNotificationHandlers(eventBus, orgRepo),
+		taskHandlers:         handlers.NewTaskHandlers(taskRepo, orgRepo, eventBus),
+		providerHandlers:     handlers.NewProviderHandlers(cfg),
+		sshKeyHandlers:       handlers.NewSSHKeyHandlers(sshKeyRepo, userRepo, roleCache),
+		apiUsageHandlers:     handlers.NewAPIUsageHandlers(apiUsageRepo, roleCache),
+		groupSyncHandlers:    handlers.NewGroupSyncHandlers(createGroupSyncService(cfg, k8sService, userRepo, orgRepo, roleRepo)),
+		componentHandlers:    handlers.NewComponentHandlers(repositories.NewComponentHeartbeatRepository(db.DB)),
+		changeHandlers:       handlers.NewChangeHandlers(repositories.NewEntityChangeRepository(db.DB)),
+		vmArchiveHandlers:    handlers.NewVMArchiveHandlers(vmArchiveRepo),
+		publicCatalogs:       handlers.NewPublicCatalogHandlers(catalogRepo, catalogItemRepo),
+		tenantHandlers:       handlers.NewTenantHandlers(db, k8sService, roleCache),
+	}
+	catalogPinRepo := repositories.NewCatalogItemPinRepository(db.DB)
+	server.catalogPinHandlers = handlers.NewCatalogPinHandlers(catalogPinRepo, catalogRepo, catalogItemRepo, accessControl)
+	server.vmCreationHandlers.SetCatalogPins(catalogPinRepo)
+	if cfg.API.Usage.FlushInterval > 0 {
+		server.apiUsage = services.NewAPIUsageRecorder(apiUsageRepo, cfg.API.Usage.FlushInterval, cfg.API.Usage.RetentionDays, slog.Default())
+	}
+	if archive := cfg.Database.VMArchive; archive.Interval > 0 {
+		server.vmArchiver = services.NewVMArchiver(vmArchiveRepo, archive.Interval, archive.ArchiveAfter, archive.Retention, slog.Default())
 	}
+	server.serverErrors = services.DefaultServerErrorWindow()
+	systemStatusRepo := repositories.NewSystemStatusRepository(db.DB)
+	server.systemStatusHandlers = handlers.NewSystemStatusHandlers(services.NewSystemStatusReporter(
+		repositories.NewComponentHeartbeatRepository(db.DB), taskRepo, systemStatusRepo, server.serverErrors), systemStatusRepo)
+	server.powerMgmtHandlers.SetTaskCreator(taskRepo)
+	server.powerMgmtHandlers.SetAccessControl(accessControl)
+	server.powerMgmtHandlers.SetRoleCache(roleCache)
+	server.vappHandlers.SetTaskStore(taskRepo, eventBus)
+	server.vappHandlers.SetRoleCache(roleCache)
+	server.background = services.NewBackgroundWork()
+	server.vappHandlers.SetBackgroundWork(server.background)
+	server.vmHandlers.SetTaskStore(taskRepo)
+	server.vmHandlers.SetBackgroundWork(server.background)
+	server.userHandlers.SetTaskStore(taskRepo, eventBus)
+	server.userHandlers.SetBackgroundWork(server.background)
+	server.catalogHandlers.SetCatalogSources(repositories.NewCatalogSourceRepository(db.DB))
+	server.orgHandlers.SetDefaultCatalog(cfg.Organizations.DefaultCatalog)
+	server.orgHandlers.SetRoleCache(roleCache)
+	server.vdcPublicHandlers.SetDefaultVMSize(cfg.Quota.DefaultVMSize)
+	server.vmCreationHandlers.SetSSHKeyStore(sshKeyRepo)
+	pricing := services.PricingFromConfig(cfg)
+	server.vmCreationHandlers.SetPricing(pricing)
+	server.vmHandlers.SetPricing(pricing)
+	if k8sService != nil {
+		server.vmHandlers.SetDiagnostics(k8sService)
+		server.vmHandlers.SetConsoleLogs(k8sService)
+		backups := services.NewBackupService(k8sService.GetClient(), cfg.Backup.VeleroNamespace)
+		server.vmHandlers.SetBackups(backups)
+		server.vmHandlers.SetSecurityProfiles(services.NewVMSecurityService(k8sService.GetClient()), orgRepo)
+		server.vmHandlers.SetExports(services.NewVMExportService(k8sService.GetClient()), cfg.Export.TTL, cfg.Export.Timeout)
+		server.vappHandlers.SetBackups(backups)
+	}
+	if cfg.NetworkFlows.PrometheusURL != "" {
+		flows, err := services.NewPrometheusFlowService(*cfg)
+		if err != nil {
+			slog.Default().Warn("VM network flows disabled", "error", err)
+		} else {
+			server.vmHandlers.SetNetworkFlows(flows)
+		}
+	}
+	quotaService := services.NewQuotaService(vdcRepo, eventBus, cfg.Quota.GracePeriod)
+	quotaService.SetOrgQuotas(orgRepo)
+	server.vmCreationHandlers.SetQuotaService(quotaService)
+	server.vmCreationHandlers.SetInstantiationLimiter(services.NewInstantiationLimiter(vappRepo,
+		cfg.Instantiation.MaxConcurrentPerVDC, cfg.Instantiation.MaxConcurrentPerOrg, cfg.Instantiation.QueueTimeout))
+	if cfg.Approval.WebhookURL != "" {
+		server.approvals = handlers.NewApprovals(services.NewApprovalGate(*cfg), taskRepo, eventBus)
+		server.vdcHandlers.SetApprovals(server.approvals)
+		server.vmCreationHandlers.SetApprovals(server.approvals)
+	}
+	reservedNames := handlers.NewReservedNamePrefixes(cfg.Naming.ReservedPrefixes)
+	server.vmCreationHandlers.SetReservedNamePrefixes(reservedNames)
+	server.vdcHandlers.SetReservedNamePrefixes(reservedNames)
+	server.vmCreationHandlers.SetMetadataPolicy(models.MetadataPolicy{
+		Labels:      cfg.Instantiation.Labels,
+		Annotations: cfg.Instantiation.Annotations,
+	})
 
 	// Configure gin mode based on log level
 	if cfg.Log.Level == "debug" {
@@ -120,20 +260,61 @@ func createPowerManagementHandler(vmRepo *repositories.VMRepository, k8sService
 	return handlers.NewPowerManagementHandler(vmRepo, k8sService.GetClient(), slog.Default())
 }
 
+// getK8sClient returns the Kubernetes client from k8sService, or nil when k8sService is nil
+func getK8sClient(k8sService services.KubernetesService) client.Client {
+	if k8sService == nil {
+		return nil
+	}
+	return k8sService.GetClient()
+}
+
+// createGroupSyncService creates the group sync service behind the dry-run
+// report, or returns nil when Kubernetes is not configured
+func createGroupSyncService(cfg *config.Config, k8sService services.KubernetesService, userRepo *repositories.UserRepository, orgRepo *repositories.OrganizationRepository, roleRepo *repositories.RoleRepository) services.GroupSyncService {
+	if k8sService == nil {
+		return nil
+	}
+	return services.NewGroupSyncService(k8sService.GetClient(), userRepo, orgRepo, roleRepo, cfg.GroupSync.Mappings)
+}
+
+// newRouter creates the gin engine. Client addresses are only taken from the
+// X-Forwarded-For and X-Real-IP headers of the configured trusted proxies, so
+// other callers cannot choose the address rate limits are keyed on.
+func newRouter(cfg *config.Config) *gin.Engine {
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.API.TrustedProxies); err != nil {
+		// validateConfig checks the entries, so this only happens in tests
+		log.Printf("Warning: Invalid trusted proxies, trusting none: %v", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+	return router
+}
+
 // setupRoutes configures all API routes
 func (s *Server) setupRoutes() {
-	s.router = gin.New()
+	s.router = newRouter(s.config)
 
 	// Global middleware
 	s.router.Use(gin.Logger())
 	s.router.Use(gin.Recovery())
+	s.router.Use(s.drainMiddleware())
 	s.router.Use(s.corsMiddleware())
+	s.router.Use(messages.Middleware(s.messageCatalog))
 	s.router.Use(s.errorHandlerMiddleware())
+	if s.apiUsage != nil {
+		s.router.Use(s.apiUsageMiddleware())
+	}
+	s.router.Use(s.serverErrorMiddleware())
+	s.router.Use(s.requestLimitsMiddleware())
+	s.router.Use(s.consistencyMiddleware())
 
 	// Health endpoints
 	s.router.GET("/healthz", s.healthHandler)
 	s.router.GET("/readyz", s.readinessHandler)
 
+	// VCD API version discovery (public, used by SDKs before login)
+	s.router.GET("/api/versions", s.apiVersionsHandler)
+
 	// API version 1 routes
 	v1 := s.router.Group("/api/v1")
 	{
@@ -152,18 +333,42 @@ func (s *Server) setupRoutes() {
 
 	// CloudAPI endpoints (VMware Cloud Director compatible)
 	cloudAPIRoot := s.router.Group("/cloudapi/1.0.0")
+	cloudAPIRoot.Use(apiversion.Middleware())
+	cloudAPIRoot.Use(s.strictJSONMiddleware("cloudapi"))
 	{
 		// Public session endpoint (Basic Auth for login)
 		cloudAPIRoot.POST("/sessions", s.sessionHandlers.CreateSession) // POST /cloudapi/1.0.0/sessions - create session (login)
 
+		// Anonymous, rate-limited browsing of published catalogs for portals shown before login
+		if s.config.PublicCatalog.Enabled {
+			limiter := newClientRateLimiter(s.config.PublicCatalog.RequestsPerMinute, s.config.PublicCatalog.Burst)
+			public := cloudAPIRoot.Group("/public")
+			public.Use(limiter.middleware())
+			public.GET("/catalogs", s.publicCatalogs.ListPublicCatalogs)                              // GET /cloudapi/1.0.0/public/catalogs - list published catalogs
+			public.GET("/catalogs/:catalogUrn/catalogItems", s.publicCatalogs.ListPublicCatalogItems) // GET /cloudapi/1.0.0/public/catalogs/{catalogUrn}/catalogItems - list items of a published catalog
+		}
+
+		// Decisions on held operations, signed by the approval system instead of authenticated with a token
+		if s.approvals != nil {
+			cloudAPIRoot.POST("/approvals/:task_id", s.approvals.RecordDecision) // POST /cloudapi/1.0.0/approvals/{task_id} - approve or deny a held operation
+		}
+
 		// Protected CloudAPI endpoints (require JWT middleware)
 		cloudAPI := cloudAPIRoot.Group("/")
 		cloudAPI.Use(auth.JWTMiddleware(s.jwtManager))
 		{
+			// Provider discovery API (called by VCD UIs and SDKs at startup)
+			cloudAPI.GET("/site", s.providerHandlers.GetSite)                          // GET /cloudapi/1.0.0/site - get site information
+			cloudAPI.GET("/siteAssociations", s.providerHandlers.ListSiteAssociations) // GET /cloudapi/1.0.0/siteAssociations - list associated sites
+			cloudAPI.GET("/featureFlags", s.providerHandlers.ListFeatureFlags)         // GET /cloudapi/1.0.0/featureFlags - list API feature flags
+
 			// Session management
 			cloudAPI.GET("/sessions/:sessionId", s.sessionHandlers.GetCurrentSession) // GET /cloudapi/1.0.0/sessions/{sessionId} - get session
 			cloudAPI.DELETE("/sessions/:sessionId", s.sessionHandlers.DeleteSession)  // DELETE /cloudapi/1.0.0/sessions/{sessionId} - delete session
 
+			// Support access: System Administrators act as a tenant user with a short-lived, audited token
+			cloudAPI.POST("/sessions/actions/impersonate", handlers.RequireSystemAdmin(s.roleCache), s.sessionHandlers.ImpersonateSession) // POST /cloudapi/1.0.0/sessions/actions/impersonate - impersonate user
+
 			// Users API
 			cloudAPI.GET("/users", s.userHandlers.ListUsers)         // GET /cloudapi/1.0.0/users - list users
 			cloudAPI.POST("/users", s.userHandlers.CreateUser)       // POST /cloudapi/1.0.0/users - create user
@@ -171,66 +376,176 @@ func (s *Server) setupRoutes() {
 			cloudAPI.PUT("/users/:id", s.userHandlers.UpdateUser)    // PUT /cloudapi/1.0.0/users/{id} - update user
 			cloudAPI.DELETE("/users/:id", s.userHandlers.DeleteUser) // DELETE /cloudapi/1.0.0/users/{id} - delete user
 
+			// SSH Keys API endpoints
+			cloudAPI.GET("/users/:id/sshKeys", s.sshKeyHandlers.ListSSHKeys)            // GET /cloudapi/1.0.0/users/{id}/sshKeys - list SSH keys
+			cloudAPI.POST("/users/:id/sshKeys", s.sshKeyHandlers.CreateSSHKey)          // POST /cloudapi/1.0.0/users/{id}/sshKeys - register SSH key
+			cloudAPI.GET("/users/:id/sshKeys/:keyId", s.sshKeyHandlers.GetSSHKey)       // GET /cloudapi/1.0.0/users/{id}/sshKeys/{keyId} - get SSH key
+			cloudAPI.PUT("/users/:id/sshKeys/:keyId", s.sshKeyHandlers.UpdateSSHKey)    // PUT /cloudapi/1.0.0/users/{id}/sshKeys/{keyId} - update SSH key
+			cloudAPI.DELETE("/users/:id/sshKeys/:keyId", s.sshKeyHandlers.DeleteSSHKey) // DELETE /cloudapi/1.0.0/users/{id}/sshKeys/{keyId} - delete SSH key
+
 			// Roles API
 			cloudAPI.GET("/roles", s.roleHandlers.ListRoles)   // GET /cloudapi/1.0.0/roles - list roles
 			cloudAPI.GET("/roles/:id", s.roleHandlers.GetRole) // GET /cloudapi/1.0.0/roles/{id} - get role
 
 			// Organizations API
-			cloudAPI.GET("/orgs", s.orgHandlers.ListOrgs)         // GET /cloudapi/1.0.0/orgs - list organizations
-			cloudAPI.POST("/orgs", s.orgHandlers.CreateOrg)       // POST /cloudapi/1.0.0/orgs - create organization
-			cloudAPI.GET("/orgs/:id", s.orgHandlers.GetOrg)       // GET /cloudapi/1.0.0/orgs/{id} - get organization
-			cloudAPI.PUT("/orgs/:id", s.orgHandlers.UpdateOrg)    // PUT /cloudapi/1.0.0/orgs/{id} - update organization
-			cloudAPI.DELETE("/orgs/:id", s.orgHandlers.DeleteOrg) // DELETE /cloudapi/1.0.0/orgs/{id} - delete organization
+			cloudAPI.GET("/orgs", s.orgHandlers.ListOrgs)                          // GET /cloudapi/1.0.0/orgs - list organizations
+			cloudAPI.POST("/orgs", s.orgHandlers.CreateOrg)                        // POST /cloudapi/1.0.0/orgs - create organization
+			cloudAPI.GET("/orgs/:id", s.orgHandlers.GetOrg)                        // GET /cloudapi/1.0.0/orgs/{id} - get organization
+			cloudAPI.PUT("/orgs/:id", s.orgHandlers.UpdateOrg)                     // PUT /cloudapi/1.0.0/orgs/{id} - update organization
+			cloudAPI.DELETE("/orgs/:id", s.orgHandlers.DeleteOrg)                  // DELETE /cloudapi/1.0.0/orgs/{id} - delete organization
+			cloudAPI.GET("/orgs/:id/rollup", s.orgHandlers.GetOrgRollup)           // GET /cloudapi/1.0.0/orgs/{id}/rollup - aggregate counts across child organizations
+			cloudAPI.GET("/orgs/:id/usage/api", s.apiUsageHandlers.GetOrgAPIUsage) // GET /cloudapi/1.0.0/orgs/{id}/usage/api - API usage of the organization
+
+			// Organization branding API (tenant read, System Administrator write)
+			cloudAPI.GET("/orgs/:id/branding", s.orgHandlers.GetOrgBranding)                                              // GET /cloudapi/1.0.0/orgs/{id}/branding - get organization branding
+			cloudAPI.PUT("/orgs/:id/branding", handlers.RequireSystemAdmin(s.roleCache), s.orgHandlers.UpdateOrgBranding) // PUT /cloudapi/1.0.0/orgs/{id}/branding - update organization branding
 
 			// VDCs API (Public - read-only access for authenticated users)
 			cloudAPI.GET("/vdcs", s.vdcPublicHandlers.ListVDCs)       // GET /cloudapi/1.0.0/vdcs - list accessible VDCs
 			cloudAPI.GET("/vdcs/:vdc_id", s.vdcPublicHandlers.GetVDC) // GET /cloudapi/1.0.0/vdcs/{vdc_id} - get VDC
 
+			// VDC management API (guarded by rights; aliases of the /api/admin VDC routes)
+			cloudAPI.POST("/vdcs", handlers.RequireRight(s.roleCache, models.RightOrgVdcCreate), s.vdcHandlers.CloudAPICreateVDC)           // POST /cloudapi/1.0.0/vdcs - create VDC
+			cloudAPI.PUT("/vdcs/:vdc_id", handlers.RequireRight(s.roleCache, models.RightOrgVdcEdit), s.vdcHandlers.CloudAPIUpdateVDC)      // PUT /cloudapi/1.0.0/vdcs/{vdc_id} - update VDC
+			cloudAPI.DELETE("/vdcs/:vdc_id", handlers.RequireRight(s.roleCache, models.RightOrgVdcDelete), s.vdcHandlers.CloudAPIDeleteVDC) // DELETE /cloudapi/1.0.0/vdcs/{vdc_id} - delete VDC
+
 			// Catalogs API
 			cloudAPI.GET("/catalogs", s.catalogHandlers.ListCatalogs)                 // GET /cloudapi/1.0.0/catalogs - list catalogs
 			cloudAPI.POST("/catalogs", s.catalogHandlers.CreateCatalog)               // POST /cloudapi/1.0.0/catalogs - create catalog
 			cloudAPI.GET("/catalogs/:catalogUrn", s.catalogHandlers.GetCatalog)       // GET /cloudapi/1.0.0/catalogs/{catalogUrn} - get catalog
 			cloudAPI.DELETE("/catalogs/:catalogUrn", s.catalogHandlers.DeleteCatalog) // DELETE /cloudapi/1.0.0/catalogs/{catalogUrn} - delete catalog
 
+			// Catalog sharing API (VCD controlAccess)
+			cloudAPI.GET("/catalogs/:catalogUrn/controlAccess", s.catalogHandlers.GetControlAccess)         // GET /cloudapi/1.0.0/catalogs/{catalogUrn}/controlAccess - get catalog sharing settings
+			cloudAPI.POST("/catalogs/:catalogUrn/action/controlAccess", s.catalogHandlers.SetControlAccess) // POST /cloudapi/1.0.0/catalogs/{catalogUrn}/action/controlAccess - replace catalog sharing settings
+
+			// Catalog source API (remote Template import)
+			cloudAPI.GET("/catalogs/:catalogUrn/source", s.catalogHandlers.GetCatalogSource)                                                          // GET /cloudapi/1.0.0/catalogs/{catalogUrn}/source - get catalog source and sync status
+			cloudAPI.PUT("/catalogs/:catalogUrn/source", handlers.RequireSystemAdmin(s.roleCache), s.catalogHandlers.SetCatalogSource)                // PUT /cloudapi/1.0.0/catalogs/{catalogUrn}/source - set catalog source
+			cloudAPI.DELETE("/catalogs/:catalogUrn/source", handlers.RequireSystemAdmin(s.roleCache), s.catalogHandlers.DeleteCatalogSource)          // DELETE /cloudapi/1.0.0/catalogs/{catalogUrn}/source - remove catalog source
+			cloudAPI.POST("/catalogs/:catalogUrn/source/actions/sync", handlers.RequireSystemAdmin(s.roleCache), s.catalogHandlers.SyncCatalogSource) // POST /cloudapi/1.0.0/catalogs/{catalogUrn}/source/actions/sync - sync catalog source now
+
 			// Catalog Items API
 			cloudAPI.GET("/catalogs/:catalogUrn/catalogItems", s.catalogItemHandlers.ListCatalogItems)       // GET /cloudapi/1.0.0/catalogs/{catalogUrn}/catalogItems - list catalog items
 			cloudAPI.GET("/catalogs/:catalogUrn/catalogItems/:itemId", s.catalogItemHandlers.GetCatalogItem) // GET /cloudapi/1.0.0/catalogs/{catalogUrn}/catalogItems/{itemId} - get catalog item
 
+			// Pinned catalog items, whose images are prewarmed in the VDC namespace
+			cloudAPI.GET("/vdcs/:vdc_id/pinnedCatalogItems", s.catalogPinHandlers.ListPinnedCatalogItems)                                                                  // GET /cloudapi/1.0.0/vdcs/{vdc_id}/pinnedCatalogItems - list pinned catalog items and their import status
+			cloudAPI.PUT("/vdcs/:vdc_id/pinnedCatalogItems/:itemId", handlers.RequireRight(s.roleCache, models.RightOrgVdcEdit), s.catalogPinHandlers.PinCatalogItem)      // PUT /cloudapi/1.0.0/vdcs/{vdc_id}/pinnedCatalogItems/{itemId} - pin catalog item
+			cloudAPI.DELETE("/vdcs/:vdc_id/pinnedCatalogItems/:itemId", handlers.RequireRight(s.roleCache, models.RightOrgVdcEdit), s.catalogPinHandlers.UnpinCatalogItem) // DELETE /cloudapi/1.0.0/vdcs/{vdc_id}/pinnedCatalogItems/{itemId} - unpin catalog item
+
 			// VM Creation API
 			cloudAPI.POST("/vdcs/:vdc_id/actions/instantiateTemplate", s.vmCreationHandlers.InstantiateTemplate) // POST /cloudapi/1.0.0/vdcs/{vdc_id}/actions/instantiateTemplate - create vApp from template
 
 			// vApps API
-			cloudAPI.GET("/vdcs/:vdc_id/vapps", s.vappHandlers.ListVApps) // GET /cloudapi/1.0.0/vdcs/{vdc_id}/vapps - list vApps in VDC
-			cloudAPI.GET("/vapps/:vapp_id", s.vappHandlers.GetVApp)       // GET /cloudapi/1.0.0/vapps/{vapp_id} - get vApp
-			cloudAPI.DELETE("/vapps/:vapp_id", s.vappHandlers.DeleteVApp) // DELETE /cloudapi/1.0.0/vapps/{vapp_id} - delete vApp
+			cloudAPI.GET("/vdcs/:vdc_id/vapps", s.vappHandlers.ListVApps)   // GET /cloudapi/1.0.0/vdcs/{vdc_id}/vapps - list vApps in VDC
+			cloudAPI.GET("/vapps/:vapp_id", s.vappHandlers.GetVApp)         // GET /cloudapi/1.0.0/vapps/{vapp_id} - get vApp
+			cloudAPI.DELETE("/vapps/:vapp_id", s.vappHandlers.DeleteVApp)   // DELETE /cloudapi/1.0.0/vapps/{vapp_id} - delete vApp
+			cloudAPI.GET("/vapps/:vapp_id/vms", s.vappHandlers.ListVAppVMs) // GET /cloudapi/1.0.0/vapps/{vapp_id}/vms - list VMs in vApp
+
+			// vApp startup ordering API
+			cloudAPI.GET("/vapps/:vapp_id/startupSection", s.vappHandlers.GetStartupSection)    // GET /cloudapi/1.0.0/vapps/{vapp_id}/startupSection - get VM start order
+			cloudAPI.PUT("/vapps/:vapp_id/startupSection", s.vappHandlers.UpdateStartupSection) // PUT /cloudapi/1.0.0/vapps/{vapp_id}/startupSection - set VM start order and delays
+			cloudAPI.POST("/vapps/:vapp_id/actions/powerOn", s.vappHandlers.PowerOnVApp)        // POST /cloudapi/1.0.0/vapps/{vapp_id}/actions/powerOn - power on VMs in start order
+
+			// Recreate the TemplateInstance of a failed vApp
+			cloudAPI.POST("/vapps/:vapp_id/actions/retry", s.vappHandlers.RetryVAppInstantiation) // POST /cloudapi/1.0.0/vapps/{vapp_id}/actions/retry - retry a failed instantiation
+
+			// vApp backup policy API
+			cloudAPI.GET("/vapps/:vapp_id/backupPolicy", s.vappHandlers.GetBackupPolicy)    // GET /cloudapi/1.0.0/vapps/{vapp_id}/backupPolicy - get VM backup policy
+			cloudAPI.PUT("/vapps/:vapp_id/backupPolicy", s.vappHandlers.UpdateBackupPolicy) // PUT /cloudapi/1.0.0/vapps/{vapp_id}/backupPolicy - set or inherit VM backup policy
 
 			// VMs API
-			cloudAPI.GET("/vms/:vm_id", s.vmHandlers.GetVM) // GET /cloudapi/1.0.0/vms/{vm_id} - get VM
+			cloudAPI.GET("/vms/:vm_id", s.vmHandlers.GetVM)       // GET /cloudapi/1.0.0/vms/{vm_id} - get VM
+			cloudAPI.PATCH("/vms/:vm_id", s.vmHandlers.UpdateVM)  // PATCH /cloudapi/1.0.0/vms/{vm_id} - update VM name/description
+			cloudAPI.PUT("/vms/:vm_id", s.vmHandlers.ReplaceVM)   // PUT /cloudapi/1.0.0/vms/{vm_id} - replace VM name/description
+			cloudAPI.DELETE("/vms/:vm_id", s.vmHandlers.DeleteVM) // DELETE /cloudapi/1.0.0/vms/{vm_id} - delete VM and its VirtualMachine
+
+			// VM diagnostics API
+			cloudAPI.GET("/vms/:vm_id/diagnostics", s.vmHandlers.GetVMDiagnostics)         // GET /cloudapi/1.0.0/vms/{vm_id}/diagnostics - VMI events and launcher pod conditions
+			cloudAPI.GET("/vms/:vm_id/console/log", s.vmHandlers.GetVMConsoleLog)          // GET /cloudapi/1.0.0/vms/{vm_id}/console/log - guest serial console log
+			cloudAPI.GET("/vms/:vm_id/backupStatus", s.vmHandlers.GetVMBackupStatus)       // GET /cloudapi/1.0.0/vms/{vm_id}/backupStatus - Velero backups of the VM
+			cloudAPI.GET("/vms/:vm_id/network/flows", s.vmHandlers.GetVMNetworkFlows)      // GET /cloudapi/1.0.0/vms/{vm_id}/network/flows - top talkers over the last hour
+			cloudAPI.GET("/vms/:vm_id/securityProfile", s.vmHandlers.GetVMSecurityProfile) // GET /cloudapi/1.0.0/vms/{vm_id}/securityProfile - privileged settings checked against the org's policy
+			cloudAPI.POST("/vms/:vm_id/actions/export", s.vmHandlers.ExportVM)             // POST /cloudapi/1.0.0/vms/{vm_id}/actions/export - export VM disks for download, tracked as a task
+			// VM sections in the VCD shape
+			cloudAPI.GET("/vms/:vm_id/virtualHardwareSection", s.vmHandlers.GetVirtualHardwareSection)       // GET /cloudapi/1.0.0/vms/{vm_id}/virtualHardwareSection - CPU, memory, disks and NICs
+			cloudAPI.GET("/vms/:vm_id/guestCustomizationSection", s.vmHandlers.GetGuestCustomizationSection) // GET /cloudapi/1.0.0/vms/{vm_id}/guestCustomizationSection - guest customization settings
+
+			// Notifications API
+			cloudAPI.GET("/notifications", s.notificationHandlers.StreamNotifications) // GET /cloudapi/1.0.0/notifications - stream entity change events (SSE)
+
+			// Platform status for tenant portals
+			cloudAPI.GET("/systemStatus", s.systemStatusHandlers.GetSystemStatus) // GET /cloudapi/1.0.0/systemStatus - coarse health of the API, provisioning and networking
+
+			// Tasks API
+			cloudAPI.GET("/tasks/:task_id", s.taskHandlers.GetTask) // GET /cloudapi/1.0.0/tasks/{task_id} - get task, optionally waiting for a status
 
 			// VM Power Management API (only register if k8sService is available)
 			if s.k8sService != nil {
 				cloudAPI.POST("/vms/:vm_id/actions/powerOn", s.powerMgmtHandlers.PowerOn)   // POST /cloudapi/1.0.0/vms/{vm_id}/actions/powerOn - power on VM
 				cloudAPI.POST("/vms/:vm_id/actions/powerOff", s.powerMgmtHandlers.PowerOff) // POST /cloudapi/1.0.0/vms/{vm_id}/actions/powerOff - power off VM
+				cloudAPI.POST("/vms/:vm_id/actions/reboot", s.powerMgmtHandlers.Reboot)     // POST /cloudapi/1.0.0/vms/{vm_id}/actions/reboot - reboot the guest OS
+				cloudAPI.POST("/vms/:vm_id/actions/reset", s.powerMgmtHandlers.Reset)       // POST /cloudapi/1.0.0/vms/{vm_id}/actions/reset - hard reset VM
 			}
 		}
 
 	}
 
-	// Admin API endpoints (System Administrator only)
+	// Admin API endpoints (System Administrator only). The VDC routes are kept as
+	// aliases of the rights-guarded CloudAPI VDC management routes.
 	adminAPIRoot := s.router.Group("/api/admin")
+	adminAPIRoot.Use(apiversion.Middleware())
+	adminAPIRoot.Use(s.strictJSONMiddleware("admin"))
 	adminAPIRoot.Use(auth.JWTMiddleware(s.jwtManager))
-	adminAPIRoot.Use(handlers.RequireSystemAdmin(s.userRepo))
+	adminAPIRoot.Use(handlers.RequireSystemAdmin(s.roleCache))
 	{
 		// VDC Management API (System Administrator only)
-		adminAPIRoot.GET("/org/:orgId/vdcs", s.vdcHandlers.ListVDCs)            // GET /api/admin/org/{orgId}/vdcs - list VDCs in organization
-		adminAPIRoot.POST("/org/:orgId/vdcs", s.vdcHandlers.CreateVDC)          // POST /api/admin/org/{orgId}/vdcs - create VDC
-		adminAPIRoot.GET("/org/:orgId/vdcs/:vdcId", s.vdcHandlers.GetVDC)       // GET /api/admin/org/{orgId}/vdcs/{vdcId} - get VDC
-		adminAPIRoot.PUT("/org/:orgId/vdcs/:vdcId", s.vdcHandlers.UpdateVDC)    // PUT /api/admin/org/{orgId}/vdcs/{vdcId} - update VDC
-		adminAPIRoot.DELETE("/org/:orgId/vdcs/:vdcId", s.vdcHandlers.DeleteVDC) // DELETE /api/admin/org/{orgId}/vdcs/{vdcId} - delete VDC
+		adminAPIRoot.GET("/org/:orgId/vdcs", s.vdcHandlers.ListVDCs)                                   // GET /api/admin/org/{orgId}/vdcs - list VDCs in organization
+		adminAPIRoot.POST("/org/:orgId/vdcs", s.vdcHandlers.CreateVDC)                                 // POST /api/admin/org/{orgId}/vdcs - create VDC
+		adminAPIRoot.GET("/org/:orgId/vdcs/:vdcId", s.vdcHandlers.GetVDC)                              // GET /api/admin/org/{orgId}/vdcs/{vdcId} - get VDC
+		adminAPIRoot.PUT("/org/:orgId/vdcs/:vdcId", s.vdcHandlers.UpdateVDC)                           // PUT /api/admin/org/{orgId}/vdcs/{vdcId} - update VDC
+		adminAPIRoot.DELETE("/org/:orgId/vdcs/:vdcId", s.vdcHandlers.DeleteVDC)                        // DELETE /api/admin/org/{orgId}/vdcs/{vdcId} - delete VDC
+		adminAPIRoot.GET("/org/:orgId/vdcs/:vdcId/infrastructure", s.vdcHandlers.GetVDCInfrastructure) // GET /api/admin/org/{orgId}/vdcs/{vdcId}/infrastructure - live namespace, quotas and policies
+
+		// System settings (read-only stubs for VCD admin clients)
+		adminAPIRoot.GET("/extension/settings", s.providerHandlers.GetSystemSettings)          // GET /api/admin/extension/settings - get system settings
+		adminAPIRoot.GET("/extension/settings/general", s.providerHandlers.GetGeneralSettings) // GET /api/admin/extension/settings/general - get general settings
+
+		// API usage per user and day
+		adminAPIRoot.GET("/usage/api", s.apiUsageHandlers.ListAPIUsage) // GET /api/admin/usage/api - list API usage
+
+		// OpenShift Group sync dry run
+		adminAPIRoot.GET("/groupSync/report", s.groupSyncHandlers.GetGroupSyncReport) // GET /api/admin/groupSync/report - changes the group sync would make
+
+		// Bulk user onboarding from CSV or SCIM
+		adminAPIRoot.POST("/users/import", s.userHandlers.ImportUsers) // POST /api/admin/users/import - create users from a CSV or SCIM file
+
+		// Declarative tenant specs
+		adminAPIRoot.POST("/tenants/apply", s.tenantHandlers.ApplyTenants)  // POST /api/admin/tenants/apply - reconcile organizations with a tenant spec
+		adminAPIRoot.GET("/tenants/export", s.tenantHandlers.ExportTenants) // GET /api/admin/tenants/export - describe organizations as a tenant spec
+
+		// Controller heartbeats and builds
+		adminAPIRoot.GET("/system/components", s.componentHandlers.ListComponents) // GET /api/admin/system/components - list controller processes
+
+		// Status override shown to tenants, such as planned maintenance notices
+		adminAPIRoot.GET("/system/statusOverride", s.systemStatusHandlers.GetStatusOverride)      // GET /api/admin/system/statusOverride - get the status override
+		adminAPIRoot.PUT("/system/statusOverride", s.systemStatusHandlers.SetStatusOverride)      // PUT /api/admin/system/statusOverride - set the status override
+		adminAPIRoot.DELETE("/system/statusOverride", s.systemStatusHandlers.ClearStatusOverride) // DELETE /api/admin/system/statusOverride - report the measured status again
+
+		// Entity changes for external inventories
+		adminAPIRoot.GET("/changes", s.changeHandlers.ListChanges) // GET /api/admin/changes - entities changed since a time or cursor
+
+		// VMs archived after deletion
+		adminAPIRoot.GET("/archive/vms", s.vmArchiveHandlers.ListArchivedVMs)                        // GET /api/admin/archive/vms - list archived VMs
+		adminAPIRoot.GET("/archive/vms/:id", s.vmArchiveHandlers.GetArchivedVM)                      // GET /api/admin/archive/vms/{id} - get an archived VM and its tasks
+		adminAPIRoot.POST("/archive/vms/:id/actions/restore", s.vmArchiveHandlers.RestoreArchivedVM) // POST /api/admin/archive/vms/{id}/actions/restore - restore an archived VM for viewing
 	}
 
 	// Legacy API endpoints (DEPRECATED - use CloudAPI endpoints instead)
 	apiRoot := s.router.Group("/api")
+	apiRoot.Use(apiversion.Middleware())
+	apiRoot.Use(s.strictJSONMiddleware("legacy"))
 	{
 		// Protected legacy endpoints (require JWT middleware)
 		protected := apiRoot.Group("/")
@@ -303,12 +618,70 @@ func (s *Server) Start() error {
 	return s.httpServer.ListenAndServe()
 }
 
-// Stop gracefully stops the HTTP server
+// Stop gracefully stops the API server. New mutating requests are rejected
+// first while reads keep being served, then in-flight mutating requests and
+// background work started by handlers are given until the ctx deadline to finish.
+// Background work still running then records its state and returns before the
+// HTTP server is shut down.
 func (s *Server) Stop(ctx context.Context) error {
 	log.Println("Shutting down API server...")
+	s.draining.Store(true)
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for s.inFlight.Load() > 0 && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	if remaining := s.inFlight.Load(); remaining > 0 {
+		log.Printf("Warning: %d mutating requests still in flight at the shutdown deadline", remaining)
+	}
+
+	if running := s.background.Running(); running > 0 {
+		log.Printf("Waiting for %d background operations", running)
+	}
+	if err := s.background.Shutdown(ctx); err != nil {
+		log.Printf("Warning: Interrupted background operations at the shutdown deadline")
+	}
+
+	if s.httpServer == nil {
+		return nil
+	}
 	return s.httpServer.Shutdown(ctx)
 }
 
+// APIUsageRecorder returns the recorder of per-user API usage, or nil when
+// usage tracking is disabled
+func (s *Server) APIUsageRecorder() *services.APIUsageRecorder {
+	return s.apiUsage
+}
+
+// VMArchiver returns the archiver of deleted VM records, or nil when archiving
+// is disabled
+func (s *Server) VMArchiver() *services.VMArchiver {
+	return s.vmArchiver
+}
+
+// SetCommandDispatcher hands VM power operations to the vm-controller over the
+// internal command channel
+func (s *Server) SetCommandDispatcher(dispatcher handlers.VMCommandDispatcher) {
+	s.powerMgmtHandlers.SetCommandDispatcher(dispatcher)
+}
+
+// SetTemplateAccessChecker reports the template namespace and permission checks
+// through /readyz and the components endpoint
+func (s *Server) SetTemplateAccessChecker(checker *services.TemplateAccessChecker) {
+	s.templateAccess = checker
+	s.componentHandlers.SetTemplateAccess(checker)
+}
+
+// EventBus returns the server's internal event bus
+func (s *Server) EventBus() *events.Bus {
+	return s.eventBus
+}
+
 // GetRouter returns the gin router (useful for testing)
 func (s *Server) GetRouter() *gin.Engine {
 	return s.router
@@ -343,11 +716,34 @@ func (s *Server) readinessHandler(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"ready":     true,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"services":  services,
-	})
+	// Template namespaces and permissions are checked in the background
+	response := gin.H{}
+	if s.templateAccess != nil {
+		if status, checked := s.templateAccess.Status(); !checked {
+			services["templates"] = "pending"
+		} else if status.Ready {
+			services["templates"] = "ready"
+		} else {
+			services["templates"] = "unavailable"
+			response["templateAccess"] = status
+		}
+	}
+
+	// Draining replicas are taken out of load balancing
+	if s.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"ready":     false,
+			"draining":  true,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"services":  services,
+		})
+		return
+	}
+
+	response["ready"] = true
+	response["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+	response["services"] = services
+	c.JSON(http.StatusOK, response)
 }
 
 // versionHandler handles version requests
@@ -2,12 +2,15 @@ package types
 
 // Page represents a paginated response following VMware Cloud Director API specification
 type Page[T any] struct {
-	ResultTotal  int64 `json:"resultTotal"`
-	PageCount    int   `json:"pageCount"`
-	Page         int   `json:"page"`
-	PageSize     int   `json:"pageSize"`
-	Associations []any `json:"associations"`
-	Values       []T   `json:"values"`
+	ResultTotal int64 `json:"resultTotal"`
+	PageCount   int   `json:"pageCount"`
+	Page        int   `json:"page"`
+	PageSize    int   `json:"pageSize"`
+	// NextCursor continues a cursor-paginated list; it is empty on the last
+	// page and in page-based listings
+	NextCursor   string `json:"nextCursor,omitempty"`
+	Associations []any  `json:"associations"`
+	Values       []T    `json:"values"`
 }
 
 // NewPage creates a new paginated response
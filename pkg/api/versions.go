@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/apiversion"
+)
+
+// SupportedVersions is the VCD /api/versions response
+type SupportedVersions struct {
+	XMLName     xml.Name      `xml:"SupportedVersions" json:"-"`
+	Xmlns       string        `xml:"xmlns,attr" json:"-"`
+	VersionInfo []VersionInfo `xml:"VersionInfo" json:"versionInfo"`
+}
+
+// VersionInfo describes a single supported API version
+type VersionInfo struct {
+	Deprecated bool   `xml:"deprecated,attr" json:"deprecated"`
+	Version    string `xml:"Version" json:"version"`
+	LoginURL   string `xml:"LoginUrl" json:"loginUrl"`
+}
+
+// apiVersionsHandler lists the supported API versions. VCD SDKs call this
+// before logging in, some of them asking for XML.
+func (s *Server) apiVersionsHandler(c *gin.Context) {
+	response := SupportedVersions{
+		Xmlns: "http://www.vmware.com/vcloud/versions",
+	}
+	for _, v := range apiversion.Supported {
+		response.VersionInfo = append(response.VersionInfo, VersionInfo{
+			Version:  v.String(),
+			LoginURL: "/cloudapi/1.0.0/sessions",
+		})
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), "xml") {
+		c.XML(http.StatusOK, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+// ErrAccessDenied indicates the resource exists but the user may not access it
+var ErrAccessDenied = errors.New("access denied")
+
+// AccessControl decides whether a user may access organization-scoped entities.
+// VDCs are visible to System Administrators and to members of the owning
+// organization; vApps and VMs inherit access from the VDC they belong to.
+//
+// All checks report a missing entity as gorm.ErrRecordNotFound and an entity the
+// user cannot access as ErrAccessDenied. A VDC the user cannot access is reported
+// as not found, so VDC existence is not disclosed to other organizations.
+type AccessControl struct {
+	vdcRepo  *repositories.VDCRepository
+	vappRepo *repositories.VAppRepository
+	vmRepo   *repositories.VMRepository
+}
+
+// NewAccessControl creates a new AccessControl
+func NewAccessControl(vdcRepo *repositories.VDCRepository, vappRepo *repositories.VAppRepository, vmRepo *repositories.VMRepository) *AccessControl {
+	return &AccessControl{
+		vdcRepo:  vdcRepo,
+		vappRepo: vappRepo,
+		vmRepo:   vmRepo,
+	}
+}
+
+// CanAccessVDC returns the VDC if the user may access it
+func (a *AccessControl) CanAccessVDC(ctx context.Context, userID, vdcID string) (*models.VDC, error) {
+	return a.vdcRepo.GetAccessibleVDC(ctx, userID, vdcID)
+}
+
+// CanAccessVApp returns the vApp, with its VDC loaded, if the user may access it
+func (a *AccessControl) CanAccessVApp(ctx context.Context, userID, vappID string) (*models.VApp, error) {
+	vapp, err := a.vappRepo.GetWithVDC(ctx, vappID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.checkVDC(ctx, userID, vapp.VDCID); err != nil {
+		return nil, err
+	}
+	return vapp, nil
+}
+
+// CanManageVM returns the VM, with its vApp and VDC loaded, if the user may
+// view and operate it
+func (a *AccessControl) CanManageVM(ctx context.Context, userID, vmID string) (*models.VM, error) {
+	vm, err := a.vmRepo.GetWithVAppContext(ctx, vmID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A VM whose vApp is gone cannot be attributed to an organization
+	if vm.VApp == nil {
+		return nil, ErrAccessDenied
+	}
+
+	if err := a.checkVDC(ctx, userID, vm.VApp.VDCID); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// checkVDC maps an inaccessible parent VDC to ErrAccessDenied
+func (a *AccessControl) checkVDC(ctx context.Context, userID, vdcID string) error {
+	_, err := a.vdcRepo.GetAccessibleVDC(ctx, userID, vdcID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccessDenied
+		}
+		return err
+	}
+	return nil
+}
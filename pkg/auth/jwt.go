@@ -21,6 +21,10 @@ type Claims struct {
 	SessionID      *string `json:"session_id,omitempty"`
 	OrganizationID *string `json:"organization_id,omitempty"`
 	Role           *string `json:"role,omitempty"`
+	// ImpersonatorID and ImpersonatorUsername identify the System Administrator
+	// acting as this user; they are only set on impersonation tokens
+	ImpersonatorID       *string `json:"impersonator_id,omitempty"`
+	ImpersonatorUsername *string `json:"impersonator_username,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -28,6 +32,12 @@ type Claims struct {
 type JWTManager struct {
 	secretKey     string
 	tokenDuration time.Duration
+
+	// issuer and audience are set on issued tokens and required of verified
+	// ones when not empty
+	issuer    string
+	audience  string
+	clockSkew time.Duration
 }
 
 // NewJWTManager creates a new JWT manager with the specified secret key and token duration
@@ -38,16 +48,39 @@ func NewJWTManager(secretKey string, tokenDuration time.Duration) *JWTManager {
 	}
 }
 
+// SetClaimsValidation makes issued tokens carry the issuer (iss) and audience
+// (aud) claims and rejects tokens whose issuer differs or that are not meant
+// for the audience, so installations sharing a secret cannot use each other's
+// tokens. Empty values are neither set nor checked. clockSkew is how far the
+// clocks of the installations behind one SSO may differ when checking a
+// token's expiry and not-before times.
+func (manager *JWTManager) SetClaimsValidation(issuer, audience string, clockSkew time.Duration) {
+	manager.issuer = issuer
+	manager.audience = audience
+	manager.clockSkew = clockSkew
+}
+
+// registeredClaims returns the standard claims of a token valid for ttl from now
+func (manager *JWTManager) registeredClaims(ttl time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    manager.issuer,
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+	if manager.audience != "" {
+		claims.Audience = jwt.ClaimStrings{manager.audience}
+	}
+	return claims
+}
+
 // Generate creates a new JWT token for the specified user without organization context
 func (manager *JWTManager) Generate(userID string, username string) (string, error) {
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(manager.tokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+		UserID:           userID,
+		Username:         username,
+		RegisteredClaims: manager.registeredClaims(manager.tokenDuration),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -57,15 +90,11 @@ func (manager *JWTManager) Generate(userID string, username string) (string, err
 // GenerateWithRole creates a new JWT token for the specified user with organization and role context
 func (manager *JWTManager) GenerateWithRole(userID string, username string, organizationID string, role string) (string, error) {
 	claims := &Claims{
-		UserID:         userID,
-		Username:       username,
-		OrganizationID: &organizationID,
-		Role:           &role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(manager.tokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+		UserID:           userID,
+		Username:         username,
+		OrganizationID:   &organizationID,
+		Role:             &role,
+		RegisteredClaims: manager.registeredClaims(manager.tokenDuration),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -75,22 +104,56 @@ func (manager *JWTManager) GenerateWithRole(userID string, username string, orga
 // GenerateWithSessionID creates a new JWT token for the specified user with session context
 func (manager *JWTManager) GenerateWithSessionID(userID string, username string, sessionID string) (string, error) {
 	claims := &Claims{
-		UserID:    userID,
-		Username:  username,
-		SessionID: &sessionID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(manager.tokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+		UserID:           userID,
+		Username:         username,
+		SessionID:        &sessionID,
+		RegisteredClaims: manager.registeredClaims(manager.tokenDuration),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(manager.secretKey))
+}
+
+// GenerateImpersonation creates a short-lived JWT token that lets an administrator
+// act as the specified user. The token expires after ttl, or the manager's token
+// duration if that is shorter.
+func (manager *JWTManager) GenerateImpersonation(userID string, username string, sessionID string, impersonatorID string, impersonatorUsername string, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > manager.tokenDuration {
+		ttl = manager.tokenDuration
+	}
+	claims := &Claims{
+		UserID:               userID,
+		Username:             username,
+		SessionID:            &sessionID,
+		ImpersonatorID:       &impersonatorID,
+		ImpersonatorUsername: &impersonatorUsername,
+		RegisteredClaims:     manager.registeredClaims(ttl),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(manager.secretKey))
 }
 
-// Verify validates a JWT token and returns the parsed claims if valid
+// IsImpersonation reports whether the claims belong to an impersonation token
+func (c *Claims) IsImpersonation() bool {
+	return c.ImpersonatorID != nil
+}
+
+// Verify validates a JWT token and returns the parsed claims if valid. Tokens
+// without an expiry, or with another issuer or audience than configured, are
+// invalid.
 func (manager *JWTManager) Verify(tokenString string) (*Claims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(manager.clockSkew),
+	}
+	if manager.issuer != "" {
+		options = append(options, jwt.WithIssuer(manager.issuer))
+	}
+	if manager.audience != "" {
+		options = append(options, jwt.WithAudience(manager.audience))
+	}
+
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&Claims{},
@@ -100,9 +163,17 @@ func (manager *JWTManager) Verify(tokenString string) (*Claims, error) {
 			}
 			return []byte(manager.secretKey), nil
 		},
+		options...,
 	)
 
 	if err != nil {
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, ErrExpiredToken
+		case errors.Is(err, jwt.ErrTokenInvalidIssuer), errors.Is(err, jwt.ErrTokenInvalidAudience),
+			errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+			return nil, ErrInvalidToken
+		}
 		return nil, err
 	}
 
@@ -111,9 +182,5 @@ func (manager *JWTManager) Verify(tokenString string) (*Claims, error) {
 		return nil, ErrInvalidToken
 	}
 
-	if time.Now().After(claims.ExpiresAt.Time) {
-		return nil, ErrExpiredToken
-	}
-
 	return claims, nil
 }
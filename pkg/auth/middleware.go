@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -58,10 +59,33 @@ func JWTMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
 		if claims.SessionID != nil {
 			c.Set(SessionContextKey, *claims.SessionID)
 		}
+		if claims.IsImpersonation() {
+			logImpersonatedRequest(c, claims)
+		}
 		c.Next()
 	}
 }
 
+// logImpersonatedRequest records a request made by an administrator acting as a
+// tenant user so support activity can be audited
+func logImpersonatedRequest(c *gin.Context, claims *Claims) {
+	slog.Info("audit: impersonated request",
+		"impersonator_id", *claims.ImpersonatorID,
+		"impersonator", derefOrEmpty(claims.ImpersonatorUsername),
+		"user_id", claims.UserID,
+		"user", claims.Username,
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+	)
+}
+
+func derefOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 // OptionalJWTMiddleware creates a Gin middleware that extracts JWT claims if present but doesn't require authentication
 func OptionalJWTMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -74,6 +98,9 @@ func OptionalJWTMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
 				if claims.SessionID != nil {
 					c.Set(SessionContextKey, *claims.SessionID)
 				}
+				if claims.IsImpersonation() {
+					logImpersonatedRequest(c, claims)
+				}
 			}
 		}
 		c.Next()
@@ -0,0 +1,142 @@
+// Package password implements pluggable password hashing.
+//
+// Hashes are stored as self-describing strings so that several algorithms can
+// coexist in the database. A Manager hashes new passwords with its preferred
+// Hasher, verifies existing hashes with whichever Hasher recognizes them, and
+// reports hashes that should be upgraded on the next successful login.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported hashing algorithms
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// ErrUnknownHashFormat is returned when no configured Hasher recognizes a stored hash
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Hasher hashes and verifies passwords for one algorithm
+type Hasher interface {
+	// Hash returns an encoded hash of password
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash
+	Verify(password, encoded string) (bool, error)
+	// Recognizes reports whether encoded was produced by this algorithm
+	Recognizes(encoded string) bool
+	// NeedsRehash reports whether encoded uses weaker parameters than the Hasher is configured with
+	NeedsRehash(encoded string) bool
+}
+
+// Manager hashes with a preferred algorithm while still verifying hashes from other algorithms
+type Manager struct {
+	preferred Hasher
+	others    []Hasher
+}
+
+// NewManager creates a Manager that hashes with preferred and also verifies hashes produced by others
+func NewManager(preferred Hasher, others ...Hasher) *Manager {
+	return &Manager{
+		preferred: preferred,
+		others:    others,
+	}
+}
+
+// NewManagerForAlgorithm creates a Manager that hashes with the named algorithm
+// and verifies hashes from every supported algorithm
+func NewManagerForAlgorithm(algorithm string, argon2idParams Argon2idParams, bcryptCost int) (*Manager, error) {
+	if err := argon2idParams.Validate(); err != nil {
+		return nil, err
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	argon2idHasher := NewArgon2id(argon2idParams)
+	bcryptHasher := NewBcrypt(bcryptCost)
+
+	switch algorithm {
+	case AlgorithmArgon2id:
+		return NewManager(argon2idHasher, bcryptHasher), nil
+	case AlgorithmBcrypt:
+		return NewManager(bcryptHasher, argon2idHasher), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hashing algorithm %q", algorithm)
+	}
+}
+
+// Hash hashes password with the preferred algorithm
+func (m *Manager) Hash(password string) (string, error) {
+	return m.preferred.Hash(password)
+}
+
+// Verify checks password against an encoded hash from any known algorithm
+func (m *Manager) Verify(password, encoded string) (bool, error) {
+	hasher := m.hasherFor(encoded)
+	if hasher == nil {
+		return false, ErrUnknownHashFormat
+	}
+	return hasher.Verify(password, encoded)
+}
+
+// NeedsRehash reports whether encoded should be replaced with a hash from the
+// preferred algorithm and parameters
+func (m *Manager) NeedsRehash(encoded string) bool {
+	if !m.preferred.Recognizes(encoded) {
+		return true
+	}
+	return m.preferred.NeedsRehash(encoded)
+}
+
+func (m *Manager) hasherFor(encoded string) Hasher {
+	if m.preferred.Recognizes(encoded) {
+		return m.preferred
+	}
+	for _, h := range m.others {
+		if h.Recognizes(encoded) {
+			return h
+		}
+	}
+	return nil
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultManager = NewManager(NewArgon2id(DefaultArgon2idParams), NewBcrypt(DefaultBcryptCost))
+)
+
+// SetDefault replaces the process-wide Manager used by Hash, Verify and NeedsRehash
+func SetDefault(m *Manager) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultManager = m
+}
+
+// Default returns the process-wide Manager
+func Default() *Manager {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultManager
+}
+
+// Hash hashes password with the default Manager
+func Hash(password string) (string, error) {
+	return Default().Hash(password)
+}
+
+// Verify checks password against encoded with the default Manager
+func Verify(password, encoded string) (bool, error) {
+	return Default().Verify(password, encoded)
+}
+
+// NeedsRehash reports whether encoded should be upgraded according to the default Manager
+func NeedsRehash(encoded string) bool {
+	return Default().NeedsRehash(encoded)
+}
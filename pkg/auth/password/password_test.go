@@ -0,0 +1,107 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testArgon2idParams = Argon2idParams{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2id(t *testing.T) {
+	hasher := NewArgon2id(testArgon2idParams)
+
+	encoded, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$"))
+	assert.True(t, hasher.Recognizes(encoded))
+	assert.False(t, hasher.NeedsRehash(encoded))
+
+	ok, err := hasher.Verify("correct horse", encoded)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = hasher.Verify("wrong horse", encoded)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Same password hashes differently because of the random salt
+	other, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, encoded, other)
+
+	// Raising the cost marks existing hashes for upgrade
+	stronger := testArgon2idParams
+	stronger.Iterations = 2
+	assert.True(t, NewArgon2id(stronger).NeedsRehash(encoded))
+
+	_, err = hasher.Verify("correct horse", "$argon2id$v=19$m=64,t=1,p=1$not-base64!$abc")
+	assert.Error(t, err)
+}
+
+func TestBcrypt(t *testing.T) {
+	hasher := NewBcrypt(4)
+
+	encoded, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, hasher.Recognizes(encoded))
+	assert.False(t, hasher.NeedsRehash(encoded))
+	assert.True(t, NewBcrypt(5).NeedsRehash(encoded))
+
+	ok, err := hasher.Verify("correct horse", encoded)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = hasher.Verify("wrong horse", encoded)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestManager(t *testing.T) {
+	argon2idHasher := NewArgon2id(testArgon2idParams)
+	bcryptHasher := NewBcrypt(4)
+	manager := NewManager(argon2idHasher, bcryptHasher)
+
+	legacy, err := bcryptHasher.Hash("correct horse")
+	require.NoError(t, err)
+
+	ok, err := manager.Verify("correct horse", legacy)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, manager.NeedsRehash(legacy))
+
+	current, err := manager.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, argon2idHasher.Recognizes(current))
+	assert.False(t, manager.NeedsRehash(current))
+
+	_, err = manager.Verify("correct horse", "plaintext")
+	assert.ErrorIs(t, err, ErrUnknownHashFormat)
+}
+
+func TestNewManagerForAlgorithm(t *testing.T) {
+	manager, err := NewManagerForAlgorithm(AlgorithmBcrypt, testArgon2idParams, 4)
+	require.NoError(t, err)
+	encoded, err := manager.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$2a$"))
+
+	_, err = NewManagerForAlgorithm("md5", testArgon2idParams, 4)
+	assert.Error(t, err)
+
+	invalid := testArgon2idParams
+	invalid.Iterations = 0
+	_, err = NewManagerForAlgorithm(AlgorithmArgon2id, invalid, 4)
+	assert.Error(t, err)
+
+	_, err = NewManagerForAlgorithm(AlgorithmArgon2id, testArgon2idParams, 100)
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"github.com/mhrivnak/ssvirt/pkg/auth/password"
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+// ConfigurePasswordHashing installs the process-wide password hashing policy from configuration
+func ConfigurePasswordHashing(cfg *config.Config) error {
+	hashing := cfg.PasswordHashing
+	manager, err := password.NewManagerForAlgorithm(hashing.Algorithm, password.Argon2idParams{
+		Memory:      hashing.Argon2id.MemoryKiB,
+		Iterations:  hashing.Argon2id.Iterations,
+		Parallelism: hashing.Argon2id.Parallelism,
+		SaltLength:  hashing.Argon2id.SaltLength,
+		KeyLength:   hashing.Argon2id.KeyLength,
+	}, hashing.BcryptCost)
+	if err != nil {
+		return err
+	}
+	password.SetDefault(manager)
+	return nil
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/consistency"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// UserRolesContextKey is the Gin context key for storing the authenticated user
+// with their roles and organization, loaded at most once per request
+const UserRolesContextKey = "user_roles"
+
+// ErrNoClaims indicates the request has no authenticated user
+var ErrNoClaims = errors.New("request is not authenticated")
+
+// UserRolesLoader loads a user together with their roles and organization
+type UserRolesLoader interface {
+	GetWithRoles(id string) (*models.User, error)
+}
+
+type roleCacheEntry struct {
+	user    *models.User
+	expires time.Time
+}
+
+// RoleCache caches users with their roles and organization membership for a
+// short TTL so authorization checks do not query the database on every request.
+// Each replica holds its own cache: changes made through this replica are
+// invalidated immediately, changes made elsewhere are picked up within the TTL.
+type RoleCache struct {
+	loader UserRolesLoader
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]roleCacheEntry
+	// generation guards against caching a lookup that raced with an invalidation
+	generation uint64
+}
+
+// NewRoleCache creates a RoleCache. A ttl of zero or less disables caching
+// across requests; lookups are then only shared within a single request.
+func NewRoleCache(loader UserRolesLoader, ttl time.Duration) *RoleCache {
+	return &RoleCache{
+		loader:  loader,
+		ttl:     ttl,
+		entries: make(map[string]roleCacheEntry),
+	}
+}
+
+// Get returns the user with their roles, loading it from the database if it is
+// not cached or the cached entry has expired. The returned user is shared and
+// must not be modified.
+func (c *RoleCache) Get(userID string) (*models.User, error) {
+	if c.ttl <= 0 {
+		return c.loader.GetWithRoles(userID)
+	}
+
+	c.mu.Lock()
+	entry, found := c.entries[userID]
+	generation := c.generation
+	c.mu.Unlock()
+	if found && time.Now().Before(entry.expires) {
+		return entry.user, nil
+	}
+
+	user, err := c.loader.GetWithRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.entries[userID] = roleCacheEntry{user: user, expires: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return user, nil
+}
+
+// Invalidate drops the cached entry for a user whose roles, organization or
+// enabled state changed
+func (c *RoleCache) Invalidate(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+	c.generation++
+}
+
+// InvalidateAll drops every cached entry, for changes that affect many users
+// such as a role being modified or deleted
+func (c *RoleCache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]roleCacheEntry)
+	c.generation++
+}
+
+// UserWithRoles returns the authenticated user with their roles and
+// organization. The result is stored in the request context so every
+// middleware and handler serving the request shares a single lookup. Requests
+// asking for strong consistency reload the user, refreshing the cache.
+func UserWithRoles(c *gin.Context, cache *RoleCache) (*models.User, error) {
+	if value, exists := c.Get(UserRolesContextKey); exists {
+		if user, ok := value.(*models.User); ok {
+			return user, nil
+		}
+	}
+
+	claims, exists := c.Get(ClaimsContextKey)
+	if !exists {
+		return nil, ErrNoClaims
+	}
+	userClaims, ok := claims.(*Claims)
+	if !ok {
+		return nil, ErrNoClaims
+	}
+
+	if c.Request != nil && consistency.IsStrong(c.Request.Context()) {
+		cache.Invalidate(userClaims.UserID)
+	}
+	user, err := cache.Get(userClaims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(UserRolesContextKey, user)
+	return user, nil
+}
@@ -92,6 +92,8 @@ func (s *Service) Login(req *LoginRequest) (*LoginResponse, error) {
 		return nil, ErrInvalidCredentials
 	}
 
+	s.UpgradePasswordHash(user, req.Password)
+
 	token, err := s.jwtManager.Generate(user.ID, user.Username)
 	if err != nil {
 		log.Printf("failed to generate token for user %s: %v", req.Username, err)
@@ -147,6 +149,21 @@ func (s *Service) CreateUser(req *CreateUserRequest) (*models.User, error) {
 	return user, nil
 }
 
+// UpgradePasswordHash re-hashes a verified password when the stored hash uses an
+// outdated algorithm or parameters. Failures are logged and do not affect login.
+func (s *Service) UpgradePasswordHash(user *models.User, password string) {
+	if !user.PasswordNeedsRehash() {
+		return
+	}
+	if err := user.SetPassword(password); err != nil {
+		log.Printf("failed to rehash password for user %s: %v", user.Username, err)
+		return
+	}
+	if err := s.userRepo.UpdatePasswordHash(user.ID, user.PasswordHash); err != nil {
+		log.Printf("failed to store upgraded password hash for user %s: %v", user.Username, err)
+	}
+}
+
 // GetUser retrieves a user by their ID
 func (s *Service) GetUser(userID string) (*models.User, error) {
 	return s.userRepo.GetByID(userID)
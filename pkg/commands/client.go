@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Defaults for RetryPolicy
+const (
+	DefaultRetryAttempts  = 5
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	DefaultRequestTimeout = 10 * time.Second
+)
+
+// RetryPolicy controls how failed requests are retried. The delay doubles
+// after each attempt.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+}
+
+// Client posts commands and results over mutual TLS
+type Client struct {
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// NewClient creates a client using the TLS configuration from ClientTLSConfig
+func NewClient(tlsConfig *tls.Config, retry RetryPolicy) *Client {
+	if retry.Attempts <= 0 {
+		retry.Attempts = DefaultRetryAttempts
+	}
+	if retry.BaseDelay <= 0 {
+		retry.BaseDelay = DefaultRetryBaseDelay
+	}
+	return &Client{
+		httpClient: &http.Client{
+			Timeout:   DefaultRequestTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		retry: retry,
+	}
+}
+
+// permanentError is a failure that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// SendCommand posts a command to the vm-controller at baseURL. The controller
+// acknowledges it before executing it, so a nil error means the command was
+// accepted, not that it completed.
+func (c *Client) SendCommand(ctx context.Context, baseURL string, cmd Command) error {
+	return c.post(ctx, strings.TrimSuffix(baseURL, "/")+CommandsPath, cmd)
+}
+
+// SendResult posts a command result to the API server at baseURL
+func (c *Client) SendResult(ctx context.Context, baseURL string, result Result) error {
+	return c.post(ctx, strings.TrimSuffix(baseURL, "/")+ResultsPath, result)
+}
+
+// post sends body as JSON, retrying connection failures and server errors
+func (c *Client) post(ctx context.Context, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	delay := c.retry.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err = c.postOnce(ctx, url, data)
+		var permanent permanentError
+		if err == nil || errors.As(err, &permanent) || attempt >= c.retry.Attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (c *Client) postOnce(ctx context.Context, url string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("internal API request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("internal API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return permanentError{err}
+}
+
+// Dispatcher sends commands from the API server to the vm-controller
+type Dispatcher struct {
+	client        *Client
+	controllerURL string
+	callbackURL   string
+}
+
+// NewDispatcher creates a dispatcher for the controller at controllerURL.
+// Results are requested at callbackURL, the API server's internal endpoint.
+func NewDispatcher(client *Client, controllerURL, callbackURL string) *Dispatcher {
+	return &Dispatcher{
+		client:        client,
+		controllerURL: controllerURL,
+		callbackURL:   callbackURL,
+	}
+}
+
+// Dispatch sends a command, retrying until the controller accepts it
+func (d *Dispatcher) Dispatch(ctx context.Context, cmd Command) error {
+	if cmd.CallbackURL == "" {
+		cmd.CallbackURL = d.callbackURL
+	}
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	return d.client.SendCommand(ctx, d.controllerURL, cmd)
+}
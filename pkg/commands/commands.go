@@ -0,0 +1,89 @@
+// Package commands implements the internal control channel between the API
+// server and the vm-controller. The API server sends commands, such as VM power
+// changes, to the controller instead of changing KubeVirt objects itself, so a
+// single component acts on VMs. The controller reports each command's outcome
+// back to the API server, which completes the task tracking it. Both directions
+// use HTTPS with mutual TLS and are retried; command IDs make retries safe.
+package commands
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Paths of the internal endpoints
+const (
+	// CommandsPath is served by the vm-controller
+	CommandsPath = "/internal/v1/commands"
+	// ResultsPath is served by the API server
+	ResultsPath = "/internal/v1/results"
+)
+
+// Command types
+const (
+	TypeVMPower = "vm.power"
+)
+
+// VM power actions. ActionReboot asks the guest to restart; ActionReset
+// restarts the VM without involving the guest.
+const (
+	ActionPowerOn  = "powerOn"
+	ActionPowerOff = "powerOff"
+	ActionReboot   = "reboot"
+	ActionReset    = "reset"
+)
+
+// Result statuses
+const (
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+)
+
+// Command asks the vm-controller to act on a VirtualMachine
+type Command struct {
+	// ID identifies the command; a command sent again with the same ID is not repeated
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Action    string `json:"action"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// VMID and TaskID are returned in the result so the API server can
+	// complete the task
+	VMID   string `json:"vmId,omitempty"`
+	TaskID string `json:"taskId,omitempty"`
+	// RunStrategy is the run strategy a powered-on VM is given once it runs;
+	// empty keeps Always
+	RunStrategy string `json:"runStrategy,omitempty"`
+	// CallbackURL receives the result; the controller's configured callback URL is used when empty
+	CallbackURL string `json:"callbackUrl,omitempty"`
+}
+
+// Result reports the outcome of a command
+type Result struct {
+	CommandID string `json:"commandId"`
+	VMID      string `json:"vmId,omitempty"`
+	TaskID    string `json:"taskId,omitempty"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+}
+
+// ErrInvalidCommand is returned for commands the controller cannot execute
+var ErrInvalidCommand = errors.New("invalid command")
+
+// Validate checks that the command is complete and of a known type
+func (c Command) Validate() error {
+	if c.ID == "" || c.Namespace == "" || c.Name == "" {
+		return fmt.Errorf("%w: id, namespace and name are required", ErrInvalidCommand)
+	}
+	switch c.Type {
+	case TypeVMPower:
+		switch c.Action {
+		case ActionPowerOn, ActionPowerOff, ActionReboot, ActionReset:
+		default:
+			return fmt.Errorf("%w: unknown power action %q", ErrInvalidCommand, c.Action)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidCommand, c.Type)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestPKI creates a CA and a certificate for 127.0.0.1 that is valid for
+// both ends of the channel
+func writeTestPKI(t *testing.T) TLSFiles {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ssvirt-internal-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ssvirt"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	files := TLSFiles{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(files.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(files.CAFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600))
+	return files
+}
+
+// startTLSServer serves handler with the server side of the mTLS configuration
+func startTLSServer(t *testing.T, files TLSFiles, handler http.Handler) *httptest.Server {
+	t.Helper()
+	tlsConfig, err := ServerTLSConfig(files)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = tlsConfig
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(t *testing.T, files TLSFiles) *Client {
+	t.Helper()
+	tlsConfig, err := ClientTLSConfig(files)
+	require.NoError(t, err)
+	return NewClient(tlsConfig, RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond})
+}
+
+type fakeExecutor struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (f *fakeExecutor) Execute(ctx context.Context, cmd Command) error {
+	f.calls.Add(1)
+	return f.err
+}
+
+type fakeSink struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+func (f *fakeSink) CommandCompleted(ctx context.Context, result Result) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, result)
+	return nil
+}
+
+func (f *fakeSink) received() []Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Result(nil), f.results...)
+}
+
+func TestCommandRoundTrip(t *testing.T) {
+	files := writeTestPKI(t)
+	client := newTestClient(t, files)
+
+	sink := &fakeSink{}
+	apiServer := startTLSServer(t, files, NewResultHandler(sink, nil))
+
+	executor := &fakeExecutor{}
+	commandServer := NewCommandServer("", nil, executor, client, "", nil)
+	controller := startTLSServer(t, files, commandServer.Handler())
+
+	dispatcher := NewDispatcher(client, controller.URL, apiServer.URL)
+	cmd := Command{ID: "cmd-1", Type: TypeVMPower, Action: ActionPowerOn, Namespace: "ns", Name: "vm", VMID: "urn:vcloud:vm:1", TaskID: "task-1"}
+	require.NoError(t, dispatcher.Dispatch(context.Background(), cmd))
+
+	require.Eventually(t, func() bool { return len(sink.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	result := sink.received()[0]
+	assert.Equal(t, Result{CommandID: "cmd-1", VMID: "urn:vcloud:vm:1", TaskID: "task-1", Status: ResultSucceeded}, result)
+
+	// A retried command is acknowledged without being executed again
+	require.NoError(t, dispatcher.Dispatch(context.Background(), cmd))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), executor.calls.Load())
+	assert.Len(t, sink.received(), 1)
+
+	// Failures are reported with the executor's error
+	executor.err = errors.New("VirtualMachine ns/vm entered status ErrorUnschedulable")
+	cmd.ID = "cmd-2"
+	require.NoError(t, dispatcher.Dispatch(context.Background(), cmd))
+	require.Eventually(t, func() bool { return len(sink.received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	result = sink.received()[1]
+	assert.Equal(t, ResultFailed, result.Status)
+	assert.Equal(t, executor.err.Error(), result.Message)
+
+	// Invalid commands are refused before they are sent
+	assert.ErrorIs(t, dispatcher.Dispatch(context.Background(), Command{ID: "cmd-3", Type: TypeVMPower, Action: "migrate"}), ErrInvalidCommand)
+}
+
+func TestCommandServerRequiresClientCertificate(t *testing.T) {
+	files := writeTestPKI(t)
+	executor := &fakeExecutor{}
+	controller := startTLSServer(t, files, NewCommandServer("", nil, executor, nil, "", nil).Handler())
+
+	// Trusts the server but presents no certificate of its own
+	clientTLS, err := ClientTLSConfig(files)
+	require.NoError(t, err)
+	clientTLS = &tls.Config{RootCAs: clientTLS.RootCAs, MinVersion: tls.VersionTLS12}
+	client := NewClient(clientTLS, RetryPolicy{Attempts: 1, BaseDelay: time.Millisecond})
+
+	err = client.SendCommand(context.Background(), controller.URL, Command{ID: "cmd-1", Type: TypeVMPower, Action: ActionPowerOn, Namespace: "ns", Name: "vm"})
+	assert.Error(t, err)
+	assert.Zero(t, executor.calls.Load())
+}
+
+func TestClientRetries(t *testing.T) {
+	files := writeTestPKI(t)
+	client := newTestClient(t, files)
+
+	var requests atomic.Int32
+	status := http.StatusServiceUnavailable
+	server := startTLSServer(t, files, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	result := Result{CommandID: "cmd-1", Status: ResultSucceeded}
+	require.NoError(t, client.SendResult(context.Background(), server.URL, result))
+	assert.Equal(t, int32(3), requests.Load())
+
+	// Client errors are not retried
+	requests.Store(0)
+	status = http.StatusBadRequest
+	assert.Error(t, client.SendResult(context.Background(), server.URL, result))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Server errors are retried up to the attempt limit
+	requests.Store(-10)
+	status = http.StatusInternalServerError
+	assert.Error(t, client.SendResult(context.Background(), server.URL, result))
+	assert.Equal(t, int32(-7), requests.Load())
+}
+
+func TestCommandServerForgetsExpiredCommands(t *testing.T) {
+	server := NewCommandServer("", nil, &fakeExecutor{}, nil, "", nil)
+
+	_, first := server.accept("cmd-1")
+	assert.True(t, first)
+	_, first = server.accept("cmd-1")
+	assert.False(t, first)
+
+	// Expired IDs are kept until the next prune
+	server.seen["cmd-1"] = time.Now().Add(-seenCommandTTL - time.Minute)
+	_, first = server.accept("cmd-2")
+	assert.True(t, first)
+	assert.Contains(t, server.seen, "cmd-1")
+
+	server.lastPrune = time.Now().Add(-seenCommandPruneInterval)
+	_, first = server.accept("cmd-3")
+	assert.True(t, first)
+	assert.NotContains(t, server.seen, "cmd-1")
+	assert.Len(t, server.seen, 2)
+}
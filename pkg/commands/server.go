@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBodyBytes bounds the size of command and result requests
+	maxBodyBytes = 64 * 1024
+	// seenCommandTTL is how long command IDs are remembered to ignore retries
+	seenCommandTTL = time.Hour
+	// seenCommandPruneInterval is how often expired command IDs are forgotten
+	seenCommandPruneInterval = time.Minute
+	// shutdownTimeout bounds how long Serve waits for requests on shutdown
+	shutdownTimeout = 5 * time.Second
+)
+
+// Executor carries out commands on the vm-controller. Execute returns once the
+// command has completed, or failed.
+type Executor interface {
+	Execute(ctx context.Context, cmd Command) error
+}
+
+// ResultSink applies command results on the API server
+type ResultSink interface {
+	CommandCompleted(ctx context.Context, result Result) error
+}
+
+// CommandServer receives commands on the vm-controller, executes them in the
+// background and reports each result to the API server
+type CommandServer struct {
+	addr        string
+	tlsConfig   *tls.Config
+	executor    Executor
+	results     *Client
+	callbackURL string
+	logger      *slog.Logger
+
+	mu  sync.Mutex
+	ctx context.Context
+	// seen holds the IDs of the commands this replica received; retries that
+	// reach another replica are not recognised
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewCommandServer creates a command server listening on addr. Results are
+// sent to callbackURL unless a command names its own.
+func NewCommandServer(addr string, tlsConfig *tls.Config, executor Executor, results *Client, callbackURL string, logger *slog.Logger) *CommandServer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &CommandServer{
+		addr:        addr,
+		tlsConfig:   tlsConfig,
+		executor:    executor,
+		results:     results,
+		callbackURL: callbackURL,
+		logger:      logger,
+		ctx:         context.Background(),
+		seen:        make(map[string]time.Time),
+	}
+}
+
+// Start serves commands until ctx is cancelled
+func (s *CommandServer) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	return Serve(ctx, s.addr, s.tlsConfig, s.Handler())
+}
+
+// NeedLeaderElection reports that every vm-controller replica serves commands,
+// since the service in front of them may route to any of them. Retries are only
+// recognised by the replica that received the command, so one routed to another
+// replica is executed again.
+func (s *CommandServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Handler returns the HTTP handler of the command endpoint
+func (s *CommandServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+CommandsPath, func(w http.ResponseWriter, r *http.Request) {
+		var cmd Command
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&cmd); err != nil {
+			http.Error(w, "invalid command body", http.StatusBadRequest)
+			return
+		}
+		if err := cmd.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// A retried command is acknowledged again but executed once by this replica
+		ctx, first := s.accept(cmd.ID)
+		w.WriteHeader(http.StatusAccepted)
+		if first {
+			go s.execute(ctx, cmd)
+		}
+	})
+	return mux
+}
+
+// accept records a command ID, reporting whether this replica has not seen it
+func (s *CommandServer) accept(id string) (context.Context, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) >= seenCommandPruneInterval {
+		for seenID, at := range s.seen {
+			if now.Sub(at) > seenCommandTTL {
+				delete(s.seen, seenID)
+			}
+		}
+		s.lastPrune = now
+	}
+	if _, ok := s.seen[id]; ok {
+		return s.ctx, false
+	}
+	s.seen[id] = now
+	return s.ctx, true
+}
+
+// execute runs a command and reports its result
+func (s *CommandServer) execute(ctx context.Context, cmd Command) {
+	result := Result{CommandID: cmd.ID, VMID: cmd.VMID, TaskID: cmd.TaskID, Status: ResultSucceeded}
+	if err := s.executor.Execute(ctx, cmd); err != nil {
+		s.logger.Warn("Command failed", "id", cmd.ID, "type", cmd.Type, "action", cmd.Action,
+			"namespace", cmd.Namespace, "name", cmd.Name, "error", err)
+		result.Status = ResultFailed
+		result.Message = err.Error()
+	} else {
+		s.logger.Info("Command completed", "id", cmd.ID, "type", cmd.Type, "action", cmd.Action,
+			"namespace", cmd.Namespace, "name", cmd.Name)
+	}
+
+	callbackURL := cmd.CallbackURL
+	if callbackURL == "" {
+		callbackURL = s.callbackURL
+	}
+	if callbackURL == "" || s.results == nil {
+		return
+	}
+	// The result is still reported when the controller is shutting down
+	if err := s.results.SendResult(context.WithoutCancel(ctx), callbackURL, result); err != nil {
+		s.logger.Error("Failed to report command result", "id", cmd.ID, "error", err)
+	}
+}
+
+// NewResultHandler returns the HTTP handler of the API server's result endpoint
+func NewResultHandler(sink ResultSink, logger *slog.Logger) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+ResultsPath, func(w http.ResponseWriter, r *http.Request) {
+		var result Result
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&result); err != nil {
+			http.Error(w, "invalid result body", http.StatusBadRequest)
+			return
+		}
+		if result.CommandID == "" || (result.Status != ResultSucceeded && result.Status != ResultFailed) {
+			http.Error(w, "result requires a command ID and status", http.StatusBadRequest)
+			return
+		}
+		if err := sink.CommandCompleted(r.Context(), result); err != nil {
+			// Reported as a server error so the controller retries
+			logger.Error("Failed to apply command result", "commandID", result.CommandID, "error", err)
+			http.Error(w, "failed to apply result", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// Serve serves handler over TLS on addr until ctx is cancelled
+func Serve(ctx context.Context, addr string, tlsConfig *tls.Config, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServeTLS("", "")
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSFiles locates the certificate, key and CA bundle of one end of the channel
+type TLSFiles struct {
+	CertFile string
+	KeyFile  string
+	// CAFile verifies the other end's certificate
+	CAFile string
+}
+
+// ServerTLSConfig returns a TLS configuration that requires clients to present
+// a certificate signed by the CA
+func ServerTLSConfig(files TLSFiles) (*tls.Config, error) {
+	cert, pool, err := files.load()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientTLSConfig returns a TLS configuration that presents the certificate and
+// verifies servers against the CA
+func ClientTLSConfig(files TLSFiles) (*tls.Config, error) {
+	cert, pool, err := files.load()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func (f TLSFiles) load() (tls.Certificate, *x509.CertPool, error) {
+	if f.CertFile == "" || f.KeyFile == "" || f.CAFile == "" {
+		return tls.Certificate{}, nil, errors.New("internal API requires a certificate, key and CA bundle")
+	}
+	cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to load internal API certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(f.CAFile)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("failed to read internal API CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return tls.Certificate{}, nil, fmt.Errorf("no certificates found in %s", f.CAFile)
+	}
+	return cert, pool, nil
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/base64"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,6 +16,11 @@ import (
 
 type Config struct {
 	Database struct {
+		// Driver selects the backend: "postgres" (default) or "sqlite" for
+		// development and small single-replica deployments
+		Driver string `mapstructure:"driver"`
+		// Path is the SQLite database file; ":memory:" keeps it in memory
+		Path            string        `mapstructure:"path"`
 		Host            string        `mapstructure:"host"`
 		Port            int           `mapstructure:"port"`
 		Username        string        `mapstructure:"username"`
@@ -24,23 +31,73 @@ type Config struct {
 		MaxIdleConns    int           `mapstructure:"max_idle_connections"`
 		ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
 		ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
-		Retry           struct {
+		// LogLevel is the SQL log level: info logs every query, warn only slow ones
+		LogLevel string `mapstructure:"log_level"`
+		// SlowQueryThreshold logs and counts queries at least this slow; 0 disables
+		SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
+		Retry              struct {
 			MaxAttempts     int           `mapstructure:"max_attempts"`
 			InitialDelay    time.Duration `mapstructure:"initial_delay"`
 			MaxDelay        time.Duration `mapstructure:"max_delay"`
 			BackoffMultiple float64       `mapstructure:"backoff_multiple"`
 		} `mapstructure:"retry"`
+		// VMArchive moves VM records that stayed DELETED to the archived_vms table
+		VMArchive struct {
+			// Interval is how often VMs are archived and pruned; 0 disables archiving
+			Interval time.Duration `mapstructure:"interval"`
+			// ArchiveAfter is how long a VM stays DELETED before it is archived
+			ArchiveAfter time.Duration `mapstructure:"archive_after"`
+			// Retention is how long archived VMs are kept; 0 keeps them forever
+			Retention time.Duration `mapstructure:"retention"`
+		} `mapstructure:"vm_archive"`
 	} `mapstructure:"database"`
 
 	API struct {
 		Port    int    `mapstructure:"port"`
 		TLSCert string `mapstructure:"tls_cert"`
 		TLSKey  string `mapstructure:"tls_key"`
+		// TrustedProxies are the addresses or CIDRs of the proxies whose
+		// X-Forwarded-For and X-Real-IP headers name the client; empty trusts none
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
+		// MetricsAddress is where Prometheus metrics are served, apart from
+		// the API so they are not exposed with it; empty disables them
+		MetricsAddress string `mapstructure:"metrics_address"`
+		// ShutdownTimeout bounds how long in-flight requests and background
+		// operations are waited for on shutdown; keep it below the pod's
+		// termination grace period
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+		// Usage counts API calls and data transfer per user and day
+		Usage struct {
+			// FlushInterval is how often counts are written to the database; 0 disables tracking
+			FlushInterval time.Duration `mapstructure:"flush_interval"`
+			// RetentionDays is how long daily usage is kept; 0 keeps it forever
+			RetentionDays int `mapstructure:"retention_days"`
+		} `mapstructure:"usage"`
+		// Requests bounds request bodies; 0 disables a limit
+		Requests struct {
+			MaxBodyBytes       int64 `mapstructure:"max_body_bytes"`
+			MaxJSONDepth       int   `mapstructure:"max_json_depth"`
+			MaxJSONArrayLength int   `mapstructure:"max_json_array_length"`
+			// StrictJSONGroups lists the route groups, cloudapi, admin or
+			// legacy, whose JSON bodies may not contain unknown fields
+			StrictJSONGroups []string `mapstructure:"strict_json_groups"`
+		} `mapstructure:"requests"`
 	} `mapstructure:"api"`
 
 	Auth struct {
 		JWTSecret   string        `mapstructure:"jwt_secret"`
 		TokenExpiry time.Duration `mapstructure:"token_expiry"`
+		// RoleCacheTTL is how long a user's roles are cached for authorization checks; 0 disables caching
+		RoleCacheTTL time.Duration `mapstructure:"role_cache_ttl"`
+		// ImpersonationTTL is how long tokens issued to administrators impersonating a tenant user remain valid
+		ImpersonationTTL time.Duration `mapstructure:"impersonation_ttl"`
+		// Issuer and Audience are set as the iss and aud claims of issued tokens
+		// and required of presented ones, so installations sharing a secret
+		// reject each other's tokens; empty values are not checked
+		Issuer   string `mapstructure:"issuer"`
+		Audience string `mapstructure:"audience"`
+		// ClockSkew is how far token expiry and not-before times may be off
+		ClockSkew time.Duration `mapstructure:"clock_skew"`
 	} `mapstructure:"auth"`
 
 	Session struct {
@@ -52,10 +109,281 @@ type Config struct {
 		Location string `mapstructure:"location"`
 	} `mapstructure:"session"`
 
+	// Provider describes this installation to VCD clients through the discovery
+	// endpoints they call at startup. The site name and ID come from Session.Site.
+	Provider struct {
+		// RestEndpoint is the public URL of the API; derived from each request when empty
+		RestEndpoint     string                  `mapstructure:"rest_endpoint"`
+		InstallationID   int                     `mapstructure:"installation_id"`
+		FeatureFlags     map[string]bool         `mapstructure:"feature_flags"`
+		SiteAssociations []SiteAssociationConfig `mapstructure:"site_associations"`
+	} `mapstructure:"provider"`
+
 	Kubernetes struct {
 		Namespace string `mapstructure:"namespace"`
+		// Cache tunes the informer cache the API server reads cluster objects through
+		Cache struct {
+			// ResyncPeriod is how often cached objects are re-listed
+			ResyncPeriod time.Duration `mapstructure:"resync_period"`
+			// SyncTimeout bounds how long startup waits for the cache to sync
+			// before falling back to direct API calls
+			SyncTimeout time.Duration `mapstructure:"sync_timeout"`
+			// NamespaceSelector restricts the cache to the template namespace and
+			// the namespaces matching this label selector at startup, for running
+			// without cluster-wide list and watch permissions; empty caches all
+			NamespaceSelector string `mapstructure:"namespace_selector"`
+		} `mapstructure:"cache"`
+		// Writes throttles the API server's writes to each namespace so bursts
+		// of instantiations or power actions stay within the cluster's limits
+		Writes struct {
+			// QPS is the sustained write rate per namespace; 0 disables throttling
+			QPS float64 `mapstructure:"qps"`
+			// Burst is how many writes a namespace may make at once
+			Burst int `mapstructure:"burst"`
+			// QueueDepth is how many writes may wait in a namespace before
+			// further writes are refused
+			QueueDepth int `mapstructure:"queue_depth"`
+			// BreakerCooldown is how long writes to a namespace are refused
+			// after the cluster throttles one of them
+			BreakerCooldown time.Duration `mapstructure:"breaker_cooldown"`
+		} `mapstructure:"writes"`
 	} `mapstructure:"kubernetes"`
 
+	Organizations struct {
+		HierarchicalAccess bool `mapstructure:"hierarchical_access"`
+		// DefaultCatalog is created with every new organization when enabled
+		DefaultCatalog DefaultCatalogConfig `mapstructure:"default_catalog"`
+	} `mapstructure:"organizations"`
+
+	Notifications struct {
+		PollInterval time.Duration `mapstructure:"poll_interval"`
+		// Email delivers system events, such as storage alerts and vApps stuck
+		// instantiating, through an SMTP server
+		Email EmailConfig `mapstructure:"email"`
+	} `mapstructure:"notifications"`
+
+	Controllers struct {
+		VMStatus struct {
+			MaxConcurrentReconciles int           `mapstructure:"max_concurrent_reconciles"`
+			RetryBaseDelay          time.Duration `mapstructure:"retry_base_delay"`
+			RetryMaxDelay           time.Duration `mapstructure:"retry_max_delay"`
+			NamespaceRetryQPS       float64       `mapstructure:"namespace_retry_qps"`
+			NamespaceRetryBurst     int           `mapstructure:"namespace_retry_burst"`
+			StatusBuffer            struct {
+				Enabled        bool          `mapstructure:"enabled"`
+				Capacity       int           `mapstructure:"capacity"`
+				OverflowPolicy string        `mapstructure:"overflow_policy"`
+				ReplayInterval time.Duration `mapstructure:"replay_interval"`
+			} `mapstructure:"status_buffer"`
+			// LabelSweepInterval is how often VDC namespaces are swept for VMs
+			// whose vapp.ssvirt label was missed while the controller was down
+			LabelSweepInterval time.Duration `mapstructure:"label_sweep_interval"`
+		} `mapstructure:"vm_status"`
+		VAppStatus struct {
+			MaxConcurrentReconciles int `mapstructure:"max_concurrent_reconciles"`
+			// StuckAlertAfter is how long a vApp may instantiate before an email
+			// notification is sent; 0 disables the alert
+			StuckAlertAfter time.Duration `mapstructure:"stuck_alert_after"`
+		} `mapstructure:"vapp_status"`
+		StorageUsage struct {
+			// AlertWebhookURL receives storage usage alerts as JSON POSTs when set
+			AlertWebhookURL     string        `mapstructure:"alert_webhook_url"`
+			AlertWebhookTimeout time.Duration `mapstructure:"alert_webhook_timeout"`
+		} `mapstructure:"storage_usage"`
+		AutoSuspend struct {
+			// Interval is how often the CPU usage of VMs is sampled
+			Interval time.Duration `mapstructure:"interval"`
+			// NoticePeriod is how long owners are warned before an idle VM is suspended
+			NoticePeriod time.Duration `mapstructure:"notice_period"`
+		} `mapstructure:"auto_suspend"`
+		CatalogSync struct {
+			// PollInterval is how often catalog sources are checked for a due sync
+			PollInterval time.Duration `mapstructure:"poll_interval"`
+			// FetchTimeout bounds how long downloading one catalog source may take
+			FetchTimeout time.Duration `mapstructure:"fetch_timeout"`
+		} `mapstructure:"catalog_sync"`
+		PowerState struct {
+			// PollInterval is how often VMs are compared with their desired power state
+			PollInterval time.Duration `mapstructure:"poll_interval"`
+			// RetryMaxDelay caps the backoff for VMs whose run strategy cannot be changed
+			RetryMaxDelay time.Duration `mapstructure:"retry_max_delay"`
+		} `mapstructure:"power_state"`
+		Janitor struct {
+			// Interval is how often VDC namespaces are swept
+			Interval time.Duration `mapstructure:"interval"`
+			// SecretGracePeriod is how long a template instance Secret may
+			// exist without its TemplateInstance before it is deleted
+			SecretGracePeriod time.Duration `mapstructure:"secret_grace_period"`
+			// FailedInstanceRetention is how long failed TemplateInstances are kept
+			FailedInstanceRetention time.Duration `mapstructure:"failed_instance_retention"`
+		} `mapstructure:"janitor"`
+		Prewarm struct {
+			// PollInterval is how often pinned catalog items are checked and
+			// their golden images imported
+			PollInterval time.Duration `mapstructure:"poll_interval"`
+		} `mapstructure:"prewarm"`
+	} `mapstructure:"controllers"`
+
+	// InternalAPI is the mTLS channel over which the API server sends commands
+	// such as power changes to the vm-controller and receives their results
+	InternalAPI struct {
+		// ControllerURL is the vm-controller's command endpoint; power operations
+		// are carried out by the API server itself while it is empty
+		ControllerURL string `mapstructure:"controller_url"`
+		// CallbackURL is where the vm-controller reports command results
+		CallbackURL string `mapstructure:"callback_url"`
+		// ListenAddress is where the receiving side of the channel listens
+		ListenAddress string `mapstructure:"listen_address"`
+		TLSCert       string `mapstructure:"tls_cert"`
+		TLSKey        string `mapstructure:"tls_key"`
+		// CACert verifies the certificates of both sides
+		CACert         string        `mapstructure:"ca_cert"`
+		RetryAttempts  int           `mapstructure:"retry_attempts"`
+		RetryBaseDelay time.Duration `mapstructure:"retry_base_delay"`
+		// CommandTimeout bounds how long the vm-controller waits for a VM to
+		// reach the requested state
+		CommandTimeout time.Duration `mapstructure:"command_timeout"`
+	} `mapstructure:"internal_api"`
+
+	// Pricing sets the showback rates used to estimate monthly VM costs; estimates
+	// are omitted while every rate is zero
+	Pricing struct {
+		Currency        string  `mapstructure:"currency"`
+		CPUHour         float64 `mapstructure:"cpu_hour"`
+		MemoryGiBHour   float64 `mapstructure:"memory_gib_hour"`
+		StorageGiBMonth float64 `mapstructure:"storage_gib_month"`
+		GPUHour         float64 `mapstructure:"gpu_hour"`
+		HoursPerMonth   float64 `mapstructure:"hours_per_month"`
+	} `mapstructure:"pricing"`
+
+	// Localization adds translations of API error messages
+	Localization struct {
+		// CatalogDir holds <language>.json files mapping error codes to translated messages
+		CatalogDir string `mapstructure:"catalog_dir"`
+	} `mapstructure:"localization"`
+
+	// Quota controls how VDC compute limits are enforced when vApps are instantiated
+	Quota struct {
+		// GracePeriod is how long a VDC may stay above its hard compute limits
+		// using its grace allowance before further over-limit allocations are refused
+		GracePeriod time.Duration `mapstructure:"grace_period"`
+		// Objects is the default count of each object type allowed in a VDC
+		// namespace's ResourceQuota; VDCs may override it. 0 leaves it unlimited.
+		Objects struct {
+			Pods                   int `mapstructure:"pods"`
+			PersistentVolumeClaims int `mapstructure:"persistent_volume_claims"`
+			Services               int `mapstructure:"services"`
+			Secrets                int `mapstructure:"secrets"`
+			ConfigMaps             int `mapstructure:"config_maps"`
+		} `mapstructure:"objects"`
+		// DefaultVMSize is the VM size public VDC responses estimate the
+		// remaining capacity of each VDC in
+		DefaultVMSize VMSizeConfig `mapstructure:"default_vm_size"`
+	} `mapstructure:"quota"`
+
+	// Instantiation configures how vApps are instantiated from templates
+	Instantiation struct {
+		// Labels and annotations are applied to the TemplateInstance,
+		// VirtualMachines and VM pods of every instantiated vApp, for
+		// integrations such as backup tooling that select resources by label.
+		// A VDC's metadata policy overrides keys set here.
+		Labels      map[string]string `mapstructure:"labels"`
+		Annotations map[string]string `mapstructure:"annotations"`
+		// MaxConcurrentPerVDC and MaxConcurrentPerOrg cap the vApps that may be
+		// instantiating at once in a VDC and across an organization's VDCs, so
+		// mass deployments cannot overwhelm the cluster's image import; 0 is unlimited
+		MaxConcurrentPerVDC int `mapstructure:"max_concurrent_per_vdc"`
+		MaxConcurrentPerOrg int `mapstructure:"max_concurrent_per_org"`
+		// QueueTimeout is how long a request over a limit waits for an
+		// instantiation to finish before it is refused; 0 refuses it immediately
+		QueueTimeout time.Duration `mapstructure:"queue_timeout"`
+	} `mapstructure:"instantiation"`
+
+	// PublicCatalog serves the published catalogs and their items without
+	// authentication, for self-service portals that show the offering before login
+	PublicCatalog struct {
+		Enabled bool `mapstructure:"enabled"`
+		// RequestsPerMinute limits the requests of each client address
+		RequestsPerMinute int `mapstructure:"requests_per_minute"`
+		// Burst is how many requests a client may make at once
+		Burst int `mapstructure:"burst"`
+	} `mapstructure:"public_catalog"`
+
+	// Naming restricts the names users give to VDCs and vApps
+	Naming struct {
+		// ReservedPrefixes are name prefixes reserved for system namespaces
+		// and objects; names starting with one, ignoring case, are refused
+		ReservedPrefixes []string `mapstructure:"reserved_prefixes"`
+	} `mapstructure:"naming"`
+
+	// Backup configures the OADP/Velero integration behind VDC and vApp backup
+	// policies
+	Backup struct {
+		// VeleroNamespace is the namespace Velero creates Backups in
+		VeleroNamespace string `mapstructure:"velero_namespace"`
+	} `mapstructure:"backup"`
+
+	// Export configures VM exports, which snapshot a VM and publish its disks
+	// for download through the KubeVirt export proxy
+	Export struct {
+		// TTL is how long download links stay valid before the export is removed
+		TTL time.Duration `mapstructure:"ttl"`
+		// Timeout bounds how long snapshotting and exporting a VM may take
+		Timeout time.Duration `mapstructure:"timeout"`
+	} `mapstructure:"export"`
+
+	// NetworkFlows configures the per-VM network flow summary, read from the
+	// flow metrics Network Observability exports to Prometheus. The summary is
+	// disabled when PrometheusURL is empty.
+	NetworkFlows struct {
+		PrometheusURL string `mapstructure:"prometheus_url"`
+		// BearerTokenFile holds the token sent to Prometheus, such as a
+		// service account token for the OpenShift Thanos querier
+		BearerTokenFile string `mapstructure:"bearer_token_file"`
+		// CAFile verifies the Prometheus certificate; the system roots are
+		// used when empty
+		CAFile  string        `mapstructure:"ca_file"`
+		Timeout time.Duration `mapstructure:"timeout"`
+		// Metric is the byte counter, labelled with the source and destination
+		// workloads of each flow
+		Metric string `mapstructure:"metric"`
+	} `mapstructure:"network_flows"`
+
+	// Approval gates privileged operations through an external system, such as
+	// an ITSM, which is called before they run and may allow, deny or hold them
+	// for approval. The gate is disabled when WebhookURL is empty.
+	Approval struct {
+		WebhookURL string `mapstructure:"webhook_url"`
+		// Secret signs the callouts and verifies the decisions posted back for
+		// held operations with HMAC-SHA256
+		Secret  string        `mapstructure:"secret"`
+		Timeout time.Duration `mapstructure:"timeout"`
+		// Operations lists the gated operations: gpuVmCreate, the instantiation
+		// of vApps with GPUs, and vdcDelete
+		Operations []string `mapstructure:"operations"`
+	} `mapstructure:"approval"`
+
+	// GroupSync maps OpenShift Groups, which OpenShift fills from the groups
+	// claim of an OIDC identity provider or from LDAP group sync, to
+	// organization membership and roles. The vm-controller's groupsync
+	// controller applies the mappings every Interval.
+	GroupSync struct {
+		Interval time.Duration        `mapstructure:"interval"`
+		Mappings []GroupMappingConfig `mapstructure:"mappings"`
+	} `mapstructure:"group_sync"`
+
+	PasswordHashing struct {
+		Algorithm string `mapstructure:"algorithm"`
+		Argon2id  struct {
+			MemoryKiB   uint32 `mapstructure:"memory_kib"`
+			Iterations  uint32 `mapstructure:"iterations"`
+			Parallelism uint8  `mapstructure:"parallelism"`
+			SaltLength  uint32 `mapstructure:"salt_length"`
+			KeyLength   uint32 `mapstructure:"key_length"`
+		} `mapstructure:"argon2id"`
+		BcryptCost int `mapstructure:"bcrypt_cost"`
+	} `mapstructure:"password_hashing"`
+
 	Log struct {
 		Level  string `mapstructure:"level"`
 		Format string `mapstructure:"format"`
@@ -71,7 +399,71 @@ type Config struct {
 	} `mapstructure:"initial_admin"`
 }
 
+// StrictJSONGroups are the route groups whose JSON decoding can be made strict
+var StrictJSONGroups = []string{"cloudapi", "admin", "legacy"}
+
+// ApprovalOperations are the operations the approval webhook can gate
+var ApprovalOperations = []string{"gpuVmCreate", "vdcDelete"}
+
+// DefaultCatalogConfig configures the catalog created for new organizations
+type DefaultCatalogConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	// Templates are the names of the starter Templates the catalog lists; when
+	// empty the catalog lists every Template
+	Templates []string `mapstructure:"templates"`
+}
+
+// VMSizeConfig is the compute a VM reserves
+type VMSizeConfig struct {
+	CPUs     int `mapstructure:"cpus"`
+	MemoryMB int `mapstructure:"memory_mb"`
+}
+
+// EmailConfig configures the SMTP notifier
+type EmailConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+	// Recipients receive system alerts that are not addressed to a particular user
+	Recipients []string `mapstructure:"recipients"`
+	// TemplateDir holds <event>.tmpl files that replace the built-in message templates
+	TemplateDir string `mapstructure:"template_dir"`
+	// SenderOverrides send an organization's notifications from another address
+	SenderOverrides []EmailSenderOverride `mapstructure:"sender_overrides"`
+}
+
+// EmailSenderOverride sets the From address of one organization's notifications
+type EmailSenderOverride struct {
+	OrganizationID string `mapstructure:"organization_id"`
+	From           string `mapstructure:"from"`
+}
+
+// GroupMappingConfig makes the members of an OpenShift Group members of an
+// organization with the given roles. A user in several mapped Groups receives
+// the roles of all of them, which must name the same organization.
+type GroupMappingConfig struct {
+	Group string `mapstructure:"group"`
+	// Organization is the name of the organization
+	Organization string `mapstructure:"organization"`
+	// Roles are role names, such as "vApp User"
+	Roles []string `mapstructure:"roles"`
+}
+
+// SiteAssociationConfig describes another site associated with this one
+type SiteAssociationConfig struct {
+	SiteID       string `mapstructure:"site_id"`
+	SiteName     string `mapstructure:"site_name"`
+	RestEndpoint string `mapstructure:"rest_endpoint"`
+}
+
 func Load() (*Config, error) {
+	viper.SetDefault("database.driver", "postgres")
+	viper.SetDefault("database.path", "ssvirt.db")
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", 5432)
 	viper.SetDefault("database.username", "postgres")
@@ -82,22 +474,137 @@ func Load() (*Config, error) {
 	viper.SetDefault("database.max_idle_connections", 10)
 	viper.SetDefault("database.conn_max_lifetime", "1h")
 	viper.SetDefault("database.conn_max_idle_time", "10m")
+	viper.SetDefault("database.log_level", "info")
+	viper.SetDefault("database.slow_query_threshold", "200ms")
 	viper.SetDefault("database.retry.max_attempts", 30)
 	viper.SetDefault("database.retry.initial_delay", "2s")
 	viper.SetDefault("database.retry.max_delay", "30s")
 	viper.SetDefault("database.retry.backoff_multiple", 1.5)
+	viper.SetDefault("database.vm_archive.interval", "1h")
+	viper.SetDefault("database.vm_archive.archive_after", "720h")
+	viper.SetDefault("database.vm_archive.retention", "8760h")
 	viper.SetDefault("api.port", 8080)
+	viper.SetDefault("api.metrics_address", ":9090")
+	viper.SetDefault("api.trusted_proxies", []string{})
+	viper.SetDefault("api.shutdown_timeout", "20s")
+	viper.SetDefault("api.usage.flush_interval", "1m")
+	viper.SetDefault("api.usage.retention_days", 90)
+	viper.SetDefault("api.requests.max_body_bytes", 1<<20)
+	viper.SetDefault("api.requests.max_json_depth", 32)
+	viper.SetDefault("api.requests.max_json_array_length", 10000)
+	viper.SetDefault("api.requests.strict_json_groups", []string{})
+	viper.SetDefault("public_catalog.enabled", false)
+	viper.SetDefault("public_catalog.requests_per_minute", 60)
+	viper.SetDefault("public_catalog.burst", 20)
 	// JWT secret MUST be explicitly configured - no insecure default
 	if os.Getenv("SSVIRT_AUTH_JWT_SECRET") == "" {
 		log.Println("WARNING: JWT secret not configured. Set SSVIRT_AUTH_JWT_SECRET environment variable.")
 		viper.SetDefault("auth.jwt_secret", "development-secret-change-in-production")
 	}
 	viper.SetDefault("auth.token_expiry", "24h")
+	viper.SetDefault("auth.role_cache_ttl", "30s")
+	viper.SetDefault("auth.impersonation_ttl", "15m")
+	viper.SetDefault("auth.issuer", "")
+	viper.SetDefault("auth.audience", "")
+	viper.SetDefault("auth.clock_skew", "0s")
 	viper.SetDefault("session.idle_timeout_minutes", 30)
 	viper.SetDefault("session.site.name", "SSVirt Provider")
 	viper.SetDefault("session.site.id", "urn:vcloud:site:00000000-0000-0000-0000-000000000001")
 	viper.SetDefault("session.location", "us-west-1")
+	viper.SetDefault("provider.installation_id", 1)
 	viper.SetDefault("kubernetes.namespace", "ssvirt-system")
+	viper.SetDefault("kubernetes.cache.resync_period", "10m")
+	viper.SetDefault("kubernetes.cache.sync_timeout", "30s")
+	viper.SetDefault("kubernetes.cache.namespace_selector", "")
+	viper.SetDefault("kubernetes.writes.qps", 5)
+	viper.SetDefault("kubernetes.writes.burst", 10)
+	viper.SetDefault("kubernetes.writes.queue_depth", 50)
+	viper.SetDefault("kubernetes.writes.breaker_cooldown", "30s")
+	viper.SetDefault("organizations.hierarchical_access", false)
+	viper.SetDefault("organizations.default_catalog.enabled", false)
+	viper.SetDefault("organizations.default_catalog.name", "Default Catalog")
+	viper.SetDefault("organizations.default_catalog.description", "Starter templates")
+	viper.SetDefault("organizations.default_catalog.templates", []string{})
+	viper.SetDefault("notifications.poll_interval", "2s")
+	viper.SetDefault("notifications.email.enabled", false)
+	viper.SetDefault("notifications.email.host", "")
+	viper.SetDefault("notifications.email.port", 587)
+	viper.SetDefault("notifications.email.username", "")
+	viper.SetDefault("notifications.email.password", "")
+	viper.SetDefault("notifications.email.from", "")
+	viper.SetDefault("notifications.email.recipients", []string{})
+	viper.SetDefault("notifications.email.template_dir", "")
+	viper.SetDefault("controllers.vm_status.max_concurrent_reconciles", 1)
+	viper.SetDefault("controllers.vm_status.retry_base_delay", "5s")
+	viper.SetDefault("controllers.vm_status.retry_max_delay", "5m")
+	viper.SetDefault("controllers.vm_status.namespace_retry_qps", 5.0)
+	viper.SetDefault("controllers.vm_status.namespace_retry_burst", 50)
+	viper.SetDefault("controllers.vm_status.status_buffer.enabled", true)
+	viper.SetDefault("controllers.vm_status.status_buffer.capacity", 10000)
+	viper.SetDefault("controllers.vm_status.status_buffer.overflow_policy", "drop-oldest")
+	viper.SetDefault("controllers.vm_status.status_buffer.replay_interval", "10s")
+	viper.SetDefault("controllers.vm_status.label_sweep_interval", "1h")
+	viper.SetDefault("controllers.vapp_status.max_concurrent_reconciles", 1)
+	viper.SetDefault("controllers.vapp_status.stuck_alert_after", "30m")
+	viper.SetDefault("controllers.storage_usage.alert_webhook_url", "")
+	viper.SetDefault("controllers.storage_usage.alert_webhook_timeout", "10s")
+	viper.SetDefault("controllers.auto_suspend.interval", "5m")
+	viper.SetDefault("controllers.auto_suspend.notice_period", "1h")
+	viper.SetDefault("controllers.catalog_sync.poll_interval", "30s")
+	viper.SetDefault("controllers.catalog_sync.fetch_timeout", "3m")
+	viper.SetDefault("controllers.power_state.poll_interval", "10s")
+	viper.SetDefault("controllers.power_state.retry_max_delay", "5m")
+	viper.SetDefault("controllers.janitor.interval", "10m")
+	viper.SetDefault("controllers.janitor.secret_grace_period", "15m")
+	viper.SetDefault("controllers.janitor.failed_instance_retention", "168h")
+	viper.SetDefault("controllers.prewarm.poll_interval", "1m")
+	viper.SetDefault("internal_api.controller_url", "")
+	viper.SetDefault("internal_api.callback_url", "")
+	viper.SetDefault("internal_api.listen_address", ":8443")
+	viper.SetDefault("internal_api.tls_cert", "")
+	viper.SetDefault("internal_api.tls_key", "")
+	viper.SetDefault("internal_api.ca_cert", "")
+	viper.SetDefault("internal_api.retry_attempts", 5)
+	viper.SetDefault("internal_api.retry_base_delay", "500ms")
+	viper.SetDefault("internal_api.command_timeout", "5m")
+	viper.SetDefault("pricing.currency", "USD")
+	viper.SetDefault("pricing.cpu_hour", 0.0)
+	viper.SetDefault("pricing.memory_gib_hour", 0.0)
+	viper.SetDefault("pricing.storage_gib_month", 0.0)
+	viper.SetDefault("pricing.gpu_hour", 0.0)
+	viper.SetDefault("pricing.hours_per_month", 730.0)
+	viper.SetDefault("quota.grace_period", "24h")
+	viper.SetDefault("quota.objects.pods", 50)
+	viper.SetDefault("quota.objects.persistent_volume_claims", 20)
+	viper.SetDefault("quota.objects.services", 10)
+	viper.SetDefault("quota.objects.secrets", 50)
+	viper.SetDefault("quota.objects.config_maps", 50)
+	viper.SetDefault("quota.default_vm_size.cpus", 2)
+	viper.SetDefault("quota.default_vm_size.memory_mb", 4096)
+	viper.SetDefault("instantiation.max_concurrent_per_vdc", 0)
+	viper.SetDefault("instantiation.max_concurrent_per_org", 0)
+	viper.SetDefault("instantiation.queue_timeout", "0s")
+	viper.SetDefault("naming.reserved_prefixes", []string{"kube", "openshift", "vdc-"})
+	viper.SetDefault("backup.velero_namespace", "openshift-adp")
+	viper.SetDefault("export.ttl", "24h")
+	viper.SetDefault("export.timeout", "30m")
+	viper.SetDefault("network_flows.prometheus_url", "")
+	viper.SetDefault("network_flows.bearer_token_file", "")
+	viper.SetDefault("network_flows.ca_file", "")
+	viper.SetDefault("network_flows.timeout", "10s")
+	viper.SetDefault("network_flows.metric", "netobserv_workload_egress_bytes_total")
+	viper.SetDefault("approval.webhook_url", "")
+	viper.SetDefault("approval.secret", "")
+	viper.SetDefault("approval.timeout", "10s")
+	viper.SetDefault("approval.operations", ApprovalOperations)
+	viper.SetDefault("group_sync.interval", "10m")
+	viper.SetDefault("password_hashing.algorithm", "argon2id")
+	viper.SetDefault("password_hashing.argon2id.memory_kib", 19456)
+	viper.SetDefault("password_hashing.argon2id.iterations", 2)
+	viper.SetDefault("password_hashing.argon2id.parallelism", 1)
+	viper.SetDefault("password_hashing.argon2id.salt_length", 16)
+	viper.SetDefault("password_hashing.argon2id.key_length", 32)
+	viper.SetDefault("password_hashing.bcrypt_cost", 10)
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.format", "json")
 	viper.SetDefault("initial_admin.enabled", false)
@@ -219,6 +726,113 @@ func validateConfig(config *Config) error {
 		config.Session.IdleTimeoutMinutes = 30
 	}
 
+	// Validate controller concurrency
+	if config.Controllers.VMStatus.MaxConcurrentReconciles <= 0 {
+		log.Printf("Warning: Invalid VM status controller concurrency %d, setting to default 1", config.Controllers.VMStatus.MaxConcurrentReconciles)
+		config.Controllers.VMStatus.MaxConcurrentReconciles = 1
+	}
+	if config.Controllers.VAppStatus.MaxConcurrentReconciles <= 0 {
+		log.Printf("Warning: Invalid vApp status controller concurrency %d, setting to default 1", config.Controllers.VAppStatus.MaxConcurrentReconciles)
+		config.Controllers.VAppStatus.MaxConcurrentReconciles = 1
+	}
+
+	// Validate request limits
+	requests := config.API.Requests
+	if requests.MaxBodyBytes < 0 || requests.MaxJSONDepth < 0 || requests.MaxJSONArrayLength < 0 {
+		return fmt.Errorf("invalid api request settings: max_body_bytes, max_json_depth and max_json_array_length must not be negative")
+	}
+	for _, group := range requests.StrictJSONGroups {
+		if !slices.Contains(StrictJSONGroups, group) {
+			return fmt.Errorf("invalid api request settings: unknown strict_json_groups entry '%s', must be one of %s", group, strings.Join(StrictJSONGroups, ", "))
+		}
+	}
+
+	// Validate trusted proxies
+	for _, proxy := range config.API.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid api settings: trusted_proxies entry '%s' is not an IP address or CIDR", proxy)
+		}
+	}
+
+	// Validate token clock skew
+	if config.Auth.ClockSkew < 0 {
+		return fmt.Errorf("invalid auth settings: clock_skew must not be negative")
+	}
+
+	// Validate the default VM size used for capacity estimates
+	if config.Quota.DefaultVMSize.CPUs <= 0 || config.Quota.DefaultVMSize.MemoryMB <= 0 {
+		return fmt.Errorf("invalid quota settings: default_vm_size cpus and memory_mb must be positive")
+	}
+
+	// Validate cache tuning
+	if config.Kubernetes.Cache.ResyncPeriod < 0 || config.Kubernetes.Cache.SyncTimeout < 0 {
+		return fmt.Errorf("invalid kubernetes cache settings: resync_period and sync_timeout must not be negative")
+	}
+	if config.Export.TTL <= 0 || config.Export.Timeout <= 0 {
+		return fmt.Errorf("invalid export settings: ttl and timeout must be positive")
+	}
+	if writes := config.Kubernetes.Writes; writes.QPS < 0 ||
+		(writes.QPS > 0 && (writes.Burst <= 0 || writes.QueueDepth <= 0 || writes.BreakerCooldown <= 0)) {
+		return fmt.Errorf("invalid kubernetes write settings: qps must not be negative, and burst, queue_depth and breaker_cooldown must be positive when it is set")
+	}
+
+	// Validate the anonymous catalog rate limit
+	if config.PublicCatalog.Enabled && (config.PublicCatalog.RequestsPerMinute <= 0 || config.PublicCatalog.Burst <= 0) {
+		return fmt.Errorf("invalid public catalog settings: requests_per_minute and burst must be positive")
+	}
+
+	// Validate VM archiving
+	archive := config.Database.VMArchive
+	if archive.Interval < 0 || archive.ArchiveAfter < 0 || archive.Retention < 0 {
+		return fmt.Errorf("invalid VM archive settings: interval, archive_after and retention must not be negative")
+	}
+
+	// Validate the default ResourceQuota object counts
+	objects := config.Quota.Objects
+	if objects.Pods < 0 || objects.PersistentVolumeClaims < 0 || objects.Services < 0 || objects.Secrets < 0 || objects.ConfigMaps < 0 {
+		return fmt.Errorf("invalid quota object counts: must not be negative")
+	}
+
+	// Validate the approval webhook
+	if approval := config.Approval; approval.WebhookURL != "" {
+		if !strings.HasPrefix(approval.WebhookURL, "https://") && !strings.HasPrefix(approval.WebhookURL, "http://") {
+			return fmt.Errorf("invalid approval webhook URL '%s': must be an http or https URL", approval.WebhookURL)
+		}
+		if approval.Secret == "" {
+			return fmt.Errorf("approval webhook enabled but approval.secret is not set")
+		}
+		if approval.Timeout <= 0 {
+			return fmt.Errorf("invalid approval settings: timeout must be positive")
+		}
+		for _, operation := range approval.Operations {
+			if !slices.Contains(ApprovalOperations, operation) {
+				return fmt.Errorf("invalid approval operation '%s': must be one of %s", operation, strings.Join(ApprovalOperations, ", "))
+			}
+		}
+	}
+
+	// Validate provider settings
+	if config.Provider.InstallationID < 1 || config.Provider.InstallationID > 63 {
+		return fmt.Errorf("invalid provider installation ID %d: must be between 1 and 63", config.Provider.InstallationID)
+	}
+	for _, association := range config.Provider.SiteAssociations {
+		if !strings.HasPrefix(association.SiteID, "urn:vcloud:site:") {
+			return fmt.Errorf("invalid site association ID '%s': must be a valid site URN (urn:vcloud:site:...)", association.SiteID)
+		}
+	}
+
+	// Validate email notification settings
+	if email := config.Notifications.Email; email.Enabled {
+		if email.Host == "" || email.From == "" {
+			return fmt.Errorf("email notifications enabled but notifications.email.host or notifications.email.from is not set")
+		}
+		for _, override := range email.SenderOverrides {
+			if override.OrganizationID == "" || override.From == "" {
+				return fmt.Errorf("invalid email sender override: organization_id and from are required")
+			}
+		}
+	}
+
 	// Validate session site ID URN format
 	if config.Session.Site.ID != "" {
 		if !strings.HasPrefix(config.Session.Site.ID, "urn:vcloud:site:") {
@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// DefaultAutoSuspendInterval is how often the auto-suspend controller samples
+// the CPU usage of VMs
+const DefaultAutoSuspendInterval = 5 * time.Minute
+
+// AutoSuspendVMRepositoryInterface defines the VM repository operations used to
+// track idle VMs
+type AutoSuspendVMRepositoryInterface interface {
+	GetByNamespaceAndVMName(ctx context.Context, namespace, vmName string) (*models.VM, error)
+	UpdateIdleState(ctx context.Context, vmID string, idleSince, notifiedAt *time.Time) error
+	SetDesiredPowerState(ctx context.Context, vmID string, state string) error
+}
+
+// VMIPauser pauses running VirtualMachineInstances
+type VMIPauser interface {
+	PauseVMI(ctx context.Context, namespace, name string) error
+}
+
+// AutoSuspendOptions configures how often VMs are sampled and how long owners
+// are warned before an idle VM is suspended. A zero NoticePeriod suspends the
+// VM at the sample after the notice.
+type AutoSuspendOptions struct {
+	Interval     time.Duration
+	NoticePeriod time.Duration
+}
+
+// AutoSuspendController applies the auto-suspend policy of each VDC. It samples
+// the CPU usage of the running VMs in VDC namespaces every interval; a VM whose
+// usage stays below the policy's threshold for the idle period is reported to
+// its owners, then suspended or powered off once the notice period passes
+// without activity. VMs tagged no-auto-suspend are left alone.
+type AutoSuspendController struct {
+	client.Client
+	VDCRepo  VDCStatusRepositoryInterface
+	VMRepo   AutoSuspendVMRepositoryInterface
+	Metrics  services.MetricsService
+	Pauser   VMIPauser
+	Recorder record.EventRecorder
+	Notifier services.Notifier
+	Options  AutoSuspendOptions
+
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups=metrics.k8s.io,resources=pods,verbs=get
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachineinstances,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachines,verbs=get;patch
+// +kubebuilder:rbac:groups=subresources.kubevirt.io,resources=virtualmachineinstances/pause,verbs=update
+
+// Reconcile checks the running VMs of the VDC owning a namespace for idleness
+func (r *AutoSuspendController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Name)
+
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: req.Name}, &namespace); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	vdc, err := r.VDCRepo.GetByNamespace(ctx, namespace.Name)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to look up VDC for namespace %s: %w", namespace.Name, err)
+	}
+	if vdc == nil {
+		return ctrl.Result{}, nil
+	}
+
+	// Keep sampling while the policy is disabled so enabling it takes effect
+	// without a change to the namespace
+	result := ctrl.Result{RequeueAfter: r.Options.Interval}
+	policy := vdc.AutoSuspendPolicy()
+	if !policy.Enabled || policy.IdleHours <= 0 {
+		return result, nil
+	}
+
+	var vmis kubevirtv1.VirtualMachineInstanceList
+	if err := r.List(ctx, &vmis, client.InNamespace(namespace.Name)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list VirtualMachineInstances: %w", err)
+	}
+	for i := range vmis.Items {
+		vmi := &vmis.Items[i]
+		if vmi.Status.Phase != kubevirtv1.Running || isPaused(vmi) {
+			continue
+		}
+		if err := r.checkVM(ctx, vdc, policy, vmi, logger); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// checkVM records whether a running VM is idle and notifies, then suspends, a
+// VM that has been idle for the policy's idle period
+func (r *AutoSuspendController) checkVM(ctx context.Context, vdc *models.VDC, policy models.AutoSuspendPolicy, vmi *kubevirtv1.VirtualMachineInstance, logger logr.Logger) error {
+	vm, err := r.VMRepo.GetByNamespaceAndVMName(ctx, vmi.Namespace, vmi.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up VM %s: %w", vmi.Name, err)
+	}
+	if vm.HasTag(models.VMTagNoAutoSuspend) {
+		return r.clearIdleState(ctx, vm)
+	}
+
+	usage, err := r.Metrics.VMCPUUsage(ctx, vmi.Namespace, vmi.Name)
+	if errors.Is(err, services.ErrMetricsUnavailable) || errors.Is(err, services.ErrVMNotRunning) {
+		return nil
+	}
+	if err != nil {
+		// Metrics of one VM must not hold up the rest of the VDC
+		logger.Error(err, "Failed to get VM CPU usage", "vm", vm.ID)
+		return nil
+	}
+	if usage > idleThresholdMillicores(vm, policy) {
+		return r.clearIdleState(ctx, vm)
+	}
+
+	now := r.currentTime()
+	switch {
+	case vm.IdleSince == nil:
+		return r.updateIdleState(ctx, vm, &now, nil)
+	case now.Sub(*vm.IdleSince) < policy.IdlePeriod():
+		return nil
+	case vm.IdleNotifiedAt == nil:
+		if err := r.sendNotice(ctx, vdc, policy, vm, vmi, now); err != nil {
+			return err
+		}
+		logger.Info("Idle VM notice sent", "vm", vm.ID, "idleSince", vm.IdleSince, "action", policy.Action)
+		return r.updateIdleState(ctx, vm, vm.IdleSince, &now)
+	case now.Sub(*vm.IdleNotifiedAt) < r.Options.NoticePeriod:
+		return nil
+	}
+
+	if err := r.suspend(ctx, policy.Action, vm, vmi); err != nil {
+		return err
+	}
+	logger.Info("Idle VM suspended by auto-suspend policy", "vm", vm.ID, "idleSince", vm.IdleSince, "action", policy.Action)
+	return r.clearIdleState(ctx, vm)
+}
+
+// sendNotice warns the VM's owners that the VM will be suspended
+func (r *AutoSuspendController) sendNotice(ctx context.Context, vdc *models.VDC, policy models.AutoSuspendPolicy, vm *models.VM, vmi *kubevirtv1.VirtualMachineInstance, now time.Time) error {
+	actionAt := now.Add(r.Options.NoticePeriod)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(vmi, corev1.EventTypeWarning, "IdleVMNotice",
+			"VM has been idle since %s and will be %s after %s unless it becomes active",
+			vm.IdleSince.UTC().Format(time.RFC3339), actionVerb(policy.Action), actionAt.UTC().Format(time.RFC3339))
+	}
+	if r.Notifier == nil {
+		return nil
+	}
+	err := r.Notifier.Notify(ctx, services.Notification{
+		Event:          services.NotificationVMIdle,
+		OrganizationID: vdc.OrganizationID,
+		Data: map[string]interface{}{
+			"VMID":                vm.ID,
+			"VMName":              vm.Name,
+			"VDCName":             vdc.Name,
+			"Namespace":           vmi.Namespace,
+			"CPUThresholdPercent": policy.CPUThresholdPercent,
+			"IdleSince":           *vm.IdleSince,
+			"IdleFor":             now.Sub(*vm.IdleSince).Round(time.Minute).String(),
+			"Action":              actionName(policy.Action),
+			"ActionAt":            actionAt,
+			"OptOutTag":           models.VMTagNoAutoSuspend,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send idle VM notice: %w", err)
+	}
+	return nil
+}
+
+// suspend pauses the VM or powers it off, according to the policy's action
+func (r *AutoSuspendController) suspend(ctx context.Context, action string, dbVM *models.VM, vmi *kubevirtv1.VirtualMachineInstance) error {
+	if action == models.AutoSuspendActionPowerOff {
+		// Otherwise the power state controller would start the VM again
+		if err := r.VMRepo.SetDesiredPowerState(ctx, dbVM.ID, models.VMPowerStateOff); err != nil {
+			return fmt.Errorf("failed to set desired power state of VM %s: %w", dbVM.ID, err)
+		}
+		vm := &kubevirtv1.VirtualMachine{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: vmi.Namespace, Name: vmi.Name}, vm); err != nil {
+			return fmt.Errorf("failed to get VirtualMachine %s: %w", vmi.Name, err)
+		}
+		patch := client.MergeFrom(vm.DeepCopy())
+		halted := kubevirtv1.RunStrategyHalted
+		vm.Spec.RunStrategy = &halted
+		vm.Spec.Running = nil
+		if err := r.Patch(ctx, vm, patch); err != nil {
+			return fmt.Errorf("failed to power off VirtualMachine %s: %w", vmi.Name, err)
+		}
+	} else if err := r.Pauser.PauseVMI(ctx, vmi.Namespace, vmi.Name); err != nil {
+		return err
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Eventf(vmi, corev1.EventTypeNormal, "IdleVMSuspended",
+			"VM %s by the VDC auto-suspend policy after being idle", actionVerb(action))
+	}
+	return nil
+}
+
+// clearIdleState forgets that a VM was idle
+func (r *AutoSuspendController) clearIdleState(ctx context.Context, vm *models.VM) error {
+	if vm.IdleSince == nil && vm.IdleNotifiedAt == nil {
+		return nil
+	}
+	return r.updateIdleState(ctx, vm, nil, nil)
+}
+
+// updateIdleState records a VM's idle state
+func (r *AutoSuspendController) updateIdleState(ctx context.Context, vm *models.VM, idleSince, notifiedAt *time.Time) error {
+	if err := r.VMRepo.UpdateIdleState(ctx, vm.ID, idleSince, notifiedAt); err != nil {
+		return fmt.Errorf("failed to update idle state of VM %s: %w", vm.ID, err)
+	}
+	return nil
+}
+
+func (r *AutoSuspendController) currentTime() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// idleThresholdMillicores converts the policy's CPU threshold to millicores of
+// the VM's vCPUs
+func idleThresholdMillicores(vm *models.VM, policy models.AutoSuspendPolicy) int64 {
+	cpus := int64(1)
+	if vm.CPUCount != nil && *vm.CPUCount > 0 {
+		cpus = int64(*vm.CPUCount)
+	}
+	return cpus * 1000 * int64(policy.CPUThresholdPercent) / 100
+}
+
+// isPaused reports whether a VirtualMachineInstance is paused
+func isPaused(vmi *kubevirtv1.VirtualMachineInstance) bool {
+	for _, cond := range vmi.Status.Conditions {
+		if cond.Type == kubevirtv1.VirtualMachineInstancePaused && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// actionName describes an auto-suspend action for notifications
+func actionName(action string) string {
+	if action == models.AutoSuspendActionPowerOff {
+		return "power off"
+	}
+	return "suspend"
+}
+
+// actionVerb describes a completed auto-suspend action for events
+func actionVerb(action string) string {
+	if action == models.AutoSuspendActionPowerOff {
+		return "powered off"
+	}
+	return "suspended"
+}
+
+// SetupAutoSuspendController sets up the auto-suspend controller. notifier may be
+// nil, in which case idle VM notices are only recorded as events.
+func SetupAutoSuspendController(mgr ctrl.Manager, vdcRepo VDCStatusRepositoryInterface, vmRepo AutoSuspendVMRepositoryInterface, metrics services.MetricsService, pauser VMIPauser, notifier services.Notifier, autoSuspend AutoSuspendOptions, opts ControllerOptions) error {
+	if autoSuspend.Interval <= 0 {
+		autoSuspend.Interval = DefaultAutoSuspendInterval
+	}
+	controller := &AutoSuspendController{
+		Client:   mgr.GetClient(),
+		VDCRepo:  vdcRepo,
+		VMRepo:   vmRepo,
+		Metrics:  metrics,
+		Pauser:   pauser,
+		Recorder: mgr.GetEventRecorderFor("auto-suspend-controller"),
+		Notifier: notifier,
+		Options:  autoSuspend,
+	}
+
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named(AutoSuspendControllerName).
+		WithOptions(opts.controllerOptions(AutoSuspendControllerName)).
+		For(&corev1.Namespace{}, builder.WithPredicates(predicate.NewPredicateFuncs(isVDCNamespace))).
+		Complete(opts.wrap(controller))
+	if err != nil {
+		return fmt.Errorf("failed to setup AutoSuspendController: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// fakeIdleVMRepo keeps VMs in memory, keyed by VM name
+type fakeIdleVMRepo struct {
+	vms map[string]*models.VM
+}
+
+func (f *fakeIdleVMRepo) GetByNamespaceAndVMName(_ context.Context, _, vmName string) (*models.VM, error) {
+	vm, ok := f.vms[vmName]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *vm
+	return &copied, nil
+}
+
+func (f *fakeIdleVMRepo) UpdateIdleState(_ context.Context, vmID string, idleSince, notifiedAt *time.Time) error {
+	for _, vm := range f.vms {
+		if vm.ID == vmID {
+			vm.IdleSince, vm.IdleNotifiedAt = idleSince, notifiedAt
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (f *fakeIdleVMRepo) SetDesiredPowerState(_ context.Context, vmID string, state string) error {
+	for _, vm := range f.vms {
+		if vm.ID == vmID {
+			vm.DesiredPowerState = state
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// fakeMetrics reports a fixed CPU usage per VM name
+type fakeMetrics map[string]int64
+
+func (f fakeMetrics) VMCPUUsage(_ context.Context, _, vmName string) (int64, error) {
+	usage, ok := f[vmName]
+	if !ok {
+		return 0, services.ErrMetricsUnavailable
+	}
+	return usage, nil
+}
+
+type recordingPauser struct {
+	paused []string
+}
+
+func (p *recordingPauser) PauseVMI(_ context.Context, _, name string) error {
+	p.paused = append(p.paused, name)
+	return nil
+}
+
+type recordingEmailNotifier struct {
+	notifications []services.Notification
+}
+
+func (n *recordingEmailNotifier) Notify(_ context.Context, notification services.Notification) error {
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+func devVMI(name string) *kubevirtv1.VirtualMachineInstance {
+	return &kubevirtv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "dev-ns"},
+		Status:     kubevirtv1.VirtualMachineInstanceStatus{Phase: kubevirtv1.Running},
+	}
+}
+
+func TestAutoSuspendController_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+
+	always := kubevirtv1.RunStrategyAlways
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev-ns", Labels: map[string]string{vdcNamespaceLabel: "1234"}}},
+		devVMI("idle"), devVMI("busy"), devVMI("pinned"),
+		&kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "idle", Namespace: "dev-ns"},
+			Spec:       kubevirtv1.VirtualMachineSpec{RunStrategy: &always},
+		},
+	).Build()
+
+	vdc := &models.VDC{ID: "urn:vcloud:vdc:1234", Name: "dev", OrganizationID: "urn:vcloud:org:1", Namespace: "dev-ns"}
+	vdc.SetAutoSuspendPolicy(models.AutoSuspendPolicy{Enabled: true, IdleHours: 8})
+	cpus := 2
+	pinned := &models.VM{ID: "urn:vcloud:vm:3", Name: "pinned", CPUCount: &cpus}
+	pinned.SetTags([]string{"prod", models.VMTagNoAutoSuspend})
+	repo := &fakeIdleVMRepo{vms: map[string]*models.VM{
+		"idle":   {ID: "urn:vcloud:vm:1", Name: "idle", CPUCount: &cpus},
+		"busy":   {ID: "urn:vcloud:vm:2", Name: "busy", CPUCount: &cpus},
+		"pinned": pinned,
+	}}
+	// 5% of 2 vCPUs is 100 millicores
+	metrics := fakeMetrics{"idle": 40, "busy": 900, "pinned": 0}
+	pauser := &recordingPauser{}
+	notifier := &recordingEmailNotifier{}
+	now := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+
+	controller := &AutoSuspendController{
+		Client:   fakeClient,
+		VDCRepo:  &fakeVDCLookup{vdc: vdc},
+		VMRepo:   repo,
+		Metrics:  metrics,
+		Pauser:   pauser,
+		Recorder: record.NewFakeRecorder(10),
+		Notifier: notifier,
+		Options:  AutoSuspendOptions{Interval: 5 * time.Minute, NoticePeriod: time.Hour},
+		now:      func() time.Time { return now },
+	}
+	reconcile := func() {
+		result, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "dev-ns"}})
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Minute, result.RequeueAfter)
+	}
+
+	t.Run("idle VMs are tracked", func(t *testing.T) {
+		reconcile()
+		require.NotNil(t, repo.vms["idle"].IdleSince)
+		assert.Equal(t, now, *repo.vms["idle"].IdleSince)
+		assert.Nil(t, repo.vms["busy"].IdleSince)
+		assert.Nil(t, repo.vms["pinned"].IdleSince, "opted-out VMs are not tracked")
+		assert.Empty(t, notifier.notifications)
+	})
+
+	t.Run("owners are notified after the idle period", func(t *testing.T) {
+		now = now.Add(7 * time.Hour)
+		reconcile()
+		assert.Empty(t, notifier.notifications)
+
+		now = now.Add(time.Hour)
+		reconcile()
+		require.Len(t, notifier.notifications, 1)
+		assert.Equal(t, services.NotificationVMIdle, notifier.notifications[0].Event)
+		assert.Equal(t, vdc.OrganizationID, notifier.notifications[0].OrganizationID)
+		assert.Equal(t, now, *repo.vms["idle"].IdleNotifiedAt)
+		assert.Empty(t, pauser.paused)
+
+		reconcile()
+		assert.Len(t, notifier.notifications, 1, "the notice is sent once")
+	})
+
+	t.Run("idle VMs are suspended after the notice period", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		reconcile()
+		assert.Equal(t, []string{"idle"}, pauser.paused)
+		assert.Nil(t, repo.vms["idle"].IdleSince)
+		assert.Nil(t, repo.vms["idle"].IdleNotifiedAt)
+	})
+
+	t.Run("activity resets the idle period", func(t *testing.T) {
+		reconcile()
+		require.NotNil(t, repo.vms["idle"].IdleSince)
+		metrics["idle"] = 500
+		reconcile()
+		assert.Nil(t, repo.vms["idle"].IdleSince)
+	})
+
+	t.Run("power off action halts the VM", func(t *testing.T) {
+		vdc.SetAutoSuspendPolicy(models.AutoSuspendPolicy{Enabled: true, IdleHours: 1, Action: models.AutoSuspendActionPowerOff})
+		metrics["idle"] = 0
+		idleSince := now.Add(-2 * time.Hour)
+		notifiedAt := now.Add(-time.Hour)
+		repo.vms["idle"].IdleSince, repo.vms["idle"].IdleNotifiedAt = &idleSince, &notifiedAt
+
+		reconcile()
+		vm := &kubevirtv1.VirtualMachine{}
+		require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "idle", Namespace: "dev-ns"}, vm))
+		require.NotNil(t, vm.Spec.RunStrategy)
+		assert.Equal(t, kubevirtv1.RunStrategyHalted, *vm.Spec.RunStrategy)
+		assert.Equal(t, models.VMPowerStateOff, repo.vms["idle"].DesiredPowerState)
+		assert.Len(t, pauser.paused, 1)
+	})
+
+	t.Run("disabled policy leaves VMs alone", func(t *testing.T) {
+		vdc.SetAutoSuspendPolicy(models.AutoSuspendPolicy{})
+		reconcile()
+		assert.Nil(t, repo.vms["idle"].IdleSince)
+	})
+}
+
+type fakeVDCLookup struct {
+	vdc *models.VDC
+}
+
+func (f *fakeVDCLookup) GetByNamespace(_ context.Context, namespace string) (*models.VDC, error) {
+	if f.vdc.Namespace != namespace {
+		return nil, nil
+	}
+	return f.vdc, nil
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"gorm.io/gorm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// DefaultCatalogSyncPollInterval is how often the catalog sync controller
+// looks for sources that are due for a sync
+const DefaultCatalogSyncPollInterval = 30 * time.Second
+
+// Annotations and labels set on Templates imported from a catalog source
+const (
+	// CatalogSourceLabel holds the UUID of the catalog whose source imported a Template
+	CatalogSourceLabel = "ssvirt.io/catalog-source"
+	// CatalogSourceRevisionAnnotation records the source revision a Template was imported from
+	CatalogSourceRevisionAnnotation = "ssvirt.io/catalog-source-revision"
+)
+
+// CatalogSourceRepositoryInterface defines the catalog source operations used
+// by the catalog sync controller
+type CatalogSourceRepositoryInterface interface {
+	Get(ctx context.Context, catalogID string) (*models.CatalogSource, error)
+	List(ctx context.Context) ([]models.CatalogSource, error)
+	MarkSyncing(ctx context.Context, catalogID string) error
+	RecordSyncResult(ctx context.Context, catalogID string, syncErr error, revision string, templateCount int) error
+}
+
+// CatalogSourceFetcher downloads the Templates of a catalog source
+type CatalogSourceFetcher interface {
+	Fetch(ctx context.Context, source models.CatalogSource) (*services.FetchedTemplates, error)
+}
+
+// CatalogSyncController imports OpenShift Templates from the remote source of
+// each catalog into the catalog namespace. Sources are stored in the database
+// rather than the cluster, so the controller polls for sources whose sync
+// interval has passed or whose sync was requested through the API, instead of
+// watching a resource. Imported Templates carry the catalog-source label;
+// Templates that disappear from the source are deleted, and Templates created
+// by other means are never overwritten.
+type CatalogSyncController struct {
+	client.Client
+	Sources      CatalogSourceRepositoryInterface
+	Fetcher      CatalogSourceFetcher
+	Namespace    string
+	PollInterval time.Duration
+
+	// reconciler syncs one source; it wraps the controller for health tracking
+	reconciler reconcile.Reconciler
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=template.openshift.io,resources=templates,verbs=get;list;watch;create;update;delete
+
+// Start polls for sources due for a sync until the context is cancelled. It
+// implements manager.Runnable and runs only on the leader.
+func (r *CatalogSyncController) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("catalog-sync")
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = DefaultCatalogSyncPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.syncDue(ctx); err != nil {
+			logger.Error(err, "Failed to list catalog sources")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncDue syncs every source that is due, one at a time
+func (r *CatalogSyncController) syncDue(ctx context.Context) error {
+	sources, err := r.Sources.List(ctx)
+	if err != nil {
+		return err
+	}
+	reconciler := r.reconciler
+	if reconciler == nil {
+		reconciler = r
+	}
+	now := r.clock()
+	for _, source := range sources {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !source.SyncDue(now) {
+			continue
+		}
+		// Failures are recorded on the source and retried at its next interval
+		_, _ = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: source.CatalogID}})
+	}
+	return nil
+}
+
+// Reconcile syncs the source of the catalog named by the request
+func (r *CatalogSyncController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("catalog", req.Name)
+
+	source, err := r.Sources.Get(ctx, req.Name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if err := r.Sources.MarkSyncing(ctx, source.CatalogID); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to mark catalog source syncing: %w", err)
+	}
+
+	revision, count, syncErr := r.sync(ctx, source)
+	if syncErr != nil {
+		logger.Error(syncErr, "Catalog source sync failed", "url", source.URL)
+	} else {
+		logger.Info("Synced catalog source", "url", source.URL, "revision", revision, "templates", count)
+	}
+	if err := r.Sources.RecordSyncResult(ctx, source.CatalogID, syncErr, revision, count); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to record catalog source sync result: %w", err)
+	}
+	return ctrl.Result{}, syncErr
+}
+
+// sync imports the Templates of a source and prunes the ones it no longer has
+func (r *CatalogSyncController) sync(ctx context.Context, source *models.CatalogSource) (string, int, error) {
+	fetched, err := r.Fetcher.Fetch(ctx, *source)
+	if err != nil {
+		return "", 0, err
+	}
+
+	wanted := make(map[string]bool, len(fetched.Templates))
+	for i := range fetched.Templates {
+		tmpl := &fetched.Templates[i]
+		wanted[tmpl.Name] = true
+		if err := r.apply(ctx, source, tmpl, fetched.Revision); err != nil {
+			return "", 0, err
+		}
+	}
+
+	var existing templatev1.TemplateList
+	if err := r.List(ctx, &existing,
+		client.InNamespace(r.Namespace),
+		client.MatchingLabels{CatalogSourceLabel: source.LabelValue()},
+	); err != nil {
+		return "", 0, fmt.Errorf("failed to list imported Templates: %w", err)
+	}
+	for i := range existing.Items {
+		if wanted[existing.Items[i].Name] {
+			continue
+		}
+		if err := r.Delete(ctx, &existing.Items[i]); client.IgnoreNotFound(err) != nil {
+			return "", 0, fmt.Errorf("failed to delete Template %s: %w", existing.Items[i].Name, err)
+		}
+	}
+	return fetched.Revision, len(fetched.Templates), nil
+}
+
+// apply creates or updates one imported Template
+func (r *CatalogSyncController) apply(ctx context.Context, source *models.CatalogSource, tmpl *templatev1.Template, revision string) error {
+	// Only the name, labels and annotations of the source's metadata are kept
+	desired := tmpl.DeepCopy()
+	desired.ObjectMeta = metav1.ObjectMeta{
+		Name:        tmpl.Name,
+		Namespace:   r.Namespace,
+		Labels:      desired.Labels,
+		Annotations: desired.Annotations,
+	}
+	if desired.Labels == nil {
+		desired.Labels = make(map[string]string)
+	}
+	desired.Labels[CatalogSourceLabel] = source.LabelValue()
+	if desired.Annotations == nil {
+		desired.Annotations = make(map[string]string)
+	}
+	desired.Annotations[CatalogSourceRevisionAnnotation] = revision
+
+	// Validation results may be written between the read and the update, so
+	// a conflict reads the Template again and keeps its annotations
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		var current templatev1.Template
+		err := r.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: desired.Name}, &current)
+		if k8serrors.IsNotFound(err) {
+			if err := r.Create(ctx, desired); err != nil {
+				return fmt.Errorf("failed to create Template %s: %w", desired.Name, err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get Template %s: %w", desired.Name, err)
+		}
+		if current.Labels[CatalogSourceLabel] != source.LabelValue() {
+			return fmt.Errorf("template %s already exists and was not imported from this catalog source", desired.Name)
+		}
+
+		// Keep annotations written in the cluster, such as validation results
+		update := desired.DeepCopy()
+		for key, value := range current.Annotations {
+			if _, ok := update.Annotations[key]; !ok {
+				update.Annotations[key] = value
+			}
+		}
+		update.ResourceVersion = current.ResourceVersion
+		if err := r.Update(ctx, update); err != nil {
+			if k8serrors.IsConflict(err) {
+				recordConflictRetry(update)
+			}
+			return fmt.Errorf("failed to update Template %s: %w", desired.Name, err)
+		}
+		return nil
+	})
+}
+
+func (r *CatalogSyncController) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// SetupCatalogSyncController adds the catalog sync controller to the manager.
+// It imports Templates into the given namespace.
+func SetupCatalogSyncController(mgr ctrl.Manager, sources CatalogSourceRepositoryInterface, fetcher CatalogSourceFetcher, namespace string, pollInterval time.Duration, opts ControllerOptions) error {
+	controller := &CatalogSyncController{
+		Client:       mgr.GetClient(),
+		Sources:      sources,
+		Fetcher:      fetcher,
+		Namespace:    namespace,
+		PollInterval: pollInterval,
+	}
+	controller.reconciler = opts.wrap(controller)
+	if err := mgr.Add(controller); err != nil {
+		return fmt.Errorf("failed to setup CatalogSyncController: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// fakeCatalogSources keeps catalog sources in memory
+type fakeCatalogSources struct {
+	sources map[string]*models.CatalogSource
+	synced  []string
+}
+
+func (f *fakeCatalogSources) Get(_ context.Context, catalogID string) (*models.CatalogSource, error) {
+	source, ok := f.sources[catalogID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *source
+	return &copied, nil
+}
+
+func (f *fakeCatalogSources) List(_ context.Context) ([]models.CatalogSource, error) {
+	var sources []models.CatalogSource
+	for _, source := range f.sources {
+		sources = append(sources, *source)
+	}
+	return sources, nil
+}
+
+func (f *fakeCatalogSources) MarkSyncing(_ context.Context, catalogID string) error {
+	f.sources[catalogID].SyncStatus = models.CatalogSyncRunning
+	f.sources[catalogID].SyncRequestedAt = nil
+	return nil
+}
+
+func (f *fakeCatalogSources) RecordSyncResult(_ context.Context, catalogID string, syncErr error, revision string, templateCount int) error {
+	source := f.sources[catalogID]
+	f.synced = append(f.synced, catalogID)
+	now := time.Now()
+	source.LastSyncAt = &now
+	if syncErr != nil {
+		source.SyncStatus, source.SyncMessage = models.CatalogSyncFailed, syncErr.Error()
+		return nil
+	}
+	source.SyncStatus, source.SyncMessage = models.CatalogSyncSucceeded, ""
+	source.Revision, source.TemplateCount = revision, templateCount
+	return nil
+}
+
+// fakeFetcher returns fixed Templates
+type fakeFetcher struct {
+	fetched *services.FetchedTemplates
+	err     error
+}
+
+func (f *fakeFetcher) Fetch(_ context.Context, _ models.CatalogSource) (*services.FetchedTemplates, error) {
+	return f.fetched, f.err
+}
+
+func sourceTemplate(name, description string) templatev1.Template {
+	return templatev1.Template{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "elsewhere",
+			Labels:      map[string]string{services.TemplateVersionLabel: "v1"},
+			Annotations: map[string]string{"description": description},
+		},
+	}
+}
+
+func TestCatalogSyncController_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, templatev1.AddToScheme(scheme))
+
+	const catalogID = "urn:vcloud:catalog:11111111-2222-3333-4444-555555555555"
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&templatev1.Template{ObjectMeta: metav1.ObjectMeta{Name: "hand-made", Namespace: "catalog"}},
+		&templatev1.Template{ObjectMeta: metav1.ObjectMeta{
+			Name:      "retired",
+			Namespace: "catalog",
+			Labels:    map[string]string{CatalogSourceLabel: "11111111-2222-3333-4444-555555555555"},
+		}},
+	).Build()
+	requested := time.Now()
+	sources := &fakeCatalogSources{sources: map[string]*models.CatalogSource{
+		catalogID: {CatalogID: catalogID, Type: models.CatalogSourceGit, URL: "https://git.example.com/templates.git", SyncRequestedAt: &requested},
+	}}
+	fetcher := &fakeFetcher{fetched: &services.FetchedTemplates{
+		Templates: []templatev1.Template{sourceTemplate("rhel9", "RHEL 9"), sourceTemplate("fedora", "Fedora")},
+		Revision:  "abc123",
+	}}
+	controller := &CatalogSyncController{
+		Client:    fakeClient,
+		Sources:   sources,
+		Fetcher:   fetcher,
+		Namespace: "catalog",
+	}
+	getTemplate := func(name string) (*templatev1.Template, error) {
+		tmpl := &templatev1.Template{}
+		err := fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "catalog", Name: name}, tmpl)
+		return tmpl, err
+	}
+
+	t.Run("templates are imported and removed ones pruned", func(t *testing.T) {
+		require.NoError(t, controller.syncDue(context.Background()))
+		assert.Equal(t, []string{catalogID}, sources.synced)
+
+		source := sources.sources[catalogID]
+		assert.Equal(t, models.CatalogSyncSucceeded, source.SyncStatus)
+		assert.Equal(t, "abc123", source.Revision)
+		assert.Equal(t, 2, source.TemplateCount)
+		assert.Nil(t, source.SyncRequestedAt)
+
+		rhel, err := getTemplate("rhel9")
+		require.NoError(t, err)
+		assert.Equal(t, "11111111-2222-3333-4444-555555555555", rhel.Labels[CatalogSourceLabel])
+		assert.Equal(t, "v1", rhel.Labels[services.TemplateVersionLabel])
+		assert.Equal(t, "abc123", rhel.Annotations[CatalogSourceRevisionAnnotation])
+
+		_, err = getTemplate("retired")
+		assert.True(t, k8serrors.IsNotFound(err), "templates gone from the source are deleted")
+		_, err = getTemplate("hand-made")
+		assert.NoError(t, err, "unmanaged templates are left alone")
+	})
+
+	t.Run("sources are not synced before their interval", func(t *testing.T) {
+		require.NoError(t, controller.syncDue(context.Background()))
+		assert.Len(t, sources.synced, 1)
+	})
+
+	t.Run("updates keep cluster annotations", func(t *testing.T) {
+		rhel, err := getTemplate("rhel9")
+		require.NoError(t, err)
+		rhel.Annotations[services.ValidationStatusAnnotation] = models.CatalogItemValidationValid
+		require.NoError(t, fakeClient.Update(context.Background(), rhel))
+
+		fetcher.fetched = &services.FetchedTemplates{
+			Templates: []templatev1.Template{sourceTemplate("rhel9", "RHEL 9.4")},
+			Revision:  "def456",
+		}
+		_, err = controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: catalogID}})
+		require.NoError(t, err)
+
+		rhel, err = getTemplate("rhel9")
+		require.NoError(t, err)
+		assert.Equal(t, "RHEL 9.4", rhel.Annotations["description"])
+		assert.Equal(t, "def456", rhel.Annotations[CatalogSourceRevisionAnnotation])
+		assert.Equal(t, models.CatalogItemValidationValid, rhel.Annotations[services.ValidationStatusAnnotation])
+		_, err = getTemplate("fedora")
+		assert.True(t, k8serrors.IsNotFound(err))
+	})
+
+	t.Run("unmanaged templates are not overwritten", func(t *testing.T) {
+		fetcher.fetched = &services.FetchedTemplates{
+			Templates: []templatev1.Template{sourceTemplate("hand-made", "Imported")},
+			Revision:  "ghi789",
+		}
+		_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: catalogID}})
+		require.Error(t, err)
+
+		source := sources.sources[catalogID]
+		assert.Equal(t, models.CatalogSyncFailed, source.SyncStatus)
+		assert.Contains(t, source.SyncMessage, "hand-made")
+		assert.Equal(t, "def456", source.Revision, "a failed sync keeps the last revision")
+	})
+
+	t.Run("fetch failures are recorded", func(t *testing.T) {
+		fetcher.err = errors.New("repository not found")
+		_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: catalogID}})
+		require.Error(t, err)
+		assert.Equal(t, "repository not found", sources.sources[catalogID].SyncMessage)
+
+		_, err = getTemplate("rhel9")
+		assert.NoError(t, err, "templates are kept when the source cannot be read")
+	})
+}
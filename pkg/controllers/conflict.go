@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"context"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// patchWithRetry applies mutate to obj and patches the changes, guarded by
+// obj's resourceVersion. When another writer updated the object first, obj is
+// read again through c and mutate is reapplied, so losing the
+// race does not surface as a reconcile error and a requeue. mutate reports
+// whether obj needs a patch at all; it is called once per attempt. On success
+// obj holds the patched object.
+func patchWithRetry(ctx context.Context, c client.Client, obj client.Object, mutate func() (bool, error)) error {
+	key := client.ObjectKeyFromObject(obj)
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if !first {
+			if err := c.Get(ctx, key, obj); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		original := obj.DeepCopyObject().(client.Object)
+		changed, err := mutate()
+		if err != nil || !changed {
+			return err
+		}
+		err = c.Patch(ctx, obj, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+		if k8serrors.IsConflict(err) {
+			recordConflictRetry(obj)
+		}
+		return err
+	})
+}
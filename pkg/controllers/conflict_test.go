@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestPatchWithRetry(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	// racingClient lets another writer change the ConfigMap just before each
+	// of the first races patches
+	racingClient := func(races int) (client.Client, *int) {
+		patches := 0
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "dev-ns"}}).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					patches++
+					if patches <= races {
+						var current corev1.ConfigMap
+						require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(obj), &current))
+						if current.Labels == nil {
+							current.Labels = map[string]string{}
+						}
+						current.Labels["other-writer"] = "true"
+						require.NoError(t, c.Update(ctx, &current))
+					}
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}).
+			Build()
+		return c, &patches
+	}
+	setLabel := func(obj client.Object) func() (bool, error) {
+		return func() (bool, error) {
+			labels := obj.GetLabels()
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels["owner"] = "ssvirt"
+			obj.SetLabels(labels)
+			return true, nil
+		}
+	}
+
+	t.Run("conflicts are retried against the refetched object", func(t *testing.T) {
+		c, patches := racingClient(2)
+		var config corev1.ConfigMap
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "dev-ns"}, &config))
+
+		require.NoError(t, patchWithRetry(ctx, c, &config, setLabel(&config)))
+		assert.Equal(t, 3, *patches)
+
+		var current corev1.ConfigMap
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "dev-ns"}, &current))
+		assert.Equal(t, "ssvirt", current.Labels["owner"])
+		assert.Equal(t, "true", current.Labels["other-writer"])
+		assert.Equal(t, current.ResourceVersion, config.ResourceVersion)
+	})
+
+	t.Run("unchanged objects are not patched", func(t *testing.T) {
+		c, patches := racingClient(0)
+		var config corev1.ConfigMap
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "dev-ns"}, &config))
+
+		require.NoError(t, patchWithRetry(ctx, c, &config, func() (bool, error) { return false, nil }))
+		assert.Zero(t, *patches)
+	})
+
+	t.Run("persistent conflicts are returned", func(t *testing.T) {
+		c, _ := racingClient(100)
+		var config corev1.ConfigMap
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "config", Namespace: "dev-ns"}, &config))
+
+		err := patchWithRetry(ctx, c, &config, setLabel(&config))
+		assert.True(t, k8serrors.IsConflict(err), err)
+	})
+}
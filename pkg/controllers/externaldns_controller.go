@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"gorm.io/gorm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+const (
+	// DefaultDNSRecordTTL is the TTL in seconds of the DNS records published for VMs
+	DefaultDNSRecordTTL = 300
+	// DefaultDNSResyncInterval is how often each VM's DNS record is checked
+	// again, so that changes to its VDC's DNS zone are picked up
+	DefaultDNSResyncInterval = 10 * time.Minute
+
+	// dnsEndpointManagedByLabel marks DNSEndpoints created by SSVirt
+	dnsEndpointManagedByLabel = "app.kubernetes.io/managed-by"
+	dnsEndpointManagedByValue = "ssvirt"
+)
+
+// dnsEndpointGVK identifies the ExternalDNS DNSEndpoint resource. ExternalDNS
+// has no importable API package, so DNSEndpoints are handled as unstructured
+// objects.
+var dnsEndpointGVK = schema.GroupVersionKind{Group: "externaldns.k8s.io", Version: "v1alpha1", Kind: "DNSEndpoint"}
+
+// ExternalDNSVMRepositoryInterface defines the VM operations used by the
+// ExternalDNS controller
+type ExternalDNSVMRepositoryInterface interface {
+	GetByNamespaceAndVMName(ctx context.Context, namespace, vmName string) (*models.VM, error)
+	UpdateDNSName(ctx context.Context, vmID, dnsName string) error
+}
+
+// ExternalDNSController publishes a DNS record for each running VM in a VDC
+// with a DNS zone. The record is a DNSEndpoint named after the VM, pointing
+// <vm>.<zone> at the IP address reported by its VirtualMachineInstance, which
+// ExternalDNS then writes to the DNS provider. The DNSEndpoint is owned by the
+// VirtualMachine, so it is garbage collected with it, and is deleted when the
+// VM stops or its VDC's zone is removed.
+type ExternalDNSController struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	VMRepo         ExternalDNSVMRepositoryInterface
+	VDCRepo        VDCRepositoryInterface
+	ResyncInterval time.Duration
+}
+
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachineinstances,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachines,verbs=get
+// +kubebuilder:rbac:groups=externaldns.k8s.io,resources=dnsendpoints,verbs=get;create;update;delete
+
+// Reconcile creates, updates or deletes the DNSEndpoint of the named VM
+func (r *ExternalDNSController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("virtualmachine", req.NamespacedName)
+
+	vdc, err := r.VDCRepo.GetByNamespace(ctx, req.Namespace)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to get VDC: %w", err)
+	}
+	vm, err := r.VMRepo.GetByNamespaceAndVMName(ctx, req.Namespace, req.Name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to get VM: %w", err)
+	}
+
+	ip := ""
+	vmi := &kubevirtv1.VirtualMachineInstance{}
+	if err := r.Get(ctx, req.NamespacedName, vmi); err == nil {
+		ip = vmiIPAddress(vmi)
+	} else if !k8serrors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("failed to get VirtualMachineInstance: %w", err)
+	}
+
+	dnsName := ""
+	if vdc.DNSZone != "" && ip != "" {
+		dnsName = req.Name + "." + vdc.DNSZone
+	}
+
+	if dnsName == "" {
+		err = r.deleteEndpoint(ctx, req.Namespace, req.Name)
+	} else {
+		err = r.applyEndpoint(ctx, req.Namespace, req.Name, dnsName, ip)
+	}
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			// ExternalDNS is not installed; try again after the resync interval
+			logger.Error(err, "DNSEndpoint resource is not available; is ExternalDNS installed?")
+			recordDNSRecord("unavailable")
+			return ctrl.Result{RequeueAfter: r.resyncInterval()}, nil
+		}
+		recordDNSRecord("failure")
+		return ctrl.Result{}, err
+	}
+
+	if vm.DNSName != dnsName {
+		if err := r.VMRepo.UpdateDNSName(ctx, vm.ID, dnsName); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update VM DNS name: %w", err)
+		}
+		if dnsName == "" {
+			logger.Info("Removed VM DNS record", "vm", vm.ID)
+			recordDNSRecord("deleted")
+		} else {
+			logger.Info("Published VM DNS record", "vm", vm.ID, "dnsName", dnsName, "ip", ip)
+			recordDNSRecord("published")
+		}
+	}
+	return ctrl.Result{RequeueAfter: r.resyncInterval()}, nil
+}
+
+// applyEndpoint creates or updates the DNSEndpoint for a VM. It does nothing
+// when the VirtualMachine is gone, since the DNSEndpoint would have no owner.
+func (r *ExternalDNSController) applyEndpoint(ctx context.Context, namespace, name, dnsName, ip string) error {
+	owner := &kubevirtv1.VirtualMachine{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, owner); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get VirtualMachine: %w", err)
+	}
+
+	recordType := "A"
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		recordType = "AAAA"
+	}
+
+	endpoint := &unstructured.Unstructured{}
+	endpoint.SetGroupVersionKind(dnsEndpointGVK)
+	endpoint.SetNamespace(namespace)
+	endpoint.SetName(name)
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, endpoint, func() error {
+		if endpoint.GetResourceVersion() != "" && endpoint.GetLabels()[dnsEndpointManagedByLabel] != dnsEndpointManagedByValue {
+			return fmt.Errorf("DNSEndpoint %s/%s exists and is not managed by SSVirt", namespace, name)
+		}
+		labels := endpoint.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[dnsEndpointManagedByLabel] = dnsEndpointManagedByValue
+		endpoint.SetLabels(labels)
+		if err := controllerutil.SetControllerReference(owner, endpoint, r.Scheme); err != nil {
+			return err
+		}
+		return unstructured.SetNestedSlice(endpoint.Object, []interface{}{
+			map[string]interface{}{
+				"dnsName":    dnsName,
+				"recordType": recordType,
+				"recordTTL":  int64(DefaultDNSRecordTTL),
+				"targets":    []interface{}{ip},
+			},
+		}, "spec", "endpoints")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply DNSEndpoint: %w", err)
+	}
+	return nil
+}
+
+// deleteEndpoint deletes the DNSEndpoint for a VM if SSVirt created it
+func (r *ExternalDNSController) deleteEndpoint(ctx context.Context, namespace, name string) error {
+	endpoint := &unstructured.Unstructured{}
+	endpoint.SetGroupVersionKind(dnsEndpointGVK)
+	if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, endpoint); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get DNSEndpoint: %w", err)
+	}
+	if endpoint.GetLabels()[dnsEndpointManagedByLabel] != dnsEndpointManagedByValue {
+		return nil
+	}
+	if err := r.Delete(ctx, endpoint); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete DNSEndpoint: %w", err)
+	}
+	return nil
+}
+
+func (r *ExternalDNSController) resyncInterval() time.Duration {
+	if r.ResyncInterval > 0 {
+		return r.ResyncInterval
+	}
+	return DefaultDNSResyncInterval
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *ExternalDNSController) SetupWithManager(mgr ctrl.Manager, opts ControllerOptions) error {
+	// VirtualMachineInstances carry the IP address and come and go with power state
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named(ExternalDNSControllerName).
+		WithOptions(opts.controllerOptions(ExternalDNSControllerName)).
+		For(&kubevirtv1.VirtualMachineInstance{}).
+		Complete(opts.wrap(r))
+	if err != nil {
+		return fmt.Errorf("failed to setup ExternalDNSController: %w", err)
+	}
+	return nil
+}
+
+// SetupExternalDNSController sets up the ExternalDNS controller with the manager
+func SetupExternalDNSController(mgr ctrl.Manager, vmRepo ExternalDNSVMRepositoryInterface, vdcRepo VDCRepositoryInterface, opts ControllerOptions) error {
+	return (&ExternalDNSController{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		VMRepo:  vmRepo,
+		VDCRepo: vdcRepo,
+	}).SetupWithManager(mgr, opts)
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// fakeDNSVMRepo keeps VMs in memory, keyed by VM name
+type fakeDNSVMRepo struct {
+	vms map[string]*models.VM
+}
+
+func (f *fakeDNSVMRepo) GetByNamespaceAndVMName(_ context.Context, _, vmName string) (*models.VM, error) {
+	vm, ok := f.vms[vmName]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *vm
+	return &copied, nil
+}
+
+func (f *fakeDNSVMRepo) UpdateDNSName(_ context.Context, vmID, dnsName string) error {
+	for _, vm := range f.vms {
+		if vm.ID == vmID {
+			vm.DNSName = dnsName
+		}
+	}
+	return nil
+}
+
+func TestExternalDNSController(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(dnsEndpointGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(dnsEndpointGVK.GroupVersion().WithKind("DNSEndpointList"), &unstructured.UnstructuredList{})
+
+	newVM := func(name string) *kubevirtv1.VirtualMachine {
+		return &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "dev-ns", UID: types.UID(name + "-uid")}}
+	}
+	newVMI := func(name, ip string) *kubevirtv1.VirtualMachineInstance {
+		return &kubevirtv1.VirtualMachineInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "dev-ns"},
+			Status: kubevirtv1.VirtualMachineInstanceStatus{
+				Interfaces: []kubevirtv1.VirtualMachineInstanceNetworkInterface{{IP: ip}},
+			},
+		}
+	}
+	foreign := &unstructured.Unstructured{}
+	foreign.SetGroupVersionKind(dnsEndpointGVK)
+	foreign.SetNamespace("dev-ns")
+	foreign.SetName("foreign")
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			newVM("web"), newVMI("web", "10.0.0.5"),
+			newVM("db"), newVMI("db", "fd00::5"),
+			newVM("stopped"),
+			newVM("foreign"), newVMI("foreign", "10.0.0.7"), foreign,
+		).Build()
+
+	vdc := &models.VDC{ID: "vdc-1", Namespace: "dev-ns", DNSZone: "dev.example.com"}
+	vdcRepo := &MockVDCRepository{}
+	vdcRepo.On("GetByNamespace", context.Background(), "dev-ns").Return(vdc, nil)
+	vmRepo := &fakeDNSVMRepo{vms: map[string]*models.VM{
+		"web":     {ID: "vm-web", VMName: "web"},
+		"db":      {ID: "vm-db", VMName: "db"},
+		"stopped": {ID: "vm-stopped", VMName: "stopped", DNSName: "stopped.dev.example.com"},
+		"foreign": {ID: "vm-foreign", VMName: "foreign"},
+	}}
+	controller := &ExternalDNSController{Client: fakeClient, Scheme: scheme, VMRepo: vmRepo, VDCRepo: vdcRepo}
+
+	reconcile := func(name string) error {
+		_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "dev-ns", Name: name}})
+		return err
+	}
+	getEndpoint := func(name string) (*unstructured.Unstructured, error) {
+		endpoint := &unstructured.Unstructured{}
+		endpoint.SetGroupVersionKind(dnsEndpointGVK)
+		err := fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "dev-ns", Name: name}, endpoint)
+		return endpoint, err
+	}
+	endpointRecord := func(endpoint *unstructured.Unstructured) map[string]interface{} {
+		endpoints, _, _ := unstructured.NestedSlice(endpoint.Object, "spec", "endpoints")
+		require.Len(t, endpoints, 1)
+		return endpoints[0].(map[string]interface{})
+	}
+
+	t.Run("running VMs get records", func(t *testing.T) {
+		require.NoError(t, reconcile("web"))
+		require.NoError(t, reconcile("db"))
+
+		endpoint, err := getEndpoint("web")
+		require.NoError(t, err)
+		assert.Equal(t, dnsEndpointManagedByValue, endpoint.GetLabels()[dnsEndpointManagedByLabel])
+		require.Len(t, endpoint.GetOwnerReferences(), 1)
+		assert.Equal(t, "web", endpoint.GetOwnerReferences()[0].Name)
+		record := endpointRecord(endpoint)
+		assert.Equal(t, "web.dev.example.com", record["dnsName"])
+		assert.Equal(t, "A", record["recordType"])
+		assert.Equal(t, []interface{}{"10.0.0.5"}, record["targets"])
+		assert.Equal(t, "web.dev.example.com", vmRepo.vms["web"].DNSName)
+
+		endpoint, err = getEndpoint("db")
+		require.NoError(t, err)
+		assert.Equal(t, "AAAA", endpointRecord(endpoint)["recordType"])
+	})
+
+	t.Run("stopped VMs lose their record", func(t *testing.T) {
+		require.NoError(t, reconcile("stopped"))
+		assert.Empty(t, vmRepo.vms["stopped"].DNSName)
+
+		require.NoError(t, fakeClient.Delete(context.Background(), newVMI("web", "")))
+		require.NoError(t, reconcile("web"))
+		_, err := getEndpoint("web")
+		assert.True(t, k8serrors.IsNotFound(err))
+		assert.Empty(t, vmRepo.vms["web"].DNSName)
+	})
+
+	t.Run("DNSEndpoints not created by ssvirt are left alone", func(t *testing.T) {
+		assert.Error(t, reconcile("foreign"))
+		endpoint, err := getEndpoint("foreign")
+		require.NoError(t, err)
+		_, found, _ := unstructured.NestedSlice(endpoint.Object, "spec", "endpoints")
+		assert.False(t, found)
+		assert.Empty(t, vmRepo.vms["foreign"].DNSName)
+	})
+
+	t.Run("removing the zone removes records", func(t *testing.T) {
+		vdc.DNSZone = ""
+		require.NoError(t, reconcile("db"))
+		_, err := getEndpoint("db")
+		assert.True(t, k8serrors.IsNotFound(err))
+		assert.Empty(t, vmRepo.vms["db"].DNSName)
+	})
+}
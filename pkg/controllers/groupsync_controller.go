@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// DefaultGroupSyncInterval is how often group mappings are applied
+const DefaultGroupSyncInterval = 10 * time.Minute
+
+// GroupSyncController applies the group mappings periodically, so organization
+// membership and roles follow the OpenShift Groups that OpenShift populates
+// from the identity provider. Groups are read on each sync rather than
+// watched, since membership changes are not urgent and Groups are few.
+type GroupSyncController struct {
+	Sync     services.GroupSyncService
+	Interval time.Duration
+
+	// reconciler runs one sync; it wraps the controller for health tracking
+	reconciler reconcile.Reconciler
+}
+
+// +kubebuilder:rbac:groups=user.openshift.io,resources=groups,verbs=list
+
+// Start syncs every interval until the context is cancelled. It implements
+// manager.Runnable and runs only on the leader.
+func (r *GroupSyncController) Start(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultGroupSyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reconciler := r.reconciler
+	if reconciler == nil {
+		reconciler = r
+	}
+	for {
+		// Failures are logged and retried at the next interval
+		_, _ = reconciler.Reconcile(ctx, ctrl.Request{})
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile applies the group mappings to every user
+func (r *GroupSyncController) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithName("group-sync")
+
+	report, err := r.Sync.Sync(ctx)
+	if err != nil {
+		recordGroupSync("error")
+		logger.Error(err, "Group sync failed")
+		return ctrl.Result{}, err
+	}
+	for _, change := range report.Changes {
+		logger.Info("Synced user from Groups", "user", change.Username, "action", change.Action,
+			"organization", change.ToOrganization, "roles", change.ToRoles)
+	}
+	for _, warning := range report.Warnings {
+		logger.Info("Group mapping skipped", "reason", warning)
+	}
+	for _, conflict := range report.Conflicts {
+		logger.Info("User's Groups map to several organizations; left unchanged",
+			"user", conflict.Username, "organizations", conflict.Organizations)
+	}
+	if len(report.Errors) > 0 {
+		recordGroupSync("error")
+		err := fmt.Errorf("group sync failed for %d users: %w", len(report.Errors), errors.New(report.Errors[0]))
+		logger.Error(err, "Group sync incomplete")
+		return ctrl.Result{}, err
+	}
+	recordGroupSync("success")
+	return ctrl.Result{}, nil
+}
+
+// SetupGroupSyncController adds the group sync controller to the manager
+func SetupGroupSyncController(mgr ctrl.Manager, sync services.GroupSyncService, interval time.Duration, opts ControllerOptions) error {
+	controller := &GroupSyncController{
+		Sync:     sync,
+		Interval: interval,
+	}
+	controller.reconciler = opts.wrap(controller)
+	if err := mgr.Add(controller); err != nil {
+		return fmt.Errorf("failed to setup GroupSyncController: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// stubGroupSync returns a fixed report or error from Sync
+type stubGroupSync struct {
+	report *services.GroupSyncReport
+	err    error
+	syncs  int
+}
+
+func (s *stubGroupSync) Plan(ctx context.Context) (*services.GroupSyncReport, error) {
+	return s.report, s.err
+}
+
+func (s *stubGroupSync) Sync(ctx context.Context) (*services.GroupSyncReport, error) {
+	s.syncs++
+	return s.report, s.err
+}
+
+func TestGroupSyncController(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Records successful syncs", func(t *testing.T) {
+		sync := &stubGroupSync{report: &services.GroupSyncReport{
+			Changes: []services.GroupSyncChange{{Username: "alice", Action: services.GroupSyncAdd}},
+		}}
+		controller := &GroupSyncController{Sync: sync}
+		before := testutil.ToFloat64(groupSyncsTotal.WithLabelValues("success"))
+
+		_, err := controller.Reconcile(ctx, ctrl.Request{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sync.syncs)
+		assert.Equal(t, before+1, testutil.ToFloat64(groupSyncsTotal.WithLabelValues("success")))
+	})
+
+	t.Run("Fails when users could not be updated", func(t *testing.T) {
+		controller := &GroupSyncController{Sync: &stubGroupSync{report: &services.GroupSyncReport{
+			Errors: []string{"failed to add user alice: database is locked"},
+		}}}
+		before := testutil.ToFloat64(groupSyncsTotal.WithLabelValues("error"))
+
+		_, err := controller.Reconcile(ctx, ctrl.Request{})
+		assert.ErrorContains(t, err, "database is locked")
+		assert.Equal(t, before+1, testutil.ToFloat64(groupSyncsTotal.WithLabelValues("error")))
+	})
+
+	t.Run("Fails when Groups cannot be read", func(t *testing.T) {
+		controller := &GroupSyncController{Sync: &stubGroupSync{err: services.ErrGroupsUnavailable}}
+
+		_, err := controller.Reconcile(ctx, ctrl.Request{})
+		assert.True(t, errors.Is(err, services.ErrGroupsUnavailable))
+	})
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DefaultReconcileStallTimeout is how long a single reconcile may run before the
+// controller is reported as not ready
+const DefaultReconcileStallTimeout = 5 * time.Minute
+
+// Controller names. These label the controller-runtime workqueue_* and
+// controller_runtime_* metrics as well as the ssvirt_controller_* metrics.
+const (
+	VMStatusControllerName           = "ssvirt_vmstatus"
+	VAppStatusControllerName         = "ssvirt_vappstatus"
+	TemplateValidationControllerName = "ssvirt_templatevalidation"
+	StorageUsageControllerName       = "ssvirt_storageusage"
+	AutoSuspendControllerName        = "ssvirt_autosuspend"
+	CatalogSyncControllerName        = "ssvirt_catalogsync"
+	PowerStateControllerName         = "ssvirt_powerstate"
+	JanitorControllerName            = "ssvirt_janitor"
+	GroupSyncControllerName          = "ssvirt_groupsync"
+	ExternalDNSControllerName        = "ssvirt_externaldns"
+	VDCConditionsControllerName      = "ssvirt_vdcconditions"
+	PrewarmControllerName            = "ssvirt_prewarm"
+)
+
+var (
+	// Gauge for leadership of each controller
+	controllerLeaderGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ssvirt_controller_leader",
+			Help: "Whether this replica currently holds the leader lease for the controller (1) or not (0)",
+		},
+		[]string{"controller"},
+	)
+
+	// Histogram for reconcile duration by controller and outcome
+	controllerReconcileDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ssvirt_controller_reconcile_duration_seconds",
+			Help:    "Time taken by each reconcile, by controller and result",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"controller", "result"},
+	)
+
+	// Gauge for configured reconcile concurrency
+	controllerMaxConcurrentReconciles = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ssvirt_controller_max_concurrent_reconciles",
+			Help: "Configured number of concurrent reconcile workers for the controller",
+		},
+		[]string{"controller"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		controllerLeaderGauge,
+		controllerReconcileDuration,
+		controllerMaxConcurrentReconciles,
+	)
+}
+
+// ControllerOptions configures how a controller is registered with the manager
+type ControllerOptions struct {
+	// MaxConcurrentReconciles is the number of reconcile workers (defaults to 1)
+	MaxConcurrentReconciles int
+	// RateLimiter, when set, controls how failed reconciles are retried
+	RateLimiter workqueue.TypedRateLimiter[reconcile.Request]
+	// Health, when set, tracks reconciles so readiness reflects stalled work
+	Health *ReconcileHealth
+	// StatusBuffer, when set, holds VM status updates while the database is unavailable
+	StatusBuffer *StatusBuffer
+	// LabelSweepInterval is how often the VM status controller labels VMs
+	// missed by watch events (defaults to DefaultLabelSweepInterval)
+	LabelSweepInterval time.Duration
+}
+
+// wrap applies the options to a reconciler
+func (o ControllerOptions) wrap(r reconcile.Reconciler) reconcile.Reconciler {
+	if o.Health == nil {
+		return r
+	}
+	return o.Health.Wrap(r)
+}
+
+// controllerOptions converts the options to controller-runtime options for the named controller
+func (o ControllerOptions) controllerOptions(name string) controller.Options {
+	workers := o.MaxConcurrentReconciles
+	if workers <= 0 {
+		workers = 1
+	}
+	controllerMaxConcurrentReconciles.WithLabelValues(name).Set(float64(workers))
+	return controller.Options{
+		MaxConcurrentReconciles: workers,
+		RateLimiter:             o.RateLimiter,
+	}
+}
+
+// ReconcileHealth tracks in-flight reconciles for a controller so that readiness
+// probes can report a controller whose workers are stuck
+type ReconcileHealth struct {
+	name         string
+	stallTimeout time.Duration
+	now          func() time.Time
+
+	mu            sync.Mutex
+	nextID        uint64
+	inFlight      map[uint64]time.Time
+	lastReconcile time.Time
+	lastSuccess   time.Time
+}
+
+// NewReconcileHealth creates a tracker for the named controller
+func NewReconcileHealth(name string, stallTimeout time.Duration) *ReconcileHealth {
+	if stallTimeout <= 0 {
+		stallTimeout = DefaultReconcileStallTimeout
+	}
+	return &ReconcileHealth{
+		name:         name,
+		stallTimeout: stallTimeout,
+		now:          time.Now,
+		inFlight:     make(map[uint64]time.Time),
+	}
+}
+
+// Name returns the controller name
+func (h *ReconcileHealth) Name() string {
+	return h.name
+}
+
+// Wrap returns a reconciler that records each call to r
+func (h *ReconcileHealth) Wrap(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		id := h.start()
+		started := time.Now()
+		result, err := r.Reconcile(ctx, req)
+		h.finish(id, err == nil)
+
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+		controllerReconcileDuration.WithLabelValues(h.name, outcome).Observe(time.Since(started).Seconds())
+		return result, err
+	})
+}
+
+func (h *ReconcileHealth) start() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nextID++
+	h.inFlight[h.nextID] = h.now()
+	return h.nextID
+}
+
+func (h *ReconcileHealth) finish(id uint64, succeeded bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.inFlight, id)
+	h.lastReconcile = h.now()
+	if succeeded {
+		h.lastSuccess = h.lastReconcile
+	}
+}
+
+// LastReconciles returns when the controller last finished a reconcile and
+// when one last succeeded. Either is zero until it has happened.
+func (h *ReconcileHealth) LastReconciles() (lastReconcile, lastSuccess time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastReconcile, h.lastSuccess
+}
+
+// Check returns an error if any reconcile has been running longer than the stall timeout
+func (h *ReconcileHealth) Check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	for _, started := range h.inFlight {
+		if running := now.Sub(started); running > h.stallTimeout {
+			return fmt.Errorf("controller %s has a reconcile running for %s (limit %s)", h.name, running.Round(time.Second), h.stallTimeout)
+		}
+	}
+	return nil
+}
+
+// LeaderAwareReadyzCheck returns a readiness checker for a set of controllers sharing
+// one leader lease. Standby replicas are ready so that rollouts can proceed; once
+// elected, the replica is ready only while none of its controllers are stalled.
+func LeaderAwareReadyzCheck(elected <-chan struct{}, trackers ...*ReconcileHealth) healthz.Checker {
+	return func(_ *http.Request) error {
+		select {
+		case <-elected:
+		default:
+			for _, t := range trackers {
+				controllerLeaderGauge.WithLabelValues(t.Name()).Set(0)
+			}
+			return nil
+		}
+
+		for _, t := range trackers {
+			controllerLeaderGauge.WithLabelValues(t.Name()).Set(1)
+		}
+		for _, t := range trackers {
+			if err := t.Check(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileHealth(t *testing.T) {
+	health := NewReconcileHealth("vmstatus", time.Minute)
+	now := time.Now()
+	health.now = func() time.Time { return now }
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	wrapped := health.Wrap(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		close(started)
+		<-release
+		return reconcile.Result{}, errors.New("boom")
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = wrapped.Reconcile(context.Background(), reconcile.Request{})
+		close(done)
+	}()
+	<-started
+
+	// A reconcile within the stall timeout is healthy
+	assert.NoError(t, health.Check())
+
+	// A reconcile running past the stall timeout is reported
+	now = now.Add(2 * time.Minute)
+	assert.Error(t, health.Check())
+
+	close(release)
+	<-done
+	assert.NoError(t, health.Check())
+}
+
+func TestLeaderAwareReadyzCheck(t *testing.T) {
+	health := NewReconcileHealth("vappstatus", time.Minute)
+	now := time.Now()
+	health.now = func() time.Time { return now }
+	health.start()
+	now = now.Add(2 * time.Minute)
+
+	elected := make(chan struct{})
+	check := LeaderAwareReadyzCheck(elected, health)
+
+	// Standby replicas are ready regardless of local reconcile state
+	require.NoError(t, check(nil))
+
+	close(elected)
+	assert.Error(t, check(nil))
+}
+
+func TestControllerOptionsConcurrency(t *testing.T) {
+	// Unset concurrency falls back to a single worker
+	opts := ControllerOptions{}.controllerOptions(VMStatusControllerName)
+	assert.Equal(t, 1, opts.MaxConcurrentReconciles)
+	assert.Equal(t, float64(1), testutil.ToFloat64(controllerMaxConcurrentReconciles.WithLabelValues(VMStatusControllerName)))
+
+	opts = ControllerOptions{MaxConcurrentReconciles: 4}.controllerOptions(VAppStatusControllerName)
+	assert.Equal(t, 4, opts.MaxConcurrentReconciles)
+	assert.Equal(t, float64(4), testutil.ToFloat64(controllerMaxConcurrentReconciles.WithLabelValues(VAppStatusControllerName)))
+}
+
+func TestReconcileHealthRecordsDuration(t *testing.T) {
+	health := NewReconcileHealth("duration_test", time.Minute)
+	wrapped := health.Wrap(reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		return reconcile.Result{}, errors.New("boom")
+	}))
+
+	_, _ = wrapped.Reconcile(context.Background(), reconcile.Request{})
+	_, _ = wrapped.Reconcile(context.Background(), reconcile.Request{})
+
+	metric := &dto.Metric{}
+	require.NoError(t, controllerReconcileDuration.WithLabelValues("duration_test", "error").(prometheus.Metric).Write(metric))
+	assert.Equal(t, uint64(2), metric.GetHistogram().GetSampleCount())
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// Default heartbeat settings
+const (
+	DefaultHeartbeatInterval = 30 * time.Second
+	// DefaultHeartbeatRetention is how long the heartbeat of an instance that
+	// stopped reporting, such as a pod replaced by a rollout, is kept
+	DefaultHeartbeatRetention = 24 * time.Hour
+)
+
+// HeartbeatRepositoryInterface defines the heartbeat repository operations
+// needed by the Heartbeat
+type HeartbeatRepositoryInterface interface {
+	Upsert(ctx context.Context, heartbeat *models.ComponentHeartbeat) error
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// HeartbeatOptions configures a Heartbeat
+type HeartbeatOptions struct {
+	// InstanceID identifies this process, normally its pod name
+	InstanceID string
+	// Component is the kind of process, such as vm-controller
+	Component string
+	Version   string
+	// Interval is how often the heartbeat is written
+	Interval time.Duration
+	// Retention is how long heartbeats of instances that stopped reporting are kept
+	Retention time.Duration
+	// Elected is closed once this process holds the leader lease
+	Elected <-chan struct{}
+	// Trackers report the reconcile activity of the controllers
+	Trackers []*ReconcileHealth
+}
+
+// Heartbeat periodically records in the database that this process is alive,
+// which build it runs, whether it is leader and when its controllers last
+// reconciled, so the API server can show operators the state of each component
+type Heartbeat struct {
+	repo      HeartbeatRepositoryInterface
+	opts      HeartbeatOptions
+	startedAt time.Time
+	now       func() time.Time
+}
+
+// NewHeartbeat creates a Heartbeat that writes through repo
+func NewHeartbeat(repo HeartbeatRepositoryInterface, opts HeartbeatOptions) *Heartbeat {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultHeartbeatInterval
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultHeartbeatRetention
+	}
+	return &Heartbeat{
+		repo:      repo,
+		opts:      opts,
+		startedAt: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Start writes a heartbeat every interval until the context is cancelled. It
+// implements manager.Runnable.
+func (h *Heartbeat) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("heartbeat")
+	ticker := time.NewTicker(h.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Failures are logged and retried at the next interval; the table is
+		// created by the API server and may not exist yet
+		if err := h.Beat(ctx); err != nil {
+			logger.V(1).Info("Unable to record heartbeat", "error", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection reports that every replica heartbeats, so standby
+// replicas are visible to operators too
+func (h *Heartbeat) NeedLeaderElection() bool {
+	return false
+}
+
+// Beat writes one heartbeat and removes those of instances that stopped reporting
+func (h *Heartbeat) Beat(ctx context.Context) error {
+	now := h.now()
+	heartbeat := &models.ComponentHeartbeat{
+		InstanceID:      h.opts.InstanceID,
+		Component:       h.opts.Component,
+		Version:         h.opts.Version,
+		Leader:          h.leader(),
+		IntervalSeconds: int(h.opts.Interval / time.Second),
+		StartedAt:       h.startedAt,
+		LastHeartbeat:   now,
+	}
+	controllers := make([]models.ControllerHeartbeat, 0, len(h.opts.Trackers))
+	for _, tracker := range h.opts.Trackers {
+		controller := models.ControllerHeartbeat{Name: tracker.Name()}
+		lastReconcile, lastSuccess := tracker.LastReconciles()
+		if !lastReconcile.IsZero() {
+			controller.LastReconcile = &lastReconcile
+		}
+		if !lastSuccess.IsZero() {
+			controller.LastSuccess = &lastSuccess
+		}
+		controllers = append(controllers, controller)
+	}
+	heartbeat.SetControllers(controllers)
+
+	if err := h.repo.Upsert(ctx, heartbeat); err != nil {
+		return err
+	}
+	_, err := h.repo.DeleteBefore(ctx, now.Add(-h.opts.Retention))
+	return err
+}
+
+// leader reports whether this process holds the leader lease
+func (h *Heartbeat) leader() bool {
+	if h.opts.Elected == nil {
+		return false
+	}
+	select {
+	case <-h.opts.Elected:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// recordingHeartbeats keeps the heartbeats written to it
+type recordingHeartbeats struct {
+	heartbeats []models.ComponentHeartbeat
+	prunedTo   time.Time
+	err        error
+}
+
+func (r *recordingHeartbeats) Upsert(ctx context.Context, heartbeat *models.ComponentHeartbeat) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.heartbeats = append(r.heartbeats, *heartbeat)
+	return nil
+}
+
+func (r *recordingHeartbeats) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.prunedTo = before
+	return 0, nil
+}
+
+func TestHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	health := NewReconcileHealth(VMStatusControllerName, time.Minute)
+	health.now = func() time.Time { return now }
+	idle := NewReconcileHealth(VAppStatusControllerName, time.Minute)
+
+	elected := make(chan struct{})
+	repo := &recordingHeartbeats{}
+	heartbeat := NewHeartbeat(repo, HeartbeatOptions{
+		InstanceID: "vm-controller-abc",
+		Component:  "vm-controller",
+		Version:    "v1.2.3",
+		Elected:    elected,
+		Trackers:   []*ReconcileHealth{health, idle},
+	})
+	heartbeat.now = func() time.Time { return now }
+
+	t.Run("Standby replicas report no leadership or reconciles", func(t *testing.T) {
+		require.NoError(t, heartbeat.Beat(ctx))
+		require.Len(t, repo.heartbeats, 1)
+		beat := repo.heartbeats[0]
+		assert.Equal(t, "vm-controller-abc", beat.InstanceID)
+		assert.Equal(t, "vm-controller", beat.Component)
+		assert.Equal(t, "v1.2.3", beat.Version)
+		assert.False(t, beat.Leader)
+		assert.Equal(t, int(DefaultHeartbeatInterval/time.Second), beat.IntervalSeconds)
+		assert.Equal(t, now, beat.LastHeartbeat)
+		require.Len(t, beat.Controllers, 2)
+		assert.Nil(t, beat.Controllers[0].LastReconcile)
+		assert.Equal(t, now.Add(-DefaultHeartbeatRetention), repo.prunedTo)
+	})
+
+	t.Run("Leaders report their last reconciles", func(t *testing.T) {
+		close(elected)
+		health.finish(health.start(), true)
+		now = now.Add(time.Minute)
+		health.finish(health.start(), false)
+
+		require.NoError(t, heartbeat.Beat(ctx))
+		beat := repo.heartbeats[len(repo.heartbeats)-1]
+		assert.True(t, beat.Leader)
+
+		// The controllers are stored encoded, as read back by the API server
+		stored := &models.ComponentHeartbeat{ControllersData: beat.ControllersData}
+		require.NoError(t, stored.AfterFind(nil))
+		controllers := stored.Controllers
+		require.Len(t, controllers, 2)
+		assert.Equal(t, VMStatusControllerName, controllers[0].Name)
+		require.NotNil(t, controllers[0].LastReconcile)
+		require.NotNil(t, controllers[0].LastSuccess)
+		assert.Equal(t, now, *controllers[0].LastReconcile)
+		assert.Equal(t, now.Add(-time.Minute), *controllers[0].LastSuccess)
+		assert.Equal(t, VAppStatusControllerName, controllers[1].Name)
+		assert.Nil(t, controllers[1].LastSuccess)
+	})
+
+	t.Run("Reports database failures", func(t *testing.T) {
+		repo.err = errors.New("no such table: component_heartbeats")
+		assert.ErrorContains(t, heartbeat.Beat(ctx), "no such table")
+	})
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DefaultJanitorInterval is how often the janitor sweeps VDC namespaces
+	DefaultJanitorInterval = 10 * time.Minute
+	// DefaultJanitorSecretGracePeriod is how long a template instance Secret
+	// may exist without its TemplateInstance. Secrets are created before their
+	// TemplateInstance, so younger Secrets may belong to an instantiation in
+	// progress.
+	DefaultJanitorSecretGracePeriod = 15 * time.Minute
+	// DefaultJanitorFailedInstanceRetention is how long a failed
+	// TemplateInstance is kept for troubleshooting
+	DefaultJanitorFailedInstanceRetention = 7 * 24 * time.Hour
+)
+
+// templateInstanceLabel names the TemplateInstance a parameter or SSH key
+// Secret was created for
+const templateInstanceLabel = "ssvirt.io/template-instance"
+
+// JanitorOptions configures the janitor controller
+type JanitorOptions struct {
+	// Interval is how often VDC namespaces are swept
+	Interval time.Duration
+	// SecretGracePeriod is how old an orphaned Secret must be before it is deleted
+	SecretGracePeriod time.Duration
+	// FailedInstanceRetention is how long failed TemplateInstances are kept
+	FailedInstanceRetention time.Duration
+}
+
+// JanitorController deletes what vApp instantiation leaves behind in VDC
+// namespaces: parameter and SSH key Secrets whose TemplateInstance no longer
+// exists, which leak when their owner reference could not be set, and failed
+// TemplateInstances older than the retention window. Only resources labelled
+// as managed by ssvirt are touched.
+type JanitorController struct {
+	client.Client
+	// Reader lists resources directly from the API server so Secrets are not
+	// cached cluster-wide
+	Reader  client.Reader
+	Options JanitorOptions
+
+	// reconciler sweeps one namespace; it wraps the controller for health tracking
+	reconciler reconcile.Reconciler
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=list;delete
+// +kubebuilder:rbac:groups=template.openshift.io,resources=templateinstances,verbs=list;delete
+
+// Start sweeps VDC namespaces until the context is cancelled. It implements
+// manager.Runnable and runs only on the leader.
+func (r *JanitorController) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("janitor")
+	interval := r.Options.Interval
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.sweep(ctx); err != nil {
+			logger.Error(err, "Failed to list VDC namespaces")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep cleans up every VDC namespace, one at a time
+func (r *JanitorController) sweep(ctx context.Context) error {
+	var namespaces corev1.NamespaceList
+	if err := r.Reader.List(ctx, &namespaces, client.HasLabels{vdcNamespaceLabel}); err != nil {
+		return err
+	}
+	reconciler := r.reconciler
+	if reconciler == nil {
+		reconciler = r
+	}
+	for _, namespace := range namespaces.Items {
+		if ctx.Err() != nil {
+			return nil
+		}
+		// Failures are logged and retried at the next sweep
+		_, _ = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+	}
+	return nil
+}
+
+// Reconcile deletes orphaned Secrets and expired failed TemplateInstances in
+// the namespace named by the request
+func (r *JanitorController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Name)
+	now := r.clock()
+
+	var instances templatev1.TemplateInstanceList
+	if err := r.Reader.List(ctx, &instances, client.InNamespace(req.Name), client.MatchingLabels{managedByLabel: managedByValue}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list TemplateInstances: %w", err)
+	}
+	var secrets corev1.SecretList
+	if err := r.Reader.List(ctx, &secrets, client.InNamespace(req.Name), client.HasLabels{templateInstanceLabel}, client.MatchingLabels{managedByLabel: managedByValue}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list template instance Secrets: %w", err)
+	}
+
+	var errs []error
+	remaining := make(map[string]bool, len(instances.Items))
+	retention := r.Options.FailedInstanceRetention
+	if retention <= 0 {
+		retention = DefaultJanitorFailedInstanceRetention
+	}
+	for i := range instances.Items {
+		instance := &instances.Items[i]
+		failedAt, failed := templateInstanceFailedAt(instance)
+		if !failed || now.Sub(failedAt) < retention {
+			remaining[instance.Name] = true
+			continue
+		}
+		if err := r.Delete(ctx, instance); err != nil && !k8serrors.IsNotFound(err) {
+			recordJanitorCleanup("templateinstance", "error")
+			remaining[instance.Name] = true
+			errs = append(errs, fmt.Errorf("failed to delete TemplateInstance %s: %w", instance.Name, err))
+			continue
+		}
+		recordJanitorCleanup("templateinstance", "deleted")
+		logger.Info("Deleted failed TemplateInstance", "templateInstance", instance.Name, "failedAt", failedAt)
+	}
+
+	grace := r.Options.SecretGracePeriod
+	if grace <= 0 {
+		grace = DefaultJanitorSecretGracePeriod
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if remaining[secret.Labels[templateInstanceLabel]] || now.Sub(secret.CreationTimestamp.Time) < grace {
+			continue
+		}
+		if err := r.Delete(ctx, secret); err != nil && !k8serrors.IsNotFound(err) {
+			recordJanitorCleanup("secret", "error")
+			errs = append(errs, fmt.Errorf("failed to delete Secret %s: %w", secret.Name, err))
+			continue
+		}
+		recordJanitorCleanup("secret", "deleted")
+		logger.Info("Deleted orphaned template instance Secret", "secret", secret.Name, "templateInstance", secret.Labels[templateInstanceLabel])
+	}
+
+	if len(errs) > 0 {
+		err := fmt.Errorf("janitor failed for %d resources: %w", len(errs), errs[0])
+		logger.Error(err, "Cleanup incomplete")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// templateInstanceFailedAt reports whether a TemplateInstance failed to
+// instantiate and when
+func templateInstanceFailedAt(instance *templatev1.TemplateInstance) (time.Time, bool) {
+	for _, condition := range instance.Status.Conditions {
+		if condition.Type == templatev1.TemplateInstanceInstantiateFailure && condition.Status == corev1.ConditionTrue {
+			if condition.LastTransitionTime.IsZero() {
+				return instance.CreationTimestamp.Time, true
+			}
+			return condition.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r *JanitorController) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// SetupJanitorController adds the janitor controller to the manager
+func SetupJanitorController(mgr ctrl.Manager, janitor JanitorOptions, opts ControllerOptions) error {
+	controller := &JanitorController{
+		Client:  mgr.GetClient(),
+		Reader:  mgr.GetAPIReader(),
+		Options: janitor,
+	}
+	controller.reconciler = opts.wrap(controller)
+	if err := mgr.Add(controller); err != nil {
+		return fmt.Errorf("failed to setup JanitorController: %w", err)
+	}
+	return nil
+}
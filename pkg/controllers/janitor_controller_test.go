@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestJanitorController(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, templatev1.AddToScheme(scheme))
+
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	managed := map[string]string{managedByLabel: managedByValue}
+	secret := func(name, namespace, instance string, age time.Duration, labels map[string]string) *corev1.Secret {
+		s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			Labels:            map[string]string{templateInstanceLabel: instance},
+		}}
+		for key, value := range labels {
+			s.Labels[key] = value
+		}
+		return s
+	}
+	instance := func(name string, failedFor time.Duration) *templatev1.TemplateInstance {
+		ti := &templatev1.TemplateInstance{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "vdc-ns", Labels: managed}}
+		if failedFor > 0 {
+			ti.Status.Conditions = []templatev1.TemplateInstanceCondition{{
+				Type:               templatev1.TemplateInstanceInstantiateFailure,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(now.Add(-failedFor)),
+			}}
+		}
+		return ti
+	}
+
+	failSecretDeletes := false
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "vdc-ns", Labels: map[string]string{vdcNamespaceLabel: "vdc-1"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other-ns"}},
+			instance("running", 0),
+			instance("failed-recently", time.Hour),
+			instance("failed-long-ago", 8*24*time.Hour),
+			secret("running-params", "vdc-ns", "running", time.Hour, managed),
+			secret("failed-recently-params", "vdc-ns", "failed-recently", time.Hour, managed),
+			secret("failed-long-ago-params", "vdc-ns", "failed-long-ago", 8*24*time.Hour, managed),
+			secret("orphan-params", "vdc-ns", "orphan", time.Hour, managed),
+			secret("orphan-ssh-keys", "vdc-ns", "orphan", time.Hour, managed),
+			secret("creating-params", "vdc-ns", "creating", time.Minute, managed),
+			secret("foreign-params", "vdc-ns", "foreign", time.Hour, nil),
+			secret("elsewhere-params", "other-ns", "elsewhere", time.Hour, managed),
+		).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+				if _, ok := obj.(*corev1.Secret); ok && failSecretDeletes {
+					return errors.New("etcd unavailable")
+				}
+				return c.Delete(ctx, obj, opts...)
+			},
+		}).
+		Build()
+
+	controller := &JanitorController{
+		Client:  fakeClient,
+		Reader:  fakeClient,
+		Options: JanitorOptions{SecretGracePeriod: 15 * time.Minute, FailedInstanceRetention: 7 * 24 * time.Hour},
+		now:     func() time.Time { return now },
+	}
+	ctx := context.Background()
+	exists := func(obj client.Object, namespace, name string) bool {
+		err := fakeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj)
+		if k8serrors.IsNotFound(err) {
+			return false
+		}
+		require.NoError(t, err)
+		return true
+	}
+
+	t.Run("Deletes orphaned Secrets and expired failed TemplateInstances", func(t *testing.T) {
+		deletedSecrets := testutil.ToFloat64(janitorCleanupsTotal.WithLabelValues("secret", "deleted"))
+		deletedInstances := testutil.ToFloat64(janitorCleanupsTotal.WithLabelValues("templateinstance", "deleted"))
+
+		require.NoError(t, controller.sweep(ctx))
+
+		assert.True(t, exists(&templatev1.TemplateInstance{}, "vdc-ns", "running"))
+		assert.True(t, exists(&templatev1.TemplateInstance{}, "vdc-ns", "failed-recently"))
+		assert.False(t, exists(&templatev1.TemplateInstance{}, "vdc-ns", "failed-long-ago"))
+
+		assert.True(t, exists(&corev1.Secret{}, "vdc-ns", "running-params"))
+		assert.True(t, exists(&corev1.Secret{}, "vdc-ns", "failed-recently-params"))
+		assert.False(t, exists(&corev1.Secret{}, "vdc-ns", "failed-long-ago-params"))
+		assert.False(t, exists(&corev1.Secret{}, "vdc-ns", "orphan-params"))
+		assert.False(t, exists(&corev1.Secret{}, "vdc-ns", "orphan-ssh-keys"))
+		// Too young to tell from an instantiation in progress
+		assert.True(t, exists(&corev1.Secret{}, "vdc-ns", "creating-params"))
+		// Not created by ssvirt, or outside VDC namespaces
+		assert.True(t, exists(&corev1.Secret{}, "vdc-ns", "foreign-params"))
+		assert.True(t, exists(&corev1.Secret{}, "other-ns", "elsewhere-params"))
+
+		assert.Equal(t, deletedSecrets+3, testutil.ToFloat64(janitorCleanupsTotal.WithLabelValues("secret", "deleted")))
+		assert.Equal(t, deletedInstances+1, testutil.ToFloat64(janitorCleanupsTotal.WithLabelValues("templateinstance", "deleted")))
+	})
+
+	t.Run("Reports failed deletions", func(t *testing.T) {
+		failSecretDeletes = true
+		defer func() { failSecretDeletes = false }()
+		now = now.Add(time.Hour)
+		errorsBefore := testutil.ToFloat64(janitorCleanupsTotal.WithLabelValues("secret", "error"))
+
+		_, err := controller.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "vdc-ns"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "creating-params")
+		assert.Equal(t, errorsBefore+1, testutil.ToFloat64(janitorCleanupsTotal.WithLabelValues("secret", "error")))
+		assert.True(t, exists(&corev1.Secret{}, "vdc-ns", "creating-params"))
+	})
+}
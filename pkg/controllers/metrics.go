@@ -1,7 +1,11 @@
 package controllers
 
 import (
+	"reflect"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
@@ -95,6 +99,87 @@ var (
 		},
 		[]string{"namespace", "vdc_id", "vapp_name", "result"},
 	)
+
+	// Counter for failed reconciles scheduled for retry
+	reconcileRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_controller_reconcile_retries_total",
+			Help: "Total number of failed reconciles scheduled for retry",
+		},
+		[]string{"controller", "namespace"},
+	)
+
+	// Histogram for the backoff applied to retried reconciles
+	reconcileRetryDelay = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ssvirt_controller_reconcile_retry_delay_seconds",
+			Help:    "Backoff delay applied before retrying a failed reconcile",
+			Buckets: []float64{0.1, 1, 5, 15, 30, 60, 120, 300, 600},
+		},
+		[]string{"controller"},
+	)
+
+	// Counter for writes that lost a race with another writer and were retried
+	conflictRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_controller_conflict_retries_total",
+			Help: "Total number of writes retried against a refetched object after a conflict",
+		},
+		[]string{"kind"},
+	)
+
+	// Gauge for VM status updates waiting for the database
+	statusBufferDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ssvirt_vm_status_buffer_depth",
+			Help: "Number of VM status updates buffered while the database is unavailable",
+		},
+	)
+
+	// Counter for buffered VM status updates discarded because the buffer was full
+	statusBufferDroppedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_vm_status_buffer_dropped_total",
+			Help: "Total number of buffered VM status updates discarded because the buffer was full",
+		},
+		[]string{"policy"},
+	)
+
+	// Counter for buffered VM status updates replayed to the database
+	statusBufferReplayedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_vm_status_buffer_replayed_total",
+			Help: "Total number of buffered VM status update replay attempts",
+		},
+		[]string{"result"},
+	)
+
+	// Counter for leftover instantiation resources removed by the janitor
+	janitorCleanupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_janitor_cleanups_total",
+			Help: "Total number of orphaned Secrets and failed TemplateInstances cleaned up by the janitor",
+		},
+		[]string{"kind", "result"},
+	)
+
+	// Counter for group sync runs
+	groupSyncsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_group_syncs_total",
+			Help: "Total number of group mapping syncs, by result",
+		},
+		[]string{"result"},
+	)
+
+	// Counter for VM DNS record changes
+	dnsRecordsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_dns_records_total",
+			Help: "Total number of VM DNS records published or removed through ExternalDNS, by result",
+		},
+		[]string{"result"},
+	)
 )
 
 func init() {
@@ -110,6 +195,15 @@ func init() {
 		vmLabelOperationsTotal,
 		vmCreationOperationsTotal,
 		vappCreationOperationsTotal,
+		reconcileRetriesTotal,
+		reconcileRetryDelay,
+		conflictRetriesTotal,
+		statusBufferDepth,
+		statusBufferDroppedTotal,
+		statusBufferReplayedTotal,
+		janitorCleanupsTotal,
+		groupSyncsTotal,
+		dnsRecordsTotal,
 	)
 
 	// Initialize controller as healthy
@@ -152,6 +246,46 @@ func recordVAppCreationOperation(namespace, vdcID, vappName, result string) {
 	vappCreationOperationsTotal.WithLabelValues(namespace, vdcID, vappName, result).Inc()
 }
 
+// recordReconcileRetry records metrics for a reconcile scheduled for retry
+func recordReconcileRetry(controller, namespace string, delay time.Duration) {
+	reconcileRetriesTotal.WithLabelValues(controller, namespace).Inc()
+	reconcileRetryDelay.WithLabelValues(controller).Observe(delay.Seconds())
+}
+
+// recordConflictRetry records a write to obj that conflicted and is retried
+func recordConflictRetry(obj client.Object) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		kind = reflect.TypeOf(obj).Elem().Name()
+	}
+	conflictRetriesTotal.WithLabelValues(kind).Inc()
+}
+
+// recordStatusBufferDrop records a buffered status update discarded by the overflow policy
+func recordStatusBufferDrop(policy string) {
+	statusBufferDroppedTotal.WithLabelValues(policy).Inc()
+}
+
+// recordStatusBufferReplay records the result of replaying a buffered status update
+func recordStatusBufferReplay(result string) {
+	statusBufferReplayedTotal.WithLabelValues(result).Inc()
+}
+
+// recordJanitorCleanup records the result of deleting a leftover resource
+func recordJanitorCleanup(kind, result string) {
+	janitorCleanupsTotal.WithLabelValues(kind, result).Inc()
+}
+
+// recordGroupSync records the result of a group mapping sync
+func recordGroupSync(result string) {
+	groupSyncsTotal.WithLabelValues(result).Inc()
+}
+
+// recordDNSRecord records the result of publishing or removing a VM DNS record
+func recordDNSRecord(result string) {
+	dnsRecordsTotal.WithLabelValues(result).Inc()
+}
+
 // setControllerHealth sets the controller health metric
 func setControllerHealth(healthy bool) {
 	if healthy {
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// StorageAlertMailer delivers storage alerts through a notifier, such as the
+// SMTP notifier, to its default recipients
+type StorageAlertMailer struct {
+	Notifier services.Notifier
+}
+
+// NotifyStorageAlert sends the alert as a storage-alert notification
+func (m *StorageAlertMailer) NotifyStorageAlert(ctx context.Context, alert StorageAlert) error {
+	return m.Notifier.Notify(ctx, services.Notification{
+		Event:          services.NotificationStorageAlert,
+		OrganizationID: alert.OrganizationID,
+		Data:           alert,
+	})
+}
+
+// StorageAlertNotifiers delivers each alert to every notifier in turn
+type StorageAlertNotifiers []StorageAlertNotifier
+
+// NotifyStorageAlert notifies every notifier, returning their joined errors
+func (n StorageAlertNotifiers) NotifyStorageAlert(ctx context.Context, alert StorageAlert) error {
+	var errs []error
+	for _, notifier := range n {
+		if err := notifier.NotifyStorageAlert(ctx, alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+const (
+	// DefaultPowerStatePollInterval is how often the power state controller
+	// compares VMs with their desired power state
+	DefaultPowerStatePollInterval = 10 * time.Second
+	// DefaultPowerStateRetryMaxDelay caps the delay between attempts to fix a
+	// VM whose run strategy could not be changed
+	DefaultPowerStateRetryMaxDelay = 5 * time.Minute
+)
+
+// PowerStateVMRepositoryInterface defines the VM operations used by the power
+// state controller
+type PowerStateVMRepositoryInterface interface {
+	ListWithDesiredPowerState(ctx context.Context) ([]models.VM, error)
+	GetByNamespaceAndVMName(ctx context.Context, namespace, vmName string) (*models.VM, error)
+}
+
+// powerStateRetry tracks a VirtualMachine whose run strategy could not be changed
+type powerStateRetry struct {
+	failures int
+	next     time.Time
+}
+
+// PowerStateController reconciles the run strategy of each VirtualMachine
+// toward the desired power state recorded on its VM by the API. Desired states
+// live in the database rather than the cluster, so the controller polls for
+// them instead of watching a resource. Every pass also detects drift, such as
+// a VirtualMachine started or stopped outside the API, and reverts it. VMs
+// without a desired power state are left alone.
+type PowerStateController struct {
+	client.Client
+	VMRepo        PowerStateVMRepositoryInterface
+	Recorder      record.EventRecorder
+	PollInterval  time.Duration
+	RetryMaxDelay time.Duration
+
+	// reconciler reconciles one VM; it wraps the controller for health tracking
+	reconciler reconcile.Reconciler
+	// retries holds the VirtualMachines waiting to be retried after a failure
+	retries map[types.NamespacedName]powerStateRetry
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachines,verbs=get;list;watch;patch
+
+// Start reconciles VMs with a desired power state until the context is
+// cancelled. It implements manager.Runnable and runs only on the leader.
+func (r *PowerStateController) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("power-state")
+	ticker := time.NewTicker(r.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		if err := r.reconcileAll(ctx); err != nil {
+			logger.Error(err, "Failed to list VMs with a desired power state")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileAll reconciles every VM with a desired power state that is not
+// waiting for a retry. Failed VMs are retried with exponential backoff.
+func (r *PowerStateController) reconcileAll(ctx context.Context) error {
+	vms, err := r.VMRepo.ListWithDesiredPowerState(ctx)
+	if err != nil {
+		return err
+	}
+	reconciler := r.reconciler
+	if reconciler == nil {
+		reconciler = r
+	}
+	if r.retries == nil {
+		r.retries = make(map[types.NamespacedName]powerStateRetry)
+	}
+
+	now := r.clock()
+	listed := make(map[types.NamespacedName]bool, len(vms))
+	for _, vm := range vms {
+		if ctx.Err() != nil {
+			return nil
+		}
+		key := types.NamespacedName{Namespace: vm.Namespace, Name: vm.VMName}
+		listed[key] = true
+		retry, waiting := r.retries[key]
+		if waiting && now.Before(retry.next) {
+			continue
+		}
+
+		if _, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			retry.failures++
+			retry.next = now.Add(r.retryDelay(retry.failures))
+			r.retries[key] = retry
+			continue
+		}
+		delete(r.retries, key)
+	}
+
+	// Forget VMs that no longer have a desired power state
+	for key := range r.retries {
+		if !listed[key] {
+			delete(r.retries, key)
+		}
+	}
+	return nil
+}
+
+// Reconcile changes the run strategy of the named VirtualMachine when it does
+// not match its VM's desired power state and run strategy
+func (r *PowerStateController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("virtualmachine", req.NamespacedName)
+
+	vm, err := r.VMRepo.GetByNamespaceAndVMName(ctx, req.Namespace, req.Name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to get VM: %w", err)
+	}
+	if vm.DesiredPowerState == "" || vm.Status == "DELETING" || vm.Status == "DELETED" {
+		return ctrl.Result{}, nil
+	}
+
+	vmResource := &kubevirtv1.VirtualMachine{}
+	if err := r.Get(ctx, req.NamespacedName, vmResource); err != nil {
+		if k8serrors.IsNotFound(err) {
+			// The VM status controller reports VMs whose VirtualMachine is gone
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to get VirtualMachine: %w", err)
+	}
+
+	current, err := vmResource.RunStrategy()
+	if err != nil {
+		current = ""
+	}
+	want := desiredRunStrategy(vm, current, vmResource.Status.PrintableStatus == kubevirtv1.VirtualMachineStatusRunning)
+	if want == current {
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Changing run strategy to match desired power state",
+		"vm", vm.ID, "desiredPowerState", vm.DesiredPowerState, "runStrategy", current, "newRunStrategy", want)
+	patch := client.MergeFrom(vmResource.DeepCopy())
+	vmResource.Spec.RunStrategy = &want
+	vmResource.Spec.Running = nil
+	if err := r.Patch(ctx, vmResource, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set run strategy: %w", err)
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Eventf(vmResource, corev1.EventTypeNormal, "PowerStateReconciled",
+			"Run strategy changed from %s to %s to match the desired power state %s", current, want, vm.DesiredPowerState)
+	}
+	return ctrl.Result{}, nil
+}
+
+// desiredRunStrategy returns the run strategy a VirtualMachine should have for
+// its VM's desired power state and run strategy. Stopped VMs that should run
+// are started with Always, since KubeVirt does not start a VM given the Manual
+// strategy, and get the VM's run strategy once running. Without one, any
+// strategy that runs the VM is kept.
+func desiredRunStrategy(vm *models.VM, current kubevirtv1.VirtualMachineRunStrategy, running bool) kubevirtv1.VirtualMachineRunStrategy {
+	if vm.DesiredPowerState != models.VMPowerStateOn || vm.RunStrategy == models.VMRunStrategyHalted {
+		return kubevirtv1.RunStrategyHalted
+	}
+	if current == "" || current == kubevirtv1.RunStrategyHalted {
+		return kubevirtv1.RunStrategyAlways
+	}
+	if vm.RunStrategy == "" || (current == kubevirtv1.RunStrategyAlways && !running) {
+		return current
+	}
+	return kubevirtv1.VirtualMachineRunStrategy(vm.RunStrategy)
+}
+
+func (r *PowerStateController) pollInterval() time.Duration {
+	if r.PollInterval > 0 {
+		return r.PollInterval
+	}
+	return DefaultPowerStatePollInterval
+}
+
+// retryDelay doubles the poll interval for each consecutive failure, up to RetryMaxDelay
+func (r *PowerStateController) retryDelay(failures int) time.Duration {
+	maxDelay := r.RetryMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultPowerStateRetryMaxDelay
+	}
+	delay := r.pollInterval()
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+func (r *PowerStateController) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// SetupPowerStateController adds the power state controller to the manager
+func SetupPowerStateController(mgr ctrl.Manager, vmRepo PowerStateVMRepositoryInterface, pollInterval, retryMaxDelay time.Duration, opts ControllerOptions) error {
+	controller := &PowerStateController{
+		Client:        mgr.GetClient(),
+		VMRepo:        vmRepo,
+		Recorder:      mgr.GetEventRecorderFor("power-state-controller"),
+		PollInterval:  pollInterval,
+		RetryMaxDelay: retryMaxDelay,
+	}
+	controller.reconciler = opts.wrap(controller)
+	if err := mgr.Add(controller); err != nil {
+		return fmt.Errorf("failed to setup PowerStateController: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// fakePowerStateVMRepo keeps VMs in memory, keyed by VM name
+type fakePowerStateVMRepo struct {
+	vms map[string]*models.VM
+}
+
+func (f *fakePowerStateVMRepo) ListWithDesiredPowerState(_ context.Context) ([]models.VM, error) {
+	var vms []models.VM
+	for _, vm := range f.vms {
+		if vm.DesiredPowerState != "" {
+			vms = append(vms, *vm)
+		}
+	}
+	return vms, nil
+}
+
+func (f *fakePowerStateVMRepo) GetByNamespaceAndVMName(_ context.Context, _, vmName string) (*models.VM, error) {
+	vm, ok := f.vms[vmName]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *vm
+	return &copied, nil
+}
+
+func TestPowerStateController(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+
+	halted := kubevirtv1.RunStrategyHalted
+	always := kubevirtv1.RunStrategyAlways
+	running := true
+	newVM := func(name string, spec kubevirtv1.VirtualMachineSpec) *kubevirtv1.VirtualMachine {
+		return &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "dev-ns"}, Spec: spec}
+	}
+
+	failPatches := false
+	patches := 0
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			newVM("start", kubevirtv1.VirtualMachineSpec{RunStrategy: &halted}),
+			newVM("drifted", kubevirtv1.VirtualMachineSpec{Running: &running}),
+			newVM("synced", kubevirtv1.VirtualMachineSpec{RunStrategy: &always}),
+			newVM("unmanaged", kubevirtv1.VirtualMachineSpec{RunStrategy: &halted}),
+			newVM("deleting", kubevirtv1.VirtualMachineSpec{RunStrategy: &always}),
+		).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				patches++
+				if failPatches {
+					return errors.New("admission webhook unavailable")
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+
+	repo := &fakePowerStateVMRepo{vms: map[string]*models.VM{
+		"start":     {ID: "urn:vcloud:vm:1", VMName: "start", Namespace: "dev-ns", Status: "POWERED_OFF", DesiredPowerState: models.VMPowerStateOn},
+		"drifted":   {ID: "urn:vcloud:vm:2", VMName: "drifted", Namespace: "dev-ns", Status: "POWERED_ON", DesiredPowerState: models.VMPowerStateOff},
+		"synced":    {ID: "urn:vcloud:vm:3", VMName: "synced", Namespace: "dev-ns", Status: "POWERED_ON", DesiredPowerState: models.VMPowerStateOn},
+		"unmanaged": {ID: "urn:vcloud:vm:4", VMName: "unmanaged", Namespace: "dev-ns", Status: "POWERED_OFF"},
+		"deleting":  {ID: "urn:vcloud:vm:5", VMName: "deleting", Namespace: "dev-ns", Status: "DELETING", DesiredPowerState: models.VMPowerStateOff},
+		"missing":   {ID: "urn:vcloud:vm:6", VMName: "missing", Namespace: "dev-ns", Status: "POWERED_OFF", DesiredPowerState: models.VMPowerStateOn},
+	}}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	controller := &PowerStateController{
+		Client:        fakeClient,
+		VMRepo:        repo,
+		PollInterval:  10 * time.Second,
+		RetryMaxDelay: 30 * time.Second,
+		now:           func() time.Time { return now },
+	}
+	ctx := context.Background()
+
+	runStrategy := func(name string) kubevirtv1.VirtualMachineRunStrategy {
+		vm := &kubevirtv1.VirtualMachine{}
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "dev-ns"}, vm))
+		strategy, err := vm.RunStrategy()
+		require.NoError(t, err)
+		return strategy
+	}
+
+	t.Run("Reconciles run strategies toward the desired power state", func(t *testing.T) {
+		require.NoError(t, controller.reconcileAll(ctx))
+		assert.Equal(t, kubevirtv1.RunStrategyAlways, runStrategy("start"))
+		assert.Equal(t, kubevirtv1.RunStrategyHalted, runStrategy("drifted"))
+		assert.Equal(t, kubevirtv1.RunStrategyAlways, runStrategy("synced"))
+		assert.Equal(t, kubevirtv1.RunStrategyHalted, runStrategy("unmanaged"))
+		assert.Equal(t, kubevirtv1.RunStrategyAlways, runStrategy("deleting"))
+		assert.Equal(t, 2, patches)
+
+		// Nothing to do once the VMs match
+		require.NoError(t, controller.reconcileAll(ctx))
+		assert.Equal(t, 2, patches)
+	})
+
+	t.Run("Reverts drift", func(t *testing.T) {
+		vm := &kubevirtv1.VirtualMachine{}
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "start", Namespace: "dev-ns"}, vm))
+		vm.Spec.RunStrategy = &halted
+		require.NoError(t, fakeClient.Update(ctx, vm))
+
+		require.NoError(t, controller.reconcileAll(ctx))
+		assert.Equal(t, kubevirtv1.RunStrategyAlways, runStrategy("start"))
+	})
+
+	t.Run("Retries failures with backoff", func(t *testing.T) {
+		repo.vms["synced"].DesiredPowerState = models.VMPowerStateOff
+		failPatches = true
+		patches = 0
+
+		require.NoError(t, controller.reconcileAll(ctx))
+		assert.Equal(t, 1, patches)
+		assert.Equal(t, 1, controller.retries[types.NamespacedName{Name: "synced", Namespace: "dev-ns"}].failures)
+
+		// Waits for the first retry delay, then doubles it
+		now = now.Add(5 * time.Second)
+		require.NoError(t, controller.reconcileAll(ctx))
+		assert.Equal(t, 1, patches)
+		now = now.Add(5 * time.Second)
+		require.NoError(t, controller.reconcileAll(ctx))
+		assert.Equal(t, 2, patches)
+		assert.Equal(t, now.Add(20*time.Second), controller.retries[types.NamespacedName{Name: "synced", Namespace: "dev-ns"}].next)
+
+		failPatches = false
+		now = now.Add(20 * time.Second)
+		require.NoError(t, controller.reconcileAll(ctx))
+		assert.Equal(t, kubevirtv1.RunStrategyHalted, runStrategy("synced"))
+		assert.Empty(t, controller.retries)
+	})
+}
+
+func TestDesiredRunStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		vm      models.VM
+		current kubevirtv1.VirtualMachineRunStrategy
+		running bool
+		want    kubevirtv1.VirtualMachineRunStrategy
+	}{
+		{"powered off", models.VM{DesiredPowerState: models.VMPowerStateOff, RunStrategy: models.VMRunStrategyManual}, kubevirtv1.RunStrategyManual, true, kubevirtv1.RunStrategyHalted},
+		{"halted strategy", models.VM{DesiredPowerState: models.VMPowerStateOn, RunStrategy: models.VMRunStrategyHalted}, kubevirtv1.RunStrategyAlways, true, kubevirtv1.RunStrategyHalted},
+		{"started with Always", models.VM{DesiredPowerState: models.VMPowerStateOn, RunStrategy: models.VMRunStrategyManual}, kubevirtv1.RunStrategyHalted, false, kubevirtv1.RunStrategyAlways},
+		{"waits until running", models.VM{DesiredPowerState: models.VMPowerStateOn, RunStrategy: models.VMRunStrategyManual}, kubevirtv1.RunStrategyAlways, false, kubevirtv1.RunStrategyAlways},
+		{"applied once running", models.VM{DesiredPowerState: models.VMPowerStateOn, RunStrategy: models.VMRunStrategyManual}, kubevirtv1.RunStrategyAlways, true, kubevirtv1.RunStrategyManual},
+		{"stopped by its guest", models.VM{DesiredPowerState: models.VMPowerStateOn, RunStrategy: models.VMRunStrategyRerunOnFailure}, kubevirtv1.RunStrategyRerunOnFailure, false, kubevirtv1.RunStrategyRerunOnFailure},
+		{"changed strategy", models.VM{DesiredPowerState: models.VMPowerStateOn, RunStrategy: models.VMRunStrategyAlways}, kubevirtv1.RunStrategyRerunOnFailure, false, kubevirtv1.RunStrategyAlways},
+		{"template strategy kept", models.VM{DesiredPowerState: models.VMPowerStateOn}, kubevirtv1.RunStrategyRerunOnFailure, true, kubevirtv1.RunStrategyRerunOnFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, desiredRunStrategy(&tt.vm, tt.current, tt.running))
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cdiv1 "kubevirt.io/containerized-data-importer-api/pkg/apis/core/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// DefaultPrewarmPollInterval is how often the prewarm controller checks pinned
+// catalog items
+const DefaultPrewarmPollInterval = time.Minute
+
+// GoldenImageLabel marks the golden image DataVolumes the prewarm controller
+// imports; DataVolumes without it are never touched
+const GoldenImageLabel = "ssvirt.io/golden-image"
+
+// CDI annotations set on golden image DataVolumes
+const (
+	// Import right away instead of waiting for a pod to consume the claim
+	cdiBindImmediateAnnotation = "cdi.kubevirt.io/storage.bind.immediate.requested"
+	// Keep the DataVolume after the import, as its claim is cloned from
+	cdiDeleteAfterCompletionAnnotation = "cdi.kubevirt.io/storage.deleteAfterCompletion"
+)
+
+// CatalogItemPinRepositoryInterface defines the catalog item pin operations
+// used by the prewarm controller
+type CatalogItemPinRepositoryInterface interface {
+	Get(ctx context.Context, vdcID, templateName string) (*models.CatalogItemPin, error)
+	List(ctx context.Context) ([]models.CatalogItemPin, error)
+	RecordStatus(ctx context.Context, vdcID, templateName, status, message string, readyImages []string) error
+}
+
+// PrewarmController imports the base images of catalog items pinned for a VDC
+// into the VDC's namespace as golden image DataVolumes, so instantiating the
+// items clones the images within the namespace instead of downloading them.
+// Pins are stored in the database, so the controller polls them like the
+// catalog sync controller polls catalog sources. Golden images that no pin
+// needs any more, because the item was unpinned or its Template changed, are
+// deleted.
+type PrewarmController struct {
+	client.Client
+	Pins CatalogItemPinRepositoryInterface
+	// TemplateNamespace holds the Templates of catalog items
+	TemplateNamespace string
+	PollInterval      time.Duration
+
+	// reconciler imports the images of one pin; it wraps the controller for
+	// health tracking
+	reconciler reconcile.Reconciler
+}
+
+// +kubebuilder:rbac:groups=cdi.kubevirt.io,resources=datavolumes,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=template.openshift.io,resources=templates,verbs=get;list;watch
+
+// Start checks pinned catalog items until the context is cancelled. It
+// implements manager.Runnable and runs only on the leader.
+func (r *PrewarmController) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("prewarm")
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = DefaultPrewarmPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.sweep(ctx); err != nil {
+			logger.Error(err, "Failed to prewarm pinned catalog items")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep imports the images of every pin, then deletes the golden images no
+// pin needs. Namespaces whose wanted images cannot be determined are not pruned.
+func (r *PrewarmController) sweep(ctx context.Context) error {
+	pins, err := r.Pins.List(ctx)
+	if err != nil {
+		return err
+	}
+	reconciler := r.reconciler
+	if reconciler == nil {
+		reconciler = r
+	}
+
+	wanted := make(map[string]map[string]bool)
+	unknown := make(map[string]bool)
+	for _, pin := range pins {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if pin.VDC == nil || pin.VDC.Namespace == "" {
+			continue
+		}
+		// Failures are recorded on the pin and retried at the next poll
+		_, _ = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: pin.VDCID, Name: pin.TemplateName}})
+
+		namespace := pin.VDC.Namespace
+		images, err := r.goldenImages(ctx, pin.TemplateName)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			unknown[namespace] = true
+			continue
+		}
+		if wanted[namespace] == nil {
+			wanted[namespace] = make(map[string]bool)
+		}
+		for _, image := range images {
+			wanted[namespace][image.Name] = true
+		}
+	}
+
+	var dataVolumes cdiv1.DataVolumeList
+	if err := r.List(ctx, &dataVolumes, client.HasLabels{GoldenImageLabel}, client.MatchingLabels{managedByLabel: managedByValue}); err != nil {
+		return fmt.Errorf("failed to list golden image DataVolumes: %w", err)
+	}
+	var errs []error
+	for i := range dataVolumes.Items {
+		dv := &dataVolumes.Items[i]
+		if unknown[dv.Namespace] || wanted[dv.Namespace][dv.Name] {
+			continue
+		}
+		if err := r.Delete(ctx, dv); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, fmt.Errorf("failed to delete golden image %s/%s: %w", dv.Namespace, dv.Name, err))
+			continue
+		}
+		log.FromContext(ctx).Info("Deleted unpinned golden image", "namespace", dv.Namespace, "dataVolume", dv.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// Reconcile imports the images of the pin named by the request, whose
+// namespace is the VDC ID and name the Template name, and records its status
+func (r *PrewarmController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("vdc", req.Namespace, "template", req.Name)
+
+	pin, err := r.Pins.Get(ctx, req.Namespace, req.Name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if pin.VDC == nil || pin.VDC.Namespace == "" {
+		return ctrl.Result{}, nil
+	}
+
+	images, err := r.goldenImages(ctx, pin.TemplateName)
+	if k8serrors.IsNotFound(err) {
+		return ctrl.Result{}, r.record(ctx, pin, models.CatalogItemPinFailed,
+			fmt.Sprintf("Template %s not found", pin.TemplateName), nil)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var ready, failed []string
+	for _, image := range images {
+		dv, err := r.ensureGoldenImage(ctx, pin.VDC.Namespace, image)
+		if err != nil {
+			logger.Error(err, "Failed to import golden image", "dataVolume", image.Name)
+			return ctrl.Result{}, r.record(ctx, pin, models.CatalogItemPinFailed, err.Error(), ready)
+		}
+		switch dv.Status.Phase {
+		case cdiv1.Succeeded:
+			ready = append(ready, image.Name)
+		case cdiv1.Failed:
+			failed = append(failed, image.Name)
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		return ctrl.Result{}, r.record(ctx, pin, models.CatalogItemPinFailed,
+			fmt.Sprintf("Import of golden image %s failed", failed[0]), ready)
+	case len(ready) < len(images):
+		return ctrl.Result{}, r.record(ctx, pin, models.CatalogItemPinImporting,
+			fmt.Sprintf("%d of %d images imported", len(ready), len(images)), ready)
+	case len(images) == 0:
+		return ctrl.Result{}, r.record(ctx, pin, models.CatalogItemPinReady,
+			"The catalog item imports no images", nil)
+	}
+	if pin.Status != models.CatalogItemPinReady {
+		logger.Info("Pinned catalog item is ready", "namespace", pin.VDC.Namespace, "images", len(ready))
+	}
+	return ctrl.Result{}, r.record(ctx, pin, models.CatalogItemPinReady, "", ready)
+}
+
+// goldenImages returns the golden images of a catalog item's Template
+func (r *PrewarmController) goldenImages(ctx context.Context, templateName string) ([]services.GoldenImage, error) {
+	var template templatev1.Template
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.TemplateNamespace, Name: templateName}, &template); err != nil {
+		return nil, err
+	}
+	return services.TemplateGoldenImages(&template)
+}
+
+// ensureGoldenImage returns the golden image DataVolume in the namespace,
+// creating it when it does not exist
+func (r *PrewarmController) ensureGoldenImage(ctx context.Context, namespace string, image services.GoldenImage) (*cdiv1.DataVolume, error) {
+	var dv cdiv1.DataVolume
+	err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: image.Name}, &dv)
+	if err == nil {
+		return &dv, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get golden image %s: %w", image.Name, err)
+	}
+
+	size, err := resource.ParseQuantity(image.Size)
+	if err != nil {
+		return nil, fmt.Errorf("golden image %s has invalid size %q: %w", image.Name, image.Size, err)
+	}
+	source := image.Source
+	dv = cdiv1.DataVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      image.Name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				GoldenImageLabel: "true",
+				managedByLabel:   managedByValue,
+			},
+			Annotations: map[string]string{
+				cdiBindImmediateAnnotation:         "true",
+				cdiDeleteAfterCompletionAnnotation: "false",
+			},
+		},
+		Spec: cdiv1.DataVolumeSpec{
+			Source: &source,
+			Storage: &cdiv1.StorageSpec{
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+				},
+			},
+		},
+	}
+	if err := r.Create(ctx, &dv); err != nil {
+		return nil, fmt.Errorf("failed to create golden image %s: %w", image.Name, err)
+	}
+	log.FromContext(ctx).Info("Importing golden image", "namespace", namespace, "dataVolume", image.Name)
+	return &dv, nil
+}
+
+// record stores the status of a pin
+func (r *PrewarmController) record(ctx context.Context, pin *models.CatalogItemPin, status, message string, ready []string) error {
+	if err := r.Pins.RecordStatus(ctx, pin.VDCID, pin.TemplateName, status, message, ready); err != nil {
+		return fmt.Errorf("failed to record catalog item pin status: %w", err)
+	}
+	return nil
+}
+
+// SetupPrewarmController adds the prewarm controller to the manager. It reads
+// the Templates of catalog items from the given namespace.
+func SetupPrewarmController(mgr ctrl.Manager, pins CatalogItemPinRepositoryInterface, templateNamespace string, pollInterval time.Duration, opts ControllerOptions) error {
+	controller := &PrewarmController{
+		Client:            mgr.GetClient(),
+		Pins:              pins,
+		TemplateNamespace: templateNamespace,
+		PollInterval:      pollInterval,
+	}
+	controller.reconciler = opts.wrap(controller)
+	if err := mgr.Add(controller); err != nil {
+		return fmt.Errorf("failed to setup PrewarmController: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	cdiv1 "kubevirt.io/containerized-data-importer-api/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// fakeCatalogItemPins keeps catalog item pins in memory
+type fakeCatalogItemPins struct {
+	pins map[string]*models.CatalogItemPin
+}
+
+func (f *fakeCatalogItemPins) Get(_ context.Context, vdcID, templateName string) (*models.CatalogItemPin, error) {
+	pin, ok := f.pins[vdcID+"/"+templateName]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *pin
+	return &copied, nil
+}
+
+func (f *fakeCatalogItemPins) List(_ context.Context) ([]models.CatalogItemPin, error) {
+	var pins []models.CatalogItemPin
+	for _, pin := range f.pins {
+		pins = append(pins, *pin)
+	}
+	return pins, nil
+}
+
+func (f *fakeCatalogItemPins) RecordStatus(_ context.Context, vdcID, templateName, status, message string, readyImages []string) error {
+	pin := f.pins[vdcID+"/"+templateName]
+	pin.Status, pin.Message = status, message
+	pin.ReadyImages = strings.Join(readyImages, "\n")
+	return nil
+}
+
+// goldenImageTemplate returns a Template whose VM imports a registry image,
+// a parameterized URL and a blank disk
+func goldenImageTemplate(name string) *templatev1.Template {
+	vm := `{"apiVersion":"kubevirt.io/v1","kind":"VirtualMachine","metadata":{"name":"vm"},"spec":{"dataVolumeTemplates":[
+		{"metadata":{"name":"root"},"spec":{"source":{"registry":{"url":"docker://quay.io/containerdisks/fedora:40"}},"storage":{"resources":{"requests":{"storage":"30Gi"}}}}},
+		{"metadata":{"name":"extra"},"spec":{"source":{"http":{"url":"https://images.example.com/${IMAGE}.qcow2"}},"storage":{"resources":{"requests":{"storage":"10Gi"}}}}},
+		{"metadata":{"name":"data"},"spec":{"source":{"blank":{}},"storage":{"resources":{"requests":{"storage":"5Gi"}}}}}
+	]}}`
+	return &templatev1.Template{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "catalog"},
+		Objects:    []runtime.RawExtension{{Raw: []byte(vm)}},
+	}
+}
+
+func TestPrewarmController(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, templatev1.AddToScheme(scheme))
+	require.NoError(t, cdiv1.AddToScheme(scheme))
+
+	stale := &cdiv1.DataVolume{ObjectMeta: metav1.ObjectMeta{
+		Name:      "golden-stale",
+		Namespace: "vdc-ns",
+		Labels:    map[string]string{GoldenImageLabel: "true", managedByLabel: managedByValue},
+	}}
+	unmanaged := &cdiv1.DataVolume{ObjectMeta: metav1.ObjectMeta{Name: "golden-mine", Namespace: "vdc-ns"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(goldenImageTemplate("fedora"), stale, unmanaged).
+		WithStatusSubresource(&cdiv1.DataVolume{}).
+		Build()
+
+	vdc := &models.VDC{ID: "urn:vcloud:vdc:1", Namespace: "vdc-ns"}
+	pins := &fakeCatalogItemPins{pins: map[string]*models.CatalogItemPin{
+		"urn:vcloud:vdc:1/fedora": {VDCID: vdc.ID, TemplateName: "fedora", Status: models.CatalogItemPinPending, VDC: vdc},
+	}}
+	controller := &PrewarmController{Client: fakeClient, Pins: pins, TemplateNamespace: "catalog"}
+	pin := func() *models.CatalogItemPin { return pins.pins["urn:vcloud:vdc:1/fedora"] }
+
+	images, err := services.TemplateGoldenImages(goldenImageTemplate("fedora"))
+	require.NoError(t, err)
+	require.Len(t, images, 1, "blank and parameterized sources are not prewarmed")
+	golden := types.NamespacedName{Namespace: "vdc-ns", Name: images[0].Name}
+
+	t.Run("golden images are imported and stale ones pruned", func(t *testing.T) {
+		require.NoError(t, controller.sweep(context.Background()))
+		assert.Equal(t, models.CatalogItemPinImporting, pin().Status)
+		assert.Empty(t, pin().ReadyImageNames())
+
+		var dv cdiv1.DataVolume
+		require.NoError(t, fakeClient.Get(context.Background(), golden, &dv))
+		assert.Equal(t, "docker://quay.io/containerdisks/fedora:40", *dv.Spec.Source.Registry.URL)
+		assert.Equal(t, "30Gi", dv.Spec.Storage.Resources.Requests.Storage().String())
+		assert.Equal(t, "true", dv.Annotations[cdiBindImmediateAnnotation])
+
+		err := fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "vdc-ns", Name: "golden-stale"}, &cdiv1.DataVolume{})
+		assert.True(t, k8serrors.IsNotFound(err), "golden images no pin needs are deleted")
+		assert.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "vdc-ns", Name: "golden-mine"}, &cdiv1.DataVolume{}),
+			"unlabelled DataVolumes are left alone")
+	})
+
+	t.Run("pins are ready once their images are imported", func(t *testing.T) {
+		var dv cdiv1.DataVolume
+		require.NoError(t, fakeClient.Get(context.Background(), golden, &dv))
+		dv.Status.Phase = cdiv1.Succeeded
+		require.NoError(t, fakeClient.Status().Update(context.Background(), &dv))
+
+		require.NoError(t, controller.sweep(context.Background()))
+		assert.Equal(t, models.CatalogItemPinReady, pin().Status)
+		assert.Equal(t, []string{images[0].Name}, pin().ReadyImageNames())
+	})
+
+	t.Run("missing templates fail the pin", func(t *testing.T) {
+		pins.pins["urn:vcloud:vdc:1/gone"] = &models.CatalogItemPin{VDCID: vdc.ID, TemplateName: "gone", VDC: vdc}
+		require.NoError(t, controller.sweep(context.Background()))
+		assert.Equal(t, models.CatalogItemPinFailed, pins.pins["urn:vcloud:vdc:1/gone"].Status)
+		assert.Contains(t, pins.pins["urn:vcloud:vdc:1/gone"].Message, "not found")
+	})
+
+	t.Run("unpinned images are deleted", func(t *testing.T) {
+		delete(pins.pins, "urn:vcloud:vdc:1/fedora")
+		require.NoError(t, controller.sweep(context.Background()))
+		err := fakeClient.Get(context.Background(), golden, &cdiv1.DataVolume{})
+		assert.True(t, k8serrors.IsNotFound(err))
+	})
+}
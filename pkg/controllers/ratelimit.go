@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Default retry settings for failed reconciles
+const (
+	DefaultRetryBaseDelay = 5 * time.Second
+	DefaultRetryMaxDelay  = 5 * time.Minute
+	DefaultNamespaceQPS   = 5.0
+	DefaultNamespaceBurst = 50
+)
+
+// RateLimiterOptions configures how failed reconciles are retried
+type RateLimiterOptions struct {
+	// BaseDelay is the delay before the first retry of a request; it doubles
+	// on each consecutive failure of the same request
+	BaseDelay time.Duration
+	// MaxDelay caps the per-request exponential backoff
+	MaxDelay time.Duration
+	// NamespaceQPS limits the rate of retries across all requests in a namespace
+	NamespaceQPS float64
+	// NamespaceBurst is the number of retries a namespace may make at once
+	NamespaceBurst int
+}
+
+// withDefaults fills in unset options
+func (o RateLimiterOptions) withDefaults() RateLimiterOptions {
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = DefaultRetryBaseDelay
+	}
+	if o.MaxDelay < o.BaseDelay {
+		o.MaxDelay = DefaultRetryMaxDelay
+		if o.MaxDelay < o.BaseDelay {
+			o.MaxDelay = o.BaseDelay
+		}
+	}
+	if o.NamespaceQPS <= 0 {
+		o.NamespaceQPS = DefaultNamespaceQPS
+	}
+	if o.NamespaceBurst <= 0 {
+		o.NamespaceBurst = DefaultNamespaceBurst
+	}
+	return o
+}
+
+// NewReconcileRateLimiter returns a workqueue rate limiter that backs off each
+// failing request exponentially and spreads retries within a namespace, so an
+// outage affecting many objects does not produce a retry storm. Retries are
+// recorded in the ssvirt_controller_reconcile_retries_total metric.
+func NewReconcileRateLimiter(controllerName string, opts RateLimiterOptions) workqueue.TypedRateLimiter[reconcile.Request] {
+	opts = opts.withDefaults()
+	return &instrumentedRateLimiter{
+		controller: controllerName,
+		TypedRateLimiter: workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](opts.BaseDelay, opts.MaxDelay),
+			newNamespaceRateLimiter(opts.NamespaceQPS, opts.NamespaceBurst),
+		),
+	}
+}
+
+// namespaceRateLimiterIdleTimeout is how long a namespace's bucket is kept
+// after its last retry. Buckets are only forgotten once they have refilled, so
+// forgetting one does not let the namespace retry sooner.
+const namespaceRateLimiterIdleTimeout = 10 * time.Minute
+
+// namespaceRateLimiter applies a token bucket per namespace
+type namespaceRateLimiter struct {
+	qps   rate.Limit
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	limiters  map[string]*namespaceBucket
+	lastPrune time.Time
+}
+
+type namespaceBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newNamespaceRateLimiter(qps float64, burst int) *namespaceRateLimiter {
+	return &namespaceRateLimiter{
+		qps:      rate.Limit(qps),
+		burst:    burst,
+		now:      time.Now,
+		limiters: make(map[string]*namespaceBucket),
+	}
+}
+
+// When returns how long the request must wait for its namespace's next token
+func (n *namespaceRateLimiter) When(req reconcile.Request) time.Duration {
+	now := n.now()
+
+	n.mu.Lock()
+	n.prune(now)
+	bucket, ok := n.limiters[req.Namespace]
+	if !ok {
+		bucket = &namespaceBucket{limiter: rate.NewLimiter(n.qps, n.burst)}
+		n.limiters[req.Namespace] = bucket
+	}
+	bucket.lastSeen = now
+	n.mu.Unlock()
+
+	return bucket.limiter.ReserveN(now, 1).DelayFrom(now)
+}
+
+// prune forgets the buckets of namespaces that have not retried for a while
+// and have refilled. n.mu must be held.
+func (n *namespaceRateLimiter) prune(now time.Time) {
+	if now.Sub(n.lastPrune) < namespaceRateLimiterIdleTimeout {
+		return
+	}
+	for namespace, bucket := range n.limiters {
+		if now.Sub(bucket.lastSeen) > namespaceRateLimiterIdleTimeout && bucket.limiter.TokensAt(now) >= float64(n.burst) {
+			delete(n.limiters, namespace)
+		}
+	}
+	n.lastPrune = now
+}
+
+// Forget is a no-op; namespace buckets refill over time
+func (n *namespaceRateLimiter) Forget(req reconcile.Request) {}
+
+// NumRequeues is not tracked per namespace
+func (n *namespaceRateLimiter) NumRequeues(req reconcile.Request) int {
+	return 0
+}
+
+// instrumentedRateLimiter records retry metrics for the wrapped rate limiter
+type instrumentedRateLimiter struct {
+	workqueue.TypedRateLimiter[reconcile.Request]
+	controller string
+}
+
+// When records the retry and its delay
+func (l *instrumentedRateLimiter) When(req reconcile.Request) time.Duration {
+	delay := l.TypedRateLimiter.When(req)
+	recordReconcileRetry(l.controller, req.Namespace, delay)
+	return delay
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func request(namespace, name string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+}
+
+func TestReconcileRateLimiterBackoff(t *testing.T) {
+	limiter := NewReconcileRateLimiter("backoff_test", RateLimiterOptions{
+		BaseDelay:      time.Second,
+		MaxDelay:       4 * time.Second,
+		NamespaceQPS:   1000,
+		NamespaceBurst: 1000,
+	})
+	req := request("ns-a", "vm-1")
+
+	// Consecutive failures of the same request back off exponentially up to the cap
+	assert.Equal(t, time.Second, limiter.When(req))
+	assert.Equal(t, 2*time.Second, limiter.When(req))
+	assert.Equal(t, 4*time.Second, limiter.When(req))
+	assert.Equal(t, 4*time.Second, limiter.When(req))
+	assert.Equal(t, 4, limiter.NumRequeues(req))
+
+	// A successful reconcile resets the backoff
+	limiter.Forget(req)
+	assert.Equal(t, time.Second, limiter.When(req))
+
+	assert.Equal(t, float64(5), testutil.ToFloat64(reconcileRetriesTotal.WithLabelValues("backoff_test", "ns-a")))
+}
+
+func TestReconcileRateLimiterNamespaceLimit(t *testing.T) {
+	limiter := NewReconcileRateLimiter("namespace_test", RateLimiterOptions{
+		BaseDelay:      time.Millisecond,
+		MaxDelay:       time.Millisecond,
+		NamespaceQPS:   1,
+		NamespaceBurst: 1,
+	})
+
+	// The first retry in a namespace only waits for its own backoff
+	assert.Equal(t, time.Millisecond, limiter.When(request("ns-a", "vm-1")))
+
+	// Further retries in the same namespace are spread out by the namespace bucket
+	assert.Greater(t, limiter.When(request("ns-a", "vm-2")), 500*time.Millisecond)
+
+	// Other namespaces are unaffected
+	assert.Equal(t, time.Millisecond, limiter.When(request("ns-b", "vm-1")))
+}
+
+func TestNamespaceRateLimiterPrune(t *testing.T) {
+	now := time.Now()
+	limiter := newNamespaceRateLimiter(1, 1)
+	limiter.now = func() time.Time { return now }
+
+	limiter.When(request("ns-a", "vm-1"))
+	limiter.When(request("ns-b", "vm-1"))
+	limiter.When(request("ns-b", "vm-2"))
+	assert.Len(t, limiter.limiters, 2)
+
+	// Idle namespaces whose buckets have refilled are forgotten
+	now = now.Add(namespaceRateLimiterIdleTimeout + time.Second)
+	assert.Zero(t, limiter.When(request("ns-c", "vm-1")))
+	assert.Len(t, limiter.limiters, 1)
+	assert.Contains(t, limiter.limiters, "ns-c")
+}
+
+func TestRateLimiterOptionsDefaults(t *testing.T) {
+	opts := RateLimiterOptions{}.withDefaults()
+	assert.Equal(t, DefaultRetryBaseDelay, opts.BaseDelay)
+	assert.Equal(t, DefaultRetryMaxDelay, opts.MaxDelay)
+	assert.Equal(t, DefaultNamespaceQPS, opts.NamespaceQPS)
+	assert.Equal(t, DefaultNamespaceBurst, opts.NamespaceBurst)
+
+	// A max delay below the base delay is raised to the default
+	opts = RateLimiterOptions{BaseDelay: time.Minute, MaxDelay: time.Second}.withDefaults()
+	assert.Equal(t, DefaultRetryMaxDelay, opts.MaxDelay)
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// OverflowPolicy decides which update is discarded when the status buffer is full
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest discards the oldest pending update to make room
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	// OverflowDropNewest rejects the incoming update, leaving the reconcile to retry
+	OverflowDropNewest OverflowPolicy = "drop-newest"
+)
+
+// Default status buffer settings
+const (
+	DefaultStatusBufferCapacity       = 10000
+	DefaultStatusBufferReplayInterval = 10 * time.Second
+)
+
+// ParseOverflowPolicy validates an overflow policy name
+func ParseOverflowPolicy(policy string) (OverflowPolicy, error) {
+	switch OverflowPolicy(policy) {
+	case OverflowDropOldest, OverflowDropNewest:
+		return OverflowPolicy(policy), nil
+	case "":
+		return OverflowDropOldest, nil
+	default:
+		return "", fmt.Errorf("unknown status buffer overflow policy %q", policy)
+	}
+}
+
+// StatusBufferOptions configures a StatusBuffer
+type StatusBufferOptions struct {
+	// Capacity is the maximum number of pending updates held in memory
+	Capacity int
+	// Policy decides what to discard when the buffer is full
+	Policy OverflowPolicy
+	// ReplayInterval is how often pending updates are written to the database
+	ReplayInterval time.Duration
+}
+
+// PendingStatusUpdate is a VM status change that could not be written to the database
+type PendingStatusUpdate struct {
+	Namespace  string
+	VMName     string
+	Status     string
+	ObservedAt time.Time
+
+	seq uint64
+}
+
+// StatusBuffer holds VM status updates in memory while the database is
+// unavailable and replays them in the order they were observed once it
+// returns. Updates for a VM that is already pending replace the earlier entry,
+// so a flapping VM occupies a single slot.
+type StatusBuffer struct {
+	repo VMRepositoryInterface
+	opts StatusBufferOptions
+
+	mu      sync.Mutex
+	pending []PendingStatusUpdate
+	nextSeq uint64
+}
+
+// NewStatusBuffer creates a StatusBuffer that replays updates through repo
+func NewStatusBuffer(repo VMRepositoryInterface, opts StatusBufferOptions) *StatusBuffer {
+	if opts.Capacity <= 0 {
+		opts.Capacity = DefaultStatusBufferCapacity
+	}
+	if opts.Policy == "" {
+		opts.Policy = OverflowDropOldest
+	}
+	if opts.ReplayInterval <= 0 {
+		opts.ReplayInterval = DefaultStatusBufferReplayInterval
+	}
+	return &StatusBuffer{
+		repo: repo,
+		opts: opts,
+	}
+}
+
+// Enqueue buffers a status update. It returns false if the update was rejected
+// because the buffer is full and the policy is drop-newest.
+func (b *StatusBuffer) Enqueue(update PendingStatusUpdate) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Replace an earlier pending update for the same VM
+	for i, existing := range b.pending {
+		if existing.Namespace == update.Namespace && existing.VMName == update.VMName {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			break
+		}
+	}
+
+	if len(b.pending) >= b.opts.Capacity {
+		if b.opts.Policy == OverflowDropNewest {
+			recordStatusBufferDrop(string(b.opts.Policy))
+			statusBufferDepth.Set(float64(len(b.pending)))
+			return false
+		}
+		b.pending = b.pending[1:]
+		recordStatusBufferDrop(string(b.opts.Policy))
+	}
+
+	b.nextSeq++
+	update.seq = b.nextSeq
+	if update.ObservedAt.IsZero() {
+		update.ObservedAt = time.Now()
+	}
+	b.pending = append(b.pending, update)
+	statusBufferDepth.Set(float64(len(b.pending)))
+	return true
+}
+
+// Pending reports whether an update for the VM is waiting to be replayed. It is
+// safe to call on a nil buffer.
+func (b *StatusBuffer) Pending(namespace, vmName string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, update := range b.pending {
+		if update.Namespace == namespace && update.VMName == vmName {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of pending updates
+func (b *StatusBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// Flush replays pending updates in order, stopping at the first database error
+// so later updates are not applied ahead of earlier ones. It returns the number
+// of updates removed from the buffer.
+func (b *StatusBuffer) Flush(ctx context.Context) (int, error) {
+	flushed := 0
+	for {
+		b.mu.Lock()
+		if len(b.pending) == 0 {
+			b.mu.Unlock()
+			return flushed, nil
+		}
+		update := b.pending[0]
+		b.mu.Unlock()
+
+		result, err := b.apply(ctx, update)
+		if err != nil {
+			recordStatusBufferReplay("error")
+			return flushed, err
+		}
+		recordStatusBufferReplay(result)
+
+		b.remove(update.seq)
+		flushed++
+	}
+}
+
+// apply writes a single update, returning the replay result for metrics
+func (b *StatusBuffer) apply(ctx context.Context, update PendingStatusUpdate) (string, error) {
+	vmRecord, err := b.repo.GetByNamespaceAndVMName(ctx, update.Namespace, update.VMName)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The VM record never made it to the database; the reconcile that
+			// buffered the update was requeued to create it
+			return "not_found", nil
+		}
+		return "", err
+	}
+
+	if err := b.repo.UpdateStatus(ctx, vmRecord.ID, update.Status); err != nil {
+		return "", err
+	}
+	return "success", nil
+}
+
+// remove deletes the update with the given sequence number if it is still pending
+func (b *StatusBuffer) remove(seq uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, update := range b.pending {
+		if update.seq == seq {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			break
+		}
+	}
+	statusBufferDepth.Set(float64(len(b.pending)))
+}
+
+// Start replays pending updates until the context is cancelled. It implements
+// manager.Runnable and runs only on the leader, like the controllers that fill it.
+func (b *StatusBuffer) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("status-buffer")
+	ticker := time.NewTicker(b.opts.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if remaining := b.Len(); remaining > 0 {
+				logger.Info("Discarding buffered VM status updates on shutdown", "count", remaining)
+			}
+			return nil
+		case <-ticker.C:
+			if b.Len() == 0 {
+				continue
+			}
+			flushed, err := b.Flush(ctx)
+			if flushed > 0 {
+				logger.Info("Replayed buffered VM status updates", "count", flushed, "remaining", b.Len())
+			}
+			if err != nil {
+				logger.V(1).Info("Database still unavailable, keeping buffered VM status updates", "error", err.Error(), "remaining", b.Len())
+			}
+		}
+	}
+}
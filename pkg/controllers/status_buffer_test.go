@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+var errDatabaseDown = fmt.Errorf("dial tcp: %w", syscall.ECONNREFUSED)
+
+func pendingNames(b *StatusBuffer) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var names []string
+	for _, update := range b.pending {
+		names = append(names, update.VMName+"="+update.Status)
+	}
+	return names
+}
+
+func TestStatusBufferEnqueue(t *testing.T) {
+	t.Run("coalesces updates for the same VM", func(t *testing.T) {
+		buffer := NewStatusBuffer(new(MockVMRepository), StatusBufferOptions{Capacity: 10})
+		buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-1", Status: "POWERING_ON"})
+		buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-2", Status: "POWERED_OFF"})
+		buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-1", Status: "POWERED_ON"})
+
+		assert.Equal(t, []string{"vm-2=POWERED_OFF", "vm-1=POWERED_ON"}, pendingNames(buffer))
+		assert.True(t, buffer.Pending("ns", "vm-1"))
+		assert.False(t, buffer.Pending("other", "vm-1"))
+	})
+
+	t.Run("drop-oldest discards the head", func(t *testing.T) {
+		buffer := NewStatusBuffer(new(MockVMRepository), StatusBufferOptions{Capacity: 2, Policy: OverflowDropOldest})
+		assert.True(t, buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-1", Status: "A"}))
+		assert.True(t, buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-2", Status: "B"}))
+		assert.True(t, buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-3", Status: "C"}))
+
+		assert.Equal(t, []string{"vm-2=B", "vm-3=C"}, pendingNames(buffer))
+	})
+
+	t.Run("drop-newest rejects the incoming update", func(t *testing.T) {
+		buffer := NewStatusBuffer(new(MockVMRepository), StatusBufferOptions{Capacity: 2, Policy: OverflowDropNewest})
+		assert.True(t, buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-1", Status: "A"}))
+		assert.True(t, buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-2", Status: "B"}))
+		assert.False(t, buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-3", Status: "C"}))
+
+		assert.Equal(t, []string{"vm-1=A", "vm-2=B"}, pendingNames(buffer))
+	})
+
+	t.Run("nil buffer has nothing pending", func(t *testing.T) {
+		var buffer *StatusBuffer
+		assert.False(t, buffer.Pending("ns", "vm-1"))
+	})
+}
+
+func TestStatusBufferFlush(t *testing.T) {
+	repo := new(MockVMRepository)
+	buffer := NewStatusBuffer(repo, StatusBufferOptions{Capacity: 10})
+	buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-1", Status: "POWERED_ON"})
+	buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-2", Status: "POWERED_OFF"})
+	buffer.Enqueue(PendingStatusUpdate{Namespace: "ns", VMName: "vm-3", Status: "POWERED_ON"})
+
+	// Database still down: nothing is replayed and order is preserved
+	repo.On("GetByNamespaceAndVMName", mock.Anything, "ns", "vm-1").Return(nil, errDatabaseDown).Once()
+	flushed, err := buffer.Flush(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 0, flushed)
+	assert.Equal(t, 3, buffer.Len())
+
+	// Database back: updates replay in order, unknown VMs are dropped
+	repo.On("GetByNamespaceAndVMName", mock.Anything, "ns", "vm-1").Return(&models.VM{ID: "vm-id-1"}, nil).Once()
+	repo.On("UpdateStatus", mock.Anything, "vm-id-1", "POWERED_ON").Return(nil).Once()
+	repo.On("GetByNamespaceAndVMName", mock.Anything, "ns", "vm-2").Return(nil, gorm.ErrRecordNotFound).Once()
+	repo.On("GetByNamespaceAndVMName", mock.Anything, "ns", "vm-3").Return(&models.VM{ID: "vm-id-3"}, nil).Once()
+	repo.On("UpdateStatus", mock.Anything, "vm-id-3", "POWERED_ON").Return(nil).Once()
+
+	flushed, err = buffer.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, flushed)
+	assert.Equal(t, 0, buffer.Len())
+	repo.AssertExpectations(t)
+}
+
+func TestVMStatusControllerBuffersDuringOutage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+
+	vm := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"},
+		Status:     kubevirtv1.VirtualMachineStatus{PrintableStatus: kubevirtv1.VirtualMachineStatusRunning},
+	}
+
+	repo := new(MockVMRepository)
+	repo.On("GetByNamespaceAndVMName", mock.Anything, "test-namespace", "test-vm").Return(nil, errDatabaseDown).Once()
+	buffer := NewStatusBuffer(repo, StatusBufferOptions{Capacity: 10})
+
+	controller := &VMStatusController{
+		Client:       fake.NewClientBuilder().WithScheme(scheme).WithObjects(vm).Build(),
+		Scheme:       scheme,
+		VMRepo:       repo,
+		VAppRepo:     new(MockVAppRepository),
+		VDCRepo:      new(MockVDCRepository),
+		Recorder:     &MockEventRecorder{},
+		StatusBuffer: buffer,
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "test-vm"}}
+
+	// The reconcile succeeds and the status waits in the buffer, requeued in
+	// case the record still has to be created
+	result, err := controller.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: DefaultStatusBufferReplayInterval}, result)
+	assert.Equal(t, []string{"test-vm=POWERED_ON"}, pendingNames(buffer))
+
+	// While the update is pending, newer updates queue behind it even if the database is back
+	repo.On("GetByNamespaceAndVMName", mock.Anything, "test-namespace", "test-vm").
+		Return(&models.VM{ID: "vm-123", Status: "POWERED_OFF"}, nil)
+	vm.Status.PrintableStatus = kubevirtv1.VirtualMachineStatusStopped
+	require.NoError(t, controller.Update(context.Background(), vm))
+	_, err = controller.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test-vm=POWERED_OFF"}, pendingNames(buffer))
+	repo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+
+	// Replay writes the latest status
+	repo.On("UpdateStatus", mock.Anything, "vm-123", "POWERED_OFF").Return(nil).Once()
+	flushed, err := buffer.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+	repo.AssertExpectations(t)
+}
+
+func TestVMStatusControllerDoesNotBufferOtherErrors(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+
+	vm := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"},
+		Status:     kubevirtv1.VirtualMachineStatus{PrintableStatus: kubevirtv1.VirtualMachineStatusRunning},
+	}
+
+	repo := new(MockVMRepository)
+	repo.On("GetByNamespaceAndVMName", mock.Anything, "test-namespace", "test-vm").
+		Return(nil, errors.New("column \"status\" does not exist")).Once()
+	buffer := NewStatusBuffer(repo, StatusBufferOptions{Capacity: 10})
+
+	controller := &VMStatusController{
+		Client:       fake.NewClientBuilder().WithScheme(scheme).WithObjects(vm).Build(),
+		Scheme:       scheme,
+		VMRepo:       repo,
+		VAppRepo:     new(MockVAppRepository),
+		VDCRepo:      new(MockVDCRepository),
+		Recorder:     &MockEventRecorder{},
+		StatusBuffer: buffer,
+	}
+
+	// The reconcile fails so it is retried, and nothing is buffered
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "test-vm"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	assert.Error(t, err)
+	assert.Zero(t, buffer.Len())
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// StorageAlertWebhook posts storage alerts as JSON to an HTTP endpoint
+type StorageAlertWebhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewStorageAlertWebhook creates a webhook notifier for the given URL
+func NewStorageAlertWebhook(url string, timeout time.Duration) *StorageAlertWebhook {
+	return &StorageAlertWebhook{
+		URL:    url,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// NotifyStorageAlert posts the alert, treating any non-2xx response as a failure
+func (w *StorageAlertWebhook) NotifyStorageAlert(ctx context.Context, alert StorageAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode storage alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// vdcNamespaceLabel marks namespaces created for VDCs
+const vdcNamespaceLabel = "ssvirt.io/vdc-id"
+
+const bytesPerMB = 1024 * 1024
+
+// StorageUsageRepositoryInterface defines the VDC repository operations used to
+// track storage usage
+type StorageUsageRepositoryInterface interface {
+	GetByNamespace(ctx context.Context, namespaceName string) (*models.VDC, error)
+	UpdateStorageUsage(ctx context.Context, vdcID string, usedMB map[string]int64) ([]models.VDCStorageProfile, error)
+	SetStorageAlertLevel(ctx context.Context, profileID, level string) error
+}
+
+// StorageAlert describes a VDC storage profile whose usage crossed an alert threshold
+type StorageAlert struct {
+	VDCID          string    `json:"vdcId"`
+	VDCName        string    `json:"vdcName"`
+	OrganizationID string    `json:"organizationId"`
+	Namespace      string    `json:"namespace"`
+	StorageProfile string    `json:"storageProfile"`
+	Level          string    `json:"level"`
+	UsedMB         int64     `json:"usedMB"`
+	LimitMB        int64     `json:"limitMB"`
+	UsagePercent   int       `json:"usagePercent"`
+	Threshold      int       `json:"threshold"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// StorageAlertNotifier delivers storage alerts outside the cluster
+type StorageAlertNotifier interface {
+	NotifyStorageAlert(ctx context.Context, alert StorageAlert) error
+}
+
+// StorageUsageController totals the storage requested by PersistentVolumeClaims
+// in each VDC namespace per storage class, records it as the usage of the VDC's
+// storage profiles and raises an alert when a profile's usage crosses the VDC's
+// warning or critical threshold. Each threshold alerts once until usage drops
+// back below it.
+type StorageUsageController struct {
+	client.Client
+	VDCRepo  StorageUsageRepositoryInterface
+	Recorder record.EventRecorder
+	Notifier StorageAlertNotifier
+}
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile recomputes the storage usage of the VDC owning a namespace
+func (r *StorageUsageController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Name)
+
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: req.Name}, &namespace); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	vdc, err := r.VDCRepo.GetByNamespace(ctx, namespace.Name)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to look up VDC for namespace %s: %w", namespace.Name, err)
+	}
+	if vdc == nil {
+		return ctrl.Result{}, nil
+	}
+
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(namespace.Name)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list PersistentVolumeClaims: %w", err)
+	}
+	profiles, err := r.VDCRepo.UpdateStorageUsage(ctx, vdc.ID, storageUsageByClass(pvcs.Items))
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update storage usage: %w", err)
+	}
+
+	thresholds := vdc.StorageAlertThresholds()
+	for i := range profiles {
+		profile := &profiles[i]
+		level := profile.UsageAlert(thresholds.Warning, thresholds.Critical)
+		if level == profile.AlertLevel {
+			continue
+		}
+
+		if alertSeverity(level) > alertSeverity(profile.AlertLevel) {
+			threshold := thresholds.Warning
+			if level == models.StorageAlertCritical {
+				threshold = thresholds.Critical
+			}
+			alert := StorageAlert{
+				VDCID:          vdc.ID,
+				VDCName:        vdc.Name,
+				OrganizationID: vdc.OrganizationID,
+				Namespace:      namespace.Name,
+				StorageProfile: profile.Name,
+				Level:          level,
+				UsedMB:         profile.UsedMB,
+				LimitMB:        profile.LimitMB,
+				UsagePercent:   profile.UsagePercent(),
+				Threshold:      threshold,
+				Timestamp:      time.Now().UTC(),
+			}
+			if err := r.raiseAlert(ctx, &namespace, alert); err != nil {
+				return ctrl.Result{}, err
+			}
+			logger.Info("Storage usage alert raised", "vdc", vdc.ID, "storageProfile", profile.Name, "level", level, "usagePercent", alert.UsagePercent)
+		}
+
+		if err := r.VDCRepo.SetStorageAlertLevel(ctx, profile.ID, level); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to record storage alert level: %w", err)
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// raiseAlert emits a warning event on the VDC namespace and notifies the webhook
+func (r *StorageUsageController) raiseAlert(ctx context.Context, namespace *corev1.Namespace, alert StorageAlert) error {
+	if r.Recorder != nil {
+		reason := "StorageUsageWarning"
+		if alert.Level == models.StorageAlertCritical {
+			reason = "StorageUsageCritical"
+		}
+		r.Recorder.Eventf(namespace, corev1.EventTypeWarning, reason,
+			"Storage profile %s is at %d%% of its %d MB limit (threshold %d%%)",
+			alert.StorageProfile, alert.UsagePercent, alert.LimitMB, alert.Threshold)
+	}
+	if r.Notifier != nil {
+		if err := r.Notifier.NotifyStorageAlert(ctx, alert); err != nil {
+			return fmt.Errorf("failed to deliver storage alert: %w", err)
+		}
+	}
+	return nil
+}
+
+// storageUsageByClass totals the storage requested by PVCs per storage class, in
+// MB rounded up. PVCs without a storage class are not counted; the
+// DefaultStorageClass admission plugin assigns one to PVCs that omit it.
+func storageUsageByClass(pvcs []corev1.PersistentVolumeClaim) map[string]int64 {
+	bytes := make(map[string]int64)
+	for _, pvc := range pvcs {
+		if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+			continue
+		}
+		request, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if !ok {
+			continue
+		}
+		bytes[*pvc.Spec.StorageClassName] += request.Value()
+	}
+
+	usedMB := make(map[string]int64, len(bytes))
+	for class, total := range bytes {
+		usedMB[class] = (total + bytesPerMB - 1) / bytesPerMB
+	}
+	return usedMB
+}
+
+// alertSeverity orders storage alert levels
+func alertSeverity(level string) int {
+	switch level {
+	case models.StorageAlertCritical:
+		return 2
+	case models.StorageAlertWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SetupStorageUsageController sets up the storage usage controller. notifier may
+// be nil, in which case alerts are only recorded as events.
+func SetupStorageUsageController(mgr ctrl.Manager, vdcRepo StorageUsageRepositoryInterface, notifier StorageAlertNotifier, opts ControllerOptions) error {
+	controller := &StorageUsageController{
+		Client:   mgr.GetClient(),
+		VDCRepo:  vdcRepo,
+		Recorder: mgr.GetEventRecorderFor("storage-usage-controller"),
+		Notifier: notifier,
+	}
+
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named(StorageUsageControllerName).
+		WithOptions(opts.controllerOptions(StorageUsageControllerName)).
+		For(&corev1.Namespace{}, builder.WithPredicates(predicate.NewPredicateFuncs(isVDCNamespace))).
+		Watches(&corev1.PersistentVolumeClaim{},
+			handler.EnqueueRequestsFromMapFunc(mapToNamespace)).
+		Complete(opts.wrap(controller))
+	if err != nil {
+		return fmt.Errorf("failed to setup StorageUsageController: %w", err)
+	}
+	return nil
+}
+
+// isVDCNamespace reports whether a namespace was created for a VDC
+func isVDCNamespace(obj client.Object) bool {
+	_, ok := obj.GetLabels()[vdcNamespaceLabel]
+	return ok
+}
+
+// mapToNamespace enqueues the namespace of a changed namespaced object
+func mapToNamespace(_ context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.GetNamespace()}}}
+}
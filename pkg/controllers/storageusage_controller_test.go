@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// fakeStorageUsageRepo keeps storage profiles for a single VDC in memory
+type fakeStorageUsageRepo struct {
+	vdc      *models.VDC
+	profiles map[string]*models.VDCStorageProfile
+}
+
+func (f *fakeStorageUsageRepo) GetByNamespace(_ context.Context, namespace string) (*models.VDC, error) {
+	if f.vdc.Namespace != namespace {
+		return nil, nil
+	}
+	return f.vdc, nil
+}
+
+func (f *fakeStorageUsageRepo) UpdateStorageUsage(_ context.Context, _ string, usedMB map[string]int64) ([]models.VDCStorageProfile, error) {
+	for name, profile := range f.profiles {
+		profile.UsedMB = usedMB[name]
+	}
+	for name, used := range usedMB {
+		if _, ok := f.profiles[name]; !ok {
+			f.profiles[name] = &models.VDCStorageProfile{ID: name, Name: name, UsedMB: used}
+		}
+	}
+	var profiles []models.VDCStorageProfile
+	for _, profile := range f.profiles {
+		profiles = append(profiles, *profile)
+	}
+	return profiles, nil
+}
+
+func (f *fakeStorageUsageRepo) SetStorageAlertLevel(_ context.Context, profileID, level string) error {
+	f.profiles[profileID].AlertLevel = level
+	return nil
+}
+
+type recordingNotifier struct {
+	alerts []StorageAlert
+}
+
+func (n *recordingNotifier) NotifyStorageAlert(_ context.Context, alert StorageAlert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func storagePVC(name, class, size string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "vdc-ns"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &class,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func TestStorageUsageByClass(t *testing.T) {
+	unclassed := storagePVC("unclassed", "", "5Gi")
+	unclassed.Spec.StorageClassName = nil
+
+	usage := storageUsageByClass([]corev1.PersistentVolumeClaim{
+		*storagePVC("a", "fast", "1Gi"),
+		*storagePVC("b", "fast", "512Mi"),
+		*storagePVC("c", "slow", "1"),
+		*unclassed,
+	})
+	assert.Equal(t, map[string]int64{"fast": 1536, "slow": 1}, usage)
+}
+
+func TestStorageUsageController_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "vdc-ns",
+		Labels: map[string]string{vdcNamespaceLabel: "1234"},
+	}}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(namespace, storagePVC("root", "fast", "850Mi")).
+		Build()
+
+	repo := &fakeStorageUsageRepo{
+		vdc: &models.VDC{ID: "urn:vcloud:vdc:1234", Name: "vdc", Namespace: "vdc-ns"},
+		profiles: map[string]*models.VDCStorageProfile{
+			"fast": {ID: "fast", Name: "fast", LimitMB: 1000},
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	notifier := &recordingNotifier{}
+	controller := &StorageUsageController{Client: fakeClient, VDCRepo: repo, Recorder: recorder, Notifier: notifier}
+
+	reconcile := func() {
+		_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "vdc-ns"}})
+		require.NoError(t, err)
+	}
+	addPVC := func(pvc *corev1.PersistentVolumeClaim) {
+		require.NoError(t, fakeClient.Create(context.Background(), pvc))
+	}
+
+	t.Run("warning threshold raises one alert", func(t *testing.T) {
+		reconcile()
+		assert.Equal(t, int64(850), repo.profiles["fast"].UsedMB)
+		assert.Equal(t, models.StorageAlertWarning, repo.profiles["fast"].AlertLevel)
+		require.Len(t, notifier.alerts, 1)
+		assert.Equal(t, models.StorageAlertWarning, notifier.alerts[0].Level)
+		assert.Equal(t, 85, notifier.alerts[0].UsagePercent)
+		assert.Equal(t, 80, notifier.alerts[0].Threshold)
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, "StorageUsageWarning")
+
+		reconcile()
+		assert.Len(t, notifier.alerts, 1)
+	})
+
+	t.Run("critical threshold escalates", func(t *testing.T) {
+		addPVC(storagePVC("data", "fast", "100Mi"))
+		reconcile()
+		require.Len(t, notifier.alerts, 2)
+		assert.Equal(t, models.StorageAlertCritical, notifier.alerts[1].Level)
+		assert.Contains(t, <-recorder.Events, "StorageUsageCritical")
+	})
+
+	t.Run("dropping below thresholds resets the alert", func(t *testing.T) {
+		require.NoError(t, fakeClient.DeleteAllOf(context.Background(), &corev1.PersistentVolumeClaim{}, client.InNamespace("vdc-ns")))
+		reconcile()
+		assert.Equal(t, "", repo.profiles["fast"].AlertLevel)
+		assert.Len(t, notifier.alerts, 2)
+
+		addPVC(storagePVC("again", "fast", "900Mi"))
+		reconcile()
+		assert.Len(t, notifier.alerts, 3)
+	})
+
+	t.Run("unlimited profiles never alert", func(t *testing.T) {
+		addPVC(storagePVC("bulk", "archive", "100Gi"))
+		reconcile()
+		assert.Equal(t, int64(102400), repo.profiles["archive"].UsedMB)
+		assert.Len(t, notifier.alerts, 3)
+	})
+
+	t.Run("only VDC namespaces are watched", func(t *testing.T) {
+		assert.True(t, isVDCNamespace(namespace))
+		assert.False(t, isVDCNamespace(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}}))
+	})
+}
+
+func TestStorageAlertWebhook(t *testing.T) {
+	var received StorageAlert
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook := NewStorageAlertWebhook(server.URL, time.Second)
+	alert := StorageAlert{VDCID: "urn:vcloud:vdc:1234", StorageProfile: "fast", Level: models.StorageAlertCritical, UsagePercent: 97}
+	require.NoError(t, webhook.NotifyStorageAlert(context.Background(), alert))
+	assert.Equal(t, alert, received)
+
+	status = http.StatusInternalServerError
+	assert.ErrorContains(t, webhook.NotifyStorageAlert(context.Background(), alert), "status 500")
+}
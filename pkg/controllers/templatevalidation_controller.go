@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// TemplateValidationController validates the Templates in the catalog namespace
+// and records the result on each Template as annotations, with a warning event
+// when a Template becomes invalid. Cluster operators see broken templates with
+// kubectl before users hit instantiation failures; the API server validates
+// templates independently when listing catalog items.
+type TemplateValidationController struct {
+	client.Client
+	Namespace string
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=template.openshift.io,resources=templates,verbs=get;list;watch;update;patch
+
+// Reconcile validates a catalog Template and updates its validation annotations
+func (r *TemplateValidationController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var template templatev1.Template
+	if err := r.Get(ctx, req.NamespacedName, &template); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	problems := services.ValidateTemplate(&template)
+	status := models.CatalogItemValidationValid
+	if len(problems) > 0 {
+		status = models.CatalogItemValidationInvalid
+	}
+	message := strings.Join(problems, "; ")
+
+	if template.Annotations[services.ValidationStatusAnnotation] == status &&
+		template.Annotations[services.ValidationErrorsAnnotation] == message {
+		return ctrl.Result{}, nil
+	}
+
+	patch := client.MergeFrom(template.DeepCopy())
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	template.Annotations[services.ValidationStatusAnnotation] = status
+	if message != "" {
+		template.Annotations[services.ValidationErrorsAnnotation] = message
+	} else {
+		delete(template.Annotations, services.ValidationErrorsAnnotation)
+	}
+	if err := r.Patch(ctx, &template, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update template validation annotations: %w", err)
+	}
+
+	logger.Info("Validated catalog template", "template", template.Name, "status", status, "problems", problems)
+	if status == models.CatalogItemValidationInvalid && r.Recorder != nil {
+		r.Recorder.Event(&template, "Warning", "TemplateInvalid",
+			fmt.Sprintf("Template cannot be instantiated from the catalog: %s", message))
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupTemplateValidationController sets up the template validation controller
+// for catalog Templates in the given namespace
+func SetupTemplateValidationController(mgr ctrl.Manager, namespace string, opts ControllerOptions) error {
+	controller := &TemplateValidationController{
+		Client:    mgr.GetClient(),
+		Namespace: namespace,
+		Recorder:  mgr.GetEventRecorderFor("template-validation-controller"),
+	}
+
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named(TemplateValidationControllerName).
+		WithOptions(opts.controllerOptions(TemplateValidationControllerName)).
+		For(&templatev1.Template{}).
+		WithEventFilter(predicate.NewPredicateFuncs(controller.isCatalogTemplate)).
+		Complete(opts.wrap(controller))
+	if err != nil {
+		return fmt.Errorf("failed to setup TemplateValidationController: %w", err)
+	}
+	return nil
+}
+
+// isCatalogTemplate reports whether an object is a Template opted into the catalog
+func (r *TemplateValidationController) isCatalogTemplate(obj client.Object) bool {
+	if obj.GetNamespace() != r.Namespace {
+		return false
+	}
+	_, ok := obj.GetLabels()[services.TemplateVersionLabel]
+	return ok
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func catalogTemplate(name string, params []templatev1.Parameter, objects ...string) *templatev1.Template {
+	template := &templatev1.Template{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "openshift",
+			Labels:    map[string]string{services.TemplateVersionLabel: "v1"},
+			Annotations: map[string]string{
+				services.ContainerDisksAnnotation: "quay.io/containerdisks/fedora:latest",
+				"description":                     "Fedora VM",
+			},
+		},
+		Parameters: params,
+	}
+	for _, obj := range objects {
+		template.Objects = append(template.Objects, runtime.RawExtension{Raw: []byte(obj)})
+	}
+	return template
+}
+
+func TestValidateTemplate(t *testing.T) {
+	vm := `{"apiVersion":"kubevirt.io/v1","kind":"VirtualMachine","metadata":{"name":"${NAME}"}}`
+
+	tests := []struct {
+		name     string
+		template *templatev1.Template
+		expected []string
+	}{
+		{
+			name:     "valid template",
+			template: catalogTemplate("fedora", []templatev1.Parameter{{Name: "NAME", Generate: "expression", From: "fedora-[a-z0-9]{8}"}}, vm),
+		},
+		{
+			name:     "no virtual machines",
+			template: catalogTemplate("secret-only", nil, `{"apiVersion":"v1","kind":"Secret"}`),
+			expected: []string{"template contains no VirtualMachine objects"},
+		},
+		{
+			name:     "undeclared parameter",
+			template: catalogTemplate("fedora", nil, vm),
+			expected: []string{"parameter NAME is referenced but not declared"},
+		},
+		{
+			name:     "required parameter without default",
+			template: catalogTemplate("fedora", []templatev1.Parameter{{Name: "NAME", Required: true}}, vm),
+			expected: []string{"required parameter NAME has no default value or generator"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, services.ValidateTemplate(tt.template))
+		})
+	}
+
+	t.Run("missing annotations", func(t *testing.T) {
+		template := catalogTemplate("fedora", []templatev1.Parameter{{Name: "NAME", Value: "fedora"}}, vm)
+		template.Annotations = nil
+		assert.Equal(t, []string{
+			"missing annotation " + services.ContainerDisksAnnotation,
+			"missing annotation description",
+		}, services.ValidateTemplate(template))
+	})
+}
+
+func TestTemplateValidationController_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, templatev1.AddToScheme(scheme))
+
+	valid := catalogTemplate("valid", []templatev1.Parameter{{Name: "NAME", Value: "vm"}},
+		`{"kind":"VirtualMachine","metadata":{"name":"${NAME}"}}`)
+	invalid := catalogTemplate("invalid", nil, `{"kind":"Service"}`)
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(valid, invalid).Build()
+	recorder := record.NewFakeRecorder(10)
+	controller := &TemplateValidationController{Client: fakeClient, Namespace: "openshift", Recorder: recorder}
+
+	reconcile := func(name string) *templatev1.Template {
+		key := types.NamespacedName{Namespace: "openshift", Name: name}
+		_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var template templatev1.Template
+		require.NoError(t, fakeClient.Get(context.Background(), key, &template))
+		return &template
+	}
+
+	t.Run("valid template is annotated valid", func(t *testing.T) {
+		template := reconcile("valid")
+		assert.Equal(t, models.CatalogItemValidationValid, template.Annotations[services.ValidationStatusAnnotation])
+		assert.NotContains(t, template.Annotations, services.ValidationErrorsAnnotation)
+		assert.Empty(t, recorder.Events)
+	})
+
+	t.Run("invalid template is annotated with its problems", func(t *testing.T) {
+		template := reconcile("invalid")
+		assert.Equal(t, models.CatalogItemValidationInvalid, template.Annotations[services.ValidationStatusAnnotation])
+		assert.Equal(t, "template contains no VirtualMachine objects", template.Annotations[services.ValidationErrorsAnnotation])
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, "TemplateInvalid")
+	})
+
+	t.Run("unchanged template is not updated again", func(t *testing.T) {
+		before := reconcile("invalid").ResourceVersion
+		assert.Equal(t, before, reconcile("invalid").ResourceVersion)
+		assert.Empty(t, recorder.Events)
+	})
+
+	t.Run("only catalog templates are watched", func(t *testing.T) {
+		assert.True(t, controller.isCatalogTemplate(valid))
+
+		other := valid.DeepCopy()
+		other.Namespace = "tenant"
+		assert.False(t, controller.isCatalogTemplate(other))
+
+		unlabeled := valid.DeepCopy()
+		unlabeled.Labels = nil
+		assert.False(t, controller.isCatalogTemplate(unlabeled))
+	})
+}
@@ -3,6 +3,8 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	templatev1 "github.com/openshift/api/template/v1"
@@ -15,12 +17,15 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
 // VAppStatusRepositoryInterface defines the interface for VApp repository operations
 type VAppStatusRepositoryInterface interface {
 	GetByNameInVDC(ctx context.Context, vdcID, name string) (*models.VApp, error)
 	UpdateStatus(ctx context.Context, vappID string, status string) error
+	UpdateHealthState(ctx context.Context, vappID string, healthState string) error
+	UpdateConditions(ctx context.Context, vappID string, conditions []models.Condition) error
 }
 
 // VMStatusRepositoryInterface defines the interface for VM repository operations
@@ -33,6 +38,13 @@ type VDCStatusRepositoryInterface interface {
 	GetByNamespace(ctx context.Context, namespaceName string) (*models.VDC, error)
 }
 
+// ProvisioningAlerts sends a notification when a vApp has been instantiating
+// for longer than After. A nil Notifier or zero After disables the alert.
+type ProvisioningAlerts struct {
+	Notifier services.Notifier
+	After    time.Duration
+}
+
 // VAppStatusController reconciles vApp status based on TemplateInstance and VM states
 type VAppStatusController struct {
 	client.Client
@@ -40,6 +52,10 @@ type VAppStatusController struct {
 	VAppRepo VAppStatusRepositoryInterface
 	VMRepo   VMStatusRepositoryInterface
 	VDCRepo  VDCStatusRepositoryInterface
+	Alerts   ProvisioningAlerts
+
+	// stuckNotified holds the IDs of vApps already reported as stuck
+	stuckNotified sync.Map
 }
 
 // VAppStatusEvaluator evaluates vApp status based on multiple inputs
@@ -47,6 +63,7 @@ type VAppStatusEvaluator struct {
 	templateInstanceReady  bool
 	templateInstanceFailed bool
 	vmStatuses             []string
+	vmHealthStates         []string
 	hasVMs                 bool
 }
 
@@ -83,6 +100,29 @@ func (e *VAppStatusEvaluator) EvaluateStatus() string {
 	return models.VAppStatusDeployed
 }
 
+// EvaluateHealthState determines the vApp health state from its VMs: DEGRADED if
+// any VM is degraded, HEALTHY if all VMs are healthy, and UNKNOWN otherwise
+func (e *VAppStatusEvaluator) EvaluateHealthState() string {
+	if !e.hasVMs {
+		return models.HealthStateUnknown
+	}
+
+	healthy := true
+	for _, healthState := range e.vmHealthStates {
+		switch healthState {
+		case models.HealthStateDegraded:
+			return models.HealthStateDegraded
+		case models.HealthStateHealthy:
+		default:
+			healthy = false
+		}
+	}
+	if !healthy {
+		return models.HealthStateUnknown
+	}
+	return models.HealthStateHealthy
+}
+
 // +kubebuilder:rbac:groups=template.openshift.io,resources=templateinstances,verbs=get;list;watch
 // +kubebuilder:rbac:groups=template.openshift.io,resources=templateinstances/status,verbs=get
 // +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachines,verbs=get;list;watch
@@ -107,6 +147,13 @@ func (r *VAppStatusController) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
+	// A terminating TemplateInstance is being replaced by a retry or removed with
+	// its vApp, so it no longer decides the vApp's status
+	if !templateInstance.DeletionTimestamp.IsZero() {
+		logger.Info("TemplateInstance is being deleted, ignoring", "namespacedName", req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
 	logger.Info("Processing TemplateInstance", "name", templateInstance.Name, "namespace", templateInstance.Namespace)
 
 	// Find corresponding vApp by name and namespace
@@ -137,7 +184,7 @@ func (r *VAppStatusController) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	// Evaluate new status
-	newStatus := r.evaluateVAppStatus(ctx, &templateInstance, vapp, logger)
+	newStatus, newHealthState := r.evaluateVAppStatus(ctx, &templateInstance, vapp, logger)
 	logger.Info("Evaluated vApp status", "vapp", vapp.ID, "currentStatus", vapp.Status, "newStatus", newStatus)
 
 	// Update status if changed
@@ -155,11 +202,68 @@ func (r *VAppStatusController) Reconcile(ctx context.Context, req ctrl.Request)
 		logger.Info("vApp status unchanged", "vapp", vapp.ID, "status", vapp.Status)
 	}
 
-	return ctrl.Result{}, nil
+	if vapp.GetHealthState() != newHealthState {
+		if err := r.VAppRepo.UpdateHealthState(ctx, vapp.ID, newHealthState); err != nil {
+			logger.Error(err, "Failed to update vApp health state", "vapp", vapp.ID, "healthState", newHealthState)
+			return ctrl.Result{}, err
+		}
+		logger.Info("Updated vApp health state", "vapp", vapp.ID, "oldHealthState", vapp.GetHealthState(), "newHealthState", newHealthState)
+	}
+
+	if conditions, changed := models.SetCondition(vapp.Conditions(), templateInstantiatedCondition(&templateInstance), time.Now()); changed {
+		if err := r.VAppRepo.UpdateConditions(ctx, vapp.ID, conditions); err != nil {
+			logger.Error(err, "Failed to update vApp conditions", "vapp", vapp.ID)
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: r.alertStuckProvisioning(ctx, vapp, vdc, newStatus, logger)}, nil
+}
+
+// alertStuckProvisioning notifies once when a vApp has been instantiating for
+// longer than the alert threshold. It returns how long to wait before checking
+// again, or 0 when no further check is needed.
+func (r *VAppStatusController) alertStuckProvisioning(ctx context.Context, vapp *models.VApp, vdc *models.VDC, status string, logger logr.Logger) time.Duration {
+	if r.Alerts.Notifier == nil || r.Alerts.After <= 0 {
+		return 0
+	}
+	if status != models.VAppStatusInstantiating {
+		r.stuckNotified.Delete(vapp.ID)
+		return 0
+	}
+	age := time.Since(vapp.CreatedAt)
+	if age < r.Alerts.After {
+		return r.Alerts.After - age
+	}
+	if _, notified := r.stuckNotified.LoadOrStore(vapp.ID, struct{}{}); notified {
+		return 0
+	}
+
+	err := r.Alerts.Notifier.Notify(ctx, services.Notification{
+		Event:          services.NotificationProvisioningStuck,
+		OrganizationID: vdc.OrganizationID,
+		Data: map[string]interface{}{
+			"VAppID":           vapp.ID,
+			"VAppName":         vapp.Name,
+			"VDCName":          vdc.Name,
+			"Namespace":        vdc.Namespace,
+			"TemplateInstance": vapp.GetTemplateInstanceName(),
+			"CreatedAt":        vapp.CreatedAt,
+			"Duration":         age.Round(time.Minute).String(),
+		},
+	})
+	if err != nil {
+		// Retry the notification later rather than failing the reconcile
+		logger.Error(err, "Failed to send stuck provisioning notification", "vapp", vapp.ID)
+		r.stuckNotified.Delete(vapp.ID)
+		return time.Minute
+	}
+	logger.Info("Sent stuck provisioning notification", "vapp", vapp.ID, "age", age)
+	return 0
 }
 
-// evaluateVAppStatus evaluates the appropriate vApp status
-func (r *VAppStatusController) evaluateVAppStatus(ctx context.Context, templateInstance *templatev1.TemplateInstance, vapp *models.VApp, logger logr.Logger) string {
+// evaluateVAppStatus evaluates the appropriate vApp status and health state
+func (r *VAppStatusController) evaluateVAppStatus(ctx context.Context, templateInstance *templatev1.TemplateInstance, vapp *models.VApp, logger logr.Logger) (string, string) {
 	evaluator := &VAppStatusEvaluator{}
 
 	// Evaluate TemplateInstance status
@@ -170,16 +274,18 @@ func (r *VAppStatusController) evaluateVAppStatus(ctx context.Context, templateI
 	if err != nil {
 		logger.Error(err, "Failed to get VMs for vApp", "vapp", vapp.ID)
 		// If we can't get VMs, keep current status
-		return vapp.Status
+		return vapp.Status, vapp.GetHealthState()
 	}
 
 	evaluator.hasVMs = len(vms) > 0
 	evaluator.vmStatuses = make([]string, len(vms))
+	evaluator.vmHealthStates = make([]string, len(vms))
 	for i, vm := range vms {
 		evaluator.vmStatuses[i] = vm.Status
+		evaluator.vmHealthStates[i] = vm.GetHealthState()
 	}
 
-	return evaluator.EvaluateStatus()
+	return evaluator.EvaluateStatus(), evaluator.EvaluateHealthState()
 }
 
 // evaluateTemplateInstanceStatus checks TemplateInstance conditions
@@ -199,13 +305,56 @@ func (r *VAppStatusController) evaluateTemplateInstanceStatus(templateInstance *
 	return ready, failed
 }
 
+// templateInstantiatedCondition reports whether a TemplateInstance has created
+// its objects, carrying over the reason and message of its Ready or
+// InstantiateFailure condition
+func templateInstantiatedCondition(templateInstance *templatev1.TemplateInstance) models.Condition {
+	for _, condition := range templateInstance.Status.Conditions {
+		if condition.Status != "True" {
+			continue
+		}
+		switch condition.Type {
+		case templatev1.TemplateInstanceInstantiateFailure:
+			return models.Condition{
+				Type:    models.ConditionTemplateInstantiated,
+				Status:  models.ConditionFalse,
+				Reason:  conditionReason(condition.Reason, "InstantiateFailure"),
+				Message: condition.Message,
+			}
+		case templatev1.TemplateInstanceReady:
+			return models.Condition{
+				Type:    models.ConditionTemplateInstantiated,
+				Status:  models.ConditionTrue,
+				Reason:  conditionReason(condition.Reason, "Created"),
+				Message: condition.Message,
+			}
+		}
+	}
+	return models.Condition{
+		Type:    models.ConditionTemplateInstantiated,
+		Status:  models.ConditionUnknown,
+		Reason:  "Instantiating",
+		Message: "Waiting for the TemplateInstance to create its objects",
+	}
+}
+
+// conditionReason returns reason, or fallback when it is empty
+func conditionReason(reason, fallback string) string {
+	if reason == "" {
+		return fallback
+	}
+	return reason
+}
+
 // SetupWithManager sets up the controller with the Manager
-func (r *VAppStatusController) SetupWithManager(mgr ctrl.Manager) error {
+func (r *VAppStatusController) SetupWithManager(mgr ctrl.Manager, opts ControllerOptions) error {
 	// Watch TemplateInstance resources and VirtualMachine resources they own
 	err := ctrl.NewControllerManagedBy(mgr).
+		Named(VAppStatusControllerName).
+		WithOptions(opts.controllerOptions(VAppStatusControllerName)).
 		For(&templatev1.TemplateInstance{}).
 		Owns(&kubevirtv1.VirtualMachine{}).
-		Complete(r)
+		Complete(opts.wrap(r))
 	if err != nil {
 		return fmt.Errorf("failed to setup VAppStatusController: %w", err)
 	}
@@ -214,12 +363,13 @@ func (r *VAppStatusController) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 // SetupVAppStatusController sets up the VApp status controller with the manager
-func SetupVAppStatusController(mgr ctrl.Manager, vappRepo VAppStatusRepositoryInterface, vmRepo VMStatusRepositoryInterface, vdcRepo VDCStatusRepositoryInterface) error {
+func SetupVAppStatusController(mgr ctrl.Manager, vappRepo VAppStatusRepositoryInterface, vmRepo VMStatusRepositoryInterface, vdcRepo VDCStatusRepositoryInterface, alerts ProvisioningAlerts, opts ControllerOptions) error {
 	return (&VAppStatusController{
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
 		VAppRepo: vappRepo,
 		VMRepo:   vmRepo,
 		VDCRepo:  vdcRepo,
-	}).SetupWithManager(mgr)
+		Alerts:   alerts,
+	}).SetupWithManager(mgr, opts)
 }
@@ -1,11 +1,23 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
+	templatev1 "github.com/openshift/api/template/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
 func TestVAppStatusEvaluator_EvaluateStatus(t *testing.T) {
@@ -130,6 +142,65 @@ func TestVAppStatusEvaluator_EvaluateStatus(t *testing.T) {
 	}
 }
 
+func TestVAppStatusEvaluator_EvaluateHealthState(t *testing.T) {
+	tests := []struct {
+		name           string
+		vmHealthStates []string
+		expected       string
+	}{
+		{name: "no VMs", vmHealthStates: nil, expected: models.HealthStateUnknown},
+		{name: "all VMs healthy", vmHealthStates: []string{models.HealthStateHealthy, models.HealthStateHealthy}, expected: models.HealthStateHealthy},
+		{name: "one VM degraded", vmHealthStates: []string{models.HealthStateHealthy, models.HealthStateDegraded, models.HealthStateUnknown}, expected: models.HealthStateDegraded},
+		{name: "one VM unknown", vmHealthStates: []string{models.HealthStateHealthy, models.HealthStateUnknown}, expected: models.HealthStateUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := &VAppStatusEvaluator{
+				vmHealthStates: tt.vmHealthStates,
+				hasVMs:         len(tt.vmHealthStates) > 0,
+			}
+			assert.Equal(t, tt.expected, evaluator.EvaluateHealthState())
+		})
+	}
+}
+
+func TestTemplateInstantiatedCondition(t *testing.T) {
+	tests := []struct {
+		name           string
+		conditions     []templatev1.TemplateInstanceCondition
+		expectedStatus string
+		expectedReason string
+	}{
+		{name: "no conditions", expectedStatus: models.ConditionUnknown, expectedReason: "Instantiating"},
+		{
+			name:           "ready",
+			conditions:     []templatev1.TemplateInstanceCondition{{Type: templatev1.TemplateInstanceReady, Status: "True"}},
+			expectedStatus: models.ConditionTrue,
+			expectedReason: "Created",
+		},
+		{
+			name: "failed",
+			conditions: []templatev1.TemplateInstanceCondition{
+				{Type: templatev1.TemplateInstanceReady, Status: "False"},
+				{Type: templatev1.TemplateInstanceInstantiateFailure, Status: "True", Reason: "CreateError", Message: "quota exceeded"},
+			},
+			expectedStatus: models.ConditionFalse,
+			expectedReason: "CreateError",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templateInstance := &templatev1.TemplateInstance{Status: templatev1.TemplateInstanceStatus{Conditions: tt.conditions}}
+			condition := templateInstantiatedCondition(templateInstance)
+			assert.Equal(t, models.ConditionTemplateInstantiated, condition.Type)
+			assert.Equal(t, tt.expectedStatus, condition.Status)
+			assert.Equal(t, tt.expectedReason, condition.Reason)
+		})
+	}
+}
+
 func TestIsValidVAppStatus(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -195,3 +266,94 @@ func TestIsValidVAppStatus(t *testing.T) {
 		})
 	}
 }
+
+type recordingMailer struct {
+	notifications []services.Notification
+	err           error
+}
+
+func (m *recordingMailer) Notify(_ context.Context, notification services.Notification) error {
+	m.notifications = append(m.notifications, notification)
+	return m.err
+}
+
+func TestVAppStatusController_AlertStuckProvisioning(t *testing.T) {
+	ctx := context.Background()
+	vdc := &models.VDC{ID: "urn:vcloud:vdc:1", Name: "dev", OrganizationID: "urn:vcloud:org:1", Namespace: "vdc-dev"}
+	mailer := &recordingMailer{}
+	r := &VAppStatusController{Alerts: ProvisioningAlerts{Notifier: mailer, After: 30 * time.Minute}}
+
+	t.Run("Waits until the threshold", func(t *testing.T) {
+		vapp := &models.VApp{ID: "urn:vcloud:vapp:new", Name: "new", CreatedAt: time.Now().Add(-10 * time.Minute)}
+		requeue := r.alertStuckProvisioning(ctx, vapp, vdc, models.VAppStatusInstantiating, logr.Discard())
+		assert.InDelta(t, (20 * time.Minute).Seconds(), requeue.Seconds(), 5)
+		assert.Empty(t, mailer.notifications)
+	})
+
+	vapp := &models.VApp{ID: "urn:vcloud:vapp:stuck", Name: "stuck", CreatedAt: time.Now().Add(-time.Hour)}
+	t.Run("Notifies once when stuck", func(t *testing.T) {
+		assert.Zero(t, r.alertStuckProvisioning(ctx, vapp, vdc, models.VAppStatusInstantiating, logr.Discard()))
+		assert.Zero(t, r.alertStuckProvisioning(ctx, vapp, vdc, models.VAppStatusInstantiating, logr.Discard()))
+		require.Len(t, mailer.notifications, 1)
+
+		notification := mailer.notifications[0]
+		assert.Equal(t, services.NotificationProvisioningStuck, notification.Event)
+		assert.Equal(t, vdc.OrganizationID, notification.OrganizationID)
+		data := notification.Data.(map[string]interface{})
+		assert.Equal(t, "stuck", data["VAppName"])
+		assert.Equal(t, "vdc-dev", data["Namespace"])
+		assert.Equal(t, "1h0m0s", data["Duration"])
+	})
+
+	t.Run("Notifies again after the vApp leaves and re-enters instantiation", func(t *testing.T) {
+		assert.Zero(t, r.alertStuckProvisioning(ctx, vapp, vdc, models.VAppStatusDeployed, logr.Discard()))
+		assert.Zero(t, r.alertStuckProvisioning(ctx, vapp, vdc, models.VAppStatusInstantiating, logr.Discard()))
+		assert.Len(t, mailer.notifications, 2)
+	})
+
+	t.Run("Retries failed notifications", func(t *testing.T) {
+		failing := &recordingMailer{err: errors.New("smtp unavailable")}
+		r := &VAppStatusController{Alerts: ProvisioningAlerts{Notifier: failing, After: 30 * time.Minute}}
+		assert.Equal(t, time.Minute, r.alertStuckProvisioning(ctx, vapp, vdc, models.VAppStatusInstantiating, logr.Discard()))
+		failing.err = nil
+		assert.Zero(t, r.alertStuckProvisioning(ctx, vapp, vdc, models.VAppStatusInstantiating, logr.Discard()))
+		assert.Len(t, failing.notifications, 2)
+	})
+
+	t.Run("Disabled without a notifier", func(t *testing.T) {
+		r := &VAppStatusController{Alerts: ProvisioningAlerts{After: 30 * time.Minute}}
+		assert.Zero(t, r.alertStuckProvisioning(ctx, vapp, vdc, models.VAppStatusInstantiating, logr.Discard()))
+	})
+}
+
+func TestStorageAlertNotifiers(t *testing.T) {
+	mailer := &recordingMailer{err: errors.New("smtp unavailable")}
+	webhook := &recordingNotifier{}
+	notifiers := StorageAlertNotifiers{&StorageAlertMailer{Notifier: mailer}, webhook}
+
+	alert := StorageAlert{VDCName: "dev", OrganizationID: "urn:vcloud:org:1", Level: models.StorageAlertWarning}
+	err := notifiers.NotifyStorageAlert(context.Background(), alert)
+	assert.ErrorContains(t, err, "smtp unavailable")
+
+	// A failing notifier does not stop the others
+	require.Len(t, webhook.alerts, 1)
+	require.Len(t, mailer.notifications, 1)
+	assert.Equal(t, services.NotificationStorageAlert, mailer.notifications[0].Event)
+	assert.Equal(t, "urn:vcloud:org:1", mailer.notifications[0].OrganizationID)
+	assert.Equal(t, alert, mailer.notifications[0].Data)
+}
+
+func TestVAppStatusController_IgnoresTerminatingTemplateInstances(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, templatev1.AddToScheme(scheme))
+	now := metav1.Now()
+	terminating := &templatev1.TemplateInstance{ObjectMeta: metav1.ObjectMeta{
+		Name: "web-app", Namespace: "vdc-dev", DeletionTimestamp: &now, Finalizers: []string{"test"},
+	}}
+
+	// Without repositories, any attempt to update the vApp would panic
+	r := &VAppStatusController{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(terminating).Build()}
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "web-app", Namespace: "vdc-dev"}})
+	require.NoError(t, err)
+	assert.Zero(t, result)
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// Names of the resources SSVirt creates in each VDC namespace
+const (
+	vdcQuotaName   = "vdc-quota"
+	vdcNetworkName = "vdc-network"
+)
+
+// vdcNetworkPollInterval is how often a VDC whose network is not ready is
+// checked again; UserDefinedNetworks are read rather than watched, since the
+// resource is not installed on every cluster
+const vdcNetworkPollInterval = 30 * time.Second
+
+// userDefinedNetworkGVK identifies OVN-Kubernetes UserDefinedNetworks
+var userDefinedNetworkGVK = schema.GroupVersionKind{Group: "k8s.ovn.org", Version: "v1", Kind: "UserDefinedNetwork"}
+
+// VDCConditionsRepositoryInterface defines the VDC repository operations used
+// to maintain VDC conditions
+type VDCConditionsRepositoryInterface interface {
+	GetByNamespace(ctx context.Context, namespaceName string) (*models.VDC, error)
+	UpdateConditions(ctx context.Context, vdcID string, conditions []models.Condition) error
+}
+
+// VDCConditionsController records the readiness of each VDC's namespace,
+// ResourceQuota and UserDefinedNetwork as conditions on the VDC, so clients can
+// show why a VDC is not usable yet
+type VDCConditionsController struct {
+	client.Client
+	// Reader reads UserDefinedNetworks without starting an informer for them
+	Reader  client.Reader
+	VDCRepo VDCConditionsRepositoryInterface
+
+	now func() time.Time
+}
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;watch
+// +kubebuilder:rbac:groups=k8s.ovn.org,resources=userdefinednetworks,verbs=get
+
+// Reconcile evaluates the conditions of the VDC owning a namespace
+func (r *VDCConditionsController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Name)
+
+	vdc, err := r.VDCRepo.GetByNamespace(ctx, req.Name)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to look up VDC for namespace %s: %w", req.Name, err)
+	}
+	if vdc == nil {
+		return ctrl.Result{}, nil
+	}
+
+	evaluated, err := r.evaluate(ctx, req.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+	conditions, changed := vdc.Conditions(), false
+	for _, condition := range evaluated {
+		var conditionChanged bool
+		conditions, conditionChanged = models.SetCondition(conditions, condition, now)
+		changed = changed || conditionChanged
+	}
+	if changed {
+		if err := r.VDCRepo.UpdateConditions(ctx, vdc.ID, conditions); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update VDC conditions: %w", err)
+		}
+		logger.Info("Updated VDC conditions", "vdc", vdc.ID)
+	}
+
+	// Check again for a network being created; clusters without
+	// UserDefinedNetworks never get one
+	if network := models.FindCondition(conditions, models.ConditionNetworkReady); network != nil &&
+		network.Status != models.ConditionTrue && network.Reason != "UserDefinedNetworksUnavailable" {
+		return ctrl.Result{RequeueAfter: vdcNetworkPollInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// evaluate returns the NamespaceReady, QuotaApplied and NetworkReady conditions
+// of a VDC namespace
+func (r *VDCConditionsController) evaluate(ctx context.Context, name string) ([]models.Condition, error) {
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, &namespace); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get namespace: %w", err)
+		}
+		message := fmt.Sprintf("Namespace %s does not exist", name)
+		return []models.Condition{
+			{Type: models.ConditionNamespaceReady, Status: models.ConditionFalse, Reason: "NamespaceNotFound", Message: message},
+			{Type: models.ConditionQuotaApplied, Status: models.ConditionUnknown, Reason: "NamespaceNotFound", Message: message},
+			{Type: models.ConditionNetworkReady, Status: models.ConditionUnknown, Reason: "NamespaceNotFound", Message: message},
+		}, nil
+	}
+
+	namespaceReady := models.Condition{Type: models.ConditionNamespaceReady, Status: models.ConditionTrue, Reason: "Active"}
+	if namespace.Status.Phase == corev1.NamespaceTerminating || namespace.DeletionTimestamp != nil {
+		namespaceReady = models.Condition{
+			Type: models.ConditionNamespaceReady, Status: models.ConditionFalse, Reason: "Terminating",
+			Message: fmt.Sprintf("Namespace %s is being deleted", name),
+		}
+	}
+
+	quotaApplied, err := r.evaluateQuota(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	networkReady, err := r.evaluateNetwork(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Condition{namespaceReady, quotaApplied, networkReady}, nil
+}
+
+// evaluateQuota reports whether the VDC's ResourceQuota exists and the quota
+// controller is enforcing its current limits
+func (r *VDCConditionsController) evaluateQuota(ctx context.Context, namespace string) (models.Condition, error) {
+	condition := models.Condition{Type: models.ConditionQuotaApplied}
+
+	var quota corev1.ResourceQuota
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: vdcQuotaName}, &quota); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return condition, fmt.Errorf("failed to get ResourceQuota: %w", err)
+		}
+		condition.Status, condition.Reason = models.ConditionFalse, "QuotaNotFound"
+		condition.Message = fmt.Sprintf("ResourceQuota %s does not exist", vdcQuotaName)
+		return condition, nil
+	}
+
+	// The quota controller copies the limits to the status once it enforces them
+	if !equality.Semantic.DeepEqual(quota.Spec.Hard, quota.Status.Hard) {
+		condition.Status, condition.Reason = models.ConditionUnknown, "QuotaPending"
+		condition.Message = "Waiting for Kubernetes to enforce the ResourceQuota limits"
+		return condition, nil
+	}
+	condition.Status, condition.Reason = models.ConditionTrue, "Enforced"
+	return condition, nil
+}
+
+// evaluateNetwork reports whether the VDC's UserDefinedNetwork is ready,
+// carrying over the reason and message of its NetworkCreated condition
+func (r *VDCConditionsController) evaluateNetwork(ctx context.Context, namespace string) (models.Condition, error) {
+	condition := models.Condition{Type: models.ConditionNetworkReady}
+
+	udn := &unstructured.Unstructured{}
+	udn.SetGroupVersionKind(userDefinedNetworkGVK)
+	err := r.Reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: vdcNetworkName}, udn)
+	switch {
+	case meta.IsNoMatchError(err):
+		condition.Status, condition.Reason = models.ConditionUnknown, "UserDefinedNetworksUnavailable"
+		condition.Message = "The cluster does not support UserDefinedNetworks"
+		return condition, nil
+	case k8serrors.IsNotFound(err):
+		condition.Status, condition.Reason = models.ConditionFalse, "NetworkNotFound"
+		condition.Message = fmt.Sprintf("UserDefinedNetwork %s does not exist", vdcNetworkName)
+		return condition, nil
+	case err != nil:
+		return condition, fmt.Errorf("failed to get UserDefinedNetwork: %w", err)
+	}
+
+	udnConditions, _, _ := unstructured.NestedSlice(udn.Object, "status", "conditions")
+	for _, raw := range udnConditions {
+		udnCondition, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// Older OVN-Kubernetes releases report NetworkReady rather than NetworkCreated
+		switch udnCondition["type"] {
+		case "NetworkCreated", "NetworkReady":
+		default:
+			continue
+		}
+		status, _ := udnCondition["status"].(string)
+		reason, _ := udnCondition["reason"].(string)
+		message, _ := udnCondition["message"].(string)
+		switch status {
+		case models.ConditionTrue, models.ConditionFalse:
+			condition.Status = status
+		default:
+			condition.Status = models.ConditionUnknown
+		}
+		condition.Reason, condition.Message = conditionReason(reason, "NetworkCreated"), message
+		return condition, nil
+	}
+	condition.Status, condition.Reason = models.ConditionUnknown, "NetworkPending"
+	condition.Message = "Waiting for OVN-Kubernetes to create the network"
+	return condition, nil
+}
+
+// SetupVDCConditionsController sets up the VDC conditions controller
+func SetupVDCConditionsController(mgr ctrl.Manager, vdcRepo VDCConditionsRepositoryInterface, opts ControllerOptions) error {
+	controller := &VDCConditionsController{
+		Client:  mgr.GetClient(),
+		Reader:  mgr.GetAPIReader(),
+		VDCRepo: vdcRepo,
+	}
+
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named(VDCConditionsControllerName).
+		WithOptions(opts.controllerOptions(VDCConditionsControllerName)).
+		For(&corev1.Namespace{}, builder.WithPredicates(predicate.NewPredicateFuncs(isVDCNamespace))).
+		Watches(&corev1.ResourceQuota{},
+			handler.EnqueueRequestsFromMapFunc(mapToNamespace),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetName() == vdcQuotaName
+			}))).
+		Complete(opts.wrap(controller))
+	if err != nil {
+		return fmt.Errorf("failed to setup VDCConditionsController: %w", err)
+	}
+	return nil
+}
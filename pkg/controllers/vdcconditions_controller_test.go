@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// fakeVDCConditionsRepo keeps a single VDC in memory
+type fakeVDCConditionsRepo struct {
+	vdc     *models.VDC
+	updates int
+}
+
+func (r *fakeVDCConditionsRepo) GetByNamespace(ctx context.Context, namespaceName string) (*models.VDC, error) {
+	if r.vdc == nil || r.vdc.Namespace != namespaceName {
+		return nil, nil
+	}
+	return r.vdc, nil
+}
+
+func (r *fakeVDCConditionsRepo) UpdateConditions(ctx context.Context, vdcID string, conditions []models.Condition) error {
+	r.updates++
+	r.vdc.SetConditions(conditions)
+	return nil
+}
+
+func TestVDCConditionsController_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "vdc-ns", Labels: map[string]string{vdcNamespaceLabel: "1234"}},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	hard := corev1.ResourceList{corev1.ResourceRequestsMemory: resource.MustParse("8Gi")}
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: vdcQuotaName, Namespace: "vdc-ns"},
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+	}
+
+	// Clusters without OVN-Kubernetes have no mapping for UserDefinedNetworks
+	withoutUDN := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			return &meta.NoKindMatchError{GroupKind: userDefinedNetworkGVK.GroupKind()}
+		},
+	}).Build()
+
+	newController := func(reader client.Reader, objects ...client.Object) (*VDCConditionsController, *fakeVDCConditionsRepo) {
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		if reader == nil {
+			reader = withoutUDN
+		}
+		repo := &fakeVDCConditionsRepo{vdc: &models.VDC{ID: "urn:vcloud:vdc:1234", Namespace: "vdc-ns"}}
+		now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		return &VDCConditionsController{Client: k8sClient, Reader: reader, VDCRepo: repo, now: func() time.Time { return now }}, repo
+	}
+	reconcile := func(controller *VDCConditionsController) ctrl.Result {
+		result, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "vdc-ns"}})
+		require.NoError(t, err)
+		return result
+	}
+	condition := func(repo *fakeVDCConditionsRepo, conditionType string) models.Condition {
+		found := models.FindCondition(repo.vdc.Conditions(), conditionType)
+		require.NotNil(t, found, conditionType)
+		return *found
+	}
+
+	t.Run("missing namespace", func(t *testing.T) {
+		controller, repo := newController(nil)
+		reconcile(controller)
+		assert.Equal(t, models.ConditionFalse, condition(repo, models.ConditionNamespaceReady).Status)
+		assert.Equal(t, "NamespaceNotFound", condition(repo, models.ConditionQuotaApplied).Reason)
+	})
+
+	t.Run("quota pending and UserDefinedNetworks unavailable", func(t *testing.T) {
+		controller, repo := newController(nil, namespace, quota)
+		result := reconcile(controller)
+		assert.Equal(t, models.ConditionTrue, condition(repo, models.ConditionNamespaceReady).Status)
+		assert.Equal(t, "QuotaPending", condition(repo, models.ConditionQuotaApplied).Reason)
+		network := condition(repo, models.ConditionNetworkReady)
+		assert.Equal(t, models.ConditionUnknown, network.Status)
+		assert.Equal(t, "UserDefinedNetworksUnavailable", network.Reason)
+		assert.Zero(t, result.RequeueAfter)
+
+		// Reconciling unchanged state does not write the VDC again
+		reconcile(controller)
+		assert.Equal(t, 1, repo.updates)
+	})
+
+	t.Run("enforced quota and ready network", func(t *testing.T) {
+		enforced := quota.DeepCopy()
+		enforced.Status.Hard = hard
+		udn := &unstructured.Unstructured{}
+		udn.SetGroupVersionKind(userDefinedNetworkGVK)
+		udn.SetNamespace("vdc-ns")
+		udn.SetName(vdcNetworkName)
+		require.NoError(t, unstructured.SetNestedSlice(udn.Object, []interface{}{
+			map[string]interface{}{"type": "NetworkCreated", "status": "True", "reason": "NetworkAttachmentDefinitionCreated"},
+		}, "status", "conditions"))
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(udn).Build()
+
+		controller, repo := newController(reader, namespace, enforced)
+		result := reconcile(controller)
+		assert.Equal(t, "Enforced", condition(repo, models.ConditionQuotaApplied).Reason)
+		network := condition(repo, models.ConditionNetworkReady)
+		assert.Equal(t, models.ConditionTrue, network.Status)
+		assert.Equal(t, "NetworkAttachmentDefinitionCreated", network.Reason)
+		assert.Zero(t, result.RequeueAfter)
+	})
+
+	t.Run("missing network is checked again", func(t *testing.T) {
+		reader := fake.NewClientBuilder().WithScheme(scheme).Build()
+		controller, repo := newController(reader, namespace, quota)
+		result := reconcile(controller)
+		assert.Equal(t, "NetworkNotFound", condition(repo, models.ConditionNetworkReady).Reason)
+		assert.Equal(t, vdcNetworkPollInterval, result.RequeueAfter)
+	})
+}
+
+func TestSetCondition(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := created.Add(time.Hour)
+
+	conditions, changed := models.SetCondition(nil, models.Condition{Type: models.ConditionQuotaApplied, Status: models.ConditionUnknown, Reason: "QuotaPending"}, created)
+	require.True(t, changed)
+
+	// A new reason with the same status keeps the transition time
+	conditions, changed = models.SetCondition(conditions, models.Condition{Type: models.ConditionQuotaApplied, Status: models.ConditionUnknown, Reason: "Other"}, later)
+	require.True(t, changed)
+	assert.Equal(t, created, conditions[0].LastTransitionTime)
+
+	conditions, changed = models.SetCondition(conditions, models.Condition{Type: models.ConditionQuotaApplied, Status: models.ConditionTrue, Reason: "Enforced"}, later)
+	require.True(t, changed)
+	require.Len(t, conditions, 1)
+	assert.Equal(t, later, conditions[0].LastTransitionTime)
+
+	_, changed = models.SetCondition(conditions, models.Condition{Type: models.ConditionQuotaApplied, Status: models.ConditionTrue, Reason: "Enforced"}, later.Add(time.Hour))
+	assert.False(t, changed)
+}
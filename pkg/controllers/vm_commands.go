@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/mhrivnak/ssvirt/pkg/commands"
+)
+
+const (
+	// DefaultVMCommandTimeout bounds how long a power command waits for the VM
+	// to reach the requested state
+	DefaultVMCommandTimeout = 5 * time.Minute
+	// vmCommandPollInterval is how often the VM is checked while waiting
+	vmCommandPollInterval = 2 * time.Second
+)
+
+// vmFailureStatuses end the wait for a VM to start
+var vmFailureStatuses = map[kubevirtv1.VirtualMachinePrintableStatus]bool{
+	kubevirtv1.VirtualMachineStatusCrashLoopBackOff: true,
+	kubevirtv1.VirtualMachineStatusUnschedulable:    true,
+	kubevirtv1.VirtualMachineStatusErrImagePull:     true,
+	kubevirtv1.VirtualMachineStatusImagePullBackOff: true,
+	kubevirtv1.VirtualMachineStatusPvcNotFound:      true,
+	kubevirtv1.VirtualMachineStatusDataVolumeError:  true,
+}
+
+// VMRestarter reboots and resets running VMs
+type VMRestarter interface {
+	SoftRebootVMI(ctx context.Context, namespace, name string) error
+	ResetVM(ctx context.Context, namespace, name string) error
+}
+
+// VMCommandExecutor carries out VM commands sent by the API server. A command
+// completes when the VM reaches the requested state.
+type VMCommandExecutor struct {
+	client       client.Client
+	restarter    VMRestarter
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+// NewVMCommandExecutor creates an executor that waits up to timeout for each
+// command. Reboot and reset commands fail when restarter is nil.
+func NewVMCommandExecutor(c client.Client, restarter VMRestarter, timeout time.Duration) *VMCommandExecutor {
+	if timeout <= 0 {
+		timeout = DefaultVMCommandTimeout
+	}
+	return &VMCommandExecutor{
+		client:       c,
+		restarter:    restarter,
+		timeout:      timeout,
+		pollInterval: vmCommandPollInterval,
+	}
+}
+
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachineinstances,verbs=get
+// +kubebuilder:rbac:groups=subresources.kubevirt.io,resources=virtualmachineinstances/softreboot,verbs=update
+// +kubebuilder:rbac:groups=subresources.kubevirt.io,resources=virtualmachines/restart,verbs=update
+
+// Execute implements commands.Executor
+func (e *VMCommandExecutor) Execute(ctx context.Context, cmd commands.Command) error {
+	switch cmd.Type {
+	case commands.TypeVMPower:
+		switch cmd.Action {
+		case commands.ActionReboot:
+			return e.reboot(ctx, cmd)
+		case commands.ActionReset:
+			return e.reset(ctx, cmd)
+		}
+		return e.power(ctx, cmd)
+	default:
+		return fmt.Errorf("%w: unknown type %q", commands.ErrInvalidCommand, cmd.Type)
+	}
+}
+
+// power sets the VM's run strategy and waits for the VM to start or stop. VMs
+// are started with Always, since KubeVirt neither restarts a VM stopped by its
+// guest nor starts one given the Manual strategy, and are then given the
+// command's run strategy.
+func (e *VMCommandExecutor) power(ctx context.Context, cmd commands.Command) error {
+	runStrategy := kubevirtv1.RunStrategyAlways
+	want := kubevirtv1.VirtualMachineStatusRunning
+	if cmd.Action == commands.ActionPowerOff {
+		runStrategy = kubevirtv1.RunStrategyHalted
+		want = kubevirtv1.VirtualMachineStatusStopped
+	}
+
+	key := types.NamespacedName{Namespace: cmd.Namespace, Name: cmd.Name}
+	vm := &kubevirtv1.VirtualMachine{}
+	if err := e.client.Get(ctx, key, vm); err != nil {
+		return fmt.Errorf("failed to get VirtualMachine %s: %w", key, err)
+	}
+
+	if err := e.setRunStrategy(ctx, vm, runStrategy); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := e.client.Get(ctx, key, vm); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("VirtualMachine %s did not reach status %s within %s", key, want, e.timeout)
+			}
+			return fmt.Errorf("failed to get VirtualMachine %s: %w", key, err)
+		}
+		status := vm.Status.PrintableStatus
+		if status == want {
+			if runStrategy == kubevirtv1.RunStrategyAlways && cmd.RunStrategy != "" && cmd.RunStrategy != string(runStrategy) {
+				return e.setRunStrategy(ctx, vm, kubevirtv1.VirtualMachineRunStrategy(cmd.RunStrategy))
+			}
+			return nil
+		}
+		if runStrategy == kubevirtv1.RunStrategyAlways {
+			if err := startFailure(key, vm); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("VirtualMachine %s did not reach status %s within %s (status %s)", key, want, e.timeout, status)
+		case <-ticker.C:
+		}
+	}
+}
+
+// setRunStrategy patches the run strategy of a VirtualMachine
+func (e *VMCommandExecutor) setRunStrategy(ctx context.Context, vm *kubevirtv1.VirtualMachine, runStrategy kubevirtv1.VirtualMachineRunStrategy) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"runStrategy": runStrategy},
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.client.Patch(ctx, vm, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return fmt.Errorf("failed to set run strategy of VirtualMachine %s/%s: %w", vm.Namespace, vm.Name, err)
+	}
+	return nil
+}
+
+// reboot asks the guest to restart. The command completes once virt-launcher
+// accepts the request, since the VM stays running while the guest reboots.
+func (e *VMCommandExecutor) reboot(ctx context.Context, cmd commands.Command) error {
+	if e.restarter == nil {
+		return fmt.Errorf("%w: VM reboot is not supported", commands.ErrInvalidCommand)
+	}
+	key := types.NamespacedName{Namespace: cmd.Namespace, Name: cmd.Name}
+	if _, err := e.runningVMI(ctx, key); err != nil {
+		return err
+	}
+	return e.restarter.SoftRebootVMI(ctx, cmd.Namespace, cmd.Name)
+}
+
+// reset restarts the VM without the guest's involvement and waits until a new
+// VirtualMachineInstance is running
+func (e *VMCommandExecutor) reset(ctx context.Context, cmd commands.Command) error {
+	if e.restarter == nil {
+		return fmt.Errorf("%w: VM reset is not supported", commands.ErrInvalidCommand)
+	}
+	key := types.NamespacedName{Namespace: cmd.Namespace, Name: cmd.Name}
+	vmi, err := e.runningVMI(ctx, key)
+	if err != nil {
+		return err
+	}
+	previous := vmi.UID
+	if err := e.restarter.ResetVM(ctx, cmd.Namespace, cmd.Name); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+	vm := &kubevirtv1.VirtualMachine{}
+	for {
+		if err := e.client.Get(ctx, key, vm); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("VirtualMachine %s did not restart within %s", key, e.timeout)
+			}
+			return fmt.Errorf("failed to get VirtualMachine %s: %w", key, err)
+		}
+		if err := startFailure(key, vm); err != nil {
+			return err
+		}
+		err := e.client.Get(ctx, key, vmi)
+		switch {
+		case err == nil:
+			if vmi.UID != previous && vmi.Status.Phase == kubevirtv1.Running {
+				return nil
+			}
+		case !errors.IsNotFound(err) && ctx.Err() == nil:
+			return fmt.Errorf("failed to get VirtualMachineInstance %s: %w", key, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("VirtualMachine %s did not restart within %s (status %s)", key, e.timeout, vm.Status.PrintableStatus)
+		case <-ticker.C:
+		}
+	}
+}
+
+// startFailure returns why a starting VM cannot run, or nil while it may still
+// start. KubeVirt keeps retrying a VM held back by its quota, but the command
+// fails so its task explains what to fix.
+func startFailure(key types.NamespacedName, vm *kubevirtv1.VirtualMachine) error {
+	if message := quotaExceededMessage(vm); message != "" {
+		return fmt.Errorf("VirtualMachine %s cannot start because the VDC resource quota is exceeded: %s", key, message)
+	}
+	if vmFailureStatuses[vm.Status.PrintableStatus] {
+		return fmt.Errorf("VirtualMachine %s entered status %s", key, vm.Status.PrintableStatus)
+	}
+	return nil
+}
+
+// runningVMI returns the VM's VirtualMachineInstance, failing when the VM is
+// not running
+func (e *VMCommandExecutor) runningVMI(ctx context.Context, key types.NamespacedName) (*kubevirtv1.VirtualMachineInstance, error) {
+	vmi := &kubevirtv1.VirtualMachineInstance{}
+	if err := e.client.Get(ctx, key, vmi); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("VirtualMachine %s is not running", key)
+		}
+		return nil, fmt.Errorf("failed to get VirtualMachineInstance %s: %w", key, err)
+	}
+	if vmi.Status.Phase != kubevirtv1.Running {
+		return nil, fmt.Errorf("VirtualMachine %s is not running (phase %s)", key, vmi.Status.Phase)
+	}
+	return vmi, nil
+}
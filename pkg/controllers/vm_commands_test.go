@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/commands"
+)
+
+func TestVMCommandExecutor(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	halted := kubevirtv1.RunStrategyHalted
+	vm := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns"},
+		Spec:       kubevirtv1.VirtualMachineSpec{RunStrategy: &halted},
+		Status:     kubevirtv1.VirtualMachineStatus{PrintableStatus: kubevirtv1.VirtualMachineStatusStopped},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vm).WithStatusSubresource(vm).Build()
+	key := types.NamespacedName{Name: "web", Namespace: "ns"}
+
+	executor := NewVMCommandExecutor(k8sClient, nil, 200*time.Millisecond)
+	executor.pollInterval = 10 * time.Millisecond
+	powerOn := commands.Command{ID: "cmd-1", Type: commands.TypeVMPower, Action: commands.ActionPowerOn, Namespace: "ns", Name: "web"}
+
+	setStatus := func(status kubevirtv1.VirtualMachinePrintableStatus) {
+		current := &kubevirtv1.VirtualMachine{}
+		require.NoError(t, k8sClient.Get(context.Background(), key, current))
+		current.Status.PrintableStatus = status
+		require.NoError(t, k8sClient.Status().Update(context.Background(), current))
+	}
+	runStrategy := func() kubevirtv1.VirtualMachineRunStrategy {
+		current := &kubevirtv1.VirtualMachine{}
+		require.NoError(t, k8sClient.Get(context.Background(), key, current))
+		return *current.Spec.RunStrategy
+	}
+
+	t.Run("Completes once the VM is running", func(t *testing.T) {
+		done := make(chan error, 1)
+		go func() { done <- executor.Execute(context.Background(), powerOn) }()
+
+		require.Eventually(t, func() bool { return runStrategy() == kubevirtv1.RunStrategyAlways }, time.Second, 5*time.Millisecond)
+		setStatus(kubevirtv1.VirtualMachineStatusRunning)
+		require.NoError(t, <-done)
+	})
+
+	t.Run("Applies the run strategy once the VM is running", func(t *testing.T) {
+		setStatus(kubevirtv1.VirtualMachineStatusStopped)
+		rerun := powerOn
+		rerun.RunStrategy = string(kubevirtv1.RunStrategyRerunOnFailure)
+		done := make(chan error, 1)
+		go func() { done <- executor.Execute(context.Background(), rerun) }()
+
+		require.Eventually(t, func() bool { return runStrategy() == kubevirtv1.RunStrategyAlways }, time.Second, 5*time.Millisecond)
+		setStatus(kubevirtv1.VirtualMachineStatusRunning)
+		require.NoError(t, <-done)
+		assert.Equal(t, kubevirtv1.RunStrategyRerunOnFailure, runStrategy())
+	})
+
+	t.Run("Fails when the VM cannot start", func(t *testing.T) {
+		setStatus(kubevirtv1.VirtualMachineStatusUnschedulable)
+		err := executor.Execute(context.Background(), powerOn)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ErrorUnschedulable")
+	})
+
+	t.Run("Fails when the quota keeps the VM from starting", func(t *testing.T) {
+		current := &kubevirtv1.VirtualMachine{}
+		require.NoError(t, k8sClient.Get(context.Background(), key, current))
+		current.Status.PrintableStatus = kubevirtv1.VirtualMachineStatusStarting
+		current.Status.Conditions = []kubevirtv1.VirtualMachineCondition{quotaExceededCondition}
+		require.NoError(t, k8sClient.Status().Update(context.Background(), current))
+
+		err := executor.Execute(context.Background(), powerOn)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "VDC resource quota is exceeded")
+		assert.Contains(t, err.Error(), "limited: requests.memory=8Gi")
+	})
+
+	t.Run("Times out when the VM does not stop", func(t *testing.T) {
+		setStatus(kubevirtv1.VirtualMachineStatusStopping)
+		powerOff := powerOn
+		powerOff.Action = commands.ActionPowerOff
+		err := executor.Execute(context.Background(), powerOff)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "did not reach status Stopped")
+		assert.Equal(t, kubevirtv1.RunStrategyHalted, runStrategy())
+	})
+
+	t.Run("Fails for a missing VM", func(t *testing.T) {
+		missing := powerOn
+		missing.Name = "missing"
+		assert.Error(t, executor.Execute(context.Background(), missing))
+	})
+
+	t.Run("Rejects unknown command types", func(t *testing.T) {
+		unknown := powerOn
+		unknown.Type = "vm.migrate"
+		assert.ErrorIs(t, executor.Execute(context.Background(), unknown), commands.ErrInvalidCommand)
+	})
+}
+
+// fakeRestarter recreates the VirtualMachineInstance on reset, as KubeVirt does
+type fakeRestarter struct {
+	client   client.Client
+	reboots  int
+	resets   int
+	newPhase kubevirtv1.VirtualMachineInstancePhase
+}
+
+func (r *fakeRestarter) SoftRebootVMI(ctx context.Context, namespace, name string) error {
+	r.reboots++
+	return nil
+}
+
+func (r *fakeRestarter) ResetVM(ctx context.Context, namespace, name string) error {
+	r.resets++
+	vmi := &kubevirtv1.VirtualMachineInstance{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, vmi); err != nil {
+		return err
+	}
+	if err := r.client.Delete(ctx, vmi); err != nil {
+		return err
+	}
+	return r.client.Create(ctx, &kubevirtv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(fmt.Sprintf("vmi-%d", r.resets+1))},
+		Status:     kubevirtv1.VirtualMachineInstanceStatus{Phase: r.newPhase},
+	})
+}
+
+func TestVMCommandExecutor_Restart(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	vm := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns"},
+		Status:     kubevirtv1.VirtualMachineStatus{PrintableStatus: kubevirtv1.VirtualMachineStatusRunning},
+	}
+	vmi := &kubevirtv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns", UID: "vmi-1"},
+		Status:     kubevirtv1.VirtualMachineInstanceStatus{Phase: kubevirtv1.Running},
+	}
+	stopped := &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "ns"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vm, vmi, stopped).Build()
+
+	restarter := &fakeRestarter{client: k8sClient, newPhase: kubevirtv1.Running}
+	executor := NewVMCommandExecutor(k8sClient, restarter, 200*time.Millisecond)
+	executor.pollInterval = 10 * time.Millisecond
+	reboot := commands.Command{ID: "cmd-1", Type: commands.TypeVMPower, Action: commands.ActionReboot, Namespace: "ns", Name: "web"}
+	reset := reboot
+	reset.Action = commands.ActionReset
+
+	t.Run("Reboot asks the guest to restart", func(t *testing.T) {
+		require.NoError(t, executor.Execute(context.Background(), reboot))
+		assert.Equal(t, 1, restarter.reboots)
+		assert.Zero(t, restarter.resets)
+	})
+
+	t.Run("Reset completes once a new instance is running", func(t *testing.T) {
+		require.NoError(t, executor.Execute(context.Background(), reset))
+		assert.Equal(t, 1, restarter.resets)
+	})
+
+	t.Run("Reset times out when the new instance does not start", func(t *testing.T) {
+		restarter.newPhase = kubevirtv1.Scheduling
+		err := executor.Execute(context.Background(), reset)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "did not restart")
+	})
+
+	t.Run("Stopped VMs cannot be restarted", func(t *testing.T) {
+		stoppedReboot := reboot
+		stoppedReboot.Name = "db"
+		err := executor.Execute(context.Background(), stoppedReboot)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not running")
+		assert.Equal(t, 1, restarter.reboots)
+	})
+
+	t.Run("Fails without a restarter", func(t *testing.T) {
+		withoutRestarter := NewVMCommandExecutor(k8sClient, nil, time.Second)
+		assert.ErrorIs(t, withoutRestarter.Execute(context.Background(), reboot), commands.ErrInvalidCommand)
+	})
+}
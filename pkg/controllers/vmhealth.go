@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// evaluateVMIHealth derives a VM's health state from its VirtualMachineInstance
+// and the node it runs on. A running VMI is HEALTHY when it is Ready, not paused,
+// its guest agent has not reported a disconnect and its node is Ready. A node of
+// nil means the node could not be determined. VMIs that are not running yet, or
+// whose node is unknown, are UNKNOWN.
+func evaluateVMIHealth(vmi *kubevirtv1.VirtualMachineInstance, node *corev1.Node) string {
+	if vmi == nil {
+		return models.HealthStateUnknown
+	}
+
+	switch vmi.Status.Phase {
+	case kubevirtv1.Failed, kubevirtv1.Unknown:
+		return models.HealthStateDegraded
+	case kubevirtv1.Running:
+	default:
+		return models.HealthStateUnknown
+	}
+
+	ready := false
+	for _, condition := range vmi.Status.Conditions {
+		switch condition.Type {
+		case kubevirtv1.VirtualMachineInstanceReady:
+			ready = condition.Status == corev1.ConditionTrue
+		case kubevirtv1.VirtualMachineInstancePaused:
+			if condition.Status == corev1.ConditionTrue {
+				return models.HealthStateDegraded
+			}
+		case kubevirtv1.VirtualMachineInstanceAgentConnected:
+			// Guests without an agent never report the condition
+			if condition.Status == corev1.ConditionFalse {
+				return models.HealthStateDegraded
+			}
+		}
+	}
+	if !ready {
+		return models.HealthStateDegraded
+	}
+
+	if node == nil {
+		return models.HealthStateUnknown
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			if condition.Status == corev1.ConditionTrue {
+				return models.HealthStateHealthy
+			}
+			return models.HealthStateDegraded
+		}
+	}
+	return models.HealthStateDegraded
+}
+
+// vmiHealthState looks up the node running the VMI and evaluates the VM's health
+func (r *VMStatusController) vmiHealthState(ctx context.Context, vmi *kubevirtv1.VirtualMachineInstance) string {
+	if vmi.Status.NodeName == "" {
+		return evaluateVMIHealth(vmi, nil)
+	}
+
+	node := &corev1.Node{}
+	err := r.Get(ctx, types.NamespacedName{Name: vmi.Status.NodeName}, node)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			// The node is gone, so whatever the VMI reports is stale
+			return models.HealthStateDegraded
+		}
+		log.FromContext(ctx).Error(err, "Failed to get node for VM health", "node", vmi.Status.NodeName)
+		return evaluateVMIHealth(vmi, nil)
+	}
+	return evaluateVMIHealth(vmi, node)
+}
+
+// updateHealthState stores the VM's health state if it changed. A record whose
+// health was never evaluated is treated as UNKNOWN.
+func (r *VMStatusController) updateHealthState(ctx context.Context, vm *kubevirtv1.VirtualMachine, vmRecord *models.VM, healthState string) error {
+	if vmRecord.GetHealthState() == healthState {
+		return nil
+	}
+
+	logger := log.FromContext(ctx).WithValues("vm", vm.Name, "namespace", vm.Namespace)
+	if err := r.VMRepo.UpdateHealthState(ctx, vmRecord.ID, healthState); err != nil {
+		logger.Error(err, "Failed to update VM health state in database")
+		return err
+	}
+
+	logger.Info("Updated VM health state",
+		"vmID", vmRecord.ID,
+		"oldHealthState", vmRecord.GetHealthState(),
+		"newHealthState", healthState)
+	vmRecord.HealthState = healthState
+	return nil
+}
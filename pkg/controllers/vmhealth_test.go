@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func runningVMI(conditions ...kubevirtv1.VirtualMachineInstanceCondition) *kubevirtv1.VirtualMachineInstance {
+	return &kubevirtv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "test-namespace"},
+		Status: kubevirtv1.VirtualMachineInstanceStatus{
+			Phase:      kubevirtv1.Running,
+			NodeName:   "node-1",
+			Conditions: conditions,
+		},
+	}
+}
+
+func vmiCondition(conditionType kubevirtv1.VirtualMachineInstanceConditionType, status corev1.ConditionStatus) kubevirtv1.VirtualMachineInstanceCondition {
+	return kubevirtv1.VirtualMachineInstanceCondition{Type: conditionType, Status: status}
+}
+
+func testNode(ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+		},
+	}
+}
+
+func TestEvaluateVMIHealth(t *testing.T) {
+	ready := vmiCondition(kubevirtv1.VirtualMachineInstanceReady, corev1.ConditionTrue)
+
+	tests := []struct {
+		name     string
+		vmi      *kubevirtv1.VirtualMachineInstance
+		node     *corev1.Node
+		expected string
+	}{
+		{
+			name:     "no VMI",
+			expected: models.HealthStateUnknown,
+		},
+		{
+			name:     "ready on a ready node",
+			vmi:      runningVMI(ready),
+			node:     testNode(corev1.ConditionTrue),
+			expected: models.HealthStateHealthy,
+		},
+		{
+			name:     "ready with guest agent connected",
+			vmi:      runningVMI(ready, vmiCondition(kubevirtv1.VirtualMachineInstanceAgentConnected, corev1.ConditionTrue)),
+			node:     testNode(corev1.ConditionTrue),
+			expected: models.HealthStateHealthy,
+		},
+		{
+			name:     "not ready",
+			vmi:      runningVMI(vmiCondition(kubevirtv1.VirtualMachineInstanceReady, corev1.ConditionFalse)),
+			node:     testNode(corev1.ConditionTrue),
+			expected: models.HealthStateDegraded,
+		},
+		{
+			name:     "paused",
+			vmi:      runningVMI(ready, vmiCondition(kubevirtv1.VirtualMachineInstancePaused, corev1.ConditionTrue)),
+			node:     testNode(corev1.ConditionTrue),
+			expected: models.HealthStateDegraded,
+		},
+		{
+			name:     "guest agent disconnected",
+			vmi:      runningVMI(ready, vmiCondition(kubevirtv1.VirtualMachineInstanceAgentConnected, corev1.ConditionFalse)),
+			node:     testNode(corev1.ConditionTrue),
+			expected: models.HealthStateDegraded,
+		},
+		{
+			name:     "node not ready",
+			vmi:      runningVMI(ready),
+			node:     testNode(corev1.ConditionUnknown),
+			expected: models.HealthStateDegraded,
+		},
+		{
+			name:     "node unknown",
+			vmi:      runningVMI(ready),
+			expected: models.HealthStateUnknown,
+		},
+		{
+			name: "still scheduling",
+			vmi: &kubevirtv1.VirtualMachineInstance{
+				Status: kubevirtv1.VirtualMachineInstanceStatus{Phase: kubevirtv1.Scheduling},
+			},
+			expected: models.HealthStateUnknown,
+		},
+		{
+			name: "failed",
+			vmi: &kubevirtv1.VirtualMachineInstance{
+				Status: kubevirtv1.VirtualMachineInstanceStatus{Phase: kubevirtv1.Failed},
+			},
+			expected: models.HealthStateDegraded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, evaluateVMIHealth(tt.vmi, tt.node))
+		})
+	}
+}
+
+func TestVMStatusControllerStoresHealthState(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	vm := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-vm",
+			Namespace: "test-namespace",
+			Labels:    map[string]string{"vapp.ssvirt.io/vapp-id": "vapp-123"},
+		},
+		Status: kubevirtv1.VirtualMachineStatus{PrintableStatus: kubevirtv1.VirtualMachineStatusRunning},
+	}
+	vmi := runningVMI(vmiCondition(kubevirtv1.VirtualMachineInstanceReady, corev1.ConditionTrue))
+	node := testNode(corev1.ConditionFalse)
+
+	repo := new(MockVMRepository)
+	repo.On("GetByVAppAndVMName", mock.Anything, "vapp-123", "test-vm").Return(&models.VM{
+		ID:          "vm-123",
+		Status:      "POWERED_ON",
+		HealthState: models.HealthStateHealthy,
+		UpdatedAt:   time.Now(),
+	}, nil)
+	repo.On("UpdateHealthState", mock.Anything, "vm-123", models.HealthStateDegraded).Return(nil).Once()
+
+	controller := &VMStatusController{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(vm, vmi, node).Build(),
+		Scheme:   scheme,
+		VMRepo:   repo,
+		VAppRepo: new(MockVAppRepository),
+		VDCRepo:  new(MockVDCRepository),
+		Recorder: &MockEventRecorder{},
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "test-vm"}}
+	_, err := controller.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+}
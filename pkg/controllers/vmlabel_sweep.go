@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultLabelSweepInterval is how often VDC namespaces are swept for
+// VirtualMachines missing the vapp.ssvirt label
+const DefaultLabelSweepInterval = time.Hour
+
+// vmLabelSweep labels VirtualMachines that were created from an SSVirt
+// TemplateInstance while the controller was not watching, such as during an
+// upgrade or outage. Watch events only cover changes made after the cache
+// started, so VMs that never change again would otherwise stay unlabelled and
+// never get a vApp. The sweep runs once at start and then every interval.
+type vmLabelSweep struct {
+	controller *VMStatusController
+	// Reader lists namespaces directly from the API server so they are not
+	// cached cluster-wide
+	Reader   client.Reader
+	Interval time.Duration
+}
+
+// Start sweeps VDC namespaces until the context is cancelled. It implements
+// manager.Runnable and runs only on the leader.
+func (s *vmLabelSweep) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("vm-label-sweep")
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultLabelSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		labelled, err := s.sweep(ctx)
+		if err != nil {
+			logger.Error(err, "Failed to sweep VDC namespaces for unlabelled VMs")
+		}
+		if labelled > 0 {
+			logger.Info("Labelled VMs missed by watch events", "count", labelled)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep labels the unlabelled VMs in every VDC namespace and returns how many
+// were labelled. Failures for single VMs are logged by ensureVAppLabel and
+// retried at the next sweep.
+func (s *vmLabelSweep) sweep(ctx context.Context) (int, error) {
+	var namespaces corev1.NamespaceList
+	if err := s.Reader.List(ctx, &namespaces, client.HasLabels{vdcNamespaceLabel}); err != nil {
+		return 0, err
+	}
+
+	labelled := 0
+	for _, namespace := range namespaces.Items {
+		if ctx.Err() != nil {
+			return labelled, nil
+		}
+		var vms kubevirtv1.VirtualMachineList
+		if err := s.controller.List(ctx, &vms, client.InNamespace(namespace.Name), client.HasLabels{templateInstanceOwnerLabel}); err != nil {
+			return labelled, fmt.Errorf("failed to list VirtualMachines in %s: %w", namespace.Name, err)
+		}
+		for i := range vms.Items {
+			vm := &vms.Items[i]
+			if _, ok := vm.Labels[vappLabel]; ok || !vm.DeletionTimestamp.IsZero() {
+				continue
+			}
+			updated, err := s.controller.ensureVAppLabel(ctx, vm)
+			if err == nil && updated != nil {
+				labelled++
+			}
+		}
+	}
+	return labelled, nil
+}
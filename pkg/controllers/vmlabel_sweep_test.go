@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestVMLabelSweep(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	require.NoError(t, templatev1.AddToScheme(scheme))
+
+	vm := func(name, namespace string, labels map[string]string) *kubevirtv1.VirtualMachine {
+		return &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+	}
+	instance := func(name, namespace, uid string, labels map[string]string) *templatev1.TemplateInstance {
+		return &templatev1.TemplateInstance{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(uid), Labels: labels}}
+	}
+	managed := map[string]string{managedByLabel: managedByValue}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "vdc-ns", Labels: map[string]string{vdcNamespaceLabel: "vdc-1"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other-ns"}},
+			instance("web", "vdc-ns", "web-uid", managed),
+			instance("foreign", "vdc-ns", "foreign-uid", nil),
+			instance("elsewhere", "other-ns", "elsewhere-uid", managed),
+			vm("missed", "vdc-ns", map[string]string{templateInstanceOwnerLabel: "web-uid"}),
+			vm("labelled", "vdc-ns", map[string]string{templateInstanceOwnerLabel: "web-uid", vappLabel: "existing"}),
+			vm("untrusted", "vdc-ns", map[string]string{templateInstanceOwnerLabel: "foreign-uid"}),
+			vm("standalone", "vdc-ns", nil),
+			vm("outside", "other-ns", map[string]string{templateInstanceOwnerLabel: "elsewhere-uid"}),
+		).Build()
+
+	mockVDCRepo := new(MockVDCRepository)
+	mockVDCRepo.On("GetByNamespace", mock.Anything, "vdc-ns").Return(&models.VDC{ID: "vdc-1"}, nil)
+	sweep := &vmLabelSweep{
+		controller: &VMStatusController{
+			Client:   fakeClient,
+			Scheme:   scheme,
+			VDCRepo:  VDCRepositoryInterface(mockVDCRepo),
+			Recorder: &MockEventRecorder{},
+		},
+		Reader: fakeClient,
+	}
+
+	ctx := context.Background()
+	labelled, err := sweep.sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, labelled)
+
+	labelOf := func(name, namespace string) string {
+		var got kubevirtv1.VirtualMachine
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, &got))
+		return got.Labels[vappLabel]
+	}
+	assert.Equal(t, "web", labelOf("missed", "vdc-ns"))
+	assert.Equal(t, "existing", labelOf("labelled", "vdc-ns"))
+	assert.Empty(t, labelOf("untrusted", "vdc-ns"))
+	assert.Empty(t, labelOf("standalone", "vdc-ns"))
+	assert.Empty(t, labelOf("outside", "other-ns"))
+
+	// Labelled VMs are skipped by later sweeps
+	labelled, err = sweep.sweep(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, labelled)
+}
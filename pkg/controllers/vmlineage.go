@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	templatev1 "github.com/openshift/api/template/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer-api/pkg/apis/core/v1beta1"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// Annotations through which templates, import and clone tooling declare where
+// a VirtualMachine came from when it was not simply instantiated from the template
+const (
+	sourceTypeAnnotation = "ssvirt.io/source-type"
+	sourceRefAnnotation  = "ssvirt.io/source-ref"
+)
+
+// vmLineage records where a VM was created from. VMs are instantiated from
+// templates unless the VirtualMachine declares an import or clone source. The
+// source image is what the boot disk was populated from.
+func vmLineage(vm *kubevirtv1.VirtualMachine, templateInstance *templatev1.TemplateInstance, vapp *models.VApp) (sourceType, sourceRef, sourceImage string) {
+	sourceImage = bootDiskSource(vm)
+	if declared := vm.Annotations[sourceTypeAnnotation]; models.IsValidVMSourceType(declared) {
+		return declared, vm.Annotations[sourceRefAnnotation], sourceImage
+	}
+	sourceRef = vapp.CatalogItemID
+	if sourceRef == "" && templateInstance.Spec.Template.Name != "" {
+		sourceRef = templateInstance.Spec.Template.Name
+	}
+	return models.VMSourceTemplate, sourceRef, sourceImage
+}
+
+// bootDiskSource describes the image the VM's boot disk was populated from: a
+// container image or URL, or the PVC, VolumeSnapshot or DataSource it was
+// cloned from. It is empty when the boot disk is not backed by an image.
+func bootDiskSource(vm *kubevirtv1.VirtualMachine) string {
+	if vm.Spec.Template == nil {
+		return ""
+	}
+	spec := vm.Spec.Template.Spec
+	bootDisk := ""
+	for _, disk := range spec.Domain.Devices.Disks {
+		if bootDisk == "" || (disk.BootOrder != nil && *disk.BootOrder == 1) {
+			bootDisk = disk.Name
+		}
+	}
+
+	for _, volume := range spec.Volumes {
+		if volume.Name != bootDisk {
+			continue
+		}
+		switch {
+		case volume.ContainerDisk != nil:
+			return volume.ContainerDisk.Image
+		case volume.PersistentVolumeClaim != nil:
+			return "pvc/" + vm.Namespace + "/" + volume.PersistentVolumeClaim.ClaimName
+		case volume.DataVolume != nil:
+			for _, template := range vm.Spec.DataVolumeTemplates {
+				if template.Name == volume.DataVolume.Name {
+					return dataVolumeSource(vm.Namespace, template.Spec)
+				}
+			}
+			return "pvc/" + vm.Namespace + "/" + volume.DataVolume.Name
+		}
+		return ""
+	}
+	return ""
+}
+
+// dataVolumeSource describes what a DataVolume is populated from
+func dataVolumeSource(namespace string, spec cdiv1.DataVolumeSpec) string {
+	if ref := spec.SourceRef; ref != nil {
+		if ref.Namespace != nil {
+			namespace = *ref.Namespace
+		}
+		return "datasource/" + namespace + "/" + ref.Name
+	}
+	source := spec.Source
+	if source == nil {
+		return ""
+	}
+	switch {
+	case source.Registry != nil && source.Registry.URL != nil:
+		return *source.Registry.URL
+	case source.Registry != nil && source.Registry.ImageStream != nil:
+		return "imagestream/" + namespace + "/" + *source.Registry.ImageStream
+	case source.HTTP != nil:
+		return source.HTTP.URL
+	case source.S3 != nil:
+		return source.S3.URL
+	case source.GCS != nil:
+		return source.GCS.URL
+	case source.PVC != nil:
+		return "pvc/" + source.PVC.Namespace + "/" + source.PVC.Name
+	case source.Snapshot != nil:
+		return "volumesnapshot/" + source.Snapshot.Namespace + "/" + source.Snapshot.Name
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"testing"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer-api/pkg/apis/core/v1beta1"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func lineageVM(annotations map[string]string, disks []kubevirtv1.Disk, volumes []kubevirtv1.Volume, dataVolumes ...kubevirtv1.DataVolumeTemplateSpec) *kubevirtv1.VirtualMachine {
+	return &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-01", Namespace: "vdc-ns", Annotations: annotations},
+		Spec: kubevirtv1.VirtualMachineSpec{
+			DataVolumeTemplates: dataVolumes,
+			Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kubevirtv1.VirtualMachineInstanceSpec{
+					Domain:  kubevirtv1.DomainSpec{Devices: kubevirtv1.Devices{Disks: disks}},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
+
+func TestVMLineage(t *testing.T) {
+	registryURL := "docker://quay.io/containerdisks/fedora:40"
+	bootOrder := uint(1)
+	templateInstance := &templatev1.TemplateInstance{
+		Spec: templatev1.TemplateInstanceSpec{Template: templatev1.Template{ObjectMeta: metav1.ObjectMeta{Name: "fedora"}}},
+	}
+
+	tests := []struct {
+		name          string
+		vm            *kubevirtv1.VirtualMachine
+		vapp          *models.VApp
+		expectedType  string
+		expectedRef   string
+		expectedImage string
+	}{
+		{
+			name: "Template instantiated through the API with a container disk",
+			vm: lineageVM(nil,
+				[]kubevirtv1.Disk{{Name: "rootdisk"}},
+				[]kubevirtv1.Volume{{Name: "rootdisk", VolumeSource: kubevirtv1.VolumeSource{ContainerDisk: &kubevirtv1.ContainerDiskSource{Image: "quay.io/containerdisks/fedora:40"}}}}),
+			vapp:          &models.VApp{CatalogItemID: "urn:vcloud:catalogitem:abc:fedora"},
+			expectedType:  models.VMSourceTemplate,
+			expectedRef:   "urn:vcloud:catalogitem:abc:fedora",
+			expectedImage: "quay.io/containerdisks/fedora:40",
+		},
+		{
+			name: "Template instantiated outside the API uses the boot disk's DataVolume",
+			vm: lineageVM(nil,
+				[]kubevirtv1.Disk{{Name: "cloudinit"}, {Name: "rootdisk", BootOrder: &bootOrder}},
+				[]kubevirtv1.Volume{
+					{Name: "cloudinit", VolumeSource: kubevirtv1.VolumeSource{CloudInitNoCloud: &kubevirtv1.CloudInitNoCloudSource{}}},
+					{Name: "rootdisk", VolumeSource: kubevirtv1.VolumeSource{DataVolume: &kubevirtv1.DataVolumeSource{Name: "web-01-root"}}},
+				},
+				kubevirtv1.DataVolumeTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Name: "web-01-root"},
+					Spec:       cdiv1.DataVolumeSpec{Source: &cdiv1.DataVolumeSource{Registry: &cdiv1.DataVolumeSourceRegistry{URL: &registryURL}}},
+				}),
+			vapp:          &models.VApp{},
+			expectedType:  models.VMSourceTemplate,
+			expectedRef:   "fedora",
+			expectedImage: registryURL,
+		},
+		{
+			name: "Clones declare their parent",
+			vm: lineageVM(map[string]string{sourceTypeAnnotation: models.VMSourceClone, sourceRefAnnotation: "web-00"},
+				[]kubevirtv1.Disk{{Name: "rootdisk"}},
+				[]kubevirtv1.Volume{{Name: "rootdisk", VolumeSource: kubevirtv1.VolumeSource{DataVolume: &kubevirtv1.DataVolumeSource{Name: "web-01-root"}}}},
+				kubevirtv1.DataVolumeTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Name: "web-01-root"},
+					Spec:       cdiv1.DataVolumeSpec{Source: &cdiv1.DataVolumeSource{PVC: &cdiv1.DataVolumeSourcePVC{Namespace: "vdc-ns", Name: "web-00-root"}}},
+				}),
+			vapp:          &models.VApp{CatalogItemID: "urn:vcloud:catalogitem:abc:fedora"},
+			expectedType:  models.VMSourceClone,
+			expectedRef:   "web-00",
+			expectedImage: "pvc/vdc-ns/web-00-root",
+		},
+		{
+			name: "Unknown declared sources are ignored",
+			vm: lineageVM(map[string]string{sourceTypeAnnotation: "magic"},
+				nil,
+				nil),
+			vapp:         &models.VApp{CatalogItemID: "urn:vcloud:catalogitem:abc:fedora"},
+			expectedType: models.VMSourceTemplate,
+			expectedRef:  "urn:vcloud:catalogitem:abc:fedora",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceType, sourceRef, sourceImage := vmLineage(tt.vm, templateInstance, tt.vapp)
+			assert.Equal(t, tt.expectedType, sourceType)
+			assert.Equal(t, tt.expectedRef, sourceRef)
+			assert.Equal(t, tt.expectedImage, sourceImage)
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"strings"
 	"time"
 
 	templatev1 "github.com/openshift/api/template/v1"
@@ -21,14 +23,31 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
+// Labels used to tie VirtualMachines to SSVirt-created TemplateInstances
+const (
+	vappLabel                  = "vapp.ssvirt"
+	templateInstanceOwnerLabel = "template.openshift.io/template-instance-owner"
+	managedByLabel             = "app.kubernetes.io/managed-by"
+	managedByValue             = "ssvirt"
+)
+
+// errUntrustedTemplateInstance indicates a VirtualMachine's owning TemplateInstance
+// was not created by SSVirt in a VDC namespace, so its labels must not be trusted
+var errUntrustedTemplateInstance = errors.New("template instance is not managed by SSVirt")
+
 // VMRepositoryInterface defines the interface for VM repository operations
 type VMRepositoryInterface interface {
 	GetByNamespaceAndVMName(ctx context.Context, namespace, vmName string) (*models.VM, error)
 	GetByVAppAndVMName(ctx context.Context, vappID, vmName string) (*models.VM, error)
 	UpdateStatus(ctx context.Context, vmID string, status string) error
+	UpdateStatusDetails(ctx context.Context, vmID string, details string) error
 	UpdateVMData(ctx context.Context, vmID string, cpuCount *int, memoryMB *int, guestOS string) error
+	UpdateHealthState(ctx context.Context, vmID string, healthState string) error
+	UpdateIPAddress(ctx context.Context, vmID string, ipAddress string) error
 	CreateVM(ctx context.Context, vm *models.VM) error
 }
 
@@ -51,16 +70,19 @@ type VMStatusController struct {
 	VAppRepo VAppRepositoryInterface
 	VDCRepo  VDCRepositoryInterface
 	Recorder record.EventRecorder
+	// StatusBuffer, when set, holds status updates that fail while the database is unavailable
+	StatusBuffer *StatusBuffer
 }
 
 // VMInfo contains extracted information from VirtualMachine resource
 type VMInfo struct {
-	Name      string
-	Namespace string
-	Status    string
-	VAppID    string
-	VDCID     string
-	UpdatedAt time.Time
+	Name          string
+	Namespace     string
+	Status        string
+	StatusDetails string
+	VAppID        string
+	VDCID         string
+	UpdatedAt     time.Time
 }
 
 // VMIData represents the data we extract from VirtualMachineInstance
@@ -71,21 +93,36 @@ type VMIData struct {
 }
 
 // SetupVMStatusController sets up the controller with the Manager
-func SetupVMStatusController(mgr ctrl.Manager, vmRepo VMRepositoryInterface, vappRepo VAppRepositoryInterface, vdcRepo VDCRepositoryInterface) error {
+func SetupVMStatusController(mgr ctrl.Manager, vmRepo VMRepositoryInterface, vappRepo VAppRepositoryInterface, vdcRepo VDCRepositoryInterface, opts ControllerOptions) error {
 	controller := &VMStatusController{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		VMRepo:   vmRepo,
-		VAppRepo: vappRepo,
-		VDCRepo:  vdcRepo,
-		Recorder: mgr.GetEventRecorderFor("vm-status-controller"),
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		VMRepo:       vmRepo,
+		VAppRepo:     vappRepo,
+		VDCRepo:      vdcRepo,
+		Recorder:     mgr.GetEventRecorderFor("vm-status-controller"),
+		StatusBuffer: opts.StatusBuffer,
+	}
+
+	if opts.StatusBuffer != nil {
+		if err := mgr.Add(opts.StatusBuffer); err != nil {
+			return fmt.Errorf("failed to add VM status buffer: %w", err)
+		}
+	}
+
+	// Label VMs created while the controller was down, which no watch event reports
+	sweep := &vmLabelSweep{controller: controller, Reader: mgr.GetAPIReader(), Interval: opts.LabelSweepInterval}
+	if err := mgr.Add(sweep); err != nil {
+		return fmt.Errorf("failed to add VM label sweep: %w", err)
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
+		Named(VMStatusControllerName).
+		WithOptions(opts.controllerOptions(VMStatusControllerName)).
 		For(&kubevirtv1.VirtualMachine{}).
 		Watches(&kubevirtv1.VirtualMachineInstance{},
 			handler.EnqueueRequestsFromMapFunc(controller.mapVMIToVM)).
-		Complete(controller)
+		Complete(opts.wrap(controller))
 }
 
 // Reconcile handles VirtualMachine resource changes
@@ -96,6 +133,7 @@ func SetupVMStatusController(mgr ctrl.Manager, vmRepo VMRepositoryInterface, vap
 //+kubebuilder:rbac:groups=template.openshift.io,resources=templateinstances,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=template.openshift.io,resources=templateinstances/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 
 func (r *VMStatusController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx).WithValues("virtualmachine", req.NamespacedName)
@@ -117,7 +155,7 @@ func (r *VMStatusController) Reconcile(ctx context.Context, req ctrl.Request) (c
 	updated, err := r.ensureVAppLabel(ctx, vm)
 	if err != nil {
 		logger.Error(err, "Failed to ensure vapp.ssvirt label")
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		return ctrl.Result{}, err
 	}
 
 	// If VM was updated, use the updated version for status processing
@@ -131,6 +169,12 @@ func (r *VMStatusController) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return statusResult, err
 	}
 
+	// A buffered status means the database is unavailable; VMI data is refreshed
+	// on the VM's next event once it returns
+	if r.StatusBuffer.Pending(vm.Namespace, vm.Name) {
+		return statusResult, nil
+	}
+
 	// Handle VMI data update
 	vmiResult, err := r.handleVMIDataUpdate(ctx, vm)
 	if err != nil {
@@ -165,13 +209,32 @@ func (r *VMStatusController) handleVMStatusUpdate(ctx context.Context, vm *kubev
 		}
 		logger.Error(err, "Failed to find or create VM record")
 		recordVMReconcileError(vm.Namespace, vm.Name, "database_lookup_error")
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		if repositories.IsTransientError(err) && r.bufferStatus(ctx, vm.Namespace, vm.Name, mapVMStatus(vm)) {
+			// The record may not exist yet, and replay only updates existing
+			// records, so reconcile again to create it once the database returns
+			return ctrl.Result{RequeueAfter: r.StatusBuffer.opts.ReplayInterval}, nil
+		}
+		return ctrl.Result{}, err
 	}
 
 	// Extract current status and info
 	vmInfo := r.extractVMInfo(vm)
 	oldStatus := vmRecord.Status
 
+	// Earlier updates for this VM are still waiting for replay; queue behind them
+	// so the database never moves backwards
+	if r.StatusBuffer.Pending(vm.Namespace, vm.Name) {
+		r.bufferStatus(ctx, vm.Namespace, vm.Name, vmInfo.Status)
+		return ctrl.Result{}, nil
+	}
+
+	if vmRecord.StatusDetails != vmInfo.StatusDetails {
+		if err := r.VMRepo.UpdateStatusDetails(ctx, vmRecord.ID, vmInfo.StatusDetails); err != nil {
+			logger.Error(err, "Failed to update VM status details in database")
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Check if update is needed
 	if vmRecord.Status == vmInfo.Status &&
 		vmRecord.UpdatedAt.After(vmInfo.UpdatedAt.Add(-time.Minute)) {
@@ -198,7 +261,10 @@ func (r *VMStatusController) handleVMStatusUpdate(ctx context.Context, vm *kubev
 		recordVMReconcileError(vm.Namespace, vm.Name, "database_update_error")
 		r.Recorder.Event(vm, "Warning", "DatabaseUpdateFailed",
 			fmt.Sprintf("Failed to update VM status in database: %v", err))
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		if repositories.IsTransientError(err) && r.bufferStatus(ctx, vm.Namespace, vm.Name, vmInfo.Status) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
 	}
 
 	// Record successful update
@@ -234,7 +300,15 @@ func (r *VMStatusController) handleVMDeletion(ctx context.Context, namespacedNam
 		}
 		logger.Error(err, "Failed to find VM record for deletion")
 		recordVMReconcileError(namespace, vmName, "deletion_lookup_error")
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		if repositories.IsTransientError(err) && r.bufferStatus(ctx, namespace, vmName, "DELETED") {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if r.StatusBuffer.Pending(namespace, vmName) {
+		r.bufferStatus(ctx, namespace, vmName, "DELETED")
+		return ctrl.Result{}, nil
 	}
 
 	// Update VM status to indicate deletion
@@ -244,7 +318,10 @@ func (r *VMStatusController) handleVMDeletion(ctx context.Context, namespacedNam
 		logger.Error(err, "Failed to update VM status to DELETED")
 		recordVMDeletion(namespace, vmName, "error")
 		recordVMReconcileError(namespace, vmName, "deletion_update_error")
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		if repositories.IsTransientError(err) && r.bufferStatus(ctx, namespace, vmName, "DELETED") {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
 	}
 
 	recordVMDeletion(namespace, vmName, "success")
@@ -252,6 +329,25 @@ func (r *VMStatusController) handleVMDeletion(ctx context.Context, namespacedNam
 	return ctrl.Result{}, nil
 }
 
+// bufferStatus queues a status update for replay when the database is
+// unavailable; callers only buffer updates that failed with a transient
+// database error. It returns false if no buffer is configured or the update was
+// rejected, in which case the caller should fail the reconcile so it is retried.
+func (r *VMStatusController) bufferStatus(ctx context.Context, namespace, vmName, status string) bool {
+	if r.StatusBuffer == nil {
+		return false
+	}
+
+	logger := log.FromContext(ctx).WithValues("vm", vmName, "namespace", namespace)
+	if !r.StatusBuffer.Enqueue(PendingStatusUpdate{Namespace: namespace, VMName: vmName, Status: status}) {
+		logger.Info("VM status buffer full, update rejected", "status", status)
+		return false
+	}
+
+	logger.Info("Buffered VM status update until the database is available", "status", status)
+	return true
+}
+
 // handleVMIDataUpdate processes VirtualMachineInstance data for existing fields
 func (r *VMStatusController) handleVMIDataUpdate(ctx context.Context, vm *kubevirtv1.VirtualMachine) (ctrl.Result, error) {
 	logger := log.FromContext(ctx).WithValues("vm", vm.Name, "namespace", vm.Namespace)
@@ -265,7 +361,7 @@ func (r *VMStatusController) handleVMIDataUpdate(ctx context.Context, vm *kubevi
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Failed to find VM record for VMI data update")
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		return ctrl.Result{}, err
 	}
 
 	// Try to find corresponding VMI
@@ -274,11 +370,25 @@ func (r *VMStatusController) handleVMIDataUpdate(ctx context.Context, vm *kubevi
 
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
-			// VMI doesn't exist - VM is not running, use VM spec defaults
+			// VMI doesn't exist - VM is not running, so its health cannot be assessed
+			if err := r.updateHealthState(ctx, vm, vmRecord, evaluateVMIHealth(nil, nil)); err != nil {
+				return ctrl.Result{}, err
+			}
+			if err := r.updateIPAddress(ctx, vm, vmRecord, ""); err != nil {
+				return ctrl.Result{}, err
+			}
+			// Use VM spec defaults
 			return r.handleVMSpecData(ctx, vm, vmRecord)
 		}
 		logger.Error(err, "Failed to get VirtualMachineInstance")
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		return ctrl.Result{}, err
+	}
+
+	if err := r.updateHealthState(ctx, vm, vmRecord, r.vmiHealthState(ctx, vmi)); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateIPAddress(ctx, vm, vmRecord, vmiIPAddress(vmi)); err != nil {
+		return ctrl.Result{}, err
 	}
 
 	// Extract data from VMI
@@ -296,7 +406,7 @@ func (r *VMStatusController) handleVMIDataUpdate(ctx context.Context, vm *kubevi
 		r.Recorder.Event(vm, "Warning", "VMDataUpdateFailed",
 			fmt.Sprintf("Failed to update VM data: %v", err))
 		logger.Error(err, "Failed to update VM data in database")
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		return ctrl.Result{}, err
 	}
 
 	logger.Info("Updated VM data from VMI",
@@ -328,7 +438,7 @@ func (r *VMStatusController) handleVMSpecData(ctx context.Context, vm *kubevirtv
 	err := r.VMRepo.UpdateVMData(ctx, vmRecord.ID, specData.CPUCount, specData.MemoryMB, specData.GuestOS)
 	if err != nil {
 		logger.Error(err, "Failed to update VM data from spec")
-		return ctrl.Result{RequeueAfter: time.Minute}, err
+		return ctrl.Result{}, err
 	}
 
 	logger.Info("Updated VM data from VM spec",
@@ -371,19 +481,38 @@ func (r *VMStatusController) findOrCreateVMRecord(ctx context.Context, vm *kubev
 
 	// Strategy 3: VM doesn't exist, check if we should create it
 	// Only create if the VM has a vapp.ssvirt label (meaning it was created from a TemplateInstance)
-	vappName, hasVAppName := vm.Labels["vapp.ssvirt"]
+	vappName, hasVAppName := vm.Labels[vappLabel]
 	if !hasVAppName || vappName == "" {
 		// VM doesn't have vapp.ssvirt label, not managed by SSVirt
 		return nil, gorm.ErrRecordNotFound
 	}
 
+	// Anyone who can label a VM can set vapp.ssvirt, so only trust it when it
+	// names the SSVirt-created TemplateInstance that owns the VM
+	templateInstance, err := r.trustedTemplateInstance(ctx, vm)
+	if err != nil {
+		if errors.Is(err, errUntrustedTemplateInstance) || k8serrors.IsNotFound(err) {
+			logger.Info("Refusing to create records for VM with untrusted vapp.ssvirt label", "vappName", vappName, "reason", err.Error())
+			recordVMSkipped(vm.Namespace, vm.Name, "untrusted_label")
+			return nil, gorm.ErrRecordNotFound
+		}
+		return nil, err
+	}
+	if templateInstance.Name != vappName {
+		logger.Info("Refusing to create records for VM whose vapp.ssvirt label does not match its TemplateInstance",
+			"vappName", vappName, "templateInstance", templateInstance.Name)
+		recordVMSkipped(vm.Namespace, vm.Name, "untrusted_label")
+		return nil, gorm.ErrRecordNotFound
+	}
+
 	// Create the VM record
 	logger.Info("Creating new VM record", "vappName", vappName)
-	return r.createVMRecord(ctx, vm, vappName)
+	return r.createVMRecord(ctx, vm, templateInstance)
 }
 
-// createVMRecord creates a new VM record in the database
-func (r *VMStatusController) createVMRecord(ctx context.Context, vm *kubevirtv1.VirtualMachine, vappName string) (*models.VM, error) {
+// createVMRecord creates a new VM record in the database for a VM created by templateInstance
+func (r *VMStatusController) createVMRecord(ctx context.Context, vm *kubevirtv1.VirtualMachine, templateInstance *templatev1.TemplateInstance) (*models.VM, error) {
+	vappName := templateInstance.Name
 	logger := log.FromContext(ctx).WithValues("vm", vm.Name, "namespace", vm.Namespace, "vapp", vappName)
 
 	// Find VDC by namespace
@@ -397,7 +526,7 @@ func (r *VMStatusController) createVMRecord(ctx context.Context, vm *kubevirtv1.
 	}
 
 	// Find or create VApp
-	vapp, err := r.findOrCreateVApp(ctx, vdc.ID, vappName)
+	vapp, err := r.findOrCreateVApp(ctx, vdc.ID, templateInstance)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find or create VApp: %w", err)
 	}
@@ -416,6 +545,17 @@ func (r *VMStatusController) createVMRecord(ctx context.Context, vm *kubevirtv1.
 		UpdatedAt: time.Now(),
 	}
 
+	vmRecord.SourceType, vmRecord.SourceRef, vmRecord.SourceImage = vmLineage(vm, templateInstance, vapp)
+	vmRecord.BIOSUUID, vmRecord.SerialNumber = firmwareIdentity(vm)
+
+	// Honor display name and description annotations set through the API
+	if displayName := vm.Annotations[services.VMDisplayNameAnnotation]; displayName != "" {
+		vmRecord.Name = displayName
+	}
+	if description, ok := vm.Annotations[services.VMDescriptionAnnotation]; ok {
+		vmRecord.Description = description
+	}
+
 	err = r.VMRepo.CreateVM(ctx, vmRecord)
 	if err != nil {
 		recordVMCreationOperation(vm.Namespace, vm.Name, vappName, "error")
@@ -430,8 +570,20 @@ func (r *VMStatusController) createVMRecord(ctx context.Context, vm *kubevirtv1.
 	return vmRecord, nil
 }
 
-// findOrCreateVApp finds or creates a VApp record
-func (r *VMStatusController) findOrCreateVApp(ctx context.Context, vdcID, vappName string) (*models.VApp, error) {
+// firmwareIdentity returns the BIOS UUID and SMBIOS serial number set in the
+// VirtualMachine spec
+func firmwareIdentity(vm *kubevirtv1.VirtualMachine) (string, string) {
+	if vm.Spec.Template == nil || vm.Spec.Template.Spec.Domain.Firmware == nil {
+		return "", ""
+	}
+	firmware := vm.Spec.Template.Spec.Domain.Firmware
+	return string(firmware.UUID), firmware.Serial
+}
+
+// findOrCreateVApp finds or creates the VApp record for a TemplateInstance. vApps
+// are named after their TemplateInstance.
+func (r *VMStatusController) findOrCreateVApp(ctx context.Context, vdcID string, templateInstance *templatev1.TemplateInstance) (*models.VApp, error) {
+	vappName := templateInstance.Name
 	logger := log.FromContext(ctx).WithValues("vdc", vdcID, "vapp", vappName)
 
 	// Try to find existing VApp
@@ -446,12 +598,14 @@ func (r *VMStatusController) findOrCreateVApp(ctx context.Context, vdcID, vappNa
 	// VApp doesn't exist, create it
 	logger.Info("Creating new VApp record")
 	vapp = &models.VApp{
-		Name:        vappName,
-		VDCID:       vdcID,
-		Status:      models.VAppStatusInstantiating, // Initial status for new vApps
-		Description: fmt.Sprintf("VApp created from OpenShift TemplateInstance: %s", vappName),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Name:                 vappName,
+		VDCID:                vdcID,
+		TemplateInstanceName: templateInstance.Name,
+		TemplateName:         templateInstance.Spec.Template.Name,
+		Status:               models.VAppStatusInstantiating, // Initial status for new vApps
+		Description:          fmt.Sprintf("VApp created from OpenShift TemplateInstance: %s", vappName),
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
 	}
 
 	err = r.VAppRepo.CreateVApp(ctx, vapp)
@@ -473,6 +627,9 @@ func (r *VMStatusController) extractVMInfo(vm *kubevirtv1.VirtualMachine) VMInfo
 		Status:    mapVMStatus(vm),
 		UpdatedAt: time.Now(),
 	}
+	if info.Status == models.VMStatusQuotaExceeded {
+		info.StatusDetails = quotaExceededMessage(vm)
+	}
 
 	// Extract labels for VM identification
 	if vm.Labels != nil {
@@ -490,6 +647,12 @@ func mapVMStatus(vm *kubevirtv1.VirtualMachine) string {
 		return "DELETING"
 	}
 
+	// A VM held back by the VDC's ResourceQuota otherwise looks like it is
+	// still starting
+	if quotaExceededMessage(vm) != "" {
+		return models.VMStatusQuotaExceeded
+	}
+
 	// Map based on VM PrintableStatus
 	switch vm.Status.PrintableStatus {
 	case kubevirtv1.VirtualMachineStatusRunning:
@@ -538,13 +701,38 @@ func mapVMStatus(vm *kubevirtv1.VirtualMachine) string {
 	}
 }
 
+// quotaFailureReasons are the condition reasons KubeVirt reports when the
+// virt-launcher pod of a starting VM cannot be created or scheduled
+var quotaFailureReasons = map[string]bool{
+	"FailedCreate":  true,
+	"Unschedulable": true,
+	string(kubevirtv1.VirtualMachineStatusUnschedulable): true,
+}
+
+// quotaExceededMessage returns the reason a starting VM's virt-launcher pod was
+// rejected by its namespace's ResourceQuota, or "" when it was not
+func quotaExceededMessage(vm *kubevirtv1.VirtualMachine) string {
+	switch vm.Status.PrintableStatus {
+	case "", kubevirtv1.VirtualMachineStatusStarting, kubevirtv1.VirtualMachineStatusProvisioning,
+		kubevirtv1.VirtualMachineStatusUnschedulable:
+	default:
+		return ""
+	}
+	for _, condition := range vm.Status.Conditions {
+		if quotaFailureReasons[condition.Reason] && strings.Contains(condition.Message, "exceeded quota") {
+			return condition.Message
+		}
+	}
+	return ""
+}
+
 // ensureVAppLabel ensures the vapp.ssvirt label is set correctly on the VirtualMachine
 func (r *VMStatusController) ensureVAppLabel(ctx context.Context, vm *kubevirtv1.VirtualMachine) (*kubevirtv1.VirtualMachine, error) {
 	logger := log.FromContext(ctx).WithValues("vm", vm.Name, "namespace", vm.Namespace)
 
 	// Check if vapp.ssvirt label already exists
 	if vm.Labels != nil {
-		if _, exists := vm.Labels["vapp.ssvirt"]; exists {
+		if _, exists := vm.Labels[vappLabel]; exists {
 			// Label already exists, no need to update
 			recordVMLabelOperation(vm.Namespace, vm.Name, "check", "exists")
 			return nil, nil
@@ -552,7 +740,7 @@ func (r *VMStatusController) ensureVAppLabel(ctx context.Context, vm *kubevirtv1
 	}
 
 	// Look for template instance owner label
-	templateInstanceUID, hasTemplateLabel := vm.Labels["template.openshift.io/template-instance-owner"]
+	templateInstanceUID, hasTemplateLabel := vm.Labels[templateInstanceOwnerLabel]
 	if !hasTemplateLabel || templateInstanceUID == "" {
 		// No template instance, skip label management
 		logger.V(1).Info("No template instance owner label found, skipping vapp.ssvirt label")
@@ -560,14 +748,21 @@ func (r *VMStatusController) ensureVAppLabel(ctx context.Context, vm *kubevirtv1
 		return nil, nil
 	}
 
-	// Find the TemplateInstance by UID
-	templateInstance, err := r.findTemplateInstanceByUID(ctx, templateInstanceUID)
+	// Find the TemplateInstance and make sure SSVirt created it
+	templateInstance, err := r.trustedTemplateInstance(ctx, vm)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			logger.V(1).Info("TemplateInstance not found", "uid", templateInstanceUID)
 			recordVMLabelOperation(vm.Namespace, vm.Name, "lookup", "not_found")
 			return nil, nil
 		}
+		if errors.Is(err, errUntrustedTemplateInstance) {
+			logger.Info("Not labeling VM owned by untrusted TemplateInstance", "uid", templateInstanceUID, "reason", err.Error())
+			recordVMLabelOperation(vm.Namespace, vm.Name, "lookup", "untrusted")
+			r.Recorder.Event(vm, "Warning", "UntrustedTemplateInstance",
+				fmt.Sprintf("Ignoring template instance owner %s: %v", templateInstanceUID, err))
+			return nil, nil
+		}
 		logger.Error(err, "Failed to find TemplateInstance", "uid", templateInstanceUID)
 		recordVMLabelOperation(vm.Namespace, vm.Name, "lookup", "error")
 		return nil, err
@@ -576,30 +771,45 @@ func (r *VMStatusController) ensureVAppLabel(ctx context.Context, vm *kubevirtv1
 	// Set the vapp.ssvirt label and controller reference to the TemplateInstance
 	logger.Info("Setting vapp.ssvirt label and controller reference", "templateInstance", templateInstance.Name)
 
-	// Create a copy to modify
+	// Patch only the label and owner reference on a copy, so labels and
+	// annotations set by instantiation or by other tools, possibly after vm was
+	// read, are kept. Losing a race with another writer refetches the VM.
 	vmCopy := vm.DeepCopy()
-	if vmCopy.Labels == nil {
-		vmCopy.Labels = make(map[string]string)
-	}
-	vmCopy.Labels["vapp.ssvirt"] = templateInstance.Name
-
-	// Set controller reference to TemplateInstance
-	err = controllerutil.SetControllerReference(templateInstance, vmCopy, r.Scheme)
-	if err != nil {
-		logger.Error(err, "Failed to set controller reference")
+	var refErr error
+	labelled := false
+	err = patchWithRetry(ctx, r.Client, vmCopy, func() (bool, error) {
+		labelled = false
+		if _, exists := vmCopy.Labels[vappLabel]; exists {
+			return false, nil
+		}
+		if vmCopy.Labels == nil {
+			vmCopy.Labels = make(map[string]string)
+		}
+		vmCopy.Labels[vappLabel] = templateInstance.Name
+		// Set controller reference to TemplateInstance
+		if refErr = controllerutil.SetControllerReference(templateInstance, vmCopy, r.Scheme); refErr != nil {
+			return false, refErr
+		}
+		labelled = true
+		return true, nil
+	})
+	if refErr != nil {
+		logger.Error(refErr, "Failed to set controller reference")
 		recordVMReconcileError(vm.Namespace, vm.Name, "controller_reference_error")
 		recordVMLabelOperation(vm.Namespace, vm.Name, "update", "error")
-		return nil, err
+		return nil, refErr
 	}
-
-	// Update the VirtualMachine
-	err = r.Update(ctx, vmCopy)
 	if err != nil {
 		logger.Error(err, "Failed to update VirtualMachine with vapp.ssvirt label and controller reference")
 		recordVMReconcileError(vm.Namespace, vm.Name, "label_update_error")
 		recordVMLabelOperation(vm.Namespace, vm.Name, "update", "error")
 		return nil, err
 	}
+	if !labelled {
+		// Another writer labelled the VM first
+		recordVMLabelOperation(vm.Namespace, vm.Name, "check", "exists")
+		return vmCopy, nil
+	}
 
 	logger.Info("Successfully set vapp.ssvirt label and controller reference", "templateInstance", templateInstance.Name)
 	recordVMLabelOperation(vm.Namespace, vm.Name, "update", "success")
@@ -609,11 +819,41 @@ func (r *VMStatusController) ensureVAppLabel(ctx context.Context, vm *kubevirtv1
 	return vmCopy, nil
 }
 
-// findTemplateInstanceByUID finds a TemplateInstance by its UID across all namespaces
-func (r *VMStatusController) findTemplateInstanceByUID(ctx context.Context, uid string) (*templatev1.TemplateInstance, error) {
-	// List all TemplateInstances across all namespaces
+// trustedTemplateInstance returns the TemplateInstance named by the VM's
+// template-instance-owner label, provided it was created by SSVirt in a VDC
+// namespace. Otherwise it returns a NotFound error or errUntrustedTemplateInstance.
+func (r *VMStatusController) trustedTemplateInstance(ctx context.Context, vm *kubevirtv1.VirtualMachine) (*templatev1.TemplateInstance, error) {
+	uid := vm.Labels[templateInstanceOwnerLabel]
+	if uid == "" {
+		return nil, fmt.Errorf("%w: no %s label", errUntrustedTemplateInstance, templateInstanceOwnerLabel)
+	}
+
+	// TemplateInstances create their objects in their own namespace, so an owner
+	// in any other namespace cannot be legitimate
+	templateInstance, err := r.findTemplateInstanceByUID(ctx, vm.Namespace, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if templateInstance.Labels[managedByLabel] != managedByValue {
+		return nil, fmt.Errorf("%w: %s/%s lacks %s=%s label", errUntrustedTemplateInstance,
+			templateInstance.Namespace, templateInstance.Name, managedByLabel, managedByValue)
+	}
+
+	if _, err := r.VDCRepo.GetByNamespace(ctx, templateInstance.Namespace); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: namespace %s is not managed by a VDC", errUntrustedTemplateInstance, templateInstance.Namespace)
+		}
+		return nil, fmt.Errorf("failed to find VDC by namespace: %w", err)
+	}
+
+	return templateInstance, nil
+}
+
+// findTemplateInstanceByUID finds a TemplateInstance by its UID within a namespace
+func (r *VMStatusController) findTemplateInstanceByUID(ctx context.Context, namespace, uid string) (*templatev1.TemplateInstance, error) {
 	templateInstanceList := &templatev1.TemplateInstanceList{}
-	err := r.List(ctx, templateInstanceList)
+	err := r.List(ctx, templateInstanceList, client.InNamespace(namespace))
 	if err != nil {
 		return nil, fmt.Errorf("failed to list TemplateInstances: %w", err)
 	}
@@ -682,6 +922,40 @@ func extractVMIData(vmi *kubevirtv1.VirtualMachineInstance) VMIData {
 	return data
 }
 
+// vmiIPAddress returns the first IP address the VMI's interfaces report,
+// preferring IPv4, or "" before the guest has one
+func vmiIPAddress(vmi *kubevirtv1.VirtualMachineInstance) string {
+	first := ""
+	for _, iface := range vmi.Status.Interfaces {
+		ip := net.ParseIP(iface.IP)
+		if ip == nil || ip.IsLinkLocalUnicast() {
+			continue
+		}
+		if ip.To4() != nil {
+			return ip.String()
+		}
+		if first == "" {
+			first = ip.String()
+		}
+	}
+	return first
+}
+
+// updateIPAddress records the VM's IP address if it changed
+func (r *VMStatusController) updateIPAddress(ctx context.Context, vm *kubevirtv1.VirtualMachine, vmRecord *models.VM, ipAddress string) error {
+	if vmRecord.IPAddress == ipAddress {
+		return nil
+	}
+	logger := log.FromContext(ctx).WithValues("vm", vm.Name, "namespace", vm.Namespace)
+	if err := r.VMRepo.UpdateIPAddress(ctx, vmRecord.ID, ipAddress); err != nil {
+		logger.Error(err, "Failed to update VM IP address in database")
+		return err
+	}
+	logger.Info("Updated VM IP address", "vmID", vmRecord.ID, "ipAddress", ipAddress)
+	vmRecord.IPAddress = ipAddress
+	return nil
+}
+
 // extractVMSpecData extracts data from VirtualMachine specification when VMI doesn't exist
 func extractVMSpecData(vm *kubevirtv1.VirtualMachine) VMIData {
 	data := VMIData{}
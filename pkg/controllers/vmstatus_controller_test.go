@@ -9,6 +9,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -50,6 +51,11 @@ func (m *MockVMRepository) UpdateStatus(ctx context.Context, vmID string, status
 	return args.Error(0)
 }
 
+func (m *MockVMRepository) UpdateStatusDetails(ctx context.Context, vmID string, details string) error {
+	args := m.Called(ctx, vmID, details)
+	return args.Error(0)
+}
+
 func (m *MockVMRepository) CreateVM(ctx context.Context, vm *models.VM) error {
 	args := m.Called(ctx, vm)
 	return args.Error(0)
@@ -60,6 +66,16 @@ func (m *MockVMRepository) UpdateVMData(ctx context.Context, vmID string, cpuCou
 	return args.Error(0)
 }
 
+func (m *MockVMRepository) UpdateHealthState(ctx context.Context, vmID string, healthState string) error {
+	args := m.Called(ctx, vmID, healthState)
+	return args.Error(0)
+}
+
+func (m *MockVMRepository) UpdateIPAddress(ctx context.Context, vmID string, ipAddress string) error {
+	args := m.Called(ctx, vmID, ipAddress)
+	return args.Error(0)
+}
+
 // MockVAppRepository mocks the VApp repository
 type MockVAppRepository struct {
 	mock.Mock
@@ -172,6 +188,43 @@ func TestVMStatusController_Reconcile(t *testing.T) {
 			expectedError:  false,
 			expectedEvents: 1,
 		},
+		{
+			name: "VM blocked by quota records why",
+			setupVM: func() *kubevirtv1.VirtualMachine {
+				return &kubevirtv1.VirtualMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-vm",
+						Namespace: "test-namespace",
+						Labels: map[string]string{
+							"vapp.ssvirt.io/vapp-id": "vapp-123",
+						},
+					},
+					Status: kubevirtv1.VirtualMachineStatus{
+						PrintableStatus: kubevirtv1.VirtualMachineStatusStarting,
+						Conditions:      []kubevirtv1.VirtualMachineCondition{quotaExceededCondition},
+					},
+				}
+			},
+			setupRepo: func(repo *MockVMRepository) {
+				vm := &models.VM{
+					ID:        "vm-123",
+					Name:      "test-vm",
+					VMName:    "test-vm",
+					Namespace: "test-namespace",
+					Status:    "POWERING_ON",
+					UpdatedAt: time.Now().Add(-5 * time.Minute),
+				}
+				repo.On("GetByVAppAndVMName", mock.Anything, "vapp-123", "test-vm").
+					Return(vm, nil)
+				repo.On("UpdateStatusDetails", mock.Anything, "vm-123", quotaExceededCondition.Message).
+					Return(nil)
+				repo.On("UpdateStatus", mock.Anything, "vm-123", models.VMStatusQuotaExceeded).
+					Return(nil)
+			},
+			expectedResult: ctrl.Result{},
+			expectedError:  false,
+			expectedEvents: 1,
+		},
 		{
 			name: "VM not managed by SSVirt",
 			setupVM: func() *kubevirtv1.VirtualMachine {
@@ -223,7 +276,7 @@ func TestVMStatusController_Reconcile(t *testing.T) {
 				repo.On("UpdateStatus", mock.Anything, "vm-123", "POWERED_ON").
 					Return(assert.AnError)
 			},
-			expectedResult: ctrl.Result{RequeueAfter: time.Minute},
+			expectedResult: ctrl.Result{}, // Retried with backoff by the workqueue rate limiter
 			expectedError:  true,
 			expectedEvents: 1, // Warning event
 		},
@@ -412,6 +465,26 @@ func TestMapVMStatus(t *testing.T) {
 			},
 			expected: "UNKNOWN",
 		},
+		{
+			name: "VM starting beyond its namespace quota",
+			vm: &kubevirtv1.VirtualMachine{
+				Status: kubevirtv1.VirtualMachineStatus{
+					PrintableStatus: kubevirtv1.VirtualMachineStatusStarting,
+					Conditions:      []kubevirtv1.VirtualMachineCondition{quotaExceededCondition},
+				},
+			},
+			expected: models.VMStatusQuotaExceeded,
+		},
+		{
+			name: "Running VM with a stale quota failure",
+			vm: &kubevirtv1.VirtualMachine{
+				Status: kubevirtv1.VirtualMachineStatus{
+					PrintableStatus: kubevirtv1.VirtualMachineStatusRunning,
+					Conditions:      []kubevirtv1.VirtualMachineCondition{quotaExceededCondition},
+				},
+			},
+			expected: "POWERED_ON",
+		},
 	}
 
 	for _, tt := range tests {
@@ -422,6 +495,37 @@ func TestMapVMStatus(t *testing.T) {
 	}
 }
 
+// quotaExceededCondition is reported by KubeVirt when the namespace's
+// ResourceQuota rejects a VM's virt-launcher pod
+var quotaExceededCondition = kubevirtv1.VirtualMachineCondition{
+	Type:    kubevirtv1.VirtualMachineFailure,
+	Status:  corev1.ConditionTrue,
+	Reason:  "FailedCreate",
+	Message: `failed to create virtual machine pod: pods "virt-launcher-web-abcde" is forbidden: exceeded quota: vdc-quota, requested: requests.memory=2Gi, used: requests.memory=7Gi, limited: requests.memory=8Gi`,
+}
+
+func TestExtractVMInfoQuotaExceeded(t *testing.T) {
+	vm := &kubevirtv1.VirtualMachine{
+		Status: kubevirtv1.VirtualMachineStatus{
+			PrintableStatus: kubevirtv1.VirtualMachineStatusProvisioning,
+			Conditions: []kubevirtv1.VirtualMachineCondition{
+				{Type: kubevirtv1.VirtualMachineReady, Status: corev1.ConditionFalse, Reason: "VMINotExists"},
+				quotaExceededCondition,
+			},
+		},
+	}
+
+	info := (&VMStatusController{}).extractVMInfo(vm)
+	assert.Equal(t, models.VMStatusQuotaExceeded, info.Status)
+	assert.Equal(t, quotaExceededCondition.Message, info.StatusDetails)
+
+	// Other pod creation failures keep the usual status
+	vm.Status.Conditions[1].Message = `pods "virt-launcher-web-abcde" is forbidden: violates PodSecurity`
+	info = (&VMStatusController{}).extractVMInfo(vm)
+	assert.Equal(t, "STARTING", info.Status)
+	assert.Empty(t, info.StatusDetails)
+}
+
 func TestExtractVMInfo(t *testing.T) {
 	vm := &kubevirtv1.VirtualMachine{
 		ObjectMeta: metav1.ObjectMeta{
@@ -542,6 +646,7 @@ func TestEnsureVAppLabel(t *testing.T) {
 		name          string
 		vm            *kubevirtv1.VirtualMachine
 		templateInst  *templatev1.TemplateInstance
+		vdcNamespaces []string
 		expectedLabel string
 		expectUpdate  bool
 		expectError   bool
@@ -577,12 +682,90 @@ func TestEnsureVAppLabel(t *testing.T) {
 					Name:      "my-template-instance",
 					Namespace: "test-namespace",
 					UID:       "test-template-uid",
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by": "ssvirt",
+					},
 				},
 			},
+			vdcNamespaces: []string{"test-namespace"},
 			expectedLabel: "my-template-instance",
 			expectUpdate:  true,
 			expectError:   false,
 		},
+		{
+			name: "TemplateInstance not created by SSVirt - no update",
+			vm: &kubevirtv1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-namespace",
+					Labels: map[string]string{
+						"template.openshift.io/template-instance-owner": "test-template-uid",
+					},
+				},
+			},
+			templateInst: &templatev1.TemplateInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "fake-vapp",
+					Namespace: "test-namespace",
+					UID:       "test-template-uid",
+				},
+			},
+			vdcNamespaces: []string{"test-namespace"},
+			expectedLabel: "",
+			expectUpdate:  false,
+			expectError:   false,
+		},
+		{
+			name: "TemplateInstance in another namespace - no update",
+			vm: &kubevirtv1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-namespace",
+					Labels: map[string]string{
+						"template.openshift.io/template-instance-owner": "test-template-uid",
+					},
+				},
+			},
+			templateInst: &templatev1.TemplateInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "tenant-vapp",
+					Namespace: "tenant-namespace",
+					UID:       "test-template-uid",
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by": "ssvirt",
+					},
+				},
+			},
+			vdcNamespaces: []string{"test-namespace", "tenant-namespace"},
+			expectedLabel: "",
+			expectUpdate:  false,
+			expectError:   false,
+		},
+		{
+			name: "TemplateInstance outside a VDC namespace - no update",
+			vm: &kubevirtv1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-namespace",
+					Labels: map[string]string{
+						"template.openshift.io/template-instance-owner": "test-template-uid",
+					},
+				},
+			},
+			templateInst: &templatev1.TemplateInstance{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-template-instance",
+					Namespace: "test-namespace",
+					UID:       "test-template-uid",
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by": "ssvirt",
+					},
+				},
+			},
+			expectedLabel: "",
+			expectUpdate:  false,
+			expectError:   false,
+		},
 		{
 			name: "VM without template instance owner - no update",
 			vm: &kubevirtv1.VirtualMachine{
@@ -630,11 +813,18 @@ func TestEnsureVAppLabel(t *testing.T) {
 				WithObjects(objs...).
 				Build()
 
+			mockVDCRepo := new(MockVDCRepository)
+			for _, ns := range tt.vdcNamespaces {
+				mockVDCRepo.On("GetByNamespace", mock.Anything, ns).Return(&models.VDC{ID: "vdc-" + ns}, nil)
+			}
+			mockVDCRepo.On("GetByNamespace", mock.Anything, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+
 			// Create controller with fake client
 			mockRecorder := &MockEventRecorder{}
 			controller := &VMStatusController{
 				Client:   fakeClient,
 				Scheme:   scheme,
+				VDCRepo:  VDCRepositoryInterface(mockVDCRepo),
 				Recorder: mockRecorder,
 			}
 
@@ -685,6 +875,138 @@ func TestEnsureVAppLabel(t *testing.T) {
 	}
 }
 
+func TestEnsureVAppLabelPreservesMetadata(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+	_ = templatev1.AddToScheme(scheme)
+
+	vm := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-vm",
+			Namespace: "test-namespace",
+			Labels: map[string]string{
+				templateInstanceOwnerLabel: "test-template-uid",
+				"cost-center":              "cc-1234",
+			},
+			Annotations: map[string]string{"backup.example.com/policy": "daily"},
+		},
+	}
+	templateInstance := &templatev1.TemplateInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-template-instance",
+			Namespace: "test-namespace",
+			UID:       "test-template-uid",
+			Labels:    map[string]string{managedByLabel: managedByValue},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vm, templateInstance).Build()
+	ctx := context.Background()
+
+	// Another tool labels the VM after the controller read it
+	stale := &kubevirtv1.VirtualMachine{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-vm", Namespace: "test-namespace"}, stale))
+	current := stale.DeepCopy()
+	current.Labels["backup.example.com/last-run"] = "ok"
+	require.NoError(t, fakeClient.Update(ctx, current))
+
+	mockVDCRepo := new(MockVDCRepository)
+	mockVDCRepo.On("GetByNamespace", mock.Anything, "test-namespace").Return(&models.VDC{ID: "vdc-1"}, nil)
+	controller := &VMStatusController{
+		Client:   fakeClient,
+		Scheme:   scheme,
+		VDCRepo:  VDCRepositoryInterface(mockVDCRepo),
+		Recorder: &MockEventRecorder{},
+	}
+	updated, err := controller.ensureVAppLabel(ctx, stale)
+	require.NoError(t, err)
+	require.NotNil(t, updated)
+
+	var vmInClient kubevirtv1.VirtualMachine
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "test-vm", Namespace: "test-namespace"}, &vmInClient))
+	assert.Equal(t, "my-template-instance", vmInClient.Labels[vappLabel])
+	assert.Equal(t, "cc-1234", vmInClient.Labels["cost-center"])
+	assert.Equal(t, "ok", vmInClient.Labels["backup.example.com/last-run"])
+	assert.Equal(t, "daily", vmInClient.Annotations["backup.example.com/policy"])
+	require.Len(t, vmInClient.OwnerReferences, 1)
+	assert.Equal(t, "my-template-instance", vmInClient.OwnerReferences[0].Name)
+}
+
+func TestFindOrCreateVMRecordRejectsSpoofedLabel(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+	_ = templatev1.AddToScheme(scheme)
+
+	templateInst := &templatev1.TemplateInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "real-vapp",
+			Namespace: "test-namespace",
+			UID:       "real-uid",
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "ssvirt",
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+	}{
+		{
+			name: "vapp.ssvirt label without template instance owner",
+			labels: map[string]string{
+				"vapp.ssvirt": "real-vapp",
+			},
+		},
+		{
+			name: "vapp.ssvirt label naming a different vApp",
+			labels: map[string]string{
+				"vapp.ssvirt": "other-vapp",
+				"template.openshift.io/template-instance-owner": "real-uid",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := &kubevirtv1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-vm",
+					Namespace: "test-namespace",
+					Labels:    tt.labels,
+				},
+			}
+
+			mockVMRepo := new(MockVMRepository)
+			mockVMRepo.On("GetByNamespaceAndVMName", mock.Anything, "test-namespace", "test-vm").
+				Return(nil, gorm.ErrRecordNotFound)
+			mockVAppRepo := new(MockVAppRepository)
+			mockVDCRepo := new(MockVDCRepository)
+			mockVDCRepo.On("GetByNamespace", mock.Anything, "test-namespace").
+				Return(&models.VDC{ID: "vdc-123"}, nil)
+
+			controller := &VMStatusController{
+				Client: fake.NewClientBuilder().
+					WithScheme(scheme).
+					WithObjects(vm, templateInst).
+					Build(),
+				Scheme:   scheme,
+				VMRepo:   VMRepositoryInterface(mockVMRepo),
+				VAppRepo: VAppRepositoryInterface(mockVAppRepo),
+				VDCRepo:  VDCRepositoryInterface(mockVDCRepo),
+				Recorder: &MockEventRecorder{},
+			}
+
+			record, err := controller.findOrCreateVMRecord(context.Background(), vm)
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+			assert.Nil(t, record)
+
+			// No vApp or VM records may be created for spoofed labels
+			mockVAppRepo.AssertNotCalled(t, "CreateVApp", mock.Anything, mock.Anything)
+			mockVMRepo.AssertNotCalled(t, "CreateVM", mock.Anything, mock.Anything)
+		})
+	}
+}
+
 func TestExtractVMIData(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -859,6 +1181,30 @@ func TestExtractVMSpecData(t *testing.T) {
 	}
 }
 
+func TestFirmwareIdentity(t *testing.T) {
+	vm := &kubevirtv1.VirtualMachine{
+		Spec: kubevirtv1.VirtualMachineSpec{
+			Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kubevirtv1.VirtualMachineInstanceSpec{
+					Domain: kubevirtv1.DomainSpec{
+						Firmware: &kubevirtv1.Firmware{
+							UUID:   types.UID("5d3f1c1e-8f2a-4c55-9b6e-0c1f2a3b4c5d"),
+							Serial: "SSVIRT-5D3F1C1E8F2A4C559B6E0C1F2A3B4C5D",
+						},
+					},
+				},
+			},
+		},
+	}
+	biosUUID, serial := firmwareIdentity(vm)
+	assert.Equal(t, "5d3f1c1e-8f2a-4c55-9b6e-0c1f2a3b4c5d", biosUUID)
+	assert.Equal(t, "SSVIRT-5D3F1C1E8F2A4C559B6E0C1F2A3B4C5D", serial)
+
+	biosUUID, serial = firmwareIdentity(&kubevirtv1.VirtualMachine{})
+	assert.Empty(t, biosUUID)
+	assert.Empty(t, serial)
+}
+
 func TestFormatGuestOS(t *testing.T) {
 	tests := []struct {
 		name     string
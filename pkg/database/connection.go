@@ -3,11 +3,8 @@ package database
 import (
 	"fmt"
 	"log"
-	"strings"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 
 	"github.com/mhrivnak/ssvirt/pkg/config"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
@@ -19,25 +16,20 @@ type DB struct {
 }
 
 func NewConnection(cfg *config.Config) (*DB, error) {
+	gormLogger, err := newLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: gormLogger,
 	}
 
-	// Debug logging to see what database config we're getting
-	log.Printf("Database connection debug - Host: %s, Port: %d, Username: %q, Database: %s, SSLMode: %s, Password length: %d",
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.Username, cfg.Database.Database, cfg.Database.SSLMode, len(cfg.Database.Password))
-
-	// Build DSN connection string using GORM recommended format
-	dsn := buildDSN(cfg.Database.Host, cfg.Database.Port, cfg.Database.Username, cfg.Database.Password, cfg.Database.Database, cfg.Database.SSLMode)
-
-	// Log the DSN without password for debugging
-	debugDSN := dsn
-	if cfg.Database.Password != "" {
-		debugDSN = strings.Replace(dsn, fmt.Sprintf("password=%s", cfg.Database.Password), "password=***", 1)
+	dialector, err := openDialector(cfg)
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("Database DSN (sanitized): %s", debugDSN)
 
-	db, err := gorm.Open(postgres.Open(dsn), gormConfig)
+	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -47,10 +39,20 @@ func NewConnection(cfg *config.Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
 	}
 
-	sqlDB.SetMaxOpenConns(cfg.Database.MaxConnections)
-	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
-	sqlDB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
-	sqlDB.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
+	if dialector.Name() == DriverSQLite {
+		// SQLite allows a single writer, so serialize access through one
+		// connection that is never recycled; recycling would also discard an
+		// in-memory database
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	} else {
+		sqlDB.SetMaxOpenConns(cfg.Database.MaxConnections)
+		sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+		sqlDB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+		sqlDB.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
+	}
 
 	return &DB{db}, nil
 }
@@ -67,11 +69,33 @@ func (db *DB) AutoMigrate() error {
 		&models.VAppTemplate{},
 		&models.VApp{},
 		&models.VM{},
+		&models.OrgBranding{},
+		&models.Task{},
+		&models.CatalogItemRecord{},
+		&models.CatalogAccessControl{},
+		&models.SSHKey{},
+		&models.VDCStorageProfile{},
+		&models.CatalogSource{},
+		&models.APIUsage{},
+		&models.ComponentHeartbeat{},
+		&models.ArchivedVM{},
+		&models.OrgSequence{},
+		&models.SystemStatusOverride{},
+		&models.CatalogItemPin{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to auto-migrate database: %w", err)
 	}
 
+	// vApps created before the TemplateInstance linkage was stored are named
+	// after their TemplateInstance
+	err = db.DB.Model(&models.VApp{}).
+		Where("template_instance_name = '' OR template_instance_name IS NULL").
+		Update("template_instance_name", gorm.Expr("name")).Error
+	if err != nil {
+		return fmt.Errorf("failed to backfill vApp template instance names: %w", err)
+	}
+
 	log.Println("Database auto-migration completed successfully")
 	return nil
 }
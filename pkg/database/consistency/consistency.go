@@ -0,0 +1,58 @@
+// Package consistency carries the read consistency a request asks for.
+//
+// Clients that list resources right after changing them, such as automation
+// that creates a vApp and then lists vApps, ask for strong consistency with
+// the consistency=strong query parameter. Reads made with a strongly
+// consistent context must see every committed write, so they go to the
+// primary database and bypass caches such as the Kubernetes informer cache
+// and the role cache. Other reads may be served from caches or replicas.
+package consistency
+
+import (
+	"context"
+	"fmt"
+)
+
+// Level is the consistency of reads
+type Level string
+
+const (
+	// Eventual reads may miss writes committed shortly before; the default
+	Eventual Level = "eventual"
+	// Strong reads see every committed write
+	Strong Level = "strong"
+)
+
+// QueryParameter is the query parameter clients set the consistency with
+const QueryParameter = "consistency"
+
+type contextKey struct{}
+
+// Parse returns the level named by value. An empty value is Eventual.
+func Parse(value string) (Level, error) {
+	switch Level(value) {
+	case "", Eventual:
+		return Eventual, nil
+	case Strong:
+		return Strong, nil
+	}
+	return "", fmt.Errorf("consistency must be %q or %q", Eventual, Strong)
+}
+
+// WithLevel returns a copy of ctx whose reads have the given consistency
+func WithLevel(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, contextKey{}, level)
+}
+
+// FromContext returns the consistency of reads made with ctx
+func FromContext(ctx context.Context) Level {
+	if level, ok := ctx.Value(contextKey{}).(Level); ok {
+		return level
+	}
+	return Eventual
+}
+
+// IsStrong reports whether reads made with ctx must see every committed write
+func IsStrong(ctx context.Context) bool {
+	return FromContext(ctx) == Strong
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// errInvalidConfig marks database settings that no retry can fix
+var errInvalidConfig = errors.New("invalid database configuration")
+
+// sqliteOptions enables foreign keys, which SQLite leaves off by default, and
+// waits on locks instead of failing immediately with SQLITE_BUSY
+const sqliteOptions = "_foreign_keys=on&_busy_timeout=5000"
+
+// openDialector returns the GORM dialector for the configured driver
+func openDialector(cfg *config.Config) (gorm.Dialector, error) {
+	switch strings.ToLower(cfg.Database.Driver) {
+	case "", DriverPostgres, "postgresql":
+		// Debug logging to see what database config we're getting
+		log.Printf("Database connection debug - Host: %s, Port: %d, Username: %q, Database: %s, SSLMode: %s, Password length: %d",
+			cfg.Database.Host, cfg.Database.Port, cfg.Database.Username, cfg.Database.Database, cfg.Database.SSLMode, len(cfg.Database.Password))
+
+		// Build DSN connection string using GORM recommended format
+		dsn := buildDSN(cfg.Database.Host, cfg.Database.Port, cfg.Database.Username, cfg.Database.Password, cfg.Database.Database, cfg.Database.SSLMode)
+
+		// Log the DSN without password for debugging
+		debugDSN := dsn
+		if cfg.Database.Password != "" {
+			debugDSN = strings.Replace(dsn, fmt.Sprintf("password=%s", cfg.Database.Password), "password=***", 1)
+		}
+		log.Printf("Database DSN (sanitized): %s", debugDSN)
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		path := cfg.Database.Path
+		if path == "" {
+			return nil, fmt.Errorf("%w: database path is required for the %s driver", errInvalidConfig, DriverSQLite)
+		}
+		log.Printf("Using SQLite database: %s", path)
+		return sqlite.Open(sqliteDSN(path)), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported driver %q, must be %q or %q", errInvalidConfig, cfg.Database.Driver, DriverPostgres, DriverSQLite)
+	}
+}
+
+// sqliteDSN appends the connection options to a SQLite file path
+func sqliteDSN(path string) string {
+	if strings.Contains(path, "?") {
+		return path + "&" + sqliteOptions
+	}
+	return "file:" + path + "?" + sqliteOptions
+}
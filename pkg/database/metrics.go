@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm/logger"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+// Counter for queries that took at least the configured slow query threshold
+var slowQueriesTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "ssvirt_db_slow_queries_total",
+		Help: "Total number of database queries slower than the configured slow query threshold",
+	},
+)
+
+// RegisterMetrics registers connection pool gauges (open, in-use and idle
+// connections, plus wait counts) and the slow query counter with reg
+func (db *DB) RegisterMetrics(reg prometheus.Registerer) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+	if err := reg.Register(collectors.NewDBStatsCollector(sqlDB, db.Dialector.Name())); err != nil {
+		return fmt.Errorf("failed to register connection pool metrics: %w", err)
+	}
+	if err := reg.Register(slowQueriesTotal); err != nil {
+		// The counter is shared by every connection in the process
+		var already prometheus.AlreadyRegisteredError
+		if !errors.As(err, &already) {
+			return fmt.Errorf("failed to register slow query metric: %w", err)
+		}
+	}
+	return nil
+}
+
+// newLogger returns the GORM logger for the configured log level, logging and
+// counting queries slower than the configured threshold
+func newLogger(cfg *config.Config) (logger.Interface, error) {
+	level, err := parseLogLevel(cfg.Database.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	base := logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold: cfg.Database.SlowQueryThreshold,
+		LogLevel:      level,
+		Colorful:      true,
+	})
+	return &slowQueryLogger{Interface: base, threshold: cfg.Database.SlowQueryThreshold}, nil
+}
+
+// parseLogLevel maps the database.log_level setting to a GORM log level
+func parseLogLevel(level string) (logger.LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "", "info":
+		return logger.Info, nil
+	case "warn":
+		return logger.Warn, nil
+	case "error":
+		return logger.Error, nil
+	case "silent":
+		return logger.Silent, nil
+	default:
+		return 0, fmt.Errorf("%w: unsupported log level %q, must be info, warn, error or silent", errInvalidConfig, level)
+	}
+}
+
+// slowQueryLogger counts queries at or above threshold. Logging them is left to
+// the wrapped logger, which reports them at the warn level with their SQL.
+type slowQueryLogger struct {
+	logger.Interface
+	threshold time.Duration
+}
+
+func (l *slowQueryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &slowQueryLogger{Interface: l.Interface.LogMode(level), threshold: l.threshold}
+}
+
+func (l *slowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.threshold > 0 && time.Since(begin) >= l.threshold {
+		slowQueriesTotal.Inc()
+	}
+	l.Interface.Trace(ctx, begin, fc, err)
+}
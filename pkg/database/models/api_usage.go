@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// APIUsageDayFormat is the layout of APIUsage.Day
+const APIUsageDayFormat = "2006-01-02"
+
+// APIUsage aggregates the API calls made by one user on one UTC day
+type APIUsage struct {
+	Day            string    `gorm:"type:varchar(10);primaryKey" json:"day"`
+	UserID         string    `gorm:"type:varchar(255);primaryKey" json:"userId"`
+	OrganizationID string    `gorm:"type:varchar(255);index" json:"orgId,omitempty"`
+	RequestCount   int64     `gorm:"not null;default:0" json:"requestCount"`
+	ErrorCount     int64     `gorm:"not null;default:0" json:"errorCount"`
+	BytesIn        int64     `gorm:"not null;default:0" json:"bytesIn"`
+	BytesOut       int64     `gorm:"not null;default:0" json:"bytesOut"`
+	UpdatedAt      time.Time `json:"-"`
+}
+
+// APIUsageDay returns the APIUsage.Day of a point in time
+func APIUsageDay(t time.Time) string {
+	return t.UTC().Format(APIUsageDayFormat)
+}
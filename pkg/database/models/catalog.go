@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -21,6 +22,15 @@ type Catalog struct {
 	Version      int    `gorm:"default:1" json:"version"`
 	OwnerID      string `gorm:"type:varchar(255)" json:"-"` // Hidden, part of owner object
 
+	// Sharing within the owning organization; per-user and per-role grants are
+	// stored as CatalogAccessControl entries
+	SharedToEveryone    bool   `gorm:"default:true" json:"-"`
+	EveryoneAccessLevel string `gorm:"type:varchar(32);default:'Change'" json:"-"`
+
+	// ItemNames limits the catalog to the newline-separated Template names;
+	// when empty the catalog lists every Template
+	ItemNames string `gorm:"type:text" json:"-"`
+
 	// Timestamps (hidden from JSON in VCD format)
 	CreatedAt time.Time      `json:"-"`
 	UpdatedAt time.Time      `json:"-"`
@@ -104,6 +114,26 @@ func (c *Catalog) CreationDate() string {
 	return c.CreatedAt.Format(time.RFC3339)
 }
 
+// ItemNameList returns the Template names the catalog is limited to, or nil
+// when it lists every Template
+func (c *Catalog) ItemNameList() []string {
+	if strings.TrimSpace(c.ItemNames) == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(c.ItemNames, "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SetItemNames limits the catalog to the given Template names
+func (c *Catalog) SetItemNames(names []string) {
+	c.ItemNames = strings.Join(names, "\n")
+}
+
 // BeforeCreate sets up the catalog before database creation
 func (c *Catalog) BeforeCreate(tx *gorm.DB) error {
 	if c.ID == "" {
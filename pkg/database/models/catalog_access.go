@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Catalog access levels, from least to most privileged
+const (
+	// CatalogAccessReadOnly allows viewing the catalog and its items
+	CatalogAccessReadOnly = "ReadOnly"
+	// CatalogAccessUse additionally allows instantiating the catalog's items
+	CatalogAccessUse = "Use"
+	// CatalogAccessChange additionally allows deleting and sharing the catalog
+	CatalogAccessChange = "Change"
+)
+
+// catalogAccessFullControl is VCD's name for the highest access level, accepted as Change
+const catalogAccessFullControl = "FullControl"
+
+// Catalog access control subject types
+const (
+	AccessSubjectUser = "user"
+	AccessSubjectRole = "role"
+)
+
+// CatalogAccessControl grants a user, or every user holding a role, an access
+// level on a catalog
+type CatalogAccessControl struct {
+	CatalogID   string    `gorm:"type:varchar(255);primary_key" json:"catalogId"`
+	SubjectID   string    `gorm:"type:varchar(255);primary_key;index" json:"subjectId"`
+	SubjectType string    `gorm:"type:varchar(16);not null" json:"subjectType"`
+	AccessLevel string    `gorm:"type:varchar(32);not null" json:"accessLevel"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+
+	// Relationships
+	Catalog *Catalog `gorm:"foreignKey:CatalogID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// CatalogAccessRank orders access levels; unknown and empty levels rank 0
+func CatalogAccessRank(level string) int {
+	switch level {
+	case CatalogAccessReadOnly:
+		return 1
+	case CatalogAccessUse:
+		return 2
+	case CatalogAccessChange:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// MaxCatalogAccess returns the more privileged of two access levels
+func MaxCatalogAccess(a, b string) string {
+	if CatalogAccessRank(b) > CatalogAccessRank(a) {
+		return b
+	}
+	return a
+}
+
+// NormalizeCatalogAccessLevel validates an access level from a request,
+// accepting VCD's FullControl as Change
+func NormalizeCatalogAccessLevel(level string) (string, bool) {
+	if level == catalogAccessFullControl {
+		return CatalogAccessChange, true
+	}
+	return level, CatalogAccessRank(level) > 0
+}
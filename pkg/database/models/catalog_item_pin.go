@@ -0,0 +1,47 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Catalog item pin statuses
+const (
+	CatalogItemPinPending   = "PENDING"
+	CatalogItemPinImporting = "IMPORTING"
+	CatalogItemPinReady     = "READY"
+	CatalogItemPinFailed    = "FAILED"
+)
+
+// CatalogItemPin marks a catalog item whose base images the prewarm controller
+// keeps imported in a VDC's namespace, so instantiating the item in the VDC
+// clones them instead of downloading them. Items are pinned by their Template,
+// which is the same in every catalog presenting it.
+type CatalogItemPin struct {
+	VDCID        string `gorm:"type:varchar(255);primaryKey" json:"-"`
+	TemplateName string `gorm:"type:varchar(253);primaryKey" json:"templateName"`
+	// CatalogItemID is the URN the item was pinned through
+	CatalogItemID string `gorm:"type:varchar(512);not null" json:"catalogItemId"`
+
+	// Import status, written by the prewarm controller
+	Status  string `gorm:"size:16;not null;default:'PENDING'" json:"status"`
+	Message string `gorm:"type:text" json:"message,omitempty"`
+	// ReadyImages lists, newline-separated, the golden image DataVolumes that
+	// finished importing; instantiations clone only these
+	ReadyImages string     `gorm:"type:text" json:"-"`
+	ReadyAt     *time.Time `json:"readyAt,omitempty"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"-"`
+
+	// Relationships
+	VDC *VDC `gorm:"foreignKey:VDCID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// ReadyImageNames returns the golden image DataVolumes that finished importing
+func (p *CatalogItemPin) ReadyImageNames() []string {
+	if p.ReadyImages == "" {
+		return nil
+	}
+	return strings.Split(p.ReadyImages, "\n")
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Catalog source types
+const (
+	// CatalogSourceGit clones a git repository and imports the Templates in
+	// its YAML and JSON files
+	CatalogSourceGit = "git"
+	// CatalogSourceHTTP reads an index document listing the URLs of Templates
+	CatalogSourceHTTP = "http"
+)
+
+// Catalog source sync statuses
+const (
+	CatalogSyncPending   = "PENDING"
+	CatalogSyncRunning   = "SYNCING"
+	CatalogSyncSucceeded = "SUCCEEDED"
+	CatalogSyncFailed    = "FAILED"
+)
+
+// DefaultCatalogSyncIntervalMinutes is how often a catalog source is synced
+// when its interval is not set
+const DefaultCatalogSyncIntervalMinutes = 60
+
+// CatalogSource is a remote location, a git repository or an HTTP index, that
+// the catalog sync controller imports OpenShift Templates from into the
+// catalog namespace. A catalog has at most one source.
+type CatalogSource struct {
+	CatalogID string `gorm:"type:varchar(255);primaryKey" json:"-"`
+	Type      string `gorm:"size:16;not null" json:"type"`
+	URL       string `gorm:"size:2048;not null" json:"url"`
+	// Ref is the git branch or tag to import; the default branch when empty
+	Ref string `gorm:"size:255" json:"ref,omitempty"`
+	// Path limits a git import to a directory of the repository
+	Path string `gorm:"size:1024" json:"path,omitempty"`
+	// SyncIntervalMinutes is how often the source is synced; 0 syncs only on request
+	SyncIntervalMinutes int `gorm:"not null;default:60" json:"syncIntervalMinutes"`
+
+	// Sync status, written by the catalog sync controller
+	SyncStatus      string     `gorm:"size:16;not null;default:'PENDING'" json:"syncStatus"`
+	SyncMessage     string     `gorm:"type:text" json:"syncMessage,omitempty"`
+	LastSyncAt      *time.Time `json:"lastSyncAt,omitempty"`
+	Revision        string     `gorm:"size:255" json:"revision,omitempty"` // git commit or index digest last imported
+	TemplateCount   int        `gorm:"not null;default:0" json:"templateCount"`
+	SyncRequestedAt *time.Time `json:"syncRequestedAt,omitempty"`
+
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+
+	// Relationships
+	Catalog *Catalog `gorm:"foreignKey:CatalogID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// SyncDue reports whether the source should be synced now: a sync was
+// requested, or the sync interval has passed since the last sync
+func (s *CatalogSource) SyncDue(now time.Time) bool {
+	if s.SyncRequestedAt != nil {
+		return true
+	}
+	if s.SyncIntervalMinutes <= 0 {
+		return false
+	}
+	return s.LastSyncAt == nil || !now.Before(s.LastSyncAt.Add(time.Duration(s.SyncIntervalMinutes)*time.Minute))
+}
+
+// LabelValue identifies the source in the label of the Templates it imported.
+// It is the catalog UUID, which fits the 63 character limit of label values.
+func (s *CatalogSource) LabelValue() string {
+	return strings.TrimPrefix(s.CatalogID, URNPrefixCatalog)
+}
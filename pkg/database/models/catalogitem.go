@@ -1,5 +1,12 @@
 package models
 
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
 // CatalogItem represents a VCD-compliant catalog item backed by OpenShift Templates
 type CatalogItem struct {
 	ID           string            `json:"id"`
@@ -14,8 +21,22 @@ type CatalogItem struct {
 	Entity       CatalogItemEntity `json:"entity"`
 	Owner        EntityRef         `json:"owner"`
 	Catalog      EntityRef         `json:"catalog"`
+
+	// IconClass is the Template's iconClass annotation, such as icon-rhel
+	IconClass string `json:"iconClass,omitempty"`
+
+	// ValidationStatus flags templates that cannot be instantiated, with the
+	// reasons listed in ValidationErrors
+	ValidationStatus string   `json:"validationStatus"`
+	ValidationErrors []string `json:"validationErrors,omitempty"`
 }
 
+// Catalog item validation statuses
+const (
+	CatalogItemValidationValid   = "VALID"
+	CatalogItemValidationInvalid = "INVALID"
+)
+
 // CatalogItemEntity represents the detailed entity information for a catalog item
 type CatalogItemEntity struct {
 	Name              string `json:"name"`
@@ -25,4 +46,96 @@ type CatalogItemEntity struct {
 	NumberOfCpus      int    `json:"numberOfCpus"`
 	MemoryAllocation  int64  `json:"memoryAllocation"`
 	StorageAllocation int64  `json:"storageAllocation"`
+	NumberOfGpus      int    `json:"numberOfGpus,omitempty"`
+	// Architecture is the kubernetes.io/arch value the VMs are scheduled onto
+	Architecture string `json:"architecture,omitempty"`
+	OSType       string `json:"osType,omitempty"`
+	OSFamily     string `json:"osFamily,omitempty"`
+}
+
+// CatalogItemRecord is an OpenShift Template persisted for catalog item queries.
+// Records are kept in sync with the cluster by the catalog item syncer and are
+// presented as a CatalogItem in every catalog.
+type CatalogItemRecord struct {
+	TemplateUID       string    `gorm:"type:varchar(64);primaryKey" json:"templateUid"`
+	Name              string    `gorm:"type:varchar(253);not null;index" json:"name"`
+	Namespace         string    `gorm:"type:varchar(63);not null" json:"namespace"`
+	Description       string    `gorm:"type:text" json:"description"`
+	IsPublished       bool      `gorm:"default:false;index" json:"isPublished"`
+	NumberOfVMs       int       `json:"numberOfVMs"`
+	NumberOfCpus      int       `json:"numberOfCpus"`
+	MemoryAllocation  int64     `json:"memoryAllocation"`
+	StorageAllocation int64     `json:"storageAllocation"`
+	NumberOfGpus      int       `json:"numberOfGpus"`
+	Architecture      string    `gorm:"type:varchar(32);index" json:"architecture"`
+	OSType            string    `gorm:"type:varchar(64)" json:"osType"`
+	OSFamily          string    `gorm:"type:varchar(16)" json:"osFamily"`
+	IconClass         string    `gorm:"type:varchar(128)" json:"iconClass"`
+	Size              int64     `json:"size"`
+	ResourceVersion   string    `gorm:"type:varchar(64)" json:"resourceVersion"`
+	TemplateCreatedAt time.Time `json:"templateCreatedAt"`
+	ValidationStatus  string    `gorm:"type:varchar(16);default:'VALID';index" json:"validationStatus"`
+	ValidationErrors  string    `gorm:"type:text" json:"validationErrors"` // newline-separated
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// TableName returns the table name for catalog item records
+func (CatalogItemRecord) TableName() string {
+	return "catalog_items"
+}
+
+// CatalogItemURN builds the catalog item URN for a template name within a catalog
+func CatalogItemURN(catalogID, templateName string) string {
+	catalogUUID := strings.TrimPrefix(catalogID, URNPrefixCatalog)
+	// URL-encode template name to handle special characters
+	return fmt.Sprintf("%s%s:%s", URNPrefixCatalogItem, catalogUUID, url.QueryEscape(templateName))
+}
+
+// ToCatalogItem presents the record as an item of the given catalog
+func (r *CatalogItemRecord) ToCatalogItem(catalogID, catalogName string) CatalogItem {
+	validationStatus := r.ValidationStatus
+	if validationStatus == "" {
+		validationStatus = CatalogItemValidationValid
+	}
+	var validationErrors []string
+	if r.ValidationErrors != "" {
+		validationErrors = strings.Split(r.ValidationErrors, "\n")
+	}
+
+	return CatalogItem{
+		ID:           CatalogItemURN(catalogID, r.Name),
+		Name:         r.Name,
+		Description:  r.Description,
+		CatalogID:    catalogID,
+		IsPublished:  r.IsPublished,
+		IsExpired:    false,
+		CreationDate: r.TemplateCreatedAt.UTC().Format(time.RFC3339),
+		Size:         r.Size,
+		Status:       "AVAILABLE",
+		IconClass:    r.IconClass,
+		Entity: CatalogItemEntity{
+			Name:              r.Name,
+			Description:       r.Description,
+			Type:              "application/vnd.vmware.vcloud.vAppTemplate+xml",
+			NumberOfVMs:       r.NumberOfVMs,
+			NumberOfCpus:      r.NumberOfCpus,
+			MemoryAllocation:  r.MemoryAllocation,
+			StorageAllocation: r.StorageAllocation,
+			NumberOfGpus:      r.NumberOfGpus,
+			Architecture:      r.Architecture,
+			OSType:            r.OSType,
+			OSFamily:          r.OSFamily,
+		},
+		Owner: EntityRef{
+			Name: "System",
+			ID:   "",
+		},
+		Catalog: EntityRef{
+			Name: catalogName,
+			ID:   catalogID,
+		},
+		ValidationStatus: validationStatus,
+		ValidationErrors: validationErrors,
+	}
 }
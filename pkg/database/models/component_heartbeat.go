@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ComponentHeartbeat is written periodically by each controller process so
+// operators can see which components are alive and which build they run
+type ComponentHeartbeat struct {
+	// InstanceID identifies the process, normally its pod name
+	InstanceID string `gorm:"type:varchar(255);primaryKey" json:"instanceId"`
+	// Component is the kind of process, such as vm-controller
+	Component string `gorm:"size:64;not null;index" json:"component"`
+	Version   string `gorm:"size:128" json:"version"`
+	// Leader reports whether the process holds its leader lease; standby
+	// replicas heartbeat too but do not reconcile
+	Leader bool `json:"leader"`
+	// IntervalSeconds is how often the process heartbeats, used to tell
+	// whether a heartbeat is overdue
+	IntervalSeconds int       `json:"intervalSeconds"`
+	StartedAt       time.Time `json:"startedAt"`
+	LastHeartbeat   time.Time `gorm:"index" json:"lastHeartbeat"`
+
+	ControllersData string                `gorm:"type:text" json:"-"`
+	Controllers     []ControllerHeartbeat `gorm:"-" json:"controllers"`
+}
+
+// ControllerHeartbeat reports the reconcile activity of one controller
+type ControllerHeartbeat struct {
+	Name string `json:"name"`
+	// LastReconcile is when the controller last finished a reconcile, and
+	// LastSuccess when one last succeeded; both are unset until it has run
+	LastReconcile *time.Time `json:"lastReconcile,omitempty"`
+	LastSuccess   *time.Time `json:"lastSuccess,omitempty"`
+}
+
+// AfterFind decodes the controllers of the heartbeat
+func (h *ComponentHeartbeat) AfterFind(tx *gorm.DB) error {
+	h.Controllers = []ControllerHeartbeat{}
+	if h.ControllersData != "" {
+		_ = json.Unmarshal([]byte(h.ControllersData), &h.Controllers)
+	}
+	return nil
+}
+
+// SetControllers sets the controllers reported by the heartbeat
+func (h *ComponentHeartbeat) SetControllers(controllers []ControllerHeartbeat) {
+	h.Controllers = controllers
+	data, _ := json.Marshal(controllers)
+	h.ControllersData = string(data)
+}
+
+// Stale reports whether the component has missed three heartbeats by now
+func (h *ComponentHeartbeat) Stale(now time.Time) bool {
+	interval := time.Duration(h.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return now.Sub(h.LastHeartbeat) > 3*interval
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Condition statuses, as in Kubernetes
+const (
+	ConditionTrue    = "True"
+	ConditionFalse   = "False"
+	ConditionUnknown = "Unknown"
+)
+
+// Condition types maintained by the controllers
+const (
+	// ConditionNamespaceReady reports whether the VDC's namespace exists and is active
+	ConditionNamespaceReady = "NamespaceReady"
+	// ConditionQuotaApplied reports whether the VDC's ResourceQuota is enforced
+	ConditionQuotaApplied = "QuotaApplied"
+	// ConditionNetworkReady reports whether the VDC's UserDefinedNetwork is ready
+	ConditionNetworkReady = "NetworkReady"
+	// ConditionTemplateInstantiated reports whether the vApp's TemplateInstance
+	// created its resources
+	ConditionTemplateInstantiated = "TemplateInstantiated"
+)
+
+// Condition is one aspect of the readiness of a VDC or vApp, modelled on
+// Kubernetes conditions. LastTransitionTime is when Status last changed.
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// SetCondition adds or replaces the condition of the same type, keeping its
+// transition time unless the status changed. It returns the updated conditions
+// and whether anything changed.
+func SetCondition(conditions []Condition, condition Condition, now time.Time) ([]Condition, bool) {
+	updated := make([]Condition, 0, len(conditions)+1)
+	changed, found := false, false
+	for _, existing := range conditions {
+		if existing.Type != condition.Type {
+			updated = append(updated, existing)
+			continue
+		}
+		found = true
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = now
+		}
+		changed = existing != condition
+		updated = append(updated, condition)
+	}
+	if !found {
+		condition.LastTransitionTime = now
+		updated = append(updated, condition)
+		changed = true
+	}
+	return updated, changed
+}
+
+// FindCondition returns the condition of the given type, or nil
+func FindCondition(conditions []Condition, conditionType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// decodeConditions decodes a conditions column, which is only written by
+// encodeConditions
+func decodeConditions(data string) []Condition {
+	conditions := []Condition{}
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &conditions)
+	}
+	return conditions
+}
+
+// encodeConditions encodes conditions for a conditions column
+func encodeConditions(conditions []Condition) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(conditions)
+	return string(data)
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Entity change operations
+const (
+	EntityChangeCreated = "created"
+	EntityChangeUpdated = "updated"
+	EntityChangeDeleted = "deleted"
+)
+
+// Entity types reported in entity changes
+const (
+	EntityTypeCatalog = "catalog"
+	EntityTypeOrg     = "org"
+	EntityTypeUser    = "user"
+	EntityTypeVApp    = "vapp"
+	EntityTypeVDC     = "vdc"
+	EntityTypeVM      = "vm"
+)
+
+// EntityChange reports the latest change of an entity, for external
+// inventories that synchronize incrementally
+type EntityChange struct {
+	Type      string    `json:"type"`
+	URN       string    `json:"urn"`
+	Operation string    `json:"operation"`
+	ChangedAt time.Time `json:"changedAt"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// OrgBranding stores portal branding and support contact metadata for an organization
+type OrgBranding struct {
+	OrganizationID string    `gorm:"type:varchar(255);primary_key" json:"orgId"`
+	LogoURL        string    `gorm:"size:2048" json:"logoUrl"`
+	PrimaryColor   string    `gorm:"size:16" json:"primaryColor"`
+	SecondaryColor string    `gorm:"size:16" json:"secondaryColor"`
+	SupportEmail   string    `gorm:"size:255" json:"supportEmail"`
+	SupportURL     string    `gorm:"size:2048" json:"supportUrl"`
+	SupportPhone   string    `gorm:"size:64" json:"supportPhone"`
+	CreatedAt      time.Time `json:"-"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+
+	// Relationships
+	Organization *Organization `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
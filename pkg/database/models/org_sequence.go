@@ -0,0 +1,19 @@
+package models
+
+import "fmt"
+
+// OrgSequenceVM numbers the VMs created in an organization
+const OrgSequenceVM = "vm"
+
+// OrgSequence is a counter kept per organization, used to give entities
+// numbers that are easier to read out than their URNs
+type OrgSequence struct {
+	OrganizationID string `gorm:"type:varchar(255);primaryKey"`
+	Name           string `gorm:"type:varchar(64);primaryKey"`
+	Value          int64  `gorm:"not null;default:0"`
+}
+
+// VMFriendlyID formats the number of a VM within its organization, e.g. vm-0042
+func VMFriendlyID(number int64) string {
+	return fmt.Sprintf("vm-%04d", number)
+}
@@ -1,33 +1,50 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Organization struct {
-	ID                      string         `gorm:"type:varchar(255);primary_key" json:"id"`
-	Name                    string         `gorm:"uniqueIndex;not null;size:255" json:"name"`
-	DisplayName             string         `gorm:"size:255" json:"displayName"`
-	Description             string         `json:"description"`
-	IsEnabled               bool           `gorm:"default:true;not null" json:"isEnabled"`
-	OrgVdcCount             int            `gorm:"-" json:"orgVdcCount"`    // Computed field
-	CatalogCount            int            `gorm:"-" json:"catalogCount"`   // Computed field
-	VappCount               int            `gorm:"-" json:"vappCount"`      // Computed field
-	RunningVMCount          int            `gorm:"-" json:"runningVMCount"` // Computed field
-	UserCount               int            `gorm:"-" json:"userCount"`      // Computed field
-	DiskCount               int            `gorm:"-" json:"diskCount"`      // Computed field
-	CanManageOrgs           bool           `gorm:"default:false;not null" json:"canManageOrgs"`
-	CanPublish              bool           `gorm:"default:false;not null" json:"canPublish"`
-	MaskedEventTaskUsername string         `json:"maskedEventTaskUsername"`
-	DirectlyManagedOrgCount int            `gorm:"-" json:"directlyManagedOrgCount"` // Computed field
-	CreatedAt               time.Time      `json:"created_at"`
-	UpdatedAt               time.Time      `json:"updated_at"`
-	DeletedAt               gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID                      string  `gorm:"type:varchar(255);primary_key" json:"id"`
+	Name                    string  `gorm:"uniqueIndex;not null;size:255" json:"name"`
+	DisplayName             string  `gorm:"size:255" json:"displayName"`
+	Description             string  `json:"description"`
+	IsEnabled               bool    `gorm:"default:true;not null" json:"isEnabled"`
+	OrgVdcCount             int     `gorm:"-" json:"orgVdcCount"`    // Computed field
+	CatalogCount            int     `gorm:"-" json:"catalogCount"`   // Computed field
+	VappCount               int     `gorm:"-" json:"vappCount"`      // Computed field
+	RunningVMCount          int     `gorm:"-" json:"runningVMCount"` // Computed field
+	UserCount               int     `gorm:"-" json:"userCount"`      // Computed field
+	DiskCount               int     `gorm:"-" json:"diskCount"`      // Computed field
+	CanManageOrgs           bool    `gorm:"default:false;not null" json:"canManageOrgs"`
+	CanPublish              bool    `gorm:"default:false;not null" json:"canPublish"`
+	MaskedEventTaskUsername string  `json:"maskedEventTaskUsername"`
+	ParentOrgID             *string `gorm:"type:varchar(255);index" json:"-"`
+	// ExternalID is an optional client-provided identifier that makes creation
+	// idempotent for infrastructure-as-code tools; it cannot be changed
+	ExternalID              *string `gorm:"type:varchar(255);uniqueIndex:idx_org_external_id_active,where:deleted_at IS NULL" json:"externalId,omitempty"`
+	DirectlyManagedOrgCount int     `gorm:"-" json:"directlyManagedOrgCount"` // Computed field
+	// SecurityPolicyData stores the VM security policy as JSON; it is only
+	// written by SetSecurityPolicy
+	SecurityPolicyData string `gorm:"type:text" json:"-"`
+	// SecurityPolicy is decoded from SecurityPolicyData when the organization is loaded
+	SecurityPolicy *OrgSecurityPolicy `gorm:"-" json:"securityPolicy,omitempty"`
+	// QuotaData stores the organization-wide resource ceilings as JSON; it is
+	// only written by SetQuota
+	QuotaData string `gorm:"type:text" json:"-"`
+	// Quota is decoded from QuotaData when the organization is loaded
+	Quota     *OrgQuota      `gorm:"-" json:"quota,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Entity references (populated in API responses)
 	ManagedBy *EntityRef `gorm:"-" json:"managedBy,omitempty"`
+	// DefaultCatalog is set in the create response when a default catalog was created
+	DefaultCatalog *EntityRef `gorm:"-" json:"defaultCatalog,omitempty"`
 
 	// Relationships
 	VDCs     []VDC     `gorm:"foreignKey:OrganizationID;references:ID" json:"vdcs,omitempty"`
@@ -44,7 +61,100 @@ func (o *Organization) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// AfterFind decodes the organization's security policy
+func (o *Organization) AfterFind(tx *gorm.DB) error {
+	o.SecurityPolicy = nil
+	if o.SecurityPolicyData != "" {
+		var policy OrgSecurityPolicy
+		if err := json.Unmarshal([]byte(o.SecurityPolicyData), &policy); err == nil {
+			o.SecurityPolicy = &policy
+		}
+	}
+	o.Quota = nil
+	if o.QuotaData != "" {
+		var quota OrgQuota
+		if err := json.Unmarshal([]byte(o.QuotaData), &quota); err == nil {
+			o.Quota = &quota
+		}
+	}
+	return nil
+}
+
+// SetSecurityPolicy sets the organization's VM security policy; nil removes it
+func (o *Organization) SetSecurityPolicy(policy *OrgSecurityPolicy) {
+	o.SecurityPolicy = policy
+	if policy == nil {
+		o.SecurityPolicyData = ""
+		return
+	}
+	data, _ := json.Marshal(policy)
+	o.SecurityPolicyData = string(data)
+}
+
+// SetQuota sets the organization's resource ceilings; nil or a quota without
+// any ceiling removes them
+func (o *Organization) SetQuota(quota *OrgQuota) {
+	if quota == nil || *quota == (OrgQuota{}) {
+		o.Quota = nil
+		o.QuotaData = ""
+		return
+	}
+	o.Quota = quota
+	data, _ := json.Marshal(quota)
+	o.QuotaData = string(data)
+}
+
+// OrgQuota caps the resources allocated across all of an organization's VDCs,
+// so an organization cannot grow past it by creating more VDCs. A ceiling of 0
+// leaves the resource unlimited.
+type OrgQuota struct {
+	CPUCores  int64 `json:"cpuCores"`
+	MemoryMB  int64 `json:"memoryMB"`
+	StorageMB int64 `json:"storageMB"`
+	VMCount   int64 `json:"vmCount"`
+}
+
+// OrgQuotaUsage is what an organization's VDCs use of the resources its quota
+// caps. Storage is the measured use of the VDCs' storage profiles.
+type OrgQuotaUsage struct {
+	CPUCores  int64 `json:"cpuCores"`
+	MemoryMB  int64 `json:"memoryMB"`
+	StorageMB int64 `json:"storageMB"`
+	VMCount   int64 `json:"vmCount"`
+}
+
+// OrgSecurityPolicy lists the privileged VM settings an organization allows.
+// Settings that are not allowed are flagged in VM security profiles; they are
+// not blocked, since VMs may be created outside the API.
+type OrgSecurityPolicy struct {
+	// AllowPrivilegedDevices allows host devices and GPUs passed through to VMs
+	AllowPrivilegedDevices bool `json:"allowPrivilegedDevices"`
+	// AllowHostPassthroughCPU allows the host-passthrough CPU model
+	AllowHostPassthroughCPU bool `json:"allowHostPassthroughCpu"`
+	// AllowHostNetwork allows VMs whose virt-launcher pod uses the host network
+	AllowHostNetwork bool `json:"allowHostNetwork"`
+	// AllowCustomTolerations allows tolerations set on the VM spec
+	AllowCustomTolerations bool `json:"allowCustomTolerations"`
+}
+
 // IsProvider checks if this is the default Provider organization
 func (o *Organization) IsProvider() bool {
 	return o.Name == DefaultOrgName
 }
+
+// OrganizationRollup aggregates resource counts for an organization and all of
+// its descendant organizations
+type OrganizationRollup struct {
+	OrgID            string `json:"orgId"`
+	IncludedOrgCount int    `json:"includedOrgCount"`
+	OrgVdcCount      int64  `json:"orgVdcCount"`
+	CatalogCount     int64  `json:"catalogCount"`
+	VappCount        int64  `json:"vappCount"`
+	VMCount          int64  `json:"vmCount"`
+	RunningVMCount   int64  `json:"runningVMCount"`
+	UserCount        int64  `json:"userCount"`
+	// Quota and QuotaUsage cover the organization's own VDCs, not those of
+	// its descendants, which have quotas of their own
+	Quota      *OrgQuota     `json:"quota,omitempty"`
+	QuotaUsage OrgQuotaUsage `json:"quotaUsage"`
+}
@@ -0,0 +1,38 @@
+package models
+
+// Rights (VMware Cloud Director right names) that guard API operations
+const (
+	RightOrgVdcView   = "Organization vDC: View"
+	RightOrgVdcCreate = "Organization vDC: Create"
+	RightOrgVdcEdit   = "Organization vDC: Edit"
+	RightOrgVdcDelete = "Organization vDC: Delete"
+)
+
+// roleRights maps the predefined roles to the rights they grant. The System
+// Administrator role implicitly holds every right.
+var roleRights = map[string][]string{
+	RoleOrgAdmin: {
+		RightOrgVdcView,
+	},
+	RoleVAppUser: {
+		RightOrgVdcView,
+	},
+}
+
+// HasRight checks if this role grants the named right
+func (r *Role) HasRight(right string) bool {
+	return RoleHasRight(r.Name, right)
+}
+
+// RoleHasRight checks if the role with the given name grants the named right
+func RoleHasRight(roleName, right string) bool {
+	if roleName == RoleSystemAdmin {
+		return true
+	}
+	for _, granted := range roleRights[roleName] {
+		if granted == right {
+			return true
+		}
+	}
+	return false
+}
@@ -11,4 +11,6 @@ type Session struct {
 	Roles                     []string    `json:"roles"`
 	RoleRefs                  []EntityRef `json:"roleRefs"`
 	SessionIdleTimeoutMinutes int         `json:"sessionIdleTimeoutMinutes"`
+	// ImpersonatedBy is the System Administrator acting as User, set only on impersonation sessions
+	ImpersonatedBy *EntityRef `json:"impersonatedBy,omitempty"`
 }
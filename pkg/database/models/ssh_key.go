@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SSHKey is a public key registered by a user for injection into the VMs they create
+type SSHKey struct {
+	ID          string    `gorm:"type:varchar(255);primaryKey" json:"id"`
+	UserID      string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_ssh_keys_user_name;uniqueIndex:idx_ssh_keys_user_fingerprint" json:"-"`
+	Name        string    `gorm:"size:255;not null;uniqueIndex:idx_ssh_keys_user_name" json:"name"`
+	PublicKey   string    `gorm:"type:text;not null" json:"publicKey"`
+	Fingerprint string    `gorm:"size:128;not null;uniqueIndex:idx_ssh_keys_user_fingerprint" json:"fingerprint"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// Relationships
+	User *User `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate sets the URN ID if not already set
+func (k *SSHKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = GenerateSSHKeyURN()
+	}
+	return nil
+}
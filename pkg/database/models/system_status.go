@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Platform subsystems reported by the system status
+const (
+	SubsystemAPI          = "api"
+	SubsystemProvisioning = "provisioning"
+	SubsystemNetworking   = "networking"
+)
+
+// Subsystems lists the platform subsystems in the order they are reported
+var Subsystems = []string{SubsystemAPI, SubsystemProvisioning, SubsystemNetworking}
+
+// System status levels
+const (
+	SystemStatusOperational = "operational"
+	SystemStatusMaintenance = "maintenance"
+	SystemStatusDegraded    = "degraded"
+	SystemStatusOutage      = "outage"
+)
+
+// SystemStatusSeverity orders status levels, so the worst of several can be
+// reported; unknown levels rank with operational
+func SystemStatusSeverity(status string) int {
+	switch status {
+	case SystemStatusMaintenance:
+		return 1
+	case SystemStatusDegraded:
+		return 2
+	case SystemStatusOutage:
+		return 3
+	}
+	return 0
+}
+
+// IsValidSystemStatus reports whether status is a known status level
+func IsValidSystemStatus(status string) bool {
+	switch status {
+	case SystemStatusOperational, SystemStatusMaintenance, SystemStatusDegraded, SystemStatusOutage:
+		return true
+	}
+	return false
+}
+
+// SystemStatusOverrideID is the ID of the only SystemStatusOverride row
+const SystemStatusOverrideID = "system"
+
+// SystemStatusOverride is a status set by a System Administrator, such as a
+// planned maintenance notice, shown instead of the measured status of its
+// subsystems while it is in effect
+type SystemStatusOverride struct {
+	ID      string `gorm:"type:varchar(32);primaryKey" json:"-"`
+	Status  string `gorm:"size:32;not null" json:"status"`
+	Message string `gorm:"size:1024" json:"message"`
+	// StartsAt and EndsAt bound when the override is in effect; unset bounds
+	// are open
+	StartsAt  *time.Time `json:"startsAt,omitempty"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+	UpdatedBy string     `gorm:"type:varchar(255)" json:"updatedBy"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+
+	SubsystemsData string `gorm:"type:text" json:"-"`
+	// Subsystems the override applies to; empty applies it to all
+	Subsystems []string `gorm:"-" json:"subsystems"`
+}
+
+// AfterFind decodes the subsystems of the override
+func (o *SystemStatusOverride) AfterFind(tx *gorm.DB) error {
+	o.Subsystems = []string{}
+	if o.SubsystemsData != "" {
+		_ = json.Unmarshal([]byte(o.SubsystemsData), &o.Subsystems)
+	}
+	return nil
+}
+
+// SetSubsystems sets the subsystems the override applies to
+func (o *SystemStatusOverride) SetSubsystems(subsystems []string) {
+	if subsystems == nil {
+		subsystems = []string{}
+	}
+	o.Subsystems = subsystems
+	data, _ := json.Marshal(subsystems)
+	o.SubsystemsData = string(data)
+}
+
+// ActiveAt reports whether the override is in effect at now
+func (o *SystemStatusOverride) ActiveAt(now time.Time) bool {
+	if o.StartsAt != nil && now.Before(*o.StartsAt) {
+		return false
+	}
+	return o.EndsAt == nil || now.Before(*o.EndsAt)
+}
+
+// AppliesTo reports whether the override covers subsystem
+func (o *SystemStatusOverride) AppliesTo(subsystem string) bool {
+	return len(o.Subsystems) == 0 || slices.Contains(o.Subsystems, subsystem)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Task status constants (VMware Cloud Director task states)
+const (
+	TaskStatusQueued  = "queued"
+	TaskStatusRunning = "running"
+	TaskStatusSuccess = "success"
+	TaskStatusError   = "error"
+	TaskStatusAborted = "aborted"
+	// TaskStatusPendingApproval tasks record operations held by the approval
+	// webhook until the approval system decides on them
+	TaskStatusPendingApproval = "pendingApproval"
+)
+
+// Task operation names
+const (
+	TaskOperationVMPowerOn   = "vmPowerOn"
+	TaskOperationVMPowerOff  = "vmPowerOff"
+	TaskOperationVMReboot    = "vmReboot"
+	TaskOperationVMReset     = "vmReset"
+	TaskOperationVMDelete    = "vmDelete"
+	TaskOperationVMExport    = "vmExport"
+	TaskOperationVAppDelete  = "vappDelete"
+	TaskOperationVAppPowerOn = "vappPowerOn"
+	TaskOperationVAppRetry   = "vappRetry"
+	TaskOperationUserImport  = "userImport"
+	TaskOperationGPUVMCreate = "gpuVmCreate"
+	TaskOperationVDCDelete   = "vdcDelete"
+)
+
+// Task tracks a long-running operation on an entity
+type Task struct {
+	ID             string     `gorm:"type:varchar(255);primary_key" json:"id"`
+	Name           string     `gorm:"size:255;not null" json:"name"`
+	Operation      string     `json:"operation"`
+	Status         string     `gorm:"size:32;not null;index" json:"status"`
+	OwnerID        string     `gorm:"type:varchar(255);index" json:"ownerId"`
+	OwnerName      string     `gorm:"size:255" json:"ownerName"`
+	OrganizationID string     `gorm:"type:varchar(255);index" json:"orgId"`
+	UserID         string     `gorm:"type:varchar(255)" json:"userId"`
+	Progress       int        `gorm:"default:0" json:"progress"`
+	Details        string     `json:"details,omitempty"`
+	StartTime      time.Time  `json:"startTime"`
+	EndTime        *time.Time `json:"endTime,omitempty"`
+	CreatedAt      time.Time  `json:"-"`
+	UpdatedAt      time.Time  `json:"-"`
+
+	// ResultData is the JSON result of an operation that produces one, such
+	// as the per-row outcome of a user import
+	ResultData string `json:"-"`
+}
+
+func (t *Task) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = GenerateTaskURN()
+	}
+	if t.Status == "" {
+		t.Status = TaskStatusQueued
+	}
+	if t.StartTime.IsZero() {
+		t.StartTime = time.Now()
+	}
+	return nil
+}
+
+// IsTerminal reports whether the task has finished
+func (t *Task) IsTerminal() bool {
+	return IsTerminalTaskStatus(t.Status)
+}
+
+// IsTerminalTaskStatus reports whether status is a final task state
+func IsTerminalTaskStatus(status string) bool {
+	switch status {
+	case TaskStatusSuccess, TaskStatusError, TaskStatusAborted:
+		return true
+	default:
+		return false
+	}
+}
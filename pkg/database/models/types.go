@@ -32,6 +32,50 @@ func (am AllocationModel) String() string {
 	return string(am)
 }
 
+// InterfaceType is the KubeVirt binding used to connect a VM NIC to its network
+type InterfaceType string
+
+const (
+	InterfaceTypeBridge     InterfaceType = "bridge"
+	InterfaceTypeMasquerade InterfaceType = "masquerade"
+	InterfaceTypeSRIOV      InterfaceType = "sriov"
+)
+
+// DefaultAllowedInterfaceTypes are the interface types a VDC allows unless
+// configured otherwise. SR-IOV consumes scarce host devices, so it must be
+// enabled per VDC.
+var DefaultAllowedInterfaceTypes = []InterfaceType{InterfaceTypeBridge, InterfaceTypeMasquerade}
+
+// Valid checks if the interface type is valid
+func (it InterfaceType) Valid() bool {
+	switch it {
+	case InterfaceTypeBridge, InterfaceTypeMasquerade, InterfaceTypeSRIOV:
+		return true
+	default:
+		return false
+	}
+}
+
+// DiskTier is the storage tier of a VM disk. Ephemeral disks are containerDisk
+// or emptyDisk volumes discarded when the VM stops; persistent disks are
+// DataVolumes backed by a PersistentVolumeClaim in a storage profile.
+type DiskTier string
+
+const (
+	DiskTierEphemeral  DiskTier = "ephemeral"
+	DiskTierPersistent DiskTier = "persistent"
+)
+
+// Valid checks if the disk tier is valid
+func (dt DiskTier) Valid() bool {
+	switch dt {
+	case DiskTierEphemeral, DiskTierPersistent:
+		return true
+	default:
+		return false
+	}
+}
+
 // URN constants for VMware Cloud Director compatibility
 const (
 	URNPrefixUser        = "urn:vcloud:user:"
@@ -43,6 +87,9 @@ const (
 	URNPrefixCatalogItem = "urn:vcloud:catalogitem:"
 	URNPrefixVApp        = "urn:vcloud:vapp:"
 	URNPrefixVM          = "urn:vcloud:vm:"
+	URNPrefixTask        = "urn:vcloud:task:"
+	URNPrefixSSHKey      = "urn:vcloud:sshkey:"
+	URNPrefixVDCStorage  = "urn:vcloud:vdcstorageProfile:"
 )
 
 // Role constants
@@ -100,6 +147,18 @@ func GenerateVMURN() string {
 	return URNPrefixVM + uuid.New().String()
 }
 
+func GenerateTaskURN() string {
+	return URNPrefixTask + uuid.New().String()
+}
+
+func GenerateSSHKeyURN() string {
+	return URNPrefixSSHKey + uuid.New().String()
+}
+
+func GenerateVDCStorageProfileURN() string {
+	return URNPrefixVDCStorage + uuid.New().String()
+}
+
 // ParseURN extracts the UUID from a URN
 func ParseURN(urn string) (string, error) {
 	if urn == "" {
@@ -127,6 +186,12 @@ func ParseURN(urn string) (string, error) {
 		prefix = URNPrefixVApp
 	case strings.HasPrefix(urn, URNPrefixVM):
 		prefix = URNPrefixVM
+	case strings.HasPrefix(urn, URNPrefixTask):
+		prefix = URNPrefixTask
+	case strings.HasPrefix(urn, URNPrefixSSHKey):
+		prefix = URNPrefixSSHKey
+	case strings.HasPrefix(urn, URNPrefixVDCStorage):
+		prefix = URNPrefixVDCStorage
 	default:
 		return "", fmt.Errorf("invalid URN prefix: %s", urn)
 	}
@@ -160,6 +225,12 @@ func GetURNType(urn string) (string, error) {
 		return "vapp", nil
 	case strings.HasPrefix(urn, URNPrefixVM):
 		return "vm", nil
+	case strings.HasPrefix(urn, URNPrefixTask):
+		return "task", nil
+	case strings.HasPrefix(urn, URNPrefixSSHKey):
+		return "sshkey", nil
+	case strings.HasPrefix(urn, URNPrefixVDCStorage):
+		return "vdcstorageprofile", nil
 	default:
 		return "", fmt.Errorf("unknown URN type: %s", urn)
 	}
@@ -4,32 +4,36 @@ import (
 	"fmt"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/auth/password"
 )
 
 // User represents a user account following VMware Cloud Director API spec
 type User struct {
-	ID               string         `gorm:"type:varchar(255);primaryKey" json:"id"`
-	Username         string         `gorm:"unique;not null;size:255" json:"username"`
-	FullName         string         `gorm:"not null;size:255" json:"fullName"`
-	Description      string         `json:"description"`
-	Email            string         `gorm:"unique;not null;size:255" json:"email"`
-	PasswordHash     string         `gorm:"not null" json:"-"`
-	Password         string         `gorm:"-" json:"-"` // Only for input, never serialized to JSON
-	DeployedVmQuota  int            `gorm:"default:0;not null" json:"deployedVmQuota"`
-	StoredVmQuota    int            `gorm:"default:0;not null" json:"storedVmQuota"`
-	NameInSource     string         `json:"nameInSource"`
-	Enabled          bool           `gorm:"default:true;not null" json:"enabled"`
-	IsGroupRole      bool           `gorm:"default:false;not null" json:"isGroupRole"`
-	ProviderType     string         `gorm:"default:'LOCAL';not null;size:50" json:"providerType"`
-	Locked           bool           `gorm:"default:false;not null" json:"locked"`
-	Stranded         bool           `gorm:"default:false;not null" json:"stranded"`
-	OrganizationID   *string        `gorm:"index;type:varchar(255)" json:"organizationId,omitempty"`
-	OrganizationName string         `gorm:"size:255" json:"organizationName,omitempty"`
-	CreatedAt        time.Time      `json:"created_at"`
-	UpdatedAt        time.Time      `json:"updated_at"`
-	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID               string  `gorm:"type:varchar(255);primaryKey" json:"id"`
+	Username         string  `gorm:"unique;not null;size:255" json:"username"`
+	FullName         string  `gorm:"not null;size:255" json:"fullName"`
+	Description      string  `json:"description"`
+	Email            string  `gorm:"unique;not null;size:255" json:"email"`
+	PasswordHash     string  `gorm:"not null" json:"-"`
+	Password         string  `gorm:"-" json:"-"` // Only for input, never serialized to JSON
+	DeployedVmQuota  int     `gorm:"default:0;not null" json:"deployedVmQuota"`
+	StoredVmQuota    int     `gorm:"default:0;not null" json:"storedVmQuota"`
+	NameInSource     string  `json:"nameInSource"`
+	Enabled          bool    `gorm:"default:true;not null" json:"enabled"`
+	IsGroupRole      bool    `gorm:"default:false;not null" json:"isGroupRole"`
+	ProviderType     string  `gorm:"default:'LOCAL';not null;size:50" json:"providerType"`
+	Locked           bool    `gorm:"default:false;not null" json:"locked"`
+	Stranded         bool    `gorm:"default:false;not null" json:"stranded"`
+	OrganizationID   *string `gorm:"index;type:varchar(255)" json:"organizationId,omitempty"`
+	OrganizationName string  `gorm:"size:255" json:"organizationName,omitempty"`
+	// GroupSynced marks users whose organization and roles are managed by the
+	// OpenShift Group sync; manual changes to them are overwritten
+	GroupSynced bool           `gorm:"default:false;not null" json:"groupSynced"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Entity references (populated in API responses)
 	RoleEntityRefs []EntityRef `gorm:"-" json:"roleEntityRefs,omitempty"`
@@ -77,20 +81,25 @@ func (u *User) normalizeOrgRef(tx *gorm.DB) error {
 	return nil
 }
 
-// SetPassword hashes the provided password and stores it in the PasswordHash field
-func (u *User) SetPassword(password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// SetPassword hashes the provided password with the configured algorithm and stores it in the PasswordHash field
+func (u *User) SetPassword(plaintext string) error {
+	hashedPassword, err := password.Hash(plaintext)
 	if err != nil {
 		return err
 	}
-	u.PasswordHash = string(hashedPassword)
+	u.PasswordHash = hashedPassword
 	return nil
 }
 
 // CheckPassword verifies if the provided password matches the stored hash
-func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
-	return err == nil
+func (u *User) CheckPassword(plaintext string) bool {
+	ok, err := password.Verify(plaintext, u.PasswordHash)
+	return err == nil && ok
+}
+
+// PasswordNeedsRehash reports whether the stored hash uses an outdated algorithm or parameters
+func (u *User) PasswordNeedsRehash() bool {
+	return password.NeedsRehash(u.PasswordHash)
 }
 
 // IsSystemAdmin checks if the user has System Administrator role
@@ -39,15 +39,23 @@ func IsValidVAppStatus(status string) bool {
 }
 
 type VApp struct {
-	ID          string         `gorm:"type:varchar(255);primary_key" json:"id"`
-	Name        string         `gorm:"not null;uniqueIndex:idx_vapp_vdc_name" json:"name"`
-	VDCID       string         `gorm:"type:varchar(255);not null;index;uniqueIndex:idx_vapp_vdc_name" json:"vdc_id"`
-	TemplateID  *string        `gorm:"type:varchar(255);index" json:"template_id"`
-	Status      string         `json:"status"` // INSTANTIATING, DEPLOYED, FAILED, DELETING, DELETED, etc.
-	Description string         `json:"description"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID                   string         `gorm:"type:varchar(255);primary_key" json:"id"`
+	Name                 string         `gorm:"not null;uniqueIndex:idx_vapp_vdc_name" json:"name"`
+	VDCID                string         `gorm:"type:varchar(255);not null;index;uniqueIndex:idx_vapp_vdc_name" json:"vdc_id"`
+	TemplateID           *string        `gorm:"type:varchar(255);index" json:"template_id"`
+	TemplateInstanceName string         `gorm:"size:255" json:"template_instance_name,omitempty"` // OpenShift TemplateInstance that created the vApp's resources
+	CatalogItemID        string         `gorm:"type:varchar(255);index" json:"catalog_item_id,omitempty"`
+	TemplateName         string         `gorm:"size:255" json:"template_name,omitempty"` // OpenShift Template the TemplateInstance was created from
+	Status               string         `json:"status"`                                  // INSTANTIATING, DEPLOYED, FAILED, DELETING, DELETED, etc.
+	HealthState          string         `gorm:"size:32" json:"health_state"`             // Worst health state of the vApp's VMs
+	Description          string         `json:"description"`
+	BackupEnabled        *bool          `json:"-"`                  // Overrides the VDC's backup policy when set
+	BackupSchedule       string         `gorm:"size:63" json:"-"`   // Velero Schedule of the vApp's backup policy
+	ConditionsData       string         `gorm:"type:text" json:"-"` // Readiness conditions, JSON-encoded and maintained by the vappstatus controller
+	InstantiationData    string         `gorm:"type:text" json:"-"` // TemplateInstance request, JSON-encoded, kept so a failed instantiation can be retried
+	CreatedAt            time.Time      `json:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at"`
+	DeletedAt            gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Relationships
 	VDC      *VDC          `gorm:"foreignKey:VDCID;references:ID" json:"vdc,omitempty"`
@@ -55,6 +63,58 @@ type VApp struct {
 	VMs      []VM          `gorm:"foreignKey:VAppID;references:ID" json:"vms,omitempty"`
 }
 
+// GetTemplateInstanceName returns the name of the vApp's TemplateInstance. vApps
+// created before the name was recorded used the vApp name.
+func (va *VApp) GetTemplateInstanceName() string {
+	if va.TemplateInstanceName != "" {
+		return va.TemplateInstanceName
+	}
+	return va.Name
+}
+
+// GetHealthState returns the vApp's health state, treating a vApp whose health
+// has not been evaluated yet as UNKNOWN
+func (va *VApp) GetHealthState() string {
+	if va.HealthState == "" {
+		return HealthStateUnknown
+	}
+	return va.HealthState
+}
+
+// Conditions returns the readiness conditions of the vApp
+func (va *VApp) Conditions() []Condition {
+	return decodeConditions(va.ConditionsData)
+}
+
+// SetConditions sets the readiness conditions of the vApp
+func (va *VApp) SetConditions(conditions []Condition) {
+	va.ConditionsData = encodeConditions(conditions)
+}
+
+// BackupPolicy returns the vApp's backup policy, falling back to the policy of
+// its VDC when the vApp does not override it. It returns nil when neither sets one.
+func (va *VApp) BackupPolicy(vdc *VDC) *BackupPolicy {
+	if va.BackupEnabled != nil {
+		return &BackupPolicy{Enabled: *va.BackupEnabled, Schedule: va.BackupSchedule}
+	}
+	if vdc == nil {
+		return nil
+	}
+	return vdc.BackupPolicy()
+}
+
+// SetBackupPolicy overrides the backup policy of the vApp's VDC; nil restores it
+func (va *VApp) SetBackupPolicy(policy *BackupPolicy) {
+	if policy == nil {
+		va.BackupEnabled = nil
+		va.BackupSchedule = ""
+		return
+	}
+	enabled := policy.Enabled
+	va.BackupEnabled = &enabled
+	va.BackupSchedule = policy.Schedule
+}
+
 func (va *VApp) BeforeCreate(tx *gorm.DB) error {
 	if va.ID == "" {
 		va.ID = GenerateVAppURN()
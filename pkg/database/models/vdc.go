@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -35,17 +36,65 @@ type VDC struct {
 	IsThinProvision bool `gorm:"default:false" json:"isThinProvision"`
 	IsEnabled       bool `gorm:"default:true" json:"isEnabled"`
 
+	// ExternalID is an optional client-provided identifier that makes creation
+	// idempotent for infrastructure-as-code tools; it cannot be changed
+	ExternalID *string `gorm:"type:varchar(255);uniqueIndex:idx_vdc_external_id_active,where:deleted_at IS NULL" json:"-"` // Hidden, exposed as externalId
+
+	// Comma-separated interface types VM NICs in this VDC may request
+	AllowedInterfaceTypes string `gorm:"default:'bridge,masquerade'" json:"-"` // Hidden, exposed as allowedInterfaceTypes
+
+	// Storage usage alert thresholds, as percentages of each storage profile's limit
+	StorageWarningThreshold  int `gorm:"default:80" json:"-"`
+	StorageCriticalThreshold int `gorm:"default:95" json:"-"`
+
+	// Compute quota policy: a soft limit that raises warnings, as a percentage of
+	// the compute limits, and a grace allowance that lets burst workloads exceed
+	// the limits by a percentage for the configured grace period
+	SoftQuotaPercent    int        `gorm:"default:0" json:"-"`
+	QuotaGracePercent   int        `gorm:"default:0" json:"-"`
+	QuotaGraceStartedAt *time.Time `json:"-"` // When the VDC last went over its compute limits
+
+	// Object quota overrides, JSON-encoded: counts of pods, PVCs, services,
+	// secrets and ConfigMaps replacing the configured defaults in the VDC's
+	// ResourceQuota
+	ObjectQuotaData string `gorm:"type:text" json:"-"`
+
+	// Auto-suspend policy: VMs whose CPU usage stays below the threshold, as a
+	// percentage of their vCPUs, for the idle period are suspended or powered off
+	AutoSuspendEnabled      bool   `gorm:"default:false" json:"-"`
+	AutoSuspendIdleHours    int    `gorm:"default:0" json:"-"`
+	AutoSuspendCPUThreshold int    `gorm:"default:0" json:"-"`
+	AutoSuspendAction       string `gorm:"size:16" json:"-"`
+
+	// Metadata policy: labels and annotations, JSON-encoded, applied to the
+	// TemplateInstances, VirtualMachines and VM pods created in this VDC
+	MetadataPolicyData string `gorm:"type:text" json:"-"`
+
+	// Backup policy: whether the VMs of vApps in this VDC are backed up by
+	// OADP/Velero, and by which Schedule. Unset leaves backups to the operator.
+	BackupEnabled  *bool  `json:"-"`
+	BackupSchedule string `gorm:"size:63" json:"-"`
+
+	// DNSZone is the domain under which the VDC's VMs are published through
+	// ExternalDNS, as <vm>.<zone>; empty disables DNS records
+	DNSZone string `gorm:"size:253" json:"-"`
+
 	// Kubernetes integration (hidden from JSON)
 	Namespace string `gorm:"size:253;uniqueIndex:idx_vdc_namespace_active,where:deleted_at IS NULL" json:"-"` // Kubernetes namespace for this VDC
 
+	// Readiness conditions of the VDC's Kubernetes resources, JSON-encoded and
+	// maintained by the vdcconditions controller
+	ConditionsData string `gorm:"type:text" json:"-"`
+
 	// Timestamps (hidden from JSON in VCD format)
 	CreatedAt time.Time      `json:"-"`
 	UpdatedAt time.Time      `json:"-"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relationships (hidden from JSON)
-	Organization *Organization `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
-	VApps        []VApp        `gorm:"foreignKey:VDCID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Organization    *Organization       `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	VApps           []VApp              `gorm:"foreignKey:VDCID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	StorageProfiles []VDCStorageProfile `gorm:"foreignKey:VDCID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
 }
 
 // ComputeCapacity represents the compute capacity structure for VCD compliance
@@ -66,9 +115,120 @@ type ProviderVdc struct {
 	ID string `json:"id"`
 }
 
-// VdcStorageProfiles represents storage profiles (empty for now as specified)
+// VdcStorageProfiles lists the storage profiles a VDC uses, with their usage
 type VdcStorageProfiles struct {
-	// Empty for now as specified in requirements
+	VdcStorageProfile []VdcStorageProfile `json:"vdcStorageProfile,omitempty"`
+}
+
+// VdcStorageProfile reports a VDC storage profile's limit and usage
+type VdcStorageProfile struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Units         string `json:"units"`
+	Limit         int64  `json:"limit"`
+	StorageUsedMB int64  `json:"storageUsedMB"`
+	UsagePercent  int    `json:"usagePercent"`
+	// UsageAlert is WARNING or CRITICAL once usage crosses the VDC's thresholds
+	UsageAlert string `json:"usageAlert,omitempty"`
+	Default    bool   `json:"default"`
+	// DiskTier is the tier template disks default to in the VDC
+	DiskTier DiskTier `json:"diskTier,omitempty"`
+}
+
+// ComputeQuotaPolicy configures soft quota warnings and grace allocations for a
+// VDC's compute limits. Zero disables either.
+type ComputeQuotaPolicy struct {
+	SoftLimitPercent int `json:"softLimitPercent"`
+	GracePercent     int `json:"gracePercent"`
+}
+
+// ObjectQuota limits the number of Kubernetes objects in a VDC namespace. A
+// nil count falls back to the default profile; a count of 0 leaves the object
+// unlimited.
+type ObjectQuota struct {
+	Pods                   *int `json:"pods,omitempty"`
+	PersistentVolumeClaims *int `json:"persistentVolumeClaims,omitempty"`
+	Services               *int `json:"services,omitempty"`
+	Secrets                *int `json:"secrets,omitempty"`
+	ConfigMaps             *int `json:"configMaps,omitempty"`
+}
+
+// IsEmpty reports whether no count is set
+func (q ObjectQuota) IsEmpty() bool {
+	return q.Pods == nil && q.PersistentVolumeClaims == nil && q.Services == nil && q.Secrets == nil && q.ConfigMaps == nil
+}
+
+// WithOverrides returns the quota with the counts set in overrides replacing its own
+func (q ObjectQuota) WithOverrides(overrides ObjectQuota) ObjectQuota {
+	if overrides.Pods != nil {
+		q.Pods = overrides.Pods
+	}
+	if overrides.PersistentVolumeClaims != nil {
+		q.PersistentVolumeClaims = overrides.PersistentVolumeClaims
+	}
+	if overrides.Services != nil {
+		q.Services = overrides.Services
+	}
+	if overrides.Secrets != nil {
+		q.Secrets = overrides.Secrets
+	}
+	if overrides.ConfigMaps != nil {
+		q.ConfigMaps = overrides.ConfigMaps
+	}
+	return q
+}
+
+// Actions the auto-suspend policy takes on idle VMs
+const (
+	AutoSuspendActionSuspend  = "suspend"
+	AutoSuspendActionPowerOff = "powerOff"
+)
+
+// DefaultAutoSuspendCPUThreshold is the CPU usage, as a percentage of a VM's
+// vCPUs, below which a VM counts as idle when the policy does not set one
+const DefaultAutoSuspendCPUThreshold = 5
+
+// AutoSuspendPolicy suspends or powers off VMs that stay idle for IdleHours.
+// A VM is idle while its CPU usage is below CPUThresholdPercent of its vCPUs.
+type AutoSuspendPolicy struct {
+	Enabled             bool   `json:"enabled"`
+	IdleHours           int    `json:"idleHours"`
+	CPUThresholdPercent int    `json:"cpuThresholdPercent"`
+	Action              string `json:"action"`
+}
+
+// IdlePeriod returns how long a VM must be idle before the policy acts
+func (p AutoSuspendPolicy) IdlePeriod() time.Duration {
+	return time.Duration(p.IdleHours) * time.Hour
+}
+
+// MetadataPolicy lists labels and annotations applied to the resources created
+// when a vApp is instantiated, for integrations such as backup tooling and cost
+// reporting that select resources by label
+type MetadataPolicy struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// BackupPolicy selects whether VMs are backed up by OADP/Velero. Schedule
+// names the operator-defined Velero Schedule that backs them up; empty leaves
+// the choice to Schedules selecting every enabled VM.
+type BackupPolicy struct {
+	Enabled  bool   `json:"enabled"`
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// ComputeUsage is the compute capacity a VDC's VMs reserve
+type ComputeUsage struct {
+	VMs      int64
+	CPUs     int64
+	MemoryMB int64
+}
+
+// StorageAlertThresholds are the storage usage percentages that raise alerts
+type StorageAlertThresholds struct {
+	Warning  int `json:"warning"`
+	Critical int `json:"critical"`
 }
 
 // ComputeCapacity returns the VCD-compliant compute capacity structure
@@ -113,134 +273,236 @@ func (v *VDC) SetProviderVdc(pv ProviderVdc) {
 	v.ProviderVdcID = pv.ID
 }
 
-// VdcStorageProfiles returns empty storage profiles as specified
-func (v *VDC) VdcStorageProfiles() VdcStorageProfiles {
-	return VdcStorageProfiles{}
+// InterfaceTypes returns the interface types VM NICs in this VDC may request
+func (v *VDC) InterfaceTypes() []InterfaceType {
+	if v.AllowedInterfaceTypes == "" {
+		return append([]InterfaceType(nil), DefaultAllowedInterfaceTypes...)
+	}
+	var types []InterfaceType
+	for _, t := range strings.Split(v.AllowedInterfaceTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, InterfaceType(t))
+		}
+	}
+	return types
 }
 
-// BeforeCreate sets up the VDC before database creation
-func (v *VDC) BeforeCreate(tx *gorm.DB) error {
-	if v.ID == "" {
-		v.ID = GenerateVDCURN()
+// SetInterfaceTypes sets the interface types VM NICs in this VDC may request
+func (v *VDC) SetInterfaceTypes(types []InterfaceType) {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
 	}
+	v.AllowedInterfaceTypes = strings.Join(names, ",")
+}
 
-	// Generate namespace name if not set
-	if v.Namespace == "" {
-		// Load organization to get name
-		var org Organization
-		if err := tx.Where("id = ?", v.OrganizationID).First(&org).Error; err != nil {
-			return fmt.Errorf("failed to load organization: %w", err)
+// AllowsInterfaceType reports whether VM NICs in this VDC may use the interface type
+func (v *VDC) AllowsInterfaceType(t InterfaceType) bool {
+	for _, allowed := range v.InterfaceTypes() {
+		if allowed == t {
+			return true
 		}
+	}
+	return false
+}
 
-		// Generate unique namespace name
-		namespace, err := generateUniqueNamespaceName(tx, org.Name, v.Name)
-		if err != nil {
-			return fmt.Errorf("failed to generate unique namespace: %w", err)
+// VdcStorageProfiles returns the VDC's storage profiles with their usage. The
+// StorageProfiles association must be loaded for profiles to be reported.
+func (v *VDC) VdcStorageProfiles() VdcStorageProfiles {
+	thresholds := v.StorageAlertThresholds()
+	var profiles VdcStorageProfiles
+	for _, p := range v.StorageProfiles {
+		profiles.VdcStorageProfile = append(profiles.VdcStorageProfile, VdcStorageProfile{
+			ID:            p.ID,
+			Name:          p.Name,
+			Units:         "MB",
+			Limit:         p.LimitMB,
+			StorageUsedMB: p.UsedMB,
+			UsagePercent:  p.UsagePercent(),
+			UsageAlert:    p.UsageAlert(thresholds.Warning, thresholds.Critical),
+			Default:       p.IsDefault,
+			DiskTier:      p.DiskTier,
+		})
+	}
+	return profiles
+}
+
+// DefaultStorageProfile returns the VDC's default storage profile, or nil when
+// it has none. The StorageProfiles association must be loaded.
+func (v *VDC) DefaultStorageProfile() *VDCStorageProfile {
+	for i := range v.StorageProfiles {
+		if v.StorageProfiles[i].IsDefault {
+			return &v.StorageProfiles[i]
 		}
-		v.Namespace = namespace
 	}
+	return nil
+}
 
-	// Set default units if not provided
-	if v.CPUUnits == "" {
-		v.CPUUnits = "MHz"
+// StorageAlertThresholds returns the VDC's storage usage alert thresholds,
+// falling back to the defaults when unset
+func (v *VDC) StorageAlertThresholds() StorageAlertThresholds {
+	thresholds := StorageAlertThresholds{
+		Warning:  v.StorageWarningThreshold,
+		Critical: v.StorageCriticalThreshold,
 	}
-	if v.MemoryUnits == "" {
-		v.MemoryUnits = "MB"
+	if thresholds.Warning <= 0 {
+		thresholds.Warning = DefaultStorageWarningThreshold
+	}
+	if thresholds.Critical <= 0 {
+		thresholds.Critical = DefaultStorageCriticalThreshold
 	}
+	return thresholds
+}
 
-	return nil
+// ComputeQuotaPolicy returns the VDC's soft quota and grace allowance
+func (v *VDC) ComputeQuotaPolicy() ComputeQuotaPolicy {
+	return ComputeQuotaPolicy{
+		SoftLimitPercent: v.SoftQuotaPercent,
+		GracePercent:     v.QuotaGracePercent,
+	}
 }
 
-// generateUniqueNamespaceName creates a unique Kubernetes-compliant namespace name
-func generateUniqueNamespaceName(tx *gorm.DB, orgName, vdcName string) (string, error) {
-	// Start with the base name
-	baseName := generateNamespaceName(orgName, vdcName)
+// SetComputeQuotaPolicy sets the VDC's soft quota and grace allowance
+func (v *VDC) SetComputeQuotaPolicy(policy ComputeQuotaPolicy) {
+	v.SoftQuotaPercent = policy.SoftLimitPercent
+	v.QuotaGracePercent = policy.GracePercent
+}
 
-	// Check if the base name is available
-	var existingVDC VDC
-	err := tx.Where("namespace = ?", baseName).First(&existingVDC).Error
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// Base name is available
-			return baseName, nil
-		}
-		// Database error
-		return "", err
+// ObjectQuota returns the object counts overriding the default profile in the
+// VDC's ResourceQuota
+func (v *VDC) ObjectQuota() ObjectQuota {
+	var quota ObjectQuota
+	if v.ObjectQuotaData != "" {
+		// The column is only written by SetObjectQuota
+		_ = json.Unmarshal([]byte(v.ObjectQuotaData), &quota)
 	}
+	return quota
+}
 
-	// Base name is taken, try with incremental suffixes
-	for i := 1; i <= 999; i++ {
-		suffix := fmt.Sprintf("-%d", i)
-
-		// Ensure the final name doesn't exceed 63 characters
-		maxBaseLength := 63 - len(suffix)
-		trimmedBase := baseName
-		if len(baseName) > maxBaseLength {
-			trimmedBase = baseName[:maxBaseLength]
-			// Remove any trailing hyphens after truncation
-			trimmedBase = strings.TrimRight(trimmedBase, "-")
-		}
+// SetObjectQuota sets the object counts overriding the default profile in the
+// VDC's ResourceQuota
+func (v *VDC) SetObjectQuota(quota ObjectQuota) {
+	if quota.IsEmpty() {
+		v.ObjectQuotaData = ""
+		return
+	}
+	data, _ := json.Marshal(quota)
+	v.ObjectQuotaData = string(data)
+}
 
-		// Safety check: if trimmedBase is empty after truncation, use a safe fallback
-		if trimmedBase == "" {
-			trimmedBase = "vdc"
-		}
+// AutoSuspendPolicy returns the VDC's idle VM policy, falling back to the
+// default CPU threshold and the suspend action when unset
+func (v *VDC) AutoSuspendPolicy() AutoSuspendPolicy {
+	policy := AutoSuspendPolicy{
+		Enabled:             v.AutoSuspendEnabled,
+		IdleHours:           v.AutoSuspendIdleHours,
+		CPUThresholdPercent: v.AutoSuspendCPUThreshold,
+		Action:              v.AutoSuspendAction,
+	}
+	if policy.CPUThresholdPercent <= 0 {
+		policy.CPUThresholdPercent = DefaultAutoSuspendCPUThreshold
+	}
+	if policy.Action == "" {
+		policy.Action = AutoSuspendActionSuspend
+	}
+	return policy
+}
 
-		candidateName := trimmedBase + suffix
+// SetAutoSuspendPolicy sets the VDC's idle VM policy
+func (v *VDC) SetAutoSuspendPolicy(policy AutoSuspendPolicy) {
+	v.AutoSuspendEnabled = policy.Enabled
+	v.AutoSuspendIdleHours = policy.IdleHours
+	v.AutoSuspendCPUThreshold = policy.CPUThresholdPercent
+	v.AutoSuspendAction = policy.Action
+}
 
-		err := tx.Where("namespace = ?", candidateName).First(&existingVDC).Error
-		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				// This candidate is available
-				return candidateName, nil
-			}
-			// Database error
-			return "", err
-		}
-		// This candidate is also taken, try the next one
+// MetadataPolicy returns the labels and annotations applied to resources
+// instantiated in the VDC
+func (v *VDC) MetadataPolicy() MetadataPolicy {
+	var policy MetadataPolicy
+	if v.MetadataPolicyData != "" {
+		// The column is only written by SetMetadataPolicy
+		_ = json.Unmarshal([]byte(v.MetadataPolicyData), &policy)
 	}
+	return policy
+}
 
-	// If we get here, we couldn't find a unique name after 999 attempts
-	return "", fmt.Errorf("unable to generate unique namespace name for org '%s' and VDC '%s'", orgName, vdcName)
+// SetMetadataPolicy sets the labels and annotations applied to resources
+// instantiated in the VDC
+func (v *VDC) SetMetadataPolicy(policy MetadataPolicy) {
+	if len(policy.Labels) == 0 && len(policy.Annotations) == 0 {
+		v.MetadataPolicyData = ""
+		return
+	}
+	data, _ := json.Marshal(policy)
+	v.MetadataPolicyData = string(data)
 }
 
-// generateNamespaceName creates a Kubernetes-compliant namespace name
-func generateNamespaceName(orgName, vdcName string) string {
-	// Convert to lowercase and replace invalid characters
-	orgSafe := strings.ToLower(strings.ReplaceAll(orgName, "_", "-"))
-	vdcSafe := strings.ToLower(strings.ReplaceAll(vdcName, "_", "-"))
+// Conditions returns the readiness conditions of the VDC
+func (v *VDC) Conditions() []Condition {
+	return decodeConditions(v.ConditionsData)
+}
 
-	// Remove any characters that aren't alphanumeric or hyphen
-	orgSafe = sanitizeKubernetesName(orgSafe)
-	vdcSafe = sanitizeKubernetesName(vdcSafe)
+// SetConditions sets the readiness conditions of the VDC
+func (v *VDC) SetConditions(conditions []Condition) {
+	v.ConditionsData = encodeConditions(conditions)
+}
 
-	// Build the base name
-	baseName := fmt.Sprintf("vdc-%s-%s", orgSafe, vdcSafe)
+// BackupPolicy returns the VDC's backup policy, or nil when it has none
+func (v *VDC) BackupPolicy() *BackupPolicy {
+	if v.BackupEnabled == nil {
+		return nil
+	}
+	return &BackupPolicy{Enabled: *v.BackupEnabled, Schedule: v.BackupSchedule}
+}
 
-	// Kubernetes namespace names must be 63 characters or less
-	// Reserve space for potential numeric suffix (e.g., "-999")
-	maxBaseLength := 63 - 4 // Reserve 4 chars for "-999"
-	if len(baseName) > maxBaseLength {
-		baseName = baseName[:maxBaseLength]
-		// Remove any trailing hyphens after truncation
-		baseName = strings.TrimRight(baseName, "-")
+// SetBackupPolicy sets the VDC's backup policy; nil removes it
+func (v *VDC) SetBackupPolicy(policy *BackupPolicy) {
+	if policy == nil {
+		v.BackupEnabled = nil
+		v.BackupSchedule = ""
+		return
 	}
+	enabled := policy.Enabled
+	v.BackupEnabled = &enabled
+	v.BackupSchedule = policy.Schedule
+}
 
-	return baseName
+// SetStorageAlertThresholds sets the VDC's storage usage alert thresholds
+func (v *VDC) SetStorageAlertThresholds(thresholds StorageAlertThresholds) {
+	v.StorageWarningThreshold = thresholds.Warning
+	v.StorageCriticalThreshold = thresholds.Critical
 }
 
-// sanitizeKubernetesName ensures the name is valid for Kubernetes
-func sanitizeKubernetesName(name string) string {
-	result := ""
-	for _, r := range name {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
-			result += string(r)
+// BeforeCreate sets up the VDC before database creation
+func (v *VDC) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = GenerateVDCURN()
+	}
+
+	// Generate namespace name if not set
+	if v.Namespace == "" {
+		// Load organization to get name
+		var org Organization
+		if err := tx.Where("id = ?", v.OrganizationID).First(&org).Error; err != nil {
+			return fmt.Errorf("failed to load organization: %w", err)
 		}
+
+		// Generate unique namespace name
+		namespace, err := GenerateVDCNamespace(tx, org.Name, v.Name, nil)
+		if err != nil {
+			return fmt.Errorf("failed to generate unique namespace: %w", err)
+		}
+		v.Namespace = namespace
+	}
+
+	// Set default units if not provided
+	if v.CPUUnits == "" {
+		v.CPUUnits = "MHz"
 	}
-	// Ensure it doesn't start or end with hyphen
-	result = strings.Trim(result, "-")
-	if result == "" {
-		result = "default"
+	if v.MemoryUnits == "" {
+		v.MemoryUnits = "MB"
 	}
-	return result
+
+	return nil
 }
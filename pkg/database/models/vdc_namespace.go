@@ -0,0 +1,119 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// VDC namespace names are "<prefix>-<org short name>-<vdc name>". Names that
+// would exceed the Kubernetes limit are truncated and end in a hash of the full
+// name, so long names sharing a prefix stay distinct; names that are still
+// taken get a numeric suffix.
+const (
+	VDCNamespacePrefix = "vdc"
+	// maxNamespaceLength is the Kubernetes limit for namespace names
+	maxNamespaceLength = 63
+	// maxOrgShortNameLength bounds the organization part so the VDC name stays readable
+	maxOrgShortNameLength = 20
+	// namespaceHashLength is the number of hex characters of the hash suffix
+	namespaceHashLength = 8
+	// maxNamespaceCollisions is the highest numeric suffix tried
+	maxNamespaceCollisions = 999
+)
+
+// ErrNamespaceUnavailable is returned when no free namespace name is found
+var ErrNamespaceUnavailable = errors.New("unable to generate unique namespace name")
+
+// NamespaceInUse reports whether a namespace name is used outside the VDC
+// table, for example by a namespace that already exists in the cluster
+type NamespaceInUse func(name string) (bool, error)
+
+// ValidateNamespaceName checks that a name is a valid Kubernetes namespace name
+func ValidateNamespaceName(name string) error {
+	if problems := validation.IsDNS1123Label(name); len(problems) > 0 {
+		return fmt.Errorf("invalid namespace name %q: %s", name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// OrgShortName returns the organization part of VDC namespace names
+func OrgShortName(orgName string) string {
+	short := sanitizeKubernetesName(orgName)
+	if len(short) > maxOrgShortNameLength {
+		short = strings.TrimRight(short[:maxOrgShortNameLength], "-")
+	}
+	return short
+}
+
+// VDCNamespaceName returns the preferred namespace name for a VDC. It leaves
+// room for the numeric suffix added on collisions.
+func VDCNamespaceName(orgName, vdcName string) string {
+	orgShort := OrgShortName(orgName)
+	vdcSafe := sanitizeKubernetesName(vdcName)
+	name := fmt.Sprintf("%s-%s-%s", VDCNamespacePrefix, orgShort, vdcSafe)
+
+	maxBaseLength := maxNamespaceLength - len(fmt.Sprintf("-%d", maxNamespaceCollisions))
+	if len(name) <= maxBaseLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(sanitizeKubernetesName(orgName) + "/" + vdcSafe))
+	hash := hex.EncodeToString(sum[:])[:namespaceHashLength]
+	name = strings.TrimRight(name[:maxBaseLength-len(hash)-1], "-")
+	return name + "-" + hash
+}
+
+// GenerateVDCNamespace returns a namespace name for a new VDC that no active
+// VDC uses and, when inUse is set, that is not otherwise taken
+func GenerateVDCNamespace(tx *gorm.DB, orgName, vdcName string, inUse NamespaceInUse) (string, error) {
+	base := VDCNamespaceName(orgName, vdcName)
+	for i := 0; i <= maxNamespaceCollisions; i++ {
+		candidate := base
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		var count int64
+		if err := tx.Model(&VDC{}).Where("namespace = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count > 0 {
+			continue
+		}
+		if inUse != nil {
+			taken, err := inUse(candidate)
+			if err != nil {
+				return "", err
+			}
+			if taken {
+				continue
+			}
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("%w for org '%s' and VDC '%s'", ErrNamespaceUnavailable, orgName, vdcName)
+}
+
+// sanitizeKubernetesName lowercases a name and keeps only the characters
+// allowed in Kubernetes names
+func sanitizeKubernetesName(name string) string {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	var result strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			result.WriteRune(r)
+		}
+	}
+	// Ensure it doesn't start or end with hyphen
+	sanitized := strings.Trim(result.String(), "-")
+	if sanitized == "" {
+		sanitized = "default"
+	}
+	return sanitized
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Storage usage alert levels
+const (
+	StorageAlertWarning  = "WARNING"
+	StorageAlertCritical = "CRITICAL"
+)
+
+// Default storage usage alert thresholds, as percentages of a profile's limit
+const (
+	DefaultStorageWarningThreshold  = 80
+	DefaultStorageCriticalThreshold = 95
+)
+
+// VDCStorageProfile tracks a VDC's use of a storage profile, which maps to the
+// Kubernetes StorageClass of the same name. Usage is the total storage requested
+// by the PersistentVolumeClaims in the VDC namespace.
+type VDCStorageProfile struct {
+	ID    string `gorm:"type:varchar(255);primaryKey" json:"id"`
+	VDCID string `gorm:"type:varchar(255);not null;uniqueIndex:idx_vdc_storage_profile_name" json:"-"`
+	Name  string `gorm:"size:253;not null;uniqueIndex:idx_vdc_storage_profile_name" json:"name"`
+	// LimitMB is the storage allowed in the profile; 0 means unlimited
+	LimitMB int64 `gorm:"default:0" json:"limit"`
+	UsedMB  int64 `gorm:"default:0" json:"storageUsedMB"`
+	// IsDefault marks the profile that persistent disks use when neither the
+	// template nor the instantiation names one; a VDC has at most one
+	IsDefault bool `gorm:"default:false" json:"default"`
+	// DiskTier is the tier given to template disks that do not request one,
+	// set only on the default profile; empty keeps the template's tiers
+	DiskTier DiskTier `gorm:"size:16" json:"diskTier,omitempty"`
+	// AlertLevel is the last usage alert raised, so each threshold alerts once
+	AlertLevel     string     `gorm:"size:20" json:"-"`
+	UsageUpdatedAt *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"-"`
+	UpdatedAt      time.Time  `json:"-"`
+
+	// Relationships
+	VDC *VDC `gorm:"foreignKey:VDCID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate sets the URN ID if not already set
+func (p *VDCStorageProfile) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = GenerateVDCStorageProfileURN()
+	}
+	return nil
+}
+
+// UsagePercent returns the used share of the profile's limit, or 0 when unlimited
+func (p *VDCStorageProfile) UsagePercent() int {
+	if p.LimitMB <= 0 {
+		return 0
+	}
+	return int(p.UsedMB * 100 / p.LimitMB)
+}
+
+// UsageAlert returns the alert level the profile's usage has reached given the
+// VDC's thresholds, or "" when usage is below both
+func (p *VDCStorageProfile) UsageAlert(warningThreshold, criticalThreshold int) string {
+	if p.LimitMB <= 0 {
+		return ""
+	}
+	percent := p.UsagePercent()
+	switch {
+	case percent >= criticalThreshold:
+		return StorageAlertCritical
+	case percent >= warningThreshold:
+		return StorageAlertWarning
+	default:
+		return ""
+	}
+}
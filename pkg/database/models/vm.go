@@ -1,25 +1,132 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Health states reported for VMs and vApps, separate from their power status
+const (
+	HealthStateHealthy  = "HEALTHY"
+	HealthStateDegraded = "DEGRADED"
+	HealthStateUnknown  = "UNKNOWN"
+)
+
+// VMStatusQuotaExceeded is the status of a VM that cannot start because its
+// VDC namespace's ResourceQuota is exhausted. KubeVirt keeps retrying, so the
+// VM starts once enough of the quota is freed.
+const VMStatusQuotaExceeded = "QUOTA_EXCEEDED"
+
+// Power states a VM can be asked to reach. The vm-controller reconciles each
+// VirtualMachine's run strategy toward the VM's desired power state.
+const (
+	VMPowerStateOn  = "POWERED_ON"
+	VMPowerStateOff = "POWERED_OFF"
+)
+
+// Run strategies a VM can be given, named like KubeVirt's. They decide what
+// happens when the guest of a powered-on VM stops.
+const (
+	// VMRunStrategyAlways restarts the VM whenever it stops
+	VMRunStrategyAlways = "Always"
+	// VMRunStrategyRerunOnFailure restarts the VM after a crash, but leaves it
+	// stopped when the guest shuts down
+	VMRunStrategyRerunOnFailure = "RerunOnFailure"
+	// VMRunStrategyManual never restarts the VM; it only starts and stops
+	// through power operations
+	VMRunStrategyManual = "Manual"
+	// VMRunStrategyHalted keeps the VM stopped; it cannot be powered on until
+	// it is given another strategy
+	VMRunStrategyHalted = "Halted"
+)
+
+// IsValidVMRunStrategy reports whether strategy is a known run strategy
+func IsValidVMRunStrategy(strategy string) bool {
+	switch strategy {
+	case VMRunStrategyAlways, VMRunStrategyRerunOnFailure, VMRunStrategyManual, VMRunStrategyHalted:
+		return true
+	}
+	return false
+}
+
+// Sources a VM can be created from, recorded so the origin of its images can
+// be traced
+const (
+	// VMSourceTemplate VMs were created by instantiating a template; SourceRef
+	// is the catalog item URN, or the template name if the TemplateInstance was
+	// not created through the API
+	VMSourceTemplate = "template"
+	// VMSourceImport VMs were imported from another platform; SourceRef is the
+	// name of the VM they were imported from
+	VMSourceImport = "import"
+	// VMSourceClone VMs were cloned; SourceRef names the VM they were cloned from
+	VMSourceClone = "clone"
+	// VMSourceManual VMs were created directly rather than from a template
+	VMSourceManual = "manual"
+)
+
+// IsValidVMSourceType reports whether sourceType is a known VM source
+func IsValidVMSourceType(sourceType string) bool {
+	switch sourceType {
+	case VMSourceTemplate, VMSourceImport, VMSourceClone, VMSourceManual:
+		return true
+	}
+	return false
+}
+
 type VM struct {
-	ID          string         `gorm:"type:varchar(255);primary_key" json:"id"`
-	Name        string         `gorm:"not null" json:"name"`
-	Description string         `json:"description"`
-	VAppID      string         `gorm:"column:vapp_id;type:varchar(255);not null;index" json:"vapp_id"`
-	VMName      string         `json:"vm_name"`   // OpenShift VM resource name
-	Namespace   string         `json:"namespace"` // OpenShift namespace
-	Status      string         `json:"status"`
-	CPUCount    *int           `gorm:"check:cpu_count > 0" json:"cpu_count"`
-	MemoryMB    *int           `gorm:"check:memory_mb > 0" json:"memory_mb"`
-	GuestOS     string         `json:"guest_os"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID          string `gorm:"type:varchar(255);primary_key" json:"id"`
+	Name        string `gorm:"not null" json:"name"`
+	Description string `json:"description"`
+	VAppID      string `gorm:"column:vapp_id;type:varchar(255);not null;index" json:"vapp_id"`
+	VMName      string `json:"vm_name"`   // OpenShift VM resource name
+	Namespace   string `json:"namespace"` // OpenShift namespace
+	Status      string `json:"status"`
+	// Why the VM is in its status, such as the quota that keeps it from starting
+	StatusDetails string         `json:"status_details,omitempty"`
+	HealthState   string         `gorm:"size:32" json:"health_state"`         // HEALTHY, DEGRADED or UNKNOWN
+	IPAddress     string         `gorm:"size:45" json:"ip_address,omitempty"` // Primary address reported by the running VM
+	DNSName       string         `gorm:"size:253" json:"dns_name,omitempty"`  // FQDN published for the VM through ExternalDNS
+	CPUCount      *int           `gorm:"check:cpu_count > 0" json:"cpu_count"`
+	MemoryMB      *int           `gorm:"check:memory_mb > 0" json:"memory_mb"`
+	GuestOS       string         `json:"guest_os"`
+	StartOrder    int            `gorm:"not null;default:0" json:"start_order"` // Startup section: VMs start in ascending order
+	StartDelay    int            `gorm:"not null;default:0" json:"start_delay"` // Startup section: seconds to wait after starting the VM
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Comma-separated tags, exposed as tags
+	Tags string `json:"-"`
+
+	// Idle tracking for the VDC's auto-suspend policy
+	IdleSince      *time.Time `json:"-"` // When the VM's CPU usage dropped below the idle threshold
+	IdleNotifiedAt *time.Time `json:"-"` // When the VM's owners were told it will be suspended
+
+	// Power state requested through the API; empty while none was requested,
+	// in which case the VirtualMachine's run strategy is left as it is
+	DesiredPowerState string `gorm:"size:32" json:"desired_power_state,omitempty"`
+	// Run strategy requested through the API; empty while none was requested,
+	// in which case a powered-on VM keeps the run strategy of its template
+	RunStrategy string `gorm:"size:32" json:"run_strategy,omitempty"`
+
+	// Lineage recorded when the VM record is created: what the VM was created
+	// from and the image its boot disk was populated from
+	SourceType  string `gorm:"size:32;index" json:"source_type,omitempty"`
+	SourceRef   string `json:"source_ref,omitempty"`
+	SourceImage string `gorm:"index" json:"source_image,omitempty"`
+
+	// FriendlyID numbers the VM within its organization, such as vm-0042, for
+	// support conversations; empty for VMs recorded before numbering
+	FriendlyID string `gorm:"size:32;index" json:"friendly_id,omitempty"`
+
+	// Hardware identity the guest sees in SMBIOS, set when the VM is created
+	// and kept across restarts and migrations; empty for VMs recorded before
+	// it was tracked
+	BIOSUUID     string `gorm:"column:bios_uuid;size:36" json:"bios_uuid,omitempty"`
+	SerialNumber string `gorm:"size:64" json:"serial_number,omitempty"`
 
 	// Relationships
 	VApp *VApp `gorm:"foreignKey:VAppID;references:ID" json:"vapp,omitempty"`
@@ -31,3 +138,51 @@ func (vm *VM) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// VMTagNoAutoSuspend opts a VM out of its VDC's auto-suspend policy
+const VMTagNoAutoSuspend = "no-auto-suspend"
+
+// TagList returns the VM's tags
+func (vm *VM) TagList() []string {
+	var tags []string
+	for _, tag := range strings.Split(vm.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// SetTags sets the VM's tags
+func (vm *VM) SetTags(tags []string) {
+	vm.Tags = strings.Join(tags, ",")
+}
+
+// HasTag reports whether the VM carries a tag
+func (vm *VM) HasTag(tag string) bool {
+	for _, t := range vm.TagList() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHealthState returns the VM's health state, treating a VM whose health has
+// not been evaluated yet as UNKNOWN
+func (vm *VM) GetHealthState() string {
+	if vm.HealthState == "" {
+		return HealthStateUnknown
+	}
+	return vm.HealthState
+}
+
+// VMStartup is a VM's entry in its vApp's startup section, mirroring the Start
+// Order of VMware Cloud Director. VMs with a lower Order start first and VMs with
+// the same Order start together; Delay is how many seconds to wait after
+// starting the VM before the next order starts.
+type VMStartup struct {
+	VMID  string
+	Order int
+	Delay int
+}
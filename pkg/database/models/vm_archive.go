@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ArchivedVM is a VM record moved out of the vms table after it was DELETED
+// for the archive period, together with the tasks run on it. The records are
+// stored as JSON so archived VMs are unaffected by later changes to the vms
+// and tasks tables.
+type ArchivedVM struct {
+	ID        string `gorm:"type:varchar(255);primaryKey" json:"id"`
+	Name      string `json:"name"`
+	VAppID    string `gorm:"column:vapp_id;type:varchar(255);index" json:"vappId"`
+	VMName    string `json:"vmName"`
+	Namespace string `gorm:"index" json:"namespace"`
+	// DeletedAt is when the VM was deleted, or last updated if it was only marked DELETED
+	DeletedAt  time.Time `json:"deletedAt"`
+	ArchivedAt time.Time `gorm:"index" json:"archivedAt"`
+
+	// Record is the VM and Tasks the list of its tasks, as JSON
+	Record string `json:"-"`
+	Tasks  string `json:"-"`
+}
+
+// archivedVMRecord is the JSON form of an archived VM, including the fields
+// the API does not expose so a restored VM is complete
+type archivedVMRecord struct {
+	VM
+	Tags           string     `json:"tags"`
+	IdleSince      *time.Time `json:"idle_since,omitempty"`
+	IdleNotifiedAt *time.Time `json:"idle_notified_at,omitempty"`
+}
+
+// archivedTaskRecord is the JSON form of an archived task, including the
+// fields the API does not expose
+type archivedTaskRecord struct {
+	Task
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	ResultData string    `json:"resultData,omitempty"`
+}
+
+// NewArchivedVM captures a DELETED VM and its tasks for the archive
+func NewArchivedVM(vm *VM, tasks []Task, archivedAt time.Time) (*ArchivedVM, error) {
+	vmRecord := archivedVMRecord{VM: *vm, Tags: vm.Tags, IdleSince: vm.IdleSince, IdleNotifiedAt: vm.IdleNotifiedAt}
+	vmRecord.VApp = nil
+	record, err := json.Marshal(vmRecord)
+	if err != nil {
+		return nil, err
+	}
+	taskRecords := make([]archivedTaskRecord, 0, len(tasks))
+	for _, task := range tasks {
+		taskRecords = append(taskRecords, archivedTaskRecord{Task: task, CreatedAt: task.CreatedAt, UpdatedAt: task.UpdatedAt, ResultData: task.ResultData})
+	}
+	taskJSON, err := json.Marshal(taskRecords)
+	if err != nil {
+		return nil, err
+	}
+	deletedAt := vm.UpdatedAt
+	if vm.DeletedAt.Valid {
+		deletedAt = vm.DeletedAt.Time
+	}
+	return &ArchivedVM{
+		ID:         vm.ID,
+		Name:       vm.Name,
+		VAppID:     vm.VAppID,
+		VMName:     vm.VMName,
+		Namespace:  vm.Namespace,
+		DeletedAt:  deletedAt,
+		ArchivedAt: archivedAt,
+		Record:     string(record),
+		Tasks:      string(taskJSON),
+	}, nil
+}
+
+// VM returns the archived VM record
+func (a *ArchivedVM) VM() (*VM, error) {
+	var record archivedVMRecord
+	if err := json.Unmarshal([]byte(a.Record), &record); err != nil {
+		return nil, err
+	}
+	vm := record.VM
+	vm.Tags = record.Tags
+	vm.IdleSince = record.IdleSince
+	vm.IdleNotifiedAt = record.IdleNotifiedAt
+	return &vm, nil
+}
+
+// TaskList returns the archived tasks of the VM, oldest first
+func (a *ArchivedVM) TaskList() ([]Task, error) {
+	var records []archivedTaskRecord
+	if a.Tasks != "" {
+		if err := json.Unmarshal([]byte(a.Tasks), &records); err != nil {
+			return nil, err
+		}
+	}
+	tasks := make([]Task, 0, len(records))
+	for _, record := range records {
+		task := record.Task
+		task.CreatedAt = record.CreatedAt
+		task.UpdatedAt = record.UpdatedAt
+		task.ResultData = record.ResultData
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
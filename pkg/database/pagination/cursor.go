@@ -0,0 +1,37 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor indicates a cursor that was not produced by EncodeCursor
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// Cursor is a position in a list ordered by name and then ID. Unlike an
+// offset it stays valid when rows before it are added or removed, so paging
+// through a list that changes meanwhile neither skips nor repeats rows.
+type Cursor struct {
+	Name string `json:"n"`
+	ID   string `json:"i"`
+}
+
+// EncodeCursor returns the opaque form of the position after the named row
+func EncodeCursor(name, id string) string {
+	data, _ := json.Marshal(Cursor{Name: name, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by EncodeCursor
+func DecodeCursor(cursor string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
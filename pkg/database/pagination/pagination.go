@@ -93,6 +93,15 @@ var VAppSortColumns = map[string]bool{
 	"description": true,
 }
 
+// VMSortColumns defines valid sort columns for VM entities
+var VMSortColumns = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"status":     true,
+	"created_at": true,
+	"updated_at": true,
+}
+
 // VDCSortColumns defines valid sort columns for VDC entities
 var VDCSortColumns = map[string]bool{
 	"id":                true,
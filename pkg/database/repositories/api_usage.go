@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// APIUsageFilter restricts API usage queries; empty fields match everything.
+// From and To are inclusive days in models.APIUsageDayFormat.
+type APIUsageFilter struct {
+	OrganizationID string
+	UserID         string
+	From           string
+	To             string
+}
+
+// Ensure APIUsageRepository can be used as the API usage recorder store
+var _ services.APIUsageStore = (*APIUsageRepository)(nil)
+
+// APIUsageRepository stores daily API usage per user
+type APIUsageRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIUsageRepository creates a new APIUsageRepository
+func NewAPIUsageRepository(db *gorm.DB) *APIUsageRepository {
+	return &APIUsageRepository{db: db}
+}
+
+// AddUsage adds the given counts to the stored daily totals
+func (r *APIUsageRepository) AddUsage(ctx context.Context, usage []models.APIUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"organization_id": gorm.Expr("excluded.organization_id"),
+			"request_count":   gorm.Expr("api_usages.request_count + excluded.request_count"),
+			"error_count":     gorm.Expr("api_usages.error_count + excluded.error_count"),
+			"bytes_in":        gorm.Expr("api_usages.bytes_in + excluded.bytes_in"),
+			"bytes_out":       gorm.Expr("api_usages.bytes_out + excluded.bytes_out"),
+			"updated_at":      gorm.Expr("excluded.updated_at"),
+		}),
+	}).CreateInBatches(usage, 100).Error
+}
+
+// List returns daily usage matching the filter, most recent days and busiest
+// users first
+func (r *APIUsageRepository) List(ctx context.Context, filter APIUsageFilter, limit, offset int) ([]models.APIUsage, error) {
+	var usage []models.APIUsage
+	err := r.applyFilter(r.db.WithContext(ctx).Model(&models.APIUsage{}), filter).
+		Order("day DESC").Order("request_count DESC").Order("user_id ASC").
+		Limit(limit).Offset(offset).
+		Find(&usage).Error
+	return usage, err
+}
+
+// Count returns the number of daily usage rows matching the filter
+func (r *APIUsageRepository) Count(ctx context.Context, filter APIUsageFilter) (int64, error) {
+	var count int64
+	err := r.applyFilter(r.db.WithContext(ctx).Model(&models.APIUsage{}), filter).Count(&count).Error
+	return count, err
+}
+
+// DeleteBefore removes usage recorded before the given day
+func (r *APIUsageRepository) DeleteBefore(ctx context.Context, day string) (int64, error) {
+	result := r.db.WithContext(ctx).Where("day < ?", day).Delete(&models.APIUsage{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *APIUsageRepository) applyFilter(query *gorm.DB, filter APIUsageFilter) *gorm.DB {
+	if filter.OrganizationID != "" {
+		query = query.Where("organization_id = ?", filter.OrganizationID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.From != "" {
+		query = query.Where("day >= ?", filter.From)
+	}
+	if filter.To != "" {
+		query = query.Where("day <= ?", filter.To)
+	}
+	return query
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"gorm.io/gorm"
 
@@ -14,13 +15,20 @@ import (
 var ErrCatalogHasDependencies = errors.New("catalog has dependent vApp templates")
 
 type CatalogRepository struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	hierarchicalAccess bool
 }
 
 func NewCatalogRepository(db *gorm.DB) *CatalogRepository {
 	return &CatalogRepository{db: db}
 }
 
+// SetHierarchicalAccess controls whether catalogs are inherited from ancestor
+// organizations and visible to members of ancestor organizations
+func (r *CatalogRepository) SetHierarchicalAccess(enabled bool) {
+	r.hierarchicalAccess = enabled
+}
+
 func (r *CatalogRepository) Create(catalog *models.Catalog) error {
 	if catalog == nil {
 		return errors.New("catalog cannot be nil")
@@ -43,6 +51,16 @@ func (r *CatalogRepository) GetByOrganizationID(orgID string) ([]models.Catalog,
 	return catalogs, err
 }
 
+// GetByOrgAndName retrieves a catalog owned by an organization by name
+func (r *CatalogRepository) GetByOrgAndName(orgID, name string) (*models.Catalog, error) {
+	var catalog models.Catalog
+	err := r.db.Where("organization_id = ? AND name = ?", orgID, name).First(&catalog).Error
+	if err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
 func (r *CatalogRepository) GetByOrganizationIDs(orgIDs []string) ([]models.Catalog, error) {
 	var catalogs []models.Catalog
 	if len(orgIDs) == 0 {
@@ -104,6 +122,24 @@ func (r *CatalogRepository) CountAll() (int64, error) {
 	return count, err
 }
 
+// ListPublished returns the catalogs published to every organization, by name
+func (r *CatalogRepository) ListPublished(ctx context.Context, limit, offset int) ([]models.Catalog, error) {
+	var catalogs []models.Catalog
+	limit, offset = pagination.ClampPaginationParams(limit, offset)
+	err := r.db.WithContext(ctx).Where("is_published = ?", true).
+		Order("name ASC").Order("id ASC").
+		Limit(limit).Offset(offset).
+		Find(&catalogs).Error
+	return catalogs, err
+}
+
+// CountPublished returns the number of catalogs published to every organization
+func (r *CatalogRepository) CountPublished(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Catalog{}).Where("is_published = ?", true).Count(&count).Error
+	return count, err
+}
+
 // GetByURN retrieves a catalog by its URN
 func (r *CatalogRepository) GetByURN(urn string) (*models.Catalog, error) {
 	var catalog models.Catalog
@@ -149,6 +185,11 @@ func (r *CatalogRepository) DeleteWithValidation(urn string) error {
 			return ErrCatalogHasDependencies
 		}
 
+		// Drop the catalog's sharing grants along with it
+		if err := tx.Where("catalog_id = ?", urn).Delete(&models.CatalogAccessControl{}).Error; err != nil {
+			return err
+		}
+
 		// Delete the catalog within the same transaction
 		return tx.Where("id = ?", urn).Delete(&models.Catalog{}).Error
 	})
@@ -156,31 +197,13 @@ func (r *CatalogRepository) DeleteWithValidation(urn string) error {
 
 // ValidateUserCatalogAccess checks if a user has access to any catalogs for template instantiation
 func (r *CatalogRepository) ValidateUserCatalogAccess(ctx context.Context, userID string) error {
-	// First, check if the user is a System Administrator - they have access to all catalogs
-	var systemAdminCount int64
-	err := r.db.WithContext(ctx).Table("user_roles").
-		Joins("JOIN roles ON user_roles.role_id = roles.id").
-		Where("user_roles.user_id = ? AND roles.name = ?", userID, models.RoleSystemAdmin).
-		Count(&systemAdminCount).Error
+	query, err := r.accessibleCatalogs(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	// System Administrators have access to all catalogs
-	if systemAdminCount > 0 {
-		return nil
-	}
-
-	// For non-System Administrators, check organization and published catalog access
-	// Get the user's organization ID using a subquery approach similar to VDC access
-	subquery := r.db.WithContext(ctx).Model(&models.User{}).Select("organization_id").Where("id = ? AND organization_id IS NOT NULL", userID)
-
-	// Check if user has access to any catalogs in their organization or published catalogs
 	var catalogCount int64
-	err = r.db.WithContext(ctx).
-		Model(&models.Catalog{}).
-		Where("organization_id IN (?) OR is_published = true", subquery).
-		Count(&catalogCount).Error
+	err = query.Count(&catalogCount).Error
 	if err != nil {
 		return err
 	}
@@ -191,3 +214,60 @@ func (r *CatalogRepository) ValidateUserCatalogAccess(ctx context.Context, userI
 
 	return nil
 }
+
+// GetAccessibleCatalog returns the catalog if the user can see it, or
+// gorm.ErrRecordNotFound if it does not exist or is not visible to the user
+func (r *CatalogRepository) GetAccessibleCatalog(ctx context.Context, userID, catalogID string) (*models.Catalog, error) {
+	query, err := r.accessibleCatalogs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog models.Catalog
+	err = query.Where("id = ?", catalogID).First(&catalog).Error
+	if err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
+// accessibleCatalogs returns a query over the catalogs visible to the user:
+// every catalog for System Administrators, otherwise catalogs of the user's
+// organization that are shared to everyone, owned by the user or administered
+// by the user as Organization Administrator, catalogs inherited from ancestor
+// organizations with hierarchical access, published catalogs, and catalogs
+// shared with the user or one of their roles
+func (r *CatalogRepository) accessibleCatalogs(ctx context.Context, userID string) (*gorm.DB, error) {
+	roles, err := r.userRoleNames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.Catalog{})
+
+	// System Administrators have access to all catalogs
+	if roles[models.RoleSystemAdmin] {
+		return query, nil
+	}
+
+	subquery := userOrgScope(r.db.WithContext(ctx), userID, r.hierarchicalAccess)
+
+	conditions := []string{}
+	args := []interface{}{}
+	if roles[models.RoleOrgAdmin] {
+		conditions = append(conditions, "organization_id IN (?)")
+		args = append(args, subquery)
+	} else {
+		conditions = append(conditions, "(organization_id IN (?) AND (shared_to_everyone = ? OR owner_id = ?))")
+		args = append(args, subquery, true, userID)
+	}
+	if r.hierarchicalAccess {
+		// Catalogs owned by ancestor organizations are inherited
+		conditions = append(conditions, "(organization_id IN (?) AND shared_to_everyone = ?)")
+		args = append(args, userAncestorOrgScope(r.db.WithContext(ctx), userID), true)
+	}
+	conditions = append(conditions, "is_published = ?", "id IN (?)")
+	args = append(args, true, r.grantedCatalogs(ctx, userID))
+
+	return query.Where(strings.Join(conditions, " OR "), args...), nil
+}
@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/pagination"
+)
+
+// GetCatalogAccess returns the catalog and the user's effective access level
+// on it, or gorm.ErrRecordNotFound if the catalog does not exist or the user has
+// no access. The effective level is the highest of:
+//   - Change for System Administrators, the catalog owner and Organization
+//     Administrators of the owning organization
+//   - the everyone access level for members of the owning organization when the
+//     catalog is shared to everyone
+//   - Use, capped at the everyone access level, for catalogs inherited from
+//     ancestor organizations with hierarchical access
+//   - Use for published catalogs
+//   - any level granted to the user or one of their roles
+func (r *CatalogRepository) GetCatalogAccess(ctx context.Context, userID, catalogID string) (*models.Catalog, string, error) {
+	var catalog models.Catalog
+	if err := r.db.WithContext(ctx).Where("id = ?", catalogID).First(&catalog).Error; err != nil {
+		return nil, "", err
+	}
+
+	roles, err := r.userRoleNames(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if roles[models.RoleSystemAdmin] {
+		return &catalog, models.CatalogAccessChange, nil
+	}
+
+	level := ""
+	inOrg, err := r.orgInScope(ctx, catalog.OrganizationID, userOrgScope(r.db.WithContext(ctx), userID, r.hierarchicalAccess))
+	if err != nil {
+		return nil, "", err
+	}
+	if inOrg {
+		if roles[models.RoleOrgAdmin] || (catalog.OwnerID != "" && catalog.OwnerID == userID) {
+			return &catalog, models.CatalogAccessChange, nil
+		}
+		if catalog.SharedToEveryone {
+			level = catalog.EveryoneAccessLevel
+		}
+	} else if r.hierarchicalAccess && catalog.SharedToEveryone {
+		inherited, err := r.orgInScope(ctx, catalog.OrganizationID, userAncestorOrgScope(r.db.WithContext(ctx), userID))
+		if err != nil {
+			return nil, "", err
+		}
+		if inherited {
+			level = catalog.EveryoneAccessLevel
+			if models.CatalogAccessRank(level) > models.CatalogAccessRank(models.CatalogAccessUse) {
+				level = models.CatalogAccessUse
+			}
+		}
+	}
+
+	if catalog.IsPublished {
+		level = models.MaxCatalogAccess(level, models.CatalogAccessUse)
+	}
+
+	var grants []string
+	err = r.userGrants(ctx, userID).Where("catalog_id = ?", catalogID).Pluck("access_level", &grants).Error
+	if err != nil {
+		return nil, "", err
+	}
+	for _, grant := range grants {
+		level = models.MaxCatalogAccess(level, grant)
+	}
+
+	if models.CatalogAccessRank(level) == 0 {
+		return nil, "", gorm.ErrRecordNotFound
+	}
+	return &catalog, level, nil
+}
+
+// ListAccessibleWithPagination retrieves the catalogs visible to the user with pagination
+func (r *CatalogRepository) ListAccessibleWithPagination(ctx context.Context, userID string, limit, offset int) ([]models.Catalog, error) {
+	query, err := r.accessibleCatalogs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, offset = pagination.ClampPaginationParams(limit, offset)
+
+	var catalogs []models.Catalog
+	err = query.Preload("VAppTemplates").
+		Limit(limit).
+		Offset(offset).
+		Order("created_at DESC, id DESC").
+		Find(&catalogs).Error
+	return catalogs, err
+}
+
+// CountAccessible returns the number of catalogs visible to the user
+func (r *CatalogRepository) CountAccessible(ctx context.Context, userID string) (int64, error) {
+	query, err := r.accessibleCatalogs(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = query.Count(&count).Error
+	return count, err
+}
+
+// GetControlAccess returns the access grants on a catalog
+func (r *CatalogRepository) GetControlAccess(ctx context.Context, catalogID string) ([]models.CatalogAccessControl, error) {
+	var grants []models.CatalogAccessControl
+	err := r.db.WithContext(ctx).
+		Where("catalog_id = ?", catalogID).
+		Order("subject_type, subject_id").
+		Find(&grants).Error
+	return grants, err
+}
+
+// SetControlAccess replaces a catalog's organization-wide sharing and all of
+// its access grants atomically
+func (r *CatalogRepository) SetControlAccess(ctx context.Context, catalogID string, sharedToEveryone bool, everyoneAccessLevel string, grants []models.CatalogAccessControl) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Catalog{}).Where("id = ?", catalogID).Updates(map[string]interface{}{
+			"shared_to_everyone":    sharedToEveryone,
+			"everyone_access_level": everyoneAccessLevel,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("catalog_id = ?", catalogID).Delete(&models.CatalogAccessControl{}).Error; err != nil {
+			return err
+		}
+		if len(grants) == 0 {
+			return nil
+		}
+
+		for i := range grants {
+			grants[i].CatalogID = catalogID
+		}
+		return tx.Create(&grants).Error
+	})
+}
+
+// userRoleNames returns the names of the user's roles
+func (r *CatalogRepository) userRoleNames(ctx context.Context, userID string) (map[string]bool, error) {
+	var names []string
+	err := r.db.WithContext(ctx).Table("user_roles").
+		Joins("JOIN roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.deleted_at IS NULL", userID).
+		Pluck("roles.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]bool, len(names))
+	for _, name := range names {
+		roles[name] = true
+	}
+	return roles, nil
+}
+
+// userGrants returns a query over the access grants that apply to the user
+// directly or through one of their roles
+func (r *CatalogRepository) userGrants(ctx context.Context, userID string) *gorm.DB {
+	userRoles := r.db.WithContext(ctx).Table("user_roles").Select("role_id").Where("user_id = ?", userID)
+	return r.db.WithContext(ctx).Model(&models.CatalogAccessControl{}).
+		Where("(subject_type = ? AND subject_id = ?) OR (subject_type = ? AND subject_id IN (?))",
+			models.AccessSubjectUser, userID, models.AccessSubjectRole, userRoles)
+}
+
+// grantedCatalogs returns a subquery selecting the catalogs shared with the user
+func (r *CatalogRepository) grantedCatalogs(ctx context.Context, userID string) *gorm.DB {
+	return r.userGrants(ctx, userID).Select("catalog_id")
+}
+
+// orgInScope reports whether an organization is selected by an organization subquery
+func (r *CatalogRepository) orgInScope(ctx context.Context, orgID string, scope *gorm.DB) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Organization{}).
+		Where("id = ? AND id IN (?)", orgID, scope).
+		Count(&count).Error
+	return count > 0, err
+}
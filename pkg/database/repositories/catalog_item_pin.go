@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// CatalogItemPinRepository stores the catalog items pinned for VDCs
+type CatalogItemPinRepository struct {
+	db *gorm.DB
+}
+
+// NewCatalogItemPinRepository creates a new CatalogItemPinRepository
+func NewCatalogItemPinRepository(db *gorm.DB) *CatalogItemPinRepository {
+	return &CatalogItemPinRepository{db: db}
+}
+
+// Get returns the pin of a Template in a VDC with the VDC, or
+// gorm.ErrRecordNotFound if the Template is not pinned
+func (r *CatalogItemPinRepository) Get(ctx context.Context, vdcID, templateName string) (*models.CatalogItemPin, error) {
+	var pin models.CatalogItemPin
+	err := r.db.WithContext(ctx).Preload("VDC").
+		Where("vdc_id = ? AND template_name = ?", vdcID, templateName).
+		First(&pin).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// List returns every pin with its VDC. Pins of deleted VDCs have no VDC.
+func (r *CatalogItemPinRepository) List(ctx context.Context) ([]models.CatalogItemPin, error) {
+	var pins []models.CatalogItemPin
+	err := r.db.WithContext(ctx).Preload("VDC").
+		Order("vdc_id ASC").Order("template_name ASC").
+		Find(&pins).Error
+	return pins, err
+}
+
+// ListByVDC returns the pins of a VDC ordered by Template name
+func (r *CatalogItemPinRepository) ListByVDC(ctx context.Context, vdcID string) ([]models.CatalogItemPin, error) {
+	var pins []models.CatalogItemPin
+	err := r.db.WithContext(ctx).Where("vdc_id = ?", vdcID).
+		Order("template_name ASC").
+		Find(&pins).Error
+	return pins, err
+}
+
+// Save pins a Template in a VDC. Pinning it again only records the catalog
+// item it was pinned through; its import status is kept.
+func (r *CatalogItemPinRepository) Save(ctx context.Context, pin *models.CatalogItemPin) error {
+	pin.Status = models.CatalogItemPinPending
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "vdc_id"}, {Name: "template_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"catalog_item_id", "updated_at"}),
+	}).Create(pin).Error
+}
+
+// Delete unpins a Template from a VDC
+func (r *CatalogItemPinRepository) Delete(ctx context.Context, vdcID, templateName string) error {
+	result := r.db.WithContext(ctx).
+		Where("vdc_id = ? AND template_name = ?", vdcID, templateName).
+		Delete(&models.CatalogItemPin{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RecordStatus stores the import status of a pin and the golden images that
+// are ready. The ready time is kept while the pin stays ready.
+func (r *CatalogItemPinRepository) RecordStatus(ctx context.Context, vdcID, templateName, status, message string, readyImages []string) error {
+	updates := map[string]interface{}{
+		"status":       status,
+		"message":      message,
+		"ready_images": strings.Join(readyImages, "\n"),
+	}
+	if status == models.CatalogItemPinReady {
+		updates["ready_at"] = gorm.Expr("COALESCE(ready_at, ?)", time.Now())
+	} else {
+		updates["ready_at"] = nil
+	}
+	return r.db.WithContext(ctx).
+		Model(&models.CatalogItemPin{}).
+		Where("vdc_id = ? AND template_name = ?", vdcID, templateName).
+		Updates(updates).Error
+}
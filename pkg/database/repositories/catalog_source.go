@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// CatalogSourceRepository stores the remote sources catalogs import Templates from
+type CatalogSourceRepository struct {
+	db *gorm.DB
+}
+
+// NewCatalogSourceRepository creates a new CatalogSourceRepository
+func NewCatalogSourceRepository(db *gorm.DB) *CatalogSourceRepository {
+	return &CatalogSourceRepository{db: db}
+}
+
+// Get returns the source of a catalog, or gorm.ErrRecordNotFound if it has none
+func (r *CatalogSourceRepository) Get(ctx context.Context, catalogID string) (*models.CatalogSource, error) {
+	var source models.CatalogSource
+	if err := r.db.WithContext(ctx).Where("catalog_id = ?", catalogID).First(&source).Error; err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// List returns every catalog source
+func (r *CatalogSourceRepository) List(ctx context.Context) ([]models.CatalogSource, error) {
+	var sources []models.CatalogSource
+	err := r.db.WithContext(ctx).Order("catalog_id ASC").Find(&sources).Error
+	return sources, err
+}
+
+// Save creates or replaces the source of a catalog. The sync status is reset
+// and a sync is requested, so the new location is imported right away.
+func (r *CatalogSourceRepository) Save(ctx context.Context, source *models.CatalogSource) error {
+	now := time.Now()
+	source.SyncStatus = models.CatalogSyncPending
+	source.SyncMessage = ""
+	source.SyncRequestedAt = &now
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "catalog_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"type", "url", "ref", "path", "sync_interval_minutes",
+			"sync_status", "sync_message", "sync_requested_at", "updated_at",
+		}),
+	}).Create(source).Error
+}
+
+// Delete removes the source of a catalog
+func (r *CatalogSourceRepository) Delete(ctx context.Context, catalogID string) error {
+	result := r.db.WithContext(ctx).Where("catalog_id = ?", catalogID).Delete(&models.CatalogSource{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RequestSync asks the catalog sync controller to sync a source at its next poll
+func (r *CatalogSourceRepository) RequestSync(ctx context.Context, catalogID string) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.CatalogSource{}).
+		Where("catalog_id = ?", catalogID).
+		Updates(map[string]interface{}{
+			"sync_requested_at": time.Now(),
+			"sync_status":       models.CatalogSyncPending,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkSyncing records that a sync of a source started
+func (r *CatalogSourceRepository) MarkSyncing(ctx context.Context, catalogID string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.CatalogSource{}).
+		Where("catalog_id = ?", catalogID).
+		Updates(map[string]interface{}{
+			"sync_status":       models.CatalogSyncRunning,
+			"sync_requested_at": nil,
+		}).Error
+}
+
+// RecordSyncResult stores the outcome of a sync. A failed sync keeps the
+// revision and template count of the last successful one.
+func (r *CatalogSourceRepository) RecordSyncResult(ctx context.Context, catalogID string, syncErr error, revision string, templateCount int) error {
+	updates := map[string]interface{}{
+		"last_sync_at": time.Now(),
+	}
+	if syncErr != nil {
+		updates["sync_status"] = models.CatalogSyncFailed
+		updates["sync_message"] = syncErr.Error()
+	} else {
+		updates["sync_status"] = models.CatalogSyncSucceeded
+		updates["sync_message"] = ""
+		updates["revision"] = revision
+		updates["template_count"] = templateCount
+	}
+	return r.db.WithContext(ctx).
+		Model(&models.CatalogSource{}).
+		Where("catalog_id = ?", catalogID).
+		Updates(updates).Error
+}
@@ -3,62 +3,103 @@ package repositories
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	domainerrors "github.com/mhrivnak/ssvirt/pkg/domain/errors"
 	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
-// CatalogItemRepository provides access to catalog items backed by OpenShift Templates
+// Ensure CatalogItemRepository can be used as the catalog item sync target
+var _ services.CatalogItemStore = (*CatalogItemRepository)(nil)
+
+// CatalogItemRepository provides access to catalog items backed by OpenShift Templates.
+// Templates are persisted in the catalog_items table by the catalog item syncer, so
+// listing, counting and lookups are answered from the database.
 type CatalogItemRepository struct {
-	templateService services.TemplateServiceInterface
-	catalogRepo     *CatalogRepository
+	db          *gorm.DB
+	catalogRepo *CatalogRepository
 }
 
 // NewCatalogItemRepository creates a new CatalogItemRepository
-func NewCatalogItemRepository(templateService services.TemplateServiceInterface, catalogRepo *CatalogRepository) *CatalogItemRepository {
+func NewCatalogItemRepository(db *gorm.DB, catalogRepo *CatalogRepository) *CatalogItemRepository {
 	return &CatalogItemRepository{
-		templateService: templateService,
-		catalogRepo:     catalogRepo,
+		db:          db,
+		catalogRepo: catalogRepo,
 	}
 }
 
-// ListByCatalogID returns paginated catalog items for the specified catalog
-func (r *CatalogItemRepository) ListByCatalogID(ctx context.Context, catalogID string, limit, offset int) ([]models.CatalogItem, error) {
-	// Verify the catalog exists first
-	_, err := r.catalogRepo.GetByID(catalogID)
+// ListByCatalogID returns paginated catalog items for the specified catalog, ordered by name
+func (r *CatalogItemRepository) ListByCatalogID(ctx context.Context, catalogID, filter string, limit, offset int) ([]models.CatalogItem, error) {
+	catalog, err := r.getCatalog(catalogID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, domainerrors.ErrNotFound
-		}
 		return nil, err
 	}
 
-	// Get catalog items from template service
-	return r.templateService.ListCatalogItems(ctx, catalogID, limit, offset)
+	var records []models.CatalogItemRecord
+	err = r.applyFilter(scopeToCatalog(r.db.WithContext(ctx).Model(&models.CatalogItemRecord{}), catalog), filter).
+		Order("name ASC").Order("template_uid ASC").
+		Limit(limit).Offset(offset).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	catalogItems := make([]models.CatalogItem, len(records))
+	for i := range records {
+		catalogItems[i] = records[i].ToCatalogItem(catalog.ID, catalog.Name)
+	}
+	return catalogItems, nil
 }
 
 // CountByCatalogID returns the total count of catalog items for the specified catalog
-func (r *CatalogItemRepository) CountByCatalogID(ctx context.Context, catalogID string) (int64, error) {
-	// Verify the catalog exists first
-	_, err := r.catalogRepo.GetByID(catalogID)
+func (r *CatalogItemRepository) CountByCatalogID(ctx context.Context, catalogID, filter string) (int64, error) {
+	catalog, err := r.getCatalog(catalogID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return 0, domainerrors.ErrNotFound
-		}
 		return 0, err
 	}
 
-	// Get count from template service
-	return r.templateService.CountCatalogItems(ctx, catalogID)
+	var count int64
+	err = r.applyFilter(scopeToCatalog(r.db.WithContext(ctx).Model(&models.CatalogItemRecord{}), catalog), filter).
+		Count(&count).Error
+	return count, err
 }
 
-// GetByID returns a specific catalog item by ID within the specified catalog
+// GetByID returns a specific catalog item within the specified catalog. The item
+// may be identified by its URN (urn:vcloud:catalogitem:<catalog-uuid>:<name>),
+// by a legacy URN carrying only the template name or UID, or by template name.
 func (r *CatalogItemRepository) GetByID(ctx context.Context, catalogID, itemID string) (*models.CatalogItem, error) {
-	// Verify the catalog exists first
-	_, err := r.catalogRepo.GetByID(catalogID)
+	catalog, err := r.getCatalog(catalogID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := scopeToCatalog(r.db.WithContext(ctx), catalog)
+	if suffix, isURN := strings.CutPrefix(itemID, models.URNPrefixCatalogItem); isURN {
+		if colonIndex := strings.LastIndex(suffix, ":"); colonIndex != -1 {
+			// The item must belong to the requested catalog
+			if suffix[:colonIndex] != strings.TrimPrefix(catalog.ID, models.URNPrefixCatalog) {
+				return nil, domainerrors.ErrNotFound
+			}
+			name, err := url.QueryUnescape(suffix[colonIndex+1:])
+			if err != nil {
+				return nil, domainerrors.ErrNotFound
+			}
+			query = query.Where("name = ?", name)
+		} else {
+			query = query.Where("template_uid = ? OR name = ?", suffix, suffix)
+		}
+	} else {
+		query = query.Where("name = ?", itemID)
+	}
+
+	var record models.CatalogItemRecord
+	err = query.Order("name ASC").Order("template_uid ASC").First(&record).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, domainerrors.ErrNotFound
@@ -66,13 +107,123 @@ func (r *CatalogItemRepository) GetByID(ctx context.Context, catalogID, itemID s
 		return nil, err
 	}
 
-	// Get catalog item from template service
-	catalogItem, err := r.templateService.GetCatalogItem(ctx, catalogID, itemID)
+	catalogItem := record.ToCatalogItem(catalog.ID, catalog.Name)
+	return &catalogItem, nil
+}
+
+// SyncTemplates makes the catalog_items table match the given templates,
+// inserting new ones, updating changed ones and removing those that no longer
+// exist. It returns the number of rows written or deleted.
+func (r *CatalogItemRepository) SyncTemplates(ctx context.Context, records []models.CatalogItemRecord) (int, error) {
+	changed := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []models.CatalogItemRecord
+		err := tx.Select("template_uid", "resource_version", "validation_status", "icon_class",
+			"architecture", "os_type", "os_family").Find(&existing).Error
+		if err != nil {
+			return err
+		}
+		stored := make(map[string]models.CatalogItemRecord, len(existing))
+		for _, record := range existing {
+			stored[record.TemplateUID] = record
+		}
+
+		var upserts []models.CatalogItemRecord
+		for _, record := range records {
+			if record.ValidationStatus == "" {
+				record.ValidationStatus = models.CatalogItemValidationValid
+			}
+			current, found := stored[record.TemplateUID]
+			delete(stored, record.TemplateUID)
+			// A changed validation status, icon, architecture or OS is rewritten even
+			// without a template change, which also fills them in on rows stored
+			// before they were recorded
+			if found && current.ResourceVersion == record.ResourceVersion && record.ResourceVersion != "" &&
+				current.ValidationStatus == record.ValidationStatus && current.IconClass == record.IconClass &&
+				current.Architecture == record.Architecture && current.OSType == record.OSType &&
+				current.OSFamily == record.OSFamily {
+				continue
+			}
+			upserts = append(upserts, record)
+		}
+
+		if len(upserts) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "template_uid"}},
+				UpdateAll: true,
+			}).CreateInBatches(upserts, 100).Error
+			if err != nil {
+				return fmt.Errorf("failed to upsert catalog items: %w", err)
+			}
+			changed += len(upserts)
+		}
+
+		// Anything left in stored was not in the current template set
+		if len(stored) > 0 {
+			stale := make([]string, 0, len(stored))
+			for uid := range stored {
+				stale = append(stale, uid)
+			}
+			if err := tx.Where("template_uid IN ?", stale).Delete(&models.CatalogItemRecord{}).Error; err != nil {
+				return fmt.Errorf("failed to delete stale catalog items: %w", err)
+			}
+			changed += len(stale)
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// getCatalog verifies the catalog exists, mapping a missing catalog to ErrNotFound
+func (r *CatalogItemRepository) getCatalog(catalogID string) (*models.Catalog, error) {
+	catalog, err := r.catalogRepo.GetByID(catalogID)
 	if err != nil {
-		if errors.Is(err, domainerrors.ErrNotFound) {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, domainerrors.ErrNotFound
 		}
 		return nil, err
 	}
-	return catalogItem, nil
+	return catalog, nil
+}
+
+// scopeToCatalog limits a catalog item query to the Templates the catalog is
+// restricted to, if any
+func scopeToCatalog(query *gorm.DB, catalog *models.Catalog) *gorm.DB {
+	if names := catalog.ItemNameList(); len(names) > 0 {
+		return query.Where("name IN ?", names)
+	}
+	return query
+}
+
+// applyFilter applies VMware Cloud Director API filter syntax to a catalog item query.
+// Supports 'name==value', 'isPublished==true|false', 'validationStatus==VALID|INVALID',
+// 'architecture==value' and 'osFamily==value'; any other value is a case-insensitive
+// name search.
+func (r *CatalogItemRepository) applyFilter(query *gorm.DB, filter string) *gorm.DB {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return query
+	}
+
+	if attribute, value, found := strings.Cut(filter, "=="); found {
+		attribute = strings.TrimSpace(attribute)
+		value = strings.TrimSpace(value)
+
+		switch attribute {
+		case "name":
+			return query.Where("name = ?", value)
+		case "isPublished":
+			return query.Where("is_published = ?", strings.EqualFold(value, "true"))
+		case "validationStatus":
+			return query.Where("validation_status = ?", strings.ToUpper(value))
+		case "architecture":
+			return query.Where("architecture = ?", services.NormalizeArchitecture(value))
+		case "osFamily":
+			return query.Where("os_family = ?", strings.ToLower(value))
+		default:
+			filter = value
+		}
+	}
+
+	return query.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(filter)+"%")
 }
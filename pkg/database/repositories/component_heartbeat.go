@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// ComponentHeartbeatRepository stores the heartbeats of controller processes
+type ComponentHeartbeatRepository struct {
+	db *gorm.DB
+}
+
+// NewComponentHeartbeatRepository creates a new ComponentHeartbeatRepository
+func NewComponentHeartbeatRepository(db *gorm.DB) *ComponentHeartbeatRepository {
+	return &ComponentHeartbeatRepository{db: db}
+}
+
+// Upsert records a heartbeat, replacing the previous one of the same instance
+func (r *ComponentHeartbeatRepository) Upsert(ctx context.Context, heartbeat *models.ComponentHeartbeat) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instance_id"}},
+		UpdateAll: true,
+	}).Create(heartbeat).Error
+}
+
+// List returns every recorded heartbeat, by component and instance
+func (r *ComponentHeartbeatRepository) List(ctx context.Context) ([]models.ComponentHeartbeat, error) {
+	var heartbeats []models.ComponentHeartbeat
+	err := r.db.WithContext(ctx).Order("component ASC").Order("instance_id ASC").Find(&heartbeats).Error
+	return heartbeats, err
+}
+
+// DeleteBefore removes the heartbeats of instances that have not reported since
+// before, such as pods replaced by a rollout, returning how many were removed
+func (r *ComponentHeartbeatRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("last_heartbeat < ?", before).Delete(&models.ComponentHeartbeat{})
+	return result.RowsAffected, result.Error
+}
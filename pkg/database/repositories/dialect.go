@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// sqliteDialect is the name GORM reports for SQLite connections
+const sqliteDialect = "sqlite"
+
+var (
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	globEscaper = strings.NewReplacer(`[`, `[[]`, `*`, `[*]`, `?`, `[?]`)
+)
+
+// whereContains matches rows whose column contains value, case-sensitively on
+// every dialect. SQLite's LIKE ignores ASCII case where PostgreSQL's does not, so
+// SQLite uses GLOB instead.
+func whereContains(query *gorm.DB, column, value string) *gorm.DB {
+	if query.Dialector.Name() == sqliteDialect {
+		return query.Where(column+" GLOB ?", "*"+globEscaper.Replace(value)+"*")
+	}
+	return query.Where(column+` LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(value)+"%")
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// changeSources are the entities whose changes are reported. Deleted entities
+// stay in their tables, so deletions are reported until the rows are purged.
+var changeSources = []struct {
+	entityType string
+	model      any
+}{
+	{models.EntityTypeCatalog, &models.Catalog{}},
+	{models.EntityTypeOrg, &models.Organization{}},
+	{models.EntityTypeUser, &models.User{}},
+	{models.EntityTypeVApp, &models.VApp{}},
+	{models.EntityTypeVDC, &models.VDC{}},
+	{models.EntityTypeVM, &models.VM{}},
+}
+
+// ChangeCursor is the position of a change in the order changes are listed:
+// by time, then entity type, then URN
+type ChangeCursor struct {
+	ChangedAt time.Time
+	Type      string
+	URN       string
+}
+
+// Precedes reports whether change is listed after the cursor
+func (c ChangeCursor) Precedes(change models.EntityChange) bool {
+	if !change.ChangedAt.Equal(c.ChangedAt) {
+		return change.ChangedAt.After(c.ChangedAt)
+	}
+	if change.Type != c.Type {
+		return change.Type > c.Type
+	}
+	return change.URN > c.URN
+}
+
+// EntityChangeRepository derives entity changes from the timestamps of the
+// entity tables
+type EntityChangeRepository struct {
+	db *gorm.DB
+}
+
+// NewEntityChangeRepository creates a new EntityChangeRepository
+func NewEntityChangeRepository(db *gorm.DB) *EntityChangeRepository {
+	return &EntityChangeRepository{db: db}
+}
+
+// changeRow holds the timestamps of an entity
+type changeRow struct {
+	ID        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+// ListChanges returns up to limit entities changed after the cursor, each with
+// its latest change, in cursor order. Entities created after the cursor's time
+// are reported as created; earlier changes of an entity are not kept.
+func (r *EntityChangeRepository) ListChanges(ctx context.Context, after ChangeCursor, limit int) ([]models.EntityChange, error) {
+	// Timestamps are stored in the server's zone, and SQLite compares them as text
+	since := after.ChangedAt.Local()
+	var changes []models.EntityChange
+	for _, source := range changeSources {
+		for _, deleted := range []bool{false, true} {
+			column := "updated_at"
+			query := r.db.WithContext(ctx).Unscoped().Model(source.model).
+				Select("id, created_at, updated_at, deleted_at")
+			if deleted {
+				column = "deleted_at"
+				query = query.Where("deleted_at IS NOT NULL")
+			} else {
+				query = query.Where("deleted_at IS NULL")
+			}
+
+			// Entities changed at the cursor's time follow it when their
+			// type, then URN, sort after the cursor's
+			switch {
+			case source.entityType > after.Type:
+				query = query.Where(column+" >= ?", since)
+			case source.entityType == after.Type:
+				query = query.Where(fmt.Sprintf("(%[1]s > ? OR (%[1]s = ? AND id > ?))", column),
+					since, since, after.URN)
+			default:
+				query = query.Where(column+" > ?", since)
+			}
+
+			var rows []changeRow
+			if err := query.Order(column + ", id").Limit(limit).Find(&rows).Error; err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				change := models.EntityChange{
+					Type:      source.entityType,
+					URN:       row.ID,
+					Operation: models.EntityChangeUpdated,
+					ChangedAt: row.UpdatedAt,
+				}
+				switch {
+				case deleted:
+					change.Operation = models.EntityChangeDeleted
+					change.ChangedAt = row.DeletedAt.Time
+				case row.CreatedAt.After(after.ChangedAt):
+					change.Operation = models.EntityChangeCreated
+				}
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return ChangeCursor{ChangedAt: changes[i].ChangedAt, Type: changes[i].Type, URN: changes[i].URN}.Precedes(changes[j])
+	})
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
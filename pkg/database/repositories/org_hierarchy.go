@@ -0,0 +1,345 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// MaxOrgHierarchyDepth limits how deeply organizations can be nested
+const MaxOrgHierarchyDepth = 8
+
+var (
+	// ErrOrgHierarchyCycle is returned when a parent assignment would make an organization its own ancestor
+	ErrOrgHierarchyCycle = errors.New("organization hierarchy cycle detected")
+	// ErrOrgHierarchyTooDeep is returned when a parent assignment would exceed MaxOrgHierarchyDepth
+	ErrOrgHierarchyTooDeep = errors.New("organization hierarchy too deep")
+	// ErrOrgHasChildren is returned when deleting an organization that still has child organizations
+	ErrOrgHasChildren = errors.New("organization has child organizations")
+)
+
+// userOrgScope returns a subquery selecting the organization IDs a user can access
+// through membership. With hierarchical access enabled, organizations nested below
+// the user's organization are included as well.
+func userOrgScope(db *gorm.DB, userID string, hierarchical bool) *gorm.DB {
+	if !hierarchical {
+		return db.Model(&models.User{}).Select("organization_id").Where("id = ? AND organization_id IS NOT NULL", userID)
+	}
+	return db.Raw(`
+		WITH RECURSIVE org_tree(id) AS (
+			SELECT organization_id FROM users
+			WHERE id = ? AND organization_id IS NOT NULL AND deleted_at IS NULL
+			UNION
+			SELECT o.id FROM organizations o
+			JOIN org_tree t ON o.parent_org_id = t.id
+			WHERE o.deleted_at IS NULL
+		)
+		SELECT id FROM org_tree`, userID)
+}
+
+// userAncestorOrgScope returns a subquery selecting the user's organization and all of
+// its ancestors, used to expose catalogs inherited from parent organizations
+func userAncestorOrgScope(db *gorm.DB, userID string) *gorm.DB {
+	return db.Raw(`
+		WITH RECURSIVE org_chain(id, parent_org_id) AS (
+			SELECT o.id, o.parent_org_id FROM organizations o
+			JOIN users u ON u.organization_id = o.id
+			WHERE u.id = ? AND u.deleted_at IS NULL AND o.deleted_at IS NULL
+			UNION
+			SELECT p.id, p.parent_org_id FROM organizations p
+			JOIN org_chain c ON p.id = c.parent_org_id
+			WHERE p.deleted_at IS NULL
+		)
+		SELECT id FROM org_chain`, userID)
+}
+
+// SetHierarchicalAccess controls whether membership in an organization grants
+// access to its descendant organizations
+func (r *OrganizationRepository) SetHierarchicalAccess(enabled bool) {
+	r.hierarchicalAccess = enabled
+}
+
+// GetAncestorIDs returns the IDs of all ancestors of an organization, nearest first
+func (r *OrganizationRepository) GetAncestorIDs(ctx context.Context, orgID string) ([]string, error) {
+	var ancestors []string
+	seen := map[string]bool{orgID: true}
+
+	currentID := orgID
+	for {
+		var org models.Organization
+		err := r.db.WithContext(ctx).Select("id", "parent_org_id").Where("id = ?", currentID).First(&org).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) && currentID != orgID {
+				// Dangling parent reference (e.g. parent soft-deleted), stop here
+				return ancestors, nil
+			}
+			return nil, err
+		}
+		if org.ParentOrgID == nil || *org.ParentOrgID == "" {
+			return ancestors, nil
+		}
+		if seen[*org.ParentOrgID] {
+			return nil, ErrOrgHierarchyCycle
+		}
+		seen[*org.ParentOrgID] = true
+		ancestors = append(ancestors, *org.ParentOrgID)
+		currentID = *org.ParentOrgID
+	}
+}
+
+// GetDescendantIDs returns the IDs of all organizations nested below an organization
+func (r *OrganizationRepository) GetDescendantIDs(ctx context.Context, orgID string) ([]string, error) {
+	var descendants []string
+	seen := map[string]bool{orgID: true}
+
+	frontier := []string{orgID}
+	for len(frontier) > 0 {
+		var childIDs []string
+		err := r.db.WithContext(ctx).Model(&models.Organization{}).
+			Where("parent_org_id IN ?", frontier).
+			Pluck("id", &childIDs).Error
+		if err != nil {
+			return nil, err
+		}
+
+		frontier = frontier[:0]
+		for _, id := range childIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			descendants = append(descendants, id)
+			frontier = append(frontier, id)
+		}
+	}
+
+	return descendants, nil
+}
+
+// CountChildren returns the number of organizations whose direct parent is orgID
+func (r *OrganizationRepository) CountChildren(ctx context.Context, orgID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Organization{}).Where("parent_org_id = ?", orgID).Count(&count).Error
+	return count, err
+}
+
+// EffectiveSecurityPolicy returns the VM security policy that applies to org:
+// its own policy, or with hierarchical access enabled the policy of its nearest
+// ancestor that has one. It returns nil when no policy applies.
+func (r *OrganizationRepository) EffectiveSecurityPolicy(ctx context.Context, org *models.Organization) (*models.OrgSecurityPolicy, error) {
+	if org.SecurityPolicy != nil || !r.hierarchicalAccess {
+		return org.SecurityPolicy, nil
+	}
+	ancestorIDs, err := r.GetAncestorIDs(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ancestorIDs {
+		var ancestor models.Organization
+		if err := r.db.WithContext(ctx).Where("id = ?", id).First(&ancestor).Error; err != nil {
+			return nil, err
+		}
+		if ancestor.SecurityPolicy != nil {
+			return ancestor.SecurityPolicy, nil
+		}
+	}
+	return nil, nil
+}
+
+// ValidateParent checks that parentID can become the parent of orgID without creating
+// a cycle or exceeding MaxOrgHierarchyDepth. orgID may be empty for new organizations.
+func (r *OrganizationRepository) ValidateParent(ctx context.Context, orgID, parentID string) error {
+	if orgID != "" && orgID == parentID {
+		return ErrOrgHierarchyCycle
+	}
+
+	ancestors, err := r.GetAncestorIDs(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ancestors {
+		if id == orgID {
+			return ErrOrgHierarchyCycle
+		}
+	}
+
+	// Depth of the parent plus the depth of the subtree being attached
+	subtreeDepth := 1
+	if orgID != "" {
+		subtreeDepth, err = r.subtreeDepth(ctx, orgID)
+		if err != nil {
+			return err
+		}
+	}
+	if len(ancestors)+1+subtreeDepth > MaxOrgHierarchyDepth {
+		return ErrOrgHierarchyTooDeep
+	}
+
+	return nil
+}
+
+// subtreeDepth returns the number of levels in the tree rooted at orgID, including orgID itself
+func (r *OrganizationRepository) subtreeDepth(ctx context.Context, orgID string) (int, error) {
+	depth := 1
+	frontier := []string{orgID}
+	seen := map[string]bool{orgID: true}
+	for {
+		var childIDs []string
+		err := r.db.WithContext(ctx).Model(&models.Organization{}).
+			Where("parent_org_id IN ?", frontier).
+			Pluck("id", &childIDs).Error
+		if err != nil {
+			return 0, err
+		}
+
+		frontier = frontier[:0]
+		for _, id := range childIDs {
+			if !seen[id] {
+				seen[id] = true
+				frontier = append(frontier, id)
+			}
+		}
+		if len(frontier) == 0 {
+			return depth, nil
+		}
+		depth++
+	}
+}
+
+// GetRollup aggregates resource counts across an organization and its descendants
+func (r *OrganizationRepository) GetRollup(ctx context.Context, orgID string) (*models.OrganizationRollup, error) {
+	descendants, err := r.GetDescendantIDs(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	orgIDs := append([]string{orgID}, descendants...)
+
+	rollup := &models.OrganizationRollup{
+		OrgID:            orgID,
+		IncludedOrgCount: len(orgIDs),
+	}
+
+	db := r.db.WithContext(ctx)
+	if err := db.Model(&models.VDC{}).Where("organization_id IN ?", orgIDs).Count(&rollup.OrgVdcCount).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.Catalog{}).Where("organization_id IN ?", orgIDs).Count(&rollup.CatalogCount).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.User{}).Where("organization_id IN ?", orgIDs).Count(&rollup.UserCount).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.VApp{}).
+		Joins("JOIN vdcs ON v_apps.vdc_id = vdcs.id").
+		Where("vdcs.organization_id IN ? AND vdcs.deleted_at IS NULL", orgIDs).
+		Count(&rollup.VappCount).Error; err != nil {
+		return nil, err
+	}
+
+	vmQuery := func() *gorm.DB {
+		return db.Model(&models.VM{}).
+			Joins("JOIN v_apps ON vms.vapp_id = v_apps.id").
+			Joins("JOIN vdcs ON v_apps.vdc_id = vdcs.id").
+			Where("vdcs.organization_id IN ? AND vdcs.deleted_at IS NULL AND v_apps.deleted_at IS NULL", orgIDs)
+	}
+	if err := vmQuery().Count(&rollup.VMCount).Error; err != nil {
+		return nil, err
+	}
+	if err := vmQuery().Where("vms.status = ?", "POWERED_ON").Count(&rollup.RunningVMCount).Error; err != nil {
+		return nil, err
+	}
+
+	var org models.Organization
+	if err := db.Where("id = ?", orgID).First(&org).Error; err != nil {
+		return nil, err
+	}
+	rollup.Quota = org.Quota
+	if rollup.QuotaUsage, err = r.GetQuotaUsage(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	return rollup, nil
+}
+
+// GetQuotaUsage returns what the organization's own VDCs use of the resources
+// an organization quota caps
+func (r *OrganizationRepository) GetQuotaUsage(ctx context.Context, orgID string) (models.OrgQuotaUsage, error) {
+	var usage models.OrgQuotaUsage
+	db := r.db.WithContext(ctx)
+	err := db.Model(&models.VM{}).
+		Select("COUNT(vms.id), COALESCE(SUM(vms.cpu_count), 0), COALESCE(SUM(vms.memory_mb), 0)").
+		Joins("JOIN v_apps ON vms.vapp_id = v_apps.id").
+		Joins("JOIN vdcs ON v_apps.vdc_id = vdcs.id").
+		Where("vdcs.organization_id = ? AND vdcs.deleted_at IS NULL AND v_apps.deleted_at IS NULL", orgID).
+		Row().Scan(&usage.VMCount, &usage.CPUCores, &usage.MemoryMB)
+	if err != nil {
+		return usage, err
+	}
+	err = db.Model(&models.VDCStorageProfile{}).
+		Select("COALESCE(SUM(vdc_storage_profiles.used_mb), 0)").
+		Joins("JOIN vdcs ON vdc_storage_profiles.vdc_id = vdcs.id").
+		Where("vdcs.organization_id = ? AND vdcs.deleted_at IS NULL", orgID).
+		Row().Scan(&usage.StorageMB)
+	return usage, err
+}
+
+// populateHierarchyRefs fills in the managedBy reference and directlyManagedOrgCount
+// for a set of organizations using one query for parents and one for child counts
+func (r *OrganizationRepository) populateHierarchyRefs(orgs []models.Organization) error {
+	if len(orgs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(orgs))
+	parentIDs := make([]string, 0, len(orgs))
+	for i := range orgs {
+		ids = append(ids, orgs[i].ID)
+		if orgs[i].ParentOrgID != nil && *orgs[i].ParentOrgID != "" {
+			parentIDs = append(parentIDs, *orgs[i].ParentOrgID)
+		}
+	}
+
+	parentNames := map[string]string{}
+	if len(parentIDs) > 0 {
+		var parents []models.Organization
+		if err := r.db.Select("id", "name").Where("id IN ?", parentIDs).Find(&parents).Error; err != nil {
+			return err
+		}
+		for _, p := range parents {
+			parentNames[p.ID] = p.Name
+		}
+	}
+
+	var childCounts []struct {
+		ParentOrgID string
+		Count       int
+	}
+	err := r.db.Model(&models.Organization{}).
+		Select("parent_org_id, COUNT(*) AS count").
+		Where("parent_org_id IN ?", ids).
+		Group("parent_org_id").
+		Scan(&childCounts).Error
+	if err != nil {
+		return err
+	}
+	counts := map[string]int{}
+	for _, c := range childCounts {
+		counts[c.ParentOrgID] = c.Count
+	}
+
+	for i := range orgs {
+		org := &orgs[i]
+		org.DirectlyManagedOrgCount = counts[org.ID]
+		org.ManagedBy = nil
+		if org.ParentOrgID != nil {
+			if name, ok := parentNames[*org.ParentOrgID]; ok {
+				org.ManagedBy = &models.EntityRef{Name: name, ID: *org.ParentOrgID}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// nextOrgSequence increments an organization's counter within tx and returns
+// its new value, starting at 1. The upsert locks the counter's row until tx
+// ends, so concurrent transactions get distinct, increasing values and a
+// rolled back transaction does not consume one.
+func nextOrgSequence(tx *gorm.DB, orgID, name string) (int64, error) {
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "organization_id"}, {Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"value": gorm.Expr("org_sequences.value + 1"),
+		}),
+	}).Create(&models.OrgSequence{OrganizationID: orgID, Name: name, Value: 1}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var sequence models.OrgSequence
+	err = tx.Where("organization_id = ? AND name = ?", orgID, name).First(&sequence).Error
+	return sequence.Value, err
+}
@@ -5,13 +5,15 @@ import (
 	"errors"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/pagination"
 )
 
 type OrganizationRepository struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	hierarchicalAccess bool
 }
 
 func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
@@ -25,6 +27,21 @@ func (r *OrganizationRepository) Create(org *models.Organization) error {
 	return r.db.Create(org).Error
 }
 
+// CreateWithCatalog creates an organization together with a catalog owned by
+// it, so that neither exists without the other
+func (r *OrganizationRepository) CreateWithCatalog(org *models.Organization, catalog *models.Catalog) error {
+	if org == nil || catalog == nil {
+		return errors.New("organization and catalog cannot be nil")
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		catalog.OrganizationID = org.ID
+		return tx.Create(catalog).Error
+	})
+}
+
 func (r *OrganizationRepository) GetByID(id string) (*models.Organization, error) {
 	var org models.Organization
 	err := r.db.Where("id = ?", id).First(&org).Error
@@ -56,6 +73,16 @@ func (r *OrganizationRepository) GetByName(name string) (*models.Organization, e
 	return &org, nil
 }
 
+// GetByExternalID retrieves an organization by its client-provided external ID
+func (r *OrganizationRepository) GetByExternalID(externalID string) (*models.Organization, error) {
+	var org models.Organization
+	err := r.db.Where("external_id = ?", externalID).First(&org).Error
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
 func (r *OrganizationRepository) List() ([]models.Organization, error) {
 	var orgs []models.Organization
 	err := r.db.Find(&orgs).Error
@@ -69,8 +96,19 @@ func (r *OrganizationRepository) Update(org *models.Organization) error {
 	return r.db.Save(org).Error
 }
 
+// Delete soft-deletes an organization. It returns ErrOrgHasChildren while
+// other organizations are nested below it.
 func (r *OrganizationRepository) Delete(id string) error {
-	return r.db.Where("id = ?", id).Delete(&models.Organization{}).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var children int64
+		if err := tx.Model(&models.Organization{}).Where("parent_org_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrOrgHasChildren
+		}
+		return tx.Where("id = ?", id).Delete(&models.Organization{}).Error
+	})
 }
 
 func (r *OrganizationRepository) GetWithVDCs(id string) (*models.Organization, error) {
@@ -102,12 +140,13 @@ func (r *OrganizationRepository) GetWithEntityRefs(id string) (*models.Organizat
 	org.RunningVMCount = 0
 	org.UserCount = 0
 	org.DiskCount = 0
-	org.DirectlyManagedOrgCount = 0
 
-	// Set managedBy to nil for now - can be enhanced later
-	org.ManagedBy = nil
+	orgs := []models.Organization{*org}
+	if err := r.populateHierarchyRefs(orgs); err != nil {
+		return nil, err
+	}
 
-	return org, nil
+	return &orgs[0], nil
 }
 
 // ListWithEntityRefs gets organizations and populates entity references for API responses
@@ -132,10 +171,10 @@ func (r *OrganizationRepository) ListWithEntityRefs(limit, offset int) ([]models
 		org.RunningVMCount = 0
 		org.UserCount = 0
 		org.DiskCount = 0
-		org.DirectlyManagedOrgCount = 0
+	}
 
-		// Set managedBy to nil for now - can be enhanced later
-		org.ManagedBy = nil
+	if err := r.populateHierarchyRefs(orgs); err != nil {
+		return nil, err
 	}
 
 	return orgs, nil
@@ -178,8 +217,26 @@ func (r *OrganizationRepository) Count() (int64, error) {
 
 // Public API methods for user access control
 
+// OrgListFilter narrows the organizations returned by ListAccessibleOrgs
+type OrgListFilter struct {
+	// Name matches the organization name exactly
+	Name string
+	// ExternalID matches the client-provided external ID exactly
+	ExternalID string
+}
+
+func (f OrgListFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Name != "" {
+		query = query.Where("name = ?", f.Name)
+	}
+	if f.ExternalID != "" {
+		query = query.Where("external_id = ?", f.ExternalID)
+	}
+	return query
+}
+
 // ListAccessibleOrgs retrieves organizations accessible to a user based on their role and organization membership with pagination
-func (r *OrganizationRepository) ListAccessibleOrgs(ctx context.Context, userID string, limit, offset int) ([]models.Organization, error) {
+func (r *OrganizationRepository) ListAccessibleOrgs(ctx context.Context, userID string, filter OrgListFilter, limit, offset int) ([]models.Organization, error) {
 	var orgs []models.Organization
 
 	// Check if user is a system administrator - they have access to all organizations
@@ -197,7 +254,7 @@ func (r *OrganizationRepository) ListAccessibleOrgs(ctx context.Context, userID
 
 	if isSystemAdmin {
 		// System administrators can access all organizations
-		err := r.db.WithContext(ctx).
+		err := filter.apply(r.db.WithContext(ctx)).
 			Limit(limit).
 			Offset(offset).
 			Order("name ASC").
@@ -206,10 +263,11 @@ func (r *OrganizationRepository) ListAccessibleOrgs(ctx context.Context, userID
 			return nil, err
 		}
 	} else {
-		// For non-system administrators, return only their primary organization
-		subquery := r.db.WithContext(ctx).Model(&models.User{}).Select("organization_id").Where("id = ? AND organization_id IS NOT NULL", userID)
+		// For non-system administrators, return their primary organization (and its
+		// descendants when hierarchical access is enabled)
+		subquery := userOrgScope(r.db.WithContext(ctx), userID, r.hierarchicalAccess)
 
-		err = r.db.WithContext(ctx).Where("id IN (?)", subquery).
+		err = filter.apply(r.db.WithContext(ctx)).Where("id IN (?)", subquery).
 			Limit(limit).
 			Offset(offset).
 			Order("name ASC").
@@ -230,17 +288,17 @@ func (r *OrganizationRepository) ListAccessibleOrgs(ctx context.Context, userID
 		org.RunningVMCount = 0
 		org.UserCount = 0
 		org.DiskCount = 0
-		org.DirectlyManagedOrgCount = 0
+	}
 
-		// Set managedBy to nil for now - can be enhanced later
-		org.ManagedBy = nil
+	if err := r.populateHierarchyRefs(orgs); err != nil {
+		return nil, err
 	}
 
 	return orgs, nil
 }
 
 // CountAccessibleOrgs returns the total count of organizations accessible to a user
-func (r *OrganizationRepository) CountAccessibleOrgs(ctx context.Context, userID string) (int64, error) {
+func (r *OrganizationRepository) CountAccessibleOrgs(ctx context.Context, userID string, filter OrgListFilter) (int64, error) {
 	var count int64
 
 	// Check if user is a system administrator - they have access to all organizations
@@ -258,13 +316,14 @@ func (r *OrganizationRepository) CountAccessibleOrgs(ctx context.Context, userID
 
 	if isSystemAdmin {
 		// System administrators can access all organizations
-		err := r.db.WithContext(ctx).Model(&models.Organization{}).Count(&count).Error
+		err := filter.apply(r.db.WithContext(ctx).Model(&models.Organization{})).Count(&count).Error
 		return count, err
 	} else {
-		// For non-system administrators, count only their primary organization
-		subquery := r.db.WithContext(ctx).Model(&models.User{}).Select("organization_id").Where("id = ? AND organization_id IS NOT NULL", userID)
+		// For non-system administrators, count their primary organization (and its
+		// descendants when hierarchical access is enabled)
+		subquery := userOrgScope(r.db.WithContext(ctx), userID, r.hierarchicalAccess)
 
-		err = r.db.WithContext(ctx).Model(&models.Organization{}).Where("id IN (?)", subquery).Count(&count).Error
+		err = filter.apply(r.db.WithContext(ctx).Model(&models.Organization{})).Where("id IN (?)", subquery).Count(&count).Error
 		return count, err
 	}
 }
@@ -292,8 +351,9 @@ func (r *OrganizationRepository) GetAccessibleOrg(ctx context.Context, userID, o
 		return org, err
 	}
 
-	// For non-system administrators, check if the requested org is their primary organization
-	subquery := r.db.WithContext(ctx).Model(&models.User{}).Select("organization_id").Where("id = ? AND organization_id IS NOT NULL", userID)
+	// For non-system administrators, check if the requested org is their primary
+	// organization (or one of its descendants when hierarchical access is enabled)
+	subquery := userOrgScope(r.db.WithContext(ctx), userID, r.hierarchicalAccess)
 
 	err = r.db.WithContext(ctx).Where("id = ? AND id IN (?)", orgID, subquery).First(&org).Error
 	if err != nil {
@@ -312,10 +372,63 @@ func (r *OrganizationRepository) GetAccessibleOrg(ctx context.Context, userID, o
 	org.RunningVMCount = 0
 	org.UserCount = 0
 	org.DiskCount = 0
-	org.DirectlyManagedOrgCount = 0
 
-	// Set managedBy to nil for now - can be enhanced later
-	org.ManagedBy = nil
+	orgs := []models.Organization{org}
+	if err := r.populateHierarchyRefs(orgs); err != nil {
+		return nil, err
+	}
 
-	return &org, nil
+	return &orgs[0], nil
+}
+
+// GetBranding retrieves the branding metadata for an organization, returning an
+// empty record when none has been configured
+func (r *OrganizationRepository) GetBranding(ctx context.Context, orgID string) (*models.OrgBranding, error) {
+	var branding models.OrgBranding
+	err := r.db.WithContext(ctx).Where("organization_id = ?", orgID).First(&branding).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &models.OrgBranding{OrganizationID: orgID}, nil
+		}
+		return nil, err
+	}
+	return &branding, nil
+}
+
+// SaveBranding creates or replaces the branding metadata for an organization,
+// keeping the time it was first created
+func (r *OrganizationRepository) SaveBranding(ctx context.Context, branding *models.OrgBranding) error {
+	if branding == nil {
+		return errors.New("branding cannot be nil")
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "organization_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"logo_url", "primary_color", "secondary_color",
+			"support_email", "support_url", "support_phone", "updated_at",
+		}),
+	}).Create(branding).Error
+}
+
+// AccessibleOrgIDs returns the IDs of organizations whose resources a user can see.
+// all is true for System Administrators, who can see every organization.
+func (r *OrganizationRepository) AccessibleOrgIDs(ctx context.Context, userID string) (ids []string, all bool, err error) {
+	var isSystemAdmin bool
+	err = r.db.WithContext(ctx).Raw(`
+		SELECT EXISTS(
+			SELECT 1 FROM users u
+			JOIN user_roles ur ON u.id = ur.user_id
+			JOIN roles r ON ur.role_id = r.id
+			WHERE u.id = ? AND r.name = ? AND u.deleted_at IS NULL AND r.deleted_at IS NULL
+		)`, userID, models.RoleSystemAdmin).Scan(&isSystemAdmin).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if isSystemAdmin {
+		return nil, true, nil
+	}
+
+	subquery := userOrgScope(r.db.WithContext(ctx), userID, r.hierarchicalAccess)
+	err = r.db.WithContext(ctx).Model(&models.Organization{}).Where("id IN (?)", subquery).Pluck("id", &ids).Error
+	return ids, false, err
 }
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"nil", nil, false},
+		{"not found", gorm.ErrRecordNotFound, false},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", fmt.Errorf("update: %w", &pgconn.PgError{Code: "40P01"}), true},
+		{"connection exception", &pgconn.PgError{Code: "08006"}, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"sqlite busy", sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{"sqlite constraint", sqlite3.Error{Code: sqlite3.ErrConstraint}, false},
+		{"bad connection", driver.ErrBadConn, true},
+		{"context canceled", context.Canceled, false},
+		{"other", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, IsTransientError(tt.err))
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 8: time.Second} {
+		for i := 0; i < 20; i++ {
+			delay := policy.delay(retry)
+			assert.GreaterOrEqual(t, delay, max/2, "retry %d", retry)
+			assert.LessOrEqual(t, delay, max, "retry %d", retry)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	serializationFailure := &pgconn.PgError{Code: "40001"}
+
+	t.Run("retries transient errors until success", func(t *testing.T) {
+		calls := 0
+		err := withRetry(context.Background(), policy, func() error {
+			calls++
+			if calls < 3 {
+				return serializationFailure
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		calls := 0
+		err := withRetry(context.Background(), policy, func() error {
+			calls++
+			return serializationFailure
+		})
+		assert.ErrorIs(t, err, serializationFailure)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		calls := 0
+		err := withRetry(context.Background(), policy, func() error {
+			calls++
+			return gorm.ErrRecordNotFound
+		})
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		slow := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+		calls := 0
+		err := withRetry(ctx, slow, func() error {
+			calls++
+			cancel()
+			return serializationFailure
+		})
+		assert.ErrorIs(t, err, serializationFailure)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestVMRepositoryUpdateStatusRetriesTransientErrors(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.VM{}))
+
+	vm := &models.VM{Name: "vm", VMName: "vm", Namespace: "ns", Status: "POWERED_OFF"}
+	require.NoError(t, db.Create(vm).Error)
+
+	// Fail the first two updates as if the database reported lock contention
+	failures := 2
+	require.NoError(t, db.Callback().Update().Before("gorm:update").Register("test:busy", func(tx *gorm.DB) {
+		if failures > 0 {
+			failures--
+			_ = tx.AddError(sqlite3.Error{Code: sqlite3.ErrBusy})
+		}
+	}))
+
+	repo := NewVMRepository(db)
+	repo.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	require.NoError(t, repo.UpdateStatus(context.Background(), vm.ID, "POWERED_ON"))
+	assert.Equal(t, 0, failures)
+
+	stored, err := repo.GetByID(vm.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "POWERED_ON", stored.Status)
+}
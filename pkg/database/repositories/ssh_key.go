@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/pagination"
+)
+
+// SSHKeyRepository stores the SSH public keys users register for their VMs
+type SSHKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewSSHKeyRepository creates a new SSHKeyRepository
+func NewSSHKeyRepository(db *gorm.DB) *SSHKeyRepository {
+	return &SSHKeyRepository{db: db}
+}
+
+// Create stores a new SSH key
+func (r *SSHKeyRepository) Create(ctx context.Context, key *models.SSHKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+// GetByID retrieves a user's SSH key, returning gorm.ErrRecordNotFound if the
+// key does not exist or belongs to another user
+func (r *SSHKeyRepository) GetByID(ctx context.Context, userID, id string) (*models.SSHKey, error) {
+	var key models.SSHKey
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListByUserID returns all of a user's SSH keys ordered by name
+func (r *SSHKeyRepository) ListByUserID(ctx context.Context, userID string) ([]models.SSHKey, error) {
+	var keys []models.SSHKey
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("name ASC").Find(&keys).Error
+	return keys, err
+}
+
+// ListByUserIDWithPagination returns a page of a user's SSH keys ordered by name
+func (r *SSHKeyRepository) ListByUserIDWithPagination(ctx context.Context, userID string, limit, offset int) ([]models.SSHKey, error) {
+	limit, offset = pagination.ClampPaginationParams(limit, offset)
+
+	var keys []models.SSHKey
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("name ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&keys).Error
+	return keys, err
+}
+
+// CountByUserID returns the number of SSH keys a user has registered
+func (r *SSHKeyRepository) CountByUserID(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.SSHKey{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// ExistsForUser reports whether the user has another key with the given name
+// or fingerprint, ignoring the key being updated
+func (r *SSHKeyRepository) ExistsForUser(ctx context.Context, userID, excludeID, name, fingerprint string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.SSHKey{}).
+		Where("user_id = ? AND id <> ? AND (name = ? OR fingerprint = ?)", userID, excludeID, name, fingerprint).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Update saves changes to an SSH key
+func (r *SSHKeyRepository) Update(ctx context.Context, key *models.SSHKey) error {
+	return r.db.WithContext(ctx).Save(key).Error
+}
+
+// Delete removes a user's SSH key, returning gorm.ErrRecordNotFound if it does not exist
+func (r *SSHKeyRepository) Delete(ctx context.Context, userID, id string) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.SSHKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// SystemStatusRepository stores the administrator's system status override
+type SystemStatusRepository struct {
+	db *gorm.DB
+}
+
+// NewSystemStatusRepository creates a new SystemStatusRepository
+func NewSystemStatusRepository(db *gorm.DB) *SystemStatusRepository {
+	return &SystemStatusRepository{db: db}
+}
+
+// GetOverride returns the status override, or nil when none is set
+func (r *SystemStatusRepository) GetOverride(ctx context.Context) (*models.SystemStatusOverride, error) {
+	var override models.SystemStatusOverride
+	err := r.db.WithContext(ctx).Where("id = ?", models.SystemStatusOverrideID).First(&override).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &override, nil
+}
+
+// SaveOverride creates or replaces the status override
+func (r *SystemStatusRepository) SaveOverride(ctx context.Context, override *models.SystemStatusOverride) error {
+	if override == nil {
+		return errors.New("override cannot be nil")
+	}
+	override.ID = models.SystemStatusOverrideID
+	return r.db.WithContext(ctx).Save(override).Error
+}
+
+// ClearOverride removes the status override, if any
+func (r *SystemStatusRepository) ClearOverride(ctx context.Context) error {
+	return r.db.WithContext(ctx).Where("id = ?", models.SystemStatusOverrideID).Delete(&models.SystemStatusOverride{}).Error
+}
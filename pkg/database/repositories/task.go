@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+type TaskRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskRepository(db *gorm.DB) *TaskRepository {
+	return &TaskRepository{db: db}
+}
+
+// Create creates a new task record
+func (r *TaskRepository) Create(ctx context.Context, task *models.Task) error {
+	return r.db.WithContext(ctx).Create(task).Error
+}
+
+// GetByID retrieves a task by its URN
+func (r *TaskRepository) GetByID(ctx context.Context, id string) (*models.Task, error) {
+	var task models.Task
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&task).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// CreateVMTask creates a running task owned by a VM, resolving the owning
+// organization through the VM's vApp and VDC
+func (r *TaskRepository) CreateVMTask(ctx context.Context, vmID, name, operation, userID string) (*models.Task, error) {
+	var owner struct {
+		Name           string
+		OrganizationID string
+	}
+	err := r.db.WithContext(ctx).Table("vms").
+		Select("vms.name, vdcs.organization_id").
+		Joins("JOIN v_apps ON vms.vapp_id = v_apps.id").
+		Joins("JOIN vdcs ON v_apps.vdc_id = vdcs.id").
+		Where("vms.id = ? AND vms.deleted_at IS NULL", vmID).
+		Take(&owner).Error
+	if err != nil {
+		return nil, err
+	}
+
+	task := &models.Task{
+		Name:           name,
+		Operation:      operation,
+		Status:         models.TaskStatusRunning,
+		OwnerID:        vmID,
+		OwnerName:      owner.Name,
+		OrganizationID: owner.OrganizationID,
+		UserID:         userID,
+	}
+	if err := r.Create(ctx, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// CreateVAppTask creates a running task owned by a vApp, resolving the owning
+// organization through the vApp's VDC
+func (r *TaskRepository) CreateVAppTask(ctx context.Context, vappID, name, operation, userID string) (*models.Task, error) {
+	var owner struct {
+		Name           string
+		OrganizationID string
+	}
+	err := r.db.WithContext(ctx).Table("v_apps").
+		Select("v_apps.name, vdcs.organization_id").
+		Joins("JOIN vdcs ON v_apps.vdc_id = vdcs.id").
+		Where("v_apps.id = ? AND v_apps.deleted_at IS NULL", vappID).
+		Take(&owner).Error
+	if err != nil {
+		return nil, err
+	}
+
+	task := &models.Task{
+		Name:           name,
+		Operation:      operation,
+		Status:         models.TaskStatusRunning,
+		OwnerID:        vappID,
+		OwnerName:      owner.Name,
+		OrganizationID: owner.OrganizationID,
+		UserID:         userID,
+	}
+	if err := r.Create(ctx, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// ListActiveByOwner returns queued and running tasks for an entity, oldest first
+func (r *TaskRepository) ListActiveByOwner(ctx context.Context, ownerID string) ([]models.Task, error) {
+	var tasks []models.Task
+	err := r.db.WithContext(ctx).
+		Where("owner_id = ? AND status IN ?", ownerID, []string{models.TaskStatusQueued, models.TaskStatusRunning}).
+		Order("start_time ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// CountFinishedSince counts the tasks named one of names that finished after
+// since, by status
+func (r *TaskRepository) CountFinishedSince(ctx context.Context, names []string, since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).
+		Model(&models.Task{}).
+		Select("status, COUNT(*) AS count").
+		Where("name IN ? AND end_time > ?", names, since).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// UpdateStatus transitions a task that has not yet finished. The end time is set
+// when the new status is terminal. Returns gorm.ErrRecordNotFound if the task does
+// not exist or has already finished.
+func (r *TaskRepository) UpdateStatus(ctx context.Context, id, status string, progress int, details string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     status,
+		"progress":   progress,
+		"details":    details,
+		"updated_at": now,
+	}
+	if models.IsTerminalTaskStatus(status) {
+		updates["end_time"] = now
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.Task{}).
+		Where("id = ? AND status IN ?", id, []string{models.TaskStatusQueued, models.TaskStatusRunning}).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateResult records the JSON result of a task's operation
+func (r *TaskRepository) UpdateResult(ctx context.Context, id, result string) error {
+	res := r.db.WithContext(ctx).
+		Model(&models.Task{}).
+		Where("id = ?", id).
+		Update("result_data", result)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindApprovalTask returns the newest task in one of statuses that records a
+// user's request for approval of an operation, identified by the task name,
+// owner and description
+func (r *TaskRepository) FindApprovalTask(ctx context.Context, name, ownerID, operation, userID string, statuses []string) (*models.Task, error) {
+	var task models.Task
+	err := r.db.WithContext(ctx).
+		Where("name = ? AND owner_id = ? AND operation = ? AND user_id = ? AND status IN ?", name, ownerID, operation, userID, statuses).
+		Order("start_time DESC").
+		First(&task).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ResolveApproval records the approval system's decision on a task pending
+// approval, moving it to status. Returns gorm.ErrRecordNotFound if the task does
+// not exist or is not pending approval.
+func (r *TaskRepository) ResolveApproval(ctx context.Context, id, status, details string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     status,
+		"details":    details,
+		"updated_at": now,
+	}
+	if models.IsTerminalTaskStatus(status) {
+		updates["progress"] = 100
+		updates["end_time"] = now
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.Task{}).
+		Where("id = ? AND status = ?", id, models.TaskStatusPendingApproval).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
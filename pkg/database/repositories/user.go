@@ -72,13 +72,21 @@ func (r *UserRepository) AssignRolesTx(tx *gorm.DB, userID string, roleIDs []str
 	return tx.Model(user).Association("Roles").Replace(&roles)
 }
 
-// CreateUserWithRoles creates a user and assigns roles in a single transaction
+// CreateUserWithRoles creates a user and assigns roles in a single transaction.
+// The user's Enabled field is stored as given.
 func (r *UserRepository) CreateUserWithRoles(user *models.User, roleIDs []string) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		// Create user within transaction
+		// The column defaults to true, so gorm inserts the default instead of false
+		disabled := !user.Enabled
 		if err := r.CreateTx(tx, user); err != nil {
 			return err
 		}
+		if disabled {
+			if err := tx.Model(user).Update("enabled", false).Error; err != nil {
+				return err
+			}
+		}
 
 		// Assign roles if provided
 		if len(roleIDs) > 0 {
@@ -135,6 +143,18 @@ func (r *UserRepository) Update(user *models.User) error {
 	return r.db.Updates(user).Error
 }
 
+// UpdatePasswordHash replaces only the stored password hash for a user
+func (r *UserRepository) UpdatePasswordHash(id, passwordHash string) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).UpdateColumn("password_hash", passwordHash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func (r *UserRepository) Delete(id string) error {
 	result := r.db.Where("id = ?", id).Delete(&models.User{})
 	if result.Error != nil {
@@ -164,6 +184,34 @@ func (r *UserRepository) GetWithRoles(id string) (*models.User, error) {
 	return &user, nil
 }
 
+// ListWithRoles returns every user with their roles and organization
+func (r *UserRepository) ListWithRoles() ([]models.User, error) {
+	var users []models.User
+	err := r.db.Preload("Roles").Preload("Organization").Order("username").Find(&users).Error
+	return users, err
+}
+
+// SetGroupMembership sets a user's organization and replaces their roles, and
+// records whether the OpenShift Group sync manages them
+func (r *UserRepository) SetGroupMembership(userID string, orgID *string, roleIDs []string, groupSynced bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		user, err := r.getByIDTx(tx, userID)
+		if err != nil {
+			return err
+		}
+		user.OrganizationID = orgID
+		user.GroupSynced = groupSynced
+		// BeforeUpdate fills in the organization name
+		if err := tx.Model(user).Select("organization_id", "organization_name", "group_synced").Updates(user).Error; err != nil {
+			return err
+		}
+		if len(roleIDs) == 0 {
+			return tx.Model(user).Association("Roles").Clear()
+		}
+		return r.AssignRolesTx(tx, userID, roleIDs)
+	})
+}
+
 // GetWithEntityRefs gets a user and populates entity references for API responses
 func (r *UserRepository) GetWithEntityRefs(id string) (*models.User, error) {
 	user, err := r.GetWithRoles(id)
@@ -17,11 +17,17 @@ import (
 var ErrVAppHasRunningVMs = errors.New("vApp contains running VMs")
 
 type VAppRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	retry RetryPolicy
 }
 
 func NewVAppRepository(db *gorm.DB) *VAppRepository {
-	return &VAppRepository{db: db}
+	return &VAppRepository{db: db, retry: DefaultRetryPolicy()}
+}
+
+// SetRetryPolicy controls how controller status writes retry transient database errors
+func (r *VAppRepository) SetRetryPolicy(policy RetryPolicy) {
+	r.retry = policy
 }
 
 func (r *VAppRepository) Create(vapp *models.VApp) error {
@@ -132,38 +138,105 @@ func (r *VAppRepository) ExistsByNameInVDC(ctx context.Context, vdcID, name stri
 	return count > 0, err
 }
 
-// ListByVDCWithPagination retrieves vApps for a VDC with pagination, filtering, and sorting
-func (r *VAppRepository) ListByVDCWithPagination(ctx context.Context, vdcID string, limit, offset int, filter, sortOrder string) ([]models.VApp, error) {
-	var vapps []models.VApp
-	query := r.db.WithContext(ctx).Preload("VMs").Where("vdc_id = ?", vdcID)
+// CountInstantiatingByVDC returns the number of vApps instantiating in a VDC
+func (r *VAppRepository) CountInstantiatingByVDC(ctx context.Context, vdcID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.VApp{}).
+		Where("vdc_id = ? AND status = ?", vdcID, models.VAppStatusInstantiating).
+		Count(&count).Error
+	return count, err
+}
 
-	// Apply filter if provided
-	if filter != "" {
-		query = r.applyFilter(query, filter)
-	}
+// CountInstantiatingByOrg returns the number of vApps instantiating across an
+// organization's VDCs
+func (r *VAppRepository) CountInstantiatingByOrg(ctx context.Context, orgID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.VApp{}).
+		Joins("JOIN vdcs ON v_apps.vdc_id = vdcs.id").
+		Where("vdcs.organization_id = ? AND v_apps.status = ?", orgID, models.VAppStatusInstantiating).
+		Count(&count).Error
+	return count, err
+}
+
+// VAppListFilter narrows a vApp listing
+type VAppListFilter struct {
+	// Filter is a VMware Cloud Director filter expression ('attribute==value' or a name substring)
+	Filter string
+	// Statuses restricts results to vApps in any of the given statuses
+	Statuses []string
+	// NameSearch is a case-insensitive substring match on the vApp name
+	NameSearch string
+}
+
+// VAppSummary is a vApp together with the number of VMs it contains
+type VAppSummary struct {
+	models.VApp
+	NumberOfVMs int
+}
+
+// vmCountsSubquery aggregates live VMs per vApp so listings can join the counts
+// in the same query instead of loading every VM
+const vmCountsSubquery = "LEFT JOIN (SELECT vapp_id, COUNT(*) AS vm_count FROM vms WHERE deleted_at IS NULL GROUP BY vapp_id) AS vm_counts ON vm_counts.vapp_id = v_apps.id"
+
+// ListByVDCWithPagination retrieves vApps for a VDC with pagination, filtering, and sorting.
+// VM counts are computed by the database in the same query.
+func (r *VAppRepository) ListByVDCWithPagination(ctx context.Context, vdcID string, limit, offset int, filter VAppListFilter, sortOrder string) ([]VAppSummary, error) {
+	query := r.applyListFilter(r.db.WithContext(ctx).Model(&models.VApp{}).Where("v_apps.vdc_id = ?", vdcID), filter)
 
 	// Sanitize and validate pagination parameters
 	limit, offset = pagination.ClampPaginationParams(limit, offset)
 	sortOrder = pagination.SanitizeSortOrder(sortOrder, pagination.VAppSortColumns, "created_at DESC, id DESC")
 
-	err := query.Limit(limit).Offset(offset).Order(sortOrder).Find(&vapps).Error
-	return vapps, err
+	var summaries []VAppSummary
+	err := query.
+		Select("v_apps.*, COALESCE(vm_counts.vm_count, 0) AS number_of_vms").
+		Joins(vmCountsSubquery).
+		Limit(limit).Offset(offset).Order(sortOrder).
+		Scan(&summaries).Error
+	return summaries, err
 }
 
-// CountByVDC returns the total count of vApps in a VDC (for pagination)
-func (r *VAppRepository) CountByVDC(ctx context.Context, vdcID string, filter string) (int64, error) {
-	var count int64
-	query := r.db.WithContext(ctx).Model(&models.VApp{}).Where("vdc_id = ?", vdcID)
-
-	// Apply filter if provided
-	if filter != "" {
-		query = r.applyFilter(query, filter)
+// ListByVDCAfterCursor retrieves the vApps of a VDC that follow the cursor in
+// name and ID order. It returns up to limit+1 vApps so callers can tell
+// whether another page follows.
+func (r *VAppRepository) ListByVDCAfterCursor(ctx context.Context, vdcID string, after *pagination.Cursor, limit int, filter VAppListFilter) ([]VAppSummary, error) {
+	query := r.applyListFilter(r.db.WithContext(ctx).Model(&models.VApp{}).Where("v_apps.vdc_id = ?", vdcID), filter)
+	if after != nil {
+		query = query.Where("(v_apps.name > ? OR (v_apps.name = ? AND v_apps.id > ?))", after.Name, after.Name, after.ID)
 	}
+	limit, _ = pagination.ClampPaginationParams(limit, 0)
+
+	var summaries []VAppSummary
+	err := query.
+		Select("v_apps.*, COALESCE(vm_counts.vm_count, 0) AS number_of_vms").
+		Joins(vmCountsSubquery).
+		Limit(limit + 1).Order("v_apps.name ASC, v_apps.id ASC").
+		Scan(&summaries).Error
+	return summaries, err
+}
 
+// CountByVDC returns the total count of vApps in a VDC (for pagination)
+func (r *VAppRepository) CountByVDC(ctx context.Context, vdcID string, filter VAppListFilter) (int64, error) {
+	var count int64
+	query := r.applyListFilter(r.db.WithContext(ctx).Model(&models.VApp{}).Where("v_apps.vdc_id = ?", vdcID), filter)
 	err := query.Count(&count).Error
 	return count, err
 }
 
+// applyListFilter applies every part of a VAppListFilter to a query
+func (r *VAppRepository) applyListFilter(query *gorm.DB, filter VAppListFilter) *gorm.DB {
+	if filter.Filter != "" {
+		query = r.applyFilter(query, filter.Filter)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("v_apps.status IN ?", filter.Statuses)
+	}
+	if filter.NameSearch != "" {
+		query = query.Where("LOWER(v_apps.name) LIKE ?", "%"+strings.ToLower(filter.NameSearch)+"%")
+	}
+	return query
+}
+
 // GetWithVMsString retrieves a vApp with its VMs using string ID
 func (r *VAppRepository) GetWithVMsString(ctx context.Context, vappID string) (*models.VApp, error) {
 	var vapp models.VApp
@@ -177,6 +250,16 @@ func (r *VAppRepository) GetWithVMsString(ctx context.Context, vappID string) (*
 	return &vapp, nil
 }
 
+// LoadVMs loads the vApp's VMs into vapp.VMs
+func (r *VAppRepository) LoadVMs(ctx context.Context, vapp *models.VApp) error {
+	var vms []models.VM
+	if err := r.db.WithContext(ctx).Where("vapp_id = ?", vapp.ID).Find(&vms).Error; err != nil {
+		return err
+	}
+	vapp.VMs = vms
+	return nil
+}
+
 // DeleteWithValidation deletes a vApp after checking for dependencies
 func (r *VAppRepository) DeleteWithValidation(ctx context.Context, vappID string, force bool) error {
 	// Use a transaction to ensure atomicity
@@ -235,20 +318,20 @@ func (r *VAppRepository) applyFilter(query *gorm.DB, filter string) *gorm.DB {
 			// Validate allowed filter attributes
 			switch attribute {
 			case "name":
-				return query.Where("name = ?", value)
+				return query.Where("v_apps.name = ?", value)
 			case "status":
-				return query.Where("status = ?", value)
+				return query.Where("v_apps.status = ?", value)
 			case "description":
-				return query.Where("description = ?", value)
+				return query.Where("v_apps.description = ?", value)
 			default:
 				// Invalid attribute, fall back to name substring matching using the value part
-				return query.Where("name LIKE ?", fmt.Sprintf("%%%s%%", value))
+				return whereContains(query, "v_apps.name", value)
 			}
 		}
 	}
 
 	// Fall back to simple name substring matching for backward compatibility
-	return query.Where("name LIKE ?", fmt.Sprintf("%%%s%%", filter))
+	return whereContains(query, "v_apps.name", filter)
 }
 
 // Controller-specific methods
@@ -270,33 +353,91 @@ func (r *VAppRepository) CreateVApp(ctx context.Context, vapp *models.VApp) erro
 	return r.db.WithContext(ctx).Create(vapp).Error
 }
 
-// UpdateStatus updates only the status field of a VApp (for controller)
+// UpdateStatus updates only the status field of a VApp (for controller), retrying
+// transient database errors with backoff
 func (r *VAppRepository) UpdateStatus(ctx context.Context, vappID string, status string) error {
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VApp{}).
+			Where("id = ?", vappID).
+			Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// No rows affected could mean either:
+			// 1. vApp doesn't exist, or
+			// 2. vApp exists but status was unchanged
+			// Perform existence check to distinguish between these cases
+			var count int64
+			err := r.db.WithContext(ctx).
+				Model(&models.VApp{}).
+				Where("id = ?", vappID).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			// vApp exists but status was unchanged - this is a no-op, return nil
+			return nil
+		}
+		return nil
+	})
+}
+
+// UpdateHealthState updates only the health state of a VApp (for controller)
+func (r *VAppRepository) UpdateHealthState(ctx context.Context, vappID string, healthState string) error {
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VApp{}).
+			Where("id = ?", vappID).
+			Update("health_state", healthState)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// UpdateConditions replaces the readiness conditions of a vApp
+func (r *VAppRepository) UpdateConditions(ctx context.Context, vappID string, conditions []models.Condition) error {
+	var vapp models.VApp
+	vapp.SetConditions(conditions)
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VApp{}).
+			Where("id = ?", vappID).
+			Update("conditions_data", vapp.ConditionsData)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// UpdateBackupPolicy sets only the backup policy columns of a VApp; a nil policy
+// restores its VDC's policy
+func (r *VAppRepository) UpdateBackupPolicy(ctx context.Context, vappID string, policy *models.BackupPolicy) error {
+	var vapp models.VApp
+	vapp.SetBackupPolicy(policy)
 	result := r.db.WithContext(ctx).
 		Model(&models.VApp{}).
 		Where("id = ?", vappID).
-		Update("status", status)
+		Select("backup_enabled", "backup_schedule").
+		Updates(&vapp)
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
-		// No rows affected could mean either:
-		// 1. vApp doesn't exist, or
-		// 2. vApp exists but status was unchanged
-		// Perform existence check to distinguish between these cases
-		var count int64
-		err := r.db.WithContext(ctx).
-			Model(&models.VApp{}).
-			Where("id = ?", vappID).
-			Count(&count).Error
-		if err != nil {
-			return err
-		}
-		if count == 0 {
-			return gorm.ErrRecordNotFound
-		}
-		// vApp exists but status was unchanged - this is a no-op, return nil
-		return nil
+		return gorm.ErrRecordNotFound
 	}
 	return nil
 }
@@ -3,6 +3,8 @@ package repositories
 import (
 	"context"
 	"errors"
+	"sort"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -11,13 +13,20 @@ import (
 )
 
 type VDCRepository struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	hierarchicalAccess bool
 }
 
 func NewVDCRepository(db *gorm.DB) *VDCRepository {
 	return &VDCRepository{db: db}
 }
 
+// SetHierarchicalAccess controls whether membership in an organization grants
+// access to VDCs of its descendant organizations
+func (r *VDCRepository) SetHierarchicalAccess(enabled bool) {
+	r.hierarchicalAccess = enabled
+}
+
 func (r *VDCRepository) Create(vdc *models.VDC) error {
 	if vdc == nil {
 		return errors.New("VDC cannot be nil")
@@ -25,6 +34,12 @@ func (r *VDCRepository) Create(vdc *models.VDC) error {
 	return r.db.Create(vdc).Error
 }
 
+// GenerateNamespace returns a free namespace name for a new VDC. inUse, when
+// set, rejects names taken outside the database, such as existing namespaces.
+func (r *VDCRepository) GenerateNamespace(ctx context.Context, orgName, vdcName string, inUse models.NamespaceInUse) (string, error) {
+	return models.GenerateVDCNamespace(r.db.WithContext(ctx), orgName, vdcName, inUse)
+}
+
 func (r *VDCRepository) GetByID(id string) (*models.VDC, error) {
 	var vdc models.VDC
 	err := r.db.Where("id = ?", id).First(&vdc).Error
@@ -40,6 +55,26 @@ func (r *VDCRepository) GetByOrganizationID(orgID string) ([]models.VDC, error)
 	return vdcs, err
 }
 
+// GetByOrgAndName retrieves a VDC by name within an organization
+func (r *VDCRepository) GetByOrgAndName(orgID, name string) (*models.VDC, error) {
+	var vdc models.VDC
+	err := r.db.Where("organization_id = ? AND name = ?", orgID, name).First(&vdc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &vdc, nil
+}
+
+// GetByExternalID retrieves a VDC by its client-provided external ID
+func (r *VDCRepository) GetByExternalID(externalID string) (*models.VDC, error) {
+	var vdc models.VDC
+	err := r.db.Preload("StorageProfiles", orderByName).Where("external_id = ?", externalID).First(&vdc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &vdc, nil
+}
+
 func (r *VDCRepository) List() ([]models.VDC, error) {
 	var vdcs []models.VDC
 	err := r.db.Find(&vdcs).Error
@@ -120,6 +155,7 @@ func (r *VDCRepository) ListByOrgWithPagination(orgID string, limit, offset int)
 	limit, offset = pagination.ClampPaginationParams(limit, offset)
 
 	err := r.db.Where("organization_id = ?", orgID).
+		Preload("StorageProfiles", orderByName).
 		Limit(limit).
 		Offset(offset).
 		Order("created_at DESC, id DESC").
@@ -186,10 +222,27 @@ func (r *VDCRepository) DeleteWithValidation(id string) error {
 
 // Public API methods for user access control
 
-// ListAccessibleVDCs retrieves VDCs accessible to a user based on organization membership with pagination
-func (r *VDCRepository) ListAccessibleVDCs(ctx context.Context, userID string, limit, offset int) ([]models.VDC, error) {
-	var vdcs []models.VDC
+// VDCListFilter narrows the VDCs returned by ListAccessibleVDCs
+type VDCListFilter struct {
+	// Name matches the VDC name exactly
+	Name string
+	// ExternalID matches the client-provided external ID exactly
+	ExternalID string
+}
 
+func (f VDCListFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Name != "" {
+		query = query.Where("name = ?", f.Name)
+	}
+	if f.ExternalID != "" {
+		query = query.Where("external_id = ?", f.ExternalID)
+	}
+	return query
+}
+
+// accessibleVDCs returns a query scoped to the VDCs a user may access: all of
+// them for system administrators, otherwise those of the user's organizations
+func (r *VDCRepository) accessibleVDCs(ctx context.Context, userID string) (*gorm.DB, error) {
 	// Check if user is a system administrator - they have access to all VDCs
 	var isSystemAdmin bool
 	err := r.db.WithContext(ctx).Raw(`
@@ -203,88 +256,254 @@ func (r *VDCRepository) ListAccessibleVDCs(ctx context.Context, userID string, l
 		return nil, err
 	}
 
+	query := r.db.WithContext(ctx).Model(&models.VDC{})
 	if isSystemAdmin {
-		// System administrators can access all VDCs
-		err := r.db.WithContext(ctx).
-			Limit(limit).
-			Offset(offset).
-			Order("created_at DESC, id DESC").
-			Find(&vdcs).Error
-		return vdcs, err
+		return query, nil
 	}
 
 	// For non-system administrators, check organization membership
-	subquery := r.db.WithContext(ctx).Model(&models.User{}).Select("organization_id").Where("id = ? AND organization_id IS NOT NULL", userID)
-
-	err = r.db.WithContext(ctx).Where("organization_id IN (?)", subquery).
-		Limit(limit).
-		Offset(offset).
-		Order("created_at DESC, id DESC").
-		Find(&vdcs).Error
+	subquery := userOrgScope(r.db.WithContext(ctx), userID, r.hierarchicalAccess)
+	return query.Where("organization_id IN (?)", subquery), nil
+}
 
-	return vdcs, err
+// ListAccessibleVDCs retrieves VDCs accessible to a user based on organization membership with pagination
+func (r *VDCRepository) ListAccessibleVDCs(ctx context.Context, userID string, filter VDCListFilter, limit, offset int) ([]models.VDC, error) {
+	query, err := r.accessibleVDCs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return r.findVDCPage(filter.apply(query), limit, offset)
 }
 
 // CountAccessibleVDCs returns the total count of VDCs accessible to a user
-func (r *VDCRepository) CountAccessibleVDCs(ctx context.Context, userID string) (int64, error) {
+func (r *VDCRepository) CountAccessibleVDCs(ctx context.Context, userID string, filter VDCListFilter) (int64, error) {
+	query, err := r.accessibleVDCs(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
 	var count int64
+	err = filter.apply(query).Count(&count).Error
+	return count, err
+}
 
-	// Check if user is a system administrator - they have access to all VDCs
-	var isSystemAdmin bool
-	err := r.db.WithContext(ctx).Raw(`
-		SELECT EXISTS(
-			SELECT 1 FROM users u
-			JOIN user_roles ur ON u.id = ur.user_id
-			JOIN roles r ON ur.role_id = r.id
-			WHERE u.id = ? AND r.name = ? AND u.deleted_at IS NULL AND r.deleted_at IS NULL
-		)`, userID, models.RoleSystemAdmin).Scan(&isSystemAdmin).Error
+// ListAccessibleVDCsWithCount retrieves a page of the VDCs accessible to a
+// user along with their total count, checking the user's access only once
+func (r *VDCRepository) ListAccessibleVDCsWithCount(ctx context.Context, userID string, filter VDCListFilter, limit, offset int) ([]models.VDC, int64, error) {
+	query, err := r.accessibleVDCs(ctx, userID)
 	if err != nil {
-		return 0, err
+		return nil, 0, err
 	}
 
-	if isSystemAdmin {
-		// System administrators can access all VDCs
-		err := r.db.WithContext(ctx).Model(&models.VDC{}).Count(&count).Error
-		return count, err
+	vdcs, err := r.findVDCPage(filter.apply(query.Session(&gorm.Session{})), limit, offset)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// For non-system administrators, check organization membership
-	subquery := r.db.WithContext(ctx).Model(&models.User{}).Select("organization_id").Where("id = ? AND organization_id IS NOT NULL", userID)
+	var count int64
+	err = filter.apply(query.Session(&gorm.Session{})).Count(&count).Error
+	return vdcs, count, err
+}
 
-	err = r.db.WithContext(ctx).Model(&models.VDC{}).Where("organization_id IN (?)", subquery).Count(&count).Error
-	return count, err
+// findVDCPage retrieves a page of the VDCs matching query, newest first
+func (r *VDCRepository) findVDCPage(query *gorm.DB, limit, offset int) ([]models.VDC, error) {
+	var vdcs []models.VDC
+	err := query.
+		Preload("StorageProfiles", orderByName).
+		Limit(limit).
+		Offset(offset).
+		Order("created_at DESC, id DESC").
+		Find(&vdcs).Error
+	return vdcs, err
 }
 
 // GetAccessibleVDC retrieves a specific VDC if the user has access to it
 func (r *VDCRepository) GetAccessibleVDC(ctx context.Context, userID, vdcID string) (*models.VDC, error) {
+	query, err := r.accessibleVDCs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	var vdc models.VDC
+	if err := query.Where("id = ?", vdcID).First(&vdc).Error; err != nil {
+		return nil, err
+	}
+	return &vdc, nil
+}
 
-	// Check if user is a system administrator - they have access to all VDCs
-	var isSystemAdmin bool
-	err := r.db.WithContext(ctx).Raw(`
-		SELECT EXISTS(
-			SELECT 1 FROM users u
-			JOIN user_roles ur ON u.id = ur.user_id
-			JOIN roles r ON ur.role_id = r.id
-			WHERE u.id = ? AND r.name = ? AND u.deleted_at IS NULL AND r.deleted_at IS NULL
-		)`, userID, models.RoleSystemAdmin).Scan(&isSystemAdmin).Error
+// Storage profile methods
+
+// orderByName orders preloaded storage profiles by name
+func orderByName(db *gorm.DB) *gorm.DB {
+	return db.Order("name")
+}
+
+// LoadStorageProfiles loads the VDC's storage profiles into vdc.StorageProfiles
+func (r *VDCRepository) LoadStorageProfiles(ctx context.Context, vdc *models.VDC) error {
+	var profiles []models.VDCStorageProfile
+	err := r.db.WithContext(ctx).Where("vdc_id = ?", vdc.ID).Order("name").Find(&profiles).Error
 	if err != nil {
-		return nil, err
+		return err
 	}
+	vdc.StorageProfiles = profiles
+	return nil
+}
 
-	if isSystemAdmin {
-		// System administrators can access any VDC
-		err := r.db.WithContext(ctx).Where("id = ?", vdcID).First(&vdc).Error
-		return &vdc, err
+// SetStorageProfileLimits replaces the VDC's storage profile limits. Profiles
+// not listed are removed; usage of the remaining profiles is preserved.
+func (r *VDCRepository) SetStorageProfileLimits(ctx context.Context, vdcID string, limits map[string]int64) error {
+	profiles := make([]models.VDCStorageProfile, 0, len(limits))
+	for name, limit := range limits {
+		profiles = append(profiles, models.VDCStorageProfile{Name: name, LimitMB: limit})
 	}
+	return r.SetStorageProfiles(ctx, vdcID, profiles)
+}
 
-	// For non-system administrators, check organization membership
-	subquery := r.db.WithContext(ctx).Model(&models.User{}).Select("organization_id").Where("id = ? AND organization_id IS NOT NULL", userID)
+// SetStorageProfiles replaces the VDC's storage profiles with the limits,
+// default flag and disk tier of those given. Profiles not listed are removed;
+// usage of the remaining profiles is preserved.
+func (r *VDCRepository) SetStorageProfiles(ctx context.Context, vdcID string, profiles []models.VDCStorageProfile) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		names := make([]string, 0, len(profiles))
+		for _, profile := range profiles {
+			names = append(names, profile.Name)
+		}
 
-	err = r.db.WithContext(ctx).Where("id = ? AND organization_id IN (?)", vdcID, subquery).First(&vdc).Error
+		remove := tx.Where("vdc_id = ?", vdcID)
+		if len(names) > 0 {
+			remove = remove.Where("name NOT IN ?", names)
+		}
+		if err := remove.Delete(&models.VDCStorageProfile{}).Error; err != nil {
+			return err
+		}
+
+		for _, params := range profiles {
+			var profile models.VDCStorageProfile
+			err := tx.Where("vdc_id = ? AND name = ?", vdcID, params.Name).First(&profile).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				profile = models.VDCStorageProfile{VDCID: vdcID, Name: params.Name}
+			} else if err != nil {
+				return err
+			}
+			profile.LimitMB = params.LimitMB
+			profile.IsDefault = params.IsDefault
+			profile.DiskTier = params.DiskTier
+			if err := tx.Save(&profile).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateStorageUsage records the storage used per profile in a VDC, creating
+// unlimited profiles for storage classes in use that have none, and returns the
+// VDC's profiles after the update. Profiles absent from usedMB are set to zero.
+func (r *VDCRepository) UpdateStorageUsage(ctx context.Context, vdcID string, usedMB map[string]int64) ([]models.VDCStorageProfile, error) {
+	var profiles []models.VDCStorageProfile
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("vdc_id = ?", vdcID).Find(&profiles).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		known := make(map[string]bool, len(profiles))
+		for i := range profiles {
+			known[profiles[i].Name] = true
+			used := usedMB[profiles[i].Name]
+			if profiles[i].UsedMB == used && profiles[i].UsageUpdatedAt != nil {
+				continue
+			}
+			profiles[i].UsedMB = used
+			profiles[i].UsageUpdatedAt = &now
+			if err := tx.Model(&profiles[i]).Updates(map[string]interface{}{
+				"used_mb":          used,
+				"usage_updated_at": now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		for name, used := range usedMB {
+			if known[name] {
+				continue
+			}
+			profile := models.VDCStorageProfile{VDCID: vdcID, Name: name, UsedMB: used, UsageUpdatedAt: &now}
+			if err := tx.Create(&profile).Error; err != nil {
+				return err
+			}
+			profiles = append(profiles, profile)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
 
-	return &vdc, nil
+// SetStorageAlertLevel records the last storage usage alert raised for a profile
+func (r *VDCRepository) SetStorageAlertLevel(ctx context.Context, profileID, level string) error {
+	return r.db.WithContext(ctx).Model(&models.VDCStorageProfile{}).
+		Where("id = ?", profileID).
+		Update("alert_level", level).Error
+}
+
+// UpdateConditions replaces the readiness conditions of a VDC
+func (r *VDCRepository) UpdateConditions(ctx context.Context, vdcID string, conditions []models.Condition) error {
+	var vdc models.VDC
+	vdc.SetConditions(conditions)
+	return r.db.WithContext(ctx).Model(&models.VDC{}).
+		Where("id = ?", vdcID).
+		Update("conditions_data", vdc.ConditionsData).Error
+}
+
+// ComputeUsage returns the number of VMs in a VDC and the vCPUs and memory
+// they reserve
+func (r *VDCRepository) ComputeUsage(ctx context.Context, vdcID string) (models.ComputeUsage, error) {
+	var usage models.ComputeUsage
+	err := r.db.WithContext(ctx).Model(&models.VM{}).
+		Select("COUNT(vms.id), COALESCE(SUM(vms.cpu_count), 0), COALESCE(SUM(vms.memory_mb), 0)").
+		Joins("JOIN v_apps ON vms.vapp_id = v_apps.id").
+		Where("v_apps.vdc_id = ? AND v_apps.deleted_at IS NULL", vdcID).
+		Row().Scan(&usage.VMs, &usage.CPUs, &usage.MemoryMB)
+	return usage, err
+}
+
+// ComputeUsageByVDC returns the compute usage of each of the given VDCs in a
+// single aggregate query. VDCs without VMs are absent from the result.
+func (r *VDCRepository) ComputeUsageByVDC(ctx context.Context, vdcIDs []string) (map[string]models.ComputeUsage, error) {
+	usage := make(map[string]models.ComputeUsage, len(vdcIDs))
+	if len(vdcIDs) == 0 {
+		return usage, nil
+	}
+
+	var rows []struct {
+		VDCID    string `gorm:"column:vdc_id"`
+		VMs      int64  `gorm:"column:vms"`
+		CPUs     int64  `gorm:"column:cpus"`
+		MemoryMB int64  `gorm:"column:memory_mb"`
+	}
+	err := r.db.WithContext(ctx).Model(&models.VM{}).
+		Select("v_apps.vdc_id AS vdc_id, COUNT(vms.id) AS vms, COALESCE(SUM(vms.cpu_count), 0) AS cpus, COALESCE(SUM(vms.memory_mb), 0) AS memory_mb").
+		Joins("JOIN v_apps ON vms.vapp_id = v_apps.id").
+		Where("v_apps.vdc_id IN ? AND v_apps.deleted_at IS NULL", vdcIDs).
+		Group("v_apps.vdc_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		usage[row.VDCID] = models.ComputeUsage{VMs: row.VMs, CPUs: row.CPUs, MemoryMB: row.MemoryMB}
+	}
+	return usage, nil
+}
+
+// SetQuotaGraceStartedAt records when a VDC went over its compute limits using
+// its grace allowance; nil clears it
+func (r *VDCRepository) SetQuotaGraceStartedAt(ctx context.Context, vdcID string, startedAt *time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.VDC{}).
+		Where("id = ?", vdcID).
+		Update("quota_grace_started_at", startedAt).Error
 }
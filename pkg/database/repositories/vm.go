@@ -7,14 +7,21 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/pagination"
 )
 
 type VMRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	retry RetryPolicy
 }
 
 func NewVMRepository(db *gorm.DB) *VMRepository {
-	return &VMRepository{db: db}
+	return &VMRepository{db: db, retry: DefaultRetryPolicy()}
+}
+
+// SetRetryPolicy controls how controller status writes retry transient database errors
+func (r *VMRepository) SetRetryPolicy(policy RetryPolicy) {
+	r.retry = policy
 }
 
 func (r *VMRepository) Create(vm *models.VM) error {
@@ -184,6 +191,50 @@ func (r *VMRepository) GetWithVAppContext(ctx context.Context, vmID string) (*mo
 
 // Controller-specific methods for VM status synchronization
 
+// ListByVAppWithPagination retrieves the VMs in a vApp with pagination, status filtering and sorting
+func (r *VMRepository) ListByVAppWithPagination(ctx context.Context, vappID string, limit, offset int, statuses []string, sortOrder string) ([]models.VM, error) {
+	query := r.byVAppQuery(ctx, vappID, statuses)
+
+	// Sanitize and validate pagination parameters
+	limit, offset = pagination.ClampPaginationParams(limit, offset)
+	sortOrder = pagination.SanitizeSortOrder(sortOrder, pagination.VMSortColumns, "name ASC, id ASC")
+
+	var vms []models.VM
+	err := query.Limit(limit).Offset(offset).Order(sortOrder).Find(&vms).Error
+	return vms, err
+}
+
+// ListByVAppAfterCursor retrieves the VMs of a vApp that follow the cursor in
+// name and ID order. It returns up to limit+1 VMs so callers can tell whether
+// another page follows.
+func (r *VMRepository) ListByVAppAfterCursor(ctx context.Context, vappID string, after *pagination.Cursor, limit int, statuses []string) ([]models.VM, error) {
+	query := r.byVAppQuery(ctx, vappID, statuses)
+	if after != nil {
+		query = query.Where("(name > ? OR (name = ? AND id > ?))", after.Name, after.Name, after.ID)
+	}
+	limit, _ = pagination.ClampPaginationParams(limit, 0)
+
+	var vms []models.VM
+	err := query.Limit(limit + 1).Order("name ASC, id ASC").Find(&vms).Error
+	return vms, err
+}
+
+// CountByVApp returns the number of VMs in a vApp matching the status filter (for pagination)
+func (r *VMRepository) CountByVApp(ctx context.Context, vappID string, statuses []string) (int64, error) {
+	var count int64
+	err := r.byVAppQuery(ctx, vappID, statuses).Count(&count).Error
+	return count, err
+}
+
+// byVAppQuery scopes a VM query to a vApp and, optionally, a set of statuses
+func (r *VMRepository) byVAppQuery(ctx context.Context, vappID string, statuses []string) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.VM{}).Where("vapp_id = ?", vappID)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	return query
+}
+
 // GetByNamespaceAndVMName finds a VM by its namespace and VM name (for controller)
 func (r *VMRepository) GetByNamespaceAndVMName(ctx context.Context, namespace, vmName string) (*models.VM, error) {
 	var vm models.VM
@@ -208,15 +259,85 @@ func (r *VMRepository) GetByVAppAndVMName(ctx context.Context, vappID, vmName st
 	return &vm, nil
 }
 
-// UpdateStatus updates only the status and updated_at fields of a VM (for controller)
+// UpdateStatus updates only the status and updated_at fields of a VM (for controller),
+// retrying transient database errors with backoff
 func (r *VMRepository) UpdateStatus(ctx context.Context, vmID string, status string) error {
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VM{}).
+			Where("id = ?", vmID).
+			Updates(map[string]interface{}{
+				"status":     status,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// UpdateStatusDetails records why a VM is in its status, or clears it (for
+// controller)
+func (r *VMRepository) UpdateStatusDetails(ctx context.Context, vmID string, details string) error {
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VM{}).
+			Where("id = ?", vmID).
+			Update("status_details", details)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// UpdateHealthState updates only the health state of a VM (for controller)
+func (r *VMRepository) UpdateHealthState(ctx context.Context, vmID string, healthState string) error {
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VM{}).
+			Where("id = ?", vmID).
+			Update("health_state", healthState)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// UpdateIPAddress records the primary IP address reported by a running VM
+func (r *VMRepository) UpdateIPAddress(ctx context.Context, vmID string, ipAddress string) error {
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VM{}).
+			Where("id = ?", vmID).
+			Update("ip_address", ipAddress)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// UpdateDNSName records the FQDN published for a VM; empty when none is
+func (r *VMRepository) UpdateDNSName(ctx context.Context, vmID string, dnsName string) error {
 	result := r.db.WithContext(ctx).
 		Model(&models.VM{}).
 		Where("id = ?", vmID).
-		Updates(map[string]interface{}{
-			"status":     status,
-			"updated_at": time.Now(),
-		})
+		Update("dns_name", dnsName)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -226,9 +347,30 @@ func (r *VMRepository) UpdateStatus(ctx context.Context, vmID string, status str
 	return nil
 }
 
-// CreateVM creates a new VM record (for controller)
+// CreateVM creates a new VM record (for controller), numbering it within the
+// organization of its vApp in the same transaction
 func (r *VMRepository) CreateVM(ctx context.Context, vm *models.VM) error {
-	return r.db.WithContext(ctx).Create(vm).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if vm.FriendlyID == "" {
+			var orgID string
+			err := tx.Model(&models.VApp{}).
+				Select("vdcs.organization_id").
+				Joins("JOIN vdcs ON v_apps.vdc_id = vdcs.id").
+				Where("v_apps.id = ?", vm.VAppID).
+				Scan(&orgID).Error
+			if err != nil {
+				return err
+			}
+			if orgID != "" {
+				number, err := nextOrgSequence(tx, orgID, models.OrgSequenceVM)
+				if err != nil {
+					return err
+				}
+				vm.FriendlyID = models.VMFriendlyID(number)
+			}
+		}
+		return tx.Create(vm).Error
+	})
 }
 
 // UpdateVMData updates the CPU, memory, and guest OS fields for a VM
@@ -248,6 +390,142 @@ func (r *VMRepository) UpdateVMData(ctx context.Context, vmID string, cpuCount *
 		updates["guest_os"] = guestOS
 	}
 
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VM{}).
+			Where("id = ?", vmID).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// UpdateNameAndDescription updates the user-facing display name and description of a VM.
+// Nil arguments leave the corresponding field unchanged.
+func (r *VMRepository) UpdateNameAndDescription(ctx context.Context, vmID string, name, description *string) error {
+	updates := map[string]interface{}{
+		"updated_at": time.Now(),
+	}
+	if name != nil {
+		updates["name"] = *name
+	}
+	if description != nil {
+		updates["description"] = *description
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.VM{}).
+		Where("id = ?", vmID).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateStartupSection sets the start order and delay of VMs in a vApp in one
+// transaction. It returns gorm.ErrRecordNotFound if any VM is not in the vApp.
+func (r *VMRepository) UpdateStartupSection(ctx context.Context, vappID string, startup []models.VMStartup) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range startup {
+			result := tx.Model(&models.VM{}).
+				Where("id = ? AND vapp_id = ?", item.VMID, vappID).
+				Updates(map[string]interface{}{
+					"start_order": item.Order,
+					"start_delay": item.Delay,
+					"updated_at":  time.Now(),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateTags replaces the tags of a VM
+func (r *VMRepository) UpdateTags(ctx context.Context, vmID string, tags []string) error {
+	vm := models.VM{}
+	vm.SetTags(tags)
+	result := r.db.WithContext(ctx).
+		Model(&models.VM{}).
+		Where("id = ?", vmID).
+		Updates(map[string]interface{}{
+			"tags":       vm.Tags,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateIdleState records when a VM became idle and when its owners were
+// notified about it (for controller). Nil arguments clear the fields.
+func (r *VMRepository) UpdateIdleState(ctx context.Context, vmID string, idleSince, notifiedAt *time.Time) error {
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VM{}).
+			Where("id = ?", vmID).
+			Updates(map[string]interface{}{
+				"idle_since":       idleSince,
+				"idle_notified_at": notifiedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// SetDesiredPowerState records the power state the VM's VirtualMachine should
+// be reconciled toward
+func (r *VMRepository) SetDesiredPowerState(ctx context.Context, vmID string, state string) error {
+	return withRetry(ctx, r.retry, func() error {
+		result := r.db.WithContext(ctx).
+			Model(&models.VM{}).
+			Where("id = ?", vmID).
+			Updates(map[string]interface{}{
+				"desired_power_state": state,
+				"updated_at":          time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// SetRunStrategy records the run strategy requested for a VM. Halted VMs are
+// also given the desired power state POWERED_OFF, so the vm-controller stops them.
+func (r *VMRepository) SetRunStrategy(ctx context.Context, vmID string, strategy string) error {
+	updates := map[string]interface{}{
+		"run_strategy": strategy,
+		"updated_at":   time.Now(),
+	}
+	if strategy == models.VMRunStrategyHalted {
+		updates["desired_power_state"] = models.VMPowerStateOff
+	}
 	result := r.db.WithContext(ctx).
 		Model(&models.VM{}).
 		Where("id = ?", vmID).
@@ -260,3 +538,46 @@ func (r *VMRepository) UpdateVMData(ctx context.Context, vmID string, cpuCount *
 	}
 	return nil
 }
+
+// ListWithDesiredPowerState returns the VMs that have a desired power state and
+// are not being deleted (for controller)
+func (r *VMRepository) ListWithDesiredPowerState(ctx context.Context) ([]models.VM, error) {
+	var vms []models.VM
+	err := r.db.WithContext(ctx).
+		Where("desired_power_state <> ''").
+		Where("status NOT IN ?", []string{"DELETING", "DELETED"}).
+		Where("vm_name <> '' AND namespace <> ''").
+		Order("namespace, vm_name").
+		Find(&vms).Error
+	return vms, err
+}
+
+// DeleteWithContext removes a VM record
+func (r *VMRepository) DeleteWithContext(ctx context.Context, vmID string) error {
+	result := r.db.WithContext(ctx).Where("id = ?", vmID).Delete(&models.VM{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListUpdatedSince returns VMs updated after the given time, or at that time with
+// an ID after afterID, ordered by update time and ID. The vApp and VDC are loaded
+// so callers can resolve the owning organization.
+func (r *VMRepository) ListUpdatedSince(ctx context.Context, since time.Time, afterID string, limit int) ([]models.VM, error) {
+	var vms []models.VM
+	query := r.db.WithContext(ctx).
+		Preload("VApp").
+		Preload("VApp.VDC").
+		Where("updated_at > ? OR (updated_at = ? AND id > ?)", since, since, afterID).
+		Order("updated_at ASC").
+		Order("id ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&vms).Error
+	return vms, err
+}
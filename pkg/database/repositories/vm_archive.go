@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// ErrArchivedVMVAppNotFound indicates an archived VM cannot be restored
+// because its vApp no longer exists
+var ErrArchivedVMVAppNotFound = errors.New("vApp of the archived VM no longer exists")
+
+// archiveBatchSize is how many VMs are archived in one transaction
+const archiveBatchSize = 100
+
+// ArchivedVMFilter restricts archived VM queries; empty fields match everything
+type ArchivedVMFilter struct {
+	VAppID    string
+	Namespace string
+}
+
+// Ensure VMArchiveRepository can be used as the VM archiver store
+var _ services.VMArchiveStore = (*VMArchiveRepository)(nil)
+
+// VMArchiveRepository moves DELETED VM records and their tasks to the
+// archived_vms table and back
+type VMArchiveRepository struct {
+	db *gorm.DB
+}
+
+// NewVMArchiveRepository creates a new VMArchiveRepository
+func NewVMArchiveRepository(db *gorm.DB) *VMArchiveRepository {
+	return &VMArchiveRepository{db: db}
+}
+
+// ArchiveDeletedBefore moves the VMs marked DELETED or deleted through the API
+// before the given time to the archive, with their tasks, returning how many
+// were archived
+func (r *VMArchiveRepository) ArchiveDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	var archived int64
+	for {
+		count, err := r.archiveBatch(ctx, before)
+		archived += count
+		if err != nil || count < archiveBatchSize {
+			return archived, err
+		}
+	}
+}
+
+// archiveBatch archives up to archiveBatchSize VMs in one transaction
+func (r *VMArchiveRepository) archiveBatch(ctx context.Context, before time.Time) (int64, error) {
+	var archived int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var vms []models.VM
+		err := tx.Unscoped().
+			Where("(status = ? AND updated_at < ?) OR deleted_at < ?", "DELETED", before, before).
+			Order("updated_at ASC").Limit(archiveBatchSize).Find(&vms).Error
+		if err != nil || len(vms) == 0 {
+			return err
+		}
+
+		ids := make([]string, 0, len(vms))
+		for _, vm := range vms {
+			ids = append(ids, vm.ID)
+		}
+		var tasks []models.Task
+		if err := tx.Where("owner_id IN ?", ids).Order("start_time ASC").Find(&tasks).Error; err != nil {
+			return err
+		}
+		tasksByVM := make(map[string][]models.Task)
+		for _, task := range tasks {
+			tasksByVM[task.OwnerID] = append(tasksByVM[task.OwnerID], task)
+		}
+
+		now := time.Now()
+		rows := make([]*models.ArchivedVM, 0, len(vms))
+		for i := range vms {
+			row, err := models.NewArchivedVM(&vms[i], tasksByVM[vms[i].ID], now)
+			if err != nil {
+				return fmt.Errorf("failed to archive VM %s: %w", vms[i].ID, err)
+			}
+			rows = append(rows, row)
+		}
+		// Another API server replica may have archived the same VMs
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("owner_id IN ?", ids).Delete(&models.Task{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.VM{})
+		archived = result.RowsAffected
+		return result.Error
+	})
+	return archived, err
+}
+
+// DeleteArchivedBefore prunes VMs archived before the given time, returning
+// how many were removed
+func (r *VMArchiveRepository) DeleteArchivedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("archived_at < ?", before).Delete(&models.ArchivedVM{})
+	return result.RowsAffected, result.Error
+}
+
+// List returns archived VMs matching the filter, most recently deleted first
+func (r *VMArchiveRepository) List(ctx context.Context, filter ArchivedVMFilter, limit, offset int) ([]models.ArchivedVM, error) {
+	var vms []models.ArchivedVM
+	err := r.applyFilter(r.db.WithContext(ctx).Model(&models.ArchivedVM{}), filter).
+		Order("deleted_at DESC").Order("id ASC").
+		Limit(limit).Offset(offset).
+		Find(&vms).Error
+	return vms, err
+}
+
+// Count returns the number of archived VMs matching the filter
+func (r *VMArchiveRepository) Count(ctx context.Context, filter ArchivedVMFilter) (int64, error) {
+	var count int64
+	err := r.applyFilter(r.db.WithContext(ctx).Model(&models.ArchivedVM{}), filter).Count(&count).Error
+	return count, err
+}
+
+func (r *VMArchiveRepository) applyFilter(query *gorm.DB, filter ArchivedVMFilter) *gorm.DB {
+	if filter.VAppID != "" {
+		query = query.Where("vapp_id = ?", filter.VAppID)
+	}
+	if filter.Namespace != "" {
+		query = query.Where("namespace = ?", filter.Namespace)
+	}
+	return query
+}
+
+// GetByID returns an archived VM
+func (r *VMArchiveRepository) GetByID(ctx context.Context, id string) (*models.ArchivedVM, error) {
+	var vm models.ArchivedVM
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&vm).Error; err != nil {
+		return nil, err
+	}
+	return &vm, nil
+}
+
+// Restore moves an archived VM and its tasks back to the vms and tasks tables
+// so they can be viewed through the API again. The VM is restored as DELETED
+// and is archived again once the archive period has passed since the restore.
+func (r *VMArchiveRepository) Restore(ctx context.Context, id string) (*models.VM, error) {
+	var vm *models.VM
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var archived models.ArchivedVM
+		if err := tx.Where("id = ?", id).First(&archived).Error; err != nil {
+			return err
+		}
+		restored, err := archived.VM()
+		if err != nil {
+			return fmt.Errorf("failed to decode archived VM: %w", err)
+		}
+		tasks, err := archived.TaskList()
+		if err != nil {
+			return fmt.Errorf("failed to decode archived tasks: %w", err)
+		}
+
+		var vapps int64
+		if err := tx.Unscoped().Model(&models.VApp{}).Where("id = ?", restored.VAppID).Count(&vapps).Error; err != nil {
+			return err
+		}
+		if vapps == 0 {
+			return ErrArchivedVMVAppNotFound
+		}
+
+		restored.Status = "DELETED"
+		restored.DeletedAt = gorm.DeletedAt{}
+		restored.UpdatedAt = time.Now()
+		if err := tx.Create(restored).Error; err != nil {
+			return err
+		}
+		if len(tasks) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tasks).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&archived).Error; err != nil {
+			return err
+		}
+		vm = restored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
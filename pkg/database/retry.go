@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -60,6 +61,10 @@ func NewConnectionWithRetry(ctx context.Context, cfg *config.Config, retryConfig
 			return db, nil
 		}
 
+		if errors.Is(err, errInvalidConfig) {
+			return nil, err
+		}
+
 		lastErr = err
 		log.Printf("Database connection attempt %d failed: %v", attempt, err)
 
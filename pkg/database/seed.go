@@ -0,0 +1,402 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+// SeedProfile describes a consistent data set for demos or end-to-end tests
+type SeedProfile struct {
+	Name string
+	// SystemAdmins are created without an organization
+	SystemAdmins  []string
+	Organizations []SeedOrganization
+}
+
+// SeedOrganization describes an organization and its contents
+type SeedOrganization struct {
+	Name        string
+	Description string
+	Users       []SeedUser
+	VDCs        []SeedVDC
+	Catalogs    []string
+}
+
+// SeedUser is a user created in its organization with one role
+type SeedUser struct {
+	Username string
+	Role     string
+}
+
+// SeedVDC is a VDC with the vApps deployed into it
+type SeedVDC struct {
+	Name  string
+	VApps []SeedVApp
+}
+
+// SeedVApp is a vApp record with its VMs. No Kubernetes resources back them.
+type SeedVApp struct {
+	Name string
+	VMs  []SeedVM
+}
+
+// SeedVM is a VM record in a seeded vApp
+type SeedVM struct {
+	Name     string
+	Status   string
+	CPUCount int
+	MemoryMB int
+	GuestOS  string
+}
+
+// SeedResult counts the records a seed run created. Records that already
+// existed are not counted.
+type SeedResult struct {
+	Organizations int
+	Users         int
+	VDCs          int
+	Catalogs      int
+	VApps         int
+	VMs           int
+}
+
+var seedProfiles = map[string]SeedProfile{
+	"demo": {
+		Name:         "demo",
+		SystemAdmins: []string{"demo-admin"},
+		Organizations: []SeedOrganization{
+			{
+				Name:        "acme",
+				Description: "Acme Corporation demo organization",
+				Users: []SeedUser{
+					{Username: "acme-admin", Role: models.RoleOrgAdmin},
+					{Username: "acme-alice", Role: models.RoleVAppUser},
+					{Username: "acme-bob", Role: models.RoleVAppUser},
+				},
+				VDCs: []SeedVDC{
+					{Name: "production", VApps: []SeedVApp{
+						{Name: "web-frontend", VMs: []SeedVM{
+							{Name: "web-1", Status: "POWERED_ON", CPUCount: 2, MemoryMB: 4096, GuestOS: "rhel9"},
+							{Name: "web-2", Status: "POWERED_ON", CPUCount: 2, MemoryMB: 4096, GuestOS: "rhel9"},
+						}},
+						{Name: "database", VMs: []SeedVM{
+							{Name: "postgres-1", Status: "POWERED_ON", CPUCount: 4, MemoryMB: 8192, GuestOS: "rhel9"},
+						}},
+					}},
+					{Name: "development", VApps: []SeedVApp{
+						{Name: "sandbox", VMs: []SeedVM{
+							{Name: "dev-workstation", Status: "POWERED_OFF", CPUCount: 2, MemoryMB: 2048, GuestOS: "fedora"},
+						}},
+					}},
+				},
+				Catalogs: []string{"acme-templates"},
+			},
+			{
+				Name:        "globex",
+				Description: "Globex demo organization",
+				Users: []SeedUser{
+					{Username: "globex-admin", Role: models.RoleOrgAdmin},
+					{Username: "globex-carol", Role: models.RoleVAppUser},
+				},
+				VDCs: []SeedVDC{
+					{Name: "default", VApps: []SeedVApp{
+						{Name: "build-farm", VMs: []SeedVM{
+							{Name: "builder-1", Status: "POWERED_ON", CPUCount: 4, MemoryMB: 4096, GuestOS: "centos-stream9"},
+							{Name: "builder-2", Status: "POWERED_OFF", CPUCount: 4, MemoryMB: 4096, GuestOS: "centos-stream9"},
+						}},
+					}},
+				},
+				Catalogs: []string{"globex-templates"},
+			},
+		},
+	},
+	"e2e": {
+		Name:         "e2e",
+		SystemAdmins: []string{"e2e-sysadmin"},
+		Organizations: []SeedOrganization{
+			{
+				Name:        "e2e-org",
+				Description: "End-to-end test organization",
+				Users: []SeedUser{
+					{Username: "e2e-orgadmin", Role: models.RoleOrgAdmin},
+					{Username: "e2e-user", Role: models.RoleVAppUser},
+				},
+				VDCs: []SeedVDC{
+					{Name: "e2e-vdc", VApps: []SeedVApp{
+						{Name: "e2e-vapp", VMs: []SeedVM{
+							{Name: "e2e-vm-on", Status: "POWERED_ON", CPUCount: 1, MemoryMB: 1024, GuestOS: "fedora"},
+							{Name: "e2e-vm-off", Status: "POWERED_OFF", CPUCount: 1, MemoryMB: 1024, GuestOS: "fedora"},
+						}},
+					}},
+				},
+				Catalogs: []string{"e2e-catalog"},
+			},
+			{
+				// A second organization for tenant isolation checks
+				Name:        "e2e-other-org",
+				Description: "End-to-end test organization for isolation checks",
+				Users: []SeedUser{
+					{Username: "e2e-other-user", Role: models.RoleVAppUser},
+				},
+				VDCs:     []SeedVDC{{Name: "e2e-other-vdc"}},
+				Catalogs: []string{"e2e-other-catalog"},
+			},
+		},
+	},
+}
+
+// SeedProfileNames returns the names of the available seed profiles
+func SeedProfileNames() []string {
+	names := make([]string, 0, len(seedProfiles))
+	for name := range seedProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetSeedProfile returns the named seed profile
+func GetSeedProfile(name string) (SeedProfile, bool) {
+	profile, ok := seedProfiles[name]
+	return profile, ok
+}
+
+// Seed creates the named profile's data set through the repositories in a
+// single transaction. Records that already exist by name are reused, so seeding
+// the same profile again is a no-op. Every seeded user gets the given password.
+func (db *DB) Seed(ctx context.Context, profileName, password string) (*SeedResult, error) {
+	profile, ok := GetSeedProfile(profileName)
+	if !ok {
+		return nil, fmt.Errorf("unknown seed profile %q", profileName)
+	}
+	if password == "" {
+		return nil, errors.New("seed password must not be empty")
+	}
+
+	log.Printf("Seeding %s profile...", profile.Name)
+	result := &SeedResult{}
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		s := &seeder{
+			tx:       tx,
+			ctx:      ctx,
+			password: password,
+			result:   result,
+			roles:    make(map[string]string),
+		}
+		return s.seed(profile)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Seeded %s profile: %+v", profile.Name, *result)
+	return result, nil
+}
+
+// seeder holds the state of a single seed transaction
+type seeder struct {
+	tx       *gorm.DB
+	ctx      context.Context
+	password string
+	result   *SeedResult
+	// roles maps role names to IDs
+	roles map[string]string
+}
+
+func (s *seeder) seed(profile SeedProfile) error {
+	roleRepo := repositories.NewRoleRepository(s.tx)
+	if err := roleRepo.CreateDefaultRoles(); err != nil {
+		return fmt.Errorf("failed to create default roles: %w", err)
+	}
+	for _, name := range []string{models.RoleSystemAdmin, models.RoleOrgAdmin, models.RoleVAppUser} {
+		role, err := roleRepo.GetByName(name)
+		if err != nil {
+			return fmt.Errorf("failed to load role %s: %w", name, err)
+		}
+		s.roles[name] = role.ID
+	}
+
+	for _, username := range profile.SystemAdmins {
+		if err := s.seedUser(SeedUser{Username: username, Role: models.RoleSystemAdmin}, nil); err != nil {
+			return err
+		}
+	}
+	for _, org := range profile.Organizations {
+		if err := s.seedOrganization(org); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *seeder) seedOrganization(spec SeedOrganization) error {
+	orgRepo := repositories.NewOrganizationRepository(s.tx)
+	org, err := orgRepo.GetByName(spec.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		org = &models.Organization{Name: spec.Name, Description: spec.Description, IsEnabled: true}
+		if err = orgRepo.Create(org); err == nil {
+			s.result.Organizations++
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to seed organization %s: %w", spec.Name, err)
+	}
+
+	for _, user := range spec.Users {
+		if err := s.seedUser(user, &org.ID); err != nil {
+			return err
+		}
+	}
+	for _, vdc := range spec.VDCs {
+		if err := s.seedVDC(org, vdc); err != nil {
+			return err
+		}
+	}
+	for _, name := range spec.Catalogs {
+		if err := s.seedCatalog(org, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *seeder) seedUser(spec SeedUser, orgID *string) error {
+	userRepo := repositories.NewUserRepository(s.tx)
+	_, err := userRepo.GetByUsername(spec.Username)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to look up user %s: %w", spec.Username, err)
+	}
+
+	user := &models.User{
+		Username:       spec.Username,
+		Email:          spec.Username + "@example.com",
+		FullName:       spec.Username,
+		Description:    "Seeded user",
+		Enabled:        true,
+		OrganizationID: orgID,
+	}
+	if err := user.SetPassword(s.password); err != nil {
+		return fmt.Errorf("failed to hash password for user %s: %w", spec.Username, err)
+	}
+	if err := userRepo.CreateTx(s.tx, user); err != nil {
+		return fmt.Errorf("failed to create user %s: %w", spec.Username, err)
+	}
+	if err := userRepo.AssignRolesTx(s.tx, user.ID, []string{s.roles[spec.Role]}); err != nil {
+		return fmt.Errorf("failed to assign role %s to user %s: %w", spec.Role, spec.Username, err)
+	}
+	s.result.Users++
+	return nil
+}
+
+func (s *seeder) seedVDC(org *models.Organization, spec SeedVDC) error {
+	vdcRepo := repositories.NewVDCRepository(s.tx)
+	vdc, err := vdcRepo.GetByOrgAndName(org.ID, spec.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		vdc = &models.VDC{
+			Name:            spec.Name,
+			Description:     fmt.Sprintf("%s VDC for %s", spec.Name, org.Name),
+			OrganizationID:  org.ID,
+			AllocationModel: models.PayAsYouGo,
+			NicQuota:        100,
+			NetworkQuota:    50,
+			IsThinProvision: true,
+			IsEnabled:       true,
+		}
+		if err = vdcRepo.Create(vdc); err == nil {
+			s.result.VDCs++
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to seed VDC %s: %w", spec.Name, err)
+	}
+
+	for _, vapp := range spec.VApps {
+		if err := s.seedVApp(vdc, vapp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *seeder) seedVApp(vdc *models.VDC, spec SeedVApp) error {
+	vappRepo := repositories.NewVAppRepository(s.tx)
+	vapp, err := vappRepo.GetByNameInVDC(s.ctx, vdc.ID, spec.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		vapp = &models.VApp{
+			Name:        spec.Name,
+			VDCID:       vdc.ID,
+			Status:      models.VAppStatusDeployed,
+			HealthState: models.HealthStateHealthy,
+			Description: "Seeded vApp",
+		}
+		if err = vappRepo.CreateVApp(s.ctx, vapp); err == nil {
+			s.result.VApps++
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to seed vApp %s: %w", spec.Name, err)
+	}
+
+	vmRepo := repositories.NewVMRepository(s.tx)
+	for _, vmSpec := range spec.VMs {
+		_, err := vmRepo.GetByVAppAndVMName(s.ctx, vapp.ID, vmSpec.Name)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to look up VM %s: %w", vmSpec.Name, err)
+		}
+
+		cpuCount, memoryMB := vmSpec.CPUCount, vmSpec.MemoryMB
+		vm := &models.VM{
+			Name:        vmSpec.Name,
+			VAppID:      vapp.ID,
+			VMName:      vmSpec.Name,
+			Namespace:   vdc.Namespace,
+			Status:      vmSpec.Status,
+			HealthState: models.HealthStateHealthy,
+			CPUCount:    &cpuCount,
+			MemoryMB:    &memoryMB,
+			GuestOS:     vmSpec.GuestOS,
+			SourceType:  models.VMSourceManual,
+		}
+		if err := vmRepo.CreateVM(s.ctx, vm); err != nil {
+			return fmt.Errorf("failed to create VM %s: %w", vmSpec.Name, err)
+		}
+		s.result.VMs++
+	}
+	return nil
+}
+
+func (s *seeder) seedCatalog(org *models.Organization, name string) error {
+	catalogRepo := repositories.NewCatalogRepository(s.tx)
+	_, err := catalogRepo.GetByOrgAndName(org.ID, name)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to look up catalog %s: %w", name, err)
+	}
+
+	catalog := &models.Catalog{
+		Name:           name,
+		Description:    fmt.Sprintf("Templates for %s", org.Name),
+		OrganizationID: org.ID,
+		IsLocal:        true,
+	}
+	if err := catalogRepo.Create(catalog); err != nil {
+		return fmt.Errorf("failed to create catalog %s: %w", name, err)
+	}
+	s.result.Catalogs++
+	return nil
+}
@@ -0,0 +1,785 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+
+	"gorm.io/gorm"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+// ErrInvalidTenantSpec is returned for tenant specs that cannot be applied as written
+var ErrInvalidTenantSpec = errors.New("invalid tenant spec")
+
+// ErrTenantConflict is returned when applying a tenant spec would change
+// records it may not, such as pruning a VDC that still has vApps
+var ErrTenantConflict = errors.New("tenant spec conflicts with existing records")
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// TenantSpec declares organizations with their users, VDCs and catalogs, so
+// tenancy can be kept in Git and applied like Kubernetes manifests. The
+// Provider organization and System Administrators are not managed by it.
+type TenantSpec struct {
+	Organizations []TenantOrganization `json:"organizations"`
+}
+
+// TenantOrganization declares an organization and its contents
+type TenantOrganization struct {
+	Name string `json:"name"`
+	// DisplayName defaults to the name
+	DisplayName string `json:"displayName,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Enabled defaults to true
+	Enabled  *bool           `json:"enabled,omitempty"`
+	Users    []TenantUser    `json:"users,omitempty"`
+	VDCs     []TenantVDC     `json:"vdcs,omitempty"`
+	Catalogs []TenantCatalog `json:"catalogs,omitempty"`
+}
+
+// TenantUser declares a user of an organization and the roles they hold
+type TenantUser struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	// FullName defaults to the username
+	FullName string   `json:"fullName,omitempty"`
+	Roles    []string `json:"roles"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+	// Password is only used to create the user; applying never changes the
+	// password of an existing user
+	Password string `json:"password,omitempty"`
+}
+
+// TenantVDC declares a VDC of an organization
+type TenantVDC struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// AllocationModel defaults to PayAsYouGo
+	AllocationModel models.AllocationModel `json:"allocationModel,omitempty"`
+	// ComputeCapacity is left unchanged when omitted
+	ComputeCapacity *models.ComputeCapacity `json:"computeCapacity,omitempty"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// TenantCatalog declares a catalog of an organization
+type TenantCatalog struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Published   bool   `json:"published,omitempty"`
+}
+
+// Actions of tenant changes
+const (
+	TenantActionCreate = "create"
+	TenantActionUpdate = "update"
+	TenantActionDelete = "delete"
+)
+
+// Kinds of records tenant changes apply to
+const (
+	TenantKindOrganization = "Organization"
+	TenantKindUser         = "User"
+	TenantKindVDC          = "VDC"
+	TenantKindCatalog      = "Catalog"
+)
+
+// TenantChange is one change applying a tenant spec makes
+type TenantChange struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	// Name is the organization name, or <organization>/<name> for its contents
+	Name string `json:"name"`
+	// Fields lists the fields an update changes
+	Fields []string `json:"fields,omitempty"`
+}
+
+// TenantNamespaceError is a VDC namespace that could not be created or deleted
+// after the spec was applied
+type TenantNamespaceError struct {
+	Action string `json:"action"`
+	// VDC is <organization>/<name>
+	VDC       string `json:"vdc"`
+	Namespace string `json:"namespace"`
+	Error     string `json:"error"`
+}
+
+// TenantApplyOptions control how a tenant spec is applied
+type TenantApplyOptions struct {
+	// DryRun computes the changes without keeping them
+	DryRun bool
+	// Prune deletes organizations, and users, VDCs and catalogs of declared
+	// organizations, that the spec does not declare
+	Prune bool
+	// InitialPassword is given to new users whose spec has no password
+	InitialPassword string
+	// NamespaceInUse, when set, rejects namespace names for new VDCs that are
+	// taken outside the database, such as existing cluster namespaces
+	NamespaceInUse models.NamespaceInUse
+}
+
+// TenantApplyResult lists the changes applying a tenant spec made, or would
+// make on a dry run
+type TenantApplyResult struct {
+	DryRun  bool           `json:"dryRun"`
+	Changes []TenantChange `json:"changes"`
+	// NamespaceErrors lists the VDC namespaces the API server failed to create
+	// or delete; the database changes are kept
+	NamespaceErrors []TenantNamespaceError `json:"namespaceErrors,omitempty"`
+	// CreatedVDCs and DeletedVDCs need their namespaces created or deleted
+	CreatedVDCs []models.VDC `json:"-"`
+	DeletedVDCs []models.VDC `json:"-"`
+}
+
+// ParseTenantSpec decodes a tenant spec from YAML or JSON. Unknown fields are
+// rejected, so typos are not silently ignored.
+func ParseTenantSpec(data []byte) (*TenantSpec, error) {
+	var spec TenantSpec
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTenantSpec, err)
+	}
+	return &spec, nil
+}
+
+// Validate checks that names are set and unique, and that roles and
+// allocation models are known
+func (s *TenantSpec) Validate() error {
+	var errs []error
+	orgNames := make(map[string]bool)
+	usernames := make(map[string]bool)
+	for _, org := range s.Organizations {
+		switch {
+		case org.Name == "":
+			errs = append(errs, errors.New("organization name is required"))
+		case org.Name == models.DefaultOrgName:
+			errs = append(errs, fmt.Errorf("organization %s is not managed by tenant specs", org.Name))
+		case orgNames[org.Name]:
+			errs = append(errs, fmt.Errorf("organization %s is declared twice", org.Name))
+		}
+		orgNames[org.Name] = true
+
+		for _, user := range org.Users {
+			switch {
+			case user.Username == "":
+				errs = append(errs, fmt.Errorf("organization %s: username is required", org.Name))
+			case usernames[user.Username]:
+				errs = append(errs, fmt.Errorf("user %s is declared twice", user.Username))
+			}
+			usernames[user.Username] = true
+			if user.Email == "" {
+				errs = append(errs, fmt.Errorf("user %s: email is required", user.Username))
+			}
+			for _, role := range user.Roles {
+				if role != models.RoleOrgAdmin && role != models.RoleVAppUser {
+					errs = append(errs, fmt.Errorf("user %s: role must be %q or %q, not %q",
+						user.Username, models.RoleOrgAdmin, models.RoleVAppUser, role))
+				}
+			}
+		}
+
+		vdcNames := make(map[string]bool)
+		for _, vdc := range org.VDCs {
+			switch {
+			case vdc.Name == "":
+				errs = append(errs, fmt.Errorf("organization %s: VDC name is required", org.Name))
+			case vdcNames[vdc.Name]:
+				errs = append(errs, fmt.Errorf("VDC %s/%s is declared twice", org.Name, vdc.Name))
+			}
+			vdcNames[vdc.Name] = true
+			if vdc.AllocationModel != "" && !vdc.AllocationModel.Valid() {
+				errs = append(errs, fmt.Errorf("VDC %s/%s: invalid allocation model %q", org.Name, vdc.Name, vdc.AllocationModel))
+			}
+		}
+
+		catalogNames := make(map[string]bool)
+		for _, catalog := range org.Catalogs {
+			switch {
+			case catalog.Name == "":
+				errs = append(errs, fmt.Errorf("organization %s: catalog name is required", org.Name))
+			case catalogNames[catalog.Name]:
+				errs = append(errs, fmt.Errorf("catalog %s/%s is declared twice", org.Name, catalog.Name))
+			}
+			catalogNames[catalog.Name] = true
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidTenantSpec, errors.Join(errs...))
+	}
+	return nil
+}
+
+// ApplyTenants makes the database match the spec in a single transaction:
+// declared records are created or updated, and with opts.Prune undeclared ones
+// are deleted. A dry run rolls the transaction back, so it reports exactly the
+// changes a real run would make, including its errors.
+func (db *DB) ApplyTenants(ctx context.Context, spec *TenantSpec, opts TenantApplyOptions) (*TenantApplyResult, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	result := &TenantApplyResult{DryRun: opts.DryRun, Changes: []TenantChange{}}
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		a := &tenantApplier{
+			tx:     tx,
+			ctx:    ctx,
+			opts:   opts,
+			result: result,
+			roles:  make(map[string]string),
+		}
+		if err := a.apply(spec); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	if !opts.DryRun {
+		log.Printf("Applied tenant spec: %d changes", len(result.Changes))
+	}
+	return result, nil
+}
+
+// tenantApplier holds the state of a single apply transaction
+type tenantApplier struct {
+	tx     *gorm.DB
+	ctx    context.Context
+	opts   TenantApplyOptions
+	result *TenantApplyResult
+	// roles maps role names to IDs
+	roles map[string]string
+}
+
+func (a *tenantApplier) record(action, kind, name string, fields ...string) {
+	a.result.Changes = append(a.result.Changes, TenantChange{Action: action, Kind: kind, Name: name, Fields: fields})
+}
+
+func (a *tenantApplier) apply(spec *TenantSpec) error {
+	roleRepo := repositories.NewRoleRepository(a.tx)
+	for _, name := range []string{models.RoleOrgAdmin, models.RoleVAppUser} {
+		role, err := roleRepo.GetByName(name)
+		if err != nil {
+			return fmt.Errorf("failed to load role %s: %w", name, err)
+		}
+		a.roles[name] = role.ID
+	}
+
+	declared := make(map[string]bool)
+	applied := make([]*models.Organization, len(spec.Organizations))
+	for i, org := range spec.Organizations {
+		declared[org.Name] = true
+		var err error
+		if applied[i], err = a.applyOrganization(org); err != nil {
+			return err
+		}
+	}
+	if !a.opts.Prune {
+		return nil
+	}
+
+	// Organizations are pruned once every one is applied, so users moved to an
+	// organization declared later are not deleted from their old one first
+	for i, org := range spec.Organizations {
+		if err := a.pruneContents(applied[i], org); err != nil {
+			return err
+		}
+	}
+
+	var orgs []models.Organization
+	if err := a.tx.Order("name").Find(&orgs).Error; err != nil {
+		return fmt.Errorf("failed to list organizations: %w", err)
+	}
+	for i := range orgs {
+		if declared[orgs[i].Name] || orgs[i].Name == models.DefaultOrgName {
+			continue
+		}
+		if err := a.pruneOrganization(&orgs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *tenantApplier) applyOrganization(spec TenantOrganization) (*models.Organization, error) {
+	orgRepo := repositories.NewOrganizationRepository(a.tx)
+	displayName := spec.DisplayName
+	if displayName == "" {
+		displayName = spec.Name
+	}
+	enabled := spec.Enabled == nil || *spec.Enabled
+
+	org, err := orgRepo.GetByName(spec.Name)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		org = &models.Organization{Name: spec.Name, DisplayName: displayName, Description: spec.Description, IsEnabled: true}
+		if err := orgRepo.Create(org); err != nil {
+			return nil, fmt.Errorf("failed to create organization %s: %w", spec.Name, err)
+		}
+		// IsEnabled defaults to true, so false is only stored by an update
+		if !enabled {
+			if err := a.tx.Model(org).Update("is_enabled", false).Error; err != nil {
+				return nil, fmt.Errorf("failed to disable organization %s: %w", spec.Name, err)
+			}
+		}
+		a.record(TenantActionCreate, TenantKindOrganization, spec.Name)
+	case err != nil:
+		return nil, fmt.Errorf("failed to look up organization %s: %w", spec.Name, err)
+	default:
+		updates := map[string]interface{}{}
+		var fields []string
+		if org.DisplayName != displayName {
+			updates["display_name"], fields = displayName, append(fields, "displayName")
+		}
+		if org.Description != spec.Description {
+			updates["description"], fields = spec.Description, append(fields, "description")
+		}
+		if org.IsEnabled != enabled {
+			updates["is_enabled"], fields = enabled, append(fields, "enabled")
+		}
+		if len(updates) > 0 {
+			if err := a.tx.Model(org).Updates(updates).Error; err != nil {
+				return nil, fmt.Errorf("failed to update organization %s: %w", spec.Name, err)
+			}
+			a.record(TenantActionUpdate, TenantKindOrganization, spec.Name, fields...)
+		}
+	}
+
+	for _, user := range spec.Users {
+		if err := a.applyUser(org, user); err != nil {
+			return nil, err
+		}
+	}
+	for _, vdc := range spec.VDCs {
+		if err := a.applyVDC(org, vdc); err != nil {
+			return nil, err
+		}
+	}
+	for _, catalog := range spec.Catalogs {
+		if err := a.applyCatalog(org, catalog); err != nil {
+			return nil, err
+		}
+	}
+	return org, nil
+}
+
+func (a *tenantApplier) applyUser(org *models.Organization, spec TenantUser) error {
+	userRepo := repositories.NewUserRepository(a.tx)
+	name := org.Name + "/" + spec.Username
+	fullName := spec.FullName
+	if fullName == "" {
+		fullName = spec.Username
+	}
+	enabled := spec.Enabled == nil || *spec.Enabled
+	roleIDs := make([]string, 0, len(spec.Roles))
+	for _, role := range spec.Roles {
+		roleIDs = append(roleIDs, a.roles[role])
+	}
+
+	existing, err := userRepo.GetByUsername(spec.Username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		password := spec.Password
+		if password == "" {
+			password = a.opts.InitialPassword
+		}
+		if password == "" {
+			return fmt.Errorf("%w: user %s needs a password to be created", ErrInvalidTenantSpec, spec.Username)
+		}
+		user := &models.User{
+			Username:       spec.Username,
+			Email:          spec.Email,
+			FullName:       fullName,
+			Enabled:        true,
+			OrganizationID: &org.ID,
+		}
+		if err := user.SetPassword(password); err != nil {
+			return fmt.Errorf("failed to hash password for user %s: %w", spec.Username, err)
+		}
+		if err := userRepo.CreateTx(a.tx, user); err != nil {
+			return fmt.Errorf("failed to create user %s: %w", spec.Username, err)
+		}
+		if !enabled {
+			if err := a.tx.Model(user).Update("enabled", false).Error; err != nil {
+				return fmt.Errorf("failed to disable user %s: %w", spec.Username, err)
+			}
+		}
+		if err := userRepo.AssignRolesTx(a.tx, user.ID, roleIDs); err != nil {
+			return fmt.Errorf("failed to assign roles to user %s: %w", spec.Username, err)
+		}
+		a.record(TenantActionCreate, TenantKindUser, name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up user %s: %w", spec.Username, err)
+	}
+
+	user, err := userRepo.GetWithRoles(existing.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load roles of user %s: %w", spec.Username, err)
+	}
+	currentRoles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		if role.IsSystemAdmin() {
+			return fmt.Errorf("%w: user %s is a System Administrator", ErrTenantConflict, spec.Username)
+		}
+		currentRoles = append(currentRoles, role.Name)
+	}
+
+	updates := map[string]interface{}{}
+	var fields []string
+	if user.Email != spec.Email {
+		updates["email"], fields = spec.Email, append(fields, "email")
+	}
+	if user.FullName != fullName {
+		updates["full_name"], fields = fullName, append(fields, "fullName")
+	}
+	if user.Enabled != enabled {
+		updates["enabled"], fields = enabled, append(fields, "enabled")
+	}
+	moved := user.OrganizationID == nil || *user.OrganizationID != org.ID
+	if moved {
+		fields = append(fields, "organization")
+	}
+	rolesChanged := !sameNames(currentRoles, spec.Roles)
+	if rolesChanged {
+		fields = append(fields, "roles")
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if user.GroupSynced && (moved || rolesChanged) {
+		return fmt.Errorf("%w: the organization and roles of user %s are managed by OpenShift Group sync", ErrTenantConflict, spec.Username)
+	}
+
+	if len(updates) > 0 {
+		if err := a.tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update user %s: %w", spec.Username, err)
+		}
+	}
+	if moved || rolesChanged {
+		if err := userRepo.SetGroupMembership(user.ID, &org.ID, roleIDs, false); err != nil {
+			return fmt.Errorf("failed to update organization and roles of user %s: %w", spec.Username, err)
+		}
+	}
+	a.record(TenantActionUpdate, TenantKindUser, name, fields...)
+	return nil
+}
+
+func (a *tenantApplier) applyVDC(org *models.Organization, spec TenantVDC) error {
+	vdcRepo := repositories.NewVDCRepository(a.tx)
+	name := org.Name + "/" + spec.Name
+	allocationModel := spec.AllocationModel
+	if allocationModel == "" {
+		allocationModel = models.PayAsYouGo
+	}
+	enabled := spec.Enabled == nil || *spec.Enabled
+
+	vdc, err := vdcRepo.GetByOrgAndName(org.ID, spec.Name)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		vdc = &models.VDC{
+			Name:            spec.Name,
+			Description:     spec.Description,
+			OrganizationID:  org.ID,
+			AllocationModel: allocationModel,
+			NicQuota:        100,
+			NetworkQuota:    50,
+			IsEnabled:       true,
+		}
+		if spec.ComputeCapacity != nil {
+			vdc.SetComputeCapacity(*spec.ComputeCapacity)
+		}
+		namespace, err := vdcRepo.GenerateNamespace(a.ctx, org.Name, spec.Name, a.opts.NamespaceInUse)
+		if err != nil {
+			return fmt.Errorf("failed to generate namespace for VDC %s: %w", name, err)
+		}
+		vdc.Namespace = namespace
+		if err := vdcRepo.Create(vdc); err != nil {
+			return fmt.Errorf("failed to create VDC %s: %w", name, err)
+		}
+		if !enabled {
+			if err := a.tx.Model(vdc).Update("is_enabled", false).Error; err != nil {
+				return fmt.Errorf("failed to disable VDC %s: %w", name, err)
+			}
+			vdc.IsEnabled = false
+		}
+		vdc.Organization = org
+		a.result.CreatedVDCs = append(a.result.CreatedVDCs, *vdc)
+		a.record(TenantActionCreate, TenantKindVDC, name)
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to look up VDC %s: %w", name, err)
+	}
+
+	updates := map[string]interface{}{}
+	var fields []string
+	if vdc.Description != spec.Description {
+		updates["description"], fields = spec.Description, append(fields, "description")
+	}
+	if vdc.AllocationModel != allocationModel {
+		updates["allocation_model"], fields = allocationModel, append(fields, "allocationModel")
+	}
+	if vdc.IsEnabled != enabled {
+		updates["is_enabled"], fields = enabled, append(fields, "enabled")
+	}
+	if spec.ComputeCapacity != nil {
+		updated := *vdc
+		updated.SetComputeCapacity(*spec.ComputeCapacity)
+		if updated.ComputeCapacity() != vdc.ComputeCapacity() {
+			updates["cpu_allocated"] = updated.CPUAllocated
+			updates["cpu_limit"] = updated.CPULimit
+			updates["cpu_units"] = updated.CPUUnits
+			updates["memory_allocated"] = updated.MemoryAllocated
+			updates["memory_limit"] = updated.MemoryLimit
+			updates["memory_units"] = updated.MemoryUnits
+			fields = append(fields, "computeCapacity")
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := a.tx.Model(vdc).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update VDC %s: %w", name, err)
+	}
+	a.record(TenantActionUpdate, TenantKindVDC, name, fields...)
+	return nil
+}
+
+func (a *tenantApplier) applyCatalog(org *models.Organization, spec TenantCatalog) error {
+	catalogRepo := repositories.NewCatalogRepository(a.tx)
+	name := org.Name + "/" + spec.Name
+
+	catalog, err := catalogRepo.GetByOrgAndName(org.ID, spec.Name)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		catalog = &models.Catalog{
+			Name:           spec.Name,
+			Description:    spec.Description,
+			OrganizationID: org.ID,
+			IsPublished:    spec.Published,
+			IsLocal:        true,
+		}
+		if err := catalogRepo.Create(catalog); err != nil {
+			return fmt.Errorf("failed to create catalog %s: %w", name, err)
+		}
+		a.record(TenantActionCreate, TenantKindCatalog, name)
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to look up catalog %s: %w", name, err)
+	}
+
+	updates := map[string]interface{}{}
+	var fields []string
+	if catalog.Description != spec.Description {
+		updates["description"], fields = spec.Description, append(fields, "description")
+	}
+	if catalog.IsPublished != spec.Published {
+		updates["is_published"], fields = spec.Published, append(fields, "published")
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := a.tx.Model(catalog).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update catalog %s: %w", name, err)
+	}
+	a.record(TenantActionUpdate, TenantKindCatalog, name, fields...)
+	return nil
+}
+
+// pruneContents deletes the users, VDCs and catalogs of an organization that
+// its spec does not declare. Users managed by OpenShift Group sync are kept.
+func (a *tenantApplier) pruneContents(org *models.Organization, spec TenantOrganization) error {
+	keep := make(map[string]bool)
+	for _, user := range spec.Users {
+		keep[TenantKindUser+"/"+user.Username] = true
+	}
+	for _, vdc := range spec.VDCs {
+		keep[TenantKindVDC+"/"+vdc.Name] = true
+	}
+	for _, catalog := range spec.Catalogs {
+		keep[TenantKindCatalog+"/"+catalog.Name] = true
+	}
+
+	var users []models.User
+	if err := a.tx.Where("organization_id = ? AND group_synced = ?", org.ID, false).Order("username").Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to list users of organization %s: %w", org.Name, err)
+	}
+	for _, user := range users {
+		if keep[TenantKindUser+"/"+user.Username] {
+			continue
+		}
+		if err := repositories.NewUserRepository(a.tx).Delete(user.ID); err != nil {
+			return fmt.Errorf("failed to delete user %s: %w", user.Username, err)
+		}
+		a.record(TenantActionDelete, TenantKindUser, org.Name+"/"+user.Username)
+	}
+
+	var vdcs []models.VDC
+	if err := a.tx.Where("organization_id = ?", org.ID).Order("name").Find(&vdcs).Error; err != nil {
+		return fmt.Errorf("failed to list VDCs of organization %s: %w", org.Name, err)
+	}
+	for i := range vdcs {
+		if keep[TenantKindVDC+"/"+vdcs[i].Name] {
+			continue
+		}
+		if err := a.deleteVDC(org, &vdcs[i]); err != nil {
+			return err
+		}
+	}
+
+	var catalogs []models.Catalog
+	if err := a.tx.Where("organization_id = ?", org.ID).Order("name").Find(&catalogs).Error; err != nil {
+		return fmt.Errorf("failed to list catalogs of organization %s: %w", org.Name, err)
+	}
+	for _, catalog := range catalogs {
+		if keep[TenantKindCatalog+"/"+catalog.Name] {
+			continue
+		}
+		if err := a.deleteCatalog(org, &catalog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneOrganization deletes an undeclared organization with everything in it
+func (a *tenantApplier) pruneOrganization(org *models.Organization) error {
+	children, err := repositories.NewOrganizationRepository(a.tx).CountChildren(a.ctx, org.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count child organizations of %s: %w", org.Name, err)
+	}
+	if children > 0 {
+		return fmt.Errorf("%w: cannot prune organization %s with child organizations", ErrTenantConflict, org.Name)
+	}
+	if err := a.pruneContents(org, TenantOrganization{Name: org.Name}); err != nil {
+		return err
+	}
+	if err := repositories.NewOrganizationRepository(a.tx).Delete(org.ID); err != nil {
+		return fmt.Errorf("failed to delete organization %s: %w", org.Name, err)
+	}
+	a.record(TenantActionDelete, TenantKindOrganization, org.Name)
+	return nil
+}
+
+func (a *tenantApplier) deleteVDC(org *models.Organization, vdc *models.VDC) error {
+	name := org.Name + "/" + vdc.Name
+	hasVApps, err := repositories.NewVDCRepository(a.tx).HasDependentVApps(vdc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check vApps of VDC %s: %w", name, err)
+	}
+	if hasVApps {
+		return fmt.Errorf("%w: cannot prune VDC %s, it still has vApps", ErrTenantConflict, name)
+	}
+	if err := a.tx.Where("id = ?", vdc.ID).Delete(&models.VDC{}).Error; err != nil {
+		return fmt.Errorf("failed to delete VDC %s: %w", name, err)
+	}
+	vdc.Organization = org
+	a.result.DeletedVDCs = append(a.result.DeletedVDCs, *vdc)
+	a.record(TenantActionDelete, TenantKindVDC, name)
+	return nil
+}
+
+func (a *tenantApplier) deleteCatalog(org *models.Organization, catalog *models.Catalog) error {
+	name := org.Name + "/" + catalog.Name
+	if err := repositories.NewCatalogRepository(a.tx).DeleteWithValidation(catalog.ID); err != nil {
+		if errors.Is(err, repositories.ErrCatalogHasDependencies) {
+			return fmt.Errorf("%w: cannot prune catalog %s, it still has vApp templates", ErrTenantConflict, name)
+		}
+		return fmt.Errorf("failed to delete catalog %s: %w", name, err)
+	}
+	a.record(TenantActionDelete, TenantKindCatalog, name)
+	return nil
+}
+
+// ExportTenants describes the organizations in the database, except the
+// Provider organization, as a tenant spec. Passwords are not exported.
+func (db *DB) ExportTenants(ctx context.Context) (*TenantSpec, error) {
+	tx := db.DB.WithContext(ctx)
+	var orgs []models.Organization
+	if err := tx.Where("name <> ?", models.DefaultOrgName).Order("name").Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	spec := &TenantSpec{Organizations: make([]TenantOrganization, 0, len(orgs))}
+	for _, org := range orgs {
+		out := TenantOrganization{Name: org.Name, Description: org.Description, Enabled: disabledPtr(org.IsEnabled)}
+		if org.DisplayName != org.Name {
+			out.DisplayName = org.DisplayName
+		}
+
+		var users []models.User
+		if err := tx.Preload("Roles").Where("organization_id = ?", org.ID).Order("username").Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to list users of organization %s: %w", org.Name, err)
+		}
+		for _, user := range users {
+			roles := make([]string, 0, len(user.Roles))
+			for _, role := range user.Roles {
+				roles = append(roles, role.Name)
+			}
+			sort.Strings(roles)
+			spec := TenantUser{Username: user.Username, Email: user.Email, Roles: roles, Enabled: disabledPtr(user.Enabled)}
+			if user.FullName != user.Username {
+				spec.FullName = user.FullName
+			}
+			out.Users = append(out.Users, spec)
+		}
+
+		var vdcs []models.VDC
+		if err := tx.Where("organization_id = ?", org.ID).Order("name").Find(&vdcs).Error; err != nil {
+			return nil, fmt.Errorf("failed to list VDCs of organization %s: %w", org.Name, err)
+		}
+		for _, vdc := range vdcs {
+			capacity := vdc.ComputeCapacity()
+			out.VDCs = append(out.VDCs, TenantVDC{
+				Name:            vdc.Name,
+				Description:     vdc.Description,
+				AllocationModel: vdc.AllocationModel,
+				ComputeCapacity: &capacity,
+				Enabled:         disabledPtr(vdc.IsEnabled),
+			})
+		}
+
+		var catalogs []models.Catalog
+		if err := tx.Where("organization_id = ?", org.ID).Order("name").Find(&catalogs).Error; err != nil {
+			return nil, fmt.Errorf("failed to list catalogs of organization %s: %w", org.Name, err)
+		}
+		for _, catalog := range catalogs {
+			out.Catalogs = append(out.Catalogs, TenantCatalog{Name: catalog.Name, Description: catalog.Description, Published: catalog.IsPublished})
+		}
+		spec.Organizations = append(spec.Organizations, out)
+	}
+	return spec, nil
+}
+
+// MarshalTenantSpec encodes a tenant spec as YAML
+func MarshalTenantSpec(spec *TenantSpec) ([]byte, error) {
+	return yaml.Marshal(spec)
+}
+
+// disabledPtr returns a pointer to false for disabled records, leaving the
+// enabled default implicit
+func disabledPtr(enabled bool) *bool {
+	if enabled {
+		return nil
+	}
+	return &enabled
+}
+
+// sameNames reports whether two lists hold the same names, ignoring order and duplicates
+func sameNames(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// MigrateVDCNamespaces gives a generated namespace name to every active VDC
+// whose namespace is empty or not a valid Kubernetes name, as created by
+// releases before names were validated. Such a namespace can never have been
+// created in the cluster, so renaming it is safe; valid names are left alone
+// because Kubernetes namespaces cannot be renamed. It returns the VDCs that
+// were changed, whose namespaces still need to be created.
+func (db *DB) MigrateVDCNamespaces(ctx context.Context) ([]models.VDC, error) {
+	var migrated []models.VDC
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var vdcs []models.VDC
+		if err := tx.Preload("Organization").Find(&vdcs).Error; err != nil {
+			return fmt.Errorf("failed to list VDCs: %w", err)
+		}
+
+		for _, vdc := range vdcs {
+			if vdc.Namespace != "" && models.ValidateNamespaceName(vdc.Namespace) == nil {
+				continue
+			}
+			orgName := ""
+			if vdc.Organization != nil {
+				orgName = vdc.Organization.Name
+			}
+			namespace, err := models.GenerateVDCNamespace(tx, orgName, vdc.Name, nil)
+			if err != nil {
+				return fmt.Errorf("failed to generate namespace for VDC %s: %w", vdc.ID, err)
+			}
+			if err := tx.Model(&models.VDC{}).Where("id = ?", vdc.ID).Update("namespace", namespace).Error; err != nil {
+				return fmt.Errorf("failed to update namespace of VDC %s: %w", vdc.ID, err)
+			}
+			log.Printf("Renamed namespace of VDC %s from %q to %q", vdc.ID, vdc.Namespace, namespace)
+			vdc.Namespace = namespace
+			migrated = append(migrated, vdc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return migrated, nil
+}
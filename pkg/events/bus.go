@@ -0,0 +1,133 @@
+// Package events provides an in-process event bus for entity change notifications.
+//
+// Producers (API handlers, the VM status poller) publish Events to a Bus, and
+// consumers such as the notifications stream subscribe to receive them. Delivery
+// is best effort: a subscriber that falls behind has events dropped rather than
+// blocking publishers.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	TypeVMStatusChanged = "vm.statusChanged"
+	TypeVMUpdated       = "vm.updated"
+	TypeVMDeleted       = "vm.deleted"
+	TypeTaskUpdated     = "task.updated"
+	TypeVDCQuotaWarning = "vdc.quotaWarning"
+)
+
+// Entity types
+const (
+	EntityVM   = "vm"
+	EntityTask = "task"
+	EntityVDC  = "vdc"
+)
+
+// defaultSubscriberBuffer is the channel buffer used when Subscribe is called with size <= 0
+const defaultSubscriberBuffer = 64
+
+// Event represents a change to an entity
+type Event struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	EntityType string                 `json:"entityType"`
+	EntityID   string                 `json:"entityId"`
+	OrgID      string                 `json:"orgId"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// Subscription receives events published to a Bus until closed
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	bus     *Bus
+	once    sync.Once
+	dropped uint64
+}
+
+// Close unsubscribes from the bus and closes the event channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.unsubscribe(s)
+	})
+}
+
+// Dropped returns the number of events dropped because the subscriber was not keeping up
+func (s *Subscription) Dropped() uint64 {
+	s.bus.mu.RLock()
+	defer s.bus.mu.RUnlock()
+	return s.dropped
+}
+
+// Bus fans out published events to all subscribers
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber with the given channel buffer size
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = defaultSubscriberBuffer
+	}
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, bus: b}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Publish delivers an event to all subscribers without blocking. ID and Timestamp
+// are filled in if unset.
+func (b *Bus) Publish(event Event) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// SubscriberCount returns the number of active subscribers
+func (b *Bus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+func (b *Bus) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,148 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestBusPublishSubscribe(t *testing.T) {
+	bus := NewBus()
+	sub1 := bus.Subscribe(4)
+	sub2 := bus.Subscribe(4)
+	assert.Equal(t, 2, bus.SubscriberCount())
+
+	bus.Publish(Event{Type: TypeVMUpdated, EntityID: "urn:vcloud:vm:1"})
+
+	for _, sub := range []*Subscription{sub1, sub2} {
+		select {
+		case event := <-sub.C:
+			assert.Equal(t, TypeVMUpdated, event.Type)
+			assert.NotEmpty(t, event.ID)
+			assert.False(t, event.Timestamp.IsZero())
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+
+	sub1.Close()
+	sub1.Close() // Closing twice is safe
+	assert.Equal(t, 1, bus.SubscriberCount())
+	_, open := <-sub1.C
+	assert.False(t, open)
+	sub2.Close()
+}
+
+func TestBusDropsForSlowSubscribers(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(1)
+	defer sub.Close()
+
+	bus.Publish(Event{Type: TypeVMUpdated})
+	bus.Publish(Event{Type: TypeVMUpdated})
+	bus.Publish(Event{Type: TypeVMUpdated})
+
+	assert.Equal(t, uint64(2), sub.Dropped())
+	assert.Len(t, sub.C, 1)
+}
+
+type fakeVMSource struct {
+	vms []models.VM
+}
+
+func (f *fakeVMSource) ListUpdatedSince(ctx context.Context, since time.Time, afterID string, limit int) ([]models.VM, error) {
+	var result []models.VM
+	for _, vm := range f.vms {
+		if vm.UpdatedAt.After(since) || (vm.UpdatedAt.Equal(since) && vm.ID > afterID) {
+			result = append(result, vm)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].UpdatedAt.Equal(result[j].UpdatedAt) {
+			return result[i].UpdatedAt.Before(result[j].UpdatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func TestVMStatusPoller(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+
+	start := time.Now()
+	source := &fakeVMSource{vms: []models.VM{{
+		ID:        "urn:vcloud:vm:1",
+		Name:      "vm-1",
+		Status:    "POWERED_OFF",
+		UpdatedAt: start,
+		VApp:      &models.VApp{VDC: &models.VDC{OrganizationID: "urn:vcloud:org:1"}},
+	}}}
+	poller := NewVMStatusPoller(source, bus, time.Second, nil)
+
+	// The first poll only records current statuses
+	require.NoError(t, poller.Poll(context.Background()))
+	assert.Len(t, sub.C, 0)
+
+	source.vms[0].Status = "POWERING_ON"
+	source.vms[0].UpdatedAt = start.Add(time.Second)
+	require.NoError(t, poller.Poll(context.Background()))
+
+	event := <-sub.C
+	assert.Equal(t, TypeVMStatusChanged, event.Type)
+	assert.Equal(t, "urn:vcloud:org:1", event.OrgID)
+	assert.Equal(t, "POWERING_ON", event.Data["status"])
+	assert.Equal(t, "POWERED_OFF", event.Data["previousStatus"])
+
+	// Updates that do not change status are not published
+	source.vms[0].UpdatedAt = start.Add(2 * time.Second)
+	require.NoError(t, poller.Poll(context.Background()))
+	assert.Len(t, sub.C, 0)
+
+	// VMs seen for the first time have no transition to publish
+	source.vms = append(source.vms, models.VM{ID: "urn:vcloud:vm:2", Status: "POWERED_ON", UpdatedAt: start.Add(3 * time.Second)})
+	require.NoError(t, poller.Poll(context.Background()))
+	assert.Len(t, sub.C, 0)
+
+	// Deleted VMs are published and forgotten
+	source.vms[1].Status = "DELETED"
+	source.vms[1].UpdatedAt = start.Add(4 * time.Second)
+	require.NoError(t, poller.Poll(context.Background()))
+	event = <-sub.C
+	assert.Equal(t, "DELETED", event.Data["status"])
+	assert.NotContains(t, poller.statuses, "urn:vcloud:vm:2")
+}
+
+func TestVMStatusPollerBatchBoundary(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(2 * vmPollBatchSize)
+	defer sub.Close()
+
+	// More VMs than fit in one batch share an update time
+	start := time.Now()
+	source := &fakeVMSource{}
+	for i := 0; i < vmPollBatchSize+50; i++ {
+		source.vms = append(source.vms, models.VM{ID: fmt.Sprintf("urn:vcloud:vm:%04d", i), Status: "POWERED_OFF", UpdatedAt: start})
+	}
+	poller := NewVMStatusPoller(source, bus, time.Second, nil)
+	require.NoError(t, poller.Poll(context.Background()))
+	assert.Len(t, poller.statuses, vmPollBatchSize+50)
+
+	for i := range source.vms {
+		source.vms[i].Status = "POWERED_ON"
+		source.vms[i].UpdatedAt = start.Add(time.Second)
+	}
+	require.NoError(t, poller.Poll(context.Background()))
+	assert.Len(t, sub.C, vmPollBatchSize+50)
+}
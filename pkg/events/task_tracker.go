@@ -0,0 +1,167 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/commands"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// TaskStore reads and transitions tasks
+type TaskStore interface {
+	ListActiveByOwner(ctx context.Context, ownerID string) ([]models.Task, error)
+	UpdateStatus(ctx context.Context, id, status string, progress int, details string) error
+}
+
+// VMTaskTracker completes VM tasks when the VM reaches the state the task was
+// waiting for, based on vm.statusChanged events published by the VMStatusPoller
+type VMTaskTracker struct {
+	store  TaskStore
+	bus    *Bus
+	logger *slog.Logger
+}
+
+// NewVMTaskTracker creates a tracker that completes tasks in store
+func NewVMTaskTracker(store TaskStore, bus *Bus, logger *slog.Logger) *VMTaskTracker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &VMTaskTracker{
+		store:  store,
+		bus:    bus,
+		logger: logger,
+	}
+}
+
+// Start processes bus events until ctx is cancelled
+func (t *VMTaskTracker) Start(ctx context.Context) {
+	sub := t.bus.Subscribe(0)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if event.Type != TypeVMStatusChanged {
+				continue
+			}
+			if err := t.HandleEvent(ctx, event); err != nil {
+				t.logger.Warn("Failed to update tasks for VM", "vmID", event.EntityID, "error", err)
+			}
+		}
+	}
+}
+
+// HandleEvent completes active tasks owned by the VM in a vm.statusChanged event
+func (t *VMTaskTracker) HandleEvent(ctx context.Context, event Event) error {
+	status, _ := event.Data["status"].(string)
+	if status == "" {
+		return nil
+	}
+
+	tasks, err := t.store.ListActiveByOwner(ctx, event.EntityID)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		newStatus, details := vmTaskOutcome(task.Name, status)
+		if newStatus == "" {
+			continue
+		}
+
+		err := t.store.UpdateStatus(ctx, task.ID, newStatus, 100, details)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Completed concurrently, e.g. by another API server replica
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		t.publish(task, newStatus)
+	}
+
+	return nil
+}
+
+// CommandCompleted completes the task of a command executed by the vm-controller.
+// A task already completed, for example from the VM's status, is left alone.
+func (t *VMTaskTracker) CommandCompleted(ctx context.Context, result commands.Result) error {
+	if result.TaskID == "" || result.VMID == "" {
+		return nil
+	}
+
+	tasks, err := t.store.ListActiveByOwner(ctx, result.VMID)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if task.ID != result.TaskID {
+			continue
+		}
+
+		status := models.TaskStatusSuccess
+		if result.Status == commands.ResultFailed {
+			status = models.TaskStatusError
+		}
+		err := t.store.UpdateStatus(ctx, task.ID, status, 100, result.Message)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t.publish(task, status)
+	}
+	return nil
+}
+
+// publish notifies task waiters and notification subscribers of a task update
+func (t *VMTaskTracker) publish(task models.Task, status string) {
+	t.bus.Publish(Event{
+		Type:       TypeTaskUpdated,
+		EntityType: EntityTask,
+		EntityID:   task.ID,
+		OrgID:      task.OrganizationID,
+		Data: map[string]interface{}{
+			"name":    task.Name,
+			"status":  status,
+			"ownerId": task.OwnerID,
+		},
+	})
+}
+
+// vmTaskOutcome maps a VM status to the resulting task status for an operation,
+// returning an empty status when the task is still in progress
+func vmTaskOutcome(operation, vmStatus string) (string, string) {
+	switch vmStatus {
+	case "ERROR":
+		return models.TaskStatusError, "VM entered ERROR state"
+	case "DELETING", "DELETED":
+		return models.TaskStatusAborted, "VM was deleted"
+	}
+
+	switch operation {
+	case models.TaskOperationVMPowerOn:
+		if vmStatus == "POWERED_ON" {
+			return models.TaskStatusSuccess, ""
+		}
+		if vmStatus == models.VMStatusQuotaExceeded {
+			return models.TaskStatusError, "VM cannot start because the VDC resource quota is exceeded"
+		}
+	case models.TaskOperationVMPowerOff:
+		if vmStatus == "POWERED_OFF" || vmStatus == "STOPPED" {
+			return models.TaskStatusSuccess, ""
+		}
+	}
+	return "", ""
+}
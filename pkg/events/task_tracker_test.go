@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/commands"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+type fakeTaskStore struct {
+	tasks map[string]*models.Task
+}
+
+func (f *fakeTaskStore) ListActiveByOwner(ctx context.Context, ownerID string) ([]models.Task, error) {
+	var result []models.Task
+	for _, task := range f.tasks {
+		if task.OwnerID == ownerID && !task.IsTerminal() {
+			result = append(result, *task)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeTaskStore) UpdateStatus(ctx context.Context, id, status string, progress int, details string) error {
+	task, ok := f.tasks[id]
+	if !ok || task.IsTerminal() {
+		return gorm.ErrRecordNotFound
+	}
+	task.Status = status
+	task.Progress = progress
+	task.Details = details
+	return nil
+}
+
+func TestVMTaskTracker(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+
+	store := &fakeTaskStore{tasks: map[string]*models.Task{
+		"task-on":  {ID: "task-on", Name: models.TaskOperationVMPowerOn, Status: models.TaskStatusRunning, OwnerID: "vm-1", OrganizationID: "org-1"},
+		"task-off": {ID: "task-off", Name: models.TaskOperationVMPowerOff, Status: models.TaskStatusRunning, OwnerID: "vm-2", OrganizationID: "org-1"},
+	}}
+	tracker := NewVMTaskTracker(store, bus, nil)
+	ctx := context.Background()
+
+	// Intermediate status leaves the task running
+	require.NoError(t, tracker.HandleEvent(ctx, Event{Type: TypeVMStatusChanged, EntityID: "vm-1", Data: map[string]interface{}{"status": "POWERING_ON"}}))
+	assert.Equal(t, models.TaskStatusRunning, store.tasks["task-on"].Status)
+	assert.Len(t, sub.C, 0)
+
+	require.NoError(t, tracker.HandleEvent(ctx, Event{Type: TypeVMStatusChanged, EntityID: "vm-1", Data: map[string]interface{}{"status": "POWERED_ON"}}))
+	assert.Equal(t, models.TaskStatusSuccess, store.tasks["task-on"].Status)
+	require.Len(t, sub.C, 1)
+	event := <-sub.C
+	assert.Equal(t, TypeTaskUpdated, event.Type)
+	assert.Equal(t, "task-on", event.EntityID)
+	assert.Equal(t, "org-1", event.OrgID)
+
+	require.NoError(t, tracker.HandleEvent(ctx, Event{Type: TypeVMStatusChanged, EntityID: "vm-2", Data: map[string]interface{}{"status": "ERROR"}}))
+	assert.Equal(t, models.TaskStatusError, store.tasks["task-off"].Status)
+	assert.Equal(t, "VM entered ERROR state", store.tasks["task-off"].Details)
+
+	// A VM held back by its VDC's quota fails the power on task
+	store.tasks["task-quota"] = &models.Task{ID: "task-quota", Name: models.TaskOperationVMPowerOn, Status: models.TaskStatusRunning, OwnerID: "vm-3"}
+	require.NoError(t, tracker.HandleEvent(ctx, Event{Type: TypeVMStatusChanged, EntityID: "vm-3", Data: map[string]interface{}{"status": models.VMStatusQuotaExceeded}}))
+	assert.Equal(t, models.TaskStatusError, store.tasks["task-quota"].Status)
+	assert.Contains(t, store.tasks["task-quota"].Details, "quota is exceeded")
+}
+
+func TestVMTaskTrackerCommandCompleted(t *testing.T) {
+	store := &fakeTaskStore{tasks: map[string]*models.Task{
+		"task-on":  {ID: "task-on", Name: models.TaskOperationVMPowerOn, Status: models.TaskStatusRunning, OwnerID: "vm-1"},
+		"task-off": {ID: "task-off", Name: models.TaskOperationVMPowerOff, Status: models.TaskStatusRunning, OwnerID: "vm-2"},
+	}}
+	tracker := NewVMTaskTracker(store, NewBus(), nil)
+	ctx := context.Background()
+
+	require.NoError(t, tracker.CommandCompleted(ctx, commands.Result{CommandID: "cmd-1", VMID: "vm-1", TaskID: "task-on", Status: commands.ResultSucceeded}))
+	assert.Equal(t, models.TaskStatusSuccess, store.tasks["task-on"].Status)
+
+	require.NoError(t, tracker.CommandCompleted(ctx, commands.Result{CommandID: "cmd-2", VMID: "vm-2", TaskID: "task-off", Status: commands.ResultFailed, Message: "timed out"}))
+	assert.Equal(t, models.TaskStatusError, store.tasks["task-off"].Status)
+	assert.Equal(t, "timed out", store.tasks["task-off"].Details)
+
+	// Results for tasks that already completed are ignored
+	require.NoError(t, tracker.CommandCompleted(ctx, commands.Result{CommandID: "cmd-1", VMID: "vm-1", TaskID: "task-on", Status: commands.ResultFailed}))
+	assert.Equal(t, models.TaskStatusSuccess, store.tasks["task-on"].Status)
+}
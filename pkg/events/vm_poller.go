@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// vmPollBatchSize bounds the number of VM rows read per poll
+const vmPollBatchSize = 500
+
+// VMChangeSource lists VM records changed since a point in time. Records are
+// ordered by updated_at and ID, and those after (since, afterID) are returned,
+// so records sharing a timestamp are not skipped between batches.
+type VMChangeSource interface {
+	ListUpdatedSince(ctx context.Context, since time.Time, afterID string, limit int) ([]models.VM, error)
+}
+
+// VMStatusPoller publishes VM status transitions written to the database by the
+// VM controller. The controller runs in a separate process, so the API server
+// observes its changes by polling updated_at rather than in-process calls. The
+// first poll only records the status of every VM, so transitions are published
+// with the status they came from.
+type VMStatusPoller struct {
+	source   VMChangeSource
+	bus      *Bus
+	interval time.Duration
+	logger   *slog.Logger
+
+	since    time.Time
+	sinceID  string
+	primed   bool
+	statuses map[string]string
+}
+
+// NewVMStatusPoller creates a poller that checks for VM changes every interval
+func NewVMStatusPoller(source VMChangeSource, bus *Bus, interval time.Duration, logger *slog.Logger) *VMStatusPoller {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &VMStatusPoller{
+		source:   source,
+		bus:      bus,
+		interval: interval,
+		logger:   logger,
+		statuses: make(map[string]string),
+	}
+}
+
+// Start polls until ctx is cancelled
+func (p *VMStatusPoller) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Poll(ctx); err != nil {
+				p.logger.Warn("Failed to poll VM status changes", "error", err)
+			}
+		}
+	}
+}
+
+// Poll reads VMs changed since the last poll and publishes status transitions
+func (p *VMStatusPoller) Poll(ctx context.Context) error {
+	for {
+		vms, err := p.source.ListUpdatedSince(ctx, p.since, p.sinceID, vmPollBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, vm := range vms {
+			p.since, p.sinceID = vm.UpdatedAt, vm.ID
+
+			previous, known := p.statuses[vm.ID]
+			if vm.Status == "DELETED" {
+				delete(p.statuses, vm.ID)
+			} else {
+				p.statuses[vm.ID] = vm.Status
+			}
+			// VMs first seen have no transition to report
+			if !p.primed || !known || previous == vm.Status {
+				continue
+			}
+
+			event := Event{
+				Type:       TypeVMStatusChanged,
+				EntityType: EntityVM,
+				EntityID:   vm.ID,
+				Timestamp:  vm.UpdatedAt.UTC(),
+				Data: map[string]interface{}{
+					"name":           vm.Name,
+					"status":         vm.Status,
+					"previousStatus": previous,
+					"vappId":         vm.VAppID,
+				},
+			}
+			if vm.VApp != nil && vm.VApp.VDC != nil {
+				event.OrgID = vm.VApp.VDC.OrganizationID
+			}
+			p.bus.Publish(event)
+		}
+
+		if len(vms) < vmPollBatchSize {
+			p.primed = true
+			return nil
+		}
+	}
+}
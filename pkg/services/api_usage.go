@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// APIUsageStore persists aggregated API usage
+type APIUsageStore interface {
+	AddUsage(ctx context.Context, usage []models.APIUsage) error
+	DeleteBefore(ctx context.Context, day string) (int64, error)
+}
+
+type apiUsageKey struct {
+	day    string
+	userID string
+}
+
+// APIUsageRecorder counts API calls per user and day in memory and adds the
+// counts to the store every flush interval, so recording a request does not
+// cost a database write
+type APIUsageRecorder struct {
+	store         APIUsageStore
+	flushInterval time.Duration
+	retentionDays int
+	logger        *slog.Logger
+	now           func() time.Time
+
+	mu      sync.Mutex
+	pending map[apiUsageKey]*models.APIUsage
+}
+
+// NewAPIUsageRecorder creates a recorder. Usage older than retentionDays is
+// pruned after flushes; zero keeps it forever.
+func NewAPIUsageRecorder(store APIUsageStore, flushInterval time.Duration, retentionDays int, logger *slog.Logger) *APIUsageRecorder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &APIUsageRecorder{
+		store:         store,
+		flushInterval: flushInterval,
+		retentionDays: retentionDays,
+		logger:        logger,
+		now:           time.Now,
+		pending:       make(map[apiUsageKey]*models.APIUsage),
+	}
+}
+
+// Record counts one API call
+func (r *APIUsageRecorder) Record(userID, orgID string, bytesIn, bytesOut int64, failed bool) {
+	key := apiUsageKey{day: models.APIUsageDay(r.now()), userID: userID}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	usage, ok := r.pending[key]
+	if !ok {
+		usage = &models.APIUsage{Day: key.day, UserID: userID}
+		r.pending[key] = usage
+	}
+	usage.OrganizationID = orgID
+	usage.RequestCount++
+	usage.BytesIn += bytesIn
+	usage.BytesOut += bytesOut
+	if failed {
+		usage.ErrorCount++
+	}
+}
+
+// Start flushes recorded usage every flush interval until ctx is cancelled.
+// Usage recorded afterwards is written by a final call to Flush.
+func (r *APIUsageRecorder) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	lastPrune := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Flush(ctx); err != nil {
+				r.logger.Warn("Failed to flush API usage", "error", err)
+				continue
+			}
+			// Prune at most once a day
+			if today := models.APIUsageDay(r.now()); r.retentionDays > 0 && today != lastPrune {
+				if err := r.Prune(ctx); err != nil {
+					r.logger.Warn("Failed to prune API usage", "error", err)
+					continue
+				}
+				lastPrune = today
+			}
+		}
+	}
+}
+
+// Flush adds the usage recorded since the last flush to the store. Usage that
+// fails to be written is kept for the next flush.
+func (r *APIUsageRecorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[apiUsageKey]*models.APIUsage)
+	r.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	now := r.now()
+	usage := make([]models.APIUsage, 0, len(pending))
+	for _, u := range pending {
+		u.UpdatedAt = now
+		usage = append(usage, *u)
+	}
+	if err := r.store.AddUsage(ctx, usage); err != nil {
+		r.restore(pending)
+		return err
+	}
+	return nil
+}
+
+// Prune removes usage older than the retention period
+func (r *APIUsageRecorder) Prune(ctx context.Context) error {
+	if r.retentionDays <= 0 {
+		return nil
+	}
+	cutoff := models.APIUsageDay(r.now().AddDate(0, 0, -r.retentionDays))
+	deleted, err := r.store.DeleteBefore(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		r.logger.Info("Pruned API usage", "rows", deleted, "before", cutoff)
+	}
+	return nil
+}
+
+// restore merges usage that could not be written back into the pending counts
+func (r *APIUsageRecorder) restore(failed map[apiUsageKey]*models.APIUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, usage := range failed {
+		current, ok := r.pending[key]
+		if !ok {
+			r.pending[key] = usage
+			continue
+		}
+		current.RequestCount += usage.RequestCount
+		current.ErrorCount += usage.ErrorCount
+		current.BytesIn += usage.BytesIn
+		current.BytesOut += usage.BytesOut
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+// Headers carrying the HMAC-SHA256 signature of approval callouts and of the
+// decisions posted back. The signature is "sha256=" followed by the hex HMAC
+// of the timestamp, a dot and the request body.
+const (
+	ApprovalSignatureHeader = "X-SSVirt-Signature"
+	ApprovalTimestampHeader = "X-SSVirt-Timestamp"
+)
+
+// ApprovalSignatureMaxAge is how old a signed decision may be, limiting replays
+const ApprovalSignatureMaxAge = 5 * time.Minute
+
+// Decisions of the approval system
+const (
+	ApprovalAllow   = "allow"
+	ApprovalDeny    = "deny"
+	ApprovalPending = "pending"
+)
+
+// ErrInvalidApprovalSignature is returned when a decision posted back is not
+// signed with the approval secret or its signature has expired
+var ErrInvalidApprovalSignature = errors.New("invalid approval signature")
+
+// ApprovalRequest describes a gated operation to the approval system
+type ApprovalRequest struct {
+	// TaskID identifies the task the operation is held under when the
+	// approval system answers pending, and its decision is posted back for
+	TaskID      string `json:"taskId"`
+	Operation   string `json:"operation"`
+	Description string `json:"description"`
+	UserID      string `json:"userId"`
+	Username    string `json:"username"`
+	OrgID       string `json:"orgId"`
+	// TargetID and TargetName identify the entity operated on
+	TargetID   string                 `json:"targetId"`
+	TargetName string                 `json:"targetName"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// ApprovalDecision is the approval system's answer to a callout, and the body
+// of the decision it later posts back for pending operations
+type ApprovalDecision struct {
+	// TaskID names the task a posted decision is for, so that its signature
+	// cannot be replayed for another task; callout answers leave it empty
+	TaskID   string `json:"taskId,omitempty"`
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ApprovalGate calls an external approval webhook before privileged operations
+type ApprovalGate struct {
+	webhookURL string
+	secret     []byte
+	operations map[string]bool
+	httpClient *http.Client
+}
+
+// NewApprovalGate creates a gate calling the webhook in cfg.Approval, which
+// validateConfig has checked
+func NewApprovalGate(cfg config.Config) *ApprovalGate {
+	operations := make(map[string]bool, len(cfg.Approval.Operations))
+	for _, operation := range cfg.Approval.Operations {
+		operations[operation] = true
+	}
+	return &ApprovalGate{
+		webhookURL: cfg.Approval.WebhookURL,
+		secret:     []byte(cfg.Approval.Secret),
+		operations: operations,
+		httpClient: &http.Client{Timeout: cfg.Approval.Timeout},
+	}
+}
+
+// Requires reports whether operation must be approved before it runs
+func (g *ApprovalGate) Requires(operation string) bool {
+	return g.operations[operation]
+}
+
+// Request asks the approval system whether an operation may run. Errors,
+// including unknown decisions, mean the operation was not approved.
+func (g *ApprovalGate) Request(ctx context.Context, request ApprovalRequest) (*ApprovalDecision, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode approval request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build approval request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ApprovalTimestampHeader, timestamp)
+	req.Header.Set(ApprovalSignatureHeader, g.sign(timestamp, body))
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("approval webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approval webhook response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("approval webhook returned %s", resp.Status)
+	}
+	var decision ApprovalDecision
+	if err := json.Unmarshal(payload, &decision); err != nil {
+		return nil, fmt.Errorf("failed to decode approval webhook response: %w", err)
+	}
+	switch decision.Decision {
+	case ApprovalAllow, ApprovalDeny, ApprovalPending:
+		return &decision, nil
+	default:
+		return nil, fmt.Errorf("approval webhook returned unknown decision %q", decision.Decision)
+	}
+}
+
+// Verify checks the signature of a decision posted back by the approval system
+func (g *ApprovalGate) Verify(timestamp, signature string, body []byte) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidApprovalSignature
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > ApprovalSignatureMaxAge || age < -ApprovalSignatureMaxAge {
+		return ErrInvalidApprovalSignature
+	}
+	if !strings.HasPrefix(signature, "sha256=") || !hmac.Equal([]byte(signature), []byte(g.sign(timestamp, body))) {
+		return ErrInvalidApprovalSignature
+	}
+	return nil
+}
+
+// sign returns the signature header value for a timestamped body
+func (g *ApprovalGate) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BackgroundWorkHandoffTimeout bounds how long interrupted work may take to
+// record its state after Shutdown cancels it
+const BackgroundWorkHandoffTimeout = 5 * time.Second
+
+// BackgroundWork runs operations that outlive the request that started them,
+// such as vApp startup sequences, so the API server can wait for them when it
+// shuts down. Work still running at the shutdown deadline has its context
+// cancelled and is expected to record its state in the database and return,
+// rather than being lost with the process.
+type BackgroundWork struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	running int
+	// idle is closed when running drops to zero
+	idle chan struct{}
+}
+
+// NewBackgroundWork creates a new BackgroundWork
+func NewBackgroundWork() *BackgroundWork {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BackgroundWork{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a goroutine. Its context is cancelled when Shutdown stops
+// waiting, or immediately if Shutdown already gave up.
+func (w *BackgroundWork) Go(fn func(ctx context.Context)) {
+	w.mu.Lock()
+	if w.running == 0 {
+		w.idle = make(chan struct{})
+	}
+	w.running++
+	w.mu.Unlock()
+
+	go func() {
+		defer func() {
+			w.mu.Lock()
+			w.running--
+			if w.running == 0 {
+				close(w.idle)
+			}
+			w.mu.Unlock()
+		}()
+		fn(w.ctx)
+	}()
+}
+
+// Running returns the number of operations in progress
+func (w *BackgroundWork) Running() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.running
+}
+
+// Shutdown waits for running work until ctx is done, then cancels what is
+// left and waits up to BackgroundWorkHandoffTimeout for it to hand off. It
+// returns ctx.Err() when work had to be cancelled.
+func (w *BackgroundWork) Shutdown(ctx context.Context) error {
+	if w.wait(ctx.Done()) {
+		return nil
+	}
+
+	w.cancel()
+	handoffCtx, cancel := context.WithTimeout(context.Background(), BackgroundWorkHandoffTimeout)
+	defer cancel()
+	w.wait(handoffCtx.Done())
+	return ctx.Err()
+}
+
+// wait blocks until no work is running, returning false if stop fires first
+func (w *BackgroundWork) wait(stop <-chan struct{}) bool {
+	for {
+		w.mu.Lock()
+		if w.running == 0 {
+			w.mu.Unlock()
+			return true
+		}
+		idle := w.idle
+		w.mu.Unlock()
+
+		select {
+		case <-idle:
+		case <-stop:
+			return false
+		}
+	}
+}
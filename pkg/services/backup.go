@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// Labels that put VMs in or out of OADP/Velero backups. Operators create
+// Velero Schedules selecting BackupLabel, and BackupScheduleLabel to spread
+// VMs across Schedules.
+const (
+	// BackupLabel is set to "enabled" on VMs whose backup policy enables backups
+	BackupLabel = "ssvirt.io/backup"
+	// BackupScheduleLabel names the Velero Schedule a VM's policy selects
+	BackupScheduleLabel = "ssvirt.io/backup-schedule"
+	// VeleroExcludeLabel excludes a resource from every Velero backup; it is
+	// set on VMs whose backup policy disables backups
+	VeleroExcludeLabel = "velero.io/exclude-from-backup"
+	// veleroScheduleLabel is set by Velero on the Backups a Schedule creates
+	veleroScheduleLabel = "velero.io/schedule-name"
+)
+
+// backupLabelKeys are every label a backup policy may set, so changing a
+// policy removes the labels of the previous one
+var backupLabelKeys = []string{BackupLabel, BackupScheduleLabel, VeleroExcludeLabel}
+
+// veleroBackupListGVK is the kind Velero Backups are listed as. They are read
+// as unstructured data to avoid depending on the Velero API types.
+var veleroBackupListGVK = schema.GroupVersionKind{Group: "velero.io", Version: "v1", Kind: "BackupList"}
+
+// BackupPhaseCompleted is the phase of a Velero Backup that finished without errors
+const BackupPhaseCompleted = "Completed"
+
+// ErrBackupsUnavailable is returned when Velero is not installed in the cluster
+var ErrBackupsUnavailable = errors.New("Velero backups are not available")
+
+// ValidateBackupPolicy checks that a backup policy's Schedule is a valid label value
+func ValidateBackupPolicy(policy models.BackupPolicy) error {
+	if errs := validation.IsValidLabelValue(policy.Schedule); len(errs) > 0 {
+		return fmt.Errorf("schedule '%s' is invalid: %v", policy.Schedule, errs)
+	}
+	if policy.Schedule != "" && !policy.Enabled {
+		return fmt.Errorf("schedule requires backups to be enabled")
+	}
+	return nil
+}
+
+// BackupLabels returns the labels that apply a backup policy to a VM. A nil
+// policy sets none, leaving the VM to the operator's own Velero configuration.
+func BackupLabels(policy *models.BackupPolicy) map[string]string {
+	switch {
+	case policy == nil:
+		return nil
+	case !policy.Enabled:
+		return map[string]string{VeleroExcludeLabel: "true"}
+	case policy.Schedule != "":
+		return map[string]string{BackupLabel: "enabled", BackupScheduleLabel: policy.Schedule}
+	default:
+		return map[string]string{BackupLabel: "enabled"}
+	}
+}
+
+// BackupRecord describes one Velero Backup that included a VM
+type BackupRecord struct {
+	Name  string `json:"name"`
+	Phase string `json:"phase"`
+	// Schedule is the Velero Schedule that created the Backup, if any
+	Schedule    string     `json:"schedule,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// VMBackupStatus reports how a VM is protected by Velero backups
+type VMBackupStatus struct {
+	// Protected reports whether the VM carries the labels of an enabled backup policy
+	Protected bool `json:"protected"`
+	// LastBackup is the most recent Backup including the VM, whatever its outcome
+	LastBackup *BackupRecord `json:"lastBackup,omitempty"`
+	// LastSuccessfulBackup is the most recent completed Backup including the VM
+	LastSuccessfulBackup *BackupRecord `json:"lastSuccessfulBackup,omitempty"`
+}
+
+// BackupService applies backup policies to VMs and reports their backups
+type BackupService interface {
+	// ApplyVMBackupPolicy replaces the backup labels of the VirtualMachine
+	// vmName in namespace with those of policy
+	ApplyVMBackupPolicy(ctx context.Context, namespace, vmName string, policy *models.BackupPolicy) error
+	// VMBackupStatus finds the Velero Backups that included the VirtualMachine
+	// vmName in namespace
+	VMBackupStatus(ctx context.Context, namespace, vmName string) (*VMBackupStatus, error)
+}
+
+// veleroBackupService reads Backups from the namespace Velero runs in
+type veleroBackupService struct {
+	client          client.Client
+	veleroNamespace string
+}
+
+// NewBackupService returns a BackupService for the Velero installation in
+// veleroNamespace. Backups are read as unstructured objects, which the
+// client does not cache.
+func NewBackupService(c client.Client, veleroNamespace string) BackupService {
+	return &veleroBackupService{client: c, veleroNamespace: veleroNamespace}
+}
+
+// ApplyVMBackupPolicy patches the VM's labels. VMs not created yet are skipped;
+// they receive the labels when their vApp is instantiated.
+func (s *veleroBackupService) ApplyVMBackupPolicy(ctx context.Context, namespace, vmName string, policy *models.BackupPolicy) error {
+	vmLabels := make(map[string]interface{}, len(backupLabelKeys))
+	for _, key := range backupLabelKeys {
+		vmLabels[key] = nil
+	}
+	for key, value := range BackupLabels(policy) {
+		vmLabels[key] = value
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": vmLabels}})
+	if err != nil {
+		return fmt.Errorf("failed to encode backup labels: %w", err)
+	}
+
+	vm := &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: vmName}}
+	if err := s.client.Patch(ctx, vm, client.RawPatch(types.MergePatchType, patch)); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to label VirtualMachine %s/%s: %w", namespace, vmName, err)
+	}
+	return nil
+}
+
+// VMBackupStatus matches the VM against the namespaces, resources and label
+// selectors of every Backup, as Velero would when running it
+func (s *veleroBackupService) VMBackupStatus(ctx context.Context, namespace, vmName string) (*VMBackupStatus, error) {
+	vm := &kubevirtv1.VirtualMachine{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: vmName}, vm); err != nil {
+		if k8serrors.IsNotFound(err) {
+			// Without its labels the VM cannot be matched against Backups
+			return &VMBackupStatus{}, nil
+		}
+		return nil, fmt.Errorf("failed to get VirtualMachine %s/%s: %w", namespace, vmName, err)
+	}
+	status := &VMBackupStatus{
+		Protected: vm.Labels[BackupLabel] == "enabled" && vm.Labels[VeleroExcludeLabel] != "true",
+	}
+	if vm.Labels[VeleroExcludeLabel] == "true" {
+		// Velero skips the VM whatever the Backup selects
+		return status, nil
+	}
+
+	backups := &unstructured.UnstructuredList{}
+	backups.SetGroupVersionKind(veleroBackupListGVK)
+	if err := s.client.List(ctx, backups, client.InNamespace(s.veleroNamespace)); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, ErrBackupsUnavailable
+		}
+		return nil, fmt.Errorf("failed to list Velero Backups: %w", err)
+	}
+
+	for i := range backups.Items {
+		backup := &backups.Items[i]
+		// Backups with invalid selectors are skipped; Velero fails them too
+		included, err := backupIncludesVM(backup, namespace, labels.Set(vm.Labels))
+		if err != nil || !included {
+			continue
+		}
+		record := backupRecord(backup)
+		if status.LastBackup == nil || backupStartedAfter(record, status.LastBackup) {
+			status.LastBackup = record
+		}
+		if record.Phase == BackupPhaseCompleted && (status.LastSuccessfulBackup == nil || backupStartedAfter(record, status.LastSuccessfulBackup)) {
+			status.LastSuccessfulBackup = record
+		}
+	}
+	return status, nil
+}
+
+// backupIncludesVM reports whether a Backup's spec selects a VirtualMachine in
+// namespace with the given labels
+func backupIncludesVM(backup *unstructured.Unstructured, namespace string, vmLabels labels.Set) (bool, error) {
+	// A Backup without a spec backs up the whole cluster
+	spec, _, _ := unstructured.NestedMap(backup.Object, "spec")
+
+	includedNamespaces, _, _ := unstructured.NestedStringSlice(spec, "includedNamespaces")
+	excludedNamespaces, _, _ := unstructured.NestedStringSlice(spec, "excludedNamespaces")
+	if !matchesIncludes(includedNamespaces, namespace) || containsAny(excludedNamespaces, namespace) {
+		return false, nil
+	}
+
+	includedResources, _, _ := unstructured.NestedStringSlice(spec, "includedResources")
+	excludedResources, _, _ := unstructured.NestedStringSlice(spec, "excludedResources")
+	for _, resource := range []string{"virtualmachines", "virtualmachines.kubevirt.io"} {
+		if containsAny(excludedResources, resource) {
+			return false, nil
+		}
+	}
+	if !matchesIncludes(includedResources, "virtualmachines") && !matchesIncludes(includedResources, "virtualmachines.kubevirt.io") {
+		return false, nil
+	}
+
+	if selector, found, _ := unstructured.NestedMap(spec, "labelSelector"); found {
+		matches, err := selectorMatches(selector, vmLabels)
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+	if selectors, found, _ := unstructured.NestedSlice(spec, "orLabelSelectors"); found && len(selectors) > 0 {
+		for _, s := range selectors {
+			selector, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			matches, err := selectorMatches(selector, vmLabels)
+			if err != nil {
+				return false, err
+			}
+			if matches {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// selectorMatches evaluates an unstructured metav1.LabelSelector
+func selectorMatches(selector map[string]interface{}, set labels.Set) (bool, error) {
+	var labelSelector metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selector, &labelSelector); err != nil {
+		return false, fmt.Errorf("invalid label selector: %w", err)
+	}
+	parsed, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid label selector: %w", err)
+	}
+	return parsed.Matches(set), nil
+}
+
+// matchesIncludes reports whether value is selected by a Velero include list,
+// where an empty list or "*" selects everything
+func matchesIncludes(includes []string, value string) bool {
+	return len(includes) == 0 || containsAny(includes, "*", value)
+}
+
+func containsAny(list []string, values ...string) bool {
+	for _, item := range list {
+		for _, value := range values {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backupRecord summarizes a Backup's status
+func backupRecord(backup *unstructured.Unstructured) *BackupRecord {
+	record := &BackupRecord{
+		Name:     backup.GetName(),
+		Schedule: backup.GetLabels()[veleroScheduleLabel],
+	}
+	record.Phase, _, _ = unstructured.NestedString(backup.Object, "status", "phase")
+	record.StartedAt = backupTimestamp(backup, "startTimestamp")
+	record.CompletedAt = backupTimestamp(backup, "completionTimestamp")
+	if record.StartedAt == nil {
+		// Backups that have not started yet are ordered by creation
+		created := backup.GetCreationTimestamp().UTC()
+		if !created.IsZero() {
+			record.StartedAt = &created
+		}
+	}
+	return record
+}
+
+func backupTimestamp(backup *unstructured.Unstructured, field string) *time.Time {
+	value, found, _ := unstructured.NestedString(backup.Object, "status", field)
+	if !found {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	parsed = parsed.UTC()
+	return &parsed
+}
+
+// backupStartedAfter reports whether a started after b, ordering Backups
+// without a start time first
+func backupStartedAfter(a, b *BackupRecord) bool {
+	switch {
+	case a.StartedAt == nil:
+		return false
+	case b.StartedAt == nil:
+		return true
+	default:
+		return a.StartedAt.After(*b.StartedAt)
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// Limits on what a catalog source may contain
+const (
+	MaxCatalogSourceTemplates = 500
+	maxCatalogSourceFileBytes = 10 * 1024 * 1024
+)
+
+// DefaultCatalogSourceTimeout bounds how long fetching a catalog source may take
+const DefaultCatalogSourceTimeout = 3 * time.Minute
+
+// FetchedTemplates are the Templates found in a catalog source
+type FetchedTemplates struct {
+	Templates []templatev1.Template
+	// Revision identifies the fetched content: the git commit, or a digest of
+	// the HTTP index and the Templates it lists
+	Revision string
+}
+
+// CatalogSourceIndex is the document an HTTP catalog source points to. Template
+// URLs may be relative to the index URL.
+type CatalogSourceIndex struct {
+	Templates []string `json:"templates"`
+}
+
+// CatalogSourceFetcher downloads the Templates of catalog sources. Git sources
+// are cloned with the git command.
+type CatalogSourceFetcher struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	gitCommand string
+}
+
+// NewCatalogSourceFetcher creates a CatalogSourceFetcher whose fetches time out
+// after timeout
+func NewCatalogSourceFetcher(timeout time.Duration) *CatalogSourceFetcher {
+	if timeout <= 0 {
+		timeout = DefaultCatalogSourceTimeout
+	}
+	return &CatalogSourceFetcher{
+		httpClient: &http.Client{Timeout: timeout},
+		timeout:    timeout,
+		gitCommand: "git",
+	}
+}
+
+// ValidateCatalogSource checks the type and location of a catalog source. Only
+// http and https URLs are accepted so a source cannot read the server's files.
+func ValidateCatalogSource(source models.CatalogSource) error {
+	if source.Type != models.CatalogSourceGit && source.Type != models.CatalogSourceHTTP {
+		return fmt.Errorf("type must be one of: %s, %s", models.CatalogSourceGit, models.CatalogSourceHTTP)
+	}
+	parsed, err := url.Parse(source.URL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	if source.Type == models.CatalogSourceHTTP && (source.Ref != "" || source.Path != "") {
+		return errors.New("ref and path only apply to git sources")
+	}
+	if strings.HasPrefix(source.Ref, "-") {
+		return errors.New("ref must be a branch or tag name")
+	}
+	for _, segment := range strings.Split(source.Path, "/") {
+		if segment == ".." {
+			return errors.New("path must stay within the repository")
+		}
+	}
+	if source.SyncIntervalMinutes < 0 {
+		return errors.New("syncIntervalMinutes must not be negative")
+	}
+	return nil
+}
+
+// Fetch downloads the Templates of a catalog source
+func (f *CatalogSourceFetcher) Fetch(ctx context.Context, source models.CatalogSource) (*FetchedTemplates, error) {
+	if err := ValidateCatalogSource(source); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	var fetched *FetchedTemplates
+	var err error
+	if source.Type == models.CatalogSourceGit {
+		fetched, err = f.fetchGit(ctx, source)
+	} else {
+		fetched, err = f.fetchHTTP(ctx, source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(fetched.Templates) > MaxCatalogSourceTemplates {
+		return nil, fmt.Errorf("source has %d Templates, more than the limit of %d", len(fetched.Templates), MaxCatalogSourceTemplates)
+	}
+	seen := make(map[string]bool, len(fetched.Templates))
+	for _, tmpl := range fetched.Templates {
+		if tmpl.Name == "" {
+			return nil, errors.New("source has a Template without a name")
+		}
+		if seen[tmpl.Name] {
+			return nil, fmt.Errorf("source has more than one Template named %s", tmpl.Name)
+		}
+		seen[tmpl.Name] = true
+	}
+	return fetched, nil
+}
+
+// fetchHTTP reads the index and every Template it lists
+func (f *CatalogSourceFetcher) fetchHTTP(ctx context.Context, source models.CatalogSource) (*FetchedTemplates, error) {
+	base, err := url.Parse(source.URL)
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.get(ctx, source.URL)
+	if err != nil {
+		return nil, err
+	}
+	var index CatalogSourceIndex
+	if err := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096).Decode(&index); err != nil {
+		return nil, fmt.Errorf("invalid catalog index: %w", err)
+	}
+
+	digest := sha256.New()
+	digest.Write(data)
+	fetched := &FetchedTemplates{}
+	for _, ref := range index.Templates {
+		location, err := base.Parse(ref)
+		if err != nil || (location.Scheme != "https" && location.Scheme != "http") {
+			return nil, fmt.Errorf("invalid Template URL %q in catalog index", ref)
+		}
+		data, err := f.get(ctx, location.String())
+		if err != nil {
+			return nil, err
+		}
+		templates, err := ParseTemplates(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", location, err)
+		}
+		if len(templates) == 0 {
+			return nil, fmt.Errorf("%s contains no Templates", location)
+		}
+		digest.Write(data)
+		fetched.Templates = append(fetched.Templates, templates...)
+	}
+	fetched.Revision = "sha256:" + hex.EncodeToString(digest.Sum(nil))
+	return fetched, nil
+}
+
+// get downloads a document, refusing documents over the size limit
+func (f *CatalogSourceFetcher) get(ctx context.Context, location string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", location, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %d", location, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCatalogSourceFileBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", location, err)
+	}
+	if len(data) > maxCatalogSourceFileBytes {
+		return nil, fmt.Errorf("%s is larger than %d bytes", location, maxCatalogSourceFileBytes)
+	}
+	return data, nil
+}
+
+// fetchGit makes a shallow clone of the repository and reads the Templates in
+// the YAML and JSON files under the source's path
+func (f *CatalogSourceFetcher) fetchGit(ctx context.Context, source models.CatalogSource) (*FetchedTemplates, error) {
+	dir, err := os.MkdirTemp("", "ssvirt-catalog-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if source.Ref != "" {
+		args = append(args, "--branch", source.Ref)
+	}
+	args = append(args, "--", source.URL, dir)
+	if _, err := f.git(ctx, args...); err != nil {
+		return nil, err
+	}
+	revision, err := f.git(ctx, "-C", dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	root := filepath.Join(dir, filepath.Clean("/"+source.Path))
+	fetched := &FetchedTemplates{Revision: strings.TrimSpace(revision)}
+	err = filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel(dir, path)
+		if info.Size() > maxCatalogSourceFileBytes {
+			return fmt.Errorf("%s is larger than %d bytes", relative, maxCatalogSourceFileBytes)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		templates, err := ParseTemplates(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", relative, err)
+		}
+		fetched.Templates = append(fetched.Templates, templates...)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("path %s does not exist in the repository", source.Path)
+		}
+		return nil, err
+	}
+	return fetched, nil
+}
+
+// git runs a git command, returning its output
+func (f *CatalogSourceFetcher) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, f.gitCommand, args...)
+	// Never prompt for credentials
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// ParseTemplates reads the OpenShift Templates in a YAML or JSON document,
+// which may hold several YAML documents and Lists of Templates. Other kinds of
+// resources are ignored.
+func ParseTemplates(data []byte) ([]templatev1.Template, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var templates []templatev1.Template
+	for {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				return templates, nil
+			}
+			return nil, fmt.Errorf("invalid YAML or JSON: %w", err)
+		}
+
+		objects := []interface{}{obj}
+		if kind, _ := obj["kind"].(string); kind == "List" || kind == "TemplateList" {
+			objects, _ = obj["items"].([]interface{})
+		}
+		for _, item := range objects {
+			itemObj, ok := item.(map[string]interface{})
+			if !ok || itemObj["kind"] != "Template" {
+				continue
+			}
+			var tmpl templatev1.Template
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(itemObj, &tmpl); err != nil {
+				return nil, fmt.Errorf("invalid Template: %w", err)
+			}
+			templates = append(templates, tmpl)
+		}
+	}
+}
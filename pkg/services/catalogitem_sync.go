@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultCatalogItemResyncInterval is how often the catalog_items table is fully
+// reconciled with the cluster even when no template events arrive
+const DefaultCatalogItemResyncInterval = 5 * time.Minute
+
+// CatalogItemSyncer keeps persisted catalog items in sync with the OpenShift
+// Templates they are built from. Template events trigger a sync; a periodic
+// resync repairs anything missed while the API server was disconnected.
+type CatalogItemSyncer struct {
+	source         CatalogItemSource
+	store          CatalogItemStore
+	resyncInterval time.Duration
+	logger         *slog.Logger
+
+	trigger chan struct{}
+}
+
+// NewCatalogItemSyncer creates a new CatalogItemSyncer
+func NewCatalogItemSyncer(source CatalogItemSource, store CatalogItemStore, resyncInterval time.Duration, logger *slog.Logger) *CatalogItemSyncer {
+	if resyncInterval <= 0 {
+		resyncInterval = DefaultCatalogItemResyncInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &CatalogItemSyncer{
+		source:         source,
+		store:          store,
+		resyncInterval: resyncInterval,
+		logger:         logger,
+		trigger:        make(chan struct{}, 1),
+	}
+}
+
+// Start watches templates and syncs catalog items until the context is cancelled
+func (s *CatalogItemSyncer) Start(ctx context.Context) {
+	if err := s.source.OnTemplateChange(ctx, s.Trigger); err != nil {
+		s.logger.Warn("Failed to watch templates, relying on periodic catalog item resync", "error", err)
+	}
+
+	ticker := time.NewTicker(s.resyncInterval)
+	defer ticker.Stop()
+
+	s.sync(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.trigger:
+			s.sync(ctx)
+		case <-ticker.C:
+			s.sync(ctx)
+		}
+	}
+}
+
+// Trigger requests a sync. Bursts of template events collapse into a single sync.
+func (s *CatalogItemSyncer) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Sync writes the current template set to the store
+func (s *CatalogItemSyncer) Sync(ctx context.Context) error {
+	records, err := s.source.ListCatalogItemRecords(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed, err := s.store.SyncTemplates(ctx, records)
+	if err != nil {
+		return err
+	}
+	if changed > 0 {
+		s.logger.Info("Synchronized catalog items", "templates", len(records), "changed", changed)
+	}
+	return nil
+}
+
+// sync runs Sync, logging failures so the next trigger or resync can retry
+func (s *CatalogItemSyncer) sync(ctx context.Context) {
+	if err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+		s.logger.Error("Failed to synchronize catalog items", "error", err)
+	}
+}
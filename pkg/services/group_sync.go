@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	userv1 "github.com/openshift/api/user/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// ErrGroupsUnavailable indicates the cluster has no OpenShift Group API
+var ErrGroupsUnavailable = errors.New("OpenShift Groups are not available")
+
+// Actions of a group sync change
+const (
+	// GroupSyncAdd starts managing a user, possibly moving them to another organization
+	GroupSyncAdd = "add"
+	// GroupSyncUpdate changes the organization or roles of a managed user
+	GroupSyncUpdate = "update"
+	// GroupSyncRemove removes a managed user, who left every mapped Group, from
+	// their organization and roles
+	GroupSyncRemove = "remove"
+)
+
+// GroupSyncUserStore reads and updates the users group sync manages
+type GroupSyncUserStore interface {
+	ListWithRoles() ([]models.User, error)
+	SetGroupMembership(userID string, orgID *string, roleIDs []string, groupSynced bool) error
+}
+
+// OrganizationLookup finds organizations by name
+type OrganizationLookup interface {
+	GetByName(name string) (*models.Organization, error)
+}
+
+// RoleLookup finds roles by name
+type RoleLookup interface {
+	GetByName(name string) (*models.Role, error)
+}
+
+// GroupSyncChange is a change to one user's organization and roles
+type GroupSyncChange struct {
+	UserID           string   `json:"userId"`
+	Username         string   `json:"username"`
+	Action           string   `json:"action"`
+	FromOrganization string   `json:"fromOrganization,omitempty"`
+	ToOrganization   string   `json:"toOrganization,omitempty"`
+	FromRoles        []string `json:"fromRoles"`
+	ToRoles          []string `json:"toRoles"`
+
+	orgID   *string
+	roleIDs []string
+}
+
+// GroupSyncConflict is a user whose Groups map to more than one organization.
+// Users can only belong to one, so they are left unchanged.
+type GroupSyncConflict struct {
+	Username      string   `json:"username"`
+	Organizations []string `json:"organizations"`
+}
+
+// GroupSyncReport lists what a group sync changes, or would change
+type GroupSyncReport struct {
+	GeneratedAt    time.Time         `json:"generatedAt"`
+	Changes        []GroupSyncChange `json:"changes"`
+	UnchangedUsers int               `json:"unchangedUsers"`
+	// UnknownUsers are Group members without an ssvirt account
+	UnknownUsers []string            `json:"unknownUsers"`
+	Conflicts    []GroupSyncConflict `json:"conflicts"`
+	// Warnings name mappings that refer to missing Groups, organizations or roles
+	Warnings []string `json:"warnings"`
+	// Errors are the changes that could not be applied
+	Errors []string `json:"errors,omitempty"`
+}
+
+// GroupSyncService reconciles organization membership and roles with the
+// members of the OpenShift Groups named in the group mappings
+type GroupSyncService interface {
+	// Plan reports the changes a sync would make without making them
+	Plan(ctx context.Context) (*GroupSyncReport, error)
+	// Sync makes the changes and reports them
+	Sync(ctx context.Context) (*GroupSyncReport, error)
+}
+
+type groupSyncService struct {
+	reader   client.Reader
+	users    GroupSyncUserStore
+	orgs     OrganizationLookup
+	roles    RoleLookup
+	mappings []config.GroupMappingConfig
+}
+
+// NewGroupSyncService creates a GroupSyncService that reads Groups through
+// reader, which should not be a cached client
+func NewGroupSyncService(reader client.Reader, users GroupSyncUserStore, orgs OrganizationLookup, roles RoleLookup, mappings []config.GroupMappingConfig) GroupSyncService {
+	return &groupSyncService{
+		reader:   reader,
+		users:    users,
+		orgs:     orgs,
+		roles:    roles,
+		mappings: mappings,
+	}
+}
+
+// groupSyncTarget is the organization and roles the mappings give a user
+type groupSyncTarget struct {
+	orgs  map[string]*models.Organization
+	roles map[string]*models.Role
+}
+
+// Plan reports the changes a sync would make
+func (s *groupSyncService) Plan(ctx context.Context) (*GroupSyncReport, error) {
+	report := &GroupSyncReport{
+		GeneratedAt:  time.Now().UTC(),
+		Changes:      []GroupSyncChange{},
+		UnknownUsers: []string{},
+		Conflicts:    []GroupSyncConflict{},
+		Warnings:     []string{},
+	}
+
+	var groups userv1.GroupList
+	if err := s.reader.List(ctx, &groups); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, ErrGroupsUnavailable
+		}
+		return nil, fmt.Errorf("failed to list Groups: %w", err)
+	}
+	members := make(map[string][]string, len(groups.Items))
+	for _, group := range groups.Items {
+		members[group.Name] = group.Users
+	}
+
+	targets := make(map[string]*groupSyncTarget)
+	// held are the members of Groups whose mapping cannot be resolved; they are
+	// left unchanged rather than removed until the mapping is fixed
+	held := make(map[string]bool)
+	for _, mapping := range s.mappings {
+		users, found := members[mapping.Group]
+		if !found {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Group %s does not exist", mapping.Group))
+			continue
+		}
+		org, err := s.orgs.GetByName(mapping.Organization)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("organization %s of Group %s does not exist", mapping.Organization, mapping.Group))
+			for _, username := range users {
+				held[username] = true
+			}
+			continue
+		}
+		roles := make([]*models.Role, 0, len(mapping.Roles))
+		for _, name := range mapping.Roles {
+			role, err := s.roles.GetByName(name)
+			if err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("role %s of Group %s does not exist", name, mapping.Group))
+				for _, username := range users {
+					held[username] = true
+				}
+				continue
+			}
+			roles = append(roles, role)
+		}
+		for _, username := range users {
+			target := targets[username]
+			if target == nil {
+				target = &groupSyncTarget{orgs: map[string]*models.Organization{}, roles: map[string]*models.Role{}}
+				targets[username] = target
+			}
+			target.orgs[org.ID] = org
+			for _, role := range roles {
+				target.roles[role.ID] = role
+			}
+		}
+	}
+
+	users, err := s.users.ListWithRoles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	for i := range users {
+		user := &users[i]
+		target, mapped := targets[user.Username]
+		delete(targets, user.Username)
+		switch {
+		case held[user.Username]:
+			report.UnchangedUsers++
+		case mapped && len(target.orgs) > 1:
+			conflict := GroupSyncConflict{Username: user.Username}
+			for _, org := range target.orgs {
+				conflict.Organizations = append(conflict.Organizations, org.Name)
+			}
+			sort.Strings(conflict.Organizations)
+			report.Conflicts = append(report.Conflicts, conflict)
+		case mapped:
+			if change, ok := groupSyncChange(user, target); ok {
+				report.Changes = append(report.Changes, change)
+			} else {
+				report.UnchangedUsers++
+			}
+		case user.GroupSynced:
+			change := newGroupSyncChange(user, GroupSyncRemove)
+			change.ToRoles = []string{}
+			report.Changes = append(report.Changes, change)
+		}
+	}
+	for username := range targets {
+		if held[username] {
+			continue
+		}
+		report.UnknownUsers = append(report.UnknownUsers, username)
+	}
+	sort.Strings(report.UnknownUsers)
+	return report, nil
+}
+
+// Sync makes the changes Plan reports. Users that cannot be updated are
+// listed in the report's errors and retried at the next sync.
+func (s *groupSyncService) Sync(ctx context.Context) (*GroupSyncReport, error) {
+	report, err := s.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range report.Changes {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if err := s.users.SetGroupMembership(change.UserID, change.orgID, change.roleIDs, change.Action != GroupSyncRemove); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to %s user %s: %v", change.Action, change.Username, err))
+		}
+	}
+	return report, nil
+}
+
+// groupSyncChange compares a mapped user with their target, returning the
+// change to make if they differ
+func groupSyncChange(user *models.User, target *groupSyncTarget) (GroupSyncChange, bool) {
+	var org *models.Organization
+	for _, o := range target.orgs {
+		org = o
+	}
+	roleIDs := make([]string, 0, len(target.roles))
+	roleNames := make([]string, 0, len(target.roles))
+	for id, role := range target.roles {
+		roleIDs = append(roleIDs, id)
+		roleNames = append(roleNames, role.Name)
+	}
+	sort.Strings(roleIDs)
+	sort.Strings(roleNames)
+
+	action := GroupSyncUpdate
+	if !user.GroupSynced {
+		action = GroupSyncAdd
+	}
+	change := newGroupSyncChange(user, action)
+	change.ToOrganization = org.Name
+	change.ToRoles = roleNames
+	change.orgID = &org.ID
+	change.roleIDs = roleIDs
+
+	sameOrg := user.OrganizationID != nil && *user.OrganizationID == org.ID
+	if user.GroupSynced && sameOrg && slices.Equal(change.FromRoles, roleNames) {
+		return GroupSyncChange{}, false
+	}
+	return change, true
+}
+
+// newGroupSyncChange describes a change from the user's current organization and roles
+func newGroupSyncChange(user *models.User, action string) GroupSyncChange {
+	change := GroupSyncChange{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Action:    action,
+		FromRoles: make([]string, 0, len(user.Roles)),
+	}
+	if user.Organization != nil {
+		change.FromOrganization = user.Organization.Name
+	}
+	for _, role := range user.Roles {
+		change.FromRoles = append(change.FromRoles, role.Name)
+	}
+	sort.Strings(change.FromRoles)
+	return change
+}
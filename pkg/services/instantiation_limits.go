@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// defaultInstantiationPollInterval is how often a queued instantiation checks
+// whether it may start
+const defaultInstantiationPollInterval = time.Second
+
+// ErrInstantiationLimitReached is returned when a VDC or organization already
+// has as many vApps instantiating as it may
+var ErrInstantiationLimitReached = errors.New("too many vApps instantiating")
+
+// InstantiationCounter counts the vApps that are instantiating
+type InstantiationCounter interface {
+	CountInstantiatingByVDC(ctx context.Context, vdcID string) (int64, error)
+	CountInstantiatingByOrg(ctx context.Context, orgID string) (int64, error)
+}
+
+// InstantiationLimiter caps how many vApps may be instantiating at once in a
+// VDC and across an organization's VDCs. Requests over a cap wait up to the
+// queue timeout for instantiations to finish before they are refused.
+//
+// Admissions are serialized within the API server; with several replicas a
+// burst of concurrent requests can briefly exceed a cap by one per replica.
+type InstantiationLimiter struct {
+	counter      InstantiationCounter
+	perVDC       int
+	perOrg       int
+	queueTimeout time.Duration
+	pollInterval time.Duration
+
+	mu sync.Mutex
+}
+
+// NewInstantiationLimiter creates an InstantiationLimiter. A cap of 0 is
+// unlimited; NewInstantiationLimiter returns nil when both are.
+func NewInstantiationLimiter(counter InstantiationCounter, perVDC, perOrg int, queueTimeout time.Duration) *InstantiationLimiter {
+	if perVDC <= 0 && perOrg <= 0 {
+		return nil
+	}
+	return &InstantiationLimiter{
+		counter:      counter,
+		perVDC:       perVDC,
+		perOrg:       perOrg,
+		queueTimeout: queueTimeout,
+		pollInterval: defaultInstantiationPollInterval,
+	}
+}
+
+// Admit waits until the VDC and its organization are under their caps and
+// then calls create, which must record the new vApp as instantiating. It
+// returns an error wrapping ErrInstantiationLimitReached if no slot frees up
+// within the queue timeout.
+func (l *InstantiationLimiter) Admit(ctx context.Context, vdc *models.VDC, create func() error) error {
+	deadline := time.Now().Add(l.queueTimeout)
+	for {
+		admitted, reason, err := l.tryAdmit(ctx, vdc, create)
+		if err != nil || admitted {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %s", ErrInstantiationLimitReached, reason)
+		}
+		wait := min(l.pollInterval, time.Until(deadline))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// tryAdmit calls create if the VDC and organization are under their caps,
+// otherwise returning which cap was reached
+func (l *InstantiationLimiter) tryAdmit(ctx context.Context, vdc *models.VDC, create func() error) (bool, string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.perVDC > 0 {
+		count, err := l.counter.CountInstantiatingByVDC(ctx, vdc.ID)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to count instantiating vApps in VDC %s: %w", vdc.ID, err)
+		}
+		if count >= int64(l.perVDC) {
+			return false, fmt.Sprintf("VDC %s already has %d vApps instantiating, the limit is %d", vdc.Name, count, l.perVDC), nil
+		}
+	}
+	if l.perOrg > 0 {
+		count, err := l.counter.CountInstantiatingByOrg(ctx, vdc.OrganizationID)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to count instantiating vApps in organization %s: %w", vdc.OrganizationID, err)
+		}
+		if count >= int64(l.perOrg) {
+			return false, fmt.Sprintf("the organization already has %d vApps instantiating, the limit is %d", count, l.perOrg), nil
+		}
+	}
+	return true, "", create()
+}
@@ -8,12 +8,24 @@ import (
 
 // TemplateServiceInterface defines the interface for template service operations
 type TemplateServiceInterface interface {
-	ListCatalogItems(ctx context.Context, catalogID string, limit, offset int) ([]models.CatalogItem, error)
-	CountCatalogItems(ctx context.Context, catalogID string) (int64, error)
-	GetCatalogItem(ctx context.Context, catalogID, itemID string) (*models.CatalogItem, error)
+	CatalogItemSource
 	Start(ctx context.Context) error
 }
 
+// CatalogItemSource provides the OpenShift Templates that back catalog items
+type CatalogItemSource interface {
+	// ListCatalogItemRecords returns a record for every template offered as a catalog item
+	ListCatalogItemRecords(ctx context.Context) ([]models.CatalogItemRecord, error)
+	// OnTemplateChange registers a callback invoked whenever a template is added, updated or deleted
+	OnTemplateChange(ctx context.Context, callback func()) error
+}
+
+// CatalogItemStore persists catalog item records
+type CatalogItemStore interface {
+	// SyncTemplates replaces the stored records with the given set, returning the number of rows changed
+	SyncTemplates(ctx context.Context, records []models.CatalogItemRecord) (int, error)
+}
+
 // KubernetesServiceInterface defines the interface for Kubernetes operations
 type KubernetesServiceInterface interface {
 	KubernetesService
@@ -3,21 +3,30 @@ package services
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
 	templatev1 "github.com/openshift/api/template/v1"
+	userv1 "github.com/openshift/api/user/v1"
 	kubevirtv1 "kubevirt.io/api/core/v1"
+	exportv1beta1 "kubevirt.io/api/export/v1beta1"
+	snapshotv1beta1 "kubevirt.io/api/snapshot/v1beta1"
 
+	"github.com/mhrivnak/ssvirt/pkg/database/consistency"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 )
 
@@ -39,6 +48,7 @@ type KubernetesService interface {
 	UpdateNamespaceForVDC(ctx context.Context, vdc *models.VDC, org *models.Organization) error
 	DeleteNamespaceForVDC(ctx context.Context, vdc *models.VDC) error
 	EnsureNamespaceForVDC(ctx context.Context, vdc *models.VDC, org *models.Organization) error
+	NamespaceExists(ctx context.Context, name string) (bool, error)
 
 	// Template instantiation support
 	GetTemplate(ctx context.Context, name string) (*TemplateInfo, error)
@@ -50,6 +60,14 @@ type KubernetesService interface {
 
 	// Resource management
 	EnsureNamespaceResources(ctx context.Context, namespace string, vdc *models.VDC) error
+	SetDefaultObjectQuota(quota models.ObjectQuota)
+
+	// Diagnostics for VMs that fail to start
+	GetVMDiagnostics(ctx context.Context, namespace, vmName string) (*VMDiagnostics, error)
+	GetVMConsoleLog(ctx context.Context, namespace, vmName string, opts ConsoleLogOptions) (io.ReadCloser, error)
+
+	// Live state of VDC namespaces for administrators
+	GetVDCInfrastructure(ctx context.Context, namespace string) (*VDCInfrastructure, error)
 
 	// Client access for power management operations
 	GetClient() client.Client
@@ -92,7 +110,22 @@ type TemplateInstanceRequest struct {
 	Namespace    string                  `json:"namespace"`
 	Name         string                  `json:"name"`
 	Parameters   []TemplateInstanceParam `json:"parameters,omitempty"`
-	Labels       map[string]string       `json:"labels,omitempty"`
+	// Labels and Annotations are set on the TemplateInstance, its VirtualMachines
+	// and their pods
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// SSHPublicKeys are injected into every VM through cloud-init
+	SSHPublicKeys []string `json:"sshPublicKeys,omitempty"`
+	// NetworkInterfaces customize the NICs declared by the template's VMs
+	NetworkInterfaces []NetworkInterface `json:"networkInterfaces,omitempty"`
+	// Disks select the tier and storage profile of disks the template's VMs declare
+	Disks []Disk `json:"disks,omitempty"`
+	// DiskDefaults apply to the disks not listed in Disks
+	DiskDefaults *DiskDefaults `json:"diskDefaults,omitempty"`
+	// GoldenImages name the golden image DataVolumes ready in the namespace;
+	// DataVolume templates importing one of them clone it instead. They are
+	// not recorded for retries, which import the images again.
+	GoldenImages []string `json:"-"`
 }
 
 // TemplateInstanceParam represents a parameter for template instantiation
@@ -134,7 +167,8 @@ type kubernetesService struct {
 	client       client.Client
 	cache        cache.Cache
 	scheme       *runtime.Scheme
-	directClient client.Client // For write operations
+	directClient client.Client        // For write operations
+	clientset    kubernetes.Interface // For subresources such as pod logs
 	started      bool
 	cacheCtx     context.Context
 	cacheCancel  context.CancelFunc
@@ -142,15 +176,59 @@ type kubernetesService struct {
 
 	// Configuration
 	templateNamespace string
-	cacheResync       time.Duration
+	cacheSyncTimeout  time.Duration
+	objectQuota       models.ObjectQuota
+}
+
+// Annotations mirrored onto the VirtualMachine resource so cluster operators
+// see the same naming as CloudAPI users, and read back by the VM controller
+const (
+	VMDisplayNameAnnotation = "ssvirt.io/display-name"
+	VMDescriptionAnnotation = "ssvirt.io/description"
+)
+
+// Cache defaults used when KubernetesServiceOptions leaves them unset
+const (
+	defaultCacheResync      = 10 * time.Minute
+	defaultCacheSyncTimeout = 30 * time.Second
+)
+
+// KubernetesServiceOptions tunes the cache the KubernetesService reads through
+type KubernetesServiceOptions struct {
+	// CacheResync is how often cached objects are re-listed; 0 uses 10 minutes
+	CacheResync time.Duration
+	// CacheSyncTimeout bounds how long Start waits for the cache to sync before
+	// falling back to direct API calls; 0 uses 30 seconds
+	CacheSyncTimeout time.Duration
+	// NamespaceSelector is a label selector that restricts the cache to the
+	// template namespace and the namespaces matching it when the service is
+	// created, so the API server can run without cluster-wide list and watch
+	// permissions. Reads in other namespaces, and of Namespaces themselves, go
+	// directly to the API server. Empty caches every namespace.
+	NamespaceSelector string
+	// Writes throttles writes to each namespace; a zero QPS leaves them
+	// unthrottled
+	Writes NamespaceWriteLimits
 }
 
 // NewKubernetesService creates a new Kubernetes service
-func NewKubernetesService(templateNamespace string, logger Logger) (KubernetesService, error) {
+func NewKubernetesService(templateNamespace string, logger Logger, opts KubernetesServiceOptions) (KubernetesService, error) {
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubernetes config: %w", err)
 	}
+	return NewKubernetesServiceForConfig(cfg, templateNamespace, logger, opts)
+}
+
+// NewKubernetesServiceForConfig creates a new Kubernetes service connected to the
+// cluster described by cfg
+func NewKubernetesServiceForConfig(cfg *rest.Config, templateNamespace string, logger Logger, opts KubernetesServiceOptions) (KubernetesService, error) {
+	if opts.CacheResync <= 0 {
+		opts.CacheResync = defaultCacheResync
+	}
+	if opts.CacheSyncTimeout <= 0 {
+		opts.CacheSyncTimeout = defaultCacheSyncTimeout
+	}
 
 	scheme := runtime.NewScheme()
 
@@ -167,41 +245,90 @@ func NewKubernetesService(templateNamespace string, logger Logger) (KubernetesSe
 		return nil, fmt.Errorf("failed to add kubevirt/v1 to scheme: %w", err)
 	}
 
+	if err := userv1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to add user/v1 to scheme: %w", err)
+	}
+
+	if err := networkingv1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to add networking/v1 to scheme: %w", err)
+	}
+
+	if err := snapshotv1beta1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to add snapshot.kubevirt.io/v1beta1 to scheme: %w", err)
+	}
+
+	if err := exportv1beta1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to add export.kubevirt.io/v1beta1 to scheme: %w", err)
+	}
+
+	// Create direct client for write operations
+	directClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create direct client: %w", err)
+	}
+
 	// Create cache for read operations
-	syncPeriod := 10 * time.Minute
-	cache, err := cache.New(cfg, cache.Options{
+	cacheOptions := cache.Options{
 		Scheme:     scheme,
-		SyncPeriod: &syncPeriod,
-	})
+		SyncPeriod: &opts.CacheResync,
+	}
+	// Groups are only read by the group sync report, which should not start a
+	// cluster-wide informer
+	uncached := []client.Object{&userv1.Group{}}
+	var cachedNamespaces map[string]bool
+	if opts.NamespaceSelector != "" {
+		cachedNamespaces, err = selectCacheNamespaces(directClient, templateNamespace, opts)
+		if err != nil {
+			return nil, err
+		}
+		cacheOptions.DefaultNamespaces = make(map[string]cache.Config, len(cachedNamespaces))
+		for namespace := range cachedNamespaces {
+			cacheOptions.DefaultNamespaces[namespace] = cache.Config{}
+		}
+		// Watching Namespaces needs cluster-wide permissions
+		uncached = append(uncached, &corev1.Namespace{})
+		logger.Printf("Kubernetes cache restricted to %d namespaces matching %q", len(cachedNamespaces), opts.NamespaceSelector)
+	}
+	cache, err := cache.New(cfg, cacheOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cache: %w", err)
 	}
+	var cacheReader client.Reader = cache
+	if cachedNamespaces != nil {
+		cacheReader = &namespacedCacheReader{cache: cache, direct: directClient, namespaces: cachedNamespaces}
+	}
 
-	// Create direct client for write operations
-	directClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	// Create clientset for subresources the controller-runtime client does not support
+	clientset, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create direct client: %w", err)
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
 
 	// Create cached client for read operations
 	cachedClient, err := client.New(cfg, client.Options{
 		Scheme: scheme,
 		Cache: &client.CacheOptions{
-			Reader: cache,
+			Reader:     cacheReader,
+			DisableFor: uncached,
 		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cached client: %w", err)
 	}
 
+	// Both clients write straight to the API server, so they share the limits
+	writes := NewNamespaceWriteLimiter(opts.Writes)
+
 	return &kubernetesService{
-		client:            cachedClient,
+		client:            NewThrottledClient(cachedClient, writes),
 		cache:             cache,
 		scheme:            scheme,
-		directClient:      directClient,
+		directClient:      NewThrottledClient(directClient, writes),
+		clientset:         clientset,
 		logger:            logger,
 		templateNamespace: templateNamespace,
-		cacheResync:       10 * time.Minute,
+		cacheSyncTimeout:  opts.CacheSyncTimeout,
+		objectQuota:       DefaultObjectQuota(),
 	}, nil
 }
 
@@ -224,7 +351,7 @@ func (k *kubernetesService) Start(ctx context.Context) error {
 	}()
 
 	// Wait for cache sync with timeout
-	syncCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	syncCtx, cancel := context.WithTimeout(ctx, k.cacheSyncTimeout)
 	defer cancel()
 
 	if !k.cache.WaitForCacheSync(syncCtx) {
@@ -313,27 +440,28 @@ func (k *kubernetesService) UpdateNamespaceForVDC(ctx context.Context, vdc *mode
 	}
 
 	// Update labels and annotations
-	if namespace.Labels == nil {
-		namespace.Labels = make(map[string]string)
-	}
-	if namespace.Annotations == nil {
-		namespace.Annotations = make(map[string]string)
-	}
-
-	namespace.Labels["ssvirt.io/organization"] = k.sanitizeLabelValue(org.Name)
-	namespace.Labels["ssvirt.io/organization-id"] = k.sanitizeLabelValue(extractUUIDFromURN(org.ID))
-	namespace.Labels["ssvirt.io/vdc"] = k.sanitizeLabelValue(vdc.Name)
-	namespace.Labels["ssvirt.io/vdc-id"] = k.sanitizeLabelValue(extractUUIDFromURN(vdc.ID))
-	namespace.Labels["app.kubernetes.io/managed-by"] = "ssvirt"
-	namespace.Labels["app.kubernetes.io/component"] = "vdc"
-
-	namespace.Annotations["ssvirt.io/organization-display-name"] = org.DisplayName
-	namespace.Annotations["ssvirt.io/organization-description"] = org.Description
-	namespace.Annotations["ssvirt.io/organization-urn"] = org.ID
-	namespace.Annotations["ssvirt.io/vdc-description"] = vdc.Description
-	namespace.Annotations["ssvirt.io/vdc-urn"] = vdc.ID
+	err = k.updateWithRetry(ctx, namespace, func() {
+		if namespace.Labels == nil {
+			namespace.Labels = make(map[string]string)
+		}
+		if namespace.Annotations == nil {
+			namespace.Annotations = make(map[string]string)
+		}
 
-	if err := k.directClient.Update(ctx, namespace); err != nil {
+		namespace.Labels["ssvirt.io/organization"] = k.sanitizeLabelValue(org.Name)
+		namespace.Labels["ssvirt.io/organization-id"] = k.sanitizeLabelValue(extractUUIDFromURN(org.ID))
+		namespace.Labels["ssvirt.io/vdc"] = k.sanitizeLabelValue(vdc.Name)
+		namespace.Labels["ssvirt.io/vdc-id"] = k.sanitizeLabelValue(extractUUIDFromURN(vdc.ID))
+		namespace.Labels["app.kubernetes.io/managed-by"] = "ssvirt"
+		namespace.Labels["app.kubernetes.io/component"] = "vdc"
+
+		namespace.Annotations["ssvirt.io/organization-display-name"] = org.DisplayName
+		namespace.Annotations["ssvirt.io/organization-description"] = org.Description
+		namespace.Annotations["ssvirt.io/organization-urn"] = org.ID
+		namespace.Annotations["ssvirt.io/vdc-description"] = vdc.Description
+		namespace.Annotations["ssvirt.io/vdc-urn"] = vdc.ID
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update namespace %s: %w", vdc.Namespace, err)
 	}
 
@@ -360,6 +488,20 @@ func (k *kubernetesService) DeleteNamespaceForVDC(ctx context.Context, vdc *mode
 	return nil
 }
 
+// NamespaceExists reports whether a namespace exists in the cluster, including
+// one that is still terminating
+func (k *kubernetesService) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	namespace := &corev1.Namespace{}
+	err := k.directClient.Get(ctx, client.ObjectKey{Name: name}, namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check namespace %s: %w", name, err)
+	}
+	return true, nil
+}
+
 // EnsureNamespaceForVDC ensures the namespace exists for a VDC
 func (k *kubernetesService) EnsureNamespaceForVDC(ctx context.Context, vdc *models.VDC, org *models.Organization) error {
 	if vdc.Namespace == "" {
@@ -388,6 +530,50 @@ func (k *kubernetesService) EnsureNamespaceResources(ctx context.Context, namesp
 	return nil
 }
 
+// withGraceAllowance returns a compute limit raised by a grace percentage
+func withGraceAllowance(limit, gracePercent int) int {
+	return limit * (100 + gracePercent) / 100
+}
+
+// DefaultObjectQuota returns the object counts in VDC ResourceQuotas when no
+// profile is configured
+func DefaultObjectQuota() models.ObjectQuota {
+	count := func(n int) *int { return &n }
+	return models.ObjectQuota{
+		Pods:                   count(50),
+		PersistentVolumeClaims: count(20),
+		Services:               count(10),
+		Secrets:                count(50),
+		ConfigMaps:             count(50),
+	}
+}
+
+// SetDefaultObjectQuota sets the object counts in VDC ResourceQuotas for VDCs
+// that do not override them
+func (k *kubernetesService) SetDefaultObjectQuota(quota models.ObjectQuota) {
+	k.objectQuota = quota
+}
+
+// ObjectQuotaLimits returns the ResourceQuota limits of a VDC's object counts:
+// the defaults with the VDC's overrides applied. Unset and zero counts are left
+// unlimited.
+func ObjectQuotaLimits(defaults models.ObjectQuota, vdc *models.VDC) corev1.ResourceList {
+	quota := defaults.WithOverrides(vdc.ObjectQuota())
+	limits := corev1.ResourceList{}
+	for name, count := range map[corev1.ResourceName]*int{
+		corev1.ResourcePods:                   quota.Pods,
+		corev1.ResourcePersistentVolumeClaims: quota.PersistentVolumeClaims,
+		corev1.ResourceServices:               quota.Services,
+		corev1.ResourceSecrets:                quota.Secrets,
+		corev1.ResourceConfigMaps:             quota.ConfigMaps,
+	} {
+		if count != nil && *count > 0 {
+			limits[name] = *resource.NewQuantity(int64(*count), resource.DecimalSI)
+		}
+	}
+	return limits
+}
+
 func (k *kubernetesService) createResourceQuota(ctx context.Context, namespace string, vdc *models.VDC) error {
 	quota := &corev1.ResourceQuota{
 		ObjectMeta: metav1.ObjectMeta{
@@ -406,29 +592,26 @@ func (k *kubernetesService) createResourceQuota(ctx context.Context, namespace s
 			},
 		},
 		Spec: corev1.ResourceQuotaSpec{
-			Hard: corev1.ResourceList{
-				// Set reasonable defaults
-				corev1.ResourcePods:                   resource.MustParse("50"),
-				corev1.ResourcePersistentVolumeClaims: resource.MustParse("20"),
-				corev1.ResourceServices:               resource.MustParse("10"),
-				corev1.ResourceSecrets:                resource.MustParse("50"),
-				corev1.ResourceConfigMaps:             resource.MustParse("50"),
-			},
+			Hard: ObjectQuotaLimits(k.objectQuota, vdc),
 		},
 	}
 
-	// Add VDC-specific limits if configured
-	if vdc.CPULimit > 0 {
+	// Add VDC-specific limits if configured. The namespace quota is the absolute
+	// ceiling, so it includes the VDC's grace allowance; the API's quota checks
+	// decide when the allowance may be used.
+	cpuLimit := withGraceAllowance(vdc.CPULimit, vdc.QuotaGracePercent)
+	memoryLimitMB := withGraceAllowance(vdc.MemoryLimit, vdc.QuotaGracePercent)
+	if cpuLimit > 0 {
 		// Only set CPU quotas when VDC uses Kubernetes-compatible units
 		switch vdc.CPUUnits {
 		case "cores":
 			// Convert cores to millicores
-			cpuLimitMillicores := fmt.Sprintf("%dm", vdc.CPULimit*1000)
+			cpuLimitMillicores := fmt.Sprintf("%dm", cpuLimit*1000)
 			quota.Spec.Hard[corev1.ResourceRequestsCPU] = resource.MustParse(cpuLimitMillicores)
 			quota.Spec.Hard[corev1.ResourceLimitsCPU] = resource.MustParse(cpuLimitMillicores)
 		case "millicores":
 			// Direct millicores value
-			cpuLimitMillicores := fmt.Sprintf("%dm", vdc.CPULimit)
+			cpuLimitMillicores := fmt.Sprintf("%dm", cpuLimit)
 			quota.Spec.Hard[corev1.ResourceRequestsCPU] = resource.MustParse(cpuLimitMillicores)
 			quota.Spec.Hard[corev1.ResourceLimitsCPU] = resource.MustParse(cpuLimitMillicores)
 		case "MHz":
@@ -440,8 +623,8 @@ func (k *kubernetesService) createResourceQuota(ctx context.Context, namespace s
 		}
 	}
 
-	if vdc.MemoryLimit > 0 {
-		memoryLimit := fmt.Sprintf("%dMi", vdc.MemoryLimit)
+	if memoryLimitMB > 0 {
+		memoryLimit := fmt.Sprintf("%dMi", memoryLimitMB)
 		quota.Spec.Hard[corev1.ResourceRequestsMemory] = resource.MustParse(memoryLimit)
 		quota.Spec.Hard[corev1.ResourceLimitsMemory] = resource.MustParse(memoryLimit)
 	}
@@ -457,16 +640,45 @@ func (k *kubernetesService) createResourceQuota(ctx context.Context, namespace s
 	}
 
 	// Update existing quota
-	existingQuota.Spec = quota.Spec
-	existingQuota.Labels = quota.Labels
-	return k.directClient.Update(ctx, existingQuota)
+	return k.updateWithRetry(ctx, existingQuota, func() {
+		existingQuota.Spec = quota.Spec
+		existingQuota.Labels = quota.Labels
+	})
+}
+
+// updateWithRetry applies mutate to obj, which the caller has read, and
+// updates it. When another writer updated obj first, obj is read again from
+// the API server, bypassing the cache that may not have seen that write yet,
+// and mutate is reapplied, so losing the race does not fail the caller.
+func (k *kubernetesService) updateWithRetry(ctx context.Context, obj client.Object, mutate func()) error {
+	key := client.ObjectKeyFromObject(obj)
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if !first {
+			if err := k.directClient.Get(ctx, key, obj); err != nil {
+				return err
+			}
+		}
+		first = false
+		mutate()
+		return k.directClient.Update(ctx, obj)
+	})
+}
+
+// reader returns the client to read with: the cached client, or the direct
+// client for reads that must see every committed write
+func (k *kubernetesService) reader(ctx context.Context) client.Reader {
+	if consistency.IsStrong(ctx) {
+		return k.directClient
+	}
+	return k.client
 }
 
 // GetTemplate retrieves a specific template by name
 func (k *kubernetesService) GetTemplate(ctx context.Context, name string) (*TemplateInfo, error) {
 	template := &templatev1.Template{}
 
-	err := k.client.Get(ctx, client.ObjectKey{
+	err := k.reader(ctx).Get(ctx, client.ObjectKey{
 		Namespace: k.templateNamespace,
 		Name:      name,
 	}, template)
@@ -548,6 +760,55 @@ func (k *kubernetesService) CreateTemplateInstance(ctx context.Context, req *Tem
 		return nil, fmt.Errorf("failed to fetch template %s/%s: %w", k.templateNamespace, req.TemplateName, err)
 	}
 
+	// Keep VMs off nodes of another architecture in mixed-architecture clusters
+	if arch := TemplateArchitecture(fullTemplate); arch != "" {
+		if err := AddArchitectureAffinity(fullTemplate, arch); err != nil {
+			return nil, fmt.Errorf("failed to add architecture affinity to template %s: %w", req.TemplateName, err)
+		}
+	}
+
+	if len(req.NetworkInterfaces) > 0 {
+		if err := ConfigureNetworkInterfaces(fullTemplate, req.NetworkInterfaces); err != nil {
+			return nil, fmt.Errorf("failed to configure network interfaces for template %s: %w", req.TemplateName, err)
+		}
+	}
+
+	if len(req.Disks) > 0 || req.DiskDefaults != nil {
+		var defaults DiskDefaults
+		if req.DiskDefaults != nil {
+			defaults = *req.DiskDefaults
+		}
+		if err := ConfigureDisks(fullTemplate, req.Disks, defaults); err != nil {
+			return nil, fmt.Errorf("failed to configure disks for template %s: %w", req.TemplateName, err)
+		}
+	}
+
+	// After the disks are configured, so disks made ephemeral keep their image
+	if len(req.GoldenImages) > 0 {
+		if err := UseGoldenImages(fullTemplate, req.Namespace, req.GoldenImages); err != nil {
+			return nil, fmt.Errorf("failed to use golden images for template %s: %w", req.TemplateName, err)
+		}
+	}
+
+	if len(req.Labels) > 0 || len(req.Annotations) > 0 {
+		if err := AddPropagatedMetadata(fullTemplate, req.Labels, req.Annotations); err != nil {
+			return nil, fmt.Errorf("failed to add labels to template %s: %w", req.TemplateName, err)
+		}
+	}
+
+	if err := AddFirmwareIdentity(fullTemplate); err != nil {
+		return nil, fmt.Errorf("failed to add firmware identity to template %s: %w", req.TemplateName, err)
+	}
+
+	if len(req.SSHPublicKeys) > 0 {
+		if err := k.createSSHKeySecret(ctx, req); err != nil {
+			return nil, fmt.Errorf("failed to create SSH key secret: %w", err)
+		}
+		if err := AddSSHKeyAccessCredentials(fullTemplate, req.Name+"-ssh-keys"); err != nil {
+			return nil, fmt.Errorf("failed to add SSH keys to template %s: %w", req.TemplateName, err)
+		}
+	}
+
 	// Create TemplateInstance resource
 	templateInstance := &templatev1.TemplateInstance{
 		ObjectMeta: metav1.ObjectMeta{
@@ -566,10 +827,16 @@ func (k *kubernetesService) CreateTemplateInstance(ctx context.Context, req *Tem
 		},
 	}
 
-	// Add custom labels
+	// Add custom labels and annotations
 	for key, value := range req.Labels {
 		templateInstance.Labels[key] = value
 	}
+	if len(req.Annotations) > 0 {
+		templateInstance.Annotations = make(map[string]string, len(req.Annotations))
+		for key, value := range req.Annotations {
+			templateInstance.Annotations[key] = value
+		}
+	}
 
 	// Create the template instance
 	if err := k.directClient.Create(ctx, templateInstance); err != nil {
@@ -581,6 +848,11 @@ func (k *kubernetesService) CreateTemplateInstance(ctx context.Context, req *Tem
 		// Log warning but don't fail the creation
 		k.logger.Printf("Warning: Failed to set owner reference on secret %s-%s: %v", req.Name, "params", err)
 	}
+	if len(req.SSHPublicKeys) > 0 {
+		if err := k.addOwnerReferenceToSecret(ctx, req.Name+"-ssh-keys", req.Namespace, templateInstance); err != nil {
+			k.logger.Printf("Warning: Failed to set owner reference on secret %s-%s: %v", req.Name, "ssh-keys", err)
+		}
+	}
 
 	return &TemplateInstanceResult{
 		Name:      templateInstance.Name,
@@ -612,6 +884,29 @@ func (k *kubernetesService) createParameterSecret(ctx context.Context, req *Temp
 	return k.directClient.Create(ctx, secret)
 }
 
+// createSSHKeySecret stores the SSH public keys to inject, one key per entry as
+// KubeVirt access credentials expect
+func (k *kubernetesService) createSSHKeySecret(ctx context.Context, req *TemplateInstanceRequest) error {
+	data := make(map[string]string, len(req.SSHPublicKeys))
+	for i, key := range req.SSHPublicKeys {
+		data[fmt.Sprintf("key%d", i+1)] = key
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      req.Name + "-ssh-keys",
+			Namespace: req.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "ssvirt",
+				"ssvirt.io/template-instance":  req.Name,
+			},
+		},
+		StringData: data,
+	}
+
+	return k.directClient.Create(ctx, secret)
+}
+
 // addOwnerReferenceToSecret adds an OwnerReference to a secret for garbage collection
 func (k *kubernetesService) addOwnerReferenceToSecret(ctx context.Context, secretName, namespace string, templateInstance *templatev1.TemplateInstance) error {
 	secret := &corev1.Secret{}
@@ -625,22 +920,22 @@ func (k *kubernetesService) addOwnerReferenceToSecret(ctx context.Context, secre
 
 	// Add OwnerReference
 	isController := true
-	secret.OwnerReferences = append(secret.OwnerReferences, metav1.OwnerReference{
-		APIVersion:         templateInstance.APIVersion,
-		Kind:               templateInstance.Kind,
-		Name:               templateInstance.Name,
-		UID:                templateInstance.UID,
-		Controller:         &isController,
-		BlockOwnerDeletion: &isController,
+	return k.updateWithRetry(ctx, secret, func() {
+		secret.OwnerReferences = append(secret.OwnerReferences, metav1.OwnerReference{
+			APIVersion:         templateInstance.APIVersion,
+			Kind:               templateInstance.Kind,
+			Name:               templateInstance.Name,
+			UID:                templateInstance.UID,
+			Controller:         &isController,
+			BlockOwnerDeletion: &isController,
+		})
 	})
-
-	return k.directClient.Update(ctx, secret)
 }
 
 // GetTemplateInstance retrieves the status of a template instance
 func (k *kubernetesService) GetTemplateInstance(ctx context.Context, namespace, name string) (*TemplateInstanceStatus, error) {
 	templateInstance := &templatev1.TemplateInstance{}
-	err := k.client.Get(ctx, client.ObjectKey{
+	err := k.reader(ctx).Get(ctx, client.ObjectKey{
 		Namespace: namespace,
 		Name:      name,
 	}, templateInstance)
@@ -767,6 +1062,24 @@ func (k *kubernetesService) sanitizeLabelValue(value string) string {
 	return sanitized
 }
 
+// GetVMDiagnostics collects the conditions and recent events of a VM. It reads
+// through the direct client so events are not cached cluster-wide.
+func (k *kubernetesService) GetVMDiagnostics(ctx context.Context, namespace, vmName string) (*VMDiagnostics, error) {
+	return CollectVMDiagnostics(ctx, k.directClient, namespace, vmName)
+}
+
+// GetVMConsoleLog opens the guest serial console log of a VM
+func (k *kubernetesService) GetVMConsoleLog(ctx context.Context, namespace, vmName string, opts ConsoleLogOptions) (io.ReadCloser, error) {
+	return StreamVMConsoleLog(ctx, k.directClient, k.clientset.CoreV1(), namespace, vmName, opts)
+}
+
+// GetVDCInfrastructure reads the live namespace, quotas, LimitRanges and
+// NetworkPolicies of a VDC. It reads through the direct client so the status
+// is current and no informers are started for these kinds.
+func (k *kubernetesService) GetVDCInfrastructure(ctx context.Context, namespace string) (*VDCInfrastructure, error) {
+	return CollectVDCInfrastructure(ctx, k.directClient, namespace)
+}
+
 // GetClient returns the Kubernetes client for power management operations
 func (k *kubernetesService) GetClient() client.Client {
 	return k.client
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// selectCacheNamespaces returns the namespaces a cache scoped by
+// opts.NamespaceSelector covers: the template namespace and the namespaces
+// matching the selector
+func selectCacheNamespaces(reader client.Reader, templateNamespace string, opts KubernetesServiceOptions) (map[string]bool, error) {
+	selector, err := labels.Parse(opts.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache namespace selector %q: %w", opts.NamespaceSelector, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.CacheSyncTimeout)
+	defer cancel()
+	var list corev1.NamespaceList
+	if err := reader.List(ctx, &list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces matching %q: %w", opts.NamespaceSelector, err)
+	}
+
+	namespaces := map[string]bool{templateNamespace: true}
+	for _, namespace := range list.Items {
+		namespaces[namespace.Name] = true
+	}
+	return namespaces, nil
+}
+
+// namespacedCacheReader reads through a cache restricted to a set of
+// namespaces, falling back to direct API calls for objects in other
+// namespaces, such as those of VDCs created after the cache was started.
+// Cluster-scoped reads and lists across all namespaces use the cache.
+type namespacedCacheReader struct {
+	cache      client.Reader
+	direct     client.Reader
+	namespaces map[string]bool
+}
+
+// readerFor returns the reader serving the namespace
+func (r *namespacedCacheReader) readerFor(namespace string) client.Reader {
+	if namespace == "" || r.namespaces[namespace] {
+		return r.cache
+	}
+	return r.direct
+}
+
+// Get reads the object from the cache when its namespace is cached
+func (r *namespacedCacheReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return r.readerFor(key.Namespace).Get(ctx, key, obj, opts...)
+}
+
+// List reads the objects from the cache when their namespace is cached
+func (r *namespacedCacheReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	return r.readerFor(listOpts.Namespace).List(ctx, list, opts...)
+}
+
+var _ client.Reader = &namespacedCacheReader{}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func namespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func configMap(namespace, name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+func TestSelectCacheNamespaces(t *testing.T) {
+	managed := map[string]string{"app.kubernetes.io/managed-by": "ssvirt"}
+	reader := fake.NewClientBuilder().WithObjects(
+		namespace("vdc-acme-dev", managed),
+		namespace("vdc-acme-prod", managed),
+		namespace("kube-system", nil),
+	).Build()
+	opts := KubernetesServiceOptions{NamespaceSelector: "app.kubernetes.io/managed-by=ssvirt", CacheSyncTimeout: time.Second}
+
+	namespaces, err := selectCacheNamespaces(reader, "openshift", opts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"openshift": true, "vdc-acme-dev": true, "vdc-acme-prod": true}, namespaces)
+
+	opts.NamespaceSelector = "app.kubernetes.io/managed-by in ("
+	_, err = selectCacheNamespaces(reader, "openshift", opts)
+	assert.ErrorContains(t, err, "invalid cache namespace selector")
+}
+
+func TestNamespacedCacheReader(t *testing.T) {
+	ctx := context.Background()
+	cached := fake.NewClientBuilder().WithObjects(configMap("vdc-acme-dev", "cached"), namespace("vdc-acme-dev", nil)).Build()
+	direct := fake.NewClientBuilder().WithObjects(configMap("vdc-acme-new", "direct"), configMap("vdc-acme-dev", "uncached")).Build()
+	reader := &namespacedCacheReader{cache: cached, direct: direct, namespaces: map[string]bool{"vdc-acme-dev": true}}
+
+	// Cached namespaces and cluster-scoped objects are read from the cache
+	assert.NoError(t, reader.Get(ctx, client.ObjectKey{Namespace: "vdc-acme-dev", Name: "cached"}, &corev1.ConfigMap{}))
+	assert.Error(t, reader.Get(ctx, client.ObjectKey{Namespace: "vdc-acme-dev", Name: "uncached"}, &corev1.ConfigMap{}))
+	assert.NoError(t, reader.Get(ctx, client.ObjectKey{Name: "vdc-acme-dev"}, &corev1.Namespace{}))
+
+	// Namespaces created after the cache started are read directly
+	assert.NoError(t, reader.Get(ctx, client.ObjectKey{Namespace: "vdc-acme-new", Name: "direct"}, &corev1.ConfigMap{}))
+
+	var list corev1.ConfigMapList
+	require.NoError(t, reader.List(ctx, &list, client.InNamespace("vdc-acme-new")))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "direct", list.Items[0].Name)
+
+	require.NoError(t, reader.List(ctx, &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "cached", list.Items[0].Name)
+}
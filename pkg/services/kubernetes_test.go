@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/consistency"
+)
+
+func TestUpdateWithRetry(t *testing.T) {
+	ctx := context.Background()
+	cached := fake.NewClientBuilder().WithObjects(namespace("vdc-acme-dev", nil)).Build()
+	direct := fake.NewClientBuilder().WithObjects(namespace("vdc-acme-dev", nil)).Build()
+	k := &kubernetesService{client: cached, directClient: direct}
+
+	// Another writer updates the namespace before the cache sees it
+	var current corev1.Namespace
+	require.NoError(t, direct.Get(ctx, client.ObjectKey{Name: "vdc-acme-dev"}, &current))
+	current.Labels = map[string]string{"other-writer": "true"}
+	require.NoError(t, direct.Update(ctx, &current))
+
+	stale := &corev1.Namespace{}
+	require.NoError(t, cached.Get(ctx, client.ObjectKey{Name: "vdc-acme-dev"}, stale))
+	mutations := 0
+	err := k.updateWithRetry(ctx, stale, func() {
+		mutations++
+		if stale.Labels == nil {
+			stale.Labels = map[string]string{}
+		}
+		stale.Labels["ssvirt.io/vdc"] = "dev"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, mutations, "the conflicting update is retried once")
+
+	var updated corev1.Namespace
+	require.NoError(t, direct.Get(ctx, client.ObjectKey{Name: "vdc-acme-dev"}, &updated))
+	assert.Equal(t, map[string]string{"other-writer": "true", "ssvirt.io/vdc": "dev"}, updated.Labels)
+}
+
+func TestReaderConsistency(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, templatev1.AddToScheme(scheme))
+	template := func(displayName string) *templatev1.Template {
+		return &templatev1.Template{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "openshift",
+			Name:        "fedora",
+			Annotations: map[string]string{"openshift.io/display-name": displayName},
+		}}
+	}
+
+	// The cache has not seen the template's update or the new instance yet
+	cached := fake.NewClientBuilder().WithScheme(scheme).WithObjects(template("Fedora 40")).Build()
+	direct := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		template("Fedora 41"),
+		&templatev1.TemplateInstance{ObjectMeta: metav1.ObjectMeta{Namespace: "vdc-acme-dev", Name: "web"}},
+	).Build()
+	k := &kubernetesService{client: cached, directClient: direct, templateNamespace: "openshift"}
+
+	ctx := context.Background()
+	strong := consistency.WithLevel(ctx, consistency.Strong)
+	assert.Same(t, cached, k.reader(ctx))
+	assert.Same(t, direct, k.reader(strong))
+
+	info, err := k.GetTemplate(ctx, "fedora")
+	require.NoError(t, err)
+	assert.Equal(t, "Fedora 40", info.DisplayName)
+	info, err = k.GetTemplate(strong, "fedora")
+	require.NoError(t, err)
+	assert.Equal(t, "Fedora 41", info.DisplayName)
+
+	_, err = k.GetTemplateInstance(ctx, "vdc-acme-dev", "web")
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = k.GetTemplateInstance(strong, "vdc-acme-dev", "web")
+	assert.NoError(t, err)
+}
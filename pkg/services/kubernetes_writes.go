@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrNamespaceWritesSaturated is returned for writes to a namespace whose
+// write queue is full or whose circuit breaker is open
+var ErrNamespaceWritesSaturated = errors.New("too many pending Kubernetes writes in namespace")
+
+// namespaceWriteIdleTimeout is how long the state of a namespace that stopped
+// being written to is kept
+const namespaceWriteIdleTimeout = 10 * time.Minute
+
+var (
+	// Gauge for writes waiting for their namespace's rate limit
+	kubernetesWriteQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ssvirt_kubernetes_write_queue_depth",
+			Help: "Number of Kubernetes writes waiting for the namespace write rate limit",
+		},
+		[]string{"namespace"},
+	)
+
+	// Counter for writes refused because their namespace was saturated
+	kubernetesWritesRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_kubernetes_writes_rejected_total",
+			Help: "Total number of Kubernetes writes refused because the namespace queue was full or its circuit breaker open",
+		},
+		[]string{"namespace", "reason"},
+	)
+
+	// Counter for circuit breakers opened by the cluster throttling writes
+	kubernetesWriteBreakerTripsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ssvirt_kubernetes_write_breaker_trips_total",
+			Help: "Total number of times the cluster throttled a write and opened the namespace circuit breaker",
+		},
+		[]string{"namespace"},
+	)
+)
+
+// RegisterKubernetesWriteMetrics registers the write queue depth gauge and the
+// rejection and circuit breaker counters with reg
+func RegisterKubernetesWriteMetrics(reg prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{kubernetesWriteQueueDepth, kubernetesWritesRejectedTotal, kubernetesWriteBreakerTripsTotal} {
+		if err := reg.Register(collector); err != nil {
+			var already prometheus.AlreadyRegisteredError
+			if !errors.As(err, &already) {
+				return fmt.Errorf("failed to register Kubernetes write metrics: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// NamespaceWriteLimits throttles writes to each namespace
+type NamespaceWriteLimits struct {
+	// QPS is the sustained write rate per namespace; 0 disables throttling
+	QPS float64
+	// Burst is how many writes a namespace may make at once
+	Burst int
+	// QueueDepth is how many writes may wait in a namespace before further
+	// writes are refused
+	QueueDepth int
+	// BreakerCooldown is how long writes to a namespace are refused after
+	// the cluster answers one with 429 Too Many Requests
+	BreakerCooldown time.Duration
+}
+
+// NamespaceWriteLimiter rate limits writes to each namespace separately, so a
+// burst of instantiations or power actions in one VDC neither exhausts the
+// API server's QPS limits nor delays writes to other VDCs. Writes over the
+// rate wait their turn; when too many are waiting, or the cluster has just
+// throttled a write to the namespace, they are refused at once with
+// ErrNamespaceWritesSaturated.
+//
+// Limits apply within the API server; each replica has its own.
+type NamespaceWriteLimiter struct {
+	limits NamespaceWriteLimits
+	now    func() time.Time
+
+	mu         sync.Mutex
+	namespaces map[string]*namespaceWrites
+	lastPrune  time.Time
+}
+
+type namespaceWrites struct {
+	limiter   *rate.Limiter
+	queued    int
+	openUntil time.Time
+	lastSeen  time.Time
+}
+
+// NewNamespaceWriteLimiter creates a NamespaceWriteLimiter, or returns nil
+// when limits.QPS is 0
+func NewNamespaceWriteLimiter(limits NamespaceWriteLimits) *NamespaceWriteLimiter {
+	if limits.QPS <= 0 {
+		return nil
+	}
+	return &NamespaceWriteLimiter{
+		limits:     limits,
+		now:        time.Now,
+		namespaces: make(map[string]*namespaceWrites),
+	}
+}
+
+// Wait blocks until a write to namespace may be made. It returns an error
+// wrapping ErrNamespaceWritesSaturated without waiting when the namespace's
+// queue is full or its circuit breaker is open.
+func (l *NamespaceWriteLimiter) Wait(ctx context.Context, namespace string) error {
+	state, err := l.enqueue(namespace)
+	if err != nil {
+		return err
+	}
+	defer l.dequeue(namespace, state)
+	return state.limiter.Wait(ctx)
+}
+
+// Record opens the namespace's circuit breaker when the cluster throttled a
+// write to it
+func (l *NamespaceWriteLimiter) Record(namespace string, err error) {
+	if !apierrors.IsTooManyRequests(err) {
+		return
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state := l.state(namespace, now)
+	state.openUntil = now.Add(l.limits.BreakerCooldown)
+	kubernetesWriteBreakerTripsTotal.WithLabelValues(namespace).Inc()
+}
+
+// Queued returns the number of writes waiting in namespace
+func (l *NamespaceWriteLimiter) Queued(namespace string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if state, ok := l.namespaces[namespace]; ok {
+		return state.queued
+	}
+	return 0
+}
+
+// enqueue adds a write to the namespace's queue unless it is saturated
+func (l *NamespaceWriteLimiter) enqueue(namespace string) (*namespaceWrites, error) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	state := l.state(namespace, now)
+	if now.Before(state.openUntil) {
+		kubernetesWritesRejectedTotal.WithLabelValues(namespace, "breaker_open").Inc()
+		return nil, fmt.Errorf("%w %s: the cluster is throttling writes to it", ErrNamespaceWritesSaturated, namespace)
+	}
+	if state.queued >= l.limits.QueueDepth {
+		kubernetesWritesRejectedTotal.WithLabelValues(namespace, "queue_full").Inc()
+		return nil, fmt.Errorf("%w %s: %d writes are already waiting", ErrNamespaceWritesSaturated, namespace, state.queued)
+	}
+	state.queued++
+	kubernetesWriteQueueDepth.WithLabelValues(namespace).Set(float64(state.queued))
+	return state, nil
+}
+
+// dequeue removes a write from the namespace's queue
+func (l *NamespaceWriteLimiter) dequeue(namespace string, state *namespaceWrites) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state.queued--
+	kubernetesWriteQueueDepth.WithLabelValues(namespace).Set(float64(state.queued))
+}
+
+// state returns the write state of namespace, creating it if needed. l.mu
+// must be held.
+func (l *NamespaceWriteLimiter) state(namespace string, now time.Time) *namespaceWrites {
+	state, ok := l.namespaces[namespace]
+	if !ok {
+		state = &namespaceWrites{limiter: rate.NewLimiter(rate.Limit(l.limits.QPS), l.limits.Burst)}
+		l.namespaces[namespace] = state
+	}
+	state.lastSeen = now
+	return state
+}
+
+// prune forgets namespaces that have not been written to for a while. l.mu
+// must be held.
+func (l *NamespaceWriteLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < namespaceWriteIdleTimeout {
+		return
+	}
+	for namespace, state := range l.namespaces {
+		if state.queued == 0 && !now.Before(state.openUntil) && now.Sub(state.lastSeen) > namespaceWriteIdleTimeout {
+			delete(l.namespaces, namespace)
+			kubernetesWriteQueueDepth.DeleteLabelValues(namespace)
+		}
+	}
+	l.lastPrune = now
+}
+
+// throttledClient passes writes through a NamespaceWriteLimiter. Reads are
+// not throttled.
+type throttledClient struct {
+	client.Client
+	limiter *NamespaceWriteLimiter
+}
+
+// NewThrottledClient returns a client whose writes, including those to
+// subresources, wait for limiter. It returns c itself when limiter is nil.
+func NewThrottledClient(c client.Client, limiter *NamespaceWriteLimiter) client.Client {
+	if limiter == nil {
+		return c
+	}
+	return &throttledClient{Client: c, limiter: limiter}
+}
+
+// Create implements client.Writer
+func (t *throttledClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return throttleWrite(ctx, t.limiter, writeNamespace(obj), func() error {
+		return t.Client.Create(ctx, obj, opts...)
+	})
+}
+
+// Update implements client.Writer
+func (t *throttledClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return throttleWrite(ctx, t.limiter, writeNamespace(obj), func() error {
+		return t.Client.Update(ctx, obj, opts...)
+	})
+}
+
+// Patch implements client.Writer
+func (t *throttledClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return throttleWrite(ctx, t.limiter, writeNamespace(obj), func() error {
+		return t.Client.Patch(ctx, obj, patch, opts...)
+	})
+}
+
+// Delete implements client.Writer
+func (t *throttledClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return throttleWrite(ctx, t.limiter, writeNamespace(obj), func() error {
+		return t.Client.Delete(ctx, obj, opts...)
+	})
+}
+
+// DeleteAllOf implements client.Writer
+func (t *throttledClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	namespace := (&client.DeleteAllOfOptions{}).ApplyOptions(opts).Namespace
+	return throttleWrite(ctx, t.limiter, namespace, func() error {
+		return t.Client.DeleteAllOf(ctx, obj, opts...)
+	})
+}
+
+// Status implements client.StatusClient
+func (t *throttledClient) Status() client.SubResourceWriter {
+	return &throttledSubResourceWriter{writer: t.Client.Status(), limiter: t.limiter}
+}
+
+// SubResource implements client.SubResourceClientConstructor
+func (t *throttledClient) SubResource(subResource string) client.SubResourceClient {
+	c := t.Client.SubResource(subResource)
+	return &throttledSubResourceClient{
+		SubResourceReader:          c,
+		throttledSubResourceWriter: throttledSubResourceWriter{writer: c, limiter: t.limiter},
+	}
+}
+
+// throttledSubResourceWriter passes subresource writes through a
+// NamespaceWriteLimiter
+type throttledSubResourceWriter struct {
+	writer  client.SubResourceWriter
+	limiter *NamespaceWriteLimiter
+}
+
+// Create implements client.SubResourceWriter
+func (t *throttledSubResourceWriter) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return throttleWrite(ctx, t.limiter, writeNamespace(obj), func() error {
+		return t.writer.Create(ctx, obj, subResource, opts...)
+	})
+}
+
+// Update implements client.SubResourceWriter
+func (t *throttledSubResourceWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return throttleWrite(ctx, t.limiter, writeNamespace(obj), func() error {
+		return t.writer.Update(ctx, obj, opts...)
+	})
+}
+
+// Patch implements client.SubResourceWriter
+func (t *throttledSubResourceWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return throttleWrite(ctx, t.limiter, writeNamespace(obj), func() error {
+		return t.writer.Patch(ctx, obj, patch, opts...)
+	})
+}
+
+// throttledSubResourceClient reads subresources directly and throttles writes
+type throttledSubResourceClient struct {
+	client.SubResourceReader
+	throttledSubResourceWriter
+}
+
+// throttleWrite makes a write to namespace once limiter allows it, recording
+// whether the cluster throttled it
+func throttleWrite(ctx context.Context, limiter *NamespaceWriteLimiter, namespace string, write func() error) error {
+	if err := limiter.Wait(ctx, namespace); err != nil {
+		return err
+	}
+	err := write()
+	limiter.Record(namespace, err)
+	return err
+}
+
+// writeNamespace returns the namespace a write to obj is throttled under.
+// Namespaces count against themselves, so creating and deleting a VDC's
+// namespace shares its limit; other cluster-scoped objects share the empty
+// namespace.
+func writeNamespace(obj client.Object) string {
+	if namespace, ok := obj.(*corev1.Namespace); ok {
+		return namespace.Name
+	}
+	return obj.GetNamespace()
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+// DefaultNetworkFlowWindow is the period network flows are summarized over
+const DefaultNetworkFlowWindow = time.Hour
+
+// Labels Network Observability sets on its workload flow metrics. A VM's
+// launcher pod is owned by its VirtualMachineInstance, which shares the VM's name.
+const (
+	flowSrcNamespaceLabel = "SrcK8S_Namespace"
+	flowSrcOwnerLabel     = "SrcK8S_OwnerName"
+	flowSrcOwnerTypeLabel = "SrcK8S_OwnerType"
+	flowDstNamespaceLabel = "DstK8S_Namespace"
+	flowDstOwnerLabel     = "DstK8S_OwnerName"
+	flowDstOwnerTypeLabel = "DstK8S_OwnerType"
+)
+
+// NetworkFlowPeer is a workload a VM exchanged traffic with. Namespace and Name
+// are empty for traffic to or from outside the cluster.
+type NetworkFlowPeer struct {
+	Namespace     string `json:"namespace,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Kind          string `json:"kind,omitempty"`
+	BytesSent     int64  `json:"bytesSent"`
+	BytesReceived int64  `json:"bytesReceived"`
+}
+
+// TotalBytes is the traffic in both directions
+func (p NetworkFlowPeer) TotalBytes() int64 {
+	return p.BytesSent + p.BytesReceived
+}
+
+// NetworkFlowSummary is a VM's traffic over a window, with every peer it
+// exchanged traffic with, busiest first
+type NetworkFlowSummary struct {
+	BytesSent     int64
+	BytesReceived int64
+	Peers         []NetworkFlowPeer
+}
+
+// NetworkFlowService summarizes the network flows of VMs
+type NetworkFlowService interface {
+	VMNetworkFlows(ctx context.Context, namespace, vmName string, window time.Duration) (*NetworkFlowSummary, error)
+}
+
+// prometheusFlowService queries the flow metrics through the Prometheus HTTP API
+type prometheusFlowService struct {
+	queryURL   string
+	metric     string
+	token      string
+	httpClient *http.Client
+}
+
+// NewPrometheusFlowService creates a NetworkFlowService reading the flow metrics
+// from the Prometheus, or Thanos querier, at cfg.PrometheusURL
+func NewPrometheusFlowService(cfg config.Config) (NetworkFlowService, error) {
+	flows := cfg.NetworkFlows
+	base, err := url.Parse(flows.PrometheusURL)
+	if err != nil || (base.Scheme != "https" && base.Scheme != "http") || base.Host == "" {
+		return nil, fmt.Errorf("invalid Prometheus URL %q", flows.PrometheusURL)
+	}
+	if flows.Metric == "" {
+		return nil, errors.New("network flow metric is required")
+	}
+
+	s := &prometheusFlowService{
+		queryURL: base.JoinPath("api", "v1", "query").String(),
+		metric:   flows.Metric,
+	}
+	if flows.BearerTokenFile != "" {
+		token, err := os.ReadFile(flows.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Prometheus bearer token: %w", err)
+		}
+		s.token = strings.TrimSpace(string(token))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if flows.CAFile != "" {
+		caPEM, err := os.ReadFile(flows.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Prometheus CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", flows.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	s.httpClient = &http.Client{Timeout: flows.Timeout, Transport: transport}
+	return s, nil
+}
+
+// VMNetworkFlows sums the bytes the VM sent to and received from each peer
+// over the window
+func (s *prometheusFlowService) VMNetworkFlows(ctx context.Context, namespace, vmName string, window time.Duration) (*NetworkFlowSummary, error) {
+	sent, err := s.query(ctx, s.flowQuery(flowSrcNamespaceLabel, flowSrcOwnerLabel, namespace, vmName, window,
+		flowDstNamespaceLabel, flowDstOwnerLabel, flowDstOwnerTypeLabel))
+	if err != nil {
+		return nil, err
+	}
+	received, err := s.query(ctx, s.flowQuery(flowDstNamespaceLabel, flowDstOwnerLabel, namespace, vmName, window,
+		flowSrcNamespaceLabel, flowSrcOwnerLabel, flowSrcOwnerTypeLabel))
+	if err != nil {
+		return nil, err
+	}
+
+	type peerKey struct{ namespace, name, kind string }
+	peers := make(map[peerKey]*NetworkFlowPeer)
+	peer := func(namespace, name, kind string) *NetworkFlowPeer {
+		key := peerKey{namespace, name, kind}
+		if peers[key] == nil {
+			peers[key] = &NetworkFlowPeer{Namespace: namespace, Name: name, Kind: kind}
+		}
+		return peers[key]
+	}
+
+	summary := &NetworkFlowSummary{}
+	for _, sample := range sent {
+		p := peer(sample.Metric[flowDstNamespaceLabel], sample.Metric[flowDstOwnerLabel], sample.Metric[flowDstOwnerTypeLabel])
+		p.BytesSent += sample.bytes
+		summary.BytesSent += sample.bytes
+	}
+	for _, sample := range received {
+		p := peer(sample.Metric[flowSrcNamespaceLabel], sample.Metric[flowSrcOwnerLabel], sample.Metric[flowSrcOwnerTypeLabel])
+		p.BytesReceived += sample.bytes
+		summary.BytesReceived += sample.bytes
+	}
+
+	summary.Peers = make([]NetworkFlowPeer, 0, len(peers))
+	for _, p := range peers {
+		summary.Peers = append(summary.Peers, *p)
+	}
+	sortNetworkFlowPeers(summary.Peers)
+	return summary, nil
+}
+
+// sortNetworkFlowPeers orders peers busiest first, breaking ties by name so the
+// order is stable
+func sortNetworkFlowPeers(peers []NetworkFlowPeer) {
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].TotalBytes() != peers[j].TotalBytes() {
+			return peers[i].TotalBytes() > peers[j].TotalBytes()
+		}
+		if peers[i].Namespace != peers[j].Namespace {
+			return peers[i].Namespace < peers[j].Namespace
+		}
+		return peers[i].Name < peers[j].Name
+	})
+}
+
+// flowQuery sums the bytes of the VM's flows in one direction, grouped by the peer
+func (s *prometheusFlowService) flowQuery(namespaceLabel, ownerLabel, namespace, vmName string, window time.Duration, by ...string) string {
+	return fmt.Sprintf(`sum by (%s) (increase(%s{%s=%s,%s=%s}[%s]))`,
+		strings.Join(by, ", "), s.metric,
+		namespaceLabel, strconv.Quote(namespace), ownerLabel, strconv.Quote(vmName),
+		formatPromDuration(window))
+}
+
+// formatPromDuration renders a duration in the PromQL syntax, which has no
+// fractional units
+func formatPromDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
+
+// promSample is one series of an instant vector result
+type promSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  []any             `json:"value"`
+	bytes  int64
+}
+
+// query runs an instant query and returns its vector result
+func (s *prometheusFlowService) query(ctx context.Context, query string) ([]promSample, error) {
+	form := url.Values{"query": {query}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.queryURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var body struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string       `json:"resultType"`
+			Result     []promSample `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10*1024*1024)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode Prometheus response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed (HTTP %d): %s", resp.StatusCode, body.Error)
+	}
+	if body.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("unexpected Prometheus result type %q", body.Data.ResultType)
+	}
+
+	samples := body.Data.Result[:0]
+	for _, sample := range body.Data.Result {
+		if len(sample.Value) != 2 {
+			continue
+		}
+		text, ok := sample.Value[1].(string)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil || value <= 0 {
+			continue
+		}
+		// increase() extrapolates, so the byte counts are rounded
+		sample.bytes = int64(value + 0.5)
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+// NotificationEvent identifies the system event a notification reports and
+// selects its message template
+type NotificationEvent string
+
+const (
+	NotificationPasswordReset     NotificationEvent = "password-reset"
+	NotificationApprovalRequested NotificationEvent = "approval-requested"
+	NotificationProvisioningStuck NotificationEvent = "provisioning-stuck"
+	NotificationStorageAlert      NotificationEvent = "storage-alert"
+	NotificationVMIdle            NotificationEvent = "vm-idle"
+)
+
+// Notification is a system event to deliver to people
+type Notification struct {
+	Event NotificationEvent
+	// OrganizationID selects the organization's sender override, if any
+	OrganizationID string
+	// To lists the recipients; the notifier's default recipients are used when empty
+	To []string
+	// Data is rendered by the event's message template
+	Data interface{}
+}
+
+// Notifier delivers notifications outside the cluster
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// Built-in message templates. Each defines a "subject" and a "body" template;
+// a file named <event>.tmpl in the configured template directory replaces one.
+var defaultNotificationTemplates = map[NotificationEvent]string{
+	NotificationPasswordReset: `{{define "subject"}}Reset your SSVirt password{{end}}
+{{define "body"}}Hello {{.Username}},
+
+A password reset was requested for your account. Use the link below within {{.ExpiresIn}} to choose a new password:
+
+{{.ResetURL}}
+
+If you did not request a reset you can ignore this message.
+{{end}}`,
+	NotificationApprovalRequested: `{{define "subject"}}Approval requested: {{.Request}}{{end}}
+{{define "body"}}{{.Requester}} requested approval for {{.Request}} in organization {{.Organization}}.
+
+Review the request at {{.ReviewURL}}
+{{end}}`,
+	NotificationProvisioningStuck: `{{define "subject"}}vApp {{.VAppName}} has been instantiating for {{.Duration}}{{end}}
+{{define "body"}}The vApp {{.VAppName}} ({{.VAppID}}) in VDC {{.VDCName}} started instantiating at {{.CreatedAt.Format "2006-01-02 15:04:05 MST"}} and has not finished after {{.Duration}}.
+
+Check TemplateInstance {{.Namespace}}/{{.TemplateInstance}} and the VirtualMachines it created.
+{{end}}`,
+	NotificationStorageAlert: `{{define "subject"}}[{{.Level}}] VDC {{.VDCName}} storage profile {{.StorageProfile}} is {{.UsagePercent}}% full{{end}}
+{{define "body"}}Storage profile {{.StorageProfile}} of VDC {{.VDCName}} ({{.VDCID}}) uses {{.UsedMB}} MB of its {{.LimitMB}} MB limit ({{.UsagePercent}}%), crossing the {{.Level}} threshold of {{.Threshold}}%.
+{{end}}`,
+	NotificationVMIdle: `{{define "subject"}}VM {{.VMName}} has been idle for {{.IdleFor}}{{end}}
+{{define "body"}}The VM {{.VMName}} ({{.VMID}}) in VDC {{.VDCName}} has used less than {{.CPUThresholdPercent}}% of its CPU since {{.IdleSince.Format "2006-01-02 15:04:05 MST"}}.
+
+The VDC's auto-suspend policy will {{.Action}} the VM after {{.ActionAt.Format "2006-01-02 15:04:05 MST"}} unless it becomes active. Tag the VM with {{.OptOutTag}} to keep it running.
+{{end}}`,
+}
+
+// SMTPNotifier emails notifications through an SMTP server, upgrading the
+// connection with STARTTLS when the server offers it
+type SMTPNotifier struct {
+	addr       string
+	auth       smtp.Auth
+	from       string
+	recipients []string
+	senders    map[string]string
+	templates  map[NotificationEvent]*template.Template
+}
+
+// NewSMTPNotifier creates an SMTP notifier, loading any template overrides from
+// cfg.TemplateDir
+func NewSMTPNotifier(cfg config.EmailConfig) (*SMTPNotifier, error) {
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", cfg.From, err)
+	}
+	for _, recipient := range cfg.Recipients {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+		}
+	}
+
+	n := &SMTPNotifier{
+		addr:       net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		from:       cfg.From,
+		recipients: cfg.Recipients,
+		senders:    make(map[string]string, len(cfg.SenderOverrides)),
+		templates:  make(map[NotificationEvent]*template.Template, len(defaultNotificationTemplates)),
+	}
+	if cfg.Username != "" {
+		n.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	for _, override := range cfg.SenderOverrides {
+		if _, err := mail.ParseAddress(override.From); err != nil {
+			return nil, fmt.Errorf("invalid from address %q for organization %s: %w", override.From, override.OrganizationID, err)
+		}
+		n.senders[override.OrganizationID] = override.From
+	}
+
+	for event, text := range defaultNotificationTemplates {
+		name := string(event) + ".tmpl"
+		if cfg.TemplateDir != "" {
+			override, err := os.ReadFile(filepath.Join(cfg.TemplateDir, name)) // #nosec G304 - template directory is operator configured
+			if err == nil {
+				text = string(override)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+			}
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		if tmpl.Lookup("subject") == nil || tmpl.Lookup("body") == nil {
+			return nil, fmt.Errorf("template %s must define \"subject\" and \"body\"", name)
+		}
+		n.templates[event] = tmpl
+	}
+	return n, nil
+}
+
+// Notify renders the notification's template and sends it to its recipients
+func (n *SMTPNotifier) Notify(ctx context.Context, notification Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, ok := n.templates[notification.Event]
+	if !ok {
+		return fmt.Errorf("no template for notification event %q", notification.Event)
+	}
+	to := notification.To
+	if len(to) == 0 {
+		to = n.recipients
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients for %s notification", notification.Event)
+	}
+	from := n.from
+	if sender, ok := n.senders[notification.OrganizationID]; ok {
+		from = sender
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", notification.Data); err != nil {
+		return fmt.Errorf("failed to render %s subject: %w", notification.Event, err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", notification.Data); err != nil {
+		return fmt.Errorf("failed to render %s body: %w", notification.Event, err)
+	}
+
+	envelopeFrom, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+	recipients, err := envelopeAddresses(to)
+	if err != nil {
+		return err
+	}
+	msg := buildMessage(from, to, subject.String(), body.String())
+	if err := smtp.SendMail(n.addr, n.auth, envelopeFrom.Address, recipients, msg); err != nil {
+		return fmt.Errorf("failed to send %s notification: %w", notification.Event, err)
+	}
+	return nil
+}
+
+// buildMessage formats a plain text RFC 5322 message. The subject is folded
+// onto one line so template output cannot inject headers.
+func buildMessage(from string, to []string, subject, body string) []byte {
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes()
+}
+
+// envelopeAddresses returns the bare addresses of recipients that may include display names
+func envelopeAddresses(recipients []string) ([]string, error) {
+	addresses := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		addr, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+		}
+		addresses = append(addresses, addr.Address)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"math"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+)
+
+// DefaultHoursPerMonth is the average number of hours in a month (365 * 24 / 12)
+const DefaultHoursPerMonth = 730
+
+const bytesPerGiB = 1024 * 1024 * 1024
+
+// Pricing holds the showback rates used to estimate what VMs cost to run
+type Pricing struct {
+	Currency        string
+	CPUHour         float64
+	MemoryGiBHour   float64
+	StorageGiBMonth float64
+	GPUHour         float64
+	HoursPerMonth   float64
+}
+
+// PricingFromConfig returns the configured pricing
+func PricingFromConfig(cfg *config.Config) Pricing {
+	return Pricing{
+		Currency:        cfg.Pricing.Currency,
+		CPUHour:         cfg.Pricing.CPUHour,
+		MemoryGiBHour:   cfg.Pricing.MemoryGiBHour,
+		StorageGiBMonth: cfg.Pricing.StorageGiBMonth,
+		GPUHour:         cfg.Pricing.GPUHour,
+		HoursPerMonth:   cfg.Pricing.HoursPerMonth,
+	}
+}
+
+// Enabled reports whether any rate is configured
+func (p Pricing) Enabled() bool {
+	return p.CPUHour > 0 || p.MemoryGiBHour > 0 || p.StorageGiBMonth > 0 || p.GPUHour > 0
+}
+
+// ResourceUsage is the capacity a VM or vApp reserves
+type ResourceUsage struct {
+	CPUs         int
+	MemoryBytes  int64
+	StorageBytes int64
+	GPUs         int
+	// VMs is the number of VMs, counted against organization quotas
+	VMs int
+}
+
+// CostEstimate is the estimated cost of running resources for a month
+type CostEstimate struct {
+	Currency    string        `json:"currency"`
+	MonthlyCost float64       `json:"monthlyCost"`
+	Breakdown   CostBreakdown `json:"breakdown"`
+}
+
+// CostBreakdown splits a monthly cost estimate by resource
+type CostBreakdown struct {
+	CPU     float64 `json:"cpu"`
+	Memory  float64 `json:"memory"`
+	Storage float64 `json:"storage"`
+	GPU     float64 `json:"gpu"`
+}
+
+// Estimate returns the monthly cost of running usage continuously, or nil when
+// no rates are configured
+func (p Pricing) Estimate(usage ResourceUsage) *CostEstimate {
+	if !p.Enabled() {
+		return nil
+	}
+	hours := p.HoursPerMonth
+	if hours <= 0 {
+		hours = DefaultHoursPerMonth
+	}
+
+	breakdown := CostBreakdown{
+		CPU:     roundCents(float64(usage.CPUs) * p.CPUHour * hours),
+		Memory:  roundCents(float64(usage.MemoryBytes) / bytesPerGiB * p.MemoryGiBHour * hours),
+		Storage: roundCents(float64(usage.StorageBytes) / bytesPerGiB * p.StorageGiBMonth),
+		GPU:     roundCents(float64(usage.GPUs) * p.GPUHour * hours),
+	}
+	return &CostEstimate{
+		Currency:    p.Currency,
+		MonthlyCost: roundCents(breakdown.CPU + breakdown.Memory + breakdown.Storage + breakdown.GPU),
+		Breakdown:   breakdown,
+	}
+}
+
+// roundCents rounds an amount to two decimal places
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// TemplateGPUCount returns the number of GPUs requested by the VirtualMachines in a Template
+func TemplateGPUCount(template *templatev1.Template) int {
+	count := 0
+	for _, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+		gpus, _, _ := unstructured.NestedSlice(vm.Object, "spec", "template", "spec", "domain", "devices", "gpus")
+		count += len(gpus)
+	}
+	return count
+}
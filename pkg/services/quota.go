@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+)
+
+// DefaultQuotaGracePeriod is how long a VDC may use its grace allowance when no
+// grace period is configured
+const DefaultQuotaGracePeriod = 24 * time.Hour
+
+// ErrQuotaExceeded is returned when an allocation would exceed a VDC's compute
+// limits beyond what its grace allowance permits
+var ErrQuotaExceeded = errors.New("VDC compute quota exceeded")
+
+// ErrOrgQuotaExceeded is returned when an allocation would exceed the quota of
+// the VDC's organization
+var ErrOrgQuotaExceeded = errors.New("organization quota exceeded")
+
+// QuotaStore reads VDC compute usage and tracks grace allocations
+type QuotaStore interface {
+	ComputeUsage(ctx context.Context, vdcID string) (models.ComputeUsage, error)
+	SetQuotaGraceStartedAt(ctx context.Context, vdcID string, startedAt *time.Time) error
+}
+
+// OrgQuotaStore reads organization quotas and their usage across the
+// organization's VDCs
+type OrgQuotaStore interface {
+	GetByIDWithContext(ctx context.Context, id string) (*models.Organization, error)
+	GetQuotaUsage(ctx context.Context, orgID string) (models.OrgQuotaUsage, error)
+}
+
+// EventPublisher publishes entity change events
+type EventPublisher interface {
+	Publish(event events.Event)
+}
+
+// QuotaDecision describes an allocation permitted by QuotaService.Check
+type QuotaDecision struct {
+	// Warnings explain which soft quotas or hard limits the allocation crosses
+	Warnings []string
+	// GraceExpiresAt is set when the allocation uses the VDC's grace allowance
+	// and is when over-limit allocations will be refused again
+	GraceExpiresAt *time.Time
+}
+
+// QuotaService checks allocations against VDC compute limits. Allocations that
+// reach a VDC's soft quota are permitted with warnings. Allocations over the
+// hard limits are permitted within the VDC's grace allowance for the grace
+// period, which starts when the VDC first goes over its limits and ends once
+// an allocation finds it back under them.
+type QuotaService struct {
+	store       QuotaStore
+	orgs        OrgQuotaStore
+	publisher   EventPublisher
+	gracePeriod time.Duration
+}
+
+// NewQuotaService creates a quota service. Warnings are published as events
+// when publisher is not nil.
+func NewQuotaService(store QuotaStore, publisher EventPublisher, gracePeriod time.Duration) *QuotaService {
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultQuotaGracePeriod
+	}
+	return &QuotaService{
+		store:       store,
+		publisher:   publisher,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// SetOrgQuotas enables checking allocations against the quota of the VDC's
+// organization, which has no grace allowance. When unset, only VDC limits are
+// checked.
+func (s *QuotaService) SetOrgQuotas(orgs OrgQuotaStore) {
+	s.orgs = orgs
+}
+
+// quotaResource is one compute resource of a VDC, in the units of its limit
+type quotaResource struct {
+	name      string
+	units     string
+	used      int64
+	requested int64
+	limit     int64
+}
+
+// Check decides whether a VDC may allocate the requested resources. It returns
+// an error wrapping ErrQuotaExceeded when it may not.
+func (s *QuotaService) Check(ctx context.Context, vdc *models.VDC, request ResourceUsage) (*QuotaDecision, error) {
+	// The organization quota is checked first so a refused allocation does
+	// not start the VDC's grace period
+	if err := s.checkOrgQuota(ctx, vdc.OrganizationID, request); err != nil {
+		return nil, err
+	}
+
+	usage, err := s.store.ComputeUsage(ctx, vdc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get compute usage of VDC %s: %w", vdc.ID, err)
+	}
+
+	policy := vdc.ComputeQuotaPolicy()
+	decision := &QuotaDecision{}
+	overLimit := false
+	for _, r := range computeResources(vdc, usage, request) {
+		if r.limit <= 0 || r.requested <= 0 {
+			continue
+		}
+		total := r.used + r.requested
+		ceiling := r.limit * int64(100+policy.GracePercent) / 100
+		switch {
+		case total > ceiling:
+			return nil, fmt.Errorf("%w: %s allocation of %d %s would exceed the limit of %d %s", ErrQuotaExceeded, r.name, total, r.units, r.limit, r.units)
+		case total > r.limit:
+			overLimit = true
+			decision.Warnings = append(decision.Warnings, fmt.Sprintf("%s allocation of %d %s exceeds the VDC limit of %d %s and uses the grace allowance of %d%%", r.name, total, r.units, r.limit, r.units, policy.GracePercent))
+		case policy.SoftLimitPercent > 0 && total*100 >= r.limit*int64(policy.SoftLimitPercent):
+			decision.Warnings = append(decision.Warnings, fmt.Sprintf("%s allocation of %d %s is %d%% of the VDC limit, above the soft quota of %d%%", r.name, total, r.units, total*100/r.limit, policy.SoftLimitPercent))
+		}
+	}
+
+	if overLimit {
+		startedAt := vdc.QuotaGraceStartedAt
+		if startedAt == nil {
+			now := time.Now()
+			startedAt = &now
+			if err := s.store.SetQuotaGraceStartedAt(ctx, vdc.ID, startedAt); err != nil {
+				return nil, fmt.Errorf("failed to record grace allocation for VDC %s: %w", vdc.ID, err)
+			}
+			vdc.QuotaGraceStartedAt = startedAt
+		}
+		expiresAt := startedAt.Add(s.gracePeriod)
+		if !time.Now().Before(expiresAt) {
+			return nil, fmt.Errorf("%w: the grace period for exceeding the limits ended at %s", ErrQuotaExceeded, expiresAt.UTC().Format(time.RFC3339))
+		}
+		decision.GraceExpiresAt = &expiresAt
+	} else if vdc.QuotaGraceStartedAt != nil {
+		if err := s.store.SetQuotaGraceStartedAt(ctx, vdc.ID, nil); err != nil {
+			return nil, fmt.Errorf("failed to clear grace allocation for VDC %s: %w", vdc.ID, err)
+		}
+		vdc.QuotaGraceStartedAt = nil
+	}
+
+	if len(decision.Warnings) > 0 {
+		s.publishWarning(vdc, decision)
+	}
+	return decision, nil
+}
+
+// checkOrgQuota returns an error wrapping ErrOrgQuotaExceeded when the
+// allocation would take the organization's VDCs over its quota
+func (s *QuotaService) checkOrgQuota(ctx context.Context, orgID string, request ResourceUsage) error {
+	if s.orgs == nil {
+		return nil
+	}
+	org, err := s.orgs.GetByIDWithContext(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization %s: %w", orgID, err)
+	}
+	if org == nil || org.Quota == nil {
+		return nil
+	}
+	usage, err := s.orgs.GetQuotaUsage(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get quota usage of organization %s: %w", orgID, err)
+	}
+
+	quota := org.Quota
+	for _, r := range []quotaResource{
+		{name: "CPU", units: "cores", used: usage.CPUCores, requested: int64(request.CPUs), limit: quota.CPUCores},
+		{name: "memory", units: "MB", used: usage.MemoryMB, requested: request.MemoryBytes / (1024 * 1024), limit: quota.MemoryMB},
+		{name: "storage", units: "MB", used: usage.StorageMB, requested: request.StorageBytes / (1024 * 1024), limit: quota.StorageMB},
+		{name: "VM", units: "VMs", used: usage.VMCount, requested: int64(request.VMs), limit: quota.VMCount},
+	} {
+		if r.limit <= 0 || r.requested <= 0 {
+			continue
+		}
+		if total := r.used + r.requested; total > r.limit {
+			return fmt.Errorf("%w: %s allocation of %d %s would exceed the organization limit of %d %s", ErrOrgQuotaExceeded, r.name, total, r.units, r.limit, r.units)
+		}
+	}
+	return nil
+}
+
+// computeResources lists the VDC's limited compute resources. CPU limits are
+// only enforced in Kubernetes-compatible units, matching the namespace quota.
+func computeResources(vdc *models.VDC, usage models.ComputeUsage, request ResourceUsage) []quotaResource {
+	resources := []quotaResource{{
+		name:      "memory",
+		units:     "MB",
+		used:      usage.MemoryMB,
+		requested: request.MemoryBytes / (1024 * 1024),
+		limit:     int64(vdc.MemoryLimit),
+	}}
+	switch vdc.CPUUnits {
+	case "cores":
+		resources = append(resources, quotaResource{name: "CPU", units: "cores", used: usage.CPUs, requested: int64(request.CPUs), limit: int64(vdc.CPULimit)})
+	case "millicores":
+		resources = append(resources, quotaResource{name: "CPU", units: "millicores", used: usage.CPUs * 1000, requested: int64(request.CPUs) * 1000, limit: int64(vdc.CPULimit)})
+	}
+	return resources
+}
+
+// publishWarning emits a quota warning event for the VDC's organization
+func (s *QuotaService) publishWarning(vdc *models.VDC, decision *QuotaDecision) {
+	if s.publisher == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"vdcName":  vdc.Name,
+		"warnings": decision.Warnings,
+	}
+	if decision.GraceExpiresAt != nil {
+		data["graceExpiresAt"] = decision.GraceExpiresAt.UTC().Format(time.RFC3339)
+	}
+	s.publisher.Publish(events.Event{
+		Type:       events.TypeVDCQuotaWarning,
+		EntityType: events.EntityVDC,
+		EntityID:   vdc.ID,
+		OrgID:      vdc.OrganizationID,
+		Data:       data,
+	})
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// System status thresholds. The status is meant for tenants, so it only
+// reports sustained problems rather than every failed request.
+const (
+	// serverErrorWindow is how far back API server errors are counted
+	serverErrorWindow = 5 * time.Minute
+	// minStatusRequests and minStatusTasks are how many requests and tasks
+	// are needed before their error rates are judged
+	minStatusRequests = 20
+	minStatusTasks    = 5
+	// apiDegradedErrorRate and apiOutageErrorRate are the shares of requests
+	// failing with server errors at which the API is degraded or out
+	apiDegradedErrorRate = 0.05
+	apiOutageErrorRate   = 0.5
+	// taskStatusWindow is how far back finished tasks are counted
+	taskStatusWindow = time.Hour
+	// taskDegradedErrorRate is the share of failed provisioning tasks at which
+	// provisioning is degraded
+	taskDegradedErrorRate = 0.25
+	// controllerFailingAfter is how long a controller may keep reconciling
+	// without success before it is considered failing
+	controllerFailingAfter = 10 * time.Minute
+	// systemStatusCacheTTL is how long a report is reused, as tenant portals
+	// poll the status
+	systemStatusCacheTTL = 15 * time.Second
+)
+
+// vmControllerComponent is the component name vm-controller replicas heartbeat with
+const vmControllerComponent = "vm-controller"
+
+// Controllers whose failures degrade each subsystem, by the names the
+// vm-controller heartbeats them with
+var (
+	provisioningControllers = []string{"ssvirt_vmstatus", "ssvirt_vappstatus", "ssvirt_powerstate"}
+	networkingControllers   = []string{"ssvirt_vdcconditions", "ssvirt_externaldns"}
+)
+
+// provisioningTasks are the task names whose failures count against provisioning
+var provisioningTasks = []string{
+	models.TaskOperationVMPowerOn,
+	models.TaskOperationVMPowerOff,
+	models.TaskOperationVMReboot,
+	models.TaskOperationVMReset,
+	models.TaskOperationVMDelete,
+	models.TaskOperationVAppDelete,
+	models.TaskOperationVAppPowerOn,
+	models.TaskOperationVAppRetry,
+	models.TaskOperationGPUVMCreate,
+}
+
+// Messages shown for measured problems
+var subsystemMessages = map[string]map[string]string{
+	models.SubsystemAPI: {
+		models.SystemStatusDegraded: "Some API requests are failing",
+		models.SystemStatusOutage:   "Most API requests are failing",
+	},
+	models.SubsystemProvisioning: {
+		models.SystemStatusDegraded: "VM operations are slower or failing more often than usual",
+		models.SystemStatusOutage:   "VM operations are not being processed",
+	},
+	models.SubsystemNetworking: {
+		models.SystemStatusDegraded: "Network and DNS changes are delayed",
+	},
+}
+
+// ServerErrorWindow counts the requests an API server answered, and those
+// failing with a server error, over a sliding window of one-minute buckets
+type ServerErrorWindow struct {
+	mu      sync.Mutex
+	buckets []serverErrorBucket
+	now     func() time.Time
+}
+
+type serverErrorBucket struct {
+	minute   int64
+	requests int64
+	errors   int64
+}
+
+// NewServerErrorWindow creates a ServerErrorWindow covering window
+func NewServerErrorWindow(window time.Duration) *ServerErrorWindow {
+	minutes := int(window / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	return &ServerErrorWindow{
+		buckets: make([]serverErrorBucket, minutes),
+		now:     time.Now,
+	}
+}
+
+// Record counts a request, which failed with a server error when serverError is set
+func (w *ServerErrorWindow) Record(serverError bool) {
+	minute := w.now().Unix() / 60
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	bucket := &w.buckets[minute%int64(len(w.buckets))]
+	if bucket.minute != minute {
+		*bucket = serverErrorBucket{minute: minute}
+	}
+	bucket.requests++
+	if serverError {
+		bucket.errors++
+	}
+}
+
+// Counts returns the requests and server errors counted within the window
+func (w *ServerErrorWindow) Counts() (requests, errors int64) {
+	oldest := w.now().Unix()/60 - int64(len(w.buckets)) + 1
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, bucket := range w.buckets {
+		if bucket.minute >= oldest {
+			requests += bucket.requests
+			errors += bucket.errors
+		}
+	}
+	return requests, errors
+}
+
+// SubsystemStatus is the status of one platform subsystem
+type SubsystemStatus struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// SystemStatusNotice is an administrator's notice, such as planned maintenance
+type SystemStatusNotice struct {
+	Status     string     `json:"status"`
+	Message    string     `json:"message"`
+	Subsystems []string   `json:"subsystems"`
+	StartsAt   *time.Time `json:"startsAt,omitempty"`
+	EndsAt     *time.Time `json:"endsAt,omitempty"`
+	// Active is false for notices announcing a future window
+	Active bool `json:"active"`
+}
+
+// SystemStatusReport is the coarse platform health shown to tenants
+type SystemStatusReport struct {
+	// Status is the worst status of the subsystems
+	Status     string              `json:"status"`
+	Subsystems []SubsystemStatus   `json:"subsystems"`
+	Notice     *SystemStatusNotice `json:"notice,omitempty"`
+	UpdatedAt  time.Time           `json:"updatedAt"`
+}
+
+// ComponentHeartbeatLister lists the heartbeats of the controller processes
+type ComponentHeartbeatLister interface {
+	List(ctx context.Context) ([]models.ComponentHeartbeat, error)
+}
+
+// FinishedTaskCounter counts the tasks named one of names that finished after
+// since, by status
+type FinishedTaskCounter interface {
+	CountFinishedSince(ctx context.Context, names []string, since time.Time) (map[string]int64, error)
+}
+
+// SystemStatusOverrideGetter returns the administrator's status override, or
+// nil when none is set
+type SystemStatusOverrideGetter interface {
+	GetOverride(ctx context.Context) (*models.SystemStatusOverride, error)
+}
+
+// SystemStatusReporter derives the platform's status from the API server's
+// error rate, the heartbeats of the vm-controller and the outcome of recent
+// tasks. An administrator's override replaces the measured status of the
+// subsystems it covers while it is in effect.
+type SystemStatusReporter struct {
+	heartbeats ComponentHeartbeatLister
+	tasks      FinishedTaskCounter
+	overrides  SystemStatusOverrideGetter
+	errors     *ServerErrorWindow
+	now        func() time.Time
+
+	mu       sync.Mutex
+	cached   *SystemStatusReport
+	cachedAt time.Time
+}
+
+// NewSystemStatusReporter creates a SystemStatusReporter. errors may be nil,
+// in which case the API is reported from overrides alone.
+func NewSystemStatusReporter(heartbeats ComponentHeartbeatLister, tasks FinishedTaskCounter, overrides SystemStatusOverrideGetter, errors *ServerErrorWindow) *SystemStatusReporter {
+	return &SystemStatusReporter{
+		heartbeats: heartbeats,
+		tasks:      tasks,
+		overrides:  overrides,
+		errors:     errors,
+		now:        time.Now,
+	}
+}
+
+// DefaultServerErrorWindow returns a ServerErrorWindow over the span the
+// system status judges the API by
+func DefaultServerErrorWindow() *ServerErrorWindow {
+	return NewServerErrorWindow(serverErrorWindow)
+}
+
+// Invalidate drops the cached report, so an override change shows at once
+func (r *SystemStatusReporter) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cached = nil
+}
+
+// Report returns the current system status
+func (r *SystemStatusReporter) Report(ctx context.Context) (*SystemStatusReport, error) {
+	now := r.now()
+	r.mu.Lock()
+	if r.cached != nil && now.Sub(r.cachedAt) < systemStatusCacheTTL {
+		report := r.cached
+		r.mu.Unlock()
+		return report, nil
+	}
+	r.mu.Unlock()
+
+	report, err := r.measure(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.cached = report
+	r.cachedAt = now
+	r.mu.Unlock()
+	return report, nil
+}
+
+func (r *SystemStatusReporter) measure(ctx context.Context, now time.Time) (*SystemStatusReport, error) {
+	heartbeats, err := r.heartbeats.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taskCounts, err := r.tasks.CountFinishedSince(ctx, provisioningTasks, now.Add(-taskStatusWindow))
+	if err != nil {
+		return nil, err
+	}
+	override, err := r.overrides.GetOverride(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	leader, controllerSeen := vmControllerLeader(heartbeats, now)
+	measured := map[string]string{
+		models.SubsystemAPI:          r.apiStatus(),
+		models.SubsystemProvisioning: provisioningStatus(leader, controllerSeen, taskCounts, now),
+		models.SubsystemNetworking:   networkingStatus(leader, now),
+	}
+
+	report := &SystemStatusReport{
+		Status:     models.SystemStatusOperational,
+		Subsystems: make([]SubsystemStatus, 0, len(models.Subsystems)),
+		UpdatedAt:  now,
+	}
+	active := override != nil && override.ActiveAt(now)
+	if override != nil && (override.EndsAt == nil || now.Before(*override.EndsAt)) {
+		report.Notice = &SystemStatusNotice{
+			Status:     override.Status,
+			Message:    override.Message,
+			Subsystems: override.Subsystems,
+			StartsAt:   override.StartsAt,
+			EndsAt:     override.EndsAt,
+			Active:     active,
+		}
+	}
+	for _, name := range models.Subsystems {
+		subsystem := SubsystemStatus{Name: name, Status: measured[name]}
+		subsystem.Message = subsystemMessages[name][subsystem.Status]
+		if active && override.AppliesTo(name) {
+			subsystem.Status = override.Status
+			subsystem.Message = override.Message
+		}
+		if models.SystemStatusSeverity(subsystem.Status) > models.SystemStatusSeverity(report.Status) {
+			report.Status = subsystem.Status
+		}
+		report.Subsystems = append(report.Subsystems, subsystem)
+	}
+	return report, nil
+}
+
+// apiStatus judges the API by the share of requests failing with server errors
+func (r *SystemStatusReporter) apiStatus() string {
+	if r.errors == nil {
+		return models.SystemStatusOperational
+	}
+	requests, errors := r.errors.Counts()
+	if requests < minStatusRequests {
+		return models.SystemStatusOperational
+	}
+	rate := float64(errors) / float64(requests)
+	switch {
+	case rate >= apiOutageErrorRate:
+		return models.SystemStatusOutage
+	case rate >= apiDegradedErrorRate:
+		return models.SystemStatusDegraded
+	}
+	return models.SystemStatusOperational
+}
+
+// vmControllerLeader returns the live vm-controller replica holding the
+// leader lease, if any, and whether any vm-controller heartbeat was recorded
+func vmControllerLeader(heartbeats []models.ComponentHeartbeat, now time.Time) (*models.ComponentHeartbeat, bool) {
+	seen := false
+	for i := range heartbeats {
+		heartbeat := &heartbeats[i]
+		if heartbeat.Component != vmControllerComponent {
+			continue
+		}
+		seen = true
+		if heartbeat.Leader && !heartbeat.Stale(now) {
+			return heartbeat, true
+		}
+	}
+	return nil, seen
+}
+
+// provisioningStatus is out when no vm-controller leads, and degraded when its
+// VM controllers are failing or many recent VM tasks failed. Without any
+// vm-controller heartbeat, as when heartbeats are disabled, only tasks count.
+func provisioningStatus(leader *models.ComponentHeartbeat, controllerSeen bool, taskCounts map[string]int64, now time.Time) string {
+	if controllerSeen && leader == nil {
+		return models.SystemStatusOutage
+	}
+	if leader != nil && anyControllerFailing(leader, provisioningControllers, now) {
+		return models.SystemStatusDegraded
+	}
+	var total int64
+	for _, count := range taskCounts {
+		total += count
+	}
+	if total >= minStatusTasks && float64(taskCounts[models.TaskStatusError])/float64(total) >= taskDegradedErrorRate {
+		return models.SystemStatusDegraded
+	}
+	return models.SystemStatusOperational
+}
+
+// networkingStatus is degraded when the controllers maintaining VDC networks
+// and DNS records are failing. VM traffic does not pass through SSVirt, so a
+// stopped vm-controller only delays network changes and is reported under
+// provisioning.
+func networkingStatus(leader *models.ComponentHeartbeat, now time.Time) string {
+	if leader != nil && anyControllerFailing(leader, networkingControllers, now) {
+		return models.SystemStatusDegraded
+	}
+	return models.SystemStatusOperational
+}
+
+// anyControllerFailing reports whether one of the named controllers of the
+// heartbeat has reconciled without success for controllerFailingAfter
+func anyControllerFailing(heartbeat *models.ComponentHeartbeat, names []string, now time.Time) bool {
+	for _, controller := range heartbeat.Controllers {
+		if !slices.Contains(names, controller.Name) || controller.LastReconcile == nil {
+			continue
+		}
+		since := heartbeat.StartedAt
+		if controller.LastSuccess != nil {
+			since = *controller.LastSuccess
+		}
+		if controller.LastReconcile.After(since) && now.Sub(since) > controllerFailingAfter {
+			return true
+		}
+	}
+	return false
+}
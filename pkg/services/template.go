@@ -4,23 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
-	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	templatev1 "github.com/openshift/api/template/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
-	domainerrors "github.com/mhrivnak/ssvirt/pkg/domain/errors"
 )
 
 // TemplateService provides access to OpenShift Templates via Kubernetes client
@@ -42,7 +40,12 @@ func NewTemplateService() (*TemplateService, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubernetes config: %w", err)
 	}
+	return NewTemplateServiceForConfig(cfg)
+}
 
+// NewTemplateServiceForConfig creates a new TemplateService connected to the
+// cluster described by cfg
+func NewTemplateServiceForConfig(cfg *rest.Config) (*TemplateService, error) {
 	scheme := runtime.NewScheme()
 	if err := templatev1.AddToScheme(scheme); err != nil {
 		return nil, fmt.Errorf("failed to add template scheme: %w", err)
@@ -72,86 +75,51 @@ func (s *TemplateService) Start(ctx context.Context) error {
 	return s.cache.Start(ctx)
 }
 
-// ListCatalogItems returns catalog items for the specified catalog with pagination
-func (s *TemplateService) ListCatalogItems(ctx context.Context, catalogID string, limit, offset int) ([]models.CatalogItem, error) {
+// ListCatalogItemRecords returns a catalog item record for every eligible template
+func (s *TemplateService) ListCatalogItemRecords(ctx context.Context) ([]models.CatalogItemRecord, error) {
 	templates, err := s.getFilteredTemplates(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert templates to catalog items
-	var catalogItems []models.CatalogItem
-	for _, template := range templates {
-		catalogItem := s.mapper.TemplateToCatalogItem(&template, catalogID)
-		catalogItems = append(catalogItems, *catalogItem)
+	records := make([]models.CatalogItemRecord, len(templates))
+	for i := range templates {
+		records[i] = *s.mapper.TemplateToRecord(&templates[i])
 	}
-
-	// Sort catalog items alphabetically by name for deterministic ordering
-	sort.Slice(catalogItems, func(i, j int) bool {
-		return catalogItems[i].Name < catalogItems[j].Name
-	})
-
-	// Apply pagination
-	start := offset
-	end := offset + limit
-	if start > len(catalogItems) {
-		start = len(catalogItems)
-	}
-	if end > len(catalogItems) {
-		end = len(catalogItems)
-	}
-
-	return catalogItems[start:end], nil
+	return records, nil
 }
 
-// CountCatalogItems returns the total count of catalog items for the specified catalog
-func (s *TemplateService) CountCatalogItems(ctx context.Context, catalogID string) (int64, error) {
-	templates, err := s.getFilteredTemplates(ctx)
+// OnTemplateChange registers a callback invoked on any template add, update or delete
+func (s *TemplateService) OnTemplateChange(ctx context.Context, callback func()) error {
+	informer, err := s.cache.GetInformer(ctx, &templatev1.Template{})
 	if err != nil {
-		return 0, err
+		return fmt.Errorf("failed to get template informer: %w", err)
 	}
 
-	return int64(len(templates)), nil
-}
-
-// GetCatalogItem returns a specific catalog item by ID
-func (s *TemplateService) GetCatalogItem(ctx context.Context, catalogID, itemID string) (*models.CatalogItem, error) {
-	// Extract UUID from catalogitem URN
-	if !strings.HasPrefix(itemID, models.URNPrefixCatalogItem) {
-		return nil, fmt.Errorf("invalid catalog item URN format")
-	}
-
-	templateUID := strings.TrimPrefix(itemID, models.URNPrefixCatalogItem)
-
-	// Get all templates and find the one with matching UID
-	templates, err := s.getFilteredTemplates(ctx)
+	_, err = informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { callback() },
+		UpdateFunc: func(oldObj, newObj interface{}) { callback() },
+		DeleteFunc: func(obj interface{}) { callback() },
+	})
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to watch templates: %w", err)
 	}
-
-	for _, template := range templates {
-		if string(template.UID) == templateUID {
-			catalogItem := s.mapper.TemplateToCatalogItem(&template, catalogID)
-			return catalogItem, nil
-		}
-	}
-
-	return nil, domainerrors.ErrNotFound
+	return nil
 }
 
-// getFilteredTemplates retrieves templates from openshift namespace with required labels/annotations
+// getFilteredTemplates retrieves templates from the catalog template namespace with required labels/annotations
 func (s *TemplateService) getFilteredTemplates(ctx context.Context) ([]templatev1.Template, error) {
 	var templateList templatev1.TemplateList
 
 	// Create label selector for templates with required label existence
-	requirement, err := labels.NewRequirement("template.kubevirt.io/version", selection.Exists, nil)
+	requirement, err := labels.NewRequirement(TemplateVersionLabel, selection.Exists, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create label requirement: %w", err)
 	}
 	labelSelector := labels.NewSelector().Add(*requirement)
 
 	err = s.cache.List(ctx, &templateList, &client.ListOptions{
-		Namespace:     "openshift",
+		Namespace:     CatalogTemplateNamespace,
 		LabelSelector: labelSelector,
 	})
 	if err != nil {
@@ -162,7 +130,7 @@ func (s *TemplateService) getFilteredTemplates(ctx context.Context) ([]templatev
 	var filteredTemplates []templatev1.Template
 	for _, template := range templateList.Items {
 		if template.Annotations != nil {
-			if _, hasAnnotation := template.Annotations["template.kubevirt.io/containerdisks"]; hasAnnotation {
+			if _, hasAnnotation := template.Annotations[ContainerDisksAnnotation]; hasAnnotation {
 				filteredTemplates = append(filteredTemplates, template)
 			}
 		}
@@ -171,8 +139,8 @@ func (s *TemplateService) getFilteredTemplates(ctx context.Context) ([]templatev
 	return filteredTemplates, nil
 }
 
-// TemplateToCatalogItem converts an OpenShift Template to a CatalogItem
-func (m *TemplateMapper) TemplateToCatalogItem(template *templatev1.Template, catalogID string) *models.CatalogItem {
+// TemplateToRecord converts an OpenShift Template to a persisted catalog item record
+func (m *TemplateMapper) TemplateToRecord(template *templatev1.Template) *models.CatalogItemRecord {
 	description := ""
 	if template.Annotations != nil {
 		if desc, ok := template.Annotations["description"]; ok {
@@ -193,45 +161,46 @@ func (m *TemplateMapper) TemplateToCatalogItem(template *templatev1.Template, ca
 	// Extract resource requirements
 	numberOfVMs := m.ExtractVMCount(template)
 	numberOfCpus, memoryAllocation, storageAllocation := m.ExtractResourceRequirements(template)
+	osType, osFamily := TemplateOS(template)
 
 	// Estimate size (simplified calculation)
 	size := int64(numberOfVMs * 2 * 1024 * 1024 * 1024) // 2GB per VM estimate
 
-	// Extract catalog ID from full catalog URN (urn:vcloud:catalog:uuid)
-	catalogUUID := strings.TrimPrefix(catalogID, models.URNPrefixCatalog)
-	// URL-encode template name to handle special characters
-	encodedTemplateName := url.QueryEscape(template.Name)
-
-	return &models.CatalogItem{
-		ID:           fmt.Sprintf("%s%s:%s", models.URNPrefixCatalogItem, catalogUUID, encodedTemplateName),
-		Name:         template.Name,
-		Description:  description,
-		CatalogID:    catalogID,
-		IsPublished:  isPublished,
-		IsExpired:    false,
-		CreationDate: template.CreationTimestamp.Format(time.RFC3339),
-		Size:         size,
-		Status:       "AVAILABLE",
-		Entity: models.CatalogItemEntity{
-			Name:              template.Name,
-			Description:       description,
-			Type:              "application/vnd.vmware.vcloud.vAppTemplate+xml",
-			NumberOfVMs:       numberOfVMs,
-			NumberOfCpus:      numberOfCpus,
-			MemoryAllocation:  memoryAllocation,
-			StorageAllocation: storageAllocation,
-		},
-		Owner: models.EntityRef{
-			Name: "System",
-			ID:   "",
-		},
-		Catalog: models.EntityRef{
-			Name: "Templates", // Default name, could be enhanced to look up actual catalog
-			ID:   catalogID,
-		},
+	validationStatus := models.CatalogItemValidationValid
+	problems := ValidateTemplate(template)
+	if len(problems) > 0 {
+		validationStatus = models.CatalogItemValidationInvalid
+	}
+
+	return &models.CatalogItemRecord{
+		TemplateUID:       string(template.UID),
+		Name:              template.Name,
+		Namespace:         template.Namespace,
+		Description:       description,
+		IsPublished:       isPublished,
+		NumberOfVMs:       numberOfVMs,
+		NumberOfCpus:      numberOfCpus,
+		MemoryAllocation:  memoryAllocation,
+		StorageAllocation: storageAllocation,
+		NumberOfGpus:      TemplateGPUCount(template),
+		Architecture:      TemplateArchitecture(template),
+		OSType:            osType,
+		OSFamily:          osFamily,
+		IconClass:         template.Annotations["iconClass"],
+		Size:              size,
+		ResourceVersion:   template.ResourceVersion,
+		TemplateCreatedAt: template.CreationTimestamp.Time,
+		ValidationStatus:  validationStatus,
+		ValidationErrors:  strings.Join(problems, "\n"),
 	}
 }
 
+// TemplateToCatalogItem converts an OpenShift Template to a CatalogItem
+func (m *TemplateMapper) TemplateToCatalogItem(template *templatev1.Template, catalogID string) *models.CatalogItem {
+	catalogItem := m.TemplateToRecord(template).ToCatalogItem(catalogID, "Templates")
+	return &catalogItem
+}
+
 // ExtractVMCount counts the number of VM objects in the template
 func (m *TemplateMapper) ExtractVMCount(template *templatev1.Template) int {
 	count := 0
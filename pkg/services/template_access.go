@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// CatalogTemplateNamespace is the namespace whose Templates are published as
+// catalog items
+const CatalogTemplateNamespace = "openshift"
+
+// DefaultTemplateAccessCheckInterval is how often template namespaces and
+// permissions are checked again after startup
+const DefaultTemplateAccessCheckInterval = 5 * time.Minute
+
+// templateGroup is the API group of Templates and TemplateInstances
+const templateGroup = "template.openshift.io"
+
+// templateAccessRule is a permission SSVirt needs to instantiate templates
+type templateAccessRule struct {
+	resource string
+	verb     string
+}
+
+// templateNamespaceRules are needed in each template namespace to cache and
+// read Templates
+var templateNamespaceRules = []templateAccessRule{
+	{resource: "templates", verb: "get"},
+	{resource: "templates", verb: "list"},
+	{resource: "templates", verb: "watch"},
+}
+
+// templateInstanceRules are needed in every namespace, since TemplateInstances
+// are created in the namespace of the VDC
+var templateInstanceRules = []templateAccessRule{
+	{resource: "templateinstances", verb: "create"},
+	{resource: "templateinstances", verb: "get"},
+	{resource: "templateinstances", verb: "delete"},
+}
+
+// TemplateAccessStatus is the result of checking that the template namespaces
+// exist and that SSVirt may read their Templates and create TemplateInstances.
+// Problems describe what to fix.
+type TemplateAccessStatus struct {
+	Ready      bool                      `json:"ready"`
+	CheckedAt  time.Time                 `json:"checkedAt"`
+	Namespaces []TemplateNamespaceStatus `json:"namespaces"`
+	Problems   []string                  `json:"problems,omitempty"`
+}
+
+// TemplateNamespaceStatus is the result of checking one template namespace
+type TemplateNamespaceStatus struct {
+	Namespace string   `json:"namespace"`
+	Ready     bool     `json:"ready"`
+	Problems  []string `json:"problems,omitempty"`
+}
+
+// TemplateAccessChecker checks the template namespaces at startup and
+// periodically afterwards, so misconfiguration is reported by /readyz instead
+// of failing the first instantiation
+type TemplateAccessChecker struct {
+	clientset  kubernetes.Interface
+	namespaces []string
+	interval   time.Duration
+	logger     *slog.Logger
+	now        func() time.Time
+
+	mu     sync.RWMutex
+	status *TemplateAccessStatus
+}
+
+// NewTemplateAccessChecker creates a TemplateAccessChecker for the cluster the
+// process runs in
+func NewTemplateAccessChecker(namespaces []string, interval time.Duration, logger *slog.Logger) (*TemplateAccessChecker, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubernetes config: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	return NewTemplateAccessCheckerForClientset(clientset, namespaces, interval, logger), nil
+}
+
+// NewTemplateAccessCheckerForClientset creates a TemplateAccessChecker using
+// clientset. Duplicate and empty namespaces are ignored.
+func NewTemplateAccessCheckerForClientset(clientset kubernetes.Interface, namespaces []string, interval time.Duration, logger *slog.Logger) *TemplateAccessChecker {
+	if interval <= 0 {
+		interval = DefaultTemplateAccessCheckInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	unique := make(map[string]bool, len(namespaces))
+	allowed := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		if namespace == "" || unique[namespace] {
+			continue
+		}
+		unique[namespace] = true
+		allowed = append(allowed, namespace)
+	}
+	sort.Strings(allowed)
+	return &TemplateAccessChecker{
+		clientset:  clientset,
+		namespaces: allowed,
+		interval:   interval,
+		logger:     logger,
+		now:        time.Now,
+	}
+}
+
+// Start checks template access immediately and then periodically until the
+// context is cancelled
+func (c *TemplateAccessChecker) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		status := c.Check(ctx)
+		if !status.Ready && ctx.Err() == nil {
+			c.logger.Warn("Templates cannot be instantiated until template access is fixed", "problems", status.problems())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the result of the latest check, or false before the first
+// check completes
+func (c *TemplateAccessChecker) Status() (TemplateAccessStatus, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.status == nil {
+		return TemplateAccessStatus{}, false
+	}
+	return *c.status, true
+}
+
+// Check verifies that each template namespace exists and that its Templates
+// and TemplateInstances in all namespaces are accessible, and records the result
+func (c *TemplateAccessChecker) Check(ctx context.Context) TemplateAccessStatus {
+	status := TemplateAccessStatus{
+		Ready:      true,
+		CheckedAt:  c.now().UTC(),
+		Namespaces: make([]TemplateNamespaceStatus, 0, len(c.namespaces)),
+	}
+	for _, namespace := range c.namespaces {
+		namespaceStatus := c.checkNamespace(ctx, namespace)
+		status.Ready = status.Ready && namespaceStatus.Ready
+		status.Namespaces = append(status.Namespaces, namespaceStatus)
+	}
+	status.Problems = c.checkRules(ctx, "", templateInstanceRules)
+	status.Ready = status.Ready && len(status.Problems) == 0
+
+	c.mu.Lock()
+	c.status = &status
+	c.mu.Unlock()
+	return status
+}
+
+// checkNamespace checks that a template namespace exists and its Templates can
+// be read
+func (c *TemplateAccessChecker) checkNamespace(ctx context.Context, namespace string) TemplateNamespaceStatus {
+	status := TemplateNamespaceStatus{Namespace: namespace}
+	_, err := c.clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		status.Problems = append(status.Problems, fmt.Sprintf("namespace %s does not exist; create it or set TEMPLATE_NAMESPACE to the namespace holding the templates", namespace))
+		return status
+	case errors.IsForbidden(err):
+		status.Problems = append(status.Problems, fmt.Sprintf("the service account may not get namespace %s; grant it get on namespaces", namespace))
+	case err != nil:
+		status.Problems = append(status.Problems, fmt.Sprintf("failed to get namespace %s: %v", namespace, err))
+	}
+	status.Problems = append(status.Problems, c.checkRules(ctx, namespace, templateNamespaceRules)...)
+	status.Ready = len(status.Problems) == 0
+	return status
+}
+
+// checkRules asks the API server whether the service account holds each rule in
+// namespace, or in all namespaces when namespace is empty
+func (c *TemplateAccessChecker) checkRules(ctx context.Context, namespace string, rules []templateAccessRule) []string {
+	scope := "in namespace " + namespace
+	if namespace == "" {
+		scope = "in all namespaces"
+	}
+
+	var problems []string
+	for _, rule := range rules {
+		review, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      rule.verb,
+					Group:     templateGroup,
+					Resource:  rule.resource,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("failed to check %s permission on %s %s: %v", rule.verb, rule.resource, scope, err))
+			continue
+		}
+		if !review.Status.Allowed {
+			problems = append(problems, fmt.Sprintf("the service account may not %s %s.%s %s; grant it in the API server's RBAC role", rule.verb, rule.resource, templateGroup, scope))
+		}
+	}
+	return problems
+}
+
+// problems returns every problem found, for logging
+func (s TemplateAccessStatus) problems() []string {
+	problems := append([]string{}, s.Problems...)
+	for _, namespace := range s.Namespaces {
+		problems = append(problems, namespace.Problems...)
+	}
+	return problems
+}
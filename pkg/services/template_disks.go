@@ -0,0 +1,264 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// Disk selects the tier and storage profile of a disk the Template's VMs declare
+type Disk struct {
+	// Name matches the volume name in the VirtualMachine's template spec
+	Name string `json:"name"`
+	// Tier converts the disk to ephemeral or persistent storage when set
+	Tier string `json:"tier,omitempty"`
+	// StorageClass places the persistent disk in the storage class when set
+	StorageClass string `json:"storageClass,omitempty"`
+}
+
+// DiskDefaults configure the disks an instantiation does not list, usually from
+// the VDC's default storage profile
+type DiskDefaults struct {
+	// Tier converts disks that support it to ephemeral or persistent storage
+	Tier string `json:"tier,omitempty"`
+	// StorageClass places persistent disks that name none in the storage class
+	StorageClass string `json:"storageClass,omitempty"`
+}
+
+// diskTier returns the tier of a VirtualMachine volume, or "" when the volume
+// is not a disk that can be tiered, such as a cloud-init volume
+func diskTier(volume map[string]interface{}) models.DiskTier {
+	switch {
+	case volume["containerDisk"] != nil, volume["emptyDisk"] != nil:
+		return models.DiskTierEphemeral
+	case volume["dataVolume"] != nil, volume["persistentVolumeClaim"] != nil:
+		return models.DiskTierPersistent
+	default:
+		return ""
+	}
+}
+
+// ConfigureDisks applies the requested tiers and storage classes to the named
+// disks of the Template's VirtualMachines, and the defaults to the others. A
+// disk's tier is given by its volume: containerDisk and emptyDisk volumes are
+// ephemeral, dataVolume and persistentVolumeClaim volumes are persistent.
+//
+// Blank DataVolumes and emptyDisks convert to each other keeping their size,
+// and DataVolumes imported from a registry become containerDisks. Requesting
+// any other conversion is an error; defaults skip disks that cannot convert.
+// Every requested disk must be declared by at least one VirtualMachine.
+func ConfigureDisks(template *templatev1.Template, disks []Disk, defaults DiskDefaults) error {
+	requested := make(map[string]Disk, len(disks))
+	for _, disk := range disks {
+		requested[disk.Name] = disk
+	}
+	found := make(map[string]bool, len(disks))
+
+	for i, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+
+		volumesPath := []string{"spec", "template", "spec", "volumes"}
+		volumes, _, err := unstructured.NestedSlice(vm.Object, volumesPath...)
+		if err != nil {
+			return fmt.Errorf("object %d has invalid volumes: %w", i, err)
+		}
+		dataVolumes, _, err := unstructured.NestedSlice(vm.Object, "spec", "dataVolumeTemplates")
+		if err != nil {
+			return fmt.Errorf("object %d has invalid dataVolumeTemplates: %w", i, err)
+		}
+		vmDisk := &vmDisks{vmName: vm.GetName(), dataVolumes: dataVolumes}
+
+		for j, volume := range volumes {
+			volumeMap, ok := volume.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("object %d has invalid volume %d", i, j)
+			}
+			tier := diskTier(volumeMap)
+			if tier == "" {
+				continue
+			}
+			name, _ := volumeMap["name"].(string)
+			disk, explicit := requested[name]
+			if explicit {
+				found[name] = true
+			}
+
+			target := models.DiskTier(defaults.Tier)
+			if disk.Tier != "" {
+				target = models.DiskTier(disk.Tier)
+			}
+			if target != "" && target != tier {
+				converted, err := vmDisk.convert(volumeMap, target)
+				switch {
+				case err != nil && disk.Tier != "":
+					return fmt.Errorf("disk %q cannot be made %s: %w", name, target, err)
+				case err == nil:
+					volumeMap, tier = converted, target
+				}
+			}
+			volumes[j] = volumeMap
+
+			if disk.StorageClass != "" {
+				if err := vmDisk.setStorageClass(volumeMap, disk.StorageClass, true); err != nil {
+					return fmt.Errorf("disk %q cannot use storage profile %s: %w", name, disk.StorageClass, err)
+				}
+			} else if defaults.StorageClass != "" && tier == models.DiskTierPersistent {
+				// Claims the template names itself are left in their storage class
+				_ = vmDisk.setStorageClass(volumeMap, defaults.StorageClass, false)
+			}
+		}
+
+		if err := unstructured.SetNestedSlice(vm.Object, volumes, volumesPath...); err != nil {
+			return fmt.Errorf("object %d: failed to set volumes: %w", i, err)
+		}
+		if len(vmDisk.dataVolumes) > 0 {
+			err = unstructured.SetNestedSlice(vm.Object, vmDisk.dataVolumes, "spec", "dataVolumeTemplates")
+		} else {
+			unstructured.RemoveNestedField(vm.Object, "spec", "dataVolumeTemplates")
+		}
+		if err != nil {
+			return fmt.Errorf("object %d: failed to set dataVolumeTemplates: %w", i, err)
+		}
+		raw, err := json.Marshal(vm.Object)
+		if err != nil {
+			return fmt.Errorf("object %d: failed to encode VirtualMachine: %w", i, err)
+		}
+		template.Objects[i] = runtime.RawExtension{Raw: raw}
+	}
+
+	for _, disk := range disks {
+		if !found[disk.Name] {
+			return fmt.Errorf("template declares no disk named %q", disk.Name)
+		}
+	}
+	return nil
+}
+
+// vmDisks converts the disks of one VirtualMachine, whose DataVolume templates
+// change as disks move between tiers
+type vmDisks struct {
+	vmName      string
+	dataVolumes []interface{}
+}
+
+// dataVolume returns the index and spec of the named DataVolume template
+func (d *vmDisks) dataVolume(name string) (int, map[string]interface{}, error) {
+	for i, dv := range d.dataVolumes {
+		dvMap, ok := dv.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if dvName, _, _ := unstructured.NestedString(dvMap, "metadata", "name"); dvName != name {
+			continue
+		}
+		spec, _, err := unstructured.NestedMap(dvMap, "spec")
+		if err != nil || spec == nil {
+			return 0, nil, fmt.Errorf("DataVolume template %s has no spec", name)
+		}
+		return i, spec, nil
+	}
+	return 0, nil, fmt.Errorf("DataVolume %s is not created by the VirtualMachine", name)
+}
+
+// convert returns the volume rewritten for the target tier
+func (d *vmDisks) convert(volume map[string]interface{}, target models.DiskTier) (map[string]interface{}, error) {
+	name := volume["name"]
+	switch target {
+	case models.DiskTierEphemeral:
+		dvName, found, _ := unstructured.NestedString(volume, "dataVolume", "name")
+		if !found {
+			return nil, fmt.Errorf("it uses an existing PersistentVolumeClaim")
+		}
+		index, spec, err := d.dataVolume(dvName)
+		if err != nil {
+			return nil, err
+		}
+
+		var converted map[string]interface{}
+		if _, blank, _ := unstructured.NestedMap(spec, "source", "blank"); blank {
+			size := dataVolumeSize(spec)
+			if size == "" {
+				return nil, fmt.Errorf("DataVolume %s requests no storage size", dvName)
+			}
+			converted = map[string]interface{}{"name": name, "emptyDisk": map[string]interface{}{"capacity": size}}
+		} else if url, _, _ := unstructured.NestedString(spec, "source", "registry", "url"); url != "" {
+			image := strings.TrimPrefix(url, "docker://")
+			converted = map[string]interface{}{"name": name, "containerDisk": map[string]interface{}{"image": image}}
+		} else {
+			return nil, fmt.Errorf("only blank and registry DataVolumes can be ephemeral")
+		}
+		d.dataVolumes = append(d.dataVolumes[:index], d.dataVolumes[index+1:]...)
+		return converted, nil
+
+	case models.DiskTierPersistent:
+		capacity, found, _ := unstructured.NestedString(volume, "emptyDisk", "capacity")
+		if !found {
+			return nil, fmt.Errorf("only empty disks can be persistent")
+		}
+		if d.vmName == "" {
+			return nil, fmt.Errorf("the VirtualMachine has no name to derive a DataVolume name from")
+		}
+		dvName := fmt.Sprintf("%s-%s", d.vmName, name)
+		d.dataVolumes = append(d.dataVolumes, map[string]interface{}{
+			"metadata": map[string]interface{}{"name": dvName},
+			"spec": map[string]interface{}{
+				"source": map[string]interface{}{"blank": map[string]interface{}{}},
+				"storage": map[string]interface{}{
+					"resources": map[string]interface{}{
+						"requests": map[string]interface{}{"storage": capacity},
+					},
+				},
+			},
+		})
+		return map[string]interface{}{"name": name, "dataVolume": map[string]interface{}{"name": dvName}}, nil
+	}
+	return nil, fmt.Errorf("unknown disk tier %s", target)
+}
+
+// setStorageClass places the volume's DataVolume in the storage class. Unless
+// override is set, a storage class the template already names is kept.
+func (d *vmDisks) setStorageClass(volume map[string]interface{}, storageClass string, override bool) error {
+	dvName, found, _ := unstructured.NestedString(volume, "dataVolume", "name")
+	if !found {
+		if volume["persistentVolumeClaim"] != nil {
+			return fmt.Errorf("it uses an existing PersistentVolumeClaim")
+		}
+		return fmt.Errorf("it is ephemeral")
+	}
+	index, spec, err := d.dataVolume(dvName)
+	if err != nil {
+		return err
+	}
+
+	// DataVolumes request storage through either the storage or the pvc API
+	field := "storage"
+	if _, hasPVC := spec["pvc"]; hasPVC {
+		field = "pvc"
+	}
+	if current, _, _ := unstructured.NestedString(spec, field, "storageClassName"); current != "" && !override {
+		return nil
+	}
+	if err := unstructured.SetNestedField(spec, storageClass, field, "storageClassName"); err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(d.dataVolumes[index].(map[string]interface{}), spec, "spec")
+}
+
+// dataVolumeSize returns the storage a DataVolume spec requests
+func dataVolumeSize(spec map[string]interface{}) string {
+	for _, field := range []string{"storage", "pvc"} {
+		if size, _, _ := unstructured.NestedString(spec, field, "resources", "requests", "storage"); size != "" {
+			return size
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// firmwareSerialPrefix starts the SMBIOS serial numbers given to VMs
+const firmwareSerialPrefix = "SSVIRT-"
+
+// FirmwareSerial returns the SMBIOS serial number for a VM with the given BIOS
+// UUID, so the two identify the VM alike
+func FirmwareSerial(biosUUID string) string {
+	return firmwareSerialPrefix + strings.ToUpper(strings.ReplaceAll(biosUUID, "-", ""))
+}
+
+// AddFirmwareIdentity gives every VirtualMachine in the Template its own BIOS
+// UUID and SMBIOS serial number. KubeVirt keeps them in the VM spec, so guests
+// see the same hardware identity across restarts and live migrations, and
+// licensing tools inside them stay bound to the VM. Values the Template sets
+// are replaced, since every VM instantiated from it would otherwise share them.
+func AddFirmwareIdentity(template *templatev1.Template) error {
+	for i, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+
+		biosUUID := uuid.NewString()
+		if err := unstructured.SetNestedField(vm.Object, biosUUID, "spec", "template", "spec", "domain", "firmware", "uuid"); err != nil {
+			return fmt.Errorf("object %d: failed to set firmware UUID: %w", i, err)
+		}
+		if err := unstructured.SetNestedField(vm.Object, FirmwareSerial(biosUUID), "spec", "template", "spec", "domain", "firmware", "serial"); err != nil {
+			return fmt.Errorf("object %d: failed to set firmware serial: %w", i, err)
+		}
+
+		raw, err := json.Marshal(vm.Object)
+		if err != nil {
+			return fmt.Errorf("object %d: failed to encode VirtualMachine: %w", i, err)
+		}
+		template.Objects[i] = runtime.RawExtension{Raw: raw}
+	}
+	return nil
+}
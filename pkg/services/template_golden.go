@@ -0,0 +1,148 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	cdiv1 "kubevirt.io/containerized-data-importer-api/pkg/apis/core/v1beta1"
+)
+
+// goldenImageSources are the DataVolume sources that download an image, and
+// so are worth importing ahead of instantiation
+var goldenImageSources = []string{"registry", "http", "s3", "gcs"}
+
+// GoldenImage is a base image a Template's VirtualMachines import into their
+// DataVolumes. The prewarm controller imports it once into a VDC namespace, so
+// the VirtualMachines of pinned catalog items clone it instead.
+type GoldenImage struct {
+	// Name of the golden image DataVolume, derived from the source and size so
+	// DataVolume templates importing the same image share it
+	Name   string
+	Source cdiv1.DataVolumeSource
+	// Size is the storage the DataVolume templates request; clones need at
+	// least the size of their source
+	Size string
+}
+
+// TemplateGoldenImages returns the golden images of the DataVolume templates of
+// the Template's VirtualMachines, without duplicates. Sources that depend on
+// Template parameters are skipped, as they are only known at instantiation.
+func TemplateGoldenImages(template *templatev1.Template) ([]GoldenImage, error) {
+	var images []GoldenImage
+	seen := make(map[string]bool)
+	err := forEachDataVolumeTemplate(template, func(spec map[string]interface{}) (bool, error) {
+		name, source, size, ok := goldenImageSource(spec)
+		if !ok || seen[name] {
+			return false, nil
+		}
+		seen[name] = true
+
+		image := GoldenImage{Name: name, Size: size}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(source, &image.Source); err != nil {
+			return false, fmt.Errorf("DataVolume source is invalid: %w", err)
+		}
+		images = append(images, image)
+		return false, nil
+	})
+	return images, err
+}
+
+// UseGoldenImages makes the DataVolume templates of the Template's
+// VirtualMachines clone the ready golden images in the namespace instead of
+// importing their source. DataVolume templates whose golden image is not
+// ready are left unchanged.
+func UseGoldenImages(template *templatev1.Template, namespace string, ready []string) error {
+	readyNames := make(map[string]bool, len(ready))
+	for _, name := range ready {
+		readyNames[name] = true
+	}
+	return forEachDataVolumeTemplate(template, func(spec map[string]interface{}) (bool, error) {
+		name, _, _, ok := goldenImageSource(spec)
+		if !ok || !readyNames[name] {
+			return false, nil
+		}
+		spec["source"] = map[string]interface{}{
+			"pvc": map[string]interface{}{"namespace": namespace, "name": name},
+		}
+		return true, nil
+	})
+}
+
+// forEachDataVolumeTemplate calls visit with the spec of every DataVolume
+// template of the Template's VirtualMachines. VirtualMachines are re-encoded
+// when visit reports that it changed a spec.
+func forEachDataVolumeTemplate(template *templatev1.Template, visit func(spec map[string]interface{}) (bool, error)) error {
+	for i, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+		dataVolumes, _, err := unstructured.NestedSlice(vm.Object, "spec", "dataVolumeTemplates")
+		if err != nil {
+			return fmt.Errorf("object %d has invalid dataVolumeTemplates: %w", i, err)
+		}
+
+		changed := false
+		for j, dv := range dataVolumes {
+			dvMap, ok := dv.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			spec, ok := dvMap["spec"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			updated, err := visit(spec)
+			if err != nil {
+				return fmt.Errorf("object %d: DataVolume template %d: %w", i, j, err)
+			}
+			changed = changed || updated
+		}
+		if !changed {
+			continue
+		}
+
+		if err := unstructured.SetNestedSlice(vm.Object, dataVolumes, "spec", "dataVolumeTemplates"); err != nil {
+			return fmt.Errorf("object %d: failed to set dataVolumeTemplates: %w", i, err)
+		}
+		raw, err := json.Marshal(vm.Object)
+		if err != nil {
+			return fmt.Errorf("object %d: failed to encode VirtualMachine: %w", i, err)
+		}
+		template.Objects[i] = runtime.RawExtension{Raw: raw}
+	}
+	return nil
+}
+
+// goldenImageSource returns the golden image name, source and size of a
+// DataVolume spec, or false when the DataVolume imports no image or its
+// source depends on Template parameters
+func goldenImageSource(spec map[string]interface{}) (string, map[string]interface{}, string, bool) {
+	source, ok := spec["source"].(map[string]interface{})
+	if !ok || len(source) != 1 {
+		return "", nil, "", false
+	}
+	importable := false
+	for _, kind := range goldenImageSources {
+		if _, found := source[kind]; found {
+			importable = true
+		}
+	}
+	size := dataVolumeSize(spec)
+	if !importable || size == "" {
+		return "", nil, "", false
+	}
+
+	key, err := json.Marshal(map[string]interface{}{"source": source, "size": size})
+	if err != nil || strings.Contains(string(key), "${") {
+		return "", nil, "", false
+	}
+	sum := sha256.Sum256(key)
+	return "golden-" + hex.EncodeToString(sum[:])[:16], source, size, true
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// reservedMetadataPrefixes are label and annotation key prefixes owned by
+// ssvirt, KubeVirt and the template service broker, which propagated metadata
+// must not set
+var reservedMetadataPrefixes = []string{
+	"ssvirt.io/",
+	"vapp.ssvirt",
+	"vdc.ssvirt.io/",
+	"app.kubernetes.io/managed-by",
+	"kubevirt.io/",
+	"template.openshift.io/",
+}
+
+// ValidateMetadataPolicy checks that a policy's label and annotation keys are
+// valid Kubernetes keys outside the reserved prefixes and that its label
+// values are valid label values
+func ValidateMetadataPolicy(policy models.MetadataPolicy) error {
+	check := func(kind, key string) error {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("%s key '%s' is invalid: %s", kind, key, strings.Join(errs, "; "))
+		}
+		for _, prefix := range reservedMetadataPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return fmt.Errorf("%s key '%s' uses the reserved prefix '%s'", kind, key, prefix)
+			}
+		}
+		return nil
+	}
+	for _, key := range sortedKeys(policy.Labels) {
+		if err := check("label", key); err != nil {
+			return err
+		}
+		if errs := validation.IsValidLabelValue(policy.Labels[key]); len(errs) > 0 {
+			return fmt.Errorf("label '%s' has an invalid value: %s", key, strings.Join(errs, "; "))
+		}
+	}
+	for _, key := range sortedKeys(policy.Annotations) {
+		if err := check("annotation", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergeMetadataPolicies combines policies in order, later policies overriding
+// the keys of earlier ones
+func MergeMetadataPolicies(policies ...models.MetadataPolicy) models.MetadataPolicy {
+	merged := models.MetadataPolicy{}
+	for _, policy := range policies {
+		for key, value := range policy.Labels {
+			if merged.Labels == nil {
+				merged.Labels = make(map[string]string)
+			}
+			merged.Labels[key] = value
+		}
+		for key, value := range policy.Annotations {
+			if merged.Annotations == nil {
+				merged.Annotations = make(map[string]string)
+			}
+			merged.Annotations[key] = value
+		}
+	}
+	return merged
+}
+
+// AddPropagatedMetadata sets labels and annotations on every VirtualMachine in
+// the Template and on its VM pod template, from which KubeVirt labels the
+// virt-launcher pods. They override metadata the Template sets with the same keys.
+func AddPropagatedMetadata(template *templatev1.Template, labels, annotations map[string]string) error {
+	for i, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+
+		for _, path := range [][]string{{"metadata"}, {"spec", "template", "metadata"}} {
+			if err := mergeStringMap(vm.Object, labels, append(path, "labels")...); err != nil {
+				return fmt.Errorf("object %d: %w", i, err)
+			}
+			if err := mergeStringMap(vm.Object, annotations, append(path, "annotations")...); err != nil {
+				return fmt.Errorf("object %d: %w", i, err)
+			}
+		}
+
+		raw, err := json.Marshal(vm.Object)
+		if err != nil {
+			return fmt.Errorf("object %d: failed to encode VirtualMachine: %w", i, err)
+		}
+		template.Objects[i] = runtime.RawExtension{Raw: raw}
+	}
+	return nil
+}
+
+// mergeStringMap adds values to the string map at path, creating it when absent
+func mergeStringMap(obj map[string]interface{}, values map[string]string, path ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	existing, _, err := unstructured.NestedStringMap(obj, path...)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", strings.Join(path, "."), err)
+	}
+	if existing == nil {
+		existing = make(map[string]string, len(values))
+	}
+	for key, value := range values {
+		existing[key] = value
+	}
+	if err := unstructured.SetNestedStringMap(obj, existing, path...); err != nil {
+		return fmt.Errorf("failed to set %s: %w", strings.Join(path, "."), err)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a map in order, so validation errors are stable
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
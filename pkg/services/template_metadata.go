@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Template labels and annotations describing the guest a Template runs
+const (
+	// ArchitectureLabel names the CPU architecture a Template's VMs require.
+	// It may also be set as an annotation.
+	ArchitectureLabel = "template.kubevirt.io/architecture"
+	// OSLabelPrefix prefixes the common-templates label naming the guest OS,
+	// e.g. os.template.kubevirt.io/fedora40: "true"
+	OSLabelPrefix = "os.template.kubevirt.io/"
+)
+
+// Guest OS families
+const (
+	OSFamilyLinux   = "linux"
+	OSFamilyWindows = "windows"
+)
+
+// architectureAliases maps kernel and vendor architecture names to Kubernetes names
+var architectureAliases = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+}
+
+// NormalizeArchitecture converts an architecture name to the form used by the
+// kubernetes.io/arch node label
+func NormalizeArchitecture(arch string) string {
+	arch = strings.ToLower(strings.TrimSpace(arch))
+	if alias, ok := architectureAliases[arch]; ok {
+		return alias
+	}
+	return arch
+}
+
+// TemplateArchitecture returns the CPU architecture a Template's VMs require,
+// from the architecture label or annotation, falling back to the architecture
+// declared on its VirtualMachine objects. It returns "" when unspecified.
+func TemplateArchitecture(template *templatev1.Template) string {
+	if arch := template.Labels[ArchitectureLabel]; arch != "" {
+		return NormalizeArchitecture(arch)
+	}
+	if arch := template.Annotations[ArchitectureLabel]; arch != "" {
+		return NormalizeArchitecture(arch)
+	}
+
+	for _, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+		arch, _, _ := unstructured.NestedString(vm.Object, "spec", "template", "spec", "architecture")
+		if arch != "" {
+			return NormalizeArchitecture(arch)
+		}
+	}
+	return ""
+}
+
+// TemplateOS returns the guest OS identifier and family of a Template from its
+// common-templates OS labels. Both are "" when the Template carries no OS label.
+func TemplateOS(template *templatev1.Template) (osType, osFamily string) {
+	var names []string
+	for key, value := range template.Labels {
+		if name, ok := strings.CutPrefix(key, OSLabelPrefix); ok && name != "" && value == "true" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", ""
+	}
+
+	// Templates often list several OS versions; pick the last so the choice is stable
+	sort.Strings(names)
+	osType = names[len(names)-1]
+	if strings.HasPrefix(osType, "win") {
+		return osType, OSFamilyWindows
+	}
+	return osType, OSFamilyLinux
+}
+
+// AddArchitectureAffinity requires every VirtualMachine in the Template to be
+// scheduled onto nodes of the given architecture. The requirement is added to
+// each existing node selector term so it holds whichever term matches.
+func AddArchitectureAffinity(template *templatev1.Template, arch string) error {
+	requirement := map[string]interface{}{
+		"key":      corev1.LabelArchStable,
+		"operator": string(corev1.NodeSelectorOpIn),
+		"values":   []interface{}{arch},
+	}
+
+	for i, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+
+		path := []string{"spec", "template", "spec", "affinity", "nodeAffinity", "requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms"}
+		terms, _, err := unstructured.NestedSlice(vm.Object, path...)
+		if err != nil {
+			return fmt.Errorf("object %d has invalid node affinity: %w", i, err)
+		}
+		if len(terms) == 0 {
+			terms = []interface{}{map[string]interface{}{}}
+		}
+		for j, term := range terms {
+			termMap, ok := term.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("object %d has invalid node selector term %d", i, j)
+			}
+			expressions, _, _ := unstructured.NestedSlice(termMap, "matchExpressions")
+			termMap["matchExpressions"] = append(expressions, runtime.DeepCopyJSONValue(requirement))
+			terms[j] = termMap
+		}
+		if err := unstructured.SetNestedSlice(vm.Object, terms, path...); err != nil {
+			return fmt.Errorf("object %d: failed to set node affinity: %w", i, err)
+		}
+
+		raw, err := json.Marshal(vm.Object)
+		if err != nil {
+			return fmt.Errorf("object %d: failed to encode VirtualMachine: %w", i, err)
+		}
+		template.Objects[i] = runtime.RawExtension{Raw: raw}
+	}
+	return nil
+}
+
+// decodeVirtualMachine decodes a Template object if it is a VirtualMachine
+func decodeVirtualMachine(obj runtime.RawExtension) (*unstructured.Unstructured, bool) {
+	if obj.Raw == nil {
+		return nil, false
+	}
+	// Decode numbers as json.Number so re-encoding preserves them exactly
+	decoder := json.NewDecoder(bytes.NewReader(obj.Raw))
+	decoder.UseNumber()
+	var content map[string]interface{}
+	if err := decoder.Decode(&content); err != nil {
+		return nil, false
+	}
+	vm := &unstructured.Unstructured{Object: content}
+	return vm, vm.GetKind() == "VirtualMachine"
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// interfaceBindings are the KubeVirt interface binding fields, one of which is
+// set on each interface
+var interfaceBindings = []string{"bridge", "masquerade", "sriov"}
+
+// NetworkInterface customizes a NIC the Template's VMs declare
+type NetworkInterface struct {
+	// Name matches the interface name in the VirtualMachine's domain devices
+	Name string `json:"name"`
+	// MACAddress pins the interface's MAC address when set
+	MACAddress string `json:"macAddress,omitempty"`
+	// InterfaceType replaces the interface binding (bridge, masquerade or sriov) when set
+	InterfaceType string `json:"interfaceType,omitempty"`
+}
+
+// ConfigureNetworkInterfaces applies MAC addresses and interface bindings to the
+// named interfaces of the Template's VirtualMachines. Every interface must be
+// declared by at least one VirtualMachine.
+func ConfigureNetworkInterfaces(template *templatev1.Template, nics []NetworkInterface) error {
+	found := make(map[string]bool, len(nics))
+
+	for i, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+
+		path := []string{"spec", "template", "spec", "domain", "devices", "interfaces"}
+		interfaces, _, err := unstructured.NestedSlice(vm.Object, path...)
+		if err != nil {
+			return fmt.Errorf("object %d has invalid interfaces: %w", i, err)
+		}
+
+		changed := false
+		for j, iface := range interfaces {
+			ifaceMap, ok := iface.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("object %d has invalid interface %d", i, j)
+			}
+			for _, nic := range nics {
+				if ifaceMap["name"] != nic.Name {
+					continue
+				}
+				found[nic.Name] = true
+				changed = true
+				if nic.MACAddress != "" {
+					ifaceMap["macAddress"] = nic.MACAddress
+				}
+				if nic.InterfaceType != "" {
+					for _, binding := range interfaceBindings {
+						delete(ifaceMap, binding)
+					}
+					ifaceMap[nic.InterfaceType] = map[string]interface{}{}
+				}
+			}
+			interfaces[j] = ifaceMap
+		}
+		if !changed {
+			continue
+		}
+
+		if err := unstructured.SetNestedSlice(vm.Object, interfaces, path...); err != nil {
+			return fmt.Errorf("object %d: failed to set interfaces: %w", i, err)
+		}
+		raw, err := json.Marshal(vm.Object)
+		if err != nil {
+			return fmt.Errorf("object %d: failed to encode VirtualMachine: %w", i, err)
+		}
+		template.Objects[i] = runtime.RawExtension{Raw: raw}
+	}
+
+	for _, nic := range nics {
+		if !found[nic.Name] {
+			return fmt.Errorf("template declares no network interface named %q", nic.Name)
+		}
+	}
+	return nil
+}
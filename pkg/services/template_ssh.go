@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// sshCloudInitVolume names the cloud-init disk added to VMs that have none, so
+// injected SSH keys have a cloud-init data source to be propagated through
+const sshCloudInitVolume = "ssvirt-cloudinit"
+
+// AddSSHKeyAccessCredentials configures every VirtualMachine in the Template to
+// receive the SSH public keys stored in the named Secret through cloud-init.
+// Keys are propagated through the VM's existing cloud-init config drive or
+// NoCloud volume; VMs without one get a NoCloud volume added.
+func AddSSHKeyAccessCredentials(template *templatev1.Template, secretName string) error {
+	for i, obj := range template.Objects {
+		vm, ok := decodeVirtualMachine(obj)
+		if !ok {
+			continue
+		}
+
+		propagation, err := ensureCloudInitVolume(vm)
+		if err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+
+		credentials, _, err := unstructured.NestedSlice(vm.Object, "spec", "template", "spec", "accessCredentials")
+		if err != nil {
+			return fmt.Errorf("object %d has invalid access credentials: %w", i, err)
+		}
+		credentials = append(credentials, map[string]interface{}{
+			"sshPublicKey": map[string]interface{}{
+				"source": map[string]interface{}{
+					"secret": map[string]interface{}{"secretName": secretName},
+				},
+				"propagationMethod": map[string]interface{}{propagation: map[string]interface{}{}},
+			},
+		})
+		if err := unstructured.SetNestedSlice(vm.Object, credentials, "spec", "template", "spec", "accessCredentials"); err != nil {
+			return fmt.Errorf("object %d: failed to set access credentials: %w", i, err)
+		}
+
+		raw, err := json.Marshal(vm.Object)
+		if err != nil {
+			return fmt.Errorf("object %d: failed to encode VirtualMachine: %w", i, err)
+		}
+		template.Objects[i] = runtime.RawExtension{Raw: raw}
+	}
+	return nil
+}
+
+// ensureCloudInitVolume returns the access credential propagation method matching
+// the VM's cloud-init volume, adding a NoCloud volume and disk when it has none
+func ensureCloudInitVolume(vm *unstructured.Unstructured) (string, error) {
+	volumes, _, err := unstructured.NestedSlice(vm.Object, "spec", "template", "spec", "volumes")
+	if err != nil {
+		return "", fmt.Errorf("invalid volumes: %w", err)
+	}
+	for _, volume := range volumes {
+		volumeMap, ok := volume.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := volumeMap["cloudInitConfigDrive"]; ok {
+			return "configDrive", nil
+		}
+		if _, ok := volumeMap["cloudInitNoCloud"]; ok {
+			return "noCloud", nil
+		}
+	}
+
+	volumes = append(volumes, map[string]interface{}{
+		"name":             sshCloudInitVolume,
+		"cloudInitNoCloud": map[string]interface{}{"userData": "#cloud-config\n"},
+	})
+	if err := unstructured.SetNestedSlice(vm.Object, volumes, "spec", "template", "spec", "volumes"); err != nil {
+		return "", fmt.Errorf("failed to add cloud-init volume: %w", err)
+	}
+
+	disks, _, err := unstructured.NestedSlice(vm.Object, "spec", "template", "spec", "domain", "devices", "disks")
+	if err != nil {
+		return "", fmt.Errorf("invalid disks: %w", err)
+	}
+	disks = append(disks, map[string]interface{}{
+		"name": sshCloudInitVolume,
+		"disk": map[string]interface{}{"bus": "virtio"},
+	})
+	if err := unstructured.SetNestedSlice(vm.Object, disks, "spec", "template", "spec", "domain", "devices", "disks"); err != nil {
+		return "", fmt.Errorf("failed to add cloud-init disk: %w", err)
+	}
+	return "noCloud", nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels and annotations that make a Template a catalog item
+const (
+	// TemplateVersionLabel opts a Template in the catalog namespace into the catalog
+	TemplateVersionLabel = "template.kubevirt.io/version"
+	// ContainerDisksAnnotation lists the container disks the Template's VMs boot from
+	ContainerDisksAnnotation = "template.kubevirt.io/containerdisks"
+)
+
+// Annotations the template validation controller writes to catalog Templates
+const (
+	ValidationStatusAnnotation = "catalog.ssvirt.io/validation-status"
+	ValidationErrorsAnnotation = "catalog.ssvirt.io/validation-errors"
+)
+
+// templateParameterRef matches ${NAME} and ${{NAME}} parameter references
+var templateParameterRef = regexp.MustCompile(`\$\{\{?([a-zA-Z0-9_]+)\}?\}`)
+
+// ValidateTemplate checks that a catalog Template can be instantiated by SSVirt
+// and returns a description of each problem found. A Template is valid when it:
+//   - contains at least one VirtualMachine object
+//   - declares every parameter its objects reference
+//   - gives every required parameter a default value or generator, since
+//     SSVirt instantiates templates without supplying parameter values
+//   - carries the container disks and description annotations the catalog presents
+func ValidateTemplate(template *templatev1.Template) []string {
+	var problems []string
+
+	declared := make(map[string]bool, len(template.Parameters))
+	for _, param := range template.Parameters {
+		declared[param.Name] = true
+		if param.Required && param.Value == "" && param.Generate == "" {
+			problems = append(problems, fmt.Sprintf("required parameter %s has no default value or generator", param.Name))
+		}
+	}
+
+	vmCount := 0
+	undeclared := make(map[string]bool)
+	for i, obj := range template.Objects {
+		if obj.Raw == nil {
+			continue
+		}
+		var typeMeta metav1.TypeMeta
+		if err := json.Unmarshal(obj.Raw, &typeMeta); err != nil {
+			problems = append(problems, fmt.Sprintf("object %d is not valid JSON", i))
+			continue
+		}
+		if typeMeta.Kind == "VirtualMachine" {
+			vmCount++
+		}
+		for _, match := range templateParameterRef.FindAllSubmatch(obj.Raw, -1) {
+			if name := string(match[1]); !declared[name] {
+				undeclared[name] = true
+			}
+		}
+	}
+	if vmCount == 0 {
+		problems = append(problems, "template contains no VirtualMachine objects")
+	}
+
+	names := make([]string, 0, len(undeclared))
+	for name := range undeclared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		problems = append(problems, fmt.Sprintf("parameter %s is referenced but not declared", name))
+	}
+
+	if _, ok := template.Annotations[ContainerDisksAnnotation]; !ok {
+		problems = append(problems, fmt.Sprintf("missing annotation %s", ContainerDisksAnnotation))
+	}
+	if template.Annotations["description"] == "" && template.Annotations["template.openshift.io/long-description"] == "" {
+		problems = append(problems, "missing annotation description")
+	}
+
+	return problems
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// VDCInfrastructure is the live Kubernetes state of a VDC namespace, so
+// administrators can verify what SSVirt created without cluster access
+type VDCInfrastructure struct {
+	Namespace       VDCNamespace       `json:"namespace"`
+	ResourceQuotas  []VDCResourceQuota `json:"resourceQuotas"`
+	LimitRanges     []VDCLimitRange    `json:"limitRanges"`
+	NetworkPolicies []VDCNetworkPolicy `json:"networkPolicies"`
+}
+
+// VDCNamespace describes the namespace of a VDC. Exists is false when it has
+// not been created or was deleted behind SSVirt's back.
+type VDCNamespace struct {
+	Name        string            `json:"name"`
+	Exists      bool              `json:"exists"`
+	Phase       string            `json:"phase,omitempty"`
+	CreatedAt   *time.Time        `json:"createdAt,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// VDCResourceQuota is a ResourceQuota with its enforced limits and usage
+type VDCResourceQuota struct {
+	Name string              `json:"name"`
+	Hard corev1.ResourceList `json:"hard"`
+	Used corev1.ResourceList `json:"used"`
+}
+
+// VDCLimitRange is a LimitRange with its default and bounding limits
+type VDCLimitRange struct {
+	Name   string                  `json:"name"`
+	Limits []corev1.LimitRangeItem `json:"limits"`
+}
+
+// VDCNetworkPolicy is a NetworkPolicy with its rules
+type VDCNetworkPolicy struct {
+	Name string                         `json:"name"`
+	Spec networkingv1.NetworkPolicySpec `json:"spec"`
+}
+
+// CollectVDCInfrastructure reads the namespace, ResourceQuotas, LimitRanges and
+// NetworkPolicies of a VDC namespace. A missing namespace is reported rather
+// than returned as an error.
+func CollectVDCInfrastructure(ctx context.Context, reader client.Reader, namespace string) (*VDCInfrastructure, error) {
+	infrastructure := &VDCInfrastructure{
+		Namespace:       VDCNamespace{Name: namespace},
+		ResourceQuotas:  []VDCResourceQuota{},
+		LimitRanges:     []VDCLimitRange{},
+		NetworkPolicies: []VDCNetworkPolicy{},
+	}
+
+	ns := &corev1.Namespace{}
+	err := reader.Get(ctx, client.ObjectKey{Name: namespace}, ns)
+	switch {
+	case errors.IsNotFound(err):
+		return infrastructure, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	created := ns.CreationTimestamp.Time
+	infrastructure.Namespace = VDCNamespace{
+		Name:        ns.Name,
+		Exists:      true,
+		Phase:       string(ns.Status.Phase),
+		CreatedAt:   &created,
+		Labels:      ns.Labels,
+		Annotations: ns.Annotations,
+	}
+
+	quotas := &corev1.ResourceQuotaList{}
+	if err := reader.List(ctx, quotas, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list ResourceQuotas in %s: %w", namespace, err)
+	}
+	for _, quota := range quotas.Items {
+		infrastructure.ResourceQuotas = append(infrastructure.ResourceQuotas, VDCResourceQuota{
+			Name: quota.Name,
+			Hard: quota.Status.Hard,
+			Used: quota.Status.Used,
+		})
+	}
+
+	limitRanges := &corev1.LimitRangeList{}
+	if err := reader.List(ctx, limitRanges, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list LimitRanges in %s: %w", namespace, err)
+	}
+	for _, limitRange := range limitRanges.Items {
+		infrastructure.LimitRanges = append(infrastructure.LimitRanges, VDCLimitRange{
+			Name:   limitRange.Name,
+			Limits: limitRange.Spec.Limits,
+		})
+	}
+
+	policies := &networkingv1.NetworkPolicyList{}
+	if err := reader.List(ctx, policies, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list NetworkPolicies in %s: %w", namespace, err)
+	}
+	for _, policy := range policies.Items {
+		infrastructure.NetworkPolicies = append(infrastructure.NetworkPolicies, VDCNetworkPolicy{
+			Name: policy.Name,
+			Spec: policy.Spec,
+		})
+	}
+	return infrastructure, nil
+}
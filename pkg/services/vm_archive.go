@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// VMArchiveStore moves DELETED VM records to the archive and prunes it
+type VMArchiveStore interface {
+	ArchiveDeletedBefore(ctx context.Context, before time.Time) (int64, error)
+	DeleteArchivedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// VMArchiver keeps the vms table from growing without bound. Deleting a VM only
+// marks its record DELETED, so records that stayed DELETED for the archive
+// period are moved with their tasks to the archive, where they are kept for
+// the retention period.
+type VMArchiver struct {
+	store        VMArchiveStore
+	interval     time.Duration
+	archiveAfter time.Duration
+	retention    time.Duration
+	logger       *slog.Logger
+	now          func() time.Time
+}
+
+// NewVMArchiver creates an archiver that runs every interval. A zero retention
+// keeps archived VMs forever.
+func NewVMArchiver(store VMArchiveStore, interval, archiveAfter, retention time.Duration, logger *slog.Logger) *VMArchiver {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &VMArchiver{
+		store:        store,
+		interval:     interval,
+		archiveAfter: archiveAfter,
+		retention:    retention,
+		logger:       logger,
+		now:          time.Now,
+	}
+}
+
+// Start archives and prunes VMs every interval until ctx is cancelled
+func (a *VMArchiver) Start(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Run(ctx); err != nil {
+				a.logger.Warn("Failed to archive deleted VMs", "error", err)
+			}
+		}
+	}
+}
+
+// Run archives the VMs DELETED for longer than the archive period and prunes
+// those archived for longer than the retention period
+func (a *VMArchiver) Run(ctx context.Context) error {
+	now := a.now()
+	archived, err := a.store.ArchiveDeletedBefore(ctx, now.Add(-a.archiveAfter))
+	if err != nil {
+		return err
+	}
+	if archived > 0 {
+		a.logger.Info("Archived deleted VMs", "vms", archived)
+	}
+
+	if a.retention <= 0 {
+		return nil
+	}
+	pruned, err := a.store.DeleteArchivedBefore(ctx, now.Add(-a.retention))
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		a.logger.Info("Pruned archived VMs", "vms", pruned)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SerialConsoleLogContainer is the virt-launcher container that KubeVirt copies
+// the guest serial console output to when serial console logging is enabled
+const SerialConsoleLogContainer = "guest-console-log"
+
+var (
+	// ErrVMNotRunning is returned when a VM has no virt-launcher pod
+	ErrVMNotRunning = errors.New("VM is not running")
+	// ErrSerialConsoleLogDisabled is returned when the virt-launcher pod of a
+	// VM does not capture the serial console, because serial console logging
+	// is disabled in KubeVirt or for the VM
+	ErrSerialConsoleLogDisabled = errors.New("serial console logging is not enabled for the VM")
+)
+
+// ConsoleLogOptions limits how much of a serial console log is returned
+type ConsoleLogOptions struct {
+	// LimitBytes is the maximum number of bytes to return; 0 means no limit
+	LimitBytes int64
+	// TailLines returns only the last lines of the log when set
+	TailLines *int64
+}
+
+// StreamVMConsoleLog opens the guest serial console log of the VirtualMachine
+// vmName in namespace, read from its virt-launcher pod. The caller must close
+// the returned stream.
+func StreamVMConsoleLog(ctx context.Context, reader client.Reader, pods corev1client.PodsGetter, namespace, vmName string, opts ConsoleLogOptions) (io.ReadCloser, error) {
+	pod, err := launcherPod(ctx, reader, namespace, vmName)
+	if err != nil {
+		return nil, err
+	}
+	if pod == nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return nil, ErrVMNotRunning
+	}
+	if !hasContainer(pod, SerialConsoleLogContainer) {
+		return nil, ErrSerialConsoleLogDisabled
+	}
+
+	logOptions := &corev1.PodLogOptions{
+		Container: SerialConsoleLogContainer,
+		TailLines: opts.TailLines,
+	}
+	if opts.LimitBytes > 0 {
+		logOptions.LimitBytes = &opts.LimitBytes
+	}
+	stream, err := pods.Pods(namespace).GetLogs(pod.Name, logOptions).Stream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream serial console log of VM %s/%s: %w", namespace, vmName, err)
+	}
+	return stream, nil
+}
+
+func hasContainer(pod *corev1.Pod, name string) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubevirtv1 "kubevirt.io/api/core/v1"
+)
+
+// MaxVMDiagnosticEvents is the number of most recent events included in VM
+// diagnostics
+const MaxVMDiagnosticEvents = 20
+
+// Categories of problems found while collecting VM diagnostics
+const (
+	DiagnosticProblemScheduling = "Scheduling"
+	DiagnosticProblemImagePull  = "ImagePull"
+	DiagnosticProblemContainer  = "Container"
+	DiagnosticProblemQuota      = "Quota"
+)
+
+// imagePullReasons are the container waiting reasons that mean the image of
+// the virt-launcher pod or a container disk could not be pulled
+var imagePullReasons = map[string]bool{
+	"ErrImagePull":        true,
+	"ImagePullBackOff":    true,
+	"InvalidImageName":    true,
+	"ErrImageNeverPull":   true,
+	"RegistryUnavailable": true,
+}
+
+// VMDiagnostics describes the Kubernetes state behind a VM, so users can see
+// why it failed to start without access to the cluster
+type VMDiagnostics struct {
+	// VMIPhase is the phase of the VirtualMachineInstance, empty when the VM
+	// is not running
+	VMIPhase string `json:"vmiPhase,omitempty"`
+	// LauncherPod is the name of the virt-launcher pod running the VM
+	LauncherPod string                `json:"launcherPod,omitempty"`
+	Problems    []DiagnosticProblem   `json:"problems"`
+	Conditions  []DiagnosticCondition `json:"conditions"`
+	Events      []DiagnosticEvent     `json:"events"`
+}
+
+// DiagnosticProblem is a known cause of a VM failing to start
+type DiagnosticProblem struct {
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message,omitempty"`
+}
+
+// DiagnosticCondition is a condition of the VM, its instance, or its
+// virt-launcher pod
+type DiagnosticCondition struct {
+	Source             string     `json:"source"`
+	Type               string     `json:"type"`
+	Status             string     `json:"status"`
+	Reason             string     `json:"reason,omitempty"`
+	Message            string     `json:"message,omitempty"`
+	LastTransitionTime *time.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// DiagnosticEvent is a Kubernetes Event about the VM, its instance, or its
+// virt-launcher pod
+type DiagnosticEvent struct {
+	Source   string    `json:"source"`
+	Type     string    `json:"type"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// CollectVMDiagnostics gathers the conditions, recent events, scheduling
+// failures, and image pull errors of the VirtualMachine vmName in namespace.
+// Missing resources are skipped, since a VM in error may not have an instance
+// or launcher pod.
+func CollectVMDiagnostics(ctx context.Context, reader client.Reader, namespace, vmName string) (*VMDiagnostics, error) {
+	diagnostics := &VMDiagnostics{
+		Problems:   []DiagnosticProblem{},
+		Conditions: []DiagnosticCondition{},
+		Events:     []DiagnosticEvent{},
+	}
+	sources := map[string]string{}
+
+	vm := &kubevirtv1.VirtualMachine{}
+	err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: vmName}, vm)
+	switch {
+	case err == nil:
+		sources["VirtualMachine/"+vmName] = "VirtualMachine"
+		for _, cond := range vm.Status.Conditions {
+			diagnostics.Conditions = append(diagnostics.Conditions, diagnosticCondition("VirtualMachine", string(cond.Type), string(cond.Status), cond.Reason, cond.Message, cond.LastTransitionTime.Time))
+		}
+	case !errors.IsNotFound(err):
+		return nil, fmt.Errorf("failed to get VirtualMachine %s/%s: %w", namespace, vmName, err)
+	}
+
+	vmi := &kubevirtv1.VirtualMachineInstance{}
+	err = reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: vmName}, vmi)
+	switch {
+	case err == nil:
+		sources["VirtualMachineInstance/"+vmName] = "VirtualMachineInstance"
+		diagnostics.VMIPhase = string(vmi.Status.Phase)
+		for _, cond := range vmi.Status.Conditions {
+			diagnostics.Conditions = append(diagnostics.Conditions, diagnosticCondition("VirtualMachineInstance", string(cond.Type), string(cond.Status), cond.Reason, cond.Message, cond.LastTransitionTime.Time))
+		}
+	case !errors.IsNotFound(err):
+		return nil, fmt.Errorf("failed to get VirtualMachineInstance %s/%s: %w", namespace, vmName, err)
+	}
+
+	pod, err := launcherPod(ctx, reader, namespace, vmName)
+	if err != nil {
+		return nil, err
+	}
+	if pod != nil {
+		diagnostics.LauncherPod = pod.Name
+		sources["Pod/"+pod.Name] = "Pod"
+		for _, cond := range pod.Status.Conditions {
+			diagnostics.Conditions = append(diagnostics.Conditions, diagnosticCondition("Pod", string(cond.Type), string(cond.Status), cond.Reason, cond.Message, cond.LastTransitionTime.Time))
+		}
+		diagnostics.Problems = append(diagnostics.Problems, podProblems(pod)...)
+	}
+
+	events := &corev1.EventList{}
+	if err := reader.List(ctx, events, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list events in namespace %s: %w", namespace, err)
+	}
+	for _, event := range events.Items {
+		kind, ok := sources[event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name]
+		if !ok {
+			continue
+		}
+		diagnostics.Events = append(diagnostics.Events, DiagnosticEvent{
+			Source:   kind,
+			Type:     event.Type,
+			Reason:   event.Reason,
+			Message:  event.Message,
+			Count:    event.Count,
+			LastSeen: eventLastSeen(event),
+		})
+		if event.Reason == "FailedCreate" && strings.Contains(event.Message, "exceeded quota") {
+			// The VDC's ResourceQuota rejected the virt-launcher pod
+			diagnostics.Problems = append(diagnostics.Problems, DiagnosticProblem{Category: DiagnosticProblemQuota, Reason: event.Reason, Message: event.Message})
+		}
+		if pod == nil && event.Reason == "FailedScheduling" {
+			// The pod may already be gone; its events outlive it
+			diagnostics.Problems = append(diagnostics.Problems, DiagnosticProblem{Category: DiagnosticProblemScheduling, Reason: event.Reason, Message: event.Message})
+		}
+	}
+	sort.SliceStable(diagnostics.Events, func(i, j int) bool {
+		return diagnostics.Events[i].LastSeen.After(diagnostics.Events[j].LastSeen)
+	})
+	if len(diagnostics.Events) > MaxVMDiagnosticEvents {
+		diagnostics.Events = diagnostics.Events[:MaxVMDiagnosticEvents]
+	}
+
+	return diagnostics, nil
+}
+
+// launcherPod returns the newest virt-launcher pod of the VM, or nil if it has none
+func launcherPod(ctx context.Context, reader client.Reader, namespace, vmName string) (*corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods, client.InNamespace(namespace), client.MatchingLabels{kubevirtv1.VirtualMachineNameLabel: vmName}); err != nil {
+		return nil, fmt.Errorf("failed to list virt-launcher pods of VM %s/%s: %w", namespace, vmName, err)
+	}
+	var newest *corev1.Pod
+	for i := range pods.Items {
+		if newest == nil || pods.Items[i].CreationTimestamp.After(newest.CreationTimestamp.Time) {
+			newest = &pods.Items[i]
+		}
+	}
+	return newest, nil
+}
+
+// podProblems reports scheduling failures and image pull errors of a pod
+func podProblems(pod *corev1.Pod) []DiagnosticProblem {
+	var problems []DiagnosticProblem
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse {
+			problems = append(problems, DiagnosticProblem{Category: DiagnosticProblemScheduling, Reason: cond.Reason, Message: cond.Message})
+		}
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		switch {
+		case status.State.Waiting != nil && imagePullReasons[status.State.Waiting.Reason]:
+			problems = append(problems, DiagnosticProblem{
+				Category: DiagnosticProblemImagePull,
+				Reason:   status.State.Waiting.Reason,
+				Message:  fmt.Sprintf("container %s: %s", status.Name, status.State.Waiting.Message),
+			})
+		case status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff":
+			problems = append(problems, DiagnosticProblem{
+				Category: DiagnosticProblemContainer,
+				Reason:   status.State.Waiting.Reason,
+				Message:  fmt.Sprintf("container %s: %s", status.Name, status.State.Waiting.Message),
+			})
+		}
+	}
+	return problems
+}
+
+func diagnosticCondition(source, condType, status, reason, message string, transitioned time.Time) DiagnosticCondition {
+	cond := DiagnosticCondition{
+		Source:  source,
+		Type:    condType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+	if !transitioned.IsZero() {
+		cond.LastTransitionTime = &transitioned
+	}
+	return cond
+}
+
+// eventLastSeen returns when an event last occurred, falling back through the
+// timestamps that older and newer event producers set
+func eventLastSeen(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
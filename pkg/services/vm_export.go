@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	exportv1beta1 "kubevirt.io/api/export/v1beta1"
+	snapshotv1beta1 "kubevirt.io/api/snapshot/v1beta1"
+)
+
+// Formats VM disks can be downloaded in
+const (
+	VMExportFormatRaw  = "raw"
+	VMExportFormatGzip = "gzip"
+)
+
+// vmExportTokenParam carries the export token in download URLs, so they work
+// without extra headers
+const vmExportTokenParam = "x-kubevirt-export-token"
+
+// vmExportPollInterval is how often snapshots and exports are checked for readiness
+const vmExportPollInterval = time.Second
+
+// ErrExportsUnavailable is returned when the cluster does not serve the
+// KubeVirt snapshot and export APIs
+var ErrExportsUnavailable = errors.New("VM exports are not available")
+
+// ErrExportNotReachable is returned when an export is ready but has no links
+// reachable from outside the cluster, because the export proxy is not exposed
+var ErrExportNotReachable = errors.New("VM export is not reachable from outside the cluster")
+
+// IsValidVMExportFormat reports whether format is a known download format
+func IsValidVMExportFormat(format string) bool {
+	return format == VMExportFormatRaw || format == VMExportFormatGzip
+}
+
+// VMExportOptions configures a VM export
+type VMExportOptions struct {
+	// Format is raw or gzip
+	Format string
+	// TTL is how long the download links stay valid
+	TTL time.Duration
+	// Timeout bounds snapshotting and exporting the VM
+	Timeout time.Duration
+}
+
+// VMExport is an exported VM's disks, ready for download
+type VMExport struct {
+	Format    string           `json:"format"`
+	ExpiresAt time.Time        `json:"expiresAt"`
+	Volumes   []VMExportVolume `json:"volumes"`
+	// CACert is the PEM CA certificate of the export proxy, when it is not
+	// signed by a publicly trusted CA
+	CACert string `json:"caCert,omitempty"`
+}
+
+// VMExportVolume is a download link for one disk. The URL embeds the export's
+// token and stops working when the export expires.
+type VMExportVolume struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// VMExportService exports VMs for download
+type VMExportService interface {
+	// ExportVM snapshots the VirtualMachine vmName in namespace and exports
+	// the snapshot's disks, calling progress as each step completes. The
+	// snapshot and export are removed by the cluster once opts.TTL passes.
+	ExportVM(ctx context.Context, namespace, vmName string, opts VMExportOptions, progress func(percent int, details string)) (*VMExport, error)
+}
+
+// vmExportService exports VMs through KubeVirt VirtualMachineSnapshots and
+// VirtualMachineExports
+type vmExportService struct {
+	client client.Client
+}
+
+// NewVMExportService returns a VMExportService working through c
+func NewVMExportService(c client.Client) VMExportService {
+	return &vmExportService{client: c}
+}
+
+// ExportVM snapshots the VM so the exported disks are consistent while it
+// keeps running, then exports the snapshot with a token of its own. The
+// VirtualMachineExport owns the snapshot and token Secret, so all three are
+// garbage collected when KubeVirt removes the export at the end of its TTL.
+func (s *vmExportService) ExportVM(ctx context.Context, namespace, vmName string, opts VMExportOptions, progress func(percent int, details string)) (*VMExport, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	name := fmt.Sprintf("%s-export-%s", vmName, utilrand.String(5))
+	vmGroup := kubevirtv1.SchemeGroupVersion.Group
+	snapshotGroup := snapshotv1beta1.SchemeGroupVersion.Group
+	snapshot := &snapshotv1beta1.VirtualMachineSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "ssvirt"},
+		},
+		Spec: snapshotv1beta1.VirtualMachineSnapshotSpec{
+			Source: corev1.TypedLocalObjectReference{
+				APIGroup: &vmGroup,
+				Kind:     "VirtualMachine",
+				Name:     vmName,
+			},
+		},
+	}
+	if err := s.client.Create(ctx, snapshot); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, ErrExportsUnavailable
+		}
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	progress(10, "Snapshotting VM disks")
+
+	if err := s.waitForSnapshot(ctx, snapshot); err != nil {
+		s.cleanup(snapshot)
+		return nil, err
+	}
+	progress(40, "Exporting VM disks")
+
+	token, err := newExportToken()
+	if err != nil {
+		s.cleanup(snapshot)
+		return nil, err
+	}
+	tokenSecret := name + "-token"
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tokenSecret,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "ssvirt"},
+		},
+		StringData: map[string]string{"token": token},
+	}
+	if err := s.client.Create(ctx, secret); err != nil {
+		s.cleanup(snapshot)
+		return nil, fmt.Errorf("failed to create export token secret: %w", err)
+	}
+
+	export := &exportv1beta1.VirtualMachineExport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "ssvirt"},
+		},
+		Spec: exportv1beta1.VirtualMachineExportSpec{
+			Source: corev1.TypedLocalObjectReference{
+				APIGroup: &snapshotGroup,
+				Kind:     "VirtualMachineSnapshot",
+				Name:     name,
+			},
+			TokenSecretRef: &tokenSecret,
+			TTLDuration:    &metav1.Duration{Duration: opts.TTL},
+		},
+	}
+	if err := s.client.Create(ctx, export); err != nil {
+		s.cleanup(snapshot, secret)
+		if meta.IsNoMatchError(err) {
+			return nil, ErrExportsUnavailable
+		}
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+	for _, owned := range []client.Object{snapshot, secret} {
+		if err := s.setExportOwner(ctx, owned, export); err != nil {
+			s.cleanup(export, snapshot, secret)
+			return nil, err
+		}
+	}
+	progress(60, "Waiting for the export server")
+
+	result, err := s.waitForExport(ctx, export, token, opts)
+	if err != nil {
+		s.cleanup(export)
+		return nil, err
+	}
+	return result, nil
+}
+
+// waitForSnapshot waits until the snapshot is ready to use
+func (s *vmExportService) waitForSnapshot(ctx context.Context, snapshot *snapshotv1beta1.VirtualMachineSnapshot) error {
+	var failure error
+	err := wait.PollUntilContextCancel(ctx, vmExportPollInterval, true, func(ctx context.Context) (bool, error) {
+		if err := s.client.Get(ctx, client.ObjectKeyFromObject(snapshot), snapshot); err != nil {
+			return false, nil
+		}
+		if snapshot.Status == nil {
+			return false, nil
+		}
+		if snapshot.Status.Phase == snapshotv1beta1.Failed {
+			failure = errors.New("snapshot failed")
+			if snapshot.Status.Error != nil && snapshot.Status.Error.Message != nil {
+				failure = fmt.Errorf("snapshot failed: %s", *snapshot.Status.Error.Message)
+			}
+			return true, nil
+		}
+		return snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse, nil
+	})
+	if failure != nil {
+		return failure
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot was not ready in time: %w", err)
+	}
+	return nil
+}
+
+// waitForExport waits until the export server is ready and returns the
+// download links of the export's volumes in opts.Format
+func (s *vmExportService) waitForExport(ctx context.Context, export *exportv1beta1.VirtualMachineExport, token string, opts VMExportOptions) (*VMExport, error) {
+	err := wait.PollUntilContextCancel(ctx, vmExportPollInterval, true, func(ctx context.Context) (bool, error) {
+		if err := s.client.Get(ctx, client.ObjectKeyFromObject(export), export); err != nil {
+			return false, nil
+		}
+		return export.Status != nil && export.Status.Phase == exportv1beta1.Ready, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("export was not ready in time: %w", err)
+	}
+
+	links := export.Status.Links
+	if links == nil || links.External == nil || len(links.External.Volumes) == 0 {
+		return nil, ErrExportNotReachable
+	}
+	result := &VMExport{
+		Format:    opts.Format,
+		ExpiresAt: export.CreationTimestamp.Add(opts.TTL),
+		CACert:    links.External.Cert,
+		Volumes:   []VMExportVolume{},
+	}
+	if export.Status.TTLExpirationTime != nil {
+		result.ExpiresAt = export.Status.TTLExpirationTime.Time
+	}
+	for _, volume := range links.External.Volumes {
+		for _, format := range volume.Formats {
+			if string(format.Format) != opts.Format {
+				continue
+			}
+			separator := "?"
+			if strings.Contains(format.Url, "?") {
+				separator = "&"
+			}
+			result.Volumes = append(result.Volumes, VMExportVolume{
+				Name: volume.Name,
+				URL:  format.Url + separator + vmExportTokenParam + "=" + url.QueryEscape(token),
+			})
+		}
+	}
+	if len(result.Volumes) == 0 {
+		return nil, fmt.Errorf("export has no volumes in %s format", opts.Format)
+	}
+	return result, nil
+}
+
+// setExportOwner makes export the owner of obj, so obj is removed with it
+func (s *vmExportService) setExportOwner(ctx context.Context, obj client.Object, export *exportv1beta1.VirtualMachineExport) error {
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	obj.SetOwnerReferences(append(obj.GetOwnerReferences(), metav1.OwnerReference{
+		APIVersion: exportv1beta1.SchemeGroupVersion.String(),
+		Kind:       "VirtualMachineExport",
+		Name:       export.Name,
+		UID:        export.UID,
+	}))
+	if err := s.client.Patch(ctx, obj, patch); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", obj.GetName(), err)
+	}
+	return nil
+}
+
+// cleanup deletes objects of a failed export, ignoring errors
+func (s *vmExportService) cleanup(objs ...client.Object) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, obj := range objs {
+		_ = s.client.Delete(ctx, obj)
+	}
+}
+
+// newExportToken returns a random export token
+func newExportToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate export token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// podMetricsGVK is the kind metrics-server reports pod resource usage as. It is
+// read as unstructured data to avoid depending on the metrics API types.
+var podMetricsGVK = schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetrics"}
+
+// ErrMetricsUnavailable is returned when no resource usage has been reported
+// for a VM, because metrics-server is not installed or has not scraped the VM yet
+var ErrMetricsUnavailable = errors.New("VM metrics are not available")
+
+// MetricsService reports the resource usage of running VMs
+type MetricsService interface {
+	// VMCPUUsage returns the CPU used by the VirtualMachine vmName in
+	// namespace, in millicores
+	VMCPUUsage(ctx context.Context, namespace, vmName string) (int64, error)
+}
+
+// podMetricsService reads VM usage from the metrics of virt-launcher pods
+type podMetricsService struct {
+	reader client.Reader
+}
+
+// NewMetricsService returns a MetricsService backed by the metrics.k8s.io API.
+// The reader must not be cached, since PodMetrics cannot be watched.
+func NewMetricsService(reader client.Reader) MetricsService {
+	return &podMetricsService{reader: reader}
+}
+
+// VMCPUUsage totals the CPU usage of the containers of the VM's virt-launcher pod
+func (s *podMetricsService) VMCPUUsage(ctx context.Context, namespace, vmName string) (int64, error) {
+	pod, err := launcherPod(ctx, s.reader, namespace, vmName)
+	if err != nil {
+		return 0, err
+	}
+	if pod == nil {
+		return 0, ErrVMNotRunning
+	}
+
+	metrics := &unstructured.Unstructured{}
+	metrics.SetGroupVersionKind(podMetricsGVK)
+	if err := s.reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: pod.Name}, metrics); err != nil {
+		if k8serrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return 0, ErrMetricsUnavailable
+		}
+		return 0, fmt.Errorf("failed to get metrics of pod %s: %w", pod.Name, err)
+	}
+
+	containers, _, err := unstructured.NestedSlice(metrics.Object, "containers")
+	if err != nil {
+		return 0, fmt.Errorf("invalid metrics of pod %s: %w", pod.Name, err)
+	}
+	var millicores int64
+	for _, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		cpu, found, err := unstructured.NestedString(container, "usage", "cpu")
+		if err != nil || !found {
+			continue
+		}
+		usage, err := resource.ParseQuantity(cpu)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CPU usage %q in metrics of pod %s: %w", cpu, pod.Name, err)
+		}
+		millicores += usage.MilliValue()
+	}
+	return millicores, nil
+}
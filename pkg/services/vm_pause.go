@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/client-go/rest"
+)
+
+// VMIPauser pauses VirtualMachineInstances through KubeVirt's pause
+// subresource, which the controller-runtime client cannot call
+type VMIPauser struct {
+	restClient rest.Interface
+}
+
+// NewVMIPauser returns a VMIPauser that calls the API server through restClient,
+// such as the core REST client of a clientset
+func NewVMIPauser(restClient rest.Interface) *VMIPauser {
+	return &VMIPauser{restClient: restClient}
+}
+
+// PauseVMI pauses the running VirtualMachineInstance name in namespace. The
+// guest keeps its memory and continues where it stopped when unpaused.
+func (p *VMIPauser) PauseVMI(ctx context.Context, namespace, name string) error {
+	err := p.restClient.Put().
+		AbsPath("/apis/subresources.kubevirt.io/v1/namespaces", namespace, "virtualmachineinstances", name, "pause").
+		Body([]byte("{}")).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return fmt.Errorf("failed to pause VirtualMachineInstance %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
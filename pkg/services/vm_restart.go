@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/client-go/rest"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+)
+
+// VMRestarter restarts VMs through KubeVirt's softreboot and restart
+// subresources, which the controller-runtime client cannot call
+type VMRestarter struct {
+	restClient rest.Interface
+}
+
+// NewVMRestarter returns a VMRestarter that calls the API server through
+// restClient, such as the core REST client of a clientset
+func NewVMRestarter(restClient rest.Interface) *VMRestarter {
+	return &VMRestarter{restClient: restClient}
+}
+
+// SoftRebootVMI asks the guest of the running VirtualMachineInstance name in
+// namespace to reboot. virt-launcher sends an ACPI reboot, or uses the guest
+// agent when one is connected; the guest may ignore it.
+func (r *VMRestarter) SoftRebootVMI(ctx context.Context, namespace, name string) error {
+	err := r.restClient.Put().
+		AbsPath("/apis/subresources.kubevirt.io/v1/namespaces", namespace, "virtualmachineinstances", name, "softreboot").
+		Body([]byte("{}")).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return fmt.Errorf("failed to reboot VirtualMachineInstance %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// ResetVM restarts the VirtualMachine name in namespace without waiting for
+// the guest to shut down, like pressing a reset button. Its
+// VirtualMachineInstance is deleted immediately and a new one is started.
+func (r *VMRestarter) ResetVM(ctx context.Context, namespace, name string) error {
+	gracePeriod := int64(0)
+	body, err := json.Marshal(kubevirtv1.RestartOptions{GracePeriodSeconds: &gracePeriod})
+	if err != nil {
+		return err
+	}
+	err = r.restClient.Put().
+		AbsPath("/apis/subresources.kubevirt.io/v1/namespaces", namespace, "virtualmachines", name, "restart").
+		Body(body).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return fmt.Errorf("failed to reset VirtualMachine %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// Privileged settings reported in VM security profiles
+const (
+	SecuritySettingPrivilegedDevice   = "privilegedDevice"
+	SecuritySettingHostPassthroughCPU = "hostPassthroughCpu"
+	SecuritySettingHostNetwork        = "hostNetwork"
+	SecuritySettingToleration         = "toleration"
+)
+
+// VMSecurityProfile lists the privileged settings of a VM, so security teams
+// can review VMs without cluster access
+type VMSecurityProfile struct {
+	Items []SecurityProfileItem `json:"items"`
+	// Violations is the number of items the organization's policy disallows
+	Violations int `json:"violations"`
+}
+
+// SecurityProfileItem is one privileged setting of a VM
+type SecurityProfileItem struct {
+	Setting string `json:"setting"`
+	Detail  string `json:"detail"`
+	// Allowed is false when the organization's policy disallows the setting.
+	// Without a policy every setting is allowed.
+	Allowed bool `json:"allowed"`
+}
+
+// VMSecurityService reports the privileged settings of VMs
+type VMSecurityService interface {
+	// VMSecurityProfile inspects the VirtualMachine vmName in namespace and its
+	// virt-launcher pod, flagging settings policy disallows. policy may be nil.
+	VMSecurityProfile(ctx context.Context, namespace, vmName string, policy *models.OrgSecurityPolicy) (*VMSecurityProfile, error)
+}
+
+// vmSecurityService reads VM specs and launcher pods from the cluster
+type vmSecurityService struct {
+	reader client.Reader
+}
+
+// NewVMSecurityService returns a VMSecurityService reading through reader
+func NewVMSecurityService(reader client.Reader) VMSecurityService {
+	return &vmSecurityService{reader: reader}
+}
+
+// VMSecurityProfile reports the host devices, GPUs, CPU model and tolerations
+// of the VirtualMachine spec, and whether its running virt-launcher pod uses
+// the host network. A missing VirtualMachine has an empty profile.
+func (s *vmSecurityService) VMSecurityProfile(ctx context.Context, namespace, vmName string, policy *models.OrgSecurityPolicy) (*VMSecurityProfile, error) {
+	profile := &VMSecurityProfile{Items: []SecurityProfileItem{}}
+	add := func(setting, detail string, allowed func(*models.OrgSecurityPolicy) bool) {
+		item := SecurityProfileItem{Setting: setting, Detail: detail, Allowed: policy == nil || allowed(policy)}
+		if !item.Allowed {
+			profile.Violations++
+		}
+		profile.Items = append(profile.Items, item)
+	}
+	devicesAllowed := func(p *models.OrgSecurityPolicy) bool { return p.AllowPrivilegedDevices }
+
+	vm := &kubevirtv1.VirtualMachine{}
+	if err := s.reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: vmName}, vm); err != nil {
+		if errors.IsNotFound(err) {
+			return profile, nil
+		}
+		return nil, fmt.Errorf("failed to get VirtualMachine %s/%s: %w", namespace, vmName, err)
+	}
+
+	if vm.Spec.Template != nil {
+		spec := vm.Spec.Template.Spec
+		for _, device := range spec.Domain.Devices.HostDevices {
+			add(SecuritySettingPrivilegedDevice, fmt.Sprintf("host device %s (%s)", device.Name, device.DeviceName), devicesAllowed)
+		}
+		for _, gpu := range spec.Domain.Devices.GPUs {
+			add(SecuritySettingPrivilegedDevice, fmt.Sprintf("GPU %s (%s)", gpu.Name, gpu.DeviceName), devicesAllowed)
+		}
+		if cpu := spec.Domain.CPU; cpu != nil && cpu.Model == kubevirtv1.CPUModeHostPassthrough {
+			add(SecuritySettingHostPassthroughCPU, "CPU model "+cpu.Model, func(p *models.OrgSecurityPolicy) bool { return p.AllowHostPassthroughCPU })
+		}
+		for _, toleration := range spec.Tolerations {
+			detail := toleration.Key
+			if detail == "" {
+				detail = "all taints"
+			}
+			if toleration.Value != "" {
+				detail += "=" + toleration.Value
+			}
+			if toleration.Effect != "" {
+				detail += ":" + string(toleration.Effect)
+			}
+			add(SecuritySettingToleration, detail, func(p *models.OrgSecurityPolicy) bool { return p.AllowCustomTolerations })
+		}
+	}
+
+	// KubeVirt has no host network setting; a launcher pod can only get one
+	// through a mutating webhook, so it is checked on the running VM
+	pod, err := launcherPod(ctx, s.reader, namespace, vmName)
+	if err != nil {
+		return nil, err
+	}
+	if pod != nil && pod.Spec.HostNetwork {
+		add(SecuritySettingHostNetwork, "virt-launcher pod "+pod.Name+" uses the host network", func(p *models.OrgSecurityPolicy) bool { return p.AllowHostNetwork })
+	}
+
+	return profile, nil
+}
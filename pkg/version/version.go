@@ -0,0 +1,30 @@
+// Package version reports the build of the running binary.
+package version
+
+import "runtime/debug"
+
+// Version is the release the binary was built from, set at build time with
+//
+//	-ldflags "-X github.com/mhrivnak/ssvirt/pkg/version.Version=v1.2.3"
+var Version = ""
+
+// Get returns the version of the running binary: Version when it was set at
+// build time, otherwise the VCS revision recorded by the Go toolchain, or
+// "dev" when neither is available.
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				revision := setting.Value
+				if len(revision) > 12 {
+					revision = revision[:12]
+				}
+				return revision
+			}
+		}
+	}
+	return "dev"
+}
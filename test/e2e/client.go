@@ -0,0 +1,78 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIClient calls the SSVirt API as one user
+type APIClient struct {
+	baseURL string
+	token   string
+}
+
+// Login opens a session with basic authentication, as VCD clients do
+func (e *Environment) Login(username, password string) (*APIClient, error) {
+	req, err := http.NewRequest(http.MethodPost, e.APIURL+"/cloudapi/1.0.0/sessions", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(username, password)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("login as %s failed with status %d: %s", username, resp.StatusCode, body)
+	}
+
+	token := strings.TrimPrefix(resp.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return nil, fmt.Errorf("login as %s returned no token", username)
+	}
+	return &APIClient{baseURL: e.APIURL, token: token}, nil
+}
+
+// Do sends body as JSON and decodes the response into out when it is not nil,
+// returning the response status
+func (c *APIClient) Do(method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if out != nil && len(data) > 0 && resp.StatusCode < http.StatusBadRequest {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
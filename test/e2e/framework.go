@@ -0,0 +1,311 @@
+// Package e2e runs SSVirt end to end: a Kubernetes API server and etcd from
+// envtest, a throwaway PostgreSQL container, the controllers and the API server,
+// all in the test process. OpenShift's template controller and KubeVirt are not
+// available in envtest, so simulators stand in for them.
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	"github.com/mhrivnak/ssvirt/pkg/api"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/controllers"
+	"github.com/mhrivnak/ssvirt/pkg/database"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// SeedPassword is the password of every user in the e2e seed profile
+const SeedPassword = "e2e-password"
+
+// TemplateNamespace holds the catalog Templates, as on OpenShift
+const TemplateNamespace = "openshift"
+
+// errPrerequisites marks an environment that cannot start because envtest
+// binaries or a container runtime are missing, so the tests skip rather than fail
+var errPrerequisites = errors.New("e2e prerequisites unavailable")
+
+// Environment is a running SSVirt installation
+type Environment struct {
+	// Client talks to the envtest API server directly
+	Client client.Client
+	// DB is connected to the throwaway PostgreSQL database
+	DB *database.DB
+	// APIURL is the base URL of the in-process API server
+	APIURL string
+
+	testEnv  *envtest.Environment
+	pool     *dockertest.Pool
+	postgres *dockertest.Resource
+	server   *httptest.Server
+	cancel   context.CancelFunc
+}
+
+// Start brings up the environment. Call Stop to tear it down, including when
+// Start returns an error.
+func Start() (*Environment, error) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		return nil, fmt.Errorf("%w: KUBEBUILDER_ASSETS is not set; run `setup-envtest use -p path`", errPrerequisites)
+	}
+
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true), zap.WriteTo(os.Stderr)))
+	gin.SetMode(gin.TestMode)
+
+	env := &Environment{}
+	ctx, cancel := context.WithCancel(context.Background())
+	env.cancel = cancel
+
+	if err := env.startPostgres(); err != nil {
+		return env, err
+	}
+	cfg, err := env.startKubernetes()
+	if err != nil {
+		return env, err
+	}
+
+	appConfig, err := config.Load()
+	if err != nil {
+		return env, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := env.connectDatabase(appConfig); err != nil {
+		return env, err
+	}
+	if err := env.startControllers(ctx, cfg); err != nil {
+		return env, err
+	}
+	if err := env.startAPIServer(ctx, cfg, appConfig); err != nil {
+		return env, err
+	}
+	return env, nil
+}
+
+// Stop tears down everything Start brought up
+func (e *Environment) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	if e.server != nil {
+		e.server.Close()
+	}
+	if e.DB != nil {
+		if err := e.DB.Close(); err != nil {
+			log.Printf("Failed to close database connection: %v", err)
+		}
+	}
+	if e.testEnv != nil {
+		if err := e.testEnv.Stop(); err != nil {
+			log.Printf("Failed to stop envtest: %v", err)
+		}
+	}
+	if e.postgres != nil {
+		if err := e.pool.Purge(e.postgres); err != nil {
+			log.Printf("Failed to remove PostgreSQL container: %v", err)
+		}
+	}
+}
+
+// startPostgres runs a PostgreSQL container and points the SSVirt database
+// configuration at it
+func (e *Environment) startPostgres() error {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPrerequisites, err)
+	}
+	if err := pool.Client.Ping(); err != nil {
+		return fmt.Errorf("%w: cannot reach a container runtime: %v", errPrerequisites, err)
+	}
+	pool.MaxWait = 2 * time.Minute
+	e.pool = pool
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "postgres",
+		Tag:        "16-alpine",
+		Env: []string{
+			"POSTGRES_USER=ssvirt",
+			"POSTGRES_PASSWORD=ssvirt",
+			"POSTGRES_DB=ssvirt",
+		},
+	}, func(hc *docker.HostConfig) {
+		hc.AutoRemove = true
+		hc.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start PostgreSQL: %w", err)
+	}
+	e.postgres = resource
+	// Reap the container even if the test process is killed
+	if err := resource.Expire(600); err != nil {
+		return fmt.Errorf("failed to set PostgreSQL container expiry: %w", err)
+	}
+
+	settings := map[string]string{
+		"SSVIRT_DATABASE_HOST":     resource.GetBoundIP("5432/tcp"),
+		"SSVIRT_DATABASE_PORT":     resource.GetPort("5432/tcp"),
+		"SSVIRT_DATABASE_USERNAME": "ssvirt",
+		"SSVIRT_DATABASE_PASSWORD": "ssvirt",
+		"SSVIRT_DATABASE_DATABASE": "ssvirt",
+		"SSVIRT_DATABASE_SSLMODE":  "disable",
+		"SSVIRT_AUTH_JWT_SECRET":   "e2e-jwt-secret",
+	}
+	for key, value := range settings {
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startKubernetes starts the envtest API server with the OpenShift template and
+// KubeVirt CRDs installed
+func (e *Environment) startKubernetes() (*rest.Config, error) {
+	e.testEnv = &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("testdata", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := e.testEnv.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start envtest: %w", err)
+	}
+
+	e.Client, err = client.New(cfg, client.Options{Scheme: newScheme()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: TemplateNamespace}}
+	if err := e.Client.Create(context.Background(), namespace); err != nil {
+		return nil, fmt.Errorf("failed to create template namespace: %w", err)
+	}
+	return cfg, nil
+}
+
+// connectDatabase connects to PostgreSQL once it accepts connections, migrates
+// the schema and loads the e2e seed profile
+func (e *Environment) connectDatabase(cfg *config.Config) error {
+	err := e.pool.Retry(func() error {
+		db, err := database.NewConnection(cfg)
+		if err != nil {
+			return err
+		}
+		e.DB = db
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("PostgreSQL did not become ready: %w", err)
+	}
+
+	if err := e.DB.AutoMigrate(); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := e.DB.BootstrapDefaultData(); err != nil {
+		return fmt.Errorf("failed to bootstrap default data: %w", err)
+	}
+	if _, err := e.DB.Seed(context.Background(), "e2e", SeedPassword); err != nil {
+		return fmt.Errorf("failed to seed database: %w", err)
+	}
+	return nil
+}
+
+// startControllers runs the VM and vApp status controllers alongside the
+// template and KubeVirt simulators
+func (e *Environment) startControllers(ctx context.Context, cfg *rest.Config) error {
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  newScheme(),
+		Metrics: server.Options{BindAddress: "0"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create manager: %w", err)
+	}
+
+	vmRepo := repositories.NewVMRepository(e.DB.DB)
+	vappRepo := repositories.NewVAppRepository(e.DB.DB)
+	vdcRepo := repositories.NewVDCRepository(e.DB.DB)
+	if err := controllers.SetupVMStatusController(mgr, vmRepo, vappRepo, vdcRepo, controllers.ControllerOptions{}); err != nil {
+		return err
+	}
+	if err := controllers.SetupVAppStatusController(mgr, vappRepo, vmRepo, vdcRepo, controllers.ProvisioningAlerts{}, controllers.ControllerOptions{}); err != nil {
+		return err
+	}
+	if err := controllers.SetupPowerStateController(mgr, vmRepo, time.Second, 0, controllers.ControllerOptions{}); err != nil {
+		return err
+	}
+	if err := setupSimulators(mgr); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			log.Printf("Controller manager stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// startAPIServer serves the API over HTTP, wired like cmd/api-server
+func (e *Environment) startAPIServer(ctx context.Context, cfg *rest.Config, appConfig *config.Config) error {
+	userRepo := repositories.NewUserRepository(e.DB.DB)
+	catalogRepo := repositories.NewCatalogRepository(e.DB.DB)
+
+	templateService, err := services.NewTemplateServiceForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create template service: %w", err)
+	}
+	go func() {
+		if err := templateService.Start(ctx); err != nil {
+			log.Printf("Template service cache error: %v", err)
+		}
+	}()
+	k8sService, err := services.NewKubernetesServiceForConfig(cfg, TemplateNamespace, log.Default(), services.KubernetesServiceOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes service: %w", err)
+	}
+	if err := k8sService.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start Kubernetes service: %w", err)
+	}
+
+	catalogItemSyncer := services.NewCatalogItemSyncer(templateService, repositories.NewCatalogItemRepository(e.DB.DB, catalogRepo), 2*time.Second, nil)
+	go catalogItemSyncer.Start(ctx)
+
+	jwtManager := auth.NewJWTManager(appConfig.Auth.JWTSecret, appConfig.Auth.TokenExpiry)
+	jwtManager.SetClaimsValidation(appConfig.Auth.Issuer, appConfig.Auth.Audience, appConfig.Auth.ClockSkew)
+	apiServer := api.NewServer(appConfig, e.DB, auth.NewService(userRepo, jwtManager), jwtManager, userRepo,
+		repositories.NewRoleRepository(e.DB.DB), repositories.NewOrganizationRepository(e.DB.DB),
+		repositories.NewVDCRepository(e.DB.DB), catalogRepo, repositories.NewVAppTemplateRepository(e.DB.DB),
+		repositories.NewVAppRepository(e.DB.DB), repositories.NewVMRepository(e.DB.DB), templateService, k8sService)
+
+	e.server = httptest.NewServer(apiServer.GetRouter())
+	e.APIURL = e.server.URL
+	return nil
+}
+
+func newScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(kubevirtv1.AddToScheme(scheme))
+	utilruntime.Must(templatev1.AddToScheme(scheme))
+	return scheme
+}
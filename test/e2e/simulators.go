@@ -0,0 +1,173 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// templateInstanceOwnerLabel is set by OpenShift on the objects a TemplateInstance creates
+const templateInstanceOwnerLabel = "template.openshift.io/template-instance-owner"
+
+// setupSimulators registers the controllers standing in for OpenShift's
+// template controller and for KubeVirt
+func setupSimulators(mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named("e2e_templateinstance_simulator").
+		For(&templatev1.TemplateInstance{}).
+		Complete(&templateInstanceSimulator{Client: mgr.GetClient()})
+	if err != nil {
+		return fmt.Errorf("failed to setup TemplateInstance simulator: %w", err)
+	}
+
+	err = ctrl.NewControllerManagedBy(mgr).
+		Named("e2e_virtualmachine_simulator").
+		For(&kubevirtv1.VirtualMachine{}).
+		Complete(&virtualMachineSimulator{Client: mgr.GetClient()})
+	if err != nil {
+		return fmt.Errorf("failed to setup VirtualMachine simulator: %w", err)
+	}
+	return nil
+}
+
+// templateInstanceSimulator creates the objects of each TemplateInstance in its
+// namespace, substituting parameters, and marks the TemplateInstance Ready
+type templateInstanceSimulator struct {
+	client.Client
+}
+
+func (s *templateInstanceSimulator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var instance templatev1.TemplateInstance
+	if err := s.Get(ctx, req.NamespacedName, &instance); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	for _, condition := range instance.Status.Conditions {
+		if condition.Type == templatev1.TemplateInstanceReady && condition.Status == corev1.ConditionTrue {
+			return ctrl.Result{}, nil
+		}
+	}
+
+	params, err := s.parameters(ctx, &instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	replacer := parameterReplacer(params)
+
+	var refs []templatev1.TemplateInstanceObject
+	for i, raw := range instance.Spec.Template.Objects {
+		data := raw.Raw
+		if data == nil {
+			if data, err = json.Marshal(raw.Object); err != nil {
+				return ctrl.Result{}, fmt.Errorf("object %d: %w", i, err)
+			}
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON([]byte(replacer.Replace(string(data)))); err != nil {
+			return ctrl.Result{}, fmt.Errorf("object %d: %w", i, err)
+		}
+		obj.SetNamespace(instance.Namespace)
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[templateInstanceOwnerLabel] = string(instance.UID)
+		obj.SetLabels(labels)
+
+		if err := s.Create(ctx, obj); err != nil && !k8serrors.IsAlreadyExists(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to create %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		refs = append(refs, templatev1.TemplateInstanceObject{Ref: corev1.ObjectReference{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		}})
+	}
+
+	instance.Status.Objects = refs
+	instance.Status.Conditions = append(instance.Status.Conditions, templatev1.TemplateInstanceCondition{
+		Type:               templatev1.TemplateInstanceReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Created",
+	})
+	return ctrl.Result{}, s.Status().Update(ctx, &instance)
+}
+
+// parameters resolves the Template's parameters from the TemplateInstance's
+// secret, falling back to their values and generating the rest
+func (s *templateInstanceSimulator) parameters(ctx context.Context, instance *templatev1.TemplateInstance) (map[string]string, error) {
+	var secret corev1.Secret
+	if instance.Spec.Secret != nil {
+		key := types.NamespacedName{Namespace: instance.Namespace, Name: instance.Spec.Secret.Name}
+		if err := s.Get(ctx, key, &secret); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+	}
+
+	params := make(map[string]string)
+	for _, param := range instance.Spec.Template.Parameters {
+		switch {
+		case secret.Data[param.Name] != nil:
+			params[param.Name] = string(secret.Data[param.Name])
+		case param.Value != "":
+			params[param.Name] = param.Value
+		case param.Generate != "":
+			params[param.Name] = rand.String(8)
+		}
+	}
+	return params, nil
+}
+
+// parameterReplacer substitutes ${NAME} references in a JSON document
+func parameterReplacer(params map[string]string) *strings.Replacer {
+	var pairs []string
+	for name, value := range params {
+		escaped, _ := json.Marshal(value)
+		pairs = append(pairs, "${"+name+"}", strings.Trim(string(escaped), `"`))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// virtualMachineSimulator reports each VirtualMachine as running or stopped
+// according to its run strategy, as virt-controller would once the VM settles
+type virtualMachineSimulator struct {
+	client.Client
+}
+
+func (s *virtualMachineSimulator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var vm kubevirtv1.VirtualMachine
+	if err := s.Get(ctx, req.NamespacedName, &vm); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	running := vm.Spec.Running != nil && *vm.Spec.Running
+	if vm.Spec.RunStrategy != nil {
+		running = *vm.Spec.RunStrategy != kubevirtv1.RunStrategyHalted
+	}
+	status := kubevirtv1.VirtualMachineStatusStopped
+	if running {
+		status = kubevirtv1.VirtualMachineStatusRunning
+	}
+	if vm.Status.PrintableStatus == status && vm.Status.Created == running {
+		return ctrl.Result{}, nil
+	}
+
+	vm.Status.PrintableStatus = status
+	vm.Status.Created = running
+	vm.Status.Ready = running
+	return ctrl.Result{}, s.Status().Update(ctx, &vm)
+}
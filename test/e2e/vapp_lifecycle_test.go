@@ -0,0 +1,193 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+const (
+	waitTimeout  = 60 * time.Second
+	pollInterval = 250 * time.Millisecond
+)
+
+var (
+	startOnce sync.Once
+	env       *Environment
+	envErr    error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if env != nil {
+		env.Stop()
+	}
+	os.Exit(code)
+}
+
+// setupEnvironment starts the shared environment on first use, skipping the
+// test when envtest binaries or a container runtime are unavailable
+func setupEnvironment(t *testing.T) *Environment {
+	t.Helper()
+	startOnce.Do(func() {
+		env, envErr = Start()
+		if envErr != nil {
+			log.Printf("E2E environment failed to start: %v", envErr)
+		}
+	})
+	if errors.Is(envErr, errPrerequisites) {
+		t.Skipf("Skipping e2e test: %v", envErr)
+	}
+	require.NoError(t, envErr)
+	return env
+}
+
+func TestVAppLifecycle(t *testing.T) {
+	env := setupEnvironment(t)
+	ctx := context.Background()
+
+	org, err := repositories.NewOrganizationRepository(env.DB.DB).GetByName("e2e-org")
+	require.NoError(t, err)
+
+	sysadmin, err := env.Login("e2e-sysadmin", SeedPassword)
+	require.NoError(t, err)
+	orgAdmin, err := env.Login("e2e-orgadmin", SeedPassword)
+	require.NoError(t, err)
+
+	var vdc handlers.VDCResponse
+	t.Run("create VDC", func(t *testing.T) {
+		status, err := sysadmin.Do(http.MethodPost, "/cloudapi/1.0.0/vdcs", map[string]interface{}{
+			"name":            "lifecycle-vdc",
+			"allocationModel": "PayAsYouGo",
+			"isEnabled":       true,
+			"org":             map[string]interface{}{"id": org.ID},
+		}, &vdc)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, status)
+
+		stored, err := repositories.NewVDCRepository(env.DB.DB).GetByID(vdc.ID)
+		require.NoError(t, err)
+		var namespace corev1.Namespace
+		require.NoError(t, env.Client.Get(ctx, client.ObjectKey{Name: stored.Namespace}, &namespace))
+		assert.NotEmpty(t, namespace.Labels["ssvirt.io/vdc-id"])
+	})
+
+	var catalogItem models.CatalogItem
+	t.Run("publish template as catalog item", func(t *testing.T) {
+		require.NoError(t, env.Client.Create(ctx, lifecycleTemplate()))
+
+		catalog, err := repositories.NewCatalogRepository(env.DB.DB).GetByOrgAndName(org.ID, "e2e-catalog")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			var page types.Page[models.CatalogItem]
+			status, err := orgAdmin.Do(http.MethodGet, "/cloudapi/1.0.0/catalogs/"+catalog.ID+"/catalogItems", nil, &page)
+			if err != nil || status != http.StatusOK {
+				return false
+			}
+			for _, item := range page.Values {
+				if item.Name == "lifecycle-template" {
+					catalogItem = item
+					return true
+				}
+			}
+			return false
+		}, waitTimeout, pollInterval, "catalog item never appeared")
+	})
+
+	var vapp handlers.VAppResponse
+	t.Run("instantiate template", func(t *testing.T) {
+		status, err := orgAdmin.Do(http.MethodPost, "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/actions/instantiateTemplate", map[string]interface{}{
+			"name":        "lifecycle-vapp",
+			"catalogItem": map[string]interface{}{"id": catalogItem.ID, "name": catalogItem.Name},
+		}, &vapp)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, status)
+	})
+
+	var vm handlers.VMResponse
+	t.Run("controllers record the VM", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			var page types.Page[handlers.VMResponse]
+			status, err := orgAdmin.Do(http.MethodGet, "/cloudapi/1.0.0/vapps/"+vapp.ID+"/vms", nil, &page)
+			if err != nil || status != http.StatusOK || len(page.Values) != 1 {
+				return false
+			}
+			vm = page.Values[0]
+			return vm.Status == "POWERED_OFF"
+		}, waitTimeout, pollInterval, "VM never reported POWERED_OFF")
+	})
+
+	t.Run("power on", func(t *testing.T) {
+		status, err := orgAdmin.Do(http.MethodPost, "/cloudapi/1.0.0/vms/"+vm.ID+"/actions/powerOn", nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusAccepted, status)
+
+		require.Eventually(t, func() bool {
+			var current handlers.VMResponse
+			status, err := orgAdmin.Do(http.MethodGet, "/cloudapi/1.0.0/vms/"+vm.ID, nil, &current)
+			return err == nil && status == http.StatusOK && current.Status == "POWERED_ON"
+		}, waitTimeout, pollInterval, "VM never reported POWERED_ON")
+	})
+}
+
+// lifecycleTemplate returns a catalog Template with one stopped VirtualMachine
+func lifecycleTemplate() *templatev1.Template {
+	vm := map[string]interface{}{
+		"apiVersion": "kubevirt.io/v1",
+		"kind":       "VirtualMachine",
+		"metadata":   map[string]interface{}{"name": "${NAME}"},
+		"spec": map[string]interface{}{
+			"runStrategy": "Halted",
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"domain": map[string]interface{}{
+						"cpu":     map[string]interface{}{"cores": 1},
+						"memory":  map[string]interface{}{"guest": "1Gi"},
+						"devices": map[string]interface{}{},
+					},
+				},
+			},
+		},
+	}
+	raw, err := json.Marshal(vm)
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode VirtualMachine: %v", err))
+	}
+
+	return &templatev1.Template{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "lifecycle-template",
+			Namespace: TemplateNamespace,
+			Labels:    map[string]string{services.TemplateVersionLabel: "v1"},
+			Annotations: map[string]string{
+				services.ContainerDisksAnnotation: "quay.io/containerdisks/fedora:latest",
+				"description":                     "Template for the e2e vApp lifecycle test",
+			},
+		},
+		Objects: []runtime.RawExtension{{Raw: raw}},
+		Parameters: []templatev1.Parameter{
+			{Name: "NAME", Generate: "expression", From: "lifecycle-vm-[a-z0-9]{5}"},
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
 	"runtime"
@@ -14,16 +15,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 
 	"github.com/mhrivnak/ssvirt/pkg/api"
+	"github.com/mhrivnak/ssvirt/pkg/api/apiversion"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
 	"github.com/mhrivnak/ssvirt/pkg/config"
 	"github.com/mhrivnak/ssvirt/pkg/database"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
-	domainerrors "github.com/mhrivnak/ssvirt/pkg/domain/errors"
 	"github.com/mhrivnak/ssvirt/pkg/services"
 )
 
@@ -32,22 +31,14 @@ type MockTemplateService struct {
 	mock.Mock
 }
 
-func (m *MockTemplateService) ListCatalogItems(ctx context.Context, catalogID string, limit, offset int) ([]models.CatalogItem, error) {
-	args := m.Called(ctx, catalogID, limit, offset)
-	return args.Get(0).([]models.CatalogItem), args.Error(1)
-}
-
-func (m *MockTemplateService) CountCatalogItems(ctx context.Context, catalogID string) (int64, error) {
-	args := m.Called(ctx, catalogID)
-	return args.Get(0).(int64), args.Error(1)
+func (m *MockTemplateService) ListCatalogItemRecords(ctx context.Context) ([]models.CatalogItemRecord, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.CatalogItemRecord), args.Error(1)
 }
 
-func (m *MockTemplateService) GetCatalogItem(ctx context.Context, catalogID, itemID string) (*models.CatalogItem, error) {
-	args := m.Called(ctx, catalogID, itemID)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*models.CatalogItem), args.Error(1)
+func (m *MockTemplateService) OnTemplateChange(ctx context.Context, callback func()) error {
+	args := m.Called(ctx, callback)
+	return args.Error(0)
 }
 
 func (m *MockTemplateService) Start(ctx context.Context) error {
@@ -56,12 +47,11 @@ func (m *MockTemplateService) Start(ctx context.Context) error {
 }
 
 func setupTestAPIServer(t *testing.T) (*api.Server, *database.DB, *auth.JWTManager) {
-	// Create in-memory SQLite database
-	gormDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	require.NoError(t, err)
+	// Create an empty database, in-memory SQLite unless PostgreSQL is configured
+	gormDB := openTestDB(t)
 
 	// Auto-migrate the schema
-	err = gormDB.AutoMigrate(&models.User{}, &models.Organization{}, &models.Role{}, &models.VDC{}, &models.Catalog{}, &models.VAppTemplate{}, &models.VApp{}, &models.VM{})
+	err := gormDB.AutoMigrate(&models.User{}, &models.Organization{}, &models.Role{}, &models.VDC{}, &models.Catalog{}, &models.VAppTemplate{}, &models.VApp{}, &models.VM{}, &models.OrgBranding{}, &models.Task{}, &models.CatalogItemRecord{}, &models.CatalogAccessControl{}, &models.SSHKey{}, &models.VDCStorageProfile{}, &models.CatalogSource{}, &models.APIUsage{}, &models.ComponentHeartbeat{}, &models.ArchivedVM{}, &models.OrgSequence{}, &models.SystemStatusOverride{}, &models.CatalogItemPin{})
 	require.NoError(t, err)
 
 	db := &database.DB{DB: gormDB}
@@ -72,15 +62,55 @@ func setupTestAPIServer(t *testing.T) (*api.Server, *database.DB, *auth.JWTManag
 			Port    int    `mapstructure:"port"`
 			TLSCert string `mapstructure:"tls_cert"`
 			TLSKey  string `mapstructure:"tls_key"`
+			// TrustedProxies are the addresses or CIDRs of the proxies whose
+			// X-Forwarded-For and X-Real-IP headers name the client; empty trusts none
+			TrustedProxies []string `mapstructure:"trusted_proxies"`
+			// MetricsAddress is where Prometheus metrics are served, apart from
+			// the API so they are not exposed with it; empty disables them
+			MetricsAddress string `mapstructure:"metrics_address"`
+			// ShutdownTimeout bounds how long in-flight requests and background
+			// operations are waited for on shutdown; keep it below the pod's
+			// termination grace period
+			ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+			// Usage counts API calls and data transfer per user and day
+			Usage struct {
+				// FlushInterval is how often counts are written to the database; 0 disables tracking
+				FlushInterval time.Duration `mapstructure:"flush_interval"`
+				// RetentionDays is how long daily usage is kept; 0 keeps it forever
+				RetentionDays int `mapstructure:"retention_days"`
+			} `mapstructure:"usage"`
+			// Requests bounds request bodies; 0 disables a limit
+			Requests struct {
+				MaxBodyBytes       int64 `mapstructure:"max_body_bytes"`
+				MaxJSONDepth       int   `mapstructure:"max_json_depth"`
+				MaxJSONArrayLength int   `mapstructure:"max_json_array_length"`
+				// StrictJSONGroups lists the route groups, cloudapi, admin or
+				// legacy, whose JSON bodies may not contain unknown fields
+				StrictJSONGroups []string `mapstructure:"strict_json_groups"`
+			} `mapstructure:"requests"`
 		}{
 			Port: 8080,
 		},
 		Auth: struct {
 			JWTSecret   string        `mapstructure:"jwt_secret"`
 			TokenExpiry time.Duration `mapstructure:"token_expiry"`
+			// RoleCacheTTL is how long a user's roles are cached for authorization checks; 0 disables caching
+			RoleCacheTTL time.Duration `mapstructure:"role_cache_ttl"`
+			// ImpersonationTTL is how long tokens issued to administrators impersonating a tenant user remain valid
+			ImpersonationTTL time.Duration `mapstructure:"impersonation_ttl"`
+			// Issuer and Audience are set as the iss and aud claims of issued tokens
+			// and required of presented ones, so installations sharing a secret
+			// reject each other's tokens; empty values are not checked
+			Issuer   string `mapstructure:"issuer"`
+			Audience string `mapstructure:"audience"`
+			// ClockSkew is how far token expiry and not-before times may be off
+			ClockSkew time.Duration `mapstructure:"clock_skew"`
 		}{
 			JWTSecret:   "test-secret",
 			TokenExpiry: time.Hour,
+			// Tests change role assignments directly in the database
+			RoleCacheTTL:     0,
+			ImpersonationTTL: 15 * time.Minute,
 		},
 		Session: struct {
 			IdleTimeoutMinutes int `mapstructure:"idle_timeout_minutes"`
@@ -107,6 +137,7 @@ func setupTestAPIServer(t *testing.T) (*api.Server, *database.DB, *auth.JWTManag
 			Level: "debug",
 		},
 	}
+	cfg.Quota.DefaultVMSize = config.VMSizeConfig{CPUs: 2, MemoryMB: 4096}
 
 	// Initialize repositories and services
 	userRepo := repositories.NewUserRepository(gormDB)
@@ -123,9 +154,8 @@ func setupTestAPIServer(t *testing.T) (*api.Server, *database.DB, *auth.JWTManag
 	// Create mock template service for testing
 	mockTemplateService := &MockTemplateService{}
 	// Set up default mock responses for catalog items
-	mockTemplateService.On("ListCatalogItems", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]models.CatalogItem{}, nil)
-	mockTemplateService.On("CountCatalogItems", mock.Anything, mock.Anything).Return(int64(0), nil)
-	mockTemplateService.On("GetCatalogItem", mock.Anything, mock.Anything, mock.Anything).Return(nil, domainerrors.ErrNotFound)
+	mockTemplateService.On("ListCatalogItemRecords", mock.Anything).Return([]models.CatalogItemRecord{}, nil)
+	mockTemplateService.On("OnTemplateChange", mock.Anything, mock.Anything).Return(nil)
 	mockTemplateService.On("Start", mock.Anything).Return(nil)
 
 	var templateService services.TemplateServiceInterface = mockTemplateService
@@ -166,6 +196,27 @@ func TestHealthEndpoint(t *testing.T) {
 	})
 }
 
+func TestMetricsEndpoint(t *testing.T) {
+	server, _, _ := setupTestAPIServer(t)
+
+	t.Run("Metrics are not served by the API", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		w := httptest.NewRecorder()
+		server.GetRouter().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Metrics handler serves Prometheus metrics", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		w := httptest.NewRecorder()
+		api.MetricsHandler().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "go_goroutines")
+	})
+}
+
 func TestReadinessEndpoint(t *testing.T) {
 	server, _, _ := setupTestAPIServer(t)
 	router := server.GetRouter()
@@ -214,6 +265,93 @@ func TestVersionEndpoint(t *testing.T) {
 	})
 }
 
+func TestAPIVersionsEndpoint(t *testing.T) {
+	server, _, _ := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	t.Run("Lists supported versions as JSON", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/versions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response api.SupportedVersions
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+		require.Len(t, response.VersionInfo, len(apiversion.Supported))
+		latest := response.VersionInfo[len(response.VersionInfo)-1]
+		assert.Equal(t, apiversion.Latest.String(), latest.Version)
+		assert.Equal(t, "/cloudapi/1.0.0/sessions", latest.LoginURL)
+	})
+
+	t.Run("Lists supported versions as XML", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/versions", nil)
+		req.Header.Set("Accept", "application/*+xml;version=39.0")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response api.SupportedVersions
+		err := xml.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, "http://www.vmware.com/vcloud/versions", response.Xmlns)
+		assert.Len(t, response.VersionInfo, len(apiversion.Supported))
+	})
+}
+
+func TestAPIVersionNegotiation(t *testing.T) {
+	t.Run("Parses versions from Accept headers", func(t *testing.T) {
+		v, found, err := apiversion.FromAccept("application/json;version=39.0")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, apiversion.Version{Major: 39}, v)
+
+		v, found, err = apiversion.FromAccept("text/html, application/*+json; version=38.0")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "38.0", v.String())
+
+		_, found, err = apiversion.FromAccept("application/json")
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		_, _, err = apiversion.FromAccept("application/json;version=latest")
+		assert.Error(t, err)
+	})
+
+	t.Run("Compares versions", func(t *testing.T) {
+		assert.True(t, apiversion.MustParse("39.0").AtLeast(apiversion.MustParse("38.1")))
+		assert.True(t, apiversion.MustParse("38.1").AtLeast(apiversion.MustParse("38.1")))
+		assert.False(t, apiversion.MustParse("38.0").AtLeast(apiversion.MustParse("38.1")))
+		assert.False(t, apiversion.IsSupported(apiversion.MustParse("5.1")))
+	})
+
+	t.Run("Shapes responses for older versions", func(t *testing.T) {
+		type item struct {
+			Name  string `json:"name"`
+			Added string `json:"added,omitempty" since:"39.0"`
+		}
+		type page struct {
+			Values []item `json:"values"`
+			Latest *item  `json:"latest"`
+		}
+		response := page{
+			Values: []item{{Name: "a", Added: "x"}},
+			Latest: &item{Name: "b", Added: "y"},
+		}
+
+		shaped := apiversion.Shape(apiversion.MustParse("39.0"), response).(page)
+		assert.Equal(t, "x", shaped.Values[0].Added)
+
+		shaped = apiversion.Shape(apiversion.MustParse("38.0"), response).(page)
+		assert.Equal(t, "a", shaped.Values[0].Name)
+		assert.Empty(t, shaped.Values[0].Added)
+		assert.Empty(t, shaped.Latest.Added)
+	})
+}
+
 func TestUserProfileEndpoint(t *testing.T) {
 	server, db, jwtManager := setupTestAPIServer(t)
 	router := server.GetRouter()
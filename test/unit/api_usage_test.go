@@ -0,0 +1,136 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestAPIUsageRecorder(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	repo := repositories.NewAPIUsageRepository(db.DB)
+	recorder := services.NewAPIUsageRecorder(repo, time.Minute, 30, nil)
+	ctx := context.Background()
+
+	recorder.Record("urn:vcloud:user:a", "urn:vcloud:org:a", 100, 2000, false)
+	recorder.Record("urn:vcloud:user:a", "urn:vcloud:org:a", 0, 500, true)
+	recorder.Record("urn:vcloud:user:b", "urn:vcloud:org:b", 10, 20, false)
+	require.NoError(t, recorder.Flush(ctx))
+
+	// A second flush adds to the stored totals
+	recorder.Record("urn:vcloud:user:a", "urn:vcloud:org:a", 1, 1, false)
+	require.NoError(t, recorder.Flush(ctx))
+	require.NoError(t, recorder.Flush(ctx))
+
+	usage, err := repo.List(ctx, repositories.APIUsageFilter{UserID: "urn:vcloud:user:a"}, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, models.APIUsageDay(time.Now()), usage[0].Day)
+	assert.Equal(t, "urn:vcloud:org:a", usage[0].OrganizationID)
+	assert.Equal(t, int64(3), usage[0].RequestCount)
+	assert.Equal(t, int64(1), usage[0].ErrorCount)
+	assert.Equal(t, int64(101), usage[0].BytesIn)
+	assert.Equal(t, int64(2501), usage[0].BytesOut)
+
+	// Usage older than the retention period is pruned
+	require.NoError(t, db.DB.Create(&models.APIUsage{Day: "2000-01-01", UserID: "urn:vcloud:user:a", RequestCount: 5}).Error)
+	require.NoError(t, recorder.Prune(ctx))
+	count, err := repo.Count(ctx, repositories.APIUsageFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestAPIUsageEndpoints(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	org := &models.Organization{Name: "usage-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	otherOrg := &models.Organization{Name: "other-usage-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(otherOrg).Error)
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+	orgAdminRole := &models.Role{Name: models.RoleOrgAdmin, Description: "Organization Administrator role"}
+	require.NoError(t, db.DB.Create(orgAdminRole).Error)
+
+	sysAdmin := &models.User{Username: "sysadmin", Email: "sysadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+	adminToken, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	orgAdmin := &models.User{Username: "orgadmin", Email: "orgadmin@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, orgAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(orgAdmin).Error)
+	require.NoError(t, db.DB.Model(orgAdmin).Association("Roles").Append(orgAdminRole))
+	orgAdminToken, err := jwtManager.Generate(orgAdmin.ID, orgAdmin.Username)
+	require.NoError(t, err)
+
+	require.NoError(t, repositories.NewAPIUsageRepository(db.DB).AddUsage(context.Background(), []models.APIUsage{
+		{Day: "2026-01-01", UserID: orgAdmin.ID, OrganizationID: org.ID, RequestCount: 10},
+		{Day: "2026-01-02", UserID: orgAdmin.ID, OrganizationID: org.ID, RequestCount: 20},
+		{Day: "2026-01-02", UserID: "urn:vcloud:user:other", OrganizationID: otherOrg.ID, RequestCount: 5000},
+	}))
+
+	doRequest := func(path, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) []models.APIUsage {
+		var page struct {
+			ResultTotal int               `json:"resultTotal"`
+			Values      []models.APIUsage `json:"values"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, len(page.Values), page.ResultTotal)
+		return page.Values
+	}
+
+	t.Run("Operators see all usage", func(t *testing.T) {
+		w := doRequest("/api/admin/usage/api", adminToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		usage := decode(w)
+		require.Len(t, usage, 3)
+		assert.Equal(t, int64(5000), usage[0].RequestCount)
+
+		w = doRequest("/api/admin/usage/api?orgId="+org.ID+"&from=2026-01-02", adminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+		usage = decode(w)
+		require.Len(t, usage, 1)
+		assert.Equal(t, int64(20), usage[0].RequestCount)
+
+		w = doRequest("/api/admin/usage/api?from=yesterday", adminToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = doRequest("/api/admin/usage/api", orgAdminToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Organization administrators see their own organization", func(t *testing.T) {
+		w := doRequest("/cloudapi/1.0.0/orgs/"+org.ID+"/usage/api", orgAdminToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Len(t, decode(w), 2)
+
+		w = doRequest("/cloudapi/1.0.0/orgs/"+otherOrg.ID+"/usage/api", orgAdminToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doRequest("/cloudapi/1.0.0/orgs/"+otherOrg.ID+"/usage/api", adminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, decode(w), 1)
+	})
+}
@@ -0,0 +1,258 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+const approvalSecret = "approval-secret"
+
+// signApproval returns the signature headers of an approval body
+func signApproval(timestamp time.Time, body []byte) (string, string) {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(approvalSecret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return ts, "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// decisionBody returns the body of a decision posted back for a task
+func decisionBody(taskID, decision, reason string) string {
+	body, _ := json.Marshal(services.ApprovalDecision{TaskID: taskID, Decision: decision, Reason: reason})
+	return string(body)
+}
+
+func TestApprovalWebhook(t *testing.T) {
+	db := setupTestDB(t)
+
+	org := &models.Organization{Name: "ApprovalOrg", IsEnabled: true}
+	require.NoError(t, db.Create(org).Error)
+	user := &models.User{Username: "approvaluser", Email: "approval@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.Create(user).Error)
+
+	// The webhook answers with the next decision and records the requests it got
+	var mu sync.Mutex
+	decision := services.ApprovalDecision{Decision: services.ApprovalPending, Reason: "CHG0001 opened"}
+	var requests []services.ApprovalRequest
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, signature := signApproval(time.Now(), body)
+		assert.Equal(t, ts, r.Header.Get(services.ApprovalTimestampHeader))
+		assert.Equal(t, signature, r.Header.Get(services.ApprovalSignatureHeader))
+
+		var request services.ApprovalRequest
+		require.NoError(t, json.Unmarshal(body, &request))
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, request)
+		_ = json.NewEncoder(w).Encode(decision)
+	}))
+	defer webhook.Close()
+	setDecision := func(d string) {
+		mu.Lock()
+		defer mu.Unlock()
+		decision.Decision = d
+	}
+
+	cfg := config.Config{}
+	cfg.Approval.WebhookURL = webhook.URL
+	cfg.Approval.Secret = approvalSecret
+	cfg.Approval.Timeout = 5 * time.Second
+	cfg.Approval.Operations = []string{models.TaskOperationVDCDelete}
+
+	vdcRepo := repositories.NewVDCRepository(db)
+	taskRepo := repositories.NewTaskRepository(db)
+	vdcHandlers := handlers.NewVDCHandlers(vdcRepo, repositories.NewOrganizationRepository(db), repositories.NewUserRepository(db), nil)
+	approvals := handlers.NewApprovals(services.NewApprovalGate(cfg), taskRepo, nil)
+	vdcHandlers.SetApprovals(approvals)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/cloudapi/1.0.0/approvals/:task_id", approvals.RecordDecision)
+	authenticated := router.Group("/")
+	authenticated.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID, Username: user.Username})
+	})
+	authenticated.DELETE("/cloudapi/1.0.0/vdcs/:vdc_id", vdcHandlers.CloudAPIDeleteVDC)
+
+	createVDC := func(t *testing.T, name string) *models.VDC {
+		vdc := &models.VDC{Name: name, OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+		require.NoError(t, db.Create(vdc).Error)
+		return vdc
+	}
+	deleteVDC := func(vdc *models.VDC) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("DELETE", "/cloudapi/1.0.0/vdcs/"+vdc.ID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	decide := func(taskID string, body string, signedAt time.Time) *httptest.ResponseRecorder {
+		ts, signature := signApproval(signedAt, []byte(body))
+		req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/approvals/"+taskID, bytes.NewBufferString(body))
+		req.Header.Set(services.ApprovalTimestampHeader, ts)
+		req.Header.Set(services.ApprovalSignatureHeader, signature)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	vdcExists := func(t *testing.T, vdc *models.VDC) bool {
+		var count int64
+		require.NoError(t, db.Model(&models.VDC{}).Where("id = ?", vdc.ID).Count(&count).Error)
+		return count > 0
+	}
+
+	t.Run("Held operations wait for approval and run when repeated", func(t *testing.T) {
+		vdc := createVDC(t, "held-vdc")
+
+		w := deleteVDC(vdc)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var task handlers.TaskResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+		assert.Equal(t, models.TaskStatusPendingApproval, task.Status)
+		assert.Equal(t, vdc.ID, task.Owner.ID)
+		assert.Equal(t, task.Href, w.Header().Get("Location"))
+		assert.True(t, vdcExists(t, vdc))
+
+		mu.Lock()
+		require.Len(t, requests, 1)
+		assert.Equal(t, task.ID, requests[0].TaskID)
+		assert.Equal(t, models.TaskOperationVDCDelete, requests[0].Operation)
+		assert.Equal(t, user.Username, requests[0].Username)
+		assert.Equal(t, org.ID, requests[0].OrgID)
+		mu.Unlock()
+
+		// Repeating the request while it is held does not call out again
+		w = deleteVDC(vdc)
+		require.Equal(t, http.StatusAccepted, w.Code)
+		var repeated handlers.TaskResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &repeated))
+		assert.Equal(t, task.ID, repeated.ID)
+		mu.Lock()
+		assert.Len(t, requests, 1)
+		mu.Unlock()
+
+		w = decide(task.ID, decisionBody(task.ID, "allow", "CHG0001 approved"), time.Now())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		stored, err := taskRepo.GetByID(t.Context(), task.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TaskStatusQueued, stored.Status)
+
+		// Decisions are only recorded once
+		w = decide(task.ID, decisionBody(task.ID, "deny", ""), time.Now())
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = deleteVDC(vdc)
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		assert.False(t, vdcExists(t, vdc))
+		stored, err = taskRepo.GetByID(t.Context(), task.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TaskStatusSuccess, stored.Status)
+	})
+
+	t.Run("Denied held operations fail their task", func(t *testing.T) {
+		vdc := createVDC(t, "denied-held-vdc")
+		w := deleteVDC(vdc)
+		require.Equal(t, http.StatusAccepted, w.Code)
+		var task handlers.TaskResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+
+		w = decide(task.ID, decisionBody(task.ID, "deny", "change window closed"), time.Now())
+		require.Equal(t, http.StatusOK, w.Code)
+		var decided handlers.TaskResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decided))
+		assert.Equal(t, models.TaskStatusError, decided.Status)
+		assert.Contains(t, decided.ErrorMessage, "change window closed")
+		assert.True(t, vdcExists(t, vdc))
+	})
+
+	t.Run("Decisions must be signed recently with the secret", func(t *testing.T) {
+		vdc := createVDC(t, "unsigned-vdc")
+		w := deleteVDC(vdc)
+		require.Equal(t, http.StatusAccepted, w.Code)
+		var task handlers.TaskResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+
+		body := decisionBody(task.ID, "allow", "")
+		req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/approvals/"+task.ID, bytes.NewBufferString(body))
+		req.Header.Set(services.ApprovalTimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+		req.Header.Set(services.ApprovalSignatureHeader, "sha256=00")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = decide(task.ID, body, time.Now().Add(-time.Hour))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Decisions signed for one task are refused for another", func(t *testing.T) {
+		var tasks []handlers.TaskResponse
+		for _, name := range []string{"replayed-a-vdc", "replayed-b-vdc"} {
+			w := deleteVDC(createVDC(t, name))
+			require.Equal(t, http.StatusAccepted, w.Code)
+			var task handlers.TaskResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+			tasks = append(tasks, task)
+		}
+
+		body := decisionBody(tasks[0].ID, "allow", "")
+		w := decide(tasks[1].ID, body, time.Now())
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		stored, err := taskRepo.GetByID(t.Context(), tasks[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TaskStatusPendingApproval, stored.Status)
+
+		// Decisions without a task ID are refused too
+		w = decide(tasks[1].ID, `{"decision":"allow"}`, time.Now())
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = decide(tasks[0].ID, body, time.Now())
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Allowed operations run immediately and denied ones are refused", func(t *testing.T) {
+		setDecision(services.ApprovalDeny)
+		vdc := createVDC(t, "denied-vdc")
+		w := deleteVDC(vdc)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.True(t, vdcExists(t, vdc))
+
+		setDecision(services.ApprovalAllow)
+		w = deleteVDC(vdc)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.False(t, vdcExists(t, vdc))
+	})
+
+	t.Run("Operations are refused when the webhook is unavailable", func(t *testing.T) {
+		unavailable := cfg
+		unavailable.Approval.WebhookURL = "http://127.0.0.1:1"
+		vdcHandlers.SetApprovals(handlers.NewApprovals(services.NewApprovalGate(unavailable), taskRepo, nil))
+		defer vdcHandlers.SetApprovals(approvals)
+
+		vdc := createVDC(t, "unavailable-vdc")
+		w := deleteVDC(vdc)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.True(t, vdcExists(t, vdc))
+	})
+}
@@ -1,24 +1,29 @@
 package unit
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"gorm.io/driver/sqlite"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
 	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/consistency"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
 )
 
 func setupTestAuthDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	require.NoError(t, err)
+	db := openTestDB(t)
 
-	err = db.AutoMigrate(&models.User{}, &models.Organization{}, &models.Role{})
+	err := db.AutoMigrate(&models.User{}, &models.Organization{}, &models.Role{})
 	require.NoError(t, err)
 
 	return db
@@ -80,6 +85,49 @@ func TestJWTManager(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "expired")
 	})
+
+	t.Run("Clock skew tolerates recently expired tokens", func(t *testing.T) {
+		skewedManager := auth.NewJWTManager(secretKey, time.Nanosecond)
+		skewedManager.SetClaimsValidation("", "", time.Minute)
+		token, err := skewedManager.Generate(userID, username)
+		require.NoError(t, err)
+
+		time.Sleep(time.Millisecond)
+
+		_, err = skewedManager.Verify(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Issuer and audience are set and checked", func(t *testing.T) {
+		prodManager := auth.NewJWTManager(secretKey, tokenDuration)
+		prodManager.SetClaimsValidation("https://ssvirt.example.com", "ssvirt-prod", 0)
+		stagingManager := auth.NewJWTManager(secretKey, tokenDuration)
+		stagingManager.SetClaimsValidation("https://ssvirt.example.com", "ssvirt-staging", 0)
+
+		token, err := prodManager.Generate(userID, username)
+		require.NoError(t, err)
+		claims, err := prodManager.Verify(token)
+		require.NoError(t, err)
+		assert.Equal(t, "https://ssvirt.example.com", claims.Issuer)
+		assert.Equal(t, []string{"ssvirt-prod"}, []string(claims.Audience))
+
+		// Installations sharing the secret reject each other's tokens
+		_, err = stagingManager.Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+		// Tokens minted without the claims are rejected too
+		unscoped, err := jwtManager.Generate(userID, username)
+		require.NoError(t, err)
+		_, err = prodManager.Verify(unscoped)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+
+		otherIssuer := auth.NewJWTManager(secretKey, tokenDuration)
+		otherIssuer.SetClaimsValidation("https://other.example.com", "ssvirt-prod", 0)
+		token, err = otherIssuer.Generate(userID, username)
+		require.NoError(t, err)
+		_, err = prodManager.Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
 }
 
 func TestUserModel(t *testing.T) {
@@ -163,6 +211,186 @@ func TestUserRepository(t *testing.T) {
 	})
 }
 
+// countingRolesLoader counts database lookups made through a RoleCache
+type countingRolesLoader struct {
+	repo  *repositories.UserRepository
+	calls int
+}
+
+func (l *countingRolesLoader) GetWithRoles(id string) (*models.User, error) {
+	l.calls++
+	return l.repo.GetWithRoles(id)
+}
+
+func TestRoleCache(t *testing.T) {
+	db := setupTestAuthDB(t)
+	userRepo := repositories.NewUserRepository(db)
+
+	role := &models.Role{Name: models.RoleOrgAdmin}
+	require.NoError(t, db.Create(role).Error)
+	user := &models.User{Username: "cached", Email: "cached@example.com", Enabled: true}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, userRepo.CreateUserWithRoles(user, []string{role.ID}))
+
+	t.Run("Caches roles until invalidated", func(t *testing.T) {
+		loader := &countingRolesLoader{repo: userRepo}
+		cache := auth.NewRoleCache(loader, time.Minute)
+
+		cached, err := cache.Get(user.ID)
+		require.NoError(t, err)
+		assert.Len(t, cached.Roles, 1)
+
+		require.NoError(t, userRepo.ClearRoles(user.ID))
+		cached, err = cache.Get(user.ID)
+		require.NoError(t, err)
+		assert.Len(t, cached.Roles, 1)
+		assert.Equal(t, 1, loader.calls)
+
+		cache.Invalidate(user.ID)
+		cached, err = cache.Get(user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, cached.Roles)
+		assert.Equal(t, 2, loader.calls)
+
+		require.NoError(t, userRepo.AssignRoles(user.ID, []string{role.ID}))
+		cache.InvalidateAll()
+		cached, err = cache.Get(user.ID)
+		require.NoError(t, err)
+		assert.Len(t, cached.Roles, 1)
+	})
+
+	t.Run("Zero TTL always loads", func(t *testing.T) {
+		loader := &countingRolesLoader{repo: userRepo}
+		cache := auth.NewRoleCache(loader, 0)
+
+		_, err := cache.Get(user.ID)
+		require.NoError(t, err)
+		_, err = cache.Get(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, loader.calls)
+	})
+
+	t.Run("Lookups are shared within a request", func(t *testing.T) {
+		loader := &countingRolesLoader{repo: userRepo}
+		cache := auth.NewRoleCache(loader, 0)
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+
+		first, err := auth.UserWithRoles(c, cache)
+		require.NoError(t, err)
+		second, err := auth.UserWithRoles(c, cache)
+		require.NoError(t, err)
+		assert.Same(t, first, second)
+		assert.Equal(t, 1, loader.calls)
+	})
+
+	t.Run("Strongly consistent requests reload roles", func(t *testing.T) {
+		loader := &countingRolesLoader{repo: userRepo}
+		cache := auth.NewRoleCache(loader, time.Minute)
+		_, err := cache.Get(user.ID)
+		require.NoError(t, err)
+
+		// Changed without invalidating the cache, as by another replica
+		require.NoError(t, userRepo.ClearRoles(user.ID))
+		t.Cleanup(func() { _ = userRepo.AssignRoles(user.ID, []string{role.ID}) })
+
+		request := func(level consistency.Level) *models.User {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Request = c.Request.WithContext(consistency.WithLevel(c.Request.Context(), level))
+			c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+			loaded, err := auth.UserWithRoles(c, cache)
+			require.NoError(t, err)
+			return loaded
+		}
+		assert.Len(t, request(consistency.Eventual).Roles, 1)
+		assert.Empty(t, request(consistency.Strong).Roles)
+		// The reload refreshed the cache for other requests too
+		assert.Empty(t, request(consistency.Eventual).Roles)
+		assert.Equal(t, 2, loader.calls)
+	})
+
+	t.Run("Unauthenticated request", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		_, err := auth.UserWithRoles(c, auth.NewRoleCache(userRepo, 0))
+		assert.ErrorIs(t, err, auth.ErrNoClaims)
+	})
+}
+
+func TestAccessControl(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	vdcRepo := repositories.NewVDCRepository(db)
+	vappRepo := repositories.NewVAppRepository(db)
+	vmRepo := repositories.NewVMRepository(db)
+	userRepo := repositories.NewUserRepository(db)
+	access := auth.NewAccessControl(vdcRepo, vappRepo, vmRepo)
+
+	org := &models.Organization{Name: "access-org"}
+	require.NoError(t, db.Create(org).Error)
+	otherOrg := &models.Organization{Name: "other-access-org"}
+	require.NoError(t, db.Create(otherOrg).Error)
+
+	vdc := &models.VDC{Name: "access-vdc", OrganizationID: otherOrg.ID, AllocationModel: models.PayAsYouGo}
+	require.NoError(t, db.Create(vdc).Error)
+	vapp := &models.VApp{Name: "access-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.Create(vapp).Error)
+	vm := &models.VM{Name: "access-vm", VMName: "access-vm", Namespace: "ns", VAppID: vapp.ID, Status: "POWERED_ON"}
+	require.NoError(t, db.Create(vm).Error)
+
+	member := &models.User{Username: "member", Email: "member@example.com", Enabled: true, OrganizationID: &otherOrg.ID}
+	require.NoError(t, member.SetPassword("password123"))
+	require.NoError(t, userRepo.Create(member))
+	outsider := &models.User{Username: "outsider", Email: "outsider@example.com", Enabled: true, OrganizationID: &org.ID}
+	require.NoError(t, outsider.SetPassword("password123"))
+	require.NoError(t, userRepo.Create(outsider))
+	adminRole := &models.Role{Name: models.RoleSystemAdmin}
+	require.NoError(t, db.Create(adminRole).Error)
+	admin := &models.User{Username: "sysadmin", Email: "sysadmin@example.com", Enabled: true}
+	require.NoError(t, admin.SetPassword("password123"))
+	require.NoError(t, userRepo.CreateUserWithRoles(admin, []string{adminRole.ID}))
+
+	t.Run("Organization member", func(t *testing.T) {
+		_, err := access.CanAccessVDC(ctx, member.ID, vdc.ID)
+		require.NoError(t, err)
+		gotVApp, err := access.CanAccessVApp(ctx, member.ID, vapp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, vapp.ID, gotVApp.ID)
+		gotVM, err := access.CanManageVM(ctx, member.ID, vm.ID)
+		require.NoError(t, err)
+		require.NotNil(t, gotVM.VApp)
+		assert.Equal(t, vdc.ID, gotVM.VApp.VDCID)
+	})
+
+	t.Run("System administrator", func(t *testing.T) {
+		_, err := access.CanAccessVDC(ctx, admin.ID, vdc.ID)
+		require.NoError(t, err)
+		_, err = access.CanAccessVApp(ctx, admin.ID, vapp.ID)
+		require.NoError(t, err)
+		_, err = access.CanManageVM(ctx, admin.ID, vm.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("Other organization", func(t *testing.T) {
+		_, err := access.CanAccessVDC(ctx, outsider.ID, vdc.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = access.CanAccessVApp(ctx, outsider.ID, vapp.ID)
+		assert.ErrorIs(t, err, auth.ErrAccessDenied)
+		_, err = access.CanManageVM(ctx, outsider.ID, vm.ID)
+		assert.ErrorIs(t, err, auth.ErrAccessDenied)
+	})
+
+	t.Run("Missing entities", func(t *testing.T) {
+		_, err := access.CanAccessVDC(ctx, member.ID, "urn:vcloud:vdc:missing")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = access.CanAccessVApp(ctx, member.ID, "urn:vcloud:vapp:missing")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		_, err = access.CanManageVM(ctx, member.ID, "urn:vcloud:vm:missing")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+}
+
 func TestAuthService(t *testing.T) {
 	db := setupTestAuthDB(t)
 	userRepo := repositories.NewUserRepository(db)
@@ -228,6 +456,31 @@ func TestAuthService(t *testing.T) {
 		assert.ErrorIs(t, err, auth.ErrUserExists)
 	})
 
+	t.Run("Login upgrades legacy bcrypt hash", func(t *testing.T) {
+		legacyHash, err := bcrypt.GenerateFromPassword([]byte("legacy-pass-123"), bcrypt.DefaultCost)
+		require.NoError(t, err)
+		legacyUser := &models.User{
+			Username:     "legacyuser",
+			Email:        "legacy@example.com",
+			PasswordHash: string(legacyHash),
+			Enabled:      true,
+		}
+		require.NoError(t, userRepo.Create(legacyUser))
+
+		_, err = authService.Login(&auth.LoginRequest{Username: "legacyuser", Password: "legacy-pass-123"})
+		require.NoError(t, err)
+
+		upgraded, err := userRepo.GetByUsername("legacyuser")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(upgraded.PasswordHash, "$argon2id$"))
+		assert.False(t, upgraded.PasswordNeedsRehash())
+		assert.True(t, upgraded.CheckPassword("legacy-pass-123"))
+
+		// Subsequent logins keep working with the upgraded hash
+		_, err = authService.Login(&auth.LoginRequest{Username: "legacyuser", Password: "legacy-pass-123"})
+		require.NoError(t, err)
+	})
+
 	t.Run("Login with nil request", func(t *testing.T) {
 		_, err := authService.Login(nil)
 		assert.Error(t, err)
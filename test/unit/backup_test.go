@@ -0,0 +1,227 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// veleroBackup returns a Velero Backup in the openshift-adp namespace
+func veleroBackup(name, phase, started string, spec map[string]interface{}) *unstructured.Unstructured {
+	backup := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Backup",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "openshift-adp",
+			"labels":    map[string]interface{}{"velero.io/schedule-name": "daily"},
+		},
+		"spec":   spec,
+		"status": map[string]interface{}{"phase": phase, "startTimestamp": started},
+	}}
+	if phase == services.BackupPhaseCompleted {
+		_ = unstructured.SetNestedField(backup.Object, started, "status", "completionTimestamp")
+	}
+	return backup
+}
+
+func backupTestClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestBackupLabels(t *testing.T) {
+	assert.Nil(t, services.BackupLabels(nil))
+	assert.Equal(t, map[string]string{services.VeleroExcludeLabel: "true"}, services.BackupLabels(&models.BackupPolicy{}))
+	assert.Equal(t, map[string]string{services.BackupLabel: "enabled", services.BackupScheduleLabel: "daily"},
+		services.BackupLabels(&models.BackupPolicy{Enabled: true, Schedule: "daily"}))
+
+	assert.NoError(t, services.ValidateBackupPolicy(models.BackupPolicy{Enabled: true, Schedule: "daily"}))
+	assert.Error(t, services.ValidateBackupPolicy(models.BackupPolicy{Enabled: true, Schedule: "not a label"}))
+	assert.Error(t, services.ValidateBackupPolicy(models.BackupPolicy{Schedule: "daily"}))
+}
+
+func TestVMBackupStatus(t *testing.T) {
+	ctx := context.Background()
+	vm := &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{
+		Name:      "web",
+		Namespace: "backup-ns",
+		Labels:    map[string]string{services.BackupLabel: "enabled", services.BackupScheduleLabel: "daily"},
+	}}
+	selects := func(labels map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"labelSelector": map[string]interface{}{"matchLabels": labels}}
+	}
+	c := backupTestClient(t, vm,
+		veleroBackup("daily-1", services.BackupPhaseCompleted, "2026-01-01T02:00:00Z", selects(map[string]interface{}{services.BackupScheduleLabel: "daily"})),
+		veleroBackup("daily-2", services.BackupPhaseCompleted, "2026-01-02T02:00:00Z", map[string]interface{}{"includedNamespaces": []interface{}{"backup-ns"}}),
+		veleroBackup("daily-3", "PartiallyFailed", "2026-01-03T02:00:00Z", map[string]interface{}{"includedNamespaces": []interface{}{"*"}}),
+		// Not selecting the VM
+		veleroBackup("weekly", services.BackupPhaseCompleted, "2026-01-04T02:00:00Z", selects(map[string]interface{}{services.BackupScheduleLabel: "weekly"})),
+		veleroBackup("other-ns", services.BackupPhaseCompleted, "2026-01-04T02:00:00Z", map[string]interface{}{"includedNamespaces": []interface{}{"other-ns"}}),
+		veleroBackup("no-vms", services.BackupPhaseCompleted, "2026-01-04T02:00:00Z", map[string]interface{}{"excludedResources": []interface{}{"virtualmachines.kubevirt.io"}}),
+		veleroBackup("pvcs-only", services.BackupPhaseCompleted, "2026-01-04T02:00:00Z", map[string]interface{}{"includedResources": []interface{}{"persistentvolumeclaims"}}),
+	)
+	backups := services.NewBackupService(c, "openshift-adp")
+
+	t.Run("Reports the latest and latest successful Backups", func(t *testing.T) {
+		status, err := backups.VMBackupStatus(ctx, "backup-ns", "web")
+		require.NoError(t, err)
+		assert.True(t, status.Protected)
+		require.NotNil(t, status.LastBackup)
+		assert.Equal(t, "daily-3", status.LastBackup.Name)
+		assert.Equal(t, "PartiallyFailed", status.LastBackup.Phase)
+		assert.Nil(t, status.LastBackup.CompletedAt)
+		require.NotNil(t, status.LastSuccessfulBackup)
+		assert.Equal(t, "daily-2", status.LastSuccessfulBackup.Name)
+		assert.Equal(t, "daily", status.LastSuccessfulBackup.Schedule)
+		assert.Equal(t, "2026-01-02T02:00:00Z", status.LastSuccessfulBackup.CompletedAt.Format("2006-01-02T15:04:05Z07:00"))
+	})
+
+	t.Run("Disabling backups excludes the VM", func(t *testing.T) {
+		require.NoError(t, backups.ApplyVMBackupPolicy(ctx, "backup-ns", "web", &models.BackupPolicy{Enabled: false}))
+
+		var labelled kubevirtv1.VirtualMachine
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "backup-ns", Name: "web"}, &labelled))
+		assert.Equal(t, map[string]string{services.VeleroExcludeLabel: "true"}, labelled.Labels)
+
+		status, err := backups.VMBackupStatus(ctx, "backup-ns", "web")
+		require.NoError(t, err)
+		assert.False(t, status.Protected)
+		assert.Nil(t, status.LastBackup)
+	})
+
+	t.Run("VMs not created yet are skipped", func(t *testing.T) {
+		require.NoError(t, backups.ApplyVMBackupPolicy(ctx, "backup-ns", "missing", &models.BackupPolicy{Enabled: true}))
+		status, err := backups.VMBackupStatus(ctx, "backup-ns", "missing")
+		require.NoError(t, err)
+		assert.False(t, status.Protected)
+	})
+}
+
+func TestBackupPolicyAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "BackupOrg", DisplayName: "Backup Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "backupuser", Email: "backup@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "backup-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "backup-ns"}
+	vdc.SetBackupPolicy(&models.BackupPolicy{Enabled: true, Schedule: "daily"})
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "backup-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	vm := &models.VM{Name: "web", VAppID: vapp.ID, Status: "POWERED_ON", VMName: "web", Namespace: "backup-ns"}
+	require.NoError(t, db.DB.Create(vm).Error)
+
+	k8sClient := backupTestClient(t,
+		&kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "backup-ns", Labels: map[string]string{
+			"app": "web", services.BackupLabel: "enabled", services.BackupScheduleLabel: "daily",
+		}}},
+		veleroBackup("daily-1", services.BackupPhaseCompleted, "2026-01-01T02:00:00Z", nil),
+	)
+	backups := services.NewBackupService(k8sClient, "openshift-adp")
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	access := auth.NewAccessControl(vdcRepo, vappRepo, vmRepo)
+	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, access, nil)
+	vappHandlers.SetBackups(backups)
+	vmHandlers := handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, access, nil, nil)
+	vmHandlers.SetBackups(backups)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+	})
+	router.GET("/cloudapi/1.0.0/vapps/:vapp_id/backupPolicy", vappHandlers.GetBackupPolicy)
+	router.PUT("/cloudapi/1.0.0/vapps/:vapp_id/backupPolicy", vappHandlers.UpdateBackupPolicy)
+	router.GET("/cloudapi/1.0.0/vms/:vm_id/backupStatus", vmHandlers.GetVMBackupStatus)
+
+	policyPath := "/cloudapi/1.0.0/vapps/" + vapp.ID + "/backupPolicy"
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	vmLabels := func() map[string]string {
+		var labelled kubevirtv1.VirtualMachine
+		require.NoError(t, k8sClient.Get(context.Background(), client.ObjectKey{Namespace: "backup-ns", Name: "web"}, &labelled))
+		return labelled.Labels
+	}
+
+	t.Run("vApps inherit their VDC's policy", func(t *testing.T) {
+		w := request("GET", policyPath, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var policy handlers.VAppBackupPolicy
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &policy))
+		assert.True(t, policy.Inherited)
+		assert.Equal(t, &models.BackupPolicy{Enabled: true, Schedule: "daily"}, policy.Policy)
+	})
+
+	t.Run("Backup status reports the last backup", func(t *testing.T) {
+		w := request("GET", "/cloudapi/1.0.0/vms/"+vm.ID+"/backupStatus", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var status map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, vm.ID, status["vmId"])
+		assert.Equal(t, true, status["protected"])
+		assert.Equal(t, map[string]interface{}{"enabled": true, "schedule": "daily"}, status["policy"])
+		lastSuccessful := status["lastSuccessfulBackup"].(map[string]interface{})
+		assert.Equal(t, "daily-1", lastSuccessful["name"])
+		assert.Equal(t, "2026-01-01T02:00:00Z", lastSuccessful["completedAt"])
+	})
+
+	t.Run("Overriding the policy relabels the VMs", func(t *testing.T) {
+		w := request("PUT", policyPath, `{"policy": {"enabled": true, "schedule": "hourly"}}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, map[string]string{"app": "web", services.BackupLabel: "enabled", services.BackupScheduleLabel: "hourly"}, vmLabels())
+
+		w = request("PUT", policyPath, `{"policy": {"enabled": false}}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, map[string]string{"app": "web", services.VeleroExcludeLabel: "true"}, vmLabels())
+
+		stored, err := vappRepo.GetByIDString(context.Background(), vapp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &models.BackupPolicy{Enabled: false}, stored.BackupPolicy(vdc))
+	})
+
+	t.Run("Inheriting restores the VDC's policy", func(t *testing.T) {
+		w := request("PUT", policyPath, `{"inherited": true}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var policy handlers.VAppBackupPolicy
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &policy))
+		assert.True(t, policy.Inherited)
+		assert.Equal(t, &models.BackupPolicy{Enabled: true, Schedule: "daily"}, policy.Policy)
+		assert.Equal(t, map[string]string{"app": "web", services.BackupLabel: "enabled", services.BackupScheduleLabel: "daily"}, vmLabels())
+	})
+
+	t.Run("Rejects invalid policies", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, request("PUT", policyPath, `{}`).Code)
+		assert.Equal(t, http.StatusBadRequest, request("PUT", policyPath, `{"policy": {"enabled": true, "schedule": "not valid!"}}`).Code)
+	})
+}
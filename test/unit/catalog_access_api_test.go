@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestCatalogControlAccess(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	org := &models.Organization{Name: "Shared Org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+
+	authorRole := &models.Role{Name: models.RoleVAppUser}
+	require.NoError(t, db.DB.Create(authorRole).Error)
+	orgAdminRole := &models.Role{Name: models.RoleOrgAdmin}
+	require.NoError(t, db.DB.Create(orgAdminRole).Error)
+
+	newUser := func(name string, roles ...*models.Role) (*models.User, string) {
+		user := &models.User{
+			Username:       name,
+			Email:          name + "@example.com",
+			Enabled:        true,
+			OrganizationID: stringPtr(org.ID),
+		}
+		require.NoError(t, user.SetPassword("password123"))
+		require.NoError(t, db.DB.Create(user).Error)
+		for _, role := range roles {
+			require.NoError(t, db.DB.Model(user).Association("Roles").Append(role))
+		}
+		token, err := jwtManager.GenerateWithRole(user.ID, user.Username, org.ID, models.RoleVAppUser)
+		require.NoError(t, err)
+		return user, token
+	}
+
+	admin, adminToken := newUser("orgadmin", orgAdminRole)
+	reader, readerToken := newUser("reader")
+	_, authorToken := newUser("author", authorRole)
+	_, otherToken := newUser("other")
+
+	catalog := &models.Catalog{
+		Name:           "Restricted",
+		OrganizationID: org.ID,
+		OwnerID:        admin.ID,
+		IsLocal:        true,
+	}
+	require.NoError(t, db.DB.Create(catalog).Error)
+
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req, _ := http.NewRequest(method, url, &buf)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	catalogURL := "/cloudapi/1.0.0/catalogs/" + catalog.ID
+	setAccess := func(token string, params handlers.ControlAccessParams) *httptest.ResponseRecorder {
+		return do("POST", catalogURL+"/action/controlAccess", token, params)
+	}
+
+	t.Run("Catalogs are shared to everyone by default", func(t *testing.T) {
+		w := do("GET", catalogURL+"/controlAccess", otherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response handlers.ControlAccessParams
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.IsSharedToEveryone)
+		assert.Equal(t, models.CatalogAccessChange, response.EveryoneAccessLevel)
+		assert.Empty(t, response.AccessSettings)
+	})
+
+	t.Run("Restrict catalog to a user and a role", func(t *testing.T) {
+		w := setAccess(adminToken, handlers.ControlAccessParams{
+			IsSharedToEveryone: false,
+			AccessSettings: []handlers.AccessSetting{
+				{Subject: models.EntityRef{ID: reader.ID}, AccessLevel: models.CatalogAccessReadOnly},
+				{Subject: models.EntityRef{ID: authorRole.ID}, AccessLevel: "FullControl"},
+			},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response handlers.ControlAccessParams
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.False(t, response.IsSharedToEveryone)
+		require.Len(t, response.AccessSettings, 2)
+		assert.Equal(t, authorRole.ID, response.AccessSettings[0].Subject.ID)
+		assert.Equal(t, models.RoleVAppUser, response.AccessSettings[0].Subject.Name)
+		assert.Equal(t, models.CatalogAccessChange, response.AccessSettings[0].AccessLevel)
+		assert.Equal(t, "reader", response.AccessSettings[1].Subject.Name)
+	})
+
+	t.Run("Unshared catalog is hidden from other members", func(t *testing.T) {
+		w := do("GET", catalogURL, otherToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = do("GET", catalogURL+"/catalogItems", otherToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = do("GET", "/cloudapi/1.0.0/catalogs", otherToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page types.Page[handlers.CatalogResponse]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, int64(0), page.ResultTotal)
+	})
+
+	t.Run("Read-only grant allows viewing but not changing", func(t *testing.T) {
+		w := do("GET", catalogURL, readerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("GET", "/cloudapi/1.0.0/catalogs", readerToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page types.Page[handlers.CatalogResponse]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, int64(1), page.ResultTotal)
+
+		w = setAccess(readerToken, handlers.ControlAccessParams{IsSharedToEveryone: true, EveryoneAccessLevel: models.CatalogAccessReadOnly})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("DELETE", catalogURL, readerToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Invalid access settings are rejected", func(t *testing.T) {
+		w := setAccess(adminToken, handlers.ControlAccessParams{
+			AccessSettings: []handlers.AccessSetting{{Subject: models.EntityRef{ID: reader.ID}, AccessLevel: "Owner"}},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = setAccess(adminToken, handlers.ControlAccessParams{
+			AccessSettings: []handlers.AccessSetting{{Subject: models.EntityRef{ID: "urn:vcloud:user:00000000-0000-0000-0000-000000000000"}, AccessLevel: models.CatalogAccessUse}},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = setAccess(adminToken, handlers.ControlAccessParams{IsSharedToEveryone: true, EveryoneAccessLevel: "Everything"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do("GET", "/cloudapi/1.0.0/catalogs/invalid-urn/controlAccess", adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Role grant with change access allows deletion", func(t *testing.T) {
+		w := do("DELETE", catalogURL, authorToken, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code, fmt.Sprintf("body: %s", w.Body.String()))
+
+		var count int64
+		require.NoError(t, db.DB.Model(&models.CatalogAccessControl{}).Where("catalog_id = ?", catalog.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+}
@@ -30,10 +30,11 @@ func TestCatalogAPIEndpoints(t *testing.T) {
 
 	// Create test user with vApp User role (catalogs are generally accessible)
 	user := &models.User{
-		Username: "testuser",
-		Email:    "testuser@example.com",
-		FullName: "Test User",
-		Enabled:  true,
+		Username:       "testuser",
+		Email:          "testuser@example.com",
+		FullName:       "Test User",
+		Enabled:        true,
+		OrganizationID: stringPtr(org.ID),
 	}
 	require.NoError(t, user.SetPassword("password123"))
 	require.NoError(t, db.DB.Create(user).Error)
@@ -359,10 +360,11 @@ func TestCatalogDependencyValidation(t *testing.T) {
 
 	// Create test user
 	user := &models.User{
-		Username: "testuser",
-		Email:    "testuser@example.com",
-		FullName: "Test User",
-		Enabled:  true,
+		Username:       "testuser",
+		Email:          "testuser@example.com",
+		FullName:       "Test User",
+		Enabled:        true,
+		OrganizationID: stringPtr(org.ID),
 	}
 	require.NoError(t, user.SetPassword("password123"))
 	require.NoError(t, db.DB.Create(user).Error)
@@ -0,0 +1,143 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestCatalogPinsAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "PinOrg", DisplayName: "Pin Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "pinuser", Email: "pin@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	catalog := &models.Catalog{Name: "pin-catalog", OrganizationID: org.ID}
+	require.NoError(t, db.DB.Create(catalog).Error)
+	require.NoError(t, db.DB.Create(&models.CatalogItemRecord{TemplateUID: "uid-fedora", Name: "fedora", Namespace: "openshift"}).Error)
+	vdc := &models.VDC{Name: "pin-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "pin-ns"}
+	require.NoError(t, db.DB.Create(vdc).Error)
+
+	otherOrg := &models.Organization{Name: "OtherPinOrg", IsEnabled: true}
+	require.NoError(t, db.DB.Create(otherOrg).Error)
+	otherVDC := &models.VDC{Name: "other-pin-vdc", OrganizationID: otherOrg.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "other-pin-ns"}
+	require.NoError(t, db.DB.Create(otherVDC).Error)
+
+	var instantiated *services.TemplateInstanceRequest
+	mockK8s := &MockKubernetesService{}
+	mockK8s.On("CreateTemplateInstance", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			instantiated = args.Get(1).(*services.TemplateInstanceRequest)
+		}).
+		Return(&services.TemplateInstanceResult{Name: "pinned-vapp"}, nil)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	catalogRepo := repositories.NewCatalogRepository(db.DB)
+	catalogItemRepo := repositories.NewCatalogItemRepository(db.DB, catalogRepo)
+	pinRepo := repositories.NewCatalogItemPinRepository(db.DB)
+	access := auth.NewAccessControl(vdcRepo, vappRepo, repositories.NewVMRepository(db.DB))
+	pins := handlers.NewCatalogPinHandlers(pinRepo, catalogRepo, catalogItemRepo, access)
+	creation := handlers.NewVMCreationHandlers(vdcRepo, vappRepo, catalogItemRepo, catalogRepo, access, mockK8s)
+	creation.SetCatalogPins(pinRepo)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+	})
+	router.GET("/cloudapi/1.0.0/vdcs/:vdc_id/pinnedCatalogItems", pins.ListPinnedCatalogItems)
+	router.PUT("/cloudapi/1.0.0/vdcs/:vdc_id/pinnedCatalogItems/:itemId", pins.PinCatalogItem)
+	router.DELETE("/cloudapi/1.0.0/vdcs/:vdc_id/pinnedCatalogItems/:itemId", pins.UnpinCatalogItem)
+	router.POST("/cloudapi/1.0.0/vdcs/:vdc_id/actions/instantiateTemplate", creation.InstantiateTemplate)
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	itemID := models.URNPrefixCatalogItem + strings.TrimPrefix(catalog.ID, models.URNPrefixCatalog) + ":fedora"
+	pinPath := "/cloudapi/1.0.0/vdcs/" + vdc.ID + "/pinnedCatalogItems/" + itemID
+
+	t.Run("Pin a catalog item", func(t *testing.T) {
+		w := request(http.MethodPut, pinPath)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var pin handlers.CatalogItemPinResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pin))
+		assert.Equal(t, itemID, pin.CatalogItemID)
+		assert.Equal(t, "fedora", pin.TemplateName)
+		assert.Equal(t, models.CatalogItemPinPending, pin.Status)
+
+		// Pinning again keeps the import status
+		require.NoError(t, pinRepo.RecordStatus(ctx, vdc.ID, "fedora", models.CatalogItemPinReady, "", []string{"golden-abc"}))
+		w = request(http.MethodPut, pinPath)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pin))
+		assert.Equal(t, models.CatalogItemPinReady, pin.Status)
+		assert.Equal(t, 1, pin.ReadyImages)
+		assert.NotNil(t, pin.ReadyAt)
+
+		w = request(http.MethodGet, "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/pinnedCatalogItems")
+		require.Equal(t, http.StatusOK, w.Code)
+		var page types.Page[handlers.CatalogItemPinResponse]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Values, 1)
+		assert.Equal(t, "fedora", page.Values[0].TemplateName)
+	})
+
+	t.Run("Instantiations clone the ready images", func(t *testing.T) {
+		body, _ := json.Marshal(handlers.InstantiateTemplateRequest{
+			Name:        "pinned-vapp",
+			CatalogItem: handlers.CatalogItem{ID: "urn:vcloud:catalogitem:fedora", Name: "fedora"},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/actions/instantiateTemplate", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NotNil(t, instantiated)
+		assert.Equal(t, []string{"golden-abc"}, instantiated.GoldenImages)
+	})
+
+	t.Run("Rejects invalid items and inaccessible VDCs", func(t *testing.T) {
+		w := request(http.MethodPut, "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/pinnedCatalogItems/urn:vcloud:catalogitem:fedora")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		missing := models.URNPrefixCatalogItem + strings.TrimPrefix(catalog.ID, models.URNPrefixCatalog) + ":missing"
+		w = request(http.MethodPut, "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/pinnedCatalogItems/"+missing)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = request(http.MethodPut, "/cloudapi/1.0.0/vdcs/"+otherVDC.ID+"/pinnedCatalogItems/"+itemID)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Unpin a catalog item", func(t *testing.T) {
+		w := request(http.MethodDelete, pinPath)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = request(http.MethodDelete, pinPath)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		_, err := pinRepo.Get(ctx, vdc.ID, "fedora")
+		assert.Error(t, err)
+	})
+}
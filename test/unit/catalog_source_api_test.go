@@ -0,0 +1,154 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestCatalogSourceAPI(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	org := &models.Organization{Name: "source-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+	sysAdmin := &models.User{Username: "sysadmin", Email: "sysadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+	adminToken, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	tenant := &models.User{Username: "tenant", Email: "tenant@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, tenant.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(tenant).Error)
+	tenantToken, err := jwtManager.Generate(tenant.ID, tenant.Username)
+	require.NoError(t, err)
+
+	catalog := &models.Catalog{Name: "Linux Templates", OrganizationID: org.ID, OwnerID: tenant.ID, IsLocal: true}
+	require.NoError(t, db.DB.Create(catalog).Error)
+
+	doRequest := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	sourcePath := "/cloudapi/1.0.0/catalogs/" + catalog.ID + "/source"
+
+	t.Run("Catalog without a source returns not found", func(t *testing.T) {
+		w := doRequest("GET", sourcePath, "", tenantToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Only system administrators set sources", func(t *testing.T) {
+		w := doRequest("PUT", sourcePath, `{"type":"git","url":"https://git.example.com/templates.git"}`, tenantToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Invalid sources are rejected", func(t *testing.T) {
+		for _, body := range []string{
+			`{"type":"svn","url":"https://svn.example.com/templates"}`,
+			`{"type":"git","url":"file:///etc"}`,
+			`{"type":"git","url":"https://git.example.com/t.git","path":"../.."}`,
+			`{"type":"git","url":"https://git.example.com/t.git","ref":"--upload-pack=x"}`,
+			`{"type":"http","url":"https://example.com/index.yaml","ref":"main"}`,
+			`{"type":"http","url":"https://example.com/index.yaml","syncIntervalMinutes":-1}`,
+		} {
+			w := doRequest("PUT", sourcePath, body, adminToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
+	t.Run("Set source requests a sync", func(t *testing.T) {
+		w := doRequest("PUT", sourcePath, `{"type":"git","url":"https://git.example.com/templates.git","ref":"main","path":"/catalog/"}`, adminToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var source handlers.CatalogSourceResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &source))
+		assert.Equal(t, catalog.ID, source.Catalog.ID)
+		assert.Equal(t, "catalog", source.Path)
+		assert.Equal(t, models.DefaultCatalogSyncIntervalMinutes, source.SyncIntervalMinutes)
+		assert.Equal(t, models.CatalogSyncPending, source.SyncStatus)
+		assert.NotNil(t, source.SyncRequestedAt)
+
+		w = doRequest("GET", sourcePath, "", tenantToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Sync action reports the pending sync", func(t *testing.T) {
+		repo := repositories.NewCatalogSourceRepository(db.DB)
+		require.NoError(t, repo.MarkSyncing(context.Background(), catalog.ID))
+		require.NoError(t, repo.RecordSyncResult(context.Background(), catalog.ID, nil, "abc123", 3))
+
+		w := doRequest("POST", sourcePath+"/actions/sync", "", tenantToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doRequest("POST", sourcePath+"/actions/sync", "", adminToken)
+		require.Equal(t, http.StatusAccepted, w.Code)
+		var source handlers.CatalogSourceResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &source))
+		assert.Equal(t, models.CatalogSyncPending, source.SyncStatus)
+		assert.Equal(t, "abc123", source.Revision)
+		assert.Equal(t, 3, source.TemplateCount)
+		require.NotNil(t, source.SyncRequestedAt)
+
+		stored, err := repo.Get(context.Background(), catalog.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.SyncDue(time.Now()))
+	})
+
+	t.Run("Delete source", func(t *testing.T) {
+		w := doRequest("DELETE", sourcePath, "", adminToken)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = doRequest("DELETE", sourcePath, "", adminToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestCatalogSourceFetcherHTTP(t *testing.T) {
+	files := map[string]string{
+		"/catalog/index.yaml":      "templates:\n- rhel9.yaml\n- extra/list.json\n",
+		"/catalog/rhel9.yaml":      "apiVersion: template.openshift.io/v1\nkind: Template\nmetadata:\n  name: rhel9\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: ignored\n",
+		"/catalog/extra/list.json": `{"kind":"List","items":[{"apiVersion":"template.openshift.io/v1","kind":"Template","metadata":{"name":"fedora"}}]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	fetcher := services.NewCatalogSourceFetcher(5 * time.Second)
+	source := models.CatalogSource{Type: models.CatalogSourceHTTP, URL: srv.URL + "/catalog/index.yaml"}
+	fetched, err := fetcher.Fetch(context.Background(), source)
+	require.NoError(t, err)
+	require.Len(t, fetched.Templates, 2)
+	assert.Equal(t, "rhel9", fetched.Templates[0].Name)
+	assert.Equal(t, "fedora", fetched.Templates[1].Name)
+	assert.Contains(t, fetched.Revision, "sha256:")
+
+	files["/catalog/index.yaml"] = "templates:\n- missing.yaml\n"
+	_, err = fetcher.Fetch(context.Background(), source)
+	assert.Error(t, err)
+}
@@ -26,13 +26,13 @@ type MockCatalogItemRepository struct {
 	mock.Mock
 }
 
-func (m *MockCatalogItemRepository) ListByCatalogID(ctx context.Context, catalogID string, limit, offset int) ([]models.CatalogItem, error) {
-	args := m.Called(ctx, catalogID, limit, offset)
+func (m *MockCatalogItemRepository) ListByCatalogID(ctx context.Context, catalogID, filter string, limit, offset int) ([]models.CatalogItem, error) {
+	args := m.Called(ctx, catalogID, filter, limit, offset)
 	return args.Get(0).([]models.CatalogItem), args.Error(1)
 }
 
-func (m *MockCatalogItemRepository) CountByCatalogID(ctx context.Context, catalogID string) (int64, error) {
-	args := m.Called(ctx, catalogID)
+func (m *MockCatalogItemRepository) CountByCatalogID(ctx context.Context, catalogID, filter string) (int64, error) {
+	args := m.Called(ctx, catalogID, filter)
 	return args.Get(0).(int64), args.Error(1)
 }
 
@@ -71,10 +71,11 @@ func TestCatalogItemAPIEndpoints(t *testing.T) {
 
 	// Create test user with vApp User role
 	user := &models.User{
-		Username: "testuser",
-		Email:    "testuser@example.com",
-		FullName: "Test User",
-		Enabled:  true,
+		Username:       "testuser",
+		Email:          "testuser@example.com",
+		FullName:       "Test User",
+		Enabled:        true,
+		OrganizationID: stringPtr(org.ID),
 	}
 	require.NoError(t, user.SetPassword("password123"))
 	require.NoError(t, db.DB.Create(user).Error)
@@ -332,6 +333,8 @@ func TestTemplateMapper(t *testing.T) {
 		assert.False(t, catalogItem.IsExpired)
 		assert.Equal(t, "AVAILABLE", catalogItem.Status)
 		assert.Equal(t, "2024-01-15T10:30:00Z", catalogItem.CreationDate)
+		assert.Equal(t, models.CatalogItemValidationInvalid, catalogItem.ValidationStatus)
+		assert.Contains(t, catalogItem.ValidationErrors, "template contains no VirtualMachine objects")
 
 		// Check entity
 		assert.Equal(t, "test-template", catalogItem.Entity.Name)
@@ -346,4 +349,261 @@ func TestTemplateMapper(t *testing.T) {
 		assert.Equal(t, "Templates", catalogItem.Catalog.Name)
 		assert.Equal(t, catalogID, catalogItem.Catalog.ID)
 	})
+
+	t.Run("Architecture and OS metadata", func(t *testing.T) {
+		template := &templatev1.Template{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "windows-server",
+				Labels: map[string]string{
+					services.ArchitectureLabel:         "x86_64",
+					services.OSLabelPrefix + "win2k19": "true",
+					services.OSLabelPrefix + "win2k22": "true",
+					services.OSLabelPrefix + "win2k16": "false",
+				},
+			},
+		}
+
+		record := mapper.TemplateToRecord(template)
+		assert.Equal(t, "amd64", record.Architecture)
+		assert.Equal(t, "win2k22", record.OSType)
+		assert.Equal(t, services.OSFamilyWindows, record.OSFamily)
+
+		item := record.ToCatalogItem("urn:vcloud:catalog:test-catalog", "Templates")
+		assert.Equal(t, "amd64", item.Entity.Architecture)
+		assert.Equal(t, services.OSFamilyWindows, item.Entity.OSFamily)
+	})
+
+	t.Run("Architecture from VirtualMachine spec", func(t *testing.T) {
+		template := &templatev1.Template{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{services.OSLabelPrefix + "fedora": "true"},
+			},
+			Objects: []runtime.RawExtension{
+				{Raw: []byte(`{"kind": "VirtualMachine", "spec": {"template": {"spec": {"architecture": "aarch64"}}}}`)},
+			},
+		}
+		assert.Equal(t, "arm64", services.TemplateArchitecture(template))
+		osType, osFamily := services.TemplateOS(template)
+		assert.Equal(t, "fedora", osType)
+		assert.Equal(t, services.OSFamilyLinux, osFamily)
+
+		assert.Equal(t, "", services.TemplateArchitecture(&templatev1.Template{}))
+	})
+
+	t.Run("GPU count from VirtualMachine spec", func(t *testing.T) {
+		template := &templatev1.Template{
+			Objects: []runtime.RawExtension{
+				{Raw: []byte(`{"kind": "VirtualMachine", "spec": {"template": {"spec": {"domain": {"devices": {"gpus": [` +
+					`{"name": "gpu1", "deviceName": "nvidia.com/A100"}, {"name": "gpu2", "deviceName": "nvidia.com/A100"}]}}}}}}`)},
+				{Raw: []byte(`{"kind": "VirtualMachine", "spec": {"template": {"spec": {"domain": {"devices": {}}}}}}`)},
+			},
+		}
+		assert.Equal(t, 2, services.TemplateGPUCount(template))
+		assert.Equal(t, 2, mapper.TemplateToRecord(template).NumberOfGpus)
+		assert.Equal(t, 0, services.TemplateGPUCount(&templatev1.Template{}))
+	})
+
+	t.Run("AddArchitectureAffinity", func(t *testing.T) {
+		template := &templatev1.Template{
+			Objects: []runtime.RawExtension{
+				{Raw: []byte(`{"kind": "VirtualMachine", "spec": {"template": {"spec": {"domain": {"memory": {"guest": 2147483648}}}}}}`)},
+				{Raw: []byte(`{"kind": "VirtualMachine", "spec": {"template": {"spec": {"affinity": {"nodeAffinity": {"requiredDuringSchedulingIgnoredDuringExecution": {"nodeSelectorTerms": [` +
+					`{"matchExpressions": [{"key": "zone", "operator": "In", "values": ["a"]}]}, {"matchFields": [{"key": "metadata.name", "operator": "In", "values": ["node-1"]}]}]}}}}}}}`)},
+				{Raw: []byte(`{"kind": "Service", "apiVersion": "v1"}`)},
+			},
+		}
+		require.NoError(t, services.AddArchitectureAffinity(template, "arm64"))
+
+		archRequirement := map[string]interface{}{"key": "kubernetes.io/arch", "operator": "In", "values": []interface{}{"arm64"}}
+		terms := func(raw []byte) []interface{} {
+			var vm map[string]interface{}
+			require.NoError(t, json.Unmarshal(raw, &vm))
+			spec := vm["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+			affinity := spec["affinity"].(map[string]interface{})["nodeAffinity"].(map[string]interface{})
+			return affinity["requiredDuringSchedulingIgnoredDuringExecution"].(map[string]interface{})["nodeSelectorTerms"].([]interface{})
+		}
+
+		added := terms(template.Objects[0].Raw)
+		require.Len(t, added, 1)
+		assert.Equal(t, []interface{}{archRequirement}, added[0].(map[string]interface{})["matchExpressions"])
+		assert.Contains(t, string(template.Objects[0].Raw), `"guest":2147483648`)
+
+		merged := terms(template.Objects[1].Raw)
+		require.Len(t, merged, 2)
+		assert.Len(t, merged[0].(map[string]interface{})["matchExpressions"], 2)
+		assert.Equal(t, []interface{}{archRequirement}, merged[1].(map[string]interface{})["matchExpressions"])
+		assert.Contains(t, merged[1], "matchFields")
+
+		assert.JSONEq(t, `{"kind": "Service", "apiVersion": "v1"}`, string(template.Objects[2].Raw))
+	})
+	t.Run("AddSSHKeyAccessCredentials", func(t *testing.T) {
+		template := &templatev1.Template{
+			Objects: []runtime.RawExtension{
+				{Raw: []byte(`{"kind": "VirtualMachine", "spec": {"template": {"spec": {"volumes": [{"name": "cloudinitdisk", "cloudInitConfigDrive": {"userData": "#cloud-config"}}]}}}}`)},
+				{Raw: []byte(`{"kind": "VirtualMachine", "spec": {"template": {"spec": {"domain": {"devices": {"disks": [{"name": "rootdisk"}]}}, "volumes": [{"name": "rootdisk"}]}}}}`)},
+			},
+		}
+		require.NoError(t, services.AddSSHKeyAccessCredentials(template, "vm-ssh-keys"))
+
+		spec := func(raw []byte) map[string]interface{} {
+			var vm map[string]interface{}
+			require.NoError(t, json.Unmarshal(raw, &vm))
+			return vm["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+		}
+		credential := func(propagation string) []interface{} {
+			return []interface{}{map[string]interface{}{"sshPublicKey": map[string]interface{}{
+				"source":            map[string]interface{}{"secret": map[string]interface{}{"secretName": "vm-ssh-keys"}},
+				"propagationMethod": map[string]interface{}{propagation: map[string]interface{}{}},
+			}}}
+		}
+
+		configDrive := spec(template.Objects[0].Raw)
+		assert.Equal(t, credential("configDrive"), configDrive["accessCredentials"])
+		assert.Len(t, configDrive["volumes"], 1)
+
+		noCloud := spec(template.Objects[1].Raw)
+		assert.Equal(t, credential("noCloud"), noCloud["accessCredentials"])
+		volumes := noCloud["volumes"].([]interface{})
+		require.Len(t, volumes, 2)
+		assert.Contains(t, volumes[1], "cloudInitNoCloud")
+		disks := noCloud["domain"].(map[string]interface{})["devices"].(map[string]interface{})["disks"].([]interface{})
+		require.Len(t, disks, 2)
+		assert.Equal(t, volumes[1].(map[string]interface{})["name"], disks[1].(map[string]interface{})["name"])
+	})
+
+	t.Run("ConfigureNetworkInterfaces", func(t *testing.T) {
+		newTemplate := func() *templatev1.Template {
+			return &templatev1.Template{
+				Objects: []runtime.RawExtension{
+					{Raw: []byte(`{"kind": "VirtualMachine", "spec": {"template": {"spec": {"domain": {"devices": {"interfaces": [` +
+						`{"name": "default", "masquerade": {}}, {"name": "data", "bridge": {}, "model": "virtio"}]}}}}}}`)},
+				},
+			}
+		}
+
+		template := newTemplate()
+		require.NoError(t, services.ConfigureNetworkInterfaces(template, []services.NetworkInterface{
+			{Name: "default", MACAddress: "02:00:00:00:00:01"},
+			{Name: "data", InterfaceType: "sriov"},
+		}))
+
+		var vm map[string]interface{}
+		require.NoError(t, json.Unmarshal(template.Objects[0].Raw, &vm))
+		devices := vm["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["domain"].(map[string]interface{})["devices"].(map[string]interface{})
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"name": "default", "masquerade": map[string]interface{}{}, "macAddress": "02:00:00:00:00:01"},
+			map[string]interface{}{"name": "data", "sriov": map[string]interface{}{}, "model": "virtio"},
+		}, devices["interfaces"])
+
+		err := services.ConfigureNetworkInterfaces(newTemplate(), []services.NetworkInterface{{Name: "missing", MACAddress: "02:00:00:00:00:02"}})
+		assert.ErrorContains(t, err, `no network interface named "missing"`)
+	})
+
+	t.Run("ConfigureDisks", func(t *testing.T) {
+		newTemplate := func() *templatev1.Template {
+			return &templatev1.Template{
+				Objects: []runtime.RawExtension{
+					{Raw: []byte(`{"kind": "VirtualMachine", "metadata": {"name": "${NAME}"}, "spec": {` +
+						`"dataVolumeTemplates": [` +
+						`{"metadata": {"name": "${NAME}-root"}, "spec": {"sourceRef": {"kind": "DataSource", "name": "rhel9"}, "storage": {"resources": {"requests": {"storage": "30Gi"}}}}},` +
+						`{"metadata": {"name": "${NAME}-data"}, "spec": {"source": {"blank": {}}, "storage": {"resources": {"requests": {"storage": "10Gi"}}}}}],` +
+						`"template": {"spec": {"volumes": [` +
+						`{"name": "rootdisk", "dataVolume": {"name": "${NAME}-root"}},` +
+						`{"name": "datadisk", "dataVolume": {"name": "${NAME}-data"}},` +
+						`{"name": "scratch", "emptyDisk": {"capacity": "5Gi"}},` +
+						`{"name": "tools", "containerDisk": {"image": "quay.io/example/tools:latest"}},` +
+						`{"name": "cloudinitdisk", "cloudInitNoCloud": {"userData": "#cloud-config"}}]}}}}`)},
+				},
+			}
+		}
+		decode := func(template *templatev1.Template) (map[string]interface{}, []interface{}) {
+			var vm map[string]interface{}
+			require.NoError(t, json.Unmarshal(template.Objects[0].Raw, &vm))
+			spec := vm["spec"].(map[string]interface{})
+			volumes := spec["template"].(map[string]interface{})["spec"].(map[string]interface{})["volumes"].([]interface{})
+			dataVolumes := map[string]interface{}{}
+			for _, dv := range spec["dataVolumeTemplates"].([]interface{}) {
+				dvMap := dv.(map[string]interface{})
+				dataVolumes[dvMap["metadata"].(map[string]interface{})["name"].(string)] = dvMap["spec"]
+			}
+			return dataVolumes, volumes
+		}
+
+		// Requested disks change tier; the default profile places the others
+		template := newTemplate()
+		require.NoError(t, services.ConfigureDisks(template, []services.Disk{
+			{Name: "datadisk", Tier: "ephemeral"},
+			{Name: "scratch", Tier: "persistent", StorageClass: "fast-ssd"},
+		}, services.DiskDefaults{StorageClass: "standard"}))
+
+		dataVolumes, volumes := decode(template)
+		assert.Equal(t, map[string]interface{}{"name": "datadisk", "emptyDisk": map[string]interface{}{"capacity": "10Gi"}}, volumes[1])
+		assert.Equal(t, map[string]interface{}{"name": "scratch", "dataVolume": map[string]interface{}{"name": "${NAME}-scratch"}}, volumes[2])
+		assert.Contains(t, volumes[4], "cloudInitNoCloud")
+		require.Len(t, dataVolumes, 2)
+		assert.NotContains(t, dataVolumes, "${NAME}-data")
+		assert.Equal(t, "standard", dataVolumes["${NAME}-root"].(map[string]interface{})["storage"].(map[string]interface{})["storageClassName"])
+		scratch := dataVolumes["${NAME}-scratch"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"blank": map[string]interface{}{}}, scratch["source"])
+		assert.Equal(t, "fast-ssd", scratch["storage"].(map[string]interface{})["storageClassName"])
+		assert.Equal(t, "5Gi", scratch["storage"].(map[string]interface{})["resources"].(map[string]interface{})["requests"].(map[string]interface{})["storage"])
+
+		// An ephemeral default tier skips disks that cannot be ephemeral
+		template = newTemplate()
+		require.NoError(t, services.ConfigureDisks(template, nil, services.DiskDefaults{Tier: "ephemeral"}))
+		dataVolumes, volumes = decode(template)
+		assert.Contains(t, volumes[0], "dataVolume")
+		assert.Contains(t, volumes[1], "emptyDisk")
+		assert.Len(t, dataVolumes, 1)
+
+		err := services.ConfigureDisks(newTemplate(), []services.Disk{{Name: "rootdisk", Tier: "ephemeral"}}, services.DiskDefaults{})
+		assert.ErrorContains(t, err, `disk "rootdisk" cannot be made ephemeral`)
+		err = services.ConfigureDisks(newTemplate(), []services.Disk{{Name: "tools", StorageClass: "fast-ssd"}}, services.DiskDefaults{})
+		assert.ErrorContains(t, err, "it is ephemeral")
+		err = services.ConfigureDisks(newTemplate(), []services.Disk{{Name: "missing", Tier: "persistent"}}, services.DiskDefaults{})
+		assert.ErrorContains(t, err, `no disk named "missing"`)
+	})
+
+	t.Run("UseGoldenImages", func(t *testing.T) {
+		newTemplate := func() *templatev1.Template {
+			return &templatev1.Template{
+				Objects: []runtime.RawExtension{
+					{Raw: []byte(`{"kind": "VirtualMachine", "metadata": {"name": "${NAME}"}, "spec": {` +
+						`"dataVolumeTemplates": [` +
+						`{"metadata": {"name": "${NAME}-root"}, "spec": {"source": {"registry": {"url": "docker://quay.io/containerdisks/fedora:40"}}, "storage": {"resources": {"requests": {"storage": "30Gi"}}}}},` +
+						`{"metadata": {"name": "${NAME}-iso"}, "spec": {"source": {"http": {"url": "https://images.example.com/tools.iso"}}, "storage": {"resources": {"requests": {"storage": "1Gi"}}}}},` +
+						`{"metadata": {"name": "${NAME}-data"}, "spec": {"source": {"blank": {}}, "storage": {"resources": {"requests": {"storage": "10Gi"}}}}}]}}`)},
+				},
+			}
+		}
+		sources := func(template *templatev1.Template) map[string]interface{} {
+			var vm map[string]interface{}
+			require.NoError(t, json.Unmarshal(template.Objects[0].Raw, &vm))
+			result := map[string]interface{}{}
+			for _, dv := range vm["spec"].(map[string]interface{})["dataVolumeTemplates"].([]interface{}) {
+				dvMap := dv.(map[string]interface{})
+				result[dvMap["metadata"].(map[string]interface{})["name"].(string)] = dvMap["spec"].(map[string]interface{})["source"]
+			}
+			return result
+		}
+
+		images, err := services.TemplateGoldenImages(newTemplate())
+		require.NoError(t, err)
+		require.Len(t, images, 2, "blank DataVolumes have no image")
+		assert.Equal(t, "30Gi", images[0].Size)
+		assert.Equal(t, "https://images.example.com/tools.iso", images[1].Source.HTTP.URL)
+
+		// Only the ready image is cloned
+		template := newTemplate()
+		require.NoError(t, services.UseGoldenImages(template, "vdc-ns", []string{images[0].Name}))
+		result := sources(template)
+		assert.Equal(t, map[string]interface{}{"pvc": map[string]interface{}{"namespace": "vdc-ns", "name": images[0].Name}}, result["${NAME}-root"])
+		assert.Contains(t, result["${NAME}-iso"], "http")
+		assert.Contains(t, result["${NAME}-data"], "blank")
+
+		// Images are the same in every Template importing them
+		again, err := services.TemplateGoldenImages(newTemplate())
+		require.NoError(t, err)
+		assert.Equal(t, images[0].Name, again[0].Name)
+	})
 }
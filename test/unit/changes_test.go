@@ -0,0 +1,120 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestChangesAPI(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+	sysAdmin := &models.User{Username: "changesadmin", Email: "changesadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+	adminToken, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	user := &models.User{Username: "changesuser", Email: "changesuser@example.com", Enabled: true}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	userToken, err := jwtManager.Generate(user.ID, user.Username)
+	require.NoError(t, err)
+
+	org := &models.Organization{Name: "changes-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	vdc := &models.VDC{Name: "changes-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+
+	request := func(token string, query url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/changes?"+query.Encode(), nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func(query url.Values) handlers.ChangesResponse {
+		w := request(adminToken, query)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response handlers.ChangesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	// syncAll follows the cursor until no changes remain
+	syncAll := func(cursor string) (map[string]models.EntityChange, string) {
+		changes := map[string]models.EntityChange{}
+		for {
+			response := list(url.Values{"since": {cursor}, "pageSize": {"2"}})
+			assert.LessOrEqual(t, len(response.Values), 2)
+			for _, change := range response.Values {
+				_, seen := changes[change.URN]
+				assert.False(t, seen, "%s listed twice", change.URN)
+				changes[change.URN] = change
+			}
+			cursor = response.NextCursor
+			if !response.HasMore {
+				return changes, cursor
+			}
+		}
+	}
+
+	t.Run("Only system administrators list changes", func(t *testing.T) {
+		w := request(userToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Rejects an invalid since", func(t *testing.T) {
+		w := request(adminToken, url.Values{"since": {"yesterday!"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	var cursor string
+	t.Run("Initial sync lists every entity as created", func(t *testing.T) {
+		var changes map[string]models.EntityChange
+		changes, cursor = syncAll("")
+		assert.Len(t, changes, 4)
+		for _, urn := range []string{sysAdmin.ID, user.ID, org.ID, vdc.ID} {
+			assert.Equal(t, models.EntityChangeCreated, changes[urn].Operation, urn)
+		}
+		assert.Equal(t, models.EntityTypeVDC, changes[vdc.ID].Type)
+		assert.Equal(t, models.EntityTypeOrg, changes[org.ID].Type)
+
+		// Nothing changed since
+		assert.Empty(t, list(url.Values{"since": {cursor}}).Values)
+	})
+
+	t.Run("Incremental sync reports updates and deletions", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, db.DB.Model(org).Update("description", "renamed").Error)
+		require.NoError(t, db.DB.Delete(vdc).Error)
+
+		changes, next := syncAll(cursor)
+		assert.Len(t, changes, 2)
+		assert.Equal(t, models.EntityChangeUpdated, changes[org.ID].Operation)
+		assert.Equal(t, models.EntityChangeDeleted, changes[vdc.ID].Operation)
+		assert.Empty(t, list(url.Values{"since": {next}}).Values)
+	})
+
+	t.Run("Accepts a timestamp", func(t *testing.T) {
+		future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		response := list(url.Values{"since": {future}})
+		assert.Empty(t, response.Values)
+		assert.NotEmpty(t, response.NextCursor)
+
+		past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		assert.Len(t, list(url.Values{"since": {past}}).Values, 4)
+	})
+}
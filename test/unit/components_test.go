@@ -0,0 +1,107 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+func TestComponentsEndpoint(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+	repo := repositories.NewComponentHeartbeatRepository(db.DB)
+	ctx := context.Background()
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+	sysAdmin := &models.User{Username: "sysadmin", Email: "sysadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+	adminToken, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	user := &models.User{Username: "plainuser", Email: "plainuser@example.com", Enabled: true}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	userToken, err := jwtManager.Generate(user.ID, user.Username)
+	require.NoError(t, err)
+
+	now := time.Now()
+	leader := &models.ComponentHeartbeat{
+		InstanceID: "vm-controller-a", Component: "vm-controller", Version: "v1.2.3", Leader: true,
+		IntervalSeconds: 30, StartedAt: now.Add(-time.Hour), LastHeartbeat: now,
+	}
+	lastSuccess := now.Add(-time.Minute)
+	leader.SetControllers([]models.ControllerHeartbeat{{Name: "ssvirt_vmstatus", LastReconcile: &lastSuccess, LastSuccess: &lastSuccess}})
+	require.NoError(t, repo.Upsert(ctx, leader))
+
+	// A replica that stopped reporting, such as one whose pod was evicted
+	stopped := &models.ComponentHeartbeat{
+		InstanceID: "vm-controller-b", Component: "vm-controller", Version: "v1.2.2",
+		IntervalSeconds: 30, StartedAt: now.Add(-2 * time.Hour), LastHeartbeat: now.Add(-5 * time.Minute),
+	}
+	require.NoError(t, repo.Upsert(ctx, stopped))
+
+	// Heartbeats replace the previous one of the same instance
+	leader.LastHeartbeat = now.Add(time.Second)
+	require.NoError(t, repo.Upsert(ctx, leader))
+
+	list := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/system/components", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("System administrators see every replica", func(t *testing.T) {
+		w := list(adminToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response handlers.ComponentsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.APIServer.Version)
+		require.Len(t, response.Components, 2)
+
+		a, b := response.Components[0], response.Components[1]
+		assert.Equal(t, "vm-controller-a", a.InstanceID)
+		assert.Equal(t, "v1.2.3", a.Version)
+		assert.True(t, a.Leader)
+		assert.True(t, a.Alive)
+		require.Len(t, a.Controllers, 1)
+		assert.Equal(t, "ssvirt_vmstatus", a.Controllers[0].Name)
+		require.NotNil(t, a.Controllers[0].LastSuccess)
+		assert.WithinDuration(t, lastSuccess, *a.Controllers[0].LastSuccess, time.Second)
+
+		assert.Equal(t, "vm-controller-b", b.InstanceID)
+		assert.False(t, b.Leader)
+		assert.False(t, b.Alive)
+		assert.Empty(t, b.Controllers)
+	})
+
+	t.Run("Other users are refused", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, list(userToken).Code)
+	})
+
+	t.Run("Heartbeats of departed replicas expire", func(t *testing.T) {
+		removed, err := repo.DeleteBefore(ctx, now.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), removed)
+
+		heartbeats, err := repo.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, heartbeats, 1)
+		assert.Equal(t, "vm-controller-a", heartbeats[0].InstanceID)
+	})
+}
@@ -1,22 +1,30 @@
 package unit
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/database"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
 	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	domainerrors "github.com/mhrivnak/ssvirt/pkg/domain/errors"
 )
 
 func setupTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	require.NoError(t, err)
+	db := openTestDB(t)
 
-	err = db.AutoMigrate(
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.Organization{},
 		&models.Role{},
@@ -25,12 +33,94 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&models.VAppTemplate{},
 		&models.VApp{},
 		&models.VM{},
+		&models.Task{},
+		&models.CatalogItemRecord{},
+		&models.CatalogAccessControl{},
+		&models.SSHKey{},
+		&models.VDCStorageProfile{},
+		&models.OrgSequence{},
 	)
 	require.NoError(t, err)
 
 	return db
 }
 
+func TestNewConnectionDrivers(t *testing.T) {
+	t.Run("SQLite file database", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Database.Driver = database.DriverSQLite
+		cfg.Database.Path = filepath.Join(t.TempDir(), "ssvirt.db")
+
+		db, err := database.NewConnection(cfg)
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		require.NoError(t, db.AutoMigrate())
+		require.NoError(t, db.BootstrapDefaultData())
+
+		org, err := repositories.NewOrganizationRepository(db.DB).GetByName(models.DefaultOrgName)
+		require.NoError(t, err)
+		assert.Equal(t, models.DefaultOrgName, org.Name)
+	})
+
+	t.Run("unsupported driver fails without retrying", func(t *testing.T) {
+		cfg := &config.Config{}
+		cfg.Database.Driver = "mysql"
+		cfg.Database.Retry.MaxAttempts = 5
+		cfg.Database.Retry.InitialDelay = time.Minute
+
+		_, err := database.NewConnectionWithRetry(context.Background(), cfg, database.RetryConfigFromConfig(cfg))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unsupported driver "mysql"`)
+	})
+}
+
+func TestDatabaseMetrics(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Database.Driver = database.DriverSQLite
+	cfg.Database.Path = ":memory:"
+	cfg.Database.LogLevel = "silent"
+	cfg.Database.SlowQueryThreshold = time.Nanosecond
+
+	db, err := database.NewConnection(cfg)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, db.RegisterMetrics(registry))
+
+	metricValue := func(name string) float64 {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != name {
+				continue
+			}
+			metric := family.GetMetric()[0]
+			if metric.GetCounter() != nil {
+				return metric.GetCounter().GetValue()
+			}
+			return metric.GetGauge().GetValue()
+		}
+		t.Fatalf("metric %s not registered", name)
+		return 0
+	}
+
+	before := metricValue("ssvirt_db_slow_queries_total")
+	require.NoError(t, db.Exec("SELECT 1").Error)
+	assert.Greater(t, metricValue("ssvirt_db_slow_queries_total"), before)
+
+	assert.Equal(t, float64(1), metricValue("go_sql_open_connections"))
+	assert.Equal(t, float64(1), metricValue("go_sql_idle_connections"))
+	assert.Equal(t, float64(0), metricValue("go_sql_in_use_connections"))
+
+	t.Run("rejects unknown log levels", func(t *testing.T) {
+		cfg.Database.LogLevel = "verbose"
+		_, err := database.NewConnection(cfg)
+		assert.ErrorContains(t, err, `unsupported log level "verbose"`)
+	})
+}
+
 func TestOrganizationRepository(t *testing.T) {
 	db := setupTestDB(t)
 	repo := repositories.NewOrganizationRepository(db)
@@ -110,6 +200,122 @@ func TestVDCRepository(t *testing.T) {
 	assert.Len(t, vdcs, 1)
 }
 
+func TestVDCStorageProfiles(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	vdcRepo := repositories.NewVDCRepository(db)
+
+	org := &models.Organization{Name: "storage-org"}
+	require.NoError(t, db.Create(org).Error)
+	vdc := &models.VDC{Name: "storage-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo}
+	require.NoError(t, vdcRepo.Create(vdc))
+
+	require.NoError(t, vdcRepo.SetStorageProfileLimits(ctx, vdc.ID, map[string]int64{"fast": 1000, "slow": 5000}))
+
+	// Usage is recorded for configured profiles and unconfigured classes in use
+	profiles, err := vdcRepo.UpdateStorageUsage(ctx, vdc.ID, map[string]int64{"fast": 850, "other": 10})
+	require.NoError(t, err)
+	require.Len(t, profiles, 3)
+	assert.Equal(t, "fast", profiles[0].Name)
+	assert.Equal(t, int64(850), profiles[0].UsedMB)
+	assert.Equal(t, models.StorageAlertWarning, profiles[0].UsageAlert(80, 95))
+	assert.Equal(t, "other", profiles[1].Name)
+	assert.Equal(t, int64(0), profiles[1].LimitMB)
+	assert.Equal(t, "", profiles[1].UsageAlert(80, 95))
+	assert.Equal(t, int64(0), profiles[2].UsedMB)
+
+	require.NoError(t, vdcRepo.SetStorageAlertLevel(ctx, profiles[0].ID, models.StorageAlertWarning))
+
+	// Replacing limits keeps the usage and alert level of retained profiles
+	require.NoError(t, vdcRepo.SetStorageProfileLimits(ctx, vdc.ID, map[string]int64{"fast": 2000}))
+	require.NoError(t, vdcRepo.LoadStorageProfiles(ctx, vdc))
+	require.Len(t, vdc.StorageProfiles, 1)
+	assert.Equal(t, int64(2000), vdc.StorageProfiles[0].LimitMB)
+	assert.Equal(t, int64(850), vdc.StorageProfiles[0].UsedMB)
+	assert.Equal(t, models.StorageAlertWarning, vdc.StorageProfiles[0].AlertLevel)
+
+	reported := vdc.VdcStorageProfiles().VdcStorageProfile
+	require.Len(t, reported, 1)
+	assert.Equal(t, 42, reported[0].UsagePercent)
+	assert.Empty(t, reported[0].UsageAlert)
+	assert.Nil(t, vdc.DefaultStorageProfile())
+
+	// The default profile and its disk tier can move between profiles
+	require.NoError(t, vdcRepo.SetStorageProfiles(ctx, vdc.ID, []models.VDCStorageProfile{
+		{Name: "fast", LimitMB: 2000},
+		{Name: "scratch", IsDefault: true, DiskTier: models.DiskTierEphemeral},
+	}))
+	require.NoError(t, vdcRepo.LoadStorageProfiles(ctx, vdc))
+	require.NotNil(t, vdc.DefaultStorageProfile())
+	assert.Equal(t, "scratch", vdc.DefaultStorageProfile().Name)
+	assert.Equal(t, models.DiskTierEphemeral, vdc.DefaultStorageProfile().DiskTier)
+
+	require.NoError(t, vdcRepo.SetStorageProfiles(ctx, vdc.ID, []models.VDCStorageProfile{
+		{Name: "fast", LimitMB: 2000, IsDefault: true},
+		{Name: "scratch"},
+	}))
+	require.NoError(t, vdcRepo.LoadStorageProfiles(ctx, vdc))
+	assert.Equal(t, "fast", vdc.DefaultStorageProfile().Name)
+	assert.Empty(t, vdc.DefaultStorageProfile().DiskTier)
+	assert.Equal(t, int64(850), vdc.DefaultStorageProfile().UsedMB)
+	assert.False(t, vdc.StorageProfiles[1].IsDefault)
+}
+
+func TestSeedProfiles(t *testing.T) {
+	assert.Equal(t, []string{"demo", "e2e"}, database.SeedProfileNames())
+
+	for _, name := range database.SeedProfileNames() {
+		t.Run(name, func(t *testing.T) {
+			db := &database.DB{DB: setupTestDB(t)}
+			profile, ok := database.GetSeedProfile(name)
+			require.True(t, ok)
+
+			result, err := db.Seed(context.Background(), name, "seed-password")
+			require.NoError(t, err)
+			assert.Equal(t, len(profile.Organizations), result.Organizations)
+			assert.NotZero(t, result.Users)
+			assert.NotZero(t, result.VDCs)
+			assert.NotZero(t, result.Catalogs)
+			assert.NotZero(t, result.VApps)
+			assert.NotZero(t, result.VMs)
+
+			var vmCount int64
+			require.NoError(t, db.Model(&models.VM{}).Count(&vmCount).Error)
+			assert.Equal(t, int64(result.VMs), vmCount)
+
+			// Seeding again reuses the existing records
+			again, err := db.Seed(context.Background(), name, "seed-password")
+			require.NoError(t, err)
+			assert.Equal(t, database.SeedResult{}, *again)
+
+			userRepo := repositories.NewUserRepository(db.DB)
+			admin, err := userRepo.GetByUsername(profile.SystemAdmins[0])
+			require.NoError(t, err)
+			assert.True(t, admin.CheckPassword("seed-password"))
+			admin, err = userRepo.GetWithRoles(admin.ID)
+			require.NoError(t, err)
+			require.Len(t, admin.Roles, 1)
+			assert.Equal(t, models.RoleSystemAdmin, admin.Roles[0].Name)
+
+			seededOrg := profile.Organizations[0]
+			orgUser, err := userRepo.GetByUsername(seededOrg.Users[0].Username)
+			require.NoError(t, err)
+			require.NotNil(t, orgUser.OrganizationID)
+			org, err := repositories.NewOrganizationRepository(db.DB).GetByID(*orgUser.OrganizationID)
+			require.NoError(t, err)
+			assert.Equal(t, seededOrg.Name, org.Name)
+		})
+	}
+
+	t.Run("rejects unknown profiles and empty passwords", func(t *testing.T) {
+		db := &database.DB{DB: setupTestDB(t)}
+		_, err := db.Seed(context.Background(), "staging", "seed-password")
+		assert.Error(t, err)
+		_, err = db.Seed(context.Background(), "e2e", "")
+		assert.Error(t, err)
+	})
+}
+
 func TestCatalogRepository(t *testing.T) {
 	db := setupTestDB(t)
 	orgRepo := repositories.NewOrganizationRepository(db)
@@ -180,3 +386,373 @@ func TestCatalogRepository(t *testing.T) {
 	_, err = catalogRepo.GetByID(catalog.ID)
 	assert.Error(t, err)
 }
+
+func TestVAppRepositoryListWithVMCounts(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repositories.NewVAppRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "vapp-count-org"}
+	require.NoError(t, db.Create(org).Error)
+	vdc := &models.VDC{Name: "vapp-count-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo}
+	require.NoError(t, db.Create(vdc).Error)
+	otherVDC := &models.VDC{Name: "other-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo}
+	require.NoError(t, db.Create(otherVDC).Error)
+
+	web := &models.VApp{Name: "web-frontend", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	db1 := &models.VApp{Name: "Database", VDCID: vdc.ID, Status: models.VAppStatusFailed}
+	empty := &models.VApp{Name: "empty-app", VDCID: vdc.ID, Status: models.VAppStatusInstantiating}
+	elsewhere := &models.VApp{Name: "web-elsewhere", VDCID: otherVDC.ID, Status: models.VAppStatusDeployed}
+	for _, vapp := range []*models.VApp{web, db1, empty, elsewhere} {
+		require.NoError(t, db.Create(vapp).Error)
+	}
+
+	createVM := func(vappID, name string) *models.VM {
+		vm := &models.VM{Name: name, VMName: name, Namespace: "ns", VAppID: vappID, Status: "POWERED_ON"}
+		require.NoError(t, db.Create(vm).Error)
+		return vm
+	}
+	createVM(web.ID, "web-1")
+	createVM(web.ID, "web-2")
+	deleted := createVM(web.ID, "web-3")
+	require.NoError(t, db.Delete(deleted).Error)
+	createVM(db1.ID, "db-1")
+	createVM(elsewhere.ID, "web-elsewhere-1")
+
+	counts := func(summaries []repositories.VAppSummary) map[string]int {
+		result := make(map[string]int, len(summaries))
+		for _, summary := range summaries {
+			result[summary.Name] = summary.NumberOfVMs
+		}
+		return result
+	}
+
+	t.Run("counts live VMs per vApp", func(t *testing.T) {
+		summaries, err := repo.ListByVDCWithPagination(ctx, vdc.ID, 25, 0, repositories.VAppListFilter{}, "name ASC")
+		require.NoError(t, err)
+		require.Len(t, summaries, 3)
+		assert.Equal(t, map[string]int{"Database": 1, "empty-app": 0, "web-frontend": 2}, counts(summaries))
+		assert.Equal(t, "Database", summaries[0].Name)
+		assert.Equal(t, vdc.ID, summaries[0].VDCID)
+	})
+
+	t.Run("filters by status", func(t *testing.T) {
+		filter := repositories.VAppListFilter{Statuses: []string{models.VAppStatusDeployed, models.VAppStatusFailed}}
+		summaries, err := repo.ListByVDCWithPagination(ctx, vdc.ID, 25, 0, filter, "")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"Database": 1, "web-frontend": 2}, counts(summaries))
+
+		count, err := repo.CountByVDC(ctx, vdc.ID, filter)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("searches names case-insensitively", func(t *testing.T) {
+		filter := repositories.VAppListFilter{NameSearch: "DATA"}
+		summaries, err := repo.ListByVDCWithPagination(ctx, vdc.ID, 25, 0, filter, "")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"Database": 1}, counts(summaries))
+	})
+
+	t.Run("combines filter expression with status", func(t *testing.T) {
+		filter := repositories.VAppListFilter{Filter: "name==web-frontend", Statuses: []string{models.VAppStatusFailed}}
+		count, err := repo.CountByVDC(ctx, vdc.ID, filter)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+
+		filter.Statuses = []string{models.VAppStatusDeployed}
+		summaries, err := repo.ListByVDCWithPagination(ctx, vdc.ID, 25, 0, filter, "status DESC")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"web-frontend": 2}, counts(summaries))
+	})
+
+	t.Run("matches filter substrings case-sensitively on every dialect", func(t *testing.T) {
+		summaries, err := repo.ListByVDCWithPagination(ctx, vdc.ID, 25, 0, repositories.VAppListFilter{Filter: "data"}, "")
+		require.NoError(t, err)
+		assert.Empty(t, summaries)
+
+		summaries, err = repo.ListByVDCWithPagination(ctx, vdc.ID, 25, 0, repositories.VAppListFilter{Filter: "Data"}, "")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"Database": 1}, counts(summaries))
+	})
+
+	t.Run("treats wildcard characters in filters literally", func(t *testing.T) {
+		count, err := repo.CountByVDC(ctx, vdc.ID, repositories.VAppListFilter{Filter: "web_frontend"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+
+		count, err = repo.CountByVDC(ctx, vdc.ID, repositories.VAppListFilter{Filter: "*"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+	})
+}
+
+func TestVMFriendlyIDs(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repositories.NewVMRepository(db)
+	ctx := context.Background()
+
+	newVApp := func(orgName string) *models.VApp {
+		org := &models.Organization{Name: orgName}
+		require.NoError(t, db.Create(org).Error)
+		vdc := &models.VDC{Name: orgName + "-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo}
+		require.NoError(t, db.Create(vdc).Error)
+		vapp := &models.VApp{Name: orgName + "-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+		require.NoError(t, db.Create(vapp).Error)
+		return vapp
+	}
+	first := newVApp("numbering-org")
+	second := newVApp("other-numbering-org")
+
+	createVM := func(vapp *models.VApp, name string) *models.VM {
+		vm := &models.VM{Name: name, VMName: name, Namespace: "ns", VAppID: vapp.ID, Status: "POWERED_OFF"}
+		require.NoError(t, repo.CreateVM(ctx, vm))
+		return vm
+	}
+
+	t.Run("VMs are numbered within their organization", func(t *testing.T) {
+		assert.Equal(t, "vm-0001", createVM(first, "a").FriendlyID)
+		assert.Equal(t, "vm-0002", createVM(first, "b").FriendlyID)
+		assert.Equal(t, "vm-0001", createVM(second, "c").FriendlyID)
+
+		stored, err := repo.GetByID(createVM(first, "d").ID)
+		require.NoError(t, err)
+		assert.Equal(t, "vm-0003", stored.FriendlyID)
+	})
+
+	t.Run("A failed creation does not consume a number", func(t *testing.T) {
+		existing := createVM(second, "e")
+		duplicate := &models.VM{ID: existing.ID, Name: "f", VAppID: second.ID}
+		assert.Error(t, repo.CreateVM(ctx, duplicate))
+		assert.Equal(t, "vm-0003", createVM(second, "g").FriendlyID)
+	})
+
+	t.Run("Concurrent creations get distinct numbers", func(t *testing.T) {
+		if os.Getenv(postgresDSNEnv) == "" {
+			t.Skip("in-memory SQLite gives each connection its own database")
+		}
+		vapp := newVApp("concurrent-numbering-org")
+		const count = 10
+		var wg sync.WaitGroup
+		ids := make([]string, count)
+		errs := make([]error, count)
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				vm := &models.VM{Name: fmt.Sprintf("vm-%d", i), VAppID: vapp.ID, Status: "POWERED_OFF"}
+				errs[i] = repo.CreateVM(ctx, vm)
+				ids[i] = vm.FriendlyID
+			}(i)
+		}
+		wg.Wait()
+		seen := make(map[string]bool, count)
+		for i := 0; i < count; i++ {
+			require.NoError(t, errs[i])
+			assert.False(t, seen[ids[i]], "duplicate friendly ID %s", ids[i])
+			seen[ids[i]] = true
+		}
+		assert.True(t, seen[models.VMFriendlyID(count)])
+	})
+}
+
+func TestVMListUpdatedSince(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repositories.NewVMRepository(db)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "poll-org"}
+	require.NoError(t, db.Create(org).Error)
+	vdc := &models.VDC{Name: "poll-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo}
+	require.NoError(t, db.Create(vdc).Error)
+	vapp := &models.VApp{Name: "poll-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.Create(vapp).Error)
+
+	// Three VMs share an update time
+	updated := time.Now().UTC().Truncate(time.Millisecond)
+	for i := 0; i < 3; i++ {
+		vm := &models.VM{Name: fmt.Sprintf("poll-vm-%d", i), VAppID: vapp.ID, Status: "POWERED_OFF"}
+		require.NoError(t, repo.CreateVM(ctx, vm))
+		require.NoError(t, db.Model(vm).UpdateColumn("updated_at", updated).Error)
+	}
+
+	var seen []string
+	since, afterID := time.Time{}, ""
+	for {
+		vms, err := repo.ListUpdatedSince(ctx, since, afterID, 2)
+		require.NoError(t, err)
+		for _, vm := range vms {
+			seen = append(seen, vm.ID)
+			since, afterID = vm.UpdatedAt, vm.ID
+			require.NotNil(t, vm.VApp)
+			assert.Equal(t, org.ID, vm.VApp.VDC.OrganizationID)
+		}
+		if len(vms) < 2 {
+			break
+		}
+	}
+	assert.Len(t, seen, 3)
+	assert.IsIncreasing(t, seen)
+}
+
+func TestCatalogItemRepository(t *testing.T) {
+	db := setupTestDB(t)
+	catalogRepo := repositories.NewCatalogRepository(db)
+	repo := repositories.NewCatalogItemRepository(db, catalogRepo)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "catalog-item-org"}
+	require.NoError(t, db.Create(org).Error)
+	catalog := &models.Catalog{Name: "Templates", OrganizationID: org.ID}
+	require.NoError(t, db.Create(catalog).Error)
+
+	records := []models.CatalogItemRecord{
+		{TemplateUID: "uid-rhel", Name: "rhel9-server", Namespace: "openshift", ResourceVersion: "1", IsPublished: true},
+		{TemplateUID: "uid-fedora", Name: "fedora-server", Namespace: "openshift", ResourceVersion: "1"},
+		{TemplateUID: "uid-centos", Name: "centos-stream9", Namespace: "openshift", ResourceVersion: "1"},
+	}
+
+	t.Run("SyncTemplates inserts, skips unchanged, updates and deletes", func(t *testing.T) {
+		changed, err := repo.SyncTemplates(ctx, records)
+		require.NoError(t, err)
+		assert.Equal(t, 3, changed)
+
+		changed, err = repo.SyncTemplates(ctx, records)
+		require.NoError(t, err)
+		assert.Equal(t, 0, changed)
+
+		updated := append([]models.CatalogItemRecord{}, records...)
+		updated[1].Description = "Fedora"
+		updated[1].ResourceVersion = "2"
+		updated = updated[:2]
+		changed, err = repo.SyncTemplates(ctx, updated)
+		require.NoError(t, err)
+		assert.Equal(t, 2, changed) // one update, one delete
+
+		var stored []models.CatalogItemRecord
+		require.NoError(t, db.Order("name").Find(&stored).Error)
+		require.Len(t, stored, 2)
+		assert.Equal(t, "Fedora", stored[0].Description)
+
+		_, err = repo.SyncTemplates(ctx, records)
+		require.NoError(t, err)
+	})
+
+	t.Run("ListByCatalogID orders by name and paginates", func(t *testing.T) {
+		items, err := repo.ListByCatalogID(ctx, catalog.ID, "", 2, 0)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, "centos-stream9", items[0].Name)
+		assert.Equal(t, "fedora-server", items[1].Name)
+		assert.Equal(t, catalog.ID, items[0].CatalogID)
+		assert.Equal(t, "Templates", items[0].Catalog.Name)
+
+		items, err = repo.ListByCatalogID(ctx, catalog.ID, "", 2, 2)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, "rhel9-server", items[0].Name)
+	})
+
+	t.Run("Filters by name and published state", func(t *testing.T) {
+		items, err := repo.ListByCatalogID(ctx, catalog.ID, "SERVER", 25, 0)
+		require.NoError(t, err)
+		assert.Len(t, items, 2)
+
+		count, err := repo.CountByCatalogID(ctx, catalog.ID, "name==fedora-server")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		count, err = repo.CountByCatalogID(ctx, catalog.ID, "isPublished==true")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Filters by architecture and OS family", func(t *testing.T) {
+		tagged := append([]models.CatalogItemRecord{}, records...)
+		tagged[0].Architecture = "arm64"
+		tagged[0].OSFamily = "linux"
+		tagged[0].ResourceVersion = "2"
+		_, err := repo.SyncTemplates(ctx, tagged)
+		require.NoError(t, err)
+
+		items, err := repo.ListByCatalogID(ctx, catalog.ID, "architecture==aarch64", 25, 0)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, "rhel9-server", items[0].Name)
+		assert.Equal(t, "arm64", items[0].Entity.Architecture)
+
+		count, err := repo.CountByCatalogID(ctx, catalog.ID, "osFamily==Linux")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		_, err = repo.SyncTemplates(ctx, records)
+		require.NoError(t, err)
+	})
+
+	t.Run("Rows stored without architecture and OS are backfilled", func(t *testing.T) {
+		require.NoError(t, db.Model(&models.CatalogItemRecord{}).Where("name = ?", "rhel9-server").
+			Updates(map[string]interface{}{"architecture": "", "os_type": "", "os_family": ""}).Error)
+		tagged := append([]models.CatalogItemRecord{}, records...)
+		tagged[0].Architecture = "amd64"
+		tagged[0].OSType = "rhel9"
+		tagged[0].OSFamily = "linux"
+
+		changed, err := repo.SyncTemplates(ctx, tagged)
+		require.NoError(t, err)
+		assert.Equal(t, 1, changed)
+		item, err := repo.GetByID(ctx, catalog.ID, "rhel9-server")
+		require.NoError(t, err)
+		assert.Equal(t, "amd64", item.Entity.Architecture)
+
+		_, err = repo.SyncTemplates(ctx, records)
+		require.NoError(t, err)
+	})
+
+	t.Run("Invalid templates are flagged and revalidated without a template change", func(t *testing.T) {
+		invalid := append([]models.CatalogItemRecord{}, records...)
+		invalid[2].ValidationStatus = models.CatalogItemValidationInvalid
+		invalid[2].ValidationErrors = "template contains no VirtualMachine objects\nmissing annotation description"
+		changed, err := repo.SyncTemplates(ctx, invalid)
+		require.NoError(t, err)
+		assert.Equal(t, 1, changed)
+
+		items, err := repo.ListByCatalogID(ctx, catalog.ID, "validationStatus==invalid", 25, 0)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, "centos-stream9", items[0].Name)
+		assert.Equal(t, models.CatalogItemValidationInvalid, items[0].ValidationStatus)
+		assert.Equal(t, []string{"template contains no VirtualMachine objects", "missing annotation description"}, items[0].ValidationErrors)
+
+		item, err := repo.GetByID(ctx, catalog.ID, "rhel9-server")
+		require.NoError(t, err)
+		assert.Equal(t, models.CatalogItemValidationValid, item.ValidationStatus)
+		assert.Empty(t, item.ValidationErrors)
+
+		_, err = repo.SyncTemplates(ctx, records)
+		require.NoError(t, err)
+	})
+
+	t.Run("GetByID resolves URNs and names", func(t *testing.T) {
+		item, err := repo.GetByID(ctx, catalog.ID, models.CatalogItemURN(catalog.ID, "rhel9-server"))
+		require.NoError(t, err)
+		assert.Equal(t, "rhel9-server", item.Name)
+		assert.Equal(t, models.CatalogItemURN(catalog.ID, "rhel9-server"), item.ID)
+
+		item, err = repo.GetByID(ctx, catalog.ID, "urn:vcloud:catalogitem:uid-fedora")
+		require.NoError(t, err)
+		assert.Equal(t, "fedora-server", item.Name)
+
+		item, err = repo.GetByID(ctx, catalog.ID, "centos-stream9")
+		require.NoError(t, err)
+		assert.Equal(t, "centos-stream9", item.Name)
+
+		// An item URN for a different catalog does not resolve
+		_, err = repo.GetByID(ctx, catalog.ID, models.CatalogItemURN(models.URNPrefixCatalog+"other", "rhel9-server"))
+		assert.ErrorIs(t, err, domainerrors.ErrNotFound)
+	})
+
+	t.Run("Unknown catalog returns not found", func(t *testing.T) {
+		_, err := repo.ListByCatalogID(ctx, models.URNPrefixCatalog+"missing", "", 25, 0)
+		assert.ErrorIs(t, err, domainerrors.ErrNotFound)
+	})
+}
@@ -0,0 +1,39 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerDraining(t *testing.T) {
+	server, _, _ := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	doRequest := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, doRequest("GET", "/readyz").Code)
+	assert.NotEqual(t, http.StatusServiceUnavailable, doRequest("POST", "/cloudapi/1.0.0/orgs").Code)
+
+	require.NoError(t, server.Stop(context.Background()))
+
+	w := doRequest("POST", "/cloudapi/1.0.0/orgs")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Server is shutting down")
+
+	// Reads are still served, but the replica reports it is not ready
+	assert.Equal(t, http.StatusOK, doRequest("GET", "/healthz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, doRequest("GET", "/readyz").Code)
+}
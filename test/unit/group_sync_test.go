@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	userv1 "github.com/openshift/api/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestGroupSync(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	userRepo := repositories.NewUserRepository(db.DB)
+	orgRepo := repositories.NewOrganizationRepository(db.DB)
+	roleRepo := repositories.NewRoleRepository(db.DB)
+	require.NoError(t, roleRepo.CreateDefaultRoles())
+	vappUser, err := roleRepo.GetVAppUserRole()
+	require.NoError(t, err)
+
+	engineering := &models.Organization{Name: "engineering", DisplayName: "Engineering", IsEnabled: true}
+	require.NoError(t, db.DB.Create(engineering).Error)
+	finance := &models.Organization{Name: "finance", DisplayName: "Finance", IsEnabled: true}
+	require.NoError(t, db.DB.Create(finance).Error)
+
+	newUser := func(username string, org *models.Organization, groupSynced bool, roleIDs ...string) *models.User {
+		user := &models.User{Username: username, Email: username + "@example.com", Enabled: true, GroupSynced: groupSynced}
+		if org != nil {
+			user.OrganizationID = stringPtr(org.ID)
+		}
+		require.NoError(t, user.SetPassword("password123"))
+		require.NoError(t, userRepo.CreateUserWithRoles(user, roleIDs))
+		return user
+	}
+	newUser("alice", nil, false)
+	newUser("carol", engineering, true, vappUser.ID)
+	newUser("dave", finance, true, vappUser.ID)
+	newUser("erin", nil, false)
+	newUser("manual", finance, false, vappUser.ID)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, userv1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&userv1.Group{ObjectMeta: metav1.ObjectMeta{Name: "eng-admins"}, Users: userv1.OptionalNames{"alice", "erin"}},
+		&userv1.Group{ObjectMeta: metav1.ObjectMeta{Name: "eng"}, Users: userv1.OptionalNames{"alice", "carol", "bob"}},
+		&userv1.Group{ObjectMeta: metav1.ObjectMeta{Name: "fin"}, Users: userv1.OptionalNames{"erin"}},
+	).Build()
+
+	sync := services.NewGroupSyncService(k8sClient, userRepo, orgRepo, roleRepo, []config.GroupMappingConfig{
+		{Group: "eng-admins", Organization: "engineering", Roles: []string{models.RoleOrgAdmin}},
+		{Group: "eng", Organization: "engineering", Roles: []string{models.RoleVAppUser}},
+		{Group: "fin", Organization: "finance", Roles: []string{models.RoleVAppUser}},
+		{Group: "missing", Organization: "engineering"},
+	})
+
+	changes := func(report *services.GroupSyncReport) map[string]services.GroupSyncChange {
+		byUser := make(map[string]services.GroupSyncChange)
+		for _, change := range report.Changes {
+			byUser[change.Username] = change
+		}
+		return byUser
+	}
+
+	t.Run("Plan reports changes without making them", func(t *testing.T) {
+		report, err := sync.Plan(context.Background())
+		require.NoError(t, err)
+
+		byUser := changes(report)
+		require.Len(t, byUser, 2)
+		assert.Equal(t, services.GroupSyncAdd, byUser["alice"].Action)
+		assert.Equal(t, "engineering", byUser["alice"].ToOrganization)
+		assert.Equal(t, []string{models.RoleOrgAdmin, models.RoleVAppUser}, byUser["alice"].ToRoles)
+		assert.Equal(t, services.GroupSyncRemove, byUser["dave"].Action)
+		assert.Equal(t, "finance", byUser["dave"].FromOrganization)
+		assert.Empty(t, byUser["dave"].ToRoles)
+
+		assert.Equal(t, 1, report.UnchangedUsers)
+		assert.Equal(t, []string{"bob"}, report.UnknownUsers)
+		assert.Equal(t, []services.GroupSyncConflict{{Username: "erin", Organizations: []string{"engineering", "finance"}}}, report.Conflicts)
+		assert.Equal(t, []string{"Group missing does not exist"}, report.Warnings)
+
+		alice, err := userRepo.GetByUsername("alice")
+		require.NoError(t, err)
+		assert.Nil(t, alice.OrganizationID)
+		assert.False(t, alice.GroupSynced)
+	})
+
+	t.Run("Sync applies the changes", func(t *testing.T) {
+		report, err := sync.Sync(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, report.Errors)
+
+		alice, err := userRepo.GetByUsername("alice")
+		require.NoError(t, err)
+		alice, err = userRepo.GetWithRoles(alice.ID)
+		require.NoError(t, err)
+		require.NotNil(t, alice.OrganizationID)
+		assert.Equal(t, engineering.ID, *alice.OrganizationID)
+		assert.Equal(t, "engineering", alice.OrganizationName)
+		assert.True(t, alice.GroupSynced)
+		assert.Len(t, alice.Roles, 2)
+
+		dave, err := userRepo.GetByUsername("dave")
+		require.NoError(t, err)
+		dave, err = userRepo.GetWithRoles(dave.ID)
+		require.NoError(t, err)
+		assert.Nil(t, dave.OrganizationID)
+		assert.False(t, dave.GroupSynced)
+		assert.Empty(t, dave.Roles)
+
+		manual, err := userRepo.GetByUsername("manual")
+		require.NoError(t, err)
+		require.NotNil(t, manual.OrganizationID)
+		assert.Equal(t, finance.ID, *manual.OrganizationID)
+
+		report, err = sync.Plan(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, report.Changes)
+		assert.Equal(t, 2, report.UnchangedUsers)
+	})
+
+	t.Run("Members of mappings naming a missing organization are kept", func(t *testing.T) {
+		broken := services.NewGroupSyncService(k8sClient, userRepo, orgRepo, roleRepo, []config.GroupMappingConfig{
+			{Group: "eng", Organization: "renamed", Roles: []string{models.RoleVAppUser}},
+		})
+		report, err := broken.Plan(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, report.Changes, "synced members of eng are not removed")
+		assert.Equal(t, 2, report.UnchangedUsers)
+		assert.Equal(t, []string{"organization renamed of Group eng does not exist"}, report.Warnings)
+	})
+
+	t.Run("Report endpoint", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/api/admin/groupSync/report", handlers.NewGroupSyncHandlers(sync).GetGroupSyncReport)
+		router.GET("/unavailable", handlers.NewGroupSyncHandlers(nil).GetGroupSyncReport)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/admin/groupSync/report", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var report map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, []interface{}{}, report["changes"])
+		assert.Equal(t, []interface{}{"bob"}, report["unknownUsers"])
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/unavailable", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
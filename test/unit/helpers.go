@@ -1,6 +1,58 @@
 package unit
 
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// postgresDSNEnv names the variable that runs the database-backed tests against
+// PostgreSQL instead of in-memory SQLite, e.g.
+// "host=localhost user=postgres password=postgres dbname=ssvirt_test sslmode=disable"
+const postgresDSNEnv = "SSVIRT_TEST_POSTGRES_DSN"
+
 // Helper function to create string pointer
 func stringPtr(s string) *string {
 	return &s
 }
+
+// openTestDB returns an empty database for one test: in-memory SQLite by default,
+// or a throwaway schema in PostgreSQL when SSVIRT_TEST_POSTGRES_DSN is set
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		return db
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	require.NoError(t, admin.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)).Error)
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), &gorm.Config{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+		if err := admin.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema)).Error; err != nil {
+			t.Logf("Failed to drop test schema %s: %v", schema, err)
+		}
+		if sqlDB, err := admin.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestIdempotentCreation(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	adminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(adminRole).Error)
+	admin := &models.User{Username: "iac-admin", Email: "iac-admin@example.com", Enabled: true}
+	require.NoError(t, admin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(admin).Error)
+	require.NoError(t, db.DB.Model(admin).Association("Roles").Append(adminRole))
+
+	token, err := jwtManager.Generate(admin.ID, admin.Username)
+	require.NoError(t, err)
+
+	doRequest := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var org models.Organization
+	t.Run("Retried organization create returns the same organization", func(t *testing.T) {
+		body := map[string]interface{}{"name": "iac-org", "externalId": "tf-org-1"}
+		w := doRequest("POST", "/cloudapi/1.0.0/orgs", body)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+		require.NotNil(t, org.ExternalID)
+		assert.Equal(t, "tf-org-1", *org.ExternalID)
+
+		w = doRequest("POST", "/cloudapi/1.0.0/orgs", body)
+		require.Equal(t, http.StatusOK, w.Code)
+		var retried models.Organization
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &retried))
+		assert.Equal(t, org.ID, retried.ID)
+
+		var count int64
+		require.NoError(t, db.DB.Model(&models.Organization{}).Where("name = ?", "iac-org").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Organization external ID cannot be reused for another name", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/orgs", map[string]interface{}{"name": "other-org", "externalId": "tf-org-1"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Duplicate name without external ID still conflicts", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/orgs", map[string]interface{}{"name": "iac-org"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Look up organizations by name and external ID", func(t *testing.T) {
+		require.NoError(t, db.DB.Create(&models.Organization{Name: "unrelated-org", IsEnabled: true}).Error)
+
+		for _, query := range []string{"name=iac-org", "externalId=tf-org-1"} {
+			w := doRequest("GET", "/cloudapi/1.0.0/orgs?"+query, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+			var page types.Page[models.Organization]
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			assert.Equal(t, int64(1), page.ResultTotal, query)
+			require.Len(t, page.Values, 1, query)
+			assert.Equal(t, org.ID, page.Values[0].ID, query)
+		}
+
+		w := doRequest("GET", "/cloudapi/1.0.0/orgs?name=missing-org", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page types.Page[models.Organization]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, int64(0), page.ResultTotal)
+	})
+
+	vdcBody := map[string]interface{}{
+		"name":            "iac-vdc",
+		"allocationModel": "PayAsYouGo",
+		"isEnabled":       true,
+		"externalId":      "tf-vdc-1",
+		"org":             map[string]interface{}{"id": org.ID},
+	}
+
+	var vdc handlers.VDCResponse
+	t.Run("Retried VDC create returns the same VDC", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/vdcs", vdcBody)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &vdc))
+		assert.Equal(t, "tf-vdc-1", vdc.ExternalID)
+
+		w = doRequest("POST", "/cloudapi/1.0.0/vdcs", vdcBody)
+		require.Equal(t, http.StatusOK, w.Code)
+		var retried handlers.VDCResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &retried))
+		assert.Equal(t, vdc.ID, retried.ID)
+	})
+
+	t.Run("VDC external ID cannot be reused for another VDC", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/vdcs", map[string]interface{}{
+			"name":            "other-vdc",
+			"allocationModel": "PayAsYouGo",
+			"externalId":      "tf-vdc-1",
+			"org":             map[string]interface{}{"id": org.ID},
+		})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Look up VDCs by name and external ID", func(t *testing.T) {
+		for _, query := range []string{"name=iac-vdc", "externalId=tf-vdc-1"} {
+			w := doRequest("GET", "/cloudapi/1.0.0/vdcs?"+query, nil)
+			require.Equal(t, http.StatusOK, w.Code)
+			var page types.Page[handlers.VDCResponse]
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			require.Len(t, page.Values, 1, query)
+			assert.Equal(t, vdc.ID, page.Values[0].ID, query)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestImpersonateSession(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	adminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(adminRole).Error)
+	vappUserRole := &models.Role{Name: models.RoleVAppUser, Description: "vApp User role"}
+	require.NoError(t, db.DB.Create(vappUserRole).Error)
+
+	org := &models.Organization{Name: "SupportOrg", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+
+	createUser := func(username string, enabled bool, roles ...*models.Role) *models.User {
+		user := &models.User{Username: username, Email: username + "@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+		require.NoError(t, user.SetPassword("password123"))
+		require.NoError(t, db.DB.Create(user).Error)
+		if !enabled {
+			require.NoError(t, db.DB.Model(user).Update("enabled", false).Error)
+		}
+		for _, role := range roles {
+			require.NoError(t, db.DB.Model(user).Association("Roles").Append(role))
+		}
+		return user
+	}
+	admin := createUser("support-admin", true, adminRole)
+	otherAdmin := createUser("other-admin", true, adminRole)
+	tenant := createUser("tenant-user", true, vappUserRole)
+	disabled := createUser("disabled-user", false, vappUserRole)
+
+	adminToken, err := jwtManager.Generate(admin.ID, admin.Username)
+	require.NoError(t, err)
+	tenantToken, err := jwtManager.Generate(tenant.ID, tenant.Username)
+	require.NoError(t, err)
+
+	impersonate := func(token string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/sessions/actions/impersonate", &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("System Administrator receives a short-lived token for the tenant user", func(t *testing.T) {
+		w := impersonate(adminToken, map[string]string{"username": tenant.Username, "reason": "TICKET-42"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var session models.Session
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+		assert.Equal(t, tenant.ID, session.User.ID)
+		assert.Equal(t, org.ID, session.Org.ID)
+		assert.Equal(t, []string{models.RoleVAppUser}, session.Roles)
+		require.NotNil(t, session.ImpersonatedBy)
+		assert.Equal(t, admin.ID, session.ImpersonatedBy.ID)
+		assert.Equal(t, admin.Username, session.ImpersonatedBy.Name)
+
+		token := strings.TrimPrefix(w.Header().Get("Authorization"), "Bearer ")
+		claims, err := jwtManager.Verify(token)
+		require.NoError(t, err)
+		assert.Equal(t, tenant.ID, claims.UserID)
+		require.True(t, claims.IsImpersonation())
+		assert.Equal(t, admin.ID, *claims.ImpersonatorID)
+		assert.Equal(t, admin.Username, *claims.ImpersonatorUsername)
+		assert.Equal(t, session.ID, *claims.SessionID)
+		assert.WithinDuration(t, time.Now().Add(15*time.Minute), claims.ExpiresAt.Time, time.Minute)
+
+		// The token acts as the tenant user and its session reports the impersonator
+		req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/sessions/"+session.ID, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var current models.Session
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &current))
+		assert.Equal(t, tenant.ID, current.User.ID)
+		require.NotNil(t, current.ImpersonatedBy)
+		assert.Equal(t, admin.ID, current.ImpersonatedBy.ID)
+
+		// Tenant users cannot reach provider-only endpoints while impersonated
+		w = impersonate(token, map[string]string{"userId": disabled.ID})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Users can be selected by ID", func(t *testing.T) {
+		w := impersonate(adminToken, map[string]string{"userId": tenant.ID})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var session models.Session
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+		assert.Equal(t, tenant.ID, session.User.ID)
+	})
+
+	t.Run("Regular sessions are not flagged", func(t *testing.T) {
+		claims, err := jwtManager.Verify(tenantToken)
+		require.NoError(t, err)
+		assert.False(t, claims.IsImpersonation())
+	})
+
+	t.Run("Rejected requests", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			token  string
+			body   map[string]string
+			status int
+		}{
+			{"non-administrator", tenantToken, map[string]string{"userId": disabled.ID}, http.StatusForbidden},
+			{"no user selected", adminToken, map[string]string{}, http.StatusBadRequest},
+			{"both ID and username", adminToken, map[string]string{"userId": tenant.ID, "username": tenant.Username}, http.StatusBadRequest},
+			{"unknown user", adminToken, map[string]string{"username": "missing"}, http.StatusNotFound},
+			{"self", adminToken, map[string]string{"userId": admin.ID}, http.StatusBadRequest},
+			{"another System Administrator", adminToken, map[string]string{"userId": otherAdmin.ID}, http.StatusForbidden},
+			{"disabled user", adminToken, map[string]string{"userId": disabled.ID}, http.StatusForbidden},
+		}
+		for _, tt := range tests {
+			w := impersonate(tt.token, tt.body)
+			assert.Equal(t, tt.status, w.Code, tt.name)
+			assert.Empty(t, w.Header().Get("Authorization"), tt.name)
+		}
+	})
+}
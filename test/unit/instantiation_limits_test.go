@@ -0,0 +1,92 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestInstantiationLimiter(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "LimitOrg", IsEnabled: true}
+	require.NoError(t, db.Create(org).Error)
+	newVDC := func(name string) *models.VDC {
+		vdc := &models.VDC{Name: name, OrganizationID: org.ID, AllocationModel: models.AllocationPool, IsEnabled: true}
+		require.NoError(t, db.Create(vdc).Error)
+		return vdc
+	}
+	vdc1 := newVDC("limit-vdc-1")
+	vdc2 := newVDC("limit-vdc-2")
+	vappRepo := repositories.NewVAppRepository(db)
+
+	busy := &models.VApp{Name: "busy", VDCID: vdc1.ID, Status: models.VAppStatusInstantiating}
+	require.NoError(t, db.Create(busy).Error)
+	require.NoError(t, db.Create(&models.VApp{Name: "done", VDCID: vdc1.ID, Status: models.VAppStatusDeployed}).Error)
+
+	create := func(vdc *models.VDC, name string) func() error {
+		return func() error {
+			return vappRepo.CreateWithContext(ctx, &models.VApp{Name: name, VDCID: vdc.ID, Status: models.VAppStatusInstantiating})
+		}
+	}
+
+	t.Run("Unlimited when no caps are configured", func(t *testing.T) {
+		assert.Nil(t, services.NewInstantiationLimiter(vappRepo, 0, 0, time.Minute))
+	})
+
+	t.Run("Counts instantiating vApps", func(t *testing.T) {
+		count, err := vappRepo.CountInstantiatingByVDC(ctx, vdc1.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+		count, err = vappRepo.CountInstantiatingByOrg(ctx, org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Refuses instantiations over the VDC cap", func(t *testing.T) {
+		limiter := services.NewInstantiationLimiter(vappRepo, 1, 0, 0)
+		called := false
+		err := limiter.Admit(ctx, vdc1, func() error { called = true; return nil })
+		assert.True(t, errors.Is(err, services.ErrInstantiationLimitReached))
+		assert.False(t, called)
+
+		require.NoError(t, limiter.Admit(ctx, vdc2, create(vdc2, "other-vdc")))
+	})
+
+	t.Run("Refuses instantiations over the organization cap", func(t *testing.T) {
+		limiter := services.NewInstantiationLimiter(vappRepo, 5, 2, 0)
+		err := limiter.Admit(ctx, vdc2, create(vdc2, "third"))
+		assert.True(t, errors.Is(err, services.ErrInstantiationLimitReached))
+		assert.ErrorContains(t, err, "the limit is 2")
+	})
+
+	t.Run("Queued instantiations start when a slot frees up", func(t *testing.T) {
+		limiter := services.NewInstantiationLimiter(vappRepo, 1, 0, 10*time.Second)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			db.Model(busy).Update("status", models.VAppStatusDeployed)
+		}()
+		require.NoError(t, limiter.Admit(ctx, vdc1, create(vdc1, "queued")))
+
+		count, err := vappRepo.CountInstantiatingByVDC(ctx, vdc1.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Queued instantiations stop when the request is cancelled", func(t *testing.T) {
+		limiter := services.NewInstantiationLimiter(vappRepo, 1, 0, time.Minute)
+		cancelled, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		err := limiter.Admit(cancelled, vdc1, create(vdc1, "never"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
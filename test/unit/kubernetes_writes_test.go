@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestNamespaceWriteLimiter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	configMap := func(namespace, name string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+
+	t.Run("Throttling is disabled without a rate", func(t *testing.T) {
+		limiter := services.NewNamespaceWriteLimiter(services.NamespaceWriteLimits{})
+		assert.Nil(t, limiter)
+
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		assert.Same(t, k8sClient, services.NewThrottledClient(k8sClient, limiter))
+	})
+
+	t.Run("Writes over a full queue are refused per namespace", func(t *testing.T) {
+		limiter := services.NewNamespaceWriteLimiter(services.NamespaceWriteLimits{
+			QPS:             0.001,
+			Burst:           1,
+			QueueDepth:      1,
+			BreakerCooldown: time.Minute,
+		})
+		k8sClient := services.NewThrottledClient(fake.NewClientBuilder().WithScheme(scheme).Build(), limiter)
+
+		require.NoError(t, k8sClient.Create(t.Context(), configMap("vdc-a", "first")))
+
+		// The next write waits for the rate limit, filling the queue
+		ctx, cancel := context.WithCancel(t.Context())
+		waiting := make(chan error, 1)
+		go func() {
+			waiting <- k8sClient.Create(ctx, configMap("vdc-a", "second"))
+		}()
+		require.Eventually(t, func() bool { return limiter.Queued("vdc-a") == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		err := k8sClient.Create(t.Context(), configMap("vdc-a", "third"))
+		assert.ErrorIs(t, err, services.ErrNamespaceWritesSaturated)
+		err = k8sClient.Delete(t.Context(), configMap("vdc-a", "first"))
+		assert.ErrorIs(t, err, services.ErrNamespaceWritesSaturated)
+
+		// Other namespaces have their own limits
+		assert.NoError(t, k8sClient.Create(t.Context(), configMap("vdc-b", "first")))
+
+		cancel()
+		assert.Error(t, <-waiting)
+		assert.Equal(t, 0, limiter.Queued("vdc-a"))
+
+		// Reads are not throttled
+		assert.NoError(t, k8sClient.Get(t.Context(), client.ObjectKey{Namespace: "vdc-a", Name: "first"}, &corev1.ConfigMap{}))
+	})
+
+	t.Run("Writes are refused while the cluster throttles the namespace", func(t *testing.T) {
+		limiter := services.NewNamespaceWriteLimiter(services.NamespaceWriteLimits{
+			QPS:             100,
+			Burst:           10,
+			QueueDepth:      10,
+			BreakerCooldown: time.Minute,
+		})
+		k8sClient := services.NewThrottledClient(fake.NewClientBuilder().WithScheme(scheme).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					if obj.GetNamespace() == "throttled" {
+						return apierrors.NewTooManyRequests("slow down", 1)
+					}
+					return c.Create(ctx, obj, opts...)
+				},
+			}).Build(), limiter)
+
+		err := k8sClient.Create(t.Context(), configMap("throttled", "first"))
+		assert.True(t, apierrors.IsTooManyRequests(err))
+		assert.NotErrorIs(t, err, services.ErrNamespaceWritesSaturated)
+
+		err = k8sClient.Create(t.Context(), configMap("throttled", "second"))
+		assert.ErrorIs(t, err, services.ErrNamespaceWritesSaturated)
+		err = k8sClient.Update(t.Context(), configMap("throttled", "first"))
+		assert.ErrorIs(t, err, services.ErrNamespaceWritesSaturated)
+
+		assert.NoError(t, k8sClient.Create(t.Context(), configMap("healthy", "first")))
+	})
+
+	t.Run("Namespaces count against their own limits", func(t *testing.T) {
+		limiter := services.NewNamespaceWriteLimiter(services.NamespaceWriteLimits{
+			QPS:             100,
+			Burst:           10,
+			QueueDepth:      10,
+			BreakerCooldown: time.Minute,
+		})
+		k8sClient := services.NewThrottledClient(fake.NewClientBuilder().WithScheme(scheme).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					return apierrors.NewTooManyRequests("slow down", 1)
+				},
+			}).Build(), limiter)
+
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "vdc-c"}}
+		assert.Error(t, k8sClient.Create(t.Context(), namespace))
+
+		err := k8sClient.Create(t.Context(), configMap("vdc-c", "first"))
+		assert.ErrorIs(t, err, services.ErrNamespaceWritesSaturated)
+		err = k8sClient.Create(t.Context(), configMap("vdc-d", "first"))
+		assert.True(t, apierrors.IsTooManyRequests(err))
+	})
+}
@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/messages"
+)
+
+func TestMessageCatalog(t *testing.T) {
+	t.Run("API errors carry the code of catalog messages", func(t *testing.T) {
+		apiErr := handlers.NewAPIError(http.StatusNotFound, "Not Found", "VDC not found")
+		assert.Equal(t, "VDC_NOT_FOUND", apiErr.MinorErrorCode)
+		assert.Equal(t, "VDC not found", apiErr.Message)
+
+		// Wording is normalized to the catalog's
+		apiErr = handlers.NewAPIError(http.StatusNotFound, "Not Found", "vdc NOT found")
+		assert.Equal(t, "VDC_NOT_FOUND", apiErr.MinorErrorCode)
+		assert.Equal(t, "VDC not found", apiErr.Message)
+
+		apiErr = handlers.NewAPIError(http.StatusBadRequest, "Bad Request", "Something unusual happened")
+		assert.Empty(t, apiErr.MinorErrorCode)
+		assert.Equal(t, "Something unusual happened", apiErr.Message)
+	})
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{"VDC_NOT_FOUND": "VDC nicht gefunden"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{"VDC_NOT_FOUND": "VDC introuvable"}`), 0o600))
+	catalog, err := messages.Load(dir)
+	require.NoError(t, err)
+
+	t.Run("Negotiates the language from Accept-Language", func(t *testing.T) {
+		assert.Equal(t, language.English, catalog.Negotiate(""))
+		assert.Equal(t, language.German, catalog.Negotiate("de-DE,de;q=0.9,en;q=0.8"))
+		assert.Equal(t, language.French, catalog.Negotiate("ja, fr;q=0.5"))
+		assert.Equal(t, language.English, catalog.Negotiate("ja"))
+		assert.Equal(t, language.English, catalog.Negotiate("not a language;;"))
+	})
+
+	t.Run("Falls back to English for untranslated codes", func(t *testing.T) {
+		message, ok := catalog.Message("VDC_NOT_FOUND", language.German)
+		assert.True(t, ok)
+		assert.Equal(t, "VDC nicht gefunden", message)
+
+		message, ok = catalog.Message("VM_NOT_FOUND", language.German)
+		assert.True(t, ok)
+		assert.Equal(t, "VM not found", message)
+
+		_, ok = catalog.Message("NO_SUCH_CODE", language.German)
+		assert.False(t, ok)
+	})
+
+	t.Run("Rejects invalid catalogs", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"de.json":       `{"NO_SUCH_CODE": "Unbekannt"}`,
+			"backup-1.json": `{}`,
+			"fr.json":       `not json`,
+		} {
+			invalid := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(invalid, name), []byte(content), 0o600))
+			_, err := messages.Load(invalid)
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("Middleware localizes error responses", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(messages.Middleware(catalog))
+		router.GET("/missing", func(c *gin.Context) {
+			c.JSON(http.StatusNotFound, handlers.NewAPIError(http.StatusNotFound, "Not Found", "VDC not found", "urn:vcloud:vdc:1"))
+		})
+		router.GET("/found", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "VDC not found"})
+		})
+
+		get := func(path, acceptLanguage string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", path, nil)
+			req.Header.Set("Accept-Language", acceptLanguage)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		w := get("/missing", "de")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "de", w.Header().Get("Content-Language"))
+		var apiErr handlers.APIError
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+		assert.Equal(t, "VDC nicht gefunden", apiErr.Message)
+		assert.Equal(t, "VDC_NOT_FOUND", apiErr.MinorErrorCode)
+		assert.Equal(t, "urn:vcloud:vdc:1", apiErr.Details)
+
+		w = get("/missing", "en-US")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+		assert.Equal(t, "VDC not found", apiErr.Message)
+		assert.Empty(t, w.Header().Get("Content-Language"))
+
+		w = get("/found", "de")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"message": "VDC not found"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,111 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestPropagatedMetadata(t *testing.T) {
+	t.Run("VirtualMachines and their pods are labelled", func(t *testing.T) {
+		template := &templatev1.Template{Objects: []runtime.RawExtension{
+			{Raw: []byte(`{"apiVersion":"kubevirt.io/v1","kind":"VirtualMachine","metadata":{"name":"vm","labels":{"app":"web","cost-center":"template"}},"spec":{"template":{"spec":{}}}}`)},
+			{Raw: []byte(`{"apiVersion":"v1","kind":"Service","metadata":{"name":"svc"}}`)},
+		}}
+		labels := map[string]string{"cost-center": "cc-1234"}
+		annotations := map[string]string{"backup.example.com/policy": "daily"}
+		require.NoError(t, services.AddPropagatedMetadata(template, labels, annotations))
+
+		var vm map[string]interface{}
+		require.NoError(t, json.Unmarshal(template.Objects[0].Raw, &vm))
+		metadata := vm["metadata"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"app": "web", "cost-center": "cc-1234"}, metadata["labels"])
+		assert.Equal(t, map[string]interface{}{"backup.example.com/policy": "daily"}, metadata["annotations"])
+		podMetadata := vm["spec"].(map[string]interface{})["template"].(map[string]interface{})["metadata"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"cost-center": "cc-1234"}, podMetadata["labels"])
+		assert.Equal(t, map[string]interface{}{"backup.example.com/policy": "daily"}, podMetadata["annotations"])
+
+		assert.JSONEq(t, `{"apiVersion":"v1","kind":"Service","metadata":{"name":"svc"}}`, string(template.Objects[1].Raw))
+	})
+
+	t.Run("VDC policies override the installation's", func(t *testing.T) {
+		merged := services.MergeMetadataPolicies(
+			models.MetadataPolicy{Labels: map[string]string{"cost-center": "default", "team": "platform"}},
+			models.MetadataPolicy{Labels: map[string]string{"cost-center": "cc-1234"}, Annotations: map[string]string{"note": "x"}},
+		)
+		assert.Equal(t, map[string]string{"cost-center": "cc-1234", "team": "platform"}, merged.Labels)
+		assert.Equal(t, map[string]string{"note": "x"}, merged.Annotations)
+		assert.Equal(t, models.MetadataPolicy{}, services.MergeMetadataPolicies())
+	})
+
+	t.Run("Instantiated vApps carry the merged metadata", func(t *testing.T) {
+		_, db, _ := setupTestAPIServer(t)
+		org := &models.Organization{Name: "MetadataOrg", DisplayName: "Metadata Organization", IsEnabled: true}
+		require.NoError(t, db.DB.Create(org).Error)
+		user := &models.User{Username: "metadatauser", Email: "metadata@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+		require.NoError(t, user.SetPassword("password123"))
+		require.NoError(t, db.DB.Create(user).Error)
+		require.NoError(t, db.DB.Create(&models.Catalog{Name: "metadata-catalog", OrganizationID: org.ID}).Error)
+		vdc := &models.VDC{Name: "metadata-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+		vdc.SetMetadataPolicy(models.MetadataPolicy{
+			Labels:      map[string]string{"cost-center": "cc-1234"},
+			Annotations: map[string]string{"backup.example.com/policy": "daily"},
+		})
+		vdc.SetBackupPolicy(&models.BackupPolicy{Enabled: true, Schedule: "daily"})
+		require.NoError(t, db.DB.Create(vdc).Error)
+
+		var instantiated *services.TemplateInstanceRequest
+		mockK8s := &MockKubernetesService{}
+		mockK8s.On("CreateTemplateInstance", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { instantiated = args.Get(1).(*services.TemplateInstanceRequest) }).
+			Return(&services.TemplateInstanceResult{Name: "metadata-vapp"}, nil)
+
+		vdcRepo := repositories.NewVDCRepository(db.DB)
+		vappRepo := repositories.NewVAppRepository(db.DB)
+		creation := handlers.NewVMCreationHandlers(vdcRepo, vappRepo,
+			repositories.NewCatalogItemRepository(nil, nil), repositories.NewCatalogRepository(db.DB),
+			auth.NewAccessControl(vdcRepo, vappRepo, repositories.NewVMRepository(db.DB)), mockK8s)
+		creation.SetMetadataPolicy(models.MetadataPolicy{Labels: map[string]string{"cost-center": "default", "backup-policy": "weekly"}})
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+		})
+		router.POST("/cloudapi/1.0.0/vdcs/:vdc_id/actions/instantiateTemplate", creation.InstantiateTemplate)
+
+		body, _ := json.Marshal(handlers.InstantiateTemplateRequest{
+			Name:        "metadata-vapp",
+			CatalogItem: handlers.CatalogItem{ID: "urn:vcloud:catalogitem:rhel9-server", Name: "rhel9-server"},
+		})
+		req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/actions/instantiateTemplate", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		require.NotNil(t, instantiated)
+		assert.Equal(t, map[string]string{
+			"cost-center":                "cc-1234",
+			"backup-policy":              "weekly",
+			services.BackupLabel:         "enabled",
+			services.BackupScheduleLabel: "daily",
+		}, instantiated.Labels)
+		assert.Equal(t, map[string]string{"backup.example.com/policy": "daily"}, instantiated.Annotations)
+	})
+}
@@ -0,0 +1,189 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// flowSample is a series of a Prometheus vector result
+type flowSample struct {
+	namespace, owner string
+	bytes            float64
+}
+
+// fakeFlowPrometheus serves the sent and received byte counts of the VM web in
+// flows-ns, recording the queries it receives
+func fakeFlowPrometheus(t *testing.T, sent, received []flowSample, queries *[]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query", r.URL.Path)
+		assert.Equal(t, "Bearer flow-token", r.Header.Get("Authorization"))
+		query := r.FormValue("query")
+		*queries = append(*queries, query)
+
+		prefix, samples := "Dst", sent
+		if strings.Contains(query, `DstK8S_OwnerName="web"`) {
+			prefix, samples = "Src", received
+		}
+		result := []map[string]interface{}{}
+		for _, sample := range samples {
+			metric := map[string]string{}
+			if sample.namespace != "" {
+				metric[prefix+"K8S_Namespace"] = sample.namespace
+				metric[prefix+"K8S_OwnerName"] = sample.owner
+				metric[prefix+"K8S_OwnerType"] = "VirtualMachineInstance"
+			}
+			result = append(result, map[string]interface{}{
+				"metric": metric,
+				"value":  []interface{}{1700000000, fmt.Sprintf("%g", sample.bytes)},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "vector", "result": result},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func flowServiceConfig(t *testing.T, url string) config.Config {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("flow-token\n"), 0o600))
+	var cfg config.Config
+	cfg.NetworkFlows.PrometheusURL = url
+	cfg.NetworkFlows.BearerTokenFile = tokenFile
+	cfg.NetworkFlows.Timeout = 5 * time.Second
+	cfg.NetworkFlows.Metric = "netobserv_workload_egress_bytes_total"
+	return cfg
+}
+
+func TestVMNetworkFlows(t *testing.T) {
+	var queries []string
+	server := fakeFlowPrometheus(t,
+		[]flowSample{{"flows-ns", "db", 1000}, {"", "", 5000.4}},
+		[]flowSample{{"flows-ns", "db", 3000}, {"other-tenant", "scanner", 200}},
+		&queries)
+
+	flows, err := services.NewPrometheusFlowService(flowServiceConfig(t, server.URL))
+	require.NoError(t, err)
+
+	summary, err := flows.VMNetworkFlows(context.Background(), "flows-ns", "web", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6000), summary.BytesSent)
+	assert.Equal(t, int64(3200), summary.BytesReceived)
+	assert.Equal(t, []services.NetworkFlowPeer{
+		{BytesSent: 5000},
+		{Namespace: "flows-ns", Name: "db", Kind: "VirtualMachineInstance", BytesSent: 1000, BytesReceived: 3000},
+		{Namespace: "other-tenant", Name: "scanner", Kind: "VirtualMachineInstance", BytesReceived: 200},
+	}, summary.Peers)
+
+	require.Len(t, queries, 2)
+	assert.Equal(t, `sum by (DstK8S_Namespace, DstK8S_OwnerName, DstK8S_OwnerType) (increase(netobserv_workload_egress_bytes_total{SrcK8S_Namespace="flows-ns",SrcK8S_OwnerName="web"}[1h]))`, queries[0])
+
+	t.Run("Rejects invalid configuration", func(t *testing.T) {
+		_, err := services.NewPrometheusFlowService(flowServiceConfig(t, "not a url"))
+		assert.Error(t, err)
+	})
+}
+
+func TestVMNetworkFlowsAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "FlowOrg", DisplayName: "Flow Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "flowuser", Email: "flow@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "flow-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "flows-ns"}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	otherVDC := &models.VDC{Name: "flow-vdc-2", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "flows-ns-2"}
+	require.NoError(t, db.DB.Create(otherVDC).Error)
+	vapp := &models.VApp{Name: "flow-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	vm := &models.VM{Name: "web", VAppID: vapp.ID, Status: "POWERED_ON", VMName: "web", Namespace: "flows-ns"}
+	require.NoError(t, db.DB.Create(vm).Error)
+
+	var queries []string
+	server := fakeFlowPrometheus(t,
+		[]flowSample{{"flows-ns", "db", 1000}, {"flows-ns-2", "cache", 400}, {"", "", 5000}},
+		[]flowSample{{"other-tenant", "scanner", 200}, {"openshift-dns", "dns-default", 100}},
+		&queries)
+	flows, err := services.NewPrometheusFlowService(flowServiceConfig(t, server.URL))
+	require.NoError(t, err)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	access := auth.NewAccessControl(vdcRepo, vappRepo, vmRepo)
+	vmHandlers := handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, access, nil, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+	})
+	router.GET("/cloudapi/1.0.0/vms/:vm_id/network/flows", vmHandlers.GetVMNetworkFlows)
+
+	request := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vms/"+vm.ID+"/network/flows"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unavailable without Prometheus", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, request("").Code)
+	})
+
+	vmHandlers.SetNetworkFlows(flows)
+
+	t.Run("Reports top talkers, hiding other tenants", func(t *testing.T) {
+		w := request("")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response handlers.VMNetworkFlowsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, vm.ID, response.VMID)
+		assert.Equal(t, int64(3600), response.WindowSeconds)
+		assert.Equal(t, int64(6400), response.BytesSent)
+		assert.Equal(t, int64(300), response.BytesReceived)
+		assert.Equal(t, []handlers.NetworkFlowPeerResponse{
+			{Scope: handlers.NetworkFlowScopeExternal, BytesSent: 5000, TotalBytes: 5000},
+			{Scope: handlers.NetworkFlowScopeOrganization, Name: "db", Kind: "VirtualMachineInstance", BytesSent: 1000, TotalBytes: 1000},
+			{Scope: handlers.NetworkFlowScopeOrganization, Name: "cache", Kind: "VirtualMachineInstance", BytesSent: 400, TotalBytes: 400},
+			{Scope: handlers.NetworkFlowScopeCluster, BytesReceived: 300, TotalBytes: 300},
+		}, response.TopTalkers)
+		assert.NotContains(t, w.Body.String(), "scanner")
+	})
+
+	t.Run("Limits the peers", func(t *testing.T) {
+		w := request("?top=2")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response handlers.VMNetworkFlowsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.TopTalkers, 2)
+	})
+
+	t.Run("Rejects invalid top", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, request("?top=0").Code)
+		assert.Equal(t, http.StatusBadRequest, request("?top=51").Code)
+	})
+}
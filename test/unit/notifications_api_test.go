@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+)
+
+func TestNotificationsStream(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	httpServer := httptest.NewServer(server.GetRouter())
+	defer httpServer.Close()
+
+	org := &models.Organization{Name: "notify-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	otherOrg := &models.Organization{Name: "other-notify-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(otherOrg).Error)
+
+	user := &models.User{Username: "notify-user", Email: "notify@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	token, err := jwtManager.Generate(user.ID, user.Username)
+	require.NoError(t, err)
+
+	t.Run("Requires authentication", func(t *testing.T) {
+		resp, err := http.Get(httpServer.URL + "/cloudapi/1.0.0/notifications")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Streams events for the user's organization only", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", httpServer.URL+"/cloudapi/1.0.0/notifications?types="+events.TypeVMStatusChanged, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		bus := server.EventBus()
+		require.Eventually(t, func() bool { return bus.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+		// Filtered out: other organization and non-matching type
+		bus.Publish(events.Event{Type: events.TypeVMStatusChanged, EntityID: "urn:vcloud:vm:other", OrgID: otherOrg.ID})
+		bus.Publish(events.Event{Type: events.TypeVMUpdated, EntityID: "urn:vcloud:vm:mine", OrgID: org.ID})
+		// Delivered
+		bus.Publish(events.Event{Type: events.TypeVMStatusChanged, EntityID: "urn:vcloud:vm:mine", OrgID: org.ID})
+
+		reader := bufio.NewReader(resp.Body)
+		var eventName, data string
+		for data == "" {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "event:") {
+				eventName = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			}
+			if strings.HasPrefix(line, "data:") {
+				data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			}
+		}
+
+		assert.Equal(t, events.TypeVMStatusChanged, eventName)
+		var event events.Event
+		require.NoError(t, json.Unmarshal([]byte(data), &event))
+		assert.Equal(t, "urn:vcloud:vm:mine", event.EntityID)
+		assert.Equal(t, org.ID, event.OrgID)
+	})
+}
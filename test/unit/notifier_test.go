@@ -0,0 +1,236 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// receivedMail is a message accepted by fakeSMTPServer
+type receivedMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTPServer accepts mail on a local port without authentication or TLS
+func fakeSMTPServer(t *testing.T) (host string, port int, messages <-chan receivedMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan receivedMail, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func serveSMTP(conn net.Conn, received chan<- receivedMail) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	var msg receivedMail
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			msg.From = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			msg.Data = data.String()
+			received <- msg
+			msg = receivedMail{}
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func nextMail(t *testing.T, messages <-chan receivedMail) receivedMail {
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for mail")
+		return receivedMail{}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	host, port, messages := fakeSMTPServer(t)
+	cfg := config.EmailConfig{
+		Enabled:    true,
+		Host:       host,
+		Port:       port,
+		From:       "SSVirt <ssvirt@example.com>",
+		Recipients: []string{"ops@example.com"},
+		SenderOverrides: []config.EmailSenderOverride{
+			{OrganizationID: "urn:vcloud:org:tenant", From: "cloud@tenant.example"},
+		},
+	}
+	ctx := context.Background()
+
+	t.Run("Sends templated system alerts to the default recipients", func(t *testing.T) {
+		notifier, err := services.NewSMTPNotifier(cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, notifier.Notify(ctx, services.Notification{
+			Event: services.NotificationProvisioningStuck,
+			Data: map[string]interface{}{
+				"VAppID":           "urn:vcloud:vapp:1",
+				"VAppName":         "web",
+				"VDCName":          "dev",
+				"Namespace":        "vdc-dev",
+				"TemplateInstance": "web",
+				"CreatedAt":        time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+				"Duration":         "45m0s",
+			},
+		}))
+
+		msg := nextMail(t, messages)
+		assert.Equal(t, "ssvirt@example.com", msg.From)
+		assert.Equal(t, []string{"ops@example.com"}, msg.To)
+		assert.Contains(t, msg.Data, "From: SSVirt <ssvirt@example.com>\r\n")
+		assert.Contains(t, msg.Data, "Subject: vApp web has been instantiating for 45m0s\r\n")
+		assert.Contains(t, msg.Data, "started instantiating at 2024-01-15 10:00:00 UTC")
+		assert.Contains(t, msg.Data, "Check TemplateInstance vdc-dev/web")
+	})
+
+	t.Run("Uses the organization's sender override and explicit recipients", func(t *testing.T) {
+		notifier, err := services.NewSMTPNotifier(cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, notifier.Notify(ctx, services.Notification{
+			Event:          services.NotificationPasswordReset,
+			OrganizationID: "urn:vcloud:org:tenant",
+			To:             []string{"Jane Doe <jane@tenant.example>"},
+			Data: map[string]interface{}{
+				"Username":  "jane",
+				"ExpiresIn": "1 hour",
+				"ResetURL":  "https://ssvirt.example.com/reset?token=abc",
+			},
+		}))
+
+		msg := nextMail(t, messages)
+		assert.Equal(t, "cloud@tenant.example", msg.From)
+		assert.Equal(t, []string{"jane@tenant.example"}, msg.To)
+		assert.Contains(t, msg.Data, "To: Jane Doe <jane@tenant.example>\r\n")
+		assert.Contains(t, msg.Data, "https://ssvirt.example.com/reset?token=abc")
+	})
+
+	t.Run("Template directory overrides built-in templates", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "approval-requested.tmpl"),
+			[]byte(`{{define "subject"}}Please review
+{{.Request}}{{end}}{{define "body"}}Custom body for {{.Requester}}{{end}}`), 0o600))
+		overridden := cfg
+		overridden.TemplateDir = dir
+		notifier, err := services.NewSMTPNotifier(overridden)
+		require.NoError(t, err)
+
+		require.NoError(t, notifier.Notify(ctx, services.Notification{
+			Event: services.NotificationApprovalRequested,
+			Data:  map[string]interface{}{"Request": "large VM", "Requester": "bob"},
+		}))
+
+		msg := nextMail(t, messages)
+		// Line breaks in a rendered subject are folded so they cannot add headers
+		assert.Contains(t, msg.Data, "Subject: Please review large VM\r\n")
+		assert.Contains(t, msg.Data, "Custom body for bob")
+	})
+
+	t.Run("Rejects invalid configuration and notifications", func(t *testing.T) {
+		invalid := cfg
+		invalid.From = "not an address"
+		_, err := services.NewSMTPNotifier(invalid)
+		assert.ErrorContains(t, err, "invalid from address")
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "storage-alert.tmpl"), []byte(`{{define "subject"}}only a subject{{end}}`), 0o600))
+		invalid = cfg
+		invalid.TemplateDir = dir
+		_, err = services.NewSMTPNotifier(invalid)
+		assert.ErrorContains(t, err, `must define "subject" and "body"`)
+
+		noRecipients := cfg
+		noRecipients.Recipients = nil
+		notifier, err := services.NewSMTPNotifier(noRecipients)
+		require.NoError(t, err)
+		err = notifier.Notify(ctx, services.Notification{Event: services.NotificationApprovalRequested})
+		assert.ErrorContains(t, err, "no recipients")
+
+		notifier, err = services.NewSMTPNotifier(cfg)
+		require.NoError(t, err)
+		err = notifier.Notify(ctx, services.Notification{
+			Event: services.NotificationApprovalRequested,
+			Data:  map[string]interface{}{"Request": "large VM"},
+		})
+		assert.ErrorContains(t, err, "failed to render")
+		assert.Empty(t, messages)
+	})
+
+	t.Run("Reports delivery failures", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		closedPort := listener.Addr().(*net.TCPAddr).Port
+		require.NoError(t, listener.Close())
+
+		unreachable := cfg
+		unreachable.Port = closedPort
+		notifier, err := services.NewSMTPNotifier(unreachable)
+		require.NoError(t, err)
+		err = notifier.Notify(ctx, services.Notification{
+			Event: services.NotificationApprovalRequested,
+			Data:  map[string]interface{}{"Request": "r", "Requester": "u", "Organization": "o", "ReviewURL": "u"},
+		})
+		assert.ErrorContains(t, err, "failed to send approval-requested notification")
+	})
+}
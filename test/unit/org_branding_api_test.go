@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestOrgBrandingAPI(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	org := &models.Organization{Name: "branded-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	otherOrg := &models.Organization{Name: "other-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(otherOrg).Error)
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+
+	sysAdmin := &models.User{Username: "sysadmin", Email: "sysadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+	adminToken, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	tenant := &models.User{Username: "tenant", Email: "tenant@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, tenant.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(tenant).Error)
+	tenantToken, err := jwtManager.Generate(tenant.ID, tenant.Username)
+	require.NoError(t, err)
+
+	doRequest := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	brandingPath := "/cloudapi/1.0.0/orgs/" + org.ID + "/branding"
+
+	t.Run("Get unconfigured branding returns empty record", func(t *testing.T) {
+		w := doRequest("GET", brandingPath, "", tenantToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var branding models.OrgBranding
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &branding))
+		assert.Equal(t, org.ID, branding.OrganizationID)
+		assert.Empty(t, branding.LogoURL)
+	})
+
+	t.Run("System administrator updates branding", func(t *testing.T) {
+		body := `{"logoUrl":"https://example.com/logo.png","primaryColor":"#112233","secondaryColor":"#abc","supportEmail":"help@example.com","supportUrl":"https://example.com/support","supportPhone":"+1 555 0100"}`
+		w := doRequest("PUT", brandingPath, body, adminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var branding models.OrgBranding
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &branding))
+		assert.Equal(t, "https://example.com/logo.png", branding.LogoURL)
+		assert.Equal(t, "#112233", branding.PrimaryColor)
+		assert.Equal(t, "help@example.com", branding.SupportEmail)
+	})
+
+	t.Run("Tenant reads updated branding", func(t *testing.T) {
+		w := doRequest("GET", brandingPath, "", tenantToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var branding models.OrgBranding
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &branding))
+		assert.Equal(t, "#abc", branding.SecondaryColor)
+		assert.Equal(t, "+1 555 0100", branding.SupportPhone)
+	})
+
+	t.Run("Updates keep the creation time", func(t *testing.T) {
+		var before models.OrgBranding
+		require.NoError(t, db.DB.Where("organization_id = ?", org.ID).First(&before).Error)
+		require.False(t, before.CreatedAt.IsZero())
+
+		w := doRequest("PUT", brandingPath, `{"primaryColor":"#445566"}`, adminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var after models.OrgBranding
+		require.NoError(t, db.DB.Where("organization_id = ?", org.ID).First(&after).Error)
+		assert.Equal(t, "#445566", after.PrimaryColor)
+		assert.True(t, before.CreatedAt.Equal(after.CreatedAt))
+	})
+
+	t.Run("Tenant cannot update branding", func(t *testing.T) {
+		w := doRequest("PUT", brandingPath, `{"primaryColor":"#000000"}`, tenantToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Tenant cannot read another organization's branding", func(t *testing.T) {
+		w := doRequest("GET", "/cloudapi/1.0.0/orgs/"+otherOrg.ID+"/branding", "", tenantToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid values are rejected", func(t *testing.T) {
+		for _, body := range []string{
+			`{"logoUrl":"javascript:alert(1)"}`,
+			`{"primaryColor":"red"}`,
+			`{"supportEmail":"not-an-email"}`,
+		} {
+			w := doRequest("PUT", brandingPath, body, adminToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+func TestCreateOrgWithDefaultCatalog(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	for _, name := range []string{"rhel9", "fedora", "windows"} {
+		require.NoError(t, db.DB.Create(&models.CatalogItemRecord{TemplateUID: "uid-" + name, Name: name, Namespace: "openshift"}).Error)
+	}
+
+	orgHandlers := handlers.NewOrgHandlers(repositories.NewOrganizationRepository(db.DB))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: "urn:vcloud:user:admin"})
+	})
+	router.POST("/cloudapi/1.0.0/orgs", orgHandlers.CreateOrg)
+
+	createOrg := func(name string) *models.Organization {
+		req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/orgs", bytes.NewBufferString(`{"name":"`+name+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var org models.Organization
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+		return &org
+	}
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		org := createOrg("plain-org")
+		assert.Nil(t, org.DefaultCatalog)
+
+		var count int64
+		require.NoError(t, db.DB.Model(&models.Catalog{}).Where("organization_id = ?", org.ID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Catalog lists the starter templates", func(t *testing.T) {
+		orgHandlers.SetDefaultCatalog(config.DefaultCatalogConfig{
+			Enabled:   true,
+			Name:      "Starter",
+			Templates: []string{"rhel9", "fedora"},
+		})
+		org := createOrg("starter-org")
+		require.NotNil(t, org.DefaultCatalog)
+		assert.Equal(t, "Starter", org.DefaultCatalog.Name)
+
+		catalogRepo := repositories.NewCatalogRepository(db.DB)
+		catalog, err := catalogRepo.GetByID(org.DefaultCatalog.ID)
+		require.NoError(t, err)
+		assert.Equal(t, org.ID, catalog.OrganizationID)
+		assert.Equal(t, "urn:vcloud:user:admin", catalog.OwnerID)
+
+		itemRepo := repositories.NewCatalogItemRepository(db.DB, catalogRepo)
+		items, err := itemRepo.ListByCatalogID(context.Background(), catalog.ID, "", 10, 0)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, "fedora", items[0].Name)
+		assert.Equal(t, "rhel9", items[1].Name)
+
+		count, err := itemRepo.CountByCatalogID(context.Background(), catalog.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		_, err = itemRepo.GetByID(context.Background(), catalog.ID, "windows")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,190 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+func TestOrganizationHierarchyRepository(t *testing.T) {
+	db := setupTestDB(t)
+	orgRepo := repositories.NewOrganizationRepository(db)
+	vdcRepo := repositories.NewVDCRepository(db)
+	ctx := context.Background()
+
+	parent := &models.Organization{Name: "parent-org", IsEnabled: true}
+	require.NoError(t, orgRepo.Create(parent))
+	child := &models.Organization{Name: "child-org", IsEnabled: true, ParentOrgID: &parent.ID}
+	require.NoError(t, orgRepo.Create(child))
+	grandchild := &models.Organization{Name: "grandchild-org", IsEnabled: true, ParentOrgID: &child.ID}
+	require.NoError(t, orgRepo.Create(grandchild))
+
+	t.Run("Ancestors and descendants", func(t *testing.T) {
+		ancestors, err := orgRepo.GetAncestorIDs(ctx, grandchild.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{child.ID, parent.ID}, ancestors)
+
+		descendants, err := orgRepo.GetDescendantIDs(ctx, parent.ID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{child.ID, grandchild.ID}, descendants)
+	})
+
+	t.Run("Cycle detection", func(t *testing.T) {
+		err := orgRepo.ValidateParent(ctx, parent.ID, grandchild.ID)
+		assert.ErrorIs(t, err, repositories.ErrOrgHierarchyCycle)
+
+		err = orgRepo.ValidateParent(ctx, parent.ID, parent.ID)
+		assert.ErrorIs(t, err, repositories.ErrOrgHierarchyCycle)
+
+		assert.NoError(t, orgRepo.ValidateParent(ctx, "", grandchild.ID))
+	})
+
+	t.Run("Entity references", func(t *testing.T) {
+		retrieved, err := orgRepo.GetWithEntityRefs(child.ID)
+		require.NoError(t, err)
+		require.NotNil(t, retrieved.ManagedBy)
+		assert.Equal(t, parent.ID, retrieved.ManagedBy.ID)
+		assert.Equal(t, "parent-org", retrieved.ManagedBy.Name)
+		assert.Equal(t, 1, retrieved.DirectlyManagedOrgCount)
+
+		retrieved, err = orgRepo.GetWithEntityRefs(parent.ID)
+		require.NoError(t, err)
+		assert.Nil(t, retrieved.ManagedBy)
+	})
+
+	t.Run("Hierarchical VDC access", func(t *testing.T) {
+		vdc := &models.VDC{
+			Name:            "child-vdc",
+			OrganizationID:  child.ID,
+			AllocationModel: models.PayAsYouGo,
+			IsEnabled:       true,
+		}
+		require.NoError(t, db.Create(vdc).Error)
+
+		parentUser := &models.User{
+			Username:       "parent-user",
+			Email:          "parent-user@example.com",
+			Enabled:        true,
+			OrganizationID: &parent.ID,
+		}
+		require.NoError(t, parentUser.SetPassword("password123"))
+		require.NoError(t, db.Create(parentUser).Error)
+
+		// Without hierarchical access only the user's own organization is visible
+		count, err := vdcRepo.CountAccessibleVDCs(ctx, parentUser.ID, repositories.VDCListFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+
+		vdcRepo.SetHierarchicalAccess(true)
+		count, err = vdcRepo.CountAccessibleVDCs(ctx, parentUser.ID, repositories.VDCListFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		accessible, err := vdcRepo.GetAccessibleVDC(ctx, parentUser.ID, vdc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, vdc.ID, accessible.ID)
+	})
+
+	t.Run("Rollup", func(t *testing.T) {
+		rollup, err := orgRepo.GetRollup(ctx, parent.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, rollup.IncludedOrgCount)
+		assert.Equal(t, int64(1), rollup.OrgVdcCount)
+		assert.Equal(t, int64(1), rollup.UserCount)
+	})
+
+	t.Run("Organizations with children cannot be deleted", func(t *testing.T) {
+		assert.ErrorIs(t, orgRepo.Delete(child.ID), repositories.ErrOrgHasChildren)
+		_, err := orgRepo.GetByID(child.ID)
+		assert.NoError(t, err)
+	})
+}
+
+func TestOrganizationHierarchyAPI(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+
+	sysAdmin := &models.User{Username: "sysadmin", Email: "sysadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+
+	token, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	doRequest := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := doRequest("POST", "/cloudapi/1.0.0/orgs", `{"name":"umbrella"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var parent models.Organization
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &parent))
+
+	var child models.Organization
+	t.Run("Create child organization", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/orgs", `{"name":"department","managedBy":{"id":"`+parent.ID+`"}}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &child))
+		require.NotNil(t, child.ManagedBy)
+		assert.Equal(t, parent.ID, child.ManagedBy.ID)
+		assert.Equal(t, "umbrella", child.ManagedBy.Name)
+	})
+
+	t.Run("Create with unknown parent returns 400", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/orgs", `{"name":"orphan","managedBy":{"id":"urn:vcloud:org:99999999-9999-9999-9999-999999999999"}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Parent reports directly managed orgs", func(t *testing.T) {
+		w := doRequest("GET", "/cloudapi/1.0.0/orgs/"+parent.ID, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var org models.Organization
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+		assert.Equal(t, 1, org.DirectlyManagedOrgCount)
+	})
+
+	t.Run("Cycle is rejected", func(t *testing.T) {
+		w := doRequest("PUT", "/cloudapi/1.0.0/orgs/"+parent.ID, `{"managedBy":{"id":"`+child.ID+`"}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Delete parent with children returns 409", func(t *testing.T) {
+		w := doRequest("DELETE", "/cloudapi/1.0.0/orgs/"+parent.ID, "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Rollup", func(t *testing.T) {
+		w := doRequest("GET", "/cloudapi/1.0.0/orgs/"+parent.ID+"/rollup", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var rollup models.OrganizationRollup
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rollup))
+		assert.Equal(t, parent.ID, rollup.OrgID)
+		assert.Equal(t, 2, rollup.IncludedOrgCount)
+	})
+
+	t.Run("Detach child organization", func(t *testing.T) {
+		w := doRequest("PUT", "/cloudapi/1.0.0/orgs/"+child.ID, `{"managedBy":{"id":""}}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var org models.Organization
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+		assert.Nil(t, org.ManagedBy)
+	})
+}
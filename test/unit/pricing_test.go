@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestPricingEstimate(t *testing.T) {
+	pricing := services.Pricing{
+		Currency:        "EUR",
+		CPUHour:         0.01,
+		MemoryGiBHour:   0.005,
+		StorageGiBMonth: 0.1,
+		GPUHour:         1,
+		HoursPerMonth:   services.DefaultHoursPerMonth,
+	}
+
+	t.Run("Prices each resource for a month", func(t *testing.T) {
+		estimate := pricing.Estimate(services.ResourceUsage{
+			CPUs:         2,
+			MemoryBytes:  4 * 1024 * 1024 * 1024,
+			StorageBytes: 20 * 1024 * 1024 * 1024,
+			GPUs:         1,
+		})
+		require.NotNil(t, estimate)
+		assert.Equal(t, "EUR", estimate.Currency)
+		assert.Equal(t, 14.6, estimate.Breakdown.CPU)
+		assert.Equal(t, 14.6, estimate.Breakdown.Memory)
+		assert.Equal(t, 2.0, estimate.Breakdown.Storage)
+		assert.Equal(t, 730.0, estimate.Breakdown.GPU)
+		assert.Equal(t, 761.2, estimate.MonthlyCost)
+	})
+
+	t.Run("Defaults hours per month", func(t *testing.T) {
+		estimate := services.Pricing{CPUHour: 0.01}.Estimate(services.ResourceUsage{CPUs: 1})
+		require.NotNil(t, estimate)
+		assert.Equal(t, 7.3, estimate.MonthlyCost)
+	})
+
+	t.Run("Returns nil when no rates are configured", func(t *testing.T) {
+		assert.False(t, services.Pricing{Currency: "USD"}.Enabled())
+		assert.Nil(t, services.Pricing{Currency: "USD"}.Estimate(services.ResourceUsage{CPUs: 4}))
+	})
+}
+
+func TestVMCostEstimate(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "PricingOrg", DisplayName: "Pricing Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "pricinguser", Email: "pricing@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "pricing-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "pricing-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	vm := &models.VM{Name: "pricing-vm", VAppID: vapp.ID, Status: "POWERED_ON", VMName: "pricing-vm", Namespace: "test-ns", CPUCount: intPtr(2), MemoryMB: intPtr(2048)}
+	require.NoError(t, db.DB.Create(vm).Error)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	vmHandlers := handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), nil, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cloudapi/1.0.0/vms/:vm_id", func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+		vmHandlers.GetVM(c)
+	})
+	getVM := func(t *testing.T) map[string]interface{} {
+		req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vms/"+vm.ID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	t.Run("Omitted when pricing is not configured", func(t *testing.T) {
+		assert.NotContains(t, getVM(t), "estimatedCost")
+	})
+
+	t.Run("Included when pricing is configured", func(t *testing.T) {
+		vmHandlers.SetPricing(services.Pricing{Currency: "USD", CPUHour: 0.02, MemoryGiBHour: 0.01, HoursPerMonth: 100})
+
+		estimate, ok := getVM(t)["estimatedCost"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, "USD", estimate["currency"])
+		assert.Equal(t, 6.0, estimate["monthlyCost"])
+		assert.Equal(t, map[string]interface{}{"cpu": 4.0, "memory": 2.0, "storage": 0.0, "gpu": 0.0}, estimate["breakdown"])
+	})
+}
@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/config"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestProviderDiscoveryEndpoints(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	admin := &models.User{Username: "sysadmin", Email: "sysadmin@example.com", Enabled: true}
+	require.NoError(t, admin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(admin).Error)
+	adminRole := &models.Role{Name: models.RoleSystemAdmin}
+	require.NoError(t, db.DB.Create(adminRole).Error)
+	require.NoError(t, db.DB.Model(admin).Association("Roles").Append(adminRole))
+	adminToken, err := jwtManager.GenerateWithRole(admin.ID, admin.Username, "", models.RoleSystemAdmin)
+	require.NoError(t, err)
+
+	user := &models.User{Username: "tenant", Email: "tenant@example.com", Enabled: true}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	userToken, err := jwtManager.GenerateWithRole(user.ID, user.Username, "", models.RoleVAppUser)
+	require.NoError(t, err)
+
+	get := func(url, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Get site", func(t *testing.T) {
+		w := get("http://ssvirt.example.com/cloudapi/1.0.0/site", userToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response handlers.SiteResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "urn:vcloud:site:00000000-0000-0000-0000-000000000001", response.ID)
+		assert.Equal(t, "SSVirt Provider", response.Name)
+		assert.Equal(t, "http://ssvirt.example.com", response.RestEndpoint)
+	})
+
+	t.Run("Site requires authentication", func(t *testing.T) {
+		w := get("/cloudapi/1.0.0/site", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Standalone site has no associations", func(t *testing.T) {
+		w := get("/cloudapi/1.0.0/siteAssociations", userToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.Page[handlers.SiteAssociationResponse]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, int64(0), response.ResultTotal)
+		assert.Empty(t, response.Values)
+	})
+
+	t.Run("System settings require System Administrator", func(t *testing.T) {
+		w := get("/api/admin/extension/settings", userToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = get("http://ssvirt.example.com/api/admin/extension/settings", adminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response handlers.SystemSettingsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "http://ssvirt.example.com/api/admin/extension/settings", response.Href)
+		assert.Equal(t, 30, response.GeneralSettings.SessionTimeoutMinutes)
+		assert.Equal(t, 60, response.GeneralSettings.AbsoluteSessionTimeoutMinutes)
+
+		w = get("/api/admin/extension/settings/general", adminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestProviderHandlersConfiguration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{}
+	cfg.Provider.RestEndpoint = "https://vcd.example.com/"
+	cfg.Provider.InstallationID = 7
+	cfg.Provider.FeatureFlags = map[string]bool{"vapp_health": true, "legacy_api": false}
+	cfg.Provider.SiteAssociations = []config.SiteAssociationConfig{{
+		SiteID:       "urn:vcloud:site:00000000-0000-0000-0000-000000000002",
+		SiteName:     "Secondary",
+		RestEndpoint: "https://secondary.example.com",
+	}}
+	cfg.Auth.TokenExpiry = 24 * time.Hour
+
+	h := handlers.NewProviderHandlers(cfg)
+	router := gin.New()
+	router.GET("/site", h.GetSite)
+	router.GET("/siteAssociations", h.ListSiteAssociations)
+	router.GET("/featureFlags", h.ListFeatureFlags)
+	router.GET("/settings/general", h.GetGeneralSettings)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	t.Run("Configured rest endpoint", func(t *testing.T) {
+		var response handlers.SiteResponse
+		require.NoError(t, json.Unmarshal(get("/site").Body.Bytes(), &response))
+		assert.Equal(t, "https://vcd.example.com", response.RestEndpoint)
+	})
+
+	t.Run("Configured site associations", func(t *testing.T) {
+		var response types.Page[handlers.SiteAssociationResponse]
+		require.NoError(t, json.Unmarshal(get("/siteAssociations").Body.Bytes(), &response))
+		require.Len(t, response.Values, 1)
+		assert.Equal(t, "Secondary", response.Values[0].SiteName)
+		assert.Equal(t, "ACTIVE", response.Values[0].Status)
+	})
+
+	t.Run("Configured feature flags are sorted by name", func(t *testing.T) {
+		var response types.Page[handlers.FeatureFlagResponse]
+		require.NoError(t, json.Unmarshal(get("/featureFlags").Body.Bytes(), &response))
+		require.Len(t, response.Values, 2)
+		assert.Equal(t, "urn:vcloud:featureFlag:legacy_api", response.Values[0].ID)
+		assert.False(t, response.Values[0].Enabled)
+		assert.Equal(t, "vapp_health", response.Values[1].Name)
+		assert.True(t, response.Values[1].Enabled)
+	})
+
+	t.Run("Configured installation ID", func(t *testing.T) {
+		var response handlers.GeneralSettings
+		require.NoError(t, json.Unmarshal(get("/settings/general").Body.Bytes(), &response))
+		assert.Equal(t, 7, response.InstallationID)
+		assert.Equal(t, 1440, response.AbsoluteSessionTimeoutMinutes)
+		assert.Equal(t, "https://vcd.example.com/api/admin/extension/settings/general", response.Href)
+	})
+}
@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+func TestPublicCatalogBrowse(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	catalogRepo := repositories.NewCatalogRepository(db.DB)
+	catalogItemRepo := repositories.NewCatalogItemRepository(db.DB, catalogRepo)
+	publicCatalogs := handlers.NewPublicCatalogHandlers(catalogRepo, catalogItemRepo)
+
+	org := &models.Organization{Name: "PublicOrg", DisplayName: "Public Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	published := &models.Catalog{Name: "Public Templates", Description: "Templates for everyone", OrganizationID: org.ID, IsPublished: true}
+	require.NoError(t, db.DB.Create(published).Error)
+	private := &models.Catalog{Name: "Private Templates", OrganizationID: org.ID}
+	require.NoError(t, db.DB.Create(private).Error)
+
+	records := []models.CatalogItemRecord{
+		{TemplateUID: "uid-rhel", Name: "rhel9-server", Namespace: "openshift", Description: "RHEL 9", IconClass: "icon-rhel", ValidationStatus: "VALID"},
+		{TemplateUID: "uid-broken", Name: "broken-server", Namespace: "openshift", ValidationStatus: "INVALID"},
+	}
+	require.NoError(t, db.DB.Create(&records).Error)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cloudapi/1.0.0/public/catalogs", publicCatalogs.ListPublicCatalogs)
+	router.GET("/cloudapi/1.0.0/public/catalogs/:catalogUrn/catalogItems", publicCatalogs.ListPublicCatalogItems)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Only published catalogs are listed", func(t *testing.T) {
+		w := get("/cloudapi/1.0.0/public/catalogs")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page struct {
+			ResultTotal int                      `json:"resultTotal"`
+			Values      []handlers.PublicCatalog `json:"values"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, 1, page.ResultTotal)
+		require.Len(t, page.Values, 1)
+		assert.Equal(t, handlers.PublicCatalog{ID: published.ID, Name: "Public Templates", Description: "Templates for everyone"}, page.Values[0])
+	})
+
+	t.Run("Only valid items are listed with their icons", func(t *testing.T) {
+		w := get("/cloudapi/1.0.0/public/catalogs/" + published.ID + "/catalogItems")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page struct {
+			ResultTotal int                          `json:"resultTotal"`
+			Values      []handlers.PublicCatalogItem `json:"values"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, 1, page.ResultTotal)
+		require.Len(t, page.Values, 1)
+		assert.Equal(t, "rhel9-server", page.Values[0].Name)
+		assert.Equal(t, "RHEL 9", page.Values[0].Description)
+		assert.Equal(t, "icon-rhel", page.Values[0].IconClass)
+		assert.NotContains(t, w.Body.String(), "templateUid")
+	})
+
+	t.Run("Unpublished and unknown catalogs are not found", func(t *testing.T) {
+		w := get("/cloudapi/1.0.0/public/catalogs/" + private.ID + "/catalogItems")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = get("/cloudapi/1.0.0/public/catalogs/urn:vcloud:catalog:00000000-0000-0000-0000-000000000000/catalogItems")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+// queryCounter records the SQL statements run through a database
+type queryCounter struct {
+	mu         sync.Mutex
+	enabled    bool
+	statements []string
+}
+
+// newQueryCounter registers callbacks on db that record every statement sent
+// to the database while the counter is enabled
+func newQueryCounter(t *testing.T, db *gorm.DB) *queryCounter {
+	t.Helper()
+	counter := &queryCounter{}
+	record := func(tx *gorm.DB) {
+		counter.mu.Lock()
+		defer counter.mu.Unlock()
+		// Subqueries are built with dry runs that never reach the database
+		if counter.enabled && !tx.DryRun {
+			counter.statements = append(counter.statements, tx.Statement.SQL.String())
+		}
+	}
+	callbacks := db.Callback()
+	require.NoError(t, callbacks.Query().After("gorm:query").Register("test:count_query", record))
+	require.NoError(t, callbacks.Row().After("gorm:row").Register("test:count_row", record))
+	require.NoError(t, callbacks.Raw().After("gorm:raw").Register("test:count_raw", record))
+	require.NoError(t, callbacks.Create().After("gorm:create").Register("test:count_create", record))
+	require.NoError(t, callbacks.Update().After("gorm:update").Register("test:count_update", record))
+	require.NoError(t, callbacks.Delete().After("gorm:delete").Register("test:count_delete", record))
+	return counter
+}
+
+// count runs fn and returns the statements it ran
+func (q *queryCounter) count(fn func()) []string {
+	q.mu.Lock()
+	q.enabled, q.statements = true, nil
+	q.mu.Unlock()
+
+	fn()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.enabled = false
+	return q.statements
+}
+
+// assertQueryBudget serves req and checks that it succeeds with at most budget
+// SQL statements, listing them when it does not
+func assertQueryBudget(t *testing.T, counter *queryCounter, router http.Handler, req *http.Request, budget int) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	statements := counter.count(func() { router.ServeHTTP(w, req) })
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.LessOrEqual(t, len(statements), budget,
+		"%s %s ran %d SQL statements:\n%s", req.Method, req.URL.Path, len(statements), strings.Join(statements, "\n"))
+}
+
+func TestListEndpointQueryBudgets(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+	counter := newQueryCounter(t, db.DB)
+
+	org := &models.Organization{Name: "BudgetOrg", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "budgetuser", Email: "budget@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	token, err := jwtManager.GenerateWithRole(user.ID, user.Username, org.ID, models.RoleVAppUser)
+	require.NoError(t, err)
+
+	var vdc *models.VDC
+	var vapp *models.VApp
+	created := 0
+	// addRows grows the tree so budgets are checked to hold regardless of
+	// how many rows a page contains
+	addRows := func(n int) {
+		created++
+		for i := 0; i < n; i++ {
+			vdc = &models.VDC{Name: fmt.Sprintf("budget-vdc-%d-%d", created, i), OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+			require.NoError(t, db.DB.Create(vdc).Error)
+			require.NoError(t, db.DB.Create(&models.VDCStorageProfile{VDCID: vdc.ID, Name: "standard"}).Error)
+		}
+		for i := 0; i < n; i++ {
+			vapp = &models.VApp{Name: fmt.Sprintf("budget-vapp-%d-%d", created, i), VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+			require.NoError(t, db.DB.Create(vapp).Error)
+			for j := 0; j < n; j++ {
+				vm := &models.VM{Name: fmt.Sprintf("budget-vm-%d-%d-%d", created, i, j), VAppID: vapp.ID, Status: "POWERED_ON"}
+				require.NoError(t, db.DB.Create(vm).Error)
+			}
+		}
+	}
+
+	budgets := []struct {
+		name   string
+		path   func() string
+		budget int
+	}{
+		// access check, VDC page, storage profile preload, compute usage, count
+		{"GET /vdcs", func() string { return "/cloudapi/1.0.0/vdcs" }, 5},
+		// access check, vApp page with VM counts, count
+		{"GET /vdcs/{id}/vapps", func() string { return "/cloudapi/1.0.0/vdcs/" + vdc.ID + "/vapps" }, 4},
+		// vApp with its VDC, access check, VM page, count
+		{"GET /vapps/{id}/vms", func() string { return "/cloudapi/1.0.0/vapps/" + vapp.ID + "/vms" }, 6},
+		// vApp with its VDC, access check, VMs
+		{"GET /vapps/{id}", func() string { return "/cloudapi/1.0.0/vapps/" + vapp.ID }, 5},
+	}
+
+	for _, rows := range []int{1, 5} {
+		addRows(rows)
+		for _, tc := range budgets {
+			t.Run(fmt.Sprintf("%s with %d rows", tc.name, rows), func(t *testing.T) {
+				req, _ := http.NewRequest("GET", tc.path(), nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				assertQueryBudget(t, counter, router, req, tc.budget)
+			})
+		}
+	}
+}
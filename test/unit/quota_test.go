@@ -0,0 +1,265 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+const mib = 1024 * 1024
+
+func TestQuotaService(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "QuotaOrg", IsEnabled: true}
+	require.NoError(t, db.Create(org).Error)
+	vdc := &models.VDC{
+		Name:              "quota-vdc",
+		OrganizationID:    org.ID,
+		AllocationModel:   models.AllocationPool,
+		CPULimit:          8,
+		CPUUnits:          "cores",
+		MemoryLimit:       8192,
+		SoftQuotaPercent:  75,
+		QuotaGracePercent: 25,
+		IsEnabled:         true,
+	}
+	require.NoError(t, db.Create(vdc).Error)
+	vapp := &models.VApp{Name: "quota-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.Create(vapp).Error)
+	require.NoError(t, db.Create(&models.VM{Name: "vm-1", VAppID: vapp.ID, VMName: "vm-1", Namespace: "ns", CPUCount: intPtr(4), MemoryMB: intPtr(4096)}).Error)
+
+	vdcRepo := repositories.NewVDCRepository(db)
+	bus := events.NewBus()
+	sub := bus.Subscribe(10)
+	defer sub.Close()
+	quota := services.NewQuotaService(vdcRepo, bus, time.Hour)
+
+	reload := func(t *testing.T) *models.VDC {
+		loaded, err := vdcRepo.GetByID(vdc.ID)
+		require.NoError(t, err)
+		return loaded
+	}
+
+	t.Run("Sums the compute reserved by the VDC's VMs", func(t *testing.T) {
+		usage, err := vdcRepo.ComputeUsage(ctx, vdc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ComputeUsage{VMs: 1, CPUs: 4, MemoryMB: 4096}, usage)
+	})
+
+	t.Run("Allocations below the soft quota have no warnings", func(t *testing.T) {
+		decision, err := quota.Check(ctx, reload(t), services.ResourceUsage{CPUs: 1, MemoryBytes: 1024 * mib})
+		require.NoError(t, err)
+		assert.Empty(t, decision.Warnings)
+		assert.Nil(t, decision.GraceExpiresAt)
+		assert.Empty(t, sub.C)
+	})
+
+	t.Run("Crossing the soft quota warns and emits an event", func(t *testing.T) {
+		decision, err := quota.Check(ctx, reload(t), services.ResourceUsage{CPUs: 2, MemoryBytes: 2048 * mib})
+		require.NoError(t, err)
+		require.Len(t, decision.Warnings, 2)
+		assert.Contains(t, decision.Warnings[0], "memory allocation of 6144 MB is 75% of the VDC limit")
+		assert.Contains(t, decision.Warnings[1], "CPU allocation of 6 cores is 75% of the VDC limit")
+		assert.Nil(t, decision.GraceExpiresAt)
+
+		event := <-sub.C
+		assert.Equal(t, events.TypeVDCQuotaWarning, event.Type)
+		assert.Equal(t, events.EntityVDC, event.EntityType)
+		assert.Equal(t, vdc.ID, event.EntityID)
+		assert.Equal(t, org.ID, event.OrgID)
+		assert.Equal(t, decision.Warnings, event.Data["warnings"])
+	})
+
+	t.Run("Exceeding the hard limit uses the grace allowance", func(t *testing.T) {
+		decision, err := quota.Check(ctx, reload(t), services.ResourceUsage{CPUs: 1, MemoryBytes: 5120 * mib})
+		require.NoError(t, err)
+		require.Len(t, decision.Warnings, 1)
+		assert.Contains(t, decision.Warnings[0], "exceeds the VDC limit of 8192 MB and uses the grace allowance of 25%")
+		require.NotNil(t, decision.GraceExpiresAt)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *decision.GraceExpiresAt, time.Minute)
+
+		startedAt := reload(t).QuotaGraceStartedAt
+		require.NotNil(t, startedAt)
+		event := <-sub.C
+		assert.Contains(t, event.Data, "graceExpiresAt")
+
+		// Later grace allocations keep the original grace period
+		decision, err = quota.Check(ctx, reload(t), services.ResourceUsage{MemoryBytes: 5000 * mib})
+		require.NoError(t, err)
+		assert.True(t, startedAt.Equal(*reload(t).QuotaGraceStartedAt))
+		assert.WithinDuration(t, startedAt.Add(time.Hour), *decision.GraceExpiresAt, time.Second)
+	})
+
+	t.Run("Allocations beyond the grace ceiling are refused", func(t *testing.T) {
+		_, err := quota.Check(ctx, reload(t), services.ResourceUsage{MemoryBytes: 6200 * mib})
+		assert.ErrorIs(t, err, services.ErrQuotaExceeded)
+		assert.ErrorContains(t, err, "memory allocation of 10296 MB would exceed the limit of 8192 MB")
+	})
+
+	t.Run("Grace allocations are refused once the grace period ends", func(t *testing.T) {
+		expired := time.Now().Add(-2 * time.Hour)
+		require.NoError(t, vdcRepo.SetQuotaGraceStartedAt(ctx, vdc.ID, &expired))
+
+		_, err := quota.Check(ctx, reload(t), services.ResourceUsage{MemoryBytes: 5120 * mib})
+		assert.ErrorIs(t, err, services.ErrQuotaExceeded)
+		assert.ErrorContains(t, err, "grace period")
+	})
+
+	t.Run("Returning under the limits ends the grace period", func(t *testing.T) {
+		_, err := quota.Check(ctx, reload(t), services.ResourceUsage{MemoryBytes: 512 * mib})
+		require.NoError(t, err)
+		assert.Nil(t, reload(t).QuotaGraceStartedAt)
+	})
+
+	t.Run("Without a grace allowance the limit is a hard ceiling", func(t *testing.T) {
+		strict := reload(t)
+		strict.QuotaGracePercent = 0
+		_, err := quota.Check(ctx, strict, services.ResourceUsage{MemoryBytes: 4097 * mib})
+		assert.ErrorIs(t, err, services.ErrQuotaExceeded)
+	})
+
+	t.Run("CPU limits in MHz are not enforced", func(t *testing.T) {
+		mhz := reload(t)
+		mhz.CPUUnits = "MHz"
+		decision, err := quota.Check(ctx, mhz, services.ResourceUsage{CPUs: 100})
+		require.NoError(t, err)
+		assert.Empty(t, decision.Warnings)
+	})
+}
+
+func TestOrgQuota(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "OrgQuotaOrg", IsEnabled: true}
+	org.SetQuota(&models.OrgQuota{CPUCores: 10, VMCount: 3, StorageMB: 20480})
+	require.NoError(t, db.Create(org).Error)
+	orgRepo := repositories.NewOrganizationRepository(db)
+	vdcRepo := repositories.NewVDCRepository(db)
+
+	// Each VDC is well within its own limits, but together they use most of
+	// the organization's quota
+	var vdcs []*models.VDC
+	for i, name := range []string{"org-quota-a", "org-quota-b"} {
+		vdc := &models.VDC{Name: name, OrganizationID: org.ID, AllocationModel: models.AllocationPool,
+			CPULimit: 16, CPUUnits: "cores", MemoryLimit: 65536, IsEnabled: true}
+		require.NoError(t, db.Create(vdc).Error)
+		vapp := &models.VApp{Name: name + "-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+		require.NoError(t, db.Create(vapp).Error)
+		require.NoError(t, db.Create(&models.VM{Name: name + "-vm", VAppID: vapp.ID, VMName: name, Namespace: "ns",
+			CPUCount: intPtr(4), MemoryMB: intPtr(2048)}).Error, i)
+		require.NoError(t, db.Create(&models.VDCStorageProfile{VDCID: vdc.ID, Name: "standard", UsedMB: 8192}).Error)
+		vdcs = append(vdcs, vdc)
+	}
+
+	quota := services.NewQuotaService(vdcRepo, nil, time.Hour)
+	quota.SetOrgQuotas(orgRepo)
+
+	t.Run("Sums usage across the organization's VDCs", func(t *testing.T) {
+		usage, err := orgRepo.GetQuotaUsage(ctx, org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.OrgQuotaUsage{CPUCores: 8, MemoryMB: 4096, StorageMB: 16384, VMCount: 2}, usage)
+
+		rollup, err := orgRepo.GetRollup(ctx, org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, usage, rollup.QuotaUsage)
+		require.NotNil(t, rollup.Quota)
+		assert.Equal(t, int64(10), rollup.Quota.CPUCores)
+	})
+
+	t.Run("Allocations within the quota are permitted", func(t *testing.T) {
+		_, err := quota.Check(ctx, vdcs[0], services.ResourceUsage{CPUs: 2, MemoryBytes: 1024 * mib, VMs: 1})
+		require.NoError(t, err)
+	})
+
+	t.Run("Allocations over the quota are refused in any VDC", func(t *testing.T) {
+		for _, vdc := range vdcs {
+			_, err := quota.Check(ctx, vdc, services.ResourceUsage{CPUs: 4, VMs: 1})
+			assert.ErrorIs(t, err, services.ErrOrgQuotaExceeded)
+			assert.ErrorContains(t, err, "CPU allocation of 12 cores would exceed the organization limit of 10 cores")
+			assert.Nil(t, vdc.QuotaGraceStartedAt)
+		}
+
+		_, err := quota.Check(ctx, vdcs[1], services.ResourceUsage{VMs: 2})
+		assert.ErrorIs(t, err, services.ErrOrgQuotaExceeded)
+		_, err = quota.Check(ctx, vdcs[1], services.ResourceUsage{StorageBytes: 8192 * mib})
+		assert.ErrorIs(t, err, services.ErrOrgQuotaExceeded)
+	})
+
+	t.Run("Removing the quota lifts the ceilings", func(t *testing.T) {
+		org.SetQuota(&models.OrgQuota{})
+		require.NoError(t, db.Save(org).Error)
+		_, err := quota.Check(ctx, vdcs[0], services.ResourceUsage{CPUs: 4, VMs: 2})
+		require.NoError(t, err)
+	})
+}
+
+func TestOrgQuotaAPI(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+	sysAdmin := &models.User{Username: "quotaadmin", Email: "quotaadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+	adminToken, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	user := &models.User{Username: "quotauser", Email: "quotauser@example.com", Enabled: true}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	userToken, err := jwtManager.Generate(user.ID, user.Username)
+	require.NoError(t, err)
+
+	org := &models.Organization{Name: "quota-api-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Only system administrators set quotas", func(t *testing.T) {
+		w := request(http.MethodPut, "/cloudapi/1.0.0/orgs/"+org.ID, userToken, `{"quota":{"cpuCores":4}}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = request(http.MethodPut, "/cloudapi/1.0.0/orgs/"+org.ID, adminToken, `{"quota":{"cpuCores":-1}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Quotas are reported with their usage", func(t *testing.T) {
+		w := request(http.MethodPut, "/cloudapi/1.0.0/orgs/"+org.ID, adminToken, `{"quota":{"cpuCores":64,"vmCount":20}}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var updated models.Organization
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		require.NotNil(t, updated.Quota)
+		assert.Equal(t, models.OrgQuota{CPUCores: 64, VMCount: 20}, *updated.Quota)
+
+		w = request(http.MethodGet, "/cloudapi/1.0.0/orgs/"+org.ID+"/rollup", adminToken, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var rollup models.OrganizationRollup
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rollup))
+		require.NotNil(t, rollup.Quota)
+		assert.Equal(t, int64(64), rollup.Quota.CPUCores)
+		assert.Equal(t, models.OrgQuotaUsage{}, rollup.QuotaUsage)
+	})
+}
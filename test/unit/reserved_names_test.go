@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+func TestReservedNamePrefixes(t *testing.T) {
+	reserved := handlers.NewReservedNamePrefixes([]string{"Kube", " openshift ", "", "vdc-"})
+
+	prefix, ok := reserved.Match("kube-system")
+	assert.True(t, ok)
+	assert.Equal(t, "kube", prefix)
+	_, ok = reserved.Match("OpenShift-Monitoring")
+	assert.True(t, ok)
+	_, ok = reserved.Match("vdc-dev")
+	assert.True(t, ok)
+	_, ok = reserved.Match("my-vdc-dev")
+	assert.False(t, ok)
+	_, ok = handlers.ReservedNamePrefixes(nil).Match("kube-system")
+	assert.False(t, ok)
+}
+
+func TestReservedNamesRefused(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "Reserved", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "reserveduser", Email: "reserved@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "reserved-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+
+	reserved := handlers.NewReservedNamePrefixes([]string{"kube", "openshift", "vdc-"})
+	mockK8s := &MockKubernetesService{}
+	mockK8s.On("NamespaceExists", mock.Anything, mock.Anything).Return(false, nil)
+	mockK8s.On("CreateNamespaceForVDC", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vdcHandlers := handlers.NewVDCHandlers(vdcRepo, repositories.NewOrganizationRepository(db.DB), repositories.NewUserRepository(db.DB), mockK8s)
+	vdcHandlers.SetReservedNamePrefixes(reserved)
+	creation := handlers.NewVMCreationHandlers(vdcRepo, vappRepo,
+		repositories.NewCatalogItemRepository(nil, nil), repositories.NewCatalogRepository(db.DB),
+		auth.NewAccessControl(vdcRepo, vappRepo, repositories.NewVMRepository(db.DB)), mockK8s)
+	creation.SetReservedNamePrefixes(reserved)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+	})
+	router.POST("/api/admin/org/:orgId/vdcs", vdcHandlers.CreateVDC)
+	router.POST("/cloudapi/1.0.0/vdcs/:vdc_id/actions/instantiateTemplate", creation.InstantiateTemplate)
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("VDC names", func(t *testing.T) {
+		w := post("/api/admin/org/"+org.ID+"/vdcs", map[string]interface{}{"name": "Kube-Prod", "allocationModel": "PayAsYouGo"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Name uses a reserved prefix")
+
+		w = post("/api/admin/org/"+org.ID+"/vdcs", map[string]interface{}{"name": "Prod", "allocationModel": "PayAsYouGo"})
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("vApp names", func(t *testing.T) {
+		for _, name := range []string{"openshift-apps", "vdc-web", "kubernetes"} {
+			w := post("/cloudapi/1.0.0/vdcs/"+vdc.ID+"/actions/instantiateTemplate", handlers.InstantiateTemplateRequest{
+				Name:        name,
+				CatalogItem: handlers.CatalogItem{ID: "urn:vcloud:catalogitem:rhel9-server", Name: "rhel9-server"},
+			})
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Contains(t, w.Body.String(), "Name uses a reserved prefix", name)
+		}
+	})
+}
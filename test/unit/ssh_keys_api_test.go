@@ -0,0 +1,167 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func newAuthorizedKey(t *testing.T, comment string) string {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshKey, err := ssh.NewPublicKey(publicKey)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshKey))) + " " + comment
+}
+
+func TestSSHKeyAPIEndpoints(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	org := &models.Organization{Name: "SSH Org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+
+	newUser := func(name string, roles ...*models.Role) (*models.User, string) {
+		user := &models.User{
+			Username:       name,
+			Email:          name + "@example.com",
+			Enabled:        true,
+			OrganizationID: stringPtr(org.ID),
+		}
+		require.NoError(t, user.SetPassword("password123"))
+		require.NoError(t, db.DB.Create(user).Error)
+		for _, role := range roles {
+			require.NoError(t, db.DB.Model(user).Association("Roles").Append(role))
+		}
+		token, err := jwtManager.GenerateWithRole(user.ID, user.Username, org.ID, models.RoleVAppUser)
+		require.NoError(t, err)
+		return user, token
+	}
+
+	owner, ownerToken := newUser("owner")
+	_, otherToken := newUser("other")
+	_, adminToken := newUser("sysadmin", sysAdminRole)
+
+	do := func(method, url, token string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req, _ := http.NewRequest(method, url, &buf)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	keysURL := "/cloudapi/1.0.0/users/" + owner.ID + "/sshKeys"
+	laptopKey := newAuthorizedKey(t, "owner@laptop")
+
+	var created models.SSHKey
+
+	t.Run("Register SSH key", func(t *testing.T) {
+		w := do("POST", keysURL, ownerToken, handlers.SSHKeyRequest{Name: "laptop", PublicKey: laptopKey})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.True(t, strings.HasPrefix(created.ID, models.URNPrefixSSHKey))
+		assert.Equal(t, "laptop", created.Name)
+		assert.Equal(t, laptopKey, created.PublicKey)
+		assert.True(t, strings.HasPrefix(created.Fingerprint, "SHA256:"))
+	})
+
+	t.Run("Invalid public key returns 400", func(t *testing.T) {
+		w := do("POST", keysURL, ownerToken, handlers.SSHKeyRequest{Name: "bad", PublicKey: "ssh-rsa not-a-key"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Duplicate name or key returns 409", func(t *testing.T) {
+		w := do("POST", keysURL, ownerToken, handlers.SSHKeyRequest{Name: "laptop", PublicKey: newAuthorizedKey(t, "")})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = do("POST", keysURL, ownerToken, handlers.SSHKeyRequest{Name: "copy", PublicKey: laptopKey})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("List and get SSH keys", func(t *testing.T) {
+		w := do("GET", keysURL, ownerToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var page types.Page[models.SSHKey]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, int64(1), page.ResultTotal)
+		require.Len(t, page.Values, 1)
+		assert.Equal(t, created.ID, page.Values[0].ID)
+
+		w = do("GET", keysURL+"/"+created.ID, ownerToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = do("GET", keysURL+"/"+models.URNPrefixSSHKey+"00000000-0000-0000-0000-000000000000", ownerToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Other users cannot manage the keys", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do("GET", keysURL, otherToken, nil).Code)
+		assert.Equal(t, http.StatusForbidden, do("DELETE", keysURL+"/"+created.ID, otherToken, nil).Code)
+	})
+
+	t.Run("System administrators can manage any user's keys", func(t *testing.T) {
+		w := do("PUT", keysURL+"/"+created.ID, adminToken, handlers.SSHKeyRequest{Name: "workstation", PublicKey: laptopKey})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var updated models.SSHKey
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, created.ID, updated.ID)
+		assert.Equal(t, "workstation", updated.Name)
+
+		w = do("GET", "/cloudapi/1.0.0/users/"+models.URNPrefixUser+"00000000-0000-0000-0000-000000000000/sshKeys", adminToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Instantiation injects registered keys", func(t *testing.T) {
+		vdc := &models.VDC{
+			Name:            "ssh-vdc",
+			OrganizationID:  org.ID,
+			IsEnabled:       true,
+			AllocationModel: models.AllocationPool,
+		}
+		require.NoError(t, db.DB.Create(vdc).Error)
+		require.NoError(t, db.DB.Create(&models.Catalog{Name: "ssh-catalog", OrganizationID: org.ID}).Error)
+		instantiateURL := "/cloudapi/1.0.0/vdcs/" + vdc.ID + "/actions/instantiateTemplate"
+		request := func(name string) handlers.InstantiateTemplateRequest {
+			return handlers.InstantiateTemplateRequest{
+				Name:          name,
+				CatalogItem:   handlers.CatalogItem{ID: "urn:vcloud:catalogitem:fedora", Name: "fedora"},
+				InjectSSHKeys: true,
+			}
+		}
+
+		w := do("POST", instantiateURL, otherToken, request("no-keys"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "No SSH keys registered")
+
+		w = do("POST", instantiateURL, ownerToken, request("with-keys"))
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("Delete SSH key", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do("DELETE", keysURL+"/"+created.ID, ownerToken, nil).Code)
+		assert.Equal(t, http.StatusNotFound, do("DELETE", keysURL+"/"+created.ID, ownerToken, nil).Code)
+	})
+}
@@ -0,0 +1,237 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func subsystemStatuses(report *services.SystemStatusReport) map[string]string {
+	statuses := map[string]string{}
+	for _, subsystem := range report.Subsystems {
+		statuses[subsystem.Name] = subsystem.Status
+	}
+	return statuses
+}
+
+func TestSystemStatusReporter(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	ctx := context.Background()
+	heartbeatRepo := repositories.NewComponentHeartbeatRepository(db.DB)
+	taskRepo := repositories.NewTaskRepository(db.DB)
+	statusRepo := repositories.NewSystemStatusRepository(db.DB)
+	report := func(errors *services.ServerErrorWindow) *services.SystemStatusReport {
+		result, err := services.NewSystemStatusReporter(heartbeatRepo, taskRepo, statusRepo, errors).Report(ctx)
+		require.NoError(t, err)
+		return result
+	}
+	now := time.Now()
+
+	t.Run("Operational without problems", func(t *testing.T) {
+		result := report(nil)
+		assert.Equal(t, models.SystemStatusOperational, result.Status)
+		assert.Equal(t, map[string]string{
+			models.SubsystemAPI:          models.SystemStatusOperational,
+			models.SubsystemProvisioning: models.SystemStatusOperational,
+			models.SubsystemNetworking:   models.SystemStatusOperational,
+		}, subsystemStatuses(result))
+		assert.Nil(t, result.Notice)
+	})
+
+	t.Run("API server errors degrade the API", func(t *testing.T) {
+		errors := services.NewServerErrorWindow(5 * time.Minute)
+		for i := 0; i < 18; i++ {
+			errors.Record(false)
+		}
+		// Too few requests to judge
+		errors.Record(true)
+		assert.Equal(t, models.SystemStatusOperational, subsystemStatuses(report(errors))[models.SubsystemAPI])
+
+		errors.Record(true)
+		errors.Record(true)
+		result := report(errors)
+		assert.Equal(t, models.SystemStatusDegraded, subsystemStatuses(result)[models.SubsystemAPI])
+		assert.Equal(t, models.SystemStatusDegraded, result.Status)
+	})
+
+	t.Run("Failed tasks degrade provisioning", func(t *testing.T) {
+		for i, status := range []string{models.TaskStatusSuccess, models.TaskStatusSuccess, models.TaskStatusError, models.TaskStatusError, models.TaskStatusSuccess} {
+			task := &models.Task{Name: models.TaskOperationVMPowerOn, Operation: "Powering on VM", Status: models.TaskStatusRunning}
+			require.NoError(t, taskRepo.Create(ctx, task), i)
+			require.NoError(t, taskRepo.UpdateStatus(ctx, task.ID, status, 100, ""))
+		}
+		assert.Equal(t, models.SystemStatusDegraded, subsystemStatuses(report(nil))[models.SubsystemProvisioning])
+
+		// Failures of other operations do not count
+		counts, err := taskRepo.CountFinishedSince(ctx, []string{models.TaskOperationUserImport}, now.Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, counts)
+		require.NoError(t, db.DB.Where("1 = 1").Delete(&models.Task{}).Error)
+	})
+
+	t.Run("A stopped vm-controller takes provisioning out", func(t *testing.T) {
+		require.NoError(t, heartbeatRepo.Upsert(ctx, &models.ComponentHeartbeat{
+			InstanceID: "vm-controller-a", Component: "vm-controller", Leader: true,
+			IntervalSeconds: 30, StartedAt: now.Add(-time.Hour), LastHeartbeat: now.Add(-10 * time.Minute),
+		}))
+		result := report(nil)
+		assert.Equal(t, models.SystemStatusOutage, subsystemStatuses(result)[models.SubsystemProvisioning])
+		assert.Equal(t, models.SystemStatusOperational, subsystemStatuses(result)[models.SubsystemNetworking])
+		assert.Equal(t, models.SystemStatusOutage, result.Status)
+	})
+
+	t.Run("Failing network controllers degrade networking", func(t *testing.T) {
+		lastSuccess := now.Add(-time.Hour)
+		leader := &models.ComponentHeartbeat{
+			InstanceID: "vm-controller-a", Component: "vm-controller", Leader: true,
+			IntervalSeconds: 30, StartedAt: now.Add(-2 * time.Hour), LastHeartbeat: now,
+		}
+		leader.SetControllers([]models.ControllerHeartbeat{
+			{Name: "ssvirt_vmstatus", LastReconcile: &now, LastSuccess: &now},
+			{Name: "ssvirt_externaldns", LastReconcile: &now, LastSuccess: &lastSuccess},
+		})
+		require.NoError(t, heartbeatRepo.Upsert(ctx, leader))
+
+		result := report(nil)
+		assert.Equal(t, models.SystemStatusOperational, subsystemStatuses(result)[models.SubsystemProvisioning])
+		assert.Equal(t, models.SystemStatusDegraded, subsystemStatuses(result)[models.SubsystemNetworking])
+		assert.Equal(t, models.SystemStatusDegraded, result.Status)
+	})
+
+	t.Run("Overrides replace the measured status while in effect", func(t *testing.T) {
+		startsAt := now.Add(time.Hour)
+		endsAt := now.Add(2 * time.Hour)
+		override := &models.SystemStatusOverride{Status: models.SystemStatusMaintenance, Message: "Storage upgrade", StartsAt: &startsAt, EndsAt: &endsAt}
+		override.SetSubsystems([]string{models.SubsystemProvisioning})
+		require.NoError(t, statusRepo.SaveOverride(ctx, override))
+
+		// Planned maintenance is announced before it starts
+		result := report(nil)
+		assert.Equal(t, models.SystemStatusOperational, subsystemStatuses(result)[models.SubsystemProvisioning])
+		require.NotNil(t, result.Notice)
+		assert.False(t, result.Notice.Active)
+		assert.Equal(t, "Storage upgrade", result.Notice.Message)
+
+		startsAt = now.Add(-time.Minute)
+		require.NoError(t, statusRepo.SaveOverride(ctx, override))
+		result = report(nil)
+		require.NotNil(t, result.Notice)
+		assert.True(t, result.Notice.Active)
+		assert.Equal(t, map[string]string{
+			models.SubsystemAPI:          models.SystemStatusOperational,
+			models.SubsystemProvisioning: models.SystemStatusMaintenance,
+			models.SubsystemNetworking:   models.SystemStatusDegraded,
+		}, subsystemStatuses(result))
+		assert.Equal(t, models.SystemStatusDegraded, result.Status)
+		for _, subsystem := range result.Subsystems {
+			if subsystem.Name == models.SubsystemProvisioning {
+				assert.Equal(t, "Storage upgrade", subsystem.Message)
+			}
+		}
+
+		// Expired overrides are ignored
+		endsAt = now.Add(-time.Second)
+		startsAt = now.Add(-time.Hour)
+		require.NoError(t, statusRepo.SaveOverride(ctx, override))
+		result = report(nil)
+		assert.Nil(t, result.Notice)
+		assert.Equal(t, models.SystemStatusOperational, subsystemStatuses(result)[models.SubsystemProvisioning])
+	})
+}
+
+func TestSystemStatusAPI(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+	sysAdmin := &models.User{Username: "statusadmin", Email: "statusadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+	adminToken, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	user := &models.User{Username: "statususer", Email: "statususer@example.com", Enabled: true}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	userToken, err := jwtManager.Generate(user.ID, user.Username)
+	require.NoError(t, err)
+
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	status := func() services.SystemStatusReport {
+		w := request(http.MethodGet, "/cloudapi/1.0.0/systemStatus", userToken, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var report services.SystemStatusReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return report
+	}
+
+	t.Run("Any user can read the status", func(t *testing.T) {
+		report := status()
+		assert.Equal(t, models.SystemStatusOperational, report.Status)
+		assert.Len(t, report.Subsystems, 3)
+	})
+
+	t.Run("Only system administrators manage the override", func(t *testing.T) {
+		w := request(http.MethodPut, "/api/admin/system/statusOverride", userToken, `{"status":"maintenance"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = request(http.MethodGet, "/api/admin/system/statusOverride", adminToken, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Rejects invalid overrides", func(t *testing.T) {
+		for _, body := range []string{
+			`{"status":"broken"}`,
+			`{"status":"maintenance","subsystems":["storage"]}`,
+			`{"status":"maintenance","startsAt":"2030-01-02T00:00:00Z","endsAt":"2030-01-01T00:00:00Z"}`,
+		} {
+			w := request(http.MethodPut, "/api/admin/system/statusOverride", adminToken, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
+	t.Run("Overrides show at once and can be cleared", func(t *testing.T) {
+		w := request(http.MethodPut, "/api/admin/system/statusOverride", adminToken,
+			`{"status":"maintenance","message":"Planned upgrade until 10:00 UTC"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var override models.SystemStatusOverride
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &override))
+		assert.Equal(t, sysAdmin.ID, override.UpdatedBy)
+
+		report := status()
+		assert.Equal(t, models.SystemStatusMaintenance, report.Status)
+		require.NotNil(t, report.Notice)
+		assert.Equal(t, "Planned upgrade until 10:00 UTC", report.Notice.Message)
+		for _, subsystem := range report.Subsystems {
+			assert.Equal(t, models.SystemStatusMaintenance, subsystem.Status, subsystem.Name)
+		}
+
+		w = request(http.MethodGet, "/api/admin/system/statusOverride", adminToken, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = request(http.MethodDelete, "/api/admin/system/statusOverride", adminToken, "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, models.SystemStatusOperational, status().Status)
+	})
+}
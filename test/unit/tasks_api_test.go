@@ -0,0 +1,151 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/events"
+)
+
+func TestTasksAPI(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+	taskRepo := repositories.NewTaskRepository(db.DB)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "task-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	otherOrg := &models.Organization{Name: "other-task-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(otherOrg).Error)
+
+	user := &models.User{Username: "task-user", Email: "task-user@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	token, err := jwtManager.Generate(user.ID, user.Username)
+	require.NoError(t, err)
+
+	newTask := func(orgID, status string) *models.Task {
+		task := &models.Task{
+			Name:           models.TaskOperationVMPowerOn,
+			Operation:      "Powering on VM test-vm",
+			Status:         status,
+			OwnerID:        "urn:vcloud:vm:11111111-1111-1111-1111-111111111111",
+			OwnerName:      "test-vm",
+			OrganizationID: orgID,
+		}
+		require.NoError(t, taskRepo.Create(ctx, task))
+		return task
+	}
+
+	getTask := func(path string) (*httptest.ResponseRecorder, handlers.TaskResponse) {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response handlers.TaskResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w, response
+	}
+
+	t.Run("Requires authentication", func(t *testing.T) {
+		task := newTask(org.ID, models.TaskStatusRunning)
+		req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/tasks/"+task.ID, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Returns task immediately without waitFor", func(t *testing.T) {
+		task := newTask(org.ID, models.TaskStatusRunning)
+		start := time.Now()
+		w, response := getTask("/cloudapi/1.0.0/tasks/" + task.ID)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, task.ID, response.ID)
+		assert.Equal(t, models.TaskStatusRunning, response.Status)
+		assert.Equal(t, "test-vm", response.Owner.Name)
+		require.NotNil(t, response.Org)
+		assert.Equal(t, org.ID, response.Org.ID)
+		assert.Equal(t, "/cloudapi/1.0.0/tasks/"+task.ID, response.Href)
+	})
+
+	t.Run("Returns immediately when status already matches", func(t *testing.T) {
+		task := newTask(org.ID, models.TaskStatusRunning)
+		start := time.Now()
+		w, response := getTask("/cloudapi/1.0.0/tasks/" + task.ID + "?waitFor=RUNNING,SUCCESS&timeout=5s")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, models.TaskStatusRunning, response.Status)
+	})
+
+	t.Run("Blocks until task completes", func(t *testing.T) {
+		task := newTask(org.ID, models.TaskStatusRunning)
+
+		go func() {
+			assert.Eventually(t, func() bool { return server.EventBus().SubscriberCount() > 0 }, 2*time.Second, 10*time.Millisecond)
+			_ = taskRepo.UpdateStatus(ctx, task.ID, models.TaskStatusSuccess, 100, "")
+			server.EventBus().Publish(events.Event{Type: events.TypeTaskUpdated, EntityType: events.EntityTask, EntityID: task.ID, OrgID: org.ID})
+		}()
+
+		start := time.Now()
+		w, response := getTask("/cloudapi/1.0.0/tasks/" + task.ID + "?waitFor=SUCCESS&timeout=10s")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		assert.Equal(t, models.TaskStatusSuccess, response.Status)
+		assert.NotNil(t, response.EndTime)
+	})
+
+	t.Run("Terminal status ends the wait", func(t *testing.T) {
+		task := newTask(org.ID, models.TaskStatusRunning)
+		require.NoError(t, taskRepo.UpdateStatus(ctx, task.ID, models.TaskStatusError, 100, "VM entered ERROR state"))
+
+		w, response := getTask("/cloudapi/1.0.0/tasks/" + task.ID + "?waitFor=SUCCESS&timeout=10s")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.TaskStatusError, response.Status)
+		assert.Equal(t, "VM entered ERROR state", response.ErrorMessage)
+	})
+
+	t.Run("Timeout returns current state", func(t *testing.T) {
+		task := newTask(org.ID, models.TaskStatusQueued)
+		start := time.Now()
+		w, response := getTask("/cloudapi/1.0.0/tasks/" + task.ID + "?waitFor=success&timeout=200ms")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+		assert.Equal(t, models.TaskStatusQueued, response.Status)
+	})
+
+	t.Run("Invalid parameters return 400", func(t *testing.T) {
+		task := newTask(org.ID, models.TaskStatusRunning)
+		w, _ := getTask("/cloudapi/1.0.0/tasks/" + task.ID + "?waitFor=FINISHED")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w, _ = getTask("/cloudapi/1.0.0/tasks/" + task.ID + "?waitFor=SUCCESS&timeout=soon")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w, _ = getTask("/cloudapi/1.0.0/tasks/" + task.ID + "?waitFor=SUCCESS&timeout=10m")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w, _ = getTask("/cloudapi/1.0.0/tasks/not-a-task")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Unknown task returns 404", func(t *testing.T) {
+		w, _ := getTask("/cloudapi/1.0.0/tasks/urn:vcloud:task:99999999-9999-9999-9999-999999999999")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Task in another organization returns 404", func(t *testing.T) {
+		task := newTask(otherOrg.ID, models.TaskStatusRunning)
+		w, _ := getTask("/cloudapi/1.0.0/tasks/" + task.ID)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,107 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// newTemplateAccessClientset returns a clientset holding namespaces whose
+// access reviews deny the given resources
+func newTemplateAccessClientset(namespaces []string, deniedResources ...string) *fake.Clientset {
+	objects := make([]runtime.Object, 0, len(namespaces))
+	for _, name := range namespaces {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	clientset := fake.NewSimpleClientset(objects...)
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = true
+		for _, resource := range deniedResources {
+			if review.Spec.ResourceAttributes.Resource == resource {
+				review.Status.Allowed = false
+			}
+		}
+		return true, review, nil
+	})
+	return clientset
+}
+
+func TestTemplateAccessChecker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Ready when namespaces exist and access is granted", func(t *testing.T) {
+		clientset := newTemplateAccessClientset([]string{"openshift", "ssvirt-templates"})
+		checker := services.NewTemplateAccessCheckerForClientset(clientset, []string{"ssvirt-templates", "openshift", "openshift", ""}, 0, nil)
+
+		_, checked := checker.Status()
+		assert.False(t, checked)
+
+		status := checker.Check(ctx)
+		assert.True(t, status.Ready)
+		require.Len(t, status.Namespaces, 2)
+		assert.Equal(t, "openshift", status.Namespaces[0].Namespace)
+		assert.Empty(t, status.Problems)
+
+		recorded, checked := checker.Status()
+		assert.True(t, checked)
+		assert.True(t, recorded.Ready)
+	})
+
+	t.Run("Reports missing namespaces and permissions", func(t *testing.T) {
+		clientset := newTemplateAccessClientset([]string{"openshift"}, "templateinstances")
+		checker := services.NewTemplateAccessCheckerForClientset(clientset, []string{"openshift", "missing-templates"}, 0, nil)
+
+		status := checker.Check(ctx)
+		assert.False(t, status.Ready)
+		require.Len(t, status.Namespaces, 2)
+		missing := status.Namespaces[0]
+		assert.Equal(t, "missing-templates", missing.Namespace)
+		assert.False(t, missing.Ready)
+		require.Len(t, missing.Problems, 1)
+		assert.Contains(t, missing.Problems[0], "does not exist")
+		assert.True(t, status.Namespaces[1].Ready)
+
+		// create, get and delete on TemplateInstances are each reported
+		require.Len(t, status.Problems, 3)
+		assert.Contains(t, status.Problems[0], "create templateinstances.template.openshift.io in all namespaces")
+	})
+
+	t.Run("Reported through readyz", func(t *testing.T) {
+		server, _, _ := setupTestAPIServer(t)
+		checker := services.NewTemplateAccessCheckerForClientset(newTemplateAccessClientset(nil), []string{"openshift"}, 0, nil)
+		server.SetTemplateAccessChecker(checker)
+
+		readyz := func() map[string]interface{} {
+			req, _ := http.NewRequest("GET", "/readyz", nil)
+			w := httptest.NewRecorder()
+			server.GetRouter().ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			return body
+		}
+
+		assert.Equal(t, "pending", readyz()["services"].(map[string]interface{})["templates"])
+
+		checker.Check(ctx)
+		body := readyz()
+		assert.Equal(t, "unavailable", body["services"].(map[string]interface{})["templates"])
+		templateAccess := body["templateAccess"].(map[string]interface{})
+		assert.Equal(t, false, templateAccess["ready"])
+		assert.Len(t, templateAccess["namespaces"], 1)
+	})
+}
@@ -0,0 +1,356 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+const tenantSpecYAML = `
+organizations:
+  - name: engineering
+    description: Product teams
+    users:
+      - username: alice
+        email: alice@example.com
+        roles: [Organization Administrator]
+        password: alice-password
+      - username: bob
+        email: bob@example.com
+        fullName: Bob Jones
+        roles: [vApp User]
+        enabled: false
+    vdcs:
+      - name: dev
+        computeCapacity:
+          cpu: {allocated: 8000, limit: 16000, units: MHz}
+          memory: {allocated: 16384, limit: 32768, units: MB}
+    catalogs:
+      - name: golden-images
+        published: true
+`
+
+// createTenantRoles creates the roles tenant specs may assign
+func createTenantRoles(t *testing.T, db *database.DB) {
+	for _, name := range []string{models.RoleSystemAdmin, models.RoleOrgAdmin, models.RoleVAppUser} {
+		require.NoError(t, db.DB.Create(&models.Role{Name: name}).Error)
+	}
+}
+
+func TestApplyTenants(t *testing.T) {
+	db := &database.DB{DB: setupTestDB(t)}
+	createTenantRoles(t, db)
+	ctx := context.Background()
+	userRepo := repositories.NewUserRepository(db.DB)
+	orgRepo := repositories.NewOrganizationRepository(db.DB)
+
+	spec, err := database.ParseTenantSpec([]byte(tenantSpecYAML))
+	require.NoError(t, err)
+	opts := database.TenantApplyOptions{InitialPassword: "initial-password"}
+
+	t.Run("dry runs change nothing", func(t *testing.T) {
+		result, err := db.ApplyTenants(ctx, spec, database.TenantApplyOptions{DryRun: true, InitialPassword: "initial-password"})
+		require.NoError(t, err)
+		assert.True(t, result.DryRun)
+		assert.Len(t, result.Changes, 5)
+
+		_, err = orgRepo.GetByName("engineering")
+		assert.Error(t, err)
+	})
+
+	t.Run("declared records are created", func(t *testing.T) {
+		result, err := db.ApplyTenants(ctx, spec, opts)
+		require.NoError(t, err)
+		assert.Equal(t, []database.TenantChange{
+			{Action: "create", Kind: "Organization", Name: "engineering"},
+			{Action: "create", Kind: "User", Name: "engineering/alice"},
+			{Action: "create", Kind: "User", Name: "engineering/bob"},
+			{Action: "create", Kind: "VDC", Name: "engineering/dev"},
+			{Action: "create", Kind: "Catalog", Name: "engineering/golden-images"},
+		}, result.Changes)
+		require.Len(t, result.CreatedVDCs, 1)
+		assert.NotEmpty(t, result.CreatedVDCs[0].Namespace)
+
+		org, err := orgRepo.GetByName("engineering")
+		require.NoError(t, err)
+		assert.Equal(t, "engineering", org.DisplayName)
+
+		alice, err := userRepo.GetByUsername("alice")
+		require.NoError(t, err)
+		assert.True(t, alice.CheckPassword("alice-password"))
+		bob, err := userRepo.GetByUsername("bob")
+		require.NoError(t, err)
+		assert.True(t, bob.CheckPassword("initial-password"))
+		assert.False(t, bob.Enabled)
+		bob, err = userRepo.GetWithRoles(bob.ID)
+		require.NoError(t, err)
+		require.Len(t, bob.Roles, 1)
+		assert.Equal(t, models.RoleVAppUser, bob.Roles[0].Name)
+
+		vdc, err := repositories.NewVDCRepository(db.DB).GetByOrgAndName(org.ID, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, models.PayAsYouGo, vdc.AllocationModel)
+		assert.Equal(t, 16000, vdc.CPULimit)
+	})
+
+	t.Run("applying again changes nothing", func(t *testing.T) {
+		result, err := db.ApplyTenants(ctx, spec, opts)
+		require.NoError(t, err)
+		assert.Empty(t, result.Changes)
+	})
+
+	t.Run("changed fields are updated", func(t *testing.T) {
+		changed, err := database.ParseTenantSpec([]byte(strings.NewReplacer(
+			"description: Product teams", "description: Platform teams",
+			"roles: [vApp User]", "roles: [vApp User, Organization Administrator]",
+			"published: true", "published: false",
+		).Replace(tenantSpecYAML)))
+		require.NoError(t, err)
+
+		result, err := db.ApplyTenants(ctx, changed, opts)
+		require.NoError(t, err)
+		assert.Equal(t, []database.TenantChange{
+			{Action: "update", Kind: "Organization", Name: "engineering", Fields: []string{"description"}},
+			{Action: "update", Kind: "User", Name: "engineering/bob", Fields: []string{"roles"}},
+			{Action: "update", Kind: "Catalog", Name: "engineering/golden-images", Fields: []string{"published"}},
+		}, result.Changes)
+
+		bob, err := userRepo.GetByUsername("bob")
+		require.NoError(t, err)
+		bob, err = userRepo.GetWithRoles(bob.ID)
+		require.NoError(t, err)
+		assert.Len(t, bob.Roles, 2)
+		assert.True(t, bob.CheckPassword("initial-password"), "passwords are never changed")
+	})
+
+	t.Run("export describes the applied spec", func(t *testing.T) {
+		exported, err := db.ExportTenants(ctx)
+		require.NoError(t, err)
+		require.Len(t, exported.Organizations, 1)
+		result, err := db.ApplyTenants(ctx, exported, database.TenantApplyOptions{DryRun: true, Prune: true})
+		require.NoError(t, err)
+		assert.Empty(t, result.Changes)
+
+		data, err := database.MarshalTenantSpec(exported)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "username: bob")
+		assert.NotContains(t, string(data), "password")
+	})
+
+	t.Run("prune deletes undeclared records", func(t *testing.T) {
+		other := &models.Organization{Name: "legacy", IsEnabled: true}
+		require.NoError(t, db.DB.Create(other).Error)
+		pruned, err := database.ParseTenantSpec([]byte(`
+organizations:
+  - name: engineering
+    description: Platform teams
+    users:
+      - username: alice
+        email: alice@example.com
+        roles: [Organization Administrator]
+`))
+		require.NoError(t, err)
+
+		result, err := db.ApplyTenants(ctx, pruned, database.TenantApplyOptions{Prune: true})
+		require.NoError(t, err)
+		assert.Equal(t, []database.TenantChange{
+			{Action: "delete", Kind: "User", Name: "engineering/bob"},
+			{Action: "delete", Kind: "VDC", Name: "engineering/dev"},
+			{Action: "delete", Kind: "Catalog", Name: "engineering/golden-images"},
+			{Action: "delete", Kind: "Organization", Name: "legacy"},
+		}, result.Changes)
+		assert.Len(t, result.DeletedVDCs, 1)
+
+		_, err = userRepo.GetByUsername("bob")
+		assert.Error(t, err)
+		_, err = orgRepo.GetByName("legacy")
+		assert.Error(t, err)
+	})
+
+	t.Run("prune refuses to delete VDCs with vApps", func(t *testing.T) {
+		org, err := orgRepo.GetByName("engineering")
+		require.NoError(t, err)
+		vdc := &models.VDC{Name: "busy", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "busy-ns"}
+		require.NoError(t, db.DB.Create(vdc).Error)
+		require.NoError(t, db.DB.Create(&models.VApp{Name: "app", VDCID: vdc.ID}).Error)
+
+		_, err = db.ApplyTenants(ctx, &database.TenantSpec{Organizations: []database.TenantOrganization{
+			{Name: "engineering", Description: "Platform teams"},
+		}}, database.TenantApplyOptions{Prune: true})
+		assert.ErrorIs(t, err, database.ErrTenantConflict)
+
+		_, err = userRepo.GetByUsername("alice")
+		assert.NoError(t, err, "failed applies change nothing")
+	})
+
+	t.Run("prune keeps users moved to an organization declared later", func(t *testing.T) {
+		moved, err := database.ParseTenantSpec([]byte(`
+organizations:
+  - name: engineering
+    description: Platform teams
+    vdcs:
+      - name: busy
+  - name: research
+    users:
+      - username: alice
+        email: alice@example.com
+        roles: [Organization Administrator]
+`))
+		require.NoError(t, err)
+
+		result, err := db.ApplyTenants(ctx, moved, database.TenantApplyOptions{Prune: true})
+		require.NoError(t, err)
+		assert.Contains(t, result.Changes, database.TenantChange{Action: "create", Kind: "Organization", Name: "research"})
+		assert.Contains(t, result.Changes, database.TenantChange{Action: "update", Kind: "User", Name: "research/alice", Fields: []string{"organization"}})
+		assert.NotContains(t, result.Changes, database.TenantChange{Action: "delete", Kind: "User", Name: "engineering/alice"})
+
+		research, err := orgRepo.GetByName("research")
+		require.NoError(t, err)
+		alice, err := userRepo.GetByUsername("alice")
+		require.NoError(t, err)
+		require.NotNil(t, alice.OrganizationID)
+		assert.Equal(t, research.ID, *alice.OrganizationID)
+		assert.True(t, alice.CheckPassword("alice-password"))
+	})
+
+	t.Run("rejects invalid specs", func(t *testing.T) {
+		_, err := database.ParseTenantSpec([]byte("organizations:\n  - name: a\n    vdc: []\n"))
+		assert.ErrorIs(t, err, database.ErrInvalidTenantSpec)
+
+		for name, spec := range map[string]database.TenantSpec{
+			"provider organization": {Organizations: []database.TenantOrganization{{Name: models.DefaultOrgName}}},
+			"repeated organization": {Organizations: []database.TenantOrganization{{Name: "a"}, {Name: "a"}}},
+			"system admin role": {Organizations: []database.TenantOrganization{{Name: "a", Users: []database.TenantUser{
+				{Username: "u", Email: "u@example.com", Roles: []string{models.RoleSystemAdmin}},
+			}}}},
+			"allocation model": {Organizations: []database.TenantOrganization{{Name: "a", VDCs: []database.TenantVDC{
+				{Name: "v", AllocationModel: "Metered"},
+			}}}},
+			"new user without password": {Organizations: []database.TenantOrganization{{Name: "a", Users: []database.TenantUser{
+				{Username: "nopassword", Email: "nopassword@example.com"},
+			}}}},
+		} {
+			_, err := db.ApplyTenants(ctx, &spec, database.TenantApplyOptions{})
+			assert.ErrorIs(t, err, database.ErrInvalidTenantSpec, name)
+		}
+	})
+}
+
+func TestTenantHandlers(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	createTenantRoles(t, db)
+
+	tenantHandlers := handlers.NewTenantHandlers(db, nil, nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/admin/tenants/apply", tenantHandlers.ApplyTenants)
+	router.GET("/api/admin/tenants/export", tenantHandlers.ExportTenants)
+
+	// The API has no initial password, so new users need one in the spec
+	spec := strings.Replace(tenantSpecYAML, "enabled: false", "enabled: false\n        password: bob-password", 1)
+	apply := func(query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/tenants/apply"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/yaml")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("dry run reports the changes", func(t *testing.T) {
+		w := apply("?dryRun=true", spec)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result database.TenantApplyResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.True(t, result.DryRun)
+		assert.Len(t, result.Changes, 5)
+
+		_, err := repositories.NewOrganizationRepository(db.DB).GetByName("engineering")
+		assert.Error(t, err)
+	})
+
+	t.Run("apply and export", func(t *testing.T) {
+		w := apply("", spec)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/tenants/export", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "name: engineering")
+
+		w = apply("?dryRun=true&prune=true", w.Body.String())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"dryRun":true,"changes":[]}`, w.Body.String())
+	})
+
+	t.Run("invalid specs are rejected", func(t *testing.T) {
+		w := apply("", "organizations:\n  - name: ''\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "organization name is required")
+
+		w = apply("", "organizations: [")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestTenantHandlersReportNamespaceErrors(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	createTenantRoles(t, db)
+
+	k8sService := &MockKubernetesService{}
+	k8sService.On("NamespaceExists", mock.Anything, mock.Anything).Return(false, nil)
+	k8sService.On("EnsureNamespaceForVDC", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("namespaces is forbidden"))
+	k8sService.On("DeleteNamespaceForVDC", mock.Anything, mock.Anything).Return(errors.New("namespaces is forbidden"))
+
+	tenantHandlers := handlers.NewTenantHandlers(db, k8sService, nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/admin/tenants/apply", tenantHandlers.ApplyTenants)
+	apply := func(query, body string) database.TenantApplyResult {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/tenants/apply"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/yaml")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result database.TenantApplyResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result
+	}
+
+	result := apply("", "organizations:\n  - name: ops\n    vdcs:\n      - name: dev\n")
+	require.Len(t, result.NamespaceErrors, 1)
+	created := result.NamespaceErrors[0]
+	assert.Equal(t, database.TenantActionCreate, created.Action)
+	assert.Equal(t, "ops/dev", created.VDC)
+	assert.NotEmpty(t, created.Namespace)
+	assert.Contains(t, created.Error, "forbidden")
+
+	// The VDC is kept although its namespace is missing
+	var count int64
+	require.NoError(t, db.DB.Model(&models.VDC{}).Where("name = ?", "dev").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	result = apply("?prune=true", "organizations:\n  - name: ops\n")
+	require.Len(t, result.NamespaceErrors, 1)
+	assert.Equal(t, database.TenantNamespaceError{
+		Action:    database.TenantActionDelete,
+		VDC:       "ops/dev",
+		Namespace: created.Namespace,
+		Error:     "namespaces is forbidden",
+	}, result.NamespaceErrors[0])
+}
@@ -0,0 +1,203 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestImportUsers(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "ImportOrg", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	otherOrg := &models.Organization{Name: "OtherOrg", IsEnabled: true}
+	require.NoError(t, db.DB.Create(otherOrg).Error)
+	vappUser := &models.Role{Name: models.RoleVAppUser, Description: "vApp User role"}
+	require.NoError(t, db.DB.Create(vappUser).Error)
+	orgAdmin := &models.Role{Name: models.RoleOrgAdmin, Description: "Organization Administrator role"}
+	require.NoError(t, db.DB.Create(orgAdmin).Error)
+	existing := &models.User{Username: "existing", FullName: "Existing User", Email: "existing@example.com", Enabled: true}
+	require.NoError(t, existing.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(existing).Error)
+
+	userRepo := repositories.NewUserRepository(db.DB)
+	taskRepo := repositories.NewTaskRepository(db.DB)
+	userHandlers := handlers.NewUserHandlers(userRepo, repositories.NewOrganizationRepository(db.DB), repositories.NewRoleRepository(db.DB), nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/admin/users/import", userHandlers.ImportUsers)
+
+	post := func(query, contentType string, body []byte) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/admin/users/import"+query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) handlers.UserImportResponse {
+		var response handlers.UserImportResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("CSV rows are validated and created one by one", func(t *testing.T) {
+		csv := "Username,FullName,Email,Password,Organization,Roles,Enabled\n" +
+			"alice,Alice Smith,alice@example.com,secret123,,,\n" +
+			"bob,Bob Jones,bob@example.com,secret123,OtherOrg,Organization Administrator;vApp User,false\n" +
+			",No Name,not-an-email,123,,,\n" +
+			"existing,Existing Again,existing2@example.com,secret123,,,\n" +
+			"alice,Alice Again,alice2@example.com,secret123,,,\n" +
+			"carol,Carol White,carol@example.com,secret123,NoSuchOrg,Missing Role,\n"
+
+		w := post("?defaultOrgId="+org.ID+"&defaultRole=vApp%20User", "text/csv", []byte(csv))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		response := decode(w)
+		assert.Equal(t, 6, response.Total)
+		assert.Equal(t, 2, response.Created)
+		assert.Equal(t, 4, response.Failed)
+		require.Len(t, response.Results, 6)
+
+		alice := response.Results[0]
+		assert.Equal(t, 2, alice.Row)
+		assert.Equal(t, handlers.UserImportCreated, alice.Status)
+		created, err := userRepo.GetWithRoles(alice.UserID)
+		require.NoError(t, err)
+		assert.Equal(t, org.ID, *created.OrganizationID)
+		assert.True(t, created.Enabled)
+		require.Len(t, created.Roles, 1)
+		assert.Equal(t, models.RoleVAppUser, created.Roles[0].Name)
+		assert.True(t, created.CheckPassword("secret123"))
+
+		bob, err := userRepo.GetWithRoles(response.Results[1].UserID)
+		require.NoError(t, err)
+		assert.Equal(t, otherOrg.ID, *bob.OrganizationID)
+		assert.False(t, bob.Enabled)
+		assert.Len(t, bob.Roles, 2)
+
+		invalid := response.Results[2]
+		assert.Equal(t, handlers.UserImportFailed, invalid.Status)
+		assert.Len(t, invalid.Errors, 3)
+		assert.Contains(t, response.Results[3].Errors, "user existing already exists")
+		assert.Contains(t, response.Results[4].Errors, "username alice is repeated from row 2")
+		assert.Equal(t, []string{"organization NoSuchOrg not found", "role Missing Role not found"}, response.Results[5].Errors)
+
+		_, err = userRepo.GetByUsername("carol")
+		assert.Error(t, err)
+	})
+
+	t.Run("SCIM ListResponse from a multipart upload", func(t *testing.T) {
+		scim := `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:ListResponse"],
+			"Resources": [{
+				"userName": "dave",
+				"name": {"givenName": "Dave", "familyName": "Brown"},
+				"emails": [{"value": "dave.work@example.com"}, {"value": "dave@example.com", "primary": true}],
+				"password": "secret123",
+				"roles": [{"value": "` + orgAdmin.ID + `"}],
+				"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"organization": "` + otherOrg.ID + `"}
+			}]
+		}`
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("file", "users.json")
+		require.NoError(t, err)
+		_, err = part.Write([]byte(scim))
+		require.NoError(t, err)
+		require.NoError(t, form.Close())
+
+		w := post("", form.FormDataContentType(), body.Bytes())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		response := decode(w)
+		require.Equal(t, 1, response.Created, w.Body.String())
+
+		dave, err := userRepo.GetWithRoles(response.Results[0].UserID)
+		require.NoError(t, err)
+		assert.Equal(t, "Dave Brown", dave.FullName)
+		assert.Equal(t, "dave@example.com", dave.Email)
+		assert.Equal(t, otherOrg.ID, *dave.OrganizationID)
+		require.Len(t, dave.Roles, 1)
+		assert.Equal(t, orgAdmin.ID, dave.Roles[0].ID)
+	})
+
+	t.Run("Invalid files and defaults are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post("", "text/csv", []byte("username,shoeSize\nerin,42\n")).Code)
+		assert.Equal(t, http.StatusBadRequest, post("", "text/csv", []byte("username,email\n")).Code)
+		assert.Equal(t, http.StatusBadRequest, post("", "application/scim+json", []byte(`{"Resources": [`)).Code)
+		assert.Equal(t, http.StatusUnsupportedMediaType, post("", "application/xml", []byte("<users/>")).Code)
+
+		w := post("?defaultOrgId=urn:vcloud:org:00000000-0000-0000-0000-000000000000", "text/csv", []byte("username\nerin\n"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "not found")
+	})
+
+	t.Run("Large imports run under a task", func(t *testing.T) {
+		background := services.NewBackgroundWork()
+		userHandlers.SetTaskStore(taskRepo, nil)
+		userHandlers.SetBackgroundWork(background)
+
+		var csv strings.Builder
+		csv.WriteString("username,fullName,email,password\n")
+		for i := 0; i < 25; i++ {
+			fmt.Fprintf(&csv, "bulk%d,Bulk User %d,bulk%d@example.com,secret123\n", i, i, i)
+		}
+		csv.WriteString("bulk0,Bulk Duplicate,bulk-dup@example.com,secret123\n")
+
+		w := post("?defaultOrgId=ImportOrg", "text/csv", []byte(csv.String()))
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		response := decode(w)
+		assert.Equal(t, 26, response.Total)
+		require.NotEmpty(t, response.TaskID)
+		assert.Equal(t, response.TaskHref, w.Header().Get("Location"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		require.NoError(t, background.Shutdown(ctx))
+
+		task, err := taskRepo.GetByID(context.Background(), response.TaskID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TaskOperationUserImport, task.Name)
+		assert.Equal(t, models.TaskStatusSuccess, task.Status)
+		assert.Equal(t, org.ID, task.OrganizationID)
+		assert.Equal(t, "Created 25 of 26 users", task.Details)
+
+		var result handlers.UserImportResponse
+		require.NoError(t, json.Unmarshal([]byte(task.ResultData), &result))
+		assert.Equal(t, 25, result.Created)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, handlers.UserImportFailed, result.Results[25].Status)
+	})
+}
+
+func TestImportUsersRequiresSystemAdmin(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+
+	user := &models.User{Username: "plainuser", Email: "plainuser@example.com", Enabled: true}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	token, err := jwtManager.Generate(user.ID, user.Username)
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/api/admin/users/import", strings.NewReader("username\nerin\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	server.GetRouter().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
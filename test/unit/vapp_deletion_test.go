@@ -2,6 +2,7 @@ package unit
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,7 +11,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubevirtv1 "kubevirt.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
 	"github.com/mhrivnak/ssvirt/pkg/auth"
@@ -59,6 +64,11 @@ func (m *MockKubernetesService) EnsureNamespaceForVDC(ctx context.Context, vdc *
 	return args.Error(0)
 }
 
+func (m *MockKubernetesService) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	args := m.Called(ctx, name)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockKubernetesService) GetTemplate(ctx context.Context, name string) (*services.TemplateInfo, error) {
 	args := m.Called(ctx, name)
 	if template := args.Get(0); template != nil {
@@ -93,6 +103,34 @@ func (m *MockKubernetesService) EnsureNamespaceResources(ctx context.Context, na
 	return args.Error(0)
 }
 
+func (m *MockKubernetesService) SetDefaultObjectQuota(quota models.ObjectQuota) {
+	m.Called(quota)
+}
+
+func (m *MockKubernetesService) GetVMDiagnostics(ctx context.Context, namespace, vmName string) (*services.VMDiagnostics, error) {
+	args := m.Called(ctx, namespace, vmName)
+	if diagnostics := args.Get(0); diagnostics != nil {
+		return diagnostics.(*services.VMDiagnostics), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockKubernetesService) GetVMConsoleLog(ctx context.Context, namespace, vmName string, opts services.ConsoleLogOptions) (io.ReadCloser, error) {
+	args := m.Called(ctx, namespace, vmName, opts)
+	if stream := args.Get(0); stream != nil {
+		return stream.(io.ReadCloser), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockKubernetesService) GetVDCInfrastructure(ctx context.Context, namespace string) (*services.VDCInfrastructure, error) {
+	args := m.Called(ctx, namespace)
+	if infrastructure := args.Get(0); infrastructure != nil {
+		return infrastructure.(*services.VDCInfrastructure), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockKubernetesService) GetClient() client.Client {
 	args := m.Called()
 	if clientVal := args.Get(0); clientVal != nil {
@@ -115,7 +153,9 @@ func TestVAppDeletion_CleansUpTemplateInstance(t *testing.T) {
 	vmRepo := repositories.NewVMRepository(db.DB)
 
 	// Create VApp handlers with mock K8s service
-	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, mockK8sService)
+	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), mockK8sService)
+	work := services.NewBackgroundWork()
+	vappHandlers.SetBackgroundWork(work)
 
 	// Create test data
 	// 1. Create organization
@@ -162,6 +202,7 @@ func TestVAppDeletion_CleansUpTemplateInstance(t *testing.T) {
 
 	// Setup mock expectations - DeleteTemplateInstance should be called
 	mockK8sService.On("DeleteTemplateInstance", mock.Anything, vdc.Namespace, vapp.Name).Return(nil)
+	mockK8sService.On("GetClient").Return(newVAppDeletionFakeClient())
 
 	// Generate JWT token
 	token, err := jwtManager.Generate(user.ID, user.Username)
@@ -186,8 +227,9 @@ func TestVAppDeletion_CleansUpTemplateInstance(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	// Verify the response
-	assert.Equal(t, http.StatusNoContent, w.Code)
+	// Verify the response, then wait for the background deletion
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	require.NoError(t, work.Shutdown(context.Background()))
 
 	// Verify that DeleteTemplateInstance was called with correct parameters
 	mockK8sService.AssertCalled(t, "DeleteTemplateInstance", mock.Anything, vdc.Namespace, vapp.Name)
@@ -212,7 +254,9 @@ func TestVAppDeletion_HandlesKubernetesError(t *testing.T) {
 	vmRepo := repositories.NewVMRepository(db.DB)
 
 	// Create VApp handlers with mock K8s service
-	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, mockK8sService)
+	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), mockK8sService)
+	work := services.NewBackgroundWork()
+	vappHandlers.SetBackgroundWork(work)
 
 	// Create test data
 	// 1. Create organization
@@ -257,7 +301,10 @@ func TestVAppDeletion_HandlesKubernetesError(t *testing.T) {
 	user.OrganizationID = &org.ID
 	require.NoError(t, db.DB.Save(user).Error)
 
-	// Setup mock expectations - K8s service returns error but vApp deletion continues
+	taskRepo := repositories.NewTaskRepository(db.DB)
+	vappHandlers.SetTaskStore(taskRepo, nil)
+
+	// Setup mock expectations - K8s service returns error, so vApp deletion stops
 	mockK8sService.On("DeleteTemplateInstance", mock.Anything, vdc.Namespace, vapp.Name).Return(assert.AnError)
 
 	// Generate JWT token
@@ -283,14 +330,198 @@ func TestVAppDeletion_HandlesKubernetesError(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	// Verify the response - should still succeed despite K8s error
-	assert.Equal(t, http.StatusNoContent, w.Code)
+	// The deletion is accepted and fails in the background when cluster cleanup fails
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	require.NoError(t, work.Shutdown(context.Background()))
 
 	// Verify that DeleteTemplateInstance was called
 	mockK8sService.AssertCalled(t, "DeleteTemplateInstance", mock.Anything, vdc.Namespace, vapp.Name)
 
-	// Verify vApp was still deleted from database despite K8s error
-	var deletedVApp models.VApp
-	err = db.DB.Where("id = ?", vapp.ID).First(&deletedVApp).Error
-	assert.Error(t, err) // Should not be found
+	// Verify the vApp record is kept for a retry rather than orphaning cluster resources
+	var remainingVApp models.VApp
+	require.NoError(t, db.DB.Where("id = ?", vapp.ID).First(&remainingVApp).Error)
+	assert.Equal(t, models.VAppStatusDeleting, remainingVApp.Status)
+
+	// The failure is reported on the task
+	var task models.Task
+	require.NoError(t, db.DB.Where("owner_id = ?", vapp.ID).First(&task).Error)
+	assert.Equal(t, models.TaskStatusError, task.Status)
+	assert.Equal(t, assert.AnError.Error(), task.Details)
+}
+
+// newVAppDeletionFakeClient returns a fake client that knows about KubeVirt resources
+func newVAppDeletionFakeClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = kubevirtv1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func TestVAppDeletion_DeletesVirtualMachinesAndTracksTask(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "DeleteOrg", DisplayName: "Delete Org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	vdc := &models.VDC{
+		Name:            "DeleteVDC",
+		OrganizationID:  org.ID,
+		AllocationModel: models.PayAsYouGo,
+		Namespace:       "delete-ns",
+		IsEnabled:       true,
+	}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	user := &models.User{Username: "deleter", Email: "deleter@example.com", Enabled: true, OrganizationID: &org.ID}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+
+	vapp := &models.VApp{
+		Name:                 "web-app",
+		VDCID:                vdc.ID,
+		TemplateInstanceName: "web-app-ti",
+		Status:               models.VAppStatusDeployed,
+	}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	vmRecord := &models.VM{Name: "web-1", VMName: "web-1", Namespace: "delete-ns", VAppID: vapp.ID, Status: "POWERED_ON"}
+	require.NoError(t, db.DB.Create(vmRecord).Error)
+
+	// web-2 was created by the TemplateInstance but has no VM record yet
+	k8sClient := newVAppDeletionFakeClient(
+		&kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "delete-ns", Labels: map[string]string{"vapp.ssvirt": "web-app-ti"}}},
+		&kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "delete-ns", Labels: map[string]string{"vapp.ssvirt": "web-app-ti"}}},
+		&kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "delete-ns", Labels: map[string]string{"vapp.ssvirt": "other-app"}}},
+	)
+
+	mockK8sService := &MockKubernetesService{}
+	mockK8sService.On("DeleteTemplateInstance", mock.Anything, "delete-ns", "web-app-ti").Return(nil)
+	mockK8sService.On("GetClient").Return(k8sClient)
+
+	taskRepo := repositories.NewTaskRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), mockK8sService)
+	work := services.NewBackgroundWork()
+	vappHandlers.SetBackgroundWork(work)
+	vappHandlers.SetTaskStore(taskRepo, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/cloudapi/1.0.0/vapps/:vapp_id", func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+		vappHandlers.DeleteVApp(c)
+	})
+	deleteVApp := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("DELETE", "/cloudapi/1.0.0/vapps/"+vapp.ID+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("running VMs without force are rejected before touching the cluster", func(t *testing.T) {
+		w := deleteVApp("")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockK8sService.AssertNotCalled(t, "DeleteTemplateInstance", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("force deletes the TemplateInstance, VirtualMachines and records", func(t *testing.T) {
+		w := deleteVApp("?force=true")
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		require.NoError(t, work.Shutdown(ctx))
+
+		mockK8sService.AssertCalled(t, "DeleteTemplateInstance", mock.Anything, "delete-ns", "web-app-ti")
+
+		var remaining kubevirtv1.VirtualMachineList
+		require.NoError(t, k8sClient.List(ctx, &remaining, client.InNamespace("delete-ns")))
+		require.Len(t, remaining.Items, 1)
+		assert.Equal(t, "other", remaining.Items[0].Name)
+
+		var count int64
+		require.NoError(t, db.DB.Model(&models.VApp{}).Where("id = ?", vapp.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+		require.NoError(t, db.DB.Model(&models.VM{}).Where("vapp_id = ?", vapp.ID).Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+
+		var task models.Task
+		require.NoError(t, db.DB.Where("owner_id = ?", vapp.ID).First(&task).Error)
+		assert.Equal(t, models.TaskOperationVAppDelete, task.Name)
+		assert.Equal(t, models.TaskStatusSuccess, task.Status)
+		assert.Equal(t, org.ID, task.OrganizationID)
+		assert.Equal(t, user.ID, task.UserID)
+	})
+}
+
+func TestVAppDeletion_AdminForceIgnoresClusterFailure(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	userRepo := repositories.NewUserRepository(db.DB)
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+
+	mockK8sService := &MockKubernetesService{}
+	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), mockK8sService)
+	vappHandlers.SetTaskStore(repositories.NewTaskRepository(db.DB), nil)
+	vappHandlers.SetRoleCache(auth.NewRoleCache(userRepo, 0))
+
+	org := &models.Organization{Name: "ForceOrg", DisplayName: "Force Org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	vdc := &models.VDC{Name: "force-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, Namespace: "broken-ns", IsEnabled: true}
+	require.NoError(t, vdcRepo.Create(vdc))
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+	admin := &models.User{Username: "forceadmin", Email: "forceadmin@example.com", Enabled: true}
+	require.NoError(t, admin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(admin).Error)
+	require.NoError(t, db.DB.Model(admin).Association("Roles").Append(sysAdminRole))
+	tenant := &models.User{Username: "forcetenant", Email: "forcetenant@example.com", Enabled: true, OrganizationID: &org.ID}
+	require.NoError(t, tenant.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(tenant).Error)
+
+	// The tenant's namespace is broken, so the TemplateInstance cannot be removed
+	mockK8sService.On("DeleteTemplateInstance", mock.Anything, vdc.Namespace, mock.Anything).Return(assert.AnError)
+
+	gin.SetMode(gin.TestMode)
+	deleteVApp := func(t *testing.T, name, userID string) *models.Task {
+		vapp := &models.VApp{Name: name, VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+		require.NoError(t, vappRepo.CreateWithContext(context.Background(), vapp))
+		vm := &models.VM{Name: name + "-vm", VAppID: vapp.ID, Status: "POWERED_ON", VMName: name + "-vm", Namespace: vdc.Namespace}
+		require.NoError(t, db.DB.Create(vm).Error)
+
+		work := services.NewBackgroundWork()
+		vappHandlers.SetBackgroundWork(work)
+		router := gin.New()
+		router.DELETE("/cloudapi/1.0.0/vapps/:vapp_id", func(c *gin.Context) {
+			c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: userID})
+			vappHandlers.DeleteVApp(c)
+		})
+		req, _ := http.NewRequest(http.MethodDelete, "/cloudapi/1.0.0/vapps/"+vapp.ID+"?force=true", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		require.NoError(t, work.Shutdown(context.Background()))
+
+		var task models.Task
+		require.NoError(t, db.DB.Where("owner_id = ?", vapp.ID).First(&task).Error)
+		return &task
+	}
+
+	t.Run("Tenants keep the records when cleanup fails", func(t *testing.T) {
+		task := deleteVApp(t, "tenant-vapp", tenant.ID)
+		assert.Equal(t, models.TaskStatusError, task.Status)
+		var count int64
+		require.NoError(t, db.DB.Model(&models.VApp{}).Where("id = ?", task.OwnerID).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("System Administrators remove the records despite the failure", func(t *testing.T) {
+		task := deleteVApp(t, "admin-vapp", admin.ID)
+		assert.Equal(t, models.TaskStatusSuccess, task.Status)
+		assert.Contains(t, task.Details, "Cluster resources may remain")
+		var count int64
+		require.NoError(t, db.DB.Model(&models.VApp{}).Where("id = ?", task.OwnerID).Count(&count).Error)
+		assert.Zero(t, count)
+		require.NoError(t, db.DB.Model(&models.VM{}).Where("vapp_id = ?", task.OwnerID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
 }
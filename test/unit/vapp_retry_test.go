@@ -0,0 +1,189 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestRetryVAppInstantiation(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+	ctx := context.Background()
+
+	org := &models.Organization{Name: "RetryOrg", DisplayName: "Retry Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "retryuser", Email: "retry@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	require.NoError(t, db.DB.Create(&models.Catalog{Name: "retry-catalog", OrganizationID: org.ID}).Error)
+	vdc := &models.VDC{Name: "retry-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "retry-ns"}
+	vdc.SetMetadataPolicy(models.MetadataPolicy{Labels: map[string]string{"cost-center": "cc-1234"}})
+	require.NoError(t, db.DB.Create(vdc).Error)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	require.NoError(t, templatev1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	var instantiated []*services.TemplateInstanceRequest
+	mockK8s := &MockKubernetesService{}
+	mockK8s.On("GetClient").Return(k8sClient)
+	mockK8s.On("CreateTemplateInstance", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			instantiated = append(instantiated, args.Get(1).(*services.TemplateInstanceRequest))
+		}).
+		Return(&services.TemplateInstanceResult{Name: "retry-vapp"}, nil).Once()
+	mockK8s.On("DeleteTemplateInstance", mock.Anything, "retry-ns", "retry-vapp").
+		Run(func(args mock.Arguments) {
+			ti := &templatev1.TemplateInstance{ObjectMeta: metav1.ObjectMeta{Name: "retry-vapp", Namespace: "retry-ns"}}
+			_ = k8sClient.Delete(ctx, ti)
+		}).
+		Return(nil)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	taskRepo := repositories.NewTaskRepository(db.DB)
+	access := auth.NewAccessControl(vdcRepo, vappRepo, vmRepo)
+	creation := handlers.NewVMCreationHandlers(vdcRepo, vappRepo,
+		repositories.NewCatalogItemRepository(nil, nil), repositories.NewCatalogRepository(db.DB), access, mockK8s)
+	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, access, mockK8s)
+	vappHandlers.SetTaskStore(taskRepo, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+	})
+	router.POST("/cloudapi/1.0.0/vdcs/:vdc_id/actions/instantiateTemplate", creation.InstantiateTemplate)
+	router.POST("/cloudapi/1.0.0/vapps/:vapp_id/actions/retry", vappHandlers.RetryVAppInstantiation)
+
+	body, _ := json.Marshal(handlers.InstantiateTemplateRequest{
+		Name:        "retry-vapp",
+		Description: "kept across retries",
+		CatalogItem: handlers.CatalogItem{ID: "urn:vcloud:catalogitem:rhel9-server", Name: "rhel9-server"},
+	})
+	req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/actions/instantiateTemplate", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created handlers.VAppResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	retry := func(vappID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/vapps/"+vappID+"/actions/retry", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Only failed vApps are retried", func(t *testing.T) {
+		w := retry(created.ID)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "INSTANTIATING")
+	})
+
+	t.Run("vApps without a recorded instantiation cannot be retried", func(t *testing.T) {
+		legacy := &models.VApp{Name: "legacy-vapp", VDCID: vdc.ID, Status: models.VAppStatusFailed}
+		require.NoError(t, db.DB.Create(legacy).Error)
+		w := retry(legacy.ID)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "cannot be retried")
+	})
+
+	// The TemplateInstance failed after creating one of its VirtualMachines
+	require.NoError(t, vappRepo.UpdateStatus(ctx, created.ID, models.VAppStatusFailed))
+	require.NoError(t, k8sClient.Create(ctx, &templatev1.TemplateInstance{ObjectMeta: metav1.ObjectMeta{Name: "retry-vapp", Namespace: "retry-ns"}}))
+	require.NoError(t, k8sClient.Create(ctx, &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{
+		Name: "retry-vm", Namespace: "retry-ns", Labels: map[string]string{"vapp.ssvirt": "retry-vapp"},
+	}}))
+	require.NoError(t, k8sClient.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "retry-vapp-ssh-keys", Namespace: "retry-ns"}}))
+
+	t.Run("The TemplateInstance is recreated with the same request", func(t *testing.T) {
+		background := services.NewBackgroundWork()
+		vappHandlers.SetBackgroundWork(background)
+		mockK8s.On("CreateTemplateInstance", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				instantiated = append(instantiated, args.Get(1).(*services.TemplateInstanceRequest))
+			}).
+			Return(&services.TemplateInstanceResult{Name: "retry-vapp"}, nil).Once()
+
+		w := retry(created.ID)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var response handlers.RetryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, created.ID, response.ID)
+		assert.Equal(t, models.VAppStatusInstantiating, response.Status)
+		require.NotEmpty(t, response.TaskID)
+		require.NoError(t, background.Shutdown(ctx))
+
+		require.Len(t, instantiated, 2)
+		first, _ := json.Marshal(instantiated[0])
+		second, _ := json.Marshal(instantiated[1])
+		assert.JSONEq(t, string(first), string(second))
+		assert.Equal(t, "cc-1234", instantiated[1].Labels["cost-center"])
+
+		var vms kubevirtv1.VirtualMachineList
+		require.NoError(t, k8sClient.List(ctx, &vms, client.InNamespace("retry-ns")))
+		assert.Empty(t, vms.Items)
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: "retry-vapp-ssh-keys", Namespace: "retry-ns"}, &corev1.Secret{})
+		assert.True(t, k8serrors.IsNotFound(err))
+
+		vapp, err := vappRepo.GetByIDString(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.VAppStatusInstantiating, vapp.Status)
+		assert.Equal(t, "kept across retries", vapp.Description)
+
+		task, err := taskRepo.GetByID(ctx, response.TaskID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TaskOperationVAppRetry, task.Name)
+		assert.Equal(t, models.TaskStatusSuccess, task.Status)
+	})
+
+	t.Run("A failed recreation leaves the vApp failed", func(t *testing.T) {
+		require.NoError(t, vappRepo.UpdateStatus(ctx, created.ID, models.VAppStatusFailed))
+		background := services.NewBackgroundWork()
+		vappHandlers.SetBackgroundWork(background)
+		mockK8s.On("CreateTemplateInstance", mock.Anything, mock.Anything).
+			Return(nil, errors.New("template rhel9-server not found")).Once()
+
+		w := retry(created.ID)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var response handlers.RetryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NoError(t, background.Shutdown(ctx))
+
+		vapp, err := vappRepo.GetByIDString(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.VAppStatusFailed, vapp.Status)
+		task, err := taskRepo.GetByID(ctx, response.TaskID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TaskStatusError, task.Status)
+		assert.Contains(t, task.Details, "template rhel9-server not found")
+	})
+}
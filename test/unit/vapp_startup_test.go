@@ -0,0 +1,196 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestVAppStartupSection(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "StartupOrg", DisplayName: "Startup Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "startupuser", Email: "startup@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "startup-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, Namespace: "startup-ns", IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "three-tier", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	otherVApp := &models.VApp{Name: "other", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(otherVApp).Error)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	vms := map[string]*models.VM{}
+	for _, name := range []string{"db", "app", "web"} {
+		vm := &models.VM{Name: name, VAppID: vapp.ID, Status: "POWERED_OFF", VMName: name, Namespace: "startup-ns"}
+		require.NoError(t, db.DB.Create(vm).Error)
+		vms[name] = vm
+		halted := kubevirtv1.RunStrategyHalted
+		builder = builder.WithObjects(&kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "startup-ns"},
+			Spec:       kubevirtv1.VirtualMachineSpec{RunStrategy: &halted},
+		})
+	}
+	foreignVM := &models.VM{Name: "foreign", VAppID: otherVApp.ID, Status: "POWERED_OFF", VMName: "foreign", Namespace: "startup-ns"}
+	require.NoError(t, db.DB.Create(foreignVM).Error)
+	k8sClient := builder.Build()
+
+	mockK8s := &MockKubernetesService{}
+	mockK8s.On("GetClient").Return(k8sClient)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	vappHandlers := handlers.NewVAppHandlers(vappRepo, vdcRepo, vmRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), mockK8s)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+	})
+	router.GET("/cloudapi/1.0.0/vapps/:vapp_id/startupSection", vappHandlers.GetStartupSection)
+	router.PUT("/cloudapi/1.0.0/vapps/:vapp_id/startupSection", vappHandlers.UpdateStartupSection)
+	router.POST("/cloudapi/1.0.0/vapps/:vapp_id/actions/powerOn", vappHandlers.PowerOnVApp)
+
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var reader *bytes.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			reader = bytes.NewReader(data)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		req, _ := http.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	sectionPath := "/cloudapi/1.0.0/vapps/" + vapp.ID + "/startupSection"
+
+	t.Run("Updates and lists VMs in start order", func(t *testing.T) {
+		w := request("PUT", sectionPath, handlers.StartupSection{Items: []handlers.StartupSectionItem{
+			{VMID: vms["db"].ID, Order: 0, StartDelay: 1},
+			{VMID: vms["app"].ID, Order: 1},
+			{VMID: vms["web"].ID, Order: 2},
+		}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var section handlers.StartupSection
+		require.NoError(t, json.Unmarshal(request("GET", sectionPath, nil).Body.Bytes(), &section))
+		require.Len(t, section.Items, 3)
+		assert.Equal(t, []string{"db", "app", "web"}, []string{section.Items[0].Name, section.Items[1].Name, section.Items[2].Name})
+		assert.Equal(t, 1, section.Items[0].StartDelay)
+	})
+
+	t.Run("Rejects invalid startup sections", func(t *testing.T) {
+		for name, items := range map[string][]handlers.StartupSectionItem{
+			"negative order":   {{VMID: vms["db"].ID, Order: -1}},
+			"long delay":       {{VMID: vms["db"].ID, StartDelay: 7200}},
+			"duplicate VM":     {{VMID: vms["db"].ID}, {VMID: vms["db"].ID}},
+			"VM of other vApp": {{VMID: foreignVM.ID}},
+			"not a VM":         {{VMID: vapp.ID}},
+		} {
+			w := request("PUT", sectionPath, handlers.StartupSection{Items: items})
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		}
+
+		// Nothing was changed by the rejected requests
+		reloaded, err := vmRepo.GetByID(vms["db"].ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, reloaded.StartOrder)
+		assert.Equal(t, 1, reloaded.StartDelay)
+	})
+
+	t.Run("Power on starts VMs in order after each delay", func(t *testing.T) {
+		running := func(name string) bool {
+			vm := &kubevirtv1.VirtualMachine{}
+			require.NoError(t, k8sClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "startup-ns"}, vm))
+			return vm.Spec.RunStrategy != nil && *vm.Spec.RunStrategy == kubevirtv1.RunStrategyAlways
+		}
+
+		w := request("POST", "/cloudapi/1.0.0/vapps/"+vapp.ID+"/actions/powerOn", nil)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+		require.Eventually(t, func() bool { return running("db") }, time.Second, 10*time.Millisecond)
+		assert.False(t, running("app"), "app server must wait for the database's start delay")
+
+		require.Eventually(t, func() bool { return running("app") && running("web") }, 3*time.Second, 50*time.Millisecond)
+		for _, name := range []string{"db", "app", "web"} {
+			reloaded, err := vmRepo.GetByID(vms[name].ID)
+			require.NoError(t, err)
+			assert.Equal(t, models.VMPowerStateOn, reloaded.DesiredPowerState, name)
+		}
+		require.Eventually(t, func() bool {
+			reloaded, err := vappRepo.GetByIDString(context.Background(), vapp.ID)
+			return err == nil && reloaded.Status == models.VAppStatusDeployed
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Power on needs VMs to start", func(t *testing.T) {
+		require.NoError(t, db.DB.Model(&models.VM{}).Where("vapp_id = ?", vapp.ID).Update("status", "POWERED_ON").Error)
+		w := request("POST", "/cloudapi/1.0.0/vapps/"+vapp.ID+"/actions/powerOn", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Shutdown aborts an interrupted sequence", func(t *testing.T) {
+		require.NoError(t, db.DB.Model(&models.VM{}).Where("vapp_id = ?", vapp.ID).Update("status", "POWERED_OFF").Error)
+		require.NoError(t, db.DB.Model(&models.VM{}).Where("id = ?", vms["db"].ID).Update("start_delay", 300).Error)
+		for _, name := range []string{"db", "app", "web"} {
+			vm := &kubevirtv1.VirtualMachine{}
+			require.NoError(t, k8sClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "startup-ns"}, vm))
+			halted := kubevirtv1.RunStrategyHalted
+			vm.Spec.RunStrategy = &halted
+			require.NoError(t, k8sClient.Update(context.Background(), vm))
+		}
+
+		taskRepo := repositories.NewTaskRepository(db.DB)
+		vappHandlers.SetTaskStore(taskRepo, nil)
+		background := services.NewBackgroundWork()
+		vappHandlers.SetBackgroundWork(background)
+
+		w := request("POST", "/cloudapi/1.0.0/vapps/"+vapp.ID+"/actions/powerOn", nil)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var response handlers.PowerOperationResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotEmpty(t, response.TaskID)
+		require.Eventually(t, func() bool { return background.Running() == 1 }, time.Second, 10*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, background.Shutdown(ctx), context.DeadlineExceeded)
+		assert.Zero(t, background.Running())
+
+		task, err := taskRepo.GetByID(context.Background(), response.TaskID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TaskStatusAborted, task.Status)
+		assert.Contains(t, task.Details, "start order 1")
+
+		reloaded, err := vappRepo.GetByIDString(context.Background(), vapp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.VAppStatusDeployed, reloaded.Status)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -154,6 +155,77 @@ func TestVAppAPIEndpoints(t *testing.T) {
 			assert.Equal(t, vapp1.Name, response.Values[0].Name)
 		})
 
+		t.Run("List vApps by status and name returns 200", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/vapps?status=deployed,FAILED&name=VAPP", nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response types.Page[handlers.VAppResponse]
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			require.NoError(t, err)
+
+			assert.Equal(t, int64(1), response.ResultTotal)
+			require.Len(t, response.Values, 1)
+			assert.Equal(t, vapp1.Name, response.Values[0].Name)
+			assert.Equal(t, 1, response.Values[0].NumberOfVMs)
+		})
+
+		t.Run("List vApps with cursor returns every vApp once", func(t *testing.T) {
+			var names []string
+			cursor := ""
+			for pages := 0; pages < 3; pages++ {
+				req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/vapps?pageSize=1&cursor="+cursor, nil)
+				req.Header.Set("Authorization", "Bearer "+userToken)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				require.Equal(t, http.StatusOK, w.Code)
+
+				var response types.Page[handlers.VAppResponse]
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, int64(2), response.ResultTotal)
+				for _, vapp := range response.Values {
+					names = append(names, vapp.Name)
+				}
+				if cursor = response.NextCursor; cursor == "" {
+					break
+				}
+			}
+			assert.Equal(t, []string{"test-vapp-1", "test-vapp-2"}, names)
+		})
+
+		t.Run("List vApps with strong consistency includes a vApp created just before", func(t *testing.T) {
+			fresh := &models.VApp{Name: "test-vapp-fresh", VDCID: vdc.ID, Status: models.VAppStatusInstantiating}
+			require.NoError(t, db.DB.Create(fresh).Error)
+			t.Cleanup(func() { db.DB.Unscoped().Delete(fresh) })
+
+			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/vapps?consistency=strong&filter="+fresh.Name, nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			var response types.Page[handlers.VAppResponse]
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Values, 1)
+			assert.Equal(t, fresh.ID, response.Values[0].ID)
+		})
+
+		t.Run("List vApps with invalid consistency returns 400", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/vapps?consistency=linearizable", nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var response handlers.APIError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "INVALID_CONSISTENCY_LEVEL", response.MinorErrorCode)
+		})
+
 		t.Run("List vApps with invalid VDC URN returns 400", func(t *testing.T) {
 			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vdcs/invalid-vdc-id/vapps", nil)
 			req.Header.Set("Authorization", "Bearer "+userToken)
@@ -251,6 +323,120 @@ func TestVAppAPIEndpoints(t *testing.T) {
 		})
 	})
 
+	t.Run("List vApp VMs", func(t *testing.T) {
+		vmsVApp := &models.VApp{
+			Name:   "vms-vapp",
+			VDCID:  vdc.ID,
+			Status: models.VAppStatusDeployed,
+		}
+		require.NoError(t, db.DB.Create(vmsVApp).Error)
+		for _, vm := range []*models.VM{
+			{Name: "vm-c", VMName: "vm-c", Namespace: "test-ns", VAppID: vmsVApp.ID, Status: "POWERED_ON"},
+			{Name: "vm-a", VMName: "vm-a", Namespace: "test-ns", VAppID: vmsVApp.ID, Status: "POWERED_OFF"},
+			{Name: "vm-b", VMName: "vm-b", Namespace: "test-ns", VAppID: vmsVApp.ID, Status: "POWERED_ON"},
+		} {
+			require.NoError(t, db.DB.Create(vm).Error)
+		}
+
+		listVMs := func(t *testing.T, query string) types.Page[handlers.VMResponse] {
+			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vapps/"+vmsVApp.ID+"/vms"+query, nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response types.Page[handlers.VMResponse]
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			return response
+		}
+
+		t.Run("List vApp VMs returns 200 sorted by name", func(t *testing.T) {
+			response := listVMs(t, "")
+
+			assert.Equal(t, int64(3), response.ResultTotal)
+			require.Len(t, response.Values, 3)
+			assert.Equal(t, "vm-a", response.Values[0].Name)
+			assert.Equal(t, "vm-b", response.Values[1].Name)
+			assert.Equal(t, "vm-c", response.Values[2].Name)
+			assert.Equal(t, vmsVApp.ID, response.Values[0].VAppID)
+		})
+
+		t.Run("List vApp VMs with pagination returns 200", func(t *testing.T) {
+			response := listVMs(t, "?page=2&pageSize=2")
+
+			assert.Equal(t, int64(3), response.ResultTotal)
+			assert.Equal(t, 2, response.PageCount)
+			require.Len(t, response.Values, 1)
+			assert.Equal(t, "vm-c", response.Values[0].Name)
+		})
+
+		t.Run("List vApp VMs with status filter returns 200", func(t *testing.T) {
+			response := listVMs(t, "?status=powered_on&sortDesc=name")
+
+			assert.Equal(t, int64(2), response.ResultTotal)
+			require.Len(t, response.Values, 2)
+			assert.Equal(t, "vm-c", response.Values[0].Name)
+			assert.Equal(t, "vm-b", response.Values[1].Name)
+		})
+
+		t.Run("List vApp VMs with cursor is stable while VMs change", func(t *testing.T) {
+			first := listVMs(t, "?cursor=&pageSize=2")
+			assert.Equal(t, int64(3), first.ResultTotal)
+			assert.Equal(t, 0, first.Page)
+			require.Len(t, first.Values, 2)
+			assert.Equal(t, "vm-a", first.Values[0].Name)
+			assert.Equal(t, "vm-b", first.Values[1].Name)
+			require.NotEmpty(t, first.NextCursor)
+
+			// Removing an earlier VM and adding one before the cursor shifts
+			// offsets but not the cursor's position
+			added := &models.VM{Name: "vm-0", VMName: "vm-0", Namespace: "test-ns", VAppID: vmsVApp.ID, Status: "POWERED_ON"}
+			require.NoError(t, db.DB.Create(added).Error)
+			require.NoError(t, db.DB.Where("name = ? AND vapp_id = ?", "vm-a", vmsVApp.ID).Delete(&models.VM{}).Error)
+			later := &models.VM{Name: "vm-d", VMName: "vm-d", Namespace: "test-ns", VAppID: vmsVApp.ID, Status: "POWERED_ON"}
+			require.NoError(t, db.DB.Create(later).Error)
+
+			second := listVMs(t, "?cursor="+first.NextCursor+"&pageSize=2")
+			require.Len(t, second.Values, 2)
+			assert.Equal(t, "vm-c", second.Values[0].Name)
+			assert.Equal(t, "vm-d", second.Values[1].Name)
+			assert.Empty(t, second.NextCursor)
+
+			require.NoError(t, db.DB.Delete(added).Error)
+			require.NoError(t, db.DB.Delete(later).Error)
+		})
+
+		t.Run("List vApp VMs with invalid cursor returns 400", func(t *testing.T) {
+			for _, query := range []string{"?cursor=not-a-cursor", "?cursor=&page=2", "?cursor=&sortDesc=name"} {
+				req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vapps/"+vmsVApp.ID+"/vms"+query, nil)
+				req.Header.Set("Authorization", "Bearer "+userToken)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			}
+		})
+
+		t.Run("List VMs of nonexistent vApp returns 404", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vapps/urn:vcloud:vapp:99999999-9999-9999-9999-999999999999/vms", nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+
+		t.Run("List VMs with invalid vApp URN returns 400", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vapps/invalid-vapp-id/vms", nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	})
+
 	t.Run("Delete vApp", func(t *testing.T) {
 		// Create a vApp specifically for deletion testing
 		deleteVApp := &models.VApp{
@@ -261,22 +447,33 @@ func TestVAppAPIEndpoints(t *testing.T) {
 		}
 		require.NoError(t, db.DB.Create(deleteVApp).Error)
 
-		t.Run("Delete vApp returns 204", func(t *testing.T) {
+		t.Run("Delete vApp returns 202 with a task", func(t *testing.T) {
 			req, _ := http.NewRequest("DELETE", "/cloudapi/1.0.0/vapps/"+deleteVApp.ID, nil)
 			req.Header.Set("Authorization", "Bearer "+userToken)
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
 
-			assert.Equal(t, http.StatusNoContent, w.Code)
-			assert.Empty(t, w.Body.String())
-
-			// Verify vApp is deleted
-			var count int64
-			db.DB.Model(&models.VApp{}).Where("id = ?", deleteVApp.ID).Count(&count)
-			assert.Equal(t, int64(0), count)
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			var response handlers.DeletionResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, deleteVApp.ID, response.ID)
+			assert.Equal(t, models.VAppStatusDeleting, response.Status)
+			require.NotEmpty(t, response.TaskID)
+			assert.Equal(t, response.TaskHref, w.Header().Get("Location"))
+
+			// The vApp is deleted in the background
+			assert.Eventually(t, func() bool {
+				var count int64
+				db.DB.Model(&models.VApp{}).Where("id = ?", deleteVApp.ID).Count(&count)
+				return count == 0
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Eventually(t, func() bool {
+				var task models.Task
+				return db.DB.Where("id = ?", response.TaskID).First(&task).Error == nil && task.Status == models.TaskStatusSuccess
+			}, 5*time.Second, 10*time.Millisecond)
 		})
 
-		t.Run("Delete vApp with force parameter returns 204", func(t *testing.T) {
+		t.Run("Delete vApp with force parameter returns 202", func(t *testing.T) {
 			// Create another vApp for force deletion testing
 			forceDeleteVApp := &models.VApp{
 				Name:        "force-delete-vapp",
@@ -291,7 +488,7 @@ func TestVAppAPIEndpoints(t *testing.T) {
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
 
-			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, http.StatusAccepted, w.Code)
 		})
 
 		t.Run("Delete vApp with running VMs returns 400", func(t *testing.T) {
@@ -252,6 +252,7 @@ func TestVDCAPIEndpoints(t *testing.T) {
 				"name":        "Updated Test VDC",
 				"description": "Updated description",
 				"isEnabled":   false,
+				"dnsZone":     "Dev.Example.com",
 			}
 
 			jsonData, _ := json.Marshal(updateData)
@@ -270,6 +271,7 @@ func TestVDCAPIEndpoints(t *testing.T) {
 			assert.Equal(t, "Updated Test VDC", response["name"])
 			assert.Equal(t, "Updated description", response["description"])
 			assert.Equal(t, false, response["isEnabled"])
+			assert.Equal(t, "dev.example.com", response["dnsZone"])
 		})
 
 		t.Run("Delete VDC returns 204", func(t *testing.T) {
@@ -416,6 +418,26 @@ func TestVDCAPIEndpoints(t *testing.T) {
 			assert.Equal(t, http.StatusBadRequest, w.Code)
 		})
 
+		t.Run("Create VDC with invalid DNS zone returns 400", func(t *testing.T) {
+			for _, zone := range []string{"localhost", "dev_zone.example.com", "-dev.example.com"} {
+				vdcData := map[string]interface{}{
+					"name":            "DNS VDC",
+					"allocationModel": "Flex",
+					"dnsZone":         zone,
+				}
+
+				jsonData, _ := json.Marshal(vdcData)
+				req, _ := http.NewRequest("POST", fmt.Sprintf("/api/admin/org/%s/vdcs", org.ID), bytes.NewBuffer(jsonData))
+				req.Header.Set("Authorization", "Bearer "+adminToken)
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusBadRequest, w.Code, zone)
+				assert.Contains(t, w.Body.String(), "Invalid DNS zone", zone)
+			}
+		})
+
 	})
 
 	t.Run("Error Scenarios", func(t *testing.T) {
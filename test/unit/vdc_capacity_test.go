@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/api/types"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+func TestVDCCapacity(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	org := &models.Organization{Name: "CapacityOrg", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "capacityuser", Email: "capacity@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	token, err := jwtManager.GenerateWithRole(user.ID, user.Username, org.ID, models.RoleVAppUser)
+	require.NoError(t, err)
+
+	// 8 cores and 16 GB with room for 2 more VMs of the default 2 CPU, 4 GB size
+	limited := &models.VDC{Name: "limited", OrganizationID: org.ID, AllocationModel: models.AllocationPool,
+		CPULimit: 8, CPUUnits: "cores", MemoryLimit: 16384, IsEnabled: true}
+	require.NoError(t, db.DB.Create(limited).Error)
+	unlimited := &models.VDC{Name: "unlimited", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(unlimited).Error)
+
+	vapp := &models.VApp{Name: "capacity-vapp", VDCID: limited.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	require.NoError(t, db.DB.Create(&models.VM{Name: "vm-1", VAppID: vapp.ID, VMName: "vm-1", Namespace: "ns", CPUCount: intPtr(2), MemoryMB: intPtr(8192)}).Error)
+	require.NoError(t, db.DB.Create(&models.VM{Name: "vm-2", VAppID: vapp.ID, VMName: "vm-2", Namespace: "ns", CPUCount: intPtr(4), MemoryMB: intPtr(2048)}).Error)
+
+	get := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+
+	t.Run("Aggregates usage per VDC in one query", func(t *testing.T) {
+		usage, err := repositories.NewVDCRepository(db.DB).ComputeUsageByVDC(context.Background(), []string{limited.ID, unlimited.ID})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]models.ComputeUsage{limited.ID: {VMs: 2, CPUs: 6, MemoryMB: 10240}}, usage)
+	})
+
+	t.Run("List reports VM counts and headroom", func(t *testing.T) {
+		var page types.Page[handlers.VDCResponse]
+		require.NoError(t, json.Unmarshal(get(t, "/cloudapi/1.0.0/vdcs").Body.Bytes(), &page))
+		require.Len(t, page.Values, 2)
+
+		byName := map[string]handlers.VDCResponse{}
+		for _, vdc := range page.Values {
+			byName[vdc.Name] = vdc
+		}
+
+		vdc := byName["limited"]
+		require.NotNil(t, vdc.VMCount)
+		assert.Equal(t, int64(2), *vdc.VMCount)
+		require.NotNil(t, vdc.CPUUsedPct)
+		assert.Equal(t, int64(75), *vdc.CPUUsedPct)
+		require.NotNil(t, vdc.MemoryUsedPct)
+		assert.Equal(t, int64(62), *vdc.MemoryUsedPct)
+		// CPU is the tighter limit: 2 cores left fit one more VM
+		require.NotNil(t, vdc.RemainingCapacityEstimate)
+		assert.Equal(t, int64(1), *vdc.RemainingCapacityEstimate)
+
+		vdc = byName["unlimited"]
+		require.NotNil(t, vdc.VMCount)
+		assert.Equal(t, int64(0), *vdc.VMCount)
+		assert.Nil(t, vdc.CPUUsedPct)
+		assert.Nil(t, vdc.MemoryUsedPct)
+		assert.Nil(t, vdc.RemainingCapacityEstimate)
+	})
+
+	t.Run("Get reports the same headroom", func(t *testing.T) {
+		var vdc handlers.VDCResponse
+		require.NoError(t, json.Unmarshal(get(t, "/cloudapi/1.0.0/vdcs/"+limited.ID).Body.Bytes(), &vdc))
+		require.NotNil(t, vdc.RemainingCapacityEstimate)
+		assert.Equal(t, int64(1), *vdc.RemainingCapacityEstimate)
+	})
+
+	t.Run("Full VDCs have no remaining capacity", func(t *testing.T) {
+		require.NoError(t, db.DB.Create(&models.VM{Name: "vm-3", VAppID: vapp.ID, VMName: "vm-3", Namespace: "ns", CPUCount: intPtr(4), MemoryMB: intPtr(1024)}).Error)
+
+		var vdc handlers.VDCResponse
+		require.NoError(t, json.Unmarshal(get(t, "/cloudapi/1.0.0/vdcs/"+limited.ID).Body.Bytes(), &vdc))
+		assert.Equal(t, int64(125), *vdc.CPUUsedPct)
+		assert.Equal(t, int64(0), *vdc.RemainingCapacityEstimate)
+	})
+}
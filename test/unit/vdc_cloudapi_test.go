@@ -0,0 +1,309 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+)
+
+func TestCloudAPIVDCManagement(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+
+	org := &models.Organization{Name: "cloudapi-vdc-org", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+
+	adminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(adminRole).Error)
+	orgAdminRole := &models.Role{Name: models.RoleOrgAdmin, Description: "Organization Administrator role"}
+	require.NoError(t, db.DB.Create(orgAdminRole).Error)
+
+	admin := &models.User{Username: "vdc-sysadmin", Email: "vdc-sysadmin@example.com", Enabled: true}
+	require.NoError(t, admin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(admin).Error)
+	require.NoError(t, db.DB.Model(admin).Association("Roles").Append(adminRole))
+
+	orgAdmin := &models.User{Username: "vdc-orgadmin", Email: "vdc-orgadmin@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, orgAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(orgAdmin).Error)
+	require.NoError(t, db.DB.Model(orgAdmin).Association("Roles").Append(orgAdminRole))
+
+	adminToken, err := jwtManager.Generate(admin.ID, admin.Username)
+	require.NoError(t, err)
+	orgAdminToken, err := jwtManager.Generate(orgAdmin.ID, orgAdmin.Username)
+	require.NoError(t, err)
+
+	doRequest := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	createBody := map[string]interface{}{
+		"name":            "cloudapi-vdc",
+		"allocationModel": "PayAsYouGo",
+		"isEnabled":       true,
+		"org":             map[string]interface{}{"id": org.ID},
+	}
+
+	t.Run("Create requires the create right", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/vdcs", "", createBody)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = doRequest("POST", "/cloudapi/1.0.0/vdcs", orgAdminToken, createBody)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Insufficient rights", response["message"])
+	})
+
+	t.Run("Create validates the organization reference", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/vdcs", adminToken, map[string]interface{}{
+			"name":            "no-org",
+			"allocationModel": "PayAsYouGo",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = doRequest("POST", "/cloudapi/1.0.0/vdcs", adminToken, map[string]interface{}{
+			"name":            "unknown-org",
+			"allocationModel": "PayAsYouGo",
+			"org":             map[string]interface{}{"id": "urn:vcloud:org:99999999-9999-9999-9999-999999999999"},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	var vdcID string
+	t.Run("Create, update and delete through CloudAPI", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/vdcs", adminToken, createBody)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var created map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		vdcID = created["id"].(string)
+		assert.Equal(t, "cloudapi-vdc", created["name"])
+
+		// The legacy admin route sees the same VDC
+		w = doRequest("GET", "/api/admin/org/"+org.ID+"/vdcs/"+vdcID, adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+vdcID, orgAdminToken, map[string]interface{}{"name": "renamed"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+vdcID, adminToken, map[string]interface{}{"name": "renamed"})
+		require.Equal(t, http.StatusOK, w.Code)
+		var updated map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, "renamed", updated["name"])
+		assert.Equal(t, []interface{}{"bridge", "masquerade"}, updated["allowedInterfaceTypes"])
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+vdcID, adminToken, map[string]interface{}{"allowedInterfaceTypes": []string{"bridge", "sriov"}})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, []interface{}{"bridge", "sriov"}, updated["allowedInterfaceTypes"])
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+vdcID, adminToken, map[string]interface{}{"allowedInterfaceTypes": []string{"macvtap"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = doRequest("DELETE", "/cloudapi/1.0.0/vdcs/"+vdcID, orgAdminToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doRequest("DELETE", "/cloudapi/1.0.0/vdcs/"+vdcID, adminToken, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = doRequest("DELETE", "/cloudapi/1.0.0/vdcs/"+vdcID, adminToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Storage profiles and alert thresholds", func(t *testing.T) {
+		body := map[string]interface{}{
+			"name":            "storage-vdc",
+			"allocationModel": "PayAsYouGo",
+			"org":             map[string]interface{}{"id": org.ID},
+			"storageProfiles": []map[string]interface{}{
+				{"name": "fast", "limit": 2, "units": "GB"},
+				{"name": "slow", "limit": 0},
+			},
+		}
+		w := doRequest("POST", "/cloudapi/1.0.0/vdcs", adminToken, body)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var created map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		id := created["id"].(string)
+		assert.Equal(t, map[string]interface{}{"warning": float64(80), "critical": float64(95)}, created["storageAlertThresholds"])
+
+		w = doRequest("GET", "/cloudapi/1.0.0/vdcs/"+id, adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var fetched struct {
+			VdcStorageProfiles models.VdcStorageProfiles `json:"vdcStorageProfiles"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+		profiles := fetched.VdcStorageProfiles.VdcStorageProfile
+		require.Len(t, profiles, 2)
+		assert.Equal(t, "fast", profiles[0].Name)
+		assert.Equal(t, int64(2048), profiles[0].Limit)
+		assert.Equal(t, "MB", profiles[0].Units)
+		assert.Equal(t, "slow", profiles[1].Name)
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, map[string]interface{}{
+			"storageProfiles":        []map[string]interface{}{{"name": "fast", "limit": 4096}},
+			"storageAlertThresholds": map[string]interface{}{"warning": 70, "critical": 90},
+		})
+		require.Equal(t, http.StatusOK, w.Code)
+		var updated map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, map[string]interface{}{"warning": float64(70), "critical": float64(90)}, updated["storageAlertThresholds"])
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+		require.Len(t, fetched.VdcStorageProfiles.VdcStorageProfile, 1)
+		assert.Equal(t, int64(4096), fetched.VdcStorageProfiles.VdcStorageProfile[0].Limit)
+		assert.False(t, fetched.VdcStorageProfiles.VdcStorageProfile[0].Default)
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, map[string]interface{}{
+			"storageProfiles": []map[string]interface{}{
+				{"name": "fast", "limit": 4096},
+				{"name": "scratch", "default": true, "diskTier": "ephemeral"},
+			},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+		require.Len(t, fetched.VdcStorageProfiles.VdcStorageProfile, 2)
+		assert.True(t, fetched.VdcStorageProfiles.VdcStorageProfile[1].Default)
+		assert.Equal(t, models.DiskTierEphemeral, fetched.VdcStorageProfiles.VdcStorageProfile[1].DiskTier)
+
+		invalid := []map[string]interface{}{
+			{"storageAlertThresholds": map[string]interface{}{"warning": 90, "critical": 90}},
+			{"storageAlertThresholds": map[string]interface{}{"warning": 50, "critical": 101}},
+			{"storageProfiles": []map[string]interface{}{{"name": "fast", "limit": -1}}},
+			{"storageProfiles": []map[string]interface{}{{"name": "fast", "limit": 1, "units": "TB"}}},
+			{"storageProfiles": []map[string]interface{}{{"name": "fast"}, {"name": "fast"}}},
+			{"storageProfiles": []map[string]interface{}{{"name": ""}}},
+			{"storageProfiles": []map[string]interface{}{{"name": "fast", "default": true}, {"name": "slow", "default": true}}},
+			{"storageProfiles": []map[string]interface{}{{"name": "fast", "default": true, "diskTier": "archive"}}},
+			{"storageProfiles": []map[string]interface{}{{"name": "fast", "diskTier": "ephemeral"}}},
+		}
+		for _, body := range invalid {
+			w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, "body %v", body)
+		}
+
+		w = doRequest("DELETE", "/cloudapi/1.0.0/vdcs/"+id, adminToken, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("Compute quota policy", func(t *testing.T) {
+		w := doRequest("POST", "/cloudapi/1.0.0/vdcs", adminToken, map[string]interface{}{
+			"name":               "quota-vdc",
+			"allocationModel":    "AllocationPool",
+			"org":                map[string]interface{}{"id": org.ID},
+			"computeQuotaPolicy": map[string]interface{}{"softLimitPercent": 80, "gracePercent": 10},
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var created map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		id := created["id"].(string)
+		assert.Equal(t, map[string]interface{}{"softLimitPercent": float64(80), "gracePercent": float64(10)}, created["computeQuotaPolicy"])
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, map[string]interface{}{
+			"computeQuotaPolicy": map[string]interface{}{"softLimitPercent": 90, "gracePercent": 0},
+		})
+		require.Equal(t, http.StatusOK, w.Code)
+		var updated map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, map[string]interface{}{"softLimitPercent": float64(90), "gracePercent": float64(0)}, updated["computeQuotaPolicy"])
+
+		for _, policy := range []map[string]interface{}{
+			{"softLimitPercent": 101},
+			{"softLimitPercent": -1},
+			{"gracePercent": 150},
+		} {
+			w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, map[string]interface{}{"computeQuotaPolicy": policy})
+			assert.Equal(t, http.StatusBadRequest, w.Code, "policy %v", policy)
+		}
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, map[string]interface{}{
+			"autoSuspendPolicy": map[string]interface{}{"enabled": true, "idleHours": 8, "action": "powerOff"},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, map[string]interface{}{"enabled": true, "idleHours": float64(8), "cpuThresholdPercent": float64(5), "action": "powerOff"}, updated["autoSuspendPolicy"])
+
+		for _, policy := range []map[string]interface{}{
+			{"enabled": true},
+			{"enabled": true, "idleHours": 1000},
+			{"idleHours": 8, "cpuThresholdPercent": 101},
+			{"idleHours": 8, "action": "delete"},
+		} {
+			w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, map[string]interface{}{"autoSuspendPolicy": policy})
+			assert.Equal(t, http.StatusBadRequest, w.Code, "policy %v", policy)
+		}
+
+		w = doRequest("DELETE", "/cloudapi/1.0.0/vdcs/"+id, adminToken, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("Metadata policy", func(t *testing.T) {
+		policy := map[string]interface{}{
+			"labels":      map[string]interface{}{"cost-center": "cc-1234"},
+			"annotations": map[string]interface{}{"backup.example.com/policy": "daily"},
+		}
+		w := doRequest("POST", "/cloudapi/1.0.0/vdcs", adminToken, map[string]interface{}{
+			"name":            "metadata-vdc",
+			"allocationModel": "AllocationPool",
+			"org":             map[string]interface{}{"id": org.ID},
+			"metadataPolicy":  policy,
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var created map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		id := created["id"].(string)
+		assert.Equal(t, policy, created["metadataPolicy"])
+
+		w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, map[string]interface{}{
+			"metadataPolicy": map[string]interface{}{},
+		})
+		require.Equal(t, http.StatusOK, w.Code)
+		var updated map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, map[string]interface{}{}, updated["metadataPolicy"])
+
+		for _, invalid := range []map[string]interface{}{
+			{"labels": map[string]interface{}{"ssvirt.io/vdc": "x"}},
+			{"labels": map[string]interface{}{"app.kubernetes.io/managed-by": "me"}},
+			{"labels": map[string]interface{}{"cost center": "x"}},
+			{"labels": map[string]interface{}{"cost-center": "not a label value"}},
+			{"annotations": map[string]interface{}{"kubevirt.io/latest-observed-api-version": "v1"}},
+		} {
+			w = doRequest("PUT", "/cloudapi/1.0.0/vdcs/"+id, adminToken, map[string]interface{}{"metadataPolicy": invalid})
+			assert.Equal(t, http.StatusBadRequest, w.Code, "policy %v", invalid)
+		}
+
+		w = doRequest("DELETE", "/cloudapi/1.0.0/vdcs/"+id, adminToken, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("Invalid VDC URN returns 400", func(t *testing.T) {
+		w := doRequest("PUT", "/cloudapi/1.0.0/vdcs/not-a-vdc", adminToken, map[string]interface{}{"name": "x"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Rights are granted by role", func(t *testing.T) {
+		assert.True(t, models.RoleHasRight(models.RoleSystemAdmin, models.RightOrgVdcDelete))
+		assert.True(t, models.RoleHasRight(models.RoleOrgAdmin, models.RightOrgVdcView))
+		assert.False(t, models.RoleHasRight(models.RoleOrgAdmin, models.RightOrgVdcCreate))
+		assert.False(t, models.RoleHasRight(models.RoleVAppUser, models.RightOrgVdcEdit))
+	})
+}
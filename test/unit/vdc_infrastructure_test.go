@@ -0,0 +1,147 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestCollectVDCInfrastructure(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, networkingv1.AddToScheme(scheme))
+
+	t.Run("Reports quota usage, LimitRanges and NetworkPolicies", func(t *testing.T) {
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "vdc-acme-dev", Labels: map[string]string{"app.kubernetes.io/managed-by": "ssvirt"}},
+				Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+			},
+			&corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "vdc-quota", Namespace: "vdc-acme-dev"},
+				Status: corev1.ResourceQuotaStatus{
+					Hard: corev1.ResourceList{corev1.ResourceRequestsMemory: resource.MustParse("8Gi")},
+					Used: corev1.ResourceList{corev1.ResourceRequestsMemory: resource.MustParse("2Gi")},
+				},
+			},
+			&corev1.LimitRange{
+				ObjectMeta: metav1.ObjectMeta{Name: "vdc-limits", Namespace: "vdc-acme-dev"},
+				Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+					Type:    corev1.LimitTypeContainer,
+					Default: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+				}}},
+			},
+			&networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "deny-other-namespaces", Namespace: "vdc-acme-dev"},
+				Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}},
+			},
+			// Objects of other namespaces are not reported
+			&corev1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Name: "vdc-quota", Namespace: "vdc-other"}},
+		).Build()
+
+		infrastructure, err := services.CollectVDCInfrastructure(context.Background(), k8sClient, "vdc-acme-dev")
+		require.NoError(t, err)
+		assert.True(t, infrastructure.Namespace.Exists)
+		assert.Equal(t, "Active", infrastructure.Namespace.Phase)
+		assert.Equal(t, "ssvirt", infrastructure.Namespace.Labels["app.kubernetes.io/managed-by"])
+
+		require.Len(t, infrastructure.ResourceQuotas, 1)
+		quota := infrastructure.ResourceQuotas[0]
+		assert.Equal(t, "vdc-quota", quota.Name)
+		hard := quota.Hard[corev1.ResourceRequestsMemory]
+		used := quota.Used[corev1.ResourceRequestsMemory]
+		assert.Equal(t, "8Gi", hard.String())
+		assert.Equal(t, "2Gi", used.String())
+
+		require.Len(t, infrastructure.LimitRanges, 1)
+		assert.Equal(t, corev1.LimitTypeContainer, infrastructure.LimitRanges[0].Limits[0].Type)
+		require.Len(t, infrastructure.NetworkPolicies, 1)
+		assert.Equal(t, "deny-other-namespaces", infrastructure.NetworkPolicies[0].Name)
+	})
+
+	t.Run("Reports a missing namespace", func(t *testing.T) {
+		k8sClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		infrastructure, err := services.CollectVDCInfrastructure(context.Background(), k8sClient, "vdc-gone")
+		require.NoError(t, err)
+		assert.Equal(t, "vdc-gone", infrastructure.Namespace.Name)
+		assert.False(t, infrastructure.Namespace.Exists)
+		assert.Empty(t, infrastructure.ResourceQuotas)
+	})
+}
+
+func TestVDCInfrastructureAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "InfraOrg", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	vdc := &models.VDC{Name: "infra-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "vdc-infraorg-infra-vdc"}
+	require.NoError(t, db.DB.Create(vdc).Error)
+
+	newRouter := func(k8sService services.KubernetesService) *gin.Engine {
+		vdcHandlers := handlers.NewVDCHandlers(repositories.NewVDCRepository(db.DB), repositories.NewOrganizationRepository(db.DB), repositories.NewUserRepository(db.DB), k8sService)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/api/admin/org/:orgId/vdcs/:vdcId/infrastructure", vdcHandlers.GetVDCInfrastructure)
+		return router
+	}
+	get := func(router *gin.Engine, orgID, vdcID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/admin/org/"+orgID+"/vdcs/"+vdcID+"/infrastructure", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unavailable without Kubernetes", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, get(newRouter(nil), org.ID, vdc.ID).Code)
+	})
+
+	mockK8s := &MockKubernetesService{}
+	router := newRouter(mockK8s)
+
+	t.Run("Returns the live infrastructure", func(t *testing.T) {
+		mockK8s.On("GetVDCInfrastructure", mock.Anything, vdc.Namespace).Return(&services.VDCInfrastructure{
+			Namespace:       services.VDCNamespace{Name: vdc.Namespace, Exists: true, Phase: "Active"},
+			ResourceQuotas:  []services.VDCResourceQuota{{Name: "vdc-quota"}},
+			LimitRanges:     []services.VDCLimitRange{},
+			NetworkPolicies: []services.VDCNetworkPolicy{},
+		}, nil).Once()
+
+		w := get(router, org.ID, vdc.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, vdc.ID, body["vdcId"])
+		assert.Equal(t, true, body["namespace"].(map[string]interface{})["exists"])
+		assert.Len(t, body["resourceQuotas"], 1)
+		mockK8s.AssertExpectations(t)
+	})
+
+	t.Run("Reports Kubernetes failures", func(t *testing.T) {
+		mockK8s.On("GetVDCInfrastructure", mock.Anything, vdc.Namespace).Return(nil, errors.New("connection refused")).Once()
+		assert.Equal(t, http.StatusInternalServerError, get(router, org.ID, vdc.ID).Code)
+	})
+
+	t.Run("Hides VDCs of other organizations", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(router, "urn:vcloud:org:00000000-0000-0000-0000-000000000000", vdc.ID).Code)
+	})
+}
@@ -1,12 +1,22 @@
 package unit
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
 	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
 )
 
 func TestVDCNamespaceUniqueness(t *testing.T) {
@@ -81,3 +91,94 @@ func TestVDCNamespaceUniqueness(t *testing.T) {
 
 	_ = server // Avoid unused variable warning
 }
+
+func TestVDCNamespaceNames(t *testing.T) {
+	t.Run("Short names are readable", func(t *testing.T) {
+		assert.Equal(t, "vdc-acme-dev", models.VDCNamespaceName("ACME", "Dev"))
+		assert.Equal(t, "vdc-my-org-team-a", models.VDCNamespaceName("My_Org", "Team_A"))
+		assert.Equal(t, "vdc-mlaut-default", models.VDCNamespaceName("Ümlaut", "日本"))
+	})
+
+	t.Run("Organization names are shortened", func(t *testing.T) {
+		name := models.VDCNamespaceName("A Very Long Organization Name Incorporated", "dev")
+		assert.Equal(t, "vdc-averylongorganizatio-dev", name)
+	})
+
+	t.Run("Long names are truncated with a hash suffix", func(t *testing.T) {
+		long := strings.Repeat("production-workloads-", 4)
+		first := models.VDCNamespaceName("acme", long+"east")
+		second := models.VDCNamespaceName("acme", long+"west")
+
+		assert.LessOrEqual(t, len(first), 59, "room is left for a collision suffix")
+		assert.NoError(t, models.ValidateNamespaceName(first))
+		assert.NoError(t, models.ValidateNamespaceName(first+"-999"))
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, first, models.VDCNamespaceName("acme", long+"east"), "names are deterministic")
+	})
+
+	t.Run("Invalid names are rejected", func(t *testing.T) {
+		assert.Error(t, models.ValidateNamespaceName(""))
+		assert.Error(t, models.ValidateNamespaceName("Bad_Name"))
+		assert.Error(t, models.ValidateNamespaceName(strings.Repeat("a", 64)))
+	})
+}
+
+func TestVDCCreateSkipsExistingNamespaces(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "ACME", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+
+	mockK8s := &MockKubernetesService{}
+	// A namespace left behind by an earlier VDC still exists in the cluster
+	mockK8s.On("NamespaceExists", mock.Anything, "vdc-acme-dev").Return(true, nil)
+	mockK8s.On("NamespaceExists", mock.Anything, mock.Anything).Return(false, nil)
+	mockK8s.On("CreateNamespaceForVDC", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	vdcHandlers := handlers.NewVDCHandlers(repositories.NewVDCRepository(db.DB), repositories.NewOrganizationRepository(db.DB), repositories.NewUserRepository(db.DB), mockK8s)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/admin/org/:orgId/vdcs", vdcHandlers.CreateVDC)
+
+	body, _ := json.Marshal(map[string]interface{}{"name": "Dev", "allocationModel": "PayAsYouGo"})
+	req, _ := http.NewRequest("POST", "/api/admin/org/"+org.ID+"/vdcs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var vdc models.VDC
+	require.NoError(t, db.DB.Where("name = ?", "Dev").First(&vdc).Error)
+	assert.Equal(t, "vdc-acme-dev-1", vdc.Namespace)
+	mockK8s.AssertCalled(t, "CreateNamespaceForVDC", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMigrateVDCNamespaces(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "ACME", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+
+	valid := &models.VDC{Name: "Prod", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, Namespace: "legacy-prod"}
+	require.NoError(t, db.DB.Create(valid).Error)
+	invalid := &models.VDC{Name: "Dev", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, Namespace: "Legacy_Dev"}
+	require.NoError(t, db.DB.Create(invalid).Error)
+
+	migrated, err := db.MigrateVDCNamespaces(context.Background())
+	require.NoError(t, err)
+	require.Len(t, migrated, 1)
+	assert.Equal(t, invalid.ID, migrated[0].ID)
+	assert.Equal(t, "vdc-acme-dev", migrated[0].Namespace)
+	require.NotNil(t, migrated[0].Organization, "the organization is loaded to create the namespace")
+
+	var reloaded models.VDC
+	require.NoError(t, db.DB.First(&reloaded, "id = ?", invalid.ID).Error)
+	assert.Equal(t, "vdc-acme-dev", reloaded.Namespace)
+	var unchanged models.VDC
+	require.NoError(t, db.DB.First(&unchanged, "id = ?", valid.ID).Error)
+	assert.Equal(t, "legacy-prod", unchanged.Namespace, "valid namespaces are never renamed")
+
+	migrated, err = db.MigrateVDCNamespaces(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, migrated)
+}
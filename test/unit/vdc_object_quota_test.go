@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestObjectQuotaLimits(t *testing.T) {
+	t.Run("Defaults without overrides", func(t *testing.T) {
+		limits := services.ObjectQuotaLimits(services.DefaultObjectQuota(), &models.VDC{})
+		pods := limits[corev1.ResourcePods]
+		pvcs := limits[corev1.ResourcePersistentVolumeClaims]
+		assert.Equal(t, "50", pods.String())
+		assert.Equal(t, "20", pvcs.String())
+		assert.Len(t, limits, 5)
+	})
+
+	t.Run("VDC overrides replace defaults and 0 is unlimited", func(t *testing.T) {
+		vdc := &models.VDC{}
+		vdc.SetObjectQuota(models.ObjectQuota{Pods: intPtr(300), Secrets: intPtr(0)})
+		limits := services.ObjectQuotaLimits(services.DefaultObjectQuota(), vdc)
+		pods := limits[corev1.ResourcePods]
+		assert.Equal(t, "300", pods.String())
+		assert.NotContains(t, limits, corev1.ResourceSecrets)
+		assert.Contains(t, limits, corev1.ResourceConfigMaps)
+	})
+}
+
+func TestVDCObjectQuotaAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "QuotaProfileOrg", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	vdc := &models.VDC{Name: "quota-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "vdc-quotaprofileorg-quota-vdc"}
+	require.NoError(t, db.DB.Create(vdc).Error)
+
+	mockK8s := &MockKubernetesService{}
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vdcHandlers := handlers.NewVDCHandlers(vdcRepo, repositories.NewOrganizationRepository(db.DB), repositories.NewUserRepository(db.DB), mockK8s)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/admin/org/:orgId/vdcs/:vdcId", vdcHandlers.UpdateVDC)
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", "/api/admin/org/"+org.ID+"/vdcs/"+vdc.ID, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Overrides are stored and applied to the namespace", func(t *testing.T) {
+		mockK8s.On("EnsureNamespaceResources", mock.Anything, vdc.Namespace, mock.MatchedBy(func(v *models.VDC) bool {
+			return v.ObjectQuota().Pods != nil && *v.ObjectQuota().Pods == 200
+		})).Return(nil).Once()
+
+		w := update(`{"objectQuota": {"pods": 200}}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response handlers.VDCResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.ObjectQuota)
+		assert.Equal(t, 200, *response.ObjectQuota.Pods)
+		assert.Nil(t, response.ObjectQuota.Secrets)
+		mockK8s.AssertExpectations(t)
+
+		stored, err := vdcRepo.GetByID(vdc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 200, *stored.ObjectQuota().Pods)
+	})
+
+	t.Run("Negative counts are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, update(`{"objectQuota": {"services": -1}}`).Code)
+	})
+
+	t.Run("Other updates leave the ResourceQuota alone", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, update(`{"description": "unchanged quota"}`).Code)
+		mockK8s.AssertNumberOfCalls(t, "EnsureNamespaceResources", 1)
+	})
+
+	t.Run("An empty object restores the defaults", func(t *testing.T) {
+		mockK8s.On("EnsureNamespaceResources", mock.Anything, vdc.Namespace, mock.Anything).Return(nil).Once()
+		w := update(`{"objectQuota": {}}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.NotContains(t, body, "objectQuota")
+	})
+}
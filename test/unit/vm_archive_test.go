@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestVMArchive(t *testing.T) {
+	server, db, jwtManager := setupTestAPIServer(t)
+	router := server.GetRouter()
+	repo := repositories.NewVMArchiveRepository(db.DB)
+	ctx := context.Background()
+
+	sysAdminRole := &models.Role{Name: models.RoleSystemAdmin, Description: "System Administrator role"}
+	require.NoError(t, db.DB.Create(sysAdminRole).Error)
+	sysAdmin := &models.User{Username: "sysadmin", Email: "sysadmin@example.com", Enabled: true}
+	require.NoError(t, sysAdmin.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(sysAdmin).Error)
+	require.NoError(t, db.DB.Model(sysAdmin).Association("Roles").Append(sysAdminRole))
+	adminToken, err := jwtManager.Generate(sysAdmin.ID, sysAdmin.Username)
+	require.NoError(t, err)
+
+	user := &models.User{Username: "plainuser", Email: "plainuser@example.com", Enabled: true}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	userToken, err := jwtManager.Generate(user.ID, user.Username)
+	require.NoError(t, err)
+
+	org := &models.Organization{Name: "ArchiveOrg", DisplayName: "Archive Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	vdc := &models.VDC{Name: "archive-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true, Namespace: "archive-ns"}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "archive-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+
+	now := time.Now()
+	createVM := func(name, status string, updatedAt time.Time) *models.VM {
+		vm := &models.VM{Name: name, VAppID: vapp.ID, Status: status, VMName: name, Namespace: "archive-ns"}
+		vm.SetTags([]string{"web"})
+		require.NoError(t, db.DB.Create(vm).Error)
+		require.NoError(t, db.DB.Model(vm).UpdateColumn("updated_at", updatedAt).Error)
+		return vm
+	}
+	oldDeleted := createVM("old-deleted", "DELETED", now.Add(-40*24*time.Hour))
+	recentDeleted := createVM("recent-deleted", "DELETED", now.Add(-time.Hour))
+	running := createVM("running", "POWERED_ON", now.Add(-40*24*time.Hour))
+	// VMs deleted through the API are soft-deleted
+	softDeleted := createVM("soft-deleted", "POWERED_OFF", now.Add(-40*24*time.Hour))
+	require.NoError(t, db.DB.Model(softDeleted).UpdateColumn("deleted_at", now.Add(-35*24*time.Hour)).Error)
+
+	task := &models.Task{Name: "vmDelete", Operation: models.TaskOperationVMDelete, Status: models.TaskStatusSuccess,
+		OwnerID: oldDeleted.ID, OwnerName: oldDeleted.Name, ResultData: `{"ok":true}`}
+	require.NoError(t, db.DB.Create(task).Error)
+
+	archiver := services.NewVMArchiver(repo, time.Hour, 30*24*time.Hour, 365*24*time.Hour, nil)
+	require.NoError(t, archiver.Run(ctx))
+
+	t.Run("VMs deleted for the archive period are archived with their tasks", func(t *testing.T) {
+		var remaining []models.VM
+		require.NoError(t, db.DB.Unscoped().Order("name").Find(&remaining).Error)
+		require.Len(t, remaining, 2)
+		assert.Equal(t, recentDeleted.ID, remaining[0].ID)
+		assert.Equal(t, running.ID, remaining[1].ID)
+
+		var tasks int64
+		require.NoError(t, db.DB.Model(&models.Task{}).Where("owner_id = ?", oldDeleted.ID).Count(&tasks).Error)
+		assert.Zero(t, tasks)
+
+		count, err := repo.Count(ctx, repositories.ArchivedVMFilter{Namespace: "archive-ns"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	get := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Archived VMs are listed for system administrators", func(t *testing.T) {
+		w := get(http.MethodGet, "/api/admin/archive/vms?vappId="+vapp.ID, adminToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page struct {
+			ResultTotal int                 `json:"resultTotal"`
+			Values      []models.ArchivedVM `json:"values"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, 2, page.ResultTotal)
+		assert.Equal(t, softDeleted.ID, page.Values[0].ID)
+		assert.Equal(t, oldDeleted.ID, page.Values[1].ID)
+
+		w = get(http.MethodGet, "/api/admin/archive/vms", userToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("An archived VM includes its record and tasks", func(t *testing.T) {
+		w := get(http.MethodGet, "/api/admin/archive/vms/"+oldDeleted.ID, adminToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response handlers.ArchivedVMResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "old-deleted", response.VM.Name)
+		assert.Equal(t, "DELETED", response.VM.Status)
+		require.Len(t, response.Tasks, 1)
+		assert.Equal(t, task.ID, response.Tasks[0].ID)
+		assert.JSONEq(t, `{"ok":true}`, string(response.Tasks[0].Result))
+
+		w = get(http.MethodGet, "/api/admin/archive/vms/urn:vcloud:vm:00000000-0000-0000-0000-000000000000", adminToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Restoring moves the VM and its tasks back", func(t *testing.T) {
+		w := get(http.MethodPost, "/api/admin/archive/vms/"+oldDeleted.ID+"/actions/restore", adminToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var restored models.VM
+		require.NoError(t, db.DB.Where("id = ?", oldDeleted.ID).First(&restored).Error)
+		assert.Equal(t, "DELETED", restored.Status)
+		assert.Equal(t, []string{"web"}, restored.TagList())
+		assert.WithinDuration(t, time.Now(), restored.UpdatedAt, time.Minute)
+
+		var restoredTask models.Task
+		require.NoError(t, db.DB.Where("id = ?", task.ID).First(&restoredTask).Error)
+		assert.Equal(t, `{"ok":true}`, restoredTask.ResultData)
+
+		_, err := repo.GetByID(ctx, oldDeleted.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		// The restored VM is not archived again until the archive period passes
+		require.NoError(t, archiver.Run(ctx))
+		require.NoError(t, db.DB.Where("id = ?", oldDeleted.ID).First(&restored).Error)
+	})
+
+	t.Run("VMs whose vApp is gone cannot be restored", func(t *testing.T) {
+		require.NoError(t, db.DB.Model(&models.ArchivedVM{}).Where("id = ?", softDeleted.ID).Update("record",
+			`{"id":"`+softDeleted.ID+`","name":"soft-deleted","vapp_id":"urn:vcloud:vapp:00000000-0000-0000-0000-000000000000"}`).Error)
+		w := get(http.MethodPost, "/api/admin/archive/vms/"+softDeleted.ID+"/actions/restore", adminToken)
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	})
+
+	t.Run("Archived VMs are pruned after the retention period", func(t *testing.T) {
+		require.NoError(t, db.DB.Model(&models.ArchivedVM{}).Where("id = ?", softDeleted.ID).
+			Update("archived_at", now.Add(-400*24*time.Hour)).Error)
+		require.NoError(t, archiver.Run(ctx))
+		count, err := repo.Count(ctx, repositories.ArchivedVMFilter{})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,136 @@
+package unit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestStreamVMConsoleLog(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	launcher := func(name, vmName string, containers ...string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "console-ns", Labels: map[string]string{kubevirtv1.VirtualMachineNameLabel: vmName}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		for _, container := range containers {
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container})
+		}
+		return pod
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		launcher("virt-launcher-logged-abc", "logged", "compute", services.SerialConsoleLogContainer),
+		launcher("virt-launcher-quiet-abc", "quiet", "compute"),
+	).Build()
+	pods := kubefake.NewSimpleClientset().CoreV1()
+	ctx := context.Background()
+
+	t.Run("Streams the guest console log container", func(t *testing.T) {
+		stream, err := services.StreamVMConsoleLog(ctx, reader, pods, "console-ns", "logged", services.ConsoleLogOptions{LimitBytes: 1024})
+		require.NoError(t, err)
+		defer stream.Close()
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		assert.NotEmpty(t, data)
+	})
+
+	t.Run("Reports when serial console logging is disabled", func(t *testing.T) {
+		_, err := services.StreamVMConsoleLog(ctx, reader, pods, "console-ns", "quiet", services.ConsoleLogOptions{})
+		assert.ErrorIs(t, err, services.ErrSerialConsoleLogDisabled)
+	})
+
+	t.Run("Reports when the VM is not running", func(t *testing.T) {
+		_, err := services.StreamVMConsoleLog(ctx, reader, pods, "console-ns", "stopped", services.ConsoleLogOptions{})
+		assert.ErrorIs(t, err, services.ErrVMNotRunning)
+	})
+}
+
+func TestVMConsoleLogAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "ConsoleOrg", DisplayName: "Console Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "consoleuser", Email: "console@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "console-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "console-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	vm := &models.VM{Name: "console-vm", VAppID: vapp.ID, Status: "POWERED_ON", VMName: "console-vm", Namespace: "console-ns"}
+	require.NoError(t, db.DB.Create(vm).Error)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	vmHandlers := handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), nil, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cloudapi/1.0.0/vms/:vm_id/console/log", func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+		vmHandlers.GetVMConsoleLog(c)
+	})
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vms/"+vm.ID+"/console/log"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unavailable without Kubernetes", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, get("").Code)
+	})
+
+	mockK8s := &MockKubernetesService{}
+	vmHandlers.SetConsoleLogs(mockK8s)
+
+	t.Run("Streams the log as plain text within the size limit", func(t *testing.T) {
+		tail := int64(50)
+		mockK8s.On("GetVMConsoleLog", mock.Anything, "console-ns", "console-vm", services.ConsoleLogOptions{LimitBytes: 10, TailLines: &tail}).
+			Return(io.NopCloser(strings.NewReader("[    0.000000] Linux version 6.1")), nil).Once()
+
+		w := get("?limitBytes=10&tailLines=50")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "[    0.000", w.Body.String())
+	})
+
+	t.Run("Rejects invalid limits", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("?limitBytes=0").Code)
+		assert.Equal(t, http.StatusBadRequest, get("?limitBytes=104857600").Code)
+		assert.Equal(t, http.StatusBadRequest, get("?tailLines=abc").Code)
+	})
+
+	t.Run("Maps missing logs to client errors", func(t *testing.T) {
+		mockK8s.On("GetVMConsoleLog", mock.Anything, "console-ns", "console-vm", mock.Anything).
+			Return(nil, services.ErrVMNotRunning).Once()
+		assert.Equal(t, http.StatusConflict, get("").Code)
+
+		mockK8s.On("GetVMConsoleLog", mock.Anything, "console-ns", "console-vm", mock.Anything).
+			Return(nil, services.ErrSerialConsoleLogDisabled).Once()
+		assert.Equal(t, http.StatusNotFound, get("").Code)
+	})
+}
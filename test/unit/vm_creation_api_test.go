@@ -97,9 +97,17 @@ func TestVMCreationAPIEndpoints(t *testing.T) {
 			assert.Equal(t, "Test vApp from template", response.Description)
 			assert.Equal(t, models.VAppStatusInstantiating, response.Status)
 			assert.Equal(t, vdc.ID, response.VDCID)
-			assert.Equal(t, "", response.TemplateID) // No longer auto-generated from description
+			assert.Equal(t, "urn:vcloud:catalogitem:template-123", response.TemplateID)
 			assert.Contains(t, response.ID, "urn:vcloud:vapp:")
 			assert.Contains(t, response.Href, "/cloudapi/1.0.0/vapps/")
+
+			// The TemplateInstance linkage is stored in structured columns
+			var stored models.VApp
+			require.NoError(t, db.DB.Where("id = ?", response.ID).First(&stored).Error)
+			assert.Equal(t, "urn:vcloud:catalogitem:template-123", stored.CatalogItemID)
+			assert.Equal(t, "Ubuntu Template", stored.TemplateName)
+			assert.Equal(t, "test-vapp", stored.TemplateInstanceName)
+			assert.Nil(t, stored.TemplateID)
 		})
 
 		t.Run("Instantiate template with invalid VDC URN returns 400", func(t *testing.T) {
@@ -207,10 +215,12 @@ func TestVMCreationAPIEndpoints(t *testing.T) {
 
 			assert.Equal(t, http.StatusBadRequest, w.Code)
 
-			var response map[string]interface{}
+			var response handlers.APIError
 			err := json.Unmarshal(w.Body.Bytes(), &response)
 			require.NoError(t, err)
-			assert.Contains(t, response["message"], "Invalid request format")
+			assert.Equal(t, "Invalid request body", response.Message)
+			require.Len(t, response.ValidationErrors, 1)
+			assert.Equal(t, handlers.FieldError{Constraint: "json", Message: "Request body is not valid JSON"}, response.ValidationErrors[0])
 		})
 
 		t.Run("Instantiate template with missing required fields returns 400", func(t *testing.T) {
@@ -227,10 +237,15 @@ func TestVMCreationAPIEndpoints(t *testing.T) {
 
 			assert.Equal(t, http.StatusBadRequest, w.Code)
 
-			var response map[string]interface{}
+			var response handlers.APIError
 			err := json.Unmarshal(w.Body.Bytes(), &response)
 			require.NoError(t, err)
-			assert.Contains(t, response["message"], "Invalid request format")
+			assert.Equal(t, "Invalid request body", response.Message)
+			assert.Equal(t, []handlers.FieldError{
+				{Field: "name", Constraint: "required", Message: "name is required"},
+				{Field: "catalogItem.id", Constraint: "required", Message: "catalogItem.id is required"},
+			}, response.ValidationErrors)
+			assert.Equal(t, "name is required; catalogItem.id is required", response.Details)
 		})
 
 		t.Run("Instantiate template with invalid catalog item URN returns 400", func(t *testing.T) {
@@ -378,9 +393,121 @@ func TestVMCreationAPIEndpoints(t *testing.T) {
 			assert.Equal(t, "Test vApp from template by System Admin", response.Description)
 			assert.Equal(t, models.VAppStatusInstantiating, response.Status)
 			assert.Equal(t, vdc.ID, response.VDCID)
-			assert.Equal(t, "", response.TemplateID) // No longer auto-generated from description
+			assert.Equal(t, "urn:vcloud:catalogitem:admin-template-123", response.TemplateID)
 			assert.Contains(t, response.ID, "urn:vcloud:vapp:")
 			assert.Contains(t, response.Href, "/cloudapi/1.0.0/vapps/")
 		})
+
+		t.Run("Instantiate template from catalog in another organization returns 404", func(t *testing.T) {
+			otherOrg := &models.Organization{Name: "Other Organization", IsEnabled: true}
+			require.NoError(t, db.DB.Create(otherOrg).Error)
+			privateCatalog := &models.Catalog{Name: "private-catalog", OrganizationID: otherOrg.ID}
+			require.NoError(t, db.DB.Create(privateCatalog).Error)
+			require.NoError(t, db.DB.Create(&models.CatalogItemRecord{TemplateUID: "uid-ubuntu", Name: "ubuntu", Namespace: "openshift"}).Error)
+
+			instantiate := func(catalogItemID string) int {
+				requestData := handlers.InstantiateTemplateRequest{
+					Name:        "catalog-scoped-vapp",
+					CatalogItem: handlers.CatalogItem{ID: catalogItemID},
+				}
+				jsonData, _ := json.Marshal(requestData)
+				req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/actions/instantiateTemplate", bytes.NewBuffer(jsonData))
+				req.Header.Set("Authorization", "Bearer "+userToken)
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				return w.Code
+			}
+
+			// Catalogs of other organizations are not visible
+			assert.Equal(t, http.StatusNotFound, instantiate(models.CatalogItemURN(privateCatalog.ID, "ubuntu")))
+
+			// Items must exist in a visible catalog
+			assert.Equal(t, http.StatusNotFound, instantiate(models.CatalogItemURN(catalog.ID, "missing")))
+
+			// The user's own catalog resolves the item
+			assert.Equal(t, http.StatusCreated, instantiate(models.CatalogItemURN(catalog.ID, "ubuntu")))
+		})
+
+		t.Run("Instantiate template validates network interfaces", func(t *testing.T) {
+			instantiate := func(name string, nics ...handlers.NetworkInterfaceRequest) *httptest.ResponseRecorder {
+				requestData := handlers.InstantiateTemplateRequest{
+					Name:              name,
+					CatalogItem:       handlers.CatalogItem{ID: "urn:vcloud:catalogitem:template-123", Name: "Ubuntu Template"},
+					NetworkInterfaces: nics,
+				}
+				jsonData, _ := json.Marshal(requestData)
+				req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/actions/instantiateTemplate", bytes.NewBuffer(jsonData))
+				req.Header.Set("Authorization", "Bearer "+userToken)
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				return w
+			}
+
+			w := instantiate("bad-mac", handlers.NetworkInterfaceRequest{Name: "default", MACAddress: "not-a-mac"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Invalid MAC address")
+
+			w = instantiate("multicast-mac", handlers.NetworkInterfaceRequest{Name: "default", MACAddress: "01:00:5e:00:00:01"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			w = instantiate("duplicate-mac",
+				handlers.NetworkInterfaceRequest{Name: "default", MACAddress: "02:00:00:00:00:01"},
+				handlers.NetworkInterfaceRequest{Name: "data", MACAddress: "02:00:00:00:00:01"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			w = instantiate("bad-type", handlers.NetworkInterfaceRequest{Name: "default", InterfaceType: "macvtap"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			// SR-IOV must be enabled on the VDC
+			w = instantiate("sriov", handlers.NetworkInterfaceRequest{Name: "default", InterfaceType: models.InterfaceTypeSRIOV})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Interface type not allowed")
+
+			vdc.SetInterfaceTypes([]models.InterfaceType{models.InterfaceTypeBridge, models.InterfaceTypeSRIOV})
+			require.NoError(t, db.DB.Save(vdc).Error)
+
+			w = instantiate("sriov", handlers.NetworkInterfaceRequest{Name: "default", MACAddress: "02:00:00:00:00:01", InterfaceType: models.InterfaceTypeSRIOV})
+			assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		})
+
+		t.Run("Instantiate template validates disks", func(t *testing.T) {
+			require.NoError(t, db.DB.Create(&models.VDCStorageProfile{VDCID: vdc.ID, Name: "fast-ssd"}).Error)
+
+			instantiate := func(name string, disks ...handlers.DiskRequest) *httptest.ResponseRecorder {
+				requestData := handlers.InstantiateTemplateRequest{
+					Name:        name,
+					CatalogItem: handlers.CatalogItem{ID: "urn:vcloud:catalogitem:template-123", Name: "Ubuntu Template"},
+					Disks:       disks,
+				}
+				jsonData, _ := json.Marshal(requestData)
+				req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/vdcs/"+vdc.ID+"/actions/instantiateTemplate", bytes.NewBuffer(jsonData))
+				req.Header.Set("Authorization", "Bearer "+userToken)
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				return w
+			}
+
+			w := instantiate("bad-tier", handlers.DiskRequest{Name: "rootdisk", Tier: "archive"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Invalid disk tier")
+
+			w = instantiate("duplicate-disk", handlers.DiskRequest{Name: "rootdisk"}, handlers.DiskRequest{Name: "rootdisk"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			w = instantiate("ephemeral-profile", handlers.DiskRequest{Name: "scratch", Tier: models.DiskTierEphemeral, StorageProfile: "fast-ssd"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			w = instantiate("unknown-profile", handlers.DiskRequest{Name: "rootdisk", StorageProfile: "gold"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Storage profile not available")
+
+			w = instantiate("tiered-disks",
+				handlers.DiskRequest{Name: "rootdisk", StorageProfile: "fast-ssd"},
+				handlers.DiskRequest{Name: "scratch", Tier: models.DiskTierEphemeral})
+			assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		})
 	})
 }
@@ -0,0 +1,203 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestCollectVMDiagnostics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+
+	now := time.Now().Truncate(time.Second)
+	event := func(name, kind, object, reason string, lastSeen time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "diag-ns"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object, Namespace: "diag-ns"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         reason,
+			Message:        reason + " happened",
+			Count:          1,
+			LastTimestamp:  metav1.NewTime(lastSeen),
+		}
+	}
+
+	t.Run("Reports launcher pod scheduling and image pull failures", func(t *testing.T) {
+		vmi := &kubevirtv1.VirtualMachineInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "diag-ns"},
+			Status: kubevirtv1.VirtualMachineInstanceStatus{
+				Phase: kubevirtv1.Scheduling,
+				Conditions: []kubevirtv1.VirtualMachineInstanceCondition{
+					{Type: kubevirtv1.VirtualMachineInstanceReady, Status: corev1.ConditionFalse, Reason: "PodNotReady"},
+				},
+			},
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "virt-launcher-web-abcde",
+				Namespace: "diag-ns",
+				Labels:    map[string]string{kubevirtv1.VirtualMachineNameLabel: "web"},
+			},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable", Message: "0/3 nodes are available: 3 Insufficient memory."},
+				},
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "compute", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}}},
+				},
+			},
+		}
+		otherPod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "virt-launcher-db-xyz", Namespace: "diag-ns", Labels: map[string]string{kubevirtv1.VirtualMachineNameLabel: "db"}},
+		}
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			vmi, pod, otherPod,
+			event("e1", "VirtualMachineInstance", "web", "SuccessfulCreate", now.Add(-time.Minute)),
+			event("e2", "Pod", "virt-launcher-web-abcde", "FailedScheduling", now),
+			event("e3", "Pod", "virt-launcher-db-xyz", "Pulled", now),
+		).Build()
+
+		diagnostics, err := services.CollectVMDiagnostics(context.Background(), reader, "diag-ns", "web")
+		require.NoError(t, err)
+
+		assert.Equal(t, "Scheduling", diagnostics.VMIPhase)
+		assert.Equal(t, "virt-launcher-web-abcde", diagnostics.LauncherPod)
+
+		require.Len(t, diagnostics.Problems, 2)
+		assert.Equal(t, services.DiagnosticProblemScheduling, diagnostics.Problems[0].Category)
+		assert.Contains(t, diagnostics.Problems[0].Message, "Insufficient memory")
+		assert.Equal(t, services.DiagnosticProblemImagePull, diagnostics.Problems[1].Category)
+		assert.Equal(t, "ImagePullBackOff", diagnostics.Problems[1].Reason)
+
+		require.Len(t, diagnostics.Conditions, 2)
+		assert.Equal(t, "VirtualMachineInstance", diagnostics.Conditions[0].Source)
+		assert.Equal(t, "Pod", diagnostics.Conditions[1].Source)
+
+		// Only the VM's events, most recent first
+		require.Len(t, diagnostics.Events, 2)
+		assert.Equal(t, "FailedScheduling", diagnostics.Events[0].Reason)
+		assert.Equal(t, "Pod", diagnostics.Events[0].Source)
+		assert.Equal(t, "SuccessfulCreate", diagnostics.Events[1].Reason)
+	})
+
+	t.Run("Reports launcher pods rejected by the VDC quota", func(t *testing.T) {
+		vmi := &kubevirtv1.VirtualMachineInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "big", Namespace: "diag-ns"},
+			Status:     kubevirtv1.VirtualMachineInstanceStatus{Phase: kubevirtv1.Pending},
+		}
+		rejected := event("e1", "VirtualMachineInstance", "big", "FailedCreate", now)
+		rejected.Message = `Error creating pod: pods "virt-launcher-big-abcde" is forbidden: exceeded quota: vdc-quota, requested: requests.memory=16Gi, used: requests.memory=0, limited: requests.memory=8Gi`
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vmi, rejected).Build()
+
+		diagnostics, err := services.CollectVMDiagnostics(context.Background(), reader, "diag-ns", "big")
+		require.NoError(t, err)
+		require.Len(t, diagnostics.Problems, 1)
+		assert.Equal(t, services.DiagnosticProblemQuota, diagnostics.Problems[0].Category)
+		assert.Contains(t, diagnostics.Problems[0].Message, "limited: requests.memory=8Gi")
+	})
+
+	t.Run("Stopped VMs have no instance or launcher pod", func(t *testing.T) {
+		vm := &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "stopped", Namespace: "diag-ns"}}
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			vm,
+			event("e1", "VirtualMachine", "stopped", "FailedCreate", now),
+		).Build()
+
+		diagnostics, err := services.CollectVMDiagnostics(context.Background(), reader, "diag-ns", "stopped")
+		require.NoError(t, err)
+		assert.Empty(t, diagnostics.VMIPhase)
+		assert.Empty(t, diagnostics.LauncherPod)
+		assert.Empty(t, diagnostics.Problems)
+		require.Len(t, diagnostics.Events, 1)
+		assert.Equal(t, "VirtualMachine", diagnostics.Events[0].Source)
+	})
+}
+
+func TestVMDiagnosticsAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "DiagOrg", DisplayName: "Diagnostics Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "diaguser", Email: "diag@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "diag-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "diag-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	vm := &models.VM{Name: "diag-vm", VAppID: vapp.ID, Status: "ERROR", VMName: "diag-vm", Namespace: "diag-ns"}
+	require.NoError(t, db.DB.Create(vm).Error)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	vmHandlers := handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), nil, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cloudapi/1.0.0/vms/:vm_id/diagnostics", func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+		vmHandlers.GetVMDiagnostics(c)
+	})
+	get := func(vmID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vms/"+vmID+"/diagnostics", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unavailable without Kubernetes", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, get(vm.ID).Code)
+	})
+
+	mockK8s := &MockKubernetesService{}
+	vmHandlers.SetDiagnostics(mockK8s)
+
+	t.Run("Returns the VM's diagnostics", func(t *testing.T) {
+		mockK8s.On("GetVMDiagnostics", mock.Anything, "diag-ns", "diag-vm").Return(&services.VMDiagnostics{
+			VMIPhase:   "Scheduling",
+			Problems:   []services.DiagnosticProblem{{Category: services.DiagnosticProblemScheduling, Reason: "Unschedulable"}},
+			Conditions: []services.DiagnosticCondition{},
+			Events:     []services.DiagnosticEvent{},
+		}, nil).Once()
+
+		w := get(vm.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, vm.ID, body["vmId"])
+		assert.Equal(t, "ERROR", body["status"])
+		assert.Equal(t, "Scheduling", body["vmiPhase"])
+		assert.Len(t, body["problems"], 1)
+		mockK8s.AssertExpectations(t)
+	})
+
+	t.Run("Rejects invalid VM IDs", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("urn:vcloud:vdc:"+vdc.ID).Code)
+	})
+
+	t.Run("Hides VMs of other organizations", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("urn:vcloud:vm:00000000-0000-0000-0000-000000000000").Code)
+	})
+}
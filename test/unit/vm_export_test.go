@@ -0,0 +1,222 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	exportv1beta1 "kubevirt.io/api/export/v1beta1"
+	snapshotv1beta1 "kubevirt.io/api/snapshot/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+// completeVMExports plays KubeVirt's part of an export, marking snapshots ready
+// and exports reachable, until ctx is done
+func completeVMExports(ctx context.Context, k8sClient client.Client) {
+	ready := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		snapshots := &snapshotv1beta1.VirtualMachineSnapshotList{}
+		if err := k8sClient.List(ctx, snapshots); err == nil {
+			for i := range snapshots.Items {
+				snapshot := &snapshots.Items[i]
+				if snapshot.Status == nil {
+					snapshot.Status = &snapshotv1beta1.VirtualMachineSnapshotStatus{ReadyToUse: &ready}
+					_ = k8sClient.Update(ctx, snapshot)
+				}
+			}
+		}
+
+		exports := &exportv1beta1.VirtualMachineExportList{}
+		if err := k8sClient.List(ctx, exports); err == nil {
+			for i := range exports.Items {
+				export := &exports.Items[i]
+				if export.Status != nil {
+					continue
+				}
+				base := "https://export.example.com/api/export.kubevirt.io/v1beta1/namespaces/" + export.Namespace +
+					"/virtualmachineexports/" + export.Name + "/volumes/rootdisk/disk"
+				export.Status = &exportv1beta1.VirtualMachineExportStatus{
+					Phase:             exportv1beta1.Ready,
+					TTLExpirationTime: &metav1.Time{Time: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)},
+					Links: &exportv1beta1.VirtualMachineExportLinks{
+						External: &exportv1beta1.VirtualMachineExportLink{
+							Cert: "-----BEGIN CERTIFICATE-----",
+							Volumes: []exportv1beta1.VirtualMachineExportVolume{{
+								Name: "rootdisk",
+								Formats: []exportv1beta1.VirtualMachineExportVolumeFormat{
+									{Format: exportv1beta1.KubeVirtRaw, Url: base + ".img"},
+									{Format: exportv1beta1.KubeVirtGz, Url: base + ".img.gz"},
+								},
+							}},
+						},
+					},
+				}
+				_ = k8sClient.Update(ctx, export)
+			}
+		}
+	}
+}
+
+func newVMExportScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	require.NoError(t, snapshotv1beta1.AddToScheme(scheme))
+	require.NoError(t, exportv1beta1.AddToScheme(scheme))
+	return scheme
+}
+
+func TestVMExportService(t *testing.T) {
+	k8sClient := fake.NewClientBuilder().WithScheme(newVMExportScheme(t)).Build()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go completeVMExports(ctx, k8sClient)
+
+	var steps []int
+	export, err := services.NewVMExportService(k8sClient).ExportVM(t.Context(), "export-ns", "web-1", services.VMExportOptions{
+		Format:  services.VMExportFormatRaw,
+		TTL:     time.Hour,
+		Timeout: 30 * time.Second,
+	}, func(percent int, details string) {
+		steps = append(steps, percent)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{10, 40, 60}, steps)
+	assert.Equal(t, services.VMExportFormatRaw, export.Format)
+	assert.Equal(t, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC), export.ExpiresAt.UTC())
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----", export.CACert)
+	require.Len(t, export.Volumes, 1)
+	assert.Equal(t, "rootdisk", export.Volumes[0].Name)
+
+	exports := &exportv1beta1.VirtualMachineExportList{}
+	require.NoError(t, k8sClient.List(t.Context(), exports, client.InNamespace("export-ns")))
+	require.Len(t, exports.Items, 1)
+	vmExport := exports.Items[0]
+	assert.True(t, strings.HasPrefix(vmExport.Name, "web-1-export-"))
+	assert.Equal(t, time.Hour, vmExport.Spec.TTLDuration.Duration)
+	assert.Equal(t, "VirtualMachineSnapshot", vmExport.Spec.Source.Kind)
+
+	// The link carries the export's own token
+	require.NotNil(t, vmExport.Spec.TokenSecretRef)
+	secret := &corev1.Secret{}
+	require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKey{Namespace: "export-ns", Name: *vmExport.Spec.TokenSecretRef}, secret))
+	token := secret.StringData["token"]
+	require.NotEmpty(t, token)
+	assert.True(t, strings.HasSuffix(export.Volumes[0].URL, "/rootdisk/disk.img?x-kubevirt-export-token="+token), export.Volumes[0].URL)
+
+	// The snapshot and token are removed with the export
+	snapshot := &snapshotv1beta1.VirtualMachineSnapshot{}
+	require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKey{Namespace: "export-ns", Name: vmExport.Name}, snapshot))
+	for _, owned := range []client.Object{snapshot, secret} {
+		require.Len(t, owned.GetOwnerReferences(), 1)
+		assert.Equal(t, "VirtualMachineExport", owned.GetOwnerReferences()[0].Kind)
+		assert.Equal(t, vmExport.Name, owned.GetOwnerReferences()[0].Name)
+	}
+}
+
+func TestVMExportAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "ExportOrg", DisplayName: "Export Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "exportuser", Email: "export@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "export-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "export-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	vm := &models.VM{Name: "web-1", VAppID: vapp.ID, Status: "POWERED_ON", VMName: "web-1", Namespace: "export-ns"}
+	require.NoError(t, db.DB.Create(vm).Error)
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	taskRepo := repositories.NewTaskRepository(db.DB)
+	vmHandlers := handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), nil, nil)
+	vmHandlers.SetTaskStore(taskRepo)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/cloudapi/1.0.0/vms/:vm_id/actions/export", func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+		vmHandlers.ExportVM(c)
+	})
+	post := func(vmID, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/cloudapi/1.0.0/vms/"+vmID+"/actions/export", bytes.NewBufferString(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unavailable without Kubernetes", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, post(vm.ID, "").Code)
+	})
+
+	k8sClient := fake.NewClientBuilder().WithScheme(newVMExportScheme(t)).Build()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go completeVMExports(ctx, k8sClient)
+	vmHandlers.SetExports(services.NewVMExportService(k8sClient), time.Hour, 30*time.Second)
+
+	t.Run("Rejects unknown formats", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post(vm.ID, `{"format":"qcow2"}`).Code)
+	})
+
+	t.Run("Hides VMs of other organizations", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, post("urn:vcloud:vm:00000000-0000-0000-0000-000000000000", "").Code)
+	})
+
+	t.Run("Returns the download links as the task result", func(t *testing.T) {
+		w := post(vm.ID, "")
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		taskID, _ := body["id"].(string)
+		require.NotEmpty(t, taskID)
+		assert.Equal(t, models.TaskOperationVMExport, body["name"])
+		assert.NotEmpty(t, w.Header().Get("Location"))
+
+		var task *models.Task
+		require.Eventually(t, func() bool {
+			var err error
+			task, err = taskRepo.GetByID(t.Context(), taskID)
+			return err == nil && task.Status != models.TaskStatusRunning
+		}, 20*time.Second, 100*time.Millisecond)
+		require.Equal(t, models.TaskStatusSuccess, task.Status, task.Details)
+
+		var result services.VMExport
+		require.NoError(t, json.Unmarshal([]byte(task.ResultData), &result))
+		assert.Equal(t, services.VMExportFormatGzip, result.Format)
+		require.Len(t, result.Volumes, 1)
+		assert.Contains(t, result.Volumes[0].URL, "/rootdisk/disk.img.gz?x-kubevirt-export-token=")
+	})
+}
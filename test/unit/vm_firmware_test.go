@@ -0,0 +1,43 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+
+	templatev1 "github.com/openshift/api/template/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestAddFirmwareIdentity(t *testing.T) {
+	template := &templatev1.Template{Objects: []runtime.RawExtension{
+		{Raw: []byte(`{"apiVersion":"kubevirt.io/v1","kind":"VirtualMachine","metadata":{"name":"web"},"spec":{"template":{"spec":{"domain":{"firmware":{"uuid":"00000000-0000-0000-0000-000000000001","serial":"TEMPLATE"}}}}}}`)},
+		{Raw: []byte(`{"apiVersion":"kubevirt.io/v1","kind":"VirtualMachine","metadata":{"name":"db"},"spec":{"template":{"spec":{}}}}`)},
+		{Raw: []byte(`{"apiVersion":"v1","kind":"Service","metadata":{"name":"svc"}}`)},
+	}}
+	require.NoError(t, services.AddFirmwareIdentity(template))
+
+	identities := make(map[string]bool)
+	for _, obj := range template.Objects[:2] {
+		var vm map[string]interface{}
+		require.NoError(t, json.Unmarshal(obj.Raw, &vm))
+		biosUUID, _, err := unstructured.NestedString(vm, "spec", "template", "spec", "domain", "firmware", "uuid")
+		require.NoError(t, err)
+		serial, _, err := unstructured.NestedString(vm, "spec", "template", "spec", "domain", "firmware", "serial")
+		require.NoError(t, err)
+
+		// Every VM gets its own identity, replacing the one the template sets
+		assert.Len(t, biosUUID, 36)
+		assert.NotEqual(t, "00000000-0000-0000-0000-000000000001", biosUUID)
+		assert.Equal(t, services.FirmwareSerial(biosUUID), serial)
+		assert.False(t, identities[biosUUID])
+		identities[biosUUID] = true
+	}
+	assert.JSONEq(t, `{"apiVersion":"v1","kind":"Service","metadata":{"name":"svc"}}`, string(template.Objects[2].Raw))
+
+	assert.Equal(t, "SSVIRT-5D3F1C1E8F2A4C559B6E0C1F2A3B4C5D", services.FirmwareSerial("5d3f1c1e-8f2a-4c55-9b6e-0c1f2a3b4c5d"))
+}
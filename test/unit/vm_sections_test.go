@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+)
+
+const sectionsVirtualMachine = `{
+  "metadata": {"name": "web-01", "namespace": "sections-ns"},
+  "spec": {
+    "runStrategy": "Always",
+    "dataVolumeTemplates": [
+      {"metadata": {"name": "web-01-root"}, "spec": {"storage": {"resources": {"requests": {"storage": "20Gi"}}}}}
+    ],
+    "template": {
+      "spec": {
+        "hostname": "web01",
+        "domain": {
+          "cpu": {"sockets": 2, "cores": 2, "threads": 1},
+          "memory": {"guest": "8Gi"},
+          "devices": {
+            "disks": [
+              {"name": "rootdisk", "disk": {"bus": "virtio"}},
+              {"name": "cloudinitdisk", "cdrom": {"bus": "sata"}}
+            ],
+            "interfaces": [
+              {"name": "default", "model": "virtio", "macAddress": "02:00:00:00:00:01", "masquerade": {}},
+              {"name": "backend", "model": "e1000e", "state": "down", "bridge": {}}
+            ]
+          }
+        },
+        "networks": [
+          {"name": "default", "pod": {}},
+          {"name": "backend", "multus": {"networkName": "backend-net"}}
+        ],
+        "volumes": [
+          {"name": "rootdisk", "dataVolume": {"name": "web-01-root"}},
+          {"name": "cloudinitdisk", "cloudInitNoCloud": {"userData": "#cloud-config\npassword: secret"}}
+        ]
+      }
+    }
+  }
+}`
+
+func TestVMSections(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "SectionsOrg", DisplayName: "Sections Organization", IsEnabled: true}
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "sectionsuser", Email: "sections@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "sections-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "sections-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	cpus, memory := 2, 2048
+	vm := &models.VM{Name: "web-01", VAppID: vapp.ID, Status: "POWERED_ON", VMName: "web-01", Namespace: "sections-ns", CPUCount: &cpus, MemoryMB: &memory}
+	require.NoError(t, db.DB.Create(vm).Error)
+	offline := &models.VM{Name: "offline", VAppID: vapp.ID, Status: "POWERED_OFF", VMName: "offline", Namespace: "sections-ns", CPUCount: &cpus, MemoryMB: &memory}
+	require.NoError(t, db.DB.Create(offline).Error)
+
+	vmResource := &kubevirtv1.VirtualMachine{}
+	require.NoError(t, json.Unmarshal([]byte(sectionsVirtualMachine), vmResource))
+	scheme := runtime.NewScheme()
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vmResource).Build()
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	vmHandlers := handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), k8sClient, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+	})
+	router.GET("/cloudapi/1.0.0/vms/:vm_id", vmHandlers.GetVM)
+	router.GET("/cloudapi/1.0.0/vms/:vm_id/virtualHardwareSection", vmHandlers.GetVirtualHardwareSection)
+	router.GET("/cloudapi/1.0.0/vms/:vm_id/guestCustomizationSection", vmHandlers.GetGuestCustomizationSection)
+	get := func(path string, into interface{}) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), into))
+		}
+		return w.Code
+	}
+
+	t.Run("VM responses include sections built from the VirtualMachine", func(t *testing.T) {
+		var response handlers.VMResponse
+		require.Equal(t, http.StatusOK, get("/cloudapi/1.0.0/vms/"+vm.ID, &response))
+		require.NotNil(t, response.VirtualHardwareSection)
+		items := response.VirtualHardwareSection.Items
+		require.Len(t, items, 6)
+
+		assert.Equal(t, handlers.ResourceTypeProcessor, items[0].ResourceType)
+		assert.Equal(t, int64(4), items[0].VirtualQuantity)
+		assert.Equal(t, 2, items[0].CoresPerSocket)
+		assert.Equal(t, handlers.ResourceTypeMemory, items[1].ResourceType)
+		assert.Equal(t, int64(8192), items[1].VirtualQuantity)
+
+		assert.Equal(t, handlers.ResourceTypeDiskDrive, items[2].ResourceType)
+		assert.Equal(t, "rootdisk", items[2].ElementName)
+		assert.Equal(t, "virtio", items[2].ResourceSubType)
+		assert.Equal(t, int64(20480), items[2].CapacityMB)
+		assert.Equal(t, handlers.ResourceTypeCDDrive, items[3].ResourceType)
+
+		assert.Equal(t, handlers.ResourceTypeEthernetAdapter, items[4].ResourceType)
+		assert.Equal(t, "02:00:00:00:00:01", items[4].Address)
+		assert.Equal(t, "pod", items[4].Connection)
+		require.NotNil(t, items[4].AutomaticAllocation)
+		assert.True(t, *items[4].AutomaticAllocation)
+		assert.Equal(t, "backend-net", items[5].Connection)
+		assert.Equal(t, 1, items[5].AddressOnParent)
+		assert.False(t, *items[5].AutomaticAllocation)
+
+		require.NotNil(t, response.GuestCustomizationSection)
+		assert.True(t, response.GuestCustomizationSection.Enabled)
+		assert.Equal(t, "web01", response.GuestCustomizationSection.ComputerName)
+		assert.Equal(t, vm.ID, response.GuestCustomizationSection.VirtualMachineID)
+	})
+
+	t.Run("Sections are available on their own", func(t *testing.T) {
+		var hardware handlers.VirtualHardwareSection
+		require.Equal(t, http.StatusOK, get("/cloudapi/1.0.0/vms/"+vm.ID+"/virtualHardwareSection", &hardware))
+		assert.Len(t, hardware.Items, 6)
+
+		var customization map[string]interface{}
+		require.Equal(t, http.StatusOK, get("/cloudapi/1.0.0/vms/"+vm.ID+"/guestCustomizationSection", &customization))
+		assert.Equal(t, "web01", customization["computerName"])
+		assert.NotContains(t, customization, "customizationScript")
+	})
+
+	t.Run("Falls back to the database without a VirtualMachine", func(t *testing.T) {
+		var response handlers.VMResponse
+		require.Equal(t, http.StatusOK, get("/cloudapi/1.0.0/vms/"+offline.ID, &response))
+		items := response.VirtualHardwareSection.Items
+		require.Len(t, items, 2)
+		assert.Equal(t, int64(2), items[0].VirtualQuantity)
+		assert.Equal(t, int64(2048), items[1].VirtualQuantity)
+		assert.False(t, response.GuestCustomizationSection.Enabled)
+		assert.Equal(t, "offline", response.GuestCustomizationSection.ComputerName)
+	})
+
+	t.Run("Hides VMs of other organizations", func(t *testing.T) {
+		var hardware handlers.VirtualHardwareSection
+		assert.Equal(t, http.StatusNotFound, get("/cloudapi/1.0.0/vms/urn:vcloud:vm:00000000-0000-0000-0000-000000000000/virtualHardwareSection", &hardware))
+	})
+}
@@ -0,0 +1,172 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mhrivnak/ssvirt/pkg/api/handlers"
+	"github.com/mhrivnak/ssvirt/pkg/auth"
+	"github.com/mhrivnak/ssvirt/pkg/database/models"
+	"github.com/mhrivnak/ssvirt/pkg/database/repositories"
+	"github.com/mhrivnak/ssvirt/pkg/services"
+)
+
+func TestVMSecurityProfileAPI(t *testing.T) {
+	_, db, _ := setupTestAPIServer(t)
+
+	org := &models.Organization{Name: "SecOrg", DisplayName: "Security Organization", IsEnabled: true}
+	org.SetSecurityPolicy(&models.OrgSecurityPolicy{AllowHostPassthroughCPU: true})
+	require.NoError(t, db.DB.Create(org).Error)
+	user := &models.User{Username: "secuser", Email: "sec@example.com", Enabled: true, OrganizationID: stringPtr(org.ID)}
+	require.NoError(t, user.SetPassword("password123"))
+	require.NoError(t, db.DB.Create(user).Error)
+	vdc := &models.VDC{Name: "sec-vdc", OrganizationID: org.ID, AllocationModel: models.PayAsYouGo, IsEnabled: true}
+	require.NoError(t, db.DB.Create(vdc).Error)
+	vapp := &models.VApp{Name: "sec-vapp", VDCID: vdc.ID, Status: models.VAppStatusDeployed}
+	require.NoError(t, db.DB.Create(vapp).Error)
+	vm := &models.VM{Name: "gpu-vm", VAppID: vapp.ID, Status: "POWERED_ON", VMName: "gpu-vm", Namespace: "sec-ns"}
+	require.NoError(t, db.DB.Create(vm).Error)
+	plainVM := &models.VM{Name: "plain-vm", VAppID: vapp.ID, Status: "POWERED_OFF", VMName: "plain-vm", Namespace: "sec-ns"}
+	require.NoError(t, db.DB.Create(plainVM).Error)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, kubevirtv1.AddToScheme(scheme))
+	gpuVM := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-vm", Namespace: "sec-ns"},
+		Spec: kubevirtv1.VirtualMachineSpec{
+			Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kubevirtv1.VirtualMachineInstanceSpec{
+					Domain: kubevirtv1.DomainSpec{
+						CPU: &kubevirtv1.CPU{Model: kubevirtv1.CPUModeHostPassthrough},
+						Devices: kubevirtv1.Devices{
+							GPUs: []kubevirtv1.GPU{{Name: "gpu1", DeviceName: "nvidia.com/GA102GL_A10"}},
+						},
+					},
+					Tolerations: []corev1.Toleration{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+				},
+			},
+		},
+	}
+	launcher := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "virt-launcher-gpu-vm-abcde",
+			Namespace: "sec-ns",
+			Labels:    map[string]string{kubevirtv1.VirtualMachineNameLabel: "gpu-vm"},
+		},
+		Spec: corev1.PodSpec{HostNetwork: true},
+	}
+	plain := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "plain-vm", Namespace: "sec-ns"},
+		Spec:       kubevirtv1.VirtualMachineSpec{Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{}},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gpuVM, launcher, plain).Build()
+
+	vdcRepo := repositories.NewVDCRepository(db.DB)
+	vappRepo := repositories.NewVAppRepository(db.DB)
+	vmRepo := repositories.NewVMRepository(db.DB)
+	vmHandlers := handlers.NewVMHandlers(vmRepo, vappRepo, vdcRepo, auth.NewAccessControl(vdcRepo, vappRepo, vmRepo), nil, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cloudapi/1.0.0/vms/:vm_id/securityProfile", func(c *gin.Context) {
+		c.Set(auth.ClaimsContextKey, &auth.Claims{UserID: user.ID})
+		vmHandlers.GetVMSecurityProfile(c)
+	})
+	get := func(vmID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vms/"+vmID+"/securityProfile", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Unavailable without Kubernetes", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, get(vm.ID).Code)
+	})
+
+	orgRepo := repositories.NewOrganizationRepository(db.DB)
+	vmHandlers.SetSecurityProfiles(services.NewVMSecurityService(reader), orgRepo)
+
+	t.Run("Flags settings disallowed by the organization's policy", func(t *testing.T) {
+		w := get(vm.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body handlers.VMSecurityProfileResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, vm.ID, body.VMID)
+		require.NotNil(t, body.Policy)
+		assert.True(t, body.Policy.AllowHostPassthroughCPU)
+
+		allowed := map[string]bool{}
+		for _, item := range body.Items {
+			allowed[item.Setting+" "+item.Detail] = item.Allowed
+		}
+		assert.Equal(t, map[string]bool{
+			"privilegedDevice GPU gpu1 (nvidia.com/GA102GL_A10)":                             false,
+			"hostPassthroughCpu CPU model host-passthrough":                                  true,
+			"toleration dedicated=gpu:NoSchedule":                                            false,
+			"hostNetwork virt-launcher pod virt-launcher-gpu-vm-abcde uses the host network": false,
+		}, allowed)
+		assert.Equal(t, 3, body.Violations)
+	})
+
+	t.Run("VMs without privileged settings have an empty profile", func(t *testing.T) {
+		w := get(plainVM.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, []interface{}{}, body["items"])
+		assert.Equal(t, float64(0), body["violations"])
+	})
+
+	t.Run("Everything is allowed without a policy", func(t *testing.T) {
+		org.SetSecurityPolicy(nil)
+		require.NoError(t, db.DB.Save(org).Error)
+
+		w := get(vm.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body handlers.VMSecurityProfileResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Nil(t, body.Policy)
+		assert.Len(t, body.Items, 4)
+		assert.Zero(t, body.Violations)
+	})
+
+	t.Run("Organizations without a policy inherit their parent's", func(t *testing.T) {
+		parent := &models.Organization{Name: "SecParent", IsEnabled: true}
+		parent.SetSecurityPolicy(&models.OrgSecurityPolicy{AllowHostNetwork: true})
+		require.NoError(t, db.DB.Create(parent).Error)
+		require.NoError(t, db.DB.Model(org).Update("parent_org_id", parent.ID).Error)
+
+		// Inheritance follows hierarchical access
+		w := get(vm.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body handlers.VMSecurityProfileResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Nil(t, body.Policy)
+
+		orgRepo.SetHierarchicalAccess(true)
+		defer orgRepo.SetHierarchicalAccess(false)
+		w = get(vm.ID)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		body = handlers.VMSecurityProfileResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		require.NotNil(t, body.Policy)
+		assert.True(t, body.Policy.AllowHostNetwork)
+		assert.Equal(t, 3, body.Violations)
+	})
+
+	t.Run("Hides VMs of other organizations", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("urn:vcloud:vm:00000000-0000-0000-0000-000000000000").Code)
+	})
+}
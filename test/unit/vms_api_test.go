@@ -1,10 +1,12 @@
 package unit
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -70,6 +72,7 @@ func TestVMAPIEndpoints(t *testing.T) {
 		Description: "First test VM",
 		VAppID:      vapp.ID,
 		Status:      "POWERED_ON",
+		HealthState: models.HealthStateDegraded,
 		VMName:      "test-vm-1",
 		Namespace:   "test-ns",
 		CPUCount:    intPtr(2),
@@ -122,6 +125,7 @@ func TestVMAPIEndpoints(t *testing.T) {
 			assert.Equal(t, "test-vm-1", response.Name)
 			assert.Equal(t, "First test VM", response.Description)
 			assert.Equal(t, "POWERED_ON", response.Status)
+			assert.Equal(t, models.HealthStateDegraded, response.HealthState)
 			assert.Equal(t, vapp.ID, response.VAppID)
 			assert.Equal(t, "Ubuntu Linux (64-bit)", response.GuestOS)
 			assert.Equal(t, 2, response.Hardware.NumCPUs)
@@ -172,9 +176,37 @@ func TestVMAPIEndpoints(t *testing.T) {
 			assert.Equal(t, "minimal-vm", response.Name)
 			assert.Equal(t, "Virtual machine minimal-vm", response.Description) // Default description
 			assert.Equal(t, "SUSPENDED", response.Status)
-			assert.Equal(t, "Ubuntu Linux (64-bit)", response.GuestOS) // Default guest OS
-			assert.Equal(t, 2, response.Hardware.NumCPUs)              // Default CPU count
-			assert.Equal(t, 4096, response.Hardware.MemoryMB)          // Default memory
+			assert.Equal(t, models.HealthStateUnknown, response.HealthState) // Not evaluated yet
+			assert.Equal(t, "Ubuntu Linux (64-bit)", response.GuestOS)       // Default guest OS
+			assert.Equal(t, 2, response.Hardware.NumCPUs)                    // Default CPU count
+			assert.Equal(t, 4096, response.Hardware.MemoryMB)                // Default memory
+		})
+
+		t.Run("Get VM with an older API version omits newer fields", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vms/"+vm1.ID, nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			req.Header.Set("Accept", "application/json;version=38.0")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json;version=38.0", w.Header().Get("Content-Type"))
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			require.NoError(t, err)
+			assert.Equal(t, vm1.ID, response["id"])
+			assert.NotContains(t, response, "healthState")
+		})
+
+		t.Run("Get VM with an unsupported API version returns 406", func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/cloudapi/1.0.0/vms/"+vm1.ID, nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			req.Header.Set("Accept", "application/json;version=5.1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotAcceptable, w.Code)
 		})
 
 		t.Run("Get VM with invalid URN returns 400", func(t *testing.T) {
@@ -230,6 +262,202 @@ func TestVMAPIEndpoints(t *testing.T) {
 		})
 	})
 
+	t.Run("Update VM", func(t *testing.T) {
+		patchVM := func(vmID, body, token string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("PATCH", "/cloudapi/1.0.0/vms/"+vmID, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		t.Run("Update name and description returns 200", func(t *testing.T) {
+			w := patchVM(vm2.ID, `{"name":"  renamed-vm  ","description":"Renamed VM"}`, userToken)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response handlers.VMResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "renamed-vm", response.Name)
+			assert.Equal(t, "Renamed VM", response.Description)
+
+			var stored models.VM
+			require.NoError(t, db.DB.Where("id = ?", vm2.ID).First(&stored).Error)
+			assert.Equal(t, "renamed-vm", stored.Name)
+			assert.Equal(t, "Renamed VM", stored.Description)
+			assert.Equal(t, "test-vm-2", stored.VMName)
+		})
+
+		t.Run("Update description only leaves name unchanged", func(t *testing.T) {
+			w := patchVM(vm2.ID, `{"description":"Only description"}`, userToken)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var stored models.VM
+			require.NoError(t, db.DB.Where("id = ?", vm2.ID).First(&stored).Error)
+			assert.Equal(t, "renamed-vm", stored.Name)
+			assert.Equal(t, "Only description", stored.Description)
+		})
+
+		t.Run("Update run strategy returns 200", func(t *testing.T) {
+			w := patchVM(vm2.ID, `{"runStrategy":"RerunOnFailure"}`, userToken)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var response handlers.VMResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, models.VMRunStrategyRerunOnFailure, response.RunStrategy)
+
+			var stored models.VM
+			require.NoError(t, db.DB.Where("id = ?", vm2.ID).First(&stored).Error)
+			assert.Equal(t, models.VMRunStrategyRerunOnFailure, stored.RunStrategy)
+			assert.Empty(t, stored.DesiredPowerState, "stopped VMs keep no desired power state")
+
+			// Halted also powers the VM off
+			w = patchVM(vm2.ID, `{"runStrategy":"Halted"}`, userToken)
+			require.Equal(t, http.StatusOK, w.Code)
+			require.NoError(t, db.DB.Where("id = ?", vm2.ID).First(&stored).Error)
+			assert.Equal(t, models.VMRunStrategyHalted, stored.RunStrategy)
+			assert.Equal(t, models.VMPowerStateOff, stored.DesiredPowerState)
+		})
+
+		t.Run("Invalid run strategy returns 400", func(t *testing.T) {
+			w := patchVM(vm2.ID, `{"runStrategy":"Once"}`, userToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "Invalid VM run strategy", response["message"])
+		})
+
+		t.Run("Empty request body returns 400", func(t *testing.T) {
+			w := patchVM(vm2.ID, `{}`, userToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+
+		t.Run("Blank name returns 400", func(t *testing.T) {
+			w := patchVM(vm2.ID, `{"name":"   "}`, userToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Contains(t, response["message"], "VM name cannot be empty")
+		})
+
+		t.Run("Invalid URN returns 400", func(t *testing.T) {
+			w := patchVM("invalid-vm-id", `{"name":"x"}`, userToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+
+		t.Run("Nonexistent VM returns 404", func(t *testing.T) {
+			w := patchVM("urn:vcloud:vm:99999999-9999-9999-9999-999999999999", `{"name":"x"}`, userToken)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+
+		t.Run("Unauthenticated request returns 401", func(t *testing.T) {
+			w := patchVM(vm2.ID, `{"name":"x"}`, "")
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	})
+
+	t.Run("Replace VM", func(t *testing.T) {
+		putVM := func(vmID, body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("PUT", "/cloudapi/1.0.0/vms/"+vmID, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		t.Run("Replace name and clear description returns 200", func(t *testing.T) {
+			w := putVM(vm2.ID, `{"name":"replaced-vm"}`)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var stored models.VM
+			require.NoError(t, db.DB.Where("id = ?", vm2.ID).First(&stored).Error)
+			assert.Equal(t, "replaced-vm", stored.Name)
+			assert.Empty(t, stored.Description)
+		})
+
+		t.Run("Missing name returns 400", func(t *testing.T) {
+			w := putVM(vm2.ID, `{"description":"no name"}`)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Contains(t, response["message"], "VM name is required")
+		})
+
+		t.Run("Nonexistent VM returns 404", func(t *testing.T) {
+			w := putVM("urn:vcloud:vm:99999999-9999-9999-9999-999999999999", `{"name":"x"}`)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	})
+
+	t.Run("Delete VM", func(t *testing.T) {
+		deleteVM := func(path string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("DELETE", "/cloudapi/1.0.0/vms/"+path, nil)
+			req.Header.Set("Authorization", "Bearer "+userToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		runningVM := &models.VM{
+			Name:      "running-vm",
+			VAppID:    vapp.ID,
+			Status:    "POWERED_ON",
+			VMName:    "running-vm",
+			Namespace: "test-ns",
+		}
+		require.NoError(t, db.DB.Create(runningVM).Error)
+
+		t.Run("Delete powered-on VM without force returns 400", func(t *testing.T) {
+			w := deleteVM(runningVM.ID)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var stored models.VM
+			require.NoError(t, db.DB.Where("id = ?", runningVM.ID).First(&stored).Error)
+			assert.Equal(t, "POWERED_ON", stored.Status)
+		})
+
+		t.Run("Delete powered-on VM with force returns 202 with a task", func(t *testing.T) {
+			w := deleteVM(runningVM.ID + "?force=true")
+			assert.Equal(t, http.StatusAccepted, w.Code)
+
+			var response handlers.DeletionResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "DELETING", response.Status)
+			require.NotEmpty(t, response.TaskID)
+			assert.Equal(t, response.TaskHref, w.Header().Get("Location"))
+
+			// The VM is deleted in the background
+			assert.Eventually(t, func() bool {
+				var count int64
+				db.DB.Model(&models.VM{}).Where("id = ?", runningVM.ID).Count(&count)
+				return count == 0
+			}, 5*time.Second, 10*time.Millisecond)
+
+			var task models.Task
+			require.NoError(t, db.DB.Where("id = ?", response.TaskID).First(&task).Error)
+			assert.Equal(t, models.TaskOperationVMDelete, task.Name)
+			assert.Eventually(t, func() bool {
+				return db.DB.Where("id = ?", response.TaskID).First(&task).Error == nil && task.Status == models.TaskStatusSuccess
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+
+		t.Run("Delete already deleted VM returns 404", func(t *testing.T) {
+			w := deleteVM(runningVM.ID)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+
+		t.Run("Delete with invalid URN returns 400", func(t *testing.T) {
+			w := deleteVM("invalid-vm-id")
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	})
+
 	t.Run("Access Control", func(t *testing.T) {
 		// Create another organization and user to test access control
 		otherOrg := &models.Organization{
@@ -267,6 +495,25 @@ func TestVMAPIEndpoints(t *testing.T) {
 			require.NoError(t, err)
 			assert.Contains(t, response["message"], "VM access denied")
 		})
+
+		t.Run("Delete VM from different organization returns 403", func(t *testing.T) {
+			req, _ := http.NewRequest("DELETE", "/cloudapi/1.0.0/vms/"+vm2.ID, nil)
+			req.Header.Set("Authorization", "Bearer "+otherUserToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		})
+
+		t.Run("Update VM from different organization returns 403", func(t *testing.T) {
+			req, _ := http.NewRequest("PATCH", "/cloudapi/1.0.0/vms/"+vm1.ID, bytes.NewBufferString(`{"name":"stolen"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+otherUserToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		})
 	})
 }
 